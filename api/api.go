@@ -87,6 +87,20 @@ func HttpPOSTAuthenticated(url string, data string, password string) (resp *http
 	return http.DefaultClient.Do(req)
 }
 
+// RecoverPanics is middleware that recovers from panics in h. The panic is
+// reported as an alert, and the caller receives an internal server error.
+func RecoverPanics(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		defer func() {
+			if r := recover(); r != nil {
+				build.RecordPanic(r)
+				WriteError(w, Error{"internal error when calling " + req.URL.Path}, http.StatusInternalServerError)
+			}
+		}()
+		h.ServeHTTP(w, req)
+	})
+}
+
 // RequireUserAgent is middleware that requires all requests to set a
 // UserAgent that contains the specified string.
 func RequireUserAgent(h http.Handler, ua string) http.Handler {
@@ -118,6 +132,29 @@ func RequirePassword(h httprouter.Handle, password string) httprouter.Handle {
 	}
 }
 
+// ApprovalRealm is the HTTP basic auth realm of the calls that require the
+// approval password, so that clients can tell which password to ask for.
+const ApprovalRealm = "SiaApproval"
+
+// requireApprovalPassword is middleware that requires a request to
+// authenticate with the approval password using HTTP basic auth. Unlike
+// RequirePassword, requests are refused if no approval password has been set.
+func (api *API) requireApprovalPassword(h httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		if api.approvalPassword == "" {
+			WriteError(w, Error{"approvals are disabled because siad has no approval password"}, http.StatusUnauthorized)
+			return
+		}
+		_, pass, ok := req.BasicAuth()
+		if !ok || pass != api.approvalPassword {
+			w.Header().Set("WWW-Authenticate", "Basic realm=\""+ApprovalRealm+"\"")
+			WriteError(w, Error{"approval authentication failed."}, http.StatusUnauthorized)
+			return
+		}
+		h(w, req, ps)
+	}
+}
+
 // API encapsulates a collection of modules and implements a http.Handler
 // to access their methods.
 type API struct {
@@ -130,9 +167,21 @@ type API struct {
 	tpool    modules.TransactionPool
 	wallet   modules.Wallet
 
+	// approvalPassword authenticates the approval of large sends. It must be
+	// different from the API password, so that no single party can both
+	// initiate and approve a send.
+	approvalPassword string
+
 	router http.Handler
 }
 
+// SetApprovalPassword sets the password required to approve large sends and
+// to change the wallet's approval policy. It should be called before the API
+// begins serving requests.
+func (api *API) SetApprovalPassword(password string) {
+	api.approvalPassword = password
+}
+
 // api.ServeHTTP implements the http.Handler interface.
 func (api *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	api.router.ServeHTTP(w, r)
@@ -162,20 +211,39 @@ func New(requiredUserAgent string, requiredPassword string, cs modules.Consensus
 	if api.cs != nil {
 		router.GET("/consensus", api.consensusHandler)
 		router.POST("/consensus/validate/transactionset", api.consensusValidateTransactionsetHandler)
+		router.GET("/consensus/proof/:kind/:id", api.consensusProofHandler)
 	}
 
 	// Explorer API Calls
 	if api.explorer != nil {
 		router.GET("/explorer", api.explorerHandler)
+		router.GET("/explorer/alerts", api.explorerAlertsHandler)
 		router.GET("/explorer/blocks/:height", api.explorerBlocksHandler)
+		router.GET("/explorer/expirations", api.explorerExpirationsHandler)
 		router.GET("/explorer/hashes/:hash", api.explorerHashHandler)
+		router.GET("/explorer/hostmetrics", api.explorerHostMetricsHandler)
 	}
 
 	// Gateway API Calls
 	if api.gateway != nil {
 		router.GET("/gateway", api.gatewayHandler)
+		router.POST("/gateway", RequirePassword(api.gatewayHandlerPOST, requiredPassword))
+		router.GET("/gateway/anchors", api.gatewayAnchorsHandlerGET)
+		router.POST("/gateway/anchors", RequirePassword(api.gatewayAnchorsHandlerPOST, requiredPassword))
+		router.GET("/gateway/filter", api.gatewayFilterHandlerGET)
+		router.POST("/gateway/filter", RequirePassword(api.gatewayFilterHandlerPOST, requiredPassword))
+		router.GET("/gateway/limits", api.gatewayLimitsHandlerGET)
+		router.POST("/gateway/limits", RequirePassword(api.gatewayLimitsHandlerPOST, requiredPassword))
+		router.POST("/gateway/offline", RequirePassword(api.gatewayOfflineHandler, requiredPassword))
+		router.POST("/gateway/online", RequirePassword(api.gatewayOnlineHandler, requiredPassword))
+		router.GET("/gateway/peers", api.gatewayPeersHandler)
+		router.GET("/gateway/rpctimeouts", api.gatewayRPCTimeoutsHandlerGET)
+		router.POST("/gateway/rpctimeouts", RequirePassword(api.gatewayRPCTimeoutsHandlerPOST, requiredPassword))
+		router.GET("/gateway/topology", api.gatewayTopologyHandler)
+		router.GET("/gateway/transports", api.gatewayTransportsHandler)
 		router.POST("/gateway/connect/:netaddress", RequirePassword(api.gatewayConnectHandler, requiredPassword))
 		router.POST("/gateway/disconnect/:netaddress", RequirePassword(api.gatewayDisconnectHandler, requiredPassword))
+		router.POST("/gateway/forgetkey/:netaddress", RequirePassword(api.gatewayForgetKeyHandler, requiredPassword))
 	}
 
 	// Host API Calls
@@ -184,12 +252,31 @@ func New(requiredUserAgent string, requiredPassword string, cs modules.Consensus
 		router.GET("/host", api.hostHandlerGET)                                                   // Get the host status.
 		router.POST("/host", RequirePassword(api.hostHandlerPOST, requiredPassword))              // Change the settings of the host.
 		router.POST("/host/announce", RequirePassword(api.hostAnnounceHandler, requiredPassword)) // Announce the host to the network.
+		router.GET("/host/abuse", api.hostAbuseHandlerGET)
+		router.POST("/host/abuse/override", RequirePassword(api.hostAbuseOverrideHandler, requiredPassword))
+		router.POST("/host/backup", RequirePassword(api.hostBackupHandler, requiredPassword))
+		router.GET("/host/bandwidth", api.hostBandwidthHandlerGET)
+		router.GET("/host/contracts", api.hostContractsHandlerGET)
+		router.GET("/host/contracts/:id", api.hostContractHandlerGET)
+		router.GET("/host/financials", api.hostFinancialsHandlerGET)
+		router.GET("/host/gc", api.hostGCHandlerGET)
+		router.POST("/host/gc", RequirePassword(api.hostGCHandlerPOST, requiredPassword))
+		router.GET("/host/reachability", api.hostReachabilityHandlerGET)
+		router.POST("/host/restore", RequirePassword(api.hostRestoreHandler, requiredPassword))
+		router.GET("/host/score", api.hostScoreHandlerGET)
 
 		// Calls pertaining to the storage manager that the host uses.
 		router.GET("/host/storage", api.storageHandler)
+		router.GET("/host/storage/access", api.storageAccessHandler)
 		router.POST("/host/storage/folders/add", RequirePassword(api.storageFoldersAddHandler, requiredPassword))
+		router.POST("/host/storage/folders/move", RequirePassword(api.storageFoldersMoveHandler, requiredPassword))
+		router.GET("/host/storage/folders/preflight", RequirePassword(api.storageFoldersPreflightHandler, requiredPassword))
 		router.POST("/host/storage/folders/remove", RequirePassword(api.storageFoldersRemoveHandler, requiredPassword))
 		router.POST("/host/storage/folders/resize", RequirePassword(api.storageFoldersResizeHandler, requiredPassword))
+		router.POST("/host/storage/folders/tier", RequirePassword(api.storageFoldersTierHandler, requiredPassword))
+		router.POST("/host/storage/folders/writepolicy", RequirePassword(api.storageFoldersWritePolicyHandler, requiredPassword))
+		router.GET("/host/storage/scan", api.storageScanHandlerGET)
+		router.POST("/host/storage/scan", RequirePassword(api.storageScanHandlerPOST, requiredPassword))
 		router.POST("/host/storage/sectors/delete/:merkleroot", RequirePassword(api.storageSectorsDeleteHandler, requiredPassword))
 	}
 
@@ -209,20 +296,28 @@ func New(requiredUserAgent string, requiredPassword string, cs modules.Consensus
 		router.GET("/renter/contracts", api.renterContractsHandler)
 		router.GET("/renter/downloads", api.renterDownloadsHandler)
 		router.GET("/renter/files", api.renterFilesHandler)
+		router.GET("/renter/gc", api.renterGCHandlerGET)
+		router.POST("/renter/handoff", RequirePassword(api.renterHandoffHandler, requiredPassword))
+		router.POST("/renter/gc", RequirePassword(api.renterGCHandlerPOST, requiredPassword))
+		router.GET("/renter/manifest", api.renterManifestHandler)
 		router.GET("/renter/prices", api.renterPricesHandler)
+		router.GET("/renter/receipts", api.renterReceiptsHandler)
+		router.POST("/renter/pause", RequirePassword(api.renterPauseHandler, requiredPassword))
+		router.POST("/renter/resume", RequirePassword(api.renterResumeHandler, requiredPassword))
 
-		// TODO: re-enable these routes once the new .sia format has been
-		// standardized and implemented.
-		// router.POST("/renter/load", RequirePassword(api.renterLoadHandler, requiredPassword))
-		// router.POST("/renter/loadascii", RequirePassword(api.renterLoadAsciiHandler, requiredPassword))
-		// router.GET("/renter/share", RequirePassword(api.renterShareHandler, requiredPassword))
-		// router.GET("/renter/shareascii", RequirePassword(api.renterShareAsciiHandler, requiredPassword))
+		router.POST("/renter/load", RequirePassword(api.renterLoadHandler, requiredPassword))
+		router.POST("/renter/loadascii", RequirePassword(api.renterLoadAsciiHandler, requiredPassword))
+		router.GET("/renter/share", RequirePassword(api.renterShareHandler, requiredPassword))
+		router.GET("/renter/shareascii", RequirePassword(api.renterShareAsciiHandler, requiredPassword))
 
 		router.POST("/renter/delete/*siapath", RequirePassword(api.renterDeleteHandler, requiredPassword))
 		router.GET("/renter/download/*siapath", RequirePassword(api.renterDownloadHandler, requiredPassword))
 		router.GET("/renter/downloadasync/*siapath", RequirePassword(api.renterDownloadAsyncHandler, requiredPassword))
+		router.GET("/renter/receipt/*siapath", api.renterReceiptHandler)
 		router.POST("/renter/rename/*siapath", RequirePassword(api.renterRenameHandler, requiredPassword))
+		router.GET("/renter/stream/*siapath", RequirePassword(api.renterStreamHandler, requiredPassword))
 		router.POST("/renter/upload/*siapath", RequirePassword(api.renterUploadHandler, requiredPassword))
+		router.POST("/renter/uploadstream/*siapath", RequirePassword(api.renterUploadStreamHandler, requiredPassword))
 
 		// HostDB endpoints.
 		router.GET("/hostdb/active", api.hostdbActiveHandler)
@@ -232,8 +327,16 @@ func New(requiredUserAgent string, requiredPassword string, cs modules.Consensus
 
 	// TransactionPool API Calls
 	if api.tpool != nil {
-		// TODO: re-enable this route once the transaction pool API has been finalized
-		//router.GET("/transactionpool/transactions", api.transactionpoolTransactionsHandler)
+		router.GET("/transactionpool/doublespends", api.transactionpoolDoubleSpendsHandler)
+		router.GET("/transactionpool/eviction", api.transactionpoolEvictionHandlerGET)
+		router.POST("/transactionpool/eviction", RequirePassword(api.transactionpoolEvictionHandlerPOST, requiredPassword))
+		router.GET("/transactionpool/fee", api.transactionpoolFeeHandler)
+		router.GET("/transactionpool/relay", api.transactionpoolRelayHandlerGET)
+		router.POST("/transactionpool/relay", RequirePassword(api.transactionpoolRelayHandlerPOST, requiredPassword))
+		router.GET("/transactionpool/risk/:id", api.transactionpoolRiskHandler)
+		router.GET("/transactionpool/snapshot", api.transactionpoolSnapshotHandler)
+		router.GET("/transactionpool/transactions", api.transactionpoolTransactionsHandler)
+		router.GET("/transactionpool/transactions/:id", api.transactionpoolTransactionHandler)
 	}
 
 	// Wallet API Calls
@@ -241,25 +344,46 @@ func New(requiredUserAgent string, requiredPassword string, cs modules.Consensus
 		router.GET("/wallet", api.walletHandler)
 		router.POST("/wallet/033x", RequirePassword(api.wallet033xHandler, requiredPassword))
 		router.GET("/wallet/address", RequirePassword(api.walletAddressHandler, requiredPassword))
+		router.GET("/wallet/address/:addr", api.walletAddressAddrHandler)
 		router.GET("/wallet/addresses", api.walletAddressesHandler)
+		router.GET("/wallet/addresses/usage", api.walletAddressUsageHandler)
+		router.GET("/wallet/approvals", api.walletApprovalsHandlerGET)
+		router.POST("/wallet/approvals", api.requireApprovalPassword(api.walletApprovalsHandlerPOST))
+		router.POST("/wallet/approvals/approve/:id", api.requireApprovalPassword(api.walletApprovalsApproveHandler))
+		router.POST("/wallet/approvals/reject/:id", api.requireApprovalPassword(api.walletApprovalsRejectHandler))
 		router.GET("/wallet/backup", RequirePassword(api.walletBackupHandler, requiredPassword))
+		router.POST("/wallet/changepassword", RequirePassword(api.walletChangePasswordHandler, requiredPassword))
+		router.GET("/wallet/db/check", RequirePassword(api.walletDBCheckHandler, requiredPassword))
+		router.POST("/wallet/db/compact", RequirePassword(api.walletDBCompactHandler, requiredPassword))
 		router.POST("/wallet/init", RequirePassword(api.walletInitHandler, requiredPassword))
 		router.POST("/wallet/init/seed", RequirePassword(api.walletInitSeedHandler, requiredPassword))
 		router.POST("/wallet/lock", RequirePassword(api.walletLockHandler, requiredPassword))
+		router.POST("/wallet/locktimeout", RequirePassword(api.walletLockTimeoutHandler, requiredPassword))
+		router.GET("/wallet/provenance/:id", api.walletProvenanceHandler)
 		router.POST("/wallet/seed", RequirePassword(api.walletSeedHandler, requiredPassword))
 		router.GET("/wallet/seeds", RequirePassword(api.walletSeedsHandler, requiredPassword))
 		router.POST("/wallet/siacoins", RequirePassword(api.walletSiacoinsHandler, requiredPassword))
 		router.POST("/wallet/siafunds", RequirePassword(api.walletSiafundsHandler, requiredPassword))
 		router.POST("/wallet/siagkey", RequirePassword(api.walletSiagkeyHandler, requiredPassword))
+		router.GET("/wallet/spendable", api.walletSpendableHandler)
+		router.POST("/wallet/swap/audit", api.walletSwapAuditHandler)
+		router.POST("/wallet/swap/claim", RequirePassword(api.walletSwapClaimHandler, requiredPassword))
+		router.POST("/wallet/swap/construct", RequirePassword(api.walletSwapConstructHandler, requiredPassword))
+		router.GET("/wallet/swap/key", RequirePassword(api.walletSwapKeyHandler, requiredPassword))
+		router.POST("/wallet/swap/participate", RequirePassword(api.walletSwapParticipateHandler, requiredPassword))
+		router.POST("/wallet/swap/publish", RequirePassword(api.walletSwapPublishHandler, requiredPassword))
+		router.POST("/wallet/swap/refund", RequirePassword(api.walletSwapRefundHandler, requiredPassword))
+		router.GET("/wallet/swap/secret", api.walletSwapSecretHandler)
 		router.POST("/wallet/sweep/seed", RequirePassword(api.walletSweepSeedHandler, requiredPassword))
 		router.GET("/wallet/transaction/:id", api.walletTransactionHandler)
 		router.GET("/wallet/transactions", api.walletTransactionsHandler)
 		router.GET("/wallet/transactions/:addr", api.walletTransactionsAddrHandler)
 		router.POST("/wallet/unlock", RequirePassword(api.walletUnlockHandler, requiredPassword))
+		router.POST("/wallet/unlock/extend", RequirePassword(api.walletUnlockExtendHandler, requiredPassword))
 	}
 
 	// Apply UserAgent middleware and return the API
-	api.router = RequireUserAgent(router, requiredUserAgent)
+	api.router = RecoverPanics(RequireUserAgent(router, requiredUserAgent))
 	return api
 }
 
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 
 	"github.com/julienschmidt/httprouter"
@@ -46,3 +48,21 @@ func (api *API) consensusValidateTransactionsetHandler(w http.ResponseWriter, re
 	}
 	WriteSuccess(w)
 }
+
+// consensusProofHandler handles the API calls to /consensus/proof/:kind/:id.
+func (api *API) consensusProofHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	var id crypto.Hash
+	if err := id.LoadString(ps.ByName("id")); err != nil {
+		WriteError(w, Error{"unable to parse id: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	sp, err := api.cs.StateProof(ps.ByName("kind"), id)
+	if err == modules.ErrUnknownStateProofKind {
+		WriteError(w, Error{"error when calling /consensus/proof: " + err.Error()}, http.StatusBadRequest)
+		return
+	} else if err != nil {
+		WriteError(w, Error{"error when calling /consensus/proof: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, sp)
+}
@@ -5,6 +5,8 @@ import (
 	"net/url"
 	"testing"
 
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 )
 
@@ -109,3 +111,49 @@ func TestConsensusValidateTransactionSet(t *testing.T) {
 		t.Fatal("expected validation error")
 	}
 }
+
+// TestIntegrationConsensusProof probes the GET call to
+// /consensus/proof/:kind/:id.
+func TestIntegrationConsensusProof(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	// The miner payout of the first block has matured into a siacoin output.
+	b, _ := st.server.api.cs.BlockAtHeight(1)
+	var sp modules.StateProof
+	err = st.getAPI("/consensus/proof/siacoinoutput/"+b.MinerPayoutID(0).String(), &sp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sp.Exists {
+		t.Fatal("expected the miner payout to exist")
+	}
+	if err := sp.Verify(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A random ID does not exist.
+	err = st.getAPI("/consensus/proof/filecontract/"+crypto.Hash{1, 2, 3}.String(), &sp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sp.Exists {
+		t.Fatal("expected the file contract not to exist")
+	}
+	if err := sp.Verify(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Proofs can only be requested for known kinds.
+	err = st.getAPI("/consensus/proof/foo/"+crypto.Hash{}.String(), &sp)
+	if err == nil {
+		t.Fatal("expected an error for an unknown kind")
+	}
+}
@@ -12,6 +12,12 @@ import (
 	"github.com/julienschmidt/httprouter"
 )
 
+const (
+	// defaultExpirationWindow is the number of blocks covered by a call to
+	// /explorer/expirations if no end height is given.
+	defaultExpirationWindow = 4320 // 30 days
+)
+
 type (
 	// ExplorerBlock is a block with some extra information such as the id and
 	// height. This information is provided for programs that may not be
@@ -59,6 +65,28 @@ type (
 		Block ExplorerBlock `json:"block"`
 	}
 
+	// ExplorerAlertsGET is the object returned as a response to a GET request
+	// to /explorer/alerts.
+	ExplorerAlertsGET struct {
+		Alerts []modules.ExplorerAlert `json:"alerts"`
+	}
+
+	// ExplorerExpirationsGET is the object returned as a response to a GET
+	// request to /explorer/expirations.
+	ExplorerExpirationsGET struct {
+		Start             types.BlockHeight               `json:"start"`
+		End               types.BlockHeight               `json:"end"`
+		ValidProofPayout  types.Currency                  `json:"validproofpayout"`
+		MissedProofPayout types.Currency                  `json:"missedproofpayout"`
+		Expirations       []modules.ContractExpirationDay `json:"expirations"`
+	}
+
+	// ExplorerHostMetricsGET is the object returned as a response to a GET
+	// request to /explorer/hostmetrics.
+	ExplorerHostMetricsGET struct {
+		Metrics []modules.HostMetrics `json:"metrics"`
+	}
+
 	// ExplorerHashGET is the object returned as a response to a GET request to
 	// /explorer/hash. The HashType will indicate whether the hash corresponds
 	// to a block id, a transaction id, a siacoin output id, a file contract
@@ -358,3 +386,98 @@ func (api *API) explorerHandler(w http.ResponseWriter, req *http.Request, _ http
 		BlockFacts: facts,
 	})
 }
+
+// explorerExpirationsHandler handles GET requests to /explorer/expirations.
+func (api *API) explorerExpirationsHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	start := api.cs.Height() + 1
+	if s := req.FormValue("start"); s != "" {
+		if _, err := fmt.Sscan(s, &start); err != nil {
+			WriteError(w, Error{"unable to parse start: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	end := start + defaultExpirationWindow
+	if s := req.FormValue("end"); s != "" {
+		if _, err := fmt.Sscan(s, &end); err != nil {
+			WriteError(w, Error{"unable to parse end: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	if end < start {
+		WriteError(w, Error{"end height must not be less than start height"}, http.StatusBadRequest)
+		return
+	}
+
+	var days []modules.ContractExpirationDay
+	if s := req.FormValue("address"); s != "" {
+		addr, err := scanAddress(s)
+		if err != nil {
+			WriteError(w, Error{"unable to parse address: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		days = api.explorer.AddressContractExpirations(addr, start, end)
+	} else {
+		days = api.explorer.ContractExpirations(start, end)
+	}
+
+	resp := ExplorerExpirationsGET{
+		Start:       start,
+		End:         end,
+		Expirations: days,
+	}
+	for _, day := range days {
+		resp.ValidProofPayout = resp.ValidProofPayout.Add(day.ValidProofPayout)
+		resp.MissedProofPayout = resp.MissedProofPayout.Add(day.MissedProofPayout)
+	}
+	WriteJSON(w, resp)
+}
+
+// explorerHostMetricsHandler handles GET requests to /explorer/hostmetrics.
+func (api *API) explorerHostMetricsHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var start types.Timestamp
+	if s := req.FormValue("start"); s != "" {
+		if _, err := fmt.Sscan(s, &start); err != nil {
+			WriteError(w, Error{"unable to parse start: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	end := types.CurrentTimestamp()
+	if s := req.FormValue("end"); s != "" {
+		if _, err := fmt.Sscan(s, &end); err != nil {
+			WriteError(w, Error{"unable to parse end: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	if end < start {
+		WriteError(w, Error{"end timestamp must not be less than start timestamp"}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, ExplorerHostMetricsGET{
+		Metrics: api.explorer.HostMetrics(start, end),
+	})
+}
+
+// explorerAlertsHandler handles GET requests to /explorer/alerts.
+func (api *API) explorerAlertsHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var start types.BlockHeight
+	if s := req.FormValue("start"); s != "" {
+		if _, err := fmt.Sscan(s, &start); err != nil {
+			WriteError(w, Error{"unable to parse start: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	end := api.cs.Height()
+	if s := req.FormValue("end"); s != "" {
+		if _, err := fmt.Sscan(s, &end); err != nil {
+			WriteError(w, Error{"unable to parse end: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	if end < start {
+		WriteError(w, Error{"end height must not be less than start height"}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, ExplorerAlertsGET{
+		Alerts: api.explorer.Alerts(start, end),
+	})
+}
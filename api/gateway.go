@@ -2,16 +2,67 @@ package api
 
 import (
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
 
 	"github.com/julienschmidt/httprouter"
 )
 
 // GatewayGET contains the fields returned by a GET call to "/gateway".
 type GatewayGET struct {
-	NetAddress modules.NetAddress `json:"netaddress"`
-	Peers      []modules.Peer     `json:"peers"`
+	NetAddress  modules.NetAddress         `json:"netaddress"`
+	PublicKey   types.SiaPublicKey         `json:"publickey"`
+	Peers       []modules.Peer             `json:"peers"`
+	BannedPeers []modules.BannedPeer       `json:"bannedpeers"`
+	PortMapping modules.GatewayPortMapping `json:"portmapping"`
+	Offline     bool                       `json:"offline"`
+	modules.GatewayRateLimits
+}
+
+// GatewayAnchorsGET contains the fields returned by a GET call to
+// "/gateway/anchors".
+type GatewayAnchorsGET struct {
+	Anchors []modules.NetAddress `json:"anchors"`
+}
+
+// GatewayFilterGET contains the fields returned by a GET call to
+// "/gateway/filter".
+type GatewayFilterGET struct {
+	modules.GatewayPeerFilter
+}
+
+// GatewayLimitsGET contains the fields returned by a GET call to
+// "/gateway/limits".
+type GatewayLimitsGET struct {
+	modules.GatewayPeerLimits
+}
+
+// GatewayRPCTimeoutsGET contains the fields returned by a GET call to
+// "/gateway/rpctimeouts".
+type GatewayRPCTimeoutsGET struct {
+	modules.GatewayRPCTimeouts
+}
+
+// GatewayTopologyGET contains the fields returned by a GET call to
+// "/gateway/topology".
+type GatewayTopologyGET struct {
+	modules.NetworkTopology
+}
+
+// GatewayPeersGET contains the fields returned by a GET call to
+// "/gateway/peers".
+type GatewayPeersGET struct {
+	Peers []modules.PeerStats `json:"peers"`
+}
+
+// GatewayTransportsGET contains the fields returned by a GET call to
+// "/gateway/transports".
+type GatewayTransportsGET struct {
+	Transports []modules.GatewayTransportStats `json:"transports"`
 }
 
 // gatewayHandler handles the API call asking for the gatway status.
@@ -23,7 +74,217 @@ func (api *API) gatewayHandler(w http.ResponseWriter, req *http.Request, _ httpr
 	if peers == nil {
 		peers = make([]modules.Peer, 0)
 	}
-	WriteJSON(w, GatewayGET{api.gateway.Address(), peers})
+	WriteJSON(w, GatewayGET{api.gateway.Address(), api.gateway.PublicKey(), peers, api.gateway.BannedPeers(), api.gateway.PortMapping(), api.gateway.Offline(), api.gateway.RateLimits()})
+}
+
+// gatewayHandlerPOST handles the API call changing the gateway's settings.
+// Settings that are not provided are left unchanged.
+func (api *API) gatewayHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	rl := api.gateway.RateLimits()
+	for _, param := range []struct {
+		name  string
+		speed *int64
+	}{
+		{"maxdownloadspeed", &rl.MaxDownloadSpeed},
+		{"maxuploadspeed", &rl.MaxUploadSpeed},
+	} {
+		if v := req.FormValue(param.name); v != "" {
+			speed, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				WriteError(w, Error{"unable to parse " + param.name + ": " + err.Error()}, http.StatusBadRequest)
+				return
+			}
+			*param.speed = speed
+		}
+	}
+	if err := api.gateway.SetRateLimits(rl); err != nil {
+		WriteError(w, Error{"error when calling /gateway: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// gatewayFilterHandlerGET handles the API call asking for the gateway's peer
+// blacklist and whitelist.
+func (api *API) gatewayFilterHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, GatewayFilterGET{api.gateway.PeerFilter()})
+}
+
+// gatewayFilterHandlerPOST handles the API call replacing the gateway's peer
+// blacklist or whitelist. Lists are comma-separated; a list that is not
+// provided is left unchanged, and an empty list clears it.
+func (api *API) gatewayFilterHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	if err := req.ParseForm(); err != nil {
+		WriteError(w, Error{"unable to parse form: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	pf := api.gateway.PeerFilter()
+	for _, param := range []struct {
+		name string
+		list *[]string
+	}{
+		{"blacklist", &pf.Blacklist},
+		{"whitelist", &pf.Whitelist},
+	} {
+		if _, ok := req.Form[param.name]; !ok {
+			continue
+		}
+		*param.list = []string{}
+		for _, entry := range strings.Split(req.Form.Get(param.name), ",") {
+			if entry = strings.TrimSpace(entry); entry != "" {
+				*param.list = append(*param.list, entry)
+			}
+		}
+	}
+	if err := api.gateway.SetPeerFilter(pf); err != nil {
+		WriteError(w, Error{"error when calling /gateway/filter: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// gatewayAnchorsHandlerGET handles the API call asking for the peers that the
+// gateway always stays connected to.
+func (api *API) gatewayAnchorsHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, GatewayAnchorsGET{api.gateway.AnchorPeers()})
+}
+
+// gatewayAnchorsHandlerPOST handles the API call replacing the peers that the
+// gateway always stays connected to. The list is comma-separated; an empty
+// list clears it.
+func (api *API) gatewayAnchorsHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	anchors := []modules.NetAddress{}
+	for _, entry := range strings.Split(req.FormValue("anchors"), ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			anchors = append(anchors, modules.NetAddress(entry))
+		}
+	}
+	if err := api.gateway.SetAnchorPeers(anchors); err != nil {
+		WriteError(w, Error{"error when calling /gateway/anchors: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// gatewayOfflineHandler handles the API call taking the gateway off the
+// network.
+func (api *API) gatewayOfflineHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	if err := api.gateway.SetOffline(true); err != nil {
+		WriteError(w, Error{"error when calling /gateway/offline: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// gatewayOnlineHandler handles the API call bringing the gateway back onto
+// the network.
+func (api *API) gatewayOnlineHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	if err := api.gateway.SetOffline(false); err != nil {
+		WriteError(w, Error{"error when calling /gateway/online: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// gatewayLimitsHandlerGET handles the API call asking for the limits on the
+// number of peers that the gateway connects to.
+func (api *API) gatewayLimitsHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, GatewayLimitsGET{api.gateway.PeerLimits()})
+}
+
+// gatewayLimitsHandlerPOST handles the API call changing the limits on the
+// number of peers that the gateway connects to. Limits that are not provided
+// are left unchanged.
+func (api *API) gatewayLimitsHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	pl := api.gateway.PeerLimits()
+	for _, param := range []struct {
+		name  string
+		limit *int
+	}{
+		{"maxinbound", &pl.MaxInbound},
+		{"targetoutbound", &pl.TargetOutbound},
+		{"whitelistslots", &pl.WhitelistSlots},
+	} {
+		if v := req.FormValue(param.name); v != "" {
+			limit, err := strconv.Atoi(v)
+			if err != nil {
+				WriteError(w, Error{"unable to parse " + param.name + ": " + err.Error()}, http.StatusBadRequest)
+				return
+			}
+			*param.limit = limit
+		}
+	}
+	if err := api.gateway.SetPeerLimits(pl); err != nil {
+		WriteError(w, Error{"error when calling /gateway/limits: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// gatewayRPCTimeoutsHandlerGET handles the API call asking for the deadlines
+// that the gateway enforces on RPCs.
+func (api *API) gatewayRPCTimeoutsHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, GatewayRPCTimeoutsGET{api.gateway.RPCTimeouts()})
+}
+
+// gatewayRPCTimeoutsHandlerPOST handles the API call changing the deadlines
+// that the gateway enforces on an RPC, or on all RPCs if no RPC is named.
+// Timeouts that are not provided are left unchanged. An RPC whose timeouts
+// are all zero falls back to the defaults.
+func (api *API) gatewayRPCTimeoutsHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	rt := api.gateway.RPCTimeouts()
+	name := req.FormValue("rpc")
+	timeouts := rt.Default
+	if name != "" {
+		timeouts = rt.PerRPC[name]
+	}
+	for _, param := range []struct {
+		name    string
+		timeout *time.Duration
+	}{
+		{"readtimeout", &timeouts.ReadTimeout},
+		{"writetimeout", &timeouts.WriteTimeout},
+		{"timeout", &timeouts.Timeout},
+	} {
+		if v := req.FormValue(param.name); v != "" {
+			timeout, err := time.ParseDuration(v)
+			if err != nil {
+				WriteError(w, Error{"unable to parse " + param.name + ": " + err.Error()}, http.StatusBadRequest)
+				return
+			}
+			*param.timeout = timeout
+		}
+	}
+	if name == "" {
+		rt.Default = timeouts
+	} else if timeouts == (modules.RPCTimeouts{}) {
+		delete(rt.PerRPC, name)
+	} else {
+		rt.PerRPC[name] = timeouts
+	}
+	if err := api.gateway.SetRPCTimeouts(rt); err != nil {
+		WriteError(w, Error{"error when calling /gateway/rpctimeouts: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// gatewayTopologyHandler handles the API call asking for a snapshot of the
+// gateway's view of the network.
+func (api *API) gatewayTopologyHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, GatewayTopologyGET{api.gateway.Topology()})
+}
+
+// gatewayPeersHandler handles the API call asking for the metrics of each
+// peer.
+func (api *API) gatewayPeersHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, GatewayPeersGET{api.gateway.PeerStats()})
+}
+
+// gatewayTransportsHandler handles the API call asking for the metrics of each
+// transport that peer connections can be made over.
+func (api *API) gatewayTransportsHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, GatewayTransportsGET{api.gateway.Transports()})
 }
 
 // gatewayConnectHandler handles the API call to add a peer to the gateway.
@@ -49,3 +310,16 @@ func (api *API) gatewayDisconnectHandler(w http.ResponseWriter, req *http.Reques
 
 	WriteSuccess(w)
 }
+
+// gatewayForgetKeyHandler handles the API call to forget the identity key
+// that a node has presented.
+func (api *API) gatewayForgetKeyHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	addr := modules.NetAddress(ps.ByName("netaddress"))
+	err := api.gateway.ForgetPeerKey(addr)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	WriteSuccess(w)
+}
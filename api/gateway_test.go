@@ -1,9 +1,12 @@
 package api
 
 import (
+	"net/url"
 	"testing"
+	"time"
 
 	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/modules/gateway"
 )
 
@@ -99,3 +102,308 @@ func TestGatewayPeerDisconnect(t *testing.T) {
 		t.Fatal("/gateway/disconnect did not disconnect from peer", peer.Address())
 	}
 }
+
+// TestGatewayTopology checks that /gateway/topology reports connected peers
+// and the node list.
+func TestGatewayTopology(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	peer, err := gateway.New("localhost:0", false, build.TempDir("api", t.Name(), "gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+	err = st.stdPostAPI("/gateway/connect/"+string(peer.Address()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var topology GatewayTopologyGET
+	err = st.getAPI("/gateway/topology", &topology)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if topology.Address != st.server.api.gateway.Address() {
+		t.Fatal("/gateway/topology reported wrong address:", topology.Address)
+	}
+	if len(topology.Peers) != 1 || topology.Peers[0].NetAddress != peer.Address() {
+		t.Fatal("/gateway/topology reported wrong peers:", topology.Peers)
+	}
+	var found bool
+	for _, n := range topology.Nodes {
+		found = found || n.NetAddress == peer.Address()
+	}
+	if !found {
+		t.Fatal("/gateway/topology is missing the peer from the node list")
+	}
+}
+
+// TestGatewayRateLimits checks that the gateway's rate limits can be set with
+// /gateway [POST].
+func TestGatewayRateLimits(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	values := url.Values{}
+	values.Set("maxuploadspeed", "1000000")
+	if err := st.stdPostAPI("/gateway", values); err != nil {
+		t.Fatal(err)
+	}
+	// Omitted limits are left unchanged.
+	values = url.Values{}
+	values.Set("maxdownloadspeed", "2000000")
+	if err := st.stdPostAPI("/gateway", values); err != nil {
+		t.Fatal(err)
+	}
+	var info GatewayGET
+	if err := st.getAPI("/gateway", &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.MaxDownloadSpeed != 2e6 || info.MaxUploadSpeed != 1e6 {
+		t.Fatal("rate limits were not set:", info.GatewayRateLimits)
+	}
+
+	values.Set("maxdownloadspeed", "-1")
+	if err := st.stdPostAPI("/gateway", values); err == nil {
+		t.Fatal("expected an error when setting a negative rate limit")
+	}
+}
+
+// TestGatewayFilter checks that the gateway's peer blacklist and whitelist can
+// be set and cleared through the API, and that invalid entries are rejected.
+func TestGatewayFilter(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	values := url.Values{}
+	values.Set("blacklist", "203.0.113.7, 198.51.100.0/24")
+	values.Set("whitelist", "")
+	if err := st.stdPostAPI("/gateway/filter", values); err != nil {
+		t.Fatal(err)
+	}
+	var gf GatewayFilterGET
+	if err := st.getAPI("/gateway/filter", &gf); err != nil {
+		t.Fatal(err)
+	}
+	if len(gf.Blacklist) != 2 || gf.Blacklist[1] != "198.51.100.0/24" || len(gf.Whitelist) != 0 {
+		t.Fatal("peer filter was not set:", gf.GatewayPeerFilter)
+	}
+
+	values = url.Values{}
+	values.Set("whitelist", "not-an-address")
+	if err := st.stdPostAPI("/gateway/filter", values); err == nil {
+		t.Fatal("expected an error when setting an invalid whitelist entry")
+	}
+	values.Set("blacklist", "")
+	values.Del("whitelist")
+	if err := st.stdPostAPI("/gateway/filter", values); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.getAPI("/gateway/filter", &gf); err != nil {
+		t.Fatal(err)
+	}
+	if len(gf.Blacklist) != 0 {
+		t.Fatal("blacklist was not cleared:", gf.Blacklist)
+	}
+}
+
+// TestGatewayLimits checks that the /gateway/limits endpoints report and
+// change the gateway's peer limits.
+func TestGatewayLimits(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	values := url.Values{}
+	values.Set("maxinbound", "20")
+	values.Set("whitelistslots", "5")
+	if err := st.stdPostAPI("/gateway/limits", values); err != nil {
+		t.Fatal(err)
+	}
+	var gl GatewayLimitsGET
+	if err := st.getAPI("/gateway/limits", &gl); err != nil {
+		t.Fatal(err)
+	}
+	if gl.MaxInbound != 20 || gl.WhitelistSlots != 5 || gl.TargetOutbound == 0 {
+		t.Fatal("peer limits were not set:", gl.GatewayPeerLimits)
+	}
+
+	values = url.Values{}
+	values.Set("targetoutbound", "-1")
+	if err := st.stdPostAPI("/gateway/limits", values); err == nil {
+		t.Fatal("expected an error when setting a negative limit")
+	}
+}
+
+// TestGatewayAnchors checks that the /gateway/anchors endpoints report and
+// change the gateway's anchor peers.
+func TestGatewayAnchors(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	values := url.Values{}
+	values.Set("anchors", "203.0.113.7:9981, 198.51.100.8:9981")
+	if err := st.stdPostAPI("/gateway/anchors", values); err != nil {
+		t.Fatal(err)
+	}
+	var ga GatewayAnchorsGET
+	if err := st.getAPI("/gateway/anchors", &ga); err != nil {
+		t.Fatal(err)
+	}
+	if len(ga.Anchors) != 2 {
+		t.Fatal("anchor peers were not set:", ga.Anchors)
+	}
+
+	values.Set("anchors", "not-an-address")
+	if err := st.stdPostAPI("/gateway/anchors", values); err == nil {
+		t.Fatal("expected an error when setting an invalid anchor peer")
+	}
+	values.Set("anchors", "")
+	if err := st.stdPostAPI("/gateway/anchors", values); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.getAPI("/gateway/anchors", &ga); err != nil {
+		t.Fatal(err)
+	}
+	if len(ga.Anchors) != 0 {
+		t.Fatal("anchor peers were not cleared:", ga.Anchors)
+	}
+}
+
+// TestGatewayRPCTimeouts checks that the /gateway/rpctimeouts endpoints
+// report and change the deadlines that the gateway enforces on RPCs.
+func TestGatewayRPCTimeouts(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	values := url.Values{}
+	values.Set("readtimeout", "45s")
+	if err := st.stdPostAPI("/gateway/rpctimeouts", values); err != nil {
+		t.Fatal(err)
+	}
+	values = url.Values{}
+	values.Set("rpc", "SendBlocks")
+	values.Set("timeout", "20m")
+	if err := st.stdPostAPI("/gateway/rpctimeouts", values); err != nil {
+		t.Fatal(err)
+	}
+	var grt GatewayRPCTimeoutsGET
+	if err := st.getAPI("/gateway/rpctimeouts", &grt); err != nil {
+		t.Fatal(err)
+	}
+	if grt.Default.ReadTimeout != 45*time.Second || grt.Default.Timeout == 0 {
+		t.Fatal("default RPC timeouts were not set:", grt.Default)
+	}
+	if grt.PerRPC["SendBlocks"] != (modules.RPCTimeouts{Timeout: 20 * time.Minute}) {
+		t.Fatal("SendBlocks timeouts were not set:", grt.PerRPC)
+	}
+
+	values.Set("timeout", "-1s")
+	if err := st.stdPostAPI("/gateway/rpctimeouts", values); err == nil {
+		t.Fatal("expected an error when setting a negative timeout")
+	}
+	values.Set("timeout", "0s")
+	if err := st.stdPostAPI("/gateway/rpctimeouts", values); err != nil {
+		t.Fatal(err)
+	}
+	grt = GatewayRPCTimeoutsGET{}
+	if err := st.getAPI("/gateway/rpctimeouts", &grt); err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := grt.PerRPC["SendBlocks"]; exists {
+		t.Fatal("SendBlocks timeouts were not cleared:", grt.PerRPC)
+	}
+}
+
+// TestGatewayOffline checks that /gateway/offline takes the gateway off the
+// network, and that /gateway/online brings it back.
+func TestGatewayOffline(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	peer, err := gateway.New("localhost:0", false, build.TempDir("api", t.Name()+"2", "gateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+	if err := st.stdPostAPI("/gateway/connect/"+string(peer.Address()), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := st.stdPostAPI("/gateway/offline", nil); err != nil {
+		t.Fatal(err)
+	}
+	var info GatewayGET
+	if err := st.getAPI("/gateway", &info); err != nil {
+		t.Fatal(err)
+	}
+	if !info.Offline || len(info.Peers) != 0 {
+		t.Fatal("gateway is not offline:", info.Offline, info.Peers)
+	}
+	if err := st.stdPostAPI("/gateway/connect/"+string(peer.Address()), nil); err == nil {
+		t.Fatal("offline gateway connected to a peer")
+	}
+
+	if err := st.stdPostAPI("/gateway/online", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.stdPostAPI("/gateway/connect/"+string(peer.Address()), nil); err != nil {
+		t.Fatal(err)
+	}
+	info = GatewayGET{}
+	if err := st.getAPI("/gateway", &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.Offline || len(info.Peers) != 1 {
+		t.Fatal("gateway is not back online:", info.Offline, info.Peers)
+	}
+}
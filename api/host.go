@@ -1,9 +1,15 @@
 package api
 
 import (
+	"encoding/csv"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
@@ -26,10 +32,71 @@ type (
 	// HostGET contains the information that is returned after a GET request to
 	// /host - a bunch of information about the status of the host.
 	HostGET struct {
-		ExternalSettings modules.HostExternalSettings `json:"externalsettings"`
-		FinancialMetrics modules.HostFinancialMetrics `json:"financialmetrics"`
-		InternalSettings modules.HostInternalSettings `json:"internalsettings"`
-		NetworkMetrics   modules.HostNetworkMetrics   `json:"networkmetrics"`
+		AnnouncementStatus modules.HostAnnouncementStatus `json:"announcementstatus"`
+		ExternalSettings   modules.HostExternalSettings   `json:"externalsettings"`
+		FinancialMetrics   modules.HostFinancialMetrics   `json:"financialmetrics"`
+		InternalSettings   modules.HostInternalSettings   `json:"internalsettings"`
+		NetworkMetrics     modules.HostNetworkMetrics     `json:"networkmetrics"`
+		RelayStatus        modules.HostRelayStatus        `json:"relaystatus"`
+	}
+
+	// HostAbuseGET contains the records kept by the host's renter abuse
+	// detection, returned by a GET request to /host/abuse.
+	HostAbuseGET struct {
+		Renters []modules.HostRenterAbuse `json:"renters"`
+	}
+
+	// HostBandwidthGET contains the bandwidth used by each renter, returned
+	// by a GET request to /host/bandwidth.
+	HostBandwidthGET struct {
+		Renters []modules.HostRenterBandwidth `json:"renters"`
+	}
+
+	// HostContractGET contains the storage obligation of a single file
+	// contract, returned by a GET request to /host/contracts/:id.
+	HostContractGET struct {
+		Contract modules.StorageObligation `json:"contract"`
+	}
+
+	// HostContractsGET contains the storage obligations of the host, returned
+	// by a GET request to /host/contracts.
+	HostContractsGET struct {
+		Contracts []modules.StorageObligation `json:"contracts"`
+	}
+
+	// HostFinancialsGET contains the financial activity of the host broken
+	// down by period, returned by a GET request to /host/financials.
+	HostFinancialsGET struct {
+		Periods []modules.HostFinancialPeriod `json:"periods"`
+	}
+
+	// HostGCGET contains the status of the host's garbage collection,
+	// returned by a GET request to /host/gc.
+	HostGCGET struct {
+		modules.HostGarbageCollectionStatus
+	}
+
+	// HostGCPOST contains the report of the garbage collection pass run by a
+	// POST request to /host/gc.
+	HostGCPOST struct {
+		modules.HostGarbageCollection
+	}
+
+	// HostReachabilityGET contains the results of the host's checks of its
+	// own reachability, returned by a GET request to /host/reachability.
+	HostReachabilityGET struct {
+		modules.HostReachability
+	}
+
+	// HostScoreGET contains a preview of the score that renters would assign
+	// to the host, returned by a GET request to /host/score. Score is the
+	// product of the adjustments in the breakdown. InHostDB is true if the
+	// renter's hostdb already knows the host, in which case the age of the
+	// host is taken from the hostdb.
+	HostScoreGET struct {
+		Score          float64                    `json:"score"`
+		ScoreBreakdown modules.HostScoreBreakdown `json:"scorebreakdown"`
+		InHostDB       bool                       `json:"inhostdb"`
 	}
 
 	// StorageGET contains the information that is returned after a GET request
@@ -38,6 +105,19 @@ type (
 	StorageGET struct {
 		Folders []modules.StorageFolderMetadata `json:"folders"`
 	}
+
+	// StorageAccessGET contains the aggregated sector access statistics
+	// returned by a GET request to /host/storage/access.
+	StorageAccessGET struct {
+		modules.SectorAccessStats
+	}
+
+	// StorageScanGET contains the results of the most recent integrity scan
+	// of each storage folder, returned by a GET request to
+	// /host/storage/scan.
+	StorageScanGET struct {
+		Scans []modules.StorageFolderScan `json:"scans"`
+	}
 )
 
 // folderIndex determines the index of the storage folder with the provided
@@ -51,18 +131,34 @@ func folderIndex(folderPath string, storageFolders []modules.StorageFolderMetada
 	return -1, errStorageFolderNotFound
 }
 
+// splitRenterList splits a comma separated list of renter public keys. An
+// empty string is an empty list.
+func splitRenterList(list string) []string {
+	var renters []string
+	for _, renter := range strings.Split(list, ",") {
+		if renter = strings.TrimSpace(renter); renter != "" {
+			renters = append(renters, renter)
+		}
+	}
+	return renters
+}
+
 // hostHandlerGET handles GET requests to the /host API endpoint, returning key
 // information about the host.
 func (api *API) hostHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	as := api.host.AnnouncementStatus()
 	es := api.host.ExternalSettings()
 	fm := api.host.FinancialMetrics()
 	is := api.host.InternalSettings()
 	nm := api.host.NetworkMetrics()
+	rs := api.host.RelayStatus()
 	hg := HostGET{
-		ExternalSettings: es,
-		FinancialMetrics: fm,
-		InternalSettings: is,
-		NetworkMetrics:   nm,
+		AnnouncementStatus: as,
+		ExternalSettings:   es,
+		FinancialMetrics:   fm,
+		InternalSettings:   is,
+		NetworkMetrics:     nm,
+		RelayStatus:        rs,
 	}
 	WriteJSON(w, hg)
 }
@@ -82,6 +178,96 @@ func (api *API) hostHandlerPOST(w http.ResponseWriter, req *http.Request, _ http
 		}
 		settings.AcceptingContracts = x
 	}
+	if req.FormValue("maxrenterdownloadspeed") != "" {
+		var x uint64
+		_, err := fmt.Sscan(req.FormValue("maxrenterdownloadspeed"), &x)
+		if err != nil {
+			WriteError(w, Error{"Malformed maxrenterdownloadspeed"}, http.StatusBadRequest)
+			return
+		}
+		settings.MaxRenterDownloadSpeed = x
+	}
+	if req.FormValue("maxrenteruploadspeed") != "" {
+		var x uint64
+		_, err := fmt.Sscan(req.FormValue("maxrenteruploadspeed"), &x)
+		if err != nil {
+			WriteError(w, Error{"Malformed maxrenteruploadspeed"}, http.StatusBadRequest)
+			return
+		}
+		settings.MaxRenterUploadSpeed = x
+	}
+	if req.FormValue("mincontractduration") != "" {
+		var x types.BlockHeight
+		_, err := fmt.Sscan(req.FormValue("mincontractduration"), &x)
+		if err != nil {
+			WriteError(w, Error{"Malformed mincontractduration"}, http.StatusBadRequest)
+			return
+		}
+		settings.MinContractDuration = x
+	}
+	if req.FormValue("mincontractsize") != "" {
+		var x uint64
+		_, err := fmt.Sscan(req.FormValue("mincontractsize"), &x)
+		if err != nil {
+			WriteError(w, Error{"Malformed mincontractsize"}, http.StatusBadRequest)
+			return
+		}
+		settings.MinContractSize = x
+	}
+	if req.FormValue("maxcontractsize") != "" {
+		var x uint64
+		_, err := fmt.Sscan(req.FormValue("maxcontractsize"), &x)
+		if err != nil {
+			WriteError(w, Error{"Malformed maxcontractsize"}, http.StatusBadRequest)
+			return
+		}
+		settings.MaxContractSize = x
+	}
+	if req.FormValue("maxcontractsperrenter") != "" {
+		var x uint64
+		_, err := fmt.Sscan(req.FormValue("maxcontractsperrenter"), &x)
+		if err != nil {
+			WriteError(w, Error{"Malformed maxcontractsperrenter"}, http.StatusBadRequest)
+			return
+		}
+		settings.MaxContractsPerRenter = x
+	}
+	if req.FormValue("maxstorageperrenter") != "" {
+		var x uint64
+		_, err := fmt.Sscan(req.FormValue("maxstorageperrenter"), &x)
+		if err != nil {
+			WriteError(w, Error{"Malformed maxstorageperrenter"}, http.StatusBadRequest)
+			return
+		}
+		settings.MaxStoragePerRenter = x
+	}
+	if req.FormValue("maxconcurrentdownloads") != "" {
+		var x uint64
+		_, err := fmt.Sscan(req.FormValue("maxconcurrentdownloads"), &x)
+		if err != nil {
+			WriteError(w, Error{"Malformed maxconcurrentdownloads"}, http.StatusBadRequest)
+			return
+		}
+		settings.MaxConcurrentDownloads = x
+	}
+	if req.FormValue("maxconcurrentnegotiations") != "" {
+		var x uint64
+		_, err := fmt.Sscan(req.FormValue("maxconcurrentnegotiations"), &x)
+		if err != nil {
+			WriteError(w, Error{"Malformed maxconcurrentnegotiations"}, http.StatusBadRequest)
+			return
+		}
+		settings.MaxConcurrentNegotiations = x
+	}
+	if req.FormValue("maxconcurrentrevisions") != "" {
+		var x uint64
+		_, err := fmt.Sscan(req.FormValue("maxconcurrentrevisions"), &x)
+		if err != nil {
+			WriteError(w, Error{"Malformed maxconcurrentrevisions"}, http.StatusBadRequest)
+			return
+		}
+		settings.MaxConcurrentRevisions = x
+	}
 	if req.FormValue("maxdownloadbatchsize") != "" {
 		var x uint64
 		_, err := fmt.Sscan(req.FormValue("maxdownloadbatchsize"), &x)
@@ -118,6 +304,28 @@ func (api *API) hostHandlerPOST(w http.ResponseWriter, req *http.Request, _ http
 		}
 		settings.NetAddress = x
 	}
+	if req.FormValue("acceptingrelays") != "" {
+		var x bool
+		_, err := fmt.Sscan(req.FormValue("acceptingrelays"), &x)
+		if err != nil {
+			WriteError(w, Error{"Malformed acceptingrelays"}, http.StatusBadRequest)
+			return
+		}
+		settings.AcceptingRelays = x
+	}
+	// An empty relayaddress stops the host from using a relay.
+	req.ParseForm()
+	if _, ok := req.Form["relayaddress"]; ok {
+		settings.RelayAddress = modules.NetAddress(req.FormValue("relayaddress"))
+	}
+	// The renter lists are comma separated, and an empty list removes the
+	// filter.
+	if _, ok := req.Form["renterwhitelist"]; ok {
+		settings.RenterWhitelist = splitRenterList(req.FormValue("renterwhitelist"))
+	}
+	if _, ok := req.Form["renterblacklist"]; ok {
+		settings.RenterBlacklist = splitRenterList(req.FormValue("renterblacklist"))
+	}
 	if req.FormValue("windowsize") != "" {
 		var x types.BlockHeight
 		_, err := fmt.Sscan(req.FormValue("windowsize"), &x)
@@ -217,6 +425,216 @@ func (api *API) hostAnnounceHandler(w http.ResponseWriter, req *http.Request, _
 	WriteSuccess(w)
 }
 
+// hostAbuseHandlerGET handles the API call to list renters that have recently
+// misbehaved, or that have an abuse override.
+func (api *API) hostAbuseHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, HostAbuseGET{
+		Renters: api.host.RenterAbuse(),
+	})
+}
+
+// hostBackupHandler handles the API call to write a backup of the host's
+// keys, settings, and storage obligations.
+func (api *API) hostBackupHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	destination := req.FormValue("destination")
+	if !filepath.IsAbs(destination) {
+		WriteError(w, Error{"error when calling /host/backup: destination must be an absolute path"}, http.StatusBadRequest)
+		return
+	}
+	err := api.host.CreateBackup(destination)
+	if err != nil {
+		WriteError(w, Error{"error when calling /host/backup: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// hostBandwidthHandlerGET handles the API call to list the bandwidth used by
+// each renter.
+func (api *API) hostBandwidthHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, HostBandwidthGET{
+		Renters: api.host.RenterBandwidth(),
+	})
+}
+
+// writeContractsCSV writes the storage obligations as CSV, with one row per
+// obligation. The columns are named after the JSON fields of the obligations,
+// and currencies are given in hastings.
+func writeContractsCSV(w io.Writer, sos []modules.StorageObligation) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{
+		"id", "renterpublickey", "negotiationheight", "expirationheight", "proofdeadline",
+		"datasize", "sectorcount",
+		"contractcost", "lockedcollateral", "potentialdownloadrevenue", "potentialstoragerevenue",
+		"potentialuploadrevenue", "riskedcollateral", "transactionfeesadded",
+		"originconfirmed", "revisionconstructed", "revisionconfirmed", "proofconstructed",
+		"proofconfirmed", "proofattempts", "proofstatus", "obligationstatus",
+	})
+	for _, so := range sos {
+		cw.Write([]string{
+			so.ID.String(), so.RenterPublicKey.String(),
+			fmt.Sprint(so.NegotiationHeight), fmt.Sprint(so.ExpirationHeight), fmt.Sprint(so.ProofDeadline),
+			strconv.FormatUint(so.DataSize, 10), strconv.FormatUint(so.SectorCount, 10),
+			so.ContractCost.String(), so.LockedCollateral.String(), so.PotentialDownloadRevenue.String(),
+			so.PotentialStorageRevenue.String(), so.PotentialUploadRevenue.String(),
+			so.RiskedCollateral.String(), so.TransactionFeesAdded.String(),
+			strconv.FormatBool(so.OriginConfirmed), strconv.FormatBool(so.RevisionConstructed),
+			strconv.FormatBool(so.RevisionConfirmed), strconv.FormatBool(so.ProofConstructed),
+			strconv.FormatBool(so.ProofConfirmed), strconv.FormatUint(so.ProofAttempts, 10),
+			so.ProofStatus, strconv.FormatUint(so.ObligationStatus, 10),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// hostContractsHandlerGET handles the API call to list the storage obligations
+// of the host. The obligations are encoded as JSON unless CSV is requested.
+func (api *API) hostContractsHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	format := req.FormValue("format")
+	if format != "" && format != "json" && format != "csv" {
+		WriteError(w, Error{"format must be 'json' or 'csv'"}, http.StatusBadRequest)
+		return
+	}
+	sos := api.host.StorageObligations()
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		writeContractsCSV(w, sos)
+		return
+	}
+	WriteJSON(w, HostContractsGET{
+		Contracts: sos,
+	})
+}
+
+// hostContractHandlerGET handles the API call to fetch the storage obligation
+// of a single file contract.
+func (api *API) hostContractHandlerGET(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	id, err := scanHash(ps.ByName("id"))
+	if err != nil {
+		WriteError(w, Error{"error when calling /host/contracts/:id: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	so, err := api.host.StorageObligation(types.FileContractID(id))
+	if err != nil {
+		WriteError(w, Error{"error when calling /host/contracts/:id: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, HostContractGET{
+		Contract: so,
+	})
+}
+
+// hostFinancialsHandlerGET handles the API call to break the financial
+// activity of the host down by period.
+func (api *API) hostFinancialsHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	period := req.FormValue("period")
+	if period == "" {
+		period = modules.HostFinancialPeriodMonth
+	}
+	count := 12
+	if req.FormValue("count") != "" {
+		_, err := fmt.Sscan(req.FormValue("count"), &count)
+		if err != nil {
+			WriteError(w, Error{"error parsing count: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	periods, err := api.host.FinancialPeriods(period, count)
+	if err != nil {
+		WriteError(w, Error{"error when calling /host/financials: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, HostFinancialsGET{
+		Periods: periods,
+	})
+}
+
+// hostGCHandlerGET handles the API call to get the status of the host's
+// garbage collection.
+func (api *API) hostGCHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	status, err := api.host.GarbageCollectionStatus()
+	if err != nil {
+		WriteError(w, Error{"error when calling /host/gc: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, HostGCGET{status})
+}
+
+// hostGCHandlerPOST handles the API call to remove the sectors of expired
+// storage obligations. The call returns once the pass has completed.
+func (api *API) hostGCHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	gc, err := api.host.CollectGarbage()
+	if err != nil {
+		WriteError(w, Error{"error when calling /host/gc: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, HostGCPOST{gc})
+}
+
+// hostRestoreHandler handles the API call to stage a backup of the host to
+// be restored when the host restarts.
+func (api *API) hostRestoreHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	source := req.FormValue("source")
+	if !filepath.IsAbs(source) {
+		WriteError(w, Error{"error when calling /host/restore: source must be an absolute path"}, http.StatusBadRequest)
+		return
+	}
+	err := api.host.RestoreBackup(source)
+	if err != nil {
+		WriteError(w, Error{"error when calling /host/restore: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// hostReachabilityHandlerGET handles the API call to get the results of the
+// host's checks of its own reachability.
+func (api *API) hostReachabilityHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, HostReachabilityGET{api.host.Reachability()})
+}
+
+// hostScoreHandlerGET handles the API call to preview the score that renters
+// would assign to the host. The score is computed by the renter's hostdb, from
+// the host's current settings and the history of its reachability checks.
+func (api *API) hostScoreHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	if api.renter == nil {
+		WriteError(w, Error{"error when calling /host/score: the score preview requires the renter module"}, http.StatusBadRequest)
+		return
+	}
+	pk := api.host.PublicKey()
+	entry, known := api.renter.Host(pk)
+	if !known {
+		entry.FirstSeen = api.cs.Height()
+	}
+	entry.HostExternalSettings = api.host.ExternalSettings()
+	entry.PublicKey = pk
+	if r := api.host.Reachability(); len(r.History) != 0 {
+		entry.ScanHistory = r.History
+		entry.HistoricUptime = 0
+		entry.HistoricDowntime = 0
+	}
+
+	sb := api.renter.ScoreBreakdown(entry)
+	score := sb.AgeAdjustment * sb.BurnAdjustment * sb.CollateralAdjustment * sb.PriceAdjustment * sb.StorageRemainingAdjustment * sb.UptimeAdjustment * sb.VersionAdjustment
+	WriteJSON(w, HostScoreGET{
+		Score:          score,
+		ScoreBreakdown: sb,
+		InHostDB:       known,
+	})
+}
+
+// hostAbuseOverrideHandler handles the API call to override the abuse
+// detection for a renter.
+func (api *API) hostAbuseOverrideHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	err := api.host.SetRenterAbuseOverride(req.FormValue("renter"), req.FormValue("override"))
+	if err != nil {
+		WriteError(w, Error{"error when calling /host/abuse/override: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
 // storageHandler returns a bunch of information about storage management on
 // the host.
 func (api *API) storageHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
@@ -225,6 +643,32 @@ func (api *API) storageHandler(w http.ResponseWriter, req *http.Request, _ httpr
 	})
 }
 
+// storageAccessHandler handles API calls to /host/storage/access.
+func (api *API) storageAccessHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, StorageAccessGET{
+		SectorAccessStats: api.host.SectorAccessStats(),
+	})
+}
+
+// storageScanHandlerGET handles GET requests to /host/storage/scan.
+func (api *API) storageScanHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, StorageScanGET{
+		Scans: api.host.StorageFolderScans(),
+	})
+}
+
+// storageScanHandlerPOST handles POST requests to /host/storage/scan, starting
+// an integrity scan of the storage folders.
+func (api *API) storageScanHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	quarantine := req.FormValue("quarantine") == "true"
+	err := api.host.ScanStorageFolders(quarantine)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
 // storageFoldersAddHandler adds a storage folder to the storage manager.
 func (api *API) storageFoldersAddHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	folderPath := req.FormValue("path")
@@ -242,6 +686,106 @@ func (api *API) storageFoldersAddHandler(w http.ResponseWriter, req *http.Reques
 	WriteSuccess(w)
 }
 
+// storageFoldersTierHandler sets the placement tier of a storage folder.
+func (api *API) storageFoldersTierHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	folderPath := req.FormValue("path")
+	if folderPath == "" {
+		WriteError(w, Error{"path parameter is required"}, http.StatusBadRequest)
+		return
+	}
+	folderIndex, err := folderIndex(folderPath, api.host.StorageFolders())
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	err = api.host.SetStorageFolderTier(uint16(folderIndex), req.FormValue("tier"))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// storageFoldersWritePolicyHandler sets the timeouts and retries applied to
+// writes to a storage folder.
+func (api *API) storageFoldersWritePolicyHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	folderPath := req.FormValue("path")
+	if folderPath == "" {
+		WriteError(w, Error{"path parameter is required"}, http.StatusBadRequest)
+		return
+	}
+	folderIndex, err := folderIndex(folderPath, api.host.StorageFolders())
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	var policy modules.StorageFolderWritePolicy
+	policy.WriteTimeout, err = time.ParseDuration(req.FormValue("writetimeout"))
+	if err != nil {
+		WriteError(w, Error{"unable to parse writetimeout: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	if req.FormValue("retries") != "" {
+		_, err = fmt.Sscan(req.FormValue("retries"), &policy.Retries)
+		if err != nil {
+			WriteError(w, Error{"unable to parse retries: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	if req.FormValue("retrydelay") != "" {
+		policy.RetryDelay, err = time.ParseDuration(req.FormValue("retrydelay"))
+		if err != nil {
+			WriteError(w, Error{"unable to parse retrydelay: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	err = api.host.SetStorageFolderWritePolicy(uint16(folderIndex), policy)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// storageFoldersMoveHandler points a missing storage folder at the new
+// location of its files.
+func (api *API) storageFoldersMoveHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	folderPath := req.FormValue("path")
+	newPath := req.FormValue("newpath")
+	if folderPath == "" || newPath == "" {
+		WriteError(w, Error{"path and newpath parameters are required"}, http.StatusBadRequest)
+		return
+	}
+	folderIndex, err := folderIndex(folderPath, api.host.StorageFolders())
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	err = api.host.MoveStorageFolder(uint16(folderIndex), newPath)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// storageFoldersPreflightHandler checks whether a folder can safely be added
+// to the storage manager.
+func (api *API) storageFoldersPreflightHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	folderPath := req.FormValue("path")
+	if folderPath == "" {
+		WriteError(w, Error{"path parameter is required"}, http.StatusBadRequest)
+		return
+	}
+	sfp, err := api.host.PreflightStorageFolder(folderPath)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, sfp)
+}
+
 // storageFoldersResizeHandler resizes a storage folder in the storage manager.
 func (api *API) storageFoldersResizeHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	folderPath := req.FormValue("path")
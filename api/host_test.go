@@ -1,6 +1,8 @@
 package api
 
 import (
+	"bytes"
+	"encoding/csv"
 	"io"
 	"net/url"
 	"path/filepath"
@@ -11,6 +13,7 @@ import (
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/modules/host/contractmanager"
+	"github.com/NebulousLabs/Sia/types"
 )
 
 var (
@@ -666,3 +669,39 @@ func TestDeleteNonexistentSector(t *testing.T) {
 		t.Fatalf("expected error to be %v; got %v", crypto.ErrHashWrongLen, err)
 	}
 }
+
+// TestWriteContractsCSV checks that storage obligations are exported as CSV
+// with a header row and one row per obligation.
+func TestWriteContractsCSV(t *testing.T) {
+	sos := []modules.StorageObligation{{
+		ID:               types.FileContractID{1},
+		ExpirationHeight: 125000,
+		DataSize:         2 * modules.SectorSize,
+		SectorCount:      2,
+		ContractCost:     types.NewCurrency64(30),
+		ProofConfirmed:   true,
+		ProofStatus:      modules.HostProofStatusConfirmed,
+	}, {
+		ID: types.FileContractID{2},
+	}}
+	var buf bytes.Buffer
+	err := writeContractsCSV(&buf, sos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatal("expected a header and two rows, got", len(records))
+	}
+	row := make(map[string]string)
+	for i, column := range records[0] {
+		row[column] = records[1][i]
+	}
+	if row["id"] != sos[0].ID.String() || row["expirationheight"] != "125000" || row["sectorcount"] != "2" ||
+		row["contractcost"] != "30" || row["proofconfirmed"] != "true" || row["proofstatus"] != "confirmed" {
+		t.Error("wrong CSV row:", row)
+	}
+}
@@ -4,10 +4,15 @@ package api
 // zeroing them out.
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"mime"
 	"net/http"
+	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/modules"
@@ -70,6 +75,15 @@ type (
 		Settings         modules.RenterSettings `json:"settings"`
 		FinancialMetrics RenterFinancialMetrics `json:"financialmetrics"`
 		CurrentPeriod    types.BlockHeight      `json:"currentperiod"`
+
+		// ContractFormation describes the most recent attempt to form
+		// contracts, including the constraint that limited it.
+		ContractFormation modules.ContractFormationReport `json:"contractformation"`
+
+		// Paused indicates that renter activity has been halted by a call to
+		// /renter/pause. PausedUntil is the time at which activity resumes.
+		Paused      bool      `json:"paused"`
+		PausedUntil time.Time `json:"pauseduntil"`
 	}
 
 	// RenterFinancialMetrics contains metrics about how much the Renter has
@@ -123,6 +137,12 @@ type (
 		modules.RenterPriceEstimation
 	}
 
+	// RenterReceipts lists the upload receipts of the files that have
+	// finished uploading.
+	RenterReceipts struct {
+		Receipts []modules.RenterUploadReceipt `json:"receipts"`
+	}
+
 	// RenterShareASCII contains an ASCII-encoded .sia file.
 	RenterShareASCII struct {
 		ASCIIsia string `json:"asciisia"`
@@ -155,10 +175,14 @@ func (api *API) renterHandlerGET(w http.ResponseWriter, req *http.Request, _ htt
 		}
 	}
 
+	pausedUntil := api.renter.PausedUntil()
 	WriteJSON(w, RenterGET{
-		Settings:         settings,
-		FinancialMetrics: fm,
-		CurrentPeriod:    periodStart,
+		Settings:          settings,
+		FinancialMetrics:  fm,
+		CurrentPeriod:     periodStart,
+		ContractFormation: api.renter.ContractFormationReport(),
+		Paused:            !pausedUntil.IsZero(),
+		PausedUntil:       pausedUntil,
 	})
 }
 
@@ -211,13 +235,24 @@ func (api *API) renterHandlerPOST(w http.ResponseWriter, req *http.Request, _ ht
 		renewWindow = period / 2
 	}
 
+	// Scan the maximum storage price. (optional parameter)
+	var maxStoragePrice types.Currency
+	if req.FormValue("maxstorageprice") != "" {
+		maxStoragePrice, ok = scanAmount(req.FormValue("maxstorageprice"))
+		if !ok {
+			WriteError(w, Error{"unable to parse maxstorageprice"}, http.StatusBadRequest)
+			return
+		}
+	}
+
 	// Set the settings in the renter.
 	err = api.renter.SetSettings(modules.RenterSettings{
 		Allowance: modules.Allowance{
-			Funds:       funds,
-			Hosts:       hosts,
-			Period:      period,
-			RenewWindow: renewWindow,
+			Funds:           funds,
+			Hosts:           hosts,
+			Period:          period,
+			RenewWindow:     renewWindow,
+			MaxStoragePrice: maxStoragePrice,
 		},
 	})
 	if err != nil {
@@ -281,6 +316,29 @@ func (api *API) renterLoadAsciiHandler(w http.ResponseWriter, req *http.Request,
 	WriteJSON(w, RenterLoad{FilesAdded: files})
 }
 
+// renterPauseHandler handles the API call to temporarily halt all renter
+// activity.
+func (api *API) renterPauseHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	duration, err := time.ParseDuration(req.FormValue("duration"))
+	if err != nil {
+		WriteError(w, Error{"unable to parse duration: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	err = api.renter.Pause(duration)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// renterResumeHandler handles the API call to resume renter activity that was
+// halted by a call to /renter/pause.
+func (api *API) renterResumeHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	api.renter.Resume()
+	WriteSuccess(w)
+}
+
 // renterRenameHandler handles the API call to rename a file entry in the
 // renter.
 func (api *API) renterRenameHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
@@ -308,6 +366,73 @@ func (api *API) renterPricesHandler(w http.ResponseWriter, req *http.Request, _
 	})
 }
 
+// renterHandoffHandler handles the API call to hand off the renter's data from
+// a retiring host to a replacement host. The handoff record signed by the
+// retiring host is returned.
+func (api *API) renterHandoffHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	from := modules.NetAddress(req.FormValue("from"))
+	to := modules.NetAddress(req.FormValue("to"))
+	if from == "" || to == "" {
+		WriteError(w, Error{"error when calling /renter/handoff: 'from' and 'to' must both be provided"}, http.StatusBadRequest)
+		return
+	}
+	record, err := api.renter.Handoff(from, to)
+	if err != nil {
+		WriteError(w, Error{"error when calling /renter/handoff: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, record)
+}
+
+// renterManifestHandler handles the API call to retrieve a signed manifest of
+// every file known to the renter.
+func (api *API) renterManifestHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, api.renter.Manifest())
+}
+
+// renterReceiptsHandler handles the API call to retrieve the upload receipts
+// of every file that has finished uploading.
+func (api *API) renterReceiptsHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	WriteJSON(w, RenterReceipts{
+		Receipts: api.renter.UploadReceipts(),
+	})
+}
+
+// renterReceiptHandler handles the API call to retrieve the upload receipt of
+// a single file.
+func (api *API) renterReceiptHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	receipt, exists := api.renter.UploadReceipt(strings.TrimPrefix(ps.ByName("siapath"), "/"))
+	if !exists {
+		WriteError(w, Error{"no upload receipt for that file, it may not have finished uploading"}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, receipt)
+}
+
+// renterGCHandlerGET handles the API call to report the renter metadata that
+// a garbage collection pass would remove.
+func (api *API) renterGCHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	WriteJSON(w, api.renter.Garbage())
+}
+
+// renterGCHandlerPOST handles the API call to remove the renter metadata
+// reported by /renter/gc. Only the metadata listed in the garbage parameter is
+// removed. The removed metadata is returned.
+func (api *API) renterGCHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var confirmed modules.RenterGarbage
+	err := json.Unmarshal([]byte(req.FormValue("garbage")), &confirmed)
+	if err != nil {
+		WriteError(w, Error{"unable to parse garbage: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	g, err := api.renter.CollectGarbage(confirmed)
+	if err != nil {
+		WriteError(w, Error{"error when calling /renter/gc: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, g)
+}
+
 // renterDeleteHandler handles the API call to delete a file entry from the
 // renter.
 func (api *API) renterDeleteHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
@@ -322,6 +447,13 @@ func (api *API) renterDeleteHandler(w http.ResponseWriter, req *http.Request, ps
 
 // renterDownloadHandler handles the API call to download a file.
 func (api *API) renterDownloadHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	// With httpresp, the file is written to the response body instead of to a
+	// destination on disk. Range requests only fetch the chunks they cover.
+	if req.FormValue("httpresp") == "true" {
+		api.serveFile(w, req, strings.TrimPrefix(ps.ByName("siapath"), "/"), "application/octet-stream")
+		return
+	}
+
 	destination := req.FormValue("destination")
 	// Check that the destination path is absolute.
 	if !filepath.IsAbs(destination) {
@@ -329,7 +461,18 @@ func (api *API) renterDownloadHandler(w http.ResponseWriter, req *http.Request,
 		return
 	}
 
-	err := api.renter.Download(strings.TrimPrefix(ps.ByName("siapath"), "/"), destination)
+	offset, length, ranged, err := parseDownloadRange(req.FormValue("offset"), req.FormValue("length"))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	siapath := strings.TrimPrefix(ps.ByName("siapath"), "/")
+	if ranged {
+		err = api.renter.DownloadRange(siapath, destination, offset, length)
+	} else {
+		err = api.renter.Download(siapath, destination)
+	}
 	if err != nil {
 		WriteError(w, Error{"download failed: " + err.Error()}, http.StatusInternalServerError)
 		return
@@ -338,6 +481,31 @@ func (api *API) renterDownloadHandler(w http.ResponseWriter, req *http.Request,
 	WriteSuccess(w)
 }
 
+// renterStreamHandler handles the API call to stream a file in the response
+// body, with a Content-Type derived from the file's extension.
+func (api *API) renterStreamHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	siapath := strings.TrimPrefix(ps.ByName("siapath"), "/")
+	contentType := mime.TypeByExtension(path.Ext(siapath))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	api.serveFile(w, req, siapath, contentType)
+}
+
+// serveFile writes the file at siapath to the response body, honoring Range
+// requests. Only the chunks covered by the requested range are downloaded.
+func (api *API) serveFile(w http.ResponseWriter, req *http.Request, siapath string, contentType string) {
+	streamer, err := api.renter.DownloadStreamer(siapath)
+	if err != nil {
+		WriteError(w, Error{"download failed: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	// Set the content type so that ServeContent does not download the first
+	// chunk to sniff it.
+	w.Header().Set("Content-Type", contentType)
+	http.ServeContent(w, req, "", time.Time{}, streamer)
+}
+
 // renterDownloadAsyncHandler handles the API call to download a file asynchronously.
 func (api *API) renterDownloadAsyncHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
 	destination := req.FormValue("destination")
@@ -347,13 +515,21 @@ func (api *API) renterDownloadAsyncHandler(w http.ResponseWriter, req *http.Requ
 		return
 	}
 
+	offset, length, ranged, err := parseDownloadRange(req.FormValue("offset"), req.FormValue("length"))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
 	siapath := strings.TrimPrefix(ps.ByName("siapath"), "/")
 	fileExists := false
+	var filesize uint64
 
 	files := api.renter.FileList()
 	for _, file := range files {
 		if file.SiaPath == siapath {
 			fileExists = true
+			filesize = file.Filesize
 		}
 	}
 
@@ -362,7 +538,17 @@ func (api *API) renterDownloadAsyncHandler(w http.ResponseWriter, req *http.Requ
 		return
 	}
 
-	go api.renter.Download(strings.TrimPrefix(ps.ByName("siapath"), "/"), destination)
+	// Check the range here, as errors from the download itself are not
+	// returned.
+	if ranged {
+		if offset+length < offset || offset+length > filesize {
+			WriteError(w, Error{"requested range extends past the end of the file"}, http.StatusBadRequest)
+			return
+		}
+		go api.renter.DownloadRange(siapath, destination, offset, length)
+	} else {
+		go api.renter.Download(siapath, destination)
+	}
 
 	WriteSuccess(w)
 }
@@ -407,50 +593,14 @@ func (api *API) renterUploadHandler(w http.ResponseWriter, req *http.Request, ps
 		return
 	}
 
-	// Check whether the erasure coding parameters have been supplied.
-	var ec modules.ErasureCoder
-	if req.FormValue("datapieces") != "" || req.FormValue("paritypieces") != "" {
-		// Check that both values have been supplied.
-		if req.FormValue("datapieces") == "" || req.FormValue("paritypieces") == "" {
-			WriteError(w, Error{"must provide both the datapieces paramaeter and the paritypieces parameter if specifying erasure coding parameters"}, http.StatusBadRequest)
-			return
-		}
-
-		// Parse the erasure coding parameters.
-		var dataPieces, parityPieces int
-		_, err := fmt.Sscan(req.FormValue("datapieces"), &dataPieces)
-		if err != nil {
-			WriteError(w, Error{"unable to read parameter 'datapieces': " + err.Error()}, http.StatusBadRequest)
-			return
-		}
-		_, err = fmt.Sscan(req.FormValue("paritypieces"), &parityPieces)
-		if err != nil {
-			WriteError(w, Error{"unable to read parameter 'paritypieces': " + err.Error()}, http.StatusBadRequest)
-			return
-		}
-
-		// Verify that sane values for parityPieces and redundancy are being
-		// supplied.
-		if parityPieces < requiredParityPieces {
-			WriteError(w, Error{fmt.Sprintf("a minimum of %v parity pieces is required, but %v parity pieces requested", parityPieces, requiredParityPieces)}, http.StatusBadRequest)
-			return
-		}
-		redundancy := float64(dataPieces+parityPieces) / float64(dataPieces)
-		if float64(dataPieces+parityPieces)/float64(dataPieces) < requiredRedundancy {
-			WriteError(w, Error{fmt.Sprintf("a redundancy of %.2f is required, but redundancy of %.2f supplied", redundancy, requiredRedundancy)}, http.StatusBadRequest)
-			return
-		}
-
-		// Create the erasure coder.
-		ec, err = renter.NewRSCode(dataPieces, parityPieces)
-		if err != nil {
-			WriteError(w, Error{"unable to encode file using the provided parameters: " + err.Error()}, http.StatusBadRequest)
-			return
-		}
+	ec, err := parseErasureCode(req.FormValue("datapieces"), req.FormValue("paritypieces"))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
 	}
 
 	// Call the renter to upload the file.
-	err := api.renter.Upload(modules.FileUploadParams{
+	err = api.renter.Upload(modules.FileUploadParams{
 		Source:      source,
 		SiaPath:     strings.TrimPrefix(ps.ByName("siapath"), "/"),
 		ErasureCode: ec,
@@ -461,3 +611,99 @@ func (api *API) renterUploadHandler(w http.ResponseWriter, req *http.Request, ps
 	}
 	WriteSuccess(w)
 }
+
+// renterUploadStreamHandler handles the API call to upload the body of the
+// request as a file. The parameters are only read from the query string, so
+// that the body is never parsed as a form.
+func (api *API) renterUploadStreamHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	query := req.URL.Query()
+	ec, err := parseErasureCode(query.Get("datapieces"), query.Get("paritypieces"))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	// Call the renter to upload the body of the request.
+	err = api.renter.UploadStream(modules.FileUploadParams{
+		SiaPath:     strings.TrimPrefix(ps.ByName("siapath"), "/"),
+		ErasureCode: ec,
+	}, req.Body)
+	if err != nil {
+		WriteError(w, Error{"upload failed: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// parseDownloadRange parses the offset and length parameters of a download.
+// If neither is supplied, ranged is false and the whole file is downloaded.
+func parseDownloadRange(offsetStr, lengthStr string) (offset, length uint64, ranged bool, err error) {
+	// Check whether the range has been supplied.
+	if offsetStr == "" && lengthStr == "" {
+		return 0, 0, false, nil
+	}
+
+	// Check that both values have been supplied.
+	if offsetStr == "" || lengthStr == "" {
+		return 0, 0, false, errors.New("must provide both the offset parameter and the length parameter if downloading a range")
+	}
+
+	_, err = fmt.Sscan(offsetStr, &offset)
+	if err != nil {
+		return 0, 0, false, errors.New("unable to read parameter 'offset': " + err.Error())
+	}
+	_, err = fmt.Sscan(lengthStr, &length)
+	if err != nil {
+		return 0, 0, false, errors.New("unable to read parameter 'length': " + err.Error())
+	}
+	if length == 0 {
+		return 0, 0, false, errors.New("length must be greater than zero")
+	}
+	return offset, length, true, nil
+}
+
+// parseErasureCode returns the erasure coder described by the datapieces and
+// paritypieces parameters of an upload. If neither is supplied, nil is
+// returned so that the renter uses its default erasure code.
+func parseErasureCode(dataPiecesStr, parityPiecesStr string) (modules.ErasureCoder, error) {
+	// Check whether the erasure coding parameters have been supplied.
+	if dataPiecesStr == "" && parityPiecesStr == "" {
+		return nil, nil
+	}
+
+	// Check that both values have been supplied.
+	if dataPiecesStr == "" || parityPiecesStr == "" {
+		return nil, errors.New("must provide both the datapieces paramaeter and the paritypieces parameter if specifying erasure coding parameters")
+	}
+
+	// Parse the erasure coding parameters.
+	var dataPieces, parityPieces int
+	_, err := fmt.Sscan(dataPiecesStr, &dataPieces)
+	if err != nil {
+		return nil, errors.New("unable to read parameter 'datapieces': " + err.Error())
+	}
+	_, err = fmt.Sscan(parityPiecesStr, &parityPieces)
+	if err != nil {
+		return nil, errors.New("unable to read parameter 'paritypieces': " + err.Error())
+	}
+
+	// Verify that sane values for parityPieces and redundancy are being
+	// supplied.
+	if parityPieces < requiredParityPieces {
+		return nil, fmt.Errorf("a minimum of %v parity pieces is required, but %v parity pieces requested", requiredParityPieces, parityPieces)
+	}
+	if dataPieces < 1 {
+		return nil, fmt.Errorf("at least 1 data piece is required, but %v data pieces requested", dataPieces)
+	}
+	redundancy := float64(dataPieces+parityPieces) / float64(dataPieces)
+	if redundancy < requiredRedundancy {
+		return nil, fmt.Errorf("a redundancy of %.2f is required, but redundancy of %.2f supplied", requiredRedundancy, redundancy)
+	}
+
+	// Create the erasure coder.
+	ec, err := renter.NewRSCode(dataPieces, parityPieces)
+	if err != nil {
+		return nil, errors.New("unable to encode file using the provided parameters: " + err.Error())
+	}
+	return ec, nil
+}
@@ -1,7 +1,9 @@
 package api
 
 import (
+	"bytes"
 	"io/ioutil"
+	"net/http"
 	"net/url"
 	"path/filepath"
 	"strconv"
@@ -280,6 +282,328 @@ func TestRenterAsyncDownload(t *testing.T) {
 	}
 }
 
+// TestRenterDownloadHTTPResponse tests that the /renter/download route writes
+// the file to the response when httpresp is set, and that it honors Range
+// requests.
+func TestRenterDownloadHTTPResponse(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	// Announce the host and start accepting contracts.
+	err = st.announceHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.acceptContracts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.setHostStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Set an allowance for the renter, allowing a contract to be formed.
+	allowanceValues := url.Values{}
+	allowanceValues.Set("funds", testFunds)
+	allowanceValues.Set("period", testPeriod)
+	err = st.stdPostAPI("/renter", allowanceValues)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a file and upload it to the host.
+	path := filepath.Join(build.SiaTestingDir, "api", t.Name(), "test.dat")
+	err = createRandFile(path, 1e4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uploadValues := url.Values{}
+	uploadValues.Set("source", path)
+	err = st.stdPostAPI("/renter/upload/test.dat", uploadValues)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rf RenterFiles
+	for i := 0; i < 100 && (len(rf.Files) != 1 || !rf.Files[0].Available); i++ {
+		st.getAPI("/renter/files", &rf)
+		time.Sleep(100 * time.Millisecond)
+	}
+	if len(rf.Files) != 1 || !rf.Files[0].Available {
+		t.Fatal("the uploading is not succeeding for some reason:", rf.Files)
+	}
+
+	// download fetches the file from the response body, optionally
+	// requesting a byte range.
+	download := func(byteRange string) (int, []byte, error) {
+		req, err := http.NewRequest("GET", "http://"+st.server.listener.Addr().String()+"/renter/download/test.dat?httpresp=true", nil)
+		if err != nil {
+			return 0, nil, err
+		}
+		req.Header.Set("User-Agent", "Sia-Agent")
+		if byteRange != "" {
+			req.Header.Set("Range", byteRange)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return 0, nil, err
+		}
+		defer resp.Body.Close()
+		data, err := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, data, err
+	}
+
+	status, data, err := download("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != http.StatusOK || !bytes.Equal(data, orig) {
+		t.Fatal("downloaded file does not match the original, status", status)
+	}
+	status, data, err = download("bytes=100-5099")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != http.StatusPartialContent || !bytes.Equal(data, orig[100:5100]) {
+		t.Fatal("downloaded range does not match the original, status", status)
+	}
+	status, data, err = download("bytes=-10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != http.StatusPartialContent || !bytes.Equal(data, orig[len(orig)-10:]) {
+		t.Fatal("downloaded suffix does not match the original, status", status)
+	}
+
+	// Streaming a file that does not exist fails.
+	err = st.stdGetAPI("/renter/download/nonexistent.dat?httpresp=true")
+	if err == nil {
+		t.Fatal("expected an error when streaming a nonexistent file")
+	}
+}
+
+// TestRenterStream tests that the /renter/stream route writes the file to the
+// response body with a Content-Type matching its extension, and honors Range
+// requests.
+func TestRenterStream(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	// Announce the host and start accepting contracts.
+	err = st.announceHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.acceptContracts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.setHostStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Set an allowance for the renter, allowing a contract to be formed.
+	allowanceValues := url.Values{}
+	allowanceValues.Set("funds", testFunds)
+	allowanceValues.Set("period", testPeriod)
+	err = st.stdPostAPI("/renter", allowanceValues)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a file and upload it to the host.
+	path := filepath.Join(build.SiaTestingDir, "api", t.Name(), "test.dat")
+	err = createRandFile(path, 1e4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uploadValues := url.Values{}
+	uploadValues.Set("source", path)
+	err = st.stdPostAPI("/renter/upload/test.dat", uploadValues)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rf RenterFiles
+	for i := 0; i < 100 && (len(rf.Files) != 1 || !rf.Files[0].Available); i++ {
+		st.getAPI("/renter/files", &rf)
+		time.Sleep(100 * time.Millisecond)
+	}
+	if len(rf.Files) != 1 || !rf.Files[0].Available {
+		t.Fatal("the uploading is not succeeding for some reason:", rf.Files)
+	}
+
+	// stream fetches the file at siapath from the response body, optionally
+	// requesting a byte range.
+	stream := func(siapath, byteRange string) (*http.Response, []byte, error) {
+		req, err := http.NewRequest("GET", "http://"+st.server.listener.Addr().String()+"/renter/stream/"+siapath, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		req.Header.Set("User-Agent", "Sia-Agent")
+		if byteRange != "" {
+			req.Header.Set("Range", byteRange)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer resp.Body.Close()
+		data, err := ioutil.ReadAll(resp.Body)
+		return resp, data, err
+	}
+
+	resp, data, err := stream("test.dat", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || !bytes.Equal(data, orig) {
+		t.Fatal("streamed file does not match the original, status", resp.StatusCode)
+	} else if ct := resp.Header.Get("Content-Type"); ct != "application/octet-stream" {
+		t.Fatal("wrong Content-Type for a file without a known extension:", ct)
+	}
+
+	// After renaming the file, the Content-Type should follow the new
+	// extension.
+	renameValues := url.Values{}
+	renameValues.Set("newsiapath", "test.txt")
+	err = st.stdPostAPI("/renter/rename/test.dat", renameValues)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, data, err = stream("test.txt", "bytes=200-299")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusPartialContent || !bytes.Equal(data, orig[200:300]) {
+		t.Fatal("streamed range does not match the original, status", resp.StatusCode)
+	} else if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Fatal("wrong Content-Type for a .txt file:", ct)
+	}
+
+	// Streaming a file that does not exist fails.
+	err = st.stdGetAPI("/renter/stream/nonexistent.dat")
+	if err == nil {
+		t.Fatal("expected an error when streaming a nonexistent file")
+	}
+}
+
+// TestRenterUploadStream tests that the /renter/uploadstream route uploads
+// the body of the request as a file.
+func TestRenterUploadStream(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	// Announce the host and start accepting contracts.
+	err = st.announceHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.acceptContracts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.setHostStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Set an allowance for the renter, allowing a contract to be formed.
+	allowanceValues := url.Values{}
+	allowanceValues.Set("funds", testFunds)
+	allowanceValues.Set("period", testPeriod)
+	err = st.stdPostAPI("/renter", allowanceValues)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Upload random data from the body of the request.
+	data := fastrand.Bytes(1e4)
+	uploadStream := func(call string) error {
+		req, err := http.NewRequest("POST", "http://"+st.server.listener.Addr().String()+call, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("User-Agent", "Sia-Agent")
+		req.Header.Set("Content-Type", "application/octet-stream")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if non2xx(resp.StatusCode) {
+			return decodeError(resp)
+		}
+		return nil
+	}
+	err = uploadStream("/renter/uploadstream/test.dat")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The file is complete once the call returns.
+	var rf RenterFiles
+	err = st.getAPI("/renter/files", &rf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rf.Files) != 1 || rf.Files[0].Filesize != uint64(len(data)) || !rf.Files[0].Available {
+		t.Fatal("streamed file was not uploaded:", rf.Files)
+	}
+
+	// Download the file and compare it to the uploaded data.
+	downpath := filepath.Join(st.dir, "down.dat")
+	err = st.stdGetAPI("/renter/download/test.dat?destination=" + downpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	downData, err := ioutil.ReadFile(downpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(downData, data) {
+		t.Fatal("downloaded file does not match the uploaded data")
+	}
+
+	// Uploading to a siapath that is in use fails.
+	err = uploadStream("/renter/uploadstream/test.dat")
+	if err == nil {
+		t.Fatal("expected an error when uploading to an existing siapath")
+	}
+}
+
 // TestRenterPaths tests that the /renter routes handle path parameters
 // properly.
 func TestRenterPaths(t *testing.T) {
@@ -292,6 +292,9 @@ func TestHostAndRentMultiHost(t *testing.T) {
 	if len(rf.Files) != 1 || rf.Files[0].UploadProgress < 50 {
 		t.Fatal("the uploading is not succeeding for some reason:", rf.Files[0])
 	}
+	if rf.Files[0].DataPieces != 2 || rf.Files[0].ParityPieces != 4 {
+		t.Fatal("file has the wrong erasure coding parameters:", rf.Files[0].DataPieces, rf.Files[0].ParityPieces)
+	}
 
 	// Try downloading the file.
 	downpath := filepath.Join(st.dir, "testdown.dat")
@@ -320,6 +323,33 @@ func TestHostAndRentMultiHost(t *testing.T) {
 	if len(queue.Downloads) != 1 {
 		t.Fatalf("expected renter to have 1 download in the queue; got %v", len(queue.Downloads))
 	}
+
+	// Download a range of the file, and check that only the range is written
+	// to the destination.
+	rangepath := filepath.Join(st.dir, "testrange.dat")
+	err = st.stdGetAPI("/renter/download/test?offset=1000&length=30000&destination=" + rangepath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	download, err = ioutil.ReadFile(rangepath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(orig[1000:31000], download) {
+		t.Fatal("data mismatch when downloading a range of a file")
+	}
+	if err = st.getAPI("/renter/downloads", &queue); err != nil {
+		t.Fatal(err)
+	}
+	if len(queue.Downloads) != 2 || queue.Downloads[0].Offset != 1000 || queue.Downloads[0].Length != 30000 {
+		t.Fatal("range download is not reported correctly in the queue:", queue.Downloads)
+	}
+
+	// Ranges that extend past the end of the file are rejected.
+	err = st.stdGetAPI("/renter/download/test?offset=40000&length=10000&destination=" + rangepath)
+	if err == nil {
+		t.Fatal("expected an error when downloading past the end of the file")
+	}
 }
 
 // TestHostAndRentManyFiles sets up an integration test where a single renter
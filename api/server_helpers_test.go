@@ -320,7 +320,7 @@ func assembleExplorerServerTester(testdir string) (*serverTester, error) {
 	if err != nil {
 		return nil, err
 	}
-	e, err := explorer.New(cs, filepath.Join(testdir, modules.ExplorerDir))
+	e, err := explorer.New(g, cs, filepath.Join(testdir, modules.ExplorerDir))
 	if err != nil {
 		return nil, err
 	}
@@ -1,19 +1,374 @@
 package api
 
 import (
+	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"time"
 
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 
 	"github.com/julienschmidt/httprouter"
+
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// doubleSpendStreamBuffer is the number of double spends that are queued
+	// for a WebSocket client before further double spends are dropped.
+	doubleSpendStreamBuffer = 64
 )
 
-type TransactionPoolGET struct {
-	Transactions []types.Transaction `json:"transactions"`
+type (
+	// TransactionPoolTransaction describes a transaction in the transaction
+	// pool. FeePerByte is the fee-per-byte of the set containing the
+	// transaction, which determines when the transaction will be mined, and
+	// Age is the number of seconds since the transaction entered the pool.
+	TransactionPoolTransaction struct {
+		ID         types.TransactionID   `json:"id"`
+		SetID      crypto.Hash           `json:"setid"`
+		Size       uint64                `json:"size"`
+		Fees       types.Currency        `json:"fees"`
+		FeePerByte types.Currency        `json:"feeperbyte"`
+		FirstSeen  types.Timestamp       `json:"firstseen"`
+		Age        uint64                `json:"age"`
+		Parents    []types.TransactionID `json:"parents"`
+		Children   []types.TransactionID `json:"children"`
+	}
+
+	// TransactionPoolTransactionsGET lists every transaction in the
+	// transaction pool, with the sets paying the highest fee-per-byte first.
+	TransactionPoolTransactionsGET struct {
+		Size         uint64                       `json:"size"`
+		Transactions []TransactionPoolTransaction `json:"transactions"`
+	}
+
+	// TransactionPoolTransactionGET describes a single transaction in the
+	// transaction pool, along with the IDs of the transactions in its set.
+	TransactionPoolTransactionGET struct {
+		TransactionPoolTransaction
+		Transaction types.Transaction     `json:"transaction"`
+		Set         []types.TransactionID `json:"set"`
+	}
+
+	// TransactionPoolEvictionGET contains the policy used to evict
+	// transaction sets from the transaction pool. MaxAge is reported in
+	// seconds and MaxSize in bytes. Floor is the fee-per-byte that a set must
+	// pay to be accepted while the pool is full.
+	TransactionPoolEvictionGET struct {
+		MaxAge  uint64         `json:"maxage"`
+		MinFee  types.Currency `json:"minfee"`
+		MaxSize uint64         `json:"maxsize"`
+		Floor   types.Currency `json:"floor"`
+	}
+
+	// TransactionPoolRelayGET contains the limits on the transaction sets
+	// that each peer may relay to the transaction pool, and the relay
+	// activity of recent peers. Interval and BanDuration are reported in
+	// seconds.
+	TransactionPoolRelayGET struct {
+		MaxSets     uint64                             `json:"maxsets"`
+		MaxBytes    uint64                             `json:"maxbytes"`
+		Interval    uint64                             `json:"interval"`
+		BanDuration uint64                             `json:"banduration"`
+		Peers       []modules.TransactionPoolRelayPeer `json:"peers"`
+	}
+
+	// TransactionPoolRiskGET contains the risk summary of an unconfirmed
+	// transaction.
+	TransactionPoolRiskGET struct {
+		modules.TransactionPoolRisk
+	}
+
+	// doubleSpendStream forwards the double spends detected by the
+	// transaction pool to a WebSocket client. The transaction pool is locked
+	// while it notifies subscribers, so double spends are dropped instead of
+	// blocking if the client falls behind.
+	doubleSpendStream struct {
+		doubleSpends chan modules.DoubleSpend
+	}
+
+	// TransactionPoolFeeGET contains the fee recommendations of the
+	// transaction pool.
+	TransactionPoolFeeGET struct {
+		Minimum    types.Currency    `json:"minimum"`
+		Maximum    types.Currency    `json:"maximum"`
+		Target     types.BlockHeight `json:"target"`
+		FeePerByte types.Currency    `json:"feeperbyte"`
+	}
+)
+
+// transactionpoolEvictionHandlerGET handles the API call to get the eviction
+// policy of the transaction pool.
+func (api *API) transactionpoolEvictionHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	policy := api.tpool.EvictionPolicy()
+	WriteJSON(w, TransactionPoolEvictionGET{
+		MaxAge:  uint64(policy.MaxAge.Seconds()),
+		MinFee:  policy.MinFee,
+		MaxSize: policy.MaxSize,
+		Floor:   api.tpool.EvictionFloor(),
+	})
+}
+
+// transactionpoolEvictionHandlerPOST handles the API call to change the
+// eviction policy of the transaction pool. Fields that are not provided are
+// left unchanged.
+func (api *API) transactionpoolEvictionHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	policy := api.tpool.EvictionPolicy()
+	if m := req.FormValue("maxage"); m != "" {
+		var maxAge uint64
+		_, err := fmt.Sscan(m, &maxAge)
+		if err != nil {
+			WriteError(w, Error{"could not read maxage from POST call to /transactionpool/eviction: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		policy.MaxAge = time.Duration(maxAge) * time.Second
+	}
+	if f := req.FormValue("minfee"); f != "" {
+		_, err := fmt.Sscan(f, &policy.MinFee)
+		if err != nil {
+			WriteError(w, Error{"could not read minfee from POST call to /transactionpool/eviction: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	if m := req.FormValue("maxsize"); m != "" {
+		_, err := fmt.Sscan(m, &policy.MaxSize)
+		if err != nil {
+			WriteError(w, Error{"could not read maxsize from POST call to /transactionpool/eviction: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	err := api.tpool.SetEvictionPolicy(policy)
+	if err != nil {
+		WriteError(w, Error{"error when calling /transactionpool/eviction: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// transactionpoolFeeHandler handles the API call to get the recommended
+// transaction fees, optionally for a specific confirmation target.
+func (api *API) transactionpoolFeeHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	target := types.BlockHeight(1)
+	if t := req.FormValue("target"); t != "" {
+		_, err := fmt.Sscan(t, &target)
+		if err != nil {
+			WriteError(w, Error{"could not read target from GET call to /transactionpool/fee: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	fee, err := api.tpool.FeeEstimate(target)
+	if err != nil {
+		WriteError(w, Error{"error when calling /transactionpool/fee: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	min, max := api.tpool.FeeEstimation()
+	WriteJSON(w, TransactionPoolFeeGET{
+		Minimum:    min,
+		Maximum:    max,
+		Target:     target,
+		FeePerByte: fee,
+	})
 }
 
-// transactionpoolTransactionsHandler handles the API call to get the
-// transaction pool trasactions.
+// poolTransaction summarizes a transaction snapshot for the API.
+func poolTransaction(txn modules.TransactionSnapshot, set modules.TransactionSetSnapshot, now types.Timestamp) TransactionPoolTransaction {
+	var age uint64
+	if now > txn.FirstSeen {
+		age = uint64(now - txn.FirstSeen)
+	}
+	return TransactionPoolTransaction{
+		ID:         txn.ID,
+		SetID:      set.ID,
+		Size:       txn.Size,
+		Fees:       txn.Fees,
+		FeePerByte: set.FeePerByte,
+		FirstSeen:  txn.FirstSeen,
+		Age:        age,
+		Parents:    txn.Parents,
+		Children:   txn.Children,
+	}
+}
+
+// transactionpoolTransactionsHandler handles the API call to list the
+// transactions in the transaction pool.
 func (api *API) transactionpoolTransactionsHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	WriteJSON(w, TransactionPoolGET{Transactions: api.tpool.TransactionList()})
+	snapshot, err := api.tpool.TransactionSetSnapshot()
+	if err != nil {
+		WriteError(w, Error{"error when calling /transactionpool/transactions: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	now := types.CurrentTimestamp()
+	resp := TransactionPoolTransactionsGET{
+		Size:         snapshot.Size,
+		Transactions: []TransactionPoolTransaction{},
+	}
+	for _, set := range snapshot.Sets {
+		for _, txn := range set.Transactions {
+			resp.Transactions = append(resp.Transactions, poolTransaction(txn, set, now))
+		}
+	}
+	WriteJSON(w, resp)
+}
+
+// transactionpoolTransactionHandler handles the API call to look up a single
+// transaction in the transaction pool.
+func (api *API) transactionpoolTransactionHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	h, err := scanHash(ps.ByName("id"))
+	if err != nil {
+		WriteError(w, Error{"error when calling /transactionpool/transactions/:id: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	txn, set, exists := api.tpool.UnconfirmedTransaction(types.TransactionID(h))
+	if !exists {
+		WriteError(w, Error{"error when calling /transactionpool/transactions/:id: transaction not found in transaction pool"}, http.StatusNotFound)
+		return
+	}
+	resp := TransactionPoolTransactionGET{
+		TransactionPoolTransaction: poolTransaction(txn, set, types.CurrentTimestamp()),
+		Transaction:                txn.Transaction,
+	}
+	for _, t := range set.Transactions {
+		resp.Set = append(resp.Set, t.ID)
+	}
+	WriteJSON(w, resp)
+}
+
+// transactionpoolRiskHandler handles the API call to get the risk summary of
+// an unconfirmed transaction.
+func (api *API) transactionpoolRiskHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	h, err := scanHash(ps.ByName("id"))
+	if err != nil {
+		WriteError(w, Error{"error when calling /transactionpool/risk/:id: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	risk, exists := api.tpool.TransactionRisk(types.TransactionID(h))
+	if !exists {
+		WriteError(w, Error{"error when calling /transactionpool/risk/:id: transaction not found in transaction pool"}, http.StatusNotFound)
+		return
+	}
+	WriteJSON(w, TransactionPoolRiskGET{risk})
+}
+
+// transactionpoolRelayHandlerGET handles the API call to get the relay limits
+// of the transaction pool.
+func (api *API) transactionpoolRelayHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	limits := api.tpool.RelayLimits()
+	WriteJSON(w, TransactionPoolRelayGET{
+		MaxSets:     limits.MaxSets,
+		MaxBytes:    limits.MaxBytes,
+		Interval:    uint64(limits.Interval.Seconds()),
+		BanDuration: uint64(limits.BanDuration.Seconds()),
+		Peers:       api.tpool.RelayPeers(),
+	})
+}
+
+// transactionpoolRelayHandlerPOST handles the API call to change the relay
+// limits of the transaction pool. Fields that are not provided are left
+// unchanged.
+func (api *API) transactionpoolRelayHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	limits := api.tpool.RelayLimits()
+	if m := req.FormValue("maxsets"); m != "" {
+		_, err := fmt.Sscan(m, &limits.MaxSets)
+		if err != nil {
+			WriteError(w, Error{"could not read maxsets from POST call to /transactionpool/relay: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	if m := req.FormValue("maxbytes"); m != "" {
+		_, err := fmt.Sscan(m, &limits.MaxBytes)
+		if err != nil {
+			WriteError(w, Error{"could not read maxbytes from POST call to /transactionpool/relay: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	if i := req.FormValue("interval"); i != "" {
+		var interval uint64
+		_, err := fmt.Sscan(i, &interval)
+		if err != nil {
+			WriteError(w, Error{"could not read interval from POST call to /transactionpool/relay: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		limits.Interval = time.Duration(interval) * time.Second
+	}
+	if b := req.FormValue("banduration"); b != "" {
+		var banDuration uint64
+		_, err := fmt.Sscan(b, &banDuration)
+		if err != nil {
+			WriteError(w, Error{"could not read banduration from POST call to /transactionpool/relay: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		limits.BanDuration = time.Duration(banDuration) * time.Second
+	}
+	err := api.tpool.SetRelayLimits(limits)
+	if err != nil {
+		WriteError(w, Error{"error when calling /transactionpool/relay: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// transactionpoolSnapshotHandler handles the API call to get a snapshot of
+// every transaction set in the transaction pool. The snapshot is encoded as
+// JSON unless the Sia encoding is requested.
+func (api *API) transactionpoolSnapshotHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	enc := req.FormValue("encoding")
+	if enc != "" && enc != "json" && enc != "sia" {
+		WriteError(w, Error{"encoding must be 'json' or 'sia'"}, http.StatusBadRequest)
+		return
+	}
+	snapshot, err := api.tpool.TransactionSetSnapshot()
+	if err != nil {
+		WriteError(w, Error{"error when calling /transactionpool/snapshot: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	if enc == "sia" {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(encoding.Marshal(snapshot))
+		return
+	}
+	WriteJSON(w, snapshot)
+}
+
+// ReceiveDoubleSpend implements modules.DoubleSpendSubscriber.
+func (dss *doubleSpendStream) ReceiveDoubleSpend(ds modules.DoubleSpend) {
+	select {
+	case dss.doubleSpends <- ds:
+	default:
+	}
+}
+
+// transactionpoolDoubleSpendsHandler handles the API call to stream the double
+// spends detected by the transaction pool over a WebSocket. Each double spend
+// is sent as a JSON text message.
+func (api *API) transactionpoolDoubleSpendsHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	websocket.Server{Handler: func(ws *websocket.Conn) {
+		dss := &doubleSpendStream{
+			doubleSpends: make(chan modules.DoubleSpend, doubleSpendStreamBuffer),
+		}
+		api.tpool.DoubleSpendSubscribe(dss)
+		defer api.tpool.DoubleSpendUnsubscribe(dss)
+
+		// Clients are not expected to send anything. A failed read means that
+		// the client has disconnected.
+		closed := make(chan struct{})
+		go func() {
+			io.Copy(ioutil.Discard, ws)
+			close(closed)
+		}()
+		for {
+			select {
+			case ds := <-dss.doubleSpends:
+				if err := websocket.JSON.Send(ws, ds); err != nil {
+					return
+				}
+			case <-closed:
+				return
+			}
+		}
+	}}.ServeHTTP(w, req)
 }
@@ -0,0 +1,336 @@
+package api
+
+import (
+	"io/ioutil"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"golang.org/x/net/websocket"
+)
+
+// TestTransactionPoolFee checks that the /transactionpool/fee endpoint returns
+// the fee recommendations of the transaction pool and validates the target.
+func TestTransactionPoolFee(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	var tpfg TransactionPoolFeeGET
+	if err := st.getAPI("/transactionpool/fee", &tpfg); err != nil {
+		t.Fatal(err)
+	}
+	min, max := st.tpool.FeeEstimation()
+	if !tpfg.Minimum.Equals(min) || !tpfg.Maximum.Equals(max) {
+		t.Error("fee recommendations do not match the transaction pool:", tpfg)
+	}
+	if tpfg.Target != 1 || !tpfg.FeePerByte.IsZero() {
+		t.Error("unexpected estimate for an uncongested network:", tpfg)
+	}
+
+	if err := st.getAPI("/transactionpool/fee?target=6", &tpfg); err != nil {
+		t.Fatal(err)
+	}
+	if tpfg.Target != types.BlockHeight(6) {
+		t.Error("target was not used:", tpfg.Target)
+	}
+	if err := st.getAPI("/transactionpool/fee?target=0", &tpfg); err == nil {
+		t.Error("expected an error for a zero target")
+	}
+}
+
+// TestTransactionPoolEviction checks that the eviction policy of the
+// transaction pool can be viewed and changed with /transactionpool/eviction.
+func TestTransactionPoolEviction(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	var tpeg TransactionPoolEvictionGET
+	if err := st.getAPI("/transactionpool/eviction", &tpeg); err != nil {
+		t.Fatal(err)
+	}
+	if tpeg.MaxAge != uint64(st.tpool.EvictionPolicy().MaxAge.Seconds()) || !tpeg.MinFee.IsZero() {
+		t.Error("unexpected default eviction policy:", tpeg)
+	}
+
+	// Change only the maximum age.
+	values := url.Values{}
+	values.Set("maxage", "600")
+	if err := st.stdPostAPI("/transactionpool/eviction", values); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.getAPI("/transactionpool/eviction", &tpeg); err != nil {
+		t.Fatal(err)
+	}
+	if tpeg.MaxAge != 600 || !tpeg.MinFee.IsZero() {
+		t.Error("maxage was not set:", tpeg)
+	}
+	if st.tpool.EvictionPolicy().MaxAge != 10*time.Minute {
+		t.Error("maxage was not passed to the transaction pool")
+	}
+
+	// Change only the minimum fee.
+	values = url.Values{}
+	values.Set("minfee", "1000")
+	if err := st.stdPostAPI("/transactionpool/eviction", values); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.getAPI("/transactionpool/eviction", &tpeg); err != nil {
+		t.Fatal(err)
+	}
+	if tpeg.MaxAge != 600 || !tpeg.MinFee.Equals(types.NewCurrency64(1000)) {
+		t.Error("minfee was not set:", tpeg)
+	}
+
+	// Change only the maximum size.
+	values = url.Values{}
+	values.Set("maxsize", "1000000")
+	if err := st.stdPostAPI("/transactionpool/eviction", values); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.getAPI("/transactionpool/eviction", &tpeg); err != nil {
+		t.Fatal(err)
+	}
+	if tpeg.MaxSize != 1e6 || tpeg.MaxAge != 600 || !tpeg.Floor.IsZero() {
+		t.Error("maxsize was not set:", tpeg)
+	}
+
+	values = url.Values{}
+	values.Set("maxage", "foo")
+	if err := st.stdPostAPI("/transactionpool/eviction", values); err == nil {
+		t.Error("expected an error for an invalid maxage")
+	}
+	values = url.Values{}
+	values.Set("maxsize", "1")
+	if err := st.stdPostAPI("/transactionpool/eviction", values); err == nil {
+		t.Error("expected an error for a maxsize that is too small")
+	}
+}
+
+// TestTransactionPoolRelay checks that the relay limits of the transaction
+// pool can be viewed and changed with /transactionpool/relay.
+func TestTransactionPoolRelay(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	var tprg TransactionPoolRelayGET
+	if err := st.getAPI("/transactionpool/relay", &tprg); err != nil {
+		t.Fatal(err)
+	}
+	limits := st.tpool.RelayLimits()
+	if tprg.MaxSets != limits.MaxSets || tprg.Interval != uint64(limits.Interval.Seconds()) || len(tprg.Peers) != 0 {
+		t.Error("unexpected default relay limits:", tprg)
+	}
+
+	// Change only the interval and the ban duration.
+	values := url.Values{}
+	values.Set("interval", "30")
+	values.Set("banduration", "0")
+	if err := st.stdPostAPI("/transactionpool/relay", values); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.getAPI("/transactionpool/relay", &tprg); err != nil {
+		t.Fatal(err)
+	}
+	if tprg.Interval != 30 || tprg.BanDuration != 0 || tprg.MaxSets != limits.MaxSets {
+		t.Error("the interval and ban duration were not set:", tprg)
+	}
+	if st.tpool.RelayLimits().Interval != 30*time.Second {
+		t.Error("the interval was not passed to the transaction pool")
+	}
+
+	values = url.Values{}
+	values.Set("interval", "0")
+	if err := st.stdPostAPI("/transactionpool/relay", values); err == nil {
+		t.Error("expected an error for a zero interval")
+	}
+	values = url.Values{}
+	values.Set("maxsets", "foo")
+	if err := st.stdPostAPI("/transactionpool/relay", values); err == nil {
+		t.Error("expected an error for an invalid maxsets")
+	}
+}
+
+// TestTransactionPoolSnapshot checks that /transactionpool/snapshot returns
+// the same snapshot in JSON and in the Sia encoding.
+func TestTransactionPoolSnapshot(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	_, err = st.wallet.SendSiacoins(types.SiacoinPrecision, types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var snapshot modules.TransactionPoolSnapshot
+	if err := st.getAPI("/transactionpool/snapshot", &snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshot.Sets) != 1 || len(snapshot.Sets[0].Transactions) == 0 {
+		t.Fatal("snapshot does not contain the sent transaction set:", snapshot)
+	}
+	if snapshot.Size != snapshot.Sets[0].Size {
+		t.Error("snapshot size does not match the size of its sets")
+	}
+
+	resp, err := HttpGET("http://" + st.server.listener.Addr().String() + "/transactionpool/snapshot?encoding=sia")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	snapshotBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var siaSnapshot modules.TransactionPoolSnapshot
+	if err := encoding.Unmarshal(snapshotBytes, &siaSnapshot); err != nil {
+		t.Fatal(err)
+	}
+	if siaSnapshot.ConsensusChange != snapshot.ConsensusChange || len(siaSnapshot.Sets) != 1 || siaSnapshot.Sets[0].ID != snapshot.Sets[0].ID {
+		t.Error("Sia encoded snapshot does not match the JSON snapshot")
+	}
+
+	if err := st.stdGetAPI("/transactionpool/snapshot?encoding=xml"); err == nil {
+		t.Error("expected an error for an unknown encoding")
+	}
+
+	// The transactions in the snapshot should also be listed individually.
+	var tptg TransactionPoolTransactionsGET
+	if err := st.getAPI("/transactionpool/transactions", &tptg); err != nil {
+		t.Fatal(err)
+	}
+	set := snapshot.Sets[0]
+	if len(tptg.Transactions) != len(set.Transactions) || tptg.Size != snapshot.Size {
+		t.Fatal("transaction list does not match the snapshot:", tptg)
+	}
+	last := tptg.Transactions[len(tptg.Transactions)-1]
+	if last.SetID != set.ID || !last.FeePerByte.Equals(set.FeePerByte) || last.Size == 0 {
+		t.Fatal("wrong transaction summary:", last)
+	}
+
+	var tptid TransactionPoolTransactionGET
+	if err := st.getAPI("/transactionpool/transactions/"+last.ID.String(), &tptid); err != nil {
+		t.Fatal(err)
+	}
+	if tptid.ID != last.ID || tptid.Transaction.ID() != last.ID || len(tptid.Set) != len(set.Transactions) {
+		t.Fatal("wrong transaction returned by lookup:", tptid)
+	}
+	if err := st.stdGetAPI("/transactionpool/transactions/" + types.TransactionID{}.String()); err == nil {
+		t.Error("expected an error when looking up an unknown transaction")
+	}
+}
+
+// TestTransactionPoolDoubleSpends checks that double spends detected by the
+// transaction pool are streamed over the /transactionpool/doublespends
+// WebSocket.
+func TestTransactionPoolDoubleSpends(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	// Create a confirmed output that anyone can spend, and spend it.
+	emptyUH := types.UnlockConditions{}.UnlockHash()
+	builder := st.wallet.StartTransaction()
+	funding := types.SiacoinPrecision.Mul64(10)
+	if err := builder.FundSiacoins(funding); err != nil {
+		t.Fatal(err)
+	}
+	i := builder.AddSiacoinOutput(types.SiacoinOutput{Value: funding, UnlockHash: emptyUH})
+	tSet, err := builder.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.tpool.AcceptTransactionSet(tSet); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	outputID := tSet[len(tSet)-1].SiacoinOutputID(i)
+	spend := func(fee types.Currency, dest types.UnlockHash) []types.Transaction {
+		return []types.Transaction{{
+			SiacoinInputs:  []types.SiacoinInput{{ParentID: outputID}},
+			SiacoinOutputs: []types.SiacoinOutput{{Value: funding.Sub(fee), UnlockHash: dest}},
+			MinerFees:      []types.Currency{fee},
+		}}
+	}
+	original := spend(types.SiacoinPrecision, types.UnlockHash{1})
+	if err := st.tpool.AcceptTransactionSet(original); err != nil {
+		t.Fatal(err)
+	}
+
+	addr := st.server.listener.Addr().String()
+	config, err := websocket.NewConfig("ws://"+addr+"/transactionpool/doublespends", "http://"+addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Header.Set("User-Agent", "Sia-Agent")
+	ws, err := websocket.DialConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	// The stream subscribes to the transaction pool after the handshake
+	// completes, so the double spend is submitted until it is reported.
+	doubleSpend := spend(types.SiacoinPrecision.Div64(2), types.UnlockHash{2})
+	var ds modules.DoubleSpend
+	for tries := 0; ; tries++ {
+		if tries == 50 {
+			t.Fatal("double spend was not reported")
+		}
+		if err := st.tpool.AcceptTransactionSet(doubleSpend); err == nil {
+			t.Fatal("double spend was accepted")
+		}
+		ws.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		if err := websocket.JSON.Receive(ws, &ds); err == nil {
+			break
+		}
+	}
+	if len(ds.Transactions) != 1 || ds.Transactions[0] != doubleSpend[0].ID() {
+		t.Error("wrong transactions reported:", ds.Transactions)
+	}
+	if len(ds.ConflictingTransactions) != 1 || ds.ConflictingTransactions[0] != original[0].ID() {
+		t.Error("wrong conflicting transactions reported:", ds.ConflictingTransactions)
+	}
+	if ds.Confirmed || ds.Evicted {
+		t.Error("rejected unconfirmed double spend reported as confirmed or evicted")
+	}
+}
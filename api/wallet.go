@@ -1,16 +1,19 @@
 package api
 
 import (
+	"encoding/json"
 	"net/http"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 
 	"github.com/NebulousLabs/entropy-mnemonics"
+	"github.com/NebulousLabs/fastrand"
 	"github.com/julienschmidt/httprouter"
 )
 
@@ -26,6 +29,12 @@ type (
 
 		SiafundBalance      types.Currency `json:"siafundbalance"`
 		SiacoinClaimBalance types.Currency `json:"siacoinclaimbalance"`
+
+		// LockTimeout and UnlockRemaining are reported in seconds.
+		// UnlockRemaining is zero if the wallet is locked or has no lock
+		// timeout.
+		LockTimeout     uint64 `json:"locktimeout"`
+		UnlockRemaining uint64 `json:"unlockremaining"`
 	}
 
 	// WalletAddressGET contains an address returned by a GET call to
@@ -34,12 +43,24 @@ type (
 		Address types.UnlockHash `json:"address"`
 	}
 
+	// WalletAddressGETaddr contains the balance and transaction history of
+	// the address provided in a GET call to /wallet/address/:addr.
+	WalletAddressGETaddr struct {
+		modules.AddressHistory
+	}
+
 	// WalletAddressesGET contains the list of wallet addresses returned by a
 	// GET call to /wallet/addresses.
 	WalletAddressesGET struct {
 		Addresses []types.UnlockHash `json:"addresses"`
 	}
 
+	// WalletAddressUsageGET contains the usage statistics of each address
+	// returned by a GET call to /wallet/addresses/usage.
+	WalletAddressUsageGET struct {
+		Addresses []modules.AddressUsage `json:"addresses"`
+	}
+
 	// WalletInitPOST contains the primary seed that gets generated during a
 	// POST call to /wallet/init.
 	WalletInitPOST struct {
@@ -50,6 +71,21 @@ type (
 	// /wallet/siafunds.
 	WalletSiacoinsPOST struct {
 		TransactionIDs []types.TransactionID `json:"transactionids"`
+
+		// PendingApproval indicates that the amount exceeded the approval
+		// threshold. Nothing was sent; the send was queued as PendingSend
+		// and must be approved with /wallet/approvals/approve/:id.
+		PendingApproval bool                `json:"pendingapproval"`
+		PendingSend     modules.PendingSend `json:"pendingsend"`
+	}
+
+	// WalletApprovalsGET contains the approval policy of the wallet and the
+	// sends that are waiting to be approved. Timeout is in seconds.
+	WalletApprovalsGET struct {
+		Threshold    types.Currency        `json:"threshold"`
+		Timeout      uint64                `json:"timeout"`
+		Window       uint64                `json:"window"`
+		PendingSends []modules.PendingSend `json:"pendingsends"`
 	}
 
 	// WalletSiafundsPOST contains the transaction sent in the POST call to
@@ -65,6 +101,26 @@ type (
 		AllSeeds           []string `json:"allseeds"`
 	}
 
+	// WalletSwapKeyGET contains a claim key for an atomic swap, returned by a
+	// call to /wallet/swap/key.
+	WalletSwapKeyGET struct {
+		Key string `json:"key"`
+	}
+
+	// WalletSwapSecretGET contains a new atomic swap secret and its hashlock,
+	// returned by a call to /wallet/swap/secret.
+	WalletSwapSecretGET struct {
+		Secret   crypto.Hash `json:"secret"`
+		HashLock string      `json:"hashlock"`
+	}
+
+	// WalletSwapTransactionPOST contains the ID of the claim or refund
+	// transaction broadcast by a call to /wallet/swap/claim or
+	// /wallet/swap/refund.
+	WalletSwapTransactionPOST struct {
+		TransactionID types.TransactionID `json:"transactionid"`
+	}
+
 	// WalletSweepPOST contains the coins and funds returned by a call to
 	// /wallet/sweep.
 	WalletSweepPOST struct {
@@ -72,6 +128,30 @@ type (
 		Funds types.Currency `json:"funds"`
 	}
 
+	// WalletDBCheckGET contains the results of checking the integrity of the
+	// wallet database.
+	WalletDBCheckGET struct {
+		modules.WalletDBReport
+	}
+
+	// WalletDBCompactPOST contains the results of compacting the wallet
+	// database.
+	WalletDBCompactPOST struct {
+		modules.WalletDBCompaction
+	}
+
+	// WalletProvenanceGETid contains the history of the output requested by a
+	// call to /wallet/provenance/$(id)
+	WalletProvenanceGETid struct {
+		Provenance modules.OutputProvenance `json:"provenance"`
+	}
+
+	// WalletSpendableGET contains the siacoins that will be spendable at the
+	// height requested in a call to /wallet/spendable.
+	WalletSpendableGET struct {
+		modules.SpendableBalance
+	}
+
 	// WalletTransactionGETid contains the transaction returned by a call to
 	// /wallet/transaction/$(id)
 	WalletTransactionGETid struct {
@@ -113,6 +193,13 @@ func encryptionKeys(seedStr string) (validKeys []crypto.TwofishKey) {
 func (api *API) walletHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	siacoinBal, siafundBal, siaclaimBal := api.wallet.ConfirmedBalance()
 	siacoinsOut, siacoinsIn := api.wallet.UnconfirmedBalance()
+	var unlockRemaining time.Duration
+	if unlockedUntil := api.wallet.UnlockedUntil(); !unlockedUntil.IsZero() {
+		unlockRemaining = time.Until(unlockedUntil)
+	}
+	if unlockRemaining < 0 {
+		unlockRemaining = 0
+	}
 	WriteJSON(w, WalletGET{
 		Encrypted: api.wallet.Encrypted(),
 		Unlocked:  api.wallet.Unlocked(),
@@ -123,6 +210,9 @@ func (api *API) walletHandler(w http.ResponseWriter, req *http.Request, _ httpro
 
 		SiafundBalance:      siafundBal,
 		SiacoinClaimBalance: siaclaimBal,
+
+		LockTimeout:     uint64(api.wallet.LockTimeout().Seconds()),
+		UnlockRemaining: uint64(unlockRemaining.Seconds()),
 	})
 }
 
@@ -168,6 +258,37 @@ func (api *API) walletAddressesHandler(w http.ResponseWriter, req *http.Request,
 	})
 }
 
+// walletAddressAddrHandler handles API calls to /wallet/address/:addr.
+func (api *API) walletAddressAddrHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	jsonAddr := "\"" + ps.ByName("addr") + "\""
+	var addr types.UnlockHash
+	err := addr.UnmarshalJSON([]byte(jsonAddr))
+	if err != nil {
+		WriteError(w, Error{"error when calling /wallet/address: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	ah, err := api.wallet.AddressHistory(addr)
+	if err != nil {
+		WriteError(w, Error{"error when calling /wallet/address: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, WalletAddressGETaddr{
+		AddressHistory: ah,
+	})
+}
+
+// walletAddressUsageHandler handles API calls to /wallet/addresses/usage.
+func (api *API) walletAddressUsageHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	usage, err := api.wallet.AddressUsage()
+	if err != nil {
+		WriteError(w, Error{"error when calling /wallet/addresses/usage: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, WalletAddressUsageGET{
+		Addresses: usage,
+	})
+}
+
 // walletBackupHandler handles API calls to /wallet/backup.
 func (api *API) walletBackupHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	destination := req.FormValue("destination")
@@ -176,7 +297,12 @@ func (api *API) walletBackupHandler(w http.ResponseWriter, req *http.Request, _
 		WriteError(w, Error{"error when calling /wallet/backup: destination must be an absolute path"}, http.StatusBadRequest)
 		return
 	}
-	err := api.wallet.CreateBackup(destination)
+	var err error
+	if password := req.FormValue("encryptionpassword"); password != "" {
+		err = api.wallet.CreateEncryptedBackup(destination, crypto.TwofishKey(crypto.HashObject(password)))
+	} else {
+		err = api.wallet.CreateBackup(destination)
+	}
 	if err != nil {
 		WriteError(w, Error{"error after call to /wallet/backup: " + err.Error()}, http.StatusBadRequest)
 		return
@@ -184,6 +310,47 @@ func (api *API) walletBackupHandler(w http.ResponseWriter, req *http.Request, _
 	WriteSuccess(w)
 }
 
+// walletChangePasswordHandler handles API calls to /wallet/changepassword.
+func (api *API) walletChangePasswordHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var newKey crypto.TwofishKey
+	if req.FormValue("newpassword") != "" {
+		newKey = crypto.TwofishKey(crypto.HashObject(req.FormValue("newpassword")))
+	}
+	potentialKeys := encryptionKeys(req.FormValue("encryptionpassword"))
+	for _, key := range potentialKeys {
+		err := api.wallet.ChangeKey(key, newKey)
+		if err == nil {
+			WriteSuccess(w)
+			return
+		}
+		if err != nil && err != modules.ErrBadEncryptionKey {
+			WriteError(w, Error{"error when calling /wallet/changepassword: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	WriteError(w, Error{"error when calling /wallet/changepassword: " + modules.ErrBadEncryptionKey.Error()}, http.StatusBadRequest)
+}
+
+// walletDBCheckHandler handles API calls to /wallet/db/check.
+func (api *API) walletDBCheckHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	report, err := api.wallet.CheckDB()
+	if err != nil {
+		WriteError(w, Error{"error when calling /wallet/db/check: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, WalletDBCheckGET{report})
+}
+
+// walletDBCompactHandler handles API calls to /wallet/db/compact.
+func (api *API) walletDBCompactHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	compaction, err := api.wallet.CompactDB()
+	if err != nil {
+		WriteError(w, Error{"error when calling /wallet/db/compact: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, WalletDBCompactPOST{compaction})
+}
+
 // walletInitHandler handles API calls to /wallet/init.
 func (api *API) walletInitHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	var encryptionKey crypto.TwofishKey
@@ -299,6 +466,101 @@ func (api *API) walletLockHandler(w http.ResponseWriter, req *http.Request, _ ht
 	WriteSuccess(w)
 }
 
+// walletLockTimeoutHandler handles API calls to /wallet/locktimeout.
+func (api *API) walletLockTimeoutHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	timeout, err := strconv.ParseUint(req.FormValue("timeout"), 10, 64)
+	if err != nil {
+		WriteError(w, Error{"error when calling /wallet/locktimeout: could not parse timeout: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	err = api.wallet.SetLockTimeout(time.Duration(timeout) * time.Second)
+	if err != nil {
+		WriteError(w, Error{"error when calling /wallet/locktimeout: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// walletApprovalsHandlerGET handles API calls to /wallet/approvals [GET].
+func (api *API) walletApprovalsHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	policy := api.wallet.ApprovalPolicy()
+	WriteJSON(w, WalletApprovalsGET{
+		Threshold:    policy.Threshold,
+		Timeout:      uint64(policy.Timeout / time.Second),
+		Window:       uint64(policy.Window / time.Second),
+		PendingSends: api.wallet.PendingSends(),
+	})
+}
+
+// walletApprovalsHandlerPOST handles API calls to /wallet/approvals [POST].
+func (api *API) walletApprovalsHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	threshold, ok := scanAmount(req.FormValue("threshold"))
+	if !ok {
+		WriteError(w, Error{"error when calling /wallet/approvals: could not parse threshold"}, http.StatusBadRequest)
+		return
+	}
+	timeout, err := strconv.ParseUint(req.FormValue("timeout"), 10, 64)
+	if err != nil {
+		WriteError(w, Error{"error when calling /wallet/approvals: could not parse timeout: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	var window uint64
+	if windowStr := req.FormValue("window"); windowStr != "" {
+		window, err = strconv.ParseUint(windowStr, 10, 64)
+		if err != nil {
+			WriteError(w, Error{"error when calling /wallet/approvals: could not parse window: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	err = api.wallet.SetApprovalPolicy(modules.ApprovalPolicy{
+		Threshold: threshold,
+		Timeout:   time.Duration(timeout) * time.Second,
+		Window:    time.Duration(window) * time.Second,
+	})
+	if err != nil {
+		WriteError(w, Error{"error when calling /wallet/approvals: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// walletApprovalsApproveHandler handles API calls to
+// /wallet/approvals/approve/:id.
+func (api *API) walletApprovalsApproveHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	var id crypto.Hash
+	if err := id.LoadString(ps.ByName("id")); err != nil {
+		WriteError(w, Error{"error when calling /wallet/approvals/approve: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	txns, err := api.wallet.ApproveSend(id)
+	if err != nil {
+		WriteError(w, Error{"error when calling /wallet/approvals/approve: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	var txids []types.TransactionID
+	for _, txn := range txns {
+		txids = append(txids, txn.ID())
+	}
+	WriteJSON(w, WalletSiacoinsPOST{
+		TransactionIDs: txids,
+	})
+}
+
+// walletApprovalsRejectHandler handles API calls to
+// /wallet/approvals/reject/:id.
+func (api *API) walletApprovalsRejectHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	var id crypto.Hash
+	if err := id.LoadString(ps.ByName("id")); err != nil {
+		WriteError(w, Error{"error when calling /wallet/approvals/reject: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	if err := api.wallet.RejectSend(id); err != nil {
+		WriteError(w, Error{"error when calling /wallet/approvals/reject: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
 // walletSeedsHandler handles API calls to /wallet/seeds.
 func (api *API) walletSeedsHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	dictionary := mnemonics.DictionaryID(req.FormValue("dictionary"))
@@ -354,7 +616,19 @@ func (api *API) walletSiacoinsHandler(w http.ResponseWriter, req *http.Request,
 	}
 
 	txns, err := api.wallet.SendSiacoins(amount, dest)
-	if err != nil {
+	if err == modules.ErrApprovalRequired {
+		ps, err := api.wallet.QueueSiacoins(amount, dest)
+		if err != nil {
+			WriteError(w, Error{"error after call to /wallet/siacoins: " + err.Error()}, http.StatusInternalServerError)
+			return
+		}
+		WriteJSON(w, WalletSiacoinsPOST{
+			TransactionIDs:  []types.TransactionID{},
+			PendingApproval: true,
+			PendingSend:     ps,
+		})
+		return
+	} else if err != nil {
 		WriteError(w, Error{"error after call to /wallet/siacoins: " + err.Error()}, http.StatusInternalServerError)
 		return
 	}
@@ -394,6 +668,35 @@ func (api *API) walletSiafundsHandler(w http.ResponseWriter, req *http.Request,
 	})
 }
 
+// walletSpendableHandler handles API calls to /wallet/spendable.
+func (api *API) walletSpendableHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	height := api.cs.Height()
+	if heightStr := req.FormValue("height"); heightStr != "" {
+		h, err := strconv.ParseUint(heightStr, 10, 64)
+		if err != nil {
+			WriteError(w, Error{"error when calling /wallet/spendable: could not parse height: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		height = types.BlockHeight(h)
+	}
+	var minConfirmations types.BlockHeight
+	if minConfStr := req.FormValue("minconfirmations"); minConfStr != "" {
+		m, err := strconv.ParseUint(minConfStr, 10, 64)
+		if err != nil {
+			WriteError(w, Error{"error when calling /wallet/spendable: could not parse minconfirmations: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		minConfirmations = types.BlockHeight(m)
+	}
+
+	sb, err := api.wallet.SpendableBalance(height, minConfirmations)
+	if err != nil {
+		WriteError(w, Error{"error when calling /wallet/spendable: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, WalletSpendableGET{sb})
+}
+
 // walletSweepSeedHandler handles API calls to /wallet/sweep/seed.
 func (api *API) walletSweepSeedHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	// Get the seed using the ditionary + phrase
@@ -418,6 +721,27 @@ func (api *API) walletSweepSeedHandler(w http.ResponseWriter, req *http.Request,
 	})
 }
 
+// walletProvenanceHandler handles API calls to /wallet/provenance/:id.
+func (api *API) walletProvenanceHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	// Parse the id from the url.
+	var id types.OutputID
+	jsonID := "\"" + ps.ByName("id") + "\""
+	err := id.UnmarshalJSON([]byte(jsonID))
+	if err != nil {
+		WriteError(w, Error{"error when calling /wallet/provenance/$(id): " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	provenance, err := api.wallet.OutputProvenance(id)
+	if err != nil {
+		WriteError(w, Error{"error when calling /wallet/provenance/$(id): " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, WalletProvenanceGETid{
+		Provenance: provenance,
+	})
+}
+
 // walletTransactionHandler handles API calls to /wallet/transaction/:id.
 func (api *API) walletTransactionHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
 	// Parse the id from the url.
@@ -490,6 +814,21 @@ func (api *API) walletTransactionsAddrHandler(w http.ResponseWriter, req *http.R
 	})
 }
 
+// walletUnlockExtendHandler handles API calls to /wallet/unlock/extend.
+func (api *API) walletUnlockExtendHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	duration, err := strconv.ParseUint(req.FormValue("duration"), 10, 64)
+	if err != nil {
+		WriteError(w, Error{"error when calling /wallet/unlock/extend: could not parse duration: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	err = api.wallet.ExtendUnlock(time.Duration(duration) * time.Second)
+	if err != nil {
+		WriteError(w, Error{"error when calling /wallet/unlock/extend: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
 // walletUnlockHandler handles API calls to /wallet/unlock.
 func (api *API) walletUnlockHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	potentialKeys := encryptionKeys(req.FormValue("encryptionpassword"))
@@ -506,3 +845,137 @@ func (api *API) walletUnlockHandler(w http.ResponseWriter, req *http.Request, _
 	}
 	WriteError(w, Error{"error when calling /wallet/unlock: " + modules.ErrBadEncryptionKey.Error()}, http.StatusBadRequest)
 }
+
+// scanSwap decodes an atomic swap from the body of a request.
+func scanSwap(req *http.Request) (swap modules.AtomicSwap, err error) {
+	err = json.NewDecoder(req.Body).Decode(&swap)
+	return swap, err
+}
+
+// walletSwapKeyHandler handles API calls to /wallet/swap/key.
+func (api *API) walletSwapKeyHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	key, err := api.wallet.AtomicSwapKey()
+	if err != nil {
+		WriteError(w, Error{"error after call to /wallet/swap/key: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, WalletSwapKeyGET{
+		Key: key.String(),
+	})
+}
+
+// walletSwapSecretHandler handles API calls to /wallet/swap/secret.
+func (api *API) walletSwapSecretHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var secret crypto.Hash
+	fastrand.Read(secret[:])
+	hashLock := modules.AtomicSwapHashLock(secret)
+	WriteJSON(w, WalletSwapSecretGET{
+		Secret:   secret,
+		HashLock: hashLock.String(),
+	})
+}
+
+// walletSwapConstructHandler handles API calls to /wallet/swap/construct.
+func (api *API) walletSwapConstructHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	value, ok := scanAmount(req.FormValue("value"))
+	if !ok {
+		WriteError(w, Error{"could not read 'value' from POST call to /wallet/swap/construct"}, http.StatusBadRequest)
+		return
+	}
+	var hashLock, claimKey types.SiaPublicKey
+	hashLock.LoadString(req.FormValue("hashlock"))
+	claimKey.LoadString(req.FormValue("claimkey"))
+	timelock, err := strconv.ParseUint(req.FormValue("timelock"), 10, 64)
+	if err != nil {
+		WriteError(w, Error{"could not read 'timelock' from POST call to /wallet/swap/construct: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	swap, err := api.wallet.ConstructAtomicSwap(value, hashLock, claimKey, types.BlockHeight(timelock))
+	if err != nil {
+		WriteError(w, Error{"error after call to /wallet/swap/construct: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, swap)
+}
+
+// walletSwapAuditHandler handles API calls to /wallet/swap/audit.
+func (api *API) walletSwapAuditHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	swap, err := scanSwap(req)
+	if err != nil {
+		WriteError(w, Error{"could not decode atomic swap: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	if err := api.wallet.AuditAtomicSwap(swap); err != nil {
+		WriteError(w, Error{"error after call to /wallet/swap/audit: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// walletSwapParticipateHandler handles API calls to /wallet/swap/participate.
+func (api *API) walletSwapParticipateHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	swap, err := scanSwap(req)
+	if err != nil {
+		WriteError(w, Error{"could not decode atomic swap: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	swap, err = api.wallet.ParticipateAtomicSwap(swap)
+	if err != nil {
+		WriteError(w, Error{"error after call to /wallet/swap/participate: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, swap)
+}
+
+// walletSwapPublishHandler handles API calls to /wallet/swap/publish.
+func (api *API) walletSwapPublishHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	swap, err := scanSwap(req)
+	if err != nil {
+		WriteError(w, Error{"could not decode atomic swap: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	if err := api.wallet.PublishAtomicSwap(swap); err != nil {
+		WriteError(w, Error{"error after call to /wallet/swap/publish: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// walletSwapClaimHandler handles API calls to /wallet/swap/claim.
+func (api *API) walletSwapClaimHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	swap, err := scanSwap(req)
+	if err != nil {
+		WriteError(w, Error{"could not decode atomic swap: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	secret, err := scanHash(req.URL.Query().Get("secret"))
+	if err != nil {
+		WriteError(w, Error{"could not read 'secret' from POST call to /wallet/swap/claim: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	txn, err := api.wallet.ClaimAtomicSwap(swap, secret)
+	if err != nil {
+		WriteError(w, Error{"error after call to /wallet/swap/claim: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, WalletSwapTransactionPOST{
+		TransactionID: txn.ID(),
+	})
+}
+
+// walletSwapRefundHandler handles API calls to /wallet/swap/refund.
+func (api *API) walletSwapRefundHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	swap, err := scanSwap(req)
+	if err != nil {
+		WriteError(w, Error{"could not decode atomic swap: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	txn, err := api.wallet.RefundAtomicSwap(swap)
+	if err != nil {
+		WriteError(w, Error{"error after call to /wallet/swap/refund: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, WalletSwapTransactionPOST{
+		TransactionID: txn.ID(),
+	})
+}
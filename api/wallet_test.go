@@ -1,10 +1,15 @@
 package api
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/NebulousLabs/Sia/build"
@@ -556,6 +561,66 @@ func TestWalletRelativePathErrorBackup(t *testing.T) {
 	}
 }
 
+// TestWalletEncryptedBackup checks that /wallet/backup encrypts the backup when
+// given a password, and that the backup can be decrypted with that password.
+func TestWalletEncryptedBackup(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	walletTestDir := build.TempDir("api", t.Name(), "backups")
+	if err := os.MkdirAll(walletTestDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	plainPath := filepath.Join(walletTestDir, "plain.backup")
+	encryptedPath := filepath.Join(walletTestDir, "encrypted.backup")
+	if err := st.stdGetAPI("/wallet/backup?destination=" + plainPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.stdGetAPI("/wallet/backup?destination=" + encryptedPath + "&encryptionpassword=foo"); err != nil {
+		t.Fatal(err)
+	}
+	plain, err := ioutil.ReadFile(plainPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := ioutil.ReadFile(encryptedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(encrypted, plain[:1024]) {
+		t.Fatal("backup was not encrypted")
+	}
+
+	// Decrypting with the right password should recover the database.
+	sr, err := crypto.TwofishKey(crypto.HashObject("foo")).NewStreamReader(bytes.NewReader(encrypted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := ioutil.ReadAll(sr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, plain) {
+		t.Fatal("decrypted backup does not match the unencrypted backup")
+	}
+
+	// Decrypting with the wrong password should fail.
+	sr, err = crypto.TwofishKey(crypto.HashObject("bar")).NewStreamReader(bytes.NewReader(encrypted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ioutil.ReadAll(sr); err == nil {
+		t.Fatal("backup was decrypted with the wrong password")
+	}
+}
+
 // Tests that the /wallet/033x call checks for relative paths.
 func TestWalletRelativePathError033x(t *testing.T) {
 	if testing.Short() {
@@ -692,3 +757,370 @@ func TestWalletRelativePathErrorSiag(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// TestWalletLockTimeout checks that the lock timeout can be set and extended
+// through the API, and that /wallet reports it.
+func TestWalletLockTimeout(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	// Extending should fail while there is no lock timeout.
+	extendValues := url.Values{}
+	extendValues.Set("duration", "600")
+	if err := st.stdPostAPI("/wallet/unlock/extend", extendValues); err == nil {
+		t.Fatal("extending the unlock time should fail without a lock timeout")
+	}
+
+	timeoutValues := url.Values{}
+	timeoutValues.Set("timeout", "900")
+	if err := st.stdPostAPI("/wallet/locktimeout", timeoutValues); err != nil {
+		t.Fatal(err)
+	}
+	var wg WalletGET
+	if err := st.getAPI("/wallet", &wg); err != nil {
+		t.Fatal(err)
+	}
+	if wg.LockTimeout != 900 || wg.UnlockRemaining == 0 || wg.UnlockRemaining > 900 {
+		t.Fatal("unexpected lock timeout or remaining unlock time:", wg.LockTimeout, wg.UnlockRemaining)
+	}
+
+	if err := st.stdPostAPI("/wallet/unlock/extend", extendValues); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.getAPI("/wallet", &wg); err != nil {
+		t.Fatal(err)
+	}
+	if wg.UnlockRemaining <= 900 {
+		t.Fatal("remaining unlock time was not extended:", wg.UnlockRemaining)
+	}
+}
+
+// TestWalletSpendable checks that /wallet/spendable reports the confirmed
+// balance at the current height, and rejects heights in the past.
+func TestWalletSpendable(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	var wg WalletGET
+	if err := st.getAPI("/wallet", &wg); err != nil {
+		t.Fatal(err)
+	}
+	var ws WalletSpendableGET
+	if err := st.getAPI("/wallet/spendable", &ws); err != nil {
+		t.Fatal(err)
+	}
+	if ws.Height != st.cs.Height() || !ws.Available.Equals(wg.ConfirmedSiacoinBalance) {
+		t.Fatal("unexpected spendable balance:", ws.Height, ws.Available, wg.ConfirmedSiacoinBalance)
+	}
+	if err := st.getAPI("/wallet/spendable?height=0", &ws); err == nil {
+		t.Fatal("expected an error when requesting a height in the past")
+	}
+}
+
+// TestWalletChangePassword checks that /wallet/changepassword re-encrypts the
+// wallet under the new password.
+func TestWalletChangePassword(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	// The server tester's wallet is encrypted with a random key, so switch to
+	// a password-derived key first.
+	oldPassword := "foo"
+	err = st.wallet.ChangeKey(st.walletKey, crypto.TwofishKey(crypto.HashObject(oldPassword)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changeValues := url.Values{}
+	changeValues.Set("encryptionpassword", "wrong")
+	changeValues.Set("newpassword", "bar")
+	if err := st.stdPostAPI("/wallet/changepassword", changeValues); err == nil || !strings.Contains(err.Error(), modules.ErrBadEncryptionKey.Error()) {
+		t.Fatal("expected ErrBadEncryptionKey, got", err)
+	}
+	changeValues.Set("encryptionpassword", oldPassword)
+	if err := st.stdPostAPI("/wallet/changepassword", changeValues); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the new password should unlock the wallet.
+	if err := st.stdPostAPI("/wallet/lock", nil); err != nil {
+		t.Fatal(err)
+	}
+	unlockValues := url.Values{}
+	unlockValues.Set("encryptionpassword", oldPassword)
+	if err := st.stdPostAPI("/wallet/unlock", unlockValues); err == nil {
+		t.Fatal("old password should not unlock the wallet")
+	}
+	unlockValues.Set("encryptionpassword", "bar")
+	if err := st.stdPostAPI("/wallet/unlock", unlockValues); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestWalletAddressUsage checks that /wallet/addresses/usage lists the wallet's
+// addresses in order and that their balances add up to the wallet balance.
+func TestWalletAddressUsage(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	var wug WalletAddressUsageGET
+	if err := st.getAPI("/wallet/addresses/usage", &wug); err != nil {
+		t.Fatal(err)
+	}
+	if len(wug.Addresses) == 0 {
+		t.Fatal("expected at least one address")
+	}
+	var balance types.Currency
+	for i, u := range wug.Addresses {
+		if u.Index != uint64(i) {
+			t.Fatal("addresses are not ordered by index")
+		}
+		balance = balance.Add(u.Balance)
+	}
+	var wg WalletGET
+	if err := st.getAPI("/wallet", &wg); err != nil {
+		t.Fatal(err)
+	}
+	if !balance.Equals(wg.ConfirmedSiacoinBalance) {
+		t.Fatal("address balances do not match the wallet balance:", balance, wg.ConfirmedSiacoinBalance)
+	}
+
+	// The endpoint should fail while the wallet is locked.
+	if err := st.wallet.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.getAPI("/wallet/addresses/usage", &wug); err == nil || !strings.Contains(err.Error(), modules.ErrLockedWallet.Error()) {
+		t.Fatal("expected ErrLockedWallet, got", err)
+	}
+}
+
+// TestWalletAddressAddr checks that /wallet/address/:addr reports the balance
+// of a wallet address and rejects addresses outside the wallet.
+func TestWalletAddressAddr(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	var wag WalletAddressGET
+	if err := st.getAPI("/wallet/address", &wag); err != nil {
+		t.Fatal(err)
+	}
+	sendValues := url.Values{}
+	sendValues.Set("amount", types.SiacoinPrecision.String())
+	sendValues.Set("destination", wag.Address.String())
+	if err := st.stdPostAPI("/wallet/siacoins", sendValues); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	var wga WalletAddressGETaddr
+	if err := st.getAPI("/wallet/address/"+wag.Address.String(), &wga); err != nil {
+		t.Fatal(err)
+	}
+	if wga.Address != wag.Address || !wga.ConfirmedSiacoinBalance.Equals(types.SiacoinPrecision) || len(wga.ConfirmedTransactions) != 1 {
+		t.Fatal("unexpected address history:", wga)
+	}
+
+	if err := st.getAPI("/wallet/address/"+types.UnlockHash{}.String(), &wga); err == nil {
+		t.Fatal("expected an error for an address outside the wallet")
+	}
+	if err := st.getAPI("/wallet/address/foo", &wga); err == nil {
+		t.Fatal("expected an error for a malformed address")
+	}
+}
+
+// TestWalletApprovals checks that sends above the approval threshold are
+// queued, and that the approval endpoints require the approval password.
+func TestWalletApprovals(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+	baseURL := "http://" + st.server.listener.Addr().String()
+
+	// Approvals should be disabled until an approval password is set.
+	policyValues := url.Values{}
+	policyValues.Set("threshold", "1000")
+	policyValues.Set("timeout", "3600")
+	if err := st.stdPostAPI("/wallet/approvals", policyValues); err == nil {
+		t.Fatal("approval policy was set without an approval password")
+	}
+	st.server.api.SetApprovalPassword("approver")
+	resp, err := HttpPOSTAuthenticated(baseURL+"/wallet/approvals", policyValues.Encode(), "wrong password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatal("approval policy was set with the wrong password:", resp.StatusCode)
+	}
+	resp, err = HttpPOSTAuthenticated(baseURL+"/wallet/approvals", policyValues.Encode(), "approver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if non2xx(resp.StatusCode) {
+		t.Fatal("could not set approval policy:", resp.StatusCode)
+	}
+
+	// A large send should be queued rather than sent.
+	sendValues := url.Values{}
+	sendValues.Set("amount", "1001")
+	sendValues.Set("destination", types.UnlockHash{}.String())
+	var wsp WalletSiacoinsPOST
+	if err := st.postAPI("/wallet/siacoins", sendValues, &wsp); err != nil {
+		t.Fatal(err)
+	}
+	if !wsp.PendingApproval || len(wsp.TransactionIDs) != 0 {
+		t.Fatal("large send was not queued:", wsp)
+	}
+	var wag WalletApprovalsGET
+	if err := st.getAPI("/wallet/approvals", &wag); err != nil {
+		t.Fatal(err)
+	}
+	if wag.Timeout != 3600 || !wag.Threshold.Equals(types.NewCurrency64(1000)) || len(wag.PendingSends) != 1 || wag.PendingSends[0].ID != wsp.PendingSend.ID {
+		t.Fatal("unexpected approvals:", wag)
+	}
+
+	// The send can only be approved with the approval password.
+	approveURL := baseURL + "/wallet/approvals/approve/" + wsp.PendingSend.ID.String()
+	if err := st.stdPostAPI("/wallet/approvals/approve/"+wsp.PendingSend.ID.String(), url.Values{}); err == nil {
+		t.Fatal("send was approved without the approval password")
+	}
+	resp, err = HttpPOSTAuthenticated(approveURL, "", "approver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if non2xx(resp.StatusCode) {
+		t.Fatal("could not approve send:", decodeError(resp))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&wsp); err != nil {
+		t.Fatal(err)
+	}
+	if len(wsp.TransactionIDs) == 0 {
+		t.Fatal("approved send did not produce any transactions")
+	}
+	if err := st.getAPI("/wallet/approvals", &wag); err != nil {
+		t.Fatal(err)
+	}
+	if len(wag.PendingSends) != 0 {
+		t.Fatal("approved send is still pending")
+	}
+}
+
+// TestWalletSwap checks that an atomic swap can be constructed, countersigned,
+// published, and claimed through the API.
+func TestWalletSwap(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.Close()
+
+	var wss WalletSwapSecretGET
+	if err := st.getAPI("/wallet/swap/secret", &wss); err != nil {
+		t.Fatal(err)
+	}
+	var wsk WalletSwapKeyGET
+	if err := st.getAPI("/wallet/swap/key", &wsk); err != nil {
+		t.Fatal(err)
+	}
+	values := url.Values{}
+	values.Set("value", types.SiacoinPrecision.Mul64(100).String())
+	values.Set("hashlock", wss.HashLock)
+	values.Set("claimkey", wsk.Key)
+	values.Set("timelock", fmt.Sprint(st.cs.Height()+10))
+	var swap modules.AtomicSwap
+	if err := st.postAPI("/wallet/swap/construct", values, &swap); err != nil {
+		t.Fatal(err)
+	}
+
+	// postSwap posts the swap to the provided call, decoding the response
+	// into obj if it is not nil.
+	postSwap := func(call string, obj interface{}) error {
+		body, err := json.Marshal(swap)
+		if err != nil {
+			return err
+		}
+		resp, err := HttpPOST("http://"+st.server.listener.Addr().String()+call, string(body))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if non2xx(resp.StatusCode) {
+			return decodeError(resp)
+		}
+		if obj == nil {
+			return nil
+		}
+		return json.NewDecoder(resp.Body).Decode(obj)
+	}
+	if err := postSwap("/wallet/swap/audit", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := postSwap("/wallet/swap/participate", &swap); err != nil {
+		t.Fatal(err)
+	}
+	if err := postSwap("/wallet/swap/publish", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	var wstp WalletSwapTransactionPOST
+	if err := postSwap("/wallet/swap/claim?secret="+wss.Secret.String(), &wstp); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := st.wallet.Transaction(wstp.TransactionID); !ok {
+		t.Fatal("claim transaction was not confirmed")
+	}
+}
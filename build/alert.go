@@ -0,0 +1,95 @@
+package build
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Alert severities.
+const (
+	AlertSeverityCritical = "critical"
+	AlertSeverityPanic    = "panic"
+	AlertSeveritySevere   = "severe"
+)
+
+// maxAlerts is the number of recent alerts that are kept in memory. Older
+// alerts are discarded once the limit is reached.
+const maxAlerts = 100
+
+// An Alert describes a call to Critical or Severe, or a panic that was
+// recovered and reported with RecordPanic. Stack holds the call stack of the
+// goroutine that raised the alert.
+type Alert struct {
+	Severity string    `json:"severity"`
+	Message  string    `json:"message"`
+	Stack    string    `json:"stack"`
+	Time     time.Time `json:"time"`
+}
+
+var alerts struct {
+	recent   []Alert
+	handlers []func(Alert)
+	mu       sync.Mutex
+}
+
+// AddAlertHandler registers a function that is called every time an alert is
+// raised. Handlers are called synchronously by the goroutine that raised the
+// alert, before a panic in DEBUG mode, so they should return quickly and must
+// not call Critical or Severe themselves.
+func AddAlertHandler(fn func(Alert)) {
+	alerts.mu.Lock()
+	alerts.handlers = append(alerts.handlers, fn)
+	alerts.mu.Unlock()
+}
+
+// Alerts returns the most recent alerts, oldest first.
+func Alerts() []Alert {
+	alerts.mu.Lock()
+	defer alerts.mu.Unlock()
+	return append([]Alert(nil), alerts.recent...)
+}
+
+// RecordPanic raises an alert for a panic value that was recovered. It should
+// be called from the deferred function that called recover, so that the stack
+// of the panicking goroutine is captured.
+func RecordPanic(r interface{}) {
+	s := fmt.Sprint("Recovered panic: ", r)
+	recordAlert(AlertSeverityPanic, s, debug.Stack())
+}
+
+// RecordStall raises a critical alert for a module that has stopped making
+// progress. Unlike Critical, RecordStall never panics, because a stall is
+// usually caused by the environment rather than by developer error. The stacks
+// of all goroutines are captured, since the goroutine that detected the stall
+// is rarely the one that is stuck.
+func RecordStall(v ...interface{}) {
+	s := "Stalled module: " + fmt.Sprintln(v...)
+	stack := make([]byte, 1<<20)
+	stack = stack[:runtime.Stack(stack, true)]
+	recordAlert(AlertSeverityCritical, s, stack)
+}
+
+// recordAlert adds an alert to the list of recent alerts and passes it to each
+// registered handler.
+func recordAlert(severity, message string, stack []byte) {
+	a := Alert{
+		Severity: severity,
+		Message:  message,
+		Stack:    string(stack),
+		Time:     time.Now(),
+	}
+	alerts.mu.Lock()
+	alerts.recent = append(alerts.recent, a)
+	if len(alerts.recent) > maxAlerts {
+		alerts.recent = append([]Alert(nil), alerts.recent[len(alerts.recent)-maxAlerts:]...)
+	}
+	handlers := alerts.handlers
+	alerts.mu.Unlock()
+
+	for _, fn := range handlers {
+		fn(a)
+	}
+}
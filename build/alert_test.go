@@ -0,0 +1,74 @@
+package build
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestCriticalAlert checks that Critical and RecordPanic raise alerts that
+// include the stack of the calling goroutine, and that alert handlers are
+// called.
+func TestCriticalAlert(t *testing.T) {
+	var handled []Alert
+	AddAlertHandler(func(a Alert) {
+		handled = append(handled, a)
+	})
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil && DEBUG {
+				t.Error("Critical did not panic")
+			}
+		}()
+		Critical("alert test killstring")
+	}()
+	func() {
+		defer func() {
+			RecordPanic(recover())
+		}()
+		panic("alert test panic")
+	}()
+
+	recent := Alerts()
+	if len(recent) < 2 || len(handled) != 2 {
+		t.Fatal("expected two alerts, got", len(recent), len(handled))
+	}
+	crit, p := recent[len(recent)-2], recent[len(recent)-1]
+	if crit.Severity != AlertSeverityCritical || !strings.Contains(crit.Message, "alert test killstring") {
+		t.Error("unexpected critical alert:", crit)
+	}
+	if !strings.Contains(crit.Stack, "TestCriticalAlert") {
+		t.Error("critical alert is missing the stack of the caller")
+	}
+	if p.Severity != AlertSeverityPanic || !strings.Contains(p.Message, "alert test panic") {
+		t.Error("unexpected panic alert:", p)
+	}
+	if !strings.Contains(p.Stack, "TestCriticalAlert") {
+		t.Error("panic alert is missing the stack of the panicking goroutine")
+	}
+	if handled[0].Message != crit.Message || handled[1].Message != p.Message {
+		t.Error("handlers were not called with the raised alerts")
+	}
+
+	// Stall alerts are critical, but do not panic, and include the stacks of
+	// other goroutines.
+	block := make(chan struct{})
+	go func() { <-block }()
+	RecordStall("alert test stall")
+	close(block)
+	stall := Alerts()[len(Alerts())-1]
+	if stall.Severity != AlertSeverityCritical || !strings.Contains(stall.Message, "alert test stall") {
+		t.Error("unexpected stall alert:", stall)
+	}
+	if !strings.Contains(stall.Stack, "TestCriticalAlert.func") {
+		t.Error("stall alert is missing the stacks of other goroutines")
+	}
+
+	// Only the most recent alerts should be kept.
+	for i := 0; i < maxAlerts+10; i++ {
+		recordAlert(AlertSeveritySevere, "filler", nil)
+	}
+	if len(Alerts()) != maxAlerts {
+		t.Error("alert list was not bounded:", len(Alerts()))
+	}
+}
@@ -9,11 +9,14 @@ import (
 // Critical should be called if a sanity check has failed, indicating developer
 // error. Critical is called with an extended message guiding the user to the
 // issue tracker on Github. If the program does not panic, the call stack for
-// the running goroutine is printed to help determine the error.
+// the running goroutine is printed to help determine the error. An alert is
+// raised in either case.
 func Critical(v ...interface{}) {
 	s := "Critical error: " + fmt.Sprintln(v...) + "Please submit a bug report here: https://github.com/NebulousLabs/Sia/issues\n"
-	debug.PrintStack()
+	stack := debug.Stack()
+	os.Stderr.Write(stack)
 	os.Stderr.WriteString(s)
+	recordAlert(AlertSeverityCritical, s, stack)
 	if DEBUG {
 		panic(s)
 	}
@@ -23,11 +26,13 @@ func Critical(v ...interface{}) {
 // be called as well. Severe should be called in situations which indicate
 // significant problems for the user (such as disk failure or random number
 // generation failure), but where crashing is not strictly required to preserve
-// integrity.
+// integrity. An alert is raised in either case.
 func Severe(v ...interface{}) {
 	s := "Severe error: " + fmt.Sprintln(v...)
-	debug.PrintStack()
+	stack := debug.Stack()
+	os.Stderr.Write(stack)
 	os.Stderr.WriteString(s)
+	recordAlert(AlertSeveritySevere, s, stack)
 	if DEBUG {
 		panic(s)
 	}
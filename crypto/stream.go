@@ -0,0 +1,242 @@
+package crypto
+
+// stream.go contains functions for encrypting and decrypting streams of
+// arbitrary length without holding the whole plaintext in memory.
+//
+// A stream begins with a random nonce prefix, followed by a series of chunks.
+// Each chunk is the little-endian uint32 length of its ciphertext, followed by
+// the ciphertext. Chunks are sealed with Twofish-GCM using a nonce made of the
+// prefix and the index of the chunk, which prevents chunks from being
+// reordered. The final chunk is sealed with different additional data than
+// the others, which prevents the stream from being truncated.
+//
+// Encrypted wallet backups are written as streams. Files uploaded by the
+// renter from a reader are not: their pieces are encrypted one at a time like
+// those of any other upload, so that hosts store them in the same format.
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/NebulousLabs/fastrand"
+)
+
+const (
+	// StreamChunkSize is the maximum number of bytes of plaintext in each
+	// chunk of an encrypted stream.
+	StreamChunkSize = 1 << 16
+
+	// streamPrefixSize is the number of random bytes at the start of each
+	// stream. The remaining 4 bytes of the nonce hold the chunk index.
+	streamPrefixSize = 8
+)
+
+var (
+	// ErrStreamClosed is returned when writing to a stream writer that has
+	// already been closed.
+	ErrStreamClosed = errors.New("encrypted stream has been closed")
+
+	// ErrStreamChunkSize is returned when a chunk of an encrypted stream has an
+	// invalid length.
+	ErrStreamChunkSize = errors.New("encrypted stream contains a chunk with an invalid length")
+
+	// ErrStreamTrailingData is returned when an encrypted stream contains
+	// data after its final chunk.
+	ErrStreamTrailingData = errors.New("encrypted stream contains data after its final chunk")
+
+	// ErrStreamTooLong is returned when a stream has more chunks than can be
+	// given unique nonces.
+	ErrStreamTooLong = errors.New("encrypted stream has too many chunks")
+
+	// ErrStreamTruncated is returned when an encrypted stream ends before its
+	// final chunk.
+	ErrStreamTruncated = errors.New("encrypted stream was truncated")
+
+	// additional data of the final chunk and all other chunks.
+	streamFinalAD    = []byte{1}
+	streamNonFinalAD = []byte{0}
+)
+
+type (
+	// streamWriter encrypts data written to it in chunks of StreamChunkSize.
+	streamWriter struct {
+		aead   cipher.AEAD
+		nonce  []byte
+		index  uint32
+		buf    []byte
+		w      io.Writer
+		closed bool
+		err    error
+	}
+
+	// streamReader decrypts a stream created by a streamWriter.
+	streamReader struct {
+		aead  cipher.AEAD
+		nonce []byte
+		index uint32
+		pt    []byte
+		buf   []byte
+		r     io.Reader
+		final bool
+		err   error
+	}
+)
+
+// setStreamNonce sets the chunk index of a stream nonce.
+func setStreamNonce(nonce []byte, index uint32) {
+	binary.BigEndian.PutUint32(nonce[streamPrefixSize:], index)
+}
+
+// sealChunk encrypts and writes the buffered plaintext as the next chunk.
+func (sw *streamWriter) sealChunk(final bool) error {
+	if sw.index == ^uint32(0) {
+		return ErrStreamTooLong
+	}
+	ad := streamNonFinalAD
+	if final {
+		ad = streamFinalAD
+	}
+	setStreamNonce(sw.nonce, sw.index)
+	sw.index++
+	chunk := make([]byte, 4, 4+len(sw.buf)+sw.aead.Overhead())
+	chunk = sw.aead.Seal(chunk, sw.nonce, sw.buf, ad)
+	binary.LittleEndian.PutUint32(chunk[:4], uint32(len(chunk)-4))
+	sw.buf = sw.buf[:0]
+	_, err := sw.w.Write(chunk)
+	return err
+}
+
+// Write implements io.Writer. Plaintext is buffered until a full chunk is
+// available.
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	if sw.closed {
+		return 0, ErrStreamClosed
+	}
+	if sw.err != nil {
+		return 0, sw.err
+	}
+	n := 0
+	for len(p) > 0 {
+		if len(sw.buf) == StreamChunkSize {
+			if sw.err = sw.sealChunk(false); sw.err != nil {
+				return n, sw.err
+			}
+		}
+		c := copy(sw.buf[len(sw.buf):StreamChunkSize], p)
+		sw.buf = sw.buf[:len(sw.buf)+c]
+		p = p[c:]
+		n += c
+	}
+	return n, nil
+}
+
+// Close writes the final chunk of the stream. It does not close the
+// underlying writer.
+func (sw *streamWriter) Close() error {
+	if sw.closed {
+		return ErrStreamClosed
+	}
+	sw.closed = true
+	if sw.err != nil {
+		return sw.err
+	}
+	return sw.sealChunk(true)
+}
+
+// readChunk reads and decrypts the next chunk of the stream.
+func (sr *streamReader) readChunk() error {
+	if sr.final {
+		// There must be nothing after the final chunk.
+		var b [1]byte
+		if n, _ := io.ReadFull(sr.r, b[:]); n != 0 {
+			return ErrStreamTrailingData
+		}
+		return io.EOF
+	}
+
+	var lenBuf [4]byte
+	if _, err := io.ReadFull(sr.r, lenBuf[:]); err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrStreamTruncated
+	} else if err != nil {
+		return err
+	}
+	n := binary.LittleEndian.Uint32(lenBuf[:])
+	if n < uint32(sr.aead.Overhead()) || n > uint32(StreamChunkSize+sr.aead.Overhead()) {
+		return ErrStreamChunkSize
+	}
+	ct := make([]byte, n)
+	if _, err := io.ReadFull(sr.r, ct); err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrStreamTruncated
+	} else if err != nil {
+		return err
+	}
+
+	setStreamNonce(sr.nonce, sr.index)
+	sr.index++
+	// Try the non-final additional data first, as most chunks are not final.
+	pt, err := sr.aead.Open(sr.pt[:0], sr.nonce, ct, streamNonFinalAD)
+	if err != nil {
+		pt, err = sr.aead.Open(sr.pt[:0], sr.nonce, ct, streamFinalAD)
+		if err != nil {
+			return err
+		}
+		sr.final = true
+	}
+	sr.pt = pt
+	sr.buf = pt
+	return nil
+}
+
+// Read implements io.Reader.
+func (sr *streamReader) Read(p []byte) (int, error) {
+	for len(sr.buf) == 0 {
+		if sr.err != nil {
+			return 0, sr.err
+		}
+		sr.err = sr.readChunk()
+	}
+	n := copy(p, sr.buf)
+	sr.buf = sr.buf[n:]
+	return n, nil
+}
+
+// NewStreamWriter returns a writer that encrypts and authenticates everything
+// written to it, in chunks of StreamChunkSize. Close must be called to write
+// the final chunk; a stream that was not closed cannot be decrypted.
+func (key TwofishKey) NewStreamWriter(w io.Writer) (io.WriteCloser, error) {
+	// NOTE: NewGCM only returns an error if twofishCipher.BlockSize != 16.
+	aead, _ := cipher.NewGCM(key.NewCipher())
+	nonce := make([]byte, aead.NonceSize())
+	fastrand.Read(nonce[:streamPrefixSize])
+	if _, err := w.Write(nonce[:streamPrefixSize]); err != nil {
+		return nil, err
+	}
+	return &streamWriter{
+		aead:  aead,
+		nonce: nonce,
+		buf:   make([]byte, 0, StreamChunkSize),
+		w:     w,
+	}, nil
+}
+
+// NewStreamReader returns a reader that decrypts a stream created by
+// NewStreamWriter. An error is returned by Read if the stream has been
+// modified, reordered, or truncated.
+func (key TwofishKey) NewStreamReader(r io.Reader) (io.Reader, error) {
+	// NOTE: NewGCM only returns an error if twofishCipher.BlockSize != 16.
+	aead, _ := cipher.NewGCM(key.NewCipher())
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(r, nonce[:streamPrefixSize]); err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil, ErrStreamTruncated
+	} else if err != nil {
+		return nil, err
+	}
+	return &streamReader{
+		aead:  aead,
+		nonce: nonce,
+		pt:    make([]byte, 0, StreamChunkSize),
+		r:     r,
+	}, nil
+}
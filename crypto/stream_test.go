@@ -0,0 +1,142 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"testing"
+
+	"github.com/NebulousLabs/fastrand"
+)
+
+// encryptStream encrypts plaintext using a stream writer, writing it in
+// pieces of the provided size.
+func encryptStream(t *testing.T, key TwofishKey, plaintext []byte, writeSize int) []byte {
+	var buf bytes.Buffer
+	sw, err := key.NewStreamWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p := plaintext; len(p) > 0; {
+		n := writeSize
+		if n > len(p) {
+			n = len(p)
+		}
+		if _, err := sw.Write(p[:n]); err != nil {
+			t.Fatal(err)
+		}
+		p = p[n:]
+	}
+	if err := sw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// decryptStream decrypts a stream created by encryptStream.
+func decryptStream(key TwofishKey, ciphertext []byte) ([]byte, error) {
+	sr, err := key.NewStreamReader(bytes.NewReader(ciphertext))
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(sr)
+}
+
+// TestStreamEncryption checks that streams of various lengths can be
+// encrypted and decrypted.
+func TestStreamEncryption(t *testing.T) {
+	key := GenerateTwofishKey()
+	sizes := []int{0, 1, 600, StreamChunkSize - 1, StreamChunkSize, StreamChunkSize + 1, 3*StreamChunkSize + 17}
+	for _, size := range sizes {
+		plaintext := fastrand.Bytes(size)
+		for _, writeSize := range []int{1000, StreamChunkSize, 5 * StreamChunkSize} {
+			ciphertext := encryptStream(t, key, plaintext, writeSize)
+			chunks := (size + StreamChunkSize - 1) / StreamChunkSize
+			if chunks == 0 {
+				chunks = 1
+			}
+			if len(ciphertext) != streamPrefixSize+size+chunks*(4+TwofishOverhead-12) {
+				t.Errorf("unexpected ciphertext size for %v bytes: %v", size, len(ciphertext))
+			}
+			decrypted, err := decryptStream(key, ciphertext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decrypted, plaintext) {
+				t.Fatalf("decrypted stream of %v bytes does not match the plaintext", size)
+			}
+		}
+	}
+
+	// The same plaintext should not produce the same ciphertext twice.
+	plaintext := fastrand.Bytes(100)
+	if bytes.Equal(encryptStream(t, key, plaintext, 100), encryptStream(t, key, plaintext, 100)) {
+		t.Error("stream nonce was reused")
+	}
+
+	// Writing after Close should fail.
+	sw, err := key.NewStreamWriter(ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sw.Close()
+	if _, err := sw.Write([]byte{1}); err != ErrStreamClosed {
+		t.Error("expected ErrStreamClosed, got", err)
+	}
+}
+
+// TestStreamTampering checks that modified, reordered, truncated, and
+// extended streams are rejected.
+func TestStreamTampering(t *testing.T) {
+	key := GenerateTwofishKey()
+	plaintext := fastrand.Bytes(2*StreamChunkSize + 100)
+	ciphertext := encryptStream(t, key, plaintext, len(plaintext))
+	chunkLen := 4 + StreamChunkSize + TwofishOverhead - 12
+	first := streamPrefixSize
+	second := first + chunkLen
+
+	// Wrong key.
+	if _, err := decryptStream(GenerateTwofishKey(), ciphertext); err == nil {
+		t.Error("stream was decrypted with the wrong key")
+	}
+
+	// Modified ciphertext.
+	modified := append([]byte(nil), ciphertext...)
+	modified[second+10]++
+	if _, err := decryptStream(key, modified); err == nil {
+		t.Error("modified stream was decrypted")
+	}
+
+	// Reordered chunks.
+	reordered := append([]byte(nil), ciphertext[:first]...)
+	reordered = append(reordered, ciphertext[second:second+chunkLen]...)
+	reordered = append(reordered, ciphertext[first:second]...)
+	reordered = append(reordered, ciphertext[second+chunkLen:]...)
+	if _, err := decryptStream(key, reordered); err == nil {
+		t.Error("reordered stream was decrypted")
+	}
+
+	// Truncated at a chunk boundary, in the middle of a chunk, and within the
+	// prefix.
+	if _, err := decryptStream(key, ciphertext[:second+chunkLen]); err != ErrStreamTruncated {
+		t.Error("expected ErrStreamTruncated, got", err)
+	}
+	if _, err := decryptStream(key, ciphertext[:second+10]); err != ErrStreamTruncated {
+		t.Error("expected ErrStreamTruncated, got", err)
+	}
+	if _, err := decryptStream(key, ciphertext[:3]); err != ErrStreamTruncated {
+		t.Error("expected ErrStreamTruncated, got", err)
+	}
+
+	// Data after the final chunk.
+	if _, err := decryptStream(key, append(append([]byte(nil), ciphertext...), 0)); err != ErrStreamTrailingData {
+		t.Error("expected ErrStreamTrailingData, got", err)
+	}
+
+	// Oversized chunk length.
+	oversized := append([]byte(nil), ciphertext...)
+	binary.LittleEndian.PutUint32(oversized[first:], StreamChunkSize+1e3)
+	if _, err := decryptStream(key, oversized); err != ErrStreamChunkSize {
+		t.Error("expected ErrStreamChunkSize, got", err)
+	}
+}
@@ -175,8 +175,9 @@ func WriteFile(filename string, v interface{}) error {
 
 // A Decoder reads and decodes values from an input stream.
 type Decoder struct {
-	r io.Reader
-	n int
+	r   io.Reader
+	n   int
+	buf [8]byte
 }
 
 // Read implements the io.Reader interface. It also keeps track of the total
@@ -226,9 +227,16 @@ func (d *Decoder) DecodeAll(vs ...interface{}) error {
 	return nil
 }
 
-// readN reads n bytes and panics if the read fails.
+// readN reads n bytes and panics if the read fails. Reads of up to 8 bytes
+// use the decoder's scratch buffer instead of allocating, so the returned
+// slice is only valid until the next call to readN.
 func (d *Decoder) readN(n int) []byte {
-	b := make([]byte, n)
+	var b []byte
+	if n <= len(d.buf) {
+		b = d.buf[:n]
+	} else {
+		b = make([]byte, n)
+	}
 	_, err := io.ReadFull(d, b)
 	if err != nil {
 		panic(err)
@@ -326,7 +334,27 @@ func (d *Decoder) decode(val reflect.Value) {
 
 // NewDecoder returns a new decoder that reads from r.
 func NewDecoder(r io.Reader) *Decoder {
-	return &Decoder{r, 0}
+	return &Decoder{r: r}
+}
+
+// A ReusableDecoder decodes values from byte slices in the same way as
+// Unmarshal, but reuses its reader and decoder between calls. Decoding many
+// values in a loop, such as when walking a database bucket, then does not
+// allocate a new reader and decoder for each value. A ReusableDecoder is not
+// safe for concurrent use.
+type ReusableDecoder struct {
+	r bytes.Reader
+	d Decoder
+}
+
+// Unmarshal decodes the encoded value b and stores it in v, which must be a
+// pointer. The decoder does not keep a reference to b once it returns.
+func (rd *ReusableDecoder) Unmarshal(b []byte, v interface{}) error {
+	rd.r.Reset(b)
+	rd.d.r = &rd.r
+	err := rd.d.Decode(v)
+	rd.r.Reset(nil)
+	return err
 }
 
 // Unmarshal decodes the encoded value b and stores it in v, which must be a
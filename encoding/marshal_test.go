@@ -207,6 +207,37 @@ func TestMarshalUnmarshal(t *testing.T) {
 	}
 }
 
+// TestReusableDecoder tests that a ReusableDecoder decodes the same values as
+// Unmarshal, including after a failed decode.
+func TestReusableDecoder(t *testing.T) {
+	var emptyStructs = []interface{}{&test0{}, &test1{}, &test2{}, &test3{}, &test4{}, &test5{}, &test6{}}
+	var expStructs = []interface{}{&test0{}, &test1{}, &test2{}, &test3{}, &test4{}, &test5{}, &test6{}}
+	var rd ReusableDecoder
+	for i := range testStructs {
+		b := Marshal(testStructs[i])
+		err := rd.Unmarshal(b, emptyStructs[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		Unmarshal(b, expStructs[i])
+		if !reflect.DeepEqual(emptyStructs[i], expStructs[i]) {
+			t.Errorf("testStructs[%d] decoded incorrectly: %v", i, emptyStructs[i])
+		}
+		if err := rd.Unmarshal([]byte{3}, new(bool)); err == nil {
+			t.Fatal("expected bool error, got nil")
+		}
+	}
+
+	// Decoding 8-byte values reuses the scratch buffer, so consecutive
+	// values must not overwrite each other.
+	var pair struct{ A, B uint64 }
+	if err := rd.Unmarshal(MarshalAll(uint64(1), uint64(2)), &pair); err != nil {
+		t.Fatal(err)
+	} else if pair.A != 1 || pair.B != 2 {
+		t.Error("wrong values:", pair)
+	}
+}
+
 // TestEncodeDecode tests the Encode and Decode functions, which are inverses
 // of each other.
 func TestEncodeDecode(t *testing.T) {
@@ -402,6 +433,24 @@ func BenchmarkDecode(b *testing.B) {
 	b.SetBytes(numBytes)
 }
 
+// BenchmarkDecodeReusable is BenchmarkDecode with a ReusableDecoder.
+func BenchmarkDecodeReusable(b *testing.B) {
+	var emptyStructs = []interface{}{&test0{}, &test1{}, &test2{}, &test3{}, &test4{}, &test5{}, &test6{}}
+	var numBytes int64
+	var rd ReusableDecoder
+	for i := 0; i < b.N; i++ {
+		numBytes = 0
+		for i := range testEncodings {
+			err := rd.Unmarshal(testEncodings[i], emptyStructs[i])
+			if err != nil {
+				b.Fatal(err)
+			}
+			numBytes += int64(len(testEncodings[i]))
+		}
+	}
+	b.SetBytes(numBytes)
+}
+
 // i5-4670K, 2059112: 44 MB/s
 func BenchmarkMarshalAll(b *testing.B) {
 	for i := 0; i < b.N; i++ {
@@ -0,0 +1,329 @@
+package modules
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/NebulousLabs/Sia/persist"
+)
+
+// When one siad runs both a renter and a host, the two compete for the same
+// uplink and downlink. Renter repairs in particular can saturate a home
+// connection and starve the renters that the host is serving. The bandwidth
+// scheduler gives the renter and the host a single budget: every connection
+// the host accepts from a renter, and every connection the renter makes to a
+// host for an upload or download, draws from a shared token bucket for each
+// direction. When the budget is exhausted, transfers are admitted in order of
+// priority: the host serving renters first, then renter downloads, then
+// renter uploads and repairs. Lower priority transfers only proceed when no
+// higher priority transfer is waiting. A limit of zero leaves the direction
+// unlimited, which is the default. The limits are saved in the Sia
+// directory.
+
+// BandwidthClass identifies the role that a connection is transferring data
+// for. Classes are listed in order of decreasing priority.
+type BandwidthClass int
+
+// Bandwidth classes.
+const (
+	BandwidthClassHost BandwidthClass = iota
+	BandwidthClassRenterDownload
+	BandwidthClassRenterUpload
+	numBandwidthClasses
+)
+
+const (
+	// bandwidthFile is the name of the file that the bandwidth limits are
+	// saved in, relative to the Sia directory.
+	bandwidthFile = "bandwidth.json"
+
+	// bandwidthChunk is the largest number of bytes that a scheduled
+	// connection reads or writes at once, so that a large transfer does not
+	// hold up higher priority transfers for long.
+	bandwidthChunk = 1 << 14
+
+	// bandwidthPollInterval is how often a transfer that is waiting behind
+	// higher priority transfers checks whether it may proceed.
+	bandwidthPollInterval = 10 * time.Millisecond
+)
+
+var (
+	// ErrNegativeBandwidthLimit is returned when setting a negative
+	// bandwidth limit.
+	ErrNegativeBandwidthLimit = errors.New("bandwidth limits cannot be negative")
+
+	// errScheduledConnClosed is returned when a scheduled connection is
+	// closed while waiting for bandwidth.
+	errScheduledConnClosed = errors.New("connection closed while waiting for bandwidth")
+
+	// bandwidthMetadata contains the header and version strings that identify
+	// the bandwidth limits file.
+	bandwidthMetadata = persist.Metadata{
+		Header:  "Sia Bandwidth Limits",
+		Version: "1.3.0",
+	}
+
+	// bandwidthClassNames are the names of the bandwidth classes, as reported
+	// by the API.
+	bandwidthClassNames = [numBandwidthClasses]string{
+		BandwidthClassHost:           "host",
+		BandwidthClassRenterDownload: "renterdownload",
+		BandwidthClassRenterUpload:   "renterupload",
+	}
+
+	// scheduler is the bandwidth scheduler shared by the renter and the
+	// host.
+	scheduler bandwidthScheduler
+)
+
+type (
+	// BandwidthLimits are the caps on the rate at which the renter and the
+	// host together download and upload, in bytes per second. A cap of zero
+	// means that the direction is not limited.
+	BandwidthLimits struct {
+		MaxDownloadSpeed int64 `json:"maxdownloadspeed"`
+		MaxUploadSpeed   int64 `json:"maxuploadspeed"`
+	}
+
+	// BandwidthClassStats reports the traffic of a bandwidth class since siad
+	// started.
+	BandwidthClassStats struct {
+		Class      string `json:"class"`
+		Priority   int    `json:"priority"` // 0 is the highest priority.
+		Downloaded uint64 `json:"downloaded"`
+		Uploaded   uint64 `json:"uploaded"`
+		Waiting    int    `json:"waiting"` // Transfers currently waiting for bandwidth.
+	}
+)
+
+// String implements the fmt.Stringer interface.
+func (bc BandwidthClass) String() string {
+	if bc < 0 || bc >= numBandwidthClasses {
+		return "unknown"
+	}
+	return bandwidthClassNames[bc]
+}
+
+// priorityBucket is a token bucket that admits waiting transfers in order of
+// priority. A rate of zero means that transfers are not limited.
+type priorityBucket struct {
+	rate        int64
+	tokens      float64
+	last        time.Time
+	waiting     [numBandwidthClasses]int
+	transferred [numBandwidthClasses]uint64
+}
+
+// refill adds the tokens that accumulated since the bucket was last used. The
+// bucket holds at most one second worth of tokens.
+func (pb *priorityBucket) refill(now time.Time) {
+	pb.tokens += now.Sub(pb.last).Seconds() * float64(pb.rate)
+	if pb.tokens > float64(pb.rate) {
+		pb.tokens = float64(pb.rate)
+	}
+	pb.last = now
+}
+
+// higherWaiting returns true if a transfer with a higher priority than class
+// is waiting.
+func (pb *priorityBucket) higherWaiting(class BandwidthClass) bool {
+	for c := BandwidthClass(0); c < class; c++ {
+		if pb.waiting[c] > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// bandwidthScheduler holds the shared download and upload buckets.
+type bandwidthScheduler struct {
+	limits   BandwidthLimits
+	download priorityBucket
+	upload   priorityBucket
+	path     string
+	mu       sync.Mutex
+}
+
+// wait takes n tokens from pb on behalf of a transfer of the provided class,
+// blocking until no higher priority transfer is waiting and the bucket has
+// tokens. The bucket may go into debt, which later transfers pay off. false is
+// returned if the wait was interrupted by closed.
+func (bs *bandwidthScheduler) wait(pb *priorityBucket, class BandwidthClass, n int, closed <-chan struct{}) bool {
+	bs.mu.Lock()
+	pb.waiting[class]++
+	for {
+		if pb.rate <= 0 {
+			break
+		}
+		pb.refill(time.Now())
+		higher := pb.higherWaiting(class)
+		if !higher && pb.tokens > 0 {
+			pb.tokens -= float64(n)
+			break
+		}
+		delay := bandwidthPollInterval
+		if !higher {
+			delay = time.Duration(-pb.tokens/float64(pb.rate)*float64(time.Second)) + time.Millisecond
+		}
+		bs.mu.Unlock()
+		select {
+		case <-time.After(delay):
+		case <-closed:
+			bs.mu.Lock()
+			pb.waiting[class]--
+			bs.mu.Unlock()
+			return false
+		}
+		bs.mu.Lock()
+	}
+	pb.waiting[class]--
+	pb.transferred[class] += uint64(n)
+	bs.mu.Unlock()
+	return true
+}
+
+// setLimits changes the rate of each bucket.
+func (bs *bandwidthScheduler) setLimits(bl BandwidthLimits) {
+	now := time.Now()
+	bs.limits = bl
+	for _, b := range []struct {
+		pb   *priorityBucket
+		rate int64
+	}{
+		{&bs.download, bl.MaxDownloadSpeed},
+		{&bs.upload, bl.MaxUploadSpeed},
+	} {
+		b.pb.rate = b.rate
+		b.pb.last = now
+		if b.pb.tokens > float64(b.rate) {
+			b.pb.tokens = float64(b.rate)
+		}
+	}
+}
+
+// scheduledConn is a net.Conn whose reads and writes draw from the shared
+// bandwidth budget.
+type scheduledConn struct {
+	net.Conn
+	class     BandwidthClass
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// Read implements the io.Reader interface.
+func (sc *scheduledConn) Read(b []byte) (int, error) {
+	if len(b) > bandwidthChunk {
+		b = b[:bandwidthChunk]
+	}
+	n, err := sc.Conn.Read(b)
+	if n > 0 && !scheduler.wait(&scheduler.download, sc.class, n, sc.closed) && err == nil {
+		err = errScheduledConnClosed
+	}
+	return n, err
+}
+
+// Write implements the io.Writer interface.
+func (sc *scheduledConn) Write(b []byte) (int, error) {
+	var written int
+	for len(b) > 0 {
+		chunk := b
+		if len(chunk) > bandwidthChunk {
+			chunk = chunk[:bandwidthChunk]
+		}
+		if !scheduler.wait(&scheduler.upload, sc.class, len(chunk), sc.closed) {
+			return written, errScheduledConnClosed
+		}
+		n, err := sc.Conn.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		b = b[n:]
+	}
+	return written, nil
+}
+
+// Close implements the io.Closer interface. Transfers waiting for bandwidth
+// are interrupted.
+func (sc *scheduledConn) Close() error {
+	sc.closeOnce.Do(func() { close(sc.closed) })
+	return sc.Conn.Close()
+}
+
+// ScheduleConn wraps a connection so that its traffic draws from the
+// bandwidth budget shared by the renter and the host, at the priority of the
+// provided class.
+func ScheduleConn(conn net.Conn, class BandwidthClass) net.Conn {
+	return &scheduledConn{
+		Conn:   conn,
+		class:  class,
+		closed: make(chan struct{}),
+	}
+}
+
+// SharedBandwidthLimits returns the caps on the bandwidth shared by the
+// renter and the host.
+func SharedBandwidthLimits() BandwidthLimits {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+	return scheduler.limits
+}
+
+// SetSharedBandwidthLimits changes the caps on the bandwidth shared by the renter
+// and the host. The new caps take effect immediately, including on existing
+// connections. They are saved if the limits were loaded with
+// LoadSharedBandwidthLimits.
+func SetSharedBandwidthLimits(bl BandwidthLimits) error {
+	if bl.MaxDownloadSpeed < 0 || bl.MaxUploadSpeed < 0 {
+		return ErrNegativeBandwidthLimit
+	}
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+	scheduler.setLimits(bl)
+	if scheduler.path == "" {
+		return nil
+	}
+	return persist.SaveFileSync(bandwidthMetadata, bl, scheduler.path)
+}
+
+// SharedBandwidthStats returns the traffic of each bandwidth class since siad
+// started, in order of priority.
+func SharedBandwidthStats() []BandwidthClassStats {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+	stats := make([]BandwidthClassStats, numBandwidthClasses)
+	for c := BandwidthClass(0); c < numBandwidthClasses; c++ {
+		stats[c] = BandwidthClassStats{
+			Class:      c.String(),
+			Priority:   int(c),
+			Downloaded: scheduler.download.transferred[c],
+			Uploaded:   scheduler.upload.transferred[c],
+			Waiting:    scheduler.download.waiting[c] + scheduler.upload.waiting[c],
+		}
+	}
+	return stats
+}
+
+// LoadSharedBandwidthLimits loads the shared bandwidth limits saved in dir, and
+// saves future limits there.
+func LoadSharedBandwidthLimits(dir string) error {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+	scheduler.path = filepath.Join(dir, bandwidthFile)
+	var bl BandwidthLimits
+	err := persist.LoadFile(bandwidthMetadata, &bl, scheduler.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if bl.MaxDownloadSpeed < 0 || bl.MaxUploadSpeed < 0 {
+		return ErrNegativeBandwidthLimit
+	}
+	scheduler.setLimits(bl)
+	return nil
+}
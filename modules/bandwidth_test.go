@@ -0,0 +1,103 @@
+package modules
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+)
+
+// TestBandwidthPriority checks that transfers of a lower priority class wait
+// while a transfer of a higher priority class is waiting.
+func TestBandwidthPriority(t *testing.T) {
+	var bs bandwidthScheduler
+	bs.setLimits(BandwidthLimits{MaxUploadSpeed: 1000})
+	stop := make(chan struct{})
+
+	// Empty the bucket so that the next transfers have to wait.
+	bs.wait(&bs.upload, BandwidthClassHost, 1000, stop)
+
+	order := make(chan BandwidthClass, 2)
+	go func() {
+		bs.wait(&bs.upload, BandwidthClassRenterUpload, 100, stop)
+		order <- BandwidthClassRenterUpload
+	}()
+	time.Sleep(20 * time.Millisecond)
+	go func() {
+		bs.wait(&bs.upload, BandwidthClassHost, 100, stop)
+		order <- BandwidthClassHost
+	}()
+	if first := <-order; first != BandwidthClassHost {
+		t.Fatal("expected the host transfer to be admitted first, got", first)
+	}
+	<-order
+	if bs.upload.transferred[BandwidthClassHost] != 1100 || bs.upload.transferred[BandwidthClassRenterUpload] != 100 {
+		t.Fatal("transfers were not counted:", bs.upload.transferred)
+	}
+
+	// Waits are interrupted when the connection is closed.
+	close(stop)
+	if bs.wait(&bs.upload, BandwidthClassRenterUpload, 1e6, stop) {
+		t.Fatal("wait should have been interrupted")
+	}
+	if bs.upload.waiting != [numBandwidthClasses]int{} {
+		t.Fatal("waiters were not cleaned up:", bs.upload.waiting)
+	}
+}
+
+// TestScheduledConn checks that scheduled connections are subject to the
+// shared bandwidth limits, and that the limits persist.
+func TestScheduledConn(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	dir := build.TempDir("modules", t.Name())
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadSharedBandwidthLimits(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		scheduler.mu.Lock()
+		scheduler.path = ""
+		scheduler.setLimits(BandwidthLimits{})
+		scheduler.mu.Unlock()
+	}()
+	if err := SetSharedBandwidthLimits(BandwidthLimits{MaxUploadSpeed: -1}); err != ErrNegativeBandwidthLimit {
+		t.Fatal("expected ErrNegativeBandwidthLimit, got", err)
+	}
+	bl := BandwidthLimits{MaxUploadSpeed: 50e3}
+	if err := SetSharedBandwidthLimits(bl); err != nil {
+		t.Fatal(err)
+	}
+
+	client, server := net.Pipe()
+	go io.Copy(ioutil.Discard, server)
+	conn := ScheduleConn(client, BandwidthClassRenterUpload)
+	defer conn.Close()
+
+	// Writing 100 KB at 50 KB/s from an empty bucket takes about 2 seconds.
+	start := time.Now()
+	if _, err := conn.Write(make([]byte, 100e3)); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 1500*time.Millisecond {
+		t.Fatal("write was not rate limited, took", elapsed)
+	}
+
+	// Simulate a restart by clearing the limits, then load them again.
+	scheduler.mu.Lock()
+	scheduler.setLimits(BandwidthLimits{})
+	scheduler.mu.Unlock()
+	if err := LoadSharedBandwidthLimits(dir); err != nil {
+		t.Fatal(err)
+	}
+	if SharedBandwidthLimits() != bl {
+		t.Fatal("limits were not loaded:", SharedBandwidthLimits())
+	}
+}
@@ -46,6 +46,21 @@ var (
 	// should be handled by the module, and not reported to the user.
 	ErrInvalidConsensusChangeID = errors.New("consensus subscription has invalid id - files are inconsistent")
 
+	// ErrMissingSiacoinOutput indicates that a transaction spends a siacoin
+	// output that does not exist in the consensus set. The output may not
+	// have been created yet, or it may already have been spent.
+	ErrMissingSiacoinOutput = errors.New("transaction spends a nonexisting siacoin output")
+
+	// ErrMissingSiafundOutput indicates that a transaction spends a siafund
+	// output that does not exist in the consensus set.
+	ErrMissingSiafundOutput = errors.New("transaction spends a nonexisting siafund output")
+
+	// ErrMissingFileContract indicates that a transaction revises, or submits
+	// a storage proof for, a file contract that does not exist in the
+	// consensus set. The contract may not have been created yet, or it may
+	// already have been resolved.
+	ErrMissingFileContract = errors.New("transaction references a nonexisting file contract")
+
 	// ErrNonExtendingBlock indicates that a block is valid but does not result
 	// in a fork that is the heaviest known fork - the consensus set has not
 	// changed as a result of seeing the block.
@@ -229,6 +244,10 @@ type (
 		// risk of mining invalid blocks.
 		MinimumValidChildTimestamp(types.BlockID) (types.Timestamp, bool)
 
+		// StateProof returns a proof that the object with the provided kind
+		// and ID does or does not exist in the current consensus set.
+		StateProof(kind string, id crypto.Hash) (StateProof, error)
+
 		// StorageProofSegment returns the segment to be used in the storage proof for
 		// a given file contract.
 		StorageProofSegment(types.FileContractID) (uint64, error)
@@ -20,18 +20,24 @@ var (
 )
 
 // managedBroadcastBlock will broadcast a block to the consensus set's peers.
+// Peers that understand compact blocks are sent the block as a header and
+// short transaction IDs, so that they only download the transactions that
+// are not already in their transaction pool.
 func (cs *ConsensusSet) managedBroadcastBlock(b types.Block) {
 	// COMPATv0.5.1 - broadcast the block to all peers <= v0.5.1 and block header to all peers > v0.5.1.
-	var relayBlockPeers, relayHeaderPeers []modules.Peer
+	var relayBlockPeers, relayHeaderPeers, relayCompactPeers []modules.Peer
 	for _, p := range cs.gateway.Peers() {
 		if build.VersionCmp(p.Version, "0.5.1") <= 0 {
 			relayBlockPeers = append(relayBlockPeers, p)
-		} else {
+		} else if !p.Supports(modules.CapabilityCompactBlocks, compactBlockVersion) {
 			relayHeaderPeers = append(relayHeaderPeers, p)
+		} else {
+			relayCompactPeers = append(relayCompactPeers, p)
 		}
 	}
 	go cs.gateway.Broadcast("RelayBlock", b, relayBlockPeers)
 	go cs.gateway.Broadcast("RelayHeader", b.Header(), relayHeaderPeers)
+	go cs.gateway.Broadcast("RelayCompactBlock", newCompactBlock(b), relayCompactPeers)
 }
 
 // validateHeaderAndBlock does some early, low computation verification on the
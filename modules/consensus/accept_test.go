@@ -1002,8 +1002,10 @@ func TestAcceptBlockBroadcasts(t *testing.T) {
 	}
 	select {
 	case <-mg.broadcastCalled:
-		// Broadcast is called twice, once to broadcast blocks to peers <= v0.5.1
-		// and once to broadcast block headers to peers > v0.5.1.
+		// Broadcast is called three times, once to broadcast blocks to peers
+		// <= v0.5.1, once to broadcast block headers to peers > v0.5.1, and
+		// once to broadcast compact blocks to peers that understand them.
+		<-mg.broadcastCalled
 		<-mg.broadcastCalled
 	case <-time.After(10 * time.Millisecond):
 		t.Error("expected AcceptBlock to broadcast a valid block")
@@ -0,0 +1,293 @@
+package consensus
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+const (
+	// compactBlockVersion is the first version of siad that understands the
+	// RelayCompactBlock and SendTxns RPCs. It is only used for peers that do
+	// not share their capabilities. Peers running 1.1.2, the last release
+	// before compact blocks, are sent headers.
+	compactBlockVersion = "1.2.0"
+
+	// shortTxnIDSize is the number of bytes in a short transaction ID.
+	shortTxnIDSize = 8
+)
+
+var (
+	// relayCompactBlockTimeout is the timeout for the RelayCompactBlock RPC,
+	// and for the SendTxns RPC.
+	relayCompactBlockTimeout = build.Select(build.Var{
+		Standard: 3 * time.Minute,
+		Dev:      20 * time.Second,
+		Testing:  3 * time.Second,
+	}).(time.Duration)
+
+	errCompactBlockMismatch = errors.New("reconstructed block does not match the compact block's header")
+	errMissingBlockTxns     = errors.New("peer did not send the requested transactions")
+)
+
+type (
+	// A shortTxnID identifies a transaction within a compact block. It is a
+	// prefix of the hash of the transaction ID and the block ID, so that an
+	// attacker cannot craft transactions that collide with the transactions
+	// of a block before the block is found.
+	shortTxnID [shortTxnIDSize]byte
+
+	// A compactBlock is a block whose transactions have been replaced by
+	// their short IDs. The receiver reconstructs the block from its
+	// transaction pool, and requests any transactions that it is missing
+	// from the sender.
+	compactBlock struct {
+		Header       types.BlockHeader
+		MinerPayouts []types.SiacoinOutput
+		ShortIDs     []shortTxnID
+	}
+
+	// A blockTxnsRequest asks for the transactions at the given indices of a
+	// block.
+	blockTxnsRequest struct {
+		ID      types.BlockID
+		Indices []uint64
+	}
+)
+
+// computeShortTxnID returns the short ID of the transaction with the provided
+// ID in the block with the provided ID.
+func computeShortTxnID(bid types.BlockID, tid types.TransactionID) (sid shortTxnID) {
+	h := crypto.HashAll(bid, tid)
+	copy(sid[:], h[:])
+	return sid
+}
+
+// newCompactBlock returns the compact form of b.
+func newCompactBlock(b types.Block) compactBlock {
+	id := b.ID()
+	cb := compactBlock{
+		Header:       b.Header(),
+		MinerPayouts: b.MinerPayouts,
+		ShortIDs:     make([]shortTxnID, len(b.Transactions)),
+	}
+	for i, txn := range b.Transactions {
+		cb.ShortIDs[i] = computeShortTxnID(id, txn.ID())
+	}
+	return cb
+}
+
+// reconstruct fills in the transactions of the compact block that can be
+// found in pool, returning the block and the indices of the transactions that
+// could not be found. Transactions whose short IDs are ambiguous within pool
+// are treated as missing.
+func (cb compactBlock) reconstruct(pool []types.Transaction) (b types.Block, missing []uint64) {
+	id := cb.Header.ID()
+	candidates := make(map[shortTxnID]int, len(pool))
+	for i, txn := range pool {
+		sid := computeShortTxnID(id, txn.ID())
+		if _, exists := candidates[sid]; exists {
+			candidates[sid] = -1
+			continue
+		}
+		candidates[sid] = i
+	}
+
+	b = types.Block{
+		ParentID:     cb.Header.ParentID,
+		Nonce:        cb.Header.Nonce,
+		Timestamp:    cb.Header.Timestamp,
+		MinerPayouts: cb.MinerPayouts,
+		Transactions: make([]types.Transaction, len(cb.ShortIDs)),
+	}
+	for i, sid := range cb.ShortIDs {
+		if j, exists := candidates[sid]; exists && j >= 0 {
+			b.Transactions[i] = pool[j]
+		} else {
+			missing = append(missing, uint64(i))
+		}
+	}
+	return b, missing
+}
+
+// RegisterCompactBlockSource sets the function that provides the unconfirmed
+// transactions used to reconstruct compact blocks relayed by peers. It is
+// called by the transaction pool.
+func (cs *ConsensusSet) RegisterCompactBlockSource(fn func() []types.Transaction) {
+	cs.mu.Lock()
+	cs.compactBlockSource = fn
+	cs.mu.Unlock()
+}
+
+// managedPoolTransactions returns the unconfirmed transactions that compact
+// blocks are reconstructed from.
+func (cs *ConsensusSet) managedPoolTransactions() []types.Transaction {
+	cs.mu.RLock()
+	fn := cs.compactBlockSource
+	cs.mu.RUnlock()
+	if fn == nil {
+		return nil
+	}
+	return fn()
+}
+
+// threadedRPCRelayCompactBlock is an RPC that accepts a compact block from a
+// peer. The block is reconstructed from the transaction pool, and any missing
+// transactions are requested from the peer using the SendTxns RPC.
+func (cs *ConsensusSet) threadedRPCRelayCompactBlock(conn modules.PeerConn) error {
+	err := conn.SetDeadline(time.Now().Add(relayCompactBlockTimeout))
+	if err != nil {
+		return err
+	}
+	err = cs.tg.Add()
+	if err != nil {
+		return err
+	}
+	wg := new(sync.WaitGroup)
+	defer func() {
+		go func() {
+			wg.Wait()
+			cs.tg.Done()
+		}()
+	}()
+
+	// Decode the compact block from the connection.
+	var cb compactBlock
+	err = encoding.ReadObject(conn, &cb, types.BlockSizeLimit)
+	if err != nil {
+		return err
+	}
+
+	// Validate the header before doing any work to reconstruct the block.
+	cs.mu.RLock()
+	err = cs.db.View(func(tx *bolt.Tx) error {
+		return cs.validateHeader(boltTxWrapper{tx}, cb.Header)
+	})
+	cs.mu.RUnlock()
+	if err == errOrphan {
+		// If the header is an orphan, try to find the parents. As in
+		// threadedRPCRelayHeader, the call is made in a separate goroutine to
+		// prevent deadlocks in the gateway.
+		wg.Add(1)
+		go func() {
+			err := cs.gateway.RPC(conn.RPCAddr(), "SendBlocks", cs.managedReceiveBlocks)
+			if err != nil {
+				cs.log.Debugln("WARN: failed to get parents of orphan compact block:", err)
+			}
+			wg.Done()
+		}()
+		return nil
+	} else if err != nil {
+		cs.reportInvalidBlock(conn.RPCAddr(), err)
+		return err
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		b, err := cs.managedReconstructCompactBlock(conn.RPCAddr(), cb)
+		if err != nil {
+			// Fall back to downloading the full block.
+			cs.log.Debugln("WARN: failed to reconstruct compact block, requesting the full block:", err)
+			err = cs.gateway.RPC(conn.RPCAddr(), "SendBlk", cs.managedReceiveBlock(cb.Header.ID()))
+			if err != nil {
+				cs.log.Debugln("WARN: failed to get compact block's corresponding block:", err)
+			}
+			return
+		}
+		if err := cs.managedAcceptBlock(b); err != nil {
+			cs.reportInvalidBlock(conn.RPCAddr(), err)
+			return
+		}
+		cs.managedBroadcastBlock(b)
+	}()
+	return nil
+}
+
+// managedReconstructCompactBlock rebuilds the block that cb was made from,
+// requesting any transactions that are not in the transaction pool from the
+// peer at addr.
+func (cs *ConsensusSet) managedReconstructCompactBlock(addr modules.NetAddress, cb compactBlock) (types.Block, error) {
+	b, missing := cb.reconstruct(cs.managedPoolTransactions())
+	if len(missing) > 0 {
+		req := blockTxnsRequest{ID: cb.Header.ID(), Indices: missing}
+		err := cs.gateway.RPC(addr, "SendTxns", func(conn modules.PeerConn) error {
+			if err := encoding.WriteObject(conn, req); err != nil {
+				return err
+			}
+			var txns []types.Transaction
+			if err := encoding.ReadObject(conn, &txns, types.BlockSizeLimit); err != nil {
+				return err
+			}
+			if len(txns) != len(missing) {
+				return errMissingBlockTxns
+			}
+			for i, index := range missing {
+				b.Transactions[index] = txns[i]
+			}
+			return nil
+		})
+		if err != nil {
+			return types.Block{}, err
+		}
+	}
+	// The block ID commits to the Merkle root of the transactions, so a
+	// matching ID means that the block was reconstructed correctly.
+	if b.ID() != cb.Header.ID() {
+		return types.Block{}, errCompactBlockMismatch
+	}
+	return b, nil
+}
+
+// rpcSendTxns is an RPC that sends the requested transactions of a block
+// to a peer that is reconstructing it from a compact block.
+func (cs *ConsensusSet) rpcSendTxns(conn modules.PeerConn) error {
+	err := conn.SetDeadline(time.Now().Add(relayCompactBlockTimeout))
+	if err != nil {
+		return err
+	}
+	err = cs.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer cs.tg.Done()
+
+	// Decode the request. A block cannot hold more transactions than fit in
+	// the block size limit, so the request is bounded by it as well.
+	var req blockTxnsRequest
+	err = encoding.ReadObject(conn, &req, types.BlockSizeLimit)
+	if err != nil {
+		return err
+	}
+
+	// Lookup the requested transactions.
+	var txns []types.Transaction
+	cs.mu.RLock()
+	err = cs.db.View(func(tx *bolt.Tx) error {
+		pb, err := getBlockMap(tx, req.ID)
+		if err != nil {
+			return err
+		}
+		txns = make([]types.Transaction, 0, len(req.Indices))
+		for _, i := range req.Indices {
+			if i >= uint64(len(pb.Block.Transactions)) {
+				return errMissingBlockTxns
+			}
+			txns = append(txns, pb.Block.Transactions[i])
+		}
+		return nil
+	})
+	cs.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	return encoding.WriteObject(conn, txns)
+}
@@ -0,0 +1,129 @@
+package consensus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestCompactBlockReconstruct checks that a compact block is reconstructed
+// from the transactions in a pool, and that transactions missing from the
+// pool are reported.
+func TestCompactBlockReconstruct(t *testing.T) {
+	txns := make([]types.Transaction, 4)
+	for i := range txns {
+		txns[i].ArbitraryData = [][]byte{{byte(i)}}
+	}
+	b := types.Block{
+		Timestamp:    types.CurrentTimestamp(),
+		MinerPayouts: []types.SiacoinOutput{{Value: types.NewCurrency64(1)}},
+		Transactions: txns,
+	}
+	cb := newCompactBlock(b)
+	if len(cb.ShortIDs) != len(txns) || cb.Header.ID() != b.ID() {
+		t.Fatal("compact block does not describe the block")
+	}
+
+	// With every transaction in the pool, the block is reconstructed
+	// entirely. Unrelated transactions in the pool are ignored.
+	unrelated := types.Transaction{ArbitraryData: [][]byte{[]byte("foo")}}
+	pool := []types.Transaction{txns[3], unrelated, txns[1], txns[0], txns[2]}
+	rb, missing := cb.reconstruct(pool)
+	if len(missing) != 0 {
+		t.Fatal("expected no missing transactions, got", missing)
+	}
+	if rb.ID() != b.ID() {
+		t.Fatal("reconstructed block does not match the original")
+	}
+
+	// Transactions that are not in the pool are reported as missing.
+	_, missing = cb.reconstruct([]types.Transaction{txns[0], txns[2]})
+	if len(missing) != 2 || missing[0] != 1 || missing[1] != 3 {
+		t.Fatal("expected transactions 1 and 3 to be missing, got", missing)
+	}
+
+	// Short IDs are specific to the block, so a different block with the
+	// same transactions has different short IDs.
+	b.Nonce[0]++
+	if newCompactBlock(b).ShortIDs[0] == cb.ShortIDs[0] {
+		t.Fatal("short IDs do not depend on the block")
+	}
+}
+
+// TestIntegrationCompactBlockRelay checks that blocks are relayed to peers as
+// compact blocks, which are reconstructed from the transaction pool, with any
+// missing transactions requested from the relaying peer.
+func TestIntegrationCompactBlockRelay(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst1, err := createConsensusSetTester(t.Name() + "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst1.Close()
+	cst2, err := blankConsensusSetTester(t.Name() + "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst2.Close()
+
+	// Connect and wait for cst2 to synchronize to cst1.
+	if err := cst2.gateway.Connect(cst1.gateway.Address()); err != nil {
+		t.Fatal(err)
+	}
+	synced := func() bool {
+		for i := 0; i < 50; i++ {
+			if cst1.cs.dbCurrentBlockID() == cst2.cs.dbCurrentBlockID() {
+				return true
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		return false
+	}
+	if !synced() {
+		t.Fatal("cst2 did not synchronize to cst1")
+	}
+
+	// Prevent cst2 from downloading full blocks, so that new blocks can only
+	// reach it as compact blocks.
+	cst1.gateway.UnregisterRPC("SendBlk")
+	defer cst1.gateway.RegisterRPC("SendBlk", cst1.cs.rpcSendBlk)
+
+	// Send a transaction that is relayed into cst2's pool, and mine it.
+	if _, err := cst1.wallet.SendSiacoins(types.NewCurrency64(1), randAddress()); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50 && len(cst2.tpool.TransactionList()) == 0; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if len(cst2.tpool.TransactionList()) == 0 {
+		t.Fatal("transaction was not relayed to cst2")
+	}
+	if _, err := cst1.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	if !synced() {
+		t.Fatal("compact block was not reconstructed from the pool")
+	}
+
+	// Send a transaction that cst2 does not receive, so that it has to
+	// request it when reconstructing the block.
+	cst2.gateway.UnregisterRPC("RelayTransactionSet")
+	cst2.gateway.UnregisterRPC("AnnounceTxnSets")
+	if _, err := cst1.wallet.SendSiacoins(types.NewCurrency64(1), randAddress()); err != nil {
+		t.Fatal(err)
+	}
+	b, err := cst1.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Transactions) == 0 {
+		t.Fatal("mined block does not contain the transaction")
+	}
+	if !synced() {
+		t.Fatal("compact block with missing transactions was not reconstructed")
+	}
+}
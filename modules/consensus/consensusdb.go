@@ -7,6 +7,8 @@ package consensus
 // ignored otherwise, which is suboptimal.
 
 import (
+	"sync"
+
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
@@ -16,6 +18,11 @@ import (
 )
 
 var (
+	// decoderPool holds the decoders used by unmarshal.
+	decoderPool = sync.Pool{
+		New: func() interface{} { return new(encoding.ReusableDecoder) },
+	}
+
 	prefixDSCO = []byte("dsco_")
 	prefixFCEX = []byte("fcex_")
 
@@ -93,9 +100,12 @@ func (cs *ConsensusSet) createConsensusDB(tx *bolt.Tx) error {
 	// Set the siafund pool to 0.
 	setSiafundPool(tx, types.NewCurrency64(0))
 
-	// Update the siafund output diffs map for the genesis block on disk. This
-	// needs to happen between the database being opened/initilized and the
-	// consensus set hash being calculated
+	// Update the siacoin and siafund output diffs map for the genesis block on
+	// disk. This needs to happen between the database being opened/initilized
+	// and the consensus set hash being calculated
+	for _, scod := range cs.blockRoot.SiacoinOutputDiffs {
+		commitSiacoinOutputDiff(tx, scod, modules.DiffApply)
+	}
 	for _, sfod := range cs.blockRoot.SiafundOutputDiffs {
 		commitSiafundOutputDiff(tx, sfod, modules.DiffApply)
 	}
@@ -118,11 +128,22 @@ func (cs *ConsensusSet) createConsensusDB(tx *bolt.Tx) error {
 	return nil
 }
 
+// unmarshal decodes b into v like encoding.Unmarshal, using a pooled decoder.
+// The outputs, contracts, and blocks read while applying and reverting blocks
+// are decoded with unmarshal, so that each lookup does not allocate a new
+// decoder.
+func unmarshal(b []byte, v interface{}) error {
+	dec := decoderPool.Get().(*encoding.ReusableDecoder)
+	err := dec.Unmarshal(b, v)
+	decoderPool.Put(dec)
+	return err
+}
+
 // blockHeight returns the height of the blockchain.
 func blockHeight(tx *bolt.Tx) types.BlockHeight {
 	var height types.BlockHeight
 	bh := tx.Bucket(BlockHeight)
-	err := encoding.Unmarshal(bh.Get(BlockHeight), &height)
+	err := unmarshal(bh.Get(BlockHeight), &height)
 	if build.DEBUG && err != nil {
 		panic(err)
 	}
@@ -157,7 +178,7 @@ func getBlockMap(tx *bolt.Tx, id types.BlockID) (*processedBlock, error) {
 
 	// Decode the block - should never fail.
 	var pb processedBlock
-	err := encoding.Unmarshal(pbBytes, &pb)
+	err := unmarshal(pbBytes, &pb)
 	if build.DEBUG && err != nil {
 		panic(err)
 	}
@@ -180,7 +201,7 @@ func getPath(tx *bolt.Tx, height types.BlockHeight) (id types.BlockID, err error
 		return types.BlockID{}, errNilItem
 	}
 
-	err = encoding.Unmarshal(idBytes, &id)
+	err = unmarshal(idBytes, &id)
 	if build.DEBUG && err != nil {
 		panic(err)
 	}
@@ -193,7 +214,7 @@ func pushPath(tx *bolt.Tx, bid types.BlockID) {
 	bh := tx.Bucket(BlockHeight)
 	heightBytes := bh.Get(BlockHeight)
 	var oldHeight types.BlockHeight
-	err := encoding.Unmarshal(heightBytes, &oldHeight)
+	err := unmarshal(heightBytes, &oldHeight)
 	if build.DEBUG && err != nil {
 		panic(err)
 	}
@@ -218,7 +239,7 @@ func popPath(tx *bolt.Tx) {
 	bh := tx.Bucket(BlockHeight)
 	oldHeightBytes := bh.Get(BlockHeight)
 	var oldHeight types.BlockHeight
-	err := encoding.Unmarshal(oldHeightBytes, &oldHeight)
+	err := unmarshal(oldHeightBytes, &oldHeight)
 	if build.DEBUG && err != nil {
 		panic(err)
 	}
@@ -253,7 +274,7 @@ func getSiacoinOutput(tx *bolt.Tx, id types.SiacoinOutputID) (types.SiacoinOutpu
 		return types.SiacoinOutput{}, errNilItem
 	}
 	var sco types.SiacoinOutput
-	err := encoding.Unmarshal(scoBytes, &sco)
+	err := unmarshal(scoBytes, &sco)
 	if err != nil {
 		return types.SiacoinOutput{}, err
 	}
@@ -304,7 +325,7 @@ func getFileContract(tx *bolt.Tx, id types.FileContractID) (fc types.FileContrac
 	if fcBytes == nil {
 		return types.FileContract{}, errNilItem
 	}
-	err = encoding.Unmarshal(fcBytes, &fc)
+	err = unmarshal(fcBytes, &fc)
 	if err != nil {
 		return types.FileContract{}, err
 	}
@@ -379,7 +400,7 @@ func getSiafundOutput(tx *bolt.Tx, id types.SiafundOutputID) (types.SiafundOutpu
 		return types.SiafundOutput{}, errNilItem
 	}
 	var sfo types.SiafundOutput
-	err := encoding.Unmarshal(sfoBytes, &sfo)
+	err := unmarshal(sfoBytes, &sfo)
 	if err != nil {
 		return types.SiafundOutput{}, err
 	}
@@ -426,7 +447,7 @@ func getSiafundPool(tx *bolt.Tx) (pool types.Currency) {
 	// An error should only be returned if the object stored in the siafund
 	// pool bucket is either unavailable or otherwise malformed. As this is a
 	// developer error, a panic is appropriate.
-	err := encoding.Unmarshal(poolBytes, &pool)
+	err := unmarshal(poolBytes, &pool)
 	if build.DEBUG && err != nil {
 		panic(err)
 	}
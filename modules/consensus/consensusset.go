@@ -81,6 +81,11 @@ type ConsensusSet struct {
 	// whether the consensus set is synced with the network.
 	synced bool
 
+	// compactBlockSource provides the unconfirmed transactions that compact
+	// blocks relayed by peers are reconstructed from. It is registered by the
+	// transaction pool.
+	compactBlockSource func() []types.Transaction
+
 	// Interfaces to abstract the dependencies of the ConsensusSet.
 	marshaler       marshaler
 	blockRuleHelper blockRuleHelper
@@ -124,6 +129,18 @@ func New(gateway modules.Gateway, bootstrap bool, persistDir string) (*Consensus
 		persistDir: persistDir,
 	}
 
+	// Create the diffs for the genesis siacoin outputs, which are only present
+	// on private networks.
+	for i, siacoinOutput := range types.GenesisBlock.Transactions[0].SiacoinOutputs {
+		scid := types.GenesisBlock.Transactions[0].SiacoinOutputID(uint64(i))
+		scod := modules.SiacoinOutputDiff{
+			Direction:     modules.DiffApply,
+			ID:            scid,
+			SiacoinOutput: siacoinOutput,
+		}
+		cs.blockRoot.SiacoinOutputDiffs = append(cs.blockRoot.SiacoinOutputDiffs, scod)
+	}
+
 	// Create the diffs for the genesis siafund outputs.
 	for i, siafundOutput := range types.GenesisBlock.Transactions[0].SiafundOutputs {
 		sfid := types.GenesisBlock.Transactions[0].SiafundOutputID(uint64(i))
@@ -168,13 +185,21 @@ func New(gateway modules.Gateway, bootstrap bool, persistDir string) (*Consensus
 		gateway.RegisterRPC("RelayBlock", cs.rpcRelayBlock) // COMPATv0.5.1
 		gateway.RegisterRPC("RelayHeader", cs.threadedRPCRelayHeader)
 		gateway.RegisterRPC("SendBlk", cs.rpcSendBlk)
+		gateway.RegisterRPC("StateProof", cs.rpcStateProof)
+		gateway.RegisterRPC("RelayCompactBlock", cs.threadedRPCRelayCompactBlock)
+		gateway.RegisterRPC("SendTxns", cs.rpcSendTxns)
 		gateway.RegisterConnectCall("SendBlocks", cs.threadedReceiveBlocks)
+		gateway.RegisterCapability(modules.CapabilityCompactBlocks)
 		cs.tg.OnStop(func() {
 			cs.gateway.UnregisterRPC("SendBlocks")
 			cs.gateway.UnregisterRPC("RelayBlock")
 			cs.gateway.UnregisterRPC("RelayHeader")
 			cs.gateway.UnregisterRPC("SendBlk")
+			cs.gateway.UnregisterRPC("StateProof")
+			cs.gateway.UnregisterRPC("RelayCompactBlock")
+			cs.gateway.UnregisterRPC("SendTxns")
 			cs.gateway.UnregisterConnectCall("SendBlocks")
+			cs.gateway.UnregisterCapability(modules.CapabilityCompactBlocks)
 		})
 
 		// Mark that we are synced with the network.
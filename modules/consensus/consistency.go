@@ -24,27 +24,25 @@ func manageErr(tx *bolt.Tx, err error) {
 	}
 }
 
-// consensusChecksum grabs a checksum of the consensus set by pushing all of
-// the elements in sorted order into a merkle tree and taking the root. All
-// consensus sets with the same current block should have identical consensus
-// checksums.
-func consensusChecksum(tx *bolt.Tx) crypto.Hash {
-	// Create a checksum tree.
-	tree := crypto.NewTree()
+// checksumBuckets are the constant buckets of the consensus set, in the order
+// that they are pushed into the consensus checksum.
+var checksumBuckets = [][]byte{
+	BlockPath,
+	SiacoinOutputs,
+	FileContracts,
+	SiafundOutputs,
+	SiafundPool,
+}
 
+// pushChecksumLeaves passes every leaf of the consensus checksum to push, in
+// order.
+func pushChecksumLeaves(tx *bolt.Tx, push func([]byte)) {
 	// For all of the constant buckets, push every key and every value. Buckets
 	// are sorted in byte-order, therefore this operation is deterministic.
-	consensusSetBuckets := []*bolt.Bucket{
-		tx.Bucket(BlockPath),
-		tx.Bucket(SiacoinOutputs),
-		tx.Bucket(FileContracts),
-		tx.Bucket(SiafundOutputs),
-		tx.Bucket(SiafundPool),
-	}
-	for i := range consensusSetBuckets {
-		err := consensusSetBuckets[i].ForEach(func(k, v []byte) error {
-			tree.Push(k)
-			tree.Push(v)
+	for _, name := range checksumBuckets {
+		err := tx.Bucket(name).ForEach(func(k, v []byte) error {
+			push(k)
+			push(v)
 			return nil
 		})
 		if err != nil {
@@ -64,21 +62,33 @@ func consensusChecksum(tx *bolt.Tx) crypto.Hash {
 
 		// The bucket is a prefixed bucket - add all elements to the tree.
 		return b.ForEach(func(k, v []byte) error {
-			tree.Push(k)
-			tree.Push(v)
+			push(k)
+			push(v)
 			return nil
 		})
 	})
 	if err != nil {
 		manageErr(tx, err)
 	}
+}
 
+// consensusChecksum grabs a checksum of the consensus set by pushing all of
+// the elements in sorted order into a merkle tree and taking the root. All
+// consensus sets with the same current block should have identical consensus
+// checksums.
+func consensusChecksum(tx *bolt.Tx) crypto.Hash {
+	tree := crypto.NewTree()
+	pushChecksumLeaves(tx, tree.Push)
 	return tree.Root()
 }
 
 // checkSiacoinCount checks that the number of siacoins countable within the
 // consensus set equal the expected number of siacoins for the block height.
 func checkSiacoinCount(tx *bolt.Tx) {
+	// The outputs in every bucket are decoded with the same decoder to avoid
+	// allocating a decoder per output.
+	var dec encoding.ReusableDecoder
+
 	// Iterate through all the buckets looking for the delayed siacoin output
 	// buckets, and check that they are for the correct heights.
 	var dscoSiacoins types.Currency
@@ -91,7 +101,7 @@ func checkSiacoinCount(tx *bolt.Tx) {
 		// Sum up the delayed outputs in this bucket.
 		err := b.ForEach(func(_, delayedOutput []byte) error {
 			var sco types.SiacoinOutput
-			err := encoding.Unmarshal(delayedOutput, &sco)
+			err := dec.Unmarshal(delayedOutput, &sco)
 			if err != nil {
 				manageErr(tx, err)
 			}
@@ -111,7 +121,7 @@ func checkSiacoinCount(tx *bolt.Tx) {
 	var scoSiacoins types.Currency
 	err = tx.Bucket(SiacoinOutputs).ForEach(func(_, scoBytes []byte) error {
 		var sco types.SiacoinOutput
-		err := encoding.Unmarshal(scoBytes, &sco)
+		err := dec.Unmarshal(scoBytes, &sco)
 		if err != nil {
 			manageErr(tx, err)
 		}
@@ -126,7 +136,7 @@ func checkSiacoinCount(tx *bolt.Tx) {
 	var fcSiacoins types.Currency
 	err = tx.Bucket(FileContracts).ForEach(func(_, fcBytes []byte) error {
 		var fc types.FileContract
-		err := encoding.Unmarshal(fcBytes, &fc)
+		err := dec.Unmarshal(fcBytes, &fc)
 		if err != nil {
 			manageErr(tx, err)
 		}
@@ -143,14 +153,15 @@ func checkSiacoinCount(tx *bolt.Tx) {
 
 	// Add all of the siafund claims.
 	var claimSiacoins types.Currency
+	siafundPool := getSiafundPool(tx)
 	err = tx.Bucket(SiafundOutputs).ForEach(func(_, sfoBytes []byte) error {
 		var sfo types.SiafundOutput
-		err := encoding.Unmarshal(sfoBytes, &sfo)
+		err := dec.Unmarshal(sfoBytes, &sfo)
 		if err != nil {
 			manageErr(tx, err)
 		}
 
-		coinsPerFund := getSiafundPool(tx).Sub(sfo.ClaimStart)
+		coinsPerFund := siafundPool.Sub(sfo.ClaimStart)
 		claimCoins := coinsPerFund.Mul(sfo.Value).Div(types.SiafundCount)
 		claimSiacoins = claimSiacoins.Add(claimCoins)
 		return nil
@@ -175,10 +186,11 @@ func checkSiacoinCount(tx *bolt.Tx) {
 // checkSiafundCount checks that the number of siafunds countable within the
 // consensus set equal the expected number of siafunds for the block height.
 func checkSiafundCount(tx *bolt.Tx) {
+	var dec encoding.ReusableDecoder
 	var total types.Currency
 	err := tx.Bucket(SiafundOutputs).ForEach(func(_, siafundOutputBytes []byte) error {
 		var sfo types.SiafundOutput
-		err := encoding.Unmarshal(siafundOutputBytes, &sfo)
+		err := dec.Unmarshal(siafundOutputBytes, &sfo)
 		if err != nil {
 			manageErr(tx, err)
 		}
@@ -196,6 +208,8 @@ func checkSiafundCount(tx *bolt.Tx) {
 // checkDSCOs scans the sets of delayed siacoin outputs and checks for
 // consistency.
 func checkDSCOs(tx *bolt.Tx) {
+	var dec encoding.ReusableDecoder
+
 	// Create a map to track which delayed siacoin output maps exist, and
 	// another map to track which ids have appeared in the dsco set.
 	dscoTracker := make(map[types.BlockHeight]struct{})
@@ -212,7 +226,7 @@ func checkDSCOs(tx *bolt.Tx) {
 
 		// Add the bucket to the dscoTracker.
 		var height types.BlockHeight
-		err := encoding.Unmarshal(name[len(prefixDSCO):], &height)
+		err := dec.Unmarshal(name[len(prefixDSCO):], &height)
 		if err != nil {
 			manageErr(tx, err)
 		}
@@ -235,7 +249,7 @@ func checkDSCOs(tx *bolt.Tx) {
 
 			// Sum the funds in the bucket.
 			var sco types.SiacoinOutput
-			err := encoding.Unmarshal(delayedOutput, &sco)
+			err := dec.Unmarshal(delayedOutput, &sco)
 			if err != nil {
 				manageErr(tx, err)
 			}
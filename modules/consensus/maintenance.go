@@ -52,12 +52,13 @@ func applyMaturedSiacoinOutputs(tx *bolt.Tx, pb *processedBlock) {
 	bucketID := append(prefixDSCO, encoding.Marshal(pb.Height)...)
 	var scods []modules.SiacoinOutputDiff
 	var dscods []modules.DelayedSiacoinOutputDiff
+	var dec encoding.ReusableDecoder
 	dbErr := tx.Bucket(bucketID).ForEach(func(idBytes, scoBytes []byte) error {
 		// Decode the key-value pair into an id and a siacoin output.
 		var id types.SiacoinOutputID
 		var sco types.SiacoinOutput
 		copy(id[:], idBytes)
-		encErr := encoding.Unmarshal(scoBytes, &sco)
+		encErr := dec.Unmarshal(scoBytes, &sco)
 		if build.DEBUG && encErr != nil {
 			panic(encErr)
 		}
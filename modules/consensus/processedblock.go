@@ -105,7 +105,7 @@ func (cs *ConsensusSet) setChildTarget(blockMap *bolt.Bucket, pb *processedBlock
 	// Fetch the parent block.
 	var parent processedBlock
 	parentBytes := blockMap.Get(pb.Block.ParentID[:])
-	err := encoding.Unmarshal(parentBytes, &parent)
+	err := unmarshal(parentBytes, &parent)
 	if build.DEBUG && err != nil {
 		panic(err)
 	}
@@ -0,0 +1,178 @@
+package consensus
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/gateway"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+const (
+	// replayFixtureBlocks is the number of blocks containing transactions
+	// that are added to the replay fixture, on top of the blocks mined to
+	// fund the wallet.
+	replayFixtureBlocks = 100
+
+	// replayFixtureOutputs is the number of siacoin outputs created by the
+	// transaction in each block of the replay fixture.
+	replayFixtureOutputs = 20
+
+	// replayFixtureFile is the file in testdata that holds the blocks of the
+	// replay fixture.
+	replayFixtureFile = "replayblocks.dat"
+)
+
+// replayFixture returns the blocks of a blockchain in which every block
+// contains a transaction creating many siacoin outputs. The blocks are read
+// from testdata, which is checked in so that every run, on every machine,
+// replays the same blockchain. If the fixture is missing, it is generated and
+// written to testdata; the fixture must be generated again whenever the
+// testing constants of the consensus rules change.
+func replayFixture(b *testing.B) []types.Block {
+	fixturePath := filepath.Join("testdata", replayFixtureFile)
+	var blocks []types.Block
+	if err := encoding.ReadFile(fixturePath, &blocks); err == nil {
+		return blocks
+	}
+
+	cst, err := createConsensusSetTester(b.Name() + " - fixture")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer cst.Close()
+	for i := 0; i < replayFixtureBlocks; i++ {
+		txnBuilder := cst.wallet.StartTransaction()
+		err := txnBuilder.FundSiacoins(types.SiacoinPrecision.Mul64(replayFixtureOutputs))
+		if err != nil {
+			b.Fatal(err)
+		}
+		for j := 0; j < replayFixtureOutputs; j++ {
+			txnBuilder.AddSiacoinOutput(types.SiacoinOutput{
+				Value:      types.SiacoinPrecision,
+				UnlockHash: randAddress(),
+			})
+		}
+		txnSet, err := txnBuilder.Sign(true)
+		if err != nil {
+			b.Fatal(err)
+		}
+		err = cst.tpool.AcceptTransactionSet(txnSet)
+		if err != nil {
+			b.Fatal(err)
+		}
+		_, err = cst.miner.AddBlock()
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	// Collect the blocks of the current path, excluding the genesis block.
+	h := cst.cs.dbBlockHeight()
+	for i := types.BlockHeight(1); i <= h; i++ {
+		id, err := cst.cs.dbGetPath(i)
+		if err != nil {
+			b.Fatal(err)
+		}
+		pb, err := cst.cs.dbGetBlockMap(id)
+		if err != nil {
+			b.Fatal(err)
+		}
+		blocks = append(blocks, pb.Block)
+	}
+	err = os.MkdirAll(filepath.Dir(fixturePath), 0700)
+	if err != nil {
+		b.Fatal(err)
+	}
+	err = encoding.WriteFile(fixturePath, blocks)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return blocks
+}
+
+// newReplayConsensusSet returns an empty consensus set without subscribers,
+// along with its gateway.
+func newReplayConsensusSet(b *testing.B, name string) (*ConsensusSet, modules.Gateway) {
+	testdir := build.TempDir(modules.ConsensusDir, name)
+	g, err := gateway.New("localhost:0", false, filepath.Join(testdir, modules.GatewayDir))
+	if err != nil {
+		b.Fatal(err)
+	}
+	cs, err := New(g, false, filepath.Join(testdir, modules.ConsensusDir))
+	if err != nil {
+		b.Fatal(err)
+	}
+	return cs, g
+}
+
+// BenchmarkReplayBlocks measures how quickly the blocks of the replay fixture
+// are applied to an empty consensus set, as during initial blockchain
+// download. Each op applies one block, so the number of blocks applied per
+// second is 1e9 divided by ns/op. A new consensus set is created (untimed)
+// whenever the whole fixture has been applied.
+//
+// Xeon, testing build: 9.1 ms / op, 110 blocks / s
+func BenchmarkReplayBlocks(b *testing.B) {
+	blocks := replayFixture(b)
+
+	var cs *ConsensusSet
+	var g modules.Gateway
+	next := len(blocks)
+	b.ResetTimer()
+	b.StopTimer()
+	for i := 0; i < b.N; i++ {
+		if next == len(blocks) {
+			if cs != nil {
+				cs.Close()
+				g.Close()
+			}
+			cs, g = newReplayConsensusSet(b, b.Name()+strconv.Itoa(i))
+			next = 0
+		}
+
+		b.StartTimer()
+		err := cs.AcceptBlock(blocks[next])
+		b.StopTimer()
+		if err != nil {
+			b.Fatal(err)
+		}
+		next++
+	}
+	cs.Close()
+	g.Close()
+}
+
+// BenchmarkCheckConsistency measures how quickly the consistency checks walk
+// the buckets of a consensus set containing the replay fixture.
+//
+// Xeon, testing build: 0.89 ms / op
+func BenchmarkCheckConsistency(b *testing.B) {
+	blocks := replayFixture(b)
+	cs, g := newReplayConsensusSet(b, b.Name())
+	defer g.Close()
+	defer cs.Close()
+	for _, block := range blocks {
+		err := cs.AcceptBlock(block)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = cs.db.View(func(tx *bolt.Tx) error {
+			checkDSCOs(tx)
+			checkSiacoinCount(tx)
+			checkSiafundCount(tx)
+			return nil
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package consensus
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+var (
+	// stateProofTimeout is the timeout for the StateProof RPC. Producing a
+	// proof walks the entire consensus set, so it is more generous than the
+	// timeouts of RPCs that only look up a single object.
+	stateProofTimeout = build.Select(build.Var{
+		Standard: 2 * time.Minute,
+		Dev:      30 * time.Second,
+		Testing:  5 * time.Second,
+	}).(time.Duration)
+)
+
+// stateProofBucket returns the bucket that holds the objects of a kind of
+// state proof.
+func stateProofBucket(kind string) ([]byte, error) {
+	switch kind {
+	case modules.StateProofSiacoinOutput:
+		return SiacoinOutputs, nil
+	case modules.StateProofSiafundOutput:
+		return SiafundOutputs, nil
+	case modules.StateProofFileContract:
+		return FileContracts, nil
+	}
+	return nil, modules.ErrUnknownStateProofKind
+}
+
+// checksumLeafProof returns the proof that the leaf at index is a part of the
+// consensus checksum, along with the checksum and its number of leaves.
+func checksumLeafProof(tx *bolt.Tx, index uint64) (leaf modules.StateProofLeaf, checksum crypto.Hash, numLeaves uint64) {
+	tree := crypto.NewTree()
+	tree.SetIndex(index)
+	pushChecksumLeaves(tx, tree.Push)
+	root, proof, _, numLeaves := tree.Prove()
+	copy(checksum[:], root)
+	leaf.Index = index
+	leaf.Data = proof[0]
+	leaf.HashSet = make([]crypto.Hash, len(proof)-1)
+	for i, p := range proof[1:] {
+		copy(leaf.HashSet[i][:], p)
+	}
+	return leaf, checksum, numLeaves
+}
+
+// stateProof builds a proof that the object with the provided kind and ID
+// does or does not exist in the consensus set.
+func stateProof(tx *bolt.Tx, kind string, id crypto.Hash) (modules.StateProof, error) {
+	bucketName, err := stateProofBucket(kind)
+	if err != nil {
+		return modules.StateProof{}, err
+	}
+	sp := modules.StateProof{
+		Kind:    kind,
+		ID:      id,
+		Height:  blockHeight(tx),
+		BlockID: currentBlockID(tx),
+	}
+
+	// Find where the bucket begins in the checksum.
+	for _, name := range checksumBuckets {
+		keys := 2 * uint64(tx.Bucket(name).Stats().KeyN)
+		if bytes.Equal(name, bucketName) {
+			sp.BucketLeaves = keys
+			break
+		}
+		sp.BucketStart += keys
+	}
+
+	// Find the leaves to prove: the key and value of the object if it
+	// exists, otherwise the keys on either side of where it would be.
+	var indices []uint64
+	index := sp.BucketStart
+	c := tx.Bucket(bucketName).Cursor()
+	for k, _ := c.First(); k != nil; k, _ = c.Next() {
+		if cmp := bytes.Compare(k, id[:]); cmp == 0 {
+			sp.Exists = true
+			indices = []uint64{index, index + 1}
+			break
+		} else if cmp > 0 {
+			if index > sp.BucketStart {
+				indices = append(indices, index-2)
+			}
+			indices = append(indices, index)
+			break
+		}
+		index += 2
+	}
+	if len(indices) == 0 && index > sp.BucketStart {
+		// The ID is greater than every key in the bucket.
+		indices = []uint64{index - 2}
+	}
+
+	for _, i := range indices {
+		var leaf modules.StateProofLeaf
+		leaf, sp.Checksum, sp.NumLeaves = checksumLeafProof(tx, i)
+		sp.Leaves = append(sp.Leaves, leaf)
+	}
+	if len(indices) == 0 {
+		// The bucket is empty, so there are no leaves to prove.
+		tree := crypto.NewTree()
+		pushChecksumLeaves(tx, func(leaf []byte) {
+			tree.Push(leaf)
+			sp.NumLeaves++
+		})
+		sp.Checksum = tree.Root()
+	}
+	return sp, nil
+}
+
+// StateProof returns a proof that the object with the provided kind and ID
+// does or does not exist in the current consensus set.
+func (cs *ConsensusSet) StateProof(kind string, id crypto.Hash) (sp modules.StateProof, err error) {
+	// A call to a closed database can cause undefined behavior.
+	if err = cs.tg.Add(); err != nil {
+		return modules.StateProof{}, err
+	}
+	defer cs.tg.Done()
+
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	err = cs.db.View(func(tx *bolt.Tx) error {
+		sp, err = stateProof(tx, kind, id)
+		return err
+	})
+	return sp, err
+}
+
+// rpcStateProof is an RPC that sends a proof that the requested object does
+// or does not exist in the current consensus set to the requesting peer.
+func (cs *ConsensusSet) rpcStateProof(conn modules.PeerConn) error {
+	err := conn.SetDeadline(time.Now().Add(stateProofTimeout))
+	if err != nil {
+		return err
+	}
+
+	// Decode the kind and ID of the requested object.
+	var kind string
+	var id crypto.Hash
+	if err := encoding.ReadObject(conn, &kind, 64); err != nil {
+		return err
+	}
+	if err := encoding.ReadObject(conn, &id, crypto.HashSize); err != nil {
+		return err
+	}
+	sp, err := cs.StateProof(kind, id)
+	if err != nil {
+		return err
+	}
+	return encoding.WriteObject(conn, sp)
+}
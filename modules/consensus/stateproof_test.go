@@ -0,0 +1,166 @@
+package consensus
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// siacoinOutputIDs returns the IDs of every siacoin output in the consensus
+// set, in sorted order.
+func (cst *consensusSetTester) siacoinOutputIDs() (ids []crypto.Hash) {
+	_ = cst.cs.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(SiacoinOutputs).ForEach(func(k, _ []byte) error {
+			var id crypto.Hash
+			copy(id[:], k)
+			ids = append(ids, id)
+			return nil
+		})
+	})
+	return ids
+}
+
+// TestStateProof checks that the consensus set produces valid proofs for
+// objects that exist and objects that do not, and that tampered proofs are
+// rejected.
+func TestStateProof(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := createConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	ids := cst.siacoinOutputIDs()
+	if len(ids) < 2 {
+		t.Fatal("expected at least two siacoin outputs, got", len(ids))
+	}
+	checksum := cst.cs.dbConsensusChecksum()
+
+	// Prove that an existing output exists.
+	sp, err := cst.cs.StateProof(modules.StateProofSiacoinOutput, ids[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sp.Exists {
+		t.Fatal("existing output was not proven to exist")
+	} else if sp.Checksum != checksum {
+		t.Fatal("proof is not anchored to the consensus checksum")
+	} else if sp.BlockID != cst.cs.CurrentBlock().ID() {
+		t.Fatal("proof is not for the current block")
+	}
+	if err := sp.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	var sco types.SiacoinOutput
+	if err := sp.Object(&sco); err != nil {
+		t.Fatal(err)
+	}
+	var expected types.SiacoinOutput
+	_ = cst.cs.db.View(func(tx *bolt.Tx) error {
+		return encoding.Unmarshal(tx.Bucket(SiacoinOutputs).Get(ids[1][:]), &expected)
+	})
+	if sco.UnlockHash != expected.UnlockHash || sco.Value.Cmp(expected.Value) != 0 {
+		t.Fatal("proven output does not match the output in the consensus set")
+	}
+
+	// Prove that outputs before, between, and after the existing outputs do
+	// not exist.
+	before := ids[0]
+	before[len(before)-1]--
+	between := ids[0]
+	between[len(between)-1]++
+	var after crypto.Hash
+	for i := range after {
+		after[i] = 0xFF
+	}
+	for _, id := range []crypto.Hash{before, between, after} {
+		if id == ids[0] || id == ids[1] || id == ids[len(ids)-1] {
+			continue
+		}
+		sp, err := cst.cs.StateProof(modules.StateProofSiacoinOutput, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sp.Exists {
+			t.Fatal("missing output was proven to exist")
+		}
+		if err := sp.Verify(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A proof of non-existence cannot be produced for an existing output by
+	// claiming that it does not exist.
+	sp, err = cst.cs.StateProof(modules.StateProofSiacoinOutput, ids[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sp.Exists = false
+	if err := sp.Verify(); err != modules.ErrInvalidStateProof {
+		t.Fatal("expected ErrInvalidStateProof, got", err)
+	}
+
+	// Tampering with the proven value invalidates the proof.
+	sp.Exists = true
+	sp.Leaves[1].Data[0]++
+	if err := sp.Verify(); err != modules.ErrInvalidStateProof {
+		t.Fatal("expected ErrInvalidStateProof, got", err)
+	}
+
+	// Proofs of an unknown kind cannot be produced.
+	if _, err := cst.cs.StateProof("foo", ids[0]); err != modules.ErrUnknownStateProofKind {
+		t.Fatal("expected ErrUnknownStateProofKind, got", err)
+	}
+}
+
+// TestIntegrationStateProofRPC checks that peers can request state proofs
+// over the StateProof RPC.
+func TestIntegrationStateProofRPC(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst1, err := blankConsensusSetTester(t.Name() + "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst1.Close()
+	cst2, err := createConsensusSetTester(t.Name() + "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst2.Close()
+	if err := cst1.gateway.Connect(cst2.gateway.Address()); err != nil {
+		t.Fatal(err)
+	}
+
+	id := cst2.siacoinOutputIDs()[0]
+	var sp modules.StateProof
+	err = cst1.gateway.RPC(cst2.gateway.Address(), "StateProof", func(conn modules.PeerConn) error {
+		if err := encoding.WriteObject(conn, modules.StateProofSiacoinOutput); err != nil {
+			return err
+		}
+		if err := encoding.WriteObject(conn, id); err != nil {
+			return err
+		}
+		return encoding.ReadObject(conn, &sp, 1e6)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sp.Exists || sp.BlockID != cst2.cs.CurrentBlock().ID() {
+		t.Fatal("peer did not prove that its output exists")
+	}
+	if err := sp.Verify(); err != nil {
+		t.Fatal(err)
+	}
+}
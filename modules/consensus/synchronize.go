@@ -2,6 +2,7 @@ package consensus
 
 import (
 	"errors"
+	"sort"
 	"sync"
 	"time"
 
@@ -119,6 +120,90 @@ func blockHistory(tx *bolt.Tx) (blockIDs [32]types.BlockID) {
 	return blockIDs
 }
 
+// consensusRuleErrs are the errors returned when a block or header breaks the
+// consensus rules. Only peers that send blocks failing with one of them are
+// reported; any other error may have been caused by the local node, or by a
+// block that honest peers also send, such as an orphan or a known block.
+var consensusRuleErrs = map[error]struct{}{
+	errBadMinerPayouts:              {},
+	errDoSBlock:                     {},
+	errEarlyTimestamp:               {},
+	errExtremeFutureTimestamp:       {},
+	errLargeBlock:                   {},
+	modules.ErrBlockUnsolved:        {},
+	modules.ErrMissingFileContract:  {},
+	modules.ErrMissingSiacoinOutput: {},
+	modules.ErrMissingSiafundOutput: {},
+
+	errAlteredRevisionPayouts:     {},
+	errInvalidStorageProof:        {},
+	errLateRevision:               {},
+	errLowRevisionNumber:          {},
+	errSiacoinInputOutputMismatch: {},
+	errSiafundInputOutputMismatch: {},
+	errUnfinishedFileContract:     {},
+	errWrongUnlockConditions:      {},
+
+	types.ErrDoubleSpend:                      {},
+	types.ErrFileContractOutputSumViolation:   {},
+	types.ErrFileContractWindowEndViolation:   {},
+	types.ErrFileContractWindowStartViolation: {},
+	types.ErrNonZeroClaimStart:                {},
+	types.ErrNonZeroRevision:                  {},
+	types.ErrStorageProofWithOutputs:          {},
+	types.ErrTimelockNotSatisfied:             {},
+	types.ErrTransactionTooLarge:              {},
+	types.ErrZeroMinerFee:                     {},
+	types.ErrZeroOutput:                       {},
+	types.ErrZeroRevision:                     {},
+
+	types.ErrEntropyKey:                {},
+	types.ErrFrivolousSignature:        {},
+	types.ErrInvalidPubKeyIndex:        {},
+	types.ErrMissingSignatures:         {},
+	types.ErrPrematureSignature:        {},
+	types.ErrPublicKeyOveruse:          {},
+	types.ErrSortedUniqueViolation:     {},
+	types.ErrWholeTransactionViolation: {},
+}
+
+// isInvalidBlockErr returns true if err, returned while validating a block or
+// header received from a peer, means that the peer sent something that breaks
+// the consensus rules.
+func isInvalidBlockErr(err error) bool {
+	_, invalid := consensusRuleErrs[err]
+	return invalid
+}
+
+// peersByLatency sorts peers so that the peers with the lowest latency come
+// first, followed by the peers whose latency has not been measured yet.
+func peersByLatency(peers []modules.Peer) []modules.Peer {
+	sort.Stable(byLatency(peers))
+	return peers
+}
+
+// byLatency sorts peers by latency, placing peers with an unknown latency
+// last.
+type byLatency []modules.Peer
+
+func (ps byLatency) Len() int      { return len(ps) }
+func (ps byLatency) Swap(i, j int) { ps[i], ps[j] = ps[j], ps[i] }
+func (ps byLatency) Less(i, j int) bool {
+	if ps[i].Latency == 0 || ps[j].Latency == 0 {
+		return ps[j].Latency == 0 && ps[i].Latency != 0
+	}
+	return ps[i].Latency < ps[j].Latency
+}
+
+// reportInvalidBlock reports the peer at addr to the gateway if err means that
+// the peer sent an invalid block or header.
+func (cs *ConsensusSet) reportInvalidBlock(addr modules.NetAddress, err error) {
+	if isInvalidBlockErr(err) {
+		cs.log.Debugf("WARN: peer %v sent an invalid block: %v", addr, err)
+		cs.gateway.ReportMisbehavior(addr, modules.MisbehaviorInvalidBlock)
+	}
+}
+
 // managedReceiveBlocks is the calling end of the SendBlocks RPC, without the
 // threadgroup wrapping.
 func (cs *ConsensusSet) managedReceiveBlocks(conn modules.PeerConn) (returnErr error) {
@@ -138,6 +223,7 @@ func (cs *ConsensusSet) managedReceiveBlocks(conn modules.PeerConn) (returnErr e
 		//     if netErr, ok := returnErr.(net.Error); ok && netErr.Timeout() && stalled { ... }
 		if stalled && returnErr != nil && (returnErr.Error() == "Read timeout" || returnErr.Error() == "Write timeout") {
 			returnErr = errSendBlocksStalled
+			cs.gateway.ReportMisbehavior(conn.RPCAddr(), modules.MisbehaviorStalledSend)
 		}
 	}()
 
@@ -169,18 +255,7 @@ func (cs *ConsensusSet) managedReceiveBlocks(conn modules.PeerConn) (returnErr e
 		if chainExtended && synced {
 			// The last block received will be the current block since
 			// managedAcceptBlock only returns nil if a block extends the longest chain.
-			currentBlock := cs.managedCurrentBlock()
-			// COMPATv0.5.1 - broadcast the block to all peers <= v0.5.1 and block header to all peers > v0.5.1
-			var relayBlockPeers, relayHeaderPeers []modules.Peer
-			for _, p := range cs.gateway.Peers() {
-				if build.VersionCmp(p.Version, "0.5.1") <= 0 {
-					relayBlockPeers = append(relayBlockPeers, p)
-				} else {
-					relayHeaderPeers = append(relayHeaderPeers, p)
-				}
-			}
-			go cs.gateway.Broadcast("RelayBlock", currentBlock, relayBlockPeers)
-			go cs.gateway.Broadcast("RelayHeader", currentBlock.Header(), relayHeaderPeers)
+			cs.managedBroadcastBlock(cs.managedCurrentBlock())
 		}
 	}()
 
@@ -214,6 +289,7 @@ func (cs *ConsensusSet) managedReceiveBlocks(conn modules.PeerConn) (returnErr e
 				acceptErr = nil
 			}
 			if acceptErr != nil {
+				cs.reportInvalidBlock(conn.RPCAddr(), acceptErr)
 				return acceptErr
 			}
 		}
@@ -380,6 +456,7 @@ func (cs *ConsensusSet) rpcRelayBlock(conn modules.PeerConn) error {
 		}()
 	}
 	if err != nil {
+		cs.reportInvalidBlock(conn.RPCAddr(), err)
 		return err
 	}
 	cs.managedBroadcastBlock(b)
@@ -439,6 +516,7 @@ func (cs *ConsensusSet) threadedRPCRelayHeader(conn modules.PeerConn) error {
 		}()
 		return nil
 	} else if err != nil {
+		cs.reportInvalidBlock(conn.RPCAddr(), err)
 		return err
 	}
 
@@ -519,6 +597,7 @@ func (cs *ConsensusSet) managedReceiveBlock(id types.BlockID) modules.RPCFunc {
 			return err
 		}
 		if err := cs.managedAcceptBlock(block); err != nil {
+			cs.reportInvalidBlock(conn.RPCAddr(), err)
 			return err
 		}
 		cs.managedBroadcastBlock(block)
@@ -528,7 +607,8 @@ func (cs *ConsensusSet) managedReceiveBlock(id types.BlockID) modules.RPCFunc {
 
 // threadedInitialBlockchainDownload performs the IBD on outbound peers. Blocks
 // are downloaded from one peer at a time in 5 minute intervals, so as to
-// prevent any one peer from significantly slowing down IBD.
+// prevent any one peer from significantly slowing down IBD. Peers with the
+// lowest latency are asked first.
 //
 // NOTE: IBD will succeed right now when each peer has a different blockchain.
 // The height and the block id of the remote peers' current blocks are not
@@ -548,7 +628,7 @@ func (cs *ConsensusSet) threadedInitialBlockchainDownload() error {
 	for {
 		numOutboundSynced = 0
 		numOutboundNotSynced = 0
-		for _, p := range cs.gateway.Peers() {
+		for _, p := range peersByLatency(cs.gateway.Peers()) {
 			// We only sync on outbound peers at first to make IBD less susceptible to
 			// fast-mining and other attacks, as outbound peers are more difficult to
 			// manipulate.
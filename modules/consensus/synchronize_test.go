@@ -255,27 +255,27 @@ func TestSendBlocksBroadcastsOnce(t *testing.T) {
 		},
 		{
 			blocksToMine:          1,
-			expectedNumBroadcasts: 2,
+			expectedNumBroadcasts: 3,
 			synced:                true,
 		},
 		{
 			blocksToMine:          2,
-			expectedNumBroadcasts: 2,
+			expectedNumBroadcasts: 3,
 			synced:                true,
 		},
 		{
 			blocksToMine:          int(MaxCatchUpBlocks),
-			expectedNumBroadcasts: 2,
+			expectedNumBroadcasts: 3,
 			synced:                true,
 		},
 		{
 			blocksToMine:          2 * int(MaxCatchUpBlocks),
-			expectedNumBroadcasts: 2,
+			expectedNumBroadcasts: 3,
 			synced:                true,
 		},
 		{
 			blocksToMine:          2*int(MaxCatchUpBlocks) + 1,
-			expectedNumBroadcasts: 2,
+			expectedNumBroadcasts: 3,
 			synced:                true,
 		},
 	}
@@ -1506,3 +1506,50 @@ func TestIntegrationSendBlocksStalls(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// TestPeersByLatency checks that peers are sorted by latency, with peers whose
+// latency is unknown placed last.
+func TestPeersByLatency(t *testing.T) {
+	peers := peersByLatency([]modules.Peer{
+		{NetAddress: "a", Latency: 0},
+		{NetAddress: "b", Latency: 300 * time.Millisecond},
+		{NetAddress: "c", Latency: 100 * time.Millisecond},
+		{NetAddress: "d", Latency: 0},
+		{NetAddress: "e", Latency: 200 * time.Millisecond},
+	})
+	var order string
+	for _, p := range peers {
+		order += string(p.NetAddress)
+	}
+	if order != "cebad" {
+		t.Fatal("peers were sorted in the wrong order:", order)
+	}
+}
+
+// TestIsInvalidBlockErr checks that only consensus rule violations are
+// treated as misbehavior by the peer that sent the block.
+func TestIsInvalidBlockErr(t *testing.T) {
+	tests := []struct {
+		err     error
+		invalid bool
+	}{
+		{nil, false},
+		{errOrphan, false},
+		{errFutureTimestamp, false},
+		{errInconsistentSet, false},
+		{errNoBlockMap, false},
+		{modules.ErrBlockKnown, false},
+		{modules.ErrNonExtendingBlock, false},
+		{errors.New("disk full"), false},
+		{errBadMinerPayouts, true},
+		{errLargeBlock, true},
+		{modules.ErrBlockUnsolved, true},
+		{modules.ErrMissingSiacoinOutput, true},
+		{types.ErrMissingSignatures, true},
+	}
+	for _, test := range tests {
+		if isInvalidBlockErr(test.err) != test.invalid {
+			t.Errorf("isInvalidBlockErr(%v) = %v, expected %v", test.err, !test.invalid, test.invalid)
+		}
+	}
+}
@@ -18,12 +18,9 @@ var (
 	errInvalidStorageProof        = errors.New("provided storage proof is invalid")
 	errLateRevision               = errors.New("file contract revision submitted after deadline")
 	errLowRevisionNumber          = errors.New("transaction has a file contract with an outdated revision number")
-	errMissingSiacoinOutput       = errors.New("transaction spends a nonexisting siacoin output")
-	errMissingSiafundOutput       = errors.New("transaction spends a nonexisting siafund output")
 	errSiacoinInputOutputMismatch = errors.New("siacoin inputs do not equal siacoin outputs for transaction")
 	errSiafundInputOutputMismatch = errors.New("siafund inputs do not equal siafund outputs for transaction")
 	errUnfinishedFileContract     = errors.New("file contract window has not yet openend")
-	errUnrecognizedFileContractID = errors.New("cannot fetch storage proof segment for unknown file contract")
 	errWrongUnlockConditions      = errors.New("transaction contains incorrect unlock conditions")
 )
 
@@ -36,12 +33,12 @@ func validSiacoins(tx *bolt.Tx, t types.Transaction) error {
 		// Check that the input spends an existing output.
 		scoBytes := scoBucket.Get(sci.ParentID[:])
 		if scoBytes == nil {
-			return errMissingSiacoinOutput
+			return modules.ErrMissingSiacoinOutput
 		}
 
 		// Check that the unlock conditions match the required unlock hash.
 		var sco types.SiacoinOutput
-		err := encoding.Unmarshal(scoBytes, &sco)
+		err := unmarshal(scoBytes, &sco)
 		if build.DEBUG && err != nil {
 			panic(err)
 		}
@@ -64,12 +61,12 @@ func storageProofSegment(tx *bolt.Tx, fcid types.FileContractID) (uint64, error)
 	fcBucket := tx.Bucket(FileContracts)
 	fcBytes := fcBucket.Get(fcid[:])
 	if fcBytes == nil {
-		return 0, errUnrecognizedFileContractID
+		return 0, modules.ErrMissingFileContract
 	}
 
 	// Decode the file contract.
 	var fc types.FileContract
-	err := encoding.Unmarshal(fcBytes, &fc)
+	err := unmarshal(fcBytes, &fc)
 	if build.DEBUG && err != nil {
 		panic(err)
 	}
@@ -210,7 +207,9 @@ func validStorageProofs(tx *bolt.Tx, t types.Transaction) error {
 func validFileContractRevisions(tx *bolt.Tx, t types.Transaction) error {
 	for _, fcr := range t.FileContractRevisions {
 		fc, err := getFileContract(tx, fcr.ParentID)
-		if err != nil {
+		if err == errNilItem {
+			return modules.ErrMissingFileContract
+		} else if err != nil {
 			return err
 		}
 
@@ -262,7 +261,9 @@ func validSiafunds(tx *bolt.Tx, t types.Transaction) (err error) {
 	var siafundOutputSum types.Currency
 	for _, sfi := range t.SiafundInputs {
 		sfo, err := getSiafundOutput(tx, sfi.ParentID)
-		if err != nil {
+		if err == errNilItem {
+			return modules.ErrMissingSiafundOutput
+		} else if err != nil {
 			return err
 		}
 
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 	"github.com/NebulousLabs/fastrand"
 
@@ -337,7 +338,7 @@ func TestValidSiacoins(t *testing.T) {
 	}
 	err = cst.cs.db.View(func(tx *bolt.Tx) error {
 		err := validSiacoins(tx, txn)
-		if err != errMissingSiacoinOutput {
+		if err != modules.ErrMissingSiacoinOutput {
 			t.Fatal(err)
 		}
 		return nil
@@ -400,7 +401,7 @@ func TestStorageProofSegment(t *testing.T) {
 
 	// Submit a file contract that is unrecognized.
 	_, err = cst.cs.dbStorageProofSegment(types.FileContractID{})
-	if err != errUnrecognizedFileContractID {
+	if err != modules.ErrMissingFileContract {
 		t.Error(err)
 	}
 
@@ -488,7 +489,7 @@ func TestValidStorageProofs(t *testing.T) {
 	// Try to validate a proof for a file contract that doesn't exist.
 	txn.StorageProofs[0].ParentID = types.FileContractID{}
 	err = cst.cs.dbValidStorageProofs(txn)
-	if err != errUnrecognizedFileContractID {
+	if err != modules.ErrMissingFileContract {
 		t.Error(err)
 	}
 
@@ -666,7 +667,7 @@ func TestValidFileContractRevisions(t *testing.T) {
 	// Submit a file contract revision pointing to an invalid parent.
 	txn.FileContractRevisions[0].ParentID[0]--
 	err = cst.cs.dbValidFileContractRevisions(txn)
-	if err != errNilItem {
+	if err != modules.ErrMissingFileContract {
 		t.Error(err)
 	}
 	txn.FileContractRevisions[0].ParentID[0]++
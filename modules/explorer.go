@@ -10,6 +10,25 @@ const (
 	ExplorerDir = "explorer"
 )
 
+// Kinds of explorer alerts.
+const (
+	// ExplorerAlertDifficultySwing is raised when the difficulty doubles or
+	// halves within a short window.
+	ExplorerAlertDifficultySwing = "difficultyswing"
+
+	// ExplorerAlertEmptyBlockStreak is raised when a streak of blocks without
+	// transactions pays out to the same address.
+	ExplorerAlertEmptyBlockStreak = "emptyblockstreak"
+
+	// ExplorerAlertReorg is raised when a reorg is unusually deep, or when
+	// reorgs are unusually frequent.
+	ExplorerAlertReorg = "reorg"
+
+	// ExplorerAlertDustFlood is raised when a block contains an unusual
+	// number of dust outputs.
+	ExplorerAlertDustFlood = "dustflood"
+)
+
 type (
 	// BlockFacts returns a bunch of statistics about the consensus set as they
 	// were at a specific block.
@@ -45,6 +64,55 @@ type (
 		TotalRevisionVolume types.Currency `json:"totalrevisionvolume"`
 	}
 
+	// ContractExpiration describes an open file contract and the payouts that
+	// will be made when its proof window closes.
+	ContractExpiration struct {
+		ID                 types.FileContractID  `json:"id"`
+		FileSize           uint64                `json:"filesize"`
+		WindowStart        types.BlockHeight     `json:"windowstart"`
+		WindowEnd          types.BlockHeight     `json:"windowend"`
+		ValidProofOutputs  []types.SiacoinOutput `json:"validproofoutputs"`
+		MissedProofOutputs []types.SiacoinOutput `json:"missedproofoutputs"`
+	}
+
+	// ContractExpirationDay groups the file contracts that expire at a given
+	// height. ValidProofPayout is the amount that will be paid out if every
+	// contract receives a storage proof, and MissedProofPayout is the amount
+	// that will be paid out if none of them do.
+	ContractExpirationDay struct {
+		Height            types.BlockHeight    `json:"height"`
+		ValidProofPayout  types.Currency       `json:"validproofpayout"`
+		MissedProofPayout types.Currency       `json:"missedproofpayout"`
+		Contracts         []ContractExpiration `json:"contracts"`
+	}
+
+	// ExplorerAlert describes a network-level anomaly that the explorer
+	// detected in the blockchain, such as an attack in progress. Height and
+	// BlockID identify the block that triggered the alert, which for reorgs is
+	// the last block shared by the old and new chains, and Timestamp is the
+	// timestamp of the block that triggered it.
+	ExplorerAlert struct {
+		Kind      string            `json:"kind"`
+		Height    types.BlockHeight `json:"height"`
+		BlockID   types.BlockID     `json:"blockid"`
+		Timestamp types.Timestamp   `json:"timestamp"`
+		Message   string            `json:"message"`
+	}
+
+	// HostMetrics summarizes the settings advertised by the hosts on the
+	// network at a point in time. Prices are per byte per block, and storage
+	// amounts are in bytes.
+	HostMetrics struct {
+		Timestamp           types.Timestamp `json:"timestamp"`
+		Hosts               uint64          `json:"hosts"`
+		AverageStoragePrice types.Currency  `json:"averagestorageprice"`
+		MedianStoragePrice  types.Currency  `json:"medianstorageprice"`
+		AverageCollateral   types.Currency  `json:"averagecollateral"`
+		MedianCollateral    types.Currency  `json:"mediancollateral"`
+		TotalStorage        uint64          `json:"totalstorage"`
+		RemainingStorage    uint64          `json:"remainingstorage"`
+	}
+
 	// Explorer tracks the blockchain and provides tools for gathering
 	// statistics and finding objects or patterns within the blockchain.
 	Explorer interface {
@@ -99,6 +167,25 @@ type (
 		// the provided siafund output id.
 		SiafundOutputID(types.SiafundOutputID) []types.TransactionID
 
+		// ContractExpirations returns the open file contracts whose proof
+		// windows close between the start and end heights, inclusive, grouped
+		// by expiration height.
+		ContractExpirations(start, end types.BlockHeight) []ContractExpirationDay
+
+		// AddressContractExpirations returns the same calendar as
+		// ContractExpirations, restricted to contracts with proof outputs that
+		// pay to the provided unlock hash. Only those outputs are counted
+		// towards the payouts.
+		AddressContractExpirations(uh types.UnlockHash, start, end types.BlockHeight) []ContractExpirationDay
+
+		// HostMetrics returns the host metrics recorded between the start and
+		// end timestamps, inclusive, in chronological order.
+		HostMetrics(start, end types.Timestamp) []HostMetrics
+
+		// Alerts returns the alerts raised for blocks between the start and
+		// end heights, inclusive, in order of height.
+		Alerts(start, end types.BlockHeight) []ExplorerAlert
+
 		Close() error
 	}
 )
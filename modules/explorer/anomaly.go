@@ -0,0 +1,297 @@
+package explorer
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// The explorer runs a set of heuristics over the blocks it indexes to help the
+// community notice network-level incidents early:
+//
+//   - a difficulty swing, where the difficulty doubles or halves within
+//     difficultySwingWindow blocks, which can indicate a large miner joining
+//     or leaving, or timestamp manipulation;
+//   - an empty block streak, where emptyBlockStreak consecutive blocks
+//     without transactions that move value pay out to a shared address, which can indicate a
+//     miner censoring transactions or withholding them to mine faster;
+//   - a reorg that reverts at least reorgDepthThreshold blocks, or
+//     reorgFrequencyThreshold reorgs within reorgFrequencyWindow blocks,
+//     which can indicate selfish mining or a double spend attempt;
+//   - a dust flood, where a block contains at least dustFloodThreshold
+//     outputs worth less than dustValue, which can indicate an attempt to
+//     bloat the UTXO set.
+//
+// Alerts are stored in the explorer's database alongside the blocks that
+// triggered them, and are removed if those blocks are reverted. Miners
+// rotating their payout addresses defeat the empty block heuristic, so it
+// only catches miners and pools that reuse an address.
+
+var (
+	// alertCooldown is the number of blocks after a difficulty swing or dust
+	// flood alert during which another alert of the same kind is not raised,
+	// so that a sustained incident does not raise an alert for every block.
+	alertCooldown = build.Select(build.Var{
+		Standard: types.BlockHeight(144),
+		Dev:      types.BlockHeight(20),
+		Testing:  types.BlockHeight(5),
+	}).(types.BlockHeight)
+
+	// difficultySwingWindow is the number of blocks over which the
+	// difficulty is compared.
+	difficultySwingWindow = build.Select(build.Var{
+		Standard: types.BlockHeight(144),
+		Dev:      types.BlockHeight(20),
+		Testing:  types.BlockHeight(10),
+	}).(types.BlockHeight)
+
+	// dustFloodThreshold is the number of dust outputs in a single block
+	// that raises a dust flood alert.
+	dustFloodThreshold = build.Select(build.Var{
+		Standard: 500,
+		Dev:      50,
+		Testing:  20,
+	}).(int)
+
+	// dustValue is the value below which a siacoin output is considered
+	// dust.
+	dustValue = types.SiacoinPrecision.Div64(1e3)
+
+	// emptyBlockStreak is the number of consecutive empty blocks paying out
+	// to a shared address that raises an empty block streak alert.
+	emptyBlockStreak = build.Select(build.Var{
+		Standard: 12,
+		Dev:      6,
+		Testing:  4,
+	}).(int)
+
+	// reorgDepthThreshold is the number of reverted blocks that raises a
+	// reorg alert.
+	reorgDepthThreshold = build.Select(build.Var{
+		Standard: 6,
+		Dev:      4,
+		Testing:  3,
+	}).(int)
+
+	// reorgFrequencyThreshold is the number of reorgs within
+	// reorgFrequencyWindow blocks that raises a reorg alert.
+	reorgFrequencyThreshold = build.Select(build.Var{
+		Standard: 6,
+		Dev:      4,
+		Testing:  3,
+	}).(int)
+
+	// reorgFrequencyWindow is the number of blocks over which reorgs are
+	// counted.
+	reorgFrequencyWindow = build.Select(build.Var{
+		Standard: types.BlockHeight(144),
+		Dev:      types.BlockHeight(20),
+		Testing:  types.BlockHeight(10),
+	}).(types.BlockHeight)
+)
+
+// alertKey returns the key under which an alert at the provided height is
+// stored. Keys start with the big-endian height so that a cursor visits alerts
+// in order of height, followed by a sequence number that distinguishes alerts
+// at the same height.
+func alertKey(height types.BlockHeight, seq uint64) []byte {
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key, uint64(height))
+	binary.BigEndian.PutUint64(key[8:], seq)
+	return key
+}
+
+// dbAddAlert stores an alert and logs it.
+func (e *Explorer) dbAddAlert(tx *bolt.Tx, alert modules.ExplorerAlert) {
+	b := tx.Bucket(bucketAlerts)
+	seq, err := b.NextSequence()
+	assertNil(err)
+	assertNil(b.Put(alertKey(alert.Height, seq), encoding.Marshal(alert)))
+	e.log.Printf("ALERT: %v at height %v: %v", alert.Kind, alert.Height, alert.Message)
+}
+
+// dbRemoveBlockAlerts removes the alerts raised for a block that is being
+// reverted. Reorg alerts are kept, as the reorg did happen.
+func dbRemoveBlockAlerts(tx *bolt.Tx, height types.BlockHeight, id types.BlockID) {
+	c := tx.Bucket(bucketAlerts).Cursor()
+	prefix := alertKey(height, 0)[:8]
+	var remove [][]byte
+	for k, v := c.Seek(prefix); k != nil && string(k[:8]) == string(prefix); k, v = c.Next() {
+		var alert modules.ExplorerAlert
+		assertNil(encoding.Unmarshal(v, &alert))
+		if alert.BlockID == id && alert.Kind != modules.ExplorerAlertReorg {
+			remove = append(remove, k)
+		}
+	}
+	for _, k := range remove {
+		assertNil(tx.Bucket(bucketAlerts).Delete(k))
+	}
+}
+
+// dbRecentAlert returns true if an alert of the provided kind was raised for a
+// block at or above the provided height.
+func dbRecentAlert(tx *bolt.Tx, kind string, since types.BlockHeight) bool {
+	c := tx.Bucket(bucketAlerts).Cursor()
+	for k, v := c.Last(); k != nil && types.BlockHeight(binary.BigEndian.Uint64(k)) >= since; k, v = c.Prev() {
+		var alert modules.ExplorerAlert
+		assertNil(encoding.Unmarshal(v, &alert))
+		if alert.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// sharesPayoutAddress returns true if the miner payouts of the two blocks
+// have an address in common.
+func sharesPayoutAddress(a, b types.Block) bool {
+	for _, pa := range a.MinerPayouts {
+		for _, pb := range b.MinerPayouts {
+			if pa.UnlockHash == pb.UnlockHash {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isEmptyBlock returns true if the block contains no transactions other than
+// ones carrying only arbitrary data, which miners add to make their blocks
+// unique.
+func isEmptyBlock(b types.Block) bool {
+	for _, txn := range b.Transactions {
+		if len(txn.SiacoinInputs) != 0 || len(txn.FileContracts) != 0 || len(txn.FileContractRevisions) != 0 || len(txn.StorageProofs) != 0 || len(txn.SiafundInputs) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// dbDetectBlockAnomalies runs the per-block heuristics on a newly applied
+// block, whose facts have already been computed.
+func (e *Explorer) dbDetectBlockAnomalies(tx *bolt.Tx, block types.Block, facts blockFacts) {
+	height := facts.Height
+	alert := func(kind, format string, args ...interface{}) {
+		e.dbAddAlert(tx, modules.ExplorerAlert{
+			Kind:      kind,
+			Height:    height,
+			BlockID:   facts.BlockID,
+			Timestamp: block.Timestamp,
+			Message:   fmt.Sprintf(format, args...),
+		})
+	}
+	cooledDown := func(kind string) bool {
+		return height < alertCooldown || !dbRecentAlert(tx, kind, height-alertCooldown)
+	}
+
+	// Compare the difficulty to the difficulty at the start of the window.
+	if height > difficultySwingWindow {
+		var old blockFacts
+		err := e.dbGetBlockFacts(height-difficultySwingWindow, &old)(tx)
+		if err == nil && !old.Difficulty.IsZero() && cooledDown(modules.ExplorerAlertDifficultySwing) {
+			if facts.Difficulty.Cmp(old.Difficulty.Mul64(2)) >= 0 {
+				alert(modules.ExplorerAlertDifficultySwing, "difficulty rose from %v to %v within %v blocks", old.Difficulty, facts.Difficulty, difficultySwingWindow)
+			} else if facts.Difficulty.Mul64(2).Cmp(old.Difficulty) <= 0 {
+				alert(modules.ExplorerAlertDifficultySwing, "difficulty fell from %v to %v within %v blocks", old.Difficulty, facts.Difficulty, difficultySwingWindow)
+			}
+		}
+	}
+
+	// Measure the streak of empty blocks that pay out to an address shared
+	// with this block. The alert is raised once, when the streak reaches the
+	// threshold.
+	if isEmptyBlock(block) && len(block.MinerPayouts) > 0 {
+		streak := 1
+		for i := types.BlockHeight(1); i < height && streak <= emptyBlockStreak; i++ {
+			prev, exists := e.cs.BlockAtHeight(height - i)
+			if !exists || !isEmptyBlock(prev) || !sharesPayoutAddress(prev, block) {
+				break
+			}
+			streak++
+		}
+		if streak == emptyBlockStreak {
+			alert(modules.ExplorerAlertEmptyBlockStreak, "%v consecutive empty blocks paid out to %v", streak, block.MinerPayouts[0].UnlockHash)
+		}
+	}
+
+	// Count the dust outputs created by the block.
+	var dust int
+	for _, txn := range block.Transactions {
+		for _, sco := range txn.SiacoinOutputs {
+			if sco.Value.Cmp(dustValue) < 0 {
+				dust++
+			}
+		}
+	}
+	if dust >= dustFloodThreshold && cooledDown(modules.ExplorerAlertDustFlood) {
+		alert(modules.ExplorerAlertDustFlood, "block created %v outputs worth less than %v hastings", dust, dustValue)
+	}
+}
+
+// dbDetectReorg records a reorg that reverted the provided blocks, leaving
+// forkHeight as the height of the last block shared by the old and new
+// chains, and raises an alert if the reorg was deep or reorgs have been
+// frequent. first is the first block applied on top of the fork.
+func (e *Explorer) dbDetectReorg(tx *bolt.Tx, reverted []types.Block, forkHeight types.BlockHeight, first types.Block) {
+	var reorgs []types.BlockHeight
+	assertNil(dbGetInternal(internalRecentReorgs, &reorgs)(tx))
+
+	// Forget reorgs that are outside of the window.
+	recent := reorgs[:0]
+	for _, h := range reorgs {
+		if h+reorgFrequencyWindow >= forkHeight && h <= forkHeight {
+			recent = append(recent, h)
+		}
+	}
+	recent = append(recent, forkHeight)
+	assertNil(dbSetInternal(internalRecentReorgs, recent)(tx))
+
+	var reasons []string
+	if len(reverted) >= reorgDepthThreshold {
+		reasons = append(reasons, fmt.Sprintf("reverted %v blocks", len(reverted)))
+	}
+	if len(recent) == reorgFrequencyThreshold {
+		reasons = append(reasons, fmt.Sprintf("%v reorgs within %v blocks", len(recent), reorgFrequencyWindow))
+	}
+	if len(reasons) == 0 {
+		return
+	}
+	message := "reorg " + reasons[0]
+	if len(reasons) > 1 {
+		message += ", the " + reasons[1]
+	}
+	e.dbAddAlert(tx, modules.ExplorerAlert{
+		Kind:      modules.ExplorerAlertReorg,
+		Height:    forkHeight,
+		BlockID:   reverted[len(reverted)-1].ParentID,
+		Timestamp: first.Timestamp,
+		Message:   message,
+	})
+}
+
+// Alerts returns the alerts raised for blocks between the start and end
+// heights, inclusive, in order of height.
+func (e *Explorer) Alerts(start, end types.BlockHeight) []modules.ExplorerAlert {
+	var alerts []modules.ExplorerAlert
+	err := e.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketAlerts).Cursor()
+		for k, v := c.Seek(alertKey(start, 0)); k != nil && types.BlockHeight(binary.BigEndian.Uint64(k)) <= end; k, v = c.Next() {
+			var alert modules.ExplorerAlert
+			if err := encoding.Unmarshal(v, &alert); err != nil {
+				return err
+			}
+			alerts = append(alerts, alert)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil
+	}
+	return alerts
+}
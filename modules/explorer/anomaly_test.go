@@ -0,0 +1,106 @@
+package explorer
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// alertsOfKind returns the alerts of the provided kind.
+func alertsOfKind(alerts []modules.ExplorerAlert, kind string) []modules.ExplorerAlert {
+	var matches []modules.ExplorerAlert
+	for _, alert := range alerts {
+		if alert.Kind == kind {
+			matches = append(matches, alert)
+		}
+	}
+	return matches
+}
+
+// TestExplorerEmptyBlockAlerts checks that a streak of empty blocks paying out
+// to the same address raises an alert, that the alert is removed when the
+// blocks are reverted, and that deep reorgs raise an alert.
+func TestExplorerEmptyBlockAlerts(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	et, err := createExplorerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The tester mines empty blocks to a single address, so exactly one
+	// streak alert is raised, when the streak reaches the threshold.
+	streaks := alertsOfKind(et.explorer.Alerts(0, et.cs.Height()), modules.ExplorerAlertEmptyBlockStreak)
+	if len(streaks) != 1 || streaks[0].Height != types.BlockHeight(emptyBlockStreak) {
+		t.Fatal("expected one empty block streak alert at the threshold, got", streaks)
+	}
+	oldID := streaks[0].BlockID
+
+	// Reorg to a chain of blank blocks. The alert of the reverted chain is
+	// removed, the new chain raises its own alert, and the reorg is deep
+	// enough to raise an alert.
+	if err := et.reorgToBlank(); err != nil {
+		t.Fatal(err)
+	}
+	alerts := et.explorer.Alerts(0, et.cs.Height())
+	streaks = alertsOfKind(alerts, modules.ExplorerAlertEmptyBlockStreak)
+	if len(streaks) != 1 || streaks[0].BlockID == oldID {
+		t.Fatal("streak alert of the reverted chain was not replaced:", streaks)
+	}
+	block, _ := et.cs.BlockAtHeight(streaks[0].Height)
+	if block.ID() != streaks[0].BlockID {
+		t.Fatal("streak alert does not refer to a block in the current chain")
+	}
+	reorgs := alertsOfKind(alerts, modules.ExplorerAlertReorg)
+	if len(reorgs) != 1 || reorgs[0].Height != 0 || reorgs[0].BlockID != types.GenesisID {
+		t.Fatal("expected a reorg alert at the genesis block, got", reorgs)
+	}
+
+	// Alerts are filtered by height.
+	if len(et.explorer.Alerts(1, et.cs.Height())) != len(alerts)-1 {
+		t.Fatal("reorg alert was not filtered out by the start height")
+	}
+}
+
+// TestExplorerDustFloodAlert checks that a block creating many dust outputs
+// raises an alert.
+func TestExplorerDustFloodAlert(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	et, err := createExplorerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	uc, err := et.wallet.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Send dustFloodThreshold dust outputs in a single transaction.
+	dust := types.NewCurrency64(1)
+	txnBuilder := et.wallet.StartTransaction()
+	if err := txnBuilder.FundSiacoins(dust.Mul64(uint64(dustFloodThreshold))); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < dustFloodThreshold; i++ {
+		txnBuilder.AddSiacoinOutput(types.SiacoinOutput{Value: dust, UnlockHash: uc.UnlockHash()})
+	}
+	txnSet, err := txnBuilder.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := et.tpool.AcceptTransactionSet(txnSet); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := et.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	dusts := alertsOfKind(et.explorer.Alerts(0, et.cs.Height()), modules.ExplorerAlertDustFlood)
+	if len(dusts) != 1 || dusts[0].Height != et.cs.Height() {
+		t.Fatal("expected a dust flood alert for the latest block, got", dusts)
+	}
+}
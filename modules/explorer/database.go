@@ -1,6 +1,7 @@
 package explorer
 
 import (
+	"encoding/binary"
 	"errors"
 
 	"github.com/NebulousLabs/Sia/encoding"
@@ -13,18 +14,21 @@ var (
 	errNotExist = errors.New("entry does not exist")
 
 	// database buckets
-	bucketBlockFacts            = []byte("BlockFacts")
-	bucketBlockIDs              = []byte("BlockIDs")
-	bucketBlocksDifficulty      = []byte("BlocksDifficulty")
-	bucketBlockTargets          = []byte("BlockTargets")
-	bucketFileContractHistories = []byte("FileContractHistories")
-	bucketFileContractIDs       = []byte("FileContractIDs")
-	bucketSiacoinOutputIDs      = []byte("SiacoinOutputIDs")
-	bucketSiacoinOutputs        = []byte("SiacoinOutputs")
-	bucketSiafundOutputIDs      = []byte("SiafundOutputIDs")
-	bucketSiafundOutputs        = []byte("SiafundOutputs")
-	bucketTransactionIDs        = []byte("TransactionIDs")
-	bucketUnlockHashes          = []byte("UnlockHashes")
+	bucketAlerts                  = []byte("Alerts")
+	bucketBlockFacts              = []byte("BlockFacts")
+	bucketBlockIDs                = []byte("BlockIDs")
+	bucketBlocksDifficulty        = []byte("BlocksDifficulty")
+	bucketBlockTargets            = []byte("BlockTargets")
+	bucketFileContractExpirations = []byte("FileContractExpirations")
+	bucketFileContractHistories   = []byte("FileContractHistories")
+	bucketFileContractIDs         = []byte("FileContractIDs")
+	bucketHostMetrics             = []byte("HostMetrics")
+	bucketSiacoinOutputIDs        = []byte("SiacoinOutputIDs")
+	bucketSiacoinOutputs          = []byte("SiacoinOutputs")
+	bucketSiafundOutputIDs        = []byte("SiafundOutputIDs")
+	bucketSiafundOutputs          = []byte("SiafundOutputs")
+	bucketTransactionIDs          = []byte("TransactionIDs")
+	bucketUnlockHashes            = []byte("UnlockHashes")
 
 	// bucketInternal is used to store values internal to the explorer
 	bucketInternal = []byte("Internal")
@@ -32,6 +36,7 @@ var (
 	// keys for bucketInternal
 	internalBlockHeight  = []byte("BlockHeight")
 	internalRecentChange = []byte("RecentChange")
+	internalRecentReorgs = []byte("RecentReorgs")
 )
 
 // These functions all return a 'func(*bolt.Tx) error', which, allows them to
@@ -90,6 +95,15 @@ func dbGetTransactionIDSet(bucket []byte, key interface{}, ids *[]types.Transact
 	}
 }
 
+// expirationKey returns the key under which the file contracts expiring at
+// the given height are stored. Heights are encoded big-endian so that a cursor
+// visits them in order.
+func expirationKey(height types.BlockHeight) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(height))
+	return key
+}
+
 // dbGetBlockFacts returns a 'func(*bolt.Tx) error' that decodes
 // the block facts for `height` into blockfacts
 func (e *Explorer) dbGetBlockFacts(height types.BlockHeight, bf *blockFacts) func(*bolt.Tx) error {
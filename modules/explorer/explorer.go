@@ -6,7 +6,9 @@ import (
 	"errors"
 
 	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/renter/hostdb"
 	"github.com/NebulousLabs/Sia/persist"
+	"github.com/NebulousLabs/Sia/sync"
 	"github.com/NebulousLabs/Sia/types"
 )
 
@@ -46,21 +48,41 @@ type (
 	Explorer struct {
 		cs         modules.ConsensusSet
 		db         *persist.BoltDatabase
+		hostDB     hostDB
+		log        *persist.Logger
 		persistDir string
+		tg         sync.ThreadGroup
 	}
 )
 
 // New creates the internal data structures, and subscribes to
-// consensus for changes to the blockchain
-func New(cs modules.ConsensusSet, persistDir string) (*Explorer, error) {
+// consensus for changes to the blockchain. If a gateway is provided, the
+// explorer also scans the hosts announced on the network and records their
+// advertised settings.
+func New(g modules.Gateway, cs modules.ConsensusSet, persistDir string) (*Explorer, error) {
 	// Check that input modules are non-nil
 	if cs == nil {
 		return nil, errNilCS
 	}
 
+	var hdb hostDB
+	if g != nil {
+		var err error
+		hdb, err = hostdb.New(g, cs, persistDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return newExplorer(cs, hdb, persistDir)
+}
+
+// newExplorer initializes an explorer that records host metrics from the
+// provided hostdb, which may be nil.
+func newExplorer(cs modules.ConsensusSet, hdb hostDB, persistDir string) (*Explorer, error) {
 	// Initialize the explorer.
 	e := &Explorer{
 		cs:         cs,
+		hostDB:     hdb,
 		persistDir: persistDir,
 	}
 
@@ -83,10 +105,18 @@ func New(cs modules.ConsensusSet, persistDir string) (*Explorer, error) {
 		return nil, errors.New("explorer subscription failed: " + err.Error())
 	}
 
+	if e.hostDB != nil {
+		go e.threadedRecordHostMetrics()
+	}
 	return e, nil
 }
 
 // Close closes the explorer.
 func (e *Explorer) Close() error {
+	e.tg.Stop()
+	if e.hostDB != nil {
+		e.hostDB.Close()
+	}
+	e.log.Close()
 	return e.db.Close()
 }
@@ -67,7 +67,7 @@ func createExplorerTester(name string) (*explorerTester, error) {
 	if err != nil {
 		return nil, err
 	}
-	e, err := New(cs, filepath.Join(testdir, modules.ExplorerDir))
+	e, err := New(g, cs, filepath.Join(testdir, modules.ExplorerDir))
 	if err != nil {
 		return nil, err
 	}
@@ -134,7 +134,7 @@ func (et *explorerTester) reorgToBlank() error {
 
 	// Mine blocks until the height is higher than the existing consensus,
 	// submitting each block to the explorerTester.
-	currentHeight := cs.Height()
+	currentHeight := et.cs.Height()
 	for i := types.BlockHeight(0); i <= currentHeight+1; i++ {
 		block, err := m.AddBlock()
 		if err != nil {
@@ -148,7 +148,7 @@ func (et *explorerTester) reorgToBlank() error {
 // TestNilExplorerDependencies tries to initialize an explorer with nil
 // dependencies, checks that the correct error is returned.
 func TestNilExplorerDependencies(t *testing.T) {
-	_, err := New(nil, "expdir")
+	_, err := New(nil, nil, "expdir")
 	if err != errNilCS {
 		t.Fatal("Expecting errNilCS")
 	}
@@ -170,7 +170,7 @@ func TestExplorerGenesisHeight(t *testing.T) {
 
 	// Create the explorer - from the subscription only the genesis block will
 	// be received.
-	e, err := New(cs, testdir)
+	e, err := New(g, cs, testdir)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,151 @@
+package explorer
+
+import (
+	"encoding/binary"
+	"sort"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+var (
+	// hostMetricsInterval is how often the explorer records the settings
+	// advertised by the hosts in its hostdb.
+	hostMetricsInterval = build.Select(build.Var{
+		Standard: time.Hour,
+		Dev:      time.Minute,
+		Testing:  time.Second,
+	}).(time.Duration)
+)
+
+// A hostDB tracks the hosts announced on the network and scans them for their
+// settings.
+type hostDB interface {
+	// ActiveHosts returns the hosts that are online and accepting contracts.
+	ActiveHosts() []modules.HostDBEntry
+
+	// Close closes the hostdb.
+	Close() error
+}
+
+// currencies is a sortable list of currencies.
+type currencies []types.Currency
+
+func (cs currencies) Len() int           { return len(cs) }
+func (cs currencies) Less(i, j int) bool { return cs[i].Cmp(cs[j]) < 0 }
+func (cs currencies) Swap(i, j int)      { cs[i], cs[j] = cs[j], cs[i] }
+
+// average returns the average of the currencies.
+func (cs currencies) average() types.Currency {
+	if len(cs) == 0 {
+		return types.ZeroCurrency
+	}
+	var sum types.Currency
+	for _, c := range cs {
+		sum = sum.Add(c)
+	}
+	return sum.Div64(uint64(len(cs)))
+}
+
+// median returns the median of the currencies. cs must be sorted.
+func (cs currencies) median() types.Currency {
+	if len(cs) == 0 {
+		return types.ZeroCurrency
+	}
+	if len(cs)%2 == 1 {
+		return cs[len(cs)/2]
+	}
+	return cs[len(cs)/2-1].Add(cs[len(cs)/2]).Div64(2)
+}
+
+// computeHostMetrics summarizes the settings advertised by the provided hosts.
+func computeHostMetrics(hosts []modules.HostDBEntry, timestamp types.Timestamp) modules.HostMetrics {
+	hm := modules.HostMetrics{
+		Timestamp: timestamp,
+		Hosts:     uint64(len(hosts)),
+	}
+	prices := make(currencies, len(hosts))
+	collaterals := make(currencies, len(hosts))
+	for i, host := range hosts {
+		prices[i] = host.StoragePrice
+		collaterals[i] = host.Collateral
+		hm.TotalStorage += host.TotalStorage
+		hm.RemainingStorage += host.RemainingStorage
+	}
+	sort.Sort(prices)
+	sort.Sort(collaterals)
+	hm.AverageStoragePrice = prices.average()
+	hm.MedianStoragePrice = prices.median()
+	hm.AverageCollateral = collaterals.average()
+	hm.MedianCollateral = collaterals.median()
+	return hm
+}
+
+// hostMetricsKey returns the database key of the host metrics recorded at the
+// provided time. Keys are big-endian so that metrics are sorted by time.
+func hostMetricsKey(timestamp types.Timestamp) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(timestamp))
+	return key
+}
+
+// recordHostMetrics summarizes the hosts currently in the hostdb and stores
+// the result. Nothing is recorded if the hostdb does not know of any online
+// hosts yet, as would be the case shortly after startup.
+func (e *Explorer) recordHostMetrics(timestamp types.Timestamp) error {
+	hosts := e.hostDB.ActiveHosts()
+	if len(hosts) == 0 {
+		return nil
+	}
+	hm := computeHostMetrics(hosts, timestamp)
+	return e.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketHostMetrics).Put(hostMetricsKey(timestamp), encoding.Marshal(hm))
+	})
+}
+
+// threadedRecordHostMetrics periodically records the settings advertised by
+// the hosts in the explorer's hostdb.
+func (e *Explorer) threadedRecordHostMetrics() {
+	if err := e.tg.Add(); err != nil {
+		return
+	}
+	defer e.tg.Done()
+
+	for {
+		select {
+		case <-e.tg.StopChan():
+			return
+		case <-time.After(hostMetricsInterval):
+		}
+		if err := e.recordHostMetrics(types.CurrentTimestamp()); err != nil {
+			e.log.Println("WARN: unable to record host metrics:", err)
+		}
+	}
+}
+
+// HostMetrics returns the host metrics recorded between the start and end
+// timestamps, inclusive, in chronological order.
+func (e *Explorer) HostMetrics(start, end types.Timestamp) []modules.HostMetrics {
+	var metrics []modules.HostMetrics
+	err := e.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketHostMetrics).Cursor()
+		endKey := hostMetricsKey(end)
+		for k, v := c.Seek(hostMetricsKey(start)); k != nil && string(k) <= string(endKey); k, v = c.Next() {
+			var hm modules.HostMetrics
+			if err := encoding.Unmarshal(v, &hm); err != nil {
+				return err
+			}
+			metrics = append(metrics, hm)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil
+	}
+	return metrics
+}
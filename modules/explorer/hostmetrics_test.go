@@ -0,0 +1,101 @@
+package explorer
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// stubHostDB is a hostDB that returns a fixed set of hosts.
+type stubHostDB struct {
+	hosts []modules.HostDBEntry
+}
+
+func (hdb *stubHostDB) ActiveHosts() []modules.HostDBEntry { return hdb.hosts }
+func (hdb *stubHostDB) Close() error                       { return nil }
+
+// TestComputeHostMetrics checks the averages, medians, and totals computed
+// from a set of hosts.
+func TestComputeHostMetrics(t *testing.T) {
+	host := func(price, collateral uint64, total, remaining uint64) modules.HostDBEntry {
+		var entry modules.HostDBEntry
+		entry.StoragePrice = types.NewCurrency64(price)
+		entry.Collateral = types.NewCurrency64(collateral)
+		entry.TotalStorage = total
+		entry.RemainingStorage = remaining
+		return entry
+	}
+
+	hm := computeHostMetrics(nil, 5)
+	if hm.Hosts != 0 || !hm.MedianStoragePrice.IsZero() || hm.Timestamp != 5 {
+		t.Fatal("metrics of an empty host set are wrong:", hm)
+	}
+
+	hosts := []modules.HostDBEntry{
+		host(30, 10, 100, 50),
+		host(10, 40, 200, 0),
+		host(20, 20, 300, 100),
+	}
+	hm = computeHostMetrics(hosts, 5)
+	if hm.Hosts != 3 || hm.TotalStorage != 600 || hm.RemainingStorage != 150 {
+		t.Fatal("wrong host count or storage totals:", hm)
+	}
+	if hm.AverageStoragePrice.Cmp64(20) != 0 || hm.MedianStoragePrice.Cmp64(20) != 0 {
+		t.Fatal("wrong storage price metrics:", hm.AverageStoragePrice, hm.MedianStoragePrice)
+	}
+	if hm.AverageCollateral.Cmp64(23) != 0 || hm.MedianCollateral.Cmp64(20) != 0 {
+		t.Fatal("wrong collateral metrics:", hm.AverageCollateral, hm.MedianCollateral)
+	}
+
+	// The median of an even number of hosts is the mean of the middle two.
+	hm = computeHostMetrics(append(hosts, host(40, 0, 0, 0)), 5)
+	if hm.MedianStoragePrice.Cmp64(25) != 0 {
+		t.Fatal("wrong median of an even number of hosts:", hm.MedianStoragePrice)
+	}
+}
+
+// TestHostMetrics checks that host metrics are recorded and returned as a
+// time series.
+func TestHostMetrics(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	et, err := createExplorerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := et.explorer
+	hdb := new(stubHostDB)
+	e.hostDB = hdb
+
+	// Nothing is recorded while there are no active hosts.
+	if err := e.recordHostMetrics(100); err != nil {
+		t.Fatal(err)
+	}
+	if metrics := e.HostMetrics(0, 1000); len(metrics) != 0 {
+		t.Fatal("expected no metrics, got", metrics)
+	}
+
+	var entry modules.HostDBEntry
+	entry.StoragePrice = types.NewCurrency64(10)
+	entry.TotalStorage = 100
+	hdb.hosts = []modules.HostDBEntry{entry}
+	for _, ts := range []types.Timestamp{300, 100, 200} {
+		if err := e.recordHostMetrics(ts); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	metrics := e.HostMetrics(0, 1000)
+	if len(metrics) != 3 || metrics[0].Timestamp != 100 || metrics[1].Timestamp != 200 || metrics[2].Timestamp != 300 {
+		t.Fatal("metrics are missing or out of order:", metrics)
+	}
+	if metrics[0].Hosts != 1 || metrics[0].TotalStorage != 100 || metrics[0].MedianStoragePrice.Cmp64(10) != 0 {
+		t.Fatal("wrong metrics recorded:", metrics[0])
+	}
+	metrics = e.HostMetrics(150, 200)
+	if len(metrics) != 1 || metrics[0].Timestamp != 200 {
+		t.Fatal("range query returned the wrong metrics:", metrics)
+	}
+}
@@ -1,7 +1,10 @@
 package explorer
 
 import (
+	"encoding/binary"
+
 	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 
@@ -152,3 +155,79 @@ func (e *Explorer) SiafundOutputID(id types.SiafundOutputID) []types.Transaction
 	}
 	return ids
 }
+
+// ContractExpirations returns the open file contracts whose proof windows
+// close between the start and end heights, inclusive, grouped by expiration
+// height.
+func (e *Explorer) ContractExpirations(start, end types.BlockHeight) []modules.ContractExpirationDay {
+	return e.contractExpirations(start, end, func(types.SiacoinOutput) bool { return true })
+}
+
+// AddressContractExpirations returns the open file contracts with proof
+// outputs paying to the provided unlock hash whose proof windows close between
+// the start and end heights, inclusive. Only the outputs paying to the unlock
+// hash are counted towards the payouts.
+func (e *Explorer) AddressContractExpirations(uh types.UnlockHash, start, end types.BlockHeight) []modules.ContractExpirationDay {
+	return e.contractExpirations(start, end, func(sco types.SiacoinOutput) bool { return sco.UnlockHash == uh })
+}
+
+// contractExpirations builds an expiration calendar from the contracts that
+// have at least one proof output matching the filter.
+func (e *Explorer) contractExpirations(start, end types.BlockHeight, filter func(types.SiacoinOutput) bool) []modules.ContractExpirationDay {
+	var days []modules.ContractExpirationDay
+	err := e.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketFileContractExpirations).Cursor()
+		for k, _ := c.Seek(expirationKey(start)); k != nil; k, _ = c.Next() {
+			height := types.BlockHeight(binary.BigEndian.Uint64(k))
+			if height > end {
+				break
+			}
+			day := modules.ContractExpirationDay{Height: height}
+			err := c.Bucket().Bucket(k).ForEach(func(idBytes, fcBytes []byte) error {
+				var id types.FileContractID
+				var fc types.FileContract
+				if err := encoding.Unmarshal(idBytes, &id); err != nil {
+					return err
+				}
+				if err := encoding.Unmarshal(fcBytes, &fc); err != nil {
+					return err
+				}
+				var matched bool
+				for _, sco := range fc.ValidProofOutputs {
+					if filter(sco) {
+						matched = true
+						day.ValidProofPayout = day.ValidProofPayout.Add(sco.Value)
+					}
+				}
+				for _, sco := range fc.MissedProofOutputs {
+					if filter(sco) {
+						matched = true
+						day.MissedProofPayout = day.MissedProofPayout.Add(sco.Value)
+					}
+				}
+				if matched {
+					day.Contracts = append(day.Contracts, modules.ContractExpiration{
+						ID:                 id,
+						FileSize:           fc.FileSize,
+						WindowStart:        fc.WindowStart,
+						WindowEnd:          fc.WindowEnd,
+						ValidProofOutputs:  fc.ValidProofOutputs,
+						MissedProofOutputs: fc.MissedProofOutputs,
+					})
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if len(day.Contracts) > 0 {
+				days = append(days, day)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		build.Critical(err)
+	}
+	return days
+}
@@ -1,9 +1,12 @@
 package explorer
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
 )
 
 // TestImmediateBlockFacts grabs the block facts object from the block explorer
@@ -72,3 +75,117 @@ func TestBlockFacts(t *testing.T) {
 		t.Error("call to 'BlockFacts' has failed")
 	}
 }
+
+// TestContractExpirations checks that the expiration calendar tracks open
+// file contracts, filters them by address, and can be rebuilt from the file
+// contract histories.
+func TestContractExpirations(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	et, err := createExplorerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Propel explorer tester past the hardfork height.
+	for i := 0; i < 10; i++ {
+		if _, err := et.miner.AddBlock(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Create two file contracts that expire at different heights, one of
+	// which pays out to a known address.
+	var uh types.UnlockHash
+	uh[0] = 1
+	height := et.cs.Height()
+	contracts := []types.FileContract{{
+		FileSize:           5e3,
+		WindowStart:        height + 2,
+		WindowEnd:          height + 3,
+		Payout:             types.NewCurrency64(5e9),
+		ValidProofOutputs:  []types.SiacoinOutput{{Value: types.NewCurrency64(4805e6), UnlockHash: uh}},
+		MissedProofOutputs: []types.SiacoinOutput{{Value: types.NewCurrency64(4805e6)}},
+	}, {
+		FileSize:           15e3,
+		WindowStart:        height + 5,
+		WindowEnd:          height + 6,
+		Payout:             types.NewCurrency64(1e9),
+		ValidProofOutputs:  []types.SiacoinOutput{{Value: types.NewCurrency64(961e6)}},
+		MissedProofOutputs: []types.SiacoinOutput{{Value: types.NewCurrency64(961e6)}},
+	}}
+	for _, fc := range contracts {
+		builder := et.wallet.StartTransaction()
+		if err := builder.FundSiacoins(fc.Payout); err != nil {
+			t.Fatal(err)
+		}
+		builder.AddFileContract(fc)
+		txns, err := builder.Sign(true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := et.tpool.AcceptTransactionSet(txns); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := et.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	days := et.explorer.ContractExpirations(0, height+100)
+	if len(days) != 2 || days[0].Height != height+3 || days[1].Height != height+6 {
+		t.Fatalf("unexpected calendar: %+v", days)
+	}
+	if !days[0].ValidProofPayout.Equals64(4805e6) || !days[1].MissedProofPayout.Equals64(961e6) {
+		t.Fatalf("unexpected payouts: %+v", days)
+	}
+	if len(days[0].Contracts) != 1 || days[0].Contracts[0].FileSize != 5e3 {
+		t.Fatalf("unexpected contracts: %+v", days[0].Contracts)
+	}
+	if days := et.explorer.ContractExpirations(height+4, height+100); len(days) != 1 || days[0].Height != height+6 {
+		t.Fatalf("calendar was not restricted to the requested range: %+v", days)
+	}
+
+	// Only the valid proof output of the first contract pays to uh.
+	days = et.explorer.AddressContractExpirations(uh, 0, height+100)
+	if len(days) != 1 || days[0].Height != height+3 {
+		t.Fatalf("unexpected address calendar: %+v", days)
+	}
+	if !days[0].ValidProofPayout.Equals64(4805e6) || !days[0].MissedProofPayout.IsZero() {
+		t.Fatalf("unexpected address payouts: %+v", days[0])
+	}
+
+	// Rebuilding the calendar from the contract histories should produce the
+	// same result.
+	before := et.explorer.ContractExpirations(0, height+100)
+	err = et.explorer.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(bucketFileContractExpirations); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucket(bucketFileContractExpirations); err != nil {
+			return err
+		}
+		return dbRebuildFileContractExpirations(tx)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rebuilt := et.explorer.ContractExpirations(0, height+100); !reflect.DeepEqual(rebuilt, before) {
+		t.Fatalf("unexpected rebuilt calendar: %+v", rebuilt)
+	}
+
+	// Once the first contract expires, it should leave the calendar.
+	for et.cs.Height() < height+3 {
+		if _, err := et.miner.AddBlock(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	days = et.explorer.ContractExpirations(0, height+100)
+	if len(days) != 1 || days[0].Height != height+6 {
+		t.Fatalf("expired contract was not removed: %+v", days)
+	}
+	if days := et.explorer.AddressContractExpirations(uh, 0, height+100); len(days) != 0 {
+		t.Fatalf("expired contract was not removed from address calendar: %+v", days)
+	}
+}
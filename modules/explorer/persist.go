@@ -25,6 +25,12 @@ func (e *Explorer) initPersist() error {
 		return err
 	}
 
+	// Initialize the logger.
+	e.log, err = persist.NewFileLogger(filepath.Join(e.persistDir, "explorer.log"))
+	if err != nil {
+		return err
+	}
+
 	// Open the database
 	db, err := persist.OpenDatabase(explorerMetadata, filepath.Join(e.persistDir, "explorer.db"))
 	if err != nil {
@@ -34,13 +40,20 @@ func (e *Explorer) initPersist() error {
 
 	// Initialize the database
 	err = e.db.Update(func(tx *bolt.Tx) error {
+		// Databases created before the expiration calendar was added need to
+		// have it rebuilt from the file contract histories.
+		rebuildExpirations := tx.Bucket(bucketFileContractExpirations) == nil && tx.Bucket(bucketInternal) != nil
+
 		buckets := [][]byte{
+			bucketAlerts,
 			bucketBlockFacts,
 			bucketBlockIDs,
 			bucketBlocksDifficulty,
 			bucketBlockTargets,
+			bucketFileContractExpirations,
 			bucketFileContractHistories,
 			bucketFileContractIDs,
+			bucketHostMetrics,
 			bucketInternal,
 			bucketSiacoinOutputIDs,
 			bucketSiacoinOutputs,
@@ -62,6 +75,7 @@ func (e *Explorer) initPersist() error {
 		}{
 			{internalBlockHeight, encoding.Marshal(types.BlockHeight(0))},
 			{internalRecentChange, encoding.Marshal(modules.ConsensusChangeID{})},
+			{internalRecentReorgs, encoding.Marshal([]types.BlockHeight{})},
 		}
 		b := tx.Bucket(bucketInternal)
 		for _, d := range internalDefaults {
@@ -74,6 +88,9 @@ func (e *Explorer) initPersist() error {
 			}
 		}
 
+		if rebuildExpirations {
+			return dbRebuildFileContractExpirations(tx)
+		}
 		return nil
 	})
 	if err != nil {
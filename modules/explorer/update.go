@@ -38,6 +38,7 @@ func (e *Explorer) ProcessConsensusChange(cc modules.ConsensusChange) {
 			bid := block.ID()
 			tbid := types.TransactionID(bid)
 
+			dbRemoveBlockAlerts(tx, blockheight, bid)
 			blockheight--
 			dbRemoveBlockID(tx, bid)
 			dbRemoveTransactionID(tx, tbid) // Miner payouts are a transaction
@@ -122,6 +123,11 @@ func (e *Explorer) ProcessConsensusChange(cc modules.ConsensusChange) {
 			dbRemoveBlockFacts(tx, bid)
 		}
 
+		// Record the reorg, if blocks were reverted.
+		if len(cc.RevertedBlocks) > 0 {
+			e.dbDetectReorg(tx, cc.RevertedBlocks, blockheight, cc.AppliedBlocks[0])
+		}
+
 		// Update cumulative stats for applied blocks.
 		for _, block := range cc.AppliedBlocks {
 			bid := block.ID()
@@ -219,6 +225,17 @@ func (e *Explorer) ProcessConsensusChange(cc modules.ConsensusChange) {
 			if tx.Bucket(bucketBlockFacts).Get(encoding.Marshal(block.ParentID)) != nil {
 				facts := dbCalculateBlockFacts(tx, e.cs, block)
 				dbAddBlockFacts(tx, facts)
+				e.dbDetectBlockAnomalies(tx, block, facts)
+			}
+		}
+
+		// Update the file contract expiration calendar. Revisions appear as
+		// the old contract being reverted and the new contract being applied.
+		for _, diff := range cc.FileContractDiffs {
+			if diff.Direction == modules.DiffApply {
+				dbAddFileContractExpiration(tx, diff.ID, diff.FileContract)
+			} else {
+				dbRemoveFileContractExpiration(tx, diff.ID, diff.FileContract)
 			}
 		}
 
@@ -323,6 +340,63 @@ func dbRemoveFileContract(tx *bolt.Tx, id types.FileContractID) {
 	mustDelete(tx.Bucket(bucketFileContractHistories), id)
 }
 
+// Add/Remove file contract expiration
+func dbAddFileContractExpiration(tx *bolt.Tx, id types.FileContractID, fc types.FileContract) {
+	b, err := tx.Bucket(bucketFileContractExpirations).CreateBucketIfNotExists(expirationKey(fc.WindowEnd))
+	assertNil(err)
+	mustPut(b, id, fc)
+}
+func dbRemoveFileContractExpiration(tx *bolt.Tx, id types.FileContractID, fc types.FileContract) {
+	expirations := tx.Bucket(bucketFileContractExpirations)
+	b := expirations.Bucket(expirationKey(fc.WindowEnd))
+	mustDelete(b, id)
+	if k, _ := b.Cursor().First(); k == nil {
+		assertNil(expirations.DeleteBucket(expirationKey(fc.WindowEnd)))
+	}
+}
+
+// dbRebuildFileContractExpirations fills out the expiration calendar using the
+// file contract histories. A contract is still open if it has not received a
+// storage proof and its proof window has not closed.
+func dbRebuildFileContractExpirations(tx *bolt.Tx) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	var height types.BlockHeight
+	err = dbGetInternal(internalBlockHeight, &height)(tx)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(bucketFileContractHistories).ForEach(func(k, v []byte) error {
+		var id types.FileContractID
+		var history fileContractHistory
+		assertNil(encoding.Unmarshal(k, &id))
+		assertNil(encoding.Unmarshal(v, &history))
+		if history.StorageProof.ParentID == id {
+			return nil
+		}
+		fc := history.Contract
+		if n := len(history.Revisions); n > 0 {
+			fcr := history.Revisions[n-1]
+			fc.FileSize = fcr.NewFileSize
+			fc.FileMerkleRoot = fcr.NewFileMerkleRoot
+			fc.WindowStart = fcr.NewWindowStart
+			fc.WindowEnd = fcr.NewWindowEnd
+			fc.ValidProofOutputs = fcr.NewValidProofOutputs
+			fc.MissedProofOutputs = fcr.NewMissedProofOutputs
+			fc.UnlockHash = fcr.NewUnlockHash
+			fc.RevisionNumber = fcr.NewRevisionNumber
+		}
+		if fc.WindowEnd > height {
+			dbAddFileContractExpiration(tx, id, fc)
+		}
+		return nil
+	})
+}
+
 // Add/Remove txid from file contract ID bucket
 func dbAddFileContractID(tx *bolt.Tx, id types.FileContractID, txid types.TransactionID) {
 	b, err := tx.Bucket(bucketFileContractIDs).CreateBucketIfNotExists(encoding.Marshal(id))
@@ -502,6 +576,12 @@ func dbAddGenesisBlock(tx *bolt.Tx) {
 	dbAddBlockID(tx, id, 0)
 	txid := types.GenesisBlock.Transactions[0].ID()
 	dbAddTransactionID(tx, txid, 0)
+	for i, sco := range types.GenesisSiacoinAllocation {
+		scoid := types.GenesisBlock.Transactions[0].SiacoinOutputID(uint64(i))
+		dbAddSiacoinOutputID(tx, scoid, txid)
+		dbAddUnlockHash(tx, sco.UnlockHash, txid)
+		dbAddSiacoinOutput(tx, scoid, sco)
+	}
 	for i, sfo := range types.GenesisSiafundAllocation {
 		sfoid := types.GenesisBlock.Transactions[0].SiafundOutputID(uint64(i))
 		dbAddSiafundOutputID(tx, sfoid, txid)
@@ -516,6 +596,7 @@ func dbAddGenesisBlock(tx *bolt.Tx) {
 			Target:             types.RootTarget,
 			TotalCoins:         types.CalculateCoinbase(0),
 			TransactionCount:   1,
+			SiacoinOutputCount: uint64(len(types.GenesisSiacoinAllocation)),
 			SiafundOutputCount: uint64(len(types.GenesisSiafundAllocation)),
 		},
 		Timestamp: types.GenesisBlock.Timestamp,
@@ -0,0 +1,173 @@
+package modules
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/persist"
+)
+
+// Feature flags allow risky new behaviors to be shipped dark and rolled out
+// gradually. A module registers each flag it consults, along with whether the
+// behavior is enabled by default, when its package is initialized:
+//
+//	var featureFoo = modules.RegisterFeature("foo", "Does foo.", false)
+//
+// and checks modules.FeatureEnabled(featureFoo) before enabling the
+// behavior. Operators can override the default of any flag at runtime through
+// the API. Overrides are saved in the Sia directory, and are applied again
+// when siad restarts. Whether changing a flag affects work that is already in
+// progress is up to the module that consults it.
+
+const (
+	// featuresFile is the name of the file that feature flag overrides are
+	// saved in, relative to the Sia directory.
+	featuresFile = "features.json"
+)
+
+var (
+	// ErrUnknownFeature is returned when overriding a feature flag that has
+	// not been registered.
+	ErrUnknownFeature = errors.New("no feature flag with that name has been registered")
+
+	// featuresMetadata contains the header and version strings that identify
+	// the feature flags file.
+	featuresMetadata = persist.Metadata{
+		Header:  "Sia Feature Flags",
+		Version: "1.3.0",
+	}
+
+	// features is the registry of feature flags.
+	features = struct {
+		flags     map[string]FeatureFlag
+		overrides map[string]bool
+		path      string
+		mu        sync.RWMutex
+	}{
+		flags:     make(map[string]FeatureFlag),
+		overrides: make(map[string]bool),
+	}
+)
+
+// FeatureFlag describes a behavior that can be enabled or disabled at
+// runtime.
+type FeatureFlag struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Default     bool   `json:"default"`    // Whether the behavior is enabled when not overridden.
+	Enabled     bool   `json:"enabled"`    // Whether the behavior is currently enabled.
+	Overridden  bool   `json:"overridden"` // Whether Enabled was set by the operator.
+}
+
+// featureFlagsByName sorts feature flags by name.
+type featureFlagsByName []FeatureFlag
+
+func (ff featureFlagsByName) Len() int           { return len(ff) }
+func (ff featureFlagsByName) Less(i, j int) bool { return ff[i].Name < ff[j].Name }
+func (ff featureFlagsByName) Swap(i, j int)      { ff[i], ff[j] = ff[j], ff[i] }
+
+// RegisterFeature registers a feature flag and returns its name. Registering
+// the same name twice is a developer error.
+func RegisterFeature(name, description string, enabledByDefault bool) string {
+	features.mu.Lock()
+	defer features.mu.Unlock()
+	if _, exists := features.flags[name]; exists {
+		build.Critical("feature flag registered twice:", name)
+	}
+	features.flags[name] = FeatureFlag{
+		Name:        name,
+		Description: description,
+		Default:     enabledByDefault,
+	}
+	return name
+}
+
+// FeatureEnabled returns true if the behavior guarded by the named feature
+// flag should be enabled. Unregistered flags are never enabled.
+func FeatureEnabled(name string) bool {
+	features.mu.RLock()
+	defer features.mu.RUnlock()
+	flag, exists := features.flags[name]
+	if !exists {
+		return false
+	}
+	if enabled, overridden := features.overrides[name]; overridden {
+		return enabled
+	}
+	return flag.Default
+}
+
+// FeatureFlags returns every registered feature flag, sorted by name.
+func FeatureFlags() []FeatureFlag {
+	features.mu.RLock()
+	defer features.mu.RUnlock()
+	flags := make([]FeatureFlag, 0, len(features.flags))
+	for name, flag := range features.flags {
+		flag.Enabled, flag.Overridden = features.overrides[name]
+		if !flag.Overridden {
+			flag.Enabled = flag.Default
+		}
+		flags = append(flags, flag)
+	}
+	sort.Sort(featureFlagsByName(flags))
+	return flags
+}
+
+// SetFeatureOverride overrides the default of a feature flag, enabling or
+// disabling the behavior it guards. The override is saved if overrides have
+// been loaded with LoadFeatureOverrides.
+func SetFeatureOverride(name string, enabled bool) error {
+	features.mu.Lock()
+	defer features.mu.Unlock()
+	if _, exists := features.flags[name]; !exists {
+		return ErrUnknownFeature
+	}
+	features.overrides[name] = enabled
+	return saveFeatureOverrides()
+}
+
+// ClearFeatureOverride removes the override of a feature flag, restoring its
+// default.
+func ClearFeatureOverride(name string) error {
+	features.mu.Lock()
+	defer features.mu.Unlock()
+	if _, exists := features.flags[name]; !exists {
+		return ErrUnknownFeature
+	}
+	delete(features.overrides, name)
+	return saveFeatureOverrides()
+}
+
+// LoadFeatureOverrides loads the feature flag overrides saved in dir, and
+// saves future overrides there. It should be called before any module is
+// created. Overrides of flags that have not been registered are kept, as the
+// modules that register them may not have been loaded.
+func LoadFeatureOverrides(dir string) error {
+	features.mu.Lock()
+	defer features.mu.Unlock()
+	features.path = filepath.Join(dir, featuresFile)
+	var overrides map[string]bool
+	err := persist.LoadFile(featuresMetadata, &overrides, features.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for name, enabled := range overrides {
+		features.overrides[name] = enabled
+	}
+	return nil
+}
+
+// saveFeatureOverrides saves the feature flag overrides. The caller must hold
+// the lock.
+func saveFeatureOverrides() error {
+	if features.path == "" {
+		return nil
+	}
+	return persist.SaveFileSync(featuresMetadata, features.overrides, features.path)
+}
@@ -0,0 +1,88 @@
+package modules
+
+import (
+	"os"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/build"
+)
+
+// TestFeatureFlags checks that feature flags report their defaults until
+// they are overridden, and that overrides can be removed.
+func TestFeatureFlags(t *testing.T) {
+	on := RegisterFeature("test-flags-on", "On by default.", true)
+	off := RegisterFeature("test-flags-off", "Off by default.", false)
+	if !FeatureEnabled(on) || FeatureEnabled(off) {
+		t.Fatal("flags do not match their defaults")
+	}
+	if FeatureEnabled("test-flags-unregistered") {
+		t.Fatal("unregistered flags should not be enabled")
+	}
+
+	if err := SetFeatureOverride(on, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetFeatureOverride(off, true); err != nil {
+		t.Fatal(err)
+	}
+	if FeatureEnabled(on) || !FeatureEnabled(off) {
+		t.Fatal("overrides were not applied")
+	}
+	for _, f := range FeatureFlags() {
+		if (f.Name == on || f.Name == off) && (!f.Overridden || f.Enabled == f.Default) {
+			t.Fatal("flag does not report its override:", f)
+		}
+	}
+
+	if err := ClearFeatureOverride(on); err != nil {
+		t.Fatal(err)
+	}
+	if err := ClearFeatureOverride(off); err != nil {
+		t.Fatal(err)
+	}
+	if !FeatureEnabled(on) || FeatureEnabled(off) {
+		t.Fatal("defaults were not restored")
+	}
+	if err := SetFeatureOverride("test-flags-unregistered", true); err != ErrUnknownFeature {
+		t.Fatal("expected ErrUnknownFeature, got", err)
+	}
+}
+
+// TestFeatureOverridesPersist checks that feature flag overrides are saved,
+// and that saved overrides are applied to flags registered after they are
+// loaded.
+func TestFeatureOverridesPersist(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	dir := build.TempDir("modules", t.Name())
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadFeatureOverrides(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		features.mu.Lock()
+		features.path = ""
+		features.mu.Unlock()
+	}()
+
+	persisted := RegisterFeature("test-persist", "Persisted override.", true)
+	if err := SetFeatureOverride(persisted, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a restart by forgetting the overrides and the flag.
+	features.mu.Lock()
+	delete(features.overrides, persisted)
+	delete(features.flags, persisted)
+	features.mu.Unlock()
+	if err := LoadFeatureOverrides(dir); err != nil {
+		t.Fatal(err)
+	}
+	persisted = RegisterFeature("test-persist", "Persisted override.", true)
+	if FeatureEnabled(persisted) {
+		t.Fatal("override was not loaded")
+	}
+}
@@ -2,14 +2,66 @@ package modules
 
 import (
 	"net"
+	"time"
 
 	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/types"
 )
 
 const (
 	// GatewayDir is the name of the directory used to store the gateway's
 	// persistent data.
 	GatewayDir = "gateway"
+
+	// PortMappingUPnP, PortMappingPCP, and PortMappingNATPMP are the
+	// protocols that the gateway can forward its port on the router with.
+	PortMappingUPnP   = "upnp"
+	PortMappingPCP    = "pcp"
+	PortMappingNATPMP = "natpmp"
+)
+
+// Kinds of peer misbehavior, reported with Gateway.ReportMisbehavior.
+const (
+	// MisbehaviorInvalidBlock is reported when a peer relays a block or
+	// header that breaks the consensus rules.
+	MisbehaviorInvalidBlock = "invalidblock"
+
+	// MisbehaviorMalformedRPC is reported when a peer calls an unknown RPC
+	// or sends an object that cannot be decoded.
+	MisbehaviorMalformedRPC = "malformedrpc"
+
+	// MisbehaviorRPCTimeout is reported when a peer fails to read or write
+	// within the deadlines of an RPC.
+	MisbehaviorRPCTimeout = "rpctimeout"
+
+	// MisbehaviorStalledSend is reported when a peer stops sending in the
+	// middle of an RPC, such as a SendBlocks call that times out without
+	// sending any blocks.
+	MisbehaviorStalledSend = "stalledsend"
+)
+
+// Capabilities that gateways exchange with their peers upon connecting, so
+// that optional RPCs can be used with the peers that support them. Each
+// capability is a bit in a uint64; peers ignore the bits they do not know.
+const (
+	// CapabilityQUIC is set by gateways that accept peer connections over
+	// QUIC.
+	CapabilityQUIC uint64 = 1 << iota
+
+	// CapabilityCompactBlocks is set by nodes that accept blocks through the
+	// RelayCompactBlock RPC.
+	CapabilityCompactBlocks
+
+	// CapabilityInventoryRelay is set by nodes that accept transaction set
+	// announcements through the AnnounceTxnSets RPC.
+	CapabilityInventoryRelay
+
+	// CapabilityPing is set by gateways that answer the Ping RPC.
+	CapabilityPing
+
+	// CapabilityHostProbe is set by hosts that answer the ProbeHost RPC,
+	// checking that a peer's host is reachable at its address.
+	CapabilityHostProbe
 )
 
 var (
@@ -70,6 +122,153 @@ type (
 		Local      bool       `json:"local"`
 		NetAddress NetAddress `json:"netaddress"`
 		Version    string     `json:"version"`
+		BanScore   float64    `json:"banscore"` // Decaying misbehavior score; the peer is banned when it reaches 100.
+
+		// PublicKey is the identity key that the peer proved ownership of
+		// when the connection was encrypted. It is empty if the connection
+		// is not encrypted.
+		PublicKey types.SiaPublicKey `json:"publickey"`
+
+		// Latency is the smoothed round-trip time to the peer, measured
+		// periodically by the gateway. It is zero until the first
+		// measurement.
+		Latency time.Duration `json:"latency"`
+
+		// Capabilities are the capability bits that the peer shared with
+		// the gateway. SharedCapabilities is false until the peer has
+		// shared them, which older peers never do.
+		Capabilities       uint64 `json:"capabilities"`
+		SharedCapabilities bool   `json:"sharedcapabilities"`
+	}
+
+	// BannedPeer describes a peer that the gateway has temporarily banned for
+	// misbehaving.
+	BannedPeer struct {
+		NetAddress NetAddress `json:"netaddress"`
+		Reason     string     `json:"reason"` // Kind of the misbehavior that triggered the ban.
+		Until      time.Time  `json:"until"`
+	}
+
+	// NodeInfo describes an entry in the gateway's node list.
+	NodeInfo struct {
+		NetAddress NetAddress `json:"netaddress"`
+		FirstSeen  time.Time  `json:"firstseen"`
+
+		// The connection history of the node. LastSeen is the time of the
+		// last successful connection to the node, and NextAttempt is the
+		// time before which the gateway will not pick the node again after
+		// ConsecutiveFailures failed connections in a row.
+		Successes           uint64    `json:"successes"`
+		Failures            uint64    `json:"failures"`
+		ConsecutiveFailures uint64    `json:"consecutivefailures"`
+		LastSeen            time.Time `json:"lastseen"`
+		LastAttempt         time.Time `json:"lastattempt"`
+		NextAttempt         time.Time `json:"nextattempt"`
+
+		// The ban history of the node.
+		Bans          uint64    `json:"bans"`
+		LastBanned    time.Time `json:"lastbanned"`
+		LastBanReason string    `json:"lastbanreason"`
+	}
+
+	// PeerStats contains a peer along with statistics about the RPCs that
+	// have been relayed between the gateway and that peer.
+	PeerStats struct {
+		Peer
+		ConnectedSince time.Time     `json:"connectedsince"`
+		Uptime         time.Duration `json:"uptime"`         // Time since the connection was made.
+		Transport      string        `json:"transport"`      // "tcp" or "quic".
+		RPCsCalled     uint64        `json:"rpcscalled"`     // RPCs called on the peer.
+		RPCsFailed     uint64        `json:"rpcsfailed"`     // RPCs called on the peer that returned an error.
+		RPCsReceived   uint64        `json:"rpcsreceived"`   // RPCs the peer called on the gateway.
+		AverageRPCTime time.Duration `json:"averagerpctime"` // Average time taken by successful RPCs called on the peer.
+		BytesRead      uint64        `json:"bytesread"`      // Bytes read from the peer, including RPC headers.
+		BytesWritten   uint64        `json:"byteswritten"`   // Bytes written to the peer, including RPC headers.
+	}
+
+	// GatewayTransportStats contains the metrics of a transport that peer
+	// connections can be made over.
+	GatewayTransportStats struct {
+		Transport       string        `json:"transport"`
+		Enabled         bool          `json:"enabled"`
+		Peers           int           `json:"peers"`           // Peers currently connected over the transport.
+		Dials           uint64        `json:"dials"`           // Attempts to dial a peer over the transport.
+		DialFailures    uint64        `json:"dialfailures"`    // Dials that failed.
+		Inbound         uint64        `json:"inbound"`         // Peers accepted over the transport.
+		BytesRead       uint64        `json:"bytesread"`       // Bytes read from peers, including handshakes.
+		BytesWritten    uint64        `json:"byteswritten"`    // Bytes written to peers, including handshakes.
+		AverageDialTime time.Duration `json:"averagedialtime"` // Average time taken by successful dials.
+	}
+
+	// GatewayPeerLimits are the limits on the number of peers that the
+	// gateway connects to. Whitelisted peers may use WhitelistSlots inbound
+	// slots beyond MaxInbound, so that trusted peers can still connect when
+	// the gateway is full.
+	GatewayPeerLimits struct {
+		MaxInbound     int `json:"maxinbound"`
+		TargetOutbound int `json:"targetoutbound"`
+		WhitelistSlots int `json:"whitelistslots"`
+	}
+
+	// GatewayRPCTimeouts are the deadlines that the gateway enforces on
+	// RPCs. Default applies to every RPC, and PerRPC overrides it for the
+	// RPCs that it names. A zero field in an override falls back to the
+	// default.
+	GatewayRPCTimeouts struct {
+		Default RPCTimeouts            `json:"default"`
+		PerRPC  map[string]RPCTimeouts `json:"perrpc"`
+	}
+
+	// RPCTimeouts are the deadlines of an RPC. ReadTimeout and WriteTimeout
+	// limit how long a single read from or write to the peer may block, so
+	// that a peer that stops responding is dropped quickly. Timeout limits
+	// the length of the whole RPC, unless the RPC sets its own deadline.
+	// Zero means no limit.
+	RPCTimeouts struct {
+		ReadTimeout  time.Duration `json:"readtimeout"`
+		WriteTimeout time.Duration `json:"writetimeout"`
+		Timeout      time.Duration `json:"timeout"`
+	}
+
+	// GatewayPortMapping describes the gateway's attempt to forward its port
+	// on the router, so that peers outside the local network can connect to
+	// it.
+	GatewayPortMapping struct {
+		Protocol        string     `json:"protocol"` // Protocol of the last successful mapping, if any.
+		Mapped          bool       `json:"mapped"`
+		ExternalAddress NetAddress `json:"externaladdress"`
+		Expires         time.Time  `json:"expires"` // Zero for UPnP mappings, which do not expire.
+		LastAttempt     time.Time  `json:"lastattempt"`
+		Error           string     `json:"error"` // Error of the last attempt, if it failed.
+	}
+
+	// GatewayRateLimits are the caps on the rate at which the gateway reads
+	// from and writes to its peers, summed across all peer connections, in
+	// bytes per second. A cap of zero means that the direction is not
+	// limited.
+	GatewayRateLimits struct {
+		MaxDownloadSpeed int64 `json:"maxdownloadspeed"`
+		MaxUploadSpeed   int64 `json:"maxuploadspeed"`
+	}
+
+	// GatewayPeerFilter restricts the peers that the gateway connects to and
+	// accepts connections from. Entries are IP addresses, CIDR ranges, or
+	// onion hostnames. The gateway never connects to or accepts a peer that
+	// matches the blacklist, and if the whitelist is not empty, it only
+	// connects to and accepts peers that match the whitelist.
+	GatewayPeerFilter struct {
+		Blacklist []string `json:"blacklist"`
+		Whitelist []string `json:"whitelist"`
+	}
+
+	// NetworkTopology is a snapshot of the gateway's current view of the
+	// network, containing its peers and every node in its node list.
+	NetworkTopology struct {
+		Address   NetAddress  `json:"netaddress"`
+		Version   string      `json:"version"`
+		Timestamp time.Time   `json:"timestamp"`
+		Peers     []PeerStats `json:"peers"`
+		Nodes     []NodeInfo  `json:"nodes"`
 	}
 
 	// A PeerConn is the connection type used when communicating with peers during
@@ -100,12 +299,95 @@ type (
 		// Disconnect terminates a connection to a peer.
 		Disconnect(NetAddress) error
 
+		// ForgetPeerKey forgets the identity key that a node has presented,
+		// so that the node is accepted again with a new identity key.
+		ForgetPeerKey(NetAddress) error
+
 		// Address returns the Gateway's address.
 		Address() NetAddress
 
+		// PublicKey returns the identity key that the Gateway proves its
+		// identity with to peers.
+		PublicKey() types.SiaPublicKey
+
 		// Peers returns the addresses that the Gateway is currently connected to.
 		Peers() []Peer
 
+		// PeerStats returns the traffic, RPC counts, RPC latency, and uptime
+		// of each peer.
+		PeerStats() []PeerStats
+
+		// Topology returns a snapshot of the Gateway's view of the network.
+		Topology() NetworkTopology
+
+		// Transports returns the metrics of each transport that peer
+		// connections can be made over.
+		Transports() []GatewayTransportStats
+
+		// PortMapping returns the status of the gateway's port mapping on
+		// the router.
+		PortMapping() GatewayPortMapping
+
+		// RateLimits returns the caps on the rate at which the gateway reads
+		// from and writes to its peers.
+		RateLimits() GatewayRateLimits
+
+		// SetRateLimits changes the caps on the rate at which the gateway
+		// reads from and writes to its peers.
+		SetRateLimits(GatewayRateLimits) error
+
+		// PeerFilter returns the blacklist and whitelist that restrict the
+		// gateway's peers.
+		PeerFilter() GatewayPeerFilter
+
+		// SetPeerFilter replaces the blacklist and whitelist that restrict
+		// the gateway's peers, disconnecting from peers that are no longer
+		// allowed.
+		SetPeerFilter(GatewayPeerFilter) error
+
+		// PeerLimits returns the limits on the number of peers that the
+		// gateway connects to.
+		PeerLimits() GatewayPeerLimits
+
+		// SetPeerLimits changes the limits on the number of peers that the
+		// gateway connects to.
+		SetPeerLimits(GatewayPeerLimits) error
+
+		// RPCTimeouts returns the deadlines that the gateway enforces on
+		// RPCs.
+		RPCTimeouts() GatewayRPCTimeouts
+
+		// SetRPCTimeouts changes the deadlines that the gateway enforces on
+		// RPCs. Peers that miss the deadlines are penalized.
+		SetRPCTimeouts(GatewayRPCTimeouts) error
+
+		// AnchorPeers returns the peers that the gateway always stays
+		// connected to.
+		AnchorPeers() []NetAddress
+
+		// SetAnchorPeers replaces the peers that the gateway always stays
+		// connected to. Anchor peers are reconnected to aggressively and are
+		// never disconnected to make room for other peers.
+		SetAnchorPeers([]NetAddress) error
+
+		// Offline returns true if the gateway has been taken off the
+		// network.
+		Offline() bool
+
+		// SetOffline takes the gateway off the network, flushing the RPCs in
+		// progress and disconnecting from every peer, or brings it back
+		// online. The other modules keep running while the gateway is
+		// offline.
+		SetOffline(bool) error
+
+		// ReportMisbehavior adds to the ban score of a peer for the given
+		// kind of misbehavior, disconnecting and temporarily banning the
+		// peer if its score crosses the ban threshold.
+		ReportMisbehavior(addr NetAddress, kind string)
+
+		// BannedPeers returns the peers that are currently banned.
+		BannedPeers() []BannedPeer
+
 		// RegisterRPC registers a function to handle incoming connections that
 		// supply the given RPC ID.
 		RegisterRPC(string, RPCFunc)
@@ -126,6 +408,14 @@ type (
 		// removed with UnregisterRPC. If the RPC does not exist no action is taken.
 		UnregisterConnectCall(string)
 
+		// RegisterCapability adds a capability to those shared with peers.
+		// Connected peers are sent the new capabilities.
+		RegisterCapability(uint64)
+
+		// UnregisterCapability removes a capability from those shared with
+		// peers. Connected peers are sent the new capabilities.
+		UnregisterCapability(uint64)
+
 		// RPC calls an RPC on the given address. RPC cannot be called on an
 		// address that the Gateway is not connected to.
 		RPC(NetAddress, string, RPCFunc) error
@@ -138,3 +428,13 @@ type (
 		Close() error
 	}
 )
+
+// Supports returns true if the peer supports the capability. A peer that has
+// not shared its capabilities is assumed to support the capability if it runs
+// at least minVersion, the first version that had it.
+func (p Peer) Supports(capability uint64, minVersion string) bool {
+	if p.SharedCapabilities {
+		return p.Capabilities&capability != 0
+	}
+	return build.VersionCmp(p.Version, minVersion) >= 0
+}
@@ -0,0 +1,154 @@
+package gateway
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// Anchor peers are peers that the operator wants the gateway to stay
+// connected to at all times, such as the other nodes of a fleet of hosts. The
+// gateway reconnects to an anchor peer soon after losing its connection,
+// admits inbound anchor peers even when it is full, and never disconnects
+// from an anchor peer to make room for another peer or to replace a slow one.
+// Anchor peers are still subject to the blacklist and to bans.
+
+// validateAnchor checks that addr can be used as an anchor peer.
+func (g *Gateway) validateAnchor(addr modules.NetAddress) error {
+	if err := addr.IsStdValid(); err != nil {
+		return errors.New("anchor peer address is not valid: " + string(addr))
+	}
+	if net.ParseIP(addr.Host()) == nil && !(addr.IsOnion() && g.proxied()) {
+		return errors.New("anchor peer address must be an IP address: " + string(addr))
+	}
+	if addr == g.myAddr {
+		return errOurAddress
+	}
+	return nil
+}
+
+// anchorList returns the anchor peers as a slice.
+func (g *Gateway) anchorList() []modules.NetAddress {
+	addrs := make([]modules.NetAddress, 0, len(g.anchors))
+	for addr := range g.anchors {
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
+// isAnchor returns true if addr is an anchor peer.
+func (g *Gateway) isAnchor(addr modules.NetAddress) bool {
+	_, anchor := g.anchors[addr]
+	return anchor
+}
+
+// AnchorPeers returns the peers that the gateway always stays connected to.
+func (g *Gateway) AnchorPeers() []modules.NetAddress {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.anchorList()
+}
+
+// SetAnchorPeers replaces the peers that the gateway always stays connected
+// to. The gateway starts connecting to new anchor peers immediately, and
+// former anchor peers become ordinary peers. The list is saved to disk.
+func (g *Gateway) SetAnchorPeers(addrs []modules.NetAddress) error {
+	if err := g.threads.Add(); err != nil {
+		return err
+	}
+	defer g.threads.Done()
+
+	g.mu.Lock()
+	anchors := make(map[modules.NetAddress]struct{}, len(addrs))
+	for _, addr := range addrs {
+		if err := g.validateAnchor(addr); err != nil {
+			g.mu.Unlock()
+			return err
+		}
+		anchors[addr] = struct{}{}
+	}
+	g.anchors = anchors
+	err := g.saveSettings()
+	g.mu.Unlock()
+
+	// Wake the anchor manager.
+	select {
+	case g.anchorWake <- struct{}{}:
+	default:
+	}
+	return err
+}
+
+// disconnectedAnchors returns the anchor peers that the gateway is not
+// connected to. No anchor peers are returned while the gateway is offline.
+func (g *Gateway) disconnectedAnchors() []modules.NetAddress {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if g.offline {
+		return nil
+	}
+	var addrs []modules.NetAddress
+	for addr := range g.anchors {
+		if _, connected := g.peers[addr]; !connected {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// managedConnectAnchor connects to the anchor peer at addr.
+func (g *Gateway) managedConnectAnchor(addr modules.NetAddress) {
+	err := g.managedConnect(addr)
+	if err == nil {
+		g.log.Debugf("INFO: [PAM] [%v] connected to anchor peer", addr)
+	} else if err != errPeerExists {
+		g.log.Debugf("INFO: [PAM] [%v] could not connect to anchor peer: %v", addr, err)
+	}
+}
+
+// permanentAnchorManager is a thread that runs throughout the lifetime of the
+// gateway, reconnecting to anchor peers that the gateway has lost its
+// connection to. Anchor peers are retried far more often than the peer
+// manager looks for new peers.
+func (g *Gateway) permanentAnchorManager(closeChan chan struct{}) {
+	defer close(closeChan)
+
+	// Connection attempts are made in parallel, so that an unreachable
+	// anchor peer does not hold up the others. connecting tracks the
+	// attempts in progress, so that a slow attempt is not duplicated.
+	var mu sync.Mutex
+	connecting := make(map[modules.NetAddress]struct{})
+	for {
+		for _, addr := range g.disconnectedAnchors() {
+			mu.Lock()
+			_, inProgress := connecting[addr]
+			connecting[addr] = struct{}{}
+			mu.Unlock()
+			if inProgress {
+				continue
+			}
+			go func(addr modules.NetAddress) {
+				defer func() {
+					mu.Lock()
+					delete(connecting, addr)
+					mu.Unlock()
+				}()
+				if g.threads.Add() != nil {
+					return
+				}
+				defer g.threads.Done()
+				g.managedConnectAnchor(addr)
+			}(addr)
+		}
+
+		select {
+		case <-g.anchorWake:
+		case <-g.threads.StopChan():
+			return
+		case <-time.After(anchorReconnectInterval):
+		}
+	}
+}
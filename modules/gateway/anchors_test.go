@@ -0,0 +1,87 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestAnchorReconnect checks that the gateway reconnects to an anchor peer
+// after losing its connection to it.
+func TestAnchorReconnect(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	g1 := newNamedTestingGateway(t, "1")
+	defer g1.Close()
+	g2 := newNamedTestingGateway(t, "2")
+	defer g2.Close()
+
+	if err := g1.SetAnchorPeers([]modules.NetAddress{g2.Address()}); err != nil {
+		t.Fatal(err)
+	}
+	connected := func() bool {
+		g1.mu.RLock()
+		defer g1.mu.RUnlock()
+		_, exists := g1.peers[g2.Address()]
+		return exists
+	}
+	if !waitFor(connected) {
+		t.Fatal("gateway did not connect to its anchor peer")
+	}
+
+	if err := g1.Disconnect(g2.Address()); err != nil {
+		t.Fatal(err)
+	}
+	if !waitFor(connected) {
+		t.Fatal("gateway did not reconnect to its anchor peer")
+	}
+
+	// Anchor peers are never kicked, even when they are inbound.
+	var p *peer
+	inbound := func() bool {
+		g2.mu.RLock()
+		defer g2.mu.RUnlock()
+		p = g2.peers[g1.Address()]
+		return p != nil
+	}
+	if !waitFor(inbound) {
+		t.Fatal("g2 does not have g1 as a peer")
+	}
+	g2.mu.Lock()
+	g2.anchors[g1.Address()] = struct{}{}
+	kickable := g2.kickable(p)
+	g2.mu.Unlock()
+	if kickable {
+		t.Fatal("inbound anchor peer is kickable")
+	}
+}
+
+// TestAnchorPeersPersist checks that the gateway's anchor peers persist across
+// restarts, and that invalid anchor peers are rejected.
+func TestAnchorPeersPersist(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	g := newTestingGateway(t)
+	anchor := modules.NetAddress("203.0.113.7:9981")
+	if err := g.SetAnchorPeers([]modules.NetAddress{anchor}); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.SetAnchorPeers([]modules.NetAddress{"not-an-address"}); err == nil {
+		t.Fatal("expected an error when setting an invalid anchor peer")
+	}
+	if err := g.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := New("localhost:0", false, g.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	if anchors := g.AnchorPeers(); len(anchors) != 1 || anchors[0] != anchor {
+		t.Fatal("anchor peers did not persist across restart:", anchors)
+	}
+}
@@ -0,0 +1,154 @@
+package gateway
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// The gateway keeps a ban score for each peer that has misbehaved. Modules
+// report protocol violations with ReportMisbehavior, and the gateway itself
+// reports peers that send objects that cannot be decoded during an RPC.
+// Unknown RPC IDs are not penalized, as newer peers legitimately call RPCs
+// that older peers do not know. Each kind of misbehavior adds a fixed penalty
+// to the score, and the score decays by half every banScoreHalfLife, so that
+// occasional glitches are forgiven while sustained misbehavior is not. When a
+// score reaches banThreshold, the peer is disconnected and the gateway
+// refuses to connect to or accept it until banDuration has passed. Ban scores
+// are kept in memory, but bans of nodes in the node list are saved with the
+// node's ban history and survive a restart.
+
+var (
+	// errPeerBanned is returned when connecting to a peer that is banned.
+	errPeerBanned = errors.New("peer is temporarily banned for misbehaving")
+
+	// misbehaviorPenalties is the amount that each kind of misbehavior adds
+	// to a peer's ban score.
+	misbehaviorPenalties = map[string]float64{
+		modules.MisbehaviorInvalidBlock: 60,
+		modules.MisbehaviorMalformedRPC: 30,
+		modules.MisbehaviorRPCTimeout:   10,
+		modules.MisbehaviorStalledSend:  10,
+	}
+)
+
+// banScore is the decaying misbehavior score of a peer.
+type banScore struct {
+	score   float64
+	updated time.Time
+}
+
+// at returns the value of the score at the given time.
+func (bs banScore) at(now time.Time) float64 {
+	halfLives := float64(now.Sub(bs.updated)) / float64(banScoreHalfLife)
+	return bs.score * math.Pow(0.5, halfLives)
+}
+
+// isMalformedErr returns true if err indicates that an object sent by a peer
+// could not be decoded. The encoding package does not export its decoding
+// errors, so the message is inspected instead.
+func isMalformedErr(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "could not decode type") || strings.Contains(msg, "exceeds maxLen")
+}
+
+// banned returns true if the peer at addr is currently banned. Expired bans
+// are ignored, and are cleaned up by ReportMisbehavior and BannedPeers.
+func (g *Gateway) banned(addr modules.NetAddress) bool {
+	ban, exists := g.bans[addr]
+	return exists && time.Now().Before(ban.Until)
+}
+
+// peerBanScore returns the current ban score of the peer at addr.
+func (g *Gateway) peerBanScore(addr modules.NetAddress) float64 {
+	bs, exists := g.banScores[addr]
+	if !exists {
+		return 0
+	}
+	return bs.at(time.Now())
+}
+
+// pruneBans removes expired bans and scores that have decayed to nothing.
+func (g *Gateway) pruneBans(now time.Time) {
+	for addr, ban := range g.bans {
+		if !now.Before(ban.Until) {
+			delete(g.bans, addr)
+		}
+	}
+	for addr, bs := range g.banScores {
+		if bs.at(now) < 1 {
+			delete(g.banScores, addr)
+		}
+	}
+}
+
+// ReportMisbehavior adds the penalty for the given kind of misbehavior to the
+// ban score of the peer at addr. If the score reaches banThreshold, the peer
+// is disconnected and banned for banDuration.
+func (g *Gateway) ReportMisbehavior(addr modules.NetAddress, kind string) {
+	penalty, exists := misbehaviorPenalties[kind]
+	if !exists {
+		build.Critical("unknown kind of peer misbehavior reported: " + kind)
+		return
+	}
+	if g.threads.Add() != nil {
+		return
+	}
+	defer g.threads.Done()
+
+	now := time.Now()
+	g.mu.Lock()
+	g.pruneBans(now)
+	bs := banScore{score: g.peerBanScore(addr) + penalty, updated: now}
+	g.log.Debugf("INFO: peer %v misbehaved (%v), ban score is now %.0f", addr, kind, bs.score)
+	if bs.score < banThreshold {
+		g.banScores[addr] = bs
+		g.mu.Unlock()
+		return
+	}
+	delete(g.banScores, addr)
+	g.bans[addr] = modules.BannedPeer{
+		NetAddress: addr,
+		Reason:     kind,
+		Until:      now.Add(banDuration),
+	}
+	g.recordBan(addr, kind, now)
+	if err := g.save(); err != nil {
+		g.log.Println("WARN: failed to save node list after banning a peer:", err)
+	}
+	p, connected := g.peers[addr]
+	delete(g.peers, addr)
+	g.mu.Unlock()
+
+	g.log.Printf("INFO: banned peer %v until %v after misbehaving (%v)", addr, now.Add(banDuration).Format(time.RFC3339), kind)
+	if connected {
+		if err := p.sess.Close(); err != nil {
+			g.log.Debugf("WARN: error disconnecting from banned peer %q: %v", addr, err)
+		}
+	}
+}
+
+// BannedPeers returns the peers that are currently banned, sorted by address.
+func (g *Gateway) BannedPeers() []modules.BannedPeer {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.pruneBans(time.Now())
+	bans := make([]modules.BannedPeer, 0, len(g.bans))
+	for _, ban := range g.bans {
+		bans = append(bans, ban)
+	}
+	sort.Sort(bannedPeersByAddress(bans))
+	return bans
+}
+
+// bannedPeersByAddress sorts a slice of BannedPeers by NetAddress.
+type bannedPeersByAddress []modules.BannedPeer
+
+func (bp bannedPeersByAddress) Len() int           { return len(bp) }
+func (bp bannedPeersByAddress) Less(i, j int) bool { return bp[i].NetAddress < bp[j].NetAddress }
+func (bp bannedPeersByAddress) Swap(i, j int)      { bp[i], bp[j] = bp[j], bp[i] }
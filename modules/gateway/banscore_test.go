@@ -0,0 +1,93 @@
+package gateway
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestBanScoreDecay checks that ban scores halve every banScoreHalfLife.
+func TestBanScoreDecay(t *testing.T) {
+	now := time.Now()
+	bs := banScore{score: 80, updated: now}
+	if score := bs.at(now); score != 80 {
+		t.Fatal("score decayed without time passing:", score)
+	}
+	if score := bs.at(now.Add(banScoreHalfLife)); score < 39.9 || score > 40.1 {
+		t.Fatal("score did not halve after one half-life:", score)
+	}
+	if score := bs.at(now.Add(2 * banScoreHalfLife)); score < 19.9 || score > 20.1 {
+		t.Fatal("score did not quarter after two half-lives:", score)
+	}
+}
+
+// TestIsMalformedErr checks that decoding errors are recognized.
+func TestIsMalformedErr(t *testing.T) {
+	if !isMalformedErr(errors.New("could not decode type types.Block: unexpected EOF")) {
+		t.Error("decoding error was not recognized")
+	}
+	if !isMalformedErr(errors.New("length 9999999 exceeds maxLen of 1000")) {
+		t.Error("oversized object was not recognized")
+	}
+	if isMalformedErr(errors.New("Read timeout")) {
+		t.Error("timeout was treated as a malformed RPC")
+	}
+}
+
+// TestReportMisbehavior checks that a peer whose ban score crosses the
+// threshold is disconnected and banned until the ban expires.
+func TestReportMisbehavior(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	g1 := newNamedTestingGateway(t, "1")
+	defer g1.Close()
+	g2 := newNamedTestingGateway(t, "2")
+	defer g2.Close()
+	if err := g1.Connect(g2.Address()); err != nil {
+		t.Fatal(err)
+	}
+
+	// A single report raises the score without banning the peer.
+	g1.ReportMisbehavior(g2.Address(), modules.MisbehaviorInvalidBlock)
+	peers := g1.Peers()
+	if len(peers) != 1 || peers[0].BanScore < 55 || peers[0].BanScore > 60 {
+		t.Fatal("ban score was not reported:", peers)
+	}
+
+	// A second report crosses the threshold.
+	g1.ReportMisbehavior(g2.Address(), modules.MisbehaviorInvalidBlock)
+	if len(g1.Peers()) != 0 {
+		t.Fatal("banned peer was not disconnected")
+	}
+	bans := g1.BannedPeers()
+	if len(bans) != 1 || bans[0].NetAddress != g2.Address() || bans[0].Reason != modules.MisbehaviorInvalidBlock {
+		t.Fatal("ban was not recorded:", bans)
+	}
+	if err := g1.Connect(g2.Address()); err != errPeerBanned {
+		t.Fatal("expected errPeerBanned, got", err)
+	}
+	for i := 0; i < 50 && len(g2.Peers()) != 0; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	// The banned peer is refused once it supplies its dialback address,
+	// which happens after it considers itself connected.
+	g2.Connect(g1.Address())
+	time.Sleep(200 * time.Millisecond)
+	if len(g1.Peers()) != 0 {
+		t.Fatal("g1 accepted the banned peer")
+	}
+
+	// The ban expires.
+	time.Sleep(banDuration)
+	if len(g1.BannedPeers()) != 0 {
+		t.Fatal("ban did not expire")
+	}
+	// g2 may have already reconnected on its own.
+	if err := g1.Connect(g2.Address()); err != nil && err != errPeerExists {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,139 @@
+package gateway
+
+import (
+	"time"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// Upon connecting, a gateway calls the Capabilities RPC on its peer, and the
+// two gateways exchange the capability bits of the optional RPCs that they
+// support. The peer sends its capabilities first, so that peers that predate
+// the exchange, which close the stream after sending theirs, still share
+// them. Other modules register the capabilities of their RPCs with
+// RegisterCapability, and check whether a peer supports them with
+// modules.Peer.Supports, which falls back on the peer's version for peers
+// that never share their capabilities. When the capabilities of the gateway
+// change, they are exchanged again with every peer.
+
+// capabilities returns the capabilities that the gateway shares with its
+// peers.
+func (g *Gateway) capabilities() uint64 {
+	caps := g.registeredCapabilities | modules.CapabilityPing
+	if g.quicListener != nil {
+		caps |= modules.CapabilityQUIC
+	}
+	return caps
+}
+
+// recordCapabilities records the capabilities shared by the peer at addr, in
+// the peer list and in the peer's node list entry.
+func (g *Gateway) recordCapabilities(addr modules.NetAddress, caps uint64) error {
+	if p, exists := g.peers[addr]; exists {
+		p.Capabilities = caps
+		p.SharedCapabilities = true
+	}
+	n, exists := g.nodes[addr]
+	if !exists || n.QUIC == (caps&modules.CapabilityQUIC != 0) {
+		return nil
+	}
+	n.QUIC = caps&modules.CapabilityQUIC != 0
+	return g.save()
+}
+
+// shareCapabilities is the RPC that sends the gateway's capabilities to a
+// peer, and records the capabilities that the peer sends in return.
+func (g *Gateway) shareCapabilities(conn modules.PeerConn) error {
+	conn.SetDeadline(time.Now().Add(connStdDeadline))
+	g.mu.RLock()
+	caps := g.capabilities()
+	g.mu.RUnlock()
+	if err := encoding.WriteObject(conn, caps); err != nil {
+		return err
+	}
+
+	var remoteCaps uint64
+	if err := encoding.ReadObject(conn, &remoteCaps, 8); err != nil {
+		// Peers that predate the exchange do not send their capabilities.
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.recordCapabilities(conn.RPCAddr(), remoteCaps)
+}
+
+// requestCapabilities is the RPC that learns the capabilities of a peer and
+// sends the gateway's capabilities in return. It is called upon connecting to
+// a peer, and whenever the gateway's capabilities change.
+func (g *Gateway) requestCapabilities(conn modules.PeerConn) error {
+	conn.SetDeadline(time.Now().Add(connStdDeadline))
+	var remoteCaps uint64
+	if err := encoding.ReadObject(conn, &remoteCaps, 8); err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	err := g.recordCapabilities(conn.RPCAddr(), remoteCaps)
+	caps := g.capabilities()
+	g.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	// Peers that predate the exchange have closed the stream, so the error
+	// is ignored.
+	encoding.WriteObject(conn, caps)
+	return nil
+}
+
+// threadedShareCapabilities exchanges capabilities with every peer, so that
+// they learn of a change in the gateway's capabilities.
+func (g *Gateway) threadedShareCapabilities() {
+	if g.threads.Add() != nil {
+		return
+	}
+	defer g.threads.Done()
+
+	g.mu.RLock()
+	addrs := make([]modules.NetAddress, 0, len(g.peers))
+	for addr := range g.peers {
+		addrs = append(addrs, addr)
+	}
+	g.mu.RUnlock()
+	for _, addr := range addrs {
+		go func(addr modules.NetAddress) {
+			if g.threads.Add() != nil {
+				return
+			}
+			defer g.threads.Done()
+			if err := g.managedRPC(addr, "Capabilities", g.requestCapabilities); err != nil {
+				g.log.Debugf("INFO: could not share capabilities with %v: %v", addr, err)
+			}
+		}(addr)
+	}
+}
+
+// RegisterCapability adds a capability to those that the gateway shares with
+// its peers. Modules register the capabilities of their optional RPCs along
+// with the RPCs.
+func (g *Gateway) RegisterCapability(capability uint64) {
+	g.mu.Lock()
+	changed := g.registeredCapabilities&capability != capability
+	g.registeredCapabilities |= capability
+	g.mu.Unlock()
+	if changed {
+		go g.threadedShareCapabilities()
+	}
+}
+
+// UnregisterCapability removes a capability from those that the gateway
+// shares with its peers.
+func (g *Gateway) UnregisterCapability(capability uint64) {
+	g.mu.Lock()
+	changed := g.registeredCapabilities&capability != 0
+	g.registeredCapabilities &^= capability
+	g.mu.Unlock()
+	if changed {
+		go g.threadedShareCapabilities()
+	}
+}
@@ -0,0 +1,81 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestCapabilityExchange checks that connected gateways learn each other's
+// capabilities, and learn of changes to them.
+func TestCapabilityExchange(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	g1 := newNamedTestingGateway(t, "1")
+	defer g1.Close()
+	g2 := newNamedTestingGateway(t, "2")
+	defer g2.Close()
+
+	const capFoo uint64 = 1 << 40
+	g2.RegisterCapability(capFoo)
+	if err := g1.Connect(g2.Address()); err != nil {
+		t.Fatal(err)
+	}
+
+	// capabilitiesOf returns the capabilities that g shares with addr.
+	capabilitiesOf := func(g *Gateway, addr modules.NetAddress) (uint64, bool) {
+		for _, p := range g.Peers() {
+			if p.NetAddress == addr {
+				return p.Capabilities, p.SharedCapabilities
+			}
+		}
+		return 0, false
+	}
+	// Both the caller and the callee of the exchange learn the capabilities
+	// of the other.
+	exchanged := waitFor(func() bool {
+		caps1, shared1 := capabilitiesOf(g1, g2.Address())
+		caps2, shared2 := capabilitiesOf(g2, g1.Address())
+		return shared1 && shared2 && caps1 == capFoo|modules.CapabilityPing && caps2 == modules.CapabilityPing
+	})
+	if !exchanged {
+		t.Fatal("gateways did not exchange capabilities:", g1.Peers(), g2.Peers())
+	}
+
+	// Peers are told when the capabilities change.
+	g1.RegisterCapability(capFoo)
+	g2.UnregisterCapability(capFoo)
+	updated := waitFor(func() bool {
+		caps1, _ := capabilitiesOf(g1, g2.Address())
+		caps2, _ := capabilitiesOf(g2, g1.Address())
+		return caps1&capFoo == 0 && caps2&capFoo != 0
+	})
+	if !updated {
+		t.Fatal("peers did not learn of the changed capabilities:", g1.Peers(), g2.Peers())
+	}
+}
+
+// TestPeerSupports checks that peers that have not shared their capabilities
+// are judged by their version.
+func TestPeerSupports(t *testing.T) {
+	p := modules.Peer{Version: build.Version}
+	if !p.Supports(modules.CapabilityPing, latencyUpgradeVersion) {
+		t.Fatal("current peer that has not shared its capabilities should be assumed to support Ping")
+	}
+	p.Version = "1.1.2"
+	if p.Supports(modules.CapabilityPing, latencyUpgradeVersion) {
+		t.Fatal("old peer should not support Ping")
+	}
+	p.Version = build.Version
+	p.SharedCapabilities = true
+	if p.Supports(modules.CapabilityPing, latencyUpgradeVersion) {
+		t.Fatal("peer that did not share the Ping capability should not support it")
+	}
+	p.Capabilities = modules.CapabilityPing
+	if !p.Supports(modules.CapabilityPing, latencyUpgradeVersion) {
+		t.Fatal("peer that shared the Ping capability should support it")
+	}
+}
@@ -23,6 +23,17 @@ func (pc peerConn) RPCAddr() modules.NetAddress {
 // handles things like clean shutdown, fast shutdown, and chooses the correct
 // communication protocol.
 func (g *Gateway) dial(addr modules.NetAddress) (net.Conn, error) {
+	// Peers outside of the local network are dialed through the proxy, if
+	// the gateway has one.
+	if g.proxied() && !addr.IsLocal() {
+		conn, err := g.dialSOCKS5(addr)
+		if err != nil {
+			return nil, err
+		}
+		conn.SetDeadline(time.Now().Add(connStdDeadline))
+		return conn, nil
+	}
+
 	dialer := &net.Dialer{
 		Cancel:  g.threads.StopChan(),
 		Timeout: dialTimeout,
@@ -7,10 +7,24 @@ import (
 )
 
 const (
+	// banThreshold is the ban score at which a peer is disconnected and
+	// banned.
+	banThreshold = 100
+
+	// encryptionUpgradeVersion is the version where peer connections began
+	// to be encrypted and authenticated, see encryption.go. Peers below this
+	// version cannot perform the encryption handshake.
+	encryptionUpgradeVersion = "1.2.0"
+
 	// handshakeUpgradeVersion is the version where the gateway handshake RPC
 	// was altered to include adiitional information transfer.
 	handshakeUpgradeVersion = "1.0.0"
 
+	// latencyUpgradeVersion is the version where the Ping RPC, which the
+	// gateway uses to measure the latency of its peers, was added. It is
+	// only used for peers that do not share their capabilities.
+	latencyUpgradeVersion = "1.2.0"
+
 	// maxLocalOutbound is currently set to 3, meaning the gateway will not
 	// consider a local node to be an outbound peer if the gateway already has
 	// 3 outbound peers. Three is currently needed to handle situations where
@@ -20,12 +34,22 @@ const (
 	// connect to itself, this number can be reduced.
 	maxLocalOutboundPeers = 3
 
+	// maxNodeFailures is the number of failed connection attempts in a row
+	// after which a node is removed from the node list, provided that the
+	// node list is large enough to be pruned.
+	maxNodeFailures = 3
+
 	// minAcceptableVersion is the version below which the gateway will refuse to
 	// connect to peers and reject connection attempts.
 	//
 	// Reject peers < v0.4.0 as the previous version is v0.3.3 which is
 	// pre-hardfork.
 	minAcceptableVersion = "0.4.0"
+
+	// slowPeerLatencyFactor is how many times the median latency of the
+	// gateway's outbound peers an outbound peer's latency must be before the
+	// peer is replaced with a random node.
+	slowPeerLatencyFactor = 4
 )
 
 var (
@@ -54,6 +78,23 @@ var (
 		Testing:  uint64(3),
 	}).(uint64)
 
+	// nodeBackoffBase is how long the gateway waits before picking a node
+	// again after a failed connection attempt. The wait doubles with every
+	// further failure in a row, up to nodeBackoffMax.
+	nodeBackoffBase = build.Select(build.Var{
+		Standard: 5 * time.Minute,
+		Dev:      30 * time.Second,
+		Testing:  500 * time.Millisecond,
+	}).(time.Duration)
+
+	// nodeBackoffMax is the longest that the gateway waits before picking a
+	// node again after failed connection attempts.
+	nodeBackoffMax = build.Select(build.Var{
+		Standard: 24 * time.Hour,
+		Dev:      10 * time.Minute,
+		Testing:  5 * time.Second,
+	}).(time.Duration)
+
 	// nodePurgeDelay defines the amount of time that is waited between each
 	// iteration of the node purge loop.
 	nodePurgeDelay = build.Select(build.Var{
@@ -111,14 +152,40 @@ var (
 		Testing:  500 * time.Millisecond,
 	}).(time.Duration)
 
-	// fullyConnectedThreshold defines the number of peers that the gateway can
-	// have before it stops accepting inbound connections.
+	// anchorReconnectInterval defines the amount of time that is waited
+	// between attempts to reconnect to anchor peers that the gateway is not
+	// connected to.
+	anchorReconnectInterval = build.Select(build.Var{
+		Standard: 30 * time.Second,
+		Dev:      10 * time.Second,
+		Testing:  500 * time.Millisecond,
+	}).(time.Duration)
+
+	// fullyConnectedThreshold defines the default number of inbound peers
+	// that the gateway can have before it starts kicking inbound peers to
+	// make room for new ones.
 	fullyConnectedThreshold = build.Select(build.Var{
 		Standard: 128,
 		Dev:      20,
 		Testing:  10,
 	}).(int)
 
+	// idlePeerCheckInterval defines the amount of time that is waited between
+	// checks for peers that have gone silent.
+	idlePeerCheckInterval = build.Select(build.Var{
+		Standard: 1 * time.Minute,
+		Dev:      20 * time.Second,
+		Testing:  500 * time.Millisecond,
+	}).(time.Duration)
+
+	// latencyCheckInterval defines how often the gateway measures the
+	// round-trip time to each of its peers.
+	latencyCheckInterval = build.Select(build.Var{
+		Standard: 5 * time.Minute,
+		Dev:      1 * time.Minute,
+		Testing:  1 * time.Second,
+	}).(time.Duration)
+
 	// maxConcurrentOutboundPeerRequests defines the maximum number of peer
 	// connections that the gateway will try to form concurrently.
 	maxConcurrentOutboundPeerRequests = build.Select(build.Var{
@@ -136,6 +203,25 @@ var (
 		Testing:  3 * time.Second,
 	}).(time.Duration)
 
+	// offlineFlushTimeout is how long the gateway waits for the RPCs in
+	// progress to finish when going offline, before disconnecting from its
+	// peers anyway.
+	offlineFlushTimeout = build.Select(build.Var{
+		Standard: 1 * time.Minute,
+		Dev:      30 * time.Second,
+		Testing:  3 * time.Second,
+	}).(time.Duration)
+
+	// peerIdleTimeout defines how long a peer can go without any RPC traffic
+	// before the gateway pings it to check that the connection is still
+	// alive. Nodes that mostly listen may otherwise keep dead connections
+	// until the next broadcast fails.
+	peerIdleTimeout = build.Select(build.Var{
+		Standard: 10 * time.Minute,
+		Dev:      2 * time.Minute,
+		Testing:  5 * time.Second,
+	}).(time.Duration)
+
 	// unwawntedLocalPeerDelay defines the amount of time that is waited
 	// between iterations of the permanentPeerManager if the gateway has at
 	// least a few outbound peers, but is not well connected, and the recently
@@ -157,8 +243,8 @@ var (
 		Testing:  3 * time.Second,
 	}).(time.Duration)
 
-	// wellConnectedThreshold is the number of outbound connections at which
-	// the gateway will not attempt to make new outbound connections.
+	// wellConnectedThreshold is the default number of outbound connections at
+	// which the gateway will not attempt to make new outbound connections.
 	wellConnectedThreshold = build.Select(build.Var{
 		Standard: 8,
 		Dev:      5,
@@ -167,6 +253,22 @@ var (
 )
 
 var (
+	// banDuration is how long a peer is banned for after its ban score
+	// reaches banThreshold.
+	banDuration = build.Select(build.Var{
+		Standard: 24 * time.Hour,
+		Dev:      1 * time.Hour,
+		Testing:  3 * time.Second,
+	}).(time.Duration)
+
+	// banScoreHalfLife is the time it takes for a peer's ban score to decay
+	// to half of its value.
+	banScoreHalfLife = build.Select(build.Var{
+		Standard: 1 * time.Hour,
+		Dev:      10 * time.Minute,
+		Testing:  2 * time.Second,
+	}).(time.Duration)
+
 	// connStdDeadline defines the standard deadline that should be used for
 	// all temporary connections to the gateway.
 	connStdDeadline = build.Select(build.Var{
@@ -182,6 +284,53 @@ var (
 		Testing:  500 * time.Millisecond,
 	}).(time.Duration)
 
+	// natRequestAttempts is the number of times a NAT-PMP or PCP request is
+	// sent before giving up on the router. The timeout waiting for a response
+	// starts at natRequestTimeout and doubles after each attempt.
+	natRequestAttempts = build.Select(build.Var{
+		Standard: int(4),
+		Dev:      int(3),
+		Testing:  int(3),
+	}).(int)
+	natRequestTimeout = build.Select(build.Var{
+		Standard: 250 * time.Millisecond,
+		Dev:      250 * time.Millisecond,
+		Testing:  50 * time.Millisecond,
+	}).(time.Duration)
+
+	// portMappingLifetime is the lifetime requested for port mappings made
+	// with NAT-PMP or PCP. Mappings are renewed halfway through the lifetime
+	// granted by the router.
+	portMappingLifetime = build.Select(build.Var{
+		Standard: 2 * time.Hour,
+		Dev:      10 * time.Minute,
+		Testing:  10 * time.Second,
+	}).(time.Duration)
+
+	// portMappingRetry is the amount of time waited before retrying a failed
+	// NAT-PMP or PCP port mapping.
+	portMappingRetry = build.Select(build.Var{
+		Standard: 5 * time.Minute,
+		Dev:      1 * time.Minute,
+		Testing:  500 * time.Millisecond,
+	}).(time.Duration)
+
+	// pingTimeout defines how long the gateway will wait for an idle peer to
+	// respond to a ping before considering the peer unresponsive.
+	pingTimeout = build.Select(build.Var{
+		Standard: 1 * time.Minute,
+		Dev:      20 * time.Second,
+		Testing:  2 * time.Second,
+	}).(time.Duration)
+
+	// rpcIOTimeout defines the default amount of time that a single read from
+	// or write to a peer may block during an RPC.
+	rpcIOTimeout = build.Select(build.Var{
+		Standard: 2 * time.Minute,
+		Dev:      1 * time.Minute,
+		Testing:  30 * time.Second,
+	}).(time.Duration)
+
 	// rpcStdDeadline defines the standard deadline that should be used for all
 	// incoming RPC calls.
 	rpcStdDeadline = build.Select(build.Var{
@@ -189,4 +338,12 @@ var (
 		Dev:      5 * time.Minute,
 		Testing:  90 * time.Second,
 	}).(time.Duration)
+
+	// slowPeerMinLatency is the latency below which an outbound peer is never
+	// considered slow, however fast the gateway's other peers are.
+	slowPeerMinLatency = build.Select(build.Var{
+		Standard: 500 * time.Millisecond,
+		Dev:      200 * time.Millisecond,
+		Testing:  50 * time.Millisecond,
+	}).(time.Duration)
 )
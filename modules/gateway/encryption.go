@@ -0,0 +1,338 @@
+package gateway
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/fastrand"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+)
+
+// Peer connections with gateways at or above encryptionUpgradeVersion are
+// encrypted and authenticated. After the version handshake, both sides send an
+// ephemeral X25519 key and derive a pair of ChaCha20-Poly1305 keys, one for
+// each direction, from the shared secret. Every byte sent after that point,
+// including the port handshake and the muxado session, travels in
+// authenticated frames. Each side then proves its identity by signing the
+// handshake transcript with its long-term ed25519 key. Because the transcript
+// includes both ephemeral keys, a signature cannot be replayed on another
+// connection, and an on-path attacker that substitutes its own ephemeral key
+// cannot produce a valid signature for the key that the remote expects.
+//
+// The identity key of each node is recorded in the node list the first time
+// the gateway connects to it, and later connections to the same address are
+// refused if the node presents a different key.
+
+const (
+	// identityFile is the name of the file that contains the gateway's
+	// long-term identity key.
+	identityFile = "identity.json"
+
+	// encryptedFrameSize is the largest amount of plaintext that is sealed
+	// in a single frame of an encrypted connection.
+	encryptedFrameSize = 1 << 16
+)
+
+var (
+	// identityMetadata contains the header and version strings that identify
+	// the gateway identity file.
+	identityMetadata = persist.Metadata{
+		Header:  "Gateway Identity",
+		Version: "1.3.0",
+	}
+
+	// Specifiers that distinguish the two sides of a handshake, so that
+	// neither the derived keys nor the identity signatures of one side can be
+	// reflected back as those of the other.
+	specifierInitiator = types.Specifier{'i', 'n', 'i', 't', 'i', 'a', 't', 'o', 'r'}
+	specifierResponder = types.Specifier{'r', 'e', 's', 'p', 'o', 'n', 'd', 'e', 'r'}
+
+	errBadFrame         = errors.New("encrypted frame could not be authenticated")
+	errFrameTooLarge    = errors.New("encrypted frame exceeds the maximum size")
+	errInvalidEphemeral = errors.New("peer sent an invalid ephemeral key")
+	errPeerKeyMismatch  = errors.New("peer presented a different identity key than it has before")
+)
+
+type (
+	// identity is the long-term key that the gateway proves its identity
+	// with during the encryption handshake.
+	identity struct {
+		SecretKey crypto.SecretKey `json:"secretkey"`
+		PublicKey crypto.PublicKey `json:"publickey"`
+	}
+
+	// identityProof is sent by each side of the encryption handshake to prove
+	// that it holds the secret key of the identity it claims.
+	identityProof struct {
+		PublicKey crypto.PublicKey
+		Signature crypto.Signature
+	}
+
+	// encryptedConn is a net.Conn that seals everything written to it in
+	// ChaCha20-Poly1305 frames and authenticates everything read from it.
+	// Each frame is prefixed by the length of its ciphertext, and frames are
+	// numbered by a counter that serves as their nonce. The two directions of
+	// a connection use different keys, so their nonces never collide.
+	encryptedConn struct {
+		net.Conn
+
+		readMu      sync.Mutex
+		readAEAD    cipher.AEAD
+		readCounter uint64
+		readBuf     []byte
+
+		writeMu      sync.Mutex
+		writeAEAD    cipher.AEAD
+		writeCounter uint64
+	}
+)
+
+// nonce returns the nonce of the frame with the given counter.
+func (ec *encryptedConn) nonce(counter uint64) []byte {
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	binary.LittleEndian.PutUint64(nonce, counter)
+	return nonce
+}
+
+// Read implements the io.Reader interface.
+func (ec *encryptedConn) Read(b []byte) (int, error) {
+	ec.readMu.Lock()
+	defer ec.readMu.Unlock()
+	for len(ec.readBuf) == 0 {
+		var prefix [4]byte
+		if _, err := io.ReadFull(ec.Conn, prefix[:]); err != nil {
+			return 0, err
+		}
+		n := binary.LittleEndian.Uint32(prefix[:])
+		if n > encryptedFrameSize+uint32(ec.readAEAD.Overhead()) {
+			return 0, errFrameTooLarge
+		}
+		frame := make([]byte, n)
+		if _, err := io.ReadFull(ec.Conn, frame); err != nil {
+			return 0, err
+		}
+		plaintext, err := ec.readAEAD.Open(frame[:0], ec.nonce(ec.readCounter), frame, nil)
+		if err != nil {
+			return 0, errBadFrame
+		}
+		ec.readCounter++
+		ec.readBuf = plaintext
+	}
+	n := copy(b, ec.readBuf)
+	ec.readBuf = ec.readBuf[n:]
+	return n, nil
+}
+
+// Write implements the io.Writer interface.
+func (ec *encryptedConn) Write(b []byte) (int, error) {
+	ec.writeMu.Lock()
+	defer ec.writeMu.Unlock()
+	var written int
+	for len(b) > 0 {
+		chunk := b
+		if len(chunk) > encryptedFrameSize {
+			chunk = chunk[:encryptedFrameSize]
+		}
+		frame := make([]byte, 4, 4+len(chunk)+ec.writeAEAD.Overhead())
+		frame = ec.writeAEAD.Seal(frame, ec.nonce(ec.writeCounter), chunk, nil)
+		binary.LittleEndian.PutUint32(frame, uint32(len(frame)-4))
+		if _, err := ec.Conn.Write(frame); err != nil {
+			return written, err
+		}
+		ec.writeCounter++
+		written += len(chunk)
+		b = b[len(chunk):]
+	}
+	return written, nil
+}
+
+// handshakeKeys derives the keys that the initiator and the responder of a
+// handshake encrypt their frames with.
+func handshakeKeys(shared, initiatorEphemeral, responderEphemeral []byte) (initiatorKey, responderKey crypto.Hash) {
+	initiatorKey = crypto.HashAll(specifierInitiator, shared, initiatorEphemeral, responderEphemeral)
+	responderKey = crypto.HashAll(specifierResponder, shared, initiatorEphemeral, responderEphemeral)
+	return
+}
+
+// identitySigHash returns the hash that a side of a handshake signs to prove
+// its identity.
+func identitySigHash(side types.Specifier, initiatorEphemeral, responderEphemeral []byte) crypto.Hash {
+	return crypto.HashAll(side, initiatorEphemeral, responderEphemeral)
+}
+
+// encryptionHandshake performs the encryption handshake on conn, returning
+// an encrypted connection and the verified identity key of the remote peer.
+// initiator should be true on the side that made the connection request.
+func encryptionHandshake(conn net.Conn, id identity, initiator bool) (net.Conn, types.SiaPublicKey, error) {
+	// Exchange ephemeral keys. The initiator sends its key first.
+	var ephemeralSecret [32]byte
+	fastrand.Read(ephemeralSecret[:])
+	ephemeralPublic, err := curve25519.X25519(ephemeralSecret[:], curve25519.Basepoint)
+	if err != nil {
+		return nil, types.SiaPublicKey{}, err
+	}
+	var remoteEphemeral []byte
+	if initiator {
+		err = encoding.WriteObject(conn, ephemeralPublic)
+		if err == nil {
+			err = encoding.ReadObject(conn, &remoteEphemeral, 64)
+		}
+	} else {
+		err = encoding.ReadObject(conn, &remoteEphemeral, 64)
+		if err == nil {
+			err = encoding.WriteObject(conn, ephemeralPublic)
+		}
+	}
+	if err != nil {
+		return nil, types.SiaPublicKey{}, fmt.Errorf("failed to exchange ephemeral keys: %v", err)
+	}
+	if len(remoteEphemeral) != 32 {
+		return nil, types.SiaPublicKey{}, errInvalidEphemeral
+	}
+	shared, err := curve25519.X25519(ephemeralSecret[:], remoteEphemeral)
+	if err != nil {
+		// X25519 rejects low order points, which would make the shared
+		// secret predictable.
+		return nil, types.SiaPublicKey{}, errInvalidEphemeral
+	}
+
+	// Derive the frame keys.
+	initiatorEphemeral, responderEphemeral := ephemeralPublic, remoteEphemeral
+	ourSide, theirSide := specifierInitiator, specifierResponder
+	if !initiator {
+		initiatorEphemeral, responderEphemeral = remoteEphemeral, ephemeralPublic
+		ourSide, theirSide = specifierResponder, specifierInitiator
+	}
+	initiatorKey, responderKey := handshakeKeys(shared, initiatorEphemeral, responderEphemeral)
+	writeKey, readKey := initiatorKey, responderKey
+	if !initiator {
+		writeKey, readKey = responderKey, initiatorKey
+	}
+	// NOTE: chacha20poly1305.New only returns an error if the key is not 32
+	// bytes.
+	writeAEAD, _ := chacha20poly1305.New(writeKey[:])
+	readAEAD, _ := chacha20poly1305.New(readKey[:])
+	ec := &encryptedConn{
+		Conn:      conn,
+		readAEAD:  readAEAD,
+		writeAEAD: writeAEAD,
+	}
+
+	// Exchange identity proofs over the encrypted connection.
+	ourProof := identityProof{
+		PublicKey: id.PublicKey,
+		Signature: crypto.SignHash(identitySigHash(ourSide, initiatorEphemeral, responderEphemeral), id.SecretKey),
+	}
+	var theirProof identityProof
+	if initiator {
+		err = encoding.WriteObject(ec, ourProof)
+		if err == nil {
+			err = encoding.ReadObject(ec, &theirProof, 256)
+		}
+	} else {
+		err = encoding.ReadObject(ec, &theirProof, 256)
+		if err == nil {
+			err = encoding.WriteObject(ec, ourProof)
+		}
+	}
+	if err != nil {
+		return nil, types.SiaPublicKey{}, fmt.Errorf("failed to exchange identities: %v", err)
+	}
+	err = crypto.VerifyHash(identitySigHash(theirSide, initiatorEphemeral, responderEphemeral), theirProof.PublicKey, theirProof.Signature)
+	if err != nil {
+		return nil, types.SiaPublicKey{}, fmt.Errorf("peer's identity could not be verified: %v", err)
+	}
+	return ec, types.Ed25519PublicKey(theirProof.PublicKey), nil
+}
+
+// connectEncryptionHandshake performs the encryption handshake and should be
+// called on the side making the connection request, after the version
+// handshake.
+func connectEncryptionHandshake(conn net.Conn, id identity) (net.Conn, types.SiaPublicKey, error) {
+	return encryptionHandshake(conn, id, true)
+}
+
+// acceptConnEncryptionHandshake performs the encryption handshake and should
+// be called on the side accepting a connection request, after the version
+// handshake.
+func acceptConnEncryptionHandshake(conn net.Conn, id identity) (net.Conn, types.SiaPublicKey, error) {
+	return encryptionHandshake(conn, id, false)
+}
+
+// checkNodeKey returns errPeerKeyMismatch if the node at addr has previously
+// presented an identity key other than key. A node that has presented a key
+// must present it on every later connection, so that an attacker cannot
+// downgrade the connection to an unencrypted one. A node that changed its key
+// is only accepted again after its key is forgotten with ForgetPeerKey.
+func (g *Gateway) checkNodeKey(addr modules.NetAddress, key types.SiaPublicKey) error {
+	n, exists := g.nodes[addr]
+	if !exists || len(n.PublicKey.Key) == 0 {
+		return nil
+	}
+	if n.PublicKey.String() != key.String() {
+		return errPeerKeyMismatch
+	}
+	return nil
+}
+
+// ForgetPeerKey forgets the identity key that the node at addr has presented,
+// so that the gateway accepts the node again after it changed its identity
+// key.
+func (g *Gateway) ForgetPeerKey(addr modules.NetAddress) error {
+	if err := g.threads.Add(); err != nil {
+		return err
+	}
+	defer g.threads.Done()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	n, exists := g.nodes[addr]
+	if !exists {
+		return errors.New("no record of that node")
+	}
+	n.PublicKey = types.SiaPublicKey{}
+	g.log.Println("INFO: forgot the identity key of node", addr)
+	return g.save()
+}
+
+// recordNodeKey records the identity key that the node at addr presented, if
+// the node has not presented one before.
+func (g *Gateway) recordNodeKey(addr modules.NetAddress, key types.SiaPublicKey) {
+	n, exists := g.nodes[addr]
+	if !exists || len(key.Key) == 0 || len(n.PublicKey.Key) != 0 {
+		return
+	}
+	n.PublicKey = key
+}
+
+// loadIdentity loads the gateway's identity key, generating and saving a new
+// one if the gateway does not have one yet.
+func (g *Gateway) loadIdentity() error {
+	path := filepath.Join(g.persistDir, identityFile)
+	err := persist.LoadFile(identityMetadata, &g.identity, path)
+	if os.IsNotExist(err) {
+		g.identity.SecretKey, g.identity.PublicKey = crypto.GenerateKeyPair()
+		return persist.SaveFileSync(identityMetadata, g.identity, path)
+	}
+	return err
+}
+
+// PublicKey returns the identity key that the gateway proves its identity
+// with to peers that it has an encrypted connection to.
+func (g *Gateway) PublicKey() types.SiaPublicKey {
+	return types.Ed25519PublicKey(g.identity.PublicKey)
+}
@@ -0,0 +1,154 @@
+package gateway
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/fastrand"
+)
+
+// newTestIdentity returns a freshly generated identity.
+func newTestIdentity() identity {
+	var id identity
+	id.SecretKey, id.PublicKey = crypto.GenerateKeyPair()
+	return id
+}
+
+// TestEncryptionHandshake checks that the encryption handshake establishes a
+// connection that carries data in both directions, and that each side learns
+// the other's identity key.
+func TestEncryptionHandshake(t *testing.T) {
+	id1, id2 := newTestIdentity(), newTestIdentity()
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+
+	type result struct {
+		conn net.Conn
+		key  types.SiaPublicKey
+		err  error
+	}
+	accepted := make(chan result)
+	go func() {
+		conn, key, err := acceptConnEncryptionHandshake(c2, id2)
+		accepted <- result{conn, key, err}
+	}()
+	ec1, key2, err := connectEncryptionHandshake(c1, id1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := <-accepted
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	ec2, key1 := r.conn, r.key
+	if pk := types.Ed25519PublicKey(id1.PublicKey); key1.String() != pk.String() {
+		t.Fatal("responder learned the wrong identity key")
+	}
+	if pk := types.Ed25519PublicKey(id2.PublicKey); key2.String() != pk.String() {
+		t.Fatal("initiator learned the wrong identity key")
+	}
+
+	// Send more than one frame in each direction.
+	data := fastrand.Bytes(encryptedFrameSize*2 + 100)
+	go ec1.Write(data)
+	received := make([]byte, len(data))
+	if _, err := io.ReadFull(ec2, received); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(data, received) {
+		t.Fatal("responder received different data than was sent")
+	}
+	go ec2.Write(data)
+	if _, err := io.ReadFull(ec1, received); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(data, received) {
+		t.Fatal("initiator received different data than was sent")
+	}
+}
+
+// TestEncryptedConnTampering checks that an encrypted connection rejects
+// frames that were modified in transit.
+func TestEncryptedConnTampering(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	go connectEncryptionHandshake(c1, newTestIdentity())
+	ec2, _, err := acceptConnEncryptionHandshake(c2, newTestIdentity())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Write a frame with a valid length prefix but a forged ciphertext.
+	frame := make([]byte, 4+64)
+	frame[0] = 64
+	go c1.Write(frame)
+	if _, err := ec2.Read(make([]byte, 64)); err != errBadFrame {
+		t.Fatal("expected errBadFrame, got", err)
+	}
+}
+
+// TestConnectEncrypted checks that gateways encrypt their connections, learn
+// each other's identity keys, and refuse to connect to a node that presents a
+// different key than it did before.
+func TestConnectEncrypted(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	g1 := newNamedTestingGateway(t, "1")
+	defer g1.Close()
+	g2 := newNamedTestingGateway(t, "2")
+	defer g2.Close()
+
+	if err := g1.Connect(g2.Address()); err != nil {
+		t.Fatal(err)
+	}
+	g2Key, g1Key := g2.PublicKey(), g1.PublicKey()
+	peers := g1.Peers()
+	if len(peers) != 1 || peers[0].PublicKey.String() != g2Key.String() {
+		t.Fatal("g1 did not learn g2's identity key:", peers)
+	}
+	learned := waitFor(func() bool {
+		peers := g2.Peers()
+		return len(peers) == 1 && peers[0].PublicKey.String() == g1Key.String()
+	})
+	if !learned {
+		t.Fatal("g2 did not learn g1's identity key")
+	}
+	g1.mu.RLock()
+	recorded := g1.nodes[g2.Address()].PublicKey
+	g1.mu.RUnlock()
+	if recorded.String() != g2Key.String() {
+		t.Fatal("g2's identity key was not recorded in the node list")
+	}
+	if err := g1.Disconnect(g2.Address()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Pretend that g2 presented a different key the first time.
+	_, pk := crypto.GenerateKeyPair()
+	g1.mu.Lock()
+	g1.nodes[g2.Address()].PublicKey = types.Ed25519PublicKey(pk)
+	g1.mu.Unlock()
+	if err := g1.Connect(g2.Address()); err != errPeerKeyMismatch {
+		t.Fatal("expected errPeerKeyMismatch, got", err)
+	}
+
+	// Once the old key is forgotten, g2 should be accepted with its new key.
+	if err := g1.ForgetPeerKey(g2.Address()); err != nil {
+		t.Fatal(err)
+	}
+	if err := g1.Connect(g2.Address()); err != nil {
+		t.Fatal(err)
+	}
+	g1.mu.RLock()
+	recorded = g1.nodes[g2.Address()].PublicKey
+	g1.mu.RUnlock()
+	if recorded.String() != g2Key.String() {
+		t.Fatal("g2's new identity key was not recorded in the node list")
+	}
+}
@@ -0,0 +1,66 @@
+package gateway
+
+import (
+	"net"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// Nodes on the Sia network may be reachable over IPv4, IPv6, or both. A
+// gateway on an IPv4-only network cannot dial IPv6 nodes and vice versa, so
+// such nodes are skipped when selecting peers instead of being treated as
+// unreachable and purged from the node list. They are still shared with
+// peers, which may be able to reach them.
+
+// hasRoute returns true if the machine has a route to the IPv6 internet, or
+// to the IPv4 internet if ipv6 is false. Connecting a UDP socket looks up the
+// route without sending any packets, so the documentation addresses used
+// here are never contacted.
+var hasRoute = func(ipv6 bool) bool {
+	addr := "192.0.2.1:9"
+	if ipv6 {
+		addr = "[2001:db8::1]:9"
+	}
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// routableFamilies reports whether the machine can currently dial IPv4 and
+// IPv6 nodes outside of the local network.
+func routableFamilies() (ipv4, ipv6 bool) {
+	return hasRoute(false), hasRoute(true)
+}
+
+// dialable returns true if the node at addr can be dialed given the address
+// families that the machine has routes for, and whether it can reach onion
+// services. Local nodes are always considered dialable.
+func dialable(addr modules.NetAddress, ipv4, ipv6, onion bool) bool {
+	if addr.IsLocal() {
+		return true
+	} else if addr.IsOnion() {
+		return onion
+	}
+	if addr.IsIPv6() {
+		return ipv6
+	}
+	return ipv4
+}
+
+// addressGroup returns the group that addr belongs to when limiting the
+// number of peers that can come from the same source. IPv4 addresses,
+// including IPv4-mapped IPv6 addresses, are grouped by host. IPv6 addresses
+// are grouped by /64 prefix, as a single subscriber is typically assigned at
+// least a /64 and can connect from any address within it.
+func addressGroup(addr modules.NetAddress) string {
+	ip := net.ParseIP(addr.Host())
+	if ip == nil {
+		return addr.Host()
+	} else if ip4 := ip.To4(); ip4 != nil {
+		return ip4.String()
+	}
+	return ip.Mask(net.CIDRMask(64, 128)).String()
+}
@@ -0,0 +1,110 @@
+package gateway
+
+import (
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestAddressGroup checks that IPv6 addresses are grouped by /64 prefix and
+// other addresses by host.
+func TestAddressGroup(t *testing.T) {
+	tests := []struct {
+		a, b  modules.NetAddress
+		equal bool
+	}{
+		{"1.2.3.4:9981", "1.2.3.4:9982", true},
+		{"1.2.3.4:9981", "1.2.3.5:9981", false},
+		{"[2001:db8::1]:9981", "[2001:db8::2]:9982", true},
+		{"[2001:db8::1]:9981", "[2001:db8:0:0:ffff::1]:9981", true},
+		{"[2001:db8::1]:9981", "[2001:db8:0:1::1]:9981", false},
+		{"[::ffff:1.2.3.4]:9981", "1.2.3.4:9981", true},
+		{"foo.com:9981", "foo.com:9982", true},
+		{"foo.com:9981", "bar.com:9981", false},
+	}
+	for _, test := range tests {
+		if (addressGroup(test.a) == addressGroup(test.b)) != test.equal {
+			t.Errorf("expected grouping of %v and %v to be %v", test.a, test.b, test.equal)
+		}
+	}
+}
+
+// TestRandomNodeFamily checks that randomNode only selects nodes that can be
+// dialed over the address families the machine has routes for.
+func TestRandomNodeFamily(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	g := newTestingGateway(t)
+	defer g.Close()
+
+	oldHasRoute := hasRoute
+	defer func() { hasRoute = oldHasRoute }()
+
+	v4 := modules.NetAddress("1.2.3.4:9981")
+	v6 := modules.NetAddress("[2001:db8::1]:9981")
+	g.mu.Lock()
+	g.nodes = make(map[modules.NetAddress]*node)
+	g.addNode(v4)
+	g.addNode(v6)
+	g.mu.Unlock()
+
+	for _, ipv6 := range []bool{false, true} {
+		hasRoute = func(v6 bool) bool { return v6 == ipv6 }
+		want := v4
+		if ipv6 {
+			want = v6
+		}
+		for i := 0; i < 20; i++ {
+			g.mu.RLock()
+			addr, err := g.randomNode()
+			g.mu.RUnlock()
+			if err != nil {
+				t.Fatal(err)
+			} else if addr != want {
+				t.Fatalf("expected %v, got %v", want, addr)
+			}
+		}
+	}
+
+	// With no routes, neither node can be selected.
+	hasRoute = func(bool) bool { return false }
+	g.mu.RLock()
+	_, err := g.randomNode()
+	g.mu.RUnlock()
+	if err != errNoPeers {
+		t.Fatal("expected errNoPeers, got", err)
+	}
+}
+
+// TestDualStackListen checks that a gateway listening on all interfaces
+// accepts peers over both IPv4 and IPv6.
+func TestDualStackListen(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	if l, err := net.Listen("tcp", "[::1]:0"); err != nil {
+		t.Skip("IPv6 loopback is unavailable:", err)
+	} else {
+		l.Close()
+	}
+
+	g, err := New(":0", false, build.TempDir("gateway", t.Name(), "listener"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	_, port, _ := net.SplitHostPort(g.listener.Addr().String())
+
+	for i, host := range []string{"127.0.0.1", "::1"} {
+		peer := newNamedTestingGateway(t, strconv.Itoa(i))
+		err := peer.Connect(modules.NetAddress(net.JoinHostPort(host, port)))
+		peer.Close()
+		if err != nil {
+			t.Fatalf("could not connect over %v: %v", host, err)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package gateway
+
+import (
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// The operator can restrict the gateway's peers with a blacklist and a
+// whitelist. The gateway never dials or accepts a peer that matches the
+// blacklist, and if the whitelist is not empty, it only dials and accepts
+// peers that match the whitelist, which allows private network topologies to
+// be built. Entries are IP addresses, CIDR ranges, or onion hostnames.
+// Inbound connections are checked against the IP address they come from as
+// well as the dialback address the peer supplies. Blacklisted addresses are
+// not added to the node list. The lists are saved in the gateway's settings
+// file.
+
+var (
+	// errPeerFiltered is returned when connecting to a peer that is not
+	// allowed by the peer filter.
+	errPeerFiltered = errors.New("peer is not allowed by the gateway's blacklist or whitelist")
+)
+
+// filterList is a parsed blacklist or whitelist.
+type filterList struct {
+	nets  []*net.IPNet
+	hosts map[string]struct{}
+}
+
+// peerFilter is a parsed blacklist and whitelist.
+type peerFilter struct {
+	blacklist filterList
+	whitelist filterList
+}
+
+// parseFilterList parses the entries of a blacklist or whitelist.
+func parseFilterList(entries []string) (filterList, error) {
+	fl := filterList{hosts: make(map[string]struct{})}
+	for _, entry := range entries {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if _, ipnet, err := net.ParseCIDR(entry); err == nil {
+			fl.nets = append(fl.nets, ipnet)
+		} else if ip := net.ParseIP(entry); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			fl.nets = append(fl.nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+		} else if strings.HasSuffix(entry, ".onion") {
+			fl.hosts[entry] = struct{}{}
+		} else {
+			return filterList{}, errors.New("peer filter entry must be an IP address, CIDR range, or onion hostname: " + entry)
+		}
+	}
+	return fl, nil
+}
+
+// parsePeerFilter parses a blacklist and whitelist.
+func parsePeerFilter(pf modules.GatewayPeerFilter) (peerFilter, error) {
+	blacklist, err := parseFilterList(pf.Blacklist)
+	if err != nil {
+		return peerFilter{}, err
+	}
+	whitelist, err := parseFilterList(pf.Whitelist)
+	if err != nil {
+		return peerFilter{}, err
+	}
+	return peerFilter{blacklist: blacklist, whitelist: whitelist}, nil
+}
+
+// empty returns true if the list has no entries.
+func (fl filterList) empty() bool {
+	return len(fl.nets) == 0 && len(fl.hosts) == 0
+}
+
+// matches returns true if the host of addr matches an entry of the list.
+func (fl filterList) matches(addr modules.NetAddress) bool {
+	host := strings.ToLower(addr.Host())
+	if ip := net.ParseIP(host); ip != nil {
+		for _, ipnet := range fl.nets {
+			if ipnet.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+	_, exists := fl.hosts[host]
+	return exists
+}
+
+// allows returns true if the filter allows the gateway to be peers with addr.
+func (pf peerFilter) allows(addr modules.NetAddress) bool {
+	if pf.blacklist.matches(addr) {
+		return false
+	}
+	return pf.whitelist.empty() || pf.whitelist.matches(addr)
+}
+
+// PeerFilter returns the blacklist and whitelist that restrict the gateway's
+// peers.
+func (g *Gateway) PeerFilter() modules.GatewayPeerFilter {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return modules.GatewayPeerFilter{
+		Blacklist: append([]string{}, g.peerFilterLists.Blacklist...),
+		Whitelist: append([]string{}, g.peerFilterLists.Whitelist...),
+	}
+}
+
+// SetPeerFilter replaces the blacklist and whitelist that restrict the
+// gateway's peers. Peers that are no longer allowed are disconnected, and
+// blacklisted nodes are removed from the node list. The lists are saved to
+// disk.
+func (g *Gateway) SetPeerFilter(lists modules.GatewayPeerFilter) error {
+	if err := g.threads.Add(); err != nil {
+		return err
+	}
+	defer g.threads.Done()
+	pf, err := parsePeerFilter(lists)
+	if err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	g.peerFilter = pf
+	g.peerFilterLists = lists
+	var disconnect []*peer
+	for addr, p := range g.peers {
+		if !pf.allows(addr) {
+			disconnect = append(disconnect, p)
+			delete(g.peers, addr)
+		}
+	}
+	for addr := range g.nodes {
+		if pf.blacklist.matches(addr) {
+			delete(g.nodes, addr)
+		}
+	}
+	err = g.saveSettings()
+	if saveErr := g.save(); err == nil {
+		err = saveErr
+	}
+	g.mu.Unlock()
+
+	for _, p := range disconnect {
+		p.sess.Close()
+		g.log.Println("INFO: disconnected from peer that is no longer allowed by the peer filter:", p.NetAddress)
+	}
+	return err
+}
@@ -0,0 +1,116 @@
+package gateway
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestPeerFilterAllows checks that blacklists and whitelists match IP
+// addresses, CIDR ranges, and onion hostnames.
+func TestPeerFilterAllows(t *testing.T) {
+	pf, err := parsePeerFilter(modules.GatewayPeerFilter{
+		Blacklist: []string{"203.0.113.7", "2001:db8::/32", "ABCDEFGHIJKLMNOP.onion"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		addr    modules.NetAddress
+		allowed bool
+	}{
+		{"203.0.113.7:9981", false},
+		{"203.0.113.8:9981", true},
+		{"[2001:db8::1]:9981", false},
+		{"[2001:db9::1]:9981", true},
+		{"abcdefghijklmnop.onion:9981", false},
+		{"qrstuvwxyzabcdef.onion:9981", true},
+	}
+	for _, test := range tests {
+		if pf.allows(test.addr) != test.allowed {
+			t.Errorf("expected allows(%v) to be %v", test.addr, test.allowed)
+		}
+	}
+
+	// A whitelist only allows the peers it matches, and the blacklist takes
+	// precedence over it.
+	pf, err = parsePeerFilter(modules.GatewayPeerFilter{
+		Blacklist: []string{"10.0.0.5"},
+		Whitelist: []string{"10.0.0.0/8"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pf.allows("10.1.2.3:9981") || pf.allows("10.0.0.5:9981") || pf.allows("203.0.113.8:9981") {
+		t.Fatal("whitelist was not applied correctly")
+	}
+
+	if _, err := parsePeerFilter(modules.GatewayPeerFilter{Whitelist: []string{"example.com"}}); err == nil {
+		t.Fatal("expected an error for a hostname entry")
+	}
+}
+
+// TestPeerFilterConnections checks that the gateway refuses to connect to or
+// accept filtered peers, disconnects from peers that become filtered, and
+// saves its filter across restarts.
+func TestPeerFilterConnections(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	g1 := newNamedTestingGateway(t, "1")
+	defer func() { g1.Close() }()
+	g2 := newNamedTestingGateway(t, "2")
+	defer g2.Close()
+
+	// Blacklisting the loopback range prevents g1 from connecting to g2.
+	blacklist := modules.GatewayPeerFilter{Blacklist: []string{"127.0.0.0/8", "::1"}}
+	if err := g1.SetPeerFilter(blacklist); err != nil {
+		t.Fatal(err)
+	}
+	if err := g1.Connect(g2.Address()); err != errPeerFiltered {
+		t.Fatal("expected errPeerFiltered, got", err)
+	}
+
+	// g1 also refuses connections from g2.
+	if err := g2.Connect(g1.Address()); err == nil {
+		t.Fatal("expected g1 to refuse the connection")
+	}
+
+	// Clearing the blacklist allows the connection, and blacklisting again
+	// disconnects the peer.
+	if err := g1.SetPeerFilter(modules.GatewayPeerFilter{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := g1.Connect(g2.Address()); err != nil {
+		t.Fatal(err)
+	}
+	if err := g1.SetPeerFilter(blacklist); err != nil {
+		t.Fatal(err)
+	}
+	if len(g1.Peers()) != 0 {
+		t.Fatal("filtered peer was not disconnected")
+	}
+	for i := 0; i < 50 && len(g2.Peers()) != 0; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if len(g2.Peers()) != 0 {
+		t.Fatal("filtered peer did not notice the disconnect")
+	}
+
+	// The filter persists across restarts.
+	if err := g1.Close(); err != nil {
+		t.Fatal(err)
+	}
+	g1, err := New("localhost:0", false, g1.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pf := g1.PeerFilter(); len(pf.Blacklist) != 2 || pf.Blacklist[0] != "127.0.0.0/8" {
+		t.Fatal("peer filter was not loaded:", pf)
+	}
+	if err := g1.Connect(g2.Address()); err != errPeerFiltered {
+		t.Fatal("expected errPeerFiltered after restart, got", err)
+	}
+}
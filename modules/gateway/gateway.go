@@ -88,9 +88,7 @@ import (
 // of bootstrap nodes. If there is any cross-polination (which an attacker
 // could do pretty easily), the gateways will not clean up over time, which
 // will degrade the quality of the flood network as the two networks will
-// continously flood eachother with irrelevant information. Additionally, there
-// is no public key exhcange, so communications cannot be effectively encrypted
-// or authenticated. The nodes must have some way to share keys.
+// continously flood eachother with irrelevant information.
 //
 // TODO: Gateway hostname discovery currently has significant centralization,
 // namely the fallback is a single third-party website that can easily form any
@@ -104,12 +102,6 @@ import (
 // hostname, which means they will not be able to dial you back, which means
 // they will not add you to their node list.
 //
-// TODO: The gateway should encrypt and authenticate all communications. Though
-// the gateway participates in a flood network, practical attacks have been
-// demonstrated which have been able to confuse nodes by manipulating messages
-// from their peers. Encryption + authentication would have made the attack
-// more difficult.
-//
 // TODO: The gateway does an unofficial ping in two places within nodes.go.
 // These unofficial pings can be found by searching for the string "0.0.0". To
 // perform the unofficial ping, the gateway connects to a peer and writes its
@@ -145,6 +137,9 @@ import (
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/persist"
 	siasync "github.com/NebulousLabs/Sia/sync"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/lucas-clemente/quic-go"
 )
 
 var (
@@ -158,12 +153,69 @@ type Gateway struct {
 	myAddr   modules.NetAddress
 	port     string
 
+	// quicListener accepts peer connections over QUIC. It is nil unless QUIC
+	// has been enabled.
+	//
+	// transports holds the metrics of each transport, and is not modified
+	// after the gateway is created.
+	quicListener quic.Listener
+	transports   map[string]*transportStats
+
+	// proxy configures outbound connections to be made through a SOCKS5
+	// proxy, see proxy.go. It is not modified after the gateway is created.
+	proxy ProxyConfig
+
+	// rateLimits are the caps on the rate at which the gateway reads from
+	// and writes to its peers, enforced by downloadLimit and uploadLimit.
+	rateLimits    modules.GatewayRateLimits
+	downloadLimit rateLimit
+	uploadLimit   rateLimit
+
+	// peerFilterLists are the blacklist and whitelist set by the operator,
+	// and peerFilter is their parsed form, see filter.go.
+	peerFilterLists modules.GatewayPeerFilter
+	peerFilter      peerFilter
+
+	// peerLimits are the limits on the number of peers, see peerlimits.go.
+	peerLimits modules.GatewayPeerLimits
+
+	// rpcTimeouts are the deadlines enforced on RPCs, see rpctimeouts.go.
+	rpcTimeouts modules.GatewayRPCTimeouts
+
+	// anchors are the peers that the gateway always stays connected to, see
+	// anchors.go. anchorWake wakes the anchor manager when they change.
+	anchors    map[modules.NetAddress]struct{}
+	anchorWake chan struct{}
+
+	// banScores are the decaying misbehavior scores of peers, and bans are
+	// the peers that crossed the ban threshold, see banscore.go.
+	banScores map[modules.NetAddress]banScore
+	bans      map[modules.NetAddress]modules.BannedPeer
+
+	// identity is the long-term key that the gateway proves its identity
+	// with when encrypting peer connections, see encryption.go. It is not
+	// modified after the gateway is created.
+	identity identity
+
+	// portMapping is the status of the port mapping on the router.
+	portMapping modules.GatewayPortMapping
+
 	// handlers are the RPCs that the Gateway can handle.
 	//
 	// initRPCs are the RPCs that the Gateway calls upon connecting to a peer.
 	handlers map[rpcID]modules.RPCFunc
 	initRPCs map[string]modules.RPCFunc
 
+	// offline is true while the gateway has been taken off the network, see
+	// offline.go. rpcs tracks the RPCs in progress, so that they can be
+	// flushed when the gateway goes offline.
+	offline bool
+	rpcs    sync.WaitGroup
+
+	// registeredCapabilities are the capabilities registered by other
+	// modules, which are shared with peers along with the gateway's own.
+	registeredCapabilities uint64
+
 	// nodes is the set of all known nodes (i.e. potential peers).
 	//
 	// peers are the nodes that the gateway is currently connected to.
@@ -179,7 +231,7 @@ type Gateway struct {
 	// and would block any threads.Flush() calls. So a second threadgroup is
 	// added which handles clean-shutdown for the peers, without blocking
 	// threads.Flush() calls.
-	nodes  map[modules.NetAddress]struct{}
+	nodes  map[modules.NetAddress]*node
 	peers  map[modules.NetAddress]*peer
 	peerTG siasync.ThreadGroup
 
@@ -221,6 +273,17 @@ func (g *Gateway) Close() error {
 
 // New returns an initialized Gateway.
 func New(addr string, bootstrap bool, persistDir string) (*Gateway, error) {
+	return NewWithProxy(addr, bootstrap, persistDir, ProxyConfig{})
+}
+
+// NewWithProxy returns an initialized Gateway that makes its outbound
+// connections through the proxy described by pc, and optionally publishes
+// itself as a Tor onion service.
+func NewWithProxy(addr string, bootstrap bool, persistDir string, pc ProxyConfig) (*Gateway, error) {
+	if pc.TorControl != "" && pc.SOCKS5 == "" {
+		return nil, errors.New("a SOCKS5 proxy is required to publish an onion service")
+	}
+
 	// Create the directory if it doesn't exist.
 	err := os.MkdirAll(persistDir, 0700)
 	if err != nil {
@@ -232,8 +295,23 @@ func New(addr string, bootstrap bool, persistDir string) (*Gateway, error) {
 		initRPCs: make(map[string]modules.RPCFunc),
 
 		peers: make(map[modules.NetAddress]*peer),
-		nodes: make(map[modules.NetAddress]struct{}),
+		nodes: make(map[modules.NetAddress]*node),
+
+		peerLimits:  defaultPeerLimits(),
+		rpcTimeouts: defaultRPCTimeouts(),
 
+		anchors:    make(map[modules.NetAddress]struct{}),
+		anchorWake: make(chan struct{}, 1),
+
+		banScores: make(map[modules.NetAddress]banScore),
+		bans:      make(map[modules.NetAddress]modules.BannedPeer),
+
+		transports: map[string]*transportStats{
+			transportTCP:  new(transportStats),
+			transportQUIC: new(transportStats),
+		},
+
+		proxy:      pc,
 		persistDir: persistDir,
 	}
 
@@ -264,10 +342,16 @@ func New(addr string, bootstrap bool, persistDir string) (*Gateway, error) {
 	// Register RPCs.
 	g.RegisterRPC("ShareNodes", g.shareNodes)
 	g.RegisterConnectCall("ShareNodes", g.requestNodes)
+	g.RegisterRPC("Capabilities", g.shareCapabilities)
+	g.RegisterConnectCall("Capabilities", g.requestCapabilities)
+	g.RegisterRPC("Ping", g.rpcPing)
 	// Establish the de-registration of the RPCs.
 	g.threads.OnStop(func() {
 		g.UnregisterRPC("ShareNodes")
 		g.UnregisterConnectCall("ShareNodes")
+		g.UnregisterRPC("Capabilities")
+		g.UnregisterConnectCall("Capabilities")
+		g.UnregisterRPC("Ping")
 	})
 
 	// Load the old node list. If it doesn't exist, no problem, but if it does,
@@ -275,9 +359,16 @@ func New(addr string, bootstrap bool, persistDir string) (*Gateway, error) {
 	if loadErr := g.load(); loadErr != nil && !os.IsNotExist(loadErr) {
 		return nil, loadErr
 	}
+	if loadErr := g.loadSettings(); loadErr != nil && !os.IsNotExist(loadErr) {
+		return nil, loadErr
+	}
+	if err := g.loadIdentity(); err != nil {
+		return nil, err
+	}
 
-	// Add the bootstrap peers to the node list.
-	if bootstrap {
+	// Add the bootstrap peers to the node list. The bootstrap peers are on the
+	// public network, so they are not added on private networks.
+	if bootstrap && types.NetworkID == "" {
 		for _, addr := range modules.BootstrapPeers {
 			err := g.addNode(addr)
 			if err != nil && err != errNodeExists {
@@ -308,6 +399,13 @@ func New(addr string, bootstrap bool, persistDir string) (*Gateway, error) {
 	// Set myAddr equal to the address returned by the listener. It will be
 	// overwritten by threadedLearnHostname later on.
 	g.myAddr = modules.NetAddress(g.listener.Addr().String())
+	if pc.TorControl != "" {
+		g.myAddr, err = g.publishOnion()
+		if err != nil {
+			return nil, fmt.Errorf("could not publish onion service: %v", err)
+		}
+		g.log.Println("INFO: our address is", g.myAddr)
+	}
 
 	// Spawn the peer connection listener.
 	go g.permanentListen(permanentListenClosedChan)
@@ -326,6 +424,30 @@ func New(addr string, bootstrap bool, persistDir string) (*Gateway, error) {
 	})
 	go g.permanentNodeManager(nodeManagerClosedChan)
 
+	// Spawn the idle peer manager and provide tools for ensuring clean
+	// shutdown.
+	idlePeerManagerClosedChan := make(chan struct{})
+	g.threads.OnStop(func() {
+		<-idlePeerManagerClosedChan
+	})
+	go g.permanentIdlePeerManager(idlePeerManagerClosedChan)
+
+	// Spawn the latency monitor and provide tools for ensuring clean
+	// shutdown.
+	latencyMonitorClosedChan := make(chan struct{})
+	g.threads.OnStop(func() {
+		<-latencyMonitorClosedChan
+	})
+	go g.permanentLatencyMonitor(latencyMonitorClosedChan)
+
+	// Spawn the anchor manager and provide tools for ensuring clean
+	// shutdown.
+	anchorManagerClosedChan := make(chan struct{})
+	g.threads.OnStop(func() {
+		<-anchorManagerClosedChan
+	})
+	go g.permanentAnchorManager(anchorManagerClosedChan)
+
 	// Spawn the node purger and provide tools for ensuring clean shutdown.
 	nodePurgerClosedChan := make(chan struct{})
 	g.threads.OnStop(func() {
@@ -334,8 +456,11 @@ func New(addr string, bootstrap bool, persistDir string) (*Gateway, error) {
 	go g.permanentNodePurger(nodePurgerClosedChan)
 
 	// Spawn threads to take care of port forwarding and hostname discovery.
-	go g.threadedForwardPort(g.port)
-	go g.threadedLearnHostname()
+	// Proxied gateways do neither, to avoid revealing their IP address.
+	if !g.proxied() {
+		go g.threadedForwardPort(g.port)
+		go g.threadedLearnHostname()
+	}
 
 	return g, nil
 }
@@ -0,0 +1,117 @@
+package gateway
+
+import (
+	"sync"
+	"time"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// idlePeers returns the addresses of the peers that have not been heard from
+// in at least peerIdleTimeout.
+func (g *Gateway) idlePeers() []modules.NetAddress {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	var addrs []modules.NetAddress
+	for addr, p := range g.peers {
+		if time.Since(p.idleSince()) >= peerIdleTimeout {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// managedPingPeer checks that a connected peer is still responsive. The
+// ShareNodes RPC is used as the ping because every version of the gateway
+// supports it and it always produces a response. The nodes in the response
+// are discarded.
+func (g *Gateway) managedPingPeer(addr modules.NetAddress) error {
+	return g.managedRPC(addr, "ShareNodes", func(conn modules.PeerConn) error {
+		conn.SetDeadline(time.Now().Add(pingTimeout))
+		var nodes []modules.NetAddress
+		return encoding.ReadObject(conn, &nodes, maxSharedNodes*modules.MaxEncodedNetAddressLength)
+	})
+}
+
+// managedRefreshPeer pings an idle peer, and disconnects it if it does not
+// respond. The gateway then tries to re-handshake with an unresponsive
+// outbound peer, in case only the connection was lost, and connects to a
+// random node in its place if that fails. Inbound peers are free to connect
+// again on their own.
+func (g *Gateway) managedRefreshPeer(addr modules.NetAddress) {
+	err := g.managedPingPeer(addr)
+	if err == nil {
+		return
+	}
+	g.mu.RLock()
+	p, exists := g.peers[addr]
+	g.mu.RUnlock()
+	if !exists {
+		// The peer disconnected while it was being pinged.
+		return
+	}
+	inbound := p.Inbound
+	g.log.Debugf("INFO: [IPM] [%v] idle peer did not respond to ping, disconnecting: %v", addr, err)
+	if err := g.managedDisconnect(addr); err != nil {
+		g.log.Debugf("WARN: [IPM] [%v] could not disconnect from idle peer: %v", addr, err)
+		return
+	}
+	if inbound {
+		return
+	}
+
+	err = g.managedConnect(addr)
+	if err == nil {
+		g.log.Debugf("INFO: [IPM] [%v] re-handshake with idle peer succeeded", addr)
+		return
+	}
+	g.log.Debugf("INFO: [IPM] [%v] re-handshake with idle peer failed, finding a replacement: %v", addr, err)
+	g.mu.RLock()
+	replacement, err := g.randomNode()
+	g.mu.RUnlock()
+	if err != nil || replacement == addr {
+		return
+	}
+	g.managedPeerManagerConnect(replacement)
+}
+
+// permanentIdlePeerManager is a thread that runs throughout the lifetime of
+// the gateway, checking on peers that have gone silent so that dead
+// connections are replaced before a broadcast needs them.
+func (g *Gateway) permanentIdlePeerManager(closeChan chan struct{}) {
+	defer close(closeChan)
+
+	// Pings can take longer than idlePeerCheckInterval, so peers that are
+	// still being refreshed are skipped.
+	var mu sync.Mutex
+	refreshing := make(map[modules.NetAddress]struct{})
+	for {
+		if !g.managedSleep(idlePeerCheckInterval) {
+			return
+		}
+
+		for _, addr := range g.idlePeers() {
+			mu.Lock()
+			_, busy := refreshing[addr]
+			refreshing[addr] = struct{}{}
+			mu.Unlock()
+			if busy {
+				continue
+			}
+			go func(addr modules.NetAddress) {
+				defer func() {
+					mu.Lock()
+					delete(refreshing, addr)
+					mu.Unlock()
+				}()
+				if g.threads.Add() != nil {
+					return
+				}
+				defer g.threads.Done()
+				g.managedRefreshPeer(addr)
+			}(addr)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package gateway
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/muxado"
+)
+
+// TestRefreshIdlePeers checks that idle peers which answer a ping are kept,
+// and that idle peers which do not are disconnected.
+func TestRefreshIdlePeers(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	g1 := newNamedTestingGateway(t, "1")
+	defer g1.Close()
+	g2 := newNamedTestingGateway(t, "2")
+	defer g2.Close()
+	err := g1.Connect(g2.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Add a peer that accepts data but never responds.
+	c1, c2 := net.Pipe()
+	defer c2.Close()
+	go io.Copy(ioutil.Discard, c2)
+	silentAddr := modules.NetAddress("127.0.0.1:1")
+	g1.mu.Lock()
+	g1.addPeer(&peer{
+		Peer: modules.Peer{
+			NetAddress: silentAddr,
+		},
+		sess: muxado.Client(c1),
+	})
+	g1.mu.Unlock()
+	if len(g1.idlePeers()) != 0 {
+		t.Fatal("newly connected peers should not be idle")
+	}
+
+	// Make both peers look idle.
+	g1.mu.RLock()
+	for _, p := range g1.peers {
+		atomic.StoreInt64(&p.atomicLastActive, 0)
+	}
+	g1.mu.RUnlock()
+	if len(g1.idlePeers()) != 2 {
+		t.Fatal("expected both peers to be idle, got", g1.idlePeers())
+	}
+
+	g1.managedRefreshPeer(g2.Address())
+	g1.managedRefreshPeer(silentAddr)
+
+	g1.mu.RLock()
+	_, responsiveKept := g1.peers[g2.Address()]
+	_, silentKept := g1.peers[silentAddr]
+	g1.mu.RUnlock()
+	if !responsiveKept {
+		t.Fatal("responsive peer was disconnected")
+	}
+	if silentKept {
+		t.Fatal("unresponsive peer was not disconnected")
+	}
+	if idle := g1.idlePeers(); len(idle) != 0 {
+		t.Fatal("peers are still idle after being refreshed:", idle)
+	}
+}
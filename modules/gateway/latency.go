@@ -0,0 +1,176 @@
+package gateway
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/fastrand"
+)
+
+var errBadPong = errors.New("peer responded to ping with the wrong nonce")
+
+// durationsAscending sorts a slice of durations from shortest to longest.
+type durationsAscending []time.Duration
+
+func (ds durationsAscending) Len() int           { return len(ds) }
+func (ds durationsAscending) Less(i, j int) bool { return ds[i] < ds[j] }
+func (ds durationsAscending) Swap(i, j int)      { ds[i], ds[j] = ds[j], ds[i] }
+
+// latency returns the smoothed round-trip time to the peer, or zero if it has
+// not been measured yet.
+func (p *peer) latency() time.Duration {
+	return time.Duration(atomic.LoadUint64(&p.atomicLatencyNanos))
+}
+
+// recordLatency folds a new round-trip time measurement into the peer's
+// smoothed latency. The first measurement is taken as is.
+func (p *peer) recordLatency(rtt time.Duration) {
+	for {
+		old := atomic.LoadUint64(&p.atomicLatencyNanos)
+		smoothed := uint64(rtt)
+		if old != 0 {
+			smoothed = (3*old + uint64(rtt)) / 4
+		}
+		if atomic.CompareAndSwapUint64(&p.atomicLatencyNanos, old, smoothed) {
+			return
+		}
+	}
+}
+
+// rpcPing is the RPC that echoes a nonce back to a peer that is measuring its
+// latency to the gateway.
+func (g *Gateway) rpcPing(conn modules.PeerConn) error {
+	conn.SetDeadline(time.Now().Add(pingTimeout))
+	var nonce [8]byte
+	if err := encoding.ReadObject(conn, &nonce, 8); err != nil {
+		return err
+	}
+	return encoding.WriteObject(conn, nonce)
+}
+
+// managedMeasureLatency pings the peer at addr and records the round-trip
+// time in the peer's latency.
+func (g *Gateway) managedMeasureLatency(addr modules.NetAddress) error {
+	var rtt time.Duration
+	err := g.managedRPC(addr, "Ping", func(conn modules.PeerConn) error {
+		conn.SetDeadline(time.Now().Add(pingTimeout))
+		var nonce [8]byte
+		fastrand.Read(nonce[:])
+		start := time.Now()
+		if err := encoding.WriteObject(conn, nonce); err != nil {
+			return err
+		}
+		var pong [8]byte
+		if err := encoding.ReadObject(conn, &pong, 8); err != nil {
+			return err
+		}
+		rtt = time.Since(start)
+		if pong != nonce {
+			return errBadPong
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	g.mu.RLock()
+	p, exists := g.peers[addr]
+	g.mu.RUnlock()
+	if exists {
+		p.recordLatency(rtt)
+	}
+	return nil
+}
+
+// slowestOutboundPeer returns the outbound peer whose latency is furthest
+// above the median latency of the gateway's outbound peers, if it is at least
+// slowPeerLatencyFactor times the median. Local, anchor, and whitelisted peers
+// are never considered slow, and no peer is returned unless the gateway has at
+// least as many outbound peers as it aims for, so that replacing a slow peer
+// does not leave the gateway poorly connected.
+func (g *Gateway) slowestOutboundPeer() (modules.NetAddress, bool) {
+	var latencies []time.Duration
+	var slowest *peer
+	for _, p := range g.peers {
+		if p.Inbound || p.latency() == 0 {
+			continue
+		}
+		latencies = append(latencies, p.latency())
+		if p.Local || g.isAnchor(p.NetAddress) || g.peerFilter.whitelist.matches(p.NetAddress) {
+			continue
+		}
+		if slowest == nil || p.latency() > slowest.latency() {
+			slowest = p
+		}
+	}
+	if slowest == nil || len(latencies) < g.peerLimits.TargetOutbound || len(latencies) < 3 {
+		return "", false
+	}
+	sort.Sort(durationsAscending(latencies))
+	median := latencies[len(latencies)/2]
+	if slowest.latency() < slowPeerMinLatency || slowest.latency() < slowPeerLatencyFactor*median {
+		return "", false
+	}
+	return slowest.NetAddress, true
+}
+
+// managedMeasureLatencies pings every peer that supports the Ping RPC in
+// parallel, recording their latencies.
+func (g *Gateway) managedMeasureLatencies() {
+	g.mu.RLock()
+	var addrs []modules.NetAddress
+	for addr, p := range g.peers {
+		if p.Supports(modules.CapabilityPing, latencyUpgradeVersion) {
+			addrs = append(addrs, addr)
+		}
+	}
+	g.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, addr := range addrs {
+		wg.Add(1)
+		go func(addr modules.NetAddress) {
+			defer wg.Done()
+			if err := g.managedMeasureLatency(addr); err != nil {
+				g.log.Debugf("INFO: [PLM] [%v] could not measure latency: %v", addr, err)
+			}
+		}(addr)
+	}
+	wg.Wait()
+}
+
+// permanentLatencyMonitor is a thread that runs throughout the lifetime of the
+// gateway, measuring the latency of its peers. If an outbound peer is much
+// slower than the others, it is disconnected so that the peer manager
+// connects to a random node in its place, biasing the gateway's outbound
+// peers towards low-latency peers over time.
+func (g *Gateway) permanentLatencyMonitor(closeChan chan struct{}) {
+	defer close(closeChan)
+
+	for {
+		if !g.managedSleep(latencyCheckInterval) {
+			return
+		}
+		if g.threads.Add() != nil {
+			return
+		}
+		g.managedMeasureLatencies()
+
+		g.mu.RLock()
+		addr, slow := g.slowestOutboundPeer()
+		g.mu.RUnlock()
+		if slow {
+			g.log.Debugf("INFO: [PLM] [%v] disconnecting from slow outbound peer", addr)
+			if err := g.managedDisconnect(addr); err != nil {
+				g.log.Debugf("WARN: [PLM] [%v] could not disconnect from slow peer: %v", addr, err)
+			}
+		}
+		g.threads.Done()
+	}
+}
@@ -0,0 +1,111 @@
+package gateway
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestMeasureLatency checks that the gateway measures the latency of its
+// peers with the Ping RPC, and reports it in its peer list.
+func TestMeasureLatency(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	g1 := newNamedTestingGateway(t, "1")
+	defer g1.Close()
+	g2 := newNamedTestingGateway(t, "2")
+	defer g2.Close()
+
+	if err := g1.Connect(g2.Address()); err != nil {
+		t.Fatal(err)
+	}
+	if err := g1.managedMeasureLatency(g2.Address()); err != nil {
+		t.Fatal(err)
+	}
+	peers := g1.Peers()
+	if len(peers) != 1 || peers[0].Latency <= 0 {
+		t.Fatal("expected g2 to have a measured latency:", peers)
+	}
+	if stats := g1.PeerStats(); stats[0].Latency != peers[0].Latency {
+		t.Fatal("peer stats report a different latency than the peer list")
+	}
+
+	// The latency monitor measures peers periodically on its own.
+	measured := waitFor(func() bool {
+		peers := g2.Peers()
+		return len(peers) == 1 && peers[0].Latency > 0
+	})
+	if !measured {
+		t.Fatal("latency monitor did not measure g1's latency")
+	}
+}
+
+// TestRecordLatency checks that latency measurements are smoothed.
+func TestRecordLatency(t *testing.T) {
+	p := new(peer)
+	p.recordLatency(100 * time.Millisecond)
+	if p.latency() != 100*time.Millisecond {
+		t.Fatal("first measurement was not taken as is:", p.latency())
+	}
+	p.recordLatency(500 * time.Millisecond)
+	if p.latency() != 200*time.Millisecond {
+		t.Fatal("expected smoothed latency of 200ms, got", p.latency())
+	}
+}
+
+// TestSlowestOutboundPeer checks that only outbound peers that are much slower
+// than the others are selected for replacement.
+func TestSlowestOutboundPeer(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	g := newTestingGateway(t)
+	defer g.Close()
+
+	addPeer := func(i int, inbound bool, latency time.Duration) modules.NetAddress {
+		addr := modules.NetAddress("111.111.111." + strconv.Itoa(i) + ":9981")
+		p := &peer{Peer: modules.Peer{NetAddress: addr, Inbound: inbound}}
+		p.recordLatency(latency)
+		g.peers[addr] = p
+		return addr
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.peerLimits.TargetOutbound = 4
+	for i := 0; i < 3; i++ {
+		addPeer(i, false, 20*time.Millisecond)
+	}
+	// An inbound peer is never selected, however slow it is.
+	addPeer(3, true, 10*time.Second)
+	if _, slow := g.slowestOutboundPeer(); slow {
+		t.Fatal("peer was selected while below the outbound target")
+	}
+
+	// A slightly slower outbound peer is not selected.
+	addPeer(4, false, 60*time.Millisecond)
+	if addr, slow := g.slowestOutboundPeer(); slow {
+		t.Fatal("peer that is not much slower than the median was selected:", addr)
+	}
+
+	// A much slower outbound peer is selected.
+	slowAddr := addPeer(5, false, 2*time.Second)
+	if addr, slow := g.slowestOutboundPeer(); !slow || addr != slowAddr {
+		t.Fatal("expected the slow peer to be selected, got", addr, slow)
+	}
+
+	// Whitelisted peers are not selected.
+	wl, err := parseFilterList([]string{slowAddr.Host()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.peerFilter.whitelist = wl
+	if addr, slow := g.slowestOutboundPeer(); slow {
+		t.Fatal("whitelisted peer was selected:", addr)
+	}
+}
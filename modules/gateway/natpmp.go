@@ -0,0 +1,282 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/fastrand"
+
+	natgateway "github.com/jackpal/gateway"
+)
+
+// NAT-PMP (RFC 6886) and PCP (RFC 6887) are the port mapping protocols used
+// when the router does not support UPnP. Both are spoken over UDP to the
+// router on the same port, and a PCP server that only speaks NAT-PMP rejects
+// PCP requests with a NAT-PMP response. Unlike UPnP mappings, mappings made
+// with either protocol expire, so they are renewed halfway through their
+// lifetime.
+
+const (
+	// natPMPPort is the port that routers accept NAT-PMP and PCP requests on.
+	natPMPPort = 5351
+
+	natPMPVersion       = 0
+	natPMPOpExternalIP  = 0
+	natPMPOpMapTCP      = 2
+	natPMPResponseFlag  = 128
+	pcpVersion          = 2
+	pcpOpMap            = 1
+	pcpResponseFlag     = 0x80
+	pcpProtocolTCP      = 6
+	pcpRequestLen       = 60
+	natPMPMapRequestLen = 12
+)
+
+var errNATNoResponse = errors.New("router did not respond")
+
+// natResultError is the result code of an unsuccessful NAT-PMP or PCP
+// request.
+type natResultError struct {
+	protocol string
+	code     uint16
+}
+
+// Error implements the error interface for natResultError.
+func (e natResultError) Error() string {
+	return fmt.Sprintf("%v request failed with result code %v", e.protocol, e.code)
+}
+
+// natRequest sends req to the router, retrying with a doubling timeout as
+// described by RFC 6886, and returns the first response accepted by valid.
+func natRequest(router *net.UDPAddr, req []byte, valid func([]byte) bool) ([]byte, error) {
+	conn, err := net.DialUDP("udp", nil, router)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	buf := make([]byte, 1100) // maximum PCP message size
+	timeout := natRequestTimeout
+	for i := 0; i < natRequestAttempts; i++ {
+		if _, err := conn.Write(req); err != nil {
+			return nil, err
+		}
+		deadline := time.Now().Add(timeout)
+		conn.SetReadDeadline(deadline)
+		for time.Now().Before(deadline) {
+			n, err := conn.Read(buf)
+			if err != nil {
+				break
+			}
+			if valid(buf[:n]) {
+				return buf[:n], nil
+			}
+		}
+		timeout *= 2
+	}
+	return nil, errNATNoResponse
+}
+
+// natPMPExternalIP asks the router for its external IP address using
+// NAT-PMP.
+func natPMPExternalIP(router *net.UDPAddr) (net.IP, error) {
+	resp, err := natRequest(router, []byte{natPMPVersion, natPMPOpExternalIP}, func(b []byte) bool {
+		return len(b) >= 12 && b[0] == natPMPVersion && b[1] == natPMPResponseFlag|natPMPOpExternalIP
+	})
+	if err != nil {
+		return nil, err
+	}
+	if code := binary.BigEndian.Uint16(resp[2:4]); code != 0 {
+		return nil, natResultError{"NAT-PMP", code}
+	}
+	return net.IP(append([]byte(nil), resp[8:12]...)), nil
+}
+
+// natPMPMap asks the router to forward the external TCP port matching port
+// to port on this machine using NAT-PMP, returning the external port and the
+// lifetime granted by the router. A lifetime of zero removes the mapping.
+func natPMPMap(router *net.UDPAddr, port uint16, lifetime time.Duration) (uint16, time.Duration, error) {
+	req := make([]byte, natPMPMapRequestLen)
+	req[0] = natPMPVersion
+	req[1] = natPMPOpMapTCP
+	binary.BigEndian.PutUint16(req[4:6], port)
+	if lifetime > 0 {
+		binary.BigEndian.PutUint16(req[6:8], port)
+	}
+	binary.BigEndian.PutUint32(req[8:12], uint32(lifetime/time.Second))
+
+	resp, err := natRequest(router, req, func(b []byte) bool {
+		return len(b) >= 16 && b[0] == natPMPVersion && b[1] == natPMPResponseFlag|natPMPOpMapTCP &&
+			binary.BigEndian.Uint16(b[8:10]) == port
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	if code := binary.BigEndian.Uint16(resp[2:4]); code != 0 {
+		return 0, 0, natResultError{"NAT-PMP", code}
+	}
+	extPort := binary.BigEndian.Uint16(resp[10:12])
+	granted := time.Duration(binary.BigEndian.Uint32(resp[12:16])) * time.Second
+	return extPort, granted, nil
+}
+
+// pcpMap asks the router to forward the external TCP port matching port to
+// port on this machine using PCP, returning the external address and the
+// lifetime granted by the router. A lifetime of zero removes the mapping. The
+// router only lets the mapping be renewed or removed with the nonce that
+// created it.
+func pcpMap(router *net.UDPAddr, nonce []byte, port uint16, lifetime time.Duration) (net.IP, uint16, time.Duration, error) {
+	// PCP requests carry the address of the client as seen by the router.
+	conn, err := net.DialUDP("udp", nil, router)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	clientIP := conn.LocalAddr().(*net.UDPAddr).IP.To16()
+	conn.Close()
+
+	req := make([]byte, pcpRequestLen)
+	req[0] = pcpVersion
+	req[1] = pcpOpMap
+	binary.BigEndian.PutUint32(req[4:8], uint32(lifetime/time.Second))
+	copy(req[8:24], clientIP)
+	copy(req[24:36], nonce)
+	req[36] = pcpProtocolTCP
+	binary.BigEndian.PutUint16(req[40:42], port)
+	if lifetime > 0 {
+		binary.BigEndian.PutUint16(req[42:44], port)
+	}
+	// Leave the suggested external address unspecified, using the IPv4
+	// form if the client is on IPv4.
+	if clientIP.To4() != nil {
+		copy(req[44:60], net.IPv4zero.To16())
+	}
+
+	var pcpErr error
+	resp, err := natRequest(router, req, func(b []byte) bool {
+		// A router that only speaks NAT-PMP answers with its own version.
+		if len(b) >= 4 && b[0] == natPMPVersion {
+			pcpErr = natResultError{"PCP", binary.BigEndian.Uint16(b[2:4])}
+			return true
+		}
+		return len(b) >= pcpRequestLen && b[0] == pcpVersion && b[1] == pcpResponseFlag|pcpOpMap &&
+			bytes.Equal(b[24:36], nonce)
+	})
+	if err != nil {
+		return nil, 0, 0, err
+	} else if pcpErr != nil {
+		return nil, 0, 0, pcpErr
+	}
+	if code := resp[3]; code != 0 {
+		return nil, 0, 0, natResultError{"PCP", uint16(code)}
+	}
+	granted := time.Duration(binary.BigEndian.Uint32(resp[4:8])) * time.Second
+	extPort := binary.BigEndian.Uint16(resp[42:44])
+	extIP := net.IP(append([]byte(nil), resp[44:60]...))
+	if ip4 := extIP.To4(); ip4 != nil {
+		extIP = ip4
+	}
+	return extIP, extPort, granted, nil
+}
+
+// natMapPort forwards port on the router, trying PCP first and falling back
+// to NAT-PMP. The protocol used, the external address, and the lifetime of
+// the mapping are returned.
+func natMapPort(router *net.UDPAddr, nonce []byte, port uint16) (string, modules.NetAddress, time.Duration, error) {
+	extIP, extPort, granted, pcpErr := pcpMap(router, nonce, port, portMappingLifetime)
+	if pcpErr == nil {
+		addr := modules.NetAddress(net.JoinHostPort(extIP.String(), strconv.Itoa(int(extPort))))
+		return modules.PortMappingPCP, addr, granted, nil
+	}
+	extPort, granted, err := natPMPMap(router, port, portMappingLifetime)
+	if err != nil {
+		return "", "", 0, fmt.Errorf("PCP: %v, NAT-PMP: %v", pcpErr, err)
+	}
+	// The mapping is usable even if the external IP cannot be learned.
+	var host string
+	if extIP, err := natPMPExternalIP(router); err == nil {
+		host = extIP.String()
+	}
+	addr := modules.NetAddress(net.JoinHostPort(host, strconv.Itoa(int(extPort))))
+	return modules.PortMappingNATPMP, addr, granted, nil
+}
+
+// natUnmapPort removes a mapping made with natMapPort.
+func natUnmapPort(router *net.UDPAddr, nonce []byte, protocol string, port uint16) error {
+	if protocol == modules.PortMappingPCP {
+		_, _, _, err := pcpMap(router, nonce, port, 0)
+		return err
+	}
+	_, _, err := natPMPMap(router, port, 0)
+	return err
+}
+
+// discoverNATRouter returns the address that the default router accepts
+// NAT-PMP and PCP requests on.
+func discoverNATRouter() (*net.UDPAddr, error) {
+	ip, err := natgateway.DiscoverGateway()
+	if err != nil {
+		return nil, err
+	}
+	return &net.UDPAddr{IP: ip, Port: natPMPPort}, nil
+}
+
+// threadedMaintainNATMapping forwards port on the router using PCP or
+// NAT-PMP, renewing the mapping halfway through its lifetime and retrying
+// after failures. The mapping is removed when the gateway shuts down.
+func (g *Gateway) threadedMaintainNATMapping(router *net.UDPAddr, port uint16) {
+	if err := g.threads.Add(); err != nil {
+		return
+	}
+	defer g.threads.Done()
+
+	var mapped string
+	nonce := fastrand.Bytes(12)
+	for {
+		protocol, addr, granted, err := natMapPort(router, nonce, port)
+		wait := portMappingRetry
+		g.mu.Lock()
+		g.portMapping.LastAttempt = time.Now()
+		if err != nil {
+			g.portMapping.Error = err.Error()
+			// Keep reporting an existing mapping until it expires.
+			if time.Now().After(g.portMapping.Expires) {
+				g.portMapping.Mapped = false
+			}
+			g.log.Printf("WARN: could not forward port %v with PCP or NAT-PMP: %v", port, err)
+		} else {
+			if mapped == "" {
+				g.log.Printf("INFO: successfully forwarded port %v with %v, external address is %v", port, protocol, addr)
+			}
+			mapped = protocol
+			g.portMapping = modules.GatewayPortMapping{
+				Protocol:        protocol,
+				Mapped:          true,
+				ExternalAddress: addr,
+				Expires:         time.Now().Add(granted),
+				LastAttempt:     g.portMapping.LastAttempt,
+			}
+			if granted/2 > wait {
+				wait = granted / 2
+			}
+		}
+		g.mu.Unlock()
+
+		if !g.managedSleep(wait) {
+			break
+		}
+	}
+
+	if mapped != "" {
+		if err := natUnmapPort(router, nonce, mapped, port); err != nil {
+			g.log.Printf("WARN: could not automatically unforward port %v: %v", port, err)
+			return
+		}
+		g.log.Println("INFO: successfully unforwarded port", port)
+	}
+}
@@ -0,0 +1,208 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/fastrand"
+)
+
+// fakeRouter answers NAT-PMP and, optionally, PCP requests, forwarding every
+// requested port to externalPort on 1.2.3.4.
+type fakeRouter struct {
+	conn *net.UDPConn
+	pcp  bool
+
+	mu       sync.Mutex
+	drop     int // number of requests to ignore
+	lifetime uint32
+	requests [][]byte
+}
+
+const fakeExternalPort = 4321
+
+// newFakeRouter starts a fake router listening on localhost.
+func newFakeRouter(t *testing.T, pcp bool) *fakeRouter {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fr := &fakeRouter{conn: conn, pcp: pcp, lifetime: 2}
+	go fr.serve()
+	return fr
+}
+
+// addr returns the address of the fake router.
+func (fr *fakeRouter) addr() *net.UDPAddr {
+	return fr.conn.LocalAddr().(*net.UDPAddr)
+}
+
+// received returns the requests received by the fake router.
+func (fr *fakeRouter) received() [][]byte {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	return append([][]byte(nil), fr.requests...)
+}
+
+// serve answers requests until the router is closed.
+func (fr *fakeRouter) serve() {
+	buf := make([]byte, 1100)
+	for {
+		n, from, err := fr.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		req := append([]byte(nil), buf[:n]...)
+		fr.mu.Lock()
+		fr.requests = append(fr.requests, req)
+		drop := fr.drop > 0
+		if drop {
+			fr.drop--
+		}
+		lifetime := fr.lifetime
+		fr.mu.Unlock()
+		if drop {
+			continue
+		}
+
+		var resp []byte
+		switch {
+		case req[0] == pcpVersion && fr.pcp:
+			resp = make([]byte, pcpRequestLen)
+			copy(resp, req)
+			resp[1] = pcpResponseFlag | pcpOpMap
+			if binary.BigEndian.Uint32(req[4:8]) != 0 {
+				binary.BigEndian.PutUint32(resp[4:8], lifetime)
+			}
+			binary.BigEndian.PutUint16(resp[42:44], fakeExternalPort)
+			copy(resp[44:60], net.IPv4(1, 2, 3, 4).To16())
+		case req[0] == pcpVersion:
+			// Unsupported version.
+			resp = []byte{natPMPVersion, req[1] | natPMPResponseFlag, 0, 1}
+		case req[1] == natPMPOpExternalIP:
+			resp = make([]byte, 12)
+			resp[1] = natPMPResponseFlag | natPMPOpExternalIP
+			copy(resp[8:12], net.IPv4(1, 2, 3, 4).To4())
+		case req[1] == natPMPOpMapTCP:
+			resp = make([]byte, 16)
+			resp[1] = natPMPResponseFlag | natPMPOpMapTCP
+			copy(resp[8:10], req[4:6])
+			binary.BigEndian.PutUint16(resp[10:12], fakeExternalPort)
+			if binary.BigEndian.Uint32(req[8:12]) != 0 {
+				binary.BigEndian.PutUint32(resp[12:16], lifetime)
+			}
+		default:
+			continue
+		}
+		fr.conn.WriteToUDP(resp, from)
+	}
+}
+
+// TestNATMapPort checks that ports are forwarded with PCP when the router
+// supports it, and with NAT-PMP otherwise.
+func TestNATMapPort(t *testing.T) {
+	for _, pcp := range []bool{true, false} {
+		fr := newFakeRouter(t, pcp)
+		defer fr.conn.Close()
+		// The router misses the first request, which is retried.
+		fr.mu.Lock()
+		fr.drop = 1
+		fr.mu.Unlock()
+
+		nonce := fastrand.Bytes(12)
+		protocol, addr, granted, err := natMapPort(fr.addr(), nonce, 9981)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := modules.PortMappingNATPMP
+		if pcp {
+			expected = modules.PortMappingPCP
+		}
+		if protocol != expected || addr != "1.2.3.4:4321" || granted != 2*time.Second {
+			t.Fatal("wrong mapping:", protocol, addr, granted)
+		}
+		if err := natUnmapPort(fr.addr(), nonce, protocol, 9981); err != nil {
+			t.Fatal(err)
+		}
+
+		// The unmapping request must ask for a lifetime of zero, and PCP
+		// requests must all carry the same nonce.
+		reqs := fr.received()
+		last := reqs[len(reqs)-1]
+		if pcp {
+			if last[0] != pcpVersion || binary.BigEndian.Uint32(last[4:8]) != 0 || !bytes.Equal(last[24:36], nonce) {
+				t.Fatal("wrong PCP unmapping request:", last)
+			}
+		} else if last[0] != natPMPVersion || binary.BigEndian.Uint32(last[8:12]) != 0 {
+			t.Fatal("wrong NAT-PMP unmapping request:", last)
+		}
+	}
+}
+
+// TestNATNoResponse checks that mapping fails when the router does not
+// answer.
+func TestNATNoResponse(t *testing.T) {
+	fr := newFakeRouter(t, true)
+	defer fr.conn.Close()
+	fr.mu.Lock()
+	fr.drop = 1000
+	fr.mu.Unlock()
+
+	if _, _, _, err := natMapPort(fr.addr(), fastrand.Bytes(12), 9981); err == nil {
+		t.Fatal("expected an error")
+	}
+	// Each protocol is tried natRequestAttempts times.
+	if n := len(fr.received()); n != 2*natRequestAttempts {
+		t.Fatal("wrong number of requests:", n)
+	}
+}
+
+// TestMaintainNATMapping checks that the gateway renews its port mapping
+// before it expires, reports it, and removes it on shutdown.
+func TestMaintainNATMapping(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	g := newTestingGateway(t)
+	fr := newFakeRouter(t, true)
+	defer fr.conn.Close()
+
+	go g.threadedMaintainNATMapping(fr.addr(), 9981)
+	// The mapping lasts 2 seconds, so it is renewed every second.
+	time.Sleep(2500 * time.Millisecond)
+	pm := g.PortMapping()
+	if !pm.Mapped || pm.Protocol != modules.PortMappingPCP || pm.ExternalAddress != "1.2.3.4:4321" || pm.Error != "" {
+		t.Fatal("wrong port mapping:", pm)
+	}
+	if n := len(fr.received()); n < 3 {
+		t.Fatal("mapping was not renewed:", n)
+	}
+
+	// If the router stops answering, the mapping is reported as lost once
+	// it expires.
+	fr.mu.Lock()
+	fr.drop = 1000
+	fr.mu.Unlock()
+	time.Sleep(3 * time.Second)
+	if pm := g.PortMapping(); pm.Mapped || pm.Error == "" {
+		t.Fatal("expired mapping reported as mapped:", pm)
+	}
+
+	fr.mu.Lock()
+	fr.drop = 0
+	fr.mu.Unlock()
+	time.Sleep(time.Second)
+	if err := g.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reqs := fr.received()
+	if last := reqs[len(reqs)-1]; binary.BigEndian.Uint32(last[4:8]) != 0 {
+		t.Fatal("mapping was not removed on shutdown")
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
 	"github.com/NebulousLabs/fastrand"
 )
 
@@ -16,6 +17,35 @@ var (
 	errOurAddress = errors.New("can't add our own address")
 )
 
+// A node represents a potential peer on the Sia network.
+type node struct {
+	NetAddress modules.NetAddress `json:"netaddress"`
+	FirstSeen  time.Time          `json:"firstseen"`
+
+	// QUIC is true if the node accepted peer connections over QUIC when the
+	// gateway last connected to it.
+	QUIC bool `json:"quic,omitempty"`
+
+	// PublicKey is the identity key that the node presented the first time
+	// the gateway made an encrypted connection to it. It is empty if the
+	// gateway has never done so.
+	PublicKey types.SiaPublicKey `json:"publickey"`
+
+	// The connection history of the node, see reputation.go. LastSeen is
+	// the time of the last successful connection to the node, and
+	// LastAttempt the time of the last attempt.
+	Successes           uint64    `json:"successes,omitempty"`
+	Failures            uint64    `json:"failures,omitempty"`
+	ConsecutiveFailures uint64    `json:"consecutivefailures,omitempty"`
+	LastSeen            time.Time `json:"lastseen"`
+	LastAttempt         time.Time `json:"lastattempt"`
+
+	// The ban history of the node.
+	Bans          uint64    `json:"bans,omitempty"`
+	LastBanned    time.Time `json:"lastbanned"`
+	LastBanReason string    `json:"lastbanreason,omitempty"`
+}
+
 // addNode adds an address to the set of nodes on the network.
 func (g *Gateway) addNode(addr modules.NetAddress) error {
 	if addr == g.myAddr {
@@ -24,10 +54,15 @@ func (g *Gateway) addNode(addr modules.NetAddress) error {
 		return errNodeExists
 	} else if addr.IsStdValid() != nil {
 		return errors.New("address is not valid: " + string(addr))
-	} else if net.ParseIP(addr.Host()) == nil {
+	} else if net.ParseIP(addr.Host()) == nil && !(addr.IsOnion() && g.proxied()) {
 		return errors.New("address must be an IP address: " + string(addr))
+	} else if g.peerFilter.blacklist.matches(addr) {
+		return errPeerFiltered
+	}
+	g.nodes[addr] = &node{
+		NetAddress: addr,
+		FirstSeen:  time.Now(),
 	}
-	g.nodes[addr] = struct{}{}
 	return nil
 }
 
@@ -41,6 +76,10 @@ func (g *Gateway) pingNode(addr modules.NetAddress) error {
 		return err
 	}
 	defer conn.Close()
+	// Nodes on other networks are not considered reachable.
+	if err := connectNetworkHandshake(conn, types.NetworkID); err != nil {
+		return err
+	}
 	// If connection succeeds, supply an unacceptable version so that we
 	// will not be added as a peer.
 	//
@@ -62,8 +101,11 @@ func (g *Gateway) removeNode(addr modules.NetAddress) error {
 	return nil
 }
 
-// randomNode returns a random node from the gateway. An error can be returned
-// if there are no nodes in the node list.
+// randomNode returns a random node from the gateway. Nodes that are not
+// dialable over the address families that the machine has routes for, that
+// are not allowed by the peer filter, or that are backing off after failed
+// connection attempts, are not selected. An error can be returned if there
+// are no such nodes in the node list.
 func (g *Gateway) randomNode() (modules.NetAddress, error) {
 	if len(g.nodes) == 0 {
 		return "", errNoPeers
@@ -74,14 +116,22 @@ func (g *Gateway) randomNode() (modules.NetAddress, error) {
 	// every node on the network. If the network gets large, this algorithm
 	// will either need to be refactored, or more likely a cap on the size of
 	// g.nodes will need to be added.
-	r := fastrand.Intn(len(g.nodes))
-	for node := range g.nodes {
-		if r <= 0 {
-			return node, nil
+	// Proxied gateways leave routing to the proxy.
+	ipv4, ipv6 := true, true
+	if !g.proxied() {
+		ipv4, ipv6 = routableFamilies()
+	}
+	now := time.Now()
+	candidates := make([]modules.NetAddress, 0, len(g.nodes))
+	for node, n := range g.nodes {
+		if dialable(node, ipv4, ipv6, g.proxied()) && g.peerFilter.allows(node) && !g.banned(node) && !n.backingOff(now) {
+			candidates = append(candidates, node)
 		}
-		r--
 	}
-	return "", errNoPeers
+	if len(candidates) == 0 {
+		return "", errNoPeers
+	}
+	return candidates[fastrand.Intn(len(candidates))], nil
 }
 
 // shareNodes is the receiving end of the ShareNodes RPC. It writes up to 10
@@ -210,25 +260,23 @@ func (g *Gateway) permanentNodePurger(closeChan chan struct{}) {
 		// them.
 		g.mu.RLock()
 		_, exists := g.peers[node]
+		offline := g.offline
 		g.mu.RUnlock()
-		if exists {
+		if exists || offline {
 			continue
 		}
 
-		// Try connecting to the random node. If the node is not reachable,
-		// remove them from the node list.
+		// Try connecting to the random node, and record the outcome in the
+		// node's connection history. Nodes that are repeatedly unreachable
+		// are removed from the node list.
 		//
 		// NOTE: an error may be returned if the dial is canceled partway
-		// through, which would cause the node to be pruned even though it may
-		// be a good node. Because nodes are plentiful, this is an acceptable
+		// through, which would count as a failure even though the node may be
+		// a good node. Because nodes are plentiful, this is an acceptable
 		// bug.
 		err = g.pingNode(node)
-		if err != nil {
-			g.mu.Lock()
-			g.removeNode(node)
-			g.save()
-			g.mu.Unlock()
-			g.log.Debugf("INFO: removing node %q because it could not be reached during a random scan: %v", node, err)
+		if g.managedRecordConnection(node, err == nil) {
+			g.log.Debugf("INFO: removing node %q because it could not be reached during random scans: %v", node, err)
 		}
 	}
 }
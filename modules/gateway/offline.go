@@ -0,0 +1,95 @@
+package gateway
+
+import (
+	"errors"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// An operator can take the gateway off the network for maintenance without
+// shutting down siad. While the gateway is offline, it neither dials nor
+// accepts peers, and calls no RPCs; the other modules keep running, and see
+// a gateway without peers. Taking the gateway offline first stops new RPCs
+// and waits up to offlineFlushTimeout for the RPCs in progress to finish,
+// then disconnects from every peer. The offline state is saved, so a gateway
+// that is restarted during maintenance stays offline until it is brought
+// back online.
+
+// errGatewayOffline is returned when connecting to a peer or calling an RPC
+// while the gateway is offline.
+var errGatewayOffline = errors.New("gateway is offline")
+
+// managedFlushRPCs waits for the RPCs in progress to finish, for at most
+// offlineFlushTimeout.
+func (g *Gateway) managedFlushRPCs() {
+	flushed := make(chan struct{})
+	go func() {
+		g.rpcs.Wait()
+		close(flushed)
+	}()
+	select {
+	case <-flushed:
+	case <-time.After(offlineFlushTimeout):
+		g.log.Println("WARN: RPCs in progress did not finish before the gateway went offline")
+	case <-g.threads.StopChan():
+	}
+}
+
+// managedDisconnectAll disconnects from every peer if the gateway is still
+// offline.
+func (g *Gateway) managedDisconnectAll() {
+	g.mu.Lock()
+	if !g.offline {
+		g.mu.Unlock()
+		return
+	}
+	peers := g.peers
+	g.peers = make(map[modules.NetAddress]*peer)
+	g.mu.Unlock()
+	for addr, p := range peers {
+		if err := p.sess.Close(); err != nil {
+			g.log.Debugf("WARN: error disconnecting from %v when going offline: %v", addr, err)
+		}
+	}
+}
+
+// Offline returns true if the gateway has been taken off the network.
+func (g *Gateway) Offline() bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.offline
+}
+
+// SetOffline takes the gateway off the network, flushing the RPCs in progress
+// and disconnecting from every peer, or brings it back online. The state is
+// saved to disk.
+func (g *Gateway) SetOffline(offline bool) error {
+	if err := g.threads.Add(); err != nil {
+		return err
+	}
+	defer g.threads.Done()
+
+	g.mu.Lock()
+	if g.offline == offline {
+		g.mu.Unlock()
+		return nil
+	}
+	g.offline = offline
+	err := g.saveSettings()
+	g.mu.Unlock()
+
+	if !offline {
+		g.log.Println("INFO: gateway is back online")
+		// Wake the anchor manager.
+		select {
+		case g.anchorWake <- struct{}{}:
+		default:
+		}
+		return err
+	}
+	g.managedFlushRPCs()
+	g.managedDisconnectAll()
+	g.log.Println("INFO: gateway is offline")
+	return err
+}
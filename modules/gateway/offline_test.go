@@ -0,0 +1,80 @@
+package gateway
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestSetOffline checks that an offline gateway disconnects from its peers,
+// refuses connections in both directions, and flushes the RPCs in progress
+// before disconnecting.
+func TestSetOffline(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	g1 := newNamedTestingGateway(t, "1")
+	defer g1.Close()
+	g2 := newNamedTestingGateway(t, "2")
+	defer g2.Close()
+
+	// An RPC in progress when the gateway goes offline is allowed to finish.
+	started := make(chan struct{})
+	g2.RegisterRPC("Slow", func(conn modules.PeerConn) error {
+		close(started)
+		time.Sleep(500 * time.Millisecond)
+		_, err := conn.Write([]byte{1})
+		return err
+	})
+	defer g2.UnregisterRPC("Slow")
+	if err := g1.Connect(g2.Address()); err != nil {
+		t.Fatal(err)
+	}
+	rpcErr := make(chan error)
+	go func() {
+		rpcErr <- g1.RPC(g2.Address(), "Slow", func(conn modules.PeerConn) error {
+			var b [1]byte
+			_, err := conn.Read(b[:])
+			return err
+		})
+	}()
+	<-started
+	if err := g1.SetOffline(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-rpcErr; err != nil {
+		t.Fatal("RPC in progress was not flushed:", err)
+	}
+	if !g1.Offline() || len(g1.Peers()) != 0 {
+		t.Fatal("gateway did not disconnect when going offline:", g1.Peers())
+	}
+
+	// Offline gateways neither dial nor accept peers.
+	if err := g1.Connect(g2.Address()); err != errGatewayOffline {
+		t.Fatal("expected errGatewayOffline, got", err)
+	}
+	if err := g2.Connect(g1.Address()); err == nil {
+		t.Fatal("offline gateway accepted a peer")
+	}
+
+	// The offline state survives a restart.
+	if err := g1.Close(); err != nil {
+		t.Fatal(err)
+	}
+	g1, err := New("localhost:0", false, g1.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g1.Close()
+	if !g1.Offline() {
+		t.Fatal("gateway came back online after a restart")
+	}
+	if err := g1.SetOffline(false); err != nil {
+		t.Fatal(err)
+	}
+	if err := g1.Connect(g2.Address()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,109 @@
+package gateway
+
+import (
+	"errors"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/fastrand"
+)
+
+// The operator can limit the number of peers that the gateway connects to.
+// MaxInbound caps the number of inbound peers. When the cap is reached, a
+// new inbound peer takes the place of a random existing inbound peer,
+// preferably one from the same address group, so that the gateway keeps
+// meeting new peers. Outbound, local, and whitelisted peers are never kicked,
+// and if nobody can be kicked the new peer is turned away. Local peers are
+// exempt from the cap. Whitelisted peers may use WhitelistSlots slots beyond
+// the cap. TargetOutbound is the number of outbound peers that the gateway
+// tries to keep. The limits are saved in the gateway's settings file.
+
+var (
+	// errNegativePeerLimit is returned when setting a negative peer limit.
+	errNegativePeerLimit = errors.New("peer limits cannot be negative")
+
+	// errPeerLimit is returned when an inbound peer is turned away because
+	// the gateway has reached its inbound limit.
+	errPeerLimit = errors.New("gateway has reached its limit of inbound peers")
+)
+
+// defaultPeerLimits returns the peer limits that a new gateway starts with.
+func defaultPeerLimits() modules.GatewayPeerLimits {
+	return modules.GatewayPeerLimits{
+		MaxInbound:     fullyConnectedThreshold,
+		TargetOutbound: wellConnectedThreshold,
+	}
+}
+
+// validatePeerLimits returns an error if the peer limits are invalid.
+func validatePeerLimits(pl modules.GatewayPeerLimits) error {
+	if pl.MaxInbound < 0 || pl.TargetOutbound < 0 || pl.WhitelistSlots < 0 {
+		return errNegativePeerLimit
+	}
+	return nil
+}
+
+// numInboundPeers returns the number of inbound peers.
+func (g *Gateway) numInboundPeers() (n int) {
+	for _, p := range g.peers {
+		if p.Inbound {
+			n++
+		}
+	}
+	return n
+}
+
+// inboundLimit returns the number of inbound peers at which the gateway
+// starts kicking peers to make room for the peer at addr.
+func (g *Gateway) inboundLimit(addr modules.NetAddress) int {
+	limit := g.peerLimits.MaxInbound
+	if g.peerFilter.whitelist.matches(addr) {
+		limit += g.peerLimits.WhitelistSlots
+	}
+	return limit
+}
+
+// kickable returns true if the peer may be kicked to make room for another.
+func (g *Gateway) kickable(p *peer) bool {
+	return p.Inbound && !p.Local && !g.isAnchor(p.NetAddress) && !g.peerFilter.whitelist.matches(p.NetAddress)
+}
+
+// PeerLimits returns the limits on the number of peers that the gateway
+// connects to.
+func (g *Gateway) PeerLimits() modules.GatewayPeerLimits {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.peerLimits
+}
+
+// SetPeerLimits changes the limits on the number of peers that the gateway
+// connects to. If the inbound limit is lowered, inbound peers are kicked until
+// the gateway is within it. The limits are saved to disk.
+func (g *Gateway) SetPeerLimits(pl modules.GatewayPeerLimits) error {
+	if err := g.threads.Add(); err != nil {
+		return err
+	}
+	defer g.threads.Done()
+	if err := validatePeerLimits(pl); err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.peerLimits = pl
+	for g.numInboundPeers() > pl.MaxInbound {
+		var addrs []modules.NetAddress
+		for addr, p := range g.peers {
+			if g.kickable(p) {
+				addrs = append(addrs, addr)
+			}
+		}
+		if len(addrs) == 0 {
+			break
+		}
+		kick := addrs[fastrand.Intn(len(addrs))]
+		g.peers[kick].sess.Close()
+		delete(g.peers, kick)
+		g.log.Printf("INFO: disconnected from %v to respect the new inbound limit\n", kick)
+	}
+	return g.saveSettings()
+}
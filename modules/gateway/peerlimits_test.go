@@ -0,0 +1,72 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestInboundLimit checks that whitelisted peers get extra inbound slots, and
+// that only non-local, non-whitelisted inbound peers can be kicked.
+func TestInboundLimit(t *testing.T) {
+	pf, err := parsePeerFilter(modules.GatewayPeerFilter{Whitelist: []string{"10.0.0.0/8"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := &Gateway{
+		peerFilter: pf,
+		peerLimits: modules.GatewayPeerLimits{MaxInbound: 8, WhitelistSlots: 2},
+	}
+	if limit := g.inboundLimit("10.1.2.3:9981"); limit != 10 {
+		t.Fatal("whitelisted peer should have 10 inbound slots, got", limit)
+	}
+	if limit := g.inboundLimit("203.0.113.8:9981"); limit != 8 {
+		t.Fatal("other peer should have 8 inbound slots, got", limit)
+	}
+
+	tests := []struct {
+		peer     modules.Peer
+		kickable bool
+	}{
+		{modules.Peer{NetAddress: "203.0.113.8:9981", Inbound: true}, true},
+		{modules.Peer{NetAddress: "203.0.113.8:9981", Inbound: false}, false},
+		{modules.Peer{NetAddress: "203.0.113.8:9981", Inbound: true, Local: true}, false},
+		{modules.Peer{NetAddress: "10.1.2.3:9981", Inbound: true}, false},
+	}
+	for _, test := range tests {
+		if g.kickable(&peer{Peer: test.peer}) != test.kickable {
+			t.Errorf("expected kickable(%v) to be %v", test.peer, test.kickable)
+		}
+	}
+}
+
+// TestPeerLimitsPersist checks that the gateway's peer limits persist across
+// restarts, and that invalid limits are rejected.
+func TestPeerLimitsPersist(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	g := newTestingGateway(t)
+	if g.PeerLimits() != defaultPeerLimits() {
+		t.Fatal("new gateway does not use the default peer limits:", g.PeerLimits())
+	}
+	pl := modules.GatewayPeerLimits{MaxInbound: 12, TargetOutbound: 4, WhitelistSlots: 3}
+	if err := g.SetPeerLimits(pl); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.SetPeerLimits(modules.GatewayPeerLimits{TargetOutbound: -1}); err != errNegativePeerLimit {
+		t.Fatal("expected errNegativePeerLimit, got", err)
+	}
+	if err := g.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := New("localhost:0", false, g.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	if g.PeerLimits() != pl {
+		t.Fatalf("expected peer limits %v after restart, got %v", pl, g.PeerLimits())
+	}
+}
@@ -4,11 +4,14 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"sort"
+	"sync/atomic"
 	"time"
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
 	"github.com/NebulousLabs/fastrand"
 	"github.com/NebulousLabs/muxado"
 )
@@ -16,6 +19,7 @@ import (
 var (
 	errPeerExists       = errors.New("already connected to this peer")
 	errPeerRejectedConn = errors.New("peer rejected connection")
+	errWrongNetwork     = errors.New("peer is not on the same network")
 )
 
 // insufficientVersionError indicates a peer's version is insufficient.
@@ -35,8 +39,99 @@ func (s invalidVersionError) Error() string {
 }
 
 type peer struct {
+	// Relay statistics, accessed atomically. Kept at the top of the struct
+	// to guarantee 64-bit alignment.
+	atomicRPCsCalled   uint64
+	atomicRPCsFailed   uint64
+	atomicRPCsReceived uint64
+
+	// atomicRPCNanos is the total time taken by the successful RPCs called
+	// on the peer, and atomicBytesRead and atomicBytesWritten count the
+	// traffic of the peer's connection.
+	atomicRPCNanos     uint64
+	atomicBytesRead    uint64
+	atomicBytesWritten uint64
+
+	// atomicLatencyNanos is the smoothed round-trip time to the peer, as
+	// measured by the Ping RPC. It is zero until the first measurement.
+	atomicLatencyNanos uint64
+
+	// atomicLastActive is the time, in unix nanoseconds, at which the peer
+	// last called an RPC or answered one of ours.
+	atomicLastActive int64
+
 	modules.Peer
-	sess muxado.Session
+	connectedSince time.Time
+	sess           muxado.Session
+	transport      string
+}
+
+// peerCountingConn is a net.Conn that counts the bytes read from and written
+// to it in the stats of its peer.
+type peerCountingConn struct {
+	net.Conn
+	peer *peer
+}
+
+// Read implements the io.Reader interface.
+func (pc *peerCountingConn) Read(b []byte) (int, error) {
+	n, err := pc.Conn.Read(b)
+	atomic.AddUint64(&pc.peer.atomicBytesRead, uint64(n))
+	return n, err
+}
+
+// Write implements the io.Writer interface.
+func (pc *peerCountingConn) Write(b []byte) (int, error) {
+	n, err := pc.Conn.Write(b)
+	atomic.AddUint64(&pc.peer.atomicBytesWritten, uint64(n))
+	return n, err
+}
+
+// newPeer returns a peer that communicates over conn, counting the traffic of
+// the connection in the peer's stats. The gateway is the server of the
+// session for inbound peers and the client for outbound peers.
+func newPeer(info modules.Peer, conn net.Conn) *peer {
+	p := &peer{
+		Peer:      info,
+		transport: connTransport(conn),
+	}
+	pc := &peerCountingConn{Conn: conn, peer: p}
+	if info.Inbound {
+		p.sess = muxado.Server(pc)
+	} else {
+		p.sess = muxado.Client(pc)
+	}
+	return p
+}
+
+// stats returns the peer along with its relay statistics.
+func (p *peer) stats() modules.PeerStats {
+	ps := modules.PeerStats{
+		Peer:           p.Peer,
+		ConnectedSince: p.connectedSince,
+		Uptime:         time.Since(p.connectedSince),
+		Transport:      p.transport,
+		RPCsCalled:     atomic.LoadUint64(&p.atomicRPCsCalled),
+		RPCsFailed:     atomic.LoadUint64(&p.atomicRPCsFailed),
+		RPCsReceived:   atomic.LoadUint64(&p.atomicRPCsReceived),
+		BytesRead:      atomic.LoadUint64(&p.atomicBytesRead),
+		BytesWritten:   atomic.LoadUint64(&p.atomicBytesWritten),
+	}
+	ps.Latency = p.latency()
+	if ps.RPCsCalled > ps.RPCsFailed {
+		ps.AverageRPCTime = time.Duration(atomic.LoadUint64(&p.atomicRPCNanos) / (ps.RPCsCalled - ps.RPCsFailed))
+	}
+	return ps
+}
+
+// markActive records that the peer has just been heard from.
+func (p *peer) markActive() {
+	atomic.StoreInt64(&p.atomicLastActive, time.Now().UnixNano())
+}
+
+// idleSince returns the time at which the peer was last heard from.
+func (p *peer) idleSince() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&p.atomicLastActive))
 }
 
 func (p *peer) open() (modules.PeerConn, error) {
@@ -58,6 +153,8 @@ func (p *peer) accept() (modules.PeerConn, error) {
 // addPeer adds a peer to the Gateway's peer list and spawns a listener thread
 // to handle its requests.
 func (g *Gateway) addPeer(p *peer) {
+	p.connectedSince = time.Now()
+	p.markActive()
 	g.peers[p.NetAddress] = p
 	go g.threadedListenPeer(p)
 }
@@ -93,7 +190,7 @@ func (g *Gateway) permanentListen(closeChan chan struct{}) {
 			return
 		}
 
-		go g.threadedAcceptConn(conn)
+		go g.threadedAcceptConn(g.countConn(conn, transportTCP))
 
 		// Sleep after each accept. This limits the rate at which the Gateway
 		// will accept new connections. The intent here is to prevent new
@@ -119,6 +216,25 @@ func (g *Gateway) threadedAcceptConn(conn net.Conn) {
 	addr := modules.NetAddress(conn.RemoteAddr().String())
 	g.log.Debugf("INFO: %v wants to connect", addr)
 
+	g.mu.RLock()
+	allowed := g.peerFilter.allows(addr)
+	offline := g.offline
+	g.mu.RUnlock()
+	if offline {
+		g.log.Debugf("INFO: %v wanted to connect but the gateway is offline", addr)
+		conn.Close()
+		return
+	} else if !allowed {
+		g.log.Debugf("INFO: %v wanted to connect but is not allowed by the peer filter", addr)
+		conn.Close()
+		return
+	}
+
+	if err := acceptConnNetworkHandshake(conn, types.NetworkID); err != nil {
+		g.log.Debugf("INFO: %v wanted to connect but network handshake failed: %v", addr, err)
+		conn.Close()
+		return
+	}
 	remoteVersion, err := acceptConnVersionHandshake(conn, build.Version)
 	if err != nil {
 		g.log.Debugf("INFO: %v wanted to connect but version handshake failed: %v", addr, err)
@@ -126,10 +242,22 @@ func (g *Gateway) threadedAcceptConn(conn net.Conn) {
 		return
 	}
 
+	// Encrypt the rest of the connection if the peer supports it.
+	var remoteKey types.SiaPublicKey
+	if build.VersionCmp(remoteVersion, encryptionUpgradeVersion) >= 0 {
+		econn, key, err := acceptConnEncryptionHandshake(conn, g.identity)
+		if err != nil {
+			g.log.Debugf("INFO: %v wanted to connect but encryption handshake failed: %v", addr, err)
+			conn.Close()
+			return
+		}
+		conn, remoteKey = econn, key
+	}
+
 	if build.VersionCmp(remoteVersion, handshakeUpgradeVersion) < 0 {
 		err = g.managedAcceptConnOldPeer(conn, remoteVersion)
 	} else {
-		err = g.managedAcceptConnNewPeer(conn, remoteVersion)
+		err = g.managedAcceptConnNewPeer(conn, remoteVersion, remoteKey)
 	}
 	if err != nil {
 		g.log.Debugf("INFO: %v wanted to connect, but failed: %v", addr, err)
@@ -138,6 +266,9 @@ func (g *Gateway) threadedAcceptConn(conn net.Conn) {
 	}
 	// Handshake successful, remove the deadline.
 	conn.SetDeadline(time.Time{})
+	if cc := connCounter(conn); cc != nil {
+		atomic.AddUint64(&cc.stats.atomicInbound, 1)
+	}
 
 	g.log.Debugf("INFO: accepted connection from new peer %v (v%v)", addr, remoteVersion)
 }
@@ -154,23 +285,24 @@ func (g *Gateway) managedAcceptConnOldPeer(conn net.Conn, remoteVersion string)
 
 	// Old peers are unable to give us a dialback port, so we can't verify
 	// whether or not they are local peers.
-	g.acceptPeer(&peer{
-		Peer: modules.Peer{
-			Inbound:    true,
-			Local:      false,
-			NetAddress: addr,
-			Version:    remoteVersion,
-		},
-		sess: muxado.Server(conn),
-	})
+	err := g.acceptPeer(newPeer(modules.Peer{
+		Inbound:    true,
+		Local:      false,
+		NetAddress: addr,
+		Version:    remoteVersion,
+	}, conn))
+	if err != nil {
+		return err
+	}
 	g.addNode(addr)
 	return g.save()
 }
 
 // managedAcceptConnNewPeer accepts connection requests from peers >= v1.0.0.
 // The requesting peer is added as a node and a peer. The peer is only added if
-// a nil error is returned.
-func (g *Gateway) managedAcceptConnNewPeer(conn net.Conn, remoteVersion string) error {
+// a nil error is returned. remoteKey is empty if the connection is not
+// encrypted.
+func (g *Gateway) managedAcceptConnNewPeer(conn net.Conn, remoteVersion string, remoteKey types.SiaPublicKey) error {
 	// Learn the peer's dialback address. Peers older than v1.0.0 will only be
 	// able to be discovered by newer peers via the ShareNodes RPC.
 	remoteAddr, err := acceptConnPortHandshake(conn)
@@ -181,22 +313,31 @@ func (g *Gateway) managedAcceptConnNewPeer(conn net.Conn, remoteVersion string)
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
+	if !g.peerFilter.allows(remoteAddr) {
+		return errPeerFiltered
+	} else if g.banned(remoteAddr) {
+		return errPeerBanned
+	}
 	// Don't accept a connection from a peer we're already connected to.
 	if _, exists := g.peers[remoteAddr]; exists {
 		return fmt.Errorf("already connected to a peer on that address: %v", remoteAddr)
 	}
+	if err := g.checkNodeKey(remoteAddr, remoteKey); err != nil {
+		return err
+	}
 	// Accept the peer.
-	g.acceptPeer(&peer{
-		Peer: modules.Peer{
-			Inbound: true,
-			// NOTE: local may be true even if the supplied remoteAddr is not
-			// actually reachable.
-			Local:      remoteAddr.IsLocal(),
-			NetAddress: remoteAddr,
-			Version:    remoteVersion,
-		},
-		sess: muxado.Server(conn),
-	})
+	err = g.acceptPeer(newPeer(modules.Peer{
+		Inbound: true,
+		// NOTE: local may be true even if the supplied remoteAddr is not
+		// actually reachable.
+		Local:      remoteAddr.IsLocal(),
+		NetAddress: remoteAddr,
+		PublicKey:  remoteKey,
+		Version:    remoteVersion,
+	}, conn))
+	if err != nil {
+		return err
+	}
 
 	// Attempt to ping the supplied address. If successful, we will add
 	// remoteAddr to our node list after accepting the peer. We do this in a
@@ -206,6 +347,7 @@ func (g *Gateway) managedAcceptConnNewPeer(conn net.Conn, remoteVersion string)
 		if err == nil {
 			g.mu.Lock()
 			g.addNode(remoteAddr)
+			g.recordNodeKey(remoteAddr, remoteKey)
 			g.save()
 			g.mu.Unlock()
 		}
@@ -214,35 +356,41 @@ func (g *Gateway) managedAcceptConnNewPeer(conn net.Conn, remoteVersion string)
 	return nil
 }
 
-// acceptPeer makes room for the peer if necessary by kicking out existing
-// peers, then adds the peer to the peer list.
-func (g *Gateway) acceptPeer(p *peer) {
-	// If we are not fully connected, add the peer without kicking any out.
-	if len(g.peers) < fullyConnectedThreshold {
+// acceptPeer makes room for an inbound peer if necessary by kicking out an
+// existing inbound peer, then adds the peer to the peer list. errPeerLimit is
+// returned if the gateway is full and nobody can be kicked.
+func (g *Gateway) acceptPeer(p *peer) error {
+	// If we have not reached the inbound limit, add the peer without kicking
+	// any out.
+	if g.numInboundPeers() < g.inboundLimit(p.NetAddress) {
 		g.addPeer(p)
-		return
+		return nil
 	}
 
-	// Select a peer to kick. Outbound peers and local peers are not
-	// available to be kicked.
+	// Select a peer to kick. Outbound peers, local peers, anchor peers, and
+	// whitelisted peers are not available to be kicked.
 	var addrs []modules.NetAddress
-	for addr := range g.peers {
-		// Do not kick outbound peers or local peers.
-		if !p.Inbound || p.Local {
+	for addr, existing := range g.peers {
+		if !g.kickable(existing) {
 			continue
 		}
 
-		// Prefer kicking a peer with the same hostname.
-		if addr.Host() == p.NetAddress.Host() {
+		// Prefer kicking a peer with the same hostname, or from the same
+		// IPv6 /64 prefix.
+		if addressGroup(addr) == addressGroup(p.NetAddress) {
 			addrs = []modules.NetAddress{addr}
 			break
 		}
 		addrs = append(addrs, addr)
 	}
 	if len(addrs) == 0 {
-		// There is nobody suitable to kick, therefore do not kick anyone.
+		// There is nobody suitable to kick. Local peers and anchor peers are
+		// exempt from the limit, everyone else is turned away.
+		if !p.Local && !g.isAnchor(p.NetAddress) {
+			return errPeerLimit
+		}
 		g.addPeer(p)
-		return
+		return nil
 	}
 
 	// Of the remaining options, select one at random.
@@ -252,6 +400,7 @@ func (g *Gateway) acceptPeer(p *peer) {
 	delete(g.peers, kick)
 	g.log.Printf("INFO: disconnected from %v to make room for %v\n", kick, p.NetAddress)
 	g.addPeer(p)
+	return nil
 }
 
 // acceptConnPortHandshake performs the port handshake and should be called on
@@ -348,20 +497,73 @@ func acceptConnVersionHandshake(conn net.Conn, version string) (remoteVersion st
 	return remoteVersion, nil
 }
 
+// connectNetworkHandshake performs the network handshake and should be called
+// on the side making the connection request, before the version handshake.
+// The handshake is only performed on private networks. Nodes on the public
+// network read our network ID as an invalid version and reject us, so peers on
+// different networks never connect to each other.
+func connectNetworkHandshake(conn net.Conn, networkID string) error {
+	if networkID == "" {
+		return nil
+	}
+	// Send our network ID.
+	if err := encoding.WriteObject(conn, networkID); err != nil {
+		return fmt.Errorf("failed to write network ID: %v", err)
+	}
+	// Read the remote network ID.
+	var remoteNetworkID string
+	if err := encoding.ReadObject(conn, &remoteNetworkID, build.MaxEncodedVersionLength); err != nil {
+		return fmt.Errorf("failed to read remote network ID: %v", err)
+	}
+	if remoteNetworkID != networkID {
+		return errWrongNetwork
+	}
+	return nil
+}
+
+// acceptConnNetworkHandshake performs the network handshake and should be
+// called on the side accepting a connection request, before the version
+// handshake. The handshake is only performed on private networks. Nodes on the
+// public network send their version instead of a network ID, so they are
+// rejected.
+func acceptConnNetworkHandshake(conn net.Conn, networkID string) error {
+	if networkID == "" {
+		return nil
+	}
+	// Read the remote network ID.
+	var remoteNetworkID string
+	if err := encoding.ReadObject(conn, &remoteNetworkID, build.MaxEncodedVersionLength); err != nil {
+		return fmt.Errorf("failed to read remote network ID: %v", err)
+	}
+	if remoteNetworkID != networkID {
+		if err := encoding.WriteObject(conn, "reject"); err != nil {
+			return fmt.Errorf("failed to write reject: %v", err)
+		}
+		return errWrongNetwork
+	}
+	// Send our network ID.
+	if err := encoding.WriteObject(conn, networkID); err != nil {
+		return fmt.Errorf("failed to write network ID: %v", err)
+	}
+	return nil
+}
+
 // managedConnectOldPeer connects to peers < v1.0.0. The peer is added as a
 // node and a peer. The peer is only added if a nil error is returned.
 func (g *Gateway) managedConnectOldPeer(conn net.Conn, remoteVersion string, remoteAddr modules.NetAddress) error {
 	g.mu.Lock()
 	defer g.mu.Unlock()
-	g.addPeer(&peer{
-		Peer: modules.Peer{
-			Inbound:    false,
-			Local:      remoteAddr.IsLocal(),
-			NetAddress: remoteAddr,
-			Version:    remoteVersion,
-		},
-		sess: muxado.Client(conn),
-	})
+	// Another connection to the peer may have been made while this one was
+	// being initialized.
+	if _, exists := g.peers[remoteAddr]; exists {
+		return errPeerExists
+	}
+	g.addPeer(newPeer(modules.Peer{
+		Inbound:    false,
+		Local:      remoteAddr.IsLocal(),
+		NetAddress: remoteAddr,
+		Version:    remoteVersion,
+	}, conn))
 	// Add the peer to the node list. We can ignore the error: addNode
 	// validates the address and checks for duplicates, but we don't care
 	// about duplicates and we have already validated the address by
@@ -372,7 +574,8 @@ func (g *Gateway) managedConnectOldPeer(conn net.Conn, remoteVersion string, rem
 
 // managedConnectNewPeer connects to peers >= v1.0.0. The peer is added as a
 // node and a peer. The peer is only added if a nil error is returned.
-func (g *Gateway) managedConnectNewPeer(conn net.Conn, remoteVersion string, remoteAddr modules.NetAddress) error {
+// remoteKey is empty if the connection is not encrypted.
+func (g *Gateway) managedConnectNewPeer(conn net.Conn, remoteVersion string, remoteAddr modules.NetAddress, remoteKey types.SiaPublicKey) error {
 	g.mu.RLock()
 	port := g.port
 	g.mu.RUnlock()
@@ -385,23 +588,69 @@ func (g *Gateway) managedConnectNewPeer(conn net.Conn, remoteVersion string, rem
 
 	g.mu.Lock()
 	defer g.mu.Unlock()
-	g.addPeer(&peer{
-		Peer: modules.Peer{
-			Inbound:    false,
-			Local:      remoteAddr.IsLocal(),
-			NetAddress: remoteAddr,
-			Version:    remoteVersion,
-		},
-		sess: muxado.Client(conn),
-	})
+	// Another connection to the peer may have been made while this one was
+	// being initialized.
+	if _, exists := g.peers[remoteAddr]; exists {
+		return errPeerExists
+	}
+	if err := g.checkNodeKey(remoteAddr, remoteKey); err != nil {
+		return err
+	}
+	g.addPeer(newPeer(modules.Peer{
+		Inbound:    false,
+		Local:      remoteAddr.IsLocal(),
+		NetAddress: remoteAddr,
+		PublicKey:  remoteKey,
+		Version:    remoteVersion,
+	}, conn))
 	// Add the peer to the node list. We can ignore the error: addNode
 	// validates the address and checks for duplicates, but we don't care
 	// about duplicates and we have already validated the address by
 	// connecting to it.
 	g.addNode(remoteAddr)
+	g.recordNodeKey(remoteAddr, remoteKey)
 	return g.save()
 }
 
+// managedDialAndInitPeer dials the peer at addr and performs peer
+// initialization, returning the connection to the peer.
+func (g *Gateway) managedDialAndInitPeer(addr modules.NetAddress) (net.Conn, error) {
+	conn, err := g.managedDialPeer(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Perform peer initialization.
+	if err := connectNetworkHandshake(conn, types.NetworkID); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	remoteVersion, err := connectVersionHandshake(conn, build.Version)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	var remoteKey types.SiaPublicKey
+	if build.VersionCmp(remoteVersion, encryptionUpgradeVersion) >= 0 {
+		econn, key, err := connectEncryptionHandshake(conn, g.identity)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn, remoteKey = econn, key
+	}
+	if build.VersionCmp(remoteVersion, handshakeUpgradeVersion) < 0 {
+		err = g.managedConnectOldPeer(conn, remoteVersion, addr)
+	} else {
+		err = g.managedConnectNewPeer(conn, remoteVersion, addr, remoteKey)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
 // managedConnect establishes a persistent connection to a peer, and adds it to
 // the Gateway's peer list.
 func (g *Gateway) managedConnect(addr modules.NetAddress) error {
@@ -415,35 +664,32 @@ func (g *Gateway) managedConnect(addr modules.NetAddress) error {
 	if err := addr.IsStdValid(); err != nil {
 		return errors.New("can't connect to invalid address")
 	}
-	if net.ParseIP(addr.Host()) == nil {
+	if net.ParseIP(addr.Host()) == nil && !(addr.IsOnion() && g.proxied()) {
 		return errors.New("address must be an IP address")
 	}
 	g.mu.RLock()
 	_, exists := g.peers[addr]
+	allowed := g.peerFilter.allows(addr)
+	banned := g.banned(addr)
+	offline := g.offline
 	g.mu.RUnlock()
-	if exists {
+	if offline {
+		return errGatewayOffline
+	} else if exists {
 		return errPeerExists
+	} else if !allowed {
+		return errPeerFiltered
+	} else if banned {
+		return errPeerBanned
 	}
 
-	// Dial the peer and perform peer initialization.
-	conn, err := g.dial(addr)
-	if err != nil {
-		return err
-	}
-
-	// Perform peer initialization.
-	remoteVersion, err := connectVersionHandshake(conn, build.Version)
-	if err != nil {
-		conn.Close()
-		return err
-	}
-	if build.VersionCmp(remoteVersion, handshakeUpgradeVersion) < 0 {
-		err = g.managedConnectOldPeer(conn, remoteVersion, addr)
-	} else {
-		err = g.managedConnectNewPeer(conn, remoteVersion, addr)
+	// Dial the peer and perform peer initialization, recording the outcome
+	// in the node's connection history.
+	conn, err := g.managedDialAndInitPeer(addr)
+	if g.managedRecordConnection(addr, err == nil || err == errPeerExists) {
+		g.log.Debugf("INFO: removing node %q after %v failed connection attempts in a row", addr, maxNodeFailures)
 	}
 	if err != nil {
-		conn.Close()
 		return err
 	}
 	g.log.Debugln("INFO: connected to new peer", addr)
@@ -490,21 +736,25 @@ func (g *Gateway) Disconnect(addr modules.NetAddress) error {
 	}
 	defer g.threads.Done()
 
-	g.mu.RLock()
+	if err := g.managedDisconnect(addr); err != nil {
+		return err
+	}
+	g.log.Println("INFO: disconnected from peer", addr)
+	return nil
+}
+
+// managedDisconnect closes the connection to a peer and removes it from the
+// peer list.
+func (g *Gateway) managedDisconnect(addr modules.NetAddress) error {
+	g.mu.Lock()
 	p, exists := g.peers[addr]
-	g.mu.RUnlock()
 	if !exists {
+		g.mu.Unlock()
 		return errors.New("not connected to that node")
 	}
-	g.mu.Lock()
 	delete(g.peers, addr)
 	g.mu.Unlock()
-	if err := p.sess.Close(); err != nil {
-		return err
-	}
-
-	g.log.Println("INFO: disconnected from peer", addr)
-	return nil
+	return p.sess.Close()
 }
 
 // Peers returns the addresses currently connected to the Gateway.
@@ -512,8 +762,65 @@ func (g *Gateway) Peers() []modules.Peer {
 	g.mu.RLock()
 	defer g.mu.RUnlock()
 	var peers []modules.Peer
-	for _, p := range g.peers {
-		peers = append(peers, p.Peer)
+	for addr, p := range g.peers {
+		peer := p.Peer
+		peer.BanScore = g.peerBanScore(addr)
+		peer.Latency = p.latency()
+		peers = append(peers, peer)
 	}
 	return peers
 }
+
+// Topology returns a snapshot of the Gateway's current view of the network,
+// including relay statistics for each peer and the age of each node in the
+// node list. Peers and nodes are sorted by address.
+func (g *Gateway) Topology() modules.NetworkTopology {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	topology := modules.NetworkTopology{
+		Address:   g.myAddr,
+		Version:   build.Version,
+		Timestamp: time.Now(),
+		Nodes:     make([]modules.NodeInfo, 0, len(g.nodes)),
+	}
+	topology.Peers = append(topology.Peers, g.peerStats()...)
+	for _, n := range g.nodes {
+		topology.Nodes = append(topology.Nodes, n.info())
+	}
+	sort.Sort(nodeInfoByAddress(topology.Nodes))
+	return topology
+}
+
+// peerStats returns the relay statistics of every peer, sorted by address.
+func (g *Gateway) peerStats() []modules.PeerStats {
+	stats := make([]modules.PeerStats, 0, len(g.peers))
+	for addr, p := range g.peers {
+		ps := p.stats()
+		ps.BanScore = g.peerBanScore(addr)
+		stats = append(stats, ps)
+	}
+	sort.Sort(peerStatsByAddress(stats))
+	return stats
+}
+
+// PeerStats returns the traffic, RPC counts, RPC latency, and uptime of each
+// peer, sorted by address, so that slow or abusive peers can be identified.
+func (g *Gateway) PeerStats() []modules.PeerStats {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.peerStats()
+}
+
+// peerStatsByAddress sorts a slice of PeerStats by NetAddress.
+type peerStatsByAddress []modules.PeerStats
+
+func (ps peerStatsByAddress) Len() int           { return len(ps) }
+func (ps peerStatsByAddress) Less(i, j int) bool { return ps[i].NetAddress < ps[j].NetAddress }
+func (ps peerStatsByAddress) Swap(i, j int)      { ps[i], ps[j] = ps[j], ps[i] }
+
+// nodeInfoByAddress sorts a slice of NodeInfo by NetAddress.
+type nodeInfoByAddress []modules.NodeInfo
+
+func (ns nodeInfoByAddress) Len() int           { return len(ns) }
+func (ns nodeInfoByAddress) Less(i, j int) bool { return ns[i].NetAddress < ns[j].NetAddress }
+func (ns nodeInfoByAddress) Swap(i, j int)      { ns[i], ns[j] = ns[j], ns[i] }
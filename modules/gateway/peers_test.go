@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/fastrand"
 	"github.com/NebulousLabs/muxado"
@@ -130,6 +131,10 @@ func TestListen(t *testing.T) {
 	if ack != build.Version {
 		t.Fatal("gateway should have given ack")
 	}
+	conn, _, err = connectEncryptionHandshake(conn, newTestIdentity())
+	if err != nil {
+		t.Fatal(err)
+	}
 	err = connectPortHandshake(conn, "0")
 	if err != nil {
 		t.Fatal(err)
@@ -160,6 +165,10 @@ func TestListen(t *testing.T) {
 	if ack != build.Version {
 		t.Fatal("gateway should have given ack")
 	}
+	conn, _, err = connectEncryptionHandshake(conn, newTestIdentity())
+	if err != nil {
+		t.Fatal(err)
+	}
 	err = connectPortHandshake(conn, addr.Port())
 	if err != nil {
 		t.Fatal(err)
@@ -483,6 +492,12 @@ func TestConnectRejectsVersions(t *testing.T) {
 			version: "0.4.0",
 			msg:     "Connect should succeed when the remote peer's version is 0.4.0",
 		},
+		// Test that Connect succeeds without encryption when the remote peer
+		// runs the last version before encryption.
+		{
+			version: "1.1.2",
+			msg:     "Connect should succeed when the remote peer's version is 1.1.2",
+		},
 		// Test that Connect succeeds when the remote peer's version is > 0.4.0.
 		{
 			version: "9",
@@ -497,6 +512,9 @@ func TestConnectRejectsVersions(t *testing.T) {
 			msg:     "Connect should succeed when the remote peer's version is 9999.9999.9999",
 		},
 	}
+	// The remote peer presents the same identity on every connection, as the
+	// gateway refuses a node that presents a different key than before.
+	remoteID := newTestIdentity()
 	for _, tt := range tests {
 		doneChan := make(chan struct{})
 		go func() {
@@ -512,6 +530,11 @@ func TestConnectRejectsVersions(t *testing.T) {
 			if remoteVersion != build.Version {
 				panic("remoteVersion != build.Version")
 			}
+			if build.IsVersion(tt.version) && build.VersionCmp(tt.version, encryptionUpgradeVersion) >= 0 {
+				if _, _, err := acceptConnEncryptionHandshake(conn, remoteID); err != nil {
+					panic(err)
+				}
+			}
 		}()
 		err = g.Connect(modules.NetAddress(listener.Addr().String()))
 		switch {
@@ -708,8 +731,8 @@ func TestPeerManager(t *testing.T) {
 
 	// g1's node list should only contain g2
 	g1.mu.Lock()
-	g1.nodes = map[modules.NetAddress]struct{}{}
-	g1.nodes[g2.Address()] = struct{}{}
+	g1.nodes = map[modules.NetAddress]*node{}
+	g1.nodes[g2.Address()] = &node{NetAddress: g2.Address(), FirstSeen: time.Now()}
 	g1.mu.Unlock()
 
 	// when peerManager wakes up, it should connect to g2.
@@ -846,3 +869,175 @@ func TestOverloadedBootstrap(t *testing.T) {
 		}
 	}
 }
+
+// TestTopology checks that the topology snapshot reports the gateway's peers,
+// its node list, and the RPCs relayed between peers.
+func TestTopology(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	g1 := newNamedTestingGateway(t, "1")
+	defer g1.Close()
+	g2 := newNamedTestingGateway(t, "2")
+	defer g2.Close()
+
+	topology := g1.Topology()
+	if topology.Address != g1.Address() || topology.Version != build.Version {
+		t.Fatal("topology has wrong address or version:", topology.Address, topology.Version)
+	}
+	if len(topology.Peers) != 0 {
+		t.Fatal("expected no peers, got", len(topology.Peers))
+	}
+
+	if err := g1.Connect(g2.Address()); err != nil {
+		t.Fatal(err)
+	}
+	g2.RegisterRPC("Foo", func(modules.PeerConn) error { return nil })
+
+	// g2 adds the inbound peer asynchronously.
+	peerStats := func(g *Gateway) modules.PeerStats {
+		for i := 0; i < 50; i++ {
+			if peers := g.Topology().Peers; len(peers) == 1 {
+				return peers[0]
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		t.Fatal("expected exactly one peer in topology")
+		return modules.PeerStats{}
+	}
+	before := peerStats(g1)
+	if before.NetAddress != g2.Address() || before.ConnectedSince.IsZero() {
+		t.Fatal("topology reported wrong peer:", before)
+	}
+
+	// The gateways call RPCs on each other in the background, so only check
+	// that the counters increase by at least the number of RPCs we call.
+	beforeReceived := peerStats(g2).RPCsReceived
+
+	// Call one successful and one failing RPC.
+	if err := g1.RPC(g2.Address(), "Foo", func(modules.PeerConn) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if err := g1.RPC(g2.Address(), "Foo", func(modules.PeerConn) error { return errNoPeers }); err == nil {
+		t.Fatal("expected RPC to fail")
+	}
+	after := peerStats(g1)
+	if after.RPCsCalled-before.RPCsCalled < 2 || after.RPCsFailed-before.RPCsFailed < 1 {
+		t.Fatal("RPC counters were not updated:", before, after)
+	}
+	// The counter on g2 is updated asynchronously.
+	for i := 0; i < 50 && peerStats(g2).RPCsReceived-beforeReceived < 2; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if received := peerStats(g2).RPCsReceived - beforeReceived; received < 2 {
+		t.Fatal("expected at least 2 RPCs received, got", received)
+	}
+
+	// g2 should be in g1's node list.
+	var found bool
+	for _, n := range g1.Topology().Nodes {
+		if n.NetAddress == g2.Address() {
+			found = true
+			if n.FirstSeen.IsZero() {
+				t.Fatal("node is missing its first seen time")
+			}
+		}
+	}
+	if !found {
+		t.Fatal("connected peer is missing from the node list")
+	}
+}
+
+// TestPeerStats checks that the gateway reports the traffic, RPC latency, and
+// uptime of its peers.
+func TestPeerStats(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	g1 := newNamedTestingGateway(t, "1")
+	defer g1.Close()
+	g2 := newNamedTestingGateway(t, "2")
+	defer g2.Close()
+
+	if err := g1.Connect(g2.Address()); err != nil {
+		t.Fatal(err)
+	}
+	g2.RegisterRPC("Foo", func(conn modules.PeerConn) error {
+		return encoding.WriteObject(conn, "bar")
+	})
+	err := g1.RPC(g2.Address(), "Foo", func(conn modules.PeerConn) error {
+		var s string
+		return encoding.ReadObject(conn, &s, 100)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := g1.PeerStats()
+	if len(stats) != 1 || stats[0].NetAddress != g2.Address() {
+		t.Fatal("expected exactly one peer, got", stats)
+	}
+	ps := stats[0]
+	if ps.RPCsCalled < 1 {
+		t.Fatal("expected at least one RPC called, got", ps.RPCsCalled)
+	}
+	if ps.AverageRPCTime <= 0 {
+		t.Fatal("expected positive average RPC time, got", ps.AverageRPCTime)
+	}
+	if ps.BytesRead == 0 || ps.BytesWritten == 0 {
+		t.Fatal("peer traffic was not counted:", ps.BytesRead, ps.BytesWritten)
+	}
+	if ps.Uptime <= 0 {
+		t.Fatal("expected positive uptime, got", ps.Uptime)
+	}
+}
+
+// TestUnitNetworkHandshake checks that the network handshake only succeeds
+// between peers on the same network, and that it rejects peers on the public
+// network.
+func TestUnitNetworkHandshake(t *testing.T) {
+	tests := []struct {
+		connectID, acceptID string
+		connectErr          error
+		acceptErr           error
+	}{
+		{"foo", "foo", nil, nil},
+		{"foo", "bar", errWrongNetwork, errWrongNetwork},
+	}
+	for _, tt := range tests {
+		c1, c2 := net.Pipe()
+		acceptErr := make(chan error)
+		go func() {
+			acceptErr <- acceptConnNetworkHandshake(c2, tt.acceptID)
+		}()
+		if err := connectNetworkHandshake(c1, tt.connectID); err != tt.connectErr {
+			t.Errorf("%q connecting to %q: expected %v, got %v", tt.connectID, tt.acceptID, tt.connectErr, err)
+		}
+		if err := <-acceptErr; err != tt.acceptErr {
+			t.Errorf("%q accepting %q: expected %v, got %v", tt.acceptID, tt.connectID, tt.acceptErr, err)
+		}
+		c1.Close()
+		c2.Close()
+	}
+
+	// A node on the public network should reject a node on a private network
+	// as having an invalid version.
+	c1, c2 := net.Pipe()
+	go connectNetworkHandshake(c1, "foo")
+	if _, err := acceptConnVersionHandshake(c2, build.Version); err == nil {
+		t.Error("public node accepted a private node")
+	}
+	c1.Close()
+	c2.Close()
+
+	// A node on a private network should reject a node on the public network.
+	c1, c2 = net.Pipe()
+	go connectVersionHandshake(c1, build.Version)
+	if err := acceptConnNetworkHandshake(c2, "foo"); err != errWrongNetwork {
+		t.Error("private node accepted a public node:", err)
+	}
+	c1.Close()
+	c2.Close()
+}
@@ -34,14 +34,10 @@ func (g *Gateway) managedPeerManagerConnect(addr modules.NetAddress) {
 		}
 		g.mu.Unlock()
 	} else if err != nil {
-		g.log.Debugf("[PMC] [ERROR] [%v] WARN: removing peer because automatic connect failed: %v\n", addr, err)
-
-		// Remove the node, but only if there are enough nodes in the node list.
-		g.mu.Lock()
-		if len(g.nodes) > pruneNodeListLen {
-			g.removeNode(addr)
-		}
-		g.mu.Unlock()
+		// The failure has been recorded in the node's connection history by
+		// managedConnect, which backs off from the node and removes it if it
+		// keeps failing.
+		g.log.Debugf("[PMC] [ERROR] [%v] WARN: automatic connect failed: %v\n", addr, err)
 	} else {
 		g.log.Debugf("[PMC] [SUCCESS] [%v] peer successfully added", addr)
 	}
@@ -77,7 +73,18 @@ func (g *Gateway) permanentPeerManager(closedChan chan struct{}) {
 		// If the gateway is well connected, sleep for a while and then try
 		// again.
 		numOutboundPeers := g.numOutboundPeers()
-		if numOutboundPeers >= wellConnectedThreshold {
+		g.mu.RLock()
+		targetOutbound := g.peerLimits.TargetOutbound
+		offline := g.offline
+		g.mu.RUnlock()
+		if offline {
+			g.log.Debugln("INFO: [PPM] Gateway is offline, sleeping.")
+			if !g.managedSleep(acquiringPeersDelay) {
+				return
+			}
+			continue
+		}
+		if numOutboundPeers >= targetOutbound {
 			g.log.Debugln("INFO: [PPM] Gateway has enough peers, sleeping.")
 			if !g.managedSleep(wellConnectedDelay) {
 				return
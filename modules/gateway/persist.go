@@ -2,6 +2,7 @@ package gateway
 
 import (
 	"path/filepath"
+	"time"
 
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/persist"
@@ -15,25 +16,64 @@ const (
 	logFile = modules.GatewayDir + ".log"
 )
 
-// persistMetadata contains the header and version strings that identify the
-// gateway persist file.
-var persistMetadata = persist.Metadata{
-	Header:  "Sia Node List",
-	Version: "0.3.3",
-}
+var (
+	// persistMetadata contains the header and version strings that identify
+	// the gateway persist file.
+	persistMetadata = persist.Metadata{
+		Header:  "Sia Node List",
+		Version: "1.1.2",
+	}
+
+	// compatPersistMetadata identifies gateway persist files from before
+	// nodes were saved along with the time they were first seen.
+	compatPersistMetadata = persist.Metadata{
+		Header:  "Sia Node List",
+		Version: "0.3.3",
+	}
+)
 
 // persistData returns the data in the Gateway that will be saved to disk.
-func (g *Gateway) persistData() (nodes []modules.NetAddress) {
-	for node := range g.nodes {
-		nodes = append(nodes, node)
+func (g *Gateway) persistData() (nodes []*node) {
+	for _, n := range g.nodes {
+		nodes = append(nodes, n)
 	}
 	return
 }
 
 // load loads the Gateway's persistent data from disk.
 func (g *Gateway) load() error {
-	var nodes []modules.NetAddress
+	var nodes []*node
 	err := persist.LoadFile(persistMetadata, &nodes, filepath.Join(g.persistDir, nodesFile))
+	if err == persist.ErrBadVersion {
+		// COMPATv1.1.2 load the node list from before nodes were saved along
+		// with the time they were first seen.
+		return g.loadCompat()
+	} else if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, n := range nodes {
+		err := g.addNode(n.NetAddress)
+		if err != nil {
+			g.log.Printf("WARN: error loading node '%v' from persist: %v", n.NetAddress, err)
+			continue
+		}
+		loaded := *n
+		if loaded.FirstSeen.IsZero() {
+			loaded.FirstSeen = g.nodes[n.NetAddress].FirstSeen
+		}
+		*g.nodes[n.NetAddress] = loaded
+		g.restoreBan(g.nodes[n.NetAddress], now)
+	}
+	return nil
+}
+
+// loadCompat loads a node list saved by an older version of the Gateway.
+// Since the old format does not record when nodes were first seen, the loaded
+// nodes are treated as if they were first seen at load time.
+func (g *Gateway) loadCompat() error {
+	var nodes []modules.NetAddress
+	err := persist.LoadFile(compatPersistMetadata, &nodes, filepath.Join(g.persistDir, nodesFile))
 	if err != nil {
 		return err
 	}
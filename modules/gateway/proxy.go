@@ -0,0 +1,174 @@
+package gateway
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// A gateway can be configured to make all of its outbound connections to
+// peers outside of the local network through a SOCKS5 proxy (RFC 1928), such
+// as the one provided by Tor. Addresses are handed to the proxy unresolved,
+// so that hostnames, including onion service addresses, are resolved by the
+// proxy rather than leaking through the local resolver. Because the external
+// IP address of a proxied gateway is nobody's business, the gateway does not
+// forward its port on the router or discover its external IP address.
+//
+// Optionally, the gateway can publish itself as a Tor onion service using
+// Tor's control port, so that peers running Tor can connect to it without
+// learning its IP address. See tor.go.
+
+const (
+	socks5Version        = 5
+	socks5AuthNone       = 0
+	socks5CmdConnect     = 1
+	socks5AddrIPv4       = 1
+	socks5AddrDomain     = 3
+	socks5AddrIPv6       = 4
+	socks5ReplySucceeded = 0
+)
+
+var (
+	// errQUICProxied is returned when enabling QUIC on a proxied gateway. QUIC
+	// runs over UDP, which SOCKS5 proxies such as Tor do not carry.
+	errQUICProxied = errors.New("QUIC cannot be used while peer connections are proxied")
+
+	// socks5Replies describes the failure codes of a SOCKS5 CONNECT request.
+	socks5Replies = map[byte]string{
+		1: "general SOCKS server failure",
+		2: "connection not allowed by ruleset",
+		3: "network unreachable",
+		4: "host unreachable",
+		5: "connection refused",
+		6: "TTL expired",
+		7: "command not supported",
+		8: "address type not supported",
+	}
+)
+
+// ProxyConfig configures a gateway to hide its IP address from the rest of
+// the network.
+type ProxyConfig struct {
+	// SOCKS5 is the address of the SOCKS5 proxy that outbound connections to
+	// peers outside of the local network are made through, e.g. Tor's
+	// 127.0.0.1:9050. Connections are made directly if it is empty.
+	SOCKS5 string
+
+	// TorControl is the address of Tor's control port, e.g. 127.0.0.1:9051.
+	// If it is set, the gateway publishes itself as an onion service and
+	// advertises the onion address instead of its IP address. SOCKS5 must be
+	// set as well.
+	TorControl string
+}
+
+// proxied returns true if the gateway makes outbound connections through a
+// proxy. The proxy configuration does not change after the gateway is
+// created, so no lock is required.
+func (g *Gateway) proxied() bool {
+	return g.proxy.SOCKS5 != ""
+}
+
+// dialSOCKS5 connects to addr through the SOCKS5 proxy.
+func (g *Gateway) dialSOCKS5(addr modules.NetAddress) (net.Conn, error) {
+	dialer := &net.Dialer{
+		Cancel:  g.threads.StopChan(),
+		Timeout: dialTimeout,
+	}
+	conn, err := dialer.Dial("tcp", g.proxy.SOCKS5)
+	if err != nil {
+		return nil, fmt.Errorf("could not reach SOCKS5 proxy: %v", err)
+	}
+	// The handshake must complete within the dial timeout, as Tor may take a
+	// while to build a circuit to the peer.
+	conn.SetDeadline(time.Now().Add(dialTimeout))
+	if err := socks5Connect(conn, addr); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// socks5Connect performs the SOCKS5 handshake over conn, asking the proxy to
+// connect to addr.
+func socks5Connect(conn net.Conn, addr modules.NetAddress) error {
+	host, portStr, err := net.SplitHostPort(string(addr))
+	if err != nil {
+		return err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return errors.New("invalid port: " + portStr)
+	}
+
+	// Offer no authentication as the only method.
+	if _, err := conn.Write([]byte{socks5Version, 1, socks5AuthNone}); err != nil {
+		return err
+	}
+	var method [2]byte
+	if _, err := io.ReadFull(conn, method[:]); err != nil {
+		return fmt.Errorf("could not read SOCKS5 method: %v", err)
+	} else if method[0] != socks5Version {
+		return fmt.Errorf("proxy is not a SOCKS5 proxy (version %v)", method[0])
+	} else if method[1] != socks5AuthNone {
+		return errors.New("SOCKS5 proxy requires authentication")
+	}
+
+	// Request a connection to the peer.
+	req := []byte{socks5Version, socks5CmdConnect, 0}
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return errors.New("hostname is too long: " + host)
+		}
+		req = append(req, socks5AddrDomain, byte(len(host)))
+		req = append(req, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		req = append(req, socks5AddrIPv4)
+		req = append(req, ip4...)
+	} else {
+		req = append(req, socks5AddrIPv6)
+		req = append(req, ip.To16()...)
+	}
+	var portBytes [2]byte
+	binary.BigEndian.PutUint16(portBytes[:], uint16(port))
+	req = append(req, portBytes[:]...)
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	// Read the reply, including the bound address, which is discarded.
+	var reply [4]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return fmt.Errorf("could not read SOCKS5 reply: %v", err)
+	} else if reply[1] != socks5ReplySucceeded {
+		desc, ok := socks5Replies[reply[1]]
+		if !ok {
+			desc = fmt.Sprintf("unknown error %v", reply[1])
+		}
+		return fmt.Errorf("SOCKS5 proxy could not connect to %v: %v", addr, desc)
+	}
+	var boundLen int
+	switch reply[3] {
+	case socks5AddrIPv4:
+		boundLen = net.IPv4len
+	case socks5AddrIPv6:
+		boundLen = net.IPv6len
+	case socks5AddrDomain:
+		var l [1]byte
+		if _, err := io.ReadFull(conn, l[:]); err != nil {
+			return err
+		}
+		boundLen = int(l[0])
+	default:
+		return fmt.Errorf("SOCKS5 reply has unknown address type %v", reply[3])
+	}
+	if _, err := io.ReadFull(conn, make([]byte, boundLen+2)); err != nil {
+		return fmt.Errorf("could not read SOCKS5 reply: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package gateway
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// serveSOCKS5 accepts a single connection on l, answers its SOCKS5 CONNECT
+// request and relays the connection to the requested address. The requested
+// address is sent on reqs.
+func serveSOCKS5(l net.Listener, reqs chan<- string) {
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	var greeting [3]byte
+	if _, err := io.ReadFull(conn, greeting[:]); err != nil {
+		return
+	}
+	conn.Write([]byte{socks5Version, socks5AuthNone})
+
+	var hdr [4]byte
+	if _, err := io.ReadFull(conn, hdr[:]); err != nil {
+		return
+	}
+	var host string
+	switch hdr[3] {
+	case socks5AddrIPv4, socks5AddrIPv6:
+		ip := make(net.IP, net.IPv4len)
+		if hdr[3] == socks5AddrIPv6 {
+			ip = make(net.IP, net.IPv6len)
+		}
+		io.ReadFull(conn, ip)
+		host = ip.String()
+	case socks5AddrDomain:
+		var l [1]byte
+		io.ReadFull(conn, l[:])
+		name := make([]byte, l[0])
+		io.ReadFull(conn, name)
+		host = string(name)
+	}
+	var port [2]byte
+	io.ReadFull(conn, port[:])
+	addr := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port[:]))))
+	reqs <- addr
+
+	target, err := net.Dial("tcp", addr)
+	if err != nil {
+		conn.Write([]byte{socks5Version, 4, 0, socks5AddrIPv4, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer target.Close()
+	conn.Write([]byte{socks5Version, socks5ReplySucceeded, 0, socks5AddrIPv4, 127, 0, 0, 1, 0, 0})
+	go io.Copy(target, conn)
+	io.Copy(conn, target)
+}
+
+// TestSOCKS5Dial checks that a proxied gateway dials peers through the SOCKS5
+// proxy, handing hostnames to the proxy unresolved.
+func TestSOCKS5Dial(t *testing.T) {
+	proxy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Close()
+	target, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+	go func() {
+		conn, err := target.Accept()
+		if err != nil {
+			return
+		}
+		conn.Write([]byte("hello"))
+		conn.Close()
+	}()
+
+	g := &Gateway{proxy: ProxyConfig{SOCKS5: proxy.Addr().String()}}
+	reqs := make(chan string, 2)
+
+	// Dial the target through the proxy.
+	go serveSOCKS5(proxy, reqs)
+	addr := modules.NetAddress(target.Addr().String())
+	conn, err := g.dialSOCKS5(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, err := ioutil.ReadAll(conn)
+	conn.Close()
+	if err != nil {
+		t.Fatal(err)
+	} else if string(buf) != "hello" {
+		t.Fatalf("expected %q, got %q", "hello", buf)
+	}
+	if req := <-reqs; req != string(addr) {
+		t.Fatalf("proxy was asked to connect to %v, expected %v", req, addr)
+	}
+
+	// Onion addresses are passed to the proxy as hostnames, and failures
+	// reported by the proxy are returned.
+	go serveSOCKS5(proxy, reqs)
+	onion := modules.NetAddress("vww6ybal4bd7szmgncyruucpgfkqahzddi37ktceo3ah7ngmcopnpyyd.onion:9981")
+	if _, err := g.dialSOCKS5(onion); err == nil || !strings.Contains(err.Error(), "host unreachable") {
+		t.Fatal("expected the proxy to fail to reach the onion address, got", err)
+	}
+	if req := <-reqs; req != string(onion) {
+		t.Fatalf("proxy was asked to connect to %v, expected %v", req, onion)
+	}
+}
+
+// TestProxiedGateway checks that proxied gateways accept onion addresses and
+// refuse to enable QUIC.
+func TestProxiedGateway(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	onion := modules.NetAddress("vww6ybal4bd7szmgncyruucpgfkqahzddi37ktceo3ah7ngmcopnpyyd.onion:9981")
+
+	g := newTestingGateway(t)
+	defer g.Close()
+	g.mu.Lock()
+	err := g.addNode(onion)
+	g.mu.Unlock()
+	if err == nil {
+		t.Fatal("gateway without a proxy should not accept onion addresses")
+	}
+
+	pg, err := NewWithProxy("localhost:0", false, build.TempDir("gateway", t.Name()), ProxyConfig{SOCKS5: "127.0.0.1:9050"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pg.Close()
+	pg.mu.Lock()
+	err = pg.addNode(onion)
+	pg.mu.Unlock()
+	if err != nil {
+		t.Fatal("proxied gateway should accept onion addresses:", err)
+	}
+	if err := pg.EnableQUIC(); err != errQUICProxied {
+		t.Fatal("expected errQUICProxied, got", err)
+	}
+
+	if _, err := NewWithProxy("localhost:0", false, build.TempDir("gateway", t.Name()+"2"), ProxyConfig{TorControl: "127.0.0.1:9051"}); err == nil {
+		t.Fatal("publishing an onion service should require a proxy")
+	}
+}
+
+// TestTorControl checks that the gateway authenticates with Tor and asks it
+// to publish an onion service.
+func TestTorControl(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	tc := &torControlConn{conn: client, text: textproto.NewConn(client)}
+
+	cmds := make(chan string, 3)
+	go func() {
+		defer server.Close()
+		r := bufio.NewReader(server)
+		replies := []string{
+			"250-PROTOCOLINFO 1\r\n250-AUTH METHODS=NULL\r\n250-VERSION Tor=\"0.4.8.9\"\r\n250 OK\r\n",
+			"250 OK\r\n",
+			"250-ServiceID=vww6ybal4bd7szmgncyruucpgfkqahzddi37ktceo3ah7ngmcopnpyyd\r\n250-PrivateKey=ED25519-V3:c2VjcmV0\r\n250 OK\r\n",
+		}
+		for _, reply := range replies {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmds <- strings.TrimSpace(line)
+			server.Write([]byte(reply))
+		}
+	}()
+
+	if err := tc.authenticate(); err != nil {
+		t.Fatal(err)
+	}
+	serviceID, key, err := tc.addOnion("", "9981", "127.0.0.1:9981")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serviceID != "vww6ybal4bd7szmgncyruucpgfkqahzddi37ktceo3ah7ngmcopnpyyd" || key != "ED25519-V3:c2VjcmV0" {
+		t.Fatal("unexpected onion service:", serviceID, key)
+	}
+	expected := []string{"PROTOCOLINFO 1", "AUTHENTICATE", "ADD_ONION NEW:ED25519-V3 Port=9981,127.0.0.1:9981"}
+	for _, exp := range expected {
+		if cmd := <-cmds; cmd != exp {
+			t.Fatalf("expected command %q, got %q", exp, cmd)
+		}
+	}
+}
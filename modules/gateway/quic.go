@@ -0,0 +1,187 @@
+package gateway
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"math/big"
+	"net"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+
+	"github.com/lucas-clemente/quic-go"
+)
+
+// quicALPN is the application protocol negotiated by gateways connecting over
+// QUIC.
+const quicALPN = "sia-gateway"
+
+// errQUICNoStream is returned when a peer connects over QUIC but does not open
+// a stream before the deadline.
+var errQUICNoStream = errors.New("peer did not open a stream")
+
+// quicConfig is the configuration used for QUIC connections. Peer connections
+// can be idle for long periods, so keepalives are sent to keep NAT mappings
+// open.
+var quicConfig = &quic.Config{
+	HandshakeTimeout: dialTimeout,
+	IdleTimeout:      pingTimeout,
+	KeepAlive:        true,
+}
+
+// quicConn is a net.Conn made of the single stream of a QUIC session. The
+// gateway handshake and the muxado session run over the stream exactly as
+// they would over a TCP connection.
+type quicConn struct {
+	quic.Stream
+	sess quic.Session
+}
+
+// LocalAddr implements the net.Conn interface.
+func (qc *quicConn) LocalAddr() net.Addr { return qc.sess.LocalAddr() }
+
+// RemoteAddr implements the net.Conn interface.
+func (qc *quicConn) RemoteAddr() net.Addr { return qc.sess.RemoteAddr() }
+
+// Close closes the stream along with the QUIC session.
+func (qc *quicConn) Close() error {
+	qc.Stream.Close()
+	return qc.sess.Close(nil)
+}
+
+// quicTLSConfig returns the TLS configuration of the QUIC listener. QUIC
+// requires TLS, but gateways have no identities for the certificate to
+// prove, so an ephemeral self-signed certificate is used and peers do not
+// verify it.
+func quicTLSConfig() (*tls.Config, error) {
+	sk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(10 * 365 * 24 * time.Hour),
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &sk.PublicKey, sk)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{cert}, PrivateKey: sk}},
+		NextProtos:   []string{quicALPN},
+	}, nil
+}
+
+// dialQUIC dials the input address over QUIC and returns a connection. The
+// handshake is bounded by the HandshakeTimeout of quicConfig.
+func (g *Gateway) dialQUIC(addr modules.NetAddress) (net.Conn, error) {
+	tlsConf := &tls.Config{
+		InsecureSkipVerify: true,
+		NextProtos:         []string{quicALPN},
+	}
+	sess, err := quic.DialAddr(string(addr), tlsConf, quicConfig)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := sess.OpenStreamSync()
+	if err != nil {
+		sess.Close(err)
+		return nil, err
+	}
+	qc := &quicConn{Stream: stream, sess: sess}
+	qc.SetDeadline(time.Now().Add(connStdDeadline))
+	return qc, nil
+}
+
+// permanentListenQUIC handles incoming QUIC connection requests in the same
+// way that permanentListen handles incoming TCP connection requests.
+func (g *Gateway) permanentListenQUIC(listener quic.Listener, closeChan chan struct{}) {
+	defer close(closeChan)
+
+	for {
+		sess, err := listener.Accept()
+		if err != nil {
+			g.log.Debugln("[PLQ] Closing permanentListenQUIC:", err)
+			return
+		}
+
+		go g.threadedAcceptQUIC(sess)
+
+		select {
+		case <-time.After(acceptInterval):
+		case <-g.threads.StopChan():
+			return
+		}
+	}
+}
+
+// threadedAcceptQUIC waits for the peer to open the stream of a QUIC session,
+// then accepts the peer. The session is closed if the peer does not open a
+// stream within connStdDeadline.
+func (g *Gateway) threadedAcceptQUIC(sess quic.Session) {
+	if g.threads.Add() != nil {
+		sess.Close(nil)
+		return
+	}
+	defer g.threads.Done()
+
+	timer := time.AfterFunc(connStdDeadline, func() {
+		sess.Close(errQUICNoStream)
+	})
+	stream, err := sess.AcceptStream()
+	if !timer.Stop() && err == nil {
+		err = errQUICNoStream
+	}
+	if err != nil {
+		g.log.Debugf("INFO: %v connected over QUIC but did not open a stream: %v", sess.RemoteAddr(), err)
+		sess.Close(err)
+		return
+	}
+	g.threadedAcceptConn(g.countConn(&quicConn{Stream: stream, sess: sess}, transportQUIC))
+}
+
+// EnableQUIC starts accepting peer connections over QUIC, on the UDP port
+// matching the gateway's TCP port, and dialing peers that accept QUIC
+// connections over QUIC. QUIC support is experimental, and TCP connections
+// continue to be accepted.
+func (g *Gateway) EnableQUIC() error {
+	if err := g.threads.Add(); err != nil {
+		return err
+	}
+	defer g.threads.Done()
+	if g.proxied() {
+		return errQUICProxied
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.quicListener != nil {
+		return nil
+	}
+	tlsConf, err := quicTLSConfig()
+	if err != nil {
+		return err
+	}
+	listener, err := quic.ListenAddr(g.listener.Addr().String(), tlsConf, quicConfig)
+	if err != nil {
+		return err
+	}
+	g.quicListener = listener
+
+	closeChan := make(chan struct{})
+	g.threads.OnStop(func() {
+		if err := listener.Close(); err != nil {
+			g.log.Println("WARN: closing the QUIC listener failed:", err)
+		}
+		<-closeChan
+	})
+	go g.permanentListenQUIC(listener, closeChan)
+	go g.threadedShareCapabilities()
+	g.log.Println("INFO: accepting peer connections over QUIC on", listener.Addr())
+	return nil
+}
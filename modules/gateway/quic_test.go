@@ -0,0 +1,119 @@
+package gateway
+
+import (
+	"testing"
+	"time"
+)
+
+// waitFor polls fn until it returns true, returning false if it never does.
+func waitFor(fn func() bool) bool {
+	for i := 0; i < 50; i++ {
+		if fn() {
+			return true
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return false
+}
+
+// TestConnectQUIC checks that gateways with QUIC enabled learn that their
+// peers accept QUIC connections, and connect to them over QUIC afterwards.
+func TestConnectQUIC(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	g1 := newNamedTestingGateway(t, "1")
+	defer g1.Close()
+	g2 := newNamedTestingGateway(t, "2")
+	defer g2.Close()
+	for _, g := range []*Gateway{g1, g2} {
+		if err := g.EnableQUIC(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The first connection is made over TCP, as g1 does not know yet that g2
+	// accepts QUIC connections.
+	if err := g1.Connect(g2.Address()); err != nil {
+		t.Fatal(err)
+	}
+	learned := waitFor(func() bool {
+		g1.mu.RLock()
+		defer g1.mu.RUnlock()
+		return g1.nodes[g2.Address()].QUIC
+	})
+	if !learned {
+		t.Fatal("g2 was not recorded as accepting QUIC connections")
+	}
+	if peers := g1.Topology().Peers; len(peers) != 1 || peers[0].Transport != transportTCP {
+		t.Fatal("expected a single TCP peer, got", peers)
+	}
+	if err := g1.Disconnect(g2.Address()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second connection is made over QUIC.
+	if err := g1.Connect(g2.Address()); err != nil {
+		t.Fatal(err)
+	}
+	if peers := g1.Topology().Peers; len(peers) != 1 || peers[0].Transport != transportQUIC {
+		t.Fatal("expected a single QUIC peer, got", peers)
+	}
+	accepted := waitFor(func() bool {
+		peers := g2.Topology().Peers
+		return len(peers) == 1 && peers[0].Transport == transportQUIC
+	})
+	if !accepted {
+		t.Fatal("g2 did not accept g1 over QUIC:", g2.Topology().Peers)
+	}
+
+	// RPCs are made over the QUIC connection, and are counted in its stats.
+	if err := g1.RPC(g2.Address(), "ShareNodes", g1.requestNodes); err != nil {
+		t.Fatal(err)
+	}
+	ts := g1.Transports()
+	if len(ts) != 2 || ts[1].Transport != transportQUIC || !ts[1].Enabled || ts[1].Peers != 1 || ts[1].Dials != 1 || ts[1].DialFailures != 0 || ts[1].BytesRead == 0 || ts[1].BytesWritten == 0 {
+		t.Fatal("wrong QUIC stats:", ts)
+	}
+	if ts[0].Transport != transportTCP || ts[0].Peers != 0 || ts[0].Dials != 1 {
+		t.Fatal("wrong TCP stats:", ts)
+	}
+	if ts := g2.Transports(); ts[1].Inbound != 1 || ts[0].Inbound != 1 {
+		t.Fatal("wrong inbound stats:", ts)
+	}
+}
+
+// TestConnectQUICDisabled checks that peers that have not enabled QUIC are
+// not dialed over QUIC.
+func TestConnectQUICDisabled(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	g1 := newNamedTestingGateway(t, "1")
+	defer g1.Close()
+	g2 := newNamedTestingGateway(t, "2")
+	defer g2.Close()
+	if err := g1.EnableQUIC(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := g1.Connect(g2.Address()); err != nil {
+			t.Fatal(err)
+		}
+		if peers := g1.Topology().Peers; len(peers) != 1 || peers[0].Transport != transportTCP {
+			t.Fatal("expected a single TCP peer, got", peers)
+		}
+		if err := g1.Disconnect(g2.Address()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ts := g1.Transports(); ts[1].Dials != 0 || ts[0].Dials != 2 {
+		t.Fatal("wrong dial stats:", ts)
+	}
+	if ts := g2.Transports(); ts[1].Enabled {
+		t.Fatal("QUIC reported as enabled:", ts)
+	}
+}
@@ -0,0 +1,237 @@
+package gateway
+
+import (
+	"errors"
+	"net"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
+)
+
+// The gateway can cap the rate at which it reads from and writes to its
+// peers, summed across all peer connections, so that block synchronization
+// and relaying does not saturate a home connection. Each direction is limited
+// by a token bucket that holds up to one second worth of bytes. Peer
+// connections draw from the buckets as they read and write, blocking until
+// enough tokens are available. The caps can be changed while the gateway is
+// running, and are saved in the gateway's settings file.
+
+const (
+	// settingsFile is the name of the file that contains the gateway's
+	// settings.
+	settingsFile = "settings.json"
+
+	// rateLimitChunk is the largest number of bytes that a rate limited
+	// connection reads or writes at once, so that a single large read or
+	// write does not stall other connections for a long time.
+	rateLimitChunk = 1 << 14
+)
+
+var (
+	// settingsMetadata contains the header and version strings that identify
+	// the gateway settings file.
+	settingsMetadata = persist.Metadata{
+		Header:  "Gateway Settings",
+		Version: "1.3.0",
+	}
+
+	errNegativeRateLimit = errors.New("rate limits cannot be negative")
+)
+
+// settings contains the gateway settings that are saved to disk.
+type settings struct {
+	RateLimits  modules.GatewayRateLimits  `json:"ratelimits"`
+	PeerFilter  modules.GatewayPeerFilter  `json:"peerfilter"`
+	PeerLimits  modules.GatewayPeerLimits  `json:"peerlimits"`
+	Anchors     []modules.NetAddress       `json:"anchors"`
+	RPCTimeouts modules.GatewayRPCTimeouts `json:"rpctimeouts"`
+	Offline     bool                       `json:"offline"`
+}
+
+// rateLimit is a token bucket limiting the number of bytes transferred per
+// second. A rate of zero means that transfers are not limited.
+type rateLimit struct {
+	rate   int64
+	tokens float64
+	last   time.Time
+	mu     sync.Mutex
+}
+
+// setRate changes the rate of the bucket. Tokens that are owed under the
+// previous rate are carried over.
+func (rl *rateLimit) setRate(rate int64) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.rate = rate
+	rl.last = time.Now()
+	if rl.tokens > float64(rate) {
+		rl.tokens = float64(rate)
+	}
+}
+
+// wait takes n tokens from the bucket, blocking until the bucket would have
+// held them. false is returned if the wait was interrupted by stop.
+func (rl *rateLimit) wait(n int, stop <-chan struct{}) bool {
+	rl.mu.Lock()
+	if rl.rate <= 0 {
+		rl.mu.Unlock()
+		return true
+	}
+	now := time.Now()
+	rl.tokens += now.Sub(rl.last).Seconds() * float64(rl.rate)
+	if rl.tokens > float64(rl.rate) {
+		rl.tokens = float64(rl.rate)
+	}
+	rl.last = now
+	rl.tokens -= float64(n)
+	var delay time.Duration
+	if rl.tokens < 0 {
+		delay = time.Duration(-rl.tokens / float64(rl.rate) * float64(time.Second))
+	}
+	rl.mu.Unlock()
+
+	if delay == 0 {
+		return true
+	}
+	select {
+	case <-time.After(delay):
+		return true
+	case <-stop:
+		return false
+	}
+}
+
+// rateLimitedConn is a net.Conn that draws from the gateway's rate limits as
+// it is read from and written to.
+type rateLimitedConn struct {
+	net.Conn
+	g *Gateway
+}
+
+// Read implements the io.Reader interface.
+func (rc *rateLimitedConn) Read(b []byte) (int, error) {
+	if len(b) > rateLimitChunk {
+		b = b[:rateLimitChunk]
+	}
+	n, err := rc.Conn.Read(b)
+	if !rc.g.downloadLimit.wait(n, rc.g.threads.StopChan()) && err == nil {
+		err = errors.New("gateway is shutting down")
+	}
+	return n, err
+}
+
+// Write implements the io.Writer interface.
+func (rc *rateLimitedConn) Write(b []byte) (int, error) {
+	var written int
+	for len(b) > 0 {
+		chunk := b
+		if len(chunk) > rateLimitChunk {
+			chunk = chunk[:rateLimitChunk]
+		}
+		if !rc.g.uploadLimit.wait(len(chunk), rc.g.threads.StopChan()) {
+			return written, errors.New("gateway is shutting down")
+		}
+		n, err := rc.Conn.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		b = b[n:]
+	}
+	return written, nil
+}
+
+// limitConn wraps a peer connection so that its traffic is subject to the
+// gateway's rate limits.
+func (g *Gateway) limitConn(conn net.Conn) net.Conn {
+	return &rateLimitedConn{Conn: conn, g: g}
+}
+
+// loadSettings loads the gateway's settings from disk.
+func (g *Gateway) loadSettings() error {
+	// Settings files written before the peer limits or RPC timeouts were
+	// added keep the defaults.
+	s := settings{PeerLimits: defaultPeerLimits(), RPCTimeouts: defaultRPCTimeouts()}
+	err := persist.LoadFile(settingsMetadata, &s, filepath.Join(g.persistDir, settingsFile))
+	if err != nil {
+		return err
+	}
+	if s.RateLimits.MaxDownloadSpeed < 0 || s.RateLimits.MaxUploadSpeed < 0 {
+		return errNegativeRateLimit
+	}
+	pf, err := parsePeerFilter(s.PeerFilter)
+	if err != nil {
+		return err
+	}
+	if err := validatePeerLimits(s.PeerLimits); err != nil {
+		return err
+	}
+	if err := validateRPCTimeouts(s.RPCTimeouts); err != nil {
+		return err
+	}
+	if s.RPCTimeouts.PerRPC == nil {
+		s.RPCTimeouts.PerRPC = make(map[string]modules.RPCTimeouts)
+	}
+	anchors := make(map[modules.NetAddress]struct{}, len(s.Anchors))
+	for _, addr := range s.Anchors {
+		if err := g.validateAnchor(addr); err != nil {
+			return err
+		}
+		anchors[addr] = struct{}{}
+	}
+	g.anchors = anchors
+	g.offline = s.Offline
+	g.peerLimits = s.PeerLimits
+	g.rpcTimeouts = s.RPCTimeouts
+	g.peerFilterLists = s.PeerFilter
+	g.peerFilter = pf
+	g.rateLimits = s.RateLimits
+	g.downloadLimit.setRate(s.RateLimits.MaxDownloadSpeed)
+	g.uploadLimit.setRate(s.RateLimits.MaxUploadSpeed)
+	return nil
+}
+
+// saveSettings saves the gateway's settings to disk.
+func (g *Gateway) saveSettings() error {
+	s := settings{
+		RateLimits:  g.rateLimits,
+		PeerFilter:  g.peerFilterLists,
+		PeerLimits:  g.peerLimits,
+		Anchors:     g.anchorList(),
+		RPCTimeouts: g.rpcTimeouts,
+		Offline:     g.offline,
+	}
+	return persist.SaveFileSync(settingsMetadata, s, filepath.Join(g.persistDir, settingsFile))
+}
+
+// RateLimits returns the caps on the rate at which the gateway reads from and
+// writes to its peers.
+func (g *Gateway) RateLimits() modules.GatewayRateLimits {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.rateLimits
+}
+
+// SetRateLimits changes the caps on the rate at which the gateway reads from
+// and writes to its peers, in bytes per second, across all peer connections.
+// A cap of zero means that the direction is not limited. The new caps take
+// effect immediately, and are saved to disk.
+func (g *Gateway) SetRateLimits(rl modules.GatewayRateLimits) error {
+	if err := g.threads.Add(); err != nil {
+		return err
+	}
+	defer g.threads.Done()
+	if rl.MaxDownloadSpeed < 0 || rl.MaxUploadSpeed < 0 {
+		return errNegativeRateLimit
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.rateLimits = rl
+	g.downloadLimit.setRate(rl.MaxDownloadSpeed)
+	g.uploadLimit.setRate(rl.MaxUploadSpeed)
+	return g.saveSettings()
+}
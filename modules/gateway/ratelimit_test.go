@@ -0,0 +1,112 @@
+package gateway
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestRateLimit checks that the token bucket delays transfers that exceed
+// its rate.
+func TestRateLimit(t *testing.T) {
+	var rl rateLimit
+	stop := make(chan struct{})
+
+	// An unlimited bucket never blocks.
+	start := time.Now()
+	rl.wait(1e9, stop)
+	if time.Since(start) > 100*time.Millisecond {
+		t.Fatal("unlimited bucket blocked")
+	}
+
+	// Drawing three seconds worth of tokens from an empty bucket takes three
+	// seconds. Use a short period to keep the test fast.
+	rl.setRate(1000)
+	start = time.Now()
+	for i := 0; i < 3; i++ {
+		rl.wait(100, stop)
+	}
+	if elapsed := time.Since(start); elapsed < 250*time.Millisecond || elapsed > time.Second {
+		t.Fatal("expected the bucket to block for about 300ms, blocked for", elapsed)
+	}
+
+	// Waits are interrupted by stop.
+	close(stop)
+	if rl.wait(1e6, stop) {
+		t.Fatal("wait should have been interrupted")
+	}
+}
+
+// TestRateLimitedConn checks that peer connections are subject to the
+// gateway's rate limits.
+func TestRateLimitedConn(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	g := newTestingGateway(t)
+	defer g.Close()
+	if err := g.SetRateLimits(modules.GatewayRateLimits{MaxUploadSpeed: 50e3}); err != nil {
+		t.Fatal(err)
+	}
+
+	client, server := net.Pipe()
+	defer client.Close()
+	go io.Copy(ioutil.Discard, server)
+	conn := g.countConn(client, transportTCP)
+
+	// Writing 100 KB at 50 KB/s from an empty bucket takes about 2 seconds.
+	start := time.Now()
+	if _, err := conn.Write(make([]byte, 100e3)); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 1500*time.Millisecond {
+		t.Fatal("write was not rate limited, took", elapsed)
+	}
+
+	// Removing the limit takes effect immediately.
+	if err := g.SetRateLimits(modules.GatewayRateLimits{}); err != nil {
+		t.Fatal(err)
+	}
+	start = time.Now()
+	if _, err := conn.Write(make([]byte, 100e3)); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatal("write was rate limited after the limit was removed, took", elapsed)
+	}
+}
+
+// TestRateLimitsPersist checks that the gateway's rate limits persist across
+// restarts, and that invalid limits are rejected.
+func TestRateLimitsPersist(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	g := newTestingGateway(t)
+	rl := modules.GatewayRateLimits{MaxDownloadSpeed: 1e6, MaxUploadSpeed: 250e3}
+	if err := g.SetRateLimits(rl); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.SetRateLimits(modules.GatewayRateLimits{MaxUploadSpeed: -1}); err != errNegativeRateLimit {
+		t.Fatal("expected errNegativeRateLimit, got", err)
+	}
+	if err := g.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := New("localhost:0", false, g.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	if g.RateLimits() != rl {
+		t.Fatalf("expected rate limits %v after restart, got %v", rl, g.RateLimits())
+	}
+	if g.uploadLimit.rate != rl.MaxUploadSpeed || g.downloadLimit.rate != rl.MaxDownloadSpeed {
+		t.Fatal("rate limits were not applied after restart")
+	}
+}
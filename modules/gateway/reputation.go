@@ -0,0 +1,131 @@
+package gateway
+
+import (
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// The gateway keeps the connection history of each node in its node list, and
+// saves it along with the node list. A node that fails a connection attempt
+// is not picked again by the peer manager or the node purger until a backoff
+// has passed, which starts at nodeBackoffBase and doubles with every further
+// failure in a row, up to nodeBackoffMax, so that flaky nodes are retried less
+// and less often instead of on every pass. A successful connection resets the
+// backoff. Nodes are only removed from the node list after maxNodeFailures
+// failures in a row. The ban history of each node is saved as well, so that
+// bans survive a restart.
+
+// backoff returns how long the gateway waits after the last connection
+// attempt before picking the node again.
+func (n *node) backoff() time.Duration {
+	if n.ConsecutiveFailures == 0 {
+		return 0
+	}
+	backoff := nodeBackoffBase
+	for i := uint64(1); i < n.ConsecutiveFailures && backoff < nodeBackoffMax; i++ {
+		backoff *= 2
+	}
+	if backoff > nodeBackoffMax {
+		backoff = nodeBackoffMax
+	}
+	return backoff
+}
+
+// nextAttempt returns the time before which the gateway does not pick the
+// node.
+func (n *node) nextAttempt() time.Time {
+	if n.ConsecutiveFailures == 0 {
+		return time.Time{}
+	}
+	return n.LastAttempt.Add(n.backoff())
+}
+
+// backingOff returns true if the node is not to be picked at the given time.
+func (n *node) backingOff(now time.Time) bool {
+	return now.Before(n.nextAttempt())
+}
+
+// info returns the exported description of the node.
+func (n *node) info() modules.NodeInfo {
+	return modules.NodeInfo{
+		NetAddress:          n.NetAddress,
+		FirstSeen:           n.FirstSeen,
+		Successes:           n.Successes,
+		Failures:            n.Failures,
+		ConsecutiveFailures: n.ConsecutiveFailures,
+		LastSeen:            n.LastSeen,
+		LastAttempt:         n.LastAttempt,
+		NextAttempt:         n.nextAttempt(),
+		Bans:                n.Bans,
+		LastBanned:          n.LastBanned,
+		LastBanReason:       n.LastBanReason,
+	}
+}
+
+// recordConnection records the outcome of an attempt to connect to the node
+// at addr. Attempts to connect to addresses that are not in the node list are
+// not recorded.
+func (g *Gateway) recordConnection(addr modules.NetAddress, success bool, now time.Time) {
+	n, exists := g.nodes[addr]
+	if !exists {
+		return
+	}
+	n.LastAttempt = now
+	if success {
+		n.Successes++
+		n.ConsecutiveFailures = 0
+		n.LastSeen = now
+	} else {
+		n.Failures++
+		n.ConsecutiveFailures++
+	}
+}
+
+// managedRecordConnection records the outcome of an attempt to connect to the
+// node at addr and saves the node list. If the attempt failed, the node has
+// failed maxNodeFailures attempts in a row, and the node list is large enough
+// to be pruned, the node is removed. It returns true if the node was removed.
+func (g *Gateway) managedRecordConnection(addr modules.NetAddress, success bool) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	n, exists := g.nodes[addr]
+	if !exists {
+		return false
+	}
+	g.recordConnection(addr, success, time.Now())
+	removed := false
+	if n.ConsecutiveFailures >= maxNodeFailures && len(g.nodes) > pruneNodeListLen {
+		removed = g.removeNode(addr) == nil
+	}
+	if err := g.save(); err != nil {
+		g.log.Println("WARN: failed to save node list after recording a connection attempt:", err)
+	}
+	return removed
+}
+
+// recordBan records in the ban history of the node at addr that it was
+// banned for the given kind of misbehavior.
+func (g *Gateway) recordBan(addr modules.NetAddress, kind string, now time.Time) {
+	n, exists := g.nodes[addr]
+	if !exists {
+		return
+	}
+	n.Bans++
+	n.LastBanned = now
+	n.LastBanReason = kind
+}
+
+// restoreBan bans the node again if it was banned recently enough that the
+// ban has not expired. It is called when the node list is loaded.
+func (g *Gateway) restoreBan(n *node, now time.Time) {
+	until := n.LastBanned.Add(banDuration)
+	if n.Bans == 0 || !now.Before(until) {
+		return
+	}
+	g.bans[n.NetAddress] = modules.BannedPeer{
+		NetAddress: n.NetAddress,
+		Reason:     n.LastBanReason,
+		Until:      until,
+	}
+}
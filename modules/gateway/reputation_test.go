@@ -0,0 +1,126 @@
+package gateway
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestNodeBackoff checks that the backoff of a node doubles with every
+// failed connection attempt in a row, and is reset by a successful one.
+func TestNodeBackoff(t *testing.T) {
+	n := &node{NetAddress: dummyNode}
+	g := &Gateway{nodes: map[modules.NetAddress]*node{dummyNode: n}}
+	now := time.Now()
+	if n.backingOff(now) {
+		t.Fatal("node without failures is backing off")
+	}
+
+	g.recordConnection(dummyNode, false, now)
+	if n.backoff() != nodeBackoffBase || !n.backingOff(now) {
+		t.Fatal("expected a backoff of", nodeBackoffBase, "got", n.backoff())
+	}
+	if n.backingOff(now.Add(nodeBackoffBase)) {
+		t.Fatal("node is still backing off after its backoff")
+	}
+	g.recordConnection(dummyNode, false, now)
+	if n.backoff() != 2*nodeBackoffBase {
+		t.Fatal("expected a backoff of", 2*nodeBackoffBase, "got", n.backoff())
+	}
+	for i := 0; i < 64; i++ {
+		g.recordConnection(dummyNode, false, now)
+	}
+	if n.backoff() != nodeBackoffMax {
+		t.Fatal("expected the backoff to be capped at", nodeBackoffMax, "got", n.backoff())
+	}
+
+	g.recordConnection(dummyNode, true, now)
+	if n.backingOff(now) || n.Successes != 1 || n.Failures != 66 || !n.LastSeen.Equal(now) {
+		t.Fatal("success was not recorded:", n)
+	}
+}
+
+// TestRandomNodeBackoff checks that nodes that are backing off are not
+// selected.
+func TestRandomNodeBackoff(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	g := newTestingGateway(t)
+	defer g.Close()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err := g.addNode(dummyNode); err != nil {
+		t.Fatal(err)
+	}
+	g.recordConnection(dummyNode, false, time.Now())
+	if addr, err := g.randomNode(); err != errNoPeers {
+		t.Fatal("node that is backing off was selected:", addr, err)
+	}
+	g.nodes[dummyNode].LastAttempt = time.Now().Add(-nodeBackoffBase)
+	if addr, err := g.randomNode(); err != nil || addr != dummyNode {
+		t.Fatal("node was not selected after its backoff:", addr, err)
+	}
+}
+
+// TestConnectRecordsHistory checks that connection attempts are recorded in
+// the node list, and that the history and bans are saved. The peer manager
+// may also try to reconnect to the closed gateway, so the number of failures
+// is not known exactly.
+func TestConnectRecordsHistory(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	g1 := newNamedTestingGateway(t, "1")
+	g2 := newNamedTestingGateway(t, "2")
+
+	if err := g1.Connect(g2.Address()); err != nil {
+		t.Fatal(err)
+	}
+	addr := g2.Address()
+	if err := g1.Disconnect(addr); err != nil {
+		t.Fatal(err)
+	}
+	if err := g2.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := g1.Connect(addr); err == nil {
+		t.Fatal("connected to a closed gateway")
+	}
+	var info modules.NodeInfo
+	for _, n := range g1.Topology().Nodes {
+		if n.NetAddress == addr {
+			info = n
+		}
+	}
+	if info.Successes != 1 || info.Failures == 0 || info.ConsecutiveFailures != info.Failures || info.LastSeen.IsZero() || !info.NextAttempt.After(info.LastAttempt) {
+		t.Fatal("connection history was not recorded:", info)
+	}
+
+	// Bans are kept in the ban history of the node and survive a restart.
+	for i := 0; i < 2; i++ {
+		g1.ReportMisbehavior(addr, modules.MisbehaviorInvalidBlock)
+	}
+	if err := g1.Close(); err != nil {
+		t.Fatal(err)
+	}
+	g1, err := New("localhost:0", false, g1.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g1.Close()
+	g1.mu.RLock()
+	n := *g1.nodes[addr]
+	banned := g1.banned(addr)
+	g1.mu.RUnlock()
+	if n.Successes != 1 || n.Failures == 0 || n.Bans != 1 || n.LastBanReason != modules.MisbehaviorInvalidBlock {
+		t.Fatal("history was not loaded:", n)
+	}
+	if !banned {
+		t.Fatal("ban did not survive a restart")
+	}
+}
@@ -3,6 +3,7 @@ package gateway
 import (
 	"errors"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/NebulousLabs/Sia/build"
@@ -38,23 +39,48 @@ func handlerName(name string) (id rpcID) {
 func (g *Gateway) managedRPC(addr modules.NetAddress, name string, fn modules.RPCFunc) error {
 	g.mu.RLock()
 	peer, ok := g.peers[addr]
+	timeouts := g.timeoutsFor(handlerName(name))
+	offline := g.offline
+	if ok && !offline {
+		g.rpcs.Add(1)
+	}
 	g.mu.RUnlock()
-	if !ok {
+	if offline {
+		return errGatewayOffline
+	} else if !ok {
 		return errors.New("can't call RPC on unconnected peer " + string(addr))
 	}
+	defer g.rpcs.Done()
+	atomic.AddUint64(&peer.atomicRPCsCalled, 1)
+	start := time.Now()
+	var rc *rpcConn
+	err := func() error {
+		conn, err := peer.open()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		rc = newRPCConn(conn, timeouts)
 
-	conn, err := peer.open()
+		// write header
+		if err := encoding.WriteObject(rc, handlerName(name)); err != nil {
+			return err
+		}
+		// call fn
+		return fn(rc)
+	}()
 	if err != nil {
-		return err
-	}
-	defer conn.Close()
-
-	// write header
-	if err := encoding.WriteObject(conn, handlerName(name)); err != nil {
-		return err
+		atomic.AddUint64(&peer.atomicRPCsFailed, 1)
+		if isMalformedErr(err) {
+			g.ReportMisbehavior(addr, modules.MisbehaviorMalformedRPC)
+		} else if rc != nil && rc.missedDeadline() {
+			g.ReportMisbehavior(addr, modules.MisbehaviorRPCTimeout)
+		}
+	} else {
+		atomic.AddUint64(&peer.atomicRPCNanos, uint64(time.Since(start)))
+		peer.markActive()
 	}
-	// call fn
-	return fn(conn)
+	return err
 }
 
 // RPC calls an RPC on the given address. RPC cannot be called on an address
@@ -146,9 +172,13 @@ func (g *Gateway) threadedListenPeer(p *peer) {
 		case <-peerCloseChan:
 		}
 
-		// Can't call Disconnect because it could return sync.ErrStopped.
+		// Can't call Disconnect because it could return sync.ErrStopped. The
+		// peer is only removed if it has not been replaced by a newer
+		// connection to the same address.
 		g.mu.Lock()
-		delete(g.peers, p.NetAddress)
+		if g.peers[p.NetAddress] == p {
+			delete(g.peers, p.NetAddress)
+		}
 		g.mu.Unlock()
 		if err := p.sess.Close(); err != nil {
 			g.log.Debugf("WARN: error disconnecting from peer %q: %v", p.NetAddress, err)
@@ -197,7 +227,20 @@ func (g *Gateway) threadedHandleConn(conn modules.PeerConn) {
 	// call registered handler for this ID
 	g.mu.RLock()
 	fn, ok := g.handlers[id]
+	rc := newRPCConn(conn, g.timeoutsFor(id))
+	if p, exists := g.peers[conn.RPCAddr()]; exists {
+		atomic.AddUint64(&p.atomicRPCsReceived, 1)
+		p.markActive()
+	}
+	offline := g.offline
+	if !offline {
+		g.rpcs.Add(1)
+	}
 	g.mu.RUnlock()
+	if offline {
+		return
+	}
+	defer g.rpcs.Done()
 	if !ok {
 		g.log.Debugf("WARN: incoming conn %v requested unknown RPC \"%v\"", conn.RPCAddr(), id)
 		return
@@ -205,13 +248,18 @@ func (g *Gateway) threadedHandleConn(conn modules.PeerConn) {
 	g.log.Debugf("INFO: incoming conn %v requested RPC \"%v\"", conn.RPCAddr(), id)
 
 	// call fn
-	err := fn(conn)
+	err := fn(rc)
 	// don't log benign errors
 	if err == modules.ErrDuplicateTransactionSet || err == modules.ErrBlockKnown {
 		err = nil
 	}
 	if err != nil {
 		g.log.Debugf("WARN: incoming RPC \"%v\" from conn %v failed: %v", id, conn.RPCAddr(), err)
+		if isMalformedErr(err) {
+			g.ReportMisbehavior(conn.RPCAddr(), modules.MisbehaviorMalformedRPC)
+		} else if rc.missedDeadline() {
+			g.ReportMisbehavior(conn.RPCAddr(), modules.MisbehaviorRPCTimeout)
+		}
 	}
 }
 
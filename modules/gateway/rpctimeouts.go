@@ -0,0 +1,190 @@
+package gateway
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// Every RPC, whether called by the gateway or by a peer, runs on an rpcConn
+// that enforces the RPC's timeouts. Before each read or write, the rpcConn
+// moves the deadline of that direction to ReadTimeout or WriteTimeout from
+// now, so that an RPC fails quickly once the peer stops responding, however
+// long the RPC is allowed to run. The deadline of the whole RPC starts at
+// Timeout from the start of the RPC, and RPCs that know how long they should
+// take replace it by calling SetDeadline, as they always have. A peer that
+// misses a deadline is reported for MisbehaviorRPCTimeout.
+
+var errNegativeRPCTimeout = errors.New("RPC timeouts cannot be negative")
+
+// rpcConn is a PeerConn that enforces the timeouts of an RPC.
+type rpcConn struct {
+	modules.PeerConn
+	timeouts modules.RPCTimeouts
+
+	deadline time.Time
+	timedOut bool
+	mu       sync.Mutex
+}
+
+// newRPCConn wraps conn in an rpcConn enforcing timeouts, starting the
+// deadline of the whole RPC.
+func newRPCConn(conn modules.PeerConn, timeouts modules.RPCTimeouts) *rpcConn {
+	rc := &rpcConn{
+		PeerConn: conn,
+		timeouts: timeouts,
+	}
+	if timeouts.Timeout > 0 {
+		rc.SetDeadline(time.Now().Add(timeouts.Timeout))
+	}
+	return rc
+}
+
+// opDeadline returns the deadline of a read or write that may block for at
+// most timeout, which is never later than the deadline of the whole RPC.
+func (rc *rpcConn) opDeadline(timeout time.Duration) time.Time {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	deadline := rc.deadline
+	if timeout > 0 {
+		opDeadline := time.Now().Add(timeout)
+		if deadline.IsZero() || opDeadline.Before(deadline) {
+			deadline = opDeadline
+		}
+	}
+	return deadline
+}
+
+// checkTimeout records whether err was caused by a missed deadline.
+func (rc *rpcConn) checkTimeout(err error) {
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		rc.mu.Lock()
+		rc.timedOut = true
+		rc.mu.Unlock()
+	}
+}
+
+// missedDeadline returns true if a read or write missed its deadline.
+func (rc *rpcConn) missedDeadline() bool {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.timedOut
+}
+
+// Read implements the io.Reader interface.
+func (rc *rpcConn) Read(b []byte) (int, error) {
+	if err := rc.PeerConn.SetReadDeadline(rc.opDeadline(rc.timeouts.ReadTimeout)); err != nil {
+		return 0, err
+	}
+	n, err := rc.PeerConn.Read(b)
+	rc.checkTimeout(err)
+	return n, err
+}
+
+// Write implements the io.Writer interface.
+func (rc *rpcConn) Write(b []byte) (int, error) {
+	if err := rc.PeerConn.SetWriteDeadline(rc.opDeadline(rc.timeouts.WriteTimeout)); err != nil {
+		return 0, err
+	}
+	n, err := rc.PeerConn.Write(b)
+	rc.checkTimeout(err)
+	return n, err
+}
+
+// SetDeadline sets the deadline of the whole RPC. A zero deadline removes it,
+// leaving only the read and write timeouts.
+func (rc *rpcConn) SetDeadline(t time.Time) error {
+	rc.mu.Lock()
+	rc.deadline = t
+	rc.mu.Unlock()
+	return rc.PeerConn.SetDeadline(t)
+}
+
+// defaultRPCTimeouts returns the timeouts that the gateway enforces on RPCs
+// unless configured otherwise.
+func defaultRPCTimeouts() modules.GatewayRPCTimeouts {
+	return modules.GatewayRPCTimeouts{
+		Default: modules.RPCTimeouts{
+			ReadTimeout:  rpcIOTimeout,
+			WriteTimeout: rpcIOTimeout,
+			Timeout:      rpcStdDeadline,
+		},
+		PerRPC: make(map[string]modules.RPCTimeouts),
+	}
+}
+
+// validateRPCTimeouts returns an error if the RPC timeouts are invalid.
+func validateRPCTimeouts(rt modules.GatewayRPCTimeouts) error {
+	valid := func(t modules.RPCTimeouts) bool {
+		return t.ReadTimeout >= 0 && t.WriteTimeout >= 0 && t.Timeout >= 0
+	}
+	if !valid(rt.Default) {
+		return errNegativeRPCTimeout
+	}
+	for name, t := range rt.PerRPC {
+		if !valid(t) {
+			return errors.New(errNegativeRPCTimeout.Error() + ": " + name)
+		}
+	}
+	return nil
+}
+
+// timeoutsFor returns the timeouts of the RPC with the given ID.
+func (g *Gateway) timeoutsFor(id rpcID) modules.RPCTimeouts {
+	timeouts := g.rpcTimeouts.Default
+	for name, t := range g.rpcTimeouts.PerRPC {
+		if handlerName(name) != id {
+			continue
+		}
+		if t.ReadTimeout != 0 {
+			timeouts.ReadTimeout = t.ReadTimeout
+		}
+		if t.WriteTimeout != 0 {
+			timeouts.WriteTimeout = t.WriteTimeout
+		}
+		if t.Timeout != 0 {
+			timeouts.Timeout = t.Timeout
+		}
+		break
+	}
+	return timeouts
+}
+
+// RPCTimeouts returns the deadlines that the gateway enforces on RPCs.
+func (g *Gateway) RPCTimeouts() modules.GatewayRPCTimeouts {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	rt := modules.GatewayRPCTimeouts{
+		Default: g.rpcTimeouts.Default,
+		PerRPC:  make(map[string]modules.RPCTimeouts, len(g.rpcTimeouts.PerRPC)),
+	}
+	for name, t := range g.rpcTimeouts.PerRPC {
+		rt.PerRPC[name] = t
+	}
+	return rt
+}
+
+// SetRPCTimeouts changes the deadlines that the gateway enforces on RPCs.
+// The new deadlines apply to RPCs that start after the call, and are saved
+// to disk.
+func (g *Gateway) SetRPCTimeouts(rt modules.GatewayRPCTimeouts) error {
+	if err := g.threads.Add(); err != nil {
+		return err
+	}
+	defer g.threads.Done()
+	if err := validateRPCTimeouts(rt); err != nil {
+		return err
+	}
+
+	perRPC := make(map[string]modules.RPCTimeouts, len(rt.PerRPC))
+	for name, t := range rt.PerRPC {
+		perRPC[name] = t
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.rpcTimeouts = modules.GatewayRPCTimeouts{Default: rt.Default, PerRPC: perRPC}
+	return g.saveSettings()
+}
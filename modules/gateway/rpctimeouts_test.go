@@ -0,0 +1,112 @@
+package gateway
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestTimeoutsFor checks that per-RPC timeouts override the defaults, with
+// zero fields falling back to the defaults.
+func TestTimeoutsFor(t *testing.T) {
+	g := &Gateway{rpcTimeouts: modules.GatewayRPCTimeouts{
+		Default: modules.RPCTimeouts{ReadTimeout: time.Second, WriteTimeout: 2 * time.Second, Timeout: time.Minute},
+		PerRPC: map[string]modules.RPCTimeouts{
+			"SendBlocks": {Timeout: time.Hour},
+		},
+	}}
+	if to := g.timeoutsFor(handlerName("SendBlocks")); to != (modules.RPCTimeouts{ReadTimeout: time.Second, WriteTimeout: 2 * time.Second, Timeout: time.Hour}) {
+		t.Fatal("per-RPC timeouts were not applied:", to)
+	}
+	if to := g.timeoutsFor(handlerName("ShareNodes")); to != g.rpcTimeouts.Default {
+		t.Fatal("RPC without an override did not use the defaults:", to)
+	}
+}
+
+// TestRPCTimeout checks that an RPC fails once the peer stops responding for
+// longer than the read timeout, and that the peer is penalized.
+func TestRPCTimeout(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	g1 := newNamedTestingGateway(t, "1")
+	defer g1.Close()
+	g2 := newNamedTestingGateway(t, "2")
+	defer g2.Close()
+
+	// g2 reads the request and then stalls.
+	g2.RegisterRPC("Stall", func(conn modules.PeerConn) error {
+		var req uint64
+		if err := encoding.ReadObject(conn, &req, 8); err != nil {
+			return err
+		}
+		time.Sleep(2 * time.Second)
+		return nil
+	})
+	if err := g1.Connect(g2.Address()); err != nil {
+		t.Fatal(err)
+	}
+	rt := g1.RPCTimeouts()
+	rt.PerRPC["Stall"] = modules.RPCTimeouts{ReadTimeout: 200 * time.Millisecond}
+	if err := g1.SetRPCTimeouts(rt); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	err := g1.RPC(g2.Address(), "Stall", func(conn modules.PeerConn) error {
+		if err := encoding.WriteObject(conn, uint64(1)); err != nil {
+			return err
+		}
+		var resp uint64
+		return encoding.ReadObject(conn, &resp, 8)
+	})
+	if err == nil {
+		t.Fatal("expected the RPC to time out")
+	} else if time.Since(start) > time.Second {
+		t.Fatal("RPC did not time out after the read timeout:", time.Since(start))
+	}
+	g1.mu.RLock()
+	score := g1.peerBanScore(g2.Address())
+	g1.mu.RUnlock()
+	if score == 0 {
+		t.Fatal("peer that timed out was not penalized")
+	}
+}
+
+// TestRPCTimeoutsPersist checks that the gateway's RPC timeouts persist
+// across restarts, and that negative timeouts are rejected.
+func TestRPCTimeoutsPersist(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	g := newTestingGateway(t)
+	rt := g.RPCTimeouts()
+	if rt.Default != defaultRPCTimeouts().Default {
+		t.Fatal("new gateway does not use the default RPC timeouts:", rt.Default)
+	}
+	rt.Default.ReadTimeout = 5 * time.Second
+	rt.PerRPC["SendBlocks"] = modules.RPCTimeouts{Timeout: time.Hour}
+	if err := g.SetRPCTimeouts(rt); err != nil {
+		t.Fatal(err)
+	}
+	bad := modules.GatewayRPCTimeouts{Default: modules.RPCTimeouts{Timeout: -1}}
+	if err := g.SetRPCTimeouts(bad); err != errNegativeRPCTimeout {
+		t.Fatal("expected errNegativeRPCTimeout, got", err)
+	}
+	if err := g.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := New("localhost:0", false, g.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	loaded := g.RPCTimeouts()
+	if loaded.Default != rt.Default || loaded.PerRPC["SendBlocks"] != rt.PerRPC["SendBlocks"] {
+		t.Fatalf("expected RPC timeouts %v after restart, got %v", rt, loaded)
+	}
+}
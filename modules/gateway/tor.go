@@ -0,0 +1,191 @@
+package gateway
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// The gateway publishes itself as an onion service by asking Tor, over its
+// control port, to forward connections to the onion address to the gateway's
+// listener (see the ADD_ONION command of Tor's control protocol). The onion
+// service's private key is saved in the gateway's persist directory, so that
+// the onion address does not change when siad restarts. The control
+// connection is kept open for the lifetime of the gateway, and Tor removes
+// the onion service once it is closed.
+
+const (
+	// onionKeyFile is the file that the private key of the gateway's onion
+	// service is stored in.
+	onionKeyFile = "onion.key"
+
+	// torControlTimeout is the amount of time that Tor is given to answer a
+	// command on the control port.
+	torControlTimeout = 2 * time.Minute
+)
+
+var errNoTorAuth = errors.New("Tor control port does not offer a supported authentication method (NULL or COOKIE)")
+
+// torControlConn is a connection to Tor's control port.
+type torControlConn struct {
+	conn net.Conn
+	text *textproto.Conn
+}
+
+// command sends a command to Tor and returns the lines of its reply. An error
+// is returned if Tor replies with anything other than 250 (OK).
+func (tc *torControlConn) command(format string, args ...interface{}) ([]string, error) {
+	tc.conn.SetDeadline(time.Now().Add(torControlTimeout))
+	if err := tc.text.PrintfLine(format, args...); err != nil {
+		return nil, err
+	}
+	var lines []string
+	for {
+		line, err := tc.text.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+		if len(line) < 4 {
+			return nil, fmt.Errorf("malformed reply from Tor: %q", line)
+		}
+		if line[:3] != "250" {
+			return nil, fmt.Errorf("Tor refused the command: %v", line)
+		}
+		lines = append(lines, line[4:])
+		if line[3] == ' ' {
+			return lines, nil
+		}
+	}
+}
+
+// authenticate authenticates with Tor, using cookie authentication if it is
+// enabled.
+func (tc *torControlConn) authenticate() error {
+	lines, err := tc.command("PROTOCOLINFO 1")
+	if err != nil {
+		return err
+	}
+	var methods, cookieFile string
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "AUTH ") {
+			continue
+		}
+		for _, field := range strings.Fields(line[len("AUTH "):]) {
+			if strings.HasPrefix(field, "METHODS=") {
+				methods = strings.TrimPrefix(field, "METHODS=")
+			}
+		}
+		if i := strings.Index(line, `COOKIEFILE="`); i >= 0 {
+			cookieFile = line[i+len(`COOKIEFILE="`):]
+			if j := strings.Index(cookieFile, `"`); j >= 0 {
+				cookieFile = cookieFile[:j]
+			}
+		}
+	}
+
+	supported := make(map[string]bool)
+	for _, method := range strings.Split(methods, ",") {
+		supported[method] = true
+	}
+	switch {
+	case supported["NULL"]:
+		_, err = tc.command("AUTHENTICATE")
+	case supported["COOKIE"] && cookieFile != "":
+		var cookie []byte
+		cookie, err = ioutil.ReadFile(cookieFile)
+		if err != nil {
+			return fmt.Errorf("could not read Tor's authentication cookie: %v", err)
+		}
+		_, err = tc.command("AUTHENTICATE %v", hex.EncodeToString(cookie))
+	default:
+		return errNoTorAuth
+	}
+	return err
+}
+
+// addOnion asks Tor to publish an onion service that forwards the provided
+// port to target, returning the service ID. If key is empty, Tor generates a
+// new key, which is returned.
+func (tc *torControlConn) addOnion(key, port, target string) (serviceID, newKey string, err error) {
+	if key == "" {
+		key = "NEW:ED25519-V3"
+	}
+	lines, err := tc.command("ADD_ONION %v Port=%v,%v", key, port, target)
+	if err != nil {
+		return "", "", err
+	}
+	for _, line := range lines {
+		if strings.HasPrefix(line, "ServiceID=") {
+			serviceID = strings.TrimPrefix(line, "ServiceID=")
+		} else if strings.HasPrefix(line, "PrivateKey=") {
+			newKey = strings.TrimPrefix(line, "PrivateKey=")
+		}
+	}
+	if serviceID == "" {
+		return "", "", errors.New("Tor did not return the onion address")
+	}
+	return serviceID, newKey, nil
+}
+
+// publishOnion publishes the gateway as an onion service, forwarding
+// connections to the onion address to the gateway's listener, and returns the
+// onion address. The onion service is removed when the gateway shuts down.
+func (g *Gateway) publishOnion() (modules.NetAddress, error) {
+	conn, err := net.DialTimeout("tcp", g.proxy.TorControl, dialTimeout)
+	if err != nil {
+		return "", fmt.Errorf("could not reach Tor control port: %v", err)
+	}
+	tc := &torControlConn{
+		conn: conn,
+		text: textproto.NewConn(conn),
+	}
+	if err := tc.authenticate(); err != nil {
+		conn.Close()
+		return "", fmt.Errorf("could not authenticate with Tor: %v", err)
+	}
+
+	// Reuse the key of the existing onion service, if any, so that the
+	// gateway keeps its address.
+	keyPath := filepath.Join(g.persistDir, onionKeyFile)
+	keyBytes, err := ioutil.ReadFile(keyPath)
+	if err != nil && !os.IsNotExist(err) {
+		conn.Close()
+		return "", err
+	}
+	key := strings.TrimSpace(string(keyBytes))
+
+	// Tor forwards connections to the listener, using the loopback interface
+	// if the listener is bound to all interfaces.
+	target := g.listener.Addr().String()
+	if host, _, err := net.SplitHostPort(target); err == nil && net.ParseIP(host).IsUnspecified() {
+		target = net.JoinHostPort("127.0.0.1", g.port)
+	}
+	serviceID, newKey, err := tc.addOnion(key, g.port, target)
+	if err != nil {
+		conn.Close()
+		return "", err
+	}
+	if newKey != "" {
+		if err := ioutil.WriteFile(keyPath, []byte(newKey+"\n"), 0600); err != nil {
+			conn.Close()
+			return "", fmt.Errorf("could not save onion service key: %v", err)
+		}
+	}
+
+	// Keep the control connection open, as Tor removes the onion service
+	// when it is closed.
+	conn.SetDeadline(time.Time{})
+	g.threads.AfterStop(func() {
+		conn.Close()
+	})
+	return modules.NetAddress(net.JoinHostPort(serviceID+".onion", g.port)), nil
+}
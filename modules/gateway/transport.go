@@ -0,0 +1,150 @@
+package gateway
+
+import (
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+const (
+	// transportTCP and transportQUIC name the transports that peer
+	// connections can be made over.
+	transportTCP  = "tcp"
+	transportQUIC = "quic"
+)
+
+// transportStats holds the metrics of a single transport. All fields are
+// accessed atomically.
+type transportStats struct {
+	atomicBytesRead    uint64
+	atomicBytesWritten uint64
+	atomicDials        uint64
+	atomicDialFailures uint64
+	atomicDialNanos    uint64
+	atomicInbound      uint64
+}
+
+// countingConn is a net.Conn that counts the bytes read from and written to
+// it in the stats of its transport.
+type countingConn struct {
+	net.Conn
+	transport string
+	stats     *transportStats
+}
+
+// Read implements the io.Reader interface.
+func (cc *countingConn) Read(b []byte) (int, error) {
+	n, err := cc.Conn.Read(b)
+	atomic.AddUint64(&cc.stats.atomicBytesRead, uint64(n))
+	return n, err
+}
+
+// Write implements the io.Writer interface.
+func (cc *countingConn) Write(b []byte) (int, error) {
+	n, err := cc.Conn.Write(b)
+	atomic.AddUint64(&cc.stats.atomicBytesWritten, uint64(n))
+	return n, err
+}
+
+// countConn wraps conn so that its traffic is counted in the stats of the
+// transport. Every peer connection passes through countConn, so it also
+// subjects the connection to the gateway's rate limits.
+func (g *Gateway) countConn(conn net.Conn, transport string) net.Conn {
+	return &countingConn{
+		Conn:      g.limitConn(conn),
+		transport: transport,
+		stats:     g.transports[transport],
+	}
+}
+
+// connCounter returns the countingConn underlying conn, or nil if conn is not
+// counted.
+func connCounter(conn net.Conn) *countingConn {
+	if ec, ok := conn.(*encryptedConn); ok {
+		conn = ec.Conn
+	}
+	cc, _ := conn.(*countingConn)
+	return cc
+}
+
+// connTransport returns the transport that conn was made over.
+func connTransport(conn net.Conn) string {
+	if cc := connCounter(conn); cc != nil {
+		return cc.transport
+	}
+	return transportTCP
+}
+
+// managedDialTransport dials addr over the provided transport, recording the
+// attempt in the transport's stats.
+func (g *Gateway) managedDialTransport(transport string, addr modules.NetAddress) (net.Conn, error) {
+	stats := g.transports[transport]
+	atomic.AddUint64(&stats.atomicDials, 1)
+	start := time.Now()
+
+	var conn net.Conn
+	var err error
+	if transport == transportQUIC {
+		conn, err = g.dialQUIC(addr)
+	} else {
+		conn, err = g.dial(addr)
+	}
+	if err != nil {
+		atomic.AddUint64(&stats.atomicDialFailures, 1)
+		return nil, err
+	}
+	atomic.AddUint64(&stats.atomicDialNanos, uint64(time.Since(start)))
+	return g.countConn(conn, transport), nil
+}
+
+// managedDialPeer dials a peer that the gateway wants to connect to. Nodes
+// known to accept QUIC connections are dialed over QUIC if the gateway has
+// QUIC enabled, falling back to TCP if the QUIC dial fails.
+func (g *Gateway) managedDialPeer(addr modules.NetAddress) (net.Conn, error) {
+	g.mu.RLock()
+	n, exists := g.nodes[addr]
+	useQUIC := g.quicListener != nil && exists && n.QUIC
+	g.mu.RUnlock()
+
+	if useQUIC {
+		conn, err := g.managedDialTransport(transportQUIC, addr)
+		if err == nil {
+			return conn, nil
+		}
+		g.log.Debugf("INFO: could not dial %v over QUIC, falling back to TCP: %v", addr, err)
+	}
+	return g.managedDialTransport(transportTCP, addr)
+}
+
+// Transports returns the metrics of each transport that peer connections can
+// be made over, so that their performance can be compared.
+func (g *Gateway) Transports() []modules.GatewayTransportStats {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	peers := make(map[string]int)
+	for _, p := range g.peers {
+		peers[p.transport]++
+	}
+
+	var ts []modules.GatewayTransportStats
+	for _, transport := range []string{transportTCP, transportQUIC} {
+		stats := g.transports[transport]
+		s := modules.GatewayTransportStats{
+			Transport:    transport,
+			Enabled:      transport == transportTCP || g.quicListener != nil,
+			Peers:        peers[transport],
+			Dials:        atomic.LoadUint64(&stats.atomicDials),
+			DialFailures: atomic.LoadUint64(&stats.atomicDialFailures),
+			Inbound:      atomic.LoadUint64(&stats.atomicInbound),
+			BytesRead:    atomic.LoadUint64(&stats.atomicBytesRead),
+			BytesWritten: atomic.LoadUint64(&stats.atomicBytesWritten),
+		}
+		if s.Dials > s.DialFailures {
+			s.AverageDialTime = time.Duration(atomic.LoadUint64(&stats.atomicDialNanos) / (s.Dials - s.DialFailures))
+		}
+		ts = append(ts, s)
+	}
+	return ts
+}
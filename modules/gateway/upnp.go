@@ -54,12 +54,21 @@ func (g *Gateway) threadedLearnHostname() {
 		return
 	}
 
-	// try UPnP first, then fallback to myexternalip.com
+	// try UPnP first, then NAT-PMP, then fallback to myexternalip.com
 	var host string
 	d, err := upnp.Discover()
 	if err == nil {
 		host, err = d.ExternalIP()
 	}
+	if err != nil {
+		var router *net.UDPAddr
+		router, err = discoverNATRouter()
+		if err == nil {
+			var ip net.IP
+			ip, err = natPMPExternalIP(router)
+			host = ip.String()
+		}
+	}
 	if err != nil {
 		host, err = myExternalIP()
 	}
@@ -83,7 +92,9 @@ func (g *Gateway) threadedLearnHostname() {
 	g.log.Println("INFO: our address is", addr)
 }
 
-// threadedForwardPort adds a port mapping to the router.
+// threadedForwardPort adds a port mapping to the router. UPnP is tried first.
+// If the router does not support UPnP, the port is forwarded with PCP or
+// NAT-PMP instead.
 func (g *Gateway) threadedForwardPort(port string) {
 	if err := g.threads.Add(); err != nil {
 		return
@@ -94,19 +105,38 @@ func (g *Gateway) threadedForwardPort(port string) {
 		return
 	}
 
+	portInt, _ := strconv.Atoi(port)
 	d, err := upnp.Discover()
-	if err != nil {
-		g.log.Printf("WARN: could not automatically forward port %s: no UPnP-enabled devices found: %v", port, err)
-		return
+	if err == nil {
+		err = d.Forward(uint16(portInt), "Sia RPC")
 	}
-
-	portInt, _ := strconv.Atoi(port)
-	err = d.Forward(uint16(portInt), "Sia RPC")
 	if err != nil {
-		g.log.Printf("WARN: could not automatically forward port %s: %v", port, err)
+		g.log.Printf("WARN: could not automatically forward port %s with UPnP, trying PCP and NAT-PMP: %v", port, err)
+		router, err := discoverNATRouter()
+		if err != nil {
+			g.mu.Lock()
+			g.portMapping.LastAttempt = time.Now()
+			g.portMapping.Error = "could not find the router: " + err.Error()
+			g.mu.Unlock()
+			g.log.Printf("WARN: could not automatically forward port %s: could not find the router: %v", port, err)
+			return
+		}
+		go g.threadedMaintainNATMapping(router, uint16(portInt))
 		return
 	}
 
+	var host string
+	if ip, err := d.ExternalIP(); err == nil {
+		host = ip
+	}
+	g.mu.Lock()
+	g.portMapping = modules.GatewayPortMapping{
+		Protocol:        modules.PortMappingUPnP,
+		Mapped:          true,
+		ExternalAddress: modules.NetAddress(net.JoinHostPort(host, port)),
+		LastAttempt:     time.Now(),
+	}
+	g.mu.Unlock()
 	g.log.Println("INFO: successfully forwarded port", port)
 
 	// Establish port-clearing at shutdown.
@@ -115,6 +145,14 @@ func (g *Gateway) threadedForwardPort(port string) {
 	})
 }
 
+// PortMapping returns the status of the gateway's attempt to forward its port
+// on the router.
+func (g *Gateway) PortMapping() modules.GatewayPortMapping {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.portMapping
+}
+
 // managedClearPort removes a port mapping from the router.
 func (g *Gateway) managedClearPort(port string) {
 	if build.Release == "testing" {
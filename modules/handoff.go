@@ -0,0 +1,90 @@
+package modules
+
+import (
+	"errors"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// A host that is shutting down can hand off the sectors of a contract to a
+// replacement host that the renter already has a contract with, instead of
+// leaving the renter to repair every file stored on it. The renter approves
+// the handoff by signing a HandoffAuthorization with the keys of both
+// contracts and sending it to the retiring host over RPCHandoff. The
+// retiring host pushes the listed sectors to the replacement host over
+// RPCReceiveHandoff, which stages them until the renter attaches them to its
+// contract with an ActionAttach revision, and then returns a signed
+// HandoffRecord to the renter.
+
+var (
+	// ErrInvalidHandoffSignature is returned when a signature in a
+	// HandoffAuthorization or HandoffRecord does not match its contents.
+	ErrInvalidHandoffSignature = errors.New("handoff signature is invalid")
+)
+
+// A HandoffAuthorization is signed by a renter to approve the transfer of
+// sectors from the host of one of its contracts to the host of another. It is
+// signed once with the renter's key for each contract, so that each host can
+// verify the authorization against the contract that it holds. The
+// authorization can not be used after the Expiration height.
+type HandoffAuthorization struct {
+	ContractID            types.FileContractID `json:"contractid"`
+	ReplacementContractID types.FileContractID `json:"replacementcontractid"`
+	ReplacementHost       NetAddress           `json:"replacementhost"`
+	ReplacementHostKey    types.SiaPublicKey   `json:"replacementhostkey"`
+	SectorRoots           MerkleRootSet        `json:"sectorroots"`
+	Expiration            types.BlockHeight    `json:"expiration"`
+
+	ContractSignature    []byte `json:"contractsignature"`
+	ReplacementSignature []byte `json:"replacementsignature"`
+}
+
+// SigHash returns the hash of the authorization that is covered by its
+// signatures.
+func (ha HandoffAuthorization) SigHash() crypto.Hash {
+	return crypto.HashAll(ha.ContractID, ha.ReplacementContractID, ha.ReplacementHost,
+		ha.ReplacementHostKey, []crypto.Hash(ha.SectorRoots), ha.Expiration)
+}
+
+// VerifyContractSignature checks that the authorization was signed by the
+// renter key of the contract being handed off.
+func (ha HandoffAuthorization) VerifyContractSignature(renterKey types.SiaPublicKey) error {
+	if !verifyRenterSignature(ha.SigHash(), renterKey, ha.ContractSignature) {
+		return ErrInvalidHandoffSignature
+	}
+	return nil
+}
+
+// VerifyReplacementSignature checks that the authorization was signed by the
+// renter key of the contract that the sectors are handed off to.
+func (ha HandoffAuthorization) VerifyReplacementSignature(renterKey types.SiaPublicKey) error {
+	if !verifyRenterSignature(ha.SigHash(), renterKey, ha.ReplacementSignature) {
+		return ErrInvalidHandoffSignature
+	}
+	return nil
+}
+
+// A HandoffRecord is returned by a retiring host once the replacement host
+// has received every sector listed in the authorization. It is signed with
+// the retiring host's key, giving the renter a record of the handoff.
+type HandoffRecord struct {
+	Authorization HandoffAuthorization `json:"authorization"`
+	Height        types.BlockHeight    `json:"height"`
+	HostKey       types.SiaPublicKey   `json:"hostkey"`
+	HostSignature []byte               `json:"hostsignature"`
+}
+
+// SigHash returns the hash of the record that is covered by its signature.
+func (hr HandoffRecord) SigHash() crypto.Hash {
+	return crypto.HashAll(hr.Authorization, hr.Height, hr.HostKey)
+}
+
+// VerifySignature checks that the record was signed by the key in its HostKey
+// field and has not been modified since.
+func (hr HandoffRecord) VerifySignature() error {
+	if !verifyRenterSignature(hr.SigHash(), hr.HostKey, hr.HostSignature) {
+		return ErrInvalidHandoffSignature
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package modules
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestHandoffSignatures checks that the signatures of handoff authorizations
+// and records cover their contents.
+func TestHandoffSignatures(t *testing.T) {
+	contractSK, contractPK := crypto.GenerateKeyPair()
+	replacementSK, replacementPK := crypto.GenerateKeyPair()
+	hostSK, hostPK := crypto.GenerateKeyPair()
+
+	ha := HandoffAuthorization{
+		ContractID:            types.FileContractID{1},
+		ReplacementContractID: types.FileContractID{2},
+		ReplacementHost:       "foo.com:9982",
+		SectorRoots:           MerkleRootSet{{3}, {4}},
+		Expiration:            100,
+	}
+	contractSig := crypto.SignHash(ha.SigHash(), contractSK)
+	replacementSig := crypto.SignHash(ha.SigHash(), replacementSK)
+	ha.ContractSignature = contractSig[:]
+	ha.ReplacementSignature = replacementSig[:]
+	if err := ha.VerifyContractSignature(types.Ed25519PublicKey(contractPK)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ha.VerifyReplacementSignature(types.Ed25519PublicKey(replacementPK)); err != nil {
+		t.Fatal(err)
+	}
+	// Each signature must be made with the key of its own contract.
+	if err := ha.VerifyContractSignature(types.Ed25519PublicKey(replacementPK)); err != ErrInvalidHandoffSignature {
+		t.Fatal("expected ErrInvalidHandoffSignature, got", err)
+	}
+
+	record := HandoffRecord{
+		Authorization: ha,
+		Height:        50,
+		HostKey:       types.Ed25519PublicKey(hostPK),
+	}
+	sig := crypto.SignHash(record.SigHash(), hostSK)
+	record.HostSignature = sig[:]
+	if err := record.VerifySignature(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Changing the handed off sectors invalidates every signature.
+	ha.SectorRoots = ha.SectorRoots[:1]
+	if err := ha.VerifyContractSignature(types.Ed25519PublicKey(contractPK)); err != ErrInvalidHandoffSignature {
+		t.Fatal("expected ErrInvalidHandoffSignature, got", err)
+	}
+	record.Authorization = ha
+	if err := record.VerifySignature(); err != ErrInvalidHandoffSignature {
+		t.Fatal("expected ErrInvalidHandoffSignature, got", err)
+	}
+}
@@ -1,12 +1,50 @@
 package modules
 
 import (
+	"time"
+
 	"github.com/NebulousLabs/Sia/types"
 )
 
 const (
 	// HostDir names the directory that contains the host persistence.
 	HostDir = "host"
+
+	// HostAbuseStatusOK, HostAbuseStatusThrottled, and HostAbuseStatusBanned
+	// are the statuses of a renter tracked by the host's abuse detection.
+	// RPCs from throttled renters are delayed, and RPCs from banned renters
+	// are refused.
+	HostAbuseStatusOK        = "ok"
+	HostAbuseStatusThrottled = "throttled"
+	HostAbuseStatusBanned    = "banned"
+
+	// HostAbuseOverrideAllow and HostAbuseOverrideBan are the overrides that
+	// the host operator can set for a renter. Allowed renters are never
+	// throttled or banned, and banned renters are banned until the override
+	// is removed.
+	HostAbuseOverrideAllow = "allow"
+	HostAbuseOverrideBan   = "ban"
+
+	// HostFinancialPeriodDay, HostFinancialPeriodWeek, and
+	// HostFinancialPeriodMonth are the periods by which the financial
+	// activity of the host can be broken down. Periods start at midnight UTC,
+	// weeks on Monday and months on the first day of the month.
+	HostFinancialPeriodDay   = "day"
+	HostFinancialPeriodWeek  = "week"
+	HostFinancialPeriodMonth = "month"
+
+	// HostProofStatusPending, HostProofStatusDue, HostProofStatusSubmitted,
+	// HostProofStatusConfirmed, HostProofStatusMissed, and
+	// HostProofStatusNotRequired describe the storage proof of a storage
+	// obligation. A proof is pending until the proof window opens, and due
+	// once it has opened until the host submits a proof. Obligations whose
+	// contracts never made it onto the blockchain do not require a proof.
+	HostProofStatusPending     = "pending"
+	HostProofStatusDue         = "due"
+	HostProofStatusSubmitted   = "submitted"
+	HostProofStatusConfirmed   = "confirmed"
+	HostProofStatusMissed      = "missed"
+	HostProofStatusNotRequired = "notrequired"
 )
 
 var (
@@ -18,6 +56,20 @@ var (
 )
 
 type (
+	// HostAnnouncementStatus describes the host's announcement.
+	// AnnouncedAddress is the address that the host last announced, and
+	// CurrentAddress is the address that it would announce now. Pending is
+	// true while the two differ, until the host re-announces. Warning is set
+	// if the host's address is a DNS name that does not resolve to the
+	// host's external IP.
+	HostAnnouncementStatus struct {
+		AnnouncedAddress NetAddress `json:"announcedaddress"`
+		CurrentAddress   NetAddress `json:"currentaddress"`
+		LastAnnouncement time.Time  `json:"lastannouncement"`
+		Pending          bool       `json:"pending"`
+		Warning          string     `json:"warning"`
+	}
+
 	// HostFinancialMetrics provides financial statistics for the host,
 	// including money that is locked in contracts. Though verbose, these
 	// statistics should provide a clear picture of where the host's money is
@@ -48,6 +100,33 @@ type (
 		UploadBandwidthRevenue            types.Currency `json:"uploadbandwidthrevenue"`
 	}
 
+	// HostFinancialPeriod breaks the financial activity of the host down to a
+	// single day, week, or month, from Start up to but not including End.
+	// Revenue is counted in the period in which the storage proof for the
+	// contract was submitted, and losses in the period in which the proof
+	// window closed without a proof. LockedStorageCollateral and
+	// RiskedStorageCollateral are the amounts that were committed to
+	// contracts at the end of the period, or currently for the ongoing period.
+	HostFinancialPeriod struct {
+		Start time.Time `json:"start"`
+		End   time.Time `json:"end"`
+
+		ContractsFormed  uint64 `json:"contractsformed"`
+		SuccessfulProofs uint64 `json:"successfulproofs"`
+		MissedProofs     uint64 `json:"missedproofs"`
+
+		ContractCompensation     types.Currency `json:"contractcompensation"`
+		StorageRevenue           types.Currency `json:"storagerevenue"`
+		DownloadBandwidthRevenue types.Currency `json:"downloadbandwidthrevenue"`
+		UploadBandwidthRevenue   types.Currency `json:"uploadbandwidthrevenue"`
+
+		LockedStorageCollateral types.Currency `json:"lockedstoragecollateral"`
+		RiskedStorageCollateral types.Currency `json:"riskedstoragecollateral"`
+
+		LostRevenue           types.Currency `json:"lostrevenue"`
+		LostStorageCollateral types.Currency `json:"loststoragecollateral"`
+	}
+
 	// HostInternalSettings contains a list of settings that can be changed.
 	HostInternalSettings struct {
 		AcceptingContracts   bool              `json:"acceptingcontracts"`
@@ -57,6 +136,50 @@ type (
 		NetAddress           NetAddress        `json:"netaddress"`
 		WindowSize           types.BlockHeight `json:"windowsize"`
 
+		// AcceptingRelays allows hosts that are not reachable from the
+		// internet to accept renter sessions through this host. RelayAddress
+		// is the address of the relay host that this host registers with; if
+		// it is set, the host announces the address allocated by the relay
+		// unless NetAddress is set.
+		AcceptingRelays bool       `json:"acceptingrelays"`
+		RelayAddress    NetAddress `json:"relayaddress"`
+
+		// Concurrency limits for incoming RPCs. A value of zero means that
+		// the number of concurrent RPCs of that type is not limited.
+		MaxConcurrentDownloads    uint64 `json:"maxconcurrentdownloads"`
+		MaxConcurrentNegotiations uint64 `json:"maxconcurrentnegotiations"`
+		MaxConcurrentRevisions    uint64 `json:"maxconcurrentrevisions"`
+
+		// Bandwidth limits that apply to each renter separately, in bytes
+		// per second. All of a renter's connections share its limits.
+		// MaxRenterDownloadSpeed limits the data sent by the host to the
+		// renter, and MaxRenterUploadSpeed the data sent by the renter to the
+		// host. A value of zero means that renters are not limited.
+		MaxRenterDownloadSpeed uint64 `json:"maxrenterdownloadspeed"`
+		MaxRenterUploadSpeed   uint64 `json:"maxrenteruploadspeed"`
+
+		// Contract acceptance filters, applied when contracts are formed
+		// and renewed. MinContractDuration is the minimum number of blocks
+		// until the proof window of a contract starts, the maximum being
+		// MaxDuration. MaxContractSize is the most data in bytes that a
+		// contract may hold, and also bounds revisions. New contracts start
+		// out empty, so MinContractSize is the least data that a contract
+		// must hold to be renewed. If RenterWhitelist is not empty, only the
+		// listed renters may form contracts, and the renters listed in
+		// RenterBlacklist may not form contracts at all. Renters are listed
+		// by public key, e.g. "ed25519:<hex>". MaxContractsPerRenter limits
+		// the number of active contracts of each renter, and
+		// MaxStoragePerRenter limits the bytes that each renter stores under
+		// its active contracts, enforced when data is uploaded. A value of
+		// zero means that there is no limit.
+		MinContractDuration   types.BlockHeight `json:"mincontractduration"`
+		MinContractSize       uint64            `json:"mincontractsize"`
+		MaxContractSize       uint64            `json:"maxcontractsize"`
+		MaxContractsPerRenter uint64            `json:"maxcontractsperrenter"`
+		MaxStoragePerRenter   uint64            `json:"maxstorageperrenter"`
+		RenterWhitelist       []string          `json:"renterwhitelist"`
+		RenterBlacklist       []string          `json:"renterblacklist"`
+
 		Collateral       types.Currency `json:"collateral"`
 		CollateralBudget types.Currency `json:"collateralbudget"`
 		MaxCollateral    types.Currency `json:"maxcollateral"`
@@ -67,28 +190,142 @@ type (
 		MinUploadBandwidthPrice   types.Currency `json:"minuploadbandwidthprice"`
 	}
 
+	// HostGarbageCollection reports a garbage collection pass, which removes
+	// the sectors of storage obligations whose contracts have expired.
+	// Obligations is the number of obligations whose sectors were removed,
+	// and SectorsReleased the number of sectors they held. Sectors that are
+	// also stored for other contracts stay on disk, so only SectorsReclaimed
+	// sectors were actually freed. Folders breaks the reclaimed space down by
+	// storage folder.
+	HostGarbageCollection struct {
+		StartTime time.Time `json:"starttime"`
+		EndTime   time.Time `json:"endtime"`
+
+		Obligations      uint64                     `json:"obligations"`
+		SectorsReleased  uint64                     `json:"sectorsreleased"`
+		SectorsReclaimed uint64                     `json:"sectorsreclaimed"`
+		BytesReclaimed   uint64                     `json:"bytesreclaimed"`
+		Folders          []StorageFolderReclamation `json:"folders"`
+	}
+
+	// HostGarbageCollectionStatus describes the garbage collection of the
+	// host. CollectableObligations and CollectableSectors describe what the
+	// next pass would remove. WaitingObligations is the number of resolved
+	// obligations whose sectors are kept until their proof window closes.
+	HostGarbageCollectionStatus struct {
+		Running        bool                  `json:"running"`
+		LastCollection HostGarbageCollection `json:"lastcollection"`
+
+		CollectableObligations uint64 `json:"collectableobligations"`
+		CollectableSectors     uint64 `json:"collectablesectors"`
+		WaitingObligations     uint64 `json:"waitingobligations"`
+	}
+
 	// HostNetworkMetrics reports the quantity of each type of RPC call that
 	// has been made to the host.
 	HostNetworkMetrics struct {
 		DownloadCalls     uint64 `json:"downloadcalls"`
 		ErrorCalls        uint64 `json:"errorcalls"`
 		FormContractCalls uint64 `json:"formcontractcalls"`
+		RejectedCalls     uint64 `json:"rejectedcalls"`
 		RenewCalls        uint64 `json:"renewcalls"`
 		ReviseCalls       uint64 `json:"revisecalls"`
 		SettingsCalls     uint64 `json:"settingscalls"`
 		UnrecognizedCalls uint64 `json:"unrecognizedcalls"`
 	}
 
+	// HostReachability describes the checks that the host makes of its own
+	// reachability. The host periodically asks a peer to connect to it at
+	// Address and fetch its settings, as renters do when they scan hosts.
+	// History holds the outcome of the recent checks, oldest first, and
+	// Uptime is the fraction of the time covered by History during which
+	// the host was reachable. Prober is the peer that ran the last check.
+	HostReachability struct {
+		Address   NetAddress  `json:"address"`
+		LastCheck time.Time   `json:"lastcheck"`
+		LastError string      `json:"lasterror"`
+		Prober    NetAddress  `json:"prober"`
+		Reachable bool        `json:"reachable"`
+		Uptime    float64     `json:"uptime"`
+		History   HostDBScans `json:"history"`
+	}
+
+	// HostRelayStatus describes the relay connections of the host. Connected
+	// is true while the host is registered with the relay at RelayAddress,
+	// which forwards renter sessions from RelayedAddress to the host.
+	// RelayedHosts is the number of hosts registered with this host as their
+	// relay.
+	HostRelayStatus struct {
+		RelayAddress   NetAddress `json:"relayaddress"`
+		RelayedAddress NetAddress `json:"relayedaddress"`
+		Connected      bool       `json:"connected"`
+		LastError      string     `json:"lasterror"`
+		RelayedConns   uint64     `json:"relayedconns"` // Renter sessions received through the relay.
+
+		RelayedHosts int `json:"relayedhosts"`
+	}
+
+	// HostRenterAbuse is the record kept by the host's abuse detection for a
+	// renter. Renters are identified by their public key, or by their IP
+	// address if they have not presented a key. The Recent fields count the
+	// events of each kind within the detection window. Until is the time at
+	// which a throttle or temporary ban expires.
+	HostRenterAbuse struct {
+		Renter   string    `json:"renter"`
+		Address  string    `json:"address"`
+		Status   string    `json:"status"`
+		Until    time.Time `json:"until"`
+		Reason   string    `json:"reason"`
+		Override string    `json:"override"`
+
+		RecentContracts          int `json:"recentcontracts"`
+		RecentFailedNegotiations int `json:"recentfailednegotiations"`
+		RecentUnderpaidDownloads int `json:"recentunderpaiddownloads"`
+	}
+
+	// HostRenterBandwidth is the bandwidth used by a renter since the host
+	// started. Renters are identified by their public key, or by their IP
+	// address if they have not presented a key. Downloaded is the number of
+	// bytes sent by the host to the renter, and Uploaded the number of bytes
+	// sent by the renter to the host.
+	HostRenterBandwidth struct {
+		Renter       string    `json:"renter"`
+		Address      string    `json:"address"`
+		Downloaded   uint64    `json:"downloaded"`
+		Uploaded     uint64    `json:"uploaded"`
+		LastTransfer time.Time `json:"lasttransfer"`
+	}
+
 	// StorageObligation contains information about a storage obligation that
-	// the host has accepted.
+	// the host has accepted. DataSize is the size of the data that the renter
+	// has stored with the host, and ExpirationHeight is the height at which
+	// the proof window of the contract opens. The potential revenues and the
+	// risked collateral are only earned or lost once the obligation resolves.
 	StorageObligation struct {
-		NegotiationHeight types.BlockHeight `json:"negotiationheight"`
+		ID                types.FileContractID `json:"id"`
+		RenterPublicKey   types.SiaPublicKey   `json:"renterpublickey"`
+		NegotiationHeight types.BlockHeight    `json:"negotiationheight"`
+		ExpirationHeight  types.BlockHeight    `json:"expirationheight"`
+		ProofDeadline     types.BlockHeight    `json:"proofdeadline"`
+
+		DataSize    uint64 `json:"datasize"`
+		SectorCount uint64 `json:"sectorcount"`
+
+		ContractCost             types.Currency `json:"contractcost"`
+		LockedCollateral         types.Currency `json:"lockedcollateral"`
+		PotentialDownloadRevenue types.Currency `json:"potentialdownloadrevenue"`
+		PotentialStorageRevenue  types.Currency `json:"potentialstoragerevenue"`
+		PotentialUploadRevenue   types.Currency `json:"potentialuploadrevenue"`
+		RiskedCollateral         types.Currency `json:"riskedcollateral"`
+		TransactionFeesAdded     types.Currency `json:"transactionfeesadded"`
 
 		OriginConfirmed     bool   `json:"originconfirmed"`
 		RevisionConstructed bool   `json:"revisionconstructed"`
 		RevisionConfirmed   bool   `json:"revisionconfirmed"`
 		ProofConstructed    bool   `json:"proofconstructed"`
 		ProofConfirmed      bool   `json:"proofconfirmed"`
+		ProofAttempts       uint64 `json:"proofattempts"`
+		ProofStatus         string `json:"proofstatus"`
 		ObligationStatus    uint64 `json:"obligationstatus"`
 	}
 
@@ -99,9 +336,23 @@ type (
 		// Announce submits a host announcement to the blockchain.
 		Announce() error
 
-		// AnnounceAddress submits an announcement using the given address.
+		// AnnounceAddress submits an announcement using the given address,
+		// which may be an IP address or a DNS name.
 		AnnounceAddress(NetAddress) error
 
+		// AnnouncementStatus returns the address that the host last
+		// announced, and the address that it would announce now.
+		AnnouncementStatus() HostAnnouncementStatus
+
+		// CollectGarbage removes the sectors of the storage obligations
+		// whose contracts have expired, reporting the space reclaimed in
+		// each storage folder.
+		CollectGarbage() (HostGarbageCollection, error)
+
+		// CreateBackup writes a backup of the host's keys, settings, and
+		// storage obligations to the provided file.
+		CreateBackup(dst string) error
+
 		// ExternalSettings returns the settings of the host as seen by an
 		// untrusted node querying the host for settings.
 		ExternalSettings() HostExternalSettings
@@ -109,6 +360,17 @@ type (
 		// FinancialMetrics returns the financial statistics of the host.
 		FinancialMetrics() HostFinancialMetrics
 
+		// FinancialPeriods returns the financial activity of the host over
+		// the most recent 'count' periods, oldest first. The last period is
+		// the ongoing one. 'period' is one of HostFinancialPeriodDay,
+		// HostFinancialPeriodWeek, or HostFinancialPeriodMonth.
+		FinancialPeriods(period string, count int) ([]HostFinancialPeriod, error)
+
+		// GarbageCollectionStatus returns the report of the most recent
+		// garbage collection pass, along with what the next pass would
+		// collect.
+		GarbageCollectionStatus() (HostGarbageCollectionStatus, error)
+
 		// InternalSettings returns the host's internal settings, including
 		// potentially private or sensitive information.
 		InternalSettings() HostInternalSettings
@@ -120,9 +382,38 @@ type (
 		// PublicKey returns the public key of the host.
 		PublicKey() types.SiaPublicKey
 
+		// Reachability returns the results of the host's checks of its own
+		// reachability.
+		Reachability() HostReachability
+
+		// RelayStatus returns the status of the host's relay connections.
+		RelayStatus() HostRelayStatus
+
+		// RenterAbuse returns the records kept by the host's abuse detection
+		// for renters that have recently misbehaved or have an override.
+		RenterAbuse() []HostRenterAbuse
+
+		// RenterBandwidth returns the bandwidth used by each renter that has
+		// transferred data within the last day.
+		RenterBandwidth() []HostRenterBandwidth
+
+		// RestoreBackup checks a backup created by CreateBackup and stages
+		// it to be restored the next time the host starts. Backups can only
+		// be restored into a host that has no storage obligations.
+		RestoreBackup(src string) error
+
+		// SetRenterAbuseOverride sets the override for a renter, identified
+		// by its public key or IP address. An empty override removes any
+		// existing override and clears the renter's record.
+		SetRenterAbuseOverride(renter string, override string) error
+
 		// SetInternalSettings sets the hosting parameters of the host.
 		SetInternalSettings(HostInternalSettings) error
 
+		// StorageObligation returns the storage obligation of the file
+		// contract with the provided id.
+		StorageObligation(types.FileContractID) (StorageObligation, error)
+
 		// StorageObligations returns the set of storage obligations held by
 		// the host.
 		StorageObligations() []StorageObligation
@@ -0,0 +1,431 @@
+package host
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// The host watches for renters that abuse it by churning through contracts,
+// repeatedly failing negotiations, or hammering it with download requests
+// that do not pay enough. Renters are identified by their public key once
+// they present one during an RPC, and by their IP address until then. A
+// renter whose misbehavior within the abuse window exceeds a threshold is
+// throttled for a while, and a renter that exceeds twice the threshold is
+// temporarily banned. Throttles and bans are enforced against the IP address
+// that the renter last connected from as soon as a connection is accepted,
+// and against the renter's key once the key is presented. The host operator
+// can override the decisions of the abuse detection for individual renters.
+
+// featureAbuseDetection guards the throttling and banning of abusive
+// renters. When it is disabled, renters are neither tracked nor throttled,
+// though overrides are kept.
+var featureAbuseDetection = modules.RegisterFeature("host-abuse-detection",
+	"Throttle and temporarily ban renters that churn contracts, fail negotiations, or underpay downloads.", true)
+
+// abuse event kinds.
+const (
+	abuseContract = iota
+	abuseFailedNegotiation
+	abuseUnderpaidDownload
+	numAbuseKinds
+)
+
+var (
+	// errRenterBanned is returned when a banned renter presents its key
+	// during an RPC.
+	errRenterBanned = errors.New("renter has been banned by the host")
+
+	// errBadAbuseOverride is returned when setting an unrecognized abuse
+	// override.
+	errBadAbuseOverride = errors.New("abuse override must be 'allow', 'ban', or empty")
+)
+
+// abuseDescriptions describe each kind of abuse event in the reason given for
+// a throttle or ban.
+var abuseDescriptions = [numAbuseKinds]string{
+	abuseContract:          "formed or renewed %v contracts",
+	abuseFailedNegotiation: "failed %v negotiations",
+	abuseUnderpaidDownload: "made %v underpaid download requests",
+}
+
+// abuseThresholds returns the number of events of each kind that a renter
+// may cause within the abuse window before it is throttled.
+func abuseThresholds() [numAbuseKinds]int {
+	return [numAbuseKinds]int{
+		abuseContract:          abuseMaxContracts,
+		abuseFailedNegotiation: abuseMaxFailedNegotiations,
+		abuseUnderpaidDownload: abuseMaxUnderpaidDownloads,
+	}
+}
+
+// renterActivity is the abuse record of a single renter.
+type renterActivity struct {
+	address        string
+	events         [numAbuseKinds][]time.Time
+	throttledUntil time.Time
+	bannedUntil    time.Time
+	reason         string
+}
+
+// prune forgets events that are older than the abuse window.
+func (ra *renterActivity) prune(now time.Time) {
+	for kind, times := range ra.events {
+		i := 0
+		for i < len(times) && now.Sub(times[i]) > abuseWindow {
+			i++
+		}
+		ra.events[kind] = times[i:]
+	}
+}
+
+// idle returns true if the record holds no recent events and no active
+// throttle or ban, meaning that it can be forgotten.
+func (ra *renterActivity) idle(now time.Time) bool {
+	for _, times := range ra.events {
+		if len(times) > 0 {
+			return false
+		}
+	}
+	return !now.Before(ra.throttledUntil) && !now.Before(ra.bannedUntil)
+}
+
+// status returns the status of the renter, taking its override into account,
+// and the time at which a throttle or temporary ban expires.
+func (ra *renterActivity) status(now time.Time, override string) (string, time.Time) {
+	switch {
+	case override == modules.HostAbuseOverrideAllow:
+		return modules.HostAbuseStatusOK, time.Time{}
+	case override == modules.HostAbuseOverrideBan:
+		return modules.HostAbuseStatusBanned, time.Time{}
+	case now.Before(ra.bannedUntil):
+		return modules.HostAbuseStatusBanned, ra.bannedUntil
+	case now.Before(ra.throttledUntil):
+		return modules.HostAbuseStatusThrottled, ra.throttledUntil
+	}
+	return modules.HostAbuseStatusOK, time.Time{}
+}
+
+// abuseTracker tracks the activity of renters to detect abuse. It is safe
+// for concurrent use.
+type abuseTracker struct {
+	// renters holds the record of each renter, keyed by the renter's public
+	// key, or by its IP address if it has not presented a key. conns maps
+	// each open connection to the key presented over it.
+	renters   map[string]*renterActivity
+	conns     map[net.Conn]string
+	overrides map[string]string
+	lastPrune time.Time
+	mu        sync.Mutex
+}
+
+// connAddress returns the IP address of the remote end of conn.
+func connAddress(conn net.Conn) string {
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return conn.RemoteAddr().String()
+	}
+	return host
+}
+
+// renterKey returns the string that identifies the renter with the provided
+// public key.
+func renterKey(pk crypto.PublicKey) string {
+	spk := types.Ed25519PublicKey(pk)
+	return spk.String()
+}
+
+// activity returns the record of the renter, creating it if necessary. The
+// caller must hold the lock.
+func (at *abuseTracker) activity(renter, address string) *renterActivity {
+	if at.renters == nil {
+		at.renters = make(map[string]*renterActivity)
+	}
+	ra, exists := at.renters[renter]
+	if !exists {
+		ra = new(renterActivity)
+		at.renters[renter] = ra
+	}
+	ra.address = address
+	return ra
+}
+
+// identity returns the string that identifies the renter on the other end of
+// conn. The caller must hold the lock.
+func (at *abuseTracker) identity(conn net.Conn) string {
+	if renter, exists := at.conns[conn]; exists {
+		return renter
+	}
+	return connAddress(conn)
+}
+
+// addressStatus returns the most severe status of the renters that last
+// connected from address. The caller must hold the lock.
+func (at *abuseTracker) addressStatus(address string, now time.Time) string {
+	worst := modules.HostAbuseStatusOK
+	for renter, ra := range at.renters {
+		if ra.address != address && renter != address {
+			continue
+		}
+		status, _ := ra.status(now, at.overrides[renter])
+		if status == modules.HostAbuseStatusBanned {
+			return status
+		} else if status == modules.HostAbuseStatusThrottled {
+			worst = status
+		}
+	}
+	// Overrides apply to addresses even without a record.
+	if at.overrides[address] == modules.HostAbuseOverrideBan {
+		return modules.HostAbuseStatusBanned
+	}
+	return worst
+}
+
+// admit registers a new connection and returns the status that applies to
+// its IP address.
+func (at *abuseTracker) admit(conn net.Conn, now time.Time) string {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	if at.conns == nil {
+		at.conns = make(map[net.Conn]string)
+	}
+	return at.addressStatus(connAddress(conn), now)
+}
+
+// release forgets a connection once its RPC has finished.
+func (at *abuseTracker) release(conn net.Conn) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	delete(at.conns, conn)
+}
+
+// identify records that the renter on the other end of conn presented the
+// provided key. errRenterBanned is returned if the renter is banned.
+func (at *abuseTracker) identify(conn net.Conn, pk crypto.PublicKey, now time.Time) error {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	renter := renterKey(pk)
+	if at.conns != nil {
+		at.conns[conn] = renter
+	}
+	if !modules.FeatureEnabled(featureAbuseDetection) {
+		return nil
+	}
+	override := at.overrides[renter]
+	if ra, exists := at.renters[renter]; exists {
+		ra.address = connAddress(conn)
+		if status, _ := ra.status(now, override); status == modules.HostAbuseStatusBanned {
+			return errRenterBanned
+		}
+	} else if override == modules.HostAbuseOverrideBan {
+		return errRenterBanned
+	}
+	return nil
+}
+
+// record records an abuse event of the provided kind for the renter on the
+// other end of conn, throttling or banning the renter if it has exceeded the
+// threshold for that kind of event. Events from renters that are already
+// banned are not recorded.
+func (at *abuseTracker) record(conn net.Conn, kind int, now time.Time) {
+	if !modules.FeatureEnabled(featureAbuseDetection) {
+		return
+	}
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	at.recordEvent(at.identity(conn), connAddress(conn), kind, now)
+}
+
+// recordEvent records an abuse event for a renter. The caller must hold the
+// lock.
+func (at *abuseTracker) recordEvent(renter, address string, kind int, now time.Time) {
+	if now.Sub(at.lastPrune) > abuseWindow {
+		at.prune(now)
+	}
+	ra := at.activity(renter, address)
+	override := at.overrides[renter]
+	if status, _ := ra.status(now, override); status == modules.HostAbuseStatusBanned {
+		return
+	}
+	ra.prune(now)
+	ra.events[kind] = append(ra.events[kind], now)
+	if override == modules.HostAbuseOverrideAllow {
+		return
+	}
+
+	count, threshold := len(ra.events[kind]), abuseThresholds()[kind]
+	if count <= threshold {
+		return
+	}
+	ra.reason = fmt.Sprintf(abuseDescriptions[kind]+" within %v", count, abuseWindow)
+	if count > 2*threshold {
+		ra.bannedUntil = now.Add(abuseBanDuration)
+	} else if !now.Before(ra.throttledUntil) {
+		ra.throttledUntil = now.Add(abuseThrottleDuration)
+	}
+}
+
+// prune forgets the records of renters that are idle and have no override.
+// The caller must hold the lock.
+func (at *abuseTracker) prune(now time.Time) {
+	for renter, ra := range at.renters {
+		ra.prune(now)
+		if _, exists := at.overrides[renter]; !exists && ra.idle(now) {
+			delete(at.renters, renter)
+		}
+	}
+	at.lastPrune = now
+}
+
+// records returns the records of renters that have recent activity or an
+// override.
+func (at *abuseTracker) records(now time.Time) []modules.HostRenterAbuse {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	at.prune(now)
+	for renter := range at.overrides {
+		if _, exists := at.renters[renter]; !exists {
+			at.activity(renter, "")
+		}
+	}
+
+	records := make([]modules.HostRenterAbuse, 0, len(at.renters))
+	for renter, ra := range at.renters {
+		override := at.overrides[renter]
+		status, until := ra.status(now, override)
+		r := modules.HostRenterAbuse{
+			Renter:   renter,
+			Address:  ra.address,
+			Status:   status,
+			Until:    until,
+			Override: override,
+
+			RecentContracts:          len(ra.events[abuseContract]),
+			RecentFailedNegotiations: len(ra.events[abuseFailedNegotiation]),
+			RecentUnderpaidDownloads: len(ra.events[abuseUnderpaidDownload]),
+		}
+		if status != modules.HostAbuseStatusOK && override == "" {
+			r.Reason = ra.reason
+		}
+		records = append(records, r)
+	}
+	sort.Sort(abuseRecordsByRenter(records))
+	return records
+}
+
+// setOverride sets the override for a renter. An empty override removes the
+// existing override and forgets the renter's record.
+func (at *abuseTracker) setOverride(renter, override string) error {
+	if override != "" && override != modules.HostAbuseOverrideAllow && override != modules.HostAbuseOverrideBan {
+		return errBadAbuseOverride
+	}
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	if override == "" {
+		delete(at.overrides, renter)
+		delete(at.renters, renter)
+		return nil
+	}
+	if at.overrides == nil {
+		at.overrides = make(map[string]string)
+	}
+	at.overrides[renter] = override
+	return nil
+}
+
+// persistOverrides returns a copy of the overrides for persisting.
+func (at *abuseTracker) persistOverrides() map[string]string {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	if len(at.overrides) == 0 {
+		return nil
+	}
+	overrides := make(map[string]string, len(at.overrides))
+	for renter, override := range at.overrides {
+		overrides[renter] = override
+	}
+	return overrides
+}
+
+// abuseRecordsByRenter sorts abuse records by renter.
+type abuseRecordsByRenter []modules.HostRenterAbuse
+
+func (rs abuseRecordsByRenter) Len() int           { return len(rs) }
+func (rs abuseRecordsByRenter) Less(i, j int) bool { return rs[i].Renter < rs[j].Renter }
+func (rs abuseRecordsByRenter) Swap(i, j int)      { rs[i], rs[j] = rs[j], rs[i] }
+
+// managedAdmitRenter registers a connection with the abuse tracker, delaying
+// the connection if the renter is throttled. false is returned if the renter
+// is banned, in which case the connection should be closed.
+func (h *Host) managedAdmitRenter(conn net.Conn) bool {
+	status := h.abuse.admit(conn, time.Now())
+	if !modules.FeatureEnabled(featureAbuseDetection) {
+		return true
+	}
+	switch status {
+	case modules.HostAbuseStatusBanned:
+		return false
+	case modules.HostAbuseStatusThrottled:
+		select {
+		case <-time.After(abuseThrottleDelay):
+		case <-h.tg.StopChan():
+			return false
+		}
+	}
+	return true
+}
+
+// RenterAbuse returns the records kept by the host's abuse detection for
+// renters that have recently misbehaved or have an override.
+func (h *Host) RenterAbuse() []modules.HostRenterAbuse {
+	return h.abuse.records(time.Now())
+}
+
+// SetRenterAbuseOverride sets the override for a renter, identified by its
+// public key or IP address. Renters that are allowed are never throttled or
+// banned, and renters that are banned stay banned until the override is
+// removed. An empty override removes any existing override and clears the
+// renter's record. Overrides are persisted.
+func (h *Host) SetRenterAbuseOverride(renter string, override string) error {
+	if err := h.tg.Add(); err != nil {
+		return err
+	}
+	defer h.tg.Done()
+	if renter == "" {
+		return errors.New("no renter specified")
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := h.abuse.setOverride(renter, override); err != nil {
+		return err
+	}
+	return h.saveSync()
+}
+
+// managedRecordAbuse records the outcome of an RPC with the abuse tracker.
+// Successful contract formations and renewals count towards the renter's
+// contract churn, and failed negotiations count against the renter unless the
+// failure was caused by the network or by the host itself.
+func (h *Host) managedRecordAbuse(conn net.Conn, id types.Specifier, err error) {
+	switch id {
+	case modules.RPCFormContract, modules.RPCRenewContract, modules.RPCReviseContract:
+	default:
+		return
+	}
+	switch err.(type) {
+	case nil:
+		if id != modules.RPCReviseContract {
+			h.abuse.record(conn, abuseContract, time.Now())
+		}
+	case ErrorConnection, ErrorConsensus, ErrorInternal:
+	default:
+		h.abuse.record(conn, abuseFailedNegotiation, time.Now())
+	}
+}
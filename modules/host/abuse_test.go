@@ -0,0 +1,175 @@
+package host
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// abuseTestConn is a net.Conn with a configurable remote address.
+type abuseTestConn struct {
+	net.Conn
+	addr string
+}
+
+func (c abuseTestConn) RemoteAddr() net.Addr {
+	addr, _ := net.ResolveTCPAddr("tcp", c.addr)
+	return addr
+}
+
+// TestAbuseTrackerEscalation checks that renters are throttled and then
+// banned as their misbehavior grows, and that throttles and bans expire.
+func TestAbuseTrackerEscalation(t *testing.T) {
+	var at abuseTracker
+	conn := &abuseTestConn{addr: "203.0.113.7:1234"}
+	now := time.Now()
+
+	if status := at.admit(conn, now); status != modules.HostAbuseStatusOK {
+		t.Fatal("new renter should be admitted, got", status)
+	}
+	_, pk := crypto.GenerateKeyPair()
+	if err := at.identify(conn, pk, now); err != nil {
+		t.Fatal(err)
+	}
+
+	// Failing up to the threshold is tolerated.
+	for i := 0; i < abuseMaxFailedNegotiations; i++ {
+		at.record(conn, abuseFailedNegotiation, now)
+	}
+	if status := at.admit(conn, now); status != modules.HostAbuseStatusOK {
+		t.Fatal("renter at the threshold should not be throttled, got", status)
+	}
+
+	// Failing once more throttles the renter, by key and by address.
+	at.record(conn, abuseFailedNegotiation, now)
+	if status := at.admit(conn, now); status != modules.HostAbuseStatusThrottled {
+		t.Fatal("renter over the threshold should be throttled, got", status)
+	}
+	records := at.records(now)
+	if len(records) != 1 || records[0].Renter != renterKey(pk) || records[0].Status != modules.HostAbuseStatusThrottled || records[0].Reason == "" {
+		t.Fatal("unexpected records:", records)
+	}
+	if status := at.admit(conn, now.Add(abuseThrottleDuration)); status != modules.HostAbuseStatusOK {
+		t.Fatal("throttle should have expired, got", status)
+	}
+
+	// Failing more than twice the threshold bans the renter.
+	for i := 0; i < abuseMaxFailedNegotiations; i++ {
+		at.record(conn, abuseFailedNegotiation, now)
+	}
+	if status := at.admit(conn, now); status != modules.HostAbuseStatusBanned {
+		t.Fatal("renter should be banned, got", status)
+	}
+	if err := at.identify(conn, pk, now); err != errRenterBanned {
+		t.Fatal("expected errRenterBanned, got", err)
+	}
+
+	// The ban applies to the renter's key from a different address.
+	other := &abuseTestConn{addr: "198.51.100.1:1234"}
+	if status := at.admit(other, now); status != modules.HostAbuseStatusOK {
+		t.Fatal("other address should be admitted, got", status)
+	}
+	if err := at.identify(other, pk, now); err != errRenterBanned {
+		t.Fatal("expected errRenterBanned, got", err)
+	}
+
+	// Once the ban and the events expire, the record is forgotten.
+	later := now.Add(abuseBanDuration + abuseWindow + time.Second)
+	if err := at.identify(conn, pk, later); err != nil {
+		t.Fatal("ban should have expired, got", err)
+	}
+	if records := at.records(later); len(records) != 0 {
+		t.Fatal("expected no records, got", records)
+	}
+}
+
+// TestAbuseTrackerOverrides checks that overrides take precedence over the
+// abuse detection.
+func TestAbuseTrackerOverrides(t *testing.T) {
+	var at abuseTracker
+	conn := &abuseTestConn{addr: "203.0.113.7:1234"}
+	now := time.Now()
+	_, pk := crypto.GenerateKeyPair()
+	renter := renterKey(pk)
+
+	if err := at.setOverride(renter, "foo"); err != errBadAbuseOverride {
+		t.Fatal("expected errBadAbuseOverride, got", err)
+	}
+
+	// Allowed renters are never throttled.
+	if err := at.setOverride(renter, modules.HostAbuseOverrideAllow); err != nil {
+		t.Fatal(err)
+	}
+	at.admit(conn, now)
+	at.identify(conn, pk, now)
+	for i := 0; i <= 2*abuseMaxContracts; i++ {
+		at.record(conn, abuseContract, now)
+	}
+	if status := at.admit(conn, now); status != modules.HostAbuseStatusOK {
+		t.Fatal("allowed renter should not be throttled, got", status)
+	}
+
+	// Banned renters are refused indefinitely, even without misbehaving.
+	if err := at.setOverride(renter, modules.HostAbuseOverrideBan); err != nil {
+		t.Fatal(err)
+	}
+	if err := at.identify(conn, pk, now.Add(100*abuseBanDuration)); err != errRenterBanned {
+		t.Fatal("expected errRenterBanned, got", err)
+	}
+
+	// Overrides can be set on addresses.
+	if err := at.setOverride("198.51.100.1", modules.HostAbuseOverrideBan); err != nil {
+		t.Fatal(err)
+	}
+	if status := at.admit(&abuseTestConn{addr: "198.51.100.1:1234"}, now); status != modules.HostAbuseStatusBanned {
+		t.Fatal("banned address should be refused, got", status)
+	}
+
+	// Clearing an override forgets the renter.
+	if err := at.setOverride(renter, ""); err != nil {
+		t.Fatal(err)
+	}
+	records := at.records(now)
+	if len(records) != 1 || records[0].Renter != "198.51.100.1" || records[0].Override != modules.HostAbuseOverrideBan {
+		t.Fatal("unexpected records:", records)
+	}
+}
+
+// TestAbuseOverridePersistence checks that abuse overrides persist across
+// restarts of the host.
+func TestAbuseOverridePersistence(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	if err := ht.host.SetRenterAbuseOverride("203.0.113.7", modules.HostAbuseOverrideBan); err != nil {
+		t.Fatal(err)
+	}
+	if err := ht.host.SetRenterAbuseOverride("", modules.HostAbuseOverrideBan); err == nil {
+		t.Fatal("expected an error when no renter is specified")
+	}
+
+	// Reboot the host.
+	err = ht.host.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ht.host, err = New(ht.cs, ht.tpool, ht.wallet, "localhost:0", filepath.Join(ht.persistDir, modules.HostDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := ht.host.RenterAbuse()
+	if len(records) != 1 || records[0].Renter != "203.0.113.7" || records[0].Status != modules.HostAbuseStatusBanned {
+		t.Fatal("abuse override did not persist:", records)
+	}
+}
@@ -0,0 +1,222 @@
+package host
+
+import (
+	"errors"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// Host operators can restrict which contracts the host accepts beyond the
+// prices and collateral of the host settings. The acceptance filters bound
+// the duration and size of contracts, the renters that contracts are formed
+// with, and the number of contracts that a single renter may hold. They are
+// applied when contracts are formed and renewed. Rejections due to the
+// filters are the host's own choice, and are not counted against the renter
+// by the abuse detection.
+//
+// The storage quota bounds the data that a single renter stores under its
+// active contracts, so that one renter cannot fill a host that is meant to
+// serve many. It is enforced when data is uploaded, as contracts only grow
+// through revisions. The data of a renewed contract is carried over into the
+// renewal, and is only counted once while both contracts are active.
+
+var (
+	// errBadRenterFilter is returned if the renter whitelist or blacklist
+	// contains an entry that is not a renter public key.
+	errBadRenterFilter = errors.New("renter whitelist and blacklist entries must be public keys such as ed25519:<hex>")
+
+	// errContractTooLarge is returned if a contract holds more data than the
+	// host accepts in a single contract.
+	errContractTooLarge = ErrorInternal("contract holds more data than the host accepts in a single contract")
+
+	// errContractTooSmall is returned if a renewed contract holds less data
+	// than the host requires to renew a contract.
+	errContractTooSmall = ErrorInternal("contract holds less data than the host requires to renew it")
+
+	// errRenterStorageQuota is returned if an upload would take the data
+	// stored by the renter past the host's storage quota per renter.
+	errRenterStorageQuota = ErrorInternal("renter has reached the host's limit of storage per renter")
+
+	// errRenterNotAccepted is returned if the renter is on the host's
+	// blacklist, or is missing from the host's whitelist.
+	errRenterNotAccepted = ErrorInternal("host does not accept contracts from this renter")
+
+	// errShortDuration is returned if the renter proposes a file contract with
+	// a proof window that starts sooner than the host accepts.
+	errShortDuration = ErrorInternal("renter proposed a file contract with a too-short duration")
+
+	// errTooManyRenterContracts is returned if the renter already holds as
+	// many contracts with the host as the host allows.
+	errTooManyRenterContracts = ErrorInternal("renter has reached the host's limit of contracts per renter")
+)
+
+// renter returns the public key of the renter of the storage obligation, as
+// found in the unlock conditions of the contract revision.
+func (so storageObligation) renter() string {
+	if len(so.RevisionTransactionSet) == 0 {
+		return ""
+	}
+	txn := so.RevisionTransactionSet[len(so.RevisionTransactionSet)-1]
+	if len(txn.FileContractRevisions) == 0 || len(txn.FileContractRevisions[0].UnlockConditions.PublicKeys) == 0 {
+		return ""
+	}
+	return txn.FileContractRevisions[0].UnlockConditions.PublicKeys[0].String()
+}
+
+// countedStorage returns the data of the storage obligation that counts
+// towards the storage quota of its renter. Data that has been carried over
+// into a renewal is counted with the renewal instead.
+func (so storageObligation) countedStorage() uint64 {
+	size := uint64(len(so.SectorRoots)) * modules.SectorSize
+	if size < so.RenewedDataSize {
+		return 0
+	}
+	return size - so.RenewedDataSize
+}
+
+// countRenterContract adds delta to the number of active contracts of the
+// renter of the storage obligation, and adds or removes the data of the
+// obligation from the renter's storage.
+func (h *Host) countRenterContract(so storageObligation, delta int) {
+	renter := so.renter()
+	if renter == "" {
+		return
+	}
+	if h.renterContracts == nil {
+		h.renterContracts = make(map[string]int)
+	}
+	h.renterContracts[renter] += delta
+	if h.renterContracts[renter] <= 0 {
+		delete(h.renterContracts, renter)
+	}
+	h.countRenterStorage(so, delta)
+}
+
+// countRenterStorage adds the data of the storage obligation to the storage
+// of its renter if delta is positive, and removes it otherwise.
+func (h *Host) countRenterStorage(so storageObligation, delta int) {
+	renter := so.renter()
+	size := so.countedStorage()
+	if renter == "" || size == 0 {
+		return
+	}
+	if h.renterStorage == nil {
+		h.renterStorage = make(map[string]uint64)
+	}
+	if delta > 0 {
+		h.renterStorage[renter] += size
+	} else if h.renterStorage[renter] > size {
+		h.renterStorage[renter] -= size
+	} else {
+		delete(h.renterStorage, renter)
+	}
+}
+
+// markRenewed records that the data of the storage obligation with the
+// provided id has been carried over into a renewal, so that the data is only
+// counted towards the renter's storage once. The caller must hold the host
+// lock and the lock of the obligation.
+func (h *Host) markRenewed(soid types.FileContractID) error {
+	return h.db.Update(func(tx *bolt.Tx) error {
+		so, err := getStorageObligation(tx, soid)
+		if err != nil {
+			return err
+		}
+		if so.ObligationStatus != obligationUnresolved {
+			return nil
+		}
+		h.countRenterStorage(so, -1)
+		so.RenewedDataSize = uint64(len(so.SectorRoots)) * modules.SectorSize
+		h.countRenterStorage(so, 1)
+		return putStorageObligation(tx, so)
+	})
+}
+
+// checkRenterStorage checks that a revision that changes the size of a
+// contract from oldSize to newSize keeps the renter within the storage quota
+// of the host. 'stored' is the data that the renter already stores with the
+// host, including the contract being revised. Contracts may always shrink.
+func checkRenterStorage(settings modules.HostInternalSettings, stored, oldSize, newSize uint64) error {
+	if settings.MaxStoragePerRenter == 0 || newSize <= oldSize {
+		return nil
+	}
+	if stored+newSize-oldSize > settings.MaxStoragePerRenter {
+		return errRenterStorageQuota
+	}
+	return nil
+}
+
+// checkRenterFilters checks that every entry of the renter whitelist and
+// blacklist is a renter public key.
+func checkRenterFilters(settings modules.HostInternalSettings) error {
+	for _, list := range [][]string{settings.RenterWhitelist, settings.RenterBlacklist} {
+		for _, renter := range list {
+			var spk types.SiaPublicKey
+			spk.LoadString(renter)
+			if spk.Algorithm != types.SignatureEd25519 || len(spk.Key) != crypto.PublicKeySize {
+				return errBadRenterFilter
+			}
+		}
+	}
+	return nil
+}
+
+// listed returns whether the renter is in the list.
+func listed(list []string, renter string) bool {
+	for _, entry := range list {
+		if entry == renter {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAcceptanceFilters checks a new or renewed file contract against the
+// acceptance filters of the host settings. 'contracts' is the number of
+// other active contracts that the renter holds with the host.
+func checkAcceptanceFilters(settings modules.HostInternalSettings, renter string, contracts int, blockHeight types.BlockHeight, fc types.FileContract, renewal bool) error {
+	if listed(settings.RenterBlacklist, renter) {
+		return errRenterNotAccepted
+	}
+	if len(settings.RenterWhitelist) != 0 && !listed(settings.RenterWhitelist, renter) {
+		return errRenterNotAccepted
+	}
+	if settings.MaxContractsPerRenter != 0 && uint64(contracts) >= settings.MaxContractsPerRenter {
+		return errTooManyRenterContracts
+	}
+	if fc.WindowStart < blockHeight+settings.MinContractDuration {
+		return errShortDuration
+	}
+	if fc.WindowStart > blockHeight+settings.MaxDuration {
+		return errLongDuration
+	}
+	if settings.MaxContractSize != 0 && fc.FileSize > settings.MaxContractSize {
+		return errContractTooLarge
+	}
+	// New contracts are always empty, so the minimum size only applies to
+	// renewals.
+	if renewal && fc.FileSize < settings.MinContractSize {
+		return errContractTooSmall
+	}
+	return nil
+}
+
+// managedCheckAcceptanceFilters checks a file contract proposed by the renter
+// against the acceptance filters of the host. When a contract is renewed,
+// the contract being renewed does not count towards the renter's contracts.
+func (h *Host) managedCheckAcceptanceFilters(renterPK crypto.PublicKey, fc types.FileContract, renewal bool) error {
+	renter := renterKey(renterPK)
+	h.mu.RLock()
+	settings := h.settings
+	blockHeight := h.blockHeight
+	contracts := h.renterContracts[renter]
+	h.mu.RUnlock()
+	if renewal && contracts > 0 {
+		contracts--
+	}
+	return checkAcceptanceFilters(settings, renter, contracts, blockHeight, fc, renewal)
+}
@@ -0,0 +1,173 @@
+package host
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestCheckAcceptanceFilters checks that contracts are rejected by each of
+// the acceptance filters.
+func TestCheckAcceptanceFilters(t *testing.T) {
+	_, pk := crypto.GenerateKeyPair()
+	renter := renterKey(pk)
+	_, otherPK := crypto.GenerateKeyPair()
+	other := renterKey(otherPK)
+
+	settings := modules.HostInternalSettings{
+		MaxDuration:           1000,
+		MinContractDuration:   100,
+		MinContractSize:       modules.SectorSize,
+		MaxContractSize:       10 * modules.SectorSize,
+		MaxContractsPerRenter: 2,
+	}
+	fc := types.FileContract{WindowStart: 500}
+	if err := checkAcceptanceFilters(settings, renter, 1, 0, fc, false); err != nil {
+		t.Fatal("acceptable contract was rejected:", err)
+	}
+
+	tests := []struct {
+		settings  modules.HostInternalSettings
+		contracts int
+		fc        types.FileContract
+		renewal   bool
+		err       error
+	}{
+		{settings, 2, fc, false, errTooManyRenterContracts},
+		{settings, 1, types.FileContract{WindowStart: 50}, false, errShortDuration},
+		{settings, 1, types.FileContract{WindowStart: 1500}, false, errLongDuration},
+		{settings, 1, types.FileContract{WindowStart: 500, FileSize: 11 * modules.SectorSize}, true, errContractTooLarge},
+		{settings, 1, fc, true, errContractTooSmall},
+	}
+	for i, test := range tests {
+		if err := checkAcceptanceFilters(test.settings, renter, test.contracts, 0, test.fc, test.renewal); err != test.err {
+			t.Errorf("test %v: expected %v, got %v", i, test.err, err)
+		}
+	}
+
+	// Renters on the blacklist, and renters missing from a non-empty
+	// whitelist, are rejected.
+	listSettings := settings
+	listSettings.RenterBlacklist = []string{renter}
+	if err := checkAcceptanceFilters(listSettings, renter, 0, 0, fc, false); err != errRenterNotAccepted {
+		t.Error("blacklisted renter was accepted:", err)
+	}
+	listSettings.RenterBlacklist = nil
+	listSettings.RenterWhitelist = []string{other}
+	if err := checkAcceptanceFilters(listSettings, renter, 0, 0, fc, false); err != errRenterNotAccepted {
+		t.Error("renter missing from the whitelist was accepted:", err)
+	}
+	if err := checkAcceptanceFilters(listSettings, other, 0, 0, fc, false); err != nil {
+		t.Error("whitelisted renter was rejected:", err)
+	}
+
+	// Malformed renter keys are not accepted as settings.
+	if err := checkRenterFilters(listSettings); err != nil {
+		t.Error("valid renter filters were rejected:", err)
+	}
+	listSettings.RenterBlacklist = []string{"203.0.113.7"}
+	if err := checkRenterFilters(listSettings); err != errBadRenterFilter {
+		t.Error("expected errBadRenterFilter, got", err)
+	}
+}
+
+// TestCountRenterContract checks that the active contracts of each renter are
+// counted using the renter key in the contract revision.
+func TestCountRenterContract(t *testing.T) {
+	_, pk := crypto.GenerateKeyPair()
+	so := storageObligation{
+		RevisionTransactionSet: []types.Transaction{{
+			FileContractRevisions: []types.FileContractRevision{{
+				UnlockConditions: types.UnlockConditions{
+					PublicKeys: []types.SiaPublicKey{types.Ed25519PublicKey(pk)},
+				},
+			}},
+		}},
+	}
+	if so.renter() != renterKey(pk) {
+		t.Fatal("wrong renter for storage obligation:", so.renter())
+	}
+
+	h := new(Host)
+	h.countRenterContract(so, 1)
+	h.countRenterContract(so, 1)
+	if h.renterContracts[renterKey(pk)] != 2 {
+		t.Fatal("contracts were not counted:", h.renterContracts)
+	}
+	h.countRenterContract(so, -1)
+	h.countRenterContract(so, -1)
+	if len(h.renterContracts) != 0 {
+		t.Fatal("renters without contracts were not dropped:", h.renterContracts)
+	}
+
+	// Obligations without a revision are not counted.
+	h.countRenterContract(storageObligation{}, 1)
+	if len(h.renterContracts) != 0 {
+		t.Fatal("obligation without a renter was counted")
+	}
+}
+
+// TestRenterStorage checks that the data of each renter is counted once,
+// including the data of renewed contracts, and that uploads are checked
+// against the storage quota.
+func TestRenterStorage(t *testing.T) {
+	_, pk := crypto.GenerateKeyPair()
+	renter := types.Ed25519PublicKey(pk)
+	obligation := func(sectors int) storageObligation {
+		return storageObligation{
+			SectorRoots: make([]crypto.Hash, sectors),
+			RevisionTransactionSet: []types.Transaction{{
+				FileContractRevisions: []types.FileContractRevision{{
+					UnlockConditions: types.UnlockConditions{
+						PublicKeys: []types.SiaPublicKey{renter, {}},
+					},
+				}},
+			}},
+		}
+	}
+
+	// A renewal carries over the data of the renewed contract, after which
+	// only the data uploaded to the old contract is counted with it.
+	h := new(Host)
+	old, renewal := obligation(3), obligation(3)
+	h.countRenterContract(old, 1)
+	h.countRenterContract(renewal, 1)
+	h.countRenterStorage(old, -1)
+	old.RenewedDataSize = 3 * modules.SectorSize
+	h.countRenterStorage(old, 1)
+	if stored := h.renterStorage[renter.String()]; stored != 3*modules.SectorSize {
+		t.Fatal("renewed data was counted twice:", stored)
+	}
+	h.countRenterStorage(old, -1)
+	old.SectorRoots = append(old.SectorRoots, crypto.Hash{})
+	h.countRenterStorage(old, 1)
+	if stored := h.renterStorage[renter.String()]; stored != 4*modules.SectorSize {
+		t.Fatal("growth of the renewed contract was not counted:", stored)
+	}
+	h.countRenterContract(old, -1)
+	h.countRenterContract(renewal, -1)
+	if len(h.renterStorage) != 0 || len(h.renterContracts) != 0 {
+		t.Fatal("renter was not forgotten:", h.renterStorage, h.renterContracts)
+	}
+
+	settings := modules.HostInternalSettings{MaxStoragePerRenter: 10 * modules.SectorSize}
+	tests := []struct {
+		stored, oldSize, newSize uint64
+		err                      error
+	}{
+		{0, 0, 10 * modules.SectorSize, nil},
+		{9 * modules.SectorSize, 0, modules.SectorSize, nil},
+		{9 * modules.SectorSize, modules.SectorSize, 3 * modules.SectorSize, errRenterStorageQuota},
+		{12 * modules.SectorSize, 4 * modules.SectorSize, 2 * modules.SectorSize, nil},
+	}
+	for i, test := range tests {
+		if err := checkRenterStorage(settings, test.stored, test.oldSize, test.newSize); err != test.err {
+			t.Errorf("test %v: expected %v, got %v", i, test.err, err)
+		}
+	}
+	if err := checkRenterStorage(modules.HostInternalSettings{}, 1<<50, 0, 1<<50); err != nil {
+		t.Error("upload was rejected without a quota:", err)
+	}
+}
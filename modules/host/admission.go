@@ -0,0 +1,103 @@
+package host
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// errHostBusy is sent to renters whose RPC is rejected because the host is
+// already handling the maximum number of concurrent RPCs of that type.
+var errHostBusy = errors.New("host is busy, try again later")
+
+// An rpcLimiter bounds the number of RPCs of a single type that the host
+// handles concurrently. RPCs that arrive while the limit is reached wait in a
+// queue, which may hold as many RPCs as the limit itself. RPCs that arrive to
+// a full queue, or that wait longer than rpcQueueTimeout, are rejected.
+type rpcLimiter struct {
+	active uint64
+	queued uint64
+
+	// freed is closed and replaced each time an active RPC finishes, waking
+	// all queued RPCs so that they can compete for the free slot.
+	freed chan struct{}
+	mu    sync.Mutex
+}
+
+// acquire reserves a slot for an RPC under the provided limit, waiting in the
+// queue if necessary. A limit of zero means that the number of concurrent
+// RPCs is unbounded. false is returned if the RPC should be rejected, in which
+// case release must not be called.
+func (rl *rpcLimiter) acquire(limit uint64, timeout time.Duration, stop <-chan struct{}) bool {
+	rl.mu.Lock()
+	if limit == 0 || rl.active < limit {
+		rl.active++
+		rl.mu.Unlock()
+		return true
+	}
+	if rl.queued >= limit {
+		rl.mu.Unlock()
+		return false
+	}
+	rl.queued++
+	if rl.freed == nil {
+		rl.freed = make(chan struct{})
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for rl.active >= limit {
+		freed := rl.freed
+		rl.mu.Unlock()
+		select {
+		case <-freed:
+		case <-timer.C:
+			rl.mu.Lock()
+			rl.queued--
+			rl.mu.Unlock()
+			return false
+		case <-stop:
+			rl.mu.Lock()
+			rl.queued--
+			rl.mu.Unlock()
+			return false
+		}
+		rl.mu.Lock()
+	}
+	rl.queued--
+	rl.active++
+	rl.mu.Unlock()
+	return true
+}
+
+// release frees the slot held by an RPC that was admitted by acquire.
+func (rl *rpcLimiter) release() {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.active--
+	if rl.freed != nil {
+		close(rl.freed)
+		rl.freed = make(chan struct{})
+	}
+}
+
+// managedRPCLimiter returns the limiter and concurrency limit that apply to the RPC
+// with the provided specifier. A nil limiter is returned for RPCs that are
+// cheap enough to always be admitted.
+func (h *Host) managedRPCLimiter(id types.Specifier) (*rpcLimiter, uint64) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	switch id {
+	case modules.RPCDownload:
+		return &h.downloadLimiter, h.settings.MaxConcurrentDownloads
+	case modules.RPCFormContract, modules.RPCRenewContract:
+		return &h.negotiationLimiter, h.settings.MaxConcurrentNegotiations
+	case modules.RPCReviseContract, modules.RPCHandoff, modules.RPCReceiveHandoff:
+		return &h.revisionLimiter, h.settings.MaxConcurrentRevisions
+	default:
+		return nil, 0
+	}
+}
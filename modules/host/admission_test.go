@@ -0,0 +1,97 @@
+package host
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// TestRPCLimiter checks that the rpcLimiter admits RPCs up to its limit,
+// queues a bounded number of extra RPCs, and rejects RPCs that arrive to a
+// full queue or that wait too long.
+func TestRPCLimiter(t *testing.T) {
+	var rl rpcLimiter
+	stop := make(chan struct{})
+
+	// A limit of zero should never block.
+	for i := 0; i < 5; i++ {
+		if !rl.acquire(0, time.Millisecond, stop) {
+			t.Fatal("unlimited limiter rejected an RPC")
+		}
+	}
+	for i := 0; i < 5; i++ {
+		rl.release()
+	}
+
+	// Fill both slots of a limiter with a limit of 2.
+	if !rl.acquire(2, time.Millisecond, stop) || !rl.acquire(2, time.Millisecond, stop) {
+		t.Fatal("limiter rejected an RPC below the limit")
+	}
+	// A queued RPC should time out if no slot frees up.
+	if rl.acquire(2, 10*time.Millisecond, stop) {
+		t.Fatal("limiter admitted an RPC above the limit")
+	}
+
+	// Queue two RPCs, which fills the queue. A third should be rejected
+	// immediately.
+	admitted := make(chan bool, 2)
+	for i := 0; i < 2; i++ {
+		go func() { admitted <- rl.acquire(2, 10*time.Second, stop) }()
+	}
+	for {
+		rl.mu.Lock()
+		queued := rl.queued
+		rl.mu.Unlock()
+		if queued == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	start := time.Now()
+	if rl.acquire(2, 10*time.Second, stop) {
+		t.Fatal("limiter admitted an RPC to a full queue")
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("RPC arriving to a full queue was not rejected immediately")
+	}
+
+	// Releasing a slot should admit exactly one of the queued RPCs.
+	rl.release()
+	if !<-admitted {
+		t.Fatal("queued RPC was rejected after a slot was freed")
+	}
+	select {
+	case <-admitted:
+		t.Fatal("second queued RPC was admitted without a free slot")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Stopping should reject the remaining queued RPC.
+	close(stop)
+	if <-admitted {
+		t.Fatal("queued RPC was admitted after stop")
+	}
+}
+
+// TestNewPersistenceConcurrencyLimits checks that settings saved without the
+// concurrency limits load with the default limits, while limits that were
+// explicitly disabled stay disabled.
+func TestNewPersistenceConcurrencyLimits(t *testing.T) {
+	p := newPersistence()
+	if err := json.Unmarshal([]byte(`{"settings":{"acceptingcontracts":true}}`), p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Settings.MaxConcurrentDownloads != defaultMaxConcurrentDownloads ||
+		p.Settings.MaxConcurrentNegotiations != defaultMaxConcurrentNegotiations ||
+		p.Settings.MaxConcurrentRevisions != defaultMaxConcurrentRevisions {
+		t.Fatal("old settings did not load with the default limits:", p.Settings)
+	}
+
+	p = newPersistence()
+	if err := json.Unmarshal([]byte(`{"settings":{"maxconcurrentdownloads":0,"maxconcurrentnegotiations":0,"maxconcurrentrevisions":0}}`), p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Settings.MaxConcurrentDownloads != 0 || p.Settings.MaxConcurrentNegotiations != 0 || p.Settings.MaxConcurrentRevisions != 0 {
+		t.Fatal("disabled limits were overwritten with the defaults:", p.Settings)
+	}
+}
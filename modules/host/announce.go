@@ -2,10 +2,34 @@ package host
 
 import (
 	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
 
+	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/modules"
 )
 
+// Hosts can announce an IPv4 address, an IPv6 address, or a DNS name. The
+// host keeps track of the address that it last announced, and periodically
+// checks it against the address it would announce now, which changes when the
+// external IP of a host without a manually set address changes, or when the
+// host is handed a new address by its relay. If the two differ, and the host
+// is accepting contracts or has contracts, the host announces the new address
+// on its own. These automatic announcements are at least autoAnnounceCooldown
+// apart. A host that announces a DNS name keeps its announcement across IP
+// changes, so the host instead warns when the name stops resolving to the
+// host's external IP.
+
+// announcementState tracks the address that the host last announced. It is
+// guarded by the host lock.
+type announcementState struct {
+	address modules.NetAddress
+	last    time.Time
+	warning string
+}
+
 var (
 	// errAnnWalletLocked is returned during a host announcement if the wallet
 	// is locked.
@@ -67,11 +91,116 @@ func (h *Host) managedAnnounce(addr modules.NetAddress) error {
 
 	h.mu.Lock()
 	h.announced = true
+	h.announcement.address = addr
+	h.announcement.last = time.Now()
+	err = h.save()
 	h.mu.Unlock()
+	if err != nil {
+		h.log.Println("WARN: could not save the announced address:", err)
+	}
 	h.log.Printf("INFO: Successfully announced as %v", addr)
 	return nil
 }
 
+// normalizeAnnounceAddress turns an address provided by the host operator into
+// an address that can be announced. The host's own port is added to addresses
+// without a port, and IPv6 addresses without a port may be given without
+// brackets.
+func normalizeAnnounceAddress(addr string, port string) (modules.NetAddress, error) {
+	addr = strings.TrimSpace(addr)
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		host := strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+		addr = net.JoinHostPort(host, port)
+	}
+	na := modules.NetAddress(addr)
+	if err := na.IsValid(); err != nil {
+		return "", fmt.Errorf("cannot announce %v: %v", na, err)
+	}
+	return na, nil
+}
+
+// reannounceDue returns whether the host should announce the current address
+// on its own, given the address that it last announced and when.
+func reannounceDue(announced bool, previous, current modules.NetAddress, last, now time.Time) bool {
+	if current == "" || (announced && current == previous) {
+		return false
+	}
+	return now.Sub(last) >= autoAnnounceCooldown
+}
+
+// resolvesTo returns whether any of the resolved addresses of a DNS name
+// equals the ip. Resolved addresses of the other IP version are ignored, and
+// if there are none of the same version the name is assumed to be correct.
+func resolvesTo(resolved []string, ip string) bool {
+	target := net.ParseIP(ip)
+	if target == nil {
+		return true
+	}
+	sameVersion := false
+	for _, r := range resolved {
+		rip := net.ParseIP(r)
+		if rip == nil || (rip.To4() == nil) != (target.To4() == nil) {
+			continue
+		}
+		sameVersion = true
+		if rip.Equal(target) {
+			return true
+		}
+	}
+	return !sameVersion
+}
+
+// managedCheckAnnouncement announces the host's current address if it differs
+// from the address that the host last announced, and warns if the host has
+// announced a DNS name that does not resolve to the host's external IP.
+func (h *Host) managedCheckAnnouncement() {
+	if build.Release == "testing" {
+		return
+	}
+
+	h.mu.RLock()
+	announced := h.announced
+	previous := h.announcement.address
+	last := h.announcement.last
+	current := h.netAddress()
+	externalIP := h.autoAddress.Host()
+	active := h.settings.AcceptingContracts || h.financialMetrics.ContractCount > 0
+	h.mu.RUnlock()
+
+	// Announce the host, but only if the host is either accepting contracts or
+	// has a storage obligation. If the host is not accepting contracts and has
+	// no open contracts, there is no reason to notify anyone that the host's
+	// address has changed.
+	if active && reannounceDue(announced, previous, current, last, time.Now()) {
+		h.log.Println("Host address changed from", previous, "to", current, "- performing host announcement.")
+		err := h.managedAnnounce(current)
+		if err != nil {
+			h.log.Println("WARN: unable to announce address after address change:", err)
+		}
+	} else if active && announced && current != "" && current != previous {
+		h.log.Println("Host address changed from", previous, "to", current, "- announcement delayed by the announcement cooldown.")
+	}
+
+	// Check that an announced DNS name still points at the host.
+	var warning string
+	name := current.Host()
+	if externalIP != "" && name != "" && net.ParseIP(name) == nil && !current.IsLoopback() {
+		resolved, err := net.LookupHost(name)
+		if err != nil {
+			warning = fmt.Sprintf("could not resolve %v: %v", name, err)
+		} else if !resolvesTo(resolved, externalIP) {
+			warning = fmt.Sprintf("%v does not resolve to the external IP of the host, %v", name, externalIP)
+		}
+	}
+	h.mu.Lock()
+	changed := warning != h.announcement.warning
+	h.announcement.warning = warning
+	h.mu.Unlock()
+	if changed && warning != "" {
+		h.log.Println("WARN:", warning)
+	}
+}
+
 // Announce creates a host announcement transaction, adding information to the
 // arbitrary data, signing the transaction, and submitting it to the
 // transaction pool.
@@ -82,27 +211,48 @@ func (h *Host) Announce() error {
 	}
 	defer h.tg.Done()
 
-	// Determine whether to use the settings.NetAddress or autoAddress.
+	// Determine whether to use the settings.NetAddress, the address allocated
+	// by the relay, or autoAddress.
 	h.mu.RLock()
-	na := h.settings.NetAddress
-	aa := h.autoAddress
+	addr := h.netAddress()
 	h.mu.RUnlock()
-	if na != "" {
-		return h.managedAnnounce(na)
-	}
-	if aa == "" {
+	if addr == "" {
 		return errUnknownAddress
 	}
-	return h.managedAnnounce(aa)
+	return h.managedAnnounce(addr)
 }
 
 // AnnounceAddress submits a host announcement to the blockchain to announce a
-// specific address. No checks for validity are performed on the address.
+// specific address, which may be an IPv4 address, an IPv6 address, or a DNS
+// name. If the address has no port, the host's port is used.
 func (h *Host) AnnounceAddress(addr modules.NetAddress) error {
 	err := h.tg.Add()
 	if err != nil {
 		return err
 	}
 	defer h.tg.Done()
-	return h.managedAnnounce(addr)
+
+	h.mu.RLock()
+	port := h.port
+	h.mu.RUnlock()
+	na, err := normalizeAnnounceAddress(string(addr), port)
+	if err != nil {
+		return err
+	}
+	return h.managedAnnounce(na)
+}
+
+// AnnouncementStatus returns the address that the host last announced, along
+// with the address it would announce now.
+func (h *Host) AnnouncementStatus() modules.HostAnnouncementStatus {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	current := h.netAddress()
+	return modules.HostAnnouncementStatus{
+		AnnouncedAddress: h.announcement.address,
+		CurrentAddress:   current,
+		LastAnnouncement: h.announcement.last,
+		Pending:          current != "" && current != h.announcement.address,
+		Warning:          h.announcement.warning,
+	}
 }
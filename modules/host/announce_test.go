@@ -3,6 +3,7 @@ package host
 import (
 	"bytes"
 	"testing"
+	"time"
 
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
@@ -136,3 +137,82 @@ func TestHostAnnounceAddress(t *testing.T) {
 		t.Error("announcement has wrong host key")
 	}
 }
+
+// TestNormalizeAnnounceAddress checks that DNS names and IPv6 addresses are
+// accepted as announcement addresses, with the host's port added where it is
+// missing.
+func TestNormalizeAnnounceAddress(t *testing.T) {
+	tests := []struct {
+		addr string
+		want modules.NetAddress
+	}{
+		{"foo.com:1234", "foo.com:1234"},
+		{"foo.com", "foo.com:9982"},
+		{"203.0.113.7", "203.0.113.7:9982"},
+		{"[2001:db8::1]:1234", "[2001:db8::1]:1234"},
+		{"[2001:db8::1]", "[2001:db8::1]:9982"},
+		{"2001:db8::1", "[2001:db8::1]:9982"},
+	}
+	for _, test := range tests {
+		na, err := normalizeAnnounceAddress(test.addr, "9982")
+		if err != nil {
+			t.Errorf("%v was rejected: %v", test.addr, err)
+		} else if na != test.want {
+			t.Errorf("%v was normalized to %v, expected %v", test.addr, na, test.want)
+		}
+	}
+
+	for _, addr := range []string{"foo", "foo.com:0", "[fe80::1]:9982", "0.0.0.0"} {
+		if _, err := normalizeAnnounceAddress(addr, "9982"); err == nil {
+			t.Errorf("%v was accepted", addr)
+		}
+	}
+}
+
+// TestReannounceDue checks that the host re-announces when its address
+// changes, but not more often than the announcement cooldown allows.
+func TestReannounceDue(t *testing.T) {
+	now := time.Now()
+	old := now.Add(-autoAnnounceCooldown)
+	recent := now.Add(-autoAnnounceCooldown / 2)
+	tests := []struct {
+		announced         bool
+		previous, current modules.NetAddress
+		last              time.Time
+		due               bool
+	}{
+		{true, "203.0.113.7:9982", "203.0.113.7:9982", old, false},
+		{true, "203.0.113.7:9982", "203.0.113.8:9982", old, true},
+		{true, "203.0.113.7:9982", "203.0.113.8:9982", recent, false},
+		{true, "203.0.113.7:9982", "", old, false},
+		{false, "203.0.113.7:9982", "203.0.113.7:9982", old, true},
+		{false, "", "203.0.113.7:9982", time.Time{}, true},
+	}
+	for i, test := range tests {
+		if due := reannounceDue(test.announced, test.previous, test.current, test.last, now); due != test.due {
+			t.Errorf("test %v: expected %v, got %v", i, test.due, due)
+		}
+	}
+}
+
+// TestResolvesTo checks that announced DNS names are matched against the
+// external IP of the host.
+func TestResolvesTo(t *testing.T) {
+	tests := []struct {
+		resolved []string
+		ip       string
+		match    bool
+	}{
+		{[]string{"203.0.113.7"}, "203.0.113.7", true},
+		{[]string{"203.0.113.8", "203.0.113.7"}, "203.0.113.7", true},
+		{[]string{"203.0.113.8"}, "203.0.113.7", false},
+		{[]string{"2001:db8::1"}, "203.0.113.7", true},
+		{[]string{"2001:db8::1", "203.0.113.8"}, "203.0.113.7", false},
+		{[]string{"2001:db8::1"}, "2001:db8:0::1", true},
+	}
+	for i, test := range tests {
+		if match := resolvesTo(test.resolved, test.ip); match != test.match {
+			t.Errorf("test %v: expected %v, got %v", i, test.match, match)
+		}
+	}
+}
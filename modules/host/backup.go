@@ -0,0 +1,271 @@
+package host
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules/host/contractmanager"
+	"github.com/NebulousLabs/Sia/persist"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// A host backup holds everything that the host needs to keep its contracts
+// after losing the disk that holds its persist directory: the host keys and
+// settings, the storage obligations with their latest revisions, the queued
+// action items, and the settings of the storage manager, which are needed to
+// find the sectors in the surviving storage folders.
+//
+// A backup is restored in two steps. RestoreBackup checks the backup and
+// stages it in the persist directory, and the staged backup is applied the
+// next time the host starts, before the storage manager and the database are
+// opened. Operators of a host that cannot start can also stage a backup by
+// copying it into the persist directory as host.restore. After a restore, the
+// host catches up with the blockchain from the point at which the backup was
+// taken, handling any action items that came due in the meantime.
+
+var (
+	// backupMetadata is the header of a host backup file.
+	backupMetadata = persist.Metadata{
+		Header:  "Sia Host Backup",
+		Version: "1.2.0",
+	}
+
+	// errBackupKeyMismatch is returned if the public key in a backup does
+	// not belong to its secret key.
+	errBackupKeyMismatch = errors.New("backup has a public key that does not match its secret key")
+
+	// errRestoreNotEmpty is returned if a backup is restored into a host that
+	// already has storage obligations.
+	errRestoreNotEmpty = errors.New("cannot restore a backup into a host that already has storage obligations")
+)
+
+type (
+	// hostBackup is the content of a host backup file.
+	hostBackup struct {
+		Persist        persistence         `json:"persist"`
+		Obligations    []storageObligation `json:"obligations"`
+		ActionItems    []backupActionItem  `json:"actionitems"`
+		StorageManager json.RawMessage     `json:"storagemanager"`
+	}
+
+	// backupActionItem lists the storage obligations that have an action
+	// item queued at a height.
+	backupActionItem struct {
+		Height types.BlockHeight      `json:"height"`
+		IDs    []types.FileContractID `json:"ids"`
+	}
+)
+
+// check checks that a backup is sane enough to be restored.
+func (b hostBackup) check() error {
+	pk := types.Ed25519PublicKey(b.Persist.SecretKey.PublicKey())
+	if b.Persist.PublicKey.String() != pk.String() {
+		return errBackupKeyMismatch
+	}
+	for _, so := range b.Obligations {
+		if len(so.OriginTransactionSet) == 0 {
+			return errInsaneOriginSetSize
+		}
+		if len(so.OriginTransactionSet[len(so.OriginTransactionSet)-1].FileContracts) != 1 {
+			return errInsaneOriginSetFileContract
+		}
+	}
+	return nil
+}
+
+// CreateBackup writes a backup of the host's keys, settings, and storage
+// obligations to the provided file.
+func (h *Host) CreateBackup(dst string) error {
+	err := h.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer h.tg.Done()
+
+	// The host lock is held throughout so that the backup is a consistent
+	// snapshot of the host.
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	b := hostBackup{
+		Persist: h.persistData(),
+	}
+	err = h.db.View(func(tx *bolt.Tx) error {
+		err := tx.Bucket(bucketStorageObligations).ForEach(func(_, soBytes []byte) error {
+			var so storageObligation
+			err := json.Unmarshal(soBytes, &so)
+			if err != nil {
+				return err
+			}
+			b.Obligations = append(b.Obligations, so)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(bucketActionItems).ForEach(func(heightBytes, items []byte) error {
+			bai := backupActionItem{
+				Height: types.BlockHeight(binary.BigEndian.Uint64(heightBytes)),
+				IDs:    make([]types.FileContractID, len(items)/crypto.HashSize),
+			}
+			for i := range bai.IDs {
+				copy(bai.IDs[i][:], items[i*crypto.HashSize:])
+			}
+			b.ActionItems = append(b.ActionItems, bai)
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	b.StorageManager, err = h.BackupSettings()
+	if err != nil {
+		return err
+	}
+
+	err = persist.SaveFileSync(backupMetadata, b, dst)
+	if err != nil {
+		return err
+	}
+	h.log.Printf("Backed up %v storage obligations to %v", len(b.Obligations), dst)
+	return nil
+}
+
+// RestoreBackup checks the backup in the provided file and stages it to be
+// restored the next time that the host starts. Backups can only be restored
+// into a host that has no storage obligations.
+func (h *Host) RestoreBackup(src string) error {
+	err := h.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer h.tg.Done()
+
+	var b hostBackup
+	err = persist.LoadFile(backupMetadata, &b, src)
+	if err != nil {
+		return err
+	}
+	err = b.check()
+	if err != nil {
+		return err
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	var empty bool
+	err = h.db.View(func(tx *bolt.Tx) error {
+		k, _ := tx.Bucket(bucketStorageObligations).Cursor().First()
+		empty = k == nil
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !empty {
+		return errRestoreNotEmpty
+	}
+
+	err = persist.SaveFileSync(backupMetadata, b, filepath.Join(h.persistDir, restoreFile))
+	if err != nil {
+		return err
+	}
+	h.log.Printf("Staged the backup at %v, with %v storage obligations, to be restored when the host restarts", src, len(b.Obligations))
+	return nil
+}
+
+// applyRestore restores the backup staged in the persist directory, if there
+// is one. It is called at startup, before the storage manager and the
+// database are opened. Applying a backup a second time, for example after an
+// interrupted restore, has no further effect.
+func (h *Host) applyRestore() error {
+	restorePath := filepath.Join(h.persistDir, restoreFile)
+	var b hostBackup
+	err := h.dependencies.loadFile(backupMetadata, &b, restorePath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	err = b.check()
+	if err != nil {
+		return err
+	}
+
+	// Restore the settings of the storage manager, so that it finds the
+	// sectors in the storage folders when it opens.
+	err = contractmanager.RestoreSettings(filepath.Join(h.persistDir, "contractmanager"), b.StorageManager)
+	if err != nil {
+		return err
+	}
+
+	// Restore the storage obligations and action items. Obligations that the
+	// database already holds must all come from the backup.
+	db, err := h.dependencies.openDatabase(dbMetadata, filepath.Join(h.persistDir, dbFilename))
+	if err != nil {
+		return err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		bso, err := tx.CreateBucketIfNotExists(bucketStorageObligations)
+		if err != nil {
+			return err
+		}
+		bai, err := tx.CreateBucketIfNotExists(bucketActionItems)
+		if err != nil {
+			return err
+		}
+		restored := make(map[types.FileContractID]struct{})
+		for _, so := range b.Obligations {
+			restored[so.id()] = struct{}{}
+		}
+		err = bso.ForEach(func(k, _ []byte) error {
+			var soid types.FileContractID
+			copy(soid[:], k)
+			if _, exists := restored[soid]; !exists {
+				return errRestoreNotEmpty
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, so := range b.Obligations {
+			err := putStorageObligation(tx, so)
+			if err != nil {
+				return err
+			}
+		}
+		for _, item := range b.ActionItems {
+			heightBytes := make([]byte, 8)
+			binary.BigEndian.PutUint64(heightBytes, uint64(item.Height))
+			items := make([]byte, 0, len(item.IDs)*crypto.HashSize)
+			for _, soid := range item.IDs {
+				items = append(items, soid[:]...)
+			}
+			err := bai.Put(heightBytes, items)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	err = composeErrors(err, db.Close())
+	if err != nil {
+		return err
+	}
+
+	// Restore the host keys and settings, and with them the consensus change
+	// that the host resumes from.
+	err = persist.SaveFileSync(persistMetadata, b.Persist, filepath.Join(h.persistDir, settingsFile))
+	if err != nil {
+		return err
+	}
+	h.log.Printf("Restored %v storage obligations from a backup", len(b.Obligations))
+	return h.dependencies.removeFile(restorePath)
+}
@@ -0,0 +1,39 @@
+package host
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestHostBackupCheck checks that backups with mismatched keys or malformed
+// storage obligations are refused.
+func TestHostBackupCheck(t *testing.T) {
+	sk, pk := crypto.GenerateKeyPair()
+	b := hostBackup{
+		Persist: persistence{
+			PublicKey: types.Ed25519PublicKey(pk),
+			SecretKey: sk,
+		},
+		Obligations: []storageObligation{{
+			OriginTransactionSet: []types.Transaction{{FileContracts: []types.FileContract{{}}}},
+		}},
+	}
+	if err := b.check(); err != nil {
+		t.Fatal("valid backup was refused:", err)
+	}
+
+	_, otherPK := crypto.GenerateKeyPair()
+	mismatched := b
+	mismatched.Persist.PublicKey = types.Ed25519PublicKey(otherPK)
+	if err := mismatched.check(); err != errBackupKeyMismatch {
+		t.Error("expected errBackupKeyMismatch, got", err)
+	}
+
+	malformed := b
+	malformed.Obligations = []storageObligation{{}}
+	if err := malformed.check(); err != errInsaneOriginSetSize {
+		t.Error("expected errInsaneOriginSetSize, got", err)
+	}
+}
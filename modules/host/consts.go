@@ -9,6 +9,22 @@ import (
 )
 
 const (
+	// defaultMaxConcurrentDownloads is the default number of download RPCs
+	// that the host will serve at once. Downloads are bounded mostly by disk
+	// and upstream bandwidth, so a moderate number of parallel sessions is
+	// allowed.
+	defaultMaxConcurrentDownloads = 50
+
+	// defaultMaxConcurrentNegotiations is the default number of contract
+	// formation and renewal RPCs that the host will handle at once. Each
+	// negotiation involves the wallet and the transaction pool, which are
+	// comparatively expensive, so the default is kept low.
+	defaultMaxConcurrentNegotiations = 10
+
+	// defaultMaxConcurrentRevisions is the default number of revision RPCs
+	// (uploads) that the host will serve at once.
+	defaultMaxConcurrentRevisions = 50
+
 	// defaultMaxDuration defines the maximum number of blocks into the future
 	// that the host will accept for the duration of an incoming file contract
 	// obligation. 6 months is chosen because hosts are expected to be
@@ -21,19 +37,172 @@ const (
 	// necessary to limit the impact of DoS attacks.
 	fileContractNegotiationTimeout = 120 * time.Second
 
+	// hostProbeVersion is the first version of siad that answers the
+	// ProbeHost RPC. It is only used for peers that do not share their
+	// capabilities, and is newer than any such peer.
+	hostProbeVersion = "1.1.3"
+
 	// iteratedConnectionTime is the amount of time that is allowed to pass
 	// before the host will stop accepting new iterations on an iterated
 	// connection.
 	iteratedConnectionTime = 1200 * time.Second
 
+	// maxProbeSettingsLen is the largest signed settings object that is read
+	// from a host while probing it.
+	maxProbeSettingsLen = 10e3
+
+	// probeAttempts is the number of peers that the host asks to probe it
+	// before giving up on a reachability check.
+	probeAttempts = 3
+
+	// reachabilityHistoryLen is the number of reachability checks that the
+	// host keeps.
+	reachabilityHistoryLen = 200
+
 	// resubmissionTimeout defines the number of blocks that a host will wait
 	// before attempting to resubmit a transaction to the blockchain.
 	// Typically, this transaction will contain either a file contract, a file
 	// contract revision, or a storage proof.
 	resubmissionTimeout = 3
+
+	// storageProofFeeEscalation is the factor, in percent, by which the fee of
+	// a storage proof transaction is raised each time the proof is resubmitted
+	// without having been confirmed. It must exceed the 110 percent that the
+	// transaction pool requires to replace the previous submission.
+	storageProofFeeEscalation = 150
+
+	// storageProofUrgentFeeMultiplier is the multiple of the recommended fee
+	// that the host pays at least once the end of the proof window is near.
+	storageProofUrgentFeeMultiplier = 3
 )
 
 var (
+	// abuseBanDuration is how long a renter is banned for once its
+	// misbehavior within the abuse window exceeds twice the threshold.
+	abuseBanDuration = func() time.Duration {
+		if build.Release == "dev" {
+			return time.Hour
+		}
+		if build.Release == "standard" {
+			return time.Hour * 24
+		}
+		if build.Release == "testing" {
+			return time.Second * 5
+		}
+		panic("unrecognized release constant in host - abuseBanDuration")
+	}()
+
+	// abuseThrottleDelay is the delay added before each RPC from a
+	// throttled renter is handled.
+	abuseThrottleDelay = func() time.Duration {
+		if build.Release == "dev" {
+			return time.Second * 2
+		}
+		if build.Release == "standard" {
+			return time.Second * 10
+		}
+		if build.Release == "testing" {
+			return time.Millisecond * 100
+		}
+		panic("unrecognized release constant in host - abuseThrottleDelay")
+	}()
+
+	// abuseThrottleDuration is how long a renter is throttled for once its
+	// misbehavior within the abuse window exceeds the threshold.
+	abuseThrottleDuration = func() time.Duration {
+		if build.Release == "dev" {
+			return time.Minute * 10
+		}
+		if build.Release == "standard" {
+			return time.Hour
+		}
+		if build.Release == "testing" {
+			return time.Second * 2
+		}
+		panic("unrecognized release constant in host - abuseThrottleDuration")
+	}()
+
+	// abuseWindow is the period over which the host counts the
+	// contracts formed, failed negotiations, and underpaid downloads of each
+	// renter when detecting abuse.
+	abuseWindow = func() time.Duration {
+		if build.Release == "dev" {
+			return time.Minute * 10
+		}
+		if build.Release == "standard" {
+			return time.Hour
+		}
+		if build.Release == "testing" {
+			return time.Second * 10
+		}
+		panic("unrecognized release constant in host - abuseWindow")
+	}()
+
+	// abuseMaxContracts is the number of contracts that a renter may form
+	// or renew within the abuse window before it is considered to be churning
+	// contracts. Honest renters form one contract per host per period.
+	abuseMaxContracts = func() int {
+		if build.Release == "dev" {
+			return 20
+		}
+		if build.Release == "standard" {
+			return 20
+		}
+		if build.Release == "testing" {
+			return 5
+		}
+		panic("unrecognized release constant in host - abuseMaxContracts")
+	}()
+
+	// abuseMaxFailedNegotiations is the number of contract formations,
+	// renewals, and revisions that a renter may fail within the abuse window
+	// before it is throttled.
+	abuseMaxFailedNegotiations = func() int {
+		if build.Release == "dev" {
+			return 10
+		}
+		if build.Release == "standard" {
+			return 10
+		}
+		if build.Release == "testing" {
+			return 3
+		}
+		panic("unrecognized release constant in host - abuseMaxFailedNegotiations")
+	}()
+
+	// abuseMaxUnderpaidDownloads is the number of download requests with
+	// insufficient payment that a renter may make within the abuse window
+	// before it is throttled.
+	abuseMaxUnderpaidDownloads = func() int {
+		if build.Release == "dev" {
+			return 10
+		}
+		if build.Release == "standard" {
+			return 10
+		}
+		if build.Release == "testing" {
+			return 3
+		}
+		panic("unrecognized release constant in host - abuseMaxUnderpaidDownloads")
+	}()
+
+	// autoAnnounceCooldown is the minimum amount of time between two
+	// announcements that the host makes on its own after its address has
+	// changed. The cooldown keeps a host whose address is flapping from
+	// spending its coins on announcements.
+	autoAnnounceCooldown = func() time.Duration {
+		if build.Release == "dev" {
+			return time.Minute * 5
+		}
+		if build.Release == "standard" {
+			return time.Hour * 2
+		}
+		if build.Release == "testing" {
+			return time.Second * 5
+		}
+		panic("unrecognized release constant in host - autoAnnounceCooldown")
+	}()
+
 	// defaultCollateral defines the amount of money that the host puts up as
 	// collateral per-byte by default. The collateral should be considered as
 	// an absolute instead of as a percentage, because low prices result in
@@ -125,6 +294,38 @@ var (
 		panic("unrecognized release constant in host - defaultWindowSize")
 	}()
 
+	// handoffMaxDuration is the number of blocks into the future that a
+	// handoff authorization may expire at. Sectors that are handed off to the
+	// host are staged until the authorization expires, giving the renter
+	// time to attach them to its contract.
+	handoffMaxDuration = func() types.BlockHeight {
+		if build.Release == "dev" {
+			return 36 // 3.6 minutes.
+		}
+		if build.Release == "standard" {
+			return 144 // 1 day.
+		}
+		if build.Release == "testing" {
+			return 10 // 10 seconds.
+		}
+		panic("unrecognized release constant in host - handoffMaxDuration")
+	}()
+
+	// handoffMaxSectors is the maximum number of sectors that can be handed
+	// off in a single handoff.
+	handoffMaxSectors = func() int {
+		if build.Release == "dev" {
+			return 1 << 16
+		}
+		if build.Release == "standard" {
+			return 1 << 18 // 1 TiB of 4 MiB sectors.
+		}
+		if build.Release == "testing" {
+			return 64
+		}
+		panic("unrecognized release constant in host - handoffMaxSectors")
+	}()
+
 	// logAllLimit is the number of errors of each type that the host will log
 	// before switching to probabilistic logging. If there are not many errors,
 	// it is reasonable that all errors get logged. If there are lots of
@@ -191,6 +392,114 @@ var (
 		panic("unrecognized release constant in host - obligationLockTimeout")
 	}()
 
+	// probeCooldown is the minimum amount of time between two probes that
+	// the host runs on behalf of the same node.
+	probeCooldown = func() time.Duration {
+		if build.Release == "dev" {
+			return time.Minute
+		}
+		if build.Release == "standard" {
+			return time.Minute * 10
+		}
+		if build.Release == "testing" {
+			return time.Second
+		}
+		panic("unrecognized release constant in host - probeCooldown")
+	}()
+
+	// probeTimeout is the amount of time that a probing host allows for
+	// connecting to the probed host and fetching its settings.
+	probeTimeout = func() time.Duration {
+		if build.Release == "dev" {
+			return time.Second * 20
+		}
+		if build.Release == "standard" {
+			return time.Second * 60
+		}
+		if build.Release == "testing" {
+			return time.Second * 5
+		}
+		panic("unrecognized release constant in host - probeTimeout")
+	}()
+
+	// reachabilityCheckInterval is the interval at which the host asks a
+	// peer to check that it is reachable at its address.
+	reachabilityCheckInterval = func() time.Duration {
+		if build.Release == "dev" {
+			return time.Minute * 2
+		}
+		if build.Release == "standard" {
+			return time.Minute * 30
+		}
+		if build.Release == "testing" {
+			return time.Second * 3
+		}
+		panic("unrecognized release constant in host - reachabilityCheckInterval")
+	}()
+
+	// relayAcceptTimeout is the amount of time that a relay host waits for a
+	// registered host to open the connection that a renter session is
+	// relayed over before dropping the renter.
+	relayAcceptTimeout = func() time.Duration {
+		if build.Release == "dev" {
+			return time.Second * 10
+		}
+		if build.Release == "standard" {
+			return time.Second * 30
+		}
+		if build.Release == "testing" {
+			return time.Second * 3
+		}
+		panic("unrecognized release constant in host - relayAcceptTimeout")
+	}()
+
+	// relayKeepaliveInterval is the interval at which a relay host writes a
+	// keepalive to each registered host, keeping the NAT mapping of the
+	// registration open and letting both sides notice a dead connection.
+	relayKeepaliveInterval = func() time.Duration {
+		if build.Release == "dev" {
+			return time.Second * 30
+		}
+		if build.Release == "standard" {
+			return time.Minute
+		}
+		if build.Release == "testing" {
+			return time.Second
+		}
+		panic("unrecognized release constant in host - relayKeepaliveInterval")
+	}()
+
+	// relayMaxHosts is the maximum number of hosts that can be registered
+	// with a relay host at once.
+	relayMaxHosts = func() int {
+		if build.Release == "dev" {
+			return 16
+		}
+		if build.Release == "standard" {
+			return 64
+		}
+		if build.Release == "testing" {
+			return 4
+		}
+		panic("unrecognized release constant in host - relayMaxHosts")
+	}()
+
+	// relayRetryInterval is the amount of time that a host waits before
+	// registering with its relay again after the registration fails or is
+	// dropped.
+	relayRetryInterval = func() time.Duration {
+		if build.Release == "dev" {
+			return time.Second * 10
+		}
+		if build.Release == "standard" {
+			return time.Minute
+		}
+		if build.Release == "testing" {
+			return time.Millisecond * 100
+		}
+		panic("unrecognized release constant in host - relayRetryInterval")
+	}()
+
 	// revisionSubmissionBuffer describes the number of blocks ahead of time
 	// that the host will submit a file contract revision. The host will not
 	// accept any more revisions once inside the submission buffer.
@@ -206,6 +515,39 @@ var (
 		}
 		panic("unrecognized release constant in host - revision submission buffer")
 	}()
+
+	// rpcQueueTimeout is the amount of time that an incoming RPC will wait
+	// for a free slot when the host is already handling the maximum number of
+	// concurrent RPCs of that type. If no slot frees up in time, the
+	// connection is closed so that the renter can quickly try another host.
+	rpcQueueTimeout = func() time.Duration {
+		if build.Release == "dev" {
+			return time.Second * 10
+		}
+		if build.Release == "standard" {
+			return time.Second * 30
+		}
+		if build.Release == "testing" {
+			return time.Second * 1
+		}
+		panic("unrecognized release constant in host - rpcQueueTimeout")
+	}()
+
+	// storageProofUrgentBlocks is the number of blocks before the end of the
+	// proof window from which the host pays an urgent fee to get its storage
+	// proof confirmed.
+	storageProofUrgentBlocks = func() types.BlockHeight {
+		if build.Release == "dev" {
+			return 12
+		}
+		if build.Release == "standard" {
+			return 36
+		}
+		if build.Release == "testing" {
+			return 4
+		}
+		panic("unrecognized release constant in host - storageProofUrgentBlocks")
+	}()
 )
 
 // All of the following variables define the names of buckets used by the host
@@ -235,4 +577,10 @@ func init() {
 	if revisionSubmissionBuffer < resubmissionTimeout {
 		build.Critical("revision submission buffer needs to be larger than or equal to the resubmission timeout")
 	}
+
+	// Each resubmitted storage proof has to pay enough to replace the
+	// previous submission in the transaction pool.
+	if storageProofFeeEscalation <= 110 {
+		build.Critical("storage proof fee escalation needs to be larger than the replace-by-fee threshold of the transaction pool")
+	}
 }
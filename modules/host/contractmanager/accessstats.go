@@ -0,0 +1,292 @@
+package contractmanager
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
+)
+
+const (
+	// accessStatsDays is the number of days of read counts that are kept by
+	// the access tracker.
+	accessStatsDays = 30
+
+	// hotSectorWindow is how recently a sector must have been read to count
+	// as hot.
+	hotSectorWindow = 7 * 24 * time.Hour
+)
+
+// accessTracker keeps aggregated statistics about how the sectors in the
+// contract manager are accessed, so that host operators can plan their
+// storage. The statistics are saved next to the contract manager's settings,
+// periodically and at shutdown. Only the times at which sectors were added and
+// last read are tracked per sector; nothing is recorded about who read them.
+type accessTracker struct {
+	// since is the time at which tracking started. dailyReads maps a day,
+	// counted in days since the unix epoch, to the number of sectors that
+	// were read that day.
+	since      time.Time
+	dailyReads map[int64]uint64
+
+	// added and lastRead hold the unix times at which each sector was
+	// physically added and last read. Sectors that were stored before
+	// tracking started have no add time.
+	added    map[sectorID]int64
+	lastRead map[sectorID]int64
+
+	// removed is the number of sectors with a known add time that have been
+	// removed, and totalLifetime is the sum of their lifetimes.
+	removed       uint64
+	totalLifetime time.Duration
+
+	mu sync.Mutex
+}
+
+// savedAccessStats is the persistent version of an accessTracker.
+type savedAccessStats struct {
+	Since         time.Time
+	DailyReads    map[int64]uint64
+	Sectors       []savedSectorAccess
+	Removed       uint64
+	TotalLifetime time.Duration
+}
+
+// savedSectorAccess holds the add and last read times of a sector, zero if
+// they are unknown.
+type savedSectorAccess struct {
+	ID       sectorID
+	Added    int64
+	LastRead int64
+}
+
+// newAccessTracker returns an access tracker that starts tracking at the
+// provided time.
+func newAccessTracker(now time.Time) *accessTracker {
+	return &accessTracker{
+		since:      now,
+		dailyReads: make(map[int64]uint64),
+		added:      make(map[sectorID]int64),
+		lastRead:   make(map[sectorID]int64),
+	}
+}
+
+// persistData returns the persistent version of the access tracker.
+func (at *accessTracker) persistData() savedAccessStats {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	sas := savedAccessStats{
+		Since:         at.since,
+		DailyReads:    make(map[int64]uint64, len(at.dailyReads)),
+		Removed:       at.removed,
+		TotalLifetime: at.totalLifetime,
+	}
+	for d, reads := range at.dailyReads {
+		sas.DailyReads[d] = reads
+	}
+	sectors := make(map[sectorID]savedSectorAccess)
+	for id, added := range at.added {
+		sectors[id] = savedSectorAccess{ID: id, Added: added}
+	}
+	for id, lastRead := range at.lastRead {
+		ssa := sectors[id]
+		ssa.ID, ssa.LastRead = id, lastRead
+		sectors[id] = ssa
+	}
+	for _, ssa := range sectors {
+		sas.Sectors = append(sas.Sectors, ssa)
+	}
+	return sas
+}
+
+// loadAccessTracker returns the access tracker held by sas. Sectors that are
+// not in stored, such as sectors that were removed after the statistics were
+// last saved, are dropped.
+func loadAccessTracker(sas savedAccessStats, stored map[sectorID]sectorLocation) *accessTracker {
+	at := newAccessTracker(sas.Since)
+	for d, reads := range sas.DailyReads {
+		at.dailyReads[d] = reads
+	}
+	for _, ssa := range sas.Sectors {
+		if _, ok := stored[ssa.ID]; !ok {
+			continue
+		}
+		if ssa.Added != 0 {
+			at.added[ssa.ID] = ssa.Added
+		}
+		if ssa.LastRead != 0 {
+			at.lastRead[ssa.ID] = ssa.LastRead
+		}
+	}
+	at.removed = sas.Removed
+	at.totalLifetime = sas.TotalLifetime
+	return at
+}
+
+// day returns the number of days between the unix epoch and t.
+func day(t time.Time) int64 {
+	return t.Unix() / int64(24*time.Hour/time.Second)
+}
+
+// recordAdd records that a sector was physically added to the contract
+// manager.
+func (at *accessTracker) recordAdd(id sectorID, now time.Time) {
+	at.mu.Lock()
+	at.added[id] = now.Unix()
+	at.mu.Unlock()
+}
+
+// recordRead records that a sector was read, dropping the read counts that
+// are too old to be reported.
+func (at *accessTracker) recordRead(id sectorID, now time.Time) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	today := day(now)
+	at.dailyReads[today]++
+	at.lastRead[id] = now.Unix()
+	for d := range at.dailyReads {
+		if d <= today-accessStatsDays {
+			delete(at.dailyReads, d)
+		}
+	}
+}
+
+// recordRemove records that the last copy of a sector was removed from the
+// contract manager.
+func (at *accessTracker) recordRemove(id sectorID, now time.Time) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	if added, ok := at.added[id]; ok {
+		at.removed++
+		at.totalLifetime += now.Sub(time.Unix(added, 0))
+	}
+	delete(at.added, id)
+	delete(at.lastRead, id)
+}
+
+// lastActive returns the time at which the sector was last added or read. For
+// sectors without either, the time at which tracking started is returned.
+func (at *accessTracker) lastActive(id sectorID) time.Time {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	active := at.since.Unix()
+	if added := at.added[id]; added > active {
+		active = added
+	}
+	if lastRead := at.lastRead[id]; lastRead > active {
+		active = lastRead
+	}
+	return time.Unix(active, 0)
+}
+
+// stats summarizes the tracked statistics for the provided set of stored
+// sectors.
+func (at *accessTracker) stats(stored map[sectorID]sectorLocation, now time.Time) modules.SectorAccessStats {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+
+	s := modules.SectorAccessStats{
+		Since:     at.since,
+		Sectors:   uint64(len(stored)),
+		HotWindow: hotSectorWindow,
+	}
+	first := day(at.since)
+	if today := day(now); today-first >= accessStatsDays {
+		first = today - accessStatsDays + 1
+	}
+	var totalReads uint64
+	for d := first; d <= day(now); d++ {
+		s.ReadsPerDay = append(s.ReadsPerDay, at.dailyReads[d])
+		totalReads += at.dailyReads[d]
+	}
+	s.AverageReadsPerDay = float64(totalReads) / float64(len(s.ReadsPerDay))
+
+	hotSince := now.Add(-hotSectorWindow).Unix()
+	for id, sl := range stored {
+		s.SectorReferences += uint64(sl.count)
+		if lastRead, ok := at.lastRead[id]; ok && lastRead >= hotSince {
+			s.HotSectors++
+		}
+	}
+	if s.SectorReferences > s.Sectors {
+		s.DeduplicatedBytes = (s.SectorReferences - s.Sectors) * modules.SectorSize
+	}
+	if s.Sectors > 0 {
+		s.HotFraction = float64(s.HotSectors) / float64(s.Sectors)
+	}
+	s.RemovedSectors = at.removed
+	if at.removed > 0 {
+		s.AverageLifetime = at.totalLifetime / time.Duration(at.removed)
+	}
+	return s
+}
+
+// managedRecordRemove records the removal of a sector if no copies of the
+// sector remain in the contract manager, and drops the sector from the cache.
+func (cm *ContractManager) managedRecordRemove(id sectorID) {
+	cm.wal.mu.Lock()
+	_, exists := cm.sectorLocations[id]
+	cm.wal.mu.Unlock()
+	if !exists {
+		cm.access.recordRemove(id, time.Now())
+		cm.cache.remove(id)
+	}
+}
+
+// loadAccessStats loads the statistics of the access tracker saved by a
+// previous run of the contract manager. The statistics start over if they have
+// never been saved or cannot be read. loadAccessStats must be called after the
+// sector locations have been loaded.
+func (cm *ContractManager) loadAccessStats() {
+	var sas savedAccessStats
+	err := persist.LoadFile(accessStatsMetadata, &sas, filepath.Join(cm.persistDir, accessStatsFile))
+	if os.IsNotExist(err) {
+		return
+	} else if err != nil {
+		cm.log.Println("WARN: unable to load the sector access statistics, starting over:", err)
+		return
+	}
+	cm.wal.mu.Lock()
+	cm.access = loadAccessTracker(sas, cm.sectorLocations)
+	cm.wal.mu.Unlock()
+}
+
+// saveAccessStats saves the statistics of the access tracker to disk.
+func (cm *ContractManager) saveAccessStats() error {
+	sas := cm.access.persistData()
+	return persist.SaveFileSync(accessStatsMetadata, sas, filepath.Join(cm.persistDir, accessStatsFile))
+}
+
+// threadedSaveAccessStats periodically saves the statistics of the access
+// tracker, so that they survive an unclean shutdown.
+func (cm *ContractManager) threadedSaveAccessStats() {
+	for {
+		select {
+		case <-cm.tg.StopChan():
+			return
+		case <-time.After(accessStatsSaveInterval):
+		}
+		if cm.tg.Add() != nil {
+			return
+		}
+		err := cm.saveAccessStats()
+		cm.tg.Done()
+		if err != nil {
+			cm.log.Println("WARN: unable to save the sector access statistics:", err)
+		}
+	}
+}
+
+// SectorAccessStats returns aggregated statistics about how the sectors stored
+// by the contract manager have been accessed since tracking started, including
+// the hit statistics of the sector cache.
+func (cm *ContractManager) SectorAccessStats() modules.SectorAccessStats {
+	cm.wal.mu.Lock()
+	defer cm.wal.mu.Unlock()
+	s := cm.access.stats(cm.sectorLocations, time.Now())
+	s.Cache = cm.cache.stats()
+	return s
+}
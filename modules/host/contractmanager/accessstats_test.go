@@ -0,0 +1,122 @@
+package contractmanager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestAccessTracker checks the statistics reported by the access tracker as
+// sectors are added, read, and removed over time.
+func TestAccessTracker(t *testing.T) {
+	start := time.Unix(1e9, 0)
+	at := newAccessTracker(start)
+	hot, cold, removed := sectorID{1}, sectorID{2}, sectorID{3}
+	stored := map[sectorID]sectorLocation{hot: {}, cold: {}}
+
+	at.recordAdd(removed, start)
+	at.recordRead(cold, start)
+	at.recordRead(removed, start)
+	now := start.Add(10 * 24 * time.Hour)
+	at.recordRead(hot, now)
+	at.recordRead(hot, now)
+	at.recordRemove(removed, now)
+
+	s := at.stats(stored, now)
+	if len(s.ReadsPerDay) != 11 || s.ReadsPerDay[0] != 2 || s.ReadsPerDay[10] != 2 {
+		t.Fatal("wrong reads per day:", s.ReadsPerDay)
+	}
+	if s.AverageReadsPerDay != 4.0/11 {
+		t.Error("wrong average reads per day:", s.AverageReadsPerDay)
+	}
+	if s.Sectors != 2 || s.HotSectors != 1 || s.HotFraction != 0.5 {
+		t.Error("wrong hot sectors:", s.Sectors, s.HotSectors, s.HotFraction)
+	}
+	if s.RemovedSectors != 1 || s.AverageLifetime != 10*24*time.Hour {
+		t.Error("wrong lifetime:", s.RemovedSectors, s.AverageLifetime)
+	}
+
+	// Reads that are too old to be reported are dropped.
+	now = start.Add(100 * 24 * time.Hour)
+	at.recordRead(hot, now)
+	s = at.stats(stored, now)
+	if len(s.ReadsPerDay) != accessStatsDays || s.ReadsPerDay[accessStatsDays-1] != 1 || len(at.dailyReads) != 1 {
+		t.Fatal("old reads were not dropped:", s.ReadsPerDay)
+	}
+}
+
+// TestSectorAccessStats checks that the contract manager tracks the sectors
+// that are added, read, and removed.
+func TestSectorAccessStats(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	storageFolderDir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	err = os.MkdirAll(storageFolderDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cmt.cm.AddStorageFolder(storageFolderDir, modules.SectorSize*64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Add two sectors and read one of them.
+	root1, data1 := randSector()
+	root2, data2 := randSector()
+	if err := cmt.cm.AddSector(root1, data1); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmt.cm.AddSector(root2, data2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cmt.cm.ReadSector(root1); err != nil {
+		t.Fatal(err)
+	}
+	s := cmt.cm.SectorAccessStats()
+	if s.Sectors != 2 || s.HotSectors != 1 || s.ReadsPerDay[len(s.ReadsPerDay)-1] != 1 {
+		t.Fatal("wrong access stats:", s.Sectors, s.HotSectors, s.ReadsPerDay)
+	}
+
+	// A sector that has been added twice is only removed with its last copy.
+	if err := cmt.cm.AddSector(root2, data2); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmt.cm.RemoveSector(root2); err != nil {
+		t.Fatal(err)
+	}
+	if s := cmt.cm.SectorAccessStats(); s.RemovedSectors != 0 {
+		t.Fatal("a sector with a remaining copy was counted as removed")
+	}
+	if err := cmt.cm.RemoveSector(root2); err != nil {
+		t.Fatal(err)
+	}
+	s = cmt.cm.SectorAccessStats()
+	if s.Sectors != 1 || s.RemovedSectors != 1 || s.HotFraction != 1 {
+		t.Fatal("wrong access stats after removal:", s.Sectors, s.RemovedSectors, s.HotFraction)
+	}
+
+	// The statistics are kept across restarts.
+	err = cmt.cm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmt.cm, err = New(cmt.cm.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarted := cmt.cm.SectorAccessStats()
+	if !restarted.Since.Equal(s.Since) || restarted.Sectors != 1 || restarted.HotSectors != 1 || restarted.RemovedSectors != 1 || restarted.AverageLifetime != s.AverageLifetime {
+		t.Fatal("access stats were not kept across a restart:", restarted)
+	}
+}
@@ -0,0 +1,60 @@
+package contractmanager
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/NebulousLabs/Sia/persist"
+)
+
+var (
+	// errRestoreFoldersInUse is returned when settings are restored into a
+	// contract manager directory that already has storage folders.
+	errRestoreFoldersInUse = errors.New("cannot restore contract manager settings over a contract manager that has storage folders")
+)
+
+// BackupSettings returns the settings of the contract manager, which hold the
+// sector salt and the list of storage folders. Without them, the sectors in
+// the storage folders cannot be found if the contract manager directory is
+// lost.
+func (cm *ContractManager) BackupSettings() ([]byte, error) {
+	err := cm.tg.Add()
+	if err != nil {
+		return nil, err
+	}
+	defer cm.tg.Done()
+	cm.wal.mu.Lock()
+	ss := cm.savedSettings()
+	cm.wal.mu.Unlock()
+	return json.Marshal(ss)
+}
+
+// RestoreSettings writes settings returned by BackupSettings into the
+// contract manager directory at persistDir, so that the contract manager
+// opened there uses the sector salt and storage folders of the backup. The
+// contract manager must not be open while its settings are restored, and
+// settings are only restored into a directory whose contract manager has no
+// storage folders, or that already holds the settings of the backup.
+func RestoreSettings(persistDir string, settings []byte) error {
+	var ss savedSettings
+	err := json.Unmarshal(settings, &ss)
+	if err != nil {
+		return err
+	}
+
+	var existing savedSettings
+	err = persist.LoadFile(settingsMetadata, &existing, filepath.Join(persistDir, settingsFile))
+	if err == nil && len(existing.StorageFolders) != 0 && existing.SectorSalt != ss.SectorSalt {
+		return errRestoreFoldersInUse
+	} else if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	err = os.MkdirAll(persistDir, 0700)
+	if err != nil {
+		return err
+	}
+	return persist.SaveFileSync(settingsMetadata, &ss, filepath.Join(persistDir, settingsFile))
+}
@@ -0,0 +1,93 @@
+package contractmanager
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestRestoreSettings checks that a contract manager opened with restored
+// settings finds the storage folders of the backup, and that settings are not
+// restored over a contract manager that uses other storage folders.
+func TestRestoreSettings(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester("TestRestoreSettings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	storageFolderDir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	err = os.MkdirAll(storageFolderDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cmt.cm.AddStorageFolder(storageFolderDir, modules.SectorSize*storageFolderGranularity*2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, data := randSector()
+	err = cmt.cm.AddSector(root, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings, err := cmt.cm.BackupSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cmt.cm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Open a contract manager in a new directory with the restored settings.
+	restoreDir := filepath.Join(cmt.persistDir, "restored")
+	err = RestoreSettings(restoreDir, settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm, err := New(restoreDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sfs := cm.StorageFolders()
+	if len(sfs) != 1 || sfs[0].Path != storageFolderDir {
+		t.Fatal("restored contract manager has the wrong storage folders:", sfs)
+	}
+	readData, err := cm.ReadSector(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(readData) != string(data) {
+		t.Fatal("restored contract manager returned the wrong sector data")
+	}
+	err = cm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Restoring the same settings again is allowed, but settings with another
+	// sector salt are refused.
+	err = RestoreSettings(restoreDir, settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ss savedSettings
+	err = json.Unmarshal(settings, &ss)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ss.SectorSalt[0]++
+	otherSettings, err := json.Marshal(ss)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = RestoreSettings(restoreDir, otherSettings)
+	if err != errRestoreFoldersInUse {
+		t.Fatal("expected errRestoreFoldersInUse, got", err)
+	}
+}
@@ -1,7 +1,10 @@
 package contractmanager
 
 import (
+	"time"
+
 	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/persist"
 )
 
@@ -14,6 +17,15 @@ const (
 	// metadata associated with a storage folder.
 	metadataFile = "siahostmetadata.dat"
 
+	// accessStatsFile is the name of the file that is used to save the
+	// statistics of the access tracker.
+	accessStatsFile = "contractmanager_accessstats.json"
+
+	// metadataBackupFile is the name of the file that stores a checksummed
+	// copy of the sector metadata of a storage folder. The copy is used to
+	// repair the metadata file if it is damaged.
+	metadataBackupFile = "siahostmetadata_backup.dat"
+
 	// settingsFile is the name of the file that is used to save the contract
 	// manager's settings.
 	settingsFile = "contractmanager.json"
@@ -24,6 +36,11 @@ const (
 	// manager's persistent settings are updated atomically.
 	settingsFileTmp = "contractmanager.json_temp"
 
+	// saltRotationFile is the name of the file that records the progress of a
+	// rotation of the sector salt, so that an interrupted rotation can be
+	// resumed.
+	saltRotationFile = "contractmanager_saltrotation.json"
+
 	// sectorFile is the file that is placed inside of a storage folder to
 	// house all of the sectors associated with a storage folder.
 	sectorFile = "siahostdata.dat"
@@ -43,6 +60,11 @@ const (
 	// metadata of a single sector on disk.
 	sectorMetadataDiskSize = 14
 
+	// sectorMetadataBackupDiskSize defines the number of bytes it takes to
+	// store the backup metadata of a single sector on disk, which is the
+	// metadata followed by a 4 byte checksum.
+	sectorMetadataBackupDiskSize = sectorMetadataDiskSize + 4
+
 	// storageFolderGranularity defines the number of sectors that a storage
 	// folder must cleanly divide into. 64 sectors is a requirement due to the
 	// way the storage folder bitfield (field 'Usage') is constructed - the
@@ -63,6 +85,20 @@ var (
 		Version: "1.2.0",
 	}
 
+	// accessStatsMetadata is the header that is used when writing the
+	// statistics of the access tracker to disk.
+	accessStatsMetadata = persist.Metadata{
+		Header:  "Sia Contract Manager Access Stats",
+		Version: "1.2.0",
+	}
+
+	// saltRotationMetadata is the header that is used when writing the
+	// progress of a rotation of the sector salt to disk.
+	saltRotationMetadata = persist.Metadata{
+		Header:  "Sia Contract Manager Salt Rotation",
+		Version: "1.2.0",
+	}
+
 	// walMetadata is the header that is used when writing the write ahead log
 	// to disk, so that it may be identified at startup.
 	walMetadata = persist.Metadata{
@@ -104,6 +140,120 @@ var (
 		panic("unrecognized release constant in host - maximum storage folder size")
 	}()
 
+	// sectorLoadProgressInterval is the number of sectors that are loaded
+	// from a storage folder at startup between progress messages in the log,
+	// so that the operator of a host with large folders can tell that
+	// startup is making progress.
+	sectorLoadProgressInterval = build.Select(build.Var{
+		Standard: 1 << 18,
+		Dev:      1 << 12,
+		Testing:  1 << 4,
+	}).(int)
+
+	// defaultNetworkWritePolicy is the write policy given to storage folders
+	// that are added on a network filesystem. A network mount that stops
+	// responding can block writes for minutes, so writes that take longer
+	// than the timeout are abandoned and the sector is placed elsewhere.
+	defaultNetworkWritePolicy = build.Select(build.Var{
+		Standard: modules.StorageFolderWritePolicy{
+			WriteTimeout: 30 * time.Second,
+			Retries:      2,
+			RetryDelay:   time.Second,
+		},
+		Dev: modules.StorageFolderWritePolicy{
+			WriteTimeout: 10 * time.Second,
+			Retries:      2,
+			RetryDelay:   500 * time.Millisecond,
+		},
+		Testing: modules.StorageFolderWritePolicy{
+			WriteTimeout: time.Second,
+			Retries:      1,
+			RetryDelay:   10 * time.Millisecond,
+		},
+	}).(modules.StorageFolderWritePolicy)
+
+	// storageFolderFailureCooldown is the amount of time that a storage
+	// folder with a write policy is skipped when choosing where to place new
+	// sectors after its writes have failed through all retries.
+	storageFolderFailureCooldown = build.Select(build.Var{
+		Standard: 5 * time.Minute,
+		Dev:      time.Minute,
+		Testing:  time.Second,
+	}).(time.Duration)
+
+	// folderWriteConcurrency is the number of new sectors that are written
+	// to a single storage folder at once. Further sectors for the folder wait
+	// in its write queue, and new sectors are steered to the folders with the
+	// shortest queues.
+	folderWriteConcurrency = build.Select(build.Var{
+		Standard: 4,
+		Dev:      4,
+		Testing:  2,
+	}).(int)
+
+	// groupCommitDelay is the amount of time that the WAL waits after a
+	// change is appended before committing, so that changes made by
+	// concurrent operations are synced to disk together.
+	groupCommitDelay = build.Select(build.Var{
+		Standard: 5 * time.Millisecond,
+		Dev:      5 * time.Millisecond,
+		Testing:  time.Millisecond,
+	}).(time.Duration)
+
+	// scanInterval is the amount of time between scheduled integrity scans of
+	// the storage folders. scanCheckInterval is how often the contract
+	// manager checks whether a scheduled scan is due.
+	scanInterval = build.Select(build.Var{
+		Standard: 30 * 24 * time.Hour,
+		Dev:      time.Hour,
+		Testing:  24 * time.Hour,
+	}).(time.Duration)
+	scanCheckInterval = build.Select(build.Var{
+		Standard: time.Hour,
+		Dev:      time.Minute,
+		Testing:  time.Hour,
+	}).(time.Duration)
+
+	// accessStatsSaveInterval is how often the statistics of the access
+	// tracker are saved to disk. They are also saved at shutdown.
+	accessStatsSaveInterval = build.Select(build.Var{
+		Standard: time.Hour,
+		Dev:      5 * time.Minute,
+		Testing:  time.Hour,
+	}).(time.Duration)
+
+	// coldSectorAge is how long a sector in the fast tier must go without
+	// being read before it is moved down to the capacity tier.
+	// tierMigrationInterval is how often the fast tier is checked for cold
+	// sectors.
+	coldSectorAge = build.Select(build.Var{
+		Standard: 3 * 24 * time.Hour,
+		Dev:      time.Hour,
+		Testing:  time.Minute,
+	}).(time.Duration)
+	tierMigrationInterval = build.Select(build.Var{
+		Standard: time.Hour,
+		Dev:      5 * time.Minute,
+		Testing:  time.Hour,
+	}).(time.Duration)
+
+	// storageFolderMoveSamples is the number of sectors that are read to
+	// check that the files found at the new path of a moved storage folder
+	// belong to the folder.
+	storageFolderMoveSamples = build.Select(build.Var{
+		Standard: 64,
+		Dev:      16,
+		Testing:  4,
+	}).(int)
+
+	// sectorCacheSize is the number of recently read sectors that are kept in
+	// memory by the contract manager.
+	sectorCacheSize = build.Select(build.Var{
+		Standard: 32, // 128 MiB
+		Dev:      32,
+		Testing:  8,
+	}).(int)
+
 	// MinimumSectorsPerStorageFolder defines the minimum number of sectors
 	// that a storage folder is allowed to have.
 	MinimumSectorsPerStorageFolder = func() uint64 {
@@ -18,7 +18,9 @@ package contractmanager
 
 import (
 	"errors"
+	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
@@ -59,7 +61,8 @@ type ContractManager struct {
 	// cleanup before returning, which can be achieved with threadgroups.
 
 	// sectorSalt is a persistent security field that gets set the first time
-	// the contract manager is initiated and then never gets touched again.
+	// the contract manager is initiated and then only changes if it is rotated
+	// offline with RotateSectorSalt.
 	// It's used to randomize the location on-disk that a sector gets stored,
 	// so that an adversary cannot maliciously add sectors to specific disks,
 	// or otherwise perform manipulations that may degrade performance.
@@ -78,10 +81,24 @@ type ContractManager struct {
 	sectorLocations map[sectorID]sectorLocation
 	storageFolders  map[uint16]*storageFolder
 
+	// missingFolders holds the storage folders whose files could not be
+	// opened at startup. They are kept in the settings until they are moved
+	// to the new location of their files, or removed.
+	missingFolders map[uint16]savedStorageFolder
+
 	// lockedSectors contains a list of sectors that are currently being read
 	// or modified.
 	lockedSectors map[sectorID]*sectorLock
 
+	// access tracks how sectors are read, for capacity planning.
+	access *accessTracker
+
+	// scan tracks the integrity scans of the storage folders.
+	scan *scanTracker
+
+	// cache holds recently read sectors.
+	cache *sectorCache
+
 	// Utilities.
 	dependencies
 	log        *persist.Logger
@@ -101,9 +118,14 @@ func newContractManager(dependencies dependencies, persistDir string) (*Contract
 	cm := &ContractManager{
 		storageFolders:  make(map[uint16]*storageFolder),
 		sectorLocations: make(map[sectorID]sectorLocation),
+		missingFolders:  make(map[uint16]savedStorageFolder),
 
 		lockedSectors: make(map[sectorID]*sectorLock),
 
+		access: newAccessTracker(time.Now()),
+		scan:   new(scanTracker),
+		cache:  newSectorCache(sectorCacheSize),
+
 		dependencies: dependencies,
 		persistDir:   persistDir,
 	}
@@ -140,6 +162,16 @@ func newContractManager(dependencies dependencies, persistDir string) (*Contract
 		err = build.ComposeErrors(cm.log.Close(), err)
 	})
 
+	// A contract manager cannot be opened while its sector salt is being
+	// rotated, as some of its sectors are already stored under the new salt.
+	err = dependencies.loadFile(saltRotationMetadata, new(saltRotation), filepath.Join(cm.persistDir, saltRotationFile))
+	if err == nil {
+		err = errSaltRotationInProgress
+		return nil, err
+	} else if !os.IsNotExist(err) {
+		return nil, build.ExtendErr("error while checking for a rotation of the sector salt", err)
+	}
+
 	// Load the atomic state of the contract manager. Unclean shutdown may have
 	// wiped out some changes that got made. Anything really important will be
 	// recovered when the WAL is loaded.
@@ -169,6 +201,12 @@ func newContractManager(dependencies dependencies, persistDir string) (*Contract
 			if err != nil {
 				cm.log.Println("Error closing the storage folder file handle", err)
 			}
+			if sf.metadataBackupFile != nil {
+				err = sf.metadataBackupFile.Close()
+				if err != nil {
+					cm.log.Println("Error closing the storage folder file handle", err)
+				}
+			}
 		}
 	})
 
@@ -176,6 +214,15 @@ func newContractManager(dependencies dependencies, persistDir string) (*Contract
 	// during unclean shutdown has already been fixed by the WAL.
 	cm.loadSectorLocations()
 
+	// Load the access statistics, which refer to the loaded sectors, and save
+	// them again at shutdown.
+	cm.loadAccessStats()
+	cm.tg.AfterStop(func() {
+		if err := cm.saveAccessStats(); err != nil {
+			cm.log.Println("ERROR: Unable to save the sector access statistics:", err)
+		}
+	})
+
 	// Launch the sync loop that periodically flushes changes from the WAL to
 	// disk.
 	err = cm.wal.spawnSyncLoop()
@@ -189,6 +236,14 @@ func newContractManager(dependencies dependencies, persistDir string) (*Contract
 		err = errors.New("startup disrupted")
 		return nil, err
 	}
+
+	// Periodically check the stored sectors for corruption, and the disks
+	// that hold them for signs of failure. Cold sectors are periodically
+	// moved down from the fast tier, and the access statistics are saved.
+	go cm.threadedScheduledScans()
+	go cm.threadedMonitorDiskHealth()
+	go cm.threadedMigrateColdSectors()
+	go cm.threadedSaveAccessStats()
 	return cm, nil
 }
 
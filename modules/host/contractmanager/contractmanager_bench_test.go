@@ -1,9 +1,13 @@
 package contractmanager
 
 import (
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/fastrand"
 )
 
@@ -77,3 +81,63 @@ func BenchmarkStorageFolders(b *testing.B) {
 		randFreeSector(usage)
 	}
 }
+
+// benchmarkAddRemoveSector adds and removes sectors from a contract manager
+// using the provided number of threads. Each sector addition and removal waits
+// for its change to be synced to disk, so the throughput is bound by how well
+// the WAL groups the changes of concurrent operations into shared commits.
+func benchmarkAddRemoveSector(b *testing.B, name string, threads int) {
+	cmt, err := newContractManagerTester(name)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer cmt.panicClose()
+	storageFolderDir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	err = os.MkdirAll(storageFolderDir, 0700)
+	if err != nil {
+		b.Fatal(err)
+	}
+	err = cmt.cm.AddStorageFolder(storageFolderDir, modules.SectorSize*storageFolderGranularity*16)
+	if err != nil {
+		b.Fatal(err)
+	}
+	_, data := randSector()
+
+	b.SetBytes(int64(modules.SectorSize))
+	b.ResetTimer()
+	var wg sync.WaitGroup
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := i; j < b.N; j += threads {
+				var root crypto.Hash
+				fastrand.Read(root[:])
+				err := cmt.cm.AddSector(root, data)
+				if err != nil {
+					b.Error(err)
+					return
+				}
+				err = cmt.cm.RemoveSector(root)
+				if err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// BenchmarkAddRemoveSectorSerial measures the throughput of adding and
+// removing sectors one at a time.
+func BenchmarkAddRemoveSectorSerial(b *testing.B) {
+	benchmarkAddRemoveSector(b, "BenchmarkAddRemoveSectorSerial", 1)
+}
+
+// BenchmarkAddRemoveSectorParallel measures the throughput of adding and
+// removing sectors from many threads at once, as happens on a busy host. With
+// group commit, the cost of each sync is shared between the threads.
+func BenchmarkAddRemoveSectorParallel(b *testing.B) {
+	benchmarkAddRemoveSector(b, "BenchmarkAddRemoveSectorParallel", 32)
+}
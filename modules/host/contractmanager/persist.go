@@ -5,9 +5,11 @@ import (
 	"os"
 	"path/filepath"
 	"sync/atomic"
+	"time"
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/persist"
 	"github.com/NebulousLabs/fastrand"
 )
@@ -16,9 +18,12 @@ type (
 	// savedStorageFolder contains fields that are saved automatically to disk
 	// for each storage folder.
 	savedStorageFolder struct {
-		Index uint16
-		Path  string
-		Usage []uint64
+		Index       uint16
+		Path        string
+		Usage       []uint64
+		WritePolicy modules.StorageFolderWritePolicy
+		Tier        string
+		Network     bool
 	}
 
 	// savedSettings contains fields that are saved atomically to disk inside
@@ -26,6 +31,7 @@ type (
 	savedSettings struct {
 		SectorSalt     crypto.Hash
 		StorageFolders []savedStorageFolder
+		LastScan       time.Time
 	}
 )
 
@@ -35,6 +41,10 @@ func (sf *storageFolder) savedStorageFolder() savedStorageFolder {
 		Index: sf.index,
 		Path:  sf.path,
 		Usage: make([]uint64, len(sf.usage)),
+
+		WritePolicy: sf.writePolicy,
+		Tier:        sf.tier,
+		Network:     sf.network,
 	}
 	copy(ssf.Usage, sf.usage)
 	return ssf
@@ -72,69 +82,204 @@ func (cm *ContractManager) loadSettings() error {
 
 	// Copy the saved settings into the contract manager.
 	cm.sectorSalt = ss.SectorSalt
-	for i := range ss.StorageFolders {
-		sf := new(storageFolder)
-		sf.index = ss.StorageFolders[i].Index
-		sf.path = ss.StorageFolders[i].Path
-		sf.usage = ss.StorageFolders[i].Usage
-		sf.metadataFile, err = cm.dependencies.openFile(filepath.Join(ss.StorageFolders[i].Path, metadataFile), os.O_RDWR, 0700)
-		if err != nil {
-			cm.log.Printf("ERROR: unable to open the %v sector metadata file: %v\n", sf.path, err)
-			continue
-		}
-		sf.sectorFile, err = cm.dependencies.openFile(filepath.Join(ss.StorageFolders[i].Path, sectorFile), os.O_RDWR, 0700)
+	cm.scan.lastScan = ss.LastScan
+	for _, ssf := range ss.StorageFolders {
+		sf, err := cm.openStorageFolder(ssf)
 		if err != nil {
-			cm.log.Printf("ERROR: unable to open the %v sector file: %v\n", sf.path, err)
-			sf.metadataFile.Close()
+			// The folder is kept in the settings, so that it can be moved to
+			// the new location of its files.
+			cm.log.Printf("ERROR: unable to open storage folder %v, marking it as missing: %v\n", ssf.Path, err)
+			cm.missingFolders[ssf.Index] = ssf
 			continue
 		}
-		sf.availableSectors = make(map[sectorID]uint32)
 		cm.storageFolders[sf.index] = sf
 	}
 	return nil
 }
 
+// openStorageFolder opens the files of a saved storage folder. The sector
+// locations of the folder still need to be loaded.
+func (cm *ContractManager) openStorageFolder(ssf savedStorageFolder) (*storageFolder, error) {
+	sf := &storageFolder{
+		index:       ssf.Index,
+		path:        ssf.Path,
+		usage:       ssf.Usage,
+		writePolicy: ssf.WritePolicy,
+		tier:        ssf.Tier,
+		network:     ssf.Network,
+
+		availableSectors: make(map[sectorID]uint32),
+		writeQueue:       make(chan struct{}, folderWriteConcurrency),
+	}
+	var err error
+	sf.metadataFile, err = cm.dependencies.openFile(filepath.Join(sf.path, metadataFile), os.O_RDWR, 0700)
+	if err != nil {
+		return nil, build.ExtendErr("unable to open the sector metadata file", err)
+	}
+	sf.sectorFile, err = cm.dependencies.openFile(filepath.Join(sf.path, sectorFile), os.O_RDWR, 0700)
+	if err != nil {
+		sf.metadataFile.Close()
+		return nil, build.ExtendErr("unable to open the sector file", err)
+	}
+	// Folders created before metadata backups were introduced get an empty
+	// backup, which is filled in as the sector locations load.
+	sf.metadataBackupFile, err = cm.dependencies.openFile(filepath.Join(sf.path, metadataBackupFile), os.O_RDWR|os.O_CREATE, 0700)
+	if err != nil {
+		cm.log.Printf("WARN: unable to open the %v backup sector metadata file, continuing without a backup: %v\n", sf.path, err)
+		sf.metadataBackupFile = nil
+	}
+	// Network folders added before lock files were introduced are claimed
+	// now. A folder claimed by another host is still loaded, as refusing it
+	// would lose the sectors stored in it.
+	if sf.network {
+		_, err = cm.claimStorageFolder(sf.path)
+		if err != nil {
+			cm.log.Printf("WARN: unable to claim storage folder %v: %v\n", sf.path, err)
+		}
+	}
+	return sf, nil
+}
+
 // loadSectorLocations will read the metadata portion of each storage folder
-// file and load the sector location information into memory.
+// file and load the sector location information into memory. Each sector
+// marked as in use is checked against its metadata: a sector with a reference
+// count of zero, or a sector that is also stored at another location, is
+// dropped and its slot is freed, so that a corrupt metadata file does not
+// leave phantom sectors or leak capacity.
+//
+// The metadata is first checked against the backup metadata of the folder.
+// Where the two disagree, an entry in the backup with a valid checksum is
+// trusted and the metadata file is repaired from it. Where the backup entry is
+// damaged or missing, the backup is repaired from the metadata file.
 func (cm *ContractManager) loadSectorLocations() {
 	// Each storage folder houses separate sector location data.
 	for _, sf := range cm.storageFolders {
-		// Read the sector lookup table for this storage folder into memory.
-		sectorLookupBytes, err := readFullMetadata(sf.metadataFile, len(sf.usage)*storageFolderGranularity)
+		cm.loadStorageFolderSectors(sf)
+	}
+}
+
+// loadStorageFolderSectors loads the sector location information of a single
+// storage folder into memory, see loadSectorLocations.
+func (cm *ContractManager) loadStorageFolderSectors(sf *storageFolder) {
+	start := time.Now()
+
+	// Read the sector lookup table and its backup for this storage folder
+	// into memory. The folder can be loaded as long as one of the two can
+	// be read.
+	sectorLookupBytes, err := readFullMetadata(sf.metadataFile, len(sf.usage)*storageFolderGranularity)
+	if err != nil {
+		cm.log.Printf("ERROR: unable to read sector metadata for folder %v: %v\n", sf.path, err)
+		atomic.AddUint64(&sf.atomicFailedReads, 1)
+	} else {
+		atomic.AddUint64(&sf.atomicSuccessfulReads, 1)
+	}
+	var backupBytes []byte
+	if sf.metadataBackupFile != nil {
+		backupBytes, err = readFullMetadataBackup(sf.metadataBackupFile, len(sf.usage)*storageFolderGranularity)
 		if err != nil {
-			cm.log.Printf("ERROR: unable to read sector metadata for folder %v: %v\n", sf.path, err)
+			cm.log.Printf("ERROR: unable to read backup sector metadata for folder %v: %v\n", sf.path, err)
 			atomic.AddUint64(&sf.atomicFailedReads, 1)
-			continue
 		}
-		atomic.AddUint64(&sf.atomicSuccessfulReads, 1)
+	}
+	if sectorLookupBytes == nil && backupBytes == nil {
+		return
+	}
+
+	// Iterate through the sectors that are in-use and read their storage
+	// locations into memory.
+	sf.sectors = 0 // may be non-zero from WAL operations - they will be double counted here if not reset.
+	inUse := usageSectors(sf.usage)
+	var dropped, repaired, backupRepaired int
+	for i, sectorIndex := range inUse {
+		if i > 0 && i%sectorLoadProgressInterval == 0 {
+			cm.log.Printf("INFO: loaded %v of %v sectors from storage folder %v\n", i, len(inUse), sf.path)
+		}
 
-		// Iterate through the sectors that are in-use and read their storage
-		// locations into memory.
-		sf.sectors = 0 // may be non-zero from WAL operations - they will be double counted here if not reset.
-		for _, sectorIndex := range usageSectors(sf.usage) {
+		// Every sector in use is counted, clearUsage uncounts the ones that
+		// are dropped.
+		sf.sectors++
+		var id sectorID
+		var count uint16
+		if sectorLookupBytes != nil {
 			readHead := sectorMetadataDiskSize * sectorIndex
-			var id sectorID
 			copy(id[:], sectorLookupBytes[readHead:readHead+12])
-			count := binary.LittleEndian.Uint16(sectorLookupBytes[readHead+12 : readHead+14])
-			sl := sectorLocation{
-				index:         sectorIndex,
-				storageFolder: sf.index,
-				count:         count,
+			count = binary.LittleEndian.Uint16(sectorLookupBytes[readHead+12 : readHead+14])
+		}
+
+		// Check the metadata against the backup, repairing whichever copy
+		// is damaged.
+		if backupBytes != nil {
+			backupID, backupCount, ok := parseSectorMetadataBackup(backupBytes, sectorIndex)
+			if ok && (sectorLookupBytes == nil || backupID != id || backupCount != count) {
+				id, count = backupID, backupCount
+				err = writeSectorMetadata(sf.metadataFile, sectorIndex, id, count)
+				if err != nil {
+					cm.log.Printf("ERROR: unable to repair metadata of sector %v of storage folder %v: %v\n", sectorIndex, sf.path, err)
+				}
+				repaired++
+			} else if !ok && sectorLookupBytes != nil {
+				putSectorMetadataBackup(backupBytes, sectorIndex, id, count)
+				backupRepaired++
 			}
+		}
 
-			// Add the sector to the sector location map.
-			cm.sectorLocations[id] = sl
-			sf.sectors++
+		sl := sectorLocation{
+			index:         sectorIndex,
+			storageFolder: sf.index,
+			count:         count,
 		}
+
+		// A sector in use must be referenced at least once.
+		if count == 0 {
+			cm.log.Printf("WARN: sector %v of storage folder %v is marked as in use, but has no references; freeing it\n", sectorIndex, sf.path)
+			sf.clearUsage(sectorIndex)
+			dropped++
+			continue
+		}
+		// A sector is stored at most once. If it was already loaded from
+		// another location, the location with more references is kept.
+		if existing, exists := cm.sectorLocations[id]; exists {
+			cm.log.Printf("WARN: sector %x is stored in both storage folder %v and %v; keeping one copy\n", id, cm.storageFolders[existing.storageFolder].path, sf.path)
+			dropped++
+			if existing.count >= count {
+				sf.clearUsage(sectorIndex)
+				continue
+			}
+			cm.storageFolders[existing.storageFolder].clearUsage(existing.index)
+		}
+
+		// Add the sector to the sector location map.
+		cm.sectorLocations[id] = sl
+	}
+	// Damaged backup entries are repaired in memory and written out
+	// together, as a folder from before backups existed has no valid
+	// entries at all.
+	if backupRepaired > 0 {
+		_, err = sf.metadataBackupFile.WriteAt(backupBytes, 0)
+		if err != nil {
+			cm.log.Printf("ERROR: unable to repair the backup sector metadata of storage folder %v: %v\n", sf.path, err)
+		}
+	}
+	if repaired > 0 {
+		cm.log.Printf("WARN: repaired the metadata of %v sectors in storage folder %v from the backup\n", repaired, sf.path)
+	}
+	if dropped > 0 {
+		cm.log.Printf("WARN: dropped %v inconsistent sectors from storage folder %v\n", dropped, sf.path)
+	}
+	if len(inUse) >= sectorLoadProgressInterval {
+		cm.log.Printf("INFO: loaded %v sectors from storage folder %v in %v\n", sf.sectors, sf.path, time.Since(start))
 	}
 }
 
 // savedSettings returns the settings of the contract manager in an
 // easily-serializable form.
 func (cm *ContractManager) savedSettings() savedSettings {
+	cm.scan.mu.Lock()
 	ss := savedSettings{
 		SectorSalt: cm.sectorSalt,
+		LastScan:   cm.scan.lastScan,
 	}
+	cm.scan.mu.Unlock()
 	for _, sf := range cm.storageFolders {
 		// Unset all of the usage bits in the storage folder for the queued sectors.
 		for _, sectorIndex := range sf.availableSectors {
@@ -149,5 +294,8 @@ func (cm *ContractManager) savedSettings() savedSettings {
 			sf.setUsage(sectorIndex)
 		}
 	}
+	for _, ssf := range cm.missingFolders {
+		ss.StorageFolders = append(ss.StorageFolders, ssf)
+	}
 	return ss
 }
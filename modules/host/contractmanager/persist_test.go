@@ -0,0 +1,198 @@
+package contractmanager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/fastrand"
+)
+
+// TestLoadSectorLocationsCorrupt checks that a sector whose metadata has been
+// corrupted to a reference count of zero is dropped at startup, and that its
+// slot is freed, while the other sectors in the folder are loaded.
+func TestLoadSectorLocationsCorrupt(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester("TestLoadSectorLocationsCorrupt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	storageFolderDir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	err = os.MkdirAll(storageFolderDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cmt.cm.AddStorageFolder(storageFolderDir, modules.SectorSize*64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var roots []crypto.Hash
+	for i := 0; i < 3; i++ {
+		root, data := randSector()
+		err = cmt.cm.AddSector(root, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, root)
+	}
+
+	// Corrupt the metadata of the first sector so that it has no references.
+	// Both copies of the metadata are corrupted, as otherwise the metadata
+	// would be repaired from the backup.
+	corruptID := cmt.cm.managedSectorID(roots[0])
+	sl := cmt.cm.sectorLocations[corruptID]
+	err = cmt.cm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(filepath.Join(storageFolderDir, metadataFile), os.O_RDWR, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writeSectorMetadata(f, sl.index, corruptID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err = os.OpenFile(filepath.Join(storageFolderDir, metadataBackupFile), os.O_RDWR, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writeSectorMetadataBackup(f, sl.index, corruptID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmt.cm, err = New(filepath.Join(cmt.persistDir, modules.ContractManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cmt.cm.sectorLocations) != 2 {
+		t.Fatal("expected 2 sectors to be loaded, got", len(cmt.cm.sectorLocations))
+	}
+	if _, err := cmt.cm.ReadSector(roots[0]); err == nil {
+		t.Fatal("corrupt sector should not be readable")
+	}
+	for _, root := range roots[1:] {
+		if _, err := cmt.cm.ReadSector(root); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sfs := cmt.cm.StorageFolders()
+	if sfs[0].Capacity != sfs[0].CapacityRemaining+2*modules.SectorSize {
+		t.Fatal("slot of the corrupt sector was not freed:", sfs[0].Capacity, sfs[0].CapacityRemaining)
+	}
+	for _, sf := range cmt.cm.storageFolders {
+		if sf.sectors != 2 {
+			t.Fatal("dropped sector was counted by the storage folder:", sf.sectors)
+		}
+	}
+}
+
+// TestLoadSectorLocationsRepair checks that damage to either copy of the
+// sector metadata is repaired from the other copy at startup.
+func TestLoadSectorLocationsRepair(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester("TestLoadSectorLocationsRepair")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	storageFolderDir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	err = os.MkdirAll(storageFolderDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cmt.cm.AddStorageFolder(storageFolderDir, modules.SectorSize*64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var roots []crypto.Hash
+	for i := 0; i < 3; i++ {
+		root, data := randSector()
+		err = cmt.cm.AddSector(root, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, root)
+	}
+	sl0 := cmt.cm.sectorLocations[cmt.cm.managedSectorID(roots[0])]
+	sl1 := cmt.cm.sectorLocations[cmt.cm.managedSectorID(roots[1])]
+	err = cmt.cm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Overwrite the metadata of the first sector with garbage, and the backup
+	// metadata of the second sector.
+	for _, c := range []struct {
+		name  string
+		size  int64
+		index uint32
+	}{
+		{metadataFile, sectorMetadataDiskSize, sl0.index},
+		{metadataBackupFile, sectorMetadataBackupDiskSize, sl1.index},
+	} {
+		f, err := os.OpenFile(filepath.Join(storageFolderDir, c.name), os.O_RDWR, 0700)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = f.WriteAt(fastrand.Bytes(int(c.size)), c.size*int64(c.index))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// All sectors should load, and both copies of the metadata should be
+	// repaired.
+	cmt.cm, err = New(filepath.Join(cmt.persistDir, modules.ContractManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, root := range roots {
+		if _, err := cmt.cm.ReadSector(root); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmt.cm.wal.mu.Lock()
+	sf := cmt.cm.storageFolders[sl0.storageFolder]
+	cmt.cm.wal.mu.Unlock()
+	backupBytes, err := readFullMetadataBackup(sf.metadataBackupFile, len(sf.usage)*storageFolderGranularity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := parseSectorMetadataBackup(backupBytes, sl1.index); !ok {
+		t.Fatal("backup metadata was not repaired")
+	}
+	sectorLookupBytes, err := readFullMetadata(sf.metadataFile, len(sf.usage)*storageFolderGranularity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var id sectorID
+	copy(id[:], sectorLookupBytes[sectorMetadataDiskSize*sl0.index:])
+	if id != cmt.cm.managedSectorID(roots[0]) {
+		t.Fatal("metadata was not repaired")
+	}
+}
@@ -0,0 +1,302 @@
+package contractmanager
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/persist"
+	"github.com/NebulousLabs/fastrand"
+)
+
+// The sector salt decides the id of every sector, and the ids are all that
+// the storage folders record about the sectors they hold. Rotating the salt
+// therefore means reading every stored sector, recomputing its Merkle root,
+// and rewriting its metadata under the id derived from the new salt. This can
+// take hours on a large host, so the rotation runs offline and records its
+// progress after every group of storageFolderGranularity sectors. An
+// interrupted rotation is resumed by running it again, and the contract
+// manager refuses to open until the rotation is finished. The sector access
+// statistics are keyed by sector id as well, and are re-keyed alongside the
+// metadata, being saved together with the progress of each group.
+
+var (
+	// errSaltRotationInProgress is returned when a contract manager is opened
+	// while a rotation of its sector salt is unfinished.
+	errSaltRotationInProgress = errors.New("a rotation of the sector salt is unfinished, run the rotation again to complete it")
+
+	// errSaltRotationMismatch is returned when the progress of a salt rotation
+	// does not belong to the settings of the contract manager.
+	errSaltRotationMismatch = errors.New("the progress of the salt rotation does not match the sector salt of the contract manager")
+
+	// errSaltRotationUncleanShutdown is returned when the sector salt of a
+	// contract manager that was not shut down cleanly is rotated.
+	errSaltRotationUncleanShutdown = errors.New("the contract manager was not shut down cleanly, open and close it before rotating the sector salt")
+)
+
+// saltRotation is the progress of a rotation of the sector salt.
+type saltRotation struct {
+	OldSalt crypto.Hash
+	NewSalt crypto.Hash
+
+	// Progress maps the index of each storage folder to the number of its
+	// sector slots that have been re-keyed.
+	Progress map[uint16]uint32
+
+	// stats are the sector access statistics of the contract manager, nil if
+	// it has none. statsIndex maps the id of each sector in stats to its
+	// position, and statsChanged is set when a sector in stats was re-keyed
+	// since they were last saved.
+	stats        *savedAccessStats
+	statsIndex   map[sectorID]int
+	statsPath    string
+	statsChanged bool
+}
+
+// loadAccessStats loads the sector access statistics of the contract manager
+// at persistDir, so that they are re-keyed along with the sectors.
+func (sr *saltRotation) loadAccessStats(persistDir string) error {
+	var sas savedAccessStats
+	sr.statsPath = filepath.Join(persistDir, accessStatsFile)
+	err := persist.LoadFile(accessStatsMetadata, &sas, sr.statsPath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	sr.stats = &sas
+	sr.statsIndex = make(map[sectorID]int, len(sas.Sectors))
+	for i, ssa := range sas.Sectors {
+		sr.statsIndex[ssa.ID] = i
+	}
+	return nil
+}
+
+// rekeyAccessStats moves the access statistics of the sector with oldID to
+// newID.
+func (sr *saltRotation) rekeyAccessStats(oldID, newID sectorID) {
+	i, ok := sr.statsIndex[oldID]
+	if !ok || oldID == newID {
+		return
+	}
+	sr.stats.Sectors[i].ID = newID
+	delete(sr.statsIndex, oldID)
+	sr.statsIndex[newID] = i
+	sr.statsChanged = true
+}
+
+// saveProgress saves the progress of the rotation, along with the re-keyed
+// access statistics if any sector in them was re-keyed.
+func (sr *saltRotation) saveProgress(rotationPath string) error {
+	if sr.statsChanged {
+		err := persist.SaveFileSync(accessStatsMetadata, sr.stats, sr.statsPath)
+		if err != nil {
+			return err
+		}
+		sr.statsChanged = false
+	}
+	return persist.SaveFileSync(saltRotationMetadata, sr, rotationPath)
+}
+
+// RotateSectorSalt replaces the sector salt of the contract manager at
+// persistDir with a new random salt, re-keying the sectors in every storage
+// folder. The contract manager must have been shut down cleanly and must not
+// be open during the rotation. If the rotation is interrupted, calling
+// RotateSectorSalt again resumes it.
+//
+// Sectors whose data no longer matches their metadata are left under their
+// old id, where the next scan reports them as corrupt. Settings backed up
+// before the rotation no longer match the storage folders.
+func RotateSectorSalt(persistDir string) error {
+	var ss savedSettings
+	settingsPath := filepath.Join(persistDir, settingsFile)
+	err := persist.LoadFile(settingsMetadata, &ss, settingsPath)
+	if err != nil {
+		return build.ExtendErr("unable to load the contract manager settings", err)
+	}
+	_, err = os.Stat(filepath.Join(persistDir, walFile))
+	if err == nil {
+		return errSaltRotationUncleanShutdown
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	// Start a new rotation, or pick up the progress of an interrupted one.
+	var sr saltRotation
+	rotationPath := filepath.Join(persistDir, saltRotationFile)
+	err = persist.LoadFile(saltRotationMetadata, &sr, rotationPath)
+	if os.IsNotExist(err) {
+		sr = saltRotation{
+			OldSalt:  ss.SectorSalt,
+			Progress: make(map[uint16]uint32),
+		}
+		fastrand.Read(sr.NewSalt[:])
+		err = persist.SaveFileSync(saltRotationMetadata, &sr, rotationPath)
+	}
+	if err != nil {
+		return build.ExtendErr("unable to load the progress of the salt rotation", err)
+	}
+	if sr.Progress == nil {
+		sr.Progress = make(map[uint16]uint32)
+	}
+
+	// The settings are only updated once every storage folder has been
+	// re-keyed. If they already hold the new salt, the rotation was
+	// interrupted while the lock files were being updated.
+	if ss.SectorSalt == sr.OldSalt {
+		err = sr.loadAccessStats(persistDir)
+		if err != nil {
+			return build.ExtendErr("unable to load the sector access statistics", err)
+		}
+		for _, ssf := range ss.StorageFolders {
+			err = sr.rekeyStorageFolder(ssf, rotationPath)
+			if err != nil {
+				return build.ExtendErr("unable to re-key storage folder "+ssf.Path, err)
+			}
+		}
+		ss.SectorSalt = sr.NewSalt
+		err = persist.SaveFileSync(settingsMetadata, &ss, settingsPath)
+		if err != nil {
+			return build.ExtendErr("unable to save the new sector salt", err)
+		}
+	} else if ss.SectorSalt != sr.NewSalt {
+		return errSaltRotationMismatch
+	}
+	for _, ssf := range ss.StorageFolders {
+		err = sr.relockStorageFolder(ssf.Path)
+		if err != nil {
+			return build.ExtendErr("unable to update the lock file of storage folder "+ssf.Path, err)
+		}
+	}
+	return os.Remove(rotationPath)
+}
+
+// rekeyStorageFolder rewrites the metadata of every sector in the storage
+// folder under the new salt, starting from the recorded progress.
+func (sr *saltRotation) rekeyStorageFolder(ssf savedStorageFolder, rotationPath string) error {
+	metadata, err := os.OpenFile(filepath.Join(ssf.Path, metadataFile), os.O_RDWR, 0700)
+	if err != nil {
+		return err
+	}
+	defer metadata.Close()
+	sectors, err := os.Open(filepath.Join(ssf.Path, sectorFile))
+	if err != nil {
+		return err
+	}
+	defer sectors.Close()
+	backup, err := os.OpenFile(filepath.Join(ssf.Path, metadataBackupFile), os.O_RDWR|os.O_CREATE, 0700)
+	if err != nil {
+		return err
+	}
+	defer backup.Close()
+
+	numSectors := len(ssf.Usage) * storageFolderGranularity
+	metadataBytes, err := readFullMetadata(metadata, numSectors)
+	if err != nil {
+		return err
+	}
+	backupBytes, err := readFullMetadataBackup(backup, numSectors)
+	if err != nil {
+		return err
+	}
+
+	for i := int(sr.Progress[ssf.Index]) / storageFolderGranularity; i < len(ssf.Usage); i++ {
+		if ssf.Usage[i] == 0 {
+			continue
+		}
+		for j := uint32(0); j < storageFolderGranularity; j++ {
+			if ssf.Usage[i]&(1<<j) == 0 {
+				continue
+			}
+			sectorIndex := uint32(i)*storageFolderGranularity + j
+			err = sr.rekeySector(metadata, backup, sectors, metadataBytes, backupBytes, sectorIndex)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Make the group durable before recording it as done.
+		err = metadata.Sync()
+		if err != nil {
+			return err
+		}
+		err = backup.Sync()
+		if err != nil {
+			return err
+		}
+		sr.Progress[ssf.Index] = uint32(i+1) * storageFolderGranularity
+		err = sr.saveProgress(rotationPath)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rekeySector rewrites the metadata of a single sector under the new salt. The
+// reference count is taken from the metadata file, or from the backup if the
+// metadata file entry is damaged. A sector that was already re-keyed before
+// an interruption is written again unchanged.
+func (sr *saltRotation) rekeySector(metadata, backup, sectors file, metadataBytes, backupBytes []byte, sectorIndex uint32) error {
+	data, err := readSector(sectors, sectorIndex)
+	if err != nil {
+		return err
+	}
+	root := crypto.MerkleRoot(data)
+	oldID := saltedSectorID(root, sr.OldSalt)
+	newID := saltedSectorID(root, sr.NewSalt)
+
+	var id sectorID
+	entry := metadataBytes[int(sectorIndex)*sectorMetadataDiskSize:][:sectorMetadataDiskSize]
+	copy(id[:], entry[:12])
+	count := binary.LittleEndian.Uint16(entry[12:])
+	if id != oldID && id != newID {
+		var ok bool
+		id, count, ok = parseSectorMetadataBackup(backupBytes, sectorIndex)
+		if !ok || (id != oldID && id != newID) {
+			// The data does not match the sector, leave it to the scan.
+			return nil
+		}
+	}
+
+	err = writeSectorMetadata(metadata, sectorIndex, newID, count)
+	if err != nil {
+		return err
+	}
+	err = writeSectorMetadataBackup(backup, sectorIndex, newID, count)
+	if err != nil {
+		return err
+	}
+	sr.rekeyAccessStats(oldID, newID)
+	return nil
+}
+
+// relockStorageFolder replaces the lock file identifier of the old salt in the
+// folder at path with the identifier of the new salt. A folder without a lock
+// file is claimed when the contract manager next opens it.
+func (sr *saltRotation) relockStorageFolder(path string) error {
+	lockPath := filepath.Join(path, lockFile)
+	id, err := ioutil.ReadFile(lockPath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !bytes.Equal(id, saltLockID(sr.OldSalt)) {
+		return nil
+	}
+	f, err := os.OpenFile(lockPath, os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(saltLockID(sr.NewSalt))
+	if err == nil {
+		err = f.Sync()
+	}
+	return build.ComposeErrors(err, f.Close())
+}
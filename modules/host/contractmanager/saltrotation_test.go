@@ -0,0 +1,135 @@
+package contractmanager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
+)
+
+// TestRotateSectorSalt checks that the sectors of a contract manager can be
+// read after its sector salt is rotated, along with their access statistics,
+// and that a contract manager with an unfinished rotation is not opened.
+func TestRotateSectorSalt(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester("TestRotateSectorSalt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	storageFolderDir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	err = os.MkdirAll(storageFolderDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cmt.cm.AddStorageFolder(storageFolderDir, modules.SectorSize*storageFolderGranularity*2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var roots []crypto.Hash
+	var datas [][]byte
+	for i := 0; i < 3; i++ {
+		root, data := randSector()
+		err = cmt.cm.AddSector(root, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, root)
+		datas = append(datas, data)
+	}
+	// Add a virtual sector, so that the reference count is carried over.
+	err = cmt.cm.AddSector(roots[0], datas[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Read a sector, so that its access statistics are carried over.
+	_, err = cmt.cm.ReadSector(roots[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	persistDir := cmt.cm.persistDir
+	oldSalt := cmt.cm.sectorSalt
+	err = cmt.cm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = RotateSectorSalt(persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm, err := New(persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cm.sectorSalt == oldSalt {
+		t.Fatal("sector salt was not rotated")
+	}
+	for i, root := range roots {
+		data, err := cm.ReadSector(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != string(datas[i]) {
+			t.Fatal("wrong sector data after the rotation")
+		}
+	}
+	if sl := cm.sectorLocations[cm.managedSectorID(roots[0])]; sl.count != 2 {
+		t.Fatal("reference count was not carried over:", sl.count)
+	}
+	for _, root := range roots {
+		if _, ok := cm.access.added[cm.managedSectorID(root)]; !ok {
+			t.Fatal("add time of a sector was not carried over")
+		}
+	}
+	if _, ok := cm.access.lastRead[cm.managedSectorID(roots[1])]; !ok {
+		t.Fatal("last read time of a sector was not carried over")
+	}
+	newSalt := cm.sectorSalt
+	err = cm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a rotation that was interrupted before any sector was
+	// re-keyed.
+	sr := saltRotation{OldSalt: newSalt, Progress: make(map[uint16]uint32)}
+	sr.NewSalt[0] = 1
+	err = persist.SaveFileSync(saltRotationMetadata, &sr, filepath.Join(persistDir, saltRotationFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = New(persistDir)
+	if err == nil {
+		t.Fatal("contract manager opened during a salt rotation")
+	}
+	err = RotateSectorSalt(persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm, err = New(persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cm.Close()
+	if cm.sectorSalt != sr.NewSalt {
+		t.Fatal("interrupted salt rotation was not resumed")
+	}
+	for i, root := range roots {
+		data, err := cm.ReadSector(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != string(datas[i]) {
+			t.Fatal("wrong sector data after the resumed rotation")
+		}
+	}
+	if _, err := os.Stat(filepath.Join(persistDir, saltRotationFile)); !os.IsNotExist(err) {
+		t.Fatal("progress of the salt rotation was not removed:", err)
+	}
+}
@@ -3,8 +3,11 @@ package contractmanager
 import (
 	"encoding/binary"
 	"errors"
+	"hash/crc32"
+	"io"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
@@ -107,6 +110,64 @@ func writeSectorMetadata(f file, sectorIndex uint32, id sectorID, count uint16)
 	return nil
 }
 
+// metadataChecksum returns the checksum of the backup metadata of a sector.
+// The sector index is part of the checksum, so that an entry which was written
+// to the wrong place in the file is not mistaken for a valid entry.
+func metadataChecksum(sectorIndex uint32, entry []byte) uint32 {
+	var indexBytes [4]byte
+	binary.LittleEndian.PutUint32(indexBytes[:], sectorIndex)
+	checksum := crc32.Update(0, crc32.IEEETable, indexBytes[:])
+	return crc32.Update(checksum, crc32.IEEETable, entry[:sectorMetadataDiskSize])
+}
+
+// readFullMetadataBackup will read a full backup metadata file into memory.
+// The backup file may be shorter than expected, for example if the storage
+// folder was created before backups existed. The missing entries are left
+// zeroed, and fail their checksum.
+func readFullMetadataBackup(f file, numSectors int) ([]byte, error) {
+	backupBytes := make([]byte, numSectors*sectorMetadataBackupDiskSize)
+	_, err := f.ReadAt(backupBytes, 0)
+	if err != nil && err != io.EOF {
+		return nil, build.ExtendErr("unable to read backup metadata file for target storage folder", err)
+	}
+	return backupBytes, nil
+}
+
+// parseSectorMetadataBackup parses the backup metadata of a sector, returning
+// false if the checksum of the entry does not match.
+func parseSectorMetadataBackup(backupBytes []byte, sectorIndex uint32) (id sectorID, count uint16, ok bool) {
+	entry := backupBytes[int(sectorIndex)*sectorMetadataBackupDiskSize:][:sectorMetadataBackupDiskSize]
+	if binary.LittleEndian.Uint32(entry[sectorMetadataDiskSize:]) != metadataChecksum(sectorIndex, entry) {
+		return id, 0, false
+	}
+	copy(id[:], entry[:12])
+	count = binary.LittleEndian.Uint16(entry[12:14])
+	return id, count, true
+}
+
+// putSectorMetadataBackup encodes the backup metadata of a sector into an
+// in-memory copy of the backup metadata file.
+func putSectorMetadataBackup(backupBytes []byte, sectorIndex uint32, id sectorID, count uint16) {
+	entry := backupBytes[int(sectorIndex)*sectorMetadataBackupDiskSize:][:sectorMetadataBackupDiskSize]
+	copy(entry, id[:])
+	binary.LittleEndian.PutUint16(entry[12:], count)
+	binary.LittleEndian.PutUint32(entry[sectorMetadataDiskSize:], metadataChecksum(sectorIndex, entry))
+}
+
+// writeSectorMetadataBackup will write the backup metadata of a sector to
+// disk.
+func writeSectorMetadataBackup(f file, sectorIndex uint32, id sectorID, count uint16) error {
+	entry := make([]byte, sectorMetadataBackupDiskSize)
+	copy(entry, id[:])
+	binary.LittleEndian.PutUint16(entry[12:], count)
+	binary.LittleEndian.PutUint32(entry[sectorMetadataDiskSize:], metadataChecksum(sectorIndex, entry))
+	_, err := f.WriteAt(entry, sectorMetadataBackupDiskSize*int64(sectorIndex))
+	if err != nil {
+		return build.ExtendErr("unable to write in given file", err)
+	}
+	return nil
+}
+
 // sectorID returns the id that should be used when referring to a sector.
 // There are lots of sectors, and to minimize their footprint a reduced size
 // hash is used. Hashes are typically 256bits to provide collision resistance
@@ -118,7 +179,12 @@ func writeSectorMetadata(f file, sectorIndex uint32, id sectorID, count uint16)
 // 2^48. The host however is unlikely to be storing 2^48 sectors, which would
 // be an exabyte of data.
 func (cm *ContractManager) managedSectorID(sectorRoot crypto.Hash) (id sectorID) {
-	saltedRoot := crypto.HashAll(sectorRoot, cm.sectorSalt)
+	return saltedSectorID(sectorRoot, cm.sectorSalt)
+}
+
+// saltedSectorID returns the id of a sector under the provided sector salt.
+func saltedSectorID(sectorRoot crypto.Hash, salt crypto.Hash) (id sectorID) {
+	saltedRoot := crypto.HashAll(sectorRoot, salt)
 	copy(id[:], saltedRoot[:])
 	return id
 }
@@ -148,6 +214,13 @@ func (cm *ContractManager) ReadSector(root crypto.Hash) ([]byte, error) {
 		return nil, ErrSectorNotFound
 	}
 
+	// Serve the sector from the cache if possible. The sector metadata is
+	// checked first so that deleted sectors are never served from the cache.
+	if sectorData, ok := cm.cache.get(id); ok {
+		cm.access.recordRead(id, time.Now())
+		return sectorData, nil
+	}
+
 	// Read the sector.
 	sectorData, err := readSector(sf.sectorFile, sl.index)
 	if err != nil {
@@ -155,6 +228,8 @@ func (cm *ContractManager) ReadSector(root crypto.Hash) ([]byte, error) {
 		return nil, build.ExtendErr("unable to fetch sector", err)
 	}
 	atomic.AddUint64(&sf.atomicSuccessfulReads, 1)
+	cm.access.recordRead(id, time.Now())
+	cm.cache.add(id, sectorData)
 	return sectorData, nil
 }
 
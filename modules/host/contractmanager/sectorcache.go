@@ -0,0 +1,105 @@
+package contractmanager
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// sectorCache is a bounded cache of recently read sectors, so that repeated
+// downloads of popular data are served from memory rather than from disk. When
+// the cache is full, the least recently read sector is evicted. The cache has
+// its own lock, which may be grabbed while holding the WAL lock but not the
+// other way around.
+type sectorCache struct {
+	capacity int
+	entries  map[sectorID]*list.Element
+	order    *list.List // most recently read at the front
+
+	hits   uint64
+	misses uint64
+
+	mu sync.Mutex
+}
+
+// sectorCacheEntry is an element of the cache's eviction list.
+type sectorCacheEntry struct {
+	id   sectorID
+	data []byte
+}
+
+// newSectorCache returns a sector cache that holds up to capacity sectors. A
+// capacity of zero disables the cache.
+func newSectorCache(capacity int) *sectorCache {
+	return &sectorCache{
+		capacity: capacity,
+		entries:  make(map[sectorID]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// get returns a copy of the cached data of a sector, recording a hit or a
+// miss.
+func (sc *sectorCache) get(id sectorID) ([]byte, bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	elem, ok := sc.entries[id]
+	if !ok {
+		sc.misses++
+		return nil, false
+	}
+	sc.hits++
+	sc.order.MoveToFront(elem)
+	data := elem.Value.(*sectorCacheEntry).data
+	return append([]byte(nil), data...), true
+}
+
+// add caches a copy of the data of a sector, evicting the least recently read
+// sectors if the cache is full.
+func (sc *sectorCache) add(id sectorID, data []byte) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.capacity == 0 {
+		return
+	}
+	if elem, ok := sc.entries[id]; ok {
+		sc.order.MoveToFront(elem)
+		return
+	}
+	sc.entries[id] = sc.order.PushFront(&sectorCacheEntry{
+		id:   id,
+		data: append([]byte(nil), data...),
+	})
+	for sc.order.Len() > sc.capacity {
+		oldest := sc.order.Back()
+		sc.order.Remove(oldest)
+		delete(sc.entries, oldest.Value.(*sectorCacheEntry).id)
+	}
+}
+
+// remove drops a sector from the cache.
+func (sc *sectorCache) remove(id sectorID) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if elem, ok := sc.entries[id]; ok {
+		sc.order.Remove(elem)
+		delete(sc.entries, id)
+	}
+}
+
+// stats returns the hit statistics of the cache.
+func (sc *sectorCache) stats() modules.SectorCacheStats {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	s := modules.SectorCacheStats{
+		Capacity: uint64(sc.capacity),
+		Sectors:  uint64(sc.order.Len()),
+		Hits:     sc.hits,
+		Misses:   sc.misses,
+	}
+	if total := sc.hits + sc.misses; total > 0 {
+		s.HitRate = float64(sc.hits) / float64(total)
+	}
+	return s
+}
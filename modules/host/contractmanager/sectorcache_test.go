@@ -0,0 +1,108 @@
+package contractmanager
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestSectorCache checks that the sector cache evicts the least recently read
+// sector and counts hits and misses.
+func TestSectorCache(t *testing.T) {
+	sc := newSectorCache(2)
+	a, b, c := sectorID{1}, sectorID{2}, sectorID{3}
+	sc.add(a, []byte{1})
+	sc.add(b, []byte{2})
+
+	// Reading a makes b the least recently read sector, so adding c evicts b.
+	if data, ok := sc.get(a); !ok || !bytes.Equal(data, []byte{1}) {
+		t.Fatal("cached sector not returned:", data, ok)
+	}
+	sc.add(c, []byte{3})
+	if _, ok := sc.get(b); ok {
+		t.Fatal("least recently read sector was not evicted")
+	}
+	if _, ok := sc.get(a); !ok {
+		t.Fatal("recently read sector was evicted")
+	}
+
+	// Modifying returned data must not modify the cache.
+	data, _ := sc.get(c)
+	data[0] = 0
+	if data, _ := sc.get(c); data[0] != 3 {
+		t.Fatal("cached data was modified through a returned slice")
+	}
+
+	sc.remove(a)
+	s := sc.stats()
+	if s.Capacity != 2 || s.Sectors != 1 || s.Hits != 4 || s.Misses != 1 || s.HitRate != 0.8 {
+		t.Fatalf("wrong cache stats: %+v", s)
+	}
+
+	// A cache without capacity holds nothing.
+	sc = newSectorCache(0)
+	sc.add(a, []byte{1})
+	if _, ok := sc.get(a); ok {
+		t.Fatal("disabled cache returned a sector")
+	}
+}
+
+// TestReadSectorCache checks that repeated reads of a sector are served from
+// the cache, and that deleted sectors are not.
+func TestReadSectorCache(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	storageFolderDir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	err = os.MkdirAll(storageFolderDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cmt.cm.AddStorageFolder(storageFolderDir, modules.SectorSize*64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, data := randSector()
+	err = cmt.cm.AddSector(root, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		readData, err := cmt.cm.ReadSector(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(readData, data) {
+			t.Fatal("wrong sector data returned")
+		}
+	}
+	s := cmt.cm.SectorAccessStats().Cache
+	if s.Hits != 2 || s.Misses != 1 || s.Sectors != 1 {
+		t.Fatalf("wrong cache stats: %+v", s)
+	}
+	if sfs := cmt.cm.StorageFolders(); sfs[0].SuccessfulReads != 1 {
+		t.Fatal("cached reads should not touch the disk:", sfs[0].SuccessfulReads)
+	}
+
+	err = cmt.cm.DeleteSector(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cmt.cm.ReadSector(root); err != ErrSectorNotFound {
+		t.Fatal("deleted sector was served:", err)
+	}
+	if s := cmt.cm.SectorAccessStats().Cache; s.Sectors != 0 {
+		t.Fatal("deleted sector was not dropped from the cache")
+	}
+}
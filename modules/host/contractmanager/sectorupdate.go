@@ -2,8 +2,10 @@ package contractmanager
 
 import (
 	"errors"
+	"sort"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
@@ -63,7 +65,7 @@ func (wal *writeAheadLog) managedAddPhysicalSector(id sectorID, data []byte, cou
 			// Grab a vacant storage folder.
 			wal.mu.Lock()
 			var sf *storageFolder
-			sf, storageFolderIndex = vacancyStorageFolder(storageFolders)
+			sf, storageFolderIndex = vacancyStorageFolder(storageFolders, modules.StorageFolderTierFast)
 			if sf == nil {
 				// None of the storage folders have enough room to house the
 				// sector.
@@ -85,20 +87,40 @@ func (wal *writeAheadLog) managedAddPhysicalSector(id sectorID, data []byte, cou
 			// Set the usage, but mark it as uncommitted.
 			sf.setUsage(sectorIndex)
 			sf.availableSectors[id] = sectorIndex
+			policy := sf.writePolicy
+			sf.reserveWrite()
 			wal.mu.Unlock()
 
+			// Wait for a turn to write to the storage folder. Only the writes
+			// to the same folder queue up behind each other, writes to other
+			// folders proceed in parallel.
+			sf.managedWaitWriteQueue()
+			defer sf.releaseWrite()
+
 			// NOTE: The usage has been set, in the event of failure the usage
-			// must be cleared.
+			// must be cleared. If a write stalls, the usage is instead cleared
+			// once the stalled write returns, so that the sector slot is not
+			// handed out while an abandoned write may still land in it.
+			abandon := func() {
+				wal.mu.Lock()
+				sf.clearUsage(sectorIndex)
+				delete(sf.availableSectors, id)
+				wal.mu.Unlock()
+			}
 
 			// Try writing the new sector to disk.
-			err = writeSector(sf.sectorFile, sectorIndex, data)
+			err = sf.managedPolicyWrite(policy, func() error {
+				return writeSector(sf.sectorFile, sectorIndex, data)
+			}, abandon)
+			if err == errStorageFolderStalled {
+				wal.cm.log.Printf("WARN: Write of sector to folder %v has stalled, placing the sector elsewhere\n", sf.path)
+				atomic.AddUint64(&sf.atomicFailedWrites, 1)
+				return errDiskTrouble
+			}
 			if err != nil {
 				wal.cm.log.Printf("ERROR: Unable to write sector for folder %v: %v\n", sf.path, err)
 				atomic.AddUint64(&sf.atomicFailedWrites, 1)
-				wal.mu.Lock()
-				sf.clearUsage(sectorIndex)
-				delete(sf.availableSectors, id)
-				wal.mu.Unlock()
+				abandon()
 				return errDiskTrouble
 			}
 
@@ -109,14 +131,18 @@ func (wal *writeAheadLog) managedAddPhysicalSector(id sectorID, data []byte, cou
 				Folder: sf.index,
 				Index:  sectorIndex,
 			}
-			err = wal.writeSectorMetadata(sf, su)
+			err = sf.managedPolicyWrite(policy, func() error {
+				return wal.writeSectorMetadata(sf, su)
+			}, abandon)
+			if err == errStorageFolderStalled {
+				wal.cm.log.Printf("WARN: Write of sector metadata to folder %v has stalled, placing the sector elsewhere\n", sf.path)
+				atomic.AddUint64(&sf.atomicFailedWrites, 1)
+				return errDiskTrouble
+			}
 			if err != nil {
 				wal.cm.log.Printf("ERROR: Unable to write sector metadata for folder %v: %v\n", sf.path, err)
 				atomic.AddUint64(&sf.atomicFailedWrites, 1)
-				wal.mu.Lock()
-				sf.clearUsage(sectorIndex)
-				delete(sf.availableSectors, id)
-				wal.mu.Unlock()
+				abandon()
 				return errDiskTrouble
 			}
 
@@ -195,13 +221,16 @@ func (wal *writeAheadLog) managedAddVirtualSector(id sectorID, location sectorLo
 	err := wal.writeSectorMetadata(sf, su)
 	if err != nil {
 		// Revert the sector update in the WAL to reflect the fact that adding
-		// the sector has failed.
+		// the sector has failed, and wait for the revert to be committed so
+		// that the reference is not counted after an unclean shutdown.
 		su.Count--
+		location.count--
 		wal.mu.Lock()
 		wal.appendChange(stateChange{
 			SectorUpdates: []sectorUpdate{su},
 		})
 		wal.cm.sectorLocations[id] = location
+		syncChan = wal.syncChan
 		wal.mu.Unlock()
 		<-syncChan
 		return build.ExtendErr("unable to write sector metadata during addSector call", err)
@@ -264,8 +293,9 @@ func (wal *writeAheadLog) managedDeleteSector(id sectorID) error {
 }
 
 // managedRemoveSector will remove a sector (virtual or physical) from the
-// contract manager.
-func (wal *writeAheadLog) managedRemoveSector(id sectorID) error {
+// contract manager. The location of the sector is returned, with a count of
+// zero if the physical sector was freed.
+func (wal *writeAheadLog) managedRemoveSector(id sectorID) (sectorLocation, error) {
 	// Inform the WAL of the removed sector.
 	var location sectorLocation
 	var su sectorUpdate
@@ -313,7 +343,7 @@ func (wal *writeAheadLog) managedRemoveSector(id sectorID) error {
 		return nil
 	}()
 	if err != nil {
-		return err
+		return sectorLocation{}, err
 	}
 	// synchronize before updating the metadata or clearing the usage.
 	<-syncChan
@@ -330,8 +360,10 @@ func (wal *writeAheadLog) managedRemoveSector(id sectorID) error {
 				SectorUpdates: []sectorUpdate{su},
 			})
 			wal.cm.sectorLocations[id] = location
+			syncChan = wal.syncChan
 			wal.mu.Unlock()
-			return build.ExtendErr("failed to write sector metadata", err)
+			<-syncChan
+			return sectorLocation{}, build.ExtendErr("failed to write sector metadata", err)
 		}
 	}
 
@@ -345,12 +377,23 @@ func (wal *writeAheadLog) managedRemoveSector(id sectorID) error {
 		delete(sf.availableSectors, id)
 		wal.mu.Unlock()
 	}
-	return nil
+	return location, nil
 }
 
 // writeSectorMetadata will take a sector update and write the related metadata
 // to disk.
 func (wal *writeAheadLog) writeSectorMetadata(sf *storageFolder, su sectorUpdate) error {
+	// The backup is written first. If the write to the metadata file then
+	// fails, the backup holds the newer entry, which is the one that gets
+	// used to repair the metadata file at startup.
+	if sf.metadataBackupFile != nil {
+		err := writeSectorMetadataBackup(sf.metadataBackupFile, su.Index, su.ID, su.Count)
+		if err != nil {
+			wal.cm.log.Printf("ERROR: unable to write backup sector metadata to folder %v when adding sector: %v\n", su.Folder, err)
+			atomic.AddUint64(&sf.atomicFailedWrites, 1)
+			return err
+		}
+	}
 	err := writeSectorMetadata(sf.metadataFile, su.Index, su.ID, su.Count)
 	if err != nil {
 		wal.cm.log.Printf("ERROR: unable to write sector metadata to folder %v when adding sector: %v\n", su.Folder, err)
@@ -361,15 +404,9 @@ func (wal *writeAheadLog) writeSectorMetadata(sf *storageFolder, su sectorUpdate
 	return nil
 }
 
-// AddSector will add a sector to the contract manager.
-func (cm *ContractManager) AddSector(root crypto.Hash, sectorData []byte) error {
-	// Prevent shutdown until this function completes.
-	err := cm.tg.Add()
-	if err != nil {
-		return err
-	}
-	defer cm.tg.Done()
-
+// managedAddSector will add a sector to the contract manager, returning once
+// the sector is durable.
+func (cm *ContractManager) managedAddSector(root crypto.Hash, sectorData []byte) error {
 	// Hold a sector lock throughout the duration of the function, but release
 	// before syncing.
 	id := cm.managedSectorID(root)
@@ -380,6 +417,7 @@ func (cm *ContractManager) AddSector(root crypto.Hash, sectorData []byte) error
 	cm.wal.mu.Lock()
 	location, exists := cm.sectorLocations[id]
 	cm.wal.mu.Unlock()
+	var err error
 	if exists {
 		err = cm.wal.managedAddVirtualSector(id, location)
 	} else {
@@ -389,13 +427,53 @@ func (cm *ContractManager) AddSector(root crypto.Hash, sectorData []byte) error
 		cm.log.Println("ERROR: Unable to add sector:", err)
 		return err
 	}
+	if !exists {
+		cm.access.recordAdd(id, time.Now())
+	}
 	return nil
 }
 
-// AddSectorBatch is a non-ACID call to add a bunch of sectors at once.
-// Necessary for compatibility with old renters.
-//
-// TODO: Make ACID, and definitely improve the performance as well.
+// AddSector will add a sector to the contract manager.
+func (cm *ContractManager) AddSector(root crypto.Hash, sectorData []byte) error {
+	// Prevent shutdown until this function completes.
+	err := cm.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer cm.tg.Done()
+	return cm.managedAddSector(root, sectorData)
+}
+
+// AddSectorAsync will add a sector to the contract manager in the background.
+// The result is sent on the returned channel once the sector is durable in the
+// WAL, and the channel is then closed. sectorData must not be modified until
+// the result has been received. Sectors that are added concurrently share WAL
+// syncs, so a caller that receives many sectors can hand each one off as it
+// arrives and wait for all of them at the end.
+func (cm *ContractManager) AddSectorAsync(root crypto.Hash, sectorData []byte) <-chan error {
+	errChan := make(chan error, 1)
+
+	// Prevent shutdown until the sector has been added.
+	err := cm.tg.Add()
+	if err != nil {
+		errChan <- err
+		close(errChan)
+		return errChan
+	}
+	go func() {
+		defer cm.tg.Done()
+		errChan <- cm.managedAddSector(root, sectorData)
+		close(errChan)
+	}()
+	return errChan
+}
+
+// AddSectorBatch adds a reference to each of the sectors that the contract
+// manager already stores, so that a renewed contract shares the sectors of the
+// contract it renews. Sectors that are not stored are skipped. If any of the
+// references cannot be added, the references that were added are removed
+// again, leaving the reference counts as they were, and the errors are
+// returned together.
 func (cm *ContractManager) AddSectorBatch(sectorRoots []crypto.Hash) error {
 	// Prevent shutdown until this function completes.
 	err := cm.tg.Add()
@@ -404,7 +482,11 @@ func (cm *ContractManager) AddSectorBatch(sectorRoots []crypto.Hash) error {
 	}
 	defer cm.tg.Done()
 
-	// Add each sector in a separate goroutine.
+	// Add each sector in a separate goroutine, so that the additions share
+	// WAL syncs.
+	var mu sync.Mutex
+	var errs []error
+	var added []crypto.Hash
 	var wg sync.WaitGroup
 	for _, root := range sectorRoots {
 		wg.Add(1)
@@ -421,13 +503,45 @@ func (cm *ContractManager) AddSectorBatch(sectorRoots []crypto.Hash) error {
 			cm.wal.mu.Lock()
 			location, exists := cm.sectorLocations[id]
 			cm.wal.mu.Unlock()
-			if exists {
-				cm.wal.managedAddVirtualSector(id, location)
+			if !exists {
+				return
+			}
+			err := cm.wal.managedAddVirtualSector(id, location)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+			} else {
+				added = append(added, root)
 			}
 		}(root)
 	}
 	wg.Wait()
-	return nil
+	if len(errs) == 0 {
+		return nil
+	}
+
+	// Remove the references that were added.
+	for _, root := range added {
+		wg.Add(1)
+		go func(root crypto.Hash) {
+			defer wg.Done()
+			id := cm.managedSectorID(root)
+			cm.wal.managedLockSector(id)
+			defer cm.wal.managedUnlockSector(id)
+
+			_, err := cm.wal.managedRemoveSector(id)
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(root)
+	}
+	wg.Wait()
+	err = build.ComposeErrors(errs...)
+	cm.log.Println("ERROR: Unable to add sector batch:", err)
+	return err
 }
 
 // DeleteSector will delete a sector from the contract manager. If multiple
@@ -443,7 +557,11 @@ func (cm *ContractManager) DeleteSector(root crypto.Hash) error {
 	cm.wal.managedLockSector(id)
 	defer cm.wal.managedUnlockSector(id)
 
-	return cm.wal.managedDeleteSector(id)
+	err := cm.wal.managedDeleteSector(id)
+	if err == nil {
+		cm.managedRecordRemove(id)
+	}
+	return err
 }
 
 // RemoveSector will remove a sector from the contract manager. If multiple
@@ -455,5 +573,72 @@ func (cm *ContractManager) RemoveSector(root crypto.Hash) error {
 	cm.wal.managedLockSector(id)
 	defer cm.wal.managedUnlockSector(id)
 
-	return cm.wal.managedRemoveSector(id)
+	_, err := cm.wal.managedRemoveSector(id)
+	if err == nil {
+		cm.managedRecordRemove(id)
+	}
+	return err
+}
+
+// RemoveSectorBatch removes one instance of each of the sectors, like
+// RemoveSector, and reports the physical sectors that were freed in each
+// storage folder. Sectors that are not found are skipped, and the removal
+// continues past other errors, which are returned together.
+func (cm *ContractManager) RemoveSectorBatch(sectorRoots []crypto.Hash) ([]modules.StorageFolderReclamation, error) {
+	err := cm.tg.Add()
+	if err != nil {
+		return nil, err
+	}
+	defer cm.tg.Done()
+
+	// Remove each sector in a separate goroutine, so that the removals
+	// share WAL syncs.
+	var mu sync.Mutex
+	var errs []error
+	freed := make(map[uint16]uint64)
+	var wg sync.WaitGroup
+	for _, root := range sectorRoots {
+		wg.Add(1)
+		go func(root crypto.Hash) {
+			defer wg.Done()
+			id := cm.managedSectorID(root)
+			cm.wal.managedLockSector(id)
+			defer cm.wal.managedUnlockSector(id)
+
+			location, err := cm.wal.managedRemoveSector(id)
+			if err == ErrSectorNotFound {
+				return
+			} else if err == nil {
+				cm.managedRecordRemove(id)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+			} else if location.count == 0 {
+				freed[location.storageFolder]++
+			}
+		}(root)
+	}
+	wg.Wait()
+
+	var reclamations []modules.StorageFolderReclamation
+	cm.wal.mu.Lock()
+	for index, sectors := range freed {
+		var path string
+		if sf, exists := cm.storageFolders[index]; exists {
+			path = sf.path
+		}
+		reclamations = append(reclamations, modules.StorageFolderReclamation{
+			Index:   index,
+			Path:    path,
+			Sectors: sectors,
+			Bytes:   sectors * modules.SectorSize,
+		})
+	}
+	cm.wal.mu.Unlock()
+	sort.Slice(reclamations, func(i, j int) bool {
+		return reclamations[i].Index < reclamations[j].Index
+	})
+	return reclamations, build.ComposeErrors(errs...)
 }
@@ -176,6 +176,77 @@ func TestAddSector(t *testing.T) {
 	}
 }
 
+// TestAddSectorAsync checks that sectors added asynchronously are durable
+// once their results are received.
+func TestAddSectorAsync(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	storageFolderDir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	err = os.MkdirAll(storageFolderDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cmt.cm.AddStorageFolder(storageFolderDir, modules.SectorSize*64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Hand off a set of sectors, including a duplicate, before waiting for
+	// any of them.
+	roots := make([]crypto.Hash, 8)
+	datas := make([][]byte, 8)
+	for i := range roots {
+		roots[i], datas[i] = randSector()
+	}
+	roots = append(roots, roots[0])
+	datas = append(datas, datas[0])
+	results := make([]<-chan error, len(roots))
+	for i := range roots {
+		results[i] = cmt.cm.AddSectorAsync(roots[i], datas[i])
+	}
+	for _, result := range results {
+		if err := <-result; err != nil {
+			t.Fatal(err)
+		}
+		if _, open := <-result; open {
+			t.Fatal("result channel was not closed")
+		}
+	}
+
+	// The sectors should be readable after a restart.
+	err = cmt.cm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-cmt.cm.AddSectorAsync(roots[0], datas[0]); err == nil {
+		t.Fatal("sector was added after the contract manager was closed")
+	}
+	cmt.cm, err = New(filepath.Join(cmt.persistDir, modules.ContractManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range roots {
+		data, err := cmt.cm.ReadSector(roots[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, datas[i]) {
+			t.Fatal("sector data does not match")
+		}
+	}
+	if s := cmt.cm.SectorAccessStats(); s.Sectors != 8 || s.SectorReferences != 9 {
+		t.Fatal("wrong sector references:", s.Sectors, s.SectorReferences)
+	}
+}
+
 // TestAddSectorFillFolder adds sectors to a 64 sector storage folder until it
 // is full.
 func TestAddSectorFillFolder(t *testing.T) {
@@ -1215,6 +1286,84 @@ func TestRemoveSectorVirtual(t *testing.T) {
 	}
 }
 
+// TestAddSectorBatch checks that a sector batch adds a reference to each of
+// the stored sectors, and that the data is stored once until the last
+// reference is removed.
+func TestAddSectorBatch(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	storageFolderDir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	err = os.MkdirAll(storageFolderDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cmt.cm.AddStorageFolder(storageFolderDir, modules.SectorSize*64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Add a sector, and then a batch that references it twice along with a
+	// sector that is not stored.
+	root, data := randSector()
+	err = cmt.cm.AddSector(root, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing, _ := randSector()
+	err = cmt.cm.AddSectorBatch([]crypto.Hash{root, missing, root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := cmt.cm.SectorAccessStats()
+	if s.Sectors != 1 || s.SectorReferences != 3 || s.DeduplicatedBytes != 2*modules.SectorSize {
+		t.Fatal("wrong sector references:", s.Sectors, s.SectorReferences, s.DeduplicatedBytes)
+	}
+
+	// The references should survive a restart.
+	err = cmt.cm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmt.cm, err = New(filepath.Join(cmt.persistDir, modules.ContractManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := cmt.cm.SectorAccessStats(); s.Sectors != 1 || s.SectorReferences != 3 {
+		t.Fatal("wrong sector references after restart:", s.Sectors, s.SectorReferences)
+	}
+
+	// The sector should only be freed once every reference is removed.
+	for i := 0; i < 3; i++ {
+		sfs := cmt.cm.StorageFolders()
+		if sfs[0].Capacity != sfs[0].CapacityRemaining+modules.SectorSize {
+			t.Fatal("sector freed before its last reference was removed:", i)
+		}
+		_, err = cmt.cm.ReadSector(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = cmt.cm.RemoveSector(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	sfs := cmt.cm.StorageFolders()
+	if sfs[0].Capacity != sfs[0].CapacityRemaining {
+		t.Error("sector was not freed after its last reference was removed")
+	}
+	if _, err = cmt.cm.ReadSector(root); err != ErrSectorNotFound {
+		t.Error("expected ErrSectorNotFound, got", err)
+	}
+}
+
 // TestDeleteSector tries to delete a sector from the contract manager.
 func TestDeleteSector(t *testing.T) {
 	if testing.Short() {
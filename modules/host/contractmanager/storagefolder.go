@@ -4,7 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"sort"
 	"sync/atomic"
+	"time"
 
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/sync"
@@ -67,6 +69,14 @@ var (
 	// that is not a folder.
 	errStorageFolderNotFolder = errors.New("must use an existing folder")
 
+	// errStorageFolderStalled is returned if a write to a storage folder
+	// exceeds the write timeout of the folder.
+	errStorageFolderStalled = errors.New("write to storage folder timed out")
+
+	// errStorageFolderMissing is returned if an operation needs the files of
+	// a storage folder that could not be found at startup.
+	errStorageFolderMissing = errors.New("the files of the storage folder are missing, move the folder to their new location or force its removal")
+
 	// errStorageFolderNotFound is returned if a storage folder cannot be
 	// found.
 	errStorageFolderNotFound = errors.New("could not find storage folder with that id")
@@ -90,11 +100,44 @@ type storageFolder struct {
 	atomicSuccessfulReads  uint64
 	atomicSuccessfulWrites uint64
 
+	// atomicStalledWrites counts the writes which have exceeded the write
+	// timeout of the folder and have not yet returned. atomicUnavailableUntil
+	// is the unix nano timestamp until which no new sectors are placed in the
+	// folder, set when writes fail despite the retries of the write policy.
+	atomicStalledWrites    int64
+	atomicUnavailableUntil int64
+
+	// atomicQueuedWrites counts the new sectors that are waiting in the write
+	// queue of the folder or being written, see storagefolderqueue.go.
+	// writeQueue admits folderWriteConcurrency of them at a time.
+	atomicQueuedWrites int64
+	writeQueue         chan struct{}
+
 	// The index, path, and usage are all saved directly to disk.
 	index uint16
 	path  string
 	usage []uint64
 
+	// writePolicy limits how long writes of new sectors to the folder may
+	// take. The write policy is saved to disk, and is protected by the WAL
+	// lock.
+	writePolicy modules.StorageFolderWritePolicy
+
+	// health is the result of the most recent health check of the disk
+	// backing the folder. It is protected by the WAL lock.
+	health modules.StorageFolderHealth
+
+	// network indicates that the folder was added on a network filesystem.
+	// Only network folders are claimed with a lock file. network is saved to
+	// disk.
+	network bool
+
+	// tier is the placement tier of the folder, see storagefoldertier.go.
+	// The tier is saved to disk, and is protected by the WAL lock. Folders
+	// saved before tiers were introduced have an empty tier, which is the
+	// capacity tier.
+	tier string
+
 	// availableSectors indicates sectors which are marked as consumed in the
 	// usage field but are actually available. They cannot be marked as free in
 	// the usage until the action which freed them has synced to disk, but the
@@ -113,8 +156,13 @@ type storageFolder struct {
 	// An open file handle is kept so that writes can easily be made to the
 	// storage folder without needing to grab a new file handle. This also
 	// makes it easy to do delayed-syncing.
-	metadataFile file
-	sectorFile   file
+	//
+	// metadataBackupFile holds a checksummed copy of the metadata file. It is
+	// nil if the backup could not be opened, in which case the folder runs
+	// without a backup.
+	metadataFile       file
+	metadataBackupFile file
+	sectorFile         file
 }
 
 // mostSignificantBit returns the index of the most significant bit of an input
@@ -198,15 +246,31 @@ func usageSectors(usage []uint64) (usageSectors []uint32) {
 }
 
 // vacancyStorageFolder takes a set of storage folders and returns a storage
-// folder with vacancy for a sector along with its index. 'nil' and '-1' are
-// returned if none of the storage folders are available to accept a sector.
-// The returned storage folder will be holding an RLock on its mutex.
-func vacancyStorageFolder(sfs []*storageFolder) (*storageFolder, int) {
+// folder with vacancy for a sector along with its index. Folders in the
+// provided tier are preferred over the other folders, and within a tier,
+// folders with fewer queued writes are preferred. 'nil' and '-1' are returned
+// if none of the storage folders are available to accept a sector. The
+// returned storage folder will be holding an RLock on its mutex.
+func vacancyStorageFolder(sfs []*storageFolder, tier string) (*storageFolder, int) {
 	enoughRoom := false
 	var winningIndex int
 
-	// Go through the folders in random order.
-	for _, index := range fastrand.Perm(len(sfs)) {
+	// Go through the folders in random order, starting with the folders in
+	// the preferred tier and the shortest write queues.
+	queued := make([]int64, len(sfs))
+	for i, sf := range sfs {
+		queued[i] = sf.queuedWrites()
+	}
+	order := fastrand.Perm(len(sfs))
+	sort.SliceStable(order, func(i, j int) bool {
+		iPreferred := sfs[order[i]].placementTier() == tier
+		jPreferred := sfs[order[j]].placementTier() == tier
+		if iPreferred != jPreferred {
+			return iPreferred
+		}
+		return queued[order[i]] < queued[order[j]]
+	})
+	for _, index := range order {
 		sf := sfs[index]
 
 		// Skip past this storage folder if there is not enough room for at
@@ -215,6 +279,12 @@ func vacancyStorageFolder(sfs []*storageFolder) (*storageFolder, int) {
 			continue
 		}
 
+		// Skip past this storage folder if it's on a stalled mount or has
+		// recently failed.
+		if !sf.acceptingWrites() {
+			continue
+		}
+
 		// Skip past this storage folder if it's not available to receive new
 		// data.
 		if !sf.mu.TryRLock() {
@@ -354,10 +424,34 @@ func (cm *ContractManager) StorageFolders() []modules.StorageFolderMetadata {
 			CapacityRemaining: ((64 * uint64(len(sf.usage))) - sf.sectors) * modules.SectorSize,
 			Index:             sf.index,
 			Path:              sf.path,
+
+			WritePolicy: sf.writePolicy,
+			Stalled:     atomic.LoadInt64(&sf.atomicStalledWrites) > 0,
+			Health:      sf.health,
+			Tier:        sf.placementTier(),
+		}
+		if until := atomic.LoadInt64(&sf.atomicUnavailableUntil); until > time.Now().UnixNano() {
+			sfm.UnavailableUntil = time.Unix(0, until)
 		}
 
 		// Add this storage folder to the list of storage folders.
 		smfs = append(smfs, sfm)
 	}
+	for _, ssf := range cm.missingFolders {
+		tier := ssf.Tier
+		if tier == "" {
+			tier = modules.StorageFolderTierCapacity
+		}
+		smfs = append(smfs, modules.StorageFolderMetadata{
+			Capacity:          modules.SectorSize * 64 * uint64(len(ssf.Usage)),
+			CapacityRemaining: ((64 * uint64(len(ssf.Usage))) - uint64(len(usageSectors(ssf.Usage)))) * modules.SectorSize,
+			Index:             ssf.Index,
+			Path:              ssf.Path,
+
+			WritePolicy: ssf.WritePolicy,
+			Tier:        tier,
+			Missing:     true,
+		})
+	}
 	return smfs
 }
@@ -56,6 +56,12 @@ func (wal *writeAheadLog) cleanupUnfinishedStorageFolderAdditions(scs []stateCha
 			if err != nil {
 				wal.cm.log.Println("Unable to close sector file for storage folder", sf.path)
 			}
+			if sf.metadataBackupFile != nil {
+				err = sf.metadataBackupFile.Close()
+				if err != nil {
+					wal.cm.log.Println("Unable to close backup metadata file for storage folder", sf.path)
+				}
+			}
 
 			// Delete the storage folder from the storage folders map.
 			delete(wal.cm.storageFolders, sf.index)
@@ -72,6 +78,14 @@ func (wal *writeAheadLog) cleanupUnfinishedStorageFolderAdditions(scs []stateCha
 		if err != nil {
 			wal.cm.log.Println("Unable to remove documented sector housing:", sectorHousingName, err)
 		}
+		err = wal.cm.dependencies.removeFile(filepath.Join(usf.Path, metadataBackupFile))
+		if err != nil && !os.IsNotExist(err) {
+			wal.cm.log.Println("Unable to remove documented sector metadata backup:", usf.Path, err)
+		}
+		err = wal.cm.releaseStorageFolder(usf.Path)
+		if err != nil {
+			wal.cm.log.Println("Unable to remove lock file of storage folder:", usf.Path, err)
+		}
 
 		// Append an error call to the changeset, indicating that the storage
 		// folder add was not completed successfully.
@@ -94,6 +108,7 @@ func (wal *writeAheadLog) managedAddStorageFolder(sf *storageFolder) error {
 	sectorLookupSize := numSectors * sectorMetadataDiskSize
 	sectorHousingSize := numSectors * modules.SectorSize
 	sectorLookupName := filepath.Join(sf.path, metadataFile)
+	sectorLookupBackupName := filepath.Join(sf.path, metadataBackupFile)
 	sectorHousingName := filepath.Join(sf.path, sectorFile)
 
 	// Update the uncommitted state to include the storage folder, returning an
@@ -115,9 +130,14 @@ func (wal *writeAheadLog) managedAddStorageFolder(sf *storageFolder) error {
 				return ErrRepeatFolder
 			}
 		}
+		for _, ssf := range wal.cm.missingFolders {
+			if sf.path == ssf.Path {
+				return ErrRepeatFolder
+			}
+		}
 
 		// Check that there is room for another storage folder.
-		if uint64(len(wal.cm.storageFolders)) > maximumStorageFolders {
+		if uint64(len(wal.cm.storageFolders)+len(wal.cm.missingFolders)) > maximumStorageFolders {
 			return errMaxStorageFolders
 		}
 
@@ -129,7 +149,8 @@ func (wal *writeAheadLog) managedAddStorageFolder(sf *storageFolder) error {
 		for iterator = 0; iterator < 65536; iterator++ {
 			// check the list of unique folders we created earlier.
 			_, exists := wal.cm.storageFolders[index]
-			if !exists {
+			_, missing := wal.cm.missingFolders[index]
+			if !exists && !missing {
 				break
 			}
 			index++
@@ -141,16 +162,36 @@ func (wal *writeAheadLog) managedAddStorageFolder(sf *storageFolder) error {
 		// Assign the empty index to the storage folder.
 		sf.index = index
 
-		// Create the files that get used with the storage folder.
+		// Claim a network folder, so that no other host sharing the
+		// filesystem can add it.
 		var err error
+		if sf.network {
+			_, err = wal.cm.claimStorageFolder(sf.path)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Create the files that get used with the storage folder.
 		sf.metadataFile, err = wal.cm.dependencies.createFile(sectorLookupName)
 		if err != nil {
+			err = build.ComposeErrors(err, wal.cm.releaseStorageFolder(sf.path))
+			return build.ExtendErr("could not create storage folder file", err)
+		}
+		sf.metadataBackupFile, err = wal.cm.dependencies.createFile(sectorLookupBackupName)
+		if err != nil {
+			err = build.ComposeErrors(err, sf.metadataFile.Close())
+			err = build.ComposeErrors(err, wal.cm.dependencies.removeFile(sectorLookupName))
+			err = build.ComposeErrors(err, wal.cm.releaseStorageFolder(sf.path))
 			return build.ExtendErr("could not create storage folder file", err)
 		}
 		sf.sectorFile, err = wal.cm.dependencies.createFile(sectorHousingName)
 		if err != nil {
 			err = build.ComposeErrors(err, sf.metadataFile.Close())
+			err = build.ComposeErrors(err, sf.metadataBackupFile.Close())
 			err = build.ComposeErrors(err, wal.cm.dependencies.removeFile(sectorLookupName))
+			err = build.ComposeErrors(err, wal.cm.dependencies.removeFile(sectorLookupBackupName))
+			err = build.ComposeErrors(err, wal.cm.releaseStorageFolder(sf.path))
 			return build.ExtendErr("could not create storage folder file", err)
 		}
 
@@ -197,8 +238,11 @@ func (wal *writeAheadLog) managedAddStorageFolder(sf *storageFolder) error {
 			// Remove the leftover files from the failed operation.
 			err = build.ComposeErrors(err, sf.sectorFile.Close())
 			err = build.ComposeErrors(err, sf.metadataFile.Close())
+			err = build.ComposeErrors(err, sf.metadataBackupFile.Close())
 			err = build.ComposeErrors(err, wal.cm.dependencies.removeFile(sectorLookupName))
+			err = build.ComposeErrors(err, wal.cm.dependencies.removeFile(sectorLookupBackupName))
 			err = build.ComposeErrors(err, wal.cm.dependencies.removeFile(sectorHousingName))
+			err = build.ComposeErrors(err, wal.cm.releaseStorageFolder(sf.path))
 
 			// Signal in the WAL that the unfinished storage folder addition
 			// has failed.
@@ -275,6 +319,9 @@ func (wal *writeAheadLog) commitAddStorageFolder(ssf savedStorageFolder) {
 		if sf.sectorFile != nil {
 			sf.sectorFile.Close()
 		}
+		if sf.metadataBackupFile != nil {
+			sf.metadataBackupFile.Close()
+		}
 	}
 
 	sf = &storageFolder{
@@ -282,7 +329,10 @@ func (wal *writeAheadLog) commitAddStorageFolder(ssf savedStorageFolder) {
 		path:  ssf.Path,
 		usage: ssf.Usage,
 
+		writePolicy:      ssf.WritePolicy,
+		network:          ssf.Network,
 		availableSectors: make(map[sectorID]uint32),
+		writeQueue:       make(chan struct{}, folderWriteConcurrency),
 	}
 
 	var err error
@@ -297,6 +347,11 @@ func (wal *writeAheadLog) commitAddStorageFolder(ssf savedStorageFolder) {
 		sf.metadataFile.Close()
 		return
 	}
+	sf.metadataBackupFile, err = wal.cm.dependencies.openFile(filepath.Join(sf.path, metadataBackupFile), os.O_RDWR|os.O_CREATE, 0700)
+	if err != nil {
+		wal.cm.log.Println("Difficulties opening backup sector metadata file for", sf.path, ":", err)
+		sf.metadataBackupFile = nil
+	}
 	wal.cm.storageFolders[sf.index] = sf
 }
 
@@ -333,12 +388,33 @@ func (cm *ContractManager) AddStorageFolder(path string, size uint64) error {
 		return errStorageFolderNotFolder
 	}
 
-	// Create a storage folder object and add it to the WAL.
+	// Check that the folder can safely hold sectors. Folders on network
+	// filesystems are allowed, but the operator is warned about them.
+	sfp, err := cm.preflightStorageFolder(path)
+	if err != nil {
+		return err
+	}
+	if !sfp.WriteBarrier {
+		return errNoWriteBarrier
+	}
+	for _, warning := range sfp.Warnings {
+		cm.log.Printf("WARN: storage folder %v: %v\n", path, warning)
+	}
+
+	// Create a storage folder object and add it to the WAL. Folders on
+	// network filesystems get a write policy so that a stalled mount does not
+	// block writes to the other folders, and a lock file so that they are not
+	// shared with other hosts.
 	newSF := &storageFolder{
 		path:  path,
 		usage: make([]uint64, size/modules.SectorSize/64),
 
 		availableSectors: make(map[sectorID]uint32),
+		writeQueue:       make(chan struct{}, folderWriteConcurrency),
+	}
+	if sfp.NetworkFilesystem {
+		newSF.network = true
+		newSF.writePolicy = defaultNetworkWritePolicy
 	}
 	err = cm.wal.managedAddStorageFolder(newSF)
 	if err != nil {
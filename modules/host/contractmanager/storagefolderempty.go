@@ -16,8 +16,9 @@ var (
 )
 
 // managedMoveSector will move a sector from its current storage folder to
-// another.
-func (wal *writeAheadLog) managedMoveSector(id sectorID) error {
+// another, preferring folders in the provided tier. If 'tierOnly' is set, the
+// sector is only moved to a folder in the tier.
+func (wal *writeAheadLog) managedMoveSector(id sectorID, tier string, tierOnly bool) error {
 	wal.managedLockSector(id)
 	defer wal.managedUnlockSector(id)
 
@@ -50,6 +51,15 @@ func (wal *writeAheadLog) managedMoveSector(id sectorID) error {
 	// Place the sector into its new folder and add the atomic move to the WAL.
 	wal.mu.Lock()
 	storageFolders := wal.cm.storageFolderSlice()
+	if tierOnly {
+		var tierFolders []*storageFolder
+		for _, sf := range storageFolders {
+			if sf.placementTier() == tier {
+				tierFolders = append(tierFolders, sf)
+			}
+		}
+		storageFolders = tierFolders
+	}
 	wal.mu.Unlock()
 	var syncChan chan struct{}
 	for len(storageFolders) >= 1 {
@@ -63,7 +73,7 @@ func (wal *writeAheadLog) managedMoveSector(id sectorID) error {
 			// Grab a vacant storage folder.
 			wal.mu.Lock()
 			var sf *storageFolder
-			sf, storageFolderIndex = vacancyStorageFolder(storageFolders)
+			sf, storageFolderIndex = vacancyStorageFolder(storageFolders, tier)
 			if sf == nil {
 				// None of the storage folders have enough room to house the
 				// sector.
@@ -167,6 +177,10 @@ func (wal *writeAheadLog) managedEmptyStorageFolder(sfIndex uint16, startingPoin
 	// Grab the storage folder in question.
 	wal.mu.Lock()
 	sf, exists := wal.cm.storageFolders[sfIndex]
+	var tier string
+	if exists {
+		tier = sf.placementTier()
+	}
 	wal.mu.Unlock()
 	if !exists {
 		return 0, errBadStorageFolderIndex
@@ -183,7 +197,8 @@ func (wal *writeAheadLog) managedEmptyStorageFolder(sfIndex uint16, startingPoin
 
 	// Before iterating through the sectors and moving them, set up a thread
 	// pool that can parallelize the transfers without spinning up 250,000
-	// goroutines per TB.
+	// goroutines per TB. Sectors are moved to folders in the same tier where
+	// possible.
 	var errCount uint64
 	var wg sync.WaitGroup
 	workers := 250
@@ -194,7 +209,7 @@ func (wal *writeAheadLog) managedEmptyStorageFolder(sfIndex uint16, startingPoin
 			for {
 				select {
 				case id := <-workChan:
-					err := wal.managedMoveSector(id)
+					err := wal.managedMoveSector(id, tier, false)
 					if err != nil {
 						atomic.AddUint64(&errCount, 1)
 						wal.cm.log.Println("Unable to write sector:", err)
@@ -0,0 +1,272 @@
+package contractmanager
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+const (
+	// maxDiskTemperature is the disk temperature in Celsius above which an
+	// alert is raised. Disks that run hot fail sooner.
+	maxDiskTemperature = 60
+
+	// maxReallocatedSectors is the number of reallocated sectors above which
+	// an alert is raised even if the count is not growing.
+	maxReallocatedSectors = 100
+
+	// minFailedOperations and maxFailedOperationRatio determine when the
+	// error counters of a storage folder raise an alert: at least
+	// minFailedOperations reads or writes must have failed, and they must
+	// make up more than maxFailedOperationRatio of all operations.
+	minFailedOperations     = 10
+	maxFailedOperationRatio = 0.01
+
+	// smartctlTimeout is the amount of time that smartctl is given to report
+	// the health of a disk.
+	smartctlTimeout = 30 * time.Second
+)
+
+var (
+	// healthCheckInterval is the amount of time between health checks of the
+	// disks backing the storage folders. healthCheckStartDelay is the amount
+	// of time between startup and the first health check.
+	healthCheckInterval = build.Select(build.Var{
+		Standard: time.Hour,
+		Dev:      5 * time.Minute,
+		Testing:  24 * time.Hour,
+	}).(time.Duration)
+	healthCheckStartDelay = build.Select(build.Var{
+		Standard: time.Minute,
+		Dev:      10 * time.Second,
+		Testing:  24 * time.Hour,
+	}).(time.Duration)
+
+	// sysBlockDir lists the block devices on Linux, and is used to find the
+	// disk that a partition belongs to.
+	sysBlockDir = "/sys/class/block"
+)
+
+// blockDevice returns the disk that the folder at path is stored on, or an
+// empty string if the folder is not stored on a local block device.
+func blockDevice(path string) string {
+	f, err := os.Open(mountsFile)
+	if err != nil {
+		return ""
+	}
+	device, _ := parseMountEntry(f, path)
+	f.Close()
+	if !strings.HasPrefix(device, "/dev/") {
+		return ""
+	}
+
+	// Resolve links such as /dev/disk/by-uuid, and move from a partition to
+	// the disk that holds it, which is where the SMART attributes live.
+	if resolved, err := filepath.EvalSymlinks(device); err == nil {
+		device = resolved
+	}
+	name := filepath.Base(device)
+	if _, err := os.Stat(filepath.Join(sysBlockDir, name, "partition")); err == nil {
+		if link, err := filepath.EvalSymlinks(filepath.Join(sysBlockDir, name)); err == nil {
+			device = "/dev/" + filepath.Base(filepath.Dir(link))
+		}
+	}
+	return device
+}
+
+// readSMART returns the output of smartctl for the provided device.
+func readSMART(device string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), smartctlTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "smartctl", "-H", "-A", device).Output()
+	// smartctl reports the state of the disk through the bits of its exit
+	// code, so a non-zero exit code still comes with a usable report.
+	if _, ok := err.(*exec.ExitError); ok && len(out) > 0 {
+		err = nil
+	}
+	return string(out), err
+}
+
+// parseRawValue parses the leading integer of a SMART raw value, which may
+// be followed by extra information such as "35 (Min/Max 20/45)".
+func parseRawValue(s string) uint64 {
+	end := 0
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	v, _ := strconv.ParseUint(s[:end], 10, 64)
+	return v
+}
+
+// parseSMART parses the output of 'smartctl -H -A' for ATA, NVMe, and SCSI
+// disks, filling out the SMART fields of the health report and raising alerts
+// for failing attributes.
+func parseSMART(output string) (health modules.StorageFolderHealth) {
+	var temperatureSet bool
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		// Overall health, for ATA and NVMe disks and for SCSI disks
+		// respectively.
+		if i := strings.Index(line, "self-assessment test result:"); i >= 0 {
+			health.SMARTAvailable = true
+			health.SMARTPassed = strings.Contains(line[i:], "PASSED")
+			continue
+		}
+		if strings.HasPrefix(line, "SMART Health Status:") {
+			health.SMARTAvailable = true
+			health.SMARTPassed = strings.HasSuffix(line, "OK")
+			continue
+		}
+
+		// NVMe and SCSI attributes are reported as "Name: value" pairs.
+		if i := strings.Index(line, ":"); i >= 0 {
+			name, value := line[:i], strings.TrimSpace(line[i+1:])
+			switch name {
+			case "Media and Data Integrity Errors":
+				health.UncorrectableErrors += parseRawValue(strings.Replace(value, ",", "", -1))
+			case "Temperature", "Current Drive Temperature":
+				health.Temperature = parseRawValue(value)
+				temperatureSet = true
+			case "Critical Warning":
+				if value != "0x00" {
+					health.Alerts = append(health.Alerts, "disk reports a critical warning of "+value)
+				}
+			}
+			continue
+		}
+
+		// ATA attributes are reported in a table:
+		// ID# ATTRIBUTE_NAME FLAG VALUE WORST THRESH TYPE UPDATED WHEN_FAILED RAW_VALUE
+		fields := strings.Fields(line)
+		if len(fields) < 10 {
+			continue
+		}
+		id, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		raw := parseRawValue(fields[9])
+		switch id {
+		case 5:
+			health.ReallocatedSectors = raw
+		case 187, 198:
+			health.UncorrectableErrors += raw
+		case 197:
+			health.PendingSectors = raw
+		case 190, 194:
+			// Prefer attribute 194 when the disk reports both.
+			if id == 194 || !temperatureSet {
+				health.Temperature = raw
+				temperatureSet = true
+			}
+		}
+		if fields[8] == "FAILING_NOW" {
+			health.Alerts = append(health.Alerts, fmt.Sprintf("SMART attribute %v is failing", fields[1]))
+		}
+	}
+
+	if health.SMARTAvailable && !health.SMARTPassed {
+		health.Alerts = append(health.Alerts, "disk failed its SMART self-assessment")
+	}
+	if health.PendingSectors > 0 {
+		health.Alerts = append(health.Alerts, fmt.Sprintf("%v sectors are waiting to be reallocated", health.PendingSectors))
+	}
+	if health.UncorrectableErrors > 0 {
+		health.Alerts = append(health.Alerts, fmt.Sprintf("disk has reported %v uncorrectable errors", health.UncorrectableErrors))
+	}
+	if health.ReallocatedSectors > maxReallocatedSectors {
+		health.Alerts = append(health.Alerts, fmt.Sprintf("disk has reallocated %v sectors", health.ReallocatedSectors))
+	}
+	if health.Temperature > maxDiskTemperature {
+		health.Alerts = append(health.Alerts, fmt.Sprintf("disk temperature of %v C is too high", health.Temperature))
+	}
+	return health
+}
+
+// checkHealth returns the health of the disk backing the storage folder. The
+// previous health report is used to detect a growing number of reallocated
+// sectors.
+func (sf *storageFolder) checkHealth(path string, prev modules.StorageFolderHealth) modules.StorageFolderHealth {
+	var health modules.StorageFolderHealth
+	device := blockDevice(path)
+	if device != "" {
+		output, err := readSMART(device)
+		if err == nil {
+			health = parseSMART(output)
+		}
+	}
+	health.Device = device
+	health.LastChecked = time.Now()
+	if prev.SMARTAvailable && health.ReallocatedSectors > prev.ReallocatedSectors {
+		health.Alerts = append(health.Alerts, fmt.Sprintf("reallocated sector count grew from %v to %v", prev.ReallocatedSectors, health.ReallocatedSectors))
+	}
+
+	// Check the error counters of the folder itself, which catch problems
+	// that SMART does not report, such as a failing cable or controller.
+	failed := atomic.LoadUint64(&sf.atomicFailedReads) + atomic.LoadUint64(&sf.atomicFailedWrites)
+	total := failed + atomic.LoadUint64(&sf.atomicSuccessfulReads) + atomic.LoadUint64(&sf.atomicSuccessfulWrites)
+	if failed >= minFailedOperations && float64(failed)/float64(total) > maxFailedOperationRatio {
+		health.Alerts = append(health.Alerts, fmt.Sprintf("%v of %v disk operations on the folder have failed", failed, total))
+	}
+	return health
+}
+
+// managedCheckDiskHealth checks the health of the disks backing every storage
+// folder, logging any alerts that were not raised by the previous check.
+func (cm *ContractManager) managedCheckDiskHealth() {
+	cm.wal.mu.Lock()
+	sfs := cm.storageFolderSlice()
+	paths := make([]string, len(sfs))
+	prevs := make([]modules.StorageFolderHealth, len(sfs))
+	for i, sf := range sfs {
+		paths[i], prevs[i] = sf.path, sf.health
+	}
+	cm.wal.mu.Unlock()
+
+	for i, sf := range sfs {
+		health := sf.checkHealth(paths[i], prevs[i])
+		raised := make(map[string]bool)
+		for _, alert := range prevs[i].Alerts {
+			raised[alert] = true
+		}
+		for _, alert := range health.Alerts {
+			if !raised[alert] {
+				cm.log.Printf("WARN: disk health alert for storage folder %v: %v\n", paths[i], alert)
+			}
+		}
+		cm.wal.mu.Lock()
+		sf.health = health
+		cm.wal.mu.Unlock()
+	}
+}
+
+// threadedMonitorDiskHealth periodically checks the health of the disks
+// backing the storage folders.
+func (cm *ContractManager) threadedMonitorDiskHealth() {
+	wait := healthCheckStartDelay
+	for {
+		select {
+		case <-cm.tg.StopChan():
+			return
+		case <-time.After(wait):
+		}
+		if cm.tg.Add() != nil {
+			return
+		}
+		cm.managedCheckDiskHealth()
+		cm.tg.Done()
+		wait = healthCheckInterval
+	}
+}
@@ -0,0 +1,101 @@
+package contractmanager
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestParseSMART checks that the health of ATA, NVMe, and SCSI disks is read
+// from the output of smartctl.
+func TestParseSMART(t *testing.T) {
+	ata := `smartctl 6.6 2016-05-31 r4324 [x86_64-linux-4.10.0] (local build)
+
+=== START OF READ SMART DATA SECTION ===
+SMART overall-health self-assessment test result: PASSED
+
+SMART Attributes Data Structure revision number: 16
+Vendor Specific SMART Attributes with Thresholds:
+ID# ATTRIBUTE_NAME          FLAG     VALUE WORST THRESH TYPE      UPDATED  WHEN_FAILED RAW_VALUE
+  1 Raw_Read_Error_Rate     0x000f   118   099   006    Pre-fail  Always       -       180340368
+  5 Reallocated_Sector_Ct   0x0033   090   090   036    Pre-fail  Always   FAILING_NOW 12
+  9 Power_On_Hours          0x0032   075   075   000    Old_age   Always       -       22187
+187 Reported_Uncorrect      0x0032   100   100   000    Old_age   Always       -       0
+190 Airflow_Temperature_Cel 0x0022   060   045   045    Old_age   Always       -       40 (Min/Max 25/55)
+194 Temperature_Celsius     0x0022   040   055   000    Old_age   Always       -       40 (0 20 0 0 0)
+197 Current_Pending_Sector  0x0012   100   100   000    Old_age   Always       -       8
+198 Offline_Uncorrectable   0x0010   100   100   000    Old_age   Offline      -       0
+`
+	health := parseSMART(ata)
+	if !health.SMARTAvailable || !health.SMARTPassed {
+		t.Fatal("ATA self-assessment not parsed:", health)
+	}
+	if health.ReallocatedSectors != 12 || health.PendingSectors != 8 || health.UncorrectableErrors != 0 || health.Temperature != 40 {
+		t.Fatalf("ATA attributes not parsed: %+v", health)
+	}
+	if len(health.Alerts) != 2 || !strings.Contains(health.Alerts[0], "Reallocated_Sector_Ct") {
+		t.Fatal("unexpected ATA alerts:", health.Alerts)
+	}
+
+	nvme := `=== START OF SMART DATA SECTION ===
+SMART overall-health self-assessment test result: FAILED!
+
+SMART/Health Information (NVMe Log 0x02, NSID 0xffffffff)
+Critical Warning:                   0x04
+Temperature:                        71 Celsius
+Available Spare:                    100%
+Media and Data Integrity Errors:    1,024
+Error Information Log Entries:      3
+`
+	health = parseSMART(nvme)
+	if !health.SMARTAvailable || health.SMARTPassed {
+		t.Fatal("NVMe self-assessment not parsed:", health)
+	}
+	if health.UncorrectableErrors != 1024 || health.Temperature != 71 {
+		t.Fatalf("NVMe attributes not parsed: %+v", health)
+	}
+	// Critical warning, failed self-assessment, uncorrectable errors, and
+	// temperature.
+	if len(health.Alerts) != 4 {
+		t.Fatal("unexpected NVMe alerts:", health.Alerts)
+	}
+
+	scsi := `=== START OF READ SMART DATA SECTION ===
+SMART Health Status: OK
+
+Current Drive Temperature:     30 C
+`
+	health = parseSMART(scsi)
+	if !health.SMARTAvailable || !health.SMARTPassed || health.Temperature != 30 || len(health.Alerts) != 0 {
+		t.Fatalf("SCSI health not parsed: %+v", health)
+	}
+
+	// Output without a self-assessment, as produced when smartctl cannot
+	// access the device, should not report SMART as available.
+	health = parseSMART("Smartctl open device: /dev/sda failed: Permission denied\n")
+	if health.SMARTAvailable || len(health.Alerts) != 0 {
+		t.Fatalf("unavailable SMART data reported as available: %+v", health)
+	}
+}
+
+// TestParseMountEntryDevice checks that the device of the most specific mount
+// containing a path is found.
+func TestParseMountEntryDevice(t *testing.T) {
+	mounts := `/dev/sda1 / ext4 rw,relatime 0 0
+/dev/sdb1 /mnt/disk ext4 rw,relatime 0 0
+server:/export /mnt/disk/nfs nfs4 rw,relatime 0 0
+`
+	tests := []struct {
+		path   string
+		device string
+	}{
+		{"/home/host", "/dev/sda1"},
+		{"/mnt/disk/sia", "/dev/sdb1"},
+		{"/mnt/disk/nfs/sia", "server:/export"},
+	}
+	for _, test := range tests {
+		device, _ := parseMountEntry(strings.NewReader(mounts), test.path)
+		if device != test.device {
+			t.Errorf("expected device %v for %v, got %v", test.device, test.path, device)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package contractmanager
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// A storage folder whose files cannot be opened at startup is kept as a
+// missing folder instead of being dropped, so that a disk which was mounted at
+// a new path does not lose its sectors. Once the folder is moved to the new
+// path, its files are checked, opened, and loaded as if the contract manager
+// had just started. Only missing folders can be moved; the files of a folder
+// in use are open and being written to.
+
+var (
+	// errStorageFolderMoveMismatch is returned if the files at the new path
+	// of a storage folder do not hold the sectors of the folder.
+	errStorageFolderMoveMismatch = errors.New("the files at the new path do not hold the sectors of the storage folder")
+
+	// errStorageFolderNotMissing is returned if a storage folder that is in
+	// use is moved.
+	errStorageFolderNotMissing = errors.New("only missing storage folders can be moved, stop the host and move the files of the folder first")
+)
+
+// verifyMovedStorageFolder checks that the files of the storage folder are
+// found at path. The files must be large enough to hold the folder, must not
+// have been claimed by another host, and a sample of the sectors in use must
+// match the ids in the metadata. A sample is used, as reading every sector of
+// a large folder takes hours. At least half of the sample must match, so that a
+// few corrupt sectors do not prevent the move.
+func (cm *ContractManager) verifyMovedStorageFolder(ssf savedStorageFolder, path string) error {
+	owner, err := cm.readLockFile(path)
+	if err != nil {
+		return err
+	}
+	if owner != nil && !bytes.Equal(owner, cm.lockID()) {
+		return errStorageFolderInUse
+	}
+
+	numSectors := len(ssf.Usage) * storageFolderGranularity
+	info, err := os.Stat(filepath.Join(path, sectorFile))
+	if err != nil {
+		return err
+	}
+	if uint64(info.Size()) < uint64(numSectors)*modules.SectorSize {
+		return errStorageFolderMoveMismatch
+	}
+	metadata, err := cm.dependencies.openFile(filepath.Join(path, metadataFile), os.O_RDONLY, 0700)
+	if err != nil {
+		return err
+	}
+	defer metadata.Close()
+	metadataBytes, err := readFullMetadata(metadata, numSectors)
+	if err != nil {
+		return errStorageFolderMoveMismatch
+	}
+	sectors, err := cm.dependencies.openFile(filepath.Join(path, sectorFile), os.O_RDONLY, 0700)
+	if err != nil {
+		return err
+	}
+	defer sectors.Close()
+
+	// Sample sectors evenly across the folder.
+	inUse := usageSectors(ssf.Usage)
+	samples := storageFolderMoveSamples
+	if len(inUse) < samples {
+		samples = len(inUse)
+	}
+	var matches int
+	for i := 0; i < samples; i++ {
+		sectorIndex := inUse[i*len(inUse)/samples]
+		data, err := readSector(sectors, sectorIndex)
+		if err != nil {
+			continue
+		}
+		var id sectorID
+		copy(id[:], metadataBytes[int(sectorIndex)*sectorMetadataDiskSize:])
+		if cm.managedSectorID(crypto.MerkleRoot(data)) == id {
+			matches++
+		}
+	}
+	if matches*2 < samples {
+		return errStorageFolderMoveMismatch
+	}
+	return nil
+}
+
+// MoveStorageFolder points a missing storage folder at the new path of its
+// files, after checking that the files belong to the folder. The sectors in
+// the folder become available again, and the new path is saved through the
+// WAL.
+func (cm *ContractManager) MoveStorageFolder(index uint16, newPath string) error {
+	err := cm.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer cm.tg.Done()
+
+	if !filepath.IsAbs(newPath) {
+		return errRelativePath
+	}
+	cm.wal.mu.Lock()
+	_, exists := cm.storageFolders[index]
+	ssf, missing := cm.missingFolders[index]
+	for _, sf := range cm.storageFolders {
+		if sf.path == newPath {
+			cm.wal.mu.Unlock()
+			return ErrRepeatFolder
+		}
+	}
+	for _, other := range cm.missingFolders {
+		if other.Index != index && other.Path == newPath {
+			cm.wal.mu.Unlock()
+			return ErrRepeatFolder
+		}
+	}
+	cm.wal.mu.Unlock()
+	if exists {
+		return errStorageFolderNotMissing
+	} else if !missing {
+		return errStorageFolderNotFound
+	}
+
+	// Check and open the files at the new path.
+	err = cm.verifyMovedStorageFolder(ssf, newPath)
+	if err != nil {
+		return err
+	}
+	ssf.Path = newPath
+	sf, err := cm.openStorageFolder(ssf)
+	if err != nil {
+		return err
+	}
+
+	// Load the sectors of the folder, and commit the new path through the
+	// WAL. The folder may have been removed or moved by another call in the
+	// meantime.
+	cm.wal.mu.Lock()
+	if _, missing := cm.missingFolders[index]; !missing {
+		cm.wal.mu.Unlock()
+		sf.metadataFile.Close()
+		sf.sectorFile.Close()
+		if sf.metadataBackupFile != nil {
+			sf.metadataBackupFile.Close()
+		}
+		return errStorageFolderNotFound
+	}
+	delete(cm.missingFolders, index)
+	cm.storageFolders[index] = sf
+	cm.loadStorageFolderSectors(sf)
+	cm.wal.appendChange(stateChange{
+		StorageFolderMoves: []savedStorageFolder{sf.savedStorageFolder()},
+	})
+	syncChan := cm.wal.syncChan
+	cm.wal.mu.Unlock()
+	<-syncChan
+	cm.log.Printf("INFO: storage folder %v moved to %v\n", index, newPath)
+	return nil
+}
+
+// commitStorageFolderMove points a storage folder at its new path.
+// commitStorageFolderMove should only be called during WAL recovery, where the
+// folder is usually missing, as the settings still hold the old path.
+func (wal *writeAheadLog) commitStorageFolderMove(ssf savedStorageFolder) {
+	sf, exists := wal.cm.storageFolders[ssf.Index]
+	if exists && sf.path == ssf.Path {
+		return
+	}
+	moved, err := wal.cm.openStorageFolder(ssf)
+	if err != nil {
+		wal.cm.log.Printf("ERROR: unable to open storage folder %v at its new path: %v\n", ssf.Path, err)
+		if !exists {
+			wal.cm.missingFolders[ssf.Index] = ssf
+		}
+		return
+	}
+	if exists {
+		sf.metadataFile.Close()
+		sf.sectorFile.Close()
+		if sf.metadataBackupFile != nil {
+			sf.metadataBackupFile.Close()
+		}
+	}
+	delete(wal.cm.missingFolders, ssf.Index)
+	wal.cm.storageFolders[ssf.Index] = moved
+}
@@ -0,0 +1,123 @@
+package contractmanager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestMoveStorageFolder checks that a storage folder whose files were moved
+// while the contract manager was closed is kept as missing, and that moving it
+// to the new location of its files restores its sectors.
+func TestMoveStorageFolder(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester("TestMoveStorageFolder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	storageFolderDir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	err = os.MkdirAll(storageFolderDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cmt.cm.AddStorageFolder(storageFolderDir, modules.SectorSize*storageFolderGranularity*2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var roots []crypto.Hash
+	var datas [][]byte
+	for i := 0; i < 5; i++ {
+		root, data := randSector()
+		err = cmt.cm.AddSector(root, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, root)
+		datas = append(datas, data)
+	}
+	index := cmt.cm.StorageFolders()[0].Index
+	if err := cmt.cm.MoveStorageFolder(index, filepath.Join(cmt.persistDir, "other")); err != errStorageFolderNotMissing {
+		t.Fatal("expected errStorageFolderNotMissing, got", err)
+	}
+	persistDir := cmt.cm.persistDir
+	err = cmt.cm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Move the files of the folder while the contract manager is closed.
+	movedDir := filepath.Join(cmt.persistDir, "storageFolderMoved")
+	err = os.Rename(storageFolderDir, movedDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm, err := New(persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sfs := cm.StorageFolders()
+	if len(sfs) != 1 || !sfs[0].Missing || sfs[0].Path != storageFolderDir {
+		t.Fatal("storage folder was not reported as missing:", sfs)
+	}
+	if _, err := cm.ReadSector(roots[0]); err != ErrSectorNotFound {
+		t.Fatal("expected ErrSectorNotFound, got", err)
+	}
+
+	// The move is refused for paths that do not hold the files of the folder.
+	if err := cm.MoveStorageFolder(index, "relative/path"); err != errRelativePath {
+		t.Fatal("expected errRelativePath, got", err)
+	}
+	emptyDir := filepath.Join(cmt.persistDir, "empty")
+	err = os.MkdirAll(emptyDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cm.MoveStorageFolder(index, emptyDir); err == nil {
+		t.Fatal("storage folder was moved to a folder without its files")
+	}
+
+	err = cm.MoveStorageFolder(index, movedDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sfs = cm.StorageFolders()
+	if len(sfs) != 1 || sfs[0].Missing || sfs[0].Path != movedDir {
+		t.Fatal("storage folder was not moved:", sfs)
+	}
+	for i, root := range roots {
+		data, err := cm.ReadSector(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != string(datas[i]) {
+			t.Fatal("wrong sector data after the move")
+		}
+	}
+
+	// The new path is kept across restarts.
+	err = cm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm, err = New(persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sfs = cm.StorageFolders()
+	if len(sfs) != 1 || sfs[0].Missing || sfs[0].Path != movedDir {
+		t.Fatal("new path of the storage folder was not saved:", sfs)
+	}
+	if _, err := cm.ReadSector(roots[0]); err != nil {
+		t.Fatal(err)
+	}
+	err = cm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
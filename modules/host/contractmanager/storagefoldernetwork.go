@@ -0,0 +1,309 @@
+package contractmanager
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/fastrand"
+)
+
+const (
+	// lockFile is the file that is placed inside of a storage folder to
+	// indicate which contract manager is using the folder. It prevents two
+	// hosts from sharing a folder on a network filesystem.
+	lockFile = "siahostlock"
+
+	// preflightFile is the prefix of the temporary file used to test the
+	// write behavior of a folder before it is added. A random suffix is
+	// appended so that concurrent checks of the same folder do not interfere.
+	preflightFile = "siahostpreflight.tmp"
+
+	// preflightRounds is the number of times test data is written and synced
+	// during a preflight check.
+	preflightRounds = 3
+
+	// preflightWriteSize is the amount of test data written in each round of
+	// a preflight check.
+	preflightWriteSize = 1 << 16
+)
+
+var (
+	// maxStorageFolderSyncLatency is the average fsync latency above which a
+	// preflight check warns that the folder is too slow for the host to meet
+	// its obligations reliably.
+	maxStorageFolderSyncLatency = build.Select(build.Var{
+		Standard: 500 * time.Millisecond,
+		Dev:      500 * time.Millisecond,
+		Testing:  time.Second,
+	}).(time.Duration)
+
+	// minNetworkSyncLatency is the average fsync latency below which a sync on
+	// a network filesystem is unlikely to have reached the server, which
+	// suggests that the share is exported or mounted asynchronously.
+	minNetworkSyncLatency = 100 * time.Microsecond
+
+	// mountsFile lists the mounted filesystems on Linux. On other platforms
+	// it does not exist, and the filesystem type is reported as unknown.
+	mountsFile = "/proc/self/mounts"
+
+	// networkFilesystems are the filesystem types that are backed by a remote
+	// server.
+	networkFilesystems = map[string]bool{
+		"9p":         true,
+		"afs":        true,
+		"ceph":       true,
+		"cifs":       true,
+		"davfs":      true,
+		"fuse.s3fs":  true,
+		"fuse.sshfs": true,
+		"glusterfs":  true,
+		"nfs":        true,
+		"nfs4":       true,
+		"smb":        true,
+		"smb2":       true,
+		"smb3":       true,
+		"smbfs":      true,
+	}
+
+	// errNoWriteBarrier is returned if fsync fails in a folder, or if the folder
+	// does not return synced data through an independent file handle.
+	errNoWriteBarrier = errors.New("storage folder failed the write barrier check; fsync failed or data synced to it could not be read back")
+
+	// errStorageFolderInUse is returned if a folder has been claimed by a
+	// different host.
+	errStorageFolderInUse = errors.New("storage folder is already in use by another host")
+)
+
+// parseMounts reads a mounts table in the format of /proc/self/mounts and
+// returns the type of the filesystem with the longest mount point containing
+// path.
+func parseMounts(r io.Reader, path string) string {
+	_, fsType := parseMountEntry(r, path)
+	return fsType
+}
+
+// parseMountEntry reads a mounts table in the format of /proc/self/mounts and
+// returns the device and filesystem type of the mount with the longest mount
+// point containing path.
+func parseMountEntry(r io.Reader, path string) (device, fsType string) {
+	device, fsType, longest := "", "unknown", -1
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 {
+			continue
+		}
+		// Spaces in mount points are escaped as \040.
+		mountPoint := strings.Replace(fields[1], `\040`, " ", -1)
+		rel, err := filepath.Rel(mountPoint, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		// Later mounts shadow earlier mounts at the same point.
+		if len(mountPoint) >= longest {
+			device, fsType, longest = fields[0], fields[2], len(mountPoint)
+		}
+	}
+	return device, fsType
+}
+
+// filesystemType returns the type of the filesystem that path is on, or
+// "unknown" if it cannot be determined.
+func filesystemType(path string) string {
+	f, err := os.Open(mountsFile)
+	if err != nil {
+		return "unknown"
+	}
+	defer f.Close()
+	return parseMounts(f, path)
+}
+
+// lockID returns the identifier that the contract manager writes to the lock
+// file of its storage folders. It is derived from the sector salt, which is
+// unique to each contract manager and is not revealed by the identifier.
+func (cm *ContractManager) lockID() []byte {
+	return saltLockID(cm.sectorSalt)
+}
+
+// saltLockID returns the lock file identifier that belongs to the provided
+// sector salt.
+func saltLockID(salt crypto.Hash) []byte {
+	id := crypto.HashObject(salt)
+	return []byte(hex.EncodeToString(id[:]))
+}
+
+// readLockFile returns the contents of the lock file in the folder at path,
+// or nil if the folder has no lock file.
+func (cm *ContractManager) readLockFile(path string) ([]byte, error) {
+	f, err := cm.dependencies.openFile(filepath.Join(path, lockFile), os.O_RDONLY, 0600)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	buf := make([]byte, 2*crypto.HashSize)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// claimStorageFolder writes the lock file of the folder at path, returning
+// errStorageFolderInUse if the folder has been claimed by another contract
+// manager. Only folders on network filesystems are claimed, as a local folder
+// cannot be reached by another host. The lock file is created exclusively,
+// which is honored by NFSv3 and later as well as SMB. The return value
+// indicates whether the lock file was created by this call.
+func (cm *ContractManager) claimStorageFolder(path string) (bool, error) {
+	f, err := cm.dependencies.openFile(filepath.Join(path, lockFile), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		id, err := cm.readLockFile(path)
+		if err != nil {
+			return false, build.ExtendErr("could not read storage folder lock file", err)
+		}
+		if !bytes.Equal(id, cm.lockID()) {
+			return false, errStorageFolderInUse
+		}
+		return false, nil
+	} else if err != nil {
+		return false, build.ExtendErr("could not create storage folder lock file", err)
+	}
+	_, err = f.Write(cm.lockID())
+	if err == nil {
+		err = f.Sync()
+	}
+	err = build.ComposeErrors(err, f.Close())
+	if err != nil {
+		err = build.ComposeErrors(err, cm.dependencies.removeFile(filepath.Join(path, lockFile)))
+		return false, build.ExtendErr("could not write storage folder lock file", err)
+	}
+	return true, nil
+}
+
+// releaseStorageFolder removes the lock file of the folder at path if it was
+// written by this contract manager.
+func (cm *ContractManager) releaseStorageFolder(path string) error {
+	id, err := cm.readLockFile(path)
+	if err != nil || !bytes.Equal(id, cm.lockID()) {
+		return err
+	}
+	return cm.dependencies.removeFile(filepath.Join(path, lockFile))
+}
+
+// preflightStorageFolder writes, syncs, and reads back test data in the
+// folder at path to measure its latency and verify that fsync succeeds.
+//
+// The read back goes through the page cache, so it only catches filesystems
+// that fail the sync or return different data to a new file handle. It cannot
+// tell whether a server stored the data before acknowledging the sync; the
+// sync latency of network folders is used as a hint for that instead.
+func (cm *ContractManager) preflightStorageFolder(path string) (modules.StorageFolderPreflight, error) {
+	sfp := modules.StorageFolderPreflight{
+		Path:         path,
+		Filesystem:   filesystemType(path),
+		WriteBarrier: true,
+	}
+	sfp.NetworkFilesystem = networkFilesystems[sfp.Filesystem]
+
+	id, err := cm.readLockFile(path)
+	if err != nil {
+		return modules.StorageFolderPreflight{}, build.ExtendErr("could not read storage folder lock file", err)
+	}
+	sfp.InUse = id != nil && !bytes.Equal(id, cm.lockID())
+
+	// Write and sync the test data, reading it back through a separate handle
+	// after each sync.
+	testPath := filepath.Join(path, preflightFile+"_"+hex.EncodeToString(fastrand.Bytes(8)))
+	f, err := cm.dependencies.openFile(testPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return modules.StorageFolderPreflight{}, build.ExtendErr("could not create preflight file", err)
+	}
+	defer func() {
+		f.Close()
+		cm.dependencies.removeFile(testPath)
+	}()
+	var writeTime, syncTime time.Duration
+	for i := 0; i < preflightRounds; i++ {
+		data := fastrand.Bytes(preflightWriteSize)
+		offset := int64(i * preflightWriteSize)
+		start := time.Now()
+		_, err = f.WriteAt(data, offset)
+		if err != nil {
+			return modules.StorageFolderPreflight{}, build.ExtendErr("could not write preflight file", err)
+		}
+		synced := time.Now()
+		err = f.Sync()
+		writeTime += synced.Sub(start)
+		syncTime += time.Since(synced)
+		if err != nil {
+			sfp.WriteBarrier = false
+			continue
+		}
+
+		r, err := cm.dependencies.openFile(testPath, os.O_RDONLY, 0600)
+		if err != nil {
+			return modules.StorageFolderPreflight{}, build.ExtendErr("could not reopen preflight file", err)
+		}
+		readBack := make([]byte, preflightWriteSize)
+		_, err = r.ReadAt(readBack, offset)
+		r.Close()
+		if err != nil || !bytes.Equal(readBack, data) {
+			sfp.WriteBarrier = false
+		}
+	}
+	sfp.WriteLatency = writeTime / preflightRounds
+	sfp.SyncLatency = syncTime / preflightRounds
+
+	if sfp.NetworkFilesystem {
+		sfp.Warnings = append(sfp.Warnings, fmt.Sprintf("folder is on a %v network filesystem; the host will be unable to serve or store data if the connection to the server is lost", sfp.Filesystem))
+		if sfp.SyncLatency < minNetworkSyncLatency {
+			sfp.Warnings = append(sfp.Warnings, "fsync returned faster than a network round trip; the share may be exported or mounted asynchronously, in which case data can be lost if the server crashes")
+		}
+	}
+	if sfp.SyncLatency > maxStorageFolderSyncLatency {
+		sfp.Warnings = append(sfp.Warnings, fmt.Sprintf("average fsync latency of %v is high; uploads to the host will be slow", sfp.SyncLatency))
+	}
+	if !sfp.WriteBarrier {
+		sfp.Warnings = append(sfp.Warnings, errNoWriteBarrier.Error())
+	}
+	if sfp.InUse {
+		sfp.Warnings = append(sfp.Warnings, errStorageFolderInUse.Error())
+	}
+	return sfp, nil
+}
+
+// PreflightStorageFolder checks whether the folder at path can safely be used
+// as a storage folder, measuring its latency and verifying that synced data
+// can be read back. The folder is not added.
+func (cm *ContractManager) PreflightStorageFolder(path string) (modules.StorageFolderPreflight, error) {
+	err := cm.tg.Add()
+	if err != nil {
+		return modules.StorageFolderPreflight{}, err
+	}
+	defer cm.tg.Done()
+
+	if !filepath.IsAbs(path) {
+		return modules.StorageFolderPreflight{}, errRelativePath
+	}
+	pathInfo, err := os.Stat(path)
+	if err != nil {
+		return modules.StorageFolderPreflight{}, err
+	}
+	if !pathInfo.Mode().IsDir() {
+		return modules.StorageFolderPreflight{}, errStorageFolderNotFolder
+	}
+	return cm.preflightStorageFolder(path)
+}
@@ -0,0 +1,137 @@
+package contractmanager
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestParseMounts checks that the filesystem of a path is taken from the
+// longest mount point containing it.
+func TestParseMounts(t *testing.T) {
+	mounts := `rootfs / rootfs rw 0 0
+/dev/sda1 / ext4 rw,relatime 0 0
+server:/export /mnt/nfs nfs4 rw,relatime 0 0
+//server/share /mnt/smb\040share cifs rw 0 0
+/dev/sdb1 /mnt/nfsdisk xfs rw 0 0
+`
+	tests := []struct {
+		path   string
+		fsType string
+	}{
+		{"/home/sia", "ext4"},
+		{"/mnt/nfs", "nfs4"},
+		{"/mnt/nfs/sia/folder", "nfs4"},
+		{"/mnt/nfsdisk/sia", "xfs"},
+		{"/mnt/smb share/sia", "cifs"},
+	}
+	for _, test := range tests {
+		if fsType := parseMounts(strings.NewReader(mounts), test.path); fsType != test.fsType {
+			t.Errorf("expected %v to be on %v, got %v", test.path, test.fsType, fsType)
+		}
+	}
+	if fsType := parseMounts(strings.NewReader(""), "/"); fsType != "unknown" {
+		t.Error("expected unknown filesystem for an empty mounts table, got", fsType)
+	}
+}
+
+// TestPreflightStorageFolder checks that a local folder passes the preflight
+// check and is not claimed, and that a network folder claimed by one contract
+// manager cannot be added to another until it is removed.
+func TestPreflightStorageFolder(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	// The test replaces the mounts table, so it does not run in parallel.
+	cmt, err := newContractManagerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+	cmt2, err := newContractManagerTester(t.Name() + "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt2.panicClose()
+
+	storageFolderDir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	err = os.MkdirAll(storageFolderDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cmt.cm.PreflightStorageFolder("relative/path"); err != errRelativePath {
+		t.Fatal("expected errRelativePath, got", err)
+	}
+	sfp, err := cmt.cm.PreflightStorageFolder(storageFolderDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sfp.WriteBarrier || sfp.InUse || sfp.NetworkFilesystem || sfp.Filesystem == "" {
+		t.Fatal("unexpected preflight result for a local folder:", sfp)
+	}
+	files, err := ioutil.ReadDir(storageFolderDir)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(files) != 0 {
+		t.Fatal("preflight file was not removed")
+	}
+
+	// A local folder is added without a lock file.
+	size := modules.SectorSize * storageFolderGranularity * 2
+	err = cmt.cm.AddStorageFolder(storageFolderDir, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(storageFolderDir, lockFile)); !os.IsNotExist(err) {
+		t.Fatal("lock file was written into a local folder:", err)
+	}
+	err = cmt.cm.RemoveStorageFolder(cmt.cm.StorageFolders()[0].Index, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Report the folder as being on a network filesystem.
+	mounts := filepath.Join(cmt.persistDir, "mounts")
+	err = ioutil.WriteFile(mounts, []byte("server:/export "+storageFolderDir+" nfs4 rw 0 0\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldMountsFile := mountsFile
+	mountsFile = mounts
+	defer func() {
+		mountsFile = oldMountsFile
+	}()
+
+	// Add the folder to the first contract manager. The second should see it
+	// as in use and refuse to add it.
+	err = cmt.cm.AddStorageFolder(storageFolderDir, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sfp, err = cmt2.cm.PreflightStorageFolder(storageFolderDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sfp.InUse {
+		t.Fatal("folder claimed by another contract manager is not reported as in use")
+	}
+	err = cmt2.cm.AddStorageFolder(storageFolderDir, size)
+	if err != errStorageFolderInUse {
+		t.Fatal("expected errStorageFolderInUse, got", err)
+	}
+
+	// Once the folder is removed from the first contract manager, the second
+	// can add it.
+	err = cmt.cm.RemoveStorageFolder(cmt.cm.StorageFolders()[0].Index, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cmt2.cm.AddStorageFolder(storageFolderDir, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
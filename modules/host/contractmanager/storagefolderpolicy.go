@@ -0,0 +1,103 @@
+package contractmanager
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+var (
+	// errBadWritePolicy is returned if a write policy has negative values.
+	errBadWritePolicy = errors.New("write policy timeouts and retries must not be negative")
+)
+
+// acceptingWrites returns whether new sectors may be placed in the storage
+// folder. Folders with stalled writes, and folders whose writes have recently
+// failed through all retries, are skipped so that one bad mount does not slow
+// down every call to AddSector.
+func (sf *storageFolder) acceptingWrites() bool {
+	if atomic.LoadInt64(&sf.atomicStalledWrites) > 0 {
+		return false
+	}
+	return atomic.LoadInt64(&sf.atomicUnavailableUntil) <= time.Now().UnixNano()
+}
+
+// timedWrite performs a write, giving up on the write if it takes longer than
+// the timeout. A write that has been given up on cannot be cancelled, and
+// continues in the background - 'abandon' is called once the write finally
+// returns, so that any resources reserved for the write can be released. Until
+// then, the folder is reported as stalled. A timeout of zero means that the
+// write is waited on indefinitely.
+func (sf *storageFolder) timedWrite(timeout time.Duration, write func() error, abandon func()) error {
+	if timeout == 0 {
+		return write()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- write()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+	}
+
+	atomic.AddInt64(&sf.atomicStalledWrites, 1)
+	go func() {
+		<-done
+		abandon()
+		atomic.AddInt64(&sf.atomicStalledWrites, -1)
+	}()
+	return errStorageFolderStalled
+}
+
+// managedPolicyWrite performs a write to the storage folder under the provided
+// write policy, retrying failed writes. Writes that stall are not retried. If
+// the write cannot be completed and the folder has a write policy, the folder
+// is taken out of rotation for new sectors for a while.
+func (sf *storageFolder) managedPolicyWrite(policy modules.StorageFolderWritePolicy, write func() error, abandon func()) error {
+	var err error
+	for i := 0; i <= policy.Retries; i++ {
+		if i > 0 {
+			time.Sleep(policy.RetryDelay)
+		}
+		err = sf.timedWrite(policy.WriteTimeout, write, abandon)
+		if err == nil || err == errStorageFolderStalled {
+			break
+		}
+	}
+	if err != nil && policy != (modules.StorageFolderWritePolicy{}) {
+		atomic.StoreInt64(&sf.atomicUnavailableUntil, time.Now().Add(storageFolderFailureCooldown).UnixNano())
+	}
+	return err
+}
+
+// SetStorageFolderWritePolicy changes the timeouts and retries that are
+// applied when writing new sectors to a storage folder. The new policy is saved
+// with the rest of the contract manager settings during the next commit of the
+// WAL.
+func (cm *ContractManager) SetStorageFolderWritePolicy(index uint16, policy modules.StorageFolderWritePolicy) error {
+	err := cm.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer cm.tg.Done()
+
+	if policy.WriteTimeout < 0 || policy.Retries < 0 || policy.RetryDelay < 0 {
+		return errBadWritePolicy
+	}
+
+	cm.wal.mu.Lock()
+	defer cm.wal.mu.Unlock()
+	sf, exists := cm.storageFolders[index]
+	if !exists {
+		return errStorageFolderNotFound
+	}
+	sf.writePolicy = policy
+	// A new policy gives the folder a fresh start.
+	atomic.StoreInt64(&sf.atomicUnavailableUntil, 0)
+	return nil
+}
@@ -0,0 +1,111 @@
+package contractmanager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// stallingFile is a file whose writes block until release is closed,
+// simulating a network mount whose server has stopped responding.
+type stallingFile struct {
+	file
+	release chan struct{}
+}
+
+// WriteAt blocks until the file is released, and then performs the write.
+func (sf *stallingFile) WriteAt(b []byte, off int64) (int, error) {
+	<-sf.release
+	return sf.file.WriteAt(b, off)
+}
+
+// TestStalledStorageFolder checks that a storage folder whose writes stall is
+// skipped by AddSector, and that the sector slot held by the stalled write is
+// freed once the write returns.
+func TestStalledStorageFolder(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester("TestStalledStorageFolder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	// Add two storage folders.
+	for _, name := range []string{"storageFolderOne", "storageFolderTwo"} {
+		dir := filepath.Join(cmt.persistDir, name)
+		err = os.MkdirAll(dir, 0700)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = cmt.cm.AddStorageFolder(dir, modules.SectorSize*storageFolderGranularity*2)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	sfs := cmt.cm.StorageFolders()
+	stalledIndex := sfs[0].Index
+
+	// Give the first folder a short write timeout, and make its sector writes
+	// stall.
+	err = cmt.cm.SetStorageFolderWritePolicy(stalledIndex, modules.StorageFolderWritePolicy{
+		WriteTimeout: 100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	release := make(chan struct{})
+	cmt.cm.wal.mu.Lock()
+	sf := cmt.cm.storageFolders[stalledIndex]
+	sf.sectorFile = &stallingFile{file: sf.sectorFile, release: release}
+	cmt.cm.wal.mu.Unlock()
+
+	// Adding sectors should succeed, with the sectors going to the healthy
+	// folder.
+	for i := 0; i < 20; i++ {
+		root, data := randSector()
+		err = cmt.cm.AddSector(root, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var stalled, healthy modules.StorageFolderMetadata
+	for _, sfm := range cmt.cm.StorageFolders() {
+		if sfm.Index == stalledIndex {
+			stalled = sfm
+		} else {
+			healthy = sfm
+		}
+	}
+	if !stalled.Stalled {
+		t.Fatal("folder with a stalled write is not reported as stalled")
+	}
+	if stalled.CapacityRemaining != stalled.Capacity-modules.SectorSize {
+		t.Fatal("stalled write should hold exactly one sector slot:", stalled.Capacity, stalled.CapacityRemaining)
+	}
+	if healthy.CapacityRemaining != healthy.Capacity-20*modules.SectorSize {
+		t.Fatal("sectors were not added to the healthy folder:", healthy.Capacity, healthy.CapacityRemaining)
+	}
+
+	// Once the stalled write returns, the slot it held should be freed.
+	close(release)
+	for i := 0; i < 50 && stalled.Stalled; i++ {
+		time.Sleep(100 * time.Millisecond)
+		for _, sfm := range cmt.cm.StorageFolders() {
+			if sfm.Index == stalledIndex {
+				stalled = sfm
+			}
+		}
+	}
+	if stalled.Stalled {
+		t.Fatal("folder is still stalled after the write was released")
+	}
+	if stalled.CapacityRemaining != stalled.Capacity {
+		t.Fatal("slot of the abandoned write was not freed:", stalled.Capacity, stalled.CapacityRemaining)
+	}
+}
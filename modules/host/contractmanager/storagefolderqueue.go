@@ -0,0 +1,39 @@
+package contractmanager
+
+import (
+	"sync/atomic"
+)
+
+// The WAL lock is only held while a new sector is assigned a storage folder
+// and a slot within it, the sector itself is written outside of the lock. When
+// many sectors arrive at once, they are striped across the storage folders:
+// each folder admits folderWriteConcurrency writes at a time and queues the
+// rest, and new sectors are placed in the folders with the fewest queued
+// writes. Concurrent uploads therefore keep every disk busy instead of piling
+// up on whichever folder was picked first.
+
+// queuedWrites returns the number of new sectors that are queued for or being
+// written to the storage folder.
+func (sf *storageFolder) queuedWrites() int64 {
+	return atomic.LoadInt64(&sf.atomicQueuedWrites)
+}
+
+// reserveWrite counts a new sector that has been placed in the storage folder
+// but not yet written. It is called under the WAL lock, together with the
+// placement, so that the next sector sees the longer queue.
+func (sf *storageFolder) reserveWrite() {
+	atomic.AddInt64(&sf.atomicQueuedWrites, 1)
+}
+
+// managedWaitWriteQueue blocks until the storage folder admits another write.
+// reserveWrite must be called first.
+func (sf *storageFolder) managedWaitWriteQueue() {
+	sf.writeQueue <- struct{}{}
+}
+
+// releaseWrite frees the place in the write queue held by a sector once the
+// sector has been written or abandoned.
+func (sf *storageFolder) releaseWrite() {
+	<-sf.writeQueue
+	atomic.AddInt64(&sf.atomicQueuedWrites, -1)
+}
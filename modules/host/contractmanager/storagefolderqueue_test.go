@@ -0,0 +1,45 @@
+package contractmanager
+
+import (
+	"testing"
+)
+
+// TestVacancyStorageFolderQueues checks that new sectors are placed in the
+// storage folders with the fewest queued writes.
+func TestVacancyStorageFolderQueues(t *testing.T) {
+	sfs := make([]*storageFolder, 3)
+	for i := range sfs {
+		sfs[i] = &storageFolder{
+			usage:      make([]uint64, 1),
+			writeQueue: make(chan struct{}, folderWriteConcurrency),
+		}
+	}
+	sfs[0].reserveWrite()
+	sfs[0].reserveWrite()
+	sfs[2].reserveWrite()
+
+	for i := 0; i < 10; i++ {
+		sf, index := vacancyStorageFolder(sfs, "")
+		if index != 1 {
+			t.Fatal("expected the folder without queued writes, got", index)
+		}
+		sf.mu.RUnlock()
+	}
+
+	// Once the other folders catch up, the next sector should go to the
+	// folder that is now the least busy.
+	sfs[1].reserveWrite()
+	sfs[1].reserveWrite()
+	sf, index := vacancyStorageFolder(sfs, "")
+	if index != 2 {
+		t.Fatal("expected the folder with one queued write, got", index)
+	}
+	sf.mu.RUnlock()
+
+	// Writes hold a place in the queue until they are released.
+	sfs[2].managedWaitWriteQueue()
+	sfs[2].releaseWrite()
+	if sfs[2].queuedWrites() != 0 {
+		t.Fatal("write was not released from the queue:", sfs[2].queuedWrites())
+	}
+}
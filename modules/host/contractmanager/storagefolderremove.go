@@ -1,6 +1,7 @@
 package contractmanager
 
 import (
+	"os"
 	"path/filepath"
 )
 
@@ -16,6 +17,13 @@ type (
 // commitStorageFolderRemoval will finalize a storage folder removal from the
 // contract manager.
 func (wal *writeAheadLog) commitStorageFolderRemoval(sfr storageFolderRemoval) {
+	// A missing storage folder has no open files, and its files cannot be
+	// reached to be deleted.
+	if _, missing := wal.cm.missingFolders[sfr.Index]; missing {
+		delete(wal.cm.missingFolders, sfr.Index)
+		return
+	}
+
 	// Close any open file handles.
 	sf, exists := wal.cm.storageFolders[sfr.Index]
 	if exists {
@@ -33,6 +41,12 @@ func (wal *writeAheadLog) commitStorageFolderRemoval(sfr storageFolderRemoval) {
 			wal.cm.log.Printf("Error: unable to close sector file as storage folder %v is removed\n", sf.path)
 		}
 	}
+	if exists && sf.metadataBackupFile != nil {
+		err := sf.metadataBackupFile.Close()
+		if err != nil {
+			wal.cm.log.Printf("Error: unable to close backup metadata file as storage folder %v is removed\n", sf.path)
+		}
+	}
 
 	// Delete the files.
 	err := wal.cm.dependencies.removeFile(filepath.Join(sfr.Path, metadataFile))
@@ -43,6 +57,14 @@ func (wal *writeAheadLog) commitStorageFolderRemoval(sfr storageFolderRemoval) {
 	if err != nil {
 		wal.cm.log.Printf("Error: unable to reomve sector file as storage folder %v is removed\n", sfr.Path)
 	}
+	err = wal.cm.dependencies.removeFile(filepath.Join(sfr.Path, metadataBackupFile))
+	if err != nil && !os.IsNotExist(err) {
+		wal.cm.log.Printf("Error: unable to remove backup metadata file as storage folder %v is removed\n", sfr.Path)
+	}
+	err = wal.cm.releaseStorageFolder(sfr.Path)
+	if err != nil {
+		wal.cm.log.Printf("Error: unable to remove lock file as storage folder %v is removed\n", sfr.Path)
+	}
 }
 
 // RemoveStorageFolder will delete a storage folder from the contract manager,
@@ -51,8 +73,27 @@ func (cm *ContractManager) RemoveStorageFolder(index uint16, force bool) error {
 	cm.tg.Add()
 	defer cm.tg.Done()
 
-	// Retrieve the specified storage folder.
+	// The sectors of a missing storage folder cannot be moved to the other
+	// folders, they are lost when the folder is removed.
 	cm.wal.mu.Lock()
+	if ssf, missing := cm.missingFolders[index]; missing {
+		if !force {
+			cm.wal.mu.Unlock()
+			return errStorageFolderMissing
+		}
+		cm.wal.appendChange(stateChange{
+			StorageFolderRemovals: []storageFolderRemoval{{
+				Index: index,
+				Path:  ssf.Path,
+			}},
+		})
+		syncChan := cm.wal.syncChan
+		cm.wal.mu.Unlock()
+		<-syncChan
+		return nil
+	}
+
+	// Retrieve the specified storage folder.
 	sf, exists := cm.storageFolders[index]
 	if !exists {
 		cm.wal.mu.Unlock()
@@ -385,3 +385,68 @@ func TestRemoveStorageFolderConcurrentAddSector(t *testing.T) {
 		}
 	}
 }
+
+// TestRemoveMissingStorageFolder checks that the forced removal of a missing
+// storage folder is kept across restarts.
+func TestRemoveMissingStorageFolder(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester("TestRemoveMissingStorageFolder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	storageFolderDir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	err = os.MkdirAll(storageFolderDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cmt.cm.AddStorageFolder(storageFolderDir, modules.SectorSize*storageFolderGranularity*2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := cmt.cm.StorageFolders()[0].Index
+	persistDir := cmt.cm.persistDir
+	err = cmt.cm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Delete the files of the folder while the contract manager is closed.
+	err = os.RemoveAll(storageFolderDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm, err := New(persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cm.RemoveStorageFolder(index, false); err != errStorageFolderMissing {
+		t.Fatal("expected errStorageFolderMissing, got", err)
+	}
+	err = cm.RemoveStorageFolder(index, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sfs := cm.StorageFolders(); len(sfs) != 0 {
+		t.Fatal("missing storage folder was not removed:", sfs)
+	}
+	err = cm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The removal is kept across restarts.
+	cm, err = New(persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sfs := cm.StorageFolders(); len(sfs) != 0 {
+		t.Fatal("missing storage folder came back after a restart:", sfs)
+	}
+	err = cm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
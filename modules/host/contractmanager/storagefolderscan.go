@@ -0,0 +1,229 @@
+package contractmanager
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+var (
+	// errScanInProgress is returned if a scan of the storage folders is
+	// requested while another scan is running.
+	errScanInProgress = errors.New("a scan of the storage folders is already in progress")
+)
+
+// scanTracker tracks the integrity scans of the storage folders. lastScan is
+// saved with the contract manager settings so that scheduled scans keep to
+// their interval across restarts.
+//
+// The WAL lock may be grabbed before the tracker lock, but not after.
+type scanTracker struct {
+	lastScan time.Time
+	results  map[uint16]*modules.StorageFolderScan
+	running  bool
+	mu       sync.Mutex
+}
+
+// scanOutcome is the result of checking a single sector.
+type scanOutcome int
+
+const (
+	scanSkipped scanOutcome = iota
+	scanValid
+	scanCorrupt
+	scanUnreadable
+)
+
+// managedScanSector reads the sector with the provided id and checks that the
+// data hashes to a Merkle root which matches the id. If the sector is bad and
+// quarantine is set, the sector is deleted. The returned bool indicates
+// whether the sector was quarantined.
+func (cm *ContractManager) managedScanSector(id sectorID, quarantine bool) (scanOutcome, bool) {
+	cm.wal.managedLockSector(id)
+	defer cm.wal.managedUnlockSector(id)
+
+	// The sector may have been removed or moved since the scan started.
+	cm.wal.mu.Lock()
+	sl, exists1 := cm.sectorLocations[id]
+	sf, exists2 := cm.storageFolders[sl.storageFolder]
+	cm.wal.mu.Unlock()
+	if !exists1 || !exists2 {
+		return scanSkipped, false
+	}
+
+	outcome := scanValid
+	sectorData, err := readSector(sf.sectorFile, sl.index)
+	if err != nil {
+		atomic.AddUint64(&sf.atomicFailedReads, 1)
+		cm.log.Printf("WARN: unable to read sector %v of storage folder %v during scan: %v\n", sl.index, sf.path, err)
+		outcome = scanUnreadable
+	} else {
+		atomic.AddUint64(&sf.atomicSuccessfulReads, 1)
+		if cm.managedSectorID(crypto.MerkleRoot(sectorData)) != id {
+			cm.log.Printf("WARN: sector %v of storage folder %v does not match its Merkle root\n", sl.index, sf.path)
+			outcome = scanCorrupt
+		}
+	}
+	if outcome == scanValid || !quarantine {
+		return outcome, false
+	}
+
+	err = cm.wal.managedDeleteSector(id)
+	if err != nil {
+		cm.log.Printf("ERROR: unable to quarantine sector %v of storage folder %v: %v\n", sl.index, sf.path, err)
+		return outcome, false
+	}
+	cm.managedRecordRemove(id)
+	return outcome, true
+}
+
+// managedScan checks the integrity of every sector in every storage folder.
+// The caller must have set scan.running, which is cleared when the scan
+// completes.
+func (cm *ContractManager) managedScan(quarantine bool) {
+	// Collect the sectors in each storage folder, and prepare the results.
+	cm.wal.mu.Lock()
+	sectors := make(map[uint16][]sectorID)
+	for id, sl := range cm.sectorLocations {
+		sectors[sl.storageFolder] = append(sectors[sl.storageFolder], id)
+	}
+	results := make(map[uint16]*modules.StorageFolderScan)
+	for _, sf := range cm.storageFolders {
+		results[sf.index] = &modules.StorageFolderScan{
+			Index:        sf.index,
+			Path:         sf.path,
+			TotalSectors: uint64(len(sectors[sf.index])),
+		}
+	}
+	cm.scan.mu.Lock()
+	cm.scan.results = results
+	cm.scan.mu.Unlock()
+	cm.wal.mu.Unlock()
+
+	// Scan the folders one at a time, to keep the load on the disks down.
+	for index, result := range results {
+		cm.scan.mu.Lock()
+		result.StartTime = time.Now()
+		cm.scan.mu.Unlock()
+
+		for _, id := range sectors[index] {
+			select {
+			case <-cm.tg.StopChan():
+				cm.scan.mu.Lock()
+				cm.scan.running = false
+				cm.scan.mu.Unlock()
+				return
+			default:
+			}
+
+			outcome, quarantined := cm.managedScanSector(id, quarantine)
+			cm.scan.mu.Lock()
+			if outcome != scanSkipped {
+				result.ScannedSectors++
+			}
+			if outcome == scanCorrupt {
+				result.CorruptSectors++
+			}
+			if outcome == scanUnreadable {
+				result.UnreadableSectors++
+			}
+			if quarantined {
+				result.QuarantinedSectors++
+			}
+			cm.scan.mu.Unlock()
+		}
+
+		cm.scan.mu.Lock()
+		result.EndTime = time.Now()
+		if result.CorruptSectors > 0 || result.UnreadableSectors > 0 {
+			cm.log.Printf("WARN: scan of storage folder %v found %v corrupt and %v unreadable sectors, %v quarantined\n", result.Path, result.CorruptSectors, result.UnreadableSectors, result.QuarantinedSectors)
+		}
+		cm.scan.mu.Unlock()
+	}
+
+	cm.scan.mu.Lock()
+	cm.scan.lastScan = time.Now()
+	cm.scan.running = false
+	cm.scan.mu.Unlock()
+}
+
+// threadedScheduledScans periodically starts a scan of the storage folders.
+// Scheduled scans only report bad sectors, they do not quarantine them. The
+// thread group is only held while a scan runs, so that the loop does not
+// block tg.Flush.
+func (cm *ContractManager) threadedScheduledScans() {
+	for {
+		select {
+		case <-cm.tg.StopChan():
+			return
+		case <-time.After(scanCheckInterval):
+		}
+		if cm.tg.Add() != nil {
+			return
+		}
+
+		cm.scan.mu.Lock()
+		due := !cm.scan.running && time.Since(cm.scan.lastScan) > scanInterval
+		if due {
+			cm.scan.running = true
+		}
+		cm.scan.mu.Unlock()
+		if due {
+			cm.log.Println("Starting scheduled scan of the storage folders")
+			cm.managedScan(false)
+		}
+		cm.tg.Done()
+	}
+}
+
+// ScanStorageFolders starts an integrity scan of every storage folder in the
+// background. If quarantine is set, corrupt and unreadable sectors are deleted
+// so that the host stops serving them.
+func (cm *ContractManager) ScanStorageFolders(quarantine bool) error {
+	err := cm.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer cm.tg.Done()
+
+	cm.scan.mu.Lock()
+	if cm.scan.running {
+		cm.scan.mu.Unlock()
+		return errScanInProgress
+	}
+	cm.scan.running = true
+	cm.scan.mu.Unlock()
+
+	go func() {
+		if err := cm.tg.Add(); err != nil {
+			cm.scan.mu.Lock()
+			cm.scan.running = false
+			cm.scan.mu.Unlock()
+			return
+		}
+		defer cm.tg.Done()
+		cm.managedScan(quarantine)
+	}()
+	return nil
+}
+
+// StorageFolderScans returns the results of the most recent integrity scan of
+// each storage folder, sorted by folder index.
+func (cm *ContractManager) StorageFolderScans() []modules.StorageFolderScan {
+	cm.scan.mu.Lock()
+	defer cm.scan.mu.Unlock()
+
+	scans := make([]modules.StorageFolderScan, 0, len(cm.scan.results))
+	for _, result := range cm.scan.results {
+		scans = append(scans, *result)
+	}
+	sort.Slice(scans, func(i, j int) bool {
+		return scans[i].Index < scans[j].Index
+	})
+	return scans
+}
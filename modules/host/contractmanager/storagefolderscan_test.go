@@ -0,0 +1,117 @@
+package contractmanager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/fastrand"
+)
+
+// waitForScan blocks until the running scan of the storage folders has
+// completed.
+func waitForScan(t *testing.T, cm *ContractManager) {
+	for i := 0; i < 100; i++ {
+		cm.scan.mu.Lock()
+		running := cm.scan.running
+		cm.scan.mu.Unlock()
+		if !running {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatal("scan did not complete")
+}
+
+// TestScanStorageFolders checks that a scan finds a sector whose data has been
+// corrupted on disk, and that the sector is only removed when the scan is
+// asked to quarantine it.
+func TestScanStorageFolders(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester("TestScanStorageFolders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	storageFolderDir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	err = os.MkdirAll(storageFolderDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cmt.cm.AddStorageFolder(storageFolderDir, modules.SectorSize*64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var roots []crypto.Hash
+	for i := 0; i < 3; i++ {
+		root, data := randSector()
+		err = cmt.cm.AddSector(root, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, root)
+	}
+
+	// Corrupt part of the data of the first sector.
+	cmt.cm.wal.mu.Lock()
+	sl := cmt.cm.sectorLocations[cmt.cm.managedSectorID(roots[0])]
+	cmt.cm.wal.mu.Unlock()
+	f, err := os.OpenFile(filepath.Join(storageFolderDir, sectorFile), os.O_RDWR, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.WriteAt(fastrand.Bytes(64), int64(uint64(sl.index)*modules.SectorSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A scan without quarantine should report the sector but keep it.
+	err = cmt.cm.ScanStorageFolders(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForScan(t, cmt.cm)
+	scans := cmt.cm.StorageFolderScans()
+	if len(scans) != 1 {
+		t.Fatal("expected results for 1 storage folder, got", len(scans))
+	}
+	if scans[0].ScannedSectors != 3 || scans[0].CorruptSectors != 1 || scans[0].QuarantinedSectors != 0 {
+		t.Fatalf("unexpected scan results: %+v", scans[0])
+	}
+	if scans[0].EndTime.IsZero() {
+		t.Fatal("completed scan has no end time")
+	}
+	if _, err := cmt.cm.ReadSector(roots[0]); err != nil {
+		t.Fatal("corrupt sector should be kept without quarantine:", err)
+	}
+
+	// A scan with quarantine should remove the sector.
+	err = cmt.cm.ScanStorageFolders(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForScan(t, cmt.cm)
+	scans = cmt.cm.StorageFolderScans()
+	if scans[0].CorruptSectors != 1 || scans[0].QuarantinedSectors != 1 {
+		t.Fatalf("unexpected scan results: %+v", scans[0])
+	}
+	if _, err := cmt.cm.ReadSector(roots[0]); err != ErrSectorNotFound {
+		t.Fatal("quarantined sector should not be readable:", err)
+	}
+	for _, root := range roots[1:] {
+		if _, err := cmt.cm.ReadSector(root); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
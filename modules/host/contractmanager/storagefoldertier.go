@@ -0,0 +1,121 @@
+package contractmanager
+
+import (
+	"errors"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// Storage folders are tagged with a placement tier. New sectors are written to
+// folders in the fast tier while they have room, so that uploads are limited
+// by the speed of the fastest disks. Sectors in the fast tier that have not
+// been read for coldSectorAge are periodically moved down to the capacity
+// tier, keeping room in the fast tier for new uploads. Sectors are never moved
+// up a tier. Hosts without any fast folders place sectors exactly as before.
+
+var (
+	// errBadStorageFolderTier is returned if a storage folder is tagged with
+	// an unrecognized tier.
+	errBadStorageFolderTier = errors.New("storage folder tier must be either 'fast' or 'capacity'")
+)
+
+// placementTier returns the tier of the storage folder.
+func (sf *storageFolder) placementTier() string {
+	if sf.tier == "" {
+		return modules.StorageFolderTierCapacity
+	}
+	return sf.tier
+}
+
+// coldSectors returns the sectors in the fast tier that have gone without
+// being read for coldSectorAge.
+func (cm *ContractManager) coldSectors(now time.Time) []sectorID {
+	var cold []sectorID
+	for id, sl := range cm.sectorLocations {
+		sf, exists := cm.storageFolders[sl.storageFolder]
+		if !exists || sf.placementTier() != modules.StorageFolderTierFast {
+			continue
+		}
+		if now.Sub(cm.access.lastActive(id)) > coldSectorAge {
+			cold = append(cold, id)
+		}
+	}
+	return cold
+}
+
+// managedMigrateColdSectors moves the cold sectors in the fast tier down to the
+// capacity tier, returning the number of sectors that were moved. Migration
+// stops early if the capacity tier runs out of room.
+func (cm *ContractManager) managedMigrateColdSectors() (uint64, error) {
+	cm.wal.mu.Lock()
+	cold := cm.coldSectors(time.Now())
+	cm.wal.mu.Unlock()
+
+	var moved uint64
+	for _, id := range cold {
+		select {
+		case <-cm.tg.StopChan():
+			return moved, nil
+		default:
+		}
+		err := cm.wal.managedMoveSector(id, modules.StorageFolderTierCapacity, true)
+		if err == errInsufficientStorageForSector {
+			return moved, err
+		} else if err != nil {
+			cm.log.Println("Unable to move cold sector to the capacity tier:", err)
+			continue
+		}
+		moved++
+	}
+	return moved, nil
+}
+
+// threadedMigrateColdSectors periodically moves cold sectors down from the
+// fast tier.
+func (cm *ContractManager) threadedMigrateColdSectors() {
+	for {
+		select {
+		case <-cm.tg.StopChan():
+			return
+		case <-time.After(tierMigrationInterval):
+		}
+		if cm.tg.Add() != nil {
+			return
+		}
+		moved, err := cm.managedMigrateColdSectors()
+		cm.tg.Done()
+		if moved > 0 {
+			cm.log.Printf("Moved %v cold sectors from the fast tier to the capacity tier\n", moved)
+		}
+		if err != nil {
+			cm.log.Println("WARN: Unable to move all cold sectors out of the fast tier:", err)
+		}
+	}
+}
+
+// SetStorageFolderTier tags a storage folder as belonging to the fast or the
+// capacity tier. The tier only affects where sectors are placed from now on;
+// sectors already in the folder stay where they are until they are migrated.
+// The new tier is saved with the rest of the contract manager settings during
+// the next commit of the WAL.
+func (cm *ContractManager) SetStorageFolderTier(index uint16, tier string) error {
+	err := cm.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer cm.tg.Done()
+
+	if tier != modules.StorageFolderTierFast && tier != modules.StorageFolderTierCapacity {
+		return errBadStorageFolderTier
+	}
+
+	cm.wal.mu.Lock()
+	defer cm.wal.mu.Unlock()
+	sf, exists := cm.storageFolders[index]
+	if !exists {
+		return errStorageFolderNotFound
+	}
+	sf.tier = tier
+	return nil
+}
@@ -0,0 +1,109 @@
+package contractmanager
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestStorageFolderTiers checks that new sectors are placed in the fast tier,
+// and that cold sectors are moved down to the capacity tier.
+func TestStorageFolderTiers(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester("TestStorageFolderTiers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	// Add two storage folders, and tag the first one as fast.
+	for _, name := range []string{"storageFolderOne", "storageFolderTwo"} {
+		dir := filepath.Join(cmt.persistDir, name)
+		err = os.MkdirAll(dir, 0700)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = cmt.cm.AddStorageFolder(dir, modules.SectorSize*storageFolderGranularity*2)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	fastIndex := cmt.cm.StorageFolders()[0].Index
+	err = cmt.cm.SetStorageFolderTier(fastIndex, "ssd")
+	if err != errBadStorageFolderTier {
+		t.Fatal("expected errBadStorageFolderTier, got", err)
+	}
+	err = cmt.cm.SetStorageFolderTier(fastIndex, modules.StorageFolderTierFast)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// New sectors should all be placed in the fast folder.
+	root, data := randSector()
+	err = cmt.cm.AddSector(root, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 9; i++ {
+		r, d := randSector()
+		err = cmt.cm.AddSector(r, d)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	tierSectors := func() (fast, capacity uint64) {
+		for _, sfm := range cmt.cm.StorageFolders() {
+			used := (sfm.Capacity - sfm.CapacityRemaining) / modules.SectorSize
+			if sfm.Tier == modules.StorageFolderTierFast {
+				fast += used
+			} else {
+				capacity += used
+			}
+		}
+		return fast, capacity
+	}
+	if fast, capacity := tierSectors(); fast != 10 || capacity != 0 {
+		t.Fatal("new sectors were not placed in the fast tier:", fast, capacity)
+	}
+
+	// Nothing has gone cold yet.
+	moved, err := cmt.cm.managedMigrateColdSectors()
+	if err != nil || moved != 0 {
+		t.Fatal("sectors were migrated before going cold:", moved, err)
+	}
+
+	// Age every sector except one, which should stay in the fast tier.
+	id := cmt.cm.managedSectorID(root)
+	past := time.Now().Add(-2 * coldSectorAge)
+	cmt.cm.access.mu.Lock()
+	cmt.cm.access.since = past
+	for sid := range cmt.cm.access.added {
+		if sid != id {
+			cmt.cm.access.added[sid] = past.Unix()
+		}
+	}
+	cmt.cm.access.mu.Unlock()
+	moved, err = cmt.cm.managedMigrateColdSectors()
+	if err != nil || moved != 9 {
+		t.Fatal("cold sectors were not migrated:", moved, err)
+	}
+	if fast, capacity := tierSectors(); fast != 1 || capacity != 9 {
+		t.Fatal("cold sectors were not moved to the capacity tier:", fast, capacity)
+	}
+
+	// Sectors are still readable after being moved.
+	readData, err := cmt.cm.ReadSector(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(readData, data) {
+		t.Fatal("sector data changed")
+	}
+}
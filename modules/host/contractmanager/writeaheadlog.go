@@ -51,6 +51,7 @@ type (
 		ErroredStorageFolderExtensions    []uint16
 		StorageFolderAdditions            []savedStorageFolder
 		StorageFolderExtensions           []storageFolderExtension
+		StorageFolderMoves                []savedStorageFolder
 		StorageFolderRemovals             []storageFolderRemoval
 		StorageFolderReductions           []storageFolderReduction
 		UnfinishedStorageFolderAdditions  []savedStorageFolder
@@ -89,9 +90,17 @@ type (
 		// uncommittedChanges details a list of operations which have been
 		// suggested or queued to be made to the state, but are not yet
 		// guaranteed to have completed.
+		//
+		// commitRequested is signaled whenever a change is appended to the
+		// WAL, so that the sync loop can commit the change without waiting
+		// for the next scheduled commit. Changes that are appended while a
+		// commit is in progress are grouped into the following commit, which
+		// means that many concurrent operations share a single round of
+		// fsyncs.
 		fileSettingsTmp    file
 		fileWALTmp         file
 		syncChan           chan struct{}
+		commitRequested    chan struct{}
 		uncommittedChanges []stateChange
 
 		// Utilities. The WAL needs access to the ContractManager because all
@@ -155,6 +164,12 @@ func (wal *writeAheadLog) appendChange(sc stateChange) {
 	// Update the WAL to include the new storage folder in the uncommitted
 	// changes.
 	wal.uncommittedChanges = append(wal.uncommittedChanges, sc)
+
+	// Let the sync loop know that there is a change waiting to be committed.
+	select {
+	case wal.commitRequested <- struct{}{}:
+	default:
+	}
 }
 
 // commitChange will commit the provided change to the contract manager,
@@ -180,6 +195,11 @@ func (wal *writeAheadLog) commitChange(sc stateChange) {
 			wal.commitStorageFolderReduction(sfr)
 		}
 	}
+	for _, ssf := range sc.StorageFolderMoves {
+		for i := uint64(0); i < wal.cm.dependencies.atLeastOne(); i++ {
+			wal.commitStorageFolderMove(ssf)
+		}
+	}
 	for _, sfr := range sc.StorageFolderRemovals {
 		for i := uint64(0); i < wal.cm.dependencies.atLeastOne(); i++ {
 			wal.commitStorageFolderRemoval(sfr)
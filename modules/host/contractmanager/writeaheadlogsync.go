@@ -3,6 +3,7 @@ package contractmanager
 import (
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/NebulousLabs/Sia/persist"
@@ -51,8 +52,27 @@ func (wal *writeAheadLog) syncResources() {
 		}
 	}()
 
-	// Sync all of the storage folders.
+	// Sync all of the storage folders. A folder with stalled writes is only
+	// synced if the uncommitted changes touch it - a sync of a stalled network
+	// mount would block the commit, and with it every other folder. The only
+	// writes to a stalled folder that are skipped are abandoned sector writes,
+	// which never make it into the WAL.
+	touched := wal.touchedStorageFolders()
 	for _, sf := range wal.cm.storageFolders {
+		if atomic.LoadInt64(&sf.atomicStalledWrites) > 0 && !touched[sf.index] {
+			continue
+		}
+		if sf.metadataBackupFile != nil {
+			wg.Add(1)
+			go func(sf *storageFolder) {
+				defer wg.Done()
+				err := sf.metadataBackupFile.Sync()
+				if err != nil {
+					wal.cm.log.Severe("ERROR: unable to sync a storage folder:", err)
+					panic("unable to sync a storage folder, creashing to avoid data corruption")
+				}
+			}(sf)
+		}
 		wg.Add(2)
 		go func(sf *storageFolder) {
 			defer wg.Done()
@@ -131,6 +151,39 @@ func (wal *writeAheadLog) syncResources() {
 	wal.syncChan = make(chan struct{})
 }
 
+// touchedStorageFolders returns the set of storage folders which are modified
+// by the uncommitted changes in the WAL.
+func (wal *writeAheadLog) touchedStorageFolders() map[uint16]bool {
+	touched := make(map[uint16]bool)
+	for _, sc := range wal.uncommittedChanges {
+		for _, sfa := range sc.StorageFolderAdditions {
+			touched[sfa.Index] = true
+		}
+		for _, sfa := range sc.UnfinishedStorageFolderAdditions {
+			touched[sfa.Index] = true
+		}
+		for _, sfe := range sc.StorageFolderExtensions {
+			touched[sfe.Index] = true
+		}
+		for _, sfe := range sc.UnfinishedStorageFolderExtensions {
+			touched[sfe.Index] = true
+		}
+		for _, sfm := range sc.StorageFolderMoves {
+			touched[sfm.Index] = true
+		}
+		for _, sfr := range sc.StorageFolderReductions {
+			touched[sfr.Index] = true
+		}
+		for _, sfr := range sc.StorageFolderRemovals {
+			touched[sfr.Index] = true
+		}
+		for _, su := range sc.SectorUpdates {
+			touched[su.Folder] = true
+		}
+	}
+	return touched
+}
+
 // commit will take all of the changes that have been added to the WAL and
 // atomically commit the WAL to disk, then apply the actions in the WAL to the
 // state. commit will do lots of syncing disk I/O, and so can take a while,
@@ -201,6 +254,13 @@ func (wal *writeAheadLog) commit() {
 		})
 	}()
 	wg.Wait()
+
+	// The long running changes carried over into the new WAL do not need a
+	// commit of their own, clear the request made by appending them.
+	select {
+	case <-wal.commitRequested:
+	default:
+	}
 }
 
 // spawnSyncLoop prepares and establishes the loop which will be running in the
@@ -213,6 +273,7 @@ func (wal *writeAheadLog) spawnSyncLoop() (err error) {
 	threadsStopped := make(chan struct{})
 	syncLoopStopped := make(chan struct{})
 	wal.syncChan = make(chan struct{})
+	wal.commitRequested = make(chan struct{}, 1)
 	go wal.threadedSyncLoop(threadsStopped, syncLoopStopped)
 	wal.cm.tg.AfterStop(func() {
 		// Wait for another iteration of the sync loop, so that the in-progress
@@ -249,6 +310,12 @@ func (wal *writeAheadLog) spawnSyncLoop() (err error) {
 // the state as an ACID transaction. This process can be very slow, so
 // transactions to the contract manager are batched automatically and
 // occasionally committed together.
+//
+// A commit starts shortly after a change is appended to the WAL, rather than
+// at the next scheduled commit, so that operations waiting on the sync do not
+// sit idle. The short delay lets concurrent operations join the same commit,
+// and operations that arrive while a commit is running are grouped into the
+// next one.
 func (wal *writeAheadLog) threadedSyncLoop(threadsStopped chan struct{}, syncLoopStopped chan struct{}) {
 	// Provide a place for the testing to disable the sync loop.
 	if wal.cm.dependencies.disrupt("threadedSyncLoopStart") {
@@ -262,12 +329,16 @@ func (wal *writeAheadLog) threadedSyncLoop(threadsStopped chan struct{}, syncLoo
 		case <-threadsStopped:
 			close(syncLoopStopped)
 			return
+		case <-wal.commitRequested:
+			// Give concurrent operations a moment to join the commit.
+			time.Sleep(groupCommitDelay)
 		case <-time.After(syncInterval):
-			// Commit all of the changes in the WAL to disk, and then apply the
-			// changes.
-			wal.mu.Lock()
-			wal.commit()
-			wal.mu.Unlock()
 		}
+
+		// Commit all of the changes in the WAL to disk, and then apply the
+		// changes.
+		wal.mu.Lock()
+		wal.commit()
+		wal.mu.Unlock()
 	}
 }
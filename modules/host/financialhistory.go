@@ -0,0 +1,207 @@
+package host
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+const (
+	// financialHistoryDays is the number of days of financial history that
+	// the host keeps. Older days are dropped.
+	financialHistoryDays = 2 * 366
+
+	// secondsPerDay is used to convert between times and day numbers.
+	secondsPerDay = int64(24 * time.Hour / time.Second)
+)
+
+var (
+	// errBadFinancialPeriod is returned if financial periods are requested
+	// with an unrecognized period or a non-positive count.
+	errBadFinancialPeriod = errors.New("financial period must be day, week, or month, and the count must be positive")
+)
+
+// financialDay records the financial activity of the host on a single day,
+// counted in days since the unix epoch in UTC. The collateral fields hold the
+// amount committed to contracts at the time of the last activity on that day.
+type financialDay struct {
+	Day int64 `json:"day"`
+
+	ContractsFormed  uint64 `json:"contractsformed"`
+	SuccessfulProofs uint64 `json:"successfulproofs"`
+	MissedProofs     uint64 `json:"missedproofs"`
+
+	ContractCompensation     types.Currency `json:"contractcompensation"`
+	StorageRevenue           types.Currency `json:"storagerevenue"`
+	DownloadBandwidthRevenue types.Currency `json:"downloadbandwidthrevenue"`
+	UploadBandwidthRevenue   types.Currency `json:"uploadbandwidthrevenue"`
+
+	LockedStorageCollateral types.Currency `json:"lockedstoragecollateral"`
+	RiskedStorageCollateral types.Currency `json:"riskedstoragecollateral"`
+
+	LostRevenue           types.Currency `json:"lostrevenue"`
+	LostStorageCollateral types.Currency `json:"loststoragecollateral"`
+}
+
+// dayNumber returns the number of days between the unix epoch and t.
+func dayNumber(t time.Time) int64 {
+	return t.Unix() / secondsPerDay
+}
+
+// financialDayRecord returns the record of the day containing 'now', creating
+// it if necessary, and updates its collateral to the current financial
+// metrics. Records that are too old to be kept are dropped. It should be
+// called after every change to the financial metrics that is to be reported.
+func (h *Host) financialDayRecord(now time.Time) *financialDay {
+	today := dayNumber(now)
+	if h.financialHistory == nil {
+		h.financialHistory = make(map[int64]*financialDay)
+	}
+	fd, exists := h.financialHistory[today]
+	if !exists {
+		fd = &financialDay{Day: today}
+		h.financialHistory[today] = fd
+		for day := range h.financialHistory {
+			if day <= today-financialHistoryDays {
+				delete(h.financialHistory, day)
+			}
+		}
+	}
+	fd.LockedStorageCollateral = h.financialMetrics.LockedStorageCollateral
+	fd.RiskedStorageCollateral = h.financialMetrics.RiskedStorageCollateral
+	return fd
+}
+
+// recordCollateral records the collateral that is currently committed to
+// contracts in the financial history.
+func (h *Host) recordCollateral() {
+	h.financialDayRecord(time.Now())
+}
+
+// recordContractFormed records a new storage obligation in the financial
+// history.
+func (h *Host) recordContractFormed() {
+	h.financialDayRecord(time.Now()).ContractsFormed++
+}
+
+// recordObligationResolved records the revenue or losses of a storage
+// obligation in the financial history.
+func (h *Host) recordObligationResolved(so storageObligation, sos storageObligationStatus) {
+	fd := h.financialDayRecord(time.Now())
+	switch sos {
+	case obligationSucceeded:
+		fd.SuccessfulProofs++
+		fd.ContractCompensation = fd.ContractCompensation.Add(so.ContractCost)
+		fd.StorageRevenue = fd.StorageRevenue.Add(so.PotentialStorageRevenue)
+		fd.DownloadBandwidthRevenue = fd.DownloadBandwidthRevenue.Add(so.PotentialDownloadRevenue)
+		fd.UploadBandwidthRevenue = fd.UploadBandwidthRevenue.Add(so.PotentialUploadRevenue)
+	case obligationFailed:
+		fd.MissedProofs++
+		fd.LostStorageCollateral = fd.LostStorageCollateral.Add(so.RiskedCollateral)
+		fd.LostRevenue = fd.LostRevenue.Add(so.ContractCost).Add(so.PotentialStorageRevenue).Add(so.PotentialDownloadRevenue).Add(so.PotentialUploadRevenue)
+	}
+}
+
+// persistFinancialHistory returns the financial history in the order of its
+// days, for saving.
+func (h *Host) persistFinancialHistory() []financialDay {
+	days := make([]financialDay, 0, len(h.financialHistory))
+	for _, fd := range h.financialHistory {
+		days = append(days, *fd)
+	}
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].Day < days[j].Day
+	})
+	return days
+}
+
+// periodStart returns the start of the period containing t.
+func periodStart(t time.Time, period string) time.Time {
+	t = t.UTC()
+	switch period {
+	case modules.HostFinancialPeriodWeek:
+		start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		// time.Sunday is 0, weeks start on Monday.
+		return start.AddDate(0, 0, -(int(start.Weekday())+6)%7)
+	case modules.HostFinancialPeriodMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// addPeriods moves t forward by n periods. n may be negative.
+func addPeriods(t time.Time, period string, n int) time.Time {
+	switch period {
+	case modules.HostFinancialPeriodWeek:
+		return t.AddDate(0, 0, 7*n)
+	case modules.HostFinancialPeriodMonth:
+		return t.AddDate(0, n, 0)
+	default:
+		return t.AddDate(0, 0, n)
+	}
+}
+
+// financialPeriods breaks the financial history down into the most recent
+// 'count' periods before 'now', oldest first.
+func (h *Host) financialPeriods(period string, count int, now time.Time) ([]modules.HostFinancialPeriod, error) {
+	if period != modules.HostFinancialPeriodDay && period != modules.HostFinancialPeriodWeek && period != modules.HostFinancialPeriodMonth {
+		return nil, errBadFinancialPeriod
+	}
+	if count <= 0 {
+		return nil, errBadFinancialPeriod
+	}
+	days := h.persistFinancialHistory()
+
+	current := periodStart(now, period)
+	periods := make([]modules.HostFinancialPeriod, count)
+	for i := range periods {
+		fp := &periods[i]
+		fp.Start = addPeriods(current, period, i-count+1)
+		fp.End = addPeriods(fp.Start, period, 1)
+		first, end := dayNumber(fp.Start), dayNumber(fp.End)
+
+		for _, fd := range days {
+			// The collateral of a period is carried over from the last day
+			// with activity, which may be in an earlier period.
+			if fd.Day >= end {
+				break
+			}
+			fp.LockedStorageCollateral = fd.LockedStorageCollateral
+			fp.RiskedStorageCollateral = fd.RiskedStorageCollateral
+			if fd.Day < first {
+				continue
+			}
+			fp.ContractsFormed += fd.ContractsFormed
+			fp.SuccessfulProofs += fd.SuccessfulProofs
+			fp.MissedProofs += fd.MissedProofs
+			fp.ContractCompensation = fp.ContractCompensation.Add(fd.ContractCompensation)
+			fp.StorageRevenue = fp.StorageRevenue.Add(fd.StorageRevenue)
+			fp.DownloadBandwidthRevenue = fp.DownloadBandwidthRevenue.Add(fd.DownloadBandwidthRevenue)
+			fp.UploadBandwidthRevenue = fp.UploadBandwidthRevenue.Add(fd.UploadBandwidthRevenue)
+			fp.LostRevenue = fp.LostRevenue.Add(fd.LostRevenue)
+			fp.LostStorageCollateral = fp.LostStorageCollateral.Add(fd.LostStorageCollateral)
+		}
+	}
+
+	// The ongoing period reports the collateral that is committed right now.
+	periods[count-1].LockedStorageCollateral = h.financialMetrics.LockedStorageCollateral
+	periods[count-1].RiskedStorageCollateral = h.financialMetrics.RiskedStorageCollateral
+	return periods, nil
+}
+
+// FinancialPeriods returns the financial activity of the host over the most
+// recent 'count' days, weeks, or months, oldest first.
+func (h *Host) FinancialPeriods(period string, count int) ([]modules.HostFinancialPeriod, error) {
+	err := h.tg.Add()
+	if err != nil {
+		return nil, err
+	}
+	defer h.tg.Done()
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.financialPeriods(period, count, time.Now())
+}
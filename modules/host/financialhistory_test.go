@@ -0,0 +1,95 @@
+package host
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestPeriodStart checks that days, weeks, and months start where expected.
+func TestPeriodStart(t *testing.T) {
+	// Thursday, 15 June 2017.
+	now := time.Date(2017, time.June, 15, 13, 30, 0, 0, time.UTC)
+	tests := []struct {
+		period string
+		start  time.Time
+	}{
+		{modules.HostFinancialPeriodDay, time.Date(2017, time.June, 15, 0, 0, 0, 0, time.UTC)},
+		{modules.HostFinancialPeriodWeek, time.Date(2017, time.June, 12, 0, 0, 0, 0, time.UTC)},
+		{modules.HostFinancialPeriodMonth, time.Date(2017, time.June, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		if start := periodStart(now, test.period); !start.Equal(test.start) {
+			t.Errorf("wrong start for %v: expected %v, got %v", test.period, test.start, start)
+		}
+	}
+
+	// A Sunday belongs to the week that started on the previous Monday.
+	sunday := time.Date(2017, time.June, 18, 0, 0, 0, 0, time.UTC)
+	if start := periodStart(sunday, modules.HostFinancialPeriodWeek); !start.Equal(tests[1].start) {
+		t.Error("Sunday was placed in the wrong week:", start)
+	}
+}
+
+// TestFinancialPeriods checks that the financial history is broken down into
+// periods, with collateral carried over between periods.
+func TestFinancialPeriods(t *testing.T) {
+	h := new(Host)
+	may := time.Date(2017, time.May, 20, 12, 0, 0, 0, time.UTC)
+	june := time.Date(2017, time.June, 10, 12, 0, 0, 0, time.UTC)
+
+	// A contract formed and a proof submitted in May.
+	h.financialMetrics.LockedStorageCollateral = types.NewCurrency64(100)
+	h.financialMetrics.RiskedStorageCollateral = types.NewCurrency64(50)
+	h.financialDayRecord(may).ContractsFormed++
+	fd := h.financialDayRecord(may)
+	fd.SuccessfulProofs++
+	fd.StorageRevenue = types.NewCurrency64(10)
+
+	// A missed proof in June.
+	h.financialMetrics.LockedStorageCollateral = types.NewCurrency64(60)
+	h.financialMetrics.RiskedStorageCollateral = types.NewCurrency64(30)
+	fd = h.financialDayRecord(june)
+	fd.MissedProofs++
+	fd.LostStorageCollateral = types.NewCurrency64(20)
+
+	// Break the history down by month, as of July.
+	h.financialMetrics.LockedStorageCollateral = types.NewCurrency64(40)
+	periods, err := h.financialPeriods(modules.HostFinancialPeriodMonth, 4, time.Date(2017, time.July, 2, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(periods) != 4 || !periods[0].Start.Equal(time.Date(2017, time.April, 1, 0, 0, 0, 0, time.UTC)) || !periods[3].End.Equal(time.Date(2017, time.August, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatal("wrong periods:", periods)
+	}
+	april, mayPeriod, junePeriod, july := periods[0], periods[1], periods[2], periods[3]
+	if april.ContractsFormed != 0 || !april.LockedStorageCollateral.IsZero() {
+		t.Errorf("period before any activity is not empty: %+v", april)
+	}
+	if mayPeriod.ContractsFormed != 1 || mayPeriod.SuccessfulProofs != 1 || mayPeriod.StorageRevenue.Cmp64(10) != 0 {
+		t.Errorf("wrong activity in May: %+v", mayPeriod)
+	}
+	if mayPeriod.LockedStorageCollateral.Cmp64(100) != 0 || mayPeriod.RiskedStorageCollateral.Cmp64(50) != 0 {
+		t.Errorf("wrong collateral in May: %+v", mayPeriod)
+	}
+	if junePeriod.MissedProofs != 1 || junePeriod.LostStorageCollateral.Cmp64(20) != 0 || !junePeriod.StorageRevenue.IsZero() {
+		t.Errorf("wrong activity in June: %+v", junePeriod)
+	}
+	if junePeriod.LockedStorageCollateral.Cmp64(60) != 0 {
+		t.Errorf("wrong collateral in June: %+v", junePeriod)
+	}
+	// The ongoing period reports the current collateral.
+	if july.MissedProofs != 0 || july.LockedStorageCollateral.Cmp64(40) != 0 {
+		t.Errorf("wrong ongoing period: %+v", july)
+	}
+
+	// Bad requests are rejected.
+	if _, err := h.financialPeriods("year", 1, june); err != errBadFinancialPeriod {
+		t.Error("expected errBadFinancialPeriod, got", err)
+	}
+	if _, err := h.financialPeriods(modules.HostFinancialPeriodDay, 0, june); err != errBadFinancialPeriod {
+		t.Error("expected errBadFinancialPeriod, got", err)
+	}
+}
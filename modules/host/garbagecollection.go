@@ -0,0 +1,206 @@
+package host
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// The sectors of a storage obligation are not removed when the obligation is
+// resolved. Instead, the host operator runs a garbage collection pass, which
+// removes the sectors of every resolved obligation whose contract has
+// expired, and reports how much space was reclaimed in each storage folder.
+// A contract has expired once its proof window has closed. The sectors of a
+// contract that never made it onto the blockchain can be removed right away,
+// but the sectors of a contract that was resolved early for any other reason
+// are kept until the end of its proof window. Once its sectors are removed,
+// the sector roots of the obligation are dropped as well.
+
+var (
+	// errGarbageCollectionRunning is returned if garbage collection is
+	// requested while a pass is already running.
+	errGarbageCollectionRunning = errors.New("garbage collection is already running")
+)
+
+// gcState is the state of garbage collection. It is guarded by the host
+// lock.
+type gcState struct {
+	running bool
+	last    modules.HostGarbageCollection
+}
+
+// collectable returns whether the sectors of the storage obligation can be
+// removed by garbage collection at the provided height.
+func (so storageObligation) collectable(blockHeight types.BlockHeight) bool {
+	if so.ObligationStatus == obligationUnresolved || len(so.SectorRoots) == 0 {
+		return false
+	}
+	if so.ObligationStatus == obligationRejected && !so.OriginConfirmed {
+		return true
+	}
+	return blockHeight > so.proofDeadline()
+}
+
+// addReclamations merges the space reclaimed from storage folders into the
+// report of a garbage collection pass.
+func addReclamations(gc *modules.HostGarbageCollection, reclamations []modules.StorageFolderReclamation) {
+	for _, r := range reclamations {
+		gc.SectorsReclaimed += r.Sectors
+		gc.BytesReclaimed += r.Bytes
+		merged := false
+		for i := range gc.Folders {
+			if gc.Folders[i].Index == r.Index {
+				gc.Folders[i].Sectors += r.Sectors
+				gc.Folders[i].Bytes += r.Bytes
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			gc.Folders = append(gc.Folders, r)
+		}
+	}
+}
+
+// managedScanGarbage looks through the storage obligations of the host,
+// returning the ids of the obligations whose sectors can be collected, the
+// number of sectors they hold, and the number of resolved obligations whose
+// sectors are kept until their proof window closes.
+func (h *Host) managedScanGarbage() (ids []types.FileContractID, sectors uint64, waiting uint64, err error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	blockHeight := h.blockHeight
+	err = h.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketStorageObligations).ForEach(func(_, soBytes []byte) error {
+			var so storageObligation
+			err := json.Unmarshal(soBytes, &so)
+			if err != nil {
+				return err
+			}
+			if so.collectable(blockHeight) {
+				ids = append(ids, so.id())
+				sectors += uint64(len(so.SectorRoots))
+			} else if so.ObligationStatus != obligationUnresolved && len(so.SectorRoots) != 0 {
+				waiting++
+			}
+			return nil
+		})
+	})
+	return ids, sectors, waiting, err
+}
+
+// managedCollectObligation removes the sectors of a storage obligation whose
+// contract has expired, adding the space reclaimed to the report.
+func (h *Host) managedCollectObligation(soid types.FileContractID, gc *modules.HostGarbageCollection) error {
+	h.managedLockStorageObligation(soid)
+	defer h.managedUnlockStorageObligation(soid)
+
+	var so storageObligation
+	h.mu.RLock()
+	blockHeight := h.blockHeight
+	err := h.db.View(func(tx *bolt.Tx) error {
+		var err error
+		so, err = getStorageObligation(tx, soid)
+		return err
+	})
+	h.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	if !so.collectable(blockHeight) {
+		return nil
+	}
+
+	// Sectors that could not be removed are not retried, as the other
+	// sectors of the obligation have already been removed.
+	reclamations, err := h.RemoveSectorBatch(so.SectorRoots)
+	if err != nil {
+		h.log.Println("Error removing the sectors of expired storage obligation", soid, ":", err)
+	}
+	gc.Obligations++
+	gc.SectorsReleased += uint64(len(so.SectorRoots))
+	addReclamations(gc, reclamations)
+
+	so.SectorRoots = nil
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.db.Update(func(tx *bolt.Tx) error {
+		return putStorageObligation(tx, so)
+	})
+}
+
+// CollectGarbage removes the sectors of the storage obligations whose
+// contracts have expired, and reports how much space was reclaimed in each
+// storage folder.
+func (h *Host) CollectGarbage() (modules.HostGarbageCollection, error) {
+	err := h.tg.Add()
+	if err != nil {
+		return modules.HostGarbageCollection{}, err
+	}
+	defer h.tg.Done()
+
+	h.mu.Lock()
+	if h.gc.running {
+		h.mu.Unlock()
+		return modules.HostGarbageCollection{}, errGarbageCollectionRunning
+	}
+	h.gc.running = true
+	h.mu.Unlock()
+
+	gc := modules.HostGarbageCollection{StartTime: time.Now()}
+	defer func() {
+		h.mu.Lock()
+		h.gc.running = false
+		h.gc.last = gc
+		h.mu.Unlock()
+	}()
+
+	ids, _, _, err := h.managedScanGarbage()
+	if err != nil {
+		return modules.HostGarbageCollection{}, err
+	}
+	for _, soid := range ids {
+		select {
+		case <-h.tg.StopChan():
+			gc.EndTime = time.Now()
+			return gc, errors.New("garbage collection interrupted by shutdown")
+		default:
+		}
+		err := h.managedCollectObligation(soid, &gc)
+		if err != nil {
+			h.log.Println("Error collecting expired storage obligation", soid, ":", err)
+		}
+	}
+	gc.EndTime = time.Now()
+	h.log.Printf("Garbage collection removed the sectors of %v expired storage obligations, reclaiming %v sectors (%v bytes)", gc.Obligations, gc.SectorsReclaimed, gc.BytesReclaimed)
+	return gc, nil
+}
+
+// GarbageCollectionStatus returns the report of the most recent garbage
+// collection pass, along with the sectors that the next pass would collect.
+func (h *Host) GarbageCollectionStatus() (modules.HostGarbageCollectionStatus, error) {
+	err := h.tg.Add()
+	if err != nil {
+		return modules.HostGarbageCollectionStatus{}, err
+	}
+	defer h.tg.Done()
+
+	ids, sectors, waiting, err := h.managedScanGarbage()
+	if err != nil {
+		return modules.HostGarbageCollectionStatus{}, err
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return modules.HostGarbageCollectionStatus{
+		Running:                h.gc.running,
+		LastCollection:         h.gc.last,
+		CollectableObligations: uint64(len(ids)),
+		CollectableSectors:     sectors,
+		WaitingObligations:     waiting,
+	}, nil
+}
@@ -0,0 +1,64 @@
+package host
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestCollectable checks that the sectors of a storage obligation are only
+// collected once its contract has expired.
+func TestCollectable(t *testing.T) {
+	so := storageObligation{
+		SectorRoots:            []crypto.Hash{{}},
+		OriginTransactionSet:   []types.Transaction{{FileContracts: []types.FileContract{{WindowEnd: 100}}}},
+		RevisionTransactionSet: []types.Transaction{{FileContractRevisions: []types.FileContractRevision{{NewWindowEnd: 100}}}},
+	}
+	if so.collectable(200) {
+		t.Error("unresolved obligation is collectable")
+	}
+
+	// A rejected contract that never made it onto the blockchain can be
+	// collected right away.
+	so.ObligationStatus = obligationRejected
+	if !so.collectable(50) {
+		t.Error("unconfirmed rejected obligation is not collectable")
+	}
+
+	// Other resolved obligations are kept until the proof window closes.
+	so.OriginConfirmed = true
+	so.ObligationStatus = obligationSucceeded
+	if so.collectable(so.proofDeadline()) {
+		t.Error("obligation is collectable before its proof window closed")
+	}
+	if !so.collectable(so.proofDeadline() + 1) {
+		t.Error("expired obligation is not collectable")
+	}
+
+	// Obligations whose sectors have been collected are not collected again.
+	so.SectorRoots = nil
+	if so.collectable(so.proofDeadline() + 1) {
+		t.Error("collected obligation is collectable")
+	}
+}
+
+// TestAddReclamations checks that the space reclaimed from each storage
+// folder is merged into the garbage collection report.
+func TestAddReclamations(t *testing.T) {
+	var gc modules.HostGarbageCollection
+	addReclamations(&gc, []modules.StorageFolderReclamation{
+		{Index: 0, Sectors: 2, Bytes: 2 * modules.SectorSize},
+		{Index: 1, Sectors: 1, Bytes: modules.SectorSize},
+	})
+	addReclamations(&gc, []modules.StorageFolderReclamation{
+		{Index: 1, Sectors: 3, Bytes: 3 * modules.SectorSize},
+	})
+	if gc.SectorsReclaimed != 6 || gc.BytesReclaimed != 6*modules.SectorSize {
+		t.Fatal("wrong totals:", gc.SectorsReclaimed, gc.BytesReclaimed)
+	}
+	if len(gc.Folders) != 2 || gc.Folders[0].Sectors != 2 || gc.Folders[1].Sectors != 4 {
+		t.Fatal("folders were not merged:", gc.Folders)
+	}
+}
@@ -0,0 +1,402 @@
+package host
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// A retiring host hands off the sectors of a contract by pushing them to the
+// replacement host named in a renter-signed authorization. The replacement
+// host stages the sectors, holding them in the storage manager without
+// adding them to any storage obligation, until the renter attaches them to
+// its contract with an ActionAttach revision or the authorization expires.
+
+var (
+	// errHandoffExpired is returned when a handoff authorization has expired,
+	// or expires too far in the future.
+	errHandoffExpired = ErrorCommunication("handoff authorization has expired or expires too far in the future")
+
+	// errHandoffSectorCount is returned when a handoff authorization lists no
+	// sectors, or more sectors than the host allows.
+	errHandoffSectorCount = ErrorCommunication("handoff authorization lists an invalid number of sectors")
+
+	// errHandoffUnknownSector is returned when a handoff authorization lists
+	// a sector that is not stored in the contract being handed off.
+	errHandoffUnknownSector = ErrorCommunication("handoff authorization lists a sector that is not in the contract")
+
+	// errHandoffWrongHost is returned to a retiring host that hands off
+	// sectors to a host other than the one named in the authorization.
+	errHandoffWrongHost = ErrorCommunication("handoff authorization names a different replacement host")
+
+	// errBadHandoffSector is returned when a retiring host sends a sector
+	// that does not match the Merkle root listed in the authorization.
+	errBadHandoffSector = ErrorCommunication("handed off sector does not match its Merkle root")
+
+	// errNotHandedOff is returned when a renter attempts to attach a sector
+	// that has not been handed off to the host for the contract.
+	errNotHandedOff = ErrorCommunication("renter is attempting to attach a sector that has not been handed off to the host")
+)
+
+// A stagedHandoff is a set of sectors that has been handed off to the host
+// for a contract, but not yet attached to the contract by the renter.
+type stagedHandoff struct {
+	ContractID types.FileContractID `json:"contractid"`
+	Roots      []crypto.Hash        `json:"roots"`
+	Expiration types.BlockHeight    `json:"expiration"`
+}
+
+// handoffDeadline returns the amount of time that a handoff of n sectors is
+// allowed to take.
+func handoffDeadline(n int) time.Duration {
+	return modules.NegotiateHandoffTime + time.Duration(n)*modules.NegotiateFileContractRevisionTime
+}
+
+// renterKey returns the renter's public key for a storage obligation.
+func (so storageObligation) renterKey() (types.SiaPublicKey, error) {
+	if len(so.RevisionTransactionSet) == 0 {
+		return types.SiaPublicKey{}, errInsaneStorageObligationRevision
+	}
+	txn := so.RevisionTransactionSet[len(so.RevisionTransactionSet)-1]
+	if len(txn.FileContractRevisions) == 0 {
+		return types.SiaPublicKey{}, errInsaneRevisionSetRevisionCount
+	}
+	uc := txn.FileContractRevisions[0].UnlockConditions
+	if len(uc.PublicKeys) != 2 {
+		return types.SiaPublicKey{}, errRevisionWrongPublicKeyCount
+	}
+	return uc.PublicKeys[0], nil
+}
+
+// checkHandoff checks the parts of a handoff authorization that both the
+// retiring and the replacement host verify.
+func checkHandoff(so storageObligation, ha modules.HandoffAuthorization, blockHeight types.BlockHeight) error {
+	if ha.Expiration <= blockHeight || ha.Expiration > blockHeight+handoffMaxDuration {
+		return errHandoffExpired
+	}
+	if len(ha.SectorRoots) == 0 || len(ha.SectorRoots) > handoffMaxSectors {
+		return errHandoffSectorCount
+	}
+	if so.expiration()-revisionSubmissionBuffer <= blockHeight {
+		return errLateRevision
+	}
+	return nil
+}
+
+// managedStorageObligation returns the storage obligation with the given id.
+func (h *Host) managedStorageObligation(id types.FileContractID) (so storageObligation, err error) {
+	err = h.db.View(func(tx *bolt.Tx) error {
+		so, err = getStorageObligation(tx, id)
+		return err
+	})
+	return so, err
+}
+
+// managedRPCHandoff handles a request from a renter to hand off the sectors
+// of one of its contracts to a replacement host. Once every sector has been
+// received by the replacement host, a signed handoff record is returned to
+// the renter.
+func (h *Host) managedRPCHandoff(conn net.Conn) error {
+	conn.SetDeadline(time.Now().Add(modules.NegotiateHandoffTime))
+
+	var ha modules.HandoffAuthorization
+	err := encoding.ReadObject(conn, &ha, modules.NegotiateMaxHandoffAuthorizationSize)
+	if err != nil {
+		return extendErr("could not read handoff authorization: ", ErrorConnection(err.Error()))
+	}
+
+	h.mu.RLock()
+	blockHeight := h.blockHeight
+	publicKey := h.publicKey
+	secretKey := h.secretKey
+	h.mu.RUnlock()
+
+	// Verify that the renter authorized the handoff, and that every sector
+	// being handed off belongs to the contract.
+	err = func() error {
+		so, err := h.managedStorageObligation(ha.ContractID)
+		if err != nil {
+			return extendErr("could not find storage obligation: ", ErrorCommunication(err.Error()))
+		}
+		if err := checkHandoff(so, ha, blockHeight); err != nil {
+			return err
+		}
+		renterKey, err := so.renterKey()
+		if err != nil {
+			return ErrorInternal(err.Error())
+		}
+		if err := ha.VerifyContractSignature(renterKey); err != nil {
+			return ErrorCommunication(err.Error())
+		}
+		roots := make(map[crypto.Hash]struct{}, len(so.SectorRoots))
+		for _, root := range so.SectorRoots {
+			roots[root] = struct{}{}
+		}
+		for _, root := range ha.SectorRoots {
+			if _, ok := roots[root]; !ok {
+				return errHandoffUnknownSector
+			}
+		}
+		return nil
+	}()
+	if err != nil {
+		modules.WriteNegotiationRejection(conn, err) // Error is ignored so that the error type can be preserved in extendErr.
+		return extendErr("rejected handoff authorization: ", err)
+	}
+	if err := modules.WriteNegotiationAcceptance(conn); err != nil {
+		return extendErr("could not accept handoff authorization: ", ErrorConnection(err.Error()))
+	}
+
+	// Push the sectors to the replacement host. The renter waits while the
+	// sectors are transferred.
+	conn.SetDeadline(time.Now().Add(handoffDeadline(len(ha.SectorRoots))))
+	err = h.managedPushHandoff(ha)
+	if err != nil {
+		modules.WriteNegotiationRejection(conn, err) // Error is ignored so that the error type can be preserved in extendErr.
+		return extendErr("could not hand off sectors: ", err)
+	}
+	h.log.Printf("Handed off %v sectors of contract %v to %v", len(ha.SectorRoots), ha.ContractID, ha.ReplacementHost)
+
+	record := modules.HandoffRecord{
+		Authorization: ha,
+		Height:        blockHeight,
+		HostKey:       publicKey,
+	}
+	sig := crypto.SignHash(record.SigHash(), secretKey)
+	record.HostSignature = sig[:]
+	if err := modules.WriteNegotiationAcceptance(conn); err != nil {
+		return extendErr("could not accept handoff: ", ErrorConnection(err.Error()))
+	}
+	if err := encoding.WriteObject(conn, record); err != nil {
+		return extendErr("could not write handoff record: ", ErrorConnection(err.Error()))
+	}
+	return nil
+}
+
+// managedPushHandoff transfers the sectors listed in a handoff authorization
+// to the replacement host.
+func (h *Host) managedPushHandoff(ha modules.HandoffAuthorization) error {
+	conn, err := (&net.Dialer{
+		Cancel:  h.tg.StopChan(),
+		Timeout: 15 * time.Second,
+	}).Dial("tcp", string(ha.ReplacementHost))
+	if err != nil {
+		return extendErr("could not connect to replacement host: ", ErrorConnection(err.Error()))
+	}
+	defer conn.Close()
+	conn = modules.ScheduleConn(conn, modules.BandwidthClassHost)
+	conn.SetDeadline(time.Now().Add(modules.NegotiateHandoffTime))
+
+	if err := encoding.WriteObject(conn, modules.RPCReceiveHandoff); err != nil {
+		return extendErr("could not initiate handoff: ", ErrorConnection(err.Error()))
+	}
+	if err := encoding.WriteObject(conn, ha); err != nil {
+		return extendErr("could not write handoff authorization: ", ErrorConnection(err.Error()))
+	}
+	if err := modules.ReadNegotiationAcceptance(conn); err != nil {
+		return extendErr("replacement host rejected handoff: ", ErrorCommunication(err.Error()))
+	}
+
+	conn.SetDeadline(time.Now().Add(handoffDeadline(len(ha.SectorRoots))))
+	for _, root := range ha.SectorRoots {
+		sector, err := h.ReadSector(root)
+		if err != nil {
+			return extendErr("could not read sector: ", ErrorInternal(err.Error()))
+		}
+		if err := encoding.WriteObject(conn, sector); err != nil {
+			return extendErr("could not write sector: ", ErrorConnection(err.Error()))
+		}
+	}
+	if err := modules.ReadNegotiationAcceptance(conn); err != nil {
+		return extendErr("replacement host rejected sectors: ", ErrorCommunication(err.Error()))
+	}
+	return nil
+}
+
+// managedRPCReceiveHandoff handles a retiring host handing off sectors to
+// this host. The sectors are staged until the renter attaches them to its
+// contract, or the authorization expires.
+func (h *Host) managedRPCReceiveHandoff(conn net.Conn) error {
+	conn.SetDeadline(time.Now().Add(modules.NegotiateHandoffTime))
+
+	var ha modules.HandoffAuthorization
+	err := encoding.ReadObject(conn, &ha, modules.NegotiateMaxHandoffAuthorizationSize)
+	if err != nil {
+		return extendErr("could not read handoff authorization: ", ErrorConnection(err.Error()))
+	}
+
+	h.mu.RLock()
+	blockHeight := h.blockHeight
+	publicKey := h.publicKey
+	h.mu.RUnlock()
+
+	// Verify that the renter authorized the handoff to this host, and to a
+	// contract that the renter has with this host.
+	err = func() error {
+		if ha.ReplacementHostKey.String() != publicKey.String() {
+			return errHandoffWrongHost
+		}
+		so, err := h.managedStorageObligation(ha.ReplacementContractID)
+		if err != nil {
+			return extendErr("could not find storage obligation: ", ErrorCommunication(err.Error()))
+		}
+		if err := checkHandoff(so, ha, blockHeight); err != nil {
+			return err
+		}
+		renterKey, err := so.renterKey()
+		if err != nil {
+			return ErrorInternal(err.Error())
+		}
+		if err := ha.VerifyReplacementSignature(renterKey); err != nil {
+			return ErrorCommunication(err.Error())
+		}
+		return nil
+	}()
+	if err != nil {
+		modules.WriteNegotiationRejection(conn, err) // Error is ignored so that the error type can be preserved in extendErr.
+		return extendErr("rejected handoff authorization: ", err)
+	}
+	if err := modules.WriteNegotiationAcceptance(conn); err != nil {
+		return extendErr("could not accept handoff authorization: ", ErrorConnection(err.Error()))
+	}
+
+	// Receive and stage the sectors. If any sector is bad, the sectors that
+	// were already staged are removed.
+	conn.SetDeadline(time.Now().Add(handoffDeadline(len(ha.SectorRoots))))
+	var staged []crypto.Hash
+	err = func() error {
+		for _, root := range ha.SectorRoots {
+			var sector []byte
+			err := encoding.ReadObject(conn, &sector, modules.SectorSize+16)
+			if err != nil {
+				return extendErr("could not read sector: ", ErrorConnection(err.Error()))
+			}
+			if uint64(len(sector)) != modules.SectorSize || crypto.MerkleRoot(sector) != root {
+				return errBadHandoffSector
+			}
+			if err := h.AddSector(root, sector); err != nil {
+				return extendErr("could not add sector: ", ErrorInternal(err.Error()))
+			}
+			staged = append(staged, root)
+		}
+		return nil
+	}()
+	if err != nil {
+		for _, root := range staged {
+			_ = h.RemoveSector(root)
+		}
+		modules.WriteNegotiationRejection(conn, err) // Error is ignored so that the error type can be preserved in extendErr.
+		return extendErr("could not receive handoff: ", err)
+	}
+
+	h.mu.Lock()
+	h.handoffs = append(h.handoffs, stagedHandoff{
+		ContractID: ha.ReplacementContractID,
+		Roots:      staged,
+		Expiration: ha.Expiration,
+	})
+	err = h.saveSync()
+	h.mu.Unlock()
+	if err != nil {
+		h.log.Println("ERROR: could not save staged handoff:", err)
+	}
+	h.log.Printf("Received %v handed off sectors for contract %v", len(staged), ha.ReplacementContractID)
+	if err := modules.WriteNegotiationAcceptance(conn); err != nil {
+		return extendErr("could not accept handoff: ", ErrorConnection(err.Error()))
+	}
+	return nil
+}
+
+// stagedHandoffRoots returns the number of times each sector has been staged
+// for a contract.
+func (h *Host) stagedHandoffRoots(id types.FileContractID) map[crypto.Hash]int {
+	roots := make(map[crypto.Hash]int)
+	for _, sh := range h.handoffs {
+		if sh.ContractID != id {
+			continue
+		}
+		for _, root := range sh.Roots {
+			roots[root]++
+		}
+	}
+	return roots
+}
+
+// managedReleaseHandoffSectors unstages sectors that have been attached to a
+// contract. The storage obligation now holds its own reference to each
+// sector, so the reference held by the staged handoff is removed.
+func (h *Host) managedReleaseHandoffSectors(id types.FileContractID, attached []crypto.Hash) {
+	h.mu.Lock()
+	for _, root := range attached {
+		for i := range h.handoffs {
+			if h.handoffs[i].ContractID != id {
+				continue
+			}
+			if j := indexOfRoot(h.handoffs[i].Roots, root); j != -1 {
+				h.handoffs[i].Roots = append(h.handoffs[i].Roots[:j], h.handoffs[i].Roots[j+1:]...)
+				break
+			}
+		}
+	}
+	handoffs := h.handoffs[:0]
+	for _, sh := range h.handoffs {
+		if len(sh.Roots) > 0 {
+			handoffs = append(handoffs, sh)
+		}
+	}
+	h.handoffs = handoffs
+	err := h.saveSync()
+	h.mu.Unlock()
+	if err != nil {
+		h.log.Println("ERROR: could not save after attaching handed off sectors:", err)
+	}
+
+	for _, root := range attached {
+		if err := h.RemoveSector(root); err != nil {
+			h.log.Println("WARN: could not remove staging reference of handed off sector:", err)
+		}
+	}
+}
+
+// expiredHandoffs removes the staged handoffs that have expired, returning
+// their sectors.
+func (h *Host) expiredHandoffs() (roots []crypto.Hash) {
+	handoffs := h.handoffs[:0]
+	for _, sh := range h.handoffs {
+		if sh.Expiration < h.blockHeight {
+			roots = append(roots, sh.Roots...)
+		} else {
+			handoffs = append(handoffs, sh)
+		}
+	}
+	h.handoffs = handoffs
+	return roots
+}
+
+// threadedRemoveHandoffSectors removes the sectors of expired handoffs from
+// the storage manager.
+func (h *Host) threadedRemoveHandoffSectors(roots []crypto.Hash, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for _, root := range roots {
+		if err := h.RemoveSector(root); err != nil {
+			h.log.Println("WARN: could not remove expired handed off sector:", err)
+		}
+	}
+}
+
+// indexOfRoot returns the index of root in roots, or -1 if it is not present.
+func indexOfRoot(roots []crypto.Hash, root crypto.Hash) int {
+	for i, r := range roots {
+		if r == root {
+			return i
+		}
+	}
+	return -1
+}
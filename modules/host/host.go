@@ -83,6 +83,7 @@ const (
 	// Names of the various persistent files in the host.
 	dbFilename   = modules.HostDir + ".db"
 	logFile      = modules.HostDir + ".log"
+	restoreFile  = modules.HostDir + ".restore"
 	settingsFile = modules.HostDir + ".json"
 )
 
@@ -122,6 +123,7 @@ type Host struct {
 	atomicRenewCalls          uint64
 	atomicReviseCalls         uint64
 	atomicRecentRevisionCalls uint64
+	atomicRejectedCalls       uint64
 	atomicSettingsCalls       uint64
 	atomicUnrecognizedCalls   uint64
 
@@ -156,6 +158,9 @@ type Host struct {
 	// otherwise are not critical to always be correct.
 	autoAddress      modules.NetAddress // Determined using automatic tooling in network.go
 	financialMetrics modules.HostFinancialMetrics
+	financialHistory map[int64]*financialDay // see financialhistory.go
+	renterContracts  map[string]int          // active contracts per renter, see acceptance.go
+	renterStorage    map[string]uint64       // data stored per renter, see acceptance.go
 	settings         modules.HostInternalSettings
 	revisionNumber   uint64
 
@@ -164,6 +169,35 @@ type Host struct {
 	// be locked separately.
 	lockedStorageObligations map[types.FileContractID]*siasync.TryMutex
 
+	// Admission control for incoming RPCs. Each limiter bounds the number of
+	// RPCs of one type that are handled at once, queueing the excess for a
+	// short while before turning it away.
+	downloadLimiter    rpcLimiter
+	negotiationLimiter rpcLimiter
+	revisionLimiter    rpcLimiter
+
+	// Renter abuse detection, see abuse.go.
+	abuse abuseTracker
+
+	// Per-renter bandwidth metering and limits, see renterbandwidth.go.
+	bandwidth bandwidthTracker
+
+	// Sectors that other hosts have handed off to the host, see handoff.go.
+	handoffs []stagedHandoff
+
+	// Relay connections, see relay.go.
+	relay relayState
+
+	// The address that the host last announced, see announce.go.
+	announcement announcementState
+
+	// Checks of the host's own reachability, see reachability.go.
+	reachability reachabilityState
+
+	// Garbage collection of the sectors of expired storage obligations, see
+	// garbagecollection.go.
+	gc gcState
+
 	// Utilities.
 	db         *persist.BoltDatabase
 	listener   net.Listener
@@ -223,6 +257,12 @@ func newHost(dependencies dependencies, cs modules.ConsensusSet, tpool modules.T
 
 		lockedStorageObligations: make(map[types.FileContractID]*siasync.TryMutex),
 
+		relay: relayState{
+			hosts:   make(map[string]net.Listener),
+			ports:   make(map[string]string),
+			pending: make(map[relayToken]net.Conn),
+		},
+
 		persistDir: persistDir,
 	}
 
@@ -255,6 +295,14 @@ func newHost(dependencies dependencies, cs modules.ConsensusSet, tpool modules.T
 		}
 	})
 
+	// Restore a staged backup before the storage manager and the database
+	// are opened.
+	err = h.applyRestore()
+	if err != nil {
+		h.log.Println("Could not restore the staged backup:", err)
+		return nil, err
+	}
+
 	// Add the storage manager to the host, and set up the stop call that will
 	// close the storage manager.
 	h.StorageManager, err = contractmanager.New(filepath.Join(persistDir, "contractmanager"))
@@ -356,20 +404,38 @@ func (h *Host) SetInternalSettings(settings modules.HostInternalSettings) error
 	}
 
 	if settings.NetAddress != "" {
-		err := settings.NetAddress.IsValid()
+		na, err := normalizeAnnounceAddress(string(settings.NetAddress), h.port)
 		if err != nil {
 			return errors.New("internal settings not updated, invalid NetAddress: " + err.Error())
 		}
+		settings.NetAddress = na
+	}
+
+	if settings.RelayAddress != "" {
+		err := settings.RelayAddress.IsValid()
+		if err != nil {
+			return errors.New("internal settings not updated, invalid RelayAddress: " + err.Error())
+		}
+	}
+
+	err = checkRenterFilters(settings)
+	if err != nil {
+		return errors.New("internal settings not updated: " + err.Error())
 	}
 
 	// Check if the net address for the host has changed. If it has, and it's
 	// not equal to the auto address, then the host is going to need to make
-	// another blockchain announcement.
+	// another blockchain announcement. The same goes for a change of relay,
+	// which changes the address that the host is reached at.
 	if h.settings.NetAddress != settings.NetAddress && settings.NetAddress != h.autoAddress {
 		h.announced = false
 	}
+	if h.settings.RelayAddress != settings.RelayAddress && settings.NetAddress == "" {
+		h.announced = false
+	}
 
 	h.settings = settings
+	h.bandwidth.setLimits(settings.MaxRenterDownloadSpeed, settings.MaxRenterUploadSpeed)
 	h.revisionNumber++
 
 	err = h.saveSync()
@@ -84,6 +84,7 @@ func (h *Host) managedDownloadIteration(conn net.Conn, so *storageObligation) er
 		expectedTransfer := settings.MinDownloadBandwidthPrice.Mul64(totalSize)
 		err = verifyPaymentRevision(existingRevision, paymentRevision, blockHeight, expectedTransfer)
 		if err != nil {
+			h.abuse.record(conn, abuseUnderpaidDownload, time.Now())
 			return extendErr("payment verification failed: ", err)
 		}
 
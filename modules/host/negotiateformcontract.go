@@ -106,6 +106,14 @@ func (h *Host) managedRPCFormContract(conn net.Conn) error {
 		return extendErr("could not read renter public key: ", ErrorConnection(err.Error()))
 	}
 
+	// Refuse renters that have been banned for abuse.
+	identifyRenter(conn, renterPK)
+	err = h.abuse.identify(conn, renterPK, time.Now())
+	if err != nil {
+		modules.WriteNegotiationRejection(conn, err) // Error ignored to preserve type in extendErr
+		return extendErr("renter refused: ", ErrorCommunication(err.Error()))
+	}
+
 	// The host verifies that the file contract coming over the wire is
 	// acceptable.
 	err = h.managedVerifyNewContract(txnSet, renterPK)
@@ -289,6 +297,12 @@ func (h *Host) managedVerifyNewContract(txnSet []types.Transaction, renterPK cry
 		return errBadUnlockHash
 	}
 
+	// The contract must pass the acceptance filters of the host.
+	err := h.managedCheckAcceptanceFilters(renterPK, fc, false)
+	if err != nil {
+		return err
+	}
+
 	// Check that the transaction set has enough fees on it to get into the
 	// blockchain.
 	setFee := modules.CalculateFee(txnSet)
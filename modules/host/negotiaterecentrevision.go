@@ -131,6 +131,18 @@ func (h *Host) managedRPCRecentRevision(conn net.Conn) (types.FileContractID, st
 		}
 	}()
 
+	// Refuse renters that have been banned for abuse. The renter has proven
+	// that it holds the key by answering the challenge.
+	var renterPK crypto.PublicKey
+	copy(renterPK[:], recentRevision.UnlockConditions.PublicKeys[0].Key)
+	identifyRenter(conn, renterPK)
+	err = h.abuse.identify(conn, renterPK, time.Now())
+	if err != nil {
+		modules.WriteNegotiationRejection(conn, err) // Error ignored to preserve type in extendErr
+		err = extendErr("renter refused: ", ErrorCommunication(err.Error()))
+		return types.FileContractID{}, storageObligation{}, err
+	}
+
 	// Send the file contract revision and the corresponding signatures to the
 	// renter.
 	err = modules.WriteNegotiationAcceptance(conn)
@@ -118,6 +118,14 @@ func (h *Host) managedRPCRenewContract(conn net.Conn) error {
 		return extendErr("unable to read renter public key: ", ErrorConnection(err.Error()))
 	}
 
+	// Refuse renters that have been banned for abuse.
+	identifyRenter(conn, renterPK)
+	err = h.abuse.identify(conn, renterPK, time.Now())
+	if err != nil {
+		modules.WriteNegotiationRejection(conn, err) // Error ignored to preserve type in extendErr
+		return extendErr("renter refused: ", ErrorCommunication(err.Error()))
+	}
+
 	h.mu.RLock()
 	settings := h.externalSettings()
 	h.mu.RUnlock()
@@ -192,6 +200,17 @@ func (h *Host) managedRPCRenewContract(conn net.Conn) error {
 		return extendErr("failed to finalize contract: ", err)
 	}
 	defer h.managedUnlockStorageObligation(newSOID)
+
+	// The data of the old contract now counts towards the renter's storage
+	// through the renewal. The renewal has already been formed, so an error
+	// only means that the data is counted twice until the old contract
+	// expires.
+	h.mu.Lock()
+	err = h.markRenewed(so.id())
+	h.mu.Unlock()
+	if err != nil {
+		h.log.Println("Unable to mark storage obligation as renewed:", err)
+	}
 	err = modules.WriteNegotiationAcceptance(conn)
 	if err != nil {
 		return extendErr("failed to write acceptance: ", ErrorConnection(err.Error()))
@@ -302,6 +321,12 @@ func (h *Host) managedVerifyRenewedContract(so storageObligation, txnSet []types
 		return errBadUnlockHash
 	}
 
+	// The contract must pass the acceptance filters of the host.
+	err := h.managedCheckAcceptanceFilters(renterPK, fc, true)
+	if err != nil {
+		return err
+	}
+
 	// Check that the transaction set has enough fees on it to get into the
 	// blockchain.
 	setFee := modules.CalculateFee(txnSet)
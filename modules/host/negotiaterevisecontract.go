@@ -41,6 +41,8 @@ func (h *Host) managedRevisionIteration(conn net.Conn, so *storageObligation, fi
 	settings := h.settings
 	secretKey := h.secretKey
 	blockHeight := h.blockHeight
+	staged := h.stagedHandoffRoots(so.id())
+	renterStorage := h.renterStorage[so.renter()]
 	h.mu.RUnlock()
 
 	// The renter is going to send its intended modifications, followed by the
@@ -65,11 +67,13 @@ func (h *Host) managedRevisionIteration(conn net.Conn, so *storageObligation, fi
 	var sectorsRemoved []crypto.Hash
 	var sectorsGained []crypto.Hash
 	var gainedSectorData [][]byte
+	var sectorsAttached []crypto.Hash
+	oldFileSize := uint64(len(so.SectorRoots)) * modules.SectorSize
 	err = func() error {
 		for _, modification := range modifications {
 			// Check that the index points to an existing sector root. If the type
-			// is ActionInsert, we permit inserting at the end.
-			if modification.Type == modules.ActionInsert {
+			// is ActionInsert or ActionAttach, we permit inserting at the end.
+			if modification.Type == modules.ActionInsert || modification.Type == modules.ActionAttach {
 				if modification.SectorIndex > uint64(len(so.SectorRoots)) {
 					return errBadModificationIndex
 				}
@@ -82,6 +86,32 @@ func (h *Host) managedRevisionIteration(conn net.Conn, so *storageObligation, fi
 			}
 
 			switch modification.Type {
+			case modules.ActionAttach:
+				// Check that the sector was handed off to the host for this
+				// contract.
+				var root crypto.Hash
+				copy(root[:], modification.Data)
+				if len(modification.Data) != len(root) || staged[root] == 0 {
+					return errNotHandedOff
+				}
+				staged[root]--
+				sector, err := h.ReadSector(root)
+				if err != nil {
+					return extendErr("could not read handed off sector: ", ErrorInternal(err.Error()))
+				}
+
+				// Update finances. The sector was uploaded by the retiring
+				// host, so the renter does not pay for upload bandwidth.
+				blocksRemaining := so.proofDeadline() - blockHeight
+				blockBytesCurrency := types.NewCurrency64(uint64(blocksRemaining)).Mul64(modules.SectorSize)
+				storageRevenue = storageRevenue.Add(settings.MinStoragePrice.Mul(blockBytesCurrency))
+				newCollateral = newCollateral.Add(settings.Collateral.Mul(blockBytesCurrency))
+
+				// Insert the sector into the root list.
+				sectorsGained = append(sectorsGained, root)
+				gainedSectorData = append(gainedSectorData, sector)
+				sectorsAttached = append(sectorsAttached, root)
+				so.SectorRoots = append(so.SectorRoots[:modification.SectorIndex], append([]crypto.Hash{root}, so.SectorRoots[modification.SectorIndex:]...)...)
 			case modules.ActionDelete:
 				// There is no financial information to change, it is enough to
 				// remove the sector.
@@ -135,6 +165,17 @@ func (h *Host) managedRevisionIteration(conn net.Conn, so *storageObligation, fi
 				return errUnknownModification
 			}
 		}
+		// Contracts may not grow past the maximum contract size of the
+		// host, nor take the renter past its storage quota. Contracts that
+		// are already larger, because the limit was lowered, may still
+		// shrink.
+		newFileSize := uint64(len(so.SectorRoots)) * modules.SectorSize
+		if settings.MaxContractSize != 0 && newFileSize > settings.MaxContractSize && newFileSize > oldFileSize {
+			return errContractTooLarge
+		}
+		if err := checkRenterStorage(settings, renterStorage, oldFileSize, newFileSize); err != nil {
+			return err
+		}
 		newRevenue := storageRevenue.Add(bandwidthRevenue)
 		return extendErr("unable to verify updated contract: ", verifyRevision(*so, revision, blockHeight, newRevenue, newCollateral))
 	}()
@@ -170,6 +211,9 @@ func (h *Host) managedRevisionIteration(conn net.Conn, so *storageObligation, fi
 		modules.WriteNegotiationRejection(conn, err) // Error is ignored so that the error type can be preserved in extendErr.
 		return extendErr("could not modify storage obligation: ", ErrorInternal(err.Error()))
 	}
+	if len(sectorsAttached) > 0 {
+		h.managedReleaseHandoffSectors(so.id(), sectorsAttached)
+	}
 
 	// Host will now send acceptance and its signature to the renter. This
 	// iteration is complete. If the finalIter flag is set, StopResponse will
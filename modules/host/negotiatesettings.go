@@ -17,6 +17,11 @@ func (h *Host) capacity() (total, remaining uint64) {
 	// folders.
 	sfs := h.StorageFolders()
 	for _, sf := range sfs {
+		// The sectors of a missing folder are unavailable until the folder
+		// is moved to its new location.
+		if sf.Missing {
+			continue
+		}
 		total += sf.Capacity
 		remaining += sf.CapacityRemaining
 	}
@@ -29,6 +34,8 @@ func (h *Host) externalSettings() modules.HostExternalSettings {
 	var netAddr modules.NetAddress
 	if h.settings.NetAddress != "" {
 		netAddr = h.settings.NetAddress
+	} else if ra := h.relayedAddress(); ra != "" {
+		netAddr = ra
 	} else {
 		netAddr = h.autoAddress
 	}
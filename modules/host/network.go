@@ -29,12 +29,14 @@ import (
 var rpcSettingsDeprecated = types.Specifier{'S', 'e', 't', 't', 'i', 'n', 'g', 's'}
 
 // threadedUpdateHostname periodically runs 'managedLearnHostname', which
-// checks if the host's hostname has changed, and makes an updated host
-// announcement if so.
+// checks if the host's hostname has changed, and 'managedCheckAnnouncement',
+// which makes an updated host announcement if the address of the host no
+// longer matches the address it announced.
 func (h *Host) threadedUpdateHostname(closeChan chan struct{}) {
 	defer close(closeChan)
 	for {
 		h.managedLearnHostname()
+		h.managedCheckAnnouncement()
 		// Wait 30 minutes to check again. If the hostname is changing
 		// regularly (more than once a week), we want the host to be able to be
 		// seen as having 95% uptime. Every minute that the announcement is
@@ -112,6 +114,12 @@ func (h *Host) initNetworking(address string) (err error) {
 		h.tg.OnStop(func() {
 			<-threadedUpdateHostnameClosedChan
 		})
+
+		threadedMaintainRelayClosedChan := make(chan struct{})
+		go h.threadedMaintainRelay(threadedMaintainRelayClosedChan)
+		h.tg.OnStop(func() {
+			<-threadedMaintainRelayClosedChan
+		})
 	}()
 
 	// Launch the listener.
@@ -128,6 +136,13 @@ func (h *Host) threadedHandleConn(conn net.Conn) {
 	}
 	defer h.tg.Done()
 
+	// Draw from the bandwidth budget that the host shares with the renter.
+	conn = modules.ScheduleConn(conn, modules.BandwidthClassHost)
+
+	// Count the traffic towards the renter's bandwidth, and hold the renter to
+	// its rate limits.
+	conn = newMeteredConn(conn, &h.bandwidth)
+
 	// Close the conn on host.Close or when the method terminates, whichever comes
 	// first.
 	connCloseChan := make(chan struct{})
@@ -156,6 +171,32 @@ func (h *Host) threadedHandleConn(conn net.Conn) {
 		return
 	}
 
+	// Refuse renters that have been banned for abuse, and slow down renters
+	// that have been throttled. Settings requests are always served, so that
+	// renters can still learn about the host.
+	if id != modules.RPCSettings && id != rpcSettingsDeprecated {
+		defer h.abuse.release(conn)
+		if !h.managedAdmitRenter(conn) {
+			atomic.AddUint64(&h.atomicRejectedCalls, 1)
+			h.log.Debugf("WARN: rejected incoming %v from %v: renter is banned", id, conn.RemoteAddr())
+			return
+		}
+	}
+
+	// Wait for the RPC to be admitted. If the host is saturated, the renter is
+	// told so and the connection is closed right away, so that the renter does
+	// not waste its session waiting on a host that cannot serve it.
+	limiter, limit := h.managedRPCLimiter(id)
+	if limiter != nil {
+		if !limiter.acquire(limit, rpcQueueTimeout, h.tg.StopChan()) {
+			atomic.AddUint64(&h.atomicRejectedCalls, 1)
+			h.log.Debugf("WARN: rejected incoming %v from %v: host is busy", id, conn.RemoteAddr())
+			modules.WriteNegotiationRejection(conn, errHostBusy) // Error is ignored, the connection is closed either way.
+			return
+		}
+		defer limiter.release()
+	}
+
 	switch id {
 	case modules.RPCDownload:
 		atomic.AddUint64(&h.atomicDownloadCalls, 1)
@@ -179,6 +220,14 @@ func (h *Host) threadedHandleConn(conn net.Conn) {
 			// the storage obligation that gets returned.
 			h.managedUnlockStorageObligation(so.id())
 		}
+	case modules.RPCHandoff:
+		err = extendErr("incoming RPCHandoff failed: ", h.managedRPCHandoff(conn))
+	case modules.RPCReceiveHandoff:
+		err = extendErr("incoming RPCReceiveHandoff failed: ", h.managedRPCReceiveHandoff(conn))
+	case modules.RPCRelayRegister:
+		err = extendErr("incoming RPCRelayRegister failed: ", h.managedRPCRelayRegister(conn))
+	case modules.RPCRelayAccept:
+		err = extendErr("incoming RPCRelayAccept failed: ", h.managedRPCRelayAccept(conn))
 	case modules.RPCSettings:
 		atomic.AddUint64(&h.atomicSettingsCalls, 1)
 		err = extendErr("incoming RPCSettings failed: ", h.managedRPCSettings(conn))
@@ -188,6 +237,7 @@ func (h *Host) threadedHandleConn(conn net.Conn) {
 		h.log.Debugf("WARN: incoming conn %v requested unknown RPC \"%v\"", conn.RemoteAddr(), id)
 		atomic.AddUint64(&h.atomicUnrecognizedCalls, 1)
 	}
+	h.managedRecordAbuse(conn, id, err)
 	if err != nil {
 		atomic.AddUint64(&h.atomicErroredCalls, 1)
 		err = extendErr("error with "+conn.RemoteAddr().String()+": ", err)
@@ -216,10 +266,19 @@ func (h *Host) threadedListen(closeChan chan struct{}) {
 func (h *Host) NetAddress() modules.NetAddress {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
+	return h.netAddress()
+}
 
+// netAddress returns the address at which the host can be reached, preferring
+// the address in the settings, then the address allocated by the relay, and
+// then the automatically discovered address.
+func (h *Host) netAddress() modules.NetAddress {
 	if h.settings.NetAddress != "" {
 		return h.settings.NetAddress
 	}
+	if ra := h.relayedAddress(); ra != "" {
+		return ra
+	}
 	return h.autoAddress
 }
 
@@ -232,6 +291,7 @@ func (h *Host) NetworkMetrics() modules.HostNetworkMetrics {
 		DownloadCalls:     atomic.LoadUint64(&h.atomicDownloadCalls),
 		ErrorCalls:        atomic.LoadUint64(&h.atomicErroredCalls),
 		FormContractCalls: atomic.LoadUint64(&h.atomicFormContractCalls),
+		RejectedCalls:     atomic.LoadUint64(&h.atomicRejectedCalls),
 		RenewCalls:        atomic.LoadUint64(&h.atomicRenewCalls),
 		ReviseCalls:       atomic.LoadUint64(&h.atomicReviseCalls),
 		SettingsCalls:     atomic.LoadUint64(&h.atomicSettingsCalls),
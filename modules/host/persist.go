@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
@@ -22,13 +23,28 @@ type persistence struct {
 
 	// Host Identity.
 	Announced        bool                         `json:"announced"`
+	AnnouncedAddress modules.NetAddress           `json:"announcedaddress"`
 	AutoAddress      modules.NetAddress           `json:"autoaddress"`
 	FinancialMetrics modules.HostFinancialMetrics `json:"financialmetrics"`
+	LastAnnouncement time.Time                    `json:"lastannouncement"`
 	PublicKey        types.SiaPublicKey           `json:"publickey"`
 	RevisionNumber   uint64                       `json:"revisionnumber"`
 	SecretKey        crypto.SecretKey             `json:"secretkey"`
 	Settings         modules.HostInternalSettings `json:"settings"`
 	UnlockHash       types.UnlockHash             `json:"unlockhash"`
+
+	// Financial history, by day.
+	FinancialHistory []financialDay `json:"financialhistory,omitempty"`
+
+	// Renter Abuse.
+	AbuseOverrides map[string]string `json:"abuseoverrides,omitempty"`
+
+	// Handed Off Sectors.
+	Handoffs []stagedHandoff `json:"handoffs,omitempty"`
+
+	// Reachability Checks.
+	ReachabilityAddress modules.NetAddress  `json:"reachabilityaddress,omitempty"`
+	ReachabilityHistory modules.HostDBScans `json:"reachabilityhistory,omitempty"`
 }
 
 // persistData returns the data in the Host that will be saved to disk.
@@ -40,16 +56,43 @@ func (h *Host) persistData() persistence {
 
 		// Host Identity.
 		Announced:        h.announced,
+		AnnouncedAddress: h.announcement.address,
 		AutoAddress:      h.autoAddress,
 		FinancialMetrics: h.financialMetrics,
+		LastAnnouncement: h.announcement.last,
 		PublicKey:        h.publicKey,
 		RevisionNumber:   h.revisionNumber,
 		SecretKey:        h.secretKey,
 		Settings:         h.settings,
 		UnlockHash:       h.unlockHash,
+
+		// Financial history, by day.
+		FinancialHistory: h.persistFinancialHistory(),
+
+		// Renter Abuse.
+		AbuseOverrides: h.abuse.persistOverrides(),
+
+		// Handed Off Sectors.
+		Handoffs: h.handoffs,
+
+		// Reachability Checks.
+		ReachabilityAddress: h.reachability.address,
+		ReachabilityHistory: h.reachability.history,
 	}
 }
 
+// newPersistence returns a persistence object to load the host's persistent
+// data into. Settings saved before the concurrency limits were introduced do
+// not contain them, so the limits are set to their defaults beforehand;
+// otherwise such hosts would load with the limits disabled.
+func newPersistence() *persistence {
+	p := new(persistence)
+	p.Settings.MaxConcurrentDownloads = defaultMaxConcurrentDownloads
+	p.Settings.MaxConcurrentNegotiations = defaultMaxConcurrentNegotiations
+	p.Settings.MaxConcurrentRevisions = defaultMaxConcurrentRevisions
+	return p
+}
+
 // establishDefaults configures the default settings for the host, overwriting
 // any existing settings.
 func (h *Host) establishDefaults() error {
@@ -60,6 +103,10 @@ func (h *Host) establishDefaults() error {
 		MaxReviseBatchSize:   uint64(defaultMaxReviseBatchSize),
 		WindowSize:           defaultWindowSize,
 
+		MaxConcurrentDownloads:    defaultMaxConcurrentDownloads,
+		MaxConcurrentNegotiations: defaultMaxConcurrentNegotiations,
+		MaxConcurrentRevisions:    defaultMaxConcurrentRevisions,
+
 		Collateral:       defaultCollateral,
 		CollateralBudget: defaultCollateralBudget,
 		MaxCollateral:    defaultMaxCollateral,
@@ -98,15 +145,43 @@ func (h *Host) loadPersistObject(p *persistence) {
 		h.autoAddress = ""
 	}
 	h.financialMetrics = p.FinancialMetrics
+	h.financialHistory = make(map[int64]*financialDay)
+	for i := range p.FinancialHistory {
+		h.financialHistory[p.FinancialHistory[i].Day] = &p.FinancialHistory[i]
+	}
 	h.publicKey = p.PublicKey
 	h.revisionNumber = p.RevisionNumber
 	h.secretKey = p.SecretKey
 	h.settings = p.Settings
+	h.bandwidth.setLimits(p.Settings.MaxRenterDownloadSpeed, p.Settings.MaxRenterUploadSpeed)
 	if err := p.Settings.NetAddress.IsValid(); err != nil {
 		h.log.Printf("WARN: NetAddress '%v' loaded from persist is invalid: %v", p.Settings.NetAddress, err)
 		h.settings.NetAddress = ""
 	}
 	h.unlockHash = p.UnlockHash
+
+	// Hosts that announced before the announced address was saved are
+	// assumed to have announced the address they would announce now, so that
+	// they do not re-announce right after upgrading.
+	h.announcement.address = p.AnnouncedAddress
+	h.announcement.last = p.LastAnnouncement
+	if h.announced && h.announcement.address == "" {
+		h.announcement.address = h.netAddress()
+	}
+
+	// Copy over renter abuse overrides, skipping any that are unrecognized.
+	for renter, override := range p.AbuseOverrides {
+		if err := h.abuse.setOverride(renter, override); err != nil {
+			h.log.Printf("WARN: abuse override '%v' for renter %v loaded from persist is invalid: %v", override, renter, err)
+		}
+	}
+
+	// Copy over the sectors that are staged for handoffs.
+	h.handoffs = p.Handoffs
+
+	// Copy over the reachability checks.
+	h.reachability.address = p.ReachabilityAddress
+	h.reachability.history = p.ReachabilityHistory
 }
 
 // initDB will check that the database has been initialized and if not, will
@@ -154,7 +229,7 @@ func (h *Host) load() error {
 	// Load the old persistence object from disk. Simple task if the version is
 	// the most recent version, but older versions need to be updated to the
 	// more recent structures.
-	p := new(persistence)
+	p := newPersistence()
 	err = h.dependencies.loadFile(persistMetadata, p, filepath.Join(h.persistDir, settingsFile))
 	if err == nil {
 		// Copy in the persistence.
@@ -184,6 +259,7 @@ func (h *Host) load() error {
 			}
 			if so.ObligationStatus == obligationUnresolved {
 				h.financialMetrics.ContractCount++
+				h.countRenterContract(so, 1)
 			}
 		}
 		return nil
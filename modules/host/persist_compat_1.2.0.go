@@ -400,7 +400,7 @@ func (h *Host) upgradeFromV112ToV120() error {
 	}
 
 	// Try loading the persist again.
-	p := new(persistence)
+	p := newPersistence()
 	err = h.dependencies.loadFile(v112PersistMetadata, p, filepath.Join(h.persistDir, settingsFile))
 	if err != nil {
 		return err
@@ -0,0 +1,360 @@
+package host
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/fastrand"
+)
+
+// A host cannot tell whether renters can reach it by connecting to itself, as
+// many routers do not loop connections to their own external address back
+// into the local network. Instead, the host periodically asks a peer of its
+// gateway to check on it. The peer, which must be a host of its own, connects
+// to the address of the host and fetches its signed settings, exactly as a
+// renter scanning the host would, and reports whether it succeeded. The
+// outcomes of the recent checks are kept, so that operators can see the uptime
+// that renters are likely to measure and fix problems before the host loses
+// contracts.
+//
+// A probing host only connects to addresses that resolve to the IP of the
+// node that asked for the probe, and runs at most one probe per probeCooldown
+// for each node, so that probes cannot be used to make hosts connect to
+// arbitrary machines.
+
+var (
+	// errNoProbers is returned if none of the gateway's peers answer host
+	// probes.
+	errNoProbers = errors.New("no connected peer is able to check the host's reachability")
+
+	// errProbeAddress is returned to a node that asks for a probe of an
+	// address that does not resolve to the node's own IP.
+	errProbeAddress = ErrorCommunication("probed address does not belong to the requesting node")
+
+	// errProbeTooFrequent is returned to a node that asks for probes more
+	// often than once per probeCooldown.
+	errProbeTooFrequent = ErrorCommunication("host probes requested too frequently")
+
+	// errProbeUnavailable is returned if the host checks its reachability
+	// before it has a gateway to find probing peers through.
+	errProbeUnavailable = errors.New("host is not connected to a gateway")
+)
+
+type (
+	// hostProbeRequest is sent by a host that asks a peer to check that it
+	// is reachable at Address. The signature of the settings fetched from
+	// the address is checked against PublicKey.
+	hostProbeRequest struct {
+		Address   modules.NetAddress
+		PublicKey types.SiaPublicKey
+	}
+
+	// hostProbeResponse is the outcome of a probe. Error is empty if the
+	// probed host was reached.
+	hostProbeResponse struct {
+		Error string
+	}
+
+	// reachabilityState holds the reachability checks of the host, and the
+	// probes that the host has run for other nodes. It is guarded by the host
+	// lock.
+	reachabilityState struct {
+		gateway modules.Gateway
+
+		address   modules.NetAddress
+		history   modules.HostDBScans
+		lastError string
+		prober    modules.NetAddress
+
+		probed map[string]time.Time
+	}
+)
+
+// uptimeRatio returns the fraction of the time covered by the reachability
+// checks during which the host was reachable. The time between two checks is
+// attributed to the outcome of the earlier check, as in the hostdb.
+func uptimeRatio(history modules.HostDBScans) float64 {
+	if len(history) == 0 {
+		return 0
+	}
+	if len(history) == 1 {
+		if history[0].Success {
+			return 1
+		}
+		return 0
+	}
+	var uptime, downtime time.Duration
+	for i := 1; i < len(history); i++ {
+		elapsed := history[i].Timestamp.Sub(history[i-1].Timestamp)
+		if history[i-1].Success {
+			uptime += elapsed
+		} else {
+			downtime += elapsed
+		}
+	}
+	if uptime+downtime <= 0 {
+		if history[len(history)-1].Success {
+			return 1
+		}
+		return 0
+	}
+	return float64(uptime) / float64(uptime+downtime)
+}
+
+// ownsAddress returns whether ip is one of the resolved addresses of a probed
+// address.
+func ownsAddress(resolved []string, ip string) bool {
+	target := net.ParseIP(ip)
+	if target == nil {
+		return false
+	}
+	for _, r := range resolved {
+		if rip := net.ParseIP(r); rip != nil && rip.Equal(target) {
+			return true
+		}
+	}
+	return false
+}
+
+// probeHost connects to the host at addr and fetches its settings, checking
+// that they are signed by pk.
+func probeHost(addr modules.NetAddress, pk types.SiaPublicKey, cancel <-chan struct{}) error {
+	if pk.Algorithm != types.SignatureEd25519 || len(pk.Key) != crypto.PublicKeySize {
+		return errors.New("probed host has an invalid public key")
+	}
+	dialer := &net.Dialer{
+		Cancel:  cancel,
+		Timeout: probeTimeout,
+	}
+	conn, err := dialer.Dial("tcp", string(addr))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(probeTimeout))
+
+	err = encoding.WriteObject(conn, modules.RPCSettings)
+	if err != nil {
+		return err
+	}
+	var pubkey crypto.PublicKey
+	copy(pubkey[:], pk.Key)
+	var settings modules.HostExternalSettings
+	return crypto.ReadSignedObject(conn, &settings, maxProbeSettingsLen, pubkey)
+}
+
+// rpcProbeHost is the RPC that checks that a peer's host is reachable.
+func (h *Host) rpcProbeHost(conn modules.PeerConn) error {
+	err := h.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer h.tg.Done()
+	conn.SetDeadline(time.Now().Add(probeTimeout * 2))
+
+	var req hostProbeRequest
+	err = encoding.ReadObject(conn, &req, modules.MaxEncodedNetAddressLength+256)
+	if err != nil {
+		return err
+	}
+	remoteIP, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	if time.Since(h.reachability.probed[remoteIP]) < probeCooldown {
+		h.mu.Unlock()
+		return errProbeTooFrequent
+	}
+	if h.reachability.probed == nil {
+		h.reachability.probed = make(map[string]time.Time)
+	}
+	for ip, t := range h.reachability.probed {
+		if time.Since(t) >= probeCooldown {
+			delete(h.reachability.probed, ip)
+		}
+	}
+	h.reachability.probed[remoteIP] = time.Now()
+	h.mu.Unlock()
+
+	if err := req.Address.IsValid(); err != nil {
+		return err
+	}
+	resolved := []string{req.Address.Host()}
+	if net.ParseIP(req.Address.Host()) == nil {
+		resolved, err = net.LookupHost(req.Address.Host())
+		if err != nil {
+			return err
+		}
+	}
+	if !ownsAddress(resolved, remoteIP) {
+		return errProbeAddress
+	}
+
+	var resp hostProbeResponse
+	if err := probeHost(req.Address, req.PublicKey, h.tg.StopChan()); err != nil {
+		resp.Error = err.Error()
+	}
+	return encoding.WriteObject(conn, resp)
+}
+
+// managedProbe asks the peer at addr to check that the host is reachable at
+// hostAddr, returning the outcome of the probe. An error is returned if the
+// peer did not run the probe.
+func (h *Host) managedProbe(g modules.Gateway, addr, hostAddr modules.NetAddress, pk types.SiaPublicKey) (probeErr string, err error) {
+	err = g.RPC(addr, "ProbeHost", func(conn modules.PeerConn) error {
+		conn.SetDeadline(time.Now().Add(probeTimeout * 2))
+		err := encoding.WriteObject(conn, hostProbeRequest{
+			Address:   hostAddr,
+			PublicKey: pk,
+		})
+		if err != nil {
+			return err
+		}
+		var resp hostProbeResponse
+		err = encoding.ReadObject(conn, &resp, 4096)
+		if err != nil {
+			return err
+		}
+		probeErr = resp.Error
+		return nil
+	})
+	return probeErr, err
+}
+
+// managedCheckReachability asks peers of the gateway to check that the host
+// is reachable at its address, and records the outcome.
+func (h *Host) managedCheckReachability() error {
+	h.mu.RLock()
+	g := h.reachability.gateway
+	addr := h.netAddress()
+	relayed := h.relayedAddress()
+	pk := h.publicKey
+	h.mu.RUnlock()
+	if g == nil {
+		return errProbeUnavailable
+	}
+	if addr == "" {
+		return errUnknownAddress
+	}
+	// A relayed address belongs to the relay, which would refuse to probe
+	// it on the host's behalf.
+	if addr == relayed {
+		return nil
+	}
+
+	var probers []modules.NetAddress
+	for _, p := range g.Peers() {
+		if p.Supports(modules.CapabilityHostProbe, hostProbeVersion) {
+			probers = append(probers, p.NetAddress)
+		}
+	}
+	if len(probers) == 0 {
+		return errNoProbers
+	}
+
+	var prober modules.NetAddress
+	var probeErr string
+	var err error
+	for i, j := range fastrand.Perm(len(probers)) {
+		if i == probeAttempts {
+			break
+		}
+		prober = probers[j]
+		probeErr, err = h.managedProbe(g, prober, addr, pk)
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	scan := modules.HostDBScan{Timestamp: time.Now(), Success: probeErr == ""}
+	h.mu.Lock()
+	wasReachable := len(h.reachability.history) == 0 || h.reachability.history[len(h.reachability.history)-1].Success
+	if addr != h.reachability.address {
+		// Checks of a previous address say nothing about the current one.
+		h.reachability.history = nil
+	}
+	h.reachability.address = addr
+	h.reachability.history = append(h.reachability.history, scan)
+	if len(h.reachability.history) > reachabilityHistoryLen {
+		h.reachability.history = h.reachability.history[len(h.reachability.history)-reachabilityHistoryLen:]
+	}
+	h.reachability.lastError = probeErr
+	h.reachability.prober = prober
+	err = h.save()
+	h.mu.Unlock()
+
+	if !scan.Success && wasReachable {
+		h.log.Printf("WARN: host is not reachable at %v according to %v: %v", addr, prober, probeErr)
+	} else if scan.Success && !wasReachable {
+		h.log.Printf("INFO: host is reachable at %v again according to %v", addr, prober)
+	}
+	return err
+}
+
+// threadedCheckReachability periodically checks that the host is reachable.
+func (h *Host) threadedCheckReachability() {
+	err := h.tg.Add()
+	if err != nil {
+		return
+	}
+	defer h.tg.Done()
+
+	for {
+		select {
+		case <-h.tg.StopChan():
+			return
+		case <-time.After(reachabilityCheckInterval):
+		}
+		err := h.managedCheckReachability()
+		if err != nil && err != errNoProbers {
+			h.log.Debugln("Unable to check the host's reachability:", err)
+		}
+	}
+}
+
+// UseGateway lets the host check its reachability through the peers of the
+// gateway, and check the reachability of its peers in return.
+func (h *Host) UseGateway(g modules.Gateway) {
+	h.mu.Lock()
+	h.reachability.gateway = g
+	h.mu.Unlock()
+
+	g.RegisterRPC("ProbeHost", h.rpcProbeHost)
+	g.RegisterCapability(modules.CapabilityHostProbe)
+	h.tg.OnStop(func() {
+		g.UnregisterRPC("ProbeHost")
+		g.UnregisterCapability(modules.CapabilityHostProbe)
+	})
+	go h.threadedCheckReachability()
+}
+
+// Reachability returns the results of the host's checks of its own
+// reachability.
+func (h *Host) Reachability() modules.HostReachability {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	r := modules.HostReachability{
+		Address:   h.reachability.address,
+		LastError: h.reachability.lastError,
+		Prober:    h.reachability.prober,
+		Uptime:    uptimeRatio(h.reachability.history),
+		History:   append(modules.HostDBScans(nil), h.reachability.history...),
+	}
+	if len(r.History) != 0 {
+		last := r.History[len(r.History)-1]
+		r.LastCheck = last.Timestamp
+		r.Reachable = last.Success
+	}
+	return r
+}
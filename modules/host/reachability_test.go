@@ -0,0 +1,56 @@
+package host
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestUptimeRatio checks that the time between reachability checks is
+// attributed to the outcome of the earlier check.
+func TestUptimeRatio(t *testing.T) {
+	start := time.Now()
+	scan := func(hours int, success bool) modules.HostDBScan {
+		return modules.HostDBScan{Timestamp: start.Add(time.Duration(hours) * time.Hour), Success: success}
+	}
+	tests := []struct {
+		history modules.HostDBScans
+		uptime  float64
+	}{
+		{nil, 0},
+		{modules.HostDBScans{scan(0, true)}, 1},
+		{modules.HostDBScans{scan(0, false)}, 0},
+		{modules.HostDBScans{scan(0, true), scan(1, true)}, 1},
+		{modules.HostDBScans{scan(0, true), scan(3, false), scan(4, true)}, 0.75},
+		{modules.HostDBScans{scan(0, false), scan(1, true), scan(2, false)}, 0.5},
+	}
+	for i, test := range tests {
+		if uptime := uptimeRatio(test.history); uptime != test.uptime {
+			t.Errorf("test %v: expected uptime %v, got %v", i, test.uptime, uptime)
+		}
+	}
+}
+
+// TestOwnsAddress checks that hosts only probe addresses that resolve to the
+// IP of the node that asked for the probe.
+func TestOwnsAddress(t *testing.T) {
+	tests := []struct {
+		resolved []string
+		ip       string
+		owns     bool
+	}{
+		{[]string{"203.0.113.7"}, "203.0.113.7", true},
+		{[]string{"2001:db8::1", "203.0.113.7"}, "203.0.113.7", true},
+		{[]string{"2001:db8::1"}, "2001:db8:0::1", true},
+		{[]string{"203.0.113.8"}, "203.0.113.7", false},
+		{[]string{"2001:db8::1"}, "203.0.113.7", false},
+		{nil, "203.0.113.7", false},
+		{[]string{"203.0.113.7"}, "", false},
+	}
+	for i, test := range tests {
+		if owns := ownsAddress(test.resolved, test.ip); owns != test.owns {
+			t.Errorf("test %v: expected %v, got %v", i, test.owns, owns)
+		}
+	}
+}
@@ -0,0 +1,514 @@
+package host
+
+import (
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/fastrand"
+)
+
+// A host that is not reachable from the internet, such as a host behind
+// carrier-grade NAT, can register with a relay host that is. The host keeps
+// an outbound connection open to the relay over RPCRelayRegister, and the
+// relay listens on a port of its own on the host's behalf. The host announces
+// that port as its address. When a renter connects to the port, the relay
+// writes a notification with a random token over the registration, and the
+// host opens a second outbound connection over RPCRelayAccept to claim the
+// renter. The relay then copies bytes between the two connections, so the
+// renter speaks the host protocol to the host exactly as it would over a
+// direct connection. The host's identity is proven by signing a challenge
+// from the relay, and the renter still verifies the host's signatures, so a
+// relay can only drop or delay the sessions that it carries.
+
+var (
+	// errRelayFull is returned to a host that registers with a relay that is
+	// already relaying for the maximum number of hosts.
+	errRelayFull = ErrorCommunication("relay is relaying for the maximum number of hosts")
+
+	// errRelayNoAddress is returned to a host that registers with a relay
+	// that does not know its own address.
+	errRelayNoAddress = ErrorInternal("relay does not have an address to allocate")
+
+	// errRelayUnknownToken is returned when a host claims a renter session
+	// with a token that the relay did not issue, or that has expired.
+	errRelayUnknownToken = ErrorCommunication("relay token is unknown or has expired")
+
+	// errNotAcceptingRelays is returned to a host that registers with a host
+	// that does not act as a relay.
+	errNotAcceptingRelays = ErrorCommunication("host is not accepting relays")
+
+	// errRelaySignature is returned when a host fails to prove ownership of
+	// the key that it registers with.
+	errRelaySignature = ErrorCommunication("relay registration signature is invalid")
+)
+
+// relayToken identifies a renter session that is waiting at the relay for
+// the host to claim it. The zero token is a keepalive.
+type relayToken [16]byte
+
+// relayNotification is written by a relay to a registered host for every
+// renter that connects to the host's relayed address. Renter is the remote
+// address of the renter, which the host uses in place of the relay's address
+// to identify the renter.
+type relayNotification struct {
+	Token  relayToken
+	Renter string
+}
+
+// relayAddr is the address of a renter whose session is relayed.
+type relayAddr string
+
+// Network implements net.Addr.
+func (ra relayAddr) Network() string { return "tcp" }
+
+// String implements net.Addr.
+func (ra relayAddr) String() string { return string(ra) }
+
+// relayedConn is a renter session that the host received through its relay.
+// RemoteAddr reports the renter's address rather than the relay's, so that
+// abuse detection keeps telling renters apart.
+type relayedConn struct {
+	net.Conn
+	renter relayAddr
+}
+
+// RemoteAddr implements net.Conn.
+func (rc relayedConn) RemoteAddr() net.Addr { return rc.renter }
+
+// relayState is the state of the host's relay connections, both as a relay
+// for other hosts and as a host registered with a relay.
+type relayState struct {
+	mu sync.Mutex
+
+	// The hosts registered with this host, indexed by public key, the ports
+	// last allocated to each host, so that a host that registers again keeps
+	// its address, and the renter sessions waiting to be claimed.
+	hosts   map[string]net.Listener
+	ports   map[string]string
+	pending map[relayToken]net.Conn
+
+	// The registration of this host with its relay.
+	relayAddress   modules.NetAddress
+	relayedAddress modules.NetAddress
+	connected      bool
+	lastError      string
+
+	atomicRelayedConns uint64
+}
+
+// relayChallenge returns the hash that a host signs to prove ownership of the
+// key that it registers with.
+func relayChallenge(challenge [32]byte, relayed modules.NetAddress) crypto.Hash {
+	return crypto.HashAll(modules.RPCRelayRegister, challenge, relayed)
+}
+
+// splice copies bytes between two connections until either is closed, then
+// closes both.
+func splice(a, b net.Conn) {
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(a, b)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(b, a)
+		done <- struct{}{}
+	}()
+	<-done
+	a.Close()
+	b.Close()
+	<-done
+}
+
+// managedRelayedAddress returns the address that the relay allocates to a
+// host for the given port.
+func (h *Host) managedRelayedAddress(port string) (modules.NetAddress, error) {
+	h.mu.RLock()
+	addr := h.settings.NetAddress
+	if addr == "" {
+		addr = h.autoAddress
+	}
+	h.mu.RUnlock()
+	if addr == "" {
+		return "", errRelayNoAddress
+	}
+	return modules.NetAddress(net.JoinHostPort(addr.Host(), port)), nil
+}
+
+// managedRPCRelayRegister handles a request from a host that is not reachable
+// from the internet to relay renter sessions to it. The connection is kept
+// open for as long as the host stays registered.
+func (h *Host) managedRPCRelayRegister(conn net.Conn) error {
+	conn.SetDeadline(time.Now().Add(modules.NegotiateSettingsTime))
+
+	var hostKey types.SiaPublicKey
+	err := encoding.ReadObject(conn, &hostKey, 256)
+	if err != nil {
+		return extendErr("could not read host key: ", ErrorConnection(err.Error()))
+	}
+	h.mu.RLock()
+	accepting := h.settings.AcceptingRelays
+	h.mu.RUnlock()
+	if !accepting {
+		modules.WriteNegotiationRejection(conn, errNotAcceptingRelays)
+		return errNotAcceptingRelays
+	}
+
+	// Allocate a port for the host, reusing the port that it was last
+	// allocated if possible.
+	key := hostKey.String()
+	h.relay.mu.Lock()
+	old, registered := h.relay.hosts[key]
+	if !registered && len(h.relay.hosts) >= relayMaxHosts {
+		h.relay.mu.Unlock()
+		modules.WriteNegotiationRejection(conn, errRelayFull)
+		return errRelayFull
+	}
+	port := h.relay.ports[key]
+	h.relay.mu.Unlock()
+	if registered {
+		// Free the port of the old registration, which the host has most
+		// likely lost track of.
+		old.Close()
+	}
+	listener, err := h.dependencies.listen("tcp", net.JoinHostPort("", port))
+	if err != nil {
+		listener, err = h.dependencies.listen("tcp", ":0")
+	}
+	if err != nil {
+		err = ErrorInternal("could not allocate a port: " + err.Error())
+		modules.WriteNegotiationRejection(conn, err)
+		return err
+	}
+	defer listener.Close()
+	_, port, err = net.SplitHostPort(listener.Addr().String())
+	if err != nil {
+		return ErrorInternal(err.Error())
+	}
+	relayed, err := h.managedRelayedAddress(port)
+	if err != nil {
+		modules.WriteNegotiationRejection(conn, err)
+		return err
+	}
+
+	// Have the host prove that it owns the key by signing a challenge
+	// together with the address that it is allocated.
+	if err := modules.WriteNegotiationAcceptance(conn); err != nil {
+		return ErrorConnection("could not write acceptance: " + err.Error())
+	}
+	var challenge [32]byte
+	fastrand.Read(challenge[:])
+	if err := encoding.WriteObject(conn, challenge); err != nil {
+		return ErrorConnection("could not write challenge: " + err.Error())
+	}
+	if err := encoding.WriteObject(conn, relayed); err != nil {
+		return ErrorConnection("could not write relayed address: " + err.Error())
+	}
+	var sig []byte
+	if err := encoding.ReadObject(conn, &sig, 256); err != nil {
+		return ErrorConnection("could not read signature: " + err.Error())
+	}
+	if !verifyRelaySignature(relayChallenge(challenge, relayed), hostKey, sig) {
+		modules.WriteNegotiationRejection(conn, errRelaySignature)
+		return errRelaySignature
+	}
+	if err := modules.WriteNegotiationAcceptance(conn); err != nil {
+		return ErrorConnection("could not write acceptance: " + err.Error())
+	}
+
+	h.relay.mu.Lock()
+	h.relay.hosts[key] = listener
+	h.relay.ports[key] = port
+	h.relay.mu.Unlock()
+	defer func() {
+		h.relay.mu.Lock()
+		if h.relay.hosts[key] == listener {
+			delete(h.relay.hosts, key)
+		}
+		h.relay.mu.Unlock()
+	}()
+	h.log.Debugf("INFO: relaying renter sessions from %v to host %v", relayed, conn.RemoteAddr())
+
+	// Accept renters on the allocated port, and notify the host of each.
+	notifications := make(chan relayNotification)
+	go h.threadedAcceptRelayedRenters(listener, notifications)
+	conn.SetDeadline(time.Time{})
+	for {
+		var n relayNotification
+		select {
+		case n = <-notifications:
+		case <-time.After(relayKeepaliveInterval):
+		case <-h.tg.StopChan():
+			return nil
+		}
+		conn.SetWriteDeadline(time.Now().Add(relayKeepaliveInterval))
+		if err := encoding.WriteObject(conn, n); err != nil {
+			return ErrorConnection("relayed host dropped its registration: " + err.Error())
+		}
+	}
+}
+
+// threadedAcceptRelayedRenters accepts renters on the port allocated to a
+// registered host until the listener is closed. Each renter waits for the
+// host to claim it for up to relayAcceptTimeout.
+func (h *Host) threadedAcceptRelayedRenters(listener net.Listener, notifications chan<- relayNotification) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		var token relayToken
+		fastrand.Read(token[:])
+		h.relay.mu.Lock()
+		h.relay.pending[token] = conn
+		h.relay.mu.Unlock()
+
+		go func() {
+			select {
+			case notifications <- relayNotification{Token: token, Renter: conn.RemoteAddr().String()}:
+			case <-time.After(relayAcceptTimeout):
+			case <-h.tg.StopChan():
+			}
+			select {
+			case <-time.After(relayAcceptTimeout):
+			case <-h.tg.StopChan():
+			}
+			// Drop the renter if the host has not claimed it.
+			h.relay.mu.Lock()
+			_, unclaimed := h.relay.pending[token]
+			delete(h.relay.pending, token)
+			h.relay.mu.Unlock()
+			if unclaimed {
+				conn.Close()
+			}
+		}()
+	}
+}
+
+// managedRPCRelayAccept handles a registered host claiming a renter session.
+// The bytes of the session are copied between the renter and the host until
+// either side closes its connection.
+func (h *Host) managedRPCRelayAccept(conn net.Conn) error {
+	conn.SetDeadline(time.Now().Add(relayAcceptTimeout))
+	var token relayToken
+	err := encoding.ReadObject(conn, &token, 16)
+	if err != nil {
+		return extendErr("could not read relay token: ", ErrorConnection(err.Error()))
+	}
+	h.relay.mu.Lock()
+	renter, ok := h.relay.pending[token]
+	delete(h.relay.pending, token)
+	h.relay.mu.Unlock()
+	if !ok {
+		return errRelayUnknownToken
+	}
+	conn.SetDeadline(time.Time{})
+	renter.SetDeadline(time.Time{})
+	splice(conn, renter)
+	return nil
+}
+
+// verifyRelaySignature checks that sig is a valid signature of hash by key.
+func verifyRelaySignature(hash crypto.Hash, key types.SiaPublicKey, sig []byte) bool {
+	var pk crypto.PublicKey
+	var cs crypto.Signature
+	if key.Algorithm != types.SignatureEd25519 || len(key.Key) != len(pk) || len(sig) != len(cs) {
+		return false
+	}
+	copy(pk[:], key.Key)
+	copy(cs[:], sig)
+	return crypto.VerifyHash(hash, pk, cs) == nil
+}
+
+// managedRelayRegister registers the host with the relay at addr, and claims
+// the renter sessions that the relay forwards until the registration is
+// dropped or the relay address is changed.
+func (h *Host) managedRelayRegister(addr modules.NetAddress) error {
+	conn, err := (&net.Dialer{
+		Cancel:  h.tg.StopChan(),
+		Timeout: modules.NegotiateSettingsTime,
+	}).Dial("tcp", string(addr))
+	if err != nil {
+		return err
+	}
+	// Close the conn on host.Close or when the method terminates, whichever
+	// comes first.
+	connCloseChan := make(chan struct{})
+	defer close(connCloseChan)
+	go func() {
+		select {
+		case <-h.tg.StopChan():
+		case <-connCloseChan:
+		}
+		conn.Close()
+	}()
+	conn.SetDeadline(time.Now().Add(modules.NegotiateSettingsTime))
+
+	h.mu.RLock()
+	publicKey := h.publicKey
+	secretKey := h.secretKey
+	h.mu.RUnlock()
+	if err := encoding.WriteObject(conn, modules.RPCRelayRegister); err != nil {
+		return err
+	}
+	if err := encoding.WriteObject(conn, publicKey); err != nil {
+		return err
+	}
+
+	if err := modules.ReadNegotiationAcceptance(conn); err != nil {
+		return err
+	}
+	var challenge [32]byte
+	if err := encoding.ReadObject(conn, &challenge, 32); err != nil {
+		return err
+	}
+	var relayed modules.NetAddress
+	if err := encoding.ReadObject(conn, &relayed, 256); err != nil {
+		return err
+	}
+	sig := crypto.SignHash(relayChallenge(challenge, relayed), secretKey)
+	if err := encoding.WriteObject(conn, sig[:]); err != nil {
+		return err
+	}
+	if err := modules.ReadNegotiationAcceptance(conn); err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Time{})
+
+	h.mu.Lock()
+	h.relay.mu.Lock()
+	previous := h.relay.relayedAddress
+	h.relay.relayedAddress = relayed
+	h.relay.connected = true
+	h.relay.lastError = ""
+	h.relay.mu.Unlock()
+	reannounce := h.announced && h.settings.NetAddress == "" && previous != "" && previous != relayed
+	h.mu.Unlock()
+	defer func() {
+		h.relay.mu.Lock()
+		h.relay.connected = false
+		h.relay.mu.Unlock()
+	}()
+	h.log.Printf("INFO: registered with relay %v, renters can reach the host at %v", addr, relayed)
+	if reannounce {
+		go func() {
+			if err := h.tg.Add(); err != nil {
+				return
+			}
+			defer h.tg.Done()
+			if err := h.managedAnnounce(relayed); err != nil {
+				h.log.Println("WARN: could not announce the new relayed address:", err)
+			}
+		}()
+	}
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(3 * relayKeepaliveInterval))
+		var n relayNotification
+		if err := encoding.ReadObject(conn, &n, 256); err != nil {
+			return err
+		}
+		h.mu.RLock()
+		current := h.settings.RelayAddress
+		h.mu.RUnlock()
+		if current != addr {
+			return nil
+		}
+		if n.Token != (relayToken{}) {
+			go h.threadedClaimRelayedRenter(addr, n)
+		}
+	}
+}
+
+// threadedClaimRelayedRenter opens the connection that the relay at addr
+// relays a renter session over, and handles the session like any other
+// incoming connection.
+func (h *Host) threadedClaimRelayedRenter(addr modules.NetAddress, n relayNotification) {
+	if err := h.tg.Add(); err != nil {
+		return
+	}
+	defer h.tg.Done()
+
+	conn, err := (&net.Dialer{
+		Cancel:  h.tg.StopChan(),
+		Timeout: relayAcceptTimeout,
+	}).Dial("tcp", string(addr))
+	if err != nil {
+		h.log.Debugln("WARN: could not claim relayed renter session:", err)
+		return
+	}
+	conn.SetDeadline(time.Now().Add(relayAcceptTimeout))
+	if err := encoding.WriteObject(conn, modules.RPCRelayAccept); err != nil {
+		conn.Close()
+		return
+	}
+	if err := encoding.WriteObject(conn, n.Token); err != nil {
+		conn.Close()
+		return
+	}
+	atomic.AddUint64(&h.relay.atomicRelayedConns, 1)
+	h.threadedHandleConn(relayedConn{Conn: conn, renter: relayAddr(n.Renter)})
+}
+
+// threadedMaintainRelay keeps the host registered with the relay named in
+// its settings, registering again whenever the registration is dropped.
+func (h *Host) threadedMaintainRelay(closeChan chan struct{}) {
+	defer close(closeChan)
+	for {
+		h.mu.RLock()
+		addr := h.settings.RelayAddress
+		h.mu.RUnlock()
+		h.relay.mu.Lock()
+		h.relay.relayAddress = addr
+		h.relay.mu.Unlock()
+
+		if addr != "" {
+			err := h.managedRelayRegister(addr)
+			if err != nil {
+				h.log.Debugf("WARN: registration with relay %v failed: %v", addr, err)
+				h.relay.mu.Lock()
+				h.relay.lastError = err.Error()
+				h.relay.mu.Unlock()
+			}
+		}
+
+		select {
+		case <-h.tg.StopChan():
+			return
+		case <-time.After(relayRetryInterval):
+		}
+	}
+}
+
+// relayedAddress returns the address allocated to the host by its relay, or
+// the empty address if the host is not registered with a relay. The caller
+// must hold h.mu.
+func (h *Host) relayedAddress() modules.NetAddress {
+	h.relay.mu.Lock()
+	defer h.relay.mu.Unlock()
+	if h.settings.RelayAddress == "" || h.relay.relayAddress != h.settings.RelayAddress {
+		return ""
+	}
+	return h.relay.relayedAddress
+}
+
+// RelayStatus returns the status of the host's relay connections.
+func (h *Host) RelayStatus() modules.HostRelayStatus {
+	h.relay.mu.Lock()
+	defer h.relay.mu.Unlock()
+	return modules.HostRelayStatus{
+		RelayAddress:   h.relay.relayAddress,
+		RelayedAddress: h.relay.relayedAddress,
+		Connected:      h.relay.connected,
+		LastError:      h.relay.lastError,
+		RelayedConns:   atomic.LoadUint64(&h.relay.atomicRelayedConns),
+		RelayedHosts:   len(h.relay.hosts),
+	}
+}
@@ -0,0 +1,89 @@
+package host
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestRelay checks that a host can register with a relay host and serve a
+// renter that connects to the address allocated by the relay.
+func TestRelay(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	relay, err := blankHostTester("TestRelay - Relay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer relay.Close()
+	ht, err := blankHostTester("TestRelay - Host")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	// Registering with a host that does not accept relays should fail.
+	settings := ht.host.InternalSettings()
+	settings.RelayAddress = relay.host.NetAddress()
+	if err := ht.host.SetInternalSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50 && ht.host.RelayStatus().LastError == ""; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if ht.host.RelayStatus().LastError == "" {
+		t.Fatal("registration with a host that is not accepting relays did not fail")
+	}
+
+	relaySettings := relay.host.InternalSettings()
+	relaySettings.AcceptingRelays = true
+	if err := relay.host.SetInternalSettings(relaySettings); err != nil {
+		t.Fatal(err)
+	}
+	rs := ht.host.RelayStatus()
+	for i := 0; i < 50 && !rs.Connected; i++ {
+		time.Sleep(100 * time.Millisecond)
+		rs = ht.host.RelayStatus()
+	}
+	if !rs.Connected {
+		t.Fatal("host did not register with the relay:", rs.LastError)
+	}
+	if rs.RelayedAddress.Host() != relay.host.NetAddress().Host() {
+		t.Fatal("relayed address is not on the relay:", rs.RelayedAddress)
+	}
+	if ht.host.NetAddress() != rs.RelayedAddress {
+		t.Fatal("host does not use the relayed address:", ht.host.NetAddress())
+	}
+	if n := relay.host.RelayStatus().RelayedHosts; n != 1 {
+		t.Fatal("relay should have 1 registered host, has", n)
+	}
+
+	// Fetch the host's settings through the relay.
+	conn, err := net.DialTimeout("tcp", string(rs.RelayedAddress), 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := encoding.WriteObject(conn, modules.RPCSettings); err != nil {
+		t.Fatal(err)
+	}
+	var pk crypto.PublicKey
+	copy(pk[:], ht.host.PublicKey().Key)
+	var hes modules.HostExternalSettings
+	err = crypto.ReadSignedObject(conn, &hes, modules.NegotiateMaxHostExternalSettingsLen, pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hes.NetAddress != rs.RelayedAddress {
+		t.Fatal("host settings do not list the relayed address:", hes.NetAddress)
+	}
+	if n := ht.host.RelayStatus().RelayedConns; n != 1 {
+		t.Fatal("host should have received 1 relayed session, got", n)
+	}
+}
@@ -0,0 +1,268 @@
+package host
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// The host meters the bandwidth used by each renter, so that the host
+// operator can see which renters use the host's connection, and so that a
+// single aggressive renter cannot monopolize the host's uplink to the
+// detriment of its other contracts. Like the abuse detection, renters are
+// identified by their public key once they present one during an RPC, and by
+// their IP address until then. All connections of a renter share the renter's
+// rate limits. The counts are kept in memory and start over when the host is
+// restarted.
+
+const (
+	// renterBandwidthChunk is the largest number of bytes that a metered
+	// connection reads or writes at once, so that a renter that is being
+	// limited waits in small steps.
+	renterBandwidthChunk = 1 << 14
+
+	// renterBandwidthIdle is how long a renter's record is kept after its
+	// last transfer.
+	renterBandwidthIdle = 24 * time.Hour
+)
+
+var (
+	// errMeteredConnClosed is returned when a metered connection is closed
+	// while waiting for the renter's rate limit.
+	errMeteredConnClosed = errors.New("connection closed while waiting for renter bandwidth")
+)
+
+// renterBucket is a token bucket that limits the rate of one direction of a
+// renter's transfers. A rate of zero means that transfers are not limited.
+type renterBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take takes n tokens from the bucket at the provided rate, returning how
+// long the transfer must wait for the bucket to pay off its debt. The bucket
+// holds at most one second worth of tokens.
+func (rb *renterBucket) take(rate uint64, n int, now time.Time) time.Duration {
+	if rate == 0 {
+		rb.tokens, rb.last = 0, now
+		return 0
+	}
+	if !rb.last.IsZero() {
+		rb.tokens += now.Sub(rb.last).Seconds() * float64(rate)
+	}
+	if rb.tokens > float64(rate) {
+		rb.tokens = float64(rate)
+	}
+	rb.last = now
+	rb.tokens -= float64(n)
+	if rb.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-rb.tokens / float64(rate) * float64(time.Second))
+}
+
+// renterMeter is the bandwidth record of a single renter. Downloaded counts
+// the bytes sent by the host to the renter, and uploaded the bytes sent by
+// the renter to the host.
+type renterMeter struct {
+	address      string
+	downloaded   uint64
+	uploaded     uint64
+	lastTransfer time.Time
+	download     renterBucket
+	upload       renterBucket
+}
+
+// bandwidthTracker meters the bandwidth used by each renter. It is safe for
+// concurrent use.
+type bandwidthTracker struct {
+	// maxDownload and maxUpload are the per-renter rate limits in bytes per
+	// second, copied from the host settings.
+	maxDownload uint64
+	maxUpload   uint64
+
+	renters   map[string]*renterMeter
+	lastPrune time.Time
+	mu        sync.Mutex
+}
+
+// setLimits changes the per-renter rate limits. The limits apply immediately
+// to open connections.
+func (bt *bandwidthTracker) setLimits(maxDownload, maxUpload uint64) {
+	bt.mu.Lock()
+	bt.maxDownload, bt.maxUpload = maxDownload, maxUpload
+	bt.mu.Unlock()
+}
+
+// meter returns the record of the renter, creating it if necessary. Records
+// of renters that have been idle for a while are dropped. The caller must
+// hold the lock.
+func (bt *bandwidthTracker) meter(renter, address string, now time.Time) *renterMeter {
+	if bt.renters == nil {
+		bt.renters = make(map[string]*renterMeter)
+	}
+	if now.Sub(bt.lastPrune) > renterBandwidthIdle {
+		for r, rm := range bt.renters {
+			if now.Sub(rm.lastTransfer) > renterBandwidthIdle {
+				delete(bt.renters, r)
+			}
+		}
+		bt.lastPrune = now
+	}
+	rm, exists := bt.renters[renter]
+	if !exists {
+		rm = new(renterMeter)
+		bt.renters[renter] = rm
+	}
+	rm.address = address
+	return rm
+}
+
+// record counts n bytes transferred by the renter and returns how long the
+// transfer must wait to stay within the renter's rate limit.
+func (bt *bandwidthTracker) record(renter, address string, n int, download bool, now time.Time) time.Duration {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+	rm := bt.meter(renter, address, now)
+	rm.lastTransfer = now
+	if download {
+		rm.downloaded += uint64(n)
+		return rm.download.take(bt.maxDownload, n, now)
+	}
+	rm.uploaded += uint64(n)
+	return rm.upload.take(bt.maxUpload, n, now)
+}
+
+// records returns the bandwidth records of renters that have transferred data
+// recently, sorted by renter.
+func (bt *bandwidthTracker) records(now time.Time) []modules.HostRenterBandwidth {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+	records := make([]modules.HostRenterBandwidth, 0, len(bt.renters))
+	for renter, rm := range bt.renters {
+		if now.Sub(rm.lastTransfer) > renterBandwidthIdle {
+			continue
+		}
+		records = append(records, modules.HostRenterBandwidth{
+			Renter:       renter,
+			Address:      rm.address,
+			Downloaded:   rm.downloaded,
+			Uploaded:     rm.uploaded,
+			LastTransfer: rm.lastTransfer,
+		})
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Renter < records[j].Renter
+	})
+	return records
+}
+
+// meteredConn is a net.Conn whose traffic is counted towards, and limited by,
+// the bandwidth of the renter on the other end.
+type meteredConn struct {
+	net.Conn
+	address   string
+	renter    string
+	tracker   *bandwidthTracker
+	closed    chan struct{}
+	closeOnce sync.Once
+	mu        sync.Mutex
+}
+
+// newMeteredConn wraps conn so that its traffic is metered by bt. Until the
+// renter presents its key, the traffic is counted towards its IP address.
+func newMeteredConn(conn net.Conn, bt *bandwidthTracker) *meteredConn {
+	address := connAddress(conn)
+	return &meteredConn{
+		Conn:    conn,
+		address: address,
+		renter:  address,
+		tracker: bt,
+		closed:  make(chan struct{}),
+	}
+}
+
+// identify counts the future traffic of the connection towards the renter
+// with the provided key.
+func (mc *meteredConn) identify(pk crypto.PublicKey) {
+	mc.mu.Lock()
+	mc.renter = renterKey(pk)
+	mc.mu.Unlock()
+}
+
+// wait records a transfer of n bytes and blocks until it fits in the renter's
+// rate limit. false is returned if the connection was closed while waiting.
+func (mc *meteredConn) wait(n int, download bool) bool {
+	mc.mu.Lock()
+	renter := mc.renter
+	mc.mu.Unlock()
+	delay := mc.tracker.record(renter, mc.address, n, download, time.Now())
+	if delay == 0 {
+		return true
+	}
+	select {
+	case <-time.After(delay):
+		return true
+	case <-mc.closed:
+		return false
+	}
+}
+
+// Read implements the io.Reader interface.
+func (mc *meteredConn) Read(b []byte) (int, error) {
+	if len(b) > renterBandwidthChunk {
+		b = b[:renterBandwidthChunk]
+	}
+	n, err := mc.Conn.Read(b)
+	if n > 0 && !mc.wait(n, false) && err == nil {
+		err = errMeteredConnClosed
+	}
+	return n, err
+}
+
+// Write implements the io.Writer interface.
+func (mc *meteredConn) Write(b []byte) (int, error) {
+	var written int
+	for len(b) > 0 {
+		chunk := b
+		if len(chunk) > renterBandwidthChunk {
+			chunk = chunk[:renterBandwidthChunk]
+		}
+		if !mc.wait(len(chunk), true) {
+			return written, errMeteredConnClosed
+		}
+		n, err := mc.Conn.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		b = b[n:]
+	}
+	return written, nil
+}
+
+// Close implements the io.Closer interface. Transfers waiting for the
+// renter's rate limit are interrupted.
+func (mc *meteredConn) Close() error {
+	mc.closeOnce.Do(func() { close(mc.closed) })
+	return mc.Conn.Close()
+}
+
+// identifyRenter counts the traffic of conn towards the renter with the
+// provided key from now on.
+func identifyRenter(conn net.Conn, pk crypto.PublicKey) {
+	if mc, ok := conn.(*meteredConn); ok {
+		mc.identify(pk)
+	}
+}
+
+// RenterBandwidth returns the bandwidth used by each renter that has
+// transferred data within the last day.
+func (h *Host) RenterBandwidth() []modules.HostRenterBandwidth {
+	return h.bandwidth.records(time.Now())
+}
@@ -0,0 +1,100 @@
+package host
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestRenterBucket checks that the token bucket lets a second worth of data
+// through immediately and then makes transfers wait.
+func TestRenterBucket(t *testing.T) {
+	var rb renterBucket
+	now := time.Now()
+	if delay := rb.take(0, 1e9, now); delay != 0 {
+		t.Fatal("unlimited bucket delayed a transfer:", delay)
+	}
+
+	// An empty bucket makes the first transfer wait for its tokens.
+	if delay := rb.take(1000, 500, now); delay != 500*time.Millisecond {
+		t.Fatal("wrong delay for first transfer:", delay)
+	}
+	// Once the debt has been paid off and the bucket has refilled, a full
+	// second of data is let through.
+	now = now.Add(10 * time.Second)
+	if delay := rb.take(1000, 1000, now); delay != 0 {
+		t.Fatal("full bucket delayed a transfer:", delay)
+	}
+	if delay := rb.take(1000, 250, now); delay != 250*time.Millisecond {
+		t.Fatal("wrong delay for transfer from an empty bucket:", delay)
+	}
+}
+
+// TestBandwidthTrackerRecords checks that traffic is counted per renter, and
+// that idle renters are forgotten.
+func TestBandwidthTrackerRecords(t *testing.T) {
+	var bt bandwidthTracker
+	now := time.Now()
+	bt.record("203.0.113.7", "203.0.113.7", 100, true, now)
+	bt.record("ed25519:aa", "203.0.113.7", 200, true, now)
+	bt.record("ed25519:aa", "203.0.113.7", 300, false, now)
+
+	records := bt.records(now)
+	if len(records) != 2 {
+		t.Fatal("expected 2 records, got", len(records))
+	}
+	if records[1].Renter != "ed25519:aa" || records[1].Downloaded != 200 || records[1].Uploaded != 300 {
+		t.Fatalf("wrong record: %+v", records[1])
+	}
+
+	// Idle renters are not reported, and are dropped once another renter
+	// transfers data.
+	later := now.Add(renterBandwidthIdle + time.Minute)
+	if len(bt.records(later)) != 0 {
+		t.Fatal("idle renters were reported")
+	}
+	bt.record("198.51.100.1", "198.51.100.1", 1, true, later)
+	if len(bt.renters) != 1 {
+		t.Fatal("idle renters were not dropped:", len(bt.renters))
+	}
+}
+
+// TestMeteredConn checks that a metered connection counts its traffic towards
+// the renter that identified itself, and is held to the renter's limits.
+func TestMeteredConn(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	var bt bandwidthTracker
+	bt.setLimits(1<<20, 0)
+
+	c1, c2 := net.Pipe()
+	defer c2.Close()
+	go io.Copy(ioutil.Discard, c2)
+	mc := newMeteredConn(abuseTestConn{Conn: c1, addr: "203.0.113.7:1234"}, &bt)
+	defer mc.Close()
+	_, pk := crypto.GenerateKeyPair()
+	identifyRenter(mc, pk)
+
+	// Writing 1.5 MiB at 1 MiB/s should take at least half a second, as the
+	// bucket starts empty.
+	start := time.Now()
+	_, err := mc.Write(make([]byte, 3<<19))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 500*time.Millisecond {
+		t.Fatal("rate limit was not applied:", elapsed)
+	}
+
+	records := bt.records(time.Now())
+	spk := types.Ed25519PublicKey(pk)
+	if len(records) != 1 || records[0].Renter != spk.String() || records[0].Downloaded != 3<<19 {
+		t.Fatalf("traffic was not counted towards the renter: %+v", records)
+	}
+}
@@ -146,6 +146,20 @@ type storageObligation struct {
 	ProofConstructed    bool
 	ProofConfirmed      bool
 	ObligationStatus    storageObligationStatus
+
+	// Variables tracking the submission of the storage proof. Until the
+	// proof is confirmed, it is resubmitted with an escalating fee.
+	// ProofHeight is the height of the most recent attempt, and the most
+	// recent submission is kept so that it can be rebroadcast.
+	ProofAttempts       uint64
+	ProofFeePerByte     types.Currency
+	ProofHeight         types.BlockHeight
+	ProofTransactionSet []types.Transaction
+
+	// RenewedDataSize is the size of the data that was carried over into a
+	// renewal of the contract. It counts towards the storage of the renter
+	// through the renewal instead, see acceptance.go.
+	RenewedDataSize uint64
 }
 
 // getStorageObligation fetches a storage obligation from the database tx.
@@ -373,6 +387,7 @@ func (h *Host) managedAddStorageObligation(so storageObligation) error {
 		// Update the host financial metrics with regards to this storage
 		// obligation.
 		h.financialMetrics.ContractCount++
+		h.countRenterContract(so, 1)
 		h.financialMetrics.PotentialContractCompensation = h.financialMetrics.PotentialContractCompensation.Add(so.ContractCost)
 		h.financialMetrics.LockedStorageCollateral = h.financialMetrics.LockedStorageCollateral.Add(so.LockedCollateral)
 		h.financialMetrics.PotentialStorageRevenue = h.financialMetrics.PotentialStorageRevenue.Add(so.PotentialStorageRevenue)
@@ -380,6 +395,7 @@ func (h *Host) managedAddStorageObligation(so storageObligation) error {
 		h.financialMetrics.PotentialUploadBandwidthRevenue = h.financialMetrics.PotentialUploadBandwidthRevenue.Add(so.PotentialUploadRevenue)
 		h.financialMetrics.RiskedStorageCollateral = h.financialMetrics.RiskedStorageCollateral.Add(so.RiskedCollateral)
 		h.financialMetrics.TransactionFeeExpenses = h.financialMetrics.TransactionFeeExpenses.Add(so.TransactionFeesAdded)
+		h.recordContractFormed()
 		return nil
 	}()
 	if err != nil {
@@ -458,24 +474,33 @@ func (h *Host) modifyStorageObligation(so storageObligation, sectorsRemoved []cr
 	// and left to consistency checks and user actions to fix (will reduce host
 	// capacity, but will not inhibit the host's ability to submit storage
 	// proofs)
-	var i int
-	var err error
-	for i = range sectorsGained {
-		err = h.AddSector(sectorsGained[i], gainedSectorData[i])
-		if err != nil {
-			break
-		}
-	}
-	if err != nil {
+	//
+	// The sectors are handed to the storage manager all at once, so that they
+	// are written in parallel and committed to disk together.
+	results := make([]<-chan error, len(sectorsGained))
+	for i := range sectorsGained {
+		results[i] = h.AddSectorAsync(sectorsGained[i], gainedSectorData[i])
+	}
+	var addErrs []error
+	var added []crypto.Hash
+	for i, result := range results {
+		if err := <-result; err != nil {
+			addErrs = append(addErrs, err)
+		} else {
+			added = append(added, sectorsGained[i])
+		}
+	}
+	if len(addErrs) != 0 {
 		// Because there was an error, all of the sectors that got added need
 		// to be reverted.
-		for j := 0; j < i; j++ {
+		for _, root := range added {
 			// Error is not checked because there's nothing useful that can be
 			// done about an error.
-			_ = h.RemoveSector(sectorsGained[j])
+			_ = h.RemoveSector(root)
 		}
-		return err
+		return build.ComposeErrors(addErrs...)
 	}
+	var err error
 	// Update the database to contain the new storage obligation.
 	var oldSO storageObligation
 	err = h.db.Update(func(tx *bolt.Tx) error {
@@ -507,6 +532,10 @@ func (h *Host) modifyStorageObligation(so storageObligation, sectorsRemoved []cr
 		_ = h.RemoveSector(sectorsRemoved[k])
 	}
 
+	// Update the renter's storage to the new size of the obligation.
+	h.countRenterStorage(oldSO, -1)
+	h.countRenterStorage(so, 1)
+
 	// Update the financial information for the storage obligation - remove the
 	// old values.
 	h.financialMetrics.PotentialContractCompensation = h.financialMetrics.PotentialContractCompensation.Sub(oldSO.ContractCost)
@@ -526,20 +555,14 @@ func (h *Host) modifyStorageObligation(so storageObligation, sectorsRemoved []cr
 	h.financialMetrics.PotentialUploadBandwidthRevenue = h.financialMetrics.PotentialUploadBandwidthRevenue.Add(so.PotentialUploadRevenue)
 	h.financialMetrics.RiskedStorageCollateral = h.financialMetrics.RiskedStorageCollateral.Add(so.RiskedCollateral)
 	h.financialMetrics.TransactionFeeExpenses = h.financialMetrics.TransactionFeeExpenses.Add(so.TransactionFeesAdded)
+	h.recordCollateral()
 	return nil
 }
 
 // removeStorageObligation will remove a storage obligation from the host,
-// either due to failure or success.
+// either due to failure or success. The sectors of the obligation are kept
+// until they are removed by garbage collection, see garbagecollection.go.
 func (h *Host) removeStorageObligation(so storageObligation, sos storageObligationStatus) error {
-
-	// Call removeSector for every sector in the storage obligation.
-	for _, root := range so.SectorRoots {
-		// Error is not checked, we want to call remove on every sector even if
-		// there are problems - disk health information will be updated.
-		_ = h.RemoveSector(root)
-	}
-
 	// Update the host revenue metrics based on the status of the obligation.
 	if sos == obligationUnresolved {
 		h.log.Critical("storage obligation 'unresolved' during call to removeStorageObligation, id", so.id())
@@ -588,14 +611,15 @@ func (h *Host) removeStorageObligation(so storageObligation, sos storageObligati
 		h.financialMetrics.LostStorageCollateral = h.financialMetrics.LostStorageCollateral.Add(so.RiskedCollateral)
 		h.financialMetrics.LostRevenue = h.financialMetrics.LostRevenue.Add(so.ContractCost).Add(so.PotentialStorageRevenue).Add(so.PotentialDownloadRevenue).Add(so.PotentialUploadRevenue)
 	}
+	h.recordObligationResolved(so, sos)
 
 	// Update the storage obligation to be finalized but still in-database. The
 	// obligation status is updated so that the user can see how the obligation
-	// ended up, and the sector roots are removed because they are large
-	// objects with little purpose once storage proofs are no longer needed.
+	// ended up. The sector roots are kept until garbage collection removes
+	// the sectors, after which they are dropped as well.
 	h.financialMetrics.ContractCount--
+	h.countRenterContract(so, -1)
 	so.ObligationStatus = sos
-	so.SectorRoots = nil
 	return h.db.Update(func(tx *bolt.Tx) error {
 		return putStorageObligation(tx, so)
 	})
@@ -758,6 +782,13 @@ func (h *Host) threadedHandleActionItem(soid types.FileContractID, wg *sync.Wait
 			}
 			return
 		}
+		// Several action items of the obligation may fall on the same height.
+		// The proof is only submitted by the first of them, which also queues
+		// the next check.
+		if so.ProofHeight == blockHeight {
+			return
+		}
+		so.ProofHeight = blockHeight
 
 		// Get the index of the segment, and the index of the sector containing
 		// the segment.
@@ -796,8 +827,8 @@ func (h *Host) threadedHandleActionItem(soid types.FileContractID, wg *sync.Wait
 		}
 		copy(sp.Segment[:], base)
 
-		// Create and build the transaction with the storage proof.
-		builder := h.wallet.StartTransaction()
+		// Pick the fee for this submission. Each resubmission pays more than
+		// the previous one, but no more than the obligation is worth.
 		_, feeRecommendation := h.tpool.FeeEstimation()
 		if so.value().Cmp(feeRecommendation) < 0 {
 			// There's no sense submitting the storage proof if the fee is more
@@ -806,32 +837,52 @@ func (h *Host) threadedHandleActionItem(soid types.FileContractID, wg *sync.Wait
 			return
 		}
 		txnSize := uint64(len(encoding.Marshal(sp)) + 300)
-		requiredFee := feeRecommendation.Mul64(txnSize)
-		err = builder.FundSiacoins(requiredFee)
-		if err != nil {
-			h.log.Println("Host error when funding a storage proof transaction fee:", err)
-			return
-		}
-		builder.AddMinerFee(requiredFee)
-		builder.AddStorageProof(sp)
-		storageProofSet, err := builder.Sign(true)
-		if err != nil {
-			h.log.Println("Host error when signing the storage proof transaction:", err)
-			return
+		feePerByte := storageProofFeePerByte(feeRecommendation, so.ProofFeePerByte, so.ProofAttempts, so.proofDeadline()-blockHeight)
+		if maxFeePerByte := so.value().Div64(txnSize); feePerByte.Cmp(maxFeePerByte) > 0 {
+			feePerByte = maxFeePerByte
 		}
-		err = h.tpool.AcceptTransactionSet(storageProofSet)
-		if err != nil {
-			h.log.Println("Host unable to submit storage proof transaction to transaction pool:", err)
-			return
+
+		// If the fee cannot be raised enough to replace the previous
+		// submission, rebroadcast the previous submission instead.
+		if so.ProofAttempts > 0 && feePerByte.Cmp(so.ProofFeePerByte.Mul64(11).Div64(10)) <= 0 {
+			h.log.Debugln("Host rebroadcasting storage proof without raising the fee, id", so.id())
+			err = h.tpool.AcceptTransactionSet(so.ProofTransactionSet)
+			if err != nil {
+				h.log.Debugln("Host unable to rebroadcast storage proof transaction:", err)
+			}
+		} else {
+			if so.ProofAttempts > 0 {
+				h.log.Printf("Storage proof for %v not yet confirmed, resubmitting with a fee of %v per byte (attempt %v, %v blocks left)", so.id(), feePerByte, so.ProofAttempts+1, so.proofDeadline()-blockHeight)
+			}
+			firstSubmission := so.ProofAttempts == 0
+			err = h.submitStorageProof(&so, sp, feePerByte, txnSize)
+			if err != nil {
+				h.log.Println("Host unable to submit storage proof transaction:", err)
+			}
+
+			// Queue an action item at the end of the window to check whether
+			// the storage proof got confirmed.
+			if err == nil && firstSubmission {
+				h.mu.Lock()
+				if so.proofDeadline() > h.blockHeight {
+					err = h.queueActionItem(so.proofDeadline(), so.id())
+				}
+				h.mu.Unlock()
+				if err != nil {
+					h.log.Println("Error queuing action item:", err)
+				}
+			}
 		}
-		so.TransactionFeesAdded = so.TransactionFeesAdded.Add(requiredFee)
 
-		// Queue another action item to check whether the storage proof
-		// got confirmed.
+		// Queue another action item to resubmit the storage proof if it has
+		// not been confirmed in the meantime.
 		h.mu.Lock()
-		err = h.queueActionItem(so.proofDeadline(), so.id())
+		recheckHeight, recheck := storageProofRecheckHeight(h.blockHeight, so.proofDeadline(), so.ProofAttempts > 0)
+		if recheck {
+			err = h.queueActionItem(recheckHeight, so.id())
+		}
 		h.mu.Unlock()
-		if err != nil {
+		if recheck && err != nil {
 			h.log.Println("Error queuing action item:", err)
 		}
 	}
@@ -858,6 +909,78 @@ func (h *Host) threadedHandleActionItem(soid types.FileContractID, wg *sync.Wait
 	}
 }
 
+// proofStatus returns the status of the storage proof of the obligation at
+// the provided height.
+func (so storageObligation) proofStatus(blockHeight types.BlockHeight) string {
+	switch {
+	case so.ProofConfirmed:
+		return modules.HostProofStatusConfirmed
+	case so.ObligationStatus == obligationFailed:
+		return modules.HostProofStatusMissed
+	case so.ObligationStatus != obligationUnresolved:
+		return modules.HostProofStatusNotRequired
+	case so.ProofConstructed:
+		return modules.HostProofStatusSubmitted
+	case blockHeight >= so.expiration():
+		return modules.HostProofStatusDue
+	default:
+		return modules.HostProofStatusPending
+	}
+}
+
+// metadata returns the information about the storage obligation that is
+// reported to the host operator.
+func (so storageObligation) metadata(blockHeight types.BlockHeight) modules.StorageObligation {
+	// The renter key is left empty for obligations that are missing their
+	// revision.
+	renterKey, _ := so.renterKey()
+	return modules.StorageObligation{
+		ID:                so.id(),
+		RenterPublicKey:   renterKey,
+		NegotiationHeight: so.NegotiationHeight,
+		ExpirationHeight:  so.expiration(),
+		ProofDeadline:     so.proofDeadline(),
+
+		DataSize:    so.fileSize(),
+		SectorCount: uint64(len(so.SectorRoots)),
+
+		ContractCost:             so.ContractCost,
+		LockedCollateral:         so.LockedCollateral,
+		PotentialDownloadRevenue: so.PotentialDownloadRevenue,
+		PotentialStorageRevenue:  so.PotentialStorageRevenue,
+		PotentialUploadRevenue:   so.PotentialUploadRevenue,
+		RiskedCollateral:         so.RiskedCollateral,
+		TransactionFeesAdded:     so.TransactionFeesAdded,
+
+		OriginConfirmed:     so.OriginConfirmed,
+		RevisionConstructed: so.RevisionConstructed,
+		RevisionConfirmed:   so.RevisionConfirmed,
+		ProofConstructed:    so.ProofConstructed,
+		ProofConfirmed:      so.ProofConfirmed,
+		ProofAttempts:       so.ProofAttempts,
+		ProofStatus:         so.proofStatus(blockHeight),
+		ObligationStatus:    uint64(so.ObligationStatus),
+	}
+}
+
+// StorageObligation returns the storage obligation of the file contract with
+// the provided id.
+func (h *Host) StorageObligation(id types.FileContractID) (modules.StorageObligation, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var so storageObligation
+	err := h.db.View(func(tx *bolt.Tx) error {
+		var err error
+		so, err = getStorageObligation(tx, id)
+		return err
+	})
+	if err != nil {
+		return modules.StorageObligation{}, err
+	}
+	return so.metadata(h.blockHeight), nil
+}
+
 // StorageObligations fetches the set of storage obligations in the host and
 // returns metadata on them.
 func (h *Host) StorageObligations() (sos []modules.StorageObligation) {
@@ -872,17 +995,7 @@ func (h *Host) StorageObligations() (sos []modules.StorageObligation) {
 			if err != nil {
 				return build.ExtendErr("unable to unmarshal storage obligation:", err)
 			}
-			mso := modules.StorageObligation{
-				NegotiationHeight: so.NegotiationHeight,
-
-				OriginConfirmed:     so.OriginConfirmed,
-				RevisionConstructed: so.RevisionConstructed,
-				RevisionConfirmed:   so.RevisionConfirmed,
-				ProofConstructed:    so.ProofConstructed,
-				ProofConfirmed:      so.ProofConfirmed,
-				ObligationStatus:    uint64(so.ObligationStatus),
-			}
-			sos = append(sos, mso)
+			sos = append(sos, so.metadata(h.blockHeight))
 			return nil
 		})
 		if err != nil {
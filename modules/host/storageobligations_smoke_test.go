@@ -324,13 +324,19 @@ func TestSingleSectorStorageObligationStack(t *testing.T) {
 			t.Fatal(err)
 		}
 	}
+	// The sector roots are kept until garbage collection removes the sectors
+	// of the expired obligation.
+	_, err = ht.host.CollectGarbage()
+	if err != nil {
+		t.Fatal(err)
+	}
 	err = ht.host.db.View(func(tx *bolt.Tx) error {
 		so, err = getStorageObligation(tx, so.id())
 		if err != nil {
 			return err
 		}
 		if so.SectorRoots != nil {
-			t.Error("sector roots were not cleared when the host finalized the obligation")
+			t.Error("sector roots were not cleared when garbage collection removed the sectors")
 		}
 		if so.ObligationStatus != obligationSucceeded {
 			t.Error("obligation is not being reported as successful:", so.ObligationStatus)
@@ -340,6 +346,9 @@ func TestSingleSectorStorageObligationStack(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	if _, err := ht.host.ReadSector(sectorRoot); err == nil {
+		t.Fatal("sector was not removed by garbage collection")
+	}
 	if !ht.host.financialMetrics.StorageRevenue.Equals(sectorCost) {
 		t.Fatal("the host should be reporting revenue after a successful storage proof")
 	}
@@ -560,13 +569,19 @@ func TestMultiSectorStorageObligationStack(t *testing.T) {
 			t.Fatal(err)
 		}
 	}
+	// The sector roots are kept until garbage collection removes the sectors
+	// of the expired obligation.
+	_, err = ht.host.CollectGarbage()
+	if err != nil {
+		t.Fatal(err)
+	}
 	err = ht.host.db.View(func(tx *bolt.Tx) error {
 		so, err = getStorageObligation(tx, so.id())
 		if err != nil {
 			return err
 		}
 		if so.SectorRoots != nil {
-			t.Error("sector roots were not cleared out when the storage proof was finalized")
+			t.Error("sector roots were not cleared when garbage collection removed the sectors")
 		}
 		if so.ObligationStatus != obligationSucceeded {
 			t.Error("storage obligation was not reported as a success")
@@ -576,6 +591,12 @@ func TestMultiSectorStorageObligationStack(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	if _, err := ht.host.ReadSector(sectorRoot); err == nil {
+		t.Fatal("sector was not removed by garbage collection")
+	}
+	if _, err := ht.host.ReadSector(sectorRoot2); err == nil {
+		t.Fatal("sector was not removed by garbage collection")
+	}
 	if !ht.host.financialMetrics.StorageRevenue.Equals(sectorCost.Add(sectorCost2)) {
 		t.Fatal("the host should be reporting revenue after a successful storage proof")
 	}
@@ -711,13 +732,19 @@ func TestAutoRevisionSubmission(t *testing.T) {
 			t.Fatal(err)
 		}
 	}
+	// The sector roots are kept until garbage collection removes the sectors
+	// of the expired obligation.
+	_, err = ht.host.CollectGarbage()
+	if err != nil {
+		t.Fatal(err)
+	}
 	err = ht.host.db.View(func(tx *bolt.Tx) error {
 		so, err = getStorageObligation(tx, so.id())
 		if err != nil {
 			return err
 		}
 		if so.SectorRoots != nil {
-			t.Error("sector roots were not cleared out when the storage proof was finalized")
+			t.Error("sector roots were not cleared when garbage collection removed the sectors")
 		}
 		if so.ObligationStatus != obligationSucceeded {
 			t.Error("storage obligation was not reported as a success")
@@ -727,6 +754,9 @@ func TestAutoRevisionSubmission(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	if _, err := ht.host.ReadSector(sectorRoot); err == nil {
+		t.Fatal("sector was not removed by garbage collection")
+	}
 	if !ht.host.financialMetrics.StorageRevenue.Equals(sectorCost) {
 		t.Fatal("the host should be reporting revenue after a successful storage proof")
 	}
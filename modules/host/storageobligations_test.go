@@ -3,6 +3,7 @@ package host
 import (
 	"testing"
 
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 )
 
@@ -80,3 +81,35 @@ func TestStorageObligationID(t *testing.T) {
 		t.Error("id function of storage obligation incorrect for file contracts with dependencies")
 	}
 }
+
+// TestStorageObligationProofStatus checks the proof status that is reported
+// for storage obligations at each stage of their lives.
+func TestStorageObligationProofStatus(t *testing.T) {
+	t.Parallel()
+	origin := []types.Transaction{{
+		FileContracts: []types.FileContract{{
+			WindowStart: 10,
+			WindowEnd:   20,
+		}},
+	}}
+	tests := []struct {
+		so     storageObligation
+		height types.BlockHeight
+		status string
+	}{
+		{storageObligation{}, 5, modules.HostProofStatusPending},
+		{storageObligation{}, 10, modules.HostProofStatusDue},
+		{storageObligation{ProofConstructed: true}, 12, modules.HostProofStatusSubmitted},
+		{storageObligation{ProofConstructed: true, ProofConfirmed: true}, 13, modules.HostProofStatusConfirmed},
+		{storageObligation{ProofConfirmed: true, ObligationStatus: obligationSucceeded}, 30, modules.HostProofStatusConfirmed},
+		{storageObligation{ObligationStatus: obligationSucceeded}, 30, modules.HostProofStatusNotRequired},
+		{storageObligation{ObligationStatus: obligationRejected}, 5, modules.HostProofStatusNotRequired},
+		{storageObligation{ProofConstructed: true, ObligationStatus: obligationFailed}, 30, modules.HostProofStatusMissed},
+	}
+	for i, test := range tests {
+		test.so.OriginTransactionSet = origin
+		if status := test.so.proofStatus(test.height); status != test.status {
+			t.Errorf("test %v: expected %v, got %v", i, test.status, status)
+		}
+	}
+}
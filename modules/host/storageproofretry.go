@@ -0,0 +1,79 @@
+package host
+
+import (
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// A storage proof that is not confirmed by the end of the proof window costs
+// the host its collateral. The host therefore keeps an eye on the proofs that
+// it has submitted: until a proof is confirmed, it is resubmitted every few
+// blocks, each time with a higher fee so that it replaces the previous
+// submission in the transaction pools of the network. Once the end of the
+// window is near, the host pays an urgent fee. The fees are capped at the
+// value of the storage obligation, since there is no sense in spending more
+// on a proof than is lost by missing it.
+
+// storageProofFeePerByte returns the fee per byte that a storage proof
+// transaction should pay, given the recommended fee, the fee per byte of the
+// previous submission, the number of previous submissions, and the number of
+// blocks left in the proof window.
+func storageProofFeePerByte(recommended, previous types.Currency, attempts uint64, blocksLeft types.BlockHeight) types.Currency {
+	fee := recommended
+	if blocksLeft <= storageProofUrgentBlocks {
+		fee = fee.Mul64(storageProofUrgentFeeMultiplier)
+	}
+	if attempts > 0 {
+		escalated := previous.Mul64(storageProofFeeEscalation).Div64(100)
+		if escalated.Cmp(fee) > 0 {
+			fee = escalated
+		}
+	}
+	return fee
+}
+
+// storageProofRecheckHeight returns the height at which the host should next
+// check on an unconfirmed storage proof. Rechecks before the end of the
+// window are not needed at the deadline itself if an action item has already
+// been queued there, in which case false is returned. After the deadline the
+// obligation is checked once more so that it can be resolved.
+func storageProofRecheckHeight(blockHeight, deadline types.BlockHeight, deadlineQueued bool) (types.BlockHeight, bool) {
+	if blockHeight >= deadline {
+		return blockHeight + 1, true
+	}
+	next := blockHeight + resubmissionTimeout
+	if next >= deadline {
+		return deadline, !deadlineQueued
+	}
+	return next, true
+}
+
+// submitStorageProof funds, signs, and submits a transaction containing the
+// storage proof, paying the provided fee per byte. On success, the storage
+// obligation is updated to track the submission.
+func (h *Host) submitStorageProof(so *storageObligation, sp types.StorageProof, feePerByte types.Currency, txnSize uint64) error {
+	fee := feePerByte.Mul64(txnSize)
+	builder := h.wallet.StartTransaction()
+	err := builder.FundSiacoins(fee)
+	if err != nil {
+		builder.Drop()
+		return err
+	}
+	builder.AddMinerFee(fee)
+	builder.AddStorageProof(sp)
+	storageProofSet, err := builder.Sign(true)
+	if err != nil {
+		builder.Drop()
+		return err
+	}
+	err = h.tpool.AcceptTransactionSet(storageProofSet)
+	if err != nil {
+		builder.Drop()
+		return err
+	}
+	so.ProofConstructed = true
+	so.ProofAttempts++
+	so.ProofFeePerByte = feePerByte
+	so.ProofTransactionSet = storageProofSet
+	so.TransactionFeesAdded = so.TransactionFeesAdded.Add(fee)
+	return nil
+}
@@ -0,0 +1,65 @@
+package host
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestStorageProofFeePerByte checks that the fee of a storage proof escalates
+// with each resubmission and as the end of the proof window approaches.
+func TestStorageProofFeePerByte(t *testing.T) {
+	recommended := types.NewCurrency64(100)
+	relaxed := storageProofUrgentBlocks + 10
+
+	// The first submission pays the recommended fee.
+	fee := storageProofFeePerByte(recommended, types.ZeroCurrency, 0, relaxed)
+	if fee.Cmp(recommended) != 0 {
+		t.Fatal("first submission should pay the recommended fee, got", fee)
+	}
+
+	// Each resubmission pays enough to replace the previous one.
+	for attempts := uint64(1); attempts < 5; attempts++ {
+		next := storageProofFeePerByte(recommended, fee, attempts, relaxed)
+		if next.Cmp(fee.Mul64(11).Div64(10)) <= 0 {
+			t.Fatalf("resubmission %v does not pay enough to replace the previous submission: %v after %v", attempts, next, fee)
+		}
+		fee = next
+	}
+
+	// A resubmission follows the recommendation if the fee market has moved
+	// past the escalated fee.
+	fee = storageProofFeePerByte(types.NewCurrency64(1000), types.NewCurrency64(100), 1, relaxed)
+	if fee.Cmp64(1000) != 0 {
+		t.Fatal("resubmission should follow a higher recommendation, got", fee)
+	}
+
+	// Near the end of the window, the urgent fee is paid.
+	fee = storageProofFeePerByte(recommended, types.ZeroCurrency, 0, storageProofUrgentBlocks)
+	if fee.Cmp(recommended.Mul64(storageProofUrgentFeeMultiplier)) != 0 {
+		t.Fatal("urgent submission should pay the urgent fee, got", fee)
+	}
+}
+
+// TestStorageProofRecheckHeight checks that unconfirmed storage proofs are
+// rechecked regularly until the end of the window, and once after it.
+func TestStorageProofRecheckHeight(t *testing.T) {
+	tests := []struct {
+		height, deadline types.BlockHeight
+		deadlineQueued   bool
+		recheck          types.BlockHeight
+		ok               bool
+	}{
+		{100, 200, true, 100 + resubmissionTimeout, true},
+		{200 - resubmissionTimeout, 200, false, 200, true},
+		{200 - resubmissionTimeout, 200, true, 0, false},
+		{200, 200, true, 201, true},
+		{205, 200, false, 206, true},
+	}
+	for _, test := range tests {
+		recheck, ok := storageProofRecheckHeight(test.height, test.deadline, test.deadlineQueued)
+		if ok != test.ok || (ok && recheck != test.recheck) {
+			t.Errorf("height %v, deadline %v, queued %v: expected (%v, %v), got (%v, %v)", test.height, test.deadline, test.deadlineQueued, test.recheck, test.ok, recheck, ok)
+		}
+	}
+}
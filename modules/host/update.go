@@ -316,6 +316,13 @@ func (h *Host) ProcessConsensusChange(cc modules.ConsensusChange) {
 		go h.threadedHandleActionItem(actionItems[i], wg)
 	}
 
+	// Remove the sectors of handoffs that the renter did not attach before
+	// the handoff authorization expired.
+	if expired := h.expiredHandoffs(); len(expired) > 0 {
+		wg.Add(1)
+		go h.threadedRemoveHandoffSectors(expired, wg)
+	}
+
 	// Update the host's recent change pointer to point to the most recent
 	// change.
 	h.recentChange = cc.ID
@@ -16,9 +16,11 @@ import (
 	"github.com/NebulousLabs/go-upnp"
 )
 
-// managedLearnHostname discovers the external IP of the Host. If the host's
-// net address is blank and the host's auto address appears to have changed,
-// the host will make an announcement on the blockchain.
+// managedLearnHostname discovers the external IP of the Host. The address is
+// learned when the host's net address is blank, in which case the host
+// announces the learned address, and when the net address is a DNS name, in
+// which case the learned address is used to check that the name still
+// resolves to the host.
 func (h *Host) managedLearnHostname() {
 	if build.Release == "testing" {
 		return
@@ -30,17 +32,16 @@ func (h *Host) managedLearnHostname() {
 	netAddr := h.settings.NetAddress
 	hostPort := h.port
 	hostAutoAddress := h.autoAddress
-	hostAnnounced := h.announced
-	hostAcceptingContracts := h.settings.AcceptingContracts
-	hostContractCount := h.financialMetrics.ContractCount
 	h.mu.RUnlock()
 
-	// If the settings indicate that an address has been manually set, there is
-	// no reason to learn the hostname.
-	if netAddr != "" {
+	// If the settings indicate that an IP address has been manually set, there
+	// is no reason to learn the hostname.
+	if netAddr != "" && net.ParseIP(netAddr.Host()) != nil {
 		return
 	}
-	h.log.Println("No manually set net address. Scanning to automatically determine address.")
+	if netAddr == "" {
+		h.log.Println("No manually set net address. Scanning to automatically determine address.")
+	}
 
 	// try UPnP first, then fallback to myexternalip.com
 	var hostname string
@@ -61,9 +62,8 @@ func (h *Host) managedLearnHostname() {
 		h.log.Printf("WARN: discovered hostname %q is invalid: %v", autoAddress, err)
 		return
 	}
-	if autoAddress == hostAutoAddress && hostAnnounced {
-		// Nothing to do - the auto address has not changed and the previous
-		// annoucement was successful.
+	if autoAddress == hostAutoAddress {
+		// Nothing to do - the auto address has not changed.
 		return
 	}
 
@@ -74,22 +74,8 @@ func (h *Host) managedLearnHostname() {
 	if err != nil {
 		h.log.Println(err)
 	}
-
-	// Announce the host, but only if the host is either accepting contracts or
-	// has a storage obligation. If the host is not accepting contracts and has
-	// no open contracts, there is no reason to notify anyone that the host's
-	// address has changed.
-	if hostAcceptingContracts || hostContractCount > 0 {
-		h.log.Println("Host external IP address changed from", hostAutoAddress, "to", autoAddress, "- performing host announcement.")
-		err = h.managedAnnounce(autoAddress)
-		if err != nil {
-			// Set h.announced to false, as the address has changed yet the
-			// renewed annoucement has failed.
-			h.mu.Lock()
-			h.announced = false
-			h.mu.Unlock()
-			h.log.Println("unable to announce address after upnp-detected address change:", err)
-		}
+	if hostAutoAddress != "" {
+		h.log.Println("Host external IP address changed from", hostAutoAddress, "to", autoAddress)
 	}
 }
 
@@ -74,6 +74,12 @@ type Miner struct {
 	sourceBlockTime time.Time                                      // How long headers have been using the same block (different from 'recent block').
 	memProgress     int                                            // The index of the most recent header used in headerMem.
 
+	// tpoolUpdated indicates that the transaction pool has changed since the
+	// transactions of the unsolved block were selected. updateMu serializes
+	// the selection, see managedUpdateBlockTransactions.
+	tpoolUpdated bool
+	updateMu     sync.Mutex
+
 	// CPUMiner variables.
 	miningOn bool  // indicates if the miner is supposed to be running
 	mining   bool  // indicates if the miner is actually running
@@ -53,6 +53,7 @@ func (m *Miner) BlockForWork() (b types.Block, t types.Target, err error) {
 		err = modules.ErrLockedWallet
 		return
 	}
+	m.managedUpdateBlockTransactions()
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	err = m.checkAddress()
@@ -81,6 +82,7 @@ func (m *Miner) AddBlock() (types.Block, error) {
 func (m *Miner) FindBlock() (types.Block, error) {
 	var bfw types.Block
 	var target types.Target
+	m.managedUpdateBlockTransactions()
 	err := func() error {
 		m.mu.Lock()
 		defer m.mu.Unlock()
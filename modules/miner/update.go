@@ -1,7 +1,8 @@
 package miner
 
 import (
-	"github.com/NebulousLabs/Sia/encoding"
+	"time"
+
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 )
@@ -43,9 +44,11 @@ func (m *Miner) ProcessConsensusChange(cc modules.ConsensusChange) {
 	m.persist.UnsolvedBlock.Timestamp = cc.MinimumValidChildTimestamp
 
 	// There is a new parent block, the source block should be updated to keep
-	// the stale rate as low as possible.
+	// the stale rate as low as possible. The source block is replaced by the
+	// next call to HeaderForWork, after the transactions confirmed by the
+	// new block have been removed from the unsolved block.
 	if cc.Synced {
-		m.newSourceBlock()
+		m.sourceBlockTime = time.Time{}
 	}
 	m.persist.RecentChange = cc.ID
 	err := m.save()
@@ -54,28 +57,35 @@ func (m *Miner) ProcessConsensusChange(cc modules.ConsensusChange) {
 	}
 }
 
-// ReceiveUpdatedUnconfirmedTransactions will replace the current unconfirmed
-// set of transactions with the input transactions.
-func (m *Miner) ReceiveUpdatedUnconfirmedTransactions(unconfirmedTransactions []types.Transaction, _ modules.ConsensusChange) {
+// ReceiveUpdatedUnconfirmedTransactions marks the transactions of the unsolved
+// block as out of date. The transaction pool holds its lock while notifying
+// subscribers, so the transactions are selected from the pool by
+// managedUpdateBlockTransactions the next time a block is requested.
+func (m *Miner) ReceiveUpdatedUnconfirmedTransactions(_ []types.Transaction, _ modules.ConsensusChange) {
 	m.mu.Lock()
-	defer m.mu.Unlock()
+	m.tpoolUpdated = true
+	m.mu.Unlock()
+}
+
+// managedUpdateBlockTransactions replaces the transactions of the unsolved
+// block with the transactions from the pool that pay the most in fees while
+// fitting in a block, if the pool has changed since they were last selected.
+// 5e3 bytes are left for the miner payouts and the arbitrary data transaction
+// added by blockForWork.
+func (m *Miner) managedUpdateBlockTransactions() {
+	m.updateMu.Lock()
+	defer m.updateMu.Unlock()
 
-	// Edge case - if there are no transactions, set the block's transactions
-	// to nil and return.
-	if len(unconfirmedTransactions) == 0 {
-		m.persist.UnsolvedBlock.Transactions = nil
+	m.mu.Lock()
+	updated := m.tpoolUpdated
+	m.tpoolUpdated = false
+	m.mu.Unlock()
+	if !updated {
 		return
 	}
 
-	// Add transactions to the block until the block size limit is reached.
-	// Transactions are assumed to be in a sensible order.
-	var i int
-	remainingSize := int(types.BlockSizeLimit - 5e3)
-	for i = range unconfirmedTransactions {
-		remainingSize -= len(encoding.Marshal(unconfirmedTransactions[i]))
-		if remainingSize < 0 {
-			break
-		}
-	}
-	m.persist.UnsolvedBlock.Transactions = unconfirmedTransactions[:i+1]
+	txns := m.tpool.TransactionsForBlock(types.BlockSizeLimit - 5e3)
+	m.mu.Lock()
+	m.persist.UnsolvedBlock.Transactions = txns
+	m.mu.Unlock()
 }
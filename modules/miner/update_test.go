@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
 )
 
 // TestIntegrationBlockHeightReorg checks that the miner has the correct block
@@ -96,3 +97,48 @@ func TestIntegrationBlockHeightReorg(t *testing.T) {
 		t.Fatal("mt1 and mt3 should have the same current block")
 	}
 }
+
+// TestIntegrationBlockTransactions checks that the transactions of the
+// unsolved block are selected from the transaction pool when a block is
+// requested, and that confirmed transactions are removed from it.
+func TestIntegrationBlockTransactions(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	mt, err := createMinerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	txns, err := mt.wallet.SendSiacoins(types.SiacoinPrecision, types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent := txns[len(txns)-1].ID()
+	contains := func(b types.Block) bool {
+		for _, txn := range b.Transactions {
+			if txn.ID() == sent {
+				return true
+			}
+		}
+		return false
+	}
+	b, _, err := mt.miner.BlockForWork()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !contains(b) {
+		t.Fatal("block for work does not contain the transaction from the pool")
+	}
+
+	if _, err := mt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	b, _, err = mt.miner.BlockForWork()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contains(b) {
+		t.Fatal("block for work contains a confirmed transaction")
+	}
+}
@@ -28,6 +28,12 @@ const (
 	// the negotiation.
 	NegotiateDownloadTime = 600 * time.Second
 
+	// NegotiateHandoffTime defines the amount of time that the hosts involved
+	// in a handoff have to negotiate the handoff, not counting the time spent
+	// transferring sectors. Each transferred sector extends the deadline by
+	// NegotiateFileContractRevisionTime.
+	NegotiateHandoffTime = 120 * time.Second
+
 	// NegotiateFileContractTime defines the amount of time that the renter and
 	// host have to negotiate a file contract. The time is set high enough that
 	// a node behind Tor has a reasonable chance at making the multiple
@@ -83,6 +89,11 @@ const (
 	// as a reasonable guideline for determining what is too large.
 	NegotiateMaxFileContractSetLen = TransactionSetSizeLimit - 1e3
 
+	// NegotiateMaxHandoffAuthorizationSize is the maximum allowed size of an
+	// encoded HandoffAuthorization, which lists the Merkle roots of every
+	// sector being handed off.
+	NegotiateMaxHandoffAuthorizationSize = 10e6
+
 	// NegotiateMaxHostExternalSettingsLen is the maximum allowed size of an
 	// encoded HostExternalSettings.
 	NegotiateMaxHostExternalSettingsLen = 16000
@@ -103,6 +114,11 @@ const (
 )
 
 var (
+	// ActionAttach is the specifier for a RevisionAction that inserts a
+	// sector that was handed off to the host by another host. Instead of the
+	// sector data, the action carries the Merkle root of the sector.
+	ActionAttach = types.Specifier{'A', 't', 't', 'a', 'c', 'h'}
+
 	// ActionDelete is the specifier for a RevisionAction that deletes a
 	// sector.
 	ActionDelete = types.Specifier{'D', 'e', 'l', 'e', 't', 'e'}
@@ -149,6 +165,22 @@ var (
 	// RPCFormContract is the specifier for forming a contract with a host.
 	RPCFormContract = types.Specifier{'F', 'o', 'r', 'm', 'C', 'o', 'n', 't', 'r', 'a', 'c', 't', 2}
 
+	// RPCHandoff is the specifier for asking a retiring host to hand off the
+	// sectors of a contract to a replacement host.
+	RPCHandoff = types.Specifier{'H', 'a', 'n', 'd', 'o', 'f', 'f'}
+
+	// RPCReceiveHandoff is the specifier used by a retiring host to transfer
+	// sectors to a replacement host.
+	RPCReceiveHandoff = types.Specifier{'R', 'e', 'c', 'e', 'i', 'v', 'e', 'H', 'a', 'n', 'd', 'o', 'f', 'f'}
+
+	// RPCRelayAccept is the specifier used by a host behind a relay to open
+	// the connection that a renter's session is relayed over.
+	RPCRelayAccept = types.Specifier{'R', 'e', 'l', 'a', 'y', 'A', 'c', 'c', 'e', 'p', 't'}
+
+	// RPCRelayRegister is the specifier used by a host that is not reachable
+	// from the internet to register with a relay host.
+	RPCRelayRegister = types.Specifier{'R', 'e', 'l', 'a', 'y', 'R', 'e', 'g', 'i', 's', 't', 'e', 'r'}
+
 	// RPCRenewContract is the specifier to renewing an existing contract.
 	RPCRenewContract = types.Specifier{'R', 'e', 'n', 'e', 'w', 'C', 'o', 'n', 't', 'r', 'a', 'c', 't', 2}
 
@@ -259,10 +291,13 @@ type (
 	}
 
 	// A RevisionAction is a description of an edit to be performed on a file
-	// contract. Three types are allowed, 'ActionDelete', 'ActionInsert', and
-	// 'ActionModify'. ActionDelete just takes a sector index, indicating which
-	// sector is going to be deleted. ActionInsert takes a sector index, and a
-	// full sector of data, indicating that a sector at the index should be
+	// contract. Four types are allowed, 'ActionAttach', 'ActionDelete',
+	// 'ActionInsert', and 'ActionModify'. ActionAttach takes a sector index,
+	// and the Merkle root of a sector that was handed off to the host as its
+	// data, indicating that the handed off sector should be inserted at the
+	// index. ActionDelete just takes a sector index, indicating which sector
+	// is going to be deleted. ActionInsert takes a sector index, and a full
+	// sector of data, indicating that a sector at the index should be
 	// inserted with the provided data. 'Modify' revises the sector at the
 	// given index, rewriting it with the provided data starting from the
 	// 'offset' within the sector.
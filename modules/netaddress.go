@@ -60,6 +60,19 @@ func (na NetAddress) IsLoopback() bool {
 	return false
 }
 
+// IsIPv6 returns true if the host of the NetAddress is an IPv6 address.
+// IPv4-mapped IPv6 addresses are treated as IPv4 addresses.
+func (na NetAddress) IsIPv6() bool {
+	ip := net.ParseIP(na.Host())
+	return ip != nil && ip.To4() == nil
+}
+
+// IsOnion returns true if the host of the NetAddress is a Tor onion service,
+// which can only be reached through a Tor proxy.
+func (na NetAddress) IsOnion() bool {
+	return strings.HasSuffix(strings.ToLower(strings.TrimSuffix(na.Host(), ".")), ".onion")
+}
+
 // IsLocal returns true if the input IP address belongs to a local address
 // range such as 192.168.x.x or 127.x.x.x
 func (na NetAddress) IsLocal() bool {
@@ -82,6 +95,7 @@ func (na NetAddress) IsLocal() bool {
 		"172.16.0.0/12",
 		"192.168.0.0/16",
 		"fd00::/8",
+		"fe80::/10",
 	}
 	for _, cidr := range localCIDRs {
 		_, ipnet, _ := net.ParseCIDR(cidr)
@@ -134,6 +148,13 @@ func (na NetAddress) IsStdValid() error {
 	if ip := net.ParseIP(host); ip != nil {
 		if ip.IsUnspecified() {
 			return errors.New("host is the unspecified address")
+		} else if ip.IsMulticast() {
+			return errors.New("host is a multicast address")
+		} else if ip.To4() == nil && ip.IsLinkLocalUnicast() {
+			// IPv6 link-local addresses can only be dialed along with the
+			// zone of the interface they belong to, which is only meaningful
+			// to the machine that owns the address.
+			return errors.New("host is an IPv6 link-local address")
 		}
 	} else {
 		// Hostnames can have a trailing dot (which indicates that the hostname is
@@ -74,6 +74,11 @@ var (
 		"foo:1000000",
 		"localhost:0",
 		"[::1]:0",
+		// Multicast and IPv6 link-local addresses
+		"224.0.0.1:123",
+		"[ff02::1]:123",
+		"[fe80::1]:123",
+		"[fe80::1%eth0]:123",
 	}
 	validAddrs = []string{
 		// Loopback address (valid in testing only, can't really test this well)
@@ -95,6 +100,8 @@ var (
 		strings.Repeat(strings.Repeat("a", 63)+".", 3) + "a:123", // 3x63 char length labels + 1x1 char length label without trailing dot
 		strings.Repeat(strings.Repeat("a", 63)+".", 3) + ":123",  // 3x63 char length labels with trailing dot
 		"[::2]:65535",
+		"[2001:db8::1]:9981",
+		"[2001:DB8:0:0:0:0:0:1]:9981",
 		"111.111.111.111:111",
 		"12.34.45.64:7777",
 	}
@@ -284,6 +291,9 @@ func TestIsLocal(t *testing.T) {
 		{"[fdff:ffff:ffff:ffff:ffff:ffff:ffff:ffff]:1234", true},
 		{"fe00:0000:0000:0000:0000:0000:0000:0000", false},
 		{"[fe00:0000:0000:0000:0000:0000:0000:0000]:1234", false},
+		{"[fe80::1]:1234", true},
+		{"[febf:ffff::1]:1234", true},
+		{"[fec0::1]:1234", false},
 
 		// Unspecified address tests.
 		{"0.0.0.0:1234", false},
@@ -317,3 +327,54 @@ func TestIsLocal(t *testing.T) {
 		}
 	}
 }
+
+// TestIsIPv6 checks that IsIPv6 only returns true for addresses whose host is
+// an IPv6 address.
+func TestIsIPv6(t *testing.T) {
+	t.Parallel()
+
+	testSet := []struct {
+		query           NetAddress
+		desiredResponse bool
+	}{
+		{"[::1]:1234", true},
+		{"[2001:db8::1]:9981", true},
+		{"[fd00::1]:9981", true},
+		{"[::ffff:1.2.3.4]:9981", false},
+		{"1.2.3.4:9981", false},
+		{"localhost:9981", false},
+		{"foo.com:9981", false},
+		{"2001:db8::1", false},
+		{"", false},
+	}
+	for _, test := range testSet {
+		if test.query.IsIPv6() != test.desiredResponse {
+			t.Error("test failed:", test, test.query.IsIPv6())
+		}
+	}
+}
+
+// TestIsOnion checks that IsOnion only returns true for onion service
+// addresses.
+func TestIsOnion(t *testing.T) {
+	t.Parallel()
+
+	testSet := []struct {
+		query           NetAddress
+		desiredResponse bool
+	}{
+		{"vww6ybal4bd7szmgncyruucpgfkqahzddi37ktceo3ah7ngmcopnpyyd.onion:9981", true},
+		{"VWW6YBAL4BD7SZMGNCYRUUCPGFKQAHZDDI37KTCEO3AH7NGMCOPNPYYD.ONION:9981", true},
+		{"expyuzz4wqqyqhjn.onion.:9981", true},
+		{"onion:9981", false},
+		{"foo.onion.com:9981", false},
+		{"1.2.3.4:9981", false},
+		{"[2001:db8::1]:9981", false},
+		{"", false},
+	}
+	for _, test := range testSet {
+		if test.query.IsOnion() != test.desiredResponse {
+			t.Error("test failed:", test, test.query.IsOnion())
+		}
+	}
+}
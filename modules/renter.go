@@ -2,11 +2,14 @@ package modules
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
+	"math"
 	"time"
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/types"
 )
 
@@ -38,13 +41,60 @@ type ErasureCoder interface {
 	Recover(pieces [][]byte, n uint64, w io.Writer) error
 }
 
+// Binding constraints reported by a ContractFormationReport.
+const (
+	// ContractConstraintHosts indicates that the hostdb did not contain
+	// enough hosts that are online and accepting contracts.
+	ContractConstraintHosts = "hosts"
+
+	// ContractConstraintStoragePrice indicates that too many hosts charged
+	// more than the storage price limit.
+	ContractConstraintStoragePrice = "storageprice"
+
+	// ContractConstraintNegotiation indicates that too many hosts failed
+	// contract negotiation.
+	ContractConstraintNegotiation = "negotiation"
+)
+
 // An Allowance dictates how much the Renter is allowed to spend in a given
 // period. Note that funds are spent on both storage and bandwidth.
+//
+// MaxStoragePrice is the highest storage price that the renter may accept if
+// it cannot form enough contracts with hosts below the default limit. The
+// limit is raised step by step, and never above MaxStoragePrice. If
+// MaxStoragePrice is zero, the default limit is never raised.
 type Allowance struct {
-	Funds       types.Currency    `json:"funds"`
-	Hosts       uint64            `json:"hosts"`
-	Period      types.BlockHeight `json:"period"`
-	RenewWindow types.BlockHeight `json:"renewwindow"`
+	Funds           types.Currency    `json:"funds"`
+	Hosts           uint64            `json:"hosts"`
+	Period          types.BlockHeight `json:"period"`
+	RenewWindow     types.BlockHeight `json:"renewwindow"`
+	MaxStoragePrice types.Currency    `json:"maxstorageprice"`
+}
+
+// A ContractFormationReport describes the renter's most recent attempt to form
+// contracts. If fewer contracts were formed than wanted, BindingConstraint
+// names the constraint that prevented the renter from forming more.
+type ContractFormationReport struct {
+	Time              time.Time `json:"time"`
+	Wanted            int       `json:"wanted"`
+	Formed            int       `json:"formed"`
+	BindingConstraint string    `json:"bindingconstraint"`
+
+	// StoragePriceLimit is the highest storage price that was accepted,
+	// after any relaxation of the default limit.
+	StoragePriceLimit types.Currency `json:"storagepricelimit"`
+
+	// HostsConsidered counts the hosts that contract formation was attempted
+	// with, HostsTooExpensive counts the attempts rejected because of the
+	// storage price limit, and HostsFailed counts the attempts that failed
+	// for any other reason.
+	HostsConsidered   int `json:"hostsconsidered"`
+	HostsTooExpensive int `json:"hoststooexpensive"`
+	HostsFailed       int `json:"hostsfailed"`
+
+	// Rescanned indicates that the hostdb did not have enough hosts, and was
+	// asked to rescan every host it knows about.
+	Rescanned bool `json:"rescanned"`
 }
 
 // DownloadInfo provides information about a file that has been requested for
@@ -53,6 +103,8 @@ type DownloadInfo struct {
 	SiaPath     string    `json:"siapath"`
 	Destination string    `json:"destination"`
 	Filesize    uint64    `json:"filesize"`
+	Offset      uint64    `json:"offset"`
+	Length      uint64    `json:"length"`
 	Received    uint64    `json:"received"`
 	StartTime   time.Time `json:"starttime"`
 	Error       string    `json:"error"`
@@ -75,6 +127,155 @@ type FileInfo struct {
 	Redundancy     float64           `json:"redundancy"`
 	UploadProgress float64           `json:"uploadprogress"`
 	Expiration     types.BlockHeight `json:"expiration"`
+
+	// DataPieces and ParityPieces are the erasure coding parameters of the
+	// file. Each chunk can be recovered from any DataPieces of its
+	// DataPieces+ParityPieces pieces.
+	DataPieces   int `json:"datapieces"`
+	ParityPieces int `json:"paritypieces"`
+}
+
+// A RenterStaleContract is a contract referenced by a file's metadata that
+// has expired without being renewed. The host is no longer obligated to store
+// the pieces it covers.
+type RenterStaleContract struct {
+	SiaPath     string               `json:"siapath"`
+	ID          types.FileContractID `json:"id"`
+	NetAddress  NetAddress           `json:"netaddress"`
+	WindowStart types.BlockHeight    `json:"windowstart"`
+	Pieces      int                  `json:"pieces"`
+}
+
+// A RenterOrphanedPieces entry counts the piece records of a file that refer
+// to a chunk or piece index that the file does not have.
+type RenterOrphanedPieces struct {
+	SiaPath string `json:"siapath"`
+	Pieces  int    `json:"pieces"`
+}
+
+// RenterGarbage lists the renter metadata that no longer serves a purpose and
+// can be removed by a garbage collection pass. Files are unrecoverable if,
+// ignoring stale contracts, they have less than one full copy on the network
+// and the renter cannot repair them from a local copy. OrphanedShareFiles are
+// .sia files in the renter directory that do not belong to any known file, and
+// OrphanedTracking lists repair entries for files that no longer exist.
+type RenterGarbage struct {
+	StaleContracts     []RenterStaleContract  `json:"stalecontracts"`
+	OrphanedPieces     []RenterOrphanedPieces `json:"orphanedpieces"`
+	UnrecoverableFiles []string               `json:"unrecoverablefiles"`
+	OrphanedShareFiles []string               `json:"orphanedsharefiles"`
+	OrphanedTracking   []string               `json:"orphanedtracking"`
+}
+
+// A RenterManifestFile describes a single file listed in a RenterManifest.
+// The ContentHash commits to the file's size, encryption key, and the Merkle
+// root of each erasure-coded piece currently stored on the network, so any
+// change to the file's metadata results in a different hash. Copies of a piece
+// stored on several hosts are only counted once.
+type RenterManifestFile struct {
+	SiaPath      string       `json:"siapath"`
+	Filesize     uint64       `json:"filesize"`
+	ContentHash  crypto.Hash  `json:"contenthash"`
+	DataPieces   int          `json:"datapieces"`
+	ParityPieces int          `json:"paritypieces"`
+	Redundancy   float64      `json:"redundancy"`
+	Hosts        []NetAddress `json:"hosts"`
+}
+
+// A RenterManifest lists every file known to the renter at a given height. It
+// is signed with a key belonging to the renter, so that a copy kept apart
+// from siad can later be used to check that a restored renter is complete and
+// has not been tampered with.
+type RenterManifest struct {
+	Height    types.BlockHeight    `json:"height"`
+	Timestamp types.Timestamp      `json:"timestamp"`
+	Files     []RenterManifestFile `json:"files"`
+	PublicKey types.SiaPublicKey   `json:"publickey"`
+	Signature []byte               `json:"signature"`
+}
+
+// ErrInvalidManifestSignature is returned when a RenterManifest's signature
+// does not match its contents.
+var ErrInvalidManifestSignature = errors.New("manifest signature is invalid")
+
+// SigHash returns the hash of the manifest that is covered by its signature.
+func (m RenterManifest) SigHash() crypto.Hash {
+	h := crypto.NewHash()
+	enc := encoding.NewEncoder(h)
+	enc.EncodeAll(m.Height, m.Timestamp, m.PublicKey, uint64(len(m.Files)))
+	for _, f := range m.Files {
+		// Redundancy is encoded by its bit pattern, as the encoding package
+		// does not support floating point values.
+		enc.EncodeAll(f.SiaPath, f.Filesize, f.ContentHash, f.DataPieces,
+			f.ParityPieces, math.Float64bits(f.Redundancy), f.Hosts)
+	}
+	var sh crypto.Hash
+	copy(sh[:], h.Sum(nil))
+	return sh
+}
+
+// VerifySignature checks that the manifest was signed by the key in its
+// PublicKey field and has not been modified since.
+func (m RenterManifest) VerifySignature() error {
+	if !verifyRenterSignature(m.SigHash(), m.PublicKey, m.Signature) {
+		return ErrInvalidManifestSignature
+	}
+	return nil
+}
+
+// A RenterUploadReceiptPiece records the Merkle root of one piece of an
+// uploaded file, and the host and contract that store it.
+type RenterUploadReceiptPiece struct {
+	Chunk      uint64               `json:"chunk"`
+	Piece      uint64               `json:"piece"`
+	MerkleRoot crypto.Hash          `json:"merkleroot"`
+	Host       NetAddress           `json:"host"`
+	ContractID types.FileContractID `json:"contractid"`
+}
+
+// A RenterUploadReceipt is produced when a file finishes uploading. It lists
+// every piece of the file that reached the network, sorted by chunk and piece
+// index, and is signed with the same key as the renter's manifests, giving
+// backup software an auditable record of the upload.
+type RenterUploadReceipt struct {
+	SiaPath   string                     `json:"siapath"`
+	Filesize  uint64                     `json:"filesize"`
+	Height    types.BlockHeight          `json:"height"`
+	Timestamp types.Timestamp            `json:"timestamp"`
+	Pieces    []RenterUploadReceiptPiece `json:"pieces"`
+	PublicKey types.SiaPublicKey         `json:"publickey"`
+	Signature []byte                     `json:"signature"`
+}
+
+// ErrInvalidReceiptSignature is returned when a RenterUploadReceipt's
+// signature does not match its contents.
+var ErrInvalidReceiptSignature = errors.New("upload receipt signature is invalid")
+
+// SigHash returns the hash of the receipt that is covered by its signature.
+func (r RenterUploadReceipt) SigHash() crypto.Hash {
+	return crypto.HashAll(r.SiaPath, r.Filesize, r.Height, r.Timestamp, r.Pieces, r.PublicKey)
+}
+
+// VerifySignature checks that the receipt was signed by the key in its
+// PublicKey field and has not been modified since.
+func (r RenterUploadReceipt) VerifySignature() error {
+	if !verifyRenterSignature(r.SigHash(), r.PublicKey, r.Signature) {
+		return ErrInvalidReceiptSignature
+	}
+	return nil
+}
+
+// verifyRenterSignature returns true if signature is a valid signature of
+// hash by the Ed25519 key spk.
+func verifyRenterSignature(hash crypto.Hash, spk types.SiaPublicKey, signature []byte) bool {
+	var pk crypto.PublicKey
+	var sig crypto.Signature
+	if spk.Algorithm != types.SignatureEd25519 || len(spk.Key) != len(pk) || len(signature) != len(sig) {
+		return false
+	}
+	copy(pk[:], spk.Key)
+	copy(sig[:], signature)
+	return crypto.VerifyHash(hash, pk, sig) == nil
 }
 
 // A HostDBEntry represents one host entry in the Renter's host DB. It
@@ -239,6 +440,15 @@ type Renter interface {
 	// Close closes the Renter.
 	Close() error
 
+	// CollectGarbage removes the metadata listed in confirmed that is still
+	// garbage, and returns what was removed. Unrecoverable files are deleted
+	// from the renter.
+	CollectGarbage(confirmed RenterGarbage) (RenterGarbage, error)
+
+	// ContractFormationReport describes the most recent attempt to form
+	// contracts.
+	ContractFormationReport() ContractFormationReport
+
 	// Contracts returns the contracts formed by the renter.
 	Contracts() []RenterContract
 
@@ -252,12 +462,29 @@ type Renter interface {
 	// Download downloads a file to the given destination.
 	Download(path, destination string) error
 
+	// DownloadRange downloads length bytes of a file, starting at offset, to
+	// the given destination. Only the chunks covering the range are fetched.
+	DownloadRange(path, destination string, offset, length uint64) error
+
+	// DownloadStreamer returns an io.ReadSeeker over the file at siapath.
+	// Chunks are downloaded on demand as they are read.
+	DownloadStreamer(siapath string) (io.ReadSeeker, error)
+
 	// DownloadQueue lists all the files that have been scheduled for download.
 	DownloadQueue() []DownloadInfo
 
 	// FileList returns information on all of the files stored by the renter.
 	FileList() []FileInfo
 
+	// Garbage reports the renter metadata that references expired contracts,
+	// unrecoverable files, and orphaned records, without removing anything.
+	Garbage() RenterGarbage
+
+	// Handoff asks the retiring host at from to hand off the renter's data to
+	// the host at to, and re-points the renter's files at the replacement
+	// host. The renter must have contracts with both hosts.
+	Handoff(from, to NetAddress) (HandoffRecord, error)
+
 	// Host provides the DB entry and score breakdown for the requested host.
 	Host(pk types.SiaPublicKey) (HostDBEntry, bool)
 
@@ -269,6 +496,20 @@ type Renter interface {
 	// renter.
 	LoadSharedFilesAscii(asciiSia string) ([]string, error)
 
+	// Manifest returns a signed manifest listing every file known to the
+	// renter, along with its content hash, size, redundancy, and hosts.
+	Manifest() RenterManifest
+
+	// Pause halts all uploads, downloads, repairs, and the formation of new
+	// contracts for the provided duration, after which activity resumes
+	// automatically. Contracts that have entered their renew window are
+	// still renewed while the renter is paused.
+	Pause(duration time.Duration) error
+
+	// PausedUntil returns the time at which renter activity will resume. The
+	// zero time is returned if the renter is not paused.
+	PausedUntil() time.Time
+
 	// PriceEstimation estimates the cost in siacoins of performing various
 	// storage and data operations.
 	PriceEstimation() RenterPriceEstimation
@@ -276,6 +517,9 @@ type Renter interface {
 	// RenameFile changes the path of a file.
 	RenameFile(path, newPath string) error
 
+	// Resume resumes all renter activity that was halted by Pause.
+	Resume()
+
 	// ScoreBreakdown will return the score for a host db entry using the
 	// hostdb's weighting algorithm.
 	ScoreBreakdown(entry HostDBEntry) HostScoreBreakdown
@@ -294,4 +538,17 @@ type Renter interface {
 
 	// Upload uploads a file using the input parameters.
 	Upload(FileUploadParams) error
+
+	// UploadStream uploads the data read from r as it arrives, without a
+	// source file on disk. The Source of the parameters is ignored.
+	UploadStream(up FileUploadParams, r io.Reader) error
+
+	// UploadReceipt returns the signed receipt that was produced when the
+	// file at siapath finished uploading. false is returned if the file has
+	// not finished uploading.
+	UploadReceipt(siapath string) (RenterUploadReceipt, bool)
+
+	// UploadReceipts returns the receipts of every file that has finished
+	// uploading, sorted by siapath.
+	UploadReceipts() []RenterUploadReceipt
 }
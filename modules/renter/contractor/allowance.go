@@ -89,9 +89,19 @@ func (c *Contractor) SetAllowance(a modules.Allowance) error {
 	shouldRenew := a.Period != c.allowance.Period || !a.Funds.Equals(c.allowance.Funds)
 	shouldWait := c.blockHeight+a.Period < c.contractEndHeight()
 	remaining := int(a.Hosts) - len(c.contracts)
+	paused := time.Now().Before(c.formationPausedUntil)
 	c.mu.RUnlock()
 
-	if !shouldRenew {
+	if !shouldRenew && paused {
+		// No new contracts are formed while formation is paused, so only the
+		// allowance is set. The missing contracts are formed once the pause
+		// ends.
+		c.mu.Lock()
+		c.allowance = a
+		err = c.saveSync()
+		c.mu.Unlock()
+		return err
+	} else if !shouldRenew {
 		// If no contracts need renewing, just form new contracts.
 		return c.managedFormAllowanceContracts(remaining, numSectors, a)
 	} else if shouldWait {
@@ -143,9 +153,10 @@ func (c *Contractor) SetAllowance(a modules.Allowance) error {
 		}
 	}
 
-	// if we did not renew enough contracts, form new ones
-	if remaining > 0 {
-		formed, err := c.managedFormContracts(remaining, numSectors, endHeight)
+	// if we did not renew enough contracts, form new ones, unless formation
+	// is paused
+	if remaining > 0 && !paused {
+		formed, err := c.managedFormContracts(remaining, numSectors, endHeight, a)
 		if err != nil {
 			return err
 		}
@@ -154,8 +165,16 @@ func (c *Contractor) SetAllowance(a modules.Allowance) error {
 		}
 	}
 
-	// if we weren't able to form anything, return an error
-	if len(newContracts) == 0 {
+	// if we weren't able to form anything, return an error. While formation
+	// is paused, the existing contracts are kept instead, and are renewed with
+	// the new allowance once they enter the renew window.
+	if len(newContracts) == 0 && paused {
+		c.mu.Lock()
+		c.allowance = a
+		err = c.saveSync()
+		c.mu.Unlock()
+		return err
+	} else if len(newContracts) == 0 {
 		return errors.New("unable to form or renew any contracts")
 	}
 
@@ -200,7 +219,7 @@ func (c *Contractor) managedFormAllowanceContracts(n int, numSectors uint64, a m
 	c.mu.RUnlock()
 
 	// form the contracts
-	formed, err := c.managedFormContracts(n, numSectors, endHeight)
+	formed, err := c.managedFormContracts(n, numSectors, endHeight, a)
 	if err != nil {
 		return err
 	}
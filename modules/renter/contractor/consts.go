@@ -2,6 +2,7 @@ package contractor
 
 import (
 	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/types"
 )
 
 const (
@@ -39,4 +40,21 @@ var (
 			panic("unrecognized build.Release in minHostsForEstimations")
 		}
 	}()
+
+	// handoffDuration is the number of blocks that a handoff authorization
+	// remains valid for. The replacement host stages the handed off sectors
+	// until the authorization expires, so the contractor must attach them
+	// before then.
+	handoffDuration = func() types.BlockHeight {
+		switch build.Release {
+		case "dev":
+			return 18
+		case "standard":
+			return 72
+		case "testing":
+			return 5
+		default:
+			panic("unrecognized build.Release in handoffDuration")
+		}
+	}()
 )
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/persist"
@@ -55,6 +56,13 @@ type Contractor struct {
 	currentPeriod types.BlockHeight
 	lastChange    modules.ConsensusChangeID
 
+	// formationPausedUntil is the time before which no new contracts will be
+	// formed automatically. Renewals are unaffected.
+	formationPausedUntil time.Time
+
+	// formationReport describes the most recent attempt to form contracts.
+	formationReport modules.ContractFormationReport
+
 	downloaders map[types.FileContractID]*hostDownloader
 	editors     map[types.FileContractID]*hostEditor
 	renewing    map[types.FileContractID]bool // prevent revising during renewal
@@ -85,6 +93,14 @@ func (c *Contractor) Contract(hostAddr modules.NetAddress) (modules.RenterContra
 	return modules.RenterContract{}, false
 }
 
+// ContractFormationReport describes the most recent attempt to form
+// contracts.
+func (c *Contractor) ContractFormationReport() modules.ContractFormationReport {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.formationReport
+}
+
 // Contracts returns the contracts formed by the contractor in the current
 // allowance period. Only contracts formed with currently online hosts are
 // returned.
@@ -118,6 +134,15 @@ func (c *Contractor) CurrentPeriod() types.BlockHeight {
 	return c.currentPeriod
 }
 
+// PauseFormation prevents the contractor from forming new contracts until the
+// provided time. Contracts that enter the renew window are still renewed.
+// Passing the zero time lifts the pause.
+func (c *Contractor) PauseFormation(until time.Time) {
+	c.mu.Lock()
+	c.formationPausedUntil = until
+	c.mu.Unlock()
+}
+
 // ResolveID returns the ID of the most recent renewal of id.
 func (c *Contractor) ResolveID(id types.FileContractID) types.FileContractID {
 	if newID, ok := c.renewedIDs[id]; ok && newID != id {
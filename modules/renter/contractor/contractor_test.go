@@ -34,6 +34,7 @@ func (newStub) AllHosts() []modules.HostDBEntry
 func (newStub) ActiveHosts() []modules.HostDBEntry                              { return nil }
 func (newStub) Host(types.SiaPublicKey) (settings modules.HostDBEntry, ok bool) { return }
 func (newStub) RandomHosts(int, []types.SiaPublicKey) []modules.HostDBEntry     { return nil }
+func (newStub) ScanAllHosts()                                                   {}
 
 // TestNew tests the New function.
 func TestNew(t *testing.T) {
@@ -186,6 +187,7 @@ func (stubHostDB) ActiveHosts() (hs []modules.HostDBEntry)
 func (stubHostDB) Host(types.SiaPublicKey) (h modules.HostDBEntry, ok bool)         { return }
 func (stubHostDB) PublicKey() (spk types.SiaPublicKey)                              { return }
 func (stubHostDB) RandomHosts(int, []types.SiaPublicKey) (hs []modules.HostDBEntry) { return }
+func (stubHostDB) ScanAllHosts()                                                    {}
 
 // TestIntegrationSetAllowance tests the SetAllowance method.
 func TestIntegrationSetAllowance(t *testing.T) {
@@ -264,8 +266,23 @@ func TestIntegrationSetAllowance(t *testing.T) {
 
 	m.AddBlock()
 
-	// set allowance with Hosts = 2; should only form one new contract
+	// set allowance with Hosts = 2 while formation is paused; should set the
+	// allowance without forming a new contract
 	a.Hosts = 2
+	c.PauseFormation(time.Now().Add(time.Hour))
+	err = c.SetAllowance(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.contracts) != 1 {
+		t.Fatal("expected 1 contract while formation is paused, got", len(c.contracts))
+	}
+	if c.Allowance().Hosts != 2 {
+		t.Fatal("allowance was not set while formation is paused")
+	}
+	c.PauseFormation(time.Time{})
+
+	// set allowance with Hosts = 2; should only form one new contract
 	err = c.SetAllowance(a)
 	if err != nil {
 		t.Fatal(err)
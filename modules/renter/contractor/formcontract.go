@@ -24,6 +24,8 @@ var (
 	// than the amount necessary to store at least one sector
 	ErrInsufficientAllowance = errors.New("allowance is not large enough to cover fees of contract creation")
 	errTooExpensive          = errors.New("host price was too high")
+	errNoAllowance           = errors.New("an allowance must be set before forming contracts")
+	errUnknownHost           = errors.New("host is not in the hostdb")
 )
 
 // maxSectors is the estimated maximum number of sectors that the allowance
@@ -73,11 +75,30 @@ func maxSectors(a modules.Allowance, hdb hostDB, tp transactionPool) (uint64, er
 	return numSectors, nil
 }
 
+// storagePriceLimit returns the highest storage price that the allowance
+// permits. This is the default limit, unless the allowance approves a higher
+// one.
+func storagePriceLimit(a modules.Allowance) types.Currency {
+	if a.MaxStoragePrice.Cmp(maxStoragePrice) > 0 {
+		return a.MaxStoragePrice
+	}
+	return maxStoragePrice
+}
+
+// managedStoragePriceLimit returns the highest storage price that the current
+// allowance permits.
+func (c *Contractor) managedStoragePriceLimit() types.Currency {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return storagePriceLimit(c.allowance)
+}
+
 // managedNewContract negotiates an initial file contract with the specified
-// host, saves it, and returns it.
-func (c *Contractor) managedNewContract(host modules.HostDBEntry, numSectors uint64, endHeight types.BlockHeight) (modules.RenterContract, error) {
+// host, saves it, and returns it. Hosts that charge more than priceLimit for
+// storage are rejected.
+func (c *Contractor) managedNewContract(host modules.HostDBEntry, numSectors uint64, endHeight types.BlockHeight, priceLimit types.Currency) (modules.RenterContract, error) {
 	// reject hosts that are too expensive
-	if host.StoragePrice.Cmp(maxStoragePrice) > 0 {
+	if host.StoragePrice.Cmp(priceLimit) > 0 {
 		return modules.RenterContract{}, errTooExpensive
 	}
 	// cap host.MaxCollateral
@@ -117,17 +138,18 @@ func (c *Contractor) managedNewContract(host modules.HostDBEntry, numSectors uin
 }
 
 // managedFormContracts forms contracts with n hosts using the allowance
-// parameters.
-func (c *Contractor) managedFormContracts(n int, numSectors uint64, endHeight types.BlockHeight) ([]modules.RenterContract, error) {
+// parameters. Contracts are formed in rounds. If a round falls short because
+// hosts charge more than the storage price limit, the limit is doubled, up to
+// the limit approved by the allowance, and another round is attempted. If the
+// hostdb does not have enough hosts, it is asked to rescan every host it knows
+// about, so that hosts which have come back online can be used next time. The
+// outcome, including the constraint that prevented more contracts from being
+// formed, is recorded in the contractor's formation report.
+func (c *Contractor) managedFormContracts(n int, numSectors uint64, endHeight types.BlockHeight, a modules.Allowance) ([]modules.RenterContract, error) {
 	if n <= 0 {
 		return nil, nil
 	}
 
-	// Sample at least 10 hosts.
-	nRandomHosts := 2 * n
-	if nRandomHosts < 10 {
-		nRandomHosts = 10
-	}
 	// Don't select from hosts we've already formed contracts with
 	c.mu.RLock()
 	var exclude []types.SiaPublicKey
@@ -135,28 +157,90 @@ func (c *Contractor) managedFormContracts(n int, numSectors uint64, endHeight ty
 		exclude = append(exclude, contract.HostPublicKey)
 	}
 	c.mu.RUnlock()
-	hosts := c.hdb.RandomHosts(nRandomHosts, exclude)
-	if len(hosts) < n {
-		return nil, fmt.Errorf("not enough hosts in hostdb for contract formation, got %v but needed %v", len(hosts), n)
-	}
 
+	report := modules.ContractFormationReport{
+		Time:              time.Now(),
+		Wanted:            n,
+		StoragePriceLimit: maxStoragePrice,
+	}
+	limitBound := storagePriceLimit(a)
 	var contracts []modules.RenterContract
 	var errs []string
-	for _, h := range hosts {
-		contract, err := c.managedNewContract(h, numSectors, endHeight)
-		if err != nil {
-			errs = append(errs, fmt.Sprintf("\t%v: %v", h.NetAddress, err))
-			continue
+	for len(contracts) < n {
+		// Sample at least 10 hosts.
+		needed := n - len(contracts)
+		nRandomHosts := 2 * needed
+		if nRandomHosts < 10 {
+			nRandomHosts = 10
+		}
+		hosts := c.hdb.RandomHosts(nRandomHosts, exclude)
+		if len(hosts) < needed {
+			// There are not enough hosts to choose from. Ask the hostdb to
+			// rescan all of its hosts, as some may have come back online.
+			report.BindingConstraint = modules.ContractConstraintHosts
+			report.Rescanned = true
+			c.hdb.ScanAllHosts()
+			if len(contracts) == 0 {
+				errs = append(errs, fmt.Sprintf("\tnot enough hosts in hostdb for contract formation, got %v but needed %v", len(hosts), needed))
+				break
+			}
+		}
+
+		tooExpensive := 0
+		for _, h := range hosts {
+			report.HostsConsidered++
+			contract, err := c.managedNewContract(h, numSectors, endHeight, report.StoragePriceLimit)
+			if err == errTooExpensive {
+				// The host may be retried in a later round with a higher
+				// limit.
+				tooExpensive++
+				report.HostsTooExpensive++
+				errs = append(errs, fmt.Sprintf("\t%v: %v", h.NetAddress, err))
+				continue
+			}
+			exclude = append(exclude, h.PublicKey)
+			if err != nil {
+				report.HostsFailed++
+				errs = append(errs, fmt.Sprintf("\t%v: %v", h.NetAddress, err))
+				continue
+			}
+			contracts = append(contracts, contract)
+			if len(contracts) >= n {
+				break
+			}
+			if build.Release != "testing" {
+				// sleep for 1 minute to alleviate potential block propagation issues
+				time.Sleep(60 * time.Second)
+			}
 		}
-		contracts = append(contracts, contract)
-		if len(contracts) >= n {
+		if len(contracts) >= n || report.BindingConstraint == modules.ContractConstraintHosts {
 			break
 		}
-		if build.Release != "testing" {
-			// sleep for 1 minute to alleviate potential block propagation issues
-			time.Sleep(60 * time.Second)
+
+		// Decide which constraint kept this round short. If it was the
+		// storage price, relax the limit and try again.
+		if tooExpensive > 0 && report.StoragePriceLimit.Cmp(limitBound) < 0 {
+			report.StoragePriceLimit = report.StoragePriceLimit.Mul64(2)
+			if report.StoragePriceLimit.Cmp(limitBound) > 0 {
+				report.StoragePriceLimit = limitBound
+			}
+			c.log.Printf("INFO: %v hosts were too expensive, raising the storage price limit to %v", tooExpensive, report.StoragePriceLimit)
+			continue
+		} else if tooExpensive > 0 {
+			report.BindingConstraint = modules.ContractConstraintStoragePrice
+		} else {
+			report.BindingConstraint = modules.ContractConstraintNegotiation
 		}
+		break
 	}
+	if len(contracts) >= n {
+		report.BindingConstraint = ""
+	}
+	report.Formed = len(contracts)
+	c.mu.Lock()
+	c.formationReport = report
+	c.mu.Unlock()
+
 	// If we couldn't form any contracts, return an error. Otherwise, just log
 	// the failures.
 	//
@@ -164,10 +248,78 @@ func (c *Contractor) managedFormContracts(n int, numSectors uint64, endHeight ty
 	// all-or-nothing approach? We can't pick new hosts to negotiate with
 	// because they'll probably be more expensive than we can afford.
 	if len(contracts) == 0 {
-		return nil, errors.New("could not form any contracts:\n" + strings.Join(errs, "\n"))
+		return nil, fmt.Errorf("could not form any contracts (binding constraint: %v):\n%v", report.BindingConstraint, strings.Join(errs, "\n"))
 	} else if len(contracts) < n {
-		c.log.Printf("WARN: failed to form desired number of contracts (wanted %v, got %v):\n%v", n, len(contracts), strings.Join(errs, "\n"))
+		c.log.Printf("WARN: failed to form desired number of contracts (wanted %v, got %v, binding constraint: %v):\n%v", n, len(contracts), report.BindingConstraint, strings.Join(errs, "\n"))
 	}
 
 	return contracts, nil
 }
+
+// FormContracts forms contracts with the specified hosts, sized according to
+// the current allowance and ending with the existing contracts. Hosts that the
+// contractor already has a contract with are skipped. The contracts are formed
+// in addition to the contracts of the allowance, and are renewed along with
+// them. The contracts that were formed are returned, along with an error
+// describing any hosts that a contract could not be formed with.
+func (c *Contractor) FormContracts(hosts []types.SiaPublicKey) ([]modules.RenterContract, error) {
+	c.mu.RLock()
+	a := c.allowance
+	endHeight := c.blockHeight + a.Period
+	if len(c.contracts) > 0 {
+		endHeight = c.contractEndHeight()
+	}
+	existing := make(map[string]struct{})
+	for _, contract := range c.contracts {
+		existing[contract.HostPublicKey.String()] = struct{}{}
+	}
+	c.mu.RUnlock()
+	if a.Hosts == 0 {
+		return nil, errNoAllowance
+	}
+
+	// Only allocate half as many sectors as the max, as SetAllowance does.
+	max, err := maxSectors(a, c.hdb, c.tpool)
+	if err != nil {
+		return nil, err
+	}
+	numSectors := max / 2
+	if numSectors == 0 {
+		return nil, ErrInsufficientAllowance
+	}
+	priceLimit := c.managedStoragePriceLimit()
+
+	var formed []modules.RenterContract
+	var errs []string
+	for _, pk := range hosts {
+		if _, exists := existing[pk.String()]; exists {
+			continue
+		}
+		host, exists := c.hdb.Host(pk)
+		if !exists {
+			errs = append(errs, fmt.Sprintf("\t%v: %v", pk.String(), errUnknownHost))
+			continue
+		}
+		contract, err := c.managedNewContract(host, numSectors, endHeight, priceLimit)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("\t%v: %v", host.NetAddress, err))
+			continue
+		}
+		existing[pk.String()] = struct{}{}
+		formed = append(formed, contract)
+	}
+
+	c.mu.Lock()
+	for _, contract := range formed {
+		c.contracts[contract.ID] = contract
+	}
+	err = c.saveSync()
+	c.mu.Unlock()
+	if err != nil {
+		return formed, err
+	}
+	if len(errs) > 0 {
+		return formed, fmt.Errorf("could not form contracts with %v hosts:\n%v", len(errs), strings.Join(errs, "\n"))
+	}
+	return formed, nil
+}
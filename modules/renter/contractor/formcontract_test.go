@@ -0,0 +1,127 @@
+package contractor
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// formationHostDB is a hostDB that always returns the same hosts, and records
+// whether a rescan was requested.
+type formationHostDB struct {
+	stubHostDB
+	hosts     []modules.HostDBEntry
+	rescanned bool
+}
+
+func (hdb *formationHostDB) RandomHosts(n int, exclude []types.SiaPublicKey) []modules.HostDBEntry {
+	excluded := make(map[string]bool)
+	for _, pk := range exclude {
+		excluded[string(pk.Key)] = true
+	}
+	var hosts []modules.HostDBEntry
+	for _, h := range hdb.hosts {
+		if !excluded[string(h.PublicKey.Key)] && len(hosts) < n {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+func (hdb *formationHostDB) ScanAllHosts() { hdb.rescanned = true }
+
+// failWallet is a wallet that cannot provide addresses, causing every
+// contract negotiation to fail.
+type failWallet struct{ newStub }
+
+func (failWallet) NextAddress() (types.UnlockConditions, error) {
+	return types.UnlockConditions{}, errors.New("no addresses")
+}
+
+// TestFormContractsConstraints tests that managedFormContracts relaxes the
+// storage price limit and reports the constraint that prevented contracts from
+// being formed.
+func TestFormContractsConstraints(t *testing.T) {
+	expensiveHosts := func(n int) []modules.HostDBEntry {
+		hosts := make([]modules.HostDBEntry, n)
+		for i := range hosts {
+			hosts[i].PublicKey = types.SiaPublicKey{Key: []byte{byte(i)}}
+			hosts[i].StoragePrice = maxStoragePrice.Mul64(3)
+		}
+		return hosts
+	}
+	newContractor := func(hdb hostDB) *Contractor {
+		return &Contractor{
+			hdb:    hdb,
+			wallet: &walletBridge{w: failWallet{}},
+			log:    persist.NewLogger(ioutil.Discard),
+		}
+	}
+
+	// With no maximum storage price, the default limit should not be
+	// relaxed.
+	hdb := &formationHostDB{hosts: expensiveHosts(10)}
+	c := newContractor(hdb)
+	if _, err := c.managedFormContracts(2, 1, 100, modules.Allowance{}); err == nil {
+		t.Fatal("expected formation to fail")
+	}
+	r := c.ContractFormationReport()
+	if r.BindingConstraint != modules.ContractConstraintStoragePrice {
+		t.Fatal("expected storage price to be the binding constraint, got", r.BindingConstraint)
+	} else if !r.StoragePriceLimit.Equals(maxStoragePrice) {
+		t.Fatal("storage price limit should not have been relaxed:", r.StoragePriceLimit)
+	} else if r.HostsTooExpensive != 10 || r.Wanted != 2 || r.Formed != 0 {
+		t.Fatal("report has wrong counts:", r)
+	} else if hdb.rescanned {
+		t.Fatal("hostdb should not have been rescanned")
+	}
+
+	// With a higher maximum, the limit should be relaxed until the hosts are
+	// affordable, at which point negotiation becomes the constraint.
+	hdb = &formationHostDB{hosts: expensiveHosts(10)}
+	c = newContractor(hdb)
+	a := modules.Allowance{MaxStoragePrice: maxStoragePrice.Mul64(10)}
+	if _, err := c.managedFormContracts(2, 1, 100, a); err == nil {
+		t.Fatal("expected formation to fail")
+	}
+	r = c.ContractFormationReport()
+	if r.BindingConstraint != modules.ContractConstraintNegotiation {
+		t.Fatal("expected negotiation to be the binding constraint, got", r.BindingConstraint)
+	} else if !r.StoragePriceLimit.Equals(maxStoragePrice.Mul64(4)) {
+		t.Fatal("storage price limit was not relaxed correctly:", r.StoragePriceLimit)
+	} else if r.HostsTooExpensive != 20 || r.HostsFailed != 10 {
+		t.Fatal("report has wrong counts:", r)
+	}
+
+	// With too few hosts, the hostdb should be rescanned.
+	hdb = &formationHostDB{hosts: expensiveHosts(1)}
+	c = newContractor(hdb)
+	if _, err := c.managedFormContracts(2, 1, 100, a); err == nil {
+		t.Fatal("expected formation to fail")
+	}
+	r = c.ContractFormationReport()
+	if r.BindingConstraint != modules.ContractConstraintHosts {
+		t.Fatal("expected hosts to be the binding constraint, got", r.BindingConstraint)
+	} else if !r.Rescanned || !hdb.rescanned {
+		t.Fatal("hostdb was not rescanned")
+	}
+}
+
+// TestFormContractsNoAllowance checks that FormContracts refuses to form
+// contracts before an allowance has been set.
+func TestFormContractsNoAllowance(t *testing.T) {
+	c := &Contractor{
+		hdb:       &formationHostDB{},
+		contracts: make(map[types.FileContractID]modules.RenterContract),
+		log:       persist.NewLogger(ioutil.Discard),
+	}
+	formed, err := c.FormContracts([]types.SiaPublicKey{{Key: []byte{1}}})
+	if err != errNoAllowance {
+		t.Fatal("expected errNoAllowance, got", err)
+	} else if len(formed) != 0 {
+		t.Fatal("no contracts should have been formed:", formed)
+	}
+}
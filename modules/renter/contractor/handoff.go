@@ -0,0 +1,107 @@
+package contractor
+
+import (
+	"errors"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/renter/proto"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	errHandoffSameContract = errors.New("cannot hand off a contract to itself")
+	errHandoffNoSectors    = errors.New("contract has no sectors to hand off")
+)
+
+// attach negotiates revisions that add sectors that were handed off to the
+// host to the contract.
+func (he *hostEditor) attach(roots []crypto.Hash) error {
+	he.mu.Lock()
+	defer he.mu.Unlock()
+	if he.invalid {
+		return errInvalidEditor
+	}
+
+	// The revisions add many sectors at once, which is not supported by the
+	// journal update used for uploads.
+	he.editor.SaveFn = he.contractor.saveAttachRevision(he.contract.ID, len(he.contract.MerkleRoots))
+	contract, err := he.editor.Attach(roots)
+	he.editor.SaveFn = he.contractor.saveUploadRevision(he.contract.ID)
+	if err != nil {
+		return err
+	}
+
+	he.contractor.mu.Lock()
+	he.contractor.contracts[contract.ID] = contract
+	he.contractor.saveSync()
+	he.contractor.mu.Unlock()
+	he.contract = contract
+
+	return nil
+}
+
+// Handoff asks the host of the contract with the given id, which is retiring,
+// to hand off every sector of the contract to the host of the replacement
+// contract. Once the retiring host has transferred the sectors and returned
+// a signed record of the handoff, the sectors are attached to the
+// replacement contract without being uploaded again. The record is returned.
+func (c *Contractor) Handoff(id, replacementID types.FileContractID) (modules.HandoffRecord, error) {
+	if err := c.tg.Add(); err != nil {
+		return modules.HandoffRecord{}, err
+	}
+	defer c.tg.Done()
+
+	c.mu.RLock()
+	id = c.ResolveID(id)
+	replacementID = c.ResolveID(replacementID)
+	contract, haveContract := c.contracts[id]
+	replacement, haveReplacement := c.contracts[replacementID]
+	height := c.blockHeight
+	c.mu.RUnlock()
+	if !haveContract || !haveReplacement {
+		return modules.HandoffRecord{}, errors.New("no record of that contract")
+	} else if id == replacementID {
+		return modules.HandoffRecord{}, errHandoffSameContract
+	} else if len(contract.MerkleRoots) == 0 {
+		return modules.HandoffRecord{}, errHandoffNoSectors
+	} else if height > replacement.EndHeight() {
+		return modules.HandoffRecord{}, errors.New("replacement contract has already ended")
+	}
+
+	// Use the most recent addresses of both hosts.
+	if host, ok := c.hdb.Host(contract.HostPublicKey); ok {
+		contract.NetAddress = host.NetAddress
+	}
+	host, ok := c.hdb.Host(replacement.HostPublicKey)
+	if !ok {
+		return modules.HandoffRecord{}, errors.New("no record of the replacement host")
+	}
+
+	// Authorize the handoff and wait for the retiring host to transfer the
+	// sectors.
+	ha := proto.SignHandoff(modules.HandoffAuthorization{
+		ContractID:            contract.ID,
+		ReplacementContractID: replacement.ID,
+		ReplacementHost:       host.NetAddress,
+		ReplacementHostKey:    replacement.HostPublicKey,
+		SectorRoots:           append(modules.MerkleRootSet(nil), contract.MerkleRoots...),
+		Expiration:            height + handoffDuration,
+	}, contract, replacement)
+	record, err := proto.Handoff(contract, ha, c.tg.StopChan())
+	if err != nil {
+		return modules.HandoffRecord{}, err
+	}
+	c.log.Printf("%v handed off %v sectors of contract %v to %v", contract.NetAddress, len(ha.SectorRoots), contract.ID, host.NetAddress)
+
+	// Attach the sectors to the replacement contract.
+	e, err := c.Editor(replacement.ID, c.tg.StopChan())
+	if err != nil {
+		return modules.HandoffRecord{}, err
+	}
+	defer e.Close()
+	if err := e.(*hostEditor).attach(ha.SectorRoots); err != nil {
+		return modules.HandoffRecord{}, err
+	}
+	return record, nil
+}
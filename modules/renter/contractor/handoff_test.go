@@ -0,0 +1,133 @@
+package contractor
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/fastrand"
+)
+
+// TestIntegrationHandoff tests that a host can hand off the sectors of a
+// contract to another host, and that the contractor attaches them to its
+// contract with the replacement host.
+func TestIntegrationHandoff(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	h, c, m, err := newTestingTrio(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	defer c.Close()
+
+	// create and announce a replacement host
+	cs := c.cs.(modules.ConsensusSet)
+	tp := c.tpool.(modules.TransactionPool)
+	h2, err := newTestingHost(build.TempDir("contractor", t.Name(), "Host2"), cs, tp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h2.Close()
+	if err := h2.Announce(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50 && len(c.hdb.ActiveHosts()) < 2; i++ {
+		time.Sleep(time.Millisecond * 100)
+	}
+
+	// form a contract with each host
+	var contracts []modules.RenterContract
+	for _, host := range []modules.Host{h, h2} {
+		hostEntry, ok := c.hdb.Host(host.PublicKey())
+		if !ok {
+			t.Fatal("no entry for host in db")
+		}
+		contract, err := c.managedNewContract(hostEntry, 10, c.blockHeight+100, maxStoragePrice)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.mu.Lock()
+		c.contracts[contract.ID] = contract
+		c.mu.Unlock()
+		contracts = append(contracts, contract)
+	}
+
+	// upload two sectors to the retiring host
+	editor, err := c.Editor(contracts[0].ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data [][]byte
+	var roots []crypto.Hash
+	for i := 0; i < 2; i++ {
+		sector := fastrand.Bytes(int(modules.SectorSize))
+		root, err := editor.Upload(sector)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, sector)
+		roots = append(roots, root)
+	}
+	if err := editor.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a contract cannot be handed off to itself
+	if _, err := c.Handoff(contracts[0].ID, contracts[0].ID); err != errHandoffSameContract {
+		t.Fatal("expected errHandoffSameContract, got", err)
+	}
+
+	// hand off the sectors to the replacement host
+	record, err := c.Handoff(contracts[0].ID, contracts[1].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := record.VerifySignature(); err != nil {
+		t.Fatal(err)
+	}
+	if hostKey := h.PublicKey(); record.HostKey.String() != hostKey.String() {
+		t.Fatal("record was not signed by the retiring host")
+	}
+	c.mu.RLock()
+	replacement := c.contracts[contracts[1].ID]
+	c.mu.RUnlock()
+	if len(replacement.MerkleRoots) != len(roots) {
+		t.Fatalf("expected %v sectors in the replacement contract, got %v", len(roots), len(replacement.MerkleRoots))
+	}
+
+	// the sectors can be downloaded from the replacement host
+	downloader, err := c.Downloader(contracts[1].ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, root := range roots {
+		retrieved, err := downloader.Sector(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data[i], retrieved) {
+			t.Fatal("downloaded data does not match original")
+		}
+	}
+	downloader.Close()
+
+	// the sectors were unstaged when they were attached, so they cannot be
+	// attached again
+	e, err := c.Editor(contracts[1].ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+	if err := e.(*hostEditor).attach(roots[:1]); err == nil {
+		t.Fatal("expected attaching a sector twice to fail")
+	}
+}
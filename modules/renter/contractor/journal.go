@@ -260,6 +260,8 @@ func (set updateSet) MarshalJSON() ([]byte, error) {
 			marshaledSet[i].Type = "cachedUploadRevision"
 		case updateCachedDownloadRevision:
 			marshaledSet[i].Type = "cachedDownloadRevision"
+		case updateCachedAttachRevision:
+			marshaledSet[i].Type = "cachedAttachRevision"
 		}
 	}
 	return json.Marshal(marshaledSet)
@@ -294,6 +296,10 @@ func (set *updateSet) UnmarshalJSON(b []byte) error {
 			var cdr updateCachedDownloadRevision
 			err = json.Unmarshal(u.Data, &cdr)
 			*set = append(*set, cdr)
+		case "cachedAttachRevision":
+			var car updateCachedAttachRevision
+			err = json.Unmarshal(u.Data, &car)
+			*set = append(*set, car)
 		}
 		if err != nil {
 			return err
@@ -385,6 +391,26 @@ func (u updateCachedUploadRevision) apply(data *contractorPersist) {
 	data.CachedRevisions[u.Revision.ParentID.String()] = c
 }
 
+// updateCachedAttachRevision is a journalUpdate that records the unsigned
+// revision sent to the host when attaching sectors that were handed off to
+// it, along with the Merkle roots of the attached sectors.
+type updateCachedAttachRevision struct {
+	Revision    types.FileContractRevision `json:"revision"`
+	SectorRoots []crypto.Hash              `json:"sectorroots"`
+	SectorIndex int                        `json:"sectorindex"`
+}
+
+// apply sets the Revision field of the cachedRevision associated with the
+// contract being revised, and sets the Merkle roots of the attached sectors.
+func (u updateCachedAttachRevision) apply(data *contractorPersist) {
+	c := data.CachedRevisions[u.Revision.ParentID.String()]
+	c.Revision = u.Revision
+	if u.SectorIndex <= len(c.MerkleRoots) {
+		c.MerkleRoots = append(c.MerkleRoots[:u.SectorIndex], u.SectorRoots...)
+	}
+	data.CachedRevisions[u.Revision.ParentID.String()] = c
+}
+
 // updateCachedDownloadRevision is a journalUpdate that records the unsigned
 // revision sent to the host during a sector download.
 type updateCachedDownloadRevision struct {
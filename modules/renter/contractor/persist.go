@@ -137,6 +137,22 @@ func (c *Contractor) saveUploadRevision(id types.FileContractID) func(types.File
 	}
 }
 
+// saveAttachRevision returns a function that saves a revision that attaches
+// handed off sectors. It is used by the Editor type to prevent
+// desynchronizing with the host.
+func (c *Contractor) saveAttachRevision(id types.FileContractID, index int) func(types.FileContractRevision, []crypto.Hash) error {
+	return func(rev types.FileContractRevision, newRoots []crypto.Hash) error {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.cachedRevisions[id] = cachedRevision{rev, newRoots}
+		return c.persist.update(updateCachedAttachRevision{
+			Revision:    rev,
+			SectorRoots: newRoots[index:],
+			SectorIndex: index,
+		})
+	}
+}
+
 // saveDownloadRevision returns a function that saves an upload revision. It
 // is used by the Downloader type to prevent desynchronizing with the host.
 func (c *Contractor) saveDownloadRevision(id types.FileContractID) func(types.FileContractRevision, []crypto.Hash) error {
@@ -17,7 +17,7 @@ func (c *Contractor) managedRenew(contract modules.RenterContract, numSectors ui
 	host, ok := c.hdb.Host(contract.HostPublicKey)
 	if !ok {
 		return modules.RenterContract{}, errors.New("no record of that host")
-	} else if host.StoragePrice.Cmp(maxStoragePrice) > 0 {
+	} else if host.StoragePrice.Cmp(c.managedStoragePriceLimit()) > 0 {
 		return modules.RenterContract{}, errTooExpensive
 	}
 	// cap host.MaxCollateral
@@ -1,6 +1,8 @@
 package contractor
 
 import (
+	"time"
+
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 )
@@ -82,12 +84,14 @@ func (c *Contractor) ProcessConsensusChange(cc modules.ConsensusChange) {
 				c.log.Debugln("WARN: failed to renew contracts after processing a consensus chage:", err)
 			}
 
-			// If we don't have enough (online) contracts, form new ones.
+			// If we don't have enough (online) contracts, form new ones. No
+			// new contracts are formed while formation is paused.
 			c.mu.RLock()
 			a := c.allowance
 			remaining := int(a.Hosts) - len(c.onlineContracts())
+			paused := time.Now().Before(c.formationPausedUntil)
 			c.mu.RUnlock()
-			if remaining <= 0 {
+			if remaining <= 0 || paused {
 				return
 			}
 			max, err := maxSectors(a, c.hdb, c.tpool)
@@ -7,6 +7,7 @@ package renter
 import (
 	"bytes"
 	"errors"
+	"io"
 	"os"
 	"sync"
 	"sync/atomic"
@@ -72,10 +73,13 @@ type (
 		// Static information about the file - can be read without a lock.
 		chunkSize         uint64
 		destination       string
+		destinationWriter io.WriterAt
 		erasureCode       modules.ErasureCoder
 		fileSize          uint64
+		length            uint64 // number of bytes of the file to download
 		masterKey         crypto.TwofishKey
 		numChunks         uint64
+		offset            uint64 // offset within the file of the first byte to download
 		pieceSet          []map[types.FileContractID]pieceData
 		reportedPieceSize uint64
 		siapath           string
@@ -127,6 +131,7 @@ func (r *Renter) newDownload(f *file, destination string, currentContracts map[m
 		destination: destination,
 		erasureCode: f.erasureCode,
 		fileSize:    f.size,
+		length:      f.size,
 		masterKey:   f.masterKey,
 		numChunks:   f.numChunks(),
 		siapath:     f.name,
@@ -165,6 +170,28 @@ func (r *Renter) newDownload(f *file, destination string, currentContracts map[m
 	return d
 }
 
+// setRange limits the download to length bytes of the file, starting at
+// offset. Only the chunks that cover the range are downloaded, and the range is
+// written to the start of the destination. The range must be non-empty and
+// must lie within the file.
+func (d *download) setRange(offset, length uint64) {
+	d.offset = offset
+	d.length = length
+
+	// Mark the chunks outside of the range as finished.
+	firstChunk := offset / d.chunkSize
+	lastChunk := (offset + length - 1) / d.chunkSize
+	for i := range d.finishedChunks {
+		d.finishedChunks[i] = uint64(i) < firstChunk || uint64(i) > lastChunk
+	}
+
+	// Allocate the progress bar so that the download finishes at exactly
+	// the length of the range, as newDownload does for the whole file.
+	numChunks := lastChunk - firstChunk + 1
+	d.reportedPieceSize = length / (numChunks * uint64(d.erasureCode.MinPieces()))
+	d.atomicDataReceived = length - (d.reportedPieceSize * numChunks * uint64(d.erasureCode.MinPieces()))
+}
+
 // Err returns the error encountered by a download, if it exists.
 func (d *download) Err() error {
 	d.mu.Lock()
@@ -185,6 +212,49 @@ func (d *download) fail(err error) {
 	close(d.downloadFinished)
 }
 
+// writeChunk writes a recovered chunk to the destination of the download. If
+// the download has a destination writer, the chunk is written there instead of
+// to the destination file. Only the part of the chunk within the range of the
+// download is written.
+func (d *download) writeChunk(data []byte, index uint64) error {
+	start := index * d.chunkSize
+	if start < d.offset {
+		data = data[d.offset-start:]
+		start = d.offset
+	}
+	if end := start + uint64(len(data)); end > d.offset+d.length {
+		data = data[:d.offset+d.length-start]
+	}
+	offset := int64(start - d.offset)
+	if d.destinationWriter != nil {
+		_, err := d.destinationWriter.WriteAt(data, offset)
+		if err != nil {
+			return build.ExtendErr("unable to write to download destination", err)
+		}
+		return nil
+	}
+
+	// Open a file handle for the download.
+	fileDest, err := os.OpenFile(d.destination, os.O_CREATE|os.O_WRONLY, defaultFilePerm)
+	if err != nil {
+		return build.ExtendErr("unable to open download destination", err)
+	}
+	defer fileDest.Close()
+
+	// Write the bytes to the download file.
+	_, err = fileDest.WriteAt(data, offset)
+	if err != nil {
+		return build.ExtendErr("unable to write to download destination", err)
+	}
+
+	// Sync the write to provide proper durability.
+	err = fileDest.Sync()
+	if err != nil {
+		return build.ExtendErr("unable to sync downlaod destination", err)
+	}
+	return nil
+}
+
 // recoverChunk takes a chunk that has had a sufficient number of pieces
 // downloaded and verifies, decrypts and decodes them into the file.
 func (cd *chunkDownload) recoverChunk() error {
@@ -230,24 +300,10 @@ func (cd *chunkDownload) recoverChunk() error {
 		return build.ExtendErr("unable to recover chunk", err)
 	}
 
-	// Open a file handle for the download.
-	fileDest, err := os.OpenFile(cd.download.destination, os.O_CREATE|os.O_WRONLY, defaultFilePerm)
-	if err != nil {
-		return build.ExtendErr("unable to open download destination", err)
-	}
-	defer fileDest.Close()
-
-	// Write the bytes to the download file.
-	result := recoverWriter.Bytes()
-	_, err = fileDest.WriteAt(result, int64(cd.index*cd.download.chunkSize))
-	if err != nil {
-		return build.ExtendErr("unable to write to download destination", err)
-	}
-
-	// Sync the write to provide proper durability.
-	err = fileDest.Sync()
+	// Write the bytes to the download destination.
+	err = cd.download.writeChunk(recoverWriter.Bytes(), cd.index)
 	if err != nil {
-		return build.ExtendErr("unable to sync downlaod destination", err)
+		return err
 	}
 
 	cd.download.mu.Lock()
@@ -312,6 +368,23 @@ func (r *Renter) addDownloadToChunkQueue(d *download) {
 
 // downloadIteration performs one iteration of the download loop.
 func (r *Renter) managedDownloadIteration(ds *downloadState) {
+	// While the renter is paused, only collect the pieces that are already
+	// being downloaded. Once those have returned, block until the renter
+	// resumes, continuing to accept newly queued downloads.
+	if pauseChan := r.managedPauseChan(); pauseChan != nil {
+		if len(ds.activeWorkers) > 0 {
+			r.managedWaitOnDownloadWork(ds)
+			return
+		}
+		select {
+		case <-r.tg.StopChan():
+		case <-pauseChan:
+		case d := <-r.newDownloads:
+			r.addDownloadToChunkQueue(d)
+		}
+		return
+	}
+
 	// Check for sleep and break conditions.
 	if len(ds.incompleteChunks) == 0 && len(ds.activeWorkers) == 0 && len(r.chunkQueue) == 0 {
 		// If the above conditions are true, it should also be the case that
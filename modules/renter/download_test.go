@@ -0,0 +1,62 @@
+package renter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/NebulousLabs/fastrand"
+)
+
+// TestDownloadRange checks that setRange only leaves the chunks covering the
+// range to be downloaded, and that writeChunk writes only the bytes within the
+// range to the start of the destination.
+func TestDownloadRange(t *testing.T) {
+	rsc, _ := NewRSCode(2, 1)
+	fileData := fastrand.Bytes(35)
+	buf := new(chunkBuffer)
+	d := &download{
+		chunkSize:         10,
+		destinationWriter: buf,
+		erasureCode:       rsc,
+		fileSize:          uint64(len(fileData)),
+		finishedChunks:    make([]bool, 4),
+		numChunks:         4,
+	}
+	d.setRange(15, 10)
+
+	// Only the second and third chunks cover the range.
+	for i, finished := range d.finishedChunks {
+		if finished != (i != 1 && i != 2) {
+			t.Fatalf("chunk %v has the wrong finished state: %v", i, finished)
+		}
+	}
+	// The progress of the download should end at exactly the length of the
+	// range.
+	if total := d.atomicDataReceived + d.reportedPieceSize*2*uint64(rsc.MinPieces()); total != 10 {
+		t.Fatal("download progress does not add up to the length of the range:", total)
+	}
+
+	for _, index := range []uint64{1, 2} {
+		err := d.writeChunk(fileData[index*10:(index+1)*10], index)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !bytes.Equal(buf.data, fileData[15:25]) {
+		t.Fatal("wrong data written to the destination:", buf.data, fileData[15:25])
+	}
+
+	// A range within the last, partial chunk is trimmed to the file.
+	buf = new(chunkBuffer)
+	d.destinationWriter = buf
+	d.setRange(32, 3)
+	if !d.finishedChunks[0] || !d.finishedChunks[1] || !d.finishedChunks[2] || d.finishedChunks[3] {
+		t.Fatal("wrong chunks left to download:", d.finishedChunks)
+	}
+	if err := d.writeChunk(fileData[30:], 3); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.data, fileData[32:]) {
+		t.Fatal("wrong data written to the destination:", buf.data, fileData[32:])
+	}
+}
@@ -2,12 +2,28 @@ package renter
 
 import (
 	"errors"
+	"fmt"
 	"sync/atomic"
 
+	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 )
 
+// errInvalidRange is returned by DownloadRange if the requested range is empty
+// or extends past the end of the file.
+var errInvalidRange = errors.New("requested range is empty or outside of the file")
+
+// currentContracts maps the address of each host the renter has a contract
+// with to the ID of the contract.
+func (r *Renter) currentContracts() map[modules.NetAddress]types.FileContractID {
+	currentContracts := make(map[modules.NetAddress]types.FileContractID)
+	for _, contract := range r.hostContractor.Contracts() {
+		currentContracts[contract.NetAddress] = contract.ID
+	}
+	return currentContracts
+}
+
 // Download downloads a file, identified by its path, to the destination
 // specified.
 func (r *Renter) Download(path, destination string) error {
@@ -19,15 +35,37 @@ func (r *Renter) Download(path, destination string) error {
 		return errors.New("no file with that path")
 	}
 
-	// Build current contracts map.
-	currentContracts := make(map[modules.NetAddress]types.FileContractID)
-	for _, contract := range r.hostContractor.Contracts() {
-		currentContracts[contract.NetAddress] = contract.ID
+	// Create the download object and add it to the queue.
+	d := r.newDownload(file, destination, r.currentContracts())
+	return r.managedQueueDownload(d)
+}
+
+// DownloadRange downloads length bytes of a file, starting at offset, to the
+// destination specified. Only the chunks covering the range are fetched from
+// hosts.
+func (r *Renter) DownloadRange(path, destination string, offset, length uint64) error {
+	// Lookup the file associated with the nickname.
+	lockID := r.mu.RLock()
+	file, exists := r.files[path]
+	r.mu.RUnlock(lockID)
+	if !exists {
+		return errors.New("no file with that path")
 	}
 
-	// Create the download object and add it to the queue.
-	d := r.newDownload(file, destination, currentContracts)
-	lockID = r.mu.Lock()
+	// Create the download object, limit it to the range, and add it to the
+	// queue.
+	d := r.newDownload(file, destination, r.currentContracts())
+	if length == 0 || offset+length < offset || offset+length > d.fileSize {
+		return build.ExtendErr(fmt.Sprintf("cannot download %v bytes at offset %v of a %v byte file", length, offset, d.fileSize), errInvalidRange)
+	}
+	d.setRange(offset, length)
+	return r.managedQueueDownload(d)
+}
+
+// managedQueueDownload adds a download to the download queue and blocks until
+// it has completed.
+func (r *Renter) managedQueueDownload(d *download) error {
+	lockID := r.mu.Lock()
 	r.downloadQueue = append(r.downloadQueue, d)
 	r.mu.Unlock(lockID)
 	r.newDownloads <- d
@@ -57,6 +95,8 @@ func (r *Renter) DownloadQueue() []modules.DownloadInfo {
 			SiaPath:     d.siapath,
 			Destination: d.destination,
 			Filesize:    d.fileSize,
+			Offset:      d.offset,
+			Length:      d.length,
 			StartTime:   d.startTime,
 		}
 		downloads[i].Received = atomic.LoadUint64(&d.atomicDataReceived)
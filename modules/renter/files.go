@@ -35,6 +35,13 @@ type file struct {
 	mu sync.RWMutex
 }
 
+// A pendingRename records a rename of a file from one nickname to another that
+// has not yet been completed.
+type pendingRename struct {
+	From string
+	To   string
+}
+
 // A fileContract is a contract covering an arbitrary number of file pieces.
 // Chunk/Piece metadata is used to split the raw contract data appropriately.
 type fileContract struct {
@@ -180,6 +187,10 @@ func (r *Renter) DeleteFile(nickname string) error {
 	delete(r.files, nickname)
 	os.RemoveAll(filepath.Join(r.persistDir, f.name+ShareExtension))
 	r.saveSync()
+	if _, exists := r.receipts[nickname]; exists {
+		delete(r.receipts, nickname)
+		r.saveReceipts()
+	}
 	r.mu.Unlock(lockID)
 
 	// delete the file's associated contract data.
@@ -208,6 +219,8 @@ func (r *Renter) FileList() []modules.FileInfo {
 			Renewing:       renewing,
 			UploadProgress: f.uploadProgress(),
 			Expiration:     f.expiration(),
+			DataPieces:     f.erasureCode.MinPieces(),
+			ParityPieces:   f.erasureCode.NumPieces() - f.erasureCode.MinPieces(),
 		})
 		f.mu.RUnlock()
 	}
@@ -216,14 +229,15 @@ func (r *Renter) FileList() []modules.FileInfo {
 
 // RenameFile takes an existing file and changes the nickname. The original
 // file must exist, and there must not be any file that already has the
-// replacement nickname.
+// replacement nickname. The rename is recorded before the renamed .sia file is
+// written, so that it can be completed if the renter is interrupted.
 func (r *Renter) RenameFile(currentName, newName string) error {
 	lockID := r.mu.Lock()
 	defer r.mu.Unlock(lockID)
 
-	// Check that newName is nonempty.
-	if newName == "" {
-		return ErrEmptyFilename
+	// Check that newName is a legal nickname.
+	if err := validateSiapath(newName); err != nil {
+		return err
 	}
 
 	// Check that currentName exists and newName doesn't.
@@ -236,28 +250,78 @@ func (r *Renter) RenameFile(currentName, newName string) error {
 		return ErrPathOverload
 	}
 
+	// Record the rename before touching any .sia files.
+	r.pendingRename = &pendingRename{From: currentName, To: newName}
+	err := r.saveSync()
+	if err != nil {
+		r.pendingRename = nil
+		return err
+	}
+
 	// Modify the file and save it to disk.
 	file.mu.Lock()
 	file.name = newName
-	err := r.saveFile(file)
+	err = r.saveFile(file)
+	if err != nil {
+		file.name = currentName
+	}
 	file.mu.Unlock()
 	if err != nil {
-		return err
+		r.pendingRename = nil
+		return build.ComposeErrors(err, r.saveSync())
 	}
 
-	// Update the entries in the renter.
-	delete(r.files, currentName)
-	r.files[newName] = file
+	// Update the entries in the renter, and delete the old .sia file.
+	err = r.finishRename(currentName, newName)
+	r.pendingRename = nil
+	return build.ComposeErrors(err, r.saveSync())
+}
+
+// finishRename moves the tracking entry and receipt of a renamed file to its
+// new nickname, and deletes the .sia file of the old nickname. The .sia file of
+// the new nickname must already have been saved.
+func (r *Renter) finishRename(currentName, newName string) error {
+	if file, exists := r.files[currentName]; exists {
+		// When an interrupted rename is completed, the old .sia file was
+		// loaded alongside the new one, and its entry is dropped instead.
+		delete(r.files, currentName)
+		if file.name == newName {
+			r.files[newName] = file
+		}
+	}
 	if t, ok := r.tracking[currentName]; ok {
 		delete(r.tracking, currentName)
 		r.tracking[newName] = t
 	}
-	err = r.saveSync()
-	if err != nil {
-		return err
+	// The receipt keeps the siapath that the file was uploaded to, as it is
+	// covered by the signature.
+	if receipt, ok := r.receipts[currentName]; ok {
+		delete(r.receipts, currentName)
+		r.receipts[newName] = receipt
+		if err := r.saveReceipts(); err != nil {
+			return err
+		}
 	}
 
 	// Delete the old .sia file.
 	oldPath := filepath.Join(r.persistDir, currentName+ShareExtension)
 	return os.RemoveAll(oldPath)
 }
+
+// completePendingRename finishes a rename that was interrupted. If the .sia
+// file of the new nickname was saved, the rename is completed, otherwise it is
+// dropped and the file keeps its old nickname.
+func (r *Renter) completePendingRename() error {
+	pr := r.pendingRename
+	if pr == nil {
+		return nil
+	}
+	r.pendingRename = nil
+	if _, ok := r.files[pr.To]; ok {
+		r.log.Printf("INFO: completing the interrupted rename of %v to %v", pr.From, pr.To)
+		if err := r.finishRename(pr.From, pr.To); err != nil {
+			return err
+		}
+	}
+	return r.saveSync()
+}
@@ -276,6 +276,9 @@ func TestRenterFileList(t *testing.T) {
 	if rt.renter.FileList()[0].SiaPath != "one" {
 		t.Error("FileList is not returning the correct filename for the only file")
 	}
+	if fi := rt.renter.FileList()[0]; fi.DataPieces != 1 || fi.ParityPieces != 1 {
+		t.Error("FileList is not returning the erasure coding parameters of the file:", fi.DataPieces, fi.ParityPieces)
+	}
 
 	// Put multiple files in the renter.
 	rt.renter.files["2"] = &file{
@@ -339,6 +342,12 @@ func TestRenterRenameFile(t *testing.T) {
 		t.Error("Expecting ErrPathOverload, got", err)
 	}
 
+	// Rename a file to an illegal name.
+	err = rt.renter.RenameFile("1", "../1c")
+	if err == nil {
+		t.Error("Expecting an error when renaming to an illegal name")
+	}
+
 	// Renaming should also update the tracking set
 	rt.renter.tracking["1"] = trackedFile{"foo"}
 	err = rt.renter.RenameFile("1", "1b")
@@ -351,3 +360,90 @@ func TestRenterRenameFile(t *testing.T) {
 		t.Error("renaming should have updated the entry in the tracking set")
 	}
 }
+
+// TestRenterRenameFileInterrupted checks that a rename interrupted after the
+// new .sia file was saved is completed when the renter is loaded, and that a
+// rename interrupted before is dropped.
+func TestRenterRenameFileInterrupted(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Save two files, one of which has its new .sia file saved as well.
+	moved := newTestingFile()
+	moved.name = "old"
+	kept := newTestingFile()
+	kept.name = "kept"
+	for _, f := range []*file{moved, kept} {
+		if err := rt.renter.saveFile(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	moved.name = "dir/new"
+	if err := rt.renter.saveFile(moved); err != nil {
+		t.Fatal(err)
+	}
+	rt.renter.tracking["old"] = trackedFile{"foo"}
+	rt.renter.pendingRename = &pendingRename{From: "old", To: "dir/new"}
+	if err := rt.renter.saveSync(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reload the renter, which should complete the rename.
+	rt.renter.files = make(map[string]*file)
+	rt.renter.tracking = make(map[string]trackedFile)
+	id := rt.renter.mu.Lock()
+	err = rt.renter.load()
+	if err == nil {
+		err = rt.renter.completePendingRename()
+	}
+	rt.renter.mu.Unlock(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := rt.renter.files["old"]; exists {
+		t.Error("old nickname was not removed")
+	}
+	if _, exists := rt.renter.files["dir/new"]; !exists {
+		t.Error("new nickname was not loaded")
+	}
+	if _, exists := rt.renter.tracking["dir/new"]; !exists {
+		t.Error("tracking entry was not moved")
+	}
+	if _, err := os.Stat(filepath.Join(rt.renter.persistDir, "old"+ShareExtension)); !os.IsNotExist(err) {
+		t.Error("old .sia file was not removed:", err)
+	}
+	if rt.renter.pendingRename != nil {
+		t.Error("pending rename was not cleared")
+	}
+
+	// A rename whose new .sia file was never saved is dropped.
+	rt.renter.pendingRename = &pendingRename{From: "kept", To: "lost"}
+	if err := rt.renter.saveSync(); err != nil {
+		t.Fatal(err)
+	}
+	rt.renter.files = make(map[string]*file)
+	id = rt.renter.mu.Lock()
+	err = rt.renter.load()
+	if err == nil {
+		err = rt.renter.completePendingRename()
+	}
+	rt.renter.mu.Unlock(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := rt.renter.files["kept"]; !exists {
+		t.Error("file of a dropped rename was removed")
+	}
+	if _, exists := rt.renter.files["lost"]; exists {
+		t.Error("dropped rename was applied")
+	}
+	if rt.renter.pendingRename != nil {
+		t.Error("pending rename was not cleared")
+	}
+}
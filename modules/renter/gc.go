@@ -0,0 +1,302 @@
+package renter
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// staleContracts is a sortable list of stale contracts, ordered by file, then
+// by contract ID.
+type staleContracts []modules.RenterStaleContract
+
+func (sc staleContracts) Len() int      { return len(sc) }
+func (sc staleContracts) Swap(i, j int) { sc[i], sc[j] = sc[j], sc[i] }
+func (sc staleContracts) Less(i, j int) bool {
+	if sc[i].SiaPath != sc[j].SiaPath {
+		return sc[i].SiaPath < sc[j].SiaPath
+	}
+	return string(sc[i].ID[:]) < string(sc[j].ID[:])
+}
+
+// gcState holds the information about the renter's contracts that the
+// garbage collector needs to decide whether a file contract is stale.
+type gcState struct {
+	height    types.BlockHeight
+	contracts map[types.FileContractID]struct{}
+	addresses map[modules.NetAddress]struct{}
+}
+
+// managedGCState returns the current block height and the renter's current
+// contracts. All contracts are included, not only those with online hosts, so
+// that a host that is briefly offline does not make its contracts look stale.
+// It must not be called while holding the renter's lock.
+func (r *Renter) managedGCState() gcState {
+	gs := gcState{
+		height:    r.cs.Height(),
+		contracts: make(map[types.FileContractID]struct{}),
+		addresses: make(map[modules.NetAddress]struct{}),
+	}
+	for _, c := range r.AllContracts() {
+		gs.contracts[c.ID] = struct{}{}
+		gs.addresses[c.NetAddress] = struct{}{}
+	}
+	return gs
+}
+
+// isStale reports whether a file contract has expired and its data can no
+// longer be reached through any current contract. A contract is reachable in
+// the same way that downloads reach it: either a current contract exists with
+// the same host, or the contract was renewed into a current contract.
+func (r *Renter) isStale(gs gcState, fc fileContract) bool {
+	if fc.WindowStart > gs.height {
+		return false
+	}
+	if _, ok := gs.addresses[fc.IP]; ok {
+		return false
+	}
+	_, ok := gs.contracts[r.hostContractor.ResolveID(fc.ID)]
+	return !ok
+}
+
+// orphanedPieces returns the number of piece records in the file contract
+// that refer to a chunk or piece index that the file does not have.
+func (f *file) orphanedPieces(fc fileContract) int {
+	n := 0
+	for _, p := range fc.Pieces {
+		if p.Chunk >= f.numChunks() || p.Piece >= uint64(f.erasureCode.NumPieces()) {
+			n++
+		}
+	}
+	return n
+}
+
+// liveRedundancy returns the redundancy of the file's least redundant chunk,
+// counting only the pieces held by contracts that are not stale.
+// Orphaned pieces are ignored.
+func (r *Renter) liveRedundancy(gs gcState, f *file) float64 {
+	piecesPerChunk := make([]int, f.numChunks())
+	for _, fc := range f.contracts {
+		if r.isStale(gs, fc) {
+			continue
+		}
+		for _, p := range fc.Pieces {
+			if p.Chunk < uint64(len(piecesPerChunk)) && p.Piece < uint64(f.erasureCode.NumPieces()) {
+				piecesPerChunk[p.Chunk]++
+			}
+		}
+	}
+	minPieces := piecesPerChunk[0]
+	for _, numPieces := range piecesPerChunk {
+		if numPieces < minPieces {
+			minPieces = numPieces
+		}
+	}
+	return float64(minPieces) / float64(f.erasureCode.MinPieces())
+}
+
+// repairable reports whether the renter can restore the file's redundancy
+// from a local copy.
+func (r *Renter) repairable(f *file) bool {
+	tf, ok := r.tracking[f.name]
+	if !ok {
+		return false
+	}
+	_, err := os.Stat(tf.RepairPath)
+	return err == nil
+}
+
+// garbage scans the renter's metadata for garbage. It must be called while
+// holding the renter's lock.
+func (r *Renter) garbage(gs gcState) modules.RenterGarbage {
+	var g modules.RenterGarbage
+	names := make([]string, 0, len(r.files))
+	for name := range r.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sharePaths := make(map[string]struct{})
+	for _, name := range names {
+		f := r.files[name]
+		f.mu.RLock()
+		sharePaths[filepath.Join(r.persistDir, f.name+ShareExtension)] = struct{}{}
+		// The stale contracts and orphaned pieces of unrecoverable files are
+		// not listed, as the entire file is removed.
+		if f.size > 0 && r.liveRedundancy(gs, f) < 1 && !r.repairable(f) {
+			g.UnrecoverableFiles = append(g.UnrecoverableFiles, f.name)
+			f.mu.RUnlock()
+			continue
+		}
+		orphaned := 0
+		for _, fc := range f.contracts {
+			orphaned += f.orphanedPieces(fc)
+			if r.isStale(gs, fc) {
+				g.StaleContracts = append(g.StaleContracts, modules.RenterStaleContract{
+					SiaPath:     f.name,
+					ID:          fc.ID,
+					NetAddress:  fc.IP,
+					WindowStart: fc.WindowStart,
+					Pieces:      len(fc.Pieces),
+				})
+			}
+		}
+		if orphaned > 0 {
+			g.OrphanedPieces = append(g.OrphanedPieces, modules.RenterOrphanedPieces{
+				SiaPath: f.name,
+				Pieces:  orphaned,
+			})
+		}
+		f.mu.RUnlock()
+	}
+
+	for name := range r.tracking {
+		if _, ok := r.files[name]; !ok {
+			g.OrphanedTracking = append(g.OrphanedTracking, name)
+		}
+	}
+	sort.Strings(g.OrphanedTracking)
+	sort.Sort(staleContracts(g.StaleContracts))
+
+	filepath.Walk(r.persistDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(path) != ShareExtension {
+			return nil
+		}
+		if _, ok := sharePaths[path]; !ok {
+			rel, _ := filepath.Rel(r.persistDir, path)
+			g.OrphanedShareFiles = append(g.OrphanedShareFiles, rel)
+		}
+		return nil
+	})
+	return g
+}
+
+// Garbage reports the renter metadata that references expired contracts,
+// unrecoverable files, and orphaned records. Nothing is removed; the report
+// is meant to be reviewed before calling CollectGarbage.
+func (r *Renter) Garbage() modules.RenterGarbage {
+	gs := r.managedGCState()
+	lockID := r.mu.RLock()
+	defer r.mu.RUnlock(lockID)
+	return r.garbage(gs)
+}
+
+// confirmedGarbage returns the entries of g that are also listed in
+// confirmed.
+func confirmedGarbage(g, confirmed modules.RenterGarbage) modules.RenterGarbage {
+	filter := func(current, confirmed []string) []string {
+		set := make(map[string]struct{})
+		for _, s := range confirmed {
+			set[s] = struct{}{}
+		}
+		var kept []string
+		for _, s := range current {
+			if _, ok := set[s]; ok {
+				kept = append(kept, s)
+			}
+		}
+		return kept
+	}
+
+	var c modules.RenterGarbage
+	stale := make(map[string]struct{})
+	for _, sc := range confirmed.StaleContracts {
+		stale[sc.SiaPath+"/"+sc.ID.String()] = struct{}{}
+	}
+	for _, sc := range g.StaleContracts {
+		if _, ok := stale[sc.SiaPath+"/"+sc.ID.String()]; ok {
+			c.StaleContracts = append(c.StaleContracts, sc)
+		}
+	}
+	orphaned := make(map[string]struct{})
+	for _, op := range confirmed.OrphanedPieces {
+		orphaned[op.SiaPath] = struct{}{}
+	}
+	for _, op := range g.OrphanedPieces {
+		if _, ok := orphaned[op.SiaPath]; ok {
+			c.OrphanedPieces = append(c.OrphanedPieces, op)
+		}
+	}
+	c.UnrecoverableFiles = filter(g.UnrecoverableFiles, confirmed.UnrecoverableFiles)
+	c.OrphanedShareFiles = filter(g.OrphanedShareFiles, confirmed.OrphanedShareFiles)
+	c.OrphanedTracking = filter(g.OrphanedTracking, confirmed.OrphanedTracking)
+	return c
+}
+
+// CollectGarbage removes the metadata listed in confirmed, which is normally
+// a report returned by Garbage that the user has reviewed. Stale contracts and
+// orphaned pieces are removed from each file's metadata, unrecoverable files
+// are deleted, and orphaned .sia files and tracking entries are removed. The
+// renter is scanned again, and only entries that are both listed in confirmed
+// and still garbage at the time of the call are removed, so metadata that
+// became garbage after the review is kept. The removed metadata is returned.
+func (r *Renter) CollectGarbage(confirmed modules.RenterGarbage) (modules.RenterGarbage, error) {
+	if err := r.tg.Add(); err != nil {
+		return modules.RenterGarbage{}, err
+	}
+	defer r.tg.Done()
+
+	gs := r.managedGCState()
+	lockID := r.mu.Lock()
+	defer r.mu.Unlock(lockID)
+	g := confirmedGarbage(r.garbage(gs), confirmed)
+
+	for _, name := range g.UnrecoverableFiles {
+		delete(r.files, name)
+		delete(r.tracking, name)
+		err := os.RemoveAll(filepath.Join(r.persistDir, name+ShareExtension))
+		if err != nil {
+			return g, err
+		}
+	}
+
+	modified := make(map[string]struct{})
+	for _, sc := range g.StaleContracts {
+		f := r.files[sc.SiaPath]
+		f.mu.Lock()
+		delete(f.contracts, sc.ID)
+		f.mu.Unlock()
+		modified[sc.SiaPath] = struct{}{}
+	}
+	for _, op := range g.OrphanedPieces {
+		f := r.files[op.SiaPath]
+		f.mu.Lock()
+		for id, fc := range f.contracts {
+			pieces := fc.Pieces[:0]
+			for _, p := range fc.Pieces {
+				if p.Chunk < f.numChunks() && p.Piece < uint64(f.erasureCode.NumPieces()) {
+					pieces = append(pieces, p)
+				}
+			}
+			fc.Pieces = pieces
+			f.contracts[id] = fc
+		}
+		f.mu.Unlock()
+		modified[op.SiaPath] = struct{}{}
+	}
+	for name := range modified {
+		f := r.files[name]
+		f.mu.RLock()
+		err := r.saveFile(f)
+		f.mu.RUnlock()
+		if err != nil {
+			return g, err
+		}
+	}
+
+	for _, rel := range g.OrphanedShareFiles {
+		if err := os.Remove(filepath.Join(r.persistDir, rel)); err != nil {
+			return g, err
+		}
+	}
+	for _, name := range g.OrphanedTracking {
+		delete(r.tracking, name)
+	}
+
+	r.log.Printf("INFO: garbage collection removed %v stale contracts, %v unrecoverable files, %v orphaned .sia files, and %v orphaned tracking entries",
+		len(g.StaleContracts), len(g.UnrecoverableFiles), len(g.OrphanedShareFiles), len(g.OrphanedTracking))
+	return g, r.saveSync()
+}
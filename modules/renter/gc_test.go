@@ -0,0 +1,163 @@
+package renter
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestRenterGarbage checks that the garbage collector reports stale
+// contracts, orphaned pieces, unrecoverable files, and orphaned metadata, and
+// that CollectGarbage removes exactly what was reported.
+func TestRenterGarbage(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+	r := rt.renter
+	height := rt.cs.Height()
+
+	rsc, _ := NewRSCode(1, 2)
+	newFile := func(name string) *file {
+		return &file{
+			name:        name,
+			size:        100,
+			erasureCode: rsc,
+			pieceSize:   100,
+			masterKey:   crypto.GenerateTwofishKey(),
+			contracts:   make(map[types.FileContractID]fileContract),
+		}
+	}
+
+	// kept has an expired contract, a live contract, and a piece record for
+	// a chunk that does not exist. It can be repaired from a local copy.
+	repairPath := filepath.Join(r.persistDir, "kept.txt")
+	if err := ioutil.WriteFile(repairPath, make([]byte, 100), 0600); err != nil {
+		t.Fatal(err)
+	}
+	kept := newFile("foo/kept")
+	kept.contracts[types.FileContractID{1}] = fileContract{
+		ID:          types.FileContractID{1},
+		IP:          "host1:9982",
+		Pieces:      []pieceData{{Chunk: 0, Piece: 0}},
+		WindowStart: height,
+	}
+	kept.contracts[types.FileContractID{2}] = fileContract{
+		ID:          types.FileContractID{2},
+		IP:          "host2:9982",
+		Pieces:      []pieceData{{Chunk: 0, Piece: 1}, {Chunk: 5, Piece: 0}},
+		WindowStart: height + 100,
+	}
+
+	// lost is only stored on an expired contract and is not tracked.
+	lost := newFile("lost")
+	lost.contracts[types.FileContractID{3}] = fileContract{
+		ID:          types.FileContractID{3},
+		IP:          "host3:9982",
+		Pieces:      []pieceData{{Chunk: 0, Piece: 0}},
+		WindowStart: height,
+	}
+
+	id := r.mu.Lock()
+	r.files[kept.name] = kept
+	r.files[lost.name] = lost
+	r.tracking[kept.name] = trackedFile{RepairPath: repairPath}
+	r.tracking["gone"] = trackedFile{RepairPath: repairPath}
+	for _, f := range []*file{kept, lost} {
+		if err := r.saveFile(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r.mu.Unlock(id)
+	orphanPath := filepath.Join(r.persistDir, "old", "name"+ShareExtension)
+	if err := os.MkdirAll(filepath.Dir(orphanPath), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(orphanPath, []byte("stale"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	g := r.Garbage()
+	if len(g.StaleContracts) != 1 || g.StaleContracts[0].SiaPath != kept.name || g.StaleContracts[0].ID != (types.FileContractID{1}) {
+		t.Fatal("wrong stale contracts:", g.StaleContracts)
+	}
+	if len(g.OrphanedPieces) != 1 || g.OrphanedPieces[0].SiaPath != kept.name || g.OrphanedPieces[0].Pieces != 1 {
+		t.Fatal("wrong orphaned pieces:", g.OrphanedPieces)
+	}
+	if len(g.UnrecoverableFiles) != 1 || g.UnrecoverableFiles[0] != lost.name {
+		t.Fatal("wrong unrecoverable files:", g.UnrecoverableFiles)
+	}
+	if len(g.OrphanedShareFiles) != 1 || g.OrphanedShareFiles[0] != filepath.Join("old", "name"+ShareExtension) {
+		t.Fatal("wrong orphaned share files:", g.OrphanedShareFiles)
+	}
+	if len(g.OrphanedTracking) != 1 || g.OrphanedTracking[0] != "gone" {
+		t.Fatal("wrong orphaned tracking entries:", g.OrphanedTracking)
+	}
+
+	// Reporting garbage must not remove anything. FileList cannot be used
+	// yet, as it does not tolerate orphaned pieces.
+	if len(r.files) != 2 || len(kept.contracts) != 2 {
+		t.Fatal("Garbage modified the renter")
+	}
+
+	// Only the confirmed entries should be removed.
+	removed, err := r.CollectGarbage(modules.RenterGarbage{OrphanedTracking: []string{"gone"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed.OrphanedTracking) != 1 || len(removed.StaleContracts) != 0 || len(removed.UnrecoverableFiles) != 0 || len(removed.OrphanedShareFiles) != 0 {
+		t.Fatal("CollectGarbage removed unconfirmed entries:", removed)
+	}
+	if len(r.files) != 2 || len(kept.contracts) != 2 {
+		t.Fatal("CollectGarbage removed unconfirmed files or contracts")
+	}
+
+	removed, err = r.CollectGarbage(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed.StaleContracts) != 1 || len(removed.UnrecoverableFiles) != 1 {
+		t.Fatal("CollectGarbage reported the wrong removals:", removed)
+	}
+	files := r.FileList()
+	if len(files) != 1 || files[0].SiaPath != kept.name {
+		t.Fatal("unrecoverable file was not deleted:", files)
+	}
+	if len(kept.contracts) != 1 || len(kept.contracts[types.FileContractID{2}].Pieces) != 1 {
+		t.Fatal("stale contract or orphaned piece was not removed:", kept.contracts)
+	}
+	if _, exists := r.tracking["gone"]; exists {
+		t.Fatal("orphaned tracking entry was not removed")
+	}
+	for _, path := range []string{orphanPath, filepath.Join(r.persistDir, lost.name+ShareExtension)} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatal("expected file to be removed:", path)
+		}
+	}
+
+	// The pruned metadata should survive a restart, and nothing should be
+	// left to collect.
+	if err := rt.renter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	rt.renter, err = New(rt.gateway, rt.cs, rt.wallet, rt.tpool, r.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g = rt.renter.Garbage()
+	if len(g.StaleContracts)+len(g.OrphanedPieces)+len(g.UnrecoverableFiles)+len(g.OrphanedShareFiles)+len(g.OrphanedTracking) != 0 {
+		t.Fatal("garbage remained after collection:", g)
+	}
+	if len(rt.renter.FileList()) != 1 {
+		t.Fatal("expected 1 file after restart, got", len(rt.renter.FileList()))
+	}
+}
@@ -0,0 +1,105 @@
+package renter
+
+import (
+	"errors"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// errNoHandoffContract is returned when handing off to or from a host that
+// the renter does not have a contract with.
+var errNoHandoffContract = errors.New("no contract with that host")
+
+// Handoff asks the host at from, which is retiring, to hand off the sectors
+// of the renter's contract with it to the host at to, which the renter must
+// also have a contract with. Once the sectors have been attached to the
+// contract with the replacement host, the metadata of every file stored on
+// the retiring host is re-pointed at the replacement host, so the files do
+// not need to be repaired. A piece is not re-pointed if the replacement host
+// already stores another piece of the same chunk, as a host holding two pieces
+// of a chunk adds no redundancy; it is dropped instead, so the repair loop
+// uploads it to another host. The handoff record signed by the retiring host
+// is returned.
+func (r *Renter) Handoff(from, to modules.NetAddress) (modules.HandoffRecord, error) {
+	if err := r.tg.Add(); err != nil {
+		return modules.HandoffRecord{}, err
+	}
+	defer r.tg.Done()
+
+	contract, ok := r.hostContractor.Contract(from)
+	if !ok {
+		return modules.HandoffRecord{}, errNoHandoffContract
+	}
+	replacement, ok := r.hostContractor.Contract(to)
+	if !ok {
+		return modules.HandoffRecord{}, errNoHandoffContract
+	}
+	record, err := r.hostContractor.Handoff(contract.ID, replacement.ID)
+	if err != nil {
+		return modules.HandoffRecord{}, err
+	}
+	if c, ok := r.hostContractor.Contract(to); ok {
+		replacement = c
+	}
+
+	// Re-point the pieces stored on the retiring host.
+	handedOff := make(map[crypto.Hash]struct{}, len(record.Authorization.SectorRoots))
+	for _, root := range record.Authorization.SectorRoots {
+		handedOff[root] = struct{}{}
+	}
+	lockID := r.mu.Lock()
+	defer r.mu.Unlock(lockID)
+	var repointed, dropped int
+	for _, f := range r.files {
+		f.mu.Lock()
+		modified := false
+		for id, fc := range f.contracts {
+			if r.hostContractor.ResolveID(id) != record.Authorization.ContractID {
+				continue
+			}
+			dst, ok := f.contracts[replacement.ID]
+			if !ok {
+				dst = fileContract{ID: replacement.ID}
+			}
+			dst.IP = replacement.NetAddress
+			dst.WindowStart = replacement.EndHeight()
+			chunks := make(map[uint64]struct{}, len(dst.Pieces))
+			for _, p := range dst.Pieces {
+				chunks[p.Chunk] = struct{}{}
+			}
+			var remaining []pieceData
+			for _, p := range fc.Pieces {
+				if _, ok := handedOff[p.MerkleRoot]; !ok {
+					remaining = append(remaining, p)
+				} else if _, ok := chunks[p.Chunk]; ok {
+					dropped++
+				} else {
+					chunks[p.Chunk] = struct{}{}
+					dst.Pieces = append(dst.Pieces, p)
+					repointed++
+				}
+			}
+			if len(remaining) == len(fc.Pieces) {
+				continue
+			}
+			if len(remaining) == 0 {
+				delete(f.contracts, id)
+			} else {
+				fc.Pieces = remaining
+				f.contracts[id] = fc
+			}
+			f.contracts[replacement.ID] = dst
+			modified = true
+		}
+		if modified {
+			err = r.saveFile(f)
+		}
+		f.mu.Unlock()
+		if err != nil {
+			return record, err
+		}
+	}
+	r.log.Printf("INFO: %v handed off to %v; re-pointed %v pieces, dropped %v", from, to, repointed, dropped)
+	return record, nil
+}
@@ -327,3 +327,16 @@ func (hdb *HostDB) threadedScan() {
 		}
 	}
 }
+
+// ScanAllHosts queues a scan of every host known to the hostdb, including the
+// hosts that are currently offline. The contractor uses it to re-bootstrap the
+// hostdb when there are not enough active hosts to form contracts with.
+func (hdb *HostDB) ScanAllHosts() {
+	hdb.mu.Lock()
+	defer hdb.mu.Unlock()
+	allHosts := hdb.hostTree.All()
+	hdb.log.Println("Performing scan on all", len(allHosts), "hosts.")
+	for _, host := range allHosts {
+		hdb.queueScan(host)
+	}
+}
@@ -0,0 +1,104 @@
+package renter
+
+import (
+	"sort"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// pieceSet is a sortable list of pieces, ordered by chunk, then by piece
+// index, then by Merkle root.
+type pieceSet []pieceData
+
+func (ps pieceSet) Len() int      { return len(ps) }
+func (ps pieceSet) Swap(i, j int) { ps[i], ps[j] = ps[j], ps[i] }
+func (ps pieceSet) Less(i, j int) bool {
+	if ps[i].Chunk != ps[j].Chunk {
+		return ps[i].Chunk < ps[j].Chunk
+	}
+	if ps[i].Piece != ps[j].Piece {
+		return ps[i].Piece < ps[j].Piece
+	}
+	return string(ps[i].MerkleRoot[:]) < string(ps[j].MerkleRoot[:])
+}
+
+// contentHash returns a hash committing to the file's size, encryption key,
+// and the Merkle root of each erasure-coded piece stored on the network. A
+// piece is identified by its chunk and piece index; copies of the same piece
+// held by several contracts are only counted once. Pieces are sorted before
+// hashing, so the hash depends neither on which contracts hold the pieces nor
+// on how many copies of each piece are stored.
+func (f *file) contentHash() crypto.Hash {
+	var all pieceSet
+	for _, fc := range f.contracts {
+		all = append(all, fc.Pieces...)
+	}
+	sort.Sort(all)
+	var pieces []pieceData
+	for i, p := range all {
+		if i > 0 && p == all[i-1] {
+			continue
+		}
+		pieces = append(pieces, p)
+	}
+	return crypto.HashAll(f.size, f.pieceSize, f.masterKey, pieces)
+}
+
+// hosts returns the sorted addresses of the hosts storing pieces of the file.
+func (f *file) hosts() []modules.NetAddress {
+	seen := make(map[modules.NetAddress]struct{})
+	var addrs []string
+	for _, fc := range f.contracts {
+		if _, ok := seen[fc.IP]; ok || len(fc.Pieces) == 0 {
+			continue
+		}
+		seen[fc.IP] = struct{}{}
+		addrs = append(addrs, string(fc.IP))
+	}
+	sort.Strings(addrs)
+	hosts := make([]modules.NetAddress, len(addrs))
+	for i, addr := range addrs {
+		hosts[i] = modules.NetAddress(addr)
+	}
+	return hosts
+}
+
+// Manifest returns a manifest listing every file known to the renter, signed
+// with the renter's manifest key. Files are sorted by path.
+func (r *Renter) Manifest() modules.RenterManifest {
+	lockID := r.mu.RLock()
+	names := make([]string, 0, len(r.files))
+	for name := range r.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	files := make([]modules.RenterManifestFile, 0, len(names))
+	for _, name := range names {
+		f := r.files[name]
+		f.mu.RLock()
+		files = append(files, modules.RenterManifestFile{
+			SiaPath:      f.name,
+			Filesize:     f.size,
+			ContentHash:  f.contentHash(),
+			DataPieces:   f.erasureCode.MinPieces(),
+			ParityPieces: f.erasureCode.NumPieces() - f.erasureCode.MinPieces(),
+			Redundancy:   f.redundancy(),
+			Hosts:        f.hosts(),
+		})
+		f.mu.RUnlock()
+	}
+	sk := r.manifestKey
+	r.mu.RUnlock(lockID)
+
+	m := modules.RenterManifest{
+		Height:    r.cs.Height(),
+		Timestamp: types.CurrentTimestamp(),
+		Files:     files,
+		PublicKey: types.Ed25519PublicKey(sk.PublicKey()),
+	}
+	sig := crypto.SignHash(m.SigHash(), sk)
+	m.Signature = sig[:]
+	return m
+}
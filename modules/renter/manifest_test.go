@@ -0,0 +1,117 @@
+package renter
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestRenterManifest checks that the renter's manifest lists every file, is
+// signed by the renter's persistent manifest key, and reflects changes to the
+// stored pieces of a file.
+func TestRenterManifest(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// An empty renter should produce a valid, empty manifest.
+	m := rt.renter.Manifest()
+	if len(m.Files) != 0 {
+		t.Fatal("expected empty manifest, got", len(m.Files), "files")
+	}
+	if err := m.VerifySignature(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Add two files, one of which is stored on two hosts.
+	rsc, _ := NewRSCode(1, 2)
+	newFile := func(name string) *file {
+		return &file{
+			name:        name,
+			size:        100,
+			erasureCode: rsc,
+			pieceSize:   100,
+			masterKey:   crypto.GenerateTwofishKey(),
+			contracts:   make(map[types.FileContractID]fileContract),
+		}
+	}
+	b, a := newFile("foo/b"), newFile("a")
+	b.contracts[types.FileContractID{1}] = fileContract{
+		ID:     types.FileContractID{1},
+		IP:     "host2:9982",
+		Pieces: []pieceData{{Chunk: 0, Piece: 1, MerkleRoot: crypto.Hash{1}}},
+	}
+	b.contracts[types.FileContractID{2}] = fileContract{
+		ID:     types.FileContractID{2},
+		IP:     "host1:9982",
+		Pieces: []pieceData{{Chunk: 0, Piece: 0, MerkleRoot: crypto.Hash{2}}},
+	}
+	rt.renter.files[b.name] = b
+	rt.renter.files[a.name] = a
+
+	m = rt.renter.Manifest()
+	if err := m.VerifySignature(); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Files) != 2 || m.Files[0].SiaPath != "a" || m.Files[1].SiaPath != "foo/b" {
+		t.Fatal("manifest files are wrong or not sorted:", m.Files)
+	}
+	fb := m.Files[1]
+	if fb.Filesize != 100 || fb.DataPieces != 1 || fb.ParityPieces != 2 || fb.Redundancy != 2 {
+		t.Fatal("manifest entry has wrong metadata:", fb)
+	}
+	if len(fb.Hosts) != 2 || fb.Hosts[0] != "host1:9982" || fb.Hosts[1] != "host2:9982" {
+		t.Fatal("manifest entry has wrong hosts:", fb.Hosts)
+	}
+	if m.Files[0].ContentHash == fb.ContentHash {
+		t.Fatal("different files should have different content hashes")
+	}
+
+	// Tampering with the manifest should invalidate the signature.
+	m.Files[1].Filesize++
+	if err := m.VerifySignature(); err != modules.ErrInvalidManifestSignature {
+		t.Fatal("expected ErrInvalidManifestSignature, got", err)
+	}
+	m.Files[1].Filesize--
+	m.Signature[0] ^= 1
+	if err := m.VerifySignature(); err != modules.ErrInvalidManifestSignature {
+		t.Fatal("expected ErrInvalidManifestSignature, got", err)
+	}
+
+	// Storing a copy of a piece on another host should not change the content
+	// hash.
+	b.contracts[types.FileContractID{3}] = fileContract{
+		ID:     types.FileContractID{3},
+		IP:     "host3:9982",
+		Pieces: []pieceData{{Chunk: 0, Piece: 0, MerkleRoot: crypto.Hash{2}}},
+	}
+	if rt.renter.Manifest().Files[1].ContentHash != fb.ContentHash {
+		t.Fatal("content hash changed after a piece was copied to another host")
+	}
+	delete(b.contracts, types.FileContractID{3})
+
+	// Changing one of the stored pieces should change the content hash.
+	fc := b.contracts[types.FileContractID{1}]
+	fc.Pieces[0].MerkleRoot = crypto.Hash{3}
+	b.contracts[fc.ID] = fc
+	if rt.renter.Manifest().Files[1].ContentHash == fb.ContentHash {
+		t.Fatal("content hash did not change after a piece changed")
+	}
+
+	// The manifest key should survive a reload of the renter's persistence.
+	pk := m.PublicKey.String()
+	rt.renter.manifestKey = crypto.SecretKey{}
+	if err := rt.renter.load(); err != nil {
+		t.Fatal(err)
+	}
+	if m = rt.renter.Manifest(); m.PublicKey.String() != pk {
+		t.Fatal("manifest key was not persisted")
+	}
+}
@@ -0,0 +1,76 @@
+package renter
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// errInvalidPauseDuration is returned if the renter is asked to pause for
+	// a non-positive amount of time.
+	errInvalidPauseDuration = errors.New("pause duration must be greater than zero")
+)
+
+// Pause halts all uploads, downloads, repairs, and the formation of new
+// contracts for the provided duration. Work that is already in flight is
+// allowed to finish. Contracts that enter the renew window are still renewed,
+// as missing a renewal could cause files to be lost. Calling Pause while the
+// renter is already paused replaces the existing deadline.
+func (r *Renter) Pause(duration time.Duration) error {
+	if duration <= 0 {
+		return errInvalidPauseDuration
+	}
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+
+	id := r.mu.Lock()
+	defer r.mu.Unlock(id)
+	if r.pauseChan == nil {
+		r.pauseChan = make(chan struct{})
+	}
+	if r.pauseTimer != nil {
+		r.pauseTimer.Stop()
+	}
+	r.pausedUntil = time.Now().Add(duration)
+	r.pauseTimer = time.AfterFunc(duration, r.Resume)
+	r.hostContractor.PauseFormation(r.pausedUntil)
+	r.log.Println("INFO: renter activity paused until", r.pausedUntil)
+	return nil
+}
+
+// PausedUntil returns the time at which renter activity will resume, or the
+// zero time if the renter is not paused.
+func (r *Renter) PausedUntil() time.Time {
+	id := r.mu.RLock()
+	defer r.mu.RUnlock(id)
+	return r.pausedUntil
+}
+
+// Resume resumes all renter activity that was halted by Pause. Calling Resume
+// while the renter is not paused has no effect.
+func (r *Renter) Resume() {
+	id := r.mu.Lock()
+	defer r.mu.Unlock(id)
+	if r.pauseChan == nil {
+		return
+	}
+	if r.pauseTimer != nil {
+		r.pauseTimer.Stop()
+		r.pauseTimer = nil
+	}
+	close(r.pauseChan)
+	r.pauseChan = nil
+	r.pausedUntil = time.Time{}
+	r.hostContractor.PauseFormation(time.Time{})
+	r.log.Println("INFO: renter activity resumed")
+}
+
+// managedPauseChan returns a channel that will be closed when renter activity
+// resumes. A nil channel is returned if the renter is not paused.
+func (r *Renter) managedPauseChan() <-chan struct{} {
+	id := r.mu.RLock()
+	defer r.mu.RUnlock(id)
+	return r.pauseChan
+}
@@ -0,0 +1,61 @@
+package renter
+
+import (
+	"testing"
+	"time"
+)
+
+// TestRenterPause checks that pausing and resuming the renter updates the
+// pause state, and that a pause expires on its own.
+func TestRenterPause(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Non-positive durations should be rejected.
+	if err := rt.renter.Pause(0); err != errInvalidPauseDuration {
+		t.Fatal("expected errInvalidPauseDuration, got", err)
+	}
+	if !rt.renter.PausedUntil().IsZero() {
+		t.Fatal("renter should not be paused")
+	}
+
+	// Pause the renter, then resume it manually.
+	if err := rt.renter.Pause(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	pauseChan := rt.renter.managedPauseChan()
+	if pauseChan == nil {
+		t.Fatal("renter should be paused")
+	}
+	if until := rt.renter.PausedUntil(); time.Until(until) <= 0 || time.Until(until) > time.Hour {
+		t.Fatal("unexpected pause deadline:", until)
+	}
+	rt.renter.Resume()
+	select {
+	case <-pauseChan:
+	default:
+		t.Fatal("pause channel was not closed by Resume")
+	}
+	if !rt.renter.PausedUntil().IsZero() || rt.renter.managedPauseChan() != nil {
+		t.Fatal("renter should not be paused after Resume")
+	}
+
+	// A short pause should expire without intervention.
+	if err := rt.renter.Pause(50 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-rt.renter.managedPauseChan():
+	case <-time.After(5 * time.Second):
+		t.Fatal("renter did not resume after the pause expired")
+	}
+	if !rt.renter.PausedUntil().IsZero() {
+		t.Fatal("renter should not be paused after the pause expired")
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"strconv"
 
 	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/persist"
@@ -32,6 +33,12 @@ var (
 	shareHeader  = [15]byte{'S', 'i', 'a', ' ', 'S', 'h', 'a', 'r', 'e', 'd', ' ', 'F', 'i', 'l', 'e'}
 	shareVersion = "0.4"
 
+	// shareVersionPortable is the version of .sia files that are shared with
+	// other renters. Each file is followed by the public keys of the hosts of
+	// its contracts, as the contracts themselves belong to the sharing
+	// renter.
+	shareVersionPortable = "0.4-portable"
+
 	saveMetadata = persist.Metadata{
 		Header:  "Renter Persistence",
 		Version: "0.4",
@@ -169,7 +176,7 @@ func (r *Renter) saveFile(f *file) error {
 	defer handle.Close()
 
 	// Write file data.
-	err = shareFiles([]*file{f}, handle)
+	err = shareFiles([]*file{f}, nil, handle)
 	if err != nil {
 		return err
 	}
@@ -181,16 +188,20 @@ func (r *Renter) saveFile(f *file) error {
 // save stores the current renter data to disk.
 func (r *Renter) save() error {
 	data := struct {
-		Tracking map[string]trackedFile
-	}{r.tracking}
+		Tracking      map[string]trackedFile
+		ManifestKey   crypto.SecretKey
+		PendingRename *pendingRename
+	}{r.tracking, r.manifestKey, r.pendingRename}
 	return persist.SaveFile(saveMetadata, data, filepath.Join(r.persistDir, PersistFilename))
 }
 
 // saveSync stores the current renter data to disk and then syncs to disk.
 func (r *Renter) saveSync() error {
 	data := struct {
-		Tracking map[string]trackedFile
-	}{r.tracking}
+		Tracking      map[string]trackedFile
+		ManifestKey   crypto.SecretKey
+		PendingRename *pendingRename
+	}{r.tracking, r.manifestKey, r.pendingRename}
 	return persist.SaveFileSync(saveMetadata, data, filepath.Join(r.persistDir, PersistFilename))
 }
 
@@ -220,7 +231,7 @@ func (r *Renter) load() error {
 		defer file.Close()
 
 		// Load the file contents into the renter.
-		_, err = r.loadSharedFiles(file)
+		_, _, err = r.loadSharedFiles(file)
 		if err != nil {
 			r.log.Println("ERROR: could not load .sia file:", err)
 			return nil
@@ -233,8 +244,10 @@ func (r *Renter) load() error {
 
 	// Load contracts, repair set, and entropy.
 	data := struct {
-		Tracking  map[string]trackedFile
-		Repairing map[string]string // COMPATv0.4.8
+		Tracking      map[string]trackedFile
+		Repairing     map[string]string // COMPATv0.4.8
+		ManifestKey   crypto.SecretKey
+		PendingRename *pendingRename
 	}{}
 	err = persist.LoadFile(saveMetadata, &data, filepath.Join(r.persistDir, PersistFilename))
 	if err != nil {
@@ -243,17 +256,25 @@ func (r *Renter) load() error {
 	if data.Tracking != nil {
 		r.tracking = data.Tracking
 	}
+	r.manifestKey = data.ManifestKey
+	r.pendingRename = data.PendingRename
 
 	return nil
 }
 
 // shareFiles writes the specified files to w. First a header is written,
-// followed by the gzipped concatenation of each file.
-func shareFiles(files []*file, w io.Writer) error {
+// followed by the gzipped concatenation of each file. If hosts is not nil, the
+// portable format is written, where each file is followed by the hosts of its
+// contracts.
+func shareFiles(files []*file, hosts [][]sharedHost, w io.Writer) error {
 	// Write header.
+	version := shareVersion
+	if hosts != nil {
+		version = shareVersionPortable
+	}
 	err := encoding.NewEncoder(w).EncodeAll(
 		shareHeader,
-		shareVersion,
+		version,
 		uint64(len(files)),
 	)
 	if err != nil {
@@ -265,17 +286,24 @@ func shareFiles(files []*file, w io.Writer) error {
 	enc := encoding.NewEncoder(zip)
 
 	// Encode each file.
-	for _, f := range files {
+	for i, f := range files {
 		err = enc.Encode(f)
 		if err != nil {
 			return err
 		}
+		if hosts != nil {
+			err = enc.Encode(hosts[i])
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	return zip.Close()
 }
 
-// ShareFile saves the specified files to shareDest.
+// ShareFiles saves the specified files to shareDest, along with the hosts of
+// their contracts, so that they can be loaded by another renter.
 func (r *Renter) ShareFiles(nicknames []string, shareDest string) error {
 	lockID := r.mu.RLock()
 	defer r.mu.RUnlock(lockID)
@@ -301,7 +329,7 @@ func (r *Renter) ShareFiles(nicknames []string, shareDest string) error {
 		files[i] = f
 	}
 
-	err = shareFiles(files, handle)
+	err = shareFiles(files, r.sharedHosts(files), handle)
 	if err != nil {
 		os.Remove(shareDest)
 		return err
@@ -326,7 +354,13 @@ func (r *Renter) ShareFilesAscii(nicknames []string) (string, error) {
 	}
 
 	buf := new(bytes.Buffer)
-	err := shareFiles(files, base64.NewEncoder(base64.URLEncoding, buf))
+	enc := base64.NewEncoder(base64.URLEncoding, buf)
+	err := shareFiles(files, r.sharedHosts(files), enc)
+	if err != nil {
+		return "", err
+	}
+	// Flush any partially written block.
+	err = enc.Close()
 	if err != nil {
 		return "", err
 	}
@@ -335,8 +369,9 @@ func (r *Renter) ShareFilesAscii(nicknames []string) (string, error) {
 }
 
 // loadSharedFiles reads .sia data from reader and registers the contained
-// files in the renter. It returns the nicknames of the loaded files.
-func (r *Renter) loadSharedFiles(reader io.Reader) ([]string, error) {
+// files in the renter. It returns the nicknames of the loaded files, and for
+// .sia files in the portable format, the hosts of each loaded file.
+func (r *Renter) loadSharedFiles(reader io.Reader) ([]string, map[string][]sharedHost, error) {
 	// read header
 	var header [15]byte
 	var version string
@@ -347,27 +382,34 @@ func (r *Renter) loadSharedFiles(reader io.Reader) ([]string, error) {
 		&numFiles,
 	)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	} else if header != shareHeader {
-		return nil, ErrBadFile
-	} else if version != shareVersion {
-		return nil, ErrIncompatible
+		return nil, nil, ErrBadFile
+	} else if version != shareVersion && version != shareVersionPortable {
+		return nil, nil, ErrIncompatible
 	}
 
 	// Create decompressor.
 	unzip, err := gzip.NewReader(reader)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	dec := encoding.NewDecoder(unzip)
 
 	// Read each file.
 	files := make([]*file, numFiles)
+	hosts := make([][]sharedHost, numFiles)
 	for i := range files {
 		files[i] = new(file)
 		err := dec.Decode(files[i])
 		if err != nil {
-			return nil, err
+			return nil, nil, err
+		}
+		if version == shareVersionPortable {
+			err = dec.Decode(&hosts[i])
+			if err != nil {
+				return nil, nil, err
+			}
 		}
 
 		// Make sure the file's name does not conflict with existing files.
@@ -383,18 +425,27 @@ func (r *Renter) loadSharedFiles(reader io.Reader) ([]string, error) {
 		}
 	}
 
-	// Add files to renter.
+	// Add files to renter, pointing the contracts of shared files at the
+	// addresses of the renter's own contracts with the same hosts.
 	names := make([]string, numFiles)
+	var shared map[string][]sharedHost
+	if version == shareVersionPortable {
+		shared = make(map[string][]sharedHost)
+	}
 	for i, f := range files {
 		r.files[f.name] = f
 		names[i] = f.name
+		if shared != nil {
+			shared[f.name] = hosts[i]
+			r.repointSharedContracts(f, hosts[i])
+		}
 	}
 	// Save the files.
 	for _, f := range files {
 		r.saveFile(f)
 	}
 
-	return names, nil
+	return names, shared, nil
 }
 
 // initPersist handles all of the persistence initialization, such as creating
@@ -417,29 +468,54 @@ func (r *Renter) initPersist() error {
 	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
+	err = r.loadReceipts()
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	err = r.completePendingRename()
+	if err != nil {
+		return err
+	}
+
+	// Generate the key used to sign manifests if the renter does not have one
+	// yet.
+	if r.manifestKey == (crypto.SecretKey{}) {
+		r.manifestKey, _ = crypto.GenerateKeyPair()
+		return r.saveSync()
+	}
 	return nil
 }
 
 // LoadSharedFiles loads a .sia file into the renter. It returns the nicknames
-// of the loaded files.
+// of the loaded files. If the .sia file was shared by another renter, contracts
+// are formed with the hosts of the files that the renter has no contract with.
 func (r *Renter) LoadSharedFiles(filename string) ([]string, error) {
-	lockID := r.mu.Lock()
-	defer r.mu.Unlock(lockID)
-
 	file, err := os.Open(filename)
 	if err != nil {
 		return nil, err
 	}
 	defer file.Close()
-	return r.loadSharedFiles(file)
+
+	lockID := r.mu.Lock()
+	names, shared, err := r.loadSharedFiles(file)
+	r.mu.Unlock(lockID)
+	if err != nil {
+		return nil, err
+	}
+	r.managedFormSharedContracts(shared)
+	return names, nil
 }
 
 // LoadSharedFilesAscii loads an ASCII-encoded .sia file into the renter. It
 // returns the nicknames of the loaded files.
 func (r *Renter) LoadSharedFilesAscii(asciiSia string) ([]string, error) {
-	lockID := r.mu.Lock()
-	defer r.mu.Unlock(lockID)
-
 	dec := base64.NewDecoder(base64.URLEncoding, bytes.NewBufferString(asciiSia))
-	return r.loadSharedFiles(dec)
+	lockID := r.mu.Lock()
+	names, shared, err := r.loadSharedFiles(dec)
+	r.mu.Unlock(lockID)
+	if err != nil {
+		return nil, err
+	}
+	r.managedFormSharedContracts(shared)
+	return names, nil
 }
@@ -11,6 +11,7 @@ import (
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
 	"github.com/NebulousLabs/fastrand"
 )
 
@@ -178,6 +179,73 @@ func TestFileShareLoadASCII(t *testing.T) {
 	}
 }
 
+// TestFileSharePortable checks that .sia files shared with other renters
+// carry the hosts of each file's contracts, and that .sia files without hosts
+// can still be loaded.
+func TestFileSharePortable(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Create a file with a contract that the renter does not have.
+	savedFile := newTestingFile()
+	var fcid types.FileContractID
+	fastrand.Read(fcid[:])
+	savedFile.contracts = map[types.FileContractID]fileContract{
+		fcid: {ID: fcid, IP: "foo:1234", Pieces: []pieceData{{Chunk: 0, Piece: 0}}},
+	}
+	host := sharedHost{
+		ContractID: fcid,
+		PublicKey:  types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: fastrand.Bytes(32)},
+	}
+
+	// Share the file in the portable format and load it back.
+	buf := new(bytes.Buffer)
+	err = shareFiles([]*file{savedFile}, [][]sharedHost{{host}}, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := rt.renter.mu.Lock()
+	names, shared, err := rt.renter.loadSharedFiles(buf)
+	rt.renter.mu.Unlock(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != savedFile.name {
+		t.Fatal("nickname not loaded properly:", names)
+	}
+	hosts := shared[savedFile.name]
+	if len(hosts) != 1 || hosts[0].ContractID != fcid || hosts[0].PublicKey.String() != host.PublicKey.String() {
+		t.Fatal("hosts not loaded properly:", hosts)
+	}
+	// The renter has no contract with the host, so the file is unchanged.
+	if _, exists := rt.renter.files[savedFile.name].contracts[fcid]; !exists {
+		t.Fatal("contract of shared file was changed")
+	}
+
+	// Files in the old format load without hosts.
+	delete(rt.renter.files, savedFile.name)
+	buf.Reset()
+	err = shareFiles([]*file{savedFile}, nil, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id = rt.renter.mu.Lock()
+	names, shared, err = rt.renter.loadSharedFiles(buf)
+	rt.renter.mu.Unlock(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || shared != nil {
+		t.Fatal("old format not loaded properly:", names, shared)
+	}
+}
+
 // TestRenterSaveLoad probes the save and load methods of the renter type.
 func TestRenterSaveLoad(t *testing.T) {
 	if testing.Short() {
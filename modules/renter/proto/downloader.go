@@ -143,6 +143,7 @@ func NewDownloader(host modules.HostDBEntry, contract modules.RenterContract, ca
 	if err != nil {
 		return nil, err
 	}
+	conn = modules.ScheduleConn(conn, modules.BandwidthClassRenterDownload)
 
 	closeChan := make(chan struct{})
 	go func() {
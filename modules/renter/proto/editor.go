@@ -269,6 +269,7 @@ func NewEditor(host modules.HostDBEntry, contract modules.RenterContract, curren
 	if err != nil {
 		return nil, err
 	}
+	conn = modules.ScheduleConn(conn, modules.BandwidthClassRenterUpload)
 
 	closeChan := make(chan struct{})
 	go func() {
@@ -0,0 +1,140 @@
+package proto
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// errBadHandoffRecord is returned when a retiring host returns a handoff
+// record that does not match the renter's authorization.
+var errBadHandoffRecord = errors.New("host returned a handoff record that does not match the authorization")
+
+// SignHandoff signs a handoff authorization with the renter keys of the
+// contract being handed off and of the contract with the replacement host.
+func SignHandoff(ha modules.HandoffAuthorization, contract, replacement modules.RenterContract) modules.HandoffAuthorization {
+	hash := ha.SigHash()
+	contractSig := crypto.SignHash(hash, contract.SecretKey)
+	replacementSig := crypto.SignHash(hash, replacement.SecretKey)
+	ha.ContractSignature = contractSig[:]
+	ha.ReplacementSignature = replacementSig[:]
+	return ha
+}
+
+// Handoff asks the host of contract to hand off the sectors listed in the
+// authorization to the replacement host. It blocks until every sector has
+// been transferred, and returns the handoff record signed by the host.
+func Handoff(contract modules.RenterContract, ha modules.HandoffAuthorization, cancel <-chan struct{}) (modules.HandoffRecord, error) {
+	conn, err := (&net.Dialer{
+		Cancel:  cancel,
+		Timeout: 15 * time.Second,
+	}).Dial("tcp", string(contract.NetAddress))
+	if err != nil {
+		return modules.HandoffRecord{}, err
+	}
+	defer conn.Close()
+	conn = modules.ScheduleConn(conn, modules.BandwidthClassRenterUpload)
+
+	// send the authorization
+	extendDeadline(conn, modules.NegotiateHandoffTime)
+	if err := encoding.WriteObject(conn, modules.RPCHandoff); err != nil {
+		return modules.HandoffRecord{}, errors.New("couldn't initiate RPC: " + err.Error())
+	}
+	if err := encoding.WriteObject(conn, ha); err != nil {
+		return modules.HandoffRecord{}, errors.New("couldn't send handoff authorization: " + err.Error())
+	}
+	if err := modules.ReadNegotiationAcceptance(conn); err != nil {
+		return modules.HandoffRecord{}, errors.New("host rejected handoff authorization: " + err.Error())
+	}
+
+	// wait for the host to transfer the sectors; allot the same time per
+	// sector as an upload
+	extendDeadline(conn, modules.NegotiateHandoffTime+time.Duration(len(ha.SectorRoots))*modules.NegotiateFileContractRevisionTime)
+	if err := modules.ReadNegotiationAcceptance(conn); err != nil {
+		return modules.HandoffRecord{}, errors.New("host failed to hand off sectors: " + err.Error())
+	}
+	var record modules.HandoffRecord
+	if err := encoding.ReadObject(conn, &record, modules.NegotiateMaxHandoffAuthorizationSize+1e3); err != nil {
+		return modules.HandoffRecord{}, errors.New("couldn't read handoff record: " + err.Error())
+	}
+
+	// verify the record
+	if record.HostKey.String() != contract.HostPublicKey.String() || record.Authorization.SigHash() != ha.SigHash() {
+		return modules.HandoffRecord{}, errBadHandoffRecord
+	}
+	if err := record.VerifySignature(); err != nil {
+		return modules.HandoffRecord{}, err
+	}
+	return record, nil
+}
+
+// attachBatchSize is the number of sectors attached in each revision by
+// Attach, keeping the revision actions well under the host's maximum batch
+// size.
+const attachBatchSize = 1024
+
+// Attach negotiates revisions that add sectors that were handed off to the
+// host to a file contract.
+func (he *Editor) Attach(roots []crypto.Hash) (modules.RenterContract, error) {
+	for len(roots) > 0 {
+		n := len(roots)
+		if n > attachBatchSize {
+			n = attachBatchSize
+		}
+		if err := he.attachBatch(roots[:n]); err != nil {
+			return modules.RenterContract{}, err
+		}
+		roots = roots[n:]
+	}
+	return he.contract, nil
+}
+
+// attachBatch negotiates a single revision that attaches roots to the
+// contract.
+func (he *Editor) attachBatch(roots []crypto.Hash) error {
+	extendDeadline(he.conn, modules.NegotiateFileContractRevisionTime)
+	defer extendDeadline(he.conn, time.Hour) // reset deadline
+
+	// calculate price; the sectors were uploaded by the retiring host, so
+	// only storage is paid for
+	blockBytes := types.NewCurrency64(modules.SectorSize * uint64(he.contract.FileContract.WindowEnd-he.height))
+	sectorStoragePrice := he.host.StoragePrice.Mul(blockBytes).MulFloat(1 + hostPriceLeeway)
+	sectorCollateral := he.host.Collateral.Mul(blockBytes).MulFloat(1 - hostPriceLeeway)
+	storagePrice := sectorStoragePrice.Mul64(uint64(len(roots)))
+	collateral := sectorCollateral.Mul64(uint64(len(roots)))
+	if he.contract.RenterFunds().Cmp(storagePrice) < 0 {
+		return errors.New("contract has insufficient funds to support attaching sectors")
+	}
+	if he.contract.LastRevision.NewMissedProofOutputs[1].Value.Cmp(collateral) < 0 {
+		return errors.New("contract has insufficient collateral to support attaching sectors")
+	}
+
+	// calculate the new Merkle root
+	newRoots := append(append([]crypto.Hash(nil), he.contract.MerkleRoots...), roots...)
+	merkleRoot := cachedMerkleRoot(newRoots)
+
+	// create the actions and revision
+	actions := make([]modules.RevisionAction, len(roots))
+	for i, root := range roots {
+		actions[i] = modules.RevisionAction{
+			Type:        modules.ActionAttach,
+			SectorIndex: uint64(len(he.contract.MerkleRoots) + i),
+			Data:        append([]byte(nil), root[:]...),
+		}
+	}
+	rev := newAttachRevision(he.contract.LastRevision, merkleRoot, len(roots), storagePrice, collateral)
+
+	// run the revision iteration
+	if err := he.runRevisionIteration(actions, rev, newRoots); err != nil {
+		return err
+	}
+
+	// update metrics
+	he.contract.StorageSpending = he.contract.StorageSpending.Add(storagePrice)
+	return nil
+}
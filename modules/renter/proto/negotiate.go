@@ -210,6 +210,21 @@ func newUploadRevision(current types.FileContractRevision, merkleRoot crypto.Has
 	return rev
 }
 
+// newAttachRevision revises the current revision to cover the cost of
+// attaching n sectors that were handed off to the host.
+func newAttachRevision(current types.FileContractRevision, merkleRoot crypto.Hash, n int, price, collateral types.Currency) types.FileContractRevision {
+	rev := newRevision(current, price)
+
+	// move collateral from host to void
+	rev.NewMissedProofOutputs[1].Value = rev.NewMissedProofOutputs[1].Value.Sub(collateral)
+	rev.NewMissedProofOutputs[2].Value = rev.NewMissedProofOutputs[2].Value.Add(collateral)
+
+	// set new filesize and Merkle root
+	rev.NewFileSize += uint64(n) * modules.SectorSize
+	rev.NewFileMerkleRoot = merkleRoot
+	return rev
+}
+
 // newDeleteRevision revises the current revision to cover the cost of
 // deleting a sector.
 func newDeleteRevision(current types.FileContractRevision, merkleRoot crypto.Hash) types.FileContractRevision {
@@ -0,0 +1,172 @@
+package renter
+
+import (
+	"path/filepath"
+	"sort"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+const receiptsFilename = "receipts.json"
+
+// receiptsMetadata is the header of the file holding the upload receipts.
+var receiptsMetadata = persist.Metadata{
+	Header:  "Renter Upload Receipts",
+	Version: "1.0",
+}
+
+// receiptPieces sorts the pieces of a receipt by chunk, then by piece index,
+// then by Merkle root.
+type receiptPieces []modules.RenterUploadReceiptPiece
+
+func (rp receiptPieces) Len() int      { return len(rp) }
+func (rp receiptPieces) Swap(i, j int) { rp[i], rp[j] = rp[j], rp[i] }
+func (rp receiptPieces) Less(i, j int) bool {
+	if rp[i].Chunk != rp[j].Chunk {
+		return rp[i].Chunk < rp[j].Chunk
+	}
+	if rp[i].Piece != rp[j].Piece {
+		return rp[i].Piece < rp[j].Piece
+	}
+	return string(rp[i].MerkleRoot[:]) < string(rp[j].MerkleRoot[:])
+}
+
+// uploadComplete returns true if every piece of every chunk of the file has
+// been uploaded.
+func (f *file) uploadComplete() bool {
+	chunkPieces := make([]map[uint64]struct{}, f.numChunks())
+	for i := range chunkPieces {
+		chunkPieces[i] = make(map[uint64]struct{})
+	}
+	for _, fc := range f.contracts {
+		for _, p := range fc.Pieces {
+			chunkPieces[p.Chunk][p.Piece] = struct{}{}
+		}
+	}
+	for _, pieces := range chunkPieces {
+		if len(pieces) < f.erasureCode.NumPieces() {
+			return false
+		}
+	}
+	return true
+}
+
+// receiptPieces returns every piece of the file, along with the host and
+// contract storing it, sorted by chunk and piece index.
+func (f *file) receiptPieces() []modules.RenterUploadReceiptPiece {
+	var pieces receiptPieces
+	for _, fc := range f.contracts {
+		for _, p := range fc.Pieces {
+			pieces = append(pieces, modules.RenterUploadReceiptPiece{
+				Chunk:      p.Chunk,
+				Piece:      p.Piece,
+				MerkleRoot: p.MerkleRoot,
+				Host:       fc.IP,
+				ContractID: fc.ID,
+			})
+		}
+	}
+	sort.Sort(pieces)
+	return pieces
+}
+
+// saveReceipts stores the upload receipts to disk.
+func (r *Renter) saveReceipts() error {
+	return persist.SaveFileSync(receiptsMetadata, r.receipts, filepath.Join(r.persistDir, receiptsFilename))
+}
+
+// loadReceipts loads the upload receipts from disk.
+func (r *Renter) loadReceipts() error {
+	return persist.LoadFile(receiptsMetadata, &r.receipts, filepath.Join(r.persistDir, receiptsFilename))
+}
+
+// managedIssueReceipts produces a signed receipt for each of the named files
+// that has finished uploading and does not have a receipt yet. Each new
+// receipt is passed to the receipt handlers. Files that are not tracked were
+// loaded from .sia files rather than uploaded, and do not get receipts.
+func (r *Renter) managedIssueReceipts(names []string) {
+	var issued []modules.RenterUploadReceipt
+	id := r.mu.Lock()
+	for _, name := range names {
+		f, exists := r.files[name]
+		if !exists {
+			continue
+		}
+		if _, tracked := r.tracking[name]; !tracked {
+			continue
+		}
+		if _, done := r.receipts[name]; done {
+			continue
+		}
+		f.mu.RLock()
+		complete := f.uploadComplete()
+		receipt := modules.RenterUploadReceipt{
+			SiaPath:   f.name,
+			Filesize:  f.size,
+			Height:    r.cs.Height(),
+			Timestamp: types.CurrentTimestamp(),
+			Pieces:    f.receiptPieces(),
+			PublicKey: types.Ed25519PublicKey(r.manifestKey.PublicKey()),
+		}
+		f.mu.RUnlock()
+		if !complete {
+			continue
+		}
+		sig := crypto.SignHash(receipt.SigHash(), r.manifestKey)
+		receipt.Signature = sig[:]
+		r.receipts[name] = receipt
+		issued = append(issued, receipt)
+	}
+	if len(issued) > 0 {
+		if err := r.saveReceipts(); err != nil {
+			r.log.Println("ERROR: unable to save upload receipts:", err)
+		}
+	}
+	handlers := r.receiptHandlers
+	r.mu.Unlock(id)
+
+	for _, receipt := range issued {
+		r.log.Println("Upload of", receipt.SiaPath, "complete, receipt issued")
+		for _, fn := range handlers {
+			fn(receipt)
+		}
+	}
+}
+
+// AddUploadReceiptHandler registers a function that is called with each new
+// upload receipt. Handlers are called by the repair loop, so they should
+// return quickly.
+func (r *Renter) AddUploadReceiptHandler(fn func(modules.RenterUploadReceipt)) {
+	id := r.mu.Lock()
+	r.receiptHandlers = append(r.receiptHandlers, fn)
+	r.mu.Unlock(id)
+}
+
+// UploadReceipt returns the signed receipt that was produced when the file at
+// siapath finished uploading.
+func (r *Renter) UploadReceipt(siapath string) (modules.RenterUploadReceipt, bool) {
+	id := r.mu.RLock()
+	defer r.mu.RUnlock(id)
+	receipt, exists := r.receipts[siapath]
+	return receipt, exists
+}
+
+// UploadReceipts returns the receipts of every file that has finished
+// uploading, sorted by siapath.
+func (r *Renter) UploadReceipts() []modules.RenterUploadReceipt {
+	id := r.mu.RLock()
+	defer r.mu.RUnlock(id)
+	names := make([]string, 0, len(r.receipts))
+	for name := range r.receipts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	receipts := make([]modules.RenterUploadReceipt, 0, len(names))
+	for _, name := range names {
+		receipts = append(receipts, r.receipts[name])
+	}
+	return receipts
+}
@@ -0,0 +1,114 @@
+package renter
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestUploadReceipts checks that a signed receipt is issued once for each
+// tracked file that finishes uploading, and that receipts are persisted.
+func TestUploadReceipts(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	var handled []modules.RenterUploadReceipt
+	rt.renter.AddUploadReceiptHandler(func(r modules.RenterUploadReceipt) {
+		handled = append(handled, r)
+	})
+
+	// Add a tracked file with one chunk of three pieces, of which only two
+	// have been uploaded, and an untracked file that is complete.
+	rsc, _ := NewRSCode(1, 2)
+	newFile := func(name string) *file {
+		return &file{
+			name:        name,
+			size:        100,
+			erasureCode: rsc,
+			pieceSize:   100,
+			masterKey:   crypto.GenerateTwofishKey(),
+			contracts:   make(map[types.FileContractID]fileContract),
+		}
+	}
+	tracked, loaded := newFile("tracked"), newFile("loaded")
+	for _, f := range []*file{tracked, loaded} {
+		f.contracts[types.FileContractID{1}] = fileContract{
+			ID:     types.FileContractID{1},
+			IP:     "host1:9982",
+			Pieces: []pieceData{{Chunk: 0, Piece: 2, MerkleRoot: crypto.Hash{2}}, {Chunk: 0, Piece: 0, MerkleRoot: crypto.Hash{0}}},
+		}
+	}
+	loaded.contracts[types.FileContractID{2}] = fileContract{
+		ID:     types.FileContractID{2},
+		IP:     "host2:9982",
+		Pieces: []pieceData{{Chunk: 0, Piece: 1, MerkleRoot: crypto.Hash{1}}},
+	}
+	id := rt.renter.mu.Lock()
+	rt.renter.files[tracked.name] = tracked
+	rt.renter.files[loaded.name] = loaded
+	rt.renter.tracking[tracked.name] = trackedFile{RepairPath: "/tmp/tracked"}
+	rt.renter.mu.Unlock(id)
+
+	rt.renter.managedIssueReceipts([]string{tracked.name, loaded.name})
+	if len(rt.renter.UploadReceipts()) != 0 || len(handled) != 0 {
+		t.Fatal("receipts were issued for an incomplete or untracked file")
+	}
+
+	// Finish uploading the tracked file.
+	tracked.contracts[types.FileContractID{2}] = fileContract{
+		ID:     types.FileContractID{2},
+		IP:     "host2:9982",
+		Pieces: []pieceData{{Chunk: 0, Piece: 1, MerkleRoot: crypto.Hash{1}}},
+	}
+	rt.renter.managedIssueReceipts([]string{tracked.name, tracked.name})
+	receipts := rt.renter.UploadReceipts()
+	if len(receipts) != 1 || len(handled) != 1 {
+		t.Fatal("expected one receipt, got", len(receipts), len(handled))
+	}
+	r := receipts[0]
+	if err := r.VerifySignature(); err != nil {
+		t.Fatal(err)
+	}
+	if r.SiaPath != "tracked" || r.Filesize != 100 || r.Height != rt.cs.Height() {
+		t.Fatal("receipt has wrong metadata:", r)
+	}
+	if len(r.Pieces) != 3 || r.Pieces[1].Piece != 1 || r.Pieces[1].MerkleRoot != (crypto.Hash{1}) || r.Pieces[1].Host != "host2:9982" || r.Pieces[1].ContractID != (types.FileContractID{2}) {
+		t.Fatal("receipt has wrong pieces:", r.Pieces)
+	}
+	if string(r.PublicKey.Key) != string(rt.renter.Manifest().PublicKey.Key) {
+		t.Fatal("receipt was not signed with the manifest key")
+	}
+	r.Pieces = append([]modules.RenterUploadReceiptPiece(nil), r.Pieces...)
+	r.Pieces[0].MerkleRoot[0]++
+	if err := r.VerifySignature(); err != modules.ErrInvalidReceiptSignature {
+		t.Fatal("expected ErrInvalidReceiptSignature, got", err)
+	}
+
+	// Receipts follow renames, are reloaded from disk, and are removed along
+	// with their file.
+	if err := rt.renter.RenameFile("tracked", "renamed"); err != nil {
+		t.Fatal(err)
+	}
+	rt.renter.receipts = nil
+	if err := rt.renter.loadReceipts(); err != nil {
+		t.Fatal(err)
+	}
+	r, exists := rt.renter.UploadReceipt("renamed")
+	if !exists || r.SiaPath != "tracked" || r.VerifySignature() != nil {
+		t.Fatal("receipt was not kept across a rename and reload:", r)
+	}
+	if err := rt.renter.DeleteFile("renamed"); err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := rt.renter.UploadReceipt("renamed"); exists {
+		t.Fatal("receipt was not removed along with its file")
+	}
+}
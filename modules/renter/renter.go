@@ -9,8 +9,10 @@ package renter
 
 import (
 	"errors"
+	"time"
 
 	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/modules/renter/contractor"
 	"github.com/NebulousLabs/Sia/modules/renter/hostdb"
@@ -86,6 +88,10 @@ type hostContractor interface {
 	// Contract returns the latest contract formed with the specified host.
 	Contract(modules.NetAddress) (modules.RenterContract, bool)
 
+	// ContractFormationReport describes the most recent attempt to form
+	// contracts.
+	ContractFormationReport() modules.ContractFormationReport
+
 	// Contracts returns the contracts formed by the contractor.
 	Contracts() []modules.RenterContract
 
@@ -93,10 +99,19 @@ type hostContractor interface {
 	// began.
 	CurrentPeriod() types.BlockHeight
 
+	// FormContracts forms contracts with the specified hosts, in addition to
+	// the contracts of the allowance.
+	FormContracts([]types.SiaPublicKey) ([]modules.RenterContract, error)
+
 	// Editor creates an Editor from the specified contract ID, allowing the
 	// insertion, deletion, and modification of sectors.
 	Editor(types.FileContractID, <-chan struct{}) (contractor.Editor, error)
 
+	// Handoff asks the host of the first contract to hand off the
+	// contract's sectors to the host of the second contract, and attaches
+	// them to the second contract.
+	Handoff(types.FileContractID, types.FileContractID) (modules.HandoffRecord, error)
+
 	// IsOffline reports whether the specified host is considered offline.
 	IsOffline(types.FileContractID) bool
 
@@ -104,6 +119,10 @@ type hostContractor interface {
 	// allowing the retrieval of sectors.
 	Downloader(types.FileContractID, <-chan struct{}) (contractor.Downloader, error)
 
+	// PauseFormation prevents the formation of new contracts until the
+	// provided time. Passing the zero time lifts the pause.
+	PauseFormation(time.Time)
+
 	// ResolveID returns the most recent renewal of the specified ID.
 	ResolveID(types.FileContractID) types.FileContractID
 }
@@ -124,8 +143,13 @@ type Renter struct {
 	//
 	// tracking contains a list of files that the user intends to maintain. By
 	// default, files loaded through sharing are not maintained by the user.
-	files    map[string]*file
-	tracking map[string]trackedFile // map from nickname to metadata
+	//
+	// pendingRename is the rename that is in progress, if any. It is
+	// persisted before the renamed .sia file is written, so that a rename
+	// interrupted by a crash is completed when the renter is next loaded.
+	files         map[string]*file
+	tracking      map[string]trackedFile // map from nickname to metadata
+	pendingRename *pendingRename
 
 	// Work management.
 	//
@@ -135,11 +159,35 @@ type Renter struct {
 	//
 	// downloadQueue contains a complete history of work that has been
 	// submitted to the download loop.
-	chunkQueue    []*chunkDownload // Accessed without locks.
-	downloadQueue []*download
-	newDownloads  chan *download
-	newRepairs    chan *file
-	workerPool    map[types.FileContractID]*worker
+	//
+	// newStreamChunks receives the chunks of streamed uploads, which are
+	// held in memory by the repair loop until they have been uploaded.
+	chunkQueue      []*chunkDownload // Accessed without locks.
+	downloadQueue   []*download
+	newDownloads    chan *download
+	newRepairs      chan *file
+	newStreamChunks chan *streamChunk
+	workerPool      map[types.FileContractID]*worker
+
+	// Pause management.
+	//
+	// pauseChan is closed when renter activity resumes, and is nil while the
+	// renter is not paused. pauseTimer resumes activity once pausedUntil has
+	// been reached.
+	pauseChan   chan struct{}
+	pauseTimer  *time.Timer
+	pausedUntil time.Time
+
+	// manifestKey signs the manifests returned by Manifest. It is generated
+	// when the renter is first created and persisted alongside the renter's
+	// other metadata.
+	manifestKey crypto.SecretKey
+
+	// receipts holds the signed receipt of each file that has finished
+	// uploading, keyed by siapath. receiptHandlers are called with each new
+	// receipt.
+	receipts        map[string]modules.RenterUploadReceipt
+	receiptHandlers []func(modules.RenterUploadReceipt)
 
 	// Utilities.
 	cs             modules.ConsensusSet
@@ -183,9 +231,11 @@ func newRenter(cs modules.ConsensusSet, tpool modules.TransactionPool, hdb hostD
 	}
 
 	r := &Renter{
-		newRepairs: make(chan *file),
-		files:      make(map[string]*file),
-		tracking:   make(map[string]trackedFile),
+		newRepairs:      make(chan *file),
+		newStreamChunks: make(chan *streamChunk),
+		files:           make(map[string]*file),
+		tracking:        make(map[string]trackedFile),
+		receipts:        make(map[string]modules.RenterUploadReceipt),
 
 		newDownloads: make(chan *download),
 		workerPool:   make(map[types.FileContractID]*worker),
@@ -214,6 +264,9 @@ func newRenter(cs modules.ConsensusSet, tpool modules.TransactionPool, hdb hostD
 		for _, worker := range r.workerPool {
 			close(worker.killChan)
 		}
+		if r.pauseTimer != nil {
+			r.pauseTimer.Stop()
+		}
 		r.mu.RUnlock(id)
 	})
 
@@ -307,7 +360,10 @@ func (r *Renter) ScoreBreakdown(e modules.HostDBEntry) modules.HostScoreBreakdow
 
 // contractor passthroughs
 func (r *Renter) Contracts() []modules.RenterContract { return r.hostContractor.Contracts() }
-func (r *Renter) CurrentPeriod() types.BlockHeight    { return r.hostContractor.CurrentPeriod() }
+func (r *Renter) ContractFormationReport() modules.ContractFormationReport {
+	return r.hostContractor.ContractFormationReport()
+}
+func (r *Renter) CurrentPeriod() types.BlockHeight { return r.hostContractor.CurrentPeriod() }
 func (r *Renter) Settings() modules.RenterSettings {
 	return modules.RenterSettings{
 		Allowance: r.hostContractor.Allowance(),
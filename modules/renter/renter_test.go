@@ -2,6 +2,7 @@ package renter
 
 import (
 	"path/filepath"
+	"time"
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
@@ -182,3 +183,4 @@ func (stubContractor) Editor(types.FileContractID) (contractor.Editor, error) {
 func (stubContractor) Downloader(types.FileContractID) (contractor.Downloader, error) {
 	return nil, nil
 }
+func (stubContractor) PauseFormation(time.Time) {}
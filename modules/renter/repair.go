@@ -38,11 +38,18 @@ type (
 		//
 		// recordedGaps indicates the value that this chunk has recorded in the
 		// gapCounts map.
+		//
+		// data and done are only set for the chunks of streamed uploads. data
+		// holds the chunk, as it cannot be read from disk, and done is closed
+		// once the chunk leaves the repair state.
 		activePieces int
 		contracts    map[types.FileContractID]struct{}
 		pieces       map[uint64]struct{}
 		recordedGaps int
 		totalPieces  int
+
+		data []byte
+		done chan struct{}
 	}
 
 	// chunkID can be used to uniquely identify a chunk within the repair
@@ -180,6 +187,27 @@ func (r *Renter) addFileToRepairState(rs *repairState, file *file) {
 // scanning all of the files for missing pieces and attempting repair them by
 // uploading to chunks.
 func (r *Renter) managedRepairIteration(rs *repairState) {
+	// While the renter is paused, only collect the results of uploads that
+	// are already in progress. Once those have returned, block until the
+	// renter resumes, continuing to accept newly queued files.
+	if pauseChan := r.managedPauseChan(); pauseChan != nil {
+		if len(rs.activeWorkers) > 0 {
+			r.managedWaitOnRepairWork(rs)
+			return
+		}
+		select {
+		case <-r.tg.StopChan():
+		case <-pauseChan:
+		case file := <-r.newRepairs:
+			id := r.mu.Lock()
+			r.addFileToRepairState(rs, file)
+			r.mu.Unlock(id)
+		case sc := <-r.newStreamChunks:
+			r.addStreamChunkToRepairState(rs, sc)
+		}
+		return
+	}
+
 	// Wait for work if there is nothing to do.
 	if len(rs.activeWorkers) == 0 && len(rs.incompleteChunks) == 0 {
 		select {
@@ -191,6 +219,9 @@ func (r *Renter) managedRepairIteration(rs *repairState) {
 			r.addFileToRepairState(rs, file)
 			r.mu.Unlock(id)
 			return
+		case sc := <-r.newStreamChunks:
+			r.addStreamChunkToRepairState(rs, sc)
+			return
 		}
 	}
 
@@ -237,6 +268,7 @@ func (r *Renter) managedRepairIteration(rs *repairState) {
 
 	// Scan through the chunks until a candidate for uploads is found.
 	var chunksToDelete []chunkID
+	var completedFiles []string
 	for chunkID, chunkStatus := range rs.incompleteChunks {
 		// Update the number of gaps for this chunk.
 		numGaps := chunkStatus.numGaps(rs)
@@ -248,6 +280,7 @@ func (r *Renter) managedRepairIteration(rs *repairState) {
 		// completed and there are no workers still working on it.
 		if numGaps == 0 && chunkStatus.activePieces == 0 {
 			chunksToDelete = append(chunksToDelete, chunkID)
+			completedFiles = append(completedFiles, chunkID.filename)
 			continue
 		}
 
@@ -287,9 +320,17 @@ func (r *Renter) managedRepairIteration(rs *repairState) {
 		}
 	}
 	for _, cid := range chunksToDelete {
+		if done := rs.incompleteChunks[cid].done; done != nil {
+			close(done)
+		}
 		delete(rs.incompleteChunks, cid)
 	}
 
+	// Issue receipts for files that may have finished uploading.
+	if len(completedFiles) > 0 {
+		r.managedIssueReceipts(completedFiles)
+	}
+
 	// Block until some of the workers return.
 	r.managedWaitOnRepairWork(rs)
 }
@@ -297,34 +338,40 @@ func (r *Renter) managedRepairIteration(rs *repairState) {
 // managedScheduleChunkRepair takes a chunk and schedules some repair on that
 // chunk using the chunk state and a list of workers.
 func (r *Renter) managedScheduleChunkRepair(rs *repairState, chunkID chunkID, chunkStatus *chunkStatus, usefulWorkers []types.FileContractID) error {
-	// Check that the file is still in the renter.
+	// Check that the file is still in the renter. The chunks of streamed
+	// uploads are held in memory, so their files are not tracked.
 	filename := chunkID.filename
 	id := r.mu.RLock()
 	file, exists1 := r.files[filename]
 	meta, exists2 := r.tracking[filename]
 	r.mu.RUnlock(id)
-	if !exists1 || !exists2 {
+	if !exists1 || (!exists2 && chunkStatus.data == nil) {
 		return errFileDeleted
 	}
 
 	// Read the file data into memory.
 	chunkIndex := chunkID.index
-	fHandle, err := os.Open(meta.RepairPath)
-	if err != nil {
-		// TODO: Perform a download-and-repair instead of returning this error.
-		return build.ExtendErr("unable to open file to repair chunk", err)
-	}
-	defer fHandle.Close()
-	chunkData := make([]byte, file.chunkSize())
-	_, err = fHandle.ReadAt(chunkData, int64(chunkIndex*file.chunkSize()))
-	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
-		// TODO: We should be doing better error handling here - shouldn't be
-		// running into ErrUnexpectedEOF intentionally because if it happens
-		// unintentionally we will believe that the chunk was read from memory
-		// correctly.
-
-		// TODO: Perform a download-and-repair instead of returning this error.
-		return build.ExtendErr("unable to read file to repair chunk", err)
+	chunkData := chunkStatus.data
+	if chunkData == nil {
+		fHandle, err := os.Open(meta.RepairPath)
+		if err != nil {
+			// TODO: Perform a download-and-repair instead of returning this
+			// error.
+			return build.ExtendErr("unable to open file to repair chunk", err)
+		}
+		defer fHandle.Close()
+		chunkData = make([]byte, file.chunkSize())
+		_, err = fHandle.ReadAt(chunkData, int64(chunkIndex*file.chunkSize()))
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			// TODO: We should be doing better error handling here - shouldn't
+			// be running into ErrUnexpectedEOF intentionally because if it
+			// happens unintentionally we will believe that the chunk was read
+			// from memory correctly.
+
+			// TODO: Perform a download-and-repair instead of returning this
+			// error.
+			return build.ExtendErr("unable to read file to repair chunk", err)
+		}
 	}
 
 	// Erasure code the pieces.
@@ -410,6 +457,9 @@ func (r *Renter) managedWaitOnRepairWork(rs *repairState) {
 		r.addFileToRepairState(rs, file)
 		r.mu.Unlock(id)
 		return
+	case sc := <-r.newStreamChunks:
+		r.addStreamChunkToRepairState(rs, sc)
+		return
 	case <-r.tg.StopChan():
 		return
 	}
@@ -0,0 +1,126 @@
+package renter
+
+import (
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// The contracts of a file belong to the renter that uploaded it, so a renter
+// that loads a shared file cannot use them. Instead, .sia files shared with
+// other renters list the public key of the host of each contract. The
+// importing renter points the contracts at the addresses of its own contracts
+// with the same hosts, and forms contracts with the hosts it has no contract
+// with. The data is then downloaded through the importing renter's own
+// contracts, which downloads find by address. The contracts keep their
+// original IDs and end heights, as the sectors stay in the uploader's
+// contracts and expire with them.
+
+// A sharedHost identifies the host of one of the contracts of a shared file.
+type sharedHost struct {
+	ContractID types.FileContractID
+	PublicKey  types.SiaPublicKey
+}
+
+// sharedHosts returns the hosts of the contracts of each file. Hosts are
+// found through the renter's contracts, or through the hostdb if the contract
+// has expired. Contracts whose host cannot be found are left out.
+func (r *Renter) sharedHosts(files []*file) [][]sharedHost {
+	byID := make(map[types.FileContractID]types.SiaPublicKey)
+	byAddress := make(map[modules.NetAddress]types.SiaPublicKey)
+	if r.hostDB != nil {
+		for _, host := range r.hostDB.AllHosts() {
+			byAddress[host.NetAddress] = host.PublicKey
+		}
+	}
+	for _, contract := range r.hostContractor.Contracts() {
+		byID[contract.ID] = contract.HostPublicKey
+		byAddress[contract.NetAddress] = contract.HostPublicKey
+	}
+
+	hosts := make([][]sharedHost, len(files))
+	for i, f := range files {
+		hosts[i] = []sharedHost{}
+		f.mu.RLock()
+		for id, fc := range f.contracts {
+			pk, exists := byID[r.hostContractor.ResolveID(id)]
+			if !exists {
+				pk, exists = byAddress[fc.IP]
+			}
+			if exists {
+				hosts[i] = append(hosts[i], sharedHost{ContractID: id, PublicKey: pk})
+			}
+		}
+		f.mu.RUnlock()
+	}
+	return hosts
+}
+
+// repointSharedContracts points the contracts of a shared file at the
+// addresses of the renter's own contracts with the same hosts. The pieces are
+// not moved into the renter's contracts, which do not hold their sectors.
+// Contracts with hosts that the renter has no contract with are left
+// unchanged.
+func (r *Renter) repointSharedContracts(f *file, hosts []sharedHost) {
+	contracts := make(map[string]modules.RenterContract)
+	for _, contract := range r.hostContractor.Contracts() {
+		contracts[contract.HostPublicKey.String()] = contract
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, host := range hosts {
+		fc, exists := f.contracts[host.ContractID]
+		if !exists {
+			continue
+		}
+		contract, exists := contracts[host.PublicKey.String()]
+		if !exists || contract.ID == host.ContractID {
+			continue
+		}
+		fc.IP = contract.NetAddress
+		f.contracts[host.ContractID] = fc
+	}
+}
+
+// managedFormSharedContracts forms contracts with the hosts of the shared
+// files that the renter has no contract with, and points the files at the new
+// contracts. Failures are logged, as the files can still be downloaded from
+// the hosts that the renter does have contracts with.
+func (r *Renter) managedFormSharedContracts(shared map[string][]sharedHost) {
+	existing := make(map[string]struct{})
+	for _, contract := range r.hostContractor.Contracts() {
+		existing[contract.HostPublicKey.String()] = struct{}{}
+	}
+	var missing []types.SiaPublicKey
+	for _, hosts := range shared {
+		for _, host := range hosts {
+			if _, exists := existing[host.PublicKey.String()]; !exists {
+				existing[host.PublicKey.String()] = struct{}{}
+				missing = append(missing, host.PublicKey)
+			}
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+
+	formed, err := r.hostContractor.FormContracts(missing)
+	if err != nil {
+		r.log.Println("WARN: unable to form contracts with the hosts of shared files:", err)
+	}
+	if len(formed) == 0 {
+		return
+	}
+	id := r.mu.Lock()
+	defer r.mu.Unlock(id)
+	for name, hosts := range shared {
+		f, exists := r.files[name]
+		if !exists {
+			continue
+		}
+		r.repointSharedContracts(f, hosts)
+		if err := r.saveFile(f); err != nil {
+			r.log.Println("ERROR: unable to save shared file:", err)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package renter
+
+import (
+	"errors"
+	"io"
+
+	"github.com/NebulousLabs/Sia/build"
+)
+
+var (
+	// errInvalidWhence is returned by Seek if the whence is not one of the
+	// io.Seek constants.
+	errInvalidWhence = errors.New("invalid whence")
+
+	// errNegativeOffset is returned by Seek if the resulting offset would be
+	// before the start of the file.
+	errNegativeOffset = errors.New("cannot seek to a negative offset")
+)
+
+type (
+	// chunkBuffer is a destination for a download that holds a single chunk
+	// in memory instead of writing it to disk.
+	chunkBuffer struct {
+		offset int64
+		data   []byte
+	}
+
+	// streamer is an io.ReadSeeker over a renter file. Chunks are only
+	// downloaded once they are read, and the most recently downloaded chunk
+	// is kept in memory so that small sequential reads do not fetch the same
+	// chunk repeatedly.
+	streamer struct {
+		file   *file
+		offset int64
+		r      *Renter
+
+		chunk      []byte
+		chunkIndex uint64
+		chunkValid bool
+	}
+)
+
+// WriteAt writes b to the buffer at the file offset off, growing the buffer as
+// needed.
+func (cb *chunkBuffer) WriteAt(b []byte, off int64) (int, error) {
+	if off < cb.offset {
+		return 0, errNegativeOffset
+	}
+	end := off - cb.offset + int64(len(b))
+	if end > int64(len(cb.data)) {
+		cb.data = append(cb.data, make([]byte, end-int64(len(cb.data)))...)
+	}
+	return copy(cb.data[off-cb.offset:], b), nil
+}
+
+// managedDownloadChunk downloads the chunk of f at index into memory. The
+// chunk goes through the download loop like any other download, but is not
+// added to the download queue.
+func (r *Renter) managedDownloadChunk(f *file, index uint64) ([]byte, error) {
+	d := r.newDownload(f, "", r.currentContracts())
+	buf := &chunkBuffer{offset: int64(index * d.chunkSize)}
+	d.destinationWriter = buf
+	for i := range d.finishedChunks {
+		d.finishedChunks[i] = uint64(i) != index
+	}
+	r.newDownloads <- d
+
+	select {
+	case <-d.downloadFinished:
+		if err := d.Err(); err != nil {
+			return nil, err
+		}
+		return buf.data, nil
+	case <-r.tg.StopChan():
+		return nil, errors.New("download interrupted by shutdown")
+	}
+}
+
+// Read reads up to len(p) bytes of the file, downloading the chunk that holds
+// the current offset if it is not already in memory.
+func (s *streamer) Read(p []byte) (int, error) {
+	if s.offset >= int64(s.file.size) {
+		return 0, io.EOF
+	}
+
+	chunkSize := s.file.chunkSize()
+	index := uint64(s.offset) / chunkSize
+	if !s.chunkValid || s.chunkIndex != index {
+		chunk, err := s.r.managedDownloadChunk(s.file, index)
+		if err != nil {
+			return 0, build.ExtendErr("unable to download chunk", err)
+		}
+		s.chunk = chunk
+		s.chunkIndex = index
+		s.chunkValid = true
+	}
+
+	chunkOffset := uint64(s.offset) - index*chunkSize
+	if chunkOffset >= uint64(len(s.chunk)) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	n := copy(p, s.chunk[chunkOffset:])
+	s.offset += int64(n)
+	return n, nil
+}
+
+// Seek sets the offset for the next Read, following the semantics of
+// io.Seeker.
+func (s *streamer) Seek(offset int64, whence int) (int64, error) {
+	var newOffset int64
+	switch whence {
+	case io.SeekStart:
+		newOffset = offset
+	case io.SeekCurrent:
+		newOffset = s.offset + offset
+	case io.SeekEnd:
+		newOffset = int64(s.file.size) + offset
+	default:
+		return 0, errInvalidWhence
+	}
+	if newOffset < 0 {
+		return 0, errNegativeOffset
+	}
+	s.offset = newOffset
+	return newOffset, nil
+}
+
+// DownloadStreamer returns an io.ReadSeeker over the file at siapath. Chunks
+// are downloaded as they are read, so the file never has to be written to
+// disk.
+func (r *Renter) DownloadStreamer(siapath string) (io.ReadSeeker, error) {
+	lockID := r.mu.RLock()
+	file, exists := r.files[siapath]
+	r.mu.RUnlock(lockID)
+	if !exists {
+		return nil, errors.New("no file with that path")
+	}
+	return &streamer{
+		file: file,
+		r:    r,
+	}, nil
+}
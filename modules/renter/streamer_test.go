@@ -0,0 +1,58 @@
+package renter
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// TestStreamerSeek probes the Seek method of the streamer type.
+func TestStreamerSeek(t *testing.T) {
+	rsc, _ := NewRSCode(1, 1)
+	s := &streamer{file: &file{size: 100, erasureCode: rsc, pieceSize: 10}}
+
+	tests := []struct {
+		offset    int64
+		whence    int
+		expOffset int64
+		expErr    error
+	}{
+		{10, io.SeekStart, 10, nil},
+		{5, io.SeekCurrent, 15, nil},
+		{-5, io.SeekEnd, 95, nil},
+		{10, io.SeekEnd, 110, nil}, // past the end is allowed
+		{-200, io.SeekCurrent, 0, errNegativeOffset},
+		{0, 7, 0, errInvalidWhence},
+	}
+	for _, test := range tests {
+		offset, err := s.Seek(test.offset, test.whence)
+		if err != test.expErr {
+			t.Errorf("Seek(%v, %v): expected error %v, got %v", test.offset, test.whence, test.expErr, err)
+		} else if err == nil && offset != test.expOffset {
+			t.Errorf("Seek(%v, %v): expected offset %v, got %v", test.offset, test.whence, test.expOffset, offset)
+		}
+	}
+
+	// Reads past the end of the file return io.EOF without downloading.
+	if _, err := s.Read(make([]byte, 10)); err != io.EOF {
+		t.Fatal("expected io.EOF, got", err)
+	}
+}
+
+// TestChunkBufferWriteAt checks that the chunkBuffer type translates file
+// offsets into offsets within the chunk.
+func TestChunkBufferWriteAt(t *testing.T) {
+	cb := &chunkBuffer{offset: 100}
+	if _, err := cb.WriteAt([]byte{1, 2, 3}, 99); err == nil {
+		t.Fatal("expected an error when writing before the chunk")
+	}
+	if _, err := cb.WriteAt([]byte{4, 5}, 102); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cb.WriteAt([]byte{1, 2}, 100); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(cb.data, []byte{1, 2, 4, 5}) {
+		t.Fatal("wrong chunk contents:", cb.data)
+	}
+}
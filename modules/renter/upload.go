@@ -68,9 +68,10 @@ func validateSiapath(siapath string) error {
 	return nil
 }
 
-// Upload instructs the renter to start tracking a file. The renter will
-// automatically upload and repair tracked files using a background loop.
-func (r *Renter) Upload(up modules.FileUploadParams) error {
+// managedCheckUploadParams checks the siapath of an upload and that there are
+// enough contracts to upload to, filling in the default erasure code if none
+// was provided.
+func (r *Renter) managedCheckUploadParams(up *modules.FileUploadParams) error {
 	// Enforce nickname rules.
 	if err := validateSiapath(up.SiaPath); err != nil {
 		return err
@@ -85,10 +86,6 @@ func (r *Renter) Upload(up modules.FileUploadParams) error {
 	}
 
 	// Fill in any missing upload params with sensible defaults.
-	fileInfo, err := os.Stat(up.Source)
-	if err != nil {
-		return err
-	}
 	if up.ErasureCode == nil {
 		up.ErasureCode, _ = NewRSCode(defaultDataPieces, defaultParityPieces)
 	}
@@ -99,13 +96,26 @@ func (r *Renter) Upload(up modules.FileUploadParams) error {
 	if nContracts := len(r.hostContractor.Contracts()); nContracts < (up.ErasureCode.NumPieces()+up.ErasureCode.MinPieces())/2 && build.Release != "testing" {
 		return fmt.Errorf("not enough contracts to upload file: got %v, needed %v", nContracts, (up.ErasureCode.NumPieces()+up.ErasureCode.MinPieces())/2)
 	}
+	return nil
+}
+
+// Upload instructs the renter to start tracking a file. The renter will
+// automatically upload and repair tracked files using a background loop.
+func (r *Renter) Upload(up modules.FileUploadParams) error {
+	if err := r.managedCheckUploadParams(&up); err != nil {
+		return err
+	}
+	fileInfo, err := os.Stat(up.Source)
+	if err != nil {
+		return err
+	}
 
 	// Create file object.
 	f := newFile(up.SiaPath, up.ErasureCode, pieceSize, uint64(fileInfo.Size()))
 	f.mode = uint32(fileInfo.Mode())
 
 	// Add file to renter.
-	lockID = r.mu.Lock()
+	lockID := r.mu.Lock()
 	r.files[up.SiaPath] = f
 	r.tracking[up.SiaPath] = trackedFile{
 		RepairPath: up.Source,
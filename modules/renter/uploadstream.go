@@ -0,0 +1,154 @@
+package renter
+
+import (
+	"errors"
+	"io"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// A streamed upload has no file on disk that the repair loop can read chunks
+// from. Instead, each chunk is read from the stream into memory and handed to
+// the repair loop, which keeps it until the chunk has been uploaded to as many
+// hosts as possible. Only one chunk is held in memory at a time. As there is
+// no source to repair from, streamed files are not tracked, like files loaded
+// from a .sia file.
+
+var (
+	// errStreamInterrupted is returned if the renter shuts down during a
+	// streamed upload.
+	errStreamInterrupted = errors.New("upload interrupted by shutdown")
+)
+
+// streamChunk is a chunk of a streamed upload that is sent to the repair loop.
+type streamChunk struct {
+	data  []byte
+	file  *file
+	index uint64
+
+	// done is closed once the repair loop has finished uploading the chunk.
+	done chan struct{}
+}
+
+// addStreamChunkToRepairState adds a chunk of a streamed upload to the set of
+// incomplete chunks of the repair state.
+func (r *Renter) addStreamChunkToRepairState(rs *repairState, sc *streamChunk) {
+	cid := chunkID{sc.index, sc.file.name}
+	if _, exists := rs.incompleteChunks[cid]; exists {
+		r.log.Critical("streamed chunk is already in the repair state:", cid)
+		close(sc.done)
+		return
+	}
+	cs := &chunkStatus{
+		contracts:   make(map[types.FileContractID]struct{}),
+		pieces:      make(map[uint64]struct{}),
+		totalPieces: sc.file.erasureCode.NumPieces(),
+
+		data: sc.data,
+		done: sc.done,
+	}
+	cs.recordedGaps = cs.numGaps(rs)
+	rs.incompleteChunks[cid] = cs
+	rs.gapCounts[cs.recordedGaps]++
+}
+
+// managedUploadedPieces returns the number of distinct pieces of the chunk at
+// index that have been uploaded.
+func (f *file) managedUploadedPieces(index uint64) int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	pieces := make(map[uint64]struct{})
+	for _, contract := range f.contracts {
+		for _, piece := range contract.Pieces {
+			if piece.Chunk == index {
+				pieces[piece.Piece] = struct{}{}
+			}
+		}
+	}
+	return len(pieces)
+}
+
+// managedUploadStreamChunks reads the stream one chunk at a time, waiting for
+// each chunk to be uploaded before reading the next.
+func (r *Renter) managedUploadStreamChunks(f *file, reader io.Reader) error {
+	for index := uint64(0); ; index++ {
+		// Read the next chunk. The last chunk is padded with zeros, and an
+		// empty stream still uploads a single chunk, like an empty file.
+		data := make([]byte, f.chunkSize())
+		n, err := io.ReadFull(reader, data)
+		if err == io.EOF && index > 0 {
+			return nil
+		} else if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return build.ExtendErr("unable to read from stream", err)
+		}
+		last := err != nil
+		f.mu.Lock()
+		f.size += uint64(n)
+		f.mu.Unlock()
+
+		// Hand the chunk to the repair loop and wait for it to be uploaded.
+		sc := &streamChunk{
+			data:  data,
+			file:  f,
+			index: index,
+			done:  make(chan struct{}),
+		}
+		select {
+		case r.newStreamChunks <- sc:
+		case <-r.tg.StopChan():
+			return errStreamInterrupted
+		}
+		select {
+		case <-sc.done:
+		case <-r.tg.StopChan():
+			return errStreamInterrupted
+		}
+		if f.managedUploadedPieces(index) < f.erasureCode.MinPieces() {
+			return errInsufficientContracts
+		}
+		if last {
+			return nil
+		}
+	}
+}
+
+// UploadStream uploads the data read from reader to the siapath of up. The
+// data is erasure coded and uploaded as it arrives, and the call blocks until
+// the stream ends and every chunk has been uploaded. The Source of up is
+// ignored. If the upload fails, the partially uploaded file is deleted.
+func (r *Renter) UploadStream(up modules.FileUploadParams, reader io.Reader) error {
+	if err := r.managedCheckUploadParams(&up); err != nil {
+		return err
+	}
+
+	// Create the file and add it to the renter, reserving the siapath. The
+	// size of the file grows as chunks are read from the stream.
+	f := newFile(up.SiaPath, up.ErasureCode, pieceSize, 0)
+	f.mode = defaultFilePerm
+	lockID := r.mu.Lock()
+	if _, exists := r.files[up.SiaPath]; exists {
+		r.mu.Unlock(lockID)
+		return ErrPathOverload
+	}
+	r.files[up.SiaPath] = f
+	r.saveSync()
+	err := r.saveFile(f)
+	r.mu.Unlock(lockID)
+	if err != nil {
+		return err
+	}
+
+	err = r.managedUploadStreamChunks(f, reader)
+	if err != nil {
+		r.log.Println("WARN: streamed upload failed:", up.SiaPath, err)
+		return build.ComposeErrors(err, r.DeleteFile(up.SiaPath))
+	}
+
+	// Save the final size of the file.
+	lockID = r.mu.Lock()
+	err = r.saveFile(f)
+	r.mu.Unlock(lockID)
+	return err
+}
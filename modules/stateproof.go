@@ -0,0 +1,179 @@
+package modules
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// A StateProof shows that an object does or does not exist in the consensus
+// set, as of a specific block, without the verifier having to hold the
+// consensus set itself. It is anchored to the consensus checksum, which is
+// the Merkle root of every key and value in the consensus database, pushed
+// into the tree bucket by bucket in sorted order. Keys and values occupy
+// alternating leaves.
+//
+// An object exists if the proof contains the leaf holding its ID, followed by
+// the leaf holding its encoded value. An object does not exist if the proof
+// contains the two adjacent keys that its ID would sit between, or the first
+// or last key of the bucket if the ID would sit at either end of it.
+//
+// The checksum does not commit to where each bucket begins and ends, so
+// BucketStart and BucketLeaves are reported by the node that produced the
+// proof. A verifier should get the checksum for a block from several nodes,
+// and treat a proof of non-existence with the same caution.
+
+const (
+	// StateProofSiacoinOutput, StateProofSiafundOutput, and
+	// StateProofFileContract are the kinds of objects that a StateProof can
+	// be produced for.
+	StateProofSiacoinOutput = "siacoinoutput"
+	StateProofSiafundOutput = "siafundoutput"
+	StateProofFileContract  = "filecontract"
+)
+
+var (
+	// ErrInvalidStateProof is returned when a StateProof does not prove what
+	// it claims to prove.
+	ErrInvalidStateProof = errors.New("state proof is invalid")
+
+	// ErrUnknownStateProofKind is returned when a StateProof is requested
+	// for a kind of object that proofs cannot be produced for.
+	ErrUnknownStateProofKind = errors.New("unknown state proof kind")
+)
+
+type (
+	// A StateProofLeaf is a leaf of the consensus checksum tree, along with
+	// the hashes that prove its place in the tree.
+	StateProofLeaf struct {
+		Index   uint64        `json:"index"`
+		Data    []byte        `json:"data"`
+		HashSet []crypto.Hash `json:"hashset"`
+	}
+
+	// A StateProof proves that the object with the given kind and ID does or
+	// does not exist in the consensus set at the block with ID BlockID.
+	StateProof struct {
+		Kind   string      `json:"kind"`
+		ID     crypto.Hash `json:"id"`
+		Exists bool        `json:"exists"`
+
+		Height    types.BlockHeight `json:"height"`
+		BlockID   types.BlockID     `json:"blockid"`
+		Checksum  crypto.Hash       `json:"checksum"`
+		NumLeaves uint64            `json:"numleaves"`
+
+		BucketStart  uint64           `json:"bucketstart"`
+		BucketLeaves uint64           `json:"bucketleaves"`
+		Leaves       []StateProofLeaf `json:"leaves"`
+	}
+)
+
+// stateProofObject returns an empty object of the kind that a StateProof of
+// the given kind proves.
+func stateProofObject(kind string) (interface{}, error) {
+	switch kind {
+	case StateProofSiacoinOutput:
+		return new(types.SiacoinOutput), nil
+	case StateProofSiafundOutput:
+		return new(types.SiafundOutput), nil
+	case StateProofFileContract:
+		return new(types.FileContract), nil
+	}
+	return nil, ErrUnknownStateProofKind
+}
+
+// Object decodes the object that the proof shows to exist into obj, which
+// should be a *types.SiacoinOutput, *types.SiafundOutput, or
+// *types.FileContract, matching the kind of the proof.
+func (sp StateProof) Object(obj interface{}) error {
+	if !sp.Exists || len(sp.Leaves) != 2 {
+		return ErrInvalidStateProof
+	}
+	return encoding.Unmarshal(sp.Leaves[1].Data, obj)
+}
+
+// verifyLeaf checks that leaf is a part of the proof's checksum.
+func (sp StateProof) verifyLeaf(leaf StateProofLeaf) bool {
+	return crypto.VerifySegment(leaf.Data, leaf.HashSet, sp.NumLeaves, leaf.Index, sp.Checksum)
+}
+
+// isKey reports whether the leaf at index holds a key of the proof's bucket.
+func (sp StateProof) isKey(index uint64) bool {
+	return index >= sp.BucketStart && index < sp.BucketStart+sp.BucketLeaves && (index-sp.BucketStart)%2 == 0
+}
+
+// Verify checks that the proof shows what it claims to, given that its
+// checksum is the consensus checksum at BlockID.
+func (sp StateProof) Verify() error {
+	obj, err := stateProofObject(sp.Kind)
+	if err != nil {
+		return err
+	}
+	if sp.BucketLeaves%2 != 0 || sp.BucketStart+sp.BucketLeaves > sp.NumLeaves {
+		return ErrInvalidStateProof
+	}
+	for _, leaf := range sp.Leaves {
+		if !sp.verifyLeaf(leaf) {
+			return ErrInvalidStateProof
+		}
+	}
+
+	if sp.Exists {
+		// The ID must be a key, followed by the value of the object.
+		if len(sp.Leaves) != 2 || !sp.isKey(sp.Leaves[0].Index) || sp.Leaves[1].Index != sp.Leaves[0].Index+1 {
+			return ErrInvalidStateProof
+		}
+		if !bytes.Equal(sp.Leaves[0].Data, sp.ID[:]) {
+			return ErrInvalidStateProof
+		}
+		if encoding.Unmarshal(sp.Leaves[1].Data, obj) != nil {
+			return ErrInvalidStateProof
+		}
+		return nil
+	}
+
+	// The ID must sit between the keys in the proof, which must be adjacent
+	// or at the ends of the bucket.
+	var prev, next *StateProofLeaf
+	switch len(sp.Leaves) {
+	case 0:
+		if sp.BucketLeaves != 0 {
+			return ErrInvalidStateProof
+		}
+		return nil
+	case 1:
+		if bytes.Compare(sp.Leaves[0].Data, sp.ID[:]) < 0 {
+			prev = &sp.Leaves[0]
+		} else {
+			next = &sp.Leaves[0]
+		}
+	case 2:
+		prev, next = &sp.Leaves[0], &sp.Leaves[1]
+	default:
+		return ErrInvalidStateProof
+	}
+	if prev != nil {
+		if !sp.isKey(prev.Index) || bytes.Compare(prev.Data, sp.ID[:]) >= 0 {
+			return ErrInvalidStateProof
+		}
+		if next == nil && prev.Index != sp.BucketStart+sp.BucketLeaves-2 {
+			return ErrInvalidStateProof
+		}
+	}
+	if next != nil {
+		if !sp.isKey(next.Index) || bytes.Compare(next.Data, sp.ID[:]) <= 0 {
+			return ErrInvalidStateProof
+		}
+		if prev == nil && next.Index != sp.BucketStart {
+			return ErrInvalidStateProof
+		}
+	}
+	if prev != nil && next != nil && next.Index != prev.Index+2 {
+		return ErrInvalidStateProof
+	}
+	return nil
+}
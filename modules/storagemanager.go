@@ -1,6 +1,8 @@
 package modules
 
 import (
+	"time"
+
 	"github.com/NebulousLabs/Sia/crypto"
 )
 
@@ -12,6 +14,16 @@ const (
 	// StorageManagerDir is standard name used for the directory that contains
 	// all of the storage manager files.
 	StorageManagerDir = "storagemanager"
+
+	// StorageFolderTierCapacity is the tier of storage folders on large,
+	// slower disks such as HDDs. Storage folders are in the capacity tier
+	// unless they are tagged otherwise.
+	StorageFolderTierCapacity = "capacity"
+
+	// StorageFolderTierFast is the tier of storage folders on fast disks such
+	// as SSDs. New sectors are placed in the fast tier while it has room, and
+	// sectors that go unread for a while are moved down to the capacity tier.
+	StorageFolderTierFast = "fast"
 )
 
 type (
@@ -40,6 +52,190 @@ type (
 		// folder. Progress is always reported in bytes.
 		ProgressNumerator   uint64
 		ProgressDenominator uint64
+
+		// WritePolicy limits how long writes to the folder may take and how
+		// often failed writes are retried. Stalled is true while a write to
+		// the folder has exceeded its timeout without returning, as happens
+		// when the server of a network filesystem stops responding. No new
+		// sectors are placed in a stalled folder, nor in a folder whose
+		// writes have failed until UnavailableUntil.
+		WritePolicy      StorageFolderWritePolicy `json:"writepolicy"`
+		Stalled          bool                     `json:"stalled"`
+		UnavailableUntil time.Time                `json:"unavailableuntil"`
+
+		// Health is the result of the most recent health check of the disk
+		// backing the folder.
+		Health StorageFolderHealth `json:"health"`
+
+		// Tier is the placement tier of the folder, either
+		// StorageFolderTierFast or StorageFolderTierCapacity.
+		Tier string `json:"tier"`
+
+		// Missing is true if the files of the folder could not be found at
+		// startup, for example because its disk was mounted elsewhere. The
+		// sectors of a missing folder cannot be read until the folder is
+		// moved to the new location of its files.
+		Missing bool `json:"missing"`
+	}
+
+	// StorageFolderHealth reports the health of the disk backing a storage
+	// folder, as read from the disk's SMART attributes and from the error
+	// counters of the folder. Alerts lists the reasons, if any, that the disk
+	// looks likely to fail; the host operator should move the data off of a
+	// disk with alerts.
+	StorageFolderHealth struct {
+		// Device is the block device that the folder is on, or empty if it
+		// could not be determined, as is the case for network filesystems.
+		Device      string    `json:"device"`
+		LastChecked time.Time `json:"lastchecked"`
+
+		// SMARTAvailable is false if the SMART attributes of the device could
+		// not be read, in which case the SMART fields are zero.
+		// SMARTAvailable requires smartctl to be installed and the host to
+		// have permission to query the device.
+		SMARTAvailable      bool   `json:"smartavailable"`
+		SMARTPassed         bool   `json:"smartpassed"`
+		ReallocatedSectors  uint64 `json:"reallocatedsectors"`
+		PendingSectors      uint64 `json:"pendingsectors"`
+		UncorrectableErrors uint64 `json:"uncorrectableerrors"`
+		Temperature         uint64 `json:"temperature"` // Celsius
+
+		Alerts []string `json:"alerts"`
+	}
+
+	// StorageFolderWritePolicy limits the time that the storage manager waits
+	// on writes to a storage folder, so that a folder on a stalled network
+	// mount does not hold up writes to healthy folders. A write that takes
+	// longer than WriteTimeout is abandoned and the sector is placed in
+	// another folder. A write that fails is retried up to Retries times,
+	// RetryDelay apart, before the folder is given up on. A WriteTimeout of
+	// zero means that writes are waited on indefinitely.
+	StorageFolderWritePolicy struct {
+		WriteTimeout time.Duration `json:"writetimeout"`
+		Retries      int           `json:"retries"`
+		RetryDelay   time.Duration `json:"retrydelay"`
+	}
+
+	// StorageFolderPreflight contains the results of checking whether a
+	// folder can safely be used as a storage folder. Folders on network
+	// filesystems such as NFS and SMB are supported, but are slower than
+	// local disks and can silently lose writes if the server does not honor
+	// fsync.
+	StorageFolderPreflight struct {
+		Path string `json:"path"`
+
+		// Filesystem is the type of the filesystem that the folder is on, as
+		// reported by the operating system, or "unknown" if it could not be
+		// determined. NetworkFilesystem is true if the filesystem is known to
+		// be backed by a remote server.
+		Filesystem        string `json:"filesystem"`
+		NetworkFilesystem bool   `json:"networkfilesystem"`
+
+		// WriteLatency and SyncLatency are the average times taken to write a
+		// block of test data to the folder and to fsync it.
+		WriteLatency time.Duration `json:"writelatency"`
+		SyncLatency  time.Duration `json:"synclatency"`
+
+		// WriteBarrier is true if every fsync succeeded and the synced data
+		// could be read back through an independent file handle. The read
+		// may be served from the page cache of the host, so a server that
+		// acknowledges fsync before storing the data is not detected; see
+		// the sync latency warnings instead. Folders that fail the check
+		// cannot be added.
+		WriteBarrier bool `json:"writebarrier"`
+
+		// InUse is true if the folder has been claimed by a different host.
+		// Two hosts sharing a folder on a network filesystem would overwrite
+		// each other's sectors.
+		InUse bool `json:"inuse"`
+
+		// Warnings describe conditions that do not prevent the folder from
+		// being added, but that the host operator should be aware of.
+		Warnings []string `json:"warnings"`
+	}
+
+	// StorageFolderScan reports the progress and results of an integrity scan
+	// of a storage folder. A scan reads every sector in the folder and checks
+	// the data against the Merkle root that the sector is stored under.
+	// Sectors that fail the check are counted as corrupt, and sectors that
+	// cannot be read at all are counted as unreadable. If the scan was asked
+	// to quarantine bad sectors, they are removed from the folder so that the
+	// host stops serving them.
+	StorageFolderScan struct {
+		Index uint16 `json:"index"`
+		Path  string `json:"path"`
+
+		// EndTime is the zero time while the scan is in progress.
+		StartTime time.Time `json:"starttime"`
+		EndTime   time.Time `json:"endtime"`
+
+		TotalSectors       uint64 `json:"totalsectors"`
+		ScannedSectors     uint64 `json:"scannedsectors"`
+		CorruptSectors     uint64 `json:"corruptsectors"`
+		UnreadableSectors  uint64 `json:"unreadablesectors"`
+		QuarantinedSectors uint64 `json:"quarantinedsectors"`
+	}
+
+	// SectorAccessStats contains aggregated statistics about how the sectors
+	// stored by the host have been read, to help host operators plan disk
+	// purchases and tiering. Nothing is reported about individual sectors or
+	// renters. The statistics are kept across restarts.
+	SectorAccessStats struct {
+		// Since is the time at which tracking started.
+		Since time.Time `json:"since"`
+
+		// ReadsPerDay contains the number of sector reads on each of the
+		// last 30 days, or on each day since tracking started, oldest first.
+		// The last entry is the current day.
+		ReadsPerDay        []uint64 `json:"readsperday"`
+		AverageReadsPerDay float64  `json:"averagereadsperday"`
+
+		// Sectors is the number of sectors stored by the host. HotSectors
+		// is the number of those sectors that were read within HotWindow,
+		// and HotFraction is HotSectors divided by Sectors.
+		Sectors     uint64        `json:"sectors"`
+		HotSectors  uint64        `json:"hotsectors"`
+		HotFraction float64       `json:"hotfraction"`
+		HotWindow   time.Duration `json:"hotwindow"`
+
+		// SectorReferences is the number of times that the stored sectors
+		// are referenced by storage obligations. A sector that is uploaded
+		// under several contracts is stored once, and DeduplicatedBytes is
+		// the disk space that this saves.
+		SectorReferences  uint64 `json:"sectorreferences"`
+		DeduplicatedBytes uint64 `json:"deduplicatedbytes"`
+
+		// AverageLifetime is the average time between a sector being added
+		// and being removed, over the RemovedSectors sectors that were both
+		// added and removed since tracking started.
+		RemovedSectors  uint64        `json:"removedsectors"`
+		AverageLifetime time.Duration `json:"averagelifetime"`
+
+		// Cache contains the hit statistics of the cache of recently read
+		// sectors.
+		Cache SectorCacheStats `json:"cache"`
+	}
+
+	// SectorCacheStats contains the hit statistics of the in-memory cache of
+	// recently read sectors. Capacity is the number of sectors that the cache
+	// can hold, and Sectors is the number of sectors it currently holds.
+	// HitRate is Hits divided by the total number of sector reads.
+	SectorCacheStats struct {
+		Capacity uint64  `json:"capacity"`
+		Sectors  uint64  `json:"sectors"`
+		Hits     uint64  `json:"hits"`
+		Misses   uint64  `json:"misses"`
+		HitRate  float64 `json:"hitrate"`
+	}
+
+	// StorageFolderReclamation reports the space that was freed in a
+	// storage folder by removing sectors. Only sectors whose last instance
+	// was removed free up space.
+	StorageFolderReclamation struct {
+		Index   uint16 `json:"index"`
+		Path    string `json:"path"`
+		Sectors uint64 `json:"sectors"`
+		Bytes   uint64 `json:"bytes"`
 	}
 
 	// A StorageManager is responsible for managing storage folders and
@@ -56,6 +252,13 @@ type (
 		// is expected to only store the data once.
 		AddSector(sectorRoot crypto.Hash, sectorData []byte) error
 
+		// AddSectorAsync adds a sector like AddSector, but returns
+		// immediately. The result is sent on the returned channel once the
+		// sector is durable, after which the channel is closed. The sector
+		// data must not be modified until the result has been received.
+		// Sectors that are added concurrently are committed to disk together.
+		AddSectorAsync(sectorRoot crypto.Hash, sectorData []byte) <-chan error
+
 		// AddSectorBatch is a performance optimization over AddSector when
 		// adding a bunch of virtual sectors. It is necessary because otherwise
 		// potentially thousands or even tens-of-thousands of fsync calls would
@@ -69,6 +272,11 @@ type (
 		// gracefully handle running out of storage unexpectedly.
 		AddStorageFolder(path string, size uint64) error
 
+		// BackupSettings returns the settings of the storage manager, which
+		// are needed to find the sectors in the storage folders if the
+		// storage manager directory is lost.
+		BackupSettings() ([]byte, error)
+
 		// The storage manager needs to be able to shut down.
 		Close() error
 
@@ -84,11 +292,26 @@ type (
 		// bytes that match the input sector root.
 		ReadSector(sectorRoot crypto.Hash) ([]byte, error)
 
+		// MoveStorageFolder points a storage folder at a new path, after
+		// checking that the files of the folder have been moved there. The
+		// sectors in the folder are kept.
+		MoveStorageFolder(index uint16, newPath string) error
+
+		// PreflightStorageFolder checks whether the folder at path can safely
+		// be used as a storage folder, measuring its latency and verifying
+		// that synced data can be read back. The folder is not added.
+		PreflightStorageFolder(path string) (StorageFolderPreflight, error)
+
 		// RemoveSector will remove a sector from the storage manager. The
 		// height at which the sector expires should be provided, so that the
 		// auto-expiry information for that sector can be properly updated.
 		RemoveSector(sectorRoot crypto.Hash) error
 
+		// RemoveSectorBatch removes one instance of each of the sectors, like
+		// RemoveSector, and reports the space that was freed in each storage
+		// folder. Sectors that are not found are skipped.
+		RemoveSectorBatch(sectorRoots []crypto.Hash) ([]StorageFolderReclamation, error)
+
 		// RemoveStorageFolder will remove a storage folder from the manager.
 		// All storage on the folder will be moved to other storage folders,
 		// meaning that no data will be lost. If the manager is unable to save
@@ -111,8 +334,30 @@ type (
 		// that data will be lost.
 		ResizeStorageFolder(index uint16, newSize uint64, force bool) error
 
+		// ScanStorageFolders starts an integrity scan of every storage
+		// folder in the background. If quarantine is set, corrupt and
+		// unreadable sectors are removed. An error is returned if a scan is
+		// already running.
+		ScanStorageFolders(quarantine bool) error
+
+		// SetStorageFolderTier tags a storage folder as belonging to the fast
+		// or the capacity tier, which decides where new sectors are placed.
+		SetStorageFolderTier(index uint16, tier string) error
+
+		// SetStorageFolderWritePolicy changes the timeouts and retries
+		// applied to writes to a storage folder.
+		SetStorageFolderWritePolicy(index uint16, policy StorageFolderWritePolicy) error
+
+		// SectorAccessStats returns aggregated statistics about how the
+		// sectors in the manager have been read.
+		SectorAccessStats() SectorAccessStats
+
 		// StorageFolders will return a list of storage folders tracked by the
 		// manager.
 		StorageFolders() []StorageFolderMetadata
+
+		// StorageFolderScans returns the results of the most recent integrity
+		// scan of each storage folder.
+		StorageFolderScans() []StorageFolderScan
 	}
 )
@@ -2,7 +2,9 @@ package modules
 
 import (
 	"errors"
+	"time"
 
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/types"
 )
@@ -51,6 +53,141 @@ var (
 	TransactionPoolDir = "transactionpool"
 )
 
+// A TransactionPoolEvictionPolicy determines which unconfirmed transaction
+// sets are evicted from the transaction pool.
+type TransactionPoolEvictionPolicy struct {
+	// MaxAge is the amount of time that a transaction may spend in the
+	// transaction pool before every set containing it is evicted. A MaxAge of
+	// zero means that transactions never expire.
+	MaxAge time.Duration `json:"maxage"`
+
+	// MinFee is the fee-per-byte below which transaction sets are evicted
+	// from, and not accepted into, the transaction pool.
+	MinFee types.Currency `json:"minfee"`
+
+	// MaxSize is the maximum size of the transaction pool in bytes. When the
+	// pool grows beyond MaxSize, the sets paying the lowest fee-per-byte are
+	// evicted until it fits. A MaxSize of zero means the size is unlimited.
+	MaxSize uint64 `json:"maxsize"`
+}
+
+// TransactionPoolRelayLimits limit the transaction sets that each peer may
+// relay to the transaction pool, so that a single peer cannot flood the pool
+// with validation work. Peers are identified by their host, so a peer cannot
+// escape its limits by reconnecting from a different port.
+type TransactionPoolRelayLimits struct {
+	// MaxSets and MaxBytes are the number of transaction sets, and their
+	// combined size in bytes, that a peer may relay within each Interval.
+	// Sets relayed beyond either limit are ignored without being validated.
+	// A limit of zero means that it is not enforced.
+	MaxSets  uint64        `json:"maxsets"`
+	MaxBytes uint64        `json:"maxbytes"`
+	Interval time.Duration `json:"interval"`
+
+	// BanDuration is how long every set relayed by a peer is ignored after
+	// the peer exceeds its limits. With a BanDuration of zero, the peer is
+	// only throttled until the end of the interval.
+	BanDuration time.Duration `json:"banduration"`
+}
+
+// A TransactionPoolRelayPeer describes how much a peer has relayed to the
+// transaction pool in the current relay interval.
+type TransactionPoolRelayPeer struct {
+	Host        string    `json:"host"`
+	Sets        uint64    `json:"sets"`
+	Bytes       uint64    `json:"bytes"`
+	Ignored     uint64    `json:"ignored"`
+	BannedUntil time.Time `json:"banneduntil"`
+}
+
+// A TransactionPoolRisk summarizes how likely an unconfirmed transaction is to
+// be confirmed, so that merchants can decide whether to accept a payment
+// before it confirms.
+type TransactionPoolRisk struct {
+	ID        types.TransactionID `json:"id"`
+	FirstSeen types.Timestamp     `json:"firstseen"`
+
+	// FeePerByte is the effective fee-per-byte of the transaction, including
+	// the ancestors that a miner must include along with it. FeePercentile is
+	// the percentage of the pool, by size, that pays a lower fee-per-byte,
+	// and BytesAhead is the size of the transactions that pay a higher
+	// fee-per-byte and are likely to be confirmed first.
+	FeePerByte    types.Currency `json:"feeperbyte"`
+	FeePercentile float64        `json:"feepercentile"`
+	BytesAhead    uint64         `json:"bytesahead"`
+
+	// ConflictingTransactions are the transactions seen by the pool that
+	// spend an object also spent by the transaction or its ancestors.
+	ConflictingTransactions []types.TransactionID `json:"conflictingtransactions"`
+
+	// RelayPeers is the number of peers that have relayed the transaction to
+	// the pool.
+	RelayPeers int `json:"relaypeers"`
+}
+
+// A TransactionPoolSnapshot is a consistent view of every transaction set in
+// the transaction pool, taken at a single point in time.
+type TransactionPoolSnapshot struct {
+	// ConsensusChange is the ID of the most recent consensus change that the
+	// transaction pool had processed when the snapshot was taken.
+	ConsensusChange crypto.Hash `json:"consensuschange"`
+
+	// Size is the total size of the transaction sets in bytes.
+	Size uint64 `json:"size"`
+
+	// Sets are ordered by fee-per-byte, highest first.
+	Sets []TransactionSetSnapshot `json:"sets"`
+}
+
+// A TransactionSetSnapshot describes a transaction set in a
+// TransactionPoolSnapshot. Sets never depend on each other, and the
+// transactions of a set are ordered so that every transaction follows its
+// parents.
+type TransactionSetSnapshot struct {
+	ID           crypto.Hash           `json:"id"`
+	Size         uint64                `json:"size"`
+	Fees         types.Currency        `json:"fees"`
+	FeePerByte   types.Currency        `json:"feeperbyte"`
+	Transactions []TransactionSnapshot `json:"transactions"`
+}
+
+// A TransactionSnapshot is a transaction in a TransactionSetSnapshot, along
+// with the IDs of the transactions in the same set that create the objects it
+// spends or revises, and of those that spend or revise the objects it
+// creates. A transaction without parents only depends on the confirmed
+// blockchain. Size is the encoded size of the transaction in bytes, Fees are
+// the miner fees it pays, and FirstSeen is when it entered the pool.
+type TransactionSnapshot struct {
+	ID          types.TransactionID   `json:"id"`
+	Transaction types.Transaction     `json:"transaction"`
+	Parents     []types.TransactionID `json:"parents"`
+	Children    []types.TransactionID `json:"children"`
+	Size        uint64                `json:"size"`
+	Fees        types.Currency        `json:"fees"`
+	FirstSeen   types.Timestamp       `json:"firstseen"`
+}
+
+// A DoubleSpend describes transactions that were rejected by the transaction
+// pool, or evicted from it, because they spend an object that is also spent
+// by other transactions. Confirmed indicates that the conflicting
+// transactions are in the blockchain, in which case the rejected transactions
+// can never be confirmed. Evicted indicates that the transactions were in the
+// pool before the conflict arrived.
+type DoubleSpend struct {
+	Transactions            []types.TransactionID `json:"transactions"`
+	ConflictingTransactions []types.TransactionID `json:"conflictingtransactions"`
+	Confirmed               bool                  `json:"confirmed"`
+	Evicted                 bool                  `json:"evicted"`
+}
+
+// A DoubleSpendSubscriber is notified when the transaction pool detects a
+// double spend.
+type DoubleSpendSubscriber interface {
+	// ReceiveDoubleSpend is called with the transaction pool locked, and
+	// must not call back into the transaction pool.
+	ReceiveDoubleSpend(DoubleSpend)
+}
+
 // A TransactionPoolSubscriber receives updates about the confirmed and
 // unconfirmed set from the transaction pool. Generally, there is no need to
 // subscribe to both the consensus set and the transaction pool.
@@ -70,6 +207,29 @@ type TransactionPool interface {
 	// Close is necessary for clean shutdown (e.g. during testing).
 	Close() error
 
+	// DoubleSpendSubscribe adds a subscriber that is notified whenever a
+	// transaction is rejected or evicted because a conflicting spend arrived
+	// in the pool or was confirmed.
+	DoubleSpendSubscribe(DoubleSpendSubscriber)
+
+	// DoubleSpendUnsubscribe removes a double spend subscriber.
+	DoubleSpendUnsubscribe(DoubleSpendSubscriber)
+
+	// EvictionFloor returns the fee-per-byte that a transaction set must pay
+	// to be accepted while the transaction pool is full. It is raised above
+	// the fee of every set that is evicted to keep the pool below its maximum
+	// size, and reset when a block is processed.
+	EvictionFloor() types.Currency
+
+	// EvictionPolicy returns the policy used to evict transaction sets from
+	// the transaction pool.
+	EvictionPolicy() TransactionPoolEvictionPolicy
+
+	// FeeEstimate returns the fee-per-byte that a transaction set should pay
+	// to be confirmed within the provided number of blocks, based on the
+	// backlog of unconfirmed transactions and the fees paid in recent blocks.
+	FeeEstimate(targetBlocks types.BlockHeight) (types.Currency, error)
+
 	// FeeEstimation returns an estimation for how high the transaction fee
 	// needs to be per byte. The minimum recommended targets getting accepted
 	// in ~3 blocks, and the maximum recommended targets getting accepted
@@ -89,11 +249,53 @@ type TransactionPool interface {
 	// that make this condition necessary.
 	PurgeTransactionPool()
 
+	// RelayLimits returns the limits on the transaction sets that each peer
+	// may relay to the transaction pool.
+	RelayLimits() TransactionPoolRelayLimits
+
+	// RelayPeers returns the relay activity of the peers that have relayed
+	// transaction sets recently or are banned.
+	RelayPeers() []TransactionPoolRelayPeer
+
+	// SetEvictionPolicy sets the policy used to evict transaction sets from
+	// the transaction pool. Sets that violate the new policy are evicted
+	// immediately.
+	SetEvictionPolicy(TransactionPoolEvictionPolicy) error
+
+	// SetRelayLimits sets the limits on the transaction sets that each peer
+	// may relay to the transaction pool.
+	SetRelayLimits(TransactionPoolRelayLimits) error
+
 	// TransactionList returns a list of all transactions in the transaction
 	// pool. The transactions are provided in an order that can acceptably be
-	// put into a block.
+	// put into a block, with the transactions paying the highest fee-per-byte
+	// first. A transaction paying a low fee is placed alongside the highest
+	// paying child that depends on it.
 	TransactionList() []types.Transaction
 
+	// TransactionRisk returns a summary of the risk that the transaction in
+	// the pool with the provided ID is not confirmed. The bool indicates
+	// whether the transaction is in the pool.
+	TransactionRisk(types.TransactionID) (TransactionPoolRisk, bool)
+
+	// TransactionSetSnapshot returns a consistent, fee-ordered snapshot of
+	// every transaction set in the pool, including the parents of each
+	// transaction.
+	TransactionSetSnapshot() (TransactionPoolSnapshot, error)
+
+	// UnconfirmedTransaction returns a snapshot of the transaction in the
+	// pool with the provided ID, along with a snapshot of the set that
+	// contains it. The bool indicates whether the transaction is in the pool.
+	UnconfirmedTransaction(types.TransactionID) (TransactionSnapshot, TransactionSetSnapshot, bool)
+
+	// TransactionsForBlock returns the transactions that pay the most in
+	// fees while fitting in maxSize bytes. Transactions are added in order
+	// of fee-per-byte, highest first, along with the parents they depend on,
+	// so a child paying a high fee can pull in a parent paying a low fee.
+	// Transactions that depend on each other are kept in an order that can be
+	// put into a block.
+	TransactionsForBlock(maxSize uint64) []types.Transaction
+
 	// TransactionPoolSubscribe adds a subscriber to the transaction pool.
 	// Subscribers will receive all consensus set changes as well as
 	// transaction pool changes, and should not subscribe to both.
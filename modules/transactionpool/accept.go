@@ -17,18 +17,10 @@ import (
 )
 
 const (
-	// The TransactionPoolSizeLimit is first checked, and then a transaction
-	// set is added. The current transaction pool does not do any priority
-	// ordering, so the size limit is such that the transaction pool will never
-	// exceed the size of a block.
-	//
-	// TODO: Add a priority structure that will allow the transaction pool to
-	// fill up beyond the size of a single block, without being subject to
-	// manipulation.
-	//
-	// The first ~1/4 of the transaction pool can be filled for free. This is
-	// mostly to preserve compatibility with clients that do not add fees.
-	TransactionPoolSizeLimit  = 2e6 - 5e3 - modules.TransactionSetSizeLimit
+	// The first TransactionPoolSizeForFee bytes of the transaction pool can
+	// be filled for free. This is mostly to preserve compatibility with
+	// clients that do not add fees. The total size of the pool is limited by
+	// the eviction policy.
 	TransactionPoolSizeForFee = 500e3
 )
 
@@ -37,6 +29,7 @@ var (
 	errFullTransactionPool = errors.New("transaction pool cannot accept more transactions")
 	errLowMinerFees        = errors.New("transaction set needs more miner fees to be accepted")
 	errEmptySet            = errors.New("transaction set is empty")
+	errLowReplacementFee   = errors.New("replacement transaction set does not pay enough fees to evict the sets it conflicts with")
 
 	TransactionMinFee = types.SiacoinPrecision.Mul64(2)
 
@@ -86,12 +79,6 @@ func relatedObjectIDs(ts []types.Transaction) []ObjectID {
 // checkMinerFees checks that the total amount of transaction fees in the
 // transaction set is sufficient to earn a spot in the transaction pool.
 func (tp *TransactionPool) checkMinerFees(ts []types.Transaction) error {
-	// Transactions cannot be added after the TransactionPoolSizeLimit has been
-	// hit.
-	if tp.transactionListSize > TransactionPoolSizeLimit {
-		return errFullTransactionPool
-	}
-
 	// The first TransactionPoolSizeForFee transactions do not need fees.
 	if tp.transactionListSize > TransactionPoolSizeForFee {
 		// Currently required fees are set on a per-transaction basis. 2 coins
@@ -129,6 +116,10 @@ func (tp *TransactionPool) checkTransactionSetComposition(ts []types.Transaction
 	if err != nil {
 		return err
 	}
+	err = tp.checkEvictionPolicy(ts)
+	if err != nil {
+		return err
+	}
 
 	// All checks after this are expensive.
 	//
@@ -213,8 +204,13 @@ func (tp *TransactionPool) handleConflicts(ts []types.Transaction, conflicts []T
 	}
 
 	// Check that the transaction set is valid.
+	// A set that spends an output which does not exist is an orphan, unless
+	// the output was spent by one of the conflicts.
 	cc, err := txnFn(superset)
-	if err != nil {
+	conflictSpends := spentObjects(superset[:len(superset)-len(dedupSet)])
+	if isMissingParentErr(err) && len(conflictingTransactions(dedupSet, conflictSpends)) == 0 {
+		return errOrphanTransactionSet
+	} else if err != nil {
 		return modules.NewConsensusConflict("provided transaction set has prereqs, but is still invalid: " + err.Error())
 	}
 
@@ -288,10 +284,22 @@ func (tp *TransactionPool) acceptTransactionSet(ts []types.Transaction, txnFn fu
 		}
 	}
 	if len(conflicts) > 0 {
-		return tp.handleConflicts(ts, conflicts, txnFn)
+		err = tp.handleConflicts(ts, conflicts, txnFn)
+		// If the set could not be merged with its conflicts, it may be double
+		// spending them, in which case it can replace them if it pays enough
+		// fees.
+		if _, ok := err.(modules.ConsensusConflict); ok && tp.replaceByFee {
+			rerr := tp.replaceConflicts(ts, conflicts, txnFn)
+			if rerr == nil || rerr == errLowReplacementFee {
+				return rerr
+			}
+		}
+		return err
 	}
 	cc, err := txnFn(ts)
-	if err != nil {
+	if isMissingParentErr(err) {
+		return errOrphanTransactionSet
+	} else if err != nil {
 		return modules.NewConsensusConflict("provided transaction set is standalone and invalid: " + err.Error())
 	}
 
@@ -322,11 +330,34 @@ func (tp *TransactionPool) AcceptTransactionSet(ts []types.Transaction) error {
 		tp.mu.Lock()
 		defer tp.mu.Unlock()
 		err := tp.acceptTransactionSet(ts, txnFn)
+		if err == errOrphanTransactionSet {
+			// The parents of the set may arrive shortly, in which case the
+			// set is promoted into the pool.
+			return tp.addOrphan(ts, time.Now())
+		}
+		if _, ok := err.(modules.ConsensusConflict); ok || err == errLowReplacementFee {
+			tp.reportRejectedSet(ts)
+		}
 		if err != nil {
 			return err
 		}
-		// Notify subscribers and broadcast the transaction set.
-		go tp.gateway.Broadcast("RelayTransactionSet", ts, tp.gateway.Peers())
+		// If the pool has grown too large, the sets paying the lowest fees
+		// are evicted, which may include the new set.
+		if tp.enforceMaxSize() && !tp.transactionInPool(ts[len(ts)-1].ID()) {
+			tp.updateFeeBacklog()
+			tp.updateSubscribersTransactions()
+			return errFullTransactionPool
+		}
+		tp.recordFirstSeen(ts, time.Now())
+		// Notify subscribers and broadcast the transaction set. The set is
+		// broadcast again if it is not confirmed within a few blocks.
+		go tp.relayTransactionSets([][]types.Transaction{ts}, tp.gateway.Peers())
+		tp.scheduleRebroadcast(ts)
+		// The new set may contain the parents of orphans.
+		if tp.promoteOrphans(txnFn) {
+			tp.enforceMaxSize()
+		}
+		tp.updateFeeBacklog()
 		tp.updateSubscribersTransactions()
 		return nil
 	})
@@ -340,10 +371,17 @@ func (tp *TransactionPool) relayTransactionSet(conn modules.PeerConn) error {
 	if err != nil {
 		return err
 	}
+	// Sets relayed by a peer that has exceeded its relay limits are ignored
+	// without being validated.
+	host := conn.RPCAddr().Host()
+	err = tp.managedCheckRelay(host, 0, time.Now())
+	if err != nil {
+		return err
+	}
 	var ts []types.Transaction
 	err = encoding.ReadObject(conn, &ts, types.BlockSizeLimit)
 	if err != nil {
 		return err
 	}
-	return tp.AcceptTransactionSet(ts)
+	return tp.managedAcceptRelayedSet(host, ts)
 }
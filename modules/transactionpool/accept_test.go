@@ -102,7 +102,25 @@ func TestIntegrationConflictingTransactionSets(t *testing.T) {
 		t.Error("transaction should not have passed inspection")
 	}
 
-	// Purge and try the sets in the reverse order.
+	// Purge and try the sets in the reverse order. The set that pays a miner
+	// fee should replace the set that does not.
+	tpt.tpool.PurgeTransactionPool()
+	err = tpt.tpool.AcceptTransactionSet(txnSetDoubleSpend)
+	if err != nil {
+		t.Error(err)
+	}
+	err = tpt.tpool.AcceptTransactionSet(txnSet)
+	if err != nil {
+		t.Error(err)
+	}
+	for _, txn := range tpt.tpool.TransactionList() {
+		if txn.ID() == txnSetDoubleSpend[txnIndex].ID() {
+			t.Error("double spend should have been replaced")
+		}
+	}
+
+	// With replace-by-fee disabled, the double spend should be rejected.
+	tpt.tpool.SetReplaceByFee(false)
 	tpt.tpool.PurgeTransactionPool()
 	err = tpt.tpool.AcceptTransactionSet(txnSetDoubleSpend)
 	if err != nil {
@@ -184,11 +202,16 @@ func TestIntegrationTransactionSuperset(t *testing.T) {
 	if len(txnSet) <= 1 {
 		t.Fatal("test is invalid unless the transaction set has two or more transactions")
 	}
-	// Check that the second transaction is dependent on the first.
+	// Check that the second transaction is dependent on the first. Drop the
+	// orphan afterwards, so that it is not promoted when the first
+	// transaction is submitted.
 	err = tpt.tpool.AcceptTransactionSet(txnSet[1:])
-	if err == nil {
+	if err != errOrphanTransactionSet {
 		t.Fatal("transaction set must have dependent transactions")
 	}
+	tpt.tpool.mu.Lock()
+	tpt.tpool.removeOrphan(0)
+	tpt.tpool.mu.Unlock()
 
 	// Submit the first transaction in the set to the transaction pool, and
 	// then the superset.
@@ -292,19 +315,23 @@ func TestIntegrationTransactionChild(t *testing.T) {
 	}
 	// Check that the second transaction is dependent on the first.
 	err = tpt.tpool.AcceptTransactionSet([]types.Transaction{txnSet[1]})
-	if err == nil {
+	if err != errOrphanTransactionSet {
 		t.Fatal("transaction set must have dependent transactions")
 	}
 
-	// Submit the first transaction in the set to the transaction pool.
+	// Submit the first transaction in the set to the transaction pool. The
+	// second transaction is promoted out of the orphan pool.
 	err = tpt.tpool.AcceptTransactionSet(txnSet[:1])
 	if err != nil {
 		t.Fatal("first transaction in the transaction set was not valid?")
 	}
-	err = tpt.tpool.AcceptTransactionSet(txnSet[1:])
-	if err != nil {
+	if !tpt.tpool.transactionInPool(txnSet[1].ID()) {
 		t.Fatal("child transaction not seen as valid")
 	}
+	err = tpt.tpool.AcceptTransactionSet(txnSet[1:])
+	if err != modules.ErrDuplicateTransactionSet {
+		t.Fatal("expected ErrDuplicateTransactionSet, got", err)
+	}
 }
 
 // TestIntegrationNilAccept tries submitting a nil transaction set and a 0-len
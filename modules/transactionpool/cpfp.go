@@ -0,0 +1,145 @@
+package transactionpool
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// A txnChunk is a group of transactions from a set in the pool that are added
+// to a block together. A chunk is built around a transaction that pays a high
+// fee along with the ancestors it depends on, so that a child paying a high
+// fee pulls in a parent paying a low fee (child-pays-for-parent).
+type txnChunk struct {
+	fee   types.Currency // fee-per-byte of the chunk
+	fees  types.Currency
+	index int
+	setID TransactionSetID
+	size  uint64
+	txns  []types.Transaction
+}
+
+// chunksByFee sorts chunks by fee-per-byte, highest first. Chunks paying the
+// same fee are ordered by set and then by their position in the set, so that
+// the chunks of a set keep their order.
+type chunksByFee []txnChunk
+
+func (cf chunksByFee) Len() int      { return len(cf) }
+func (cf chunksByFee) Swap(i, j int) { cf[i], cf[j] = cf[j], cf[i] }
+func (cf chunksByFee) Less(i, j int) bool {
+	if c := cf[i].fee.Cmp(cf[j].fee); c != 0 {
+		return c > 0
+	}
+	if c := bytes.Compare(cf[i].setID[:], cf[j].setID[:]); c != 0 {
+		return c < 0
+	}
+	return cf[i].index < cf[j].index
+}
+
+// merge appends the transactions of c2 to c.
+func (c *txnChunk) merge(c2 txnChunk) {
+	c.txns = append(c.txns, c2.txns...)
+	c.fees = c.fees.Add(c2.fees)
+	c.size += c2.size
+	c.fee = c.fees.Div64(c.size)
+}
+
+// setChunks splits a transaction set into chunks. Chunks are chosen greedily:
+// each chunk is the remaining transaction whose package, meaning the
+// transaction along with its ancestors that are not in an earlier chunk, pays
+// the highest fee-per-byte. A chunk paying more than the chunk before it is
+// merged into that chunk, so the fee-per-byte of the chunks of a set never
+// increases and each chunk only depends on the chunks before it.
+func setChunks(setID TransactionSetID, ts []types.Transaction) []txnChunk {
+	indices := make(map[types.TransactionID]int)
+	for i, txn := range ts {
+		indices[txn.ID()] = i
+	}
+
+	// Determine the ancestors of each transaction. Parents always come before
+	// their children in a set.
+	ancestors := make([]map[int]struct{}, len(ts))
+	for i, parents := range transactionParents(ts) {
+		ancestors[i] = make(map[int]struct{})
+		for _, parentID := range parents {
+			parent := indices[parentID]
+			ancestors[i][parent] = struct{}{}
+			for a := range ancestors[parent] {
+				ancestors[i][a] = struct{}{}
+			}
+		}
+	}
+	sizes := make([]uint64, len(ts))
+	fees := make([]types.Currency, len(ts))
+	for i, txn := range ts {
+		sizes[i] = uint64(len(encoding.Marshal(txn)))
+		fees[i] = setFees(ts[i : i+1])
+	}
+
+	var chunks []txnChunk
+	chunked := make([]bool, len(ts))
+	for remaining := len(ts); remaining > 0; {
+		// Find the package paying the highest fee-per-byte.
+		var best []int
+		var bestFee types.Currency
+		for i := range ts {
+			if chunked[i] {
+				continue
+			}
+			pkg := []int{i}
+			var pkgFees types.Currency
+			var pkgSize uint64
+			for a := range ancestors[i] {
+				if !chunked[a] {
+					pkg = append(pkg, a)
+				}
+			}
+			for _, j := range pkg {
+				pkgFees = pkgFees.Add(fees[j])
+				pkgSize += sizes[j]
+			}
+			pkgFee := pkgFees.Div64(pkgSize)
+			if best == nil || pkgFee.Cmp(bestFee) > 0 {
+				best, bestFee = pkg, pkgFee
+			}
+		}
+
+		// Keep the transactions of the package in set order, so that parents
+		// come before their children.
+		sort.Ints(best)
+		chunk := txnChunk{setID: setID}
+		for _, j := range best {
+			chunked[j] = true
+			chunk.merge(txnChunk{
+				fees: fees[j],
+				size: sizes[j],
+				txns: []types.Transaction{ts[j]},
+			})
+		}
+		remaining -= len(best)
+
+		for len(chunks) > 0 && chunk.fee.Cmp(chunks[len(chunks)-1].fee) > 0 {
+			prev := chunks[len(chunks)-1]
+			chunks = chunks[:len(chunks)-1]
+			prev.merge(chunk)
+			chunk = prev
+		}
+		chunk.index = len(chunks)
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// feeOrderedChunks returns the chunks of every transaction set in the pool
+// ordered by fee-per-byte, highest first. Every chunk comes after the chunks
+// it depends on.
+func (tp *TransactionPool) feeOrderedChunks() []txnChunk {
+	var chunks []txnChunk
+	for id, set := range tp.transactionSets {
+		chunks = append(chunks, setChunks(id, set)...)
+	}
+	sort.Sort(chunksByFee(chunks))
+	return chunks
+}
@@ -0,0 +1,86 @@
+package transactionpool
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// cpfpTestSet returns a set containing a parent paying no fee, a child of the
+// parent paying a high fee, and an unrelated transaction paying a medium fee.
+func cpfpTestSet() (parent, child, unrelated types.Transaction) {
+	parent = types.Transaction{
+		SiacoinOutputs: []types.SiacoinOutput{{Value: types.NewCurrency64(1)}},
+	}
+	child = types.Transaction{
+		SiacoinInputs: []types.SiacoinInput{{ParentID: parent.SiacoinOutputID(0)}},
+		MinerFees:     []types.Currency{types.NewCurrency64(100e3)},
+	}
+	unrelated = types.Transaction{
+		SiacoinInputs: []types.SiacoinInput{{ParentID: types.SiacoinOutputID{1}}},
+		MinerFees:     []types.Currency{types.NewCurrency64(10e3)},
+	}
+	return
+}
+
+// TestSetChunks checks that a child paying a high fee is chunked together
+// with its parent, ahead of an unrelated transaction paying less.
+func TestSetChunks(t *testing.T) {
+	parent, child, unrelated := cpfpTestSet()
+	chunks := setChunks(TransactionSetID{}, []types.Transaction{parent, unrelated, child})
+	if len(chunks) != 2 {
+		t.Fatal("expected 2 chunks, got", len(chunks))
+	}
+	if len(chunks[0].txns) != 2 || chunks[0].txns[0].ID() != parent.ID() || chunks[0].txns[1].ID() != child.ID() {
+		t.Fatal("the child was not chunked after its parent")
+	}
+	if len(chunks[1].txns) != 1 || chunks[1].txns[0].ID() != unrelated.ID() {
+		t.Fatal("the unrelated transaction was not chunked on its own")
+	}
+	if chunks[0].fee.Cmp(chunks[1].fee) < 0 || chunks[0].index != 0 || chunks[1].index != 1 {
+		t.Fatal("chunks are not ordered by fee")
+	}
+
+	// A child paying less than an unrelated transaction cannot pull its
+	// parent ahead, and a later chunk paying more than an earlier one is
+	// merged into it.
+	child.MinerFees = []types.Currency{types.NewCurrency64(1e3)}
+	chunks = setChunks(TransactionSetID{}, []types.Transaction{parent, unrelated, child})
+	if len(chunks) != 2 || chunks[0].txns[0].ID() != unrelated.ID() || len(chunks[1].txns) != 2 {
+		t.Fatal("chunks were not ordered by fee:", len(chunks))
+	}
+	unrelated.MinerFees = nil
+	chunks = setChunks(TransactionSetID{}, []types.Transaction{parent, unrelated, child})
+	for i := 1; i < len(chunks); i++ {
+		if chunks[i].fee.Cmp(chunks[i-1].fee) > 0 {
+			t.Fatal("a chunk pays more than the chunk before it")
+		}
+	}
+}
+
+// TestTransactionsForBlockCPFP checks that a child paying a high fee pulls
+// its parent into a block ahead of sets paying a higher fee than the parent.
+func TestTransactionsForBlockCPFP(t *testing.T) {
+	parent, child, unrelated := cpfpTestSet()
+	tp := &TransactionPool{
+		transactionSets: map[TransactionSetID][]types.Transaction{
+			{1}: {parent, child},
+			{2}: {unrelated},
+		},
+	}
+
+	txns := tp.TransactionsForBlock(types.BlockSizeLimit)
+	if len(txns) != 3 || txns[0].ID() != parent.ID() || txns[1].ID() != child.ID() || txns[2].ID() != unrelated.ID() {
+		t.Fatal("the parent and child were not selected first")
+	}
+	if tl := tp.feeOrderedTransactions(); len(tl) != 3 || tl[0].ID() != parent.ID() {
+		t.Fatal("the transaction list is not ordered by chunk")
+	}
+
+	// Without room for the parent and child, the unrelated set is selected.
+	chunks := tp.feeOrderedChunks()
+	txns = tp.TransactionsForBlock(chunks[1].size)
+	if len(txns) != 1 || txns[0].ID() != unrelated.ID() {
+		t.Fatal("the unrelated set was not selected")
+	}
+}
@@ -0,0 +1,178 @@
+package transactionpool
+
+import (
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+const (
+	// doubleSpendMemory is the number of recent blocks whose spends are
+	// remembered, so that a transaction set that arrives after a conflicting
+	// spend has been confirmed can be reported as a double spend.
+	doubleSpendMemory = 6
+)
+
+// spentObjects returns the objects spent or revised by each transaction in
+// ts, mapped to the ID of the transaction that spends them.
+func spentObjects(ts []types.Transaction) map[ObjectID]types.TransactionID {
+	spends := make(map[ObjectID]types.TransactionID)
+	for _, txn := range ts {
+		id := txn.ID()
+		for _, sci := range txn.SiacoinInputs {
+			spends[ObjectID(sci.ParentID)] = id
+		}
+		for _, fcr := range txn.FileContractRevisions {
+			spends[ObjectID(fcr.ParentID)] = id
+		}
+		for _, sp := range txn.StorageProofs {
+			spends[ObjectID(sp.ParentID)] = id
+		}
+		for _, sfi := range txn.SiafundInputs {
+			spends[ObjectID(sfi.ParentID)] = id
+		}
+	}
+	return spends
+}
+
+// conflictingTransactions returns the IDs of the transactions in spends that
+// spend an object that is also spent by ts. Transactions that are part of ts
+// are not conflicts.
+func conflictingTransactions(ts []types.Transaction, spends map[ObjectID]types.TransactionID) []types.TransactionID {
+	own := make(map[types.TransactionID]struct{})
+	for _, txn := range ts {
+		own[txn.ID()] = struct{}{}
+	}
+	var conflicts []types.TransactionID
+	seen := make(map[types.TransactionID]struct{})
+	for oid := range spentObjects(ts) {
+		id, exists := spends[oid]
+		if !exists {
+			continue
+		}
+		_, isOwn := own[id]
+		_, isSeen := seen[id]
+		if isOwn || isSeen {
+			continue
+		}
+		seen[id] = struct{}{}
+		conflicts = append(conflicts, id)
+	}
+	return conflicts
+}
+
+// transactionIDs returns the IDs of the transactions in ts.
+func transactionIDs(ts []types.Transaction) []types.TransactionID {
+	ids := make([]types.TransactionID, len(ts))
+	for i, txn := range ts {
+		ids[i] = txn.ID()
+	}
+	return ids
+}
+
+// updateRecentSpends records the spends of the applied blocks, and forgets
+// the spends of the reverted blocks.
+func (tp *TransactionPool) updateRecentSpends(cc modules.ConsensusChange) {
+	for range cc.RevertedBlocks {
+		if len(tp.recentSpends) > 0 {
+			tp.recentSpends = tp.recentSpends[:len(tp.recentSpends)-1]
+		}
+	}
+	for _, block := range cc.AppliedBlocks {
+		tp.recentSpends = append(tp.recentSpends, spentObjects(block.Transactions))
+	}
+	if len(tp.recentSpends) > doubleSpendMemory {
+		tp.recentSpends = tp.recentSpends[len(tp.recentSpends)-doubleSpendMemory:]
+	}
+}
+
+// notifyDoubleSpend records a double spend and sends it to every subscriber.
+func (tp *TransactionPool) notifyDoubleSpend(ds modules.DoubleSpend) {
+	tp.recordConflicts(ds)
+	for _, subscriber := range tp.doubleSpendSubscribers {
+		subscriber.ReceiveDoubleSpend(ds)
+	}
+}
+
+// reportRejectedSet notifies subscribers if a transaction set that was
+// rejected by the pool conflicts with a recently confirmed transaction or
+// with a transaction in the pool. Confirmed conflicts take precedence, as
+// they mean that the set can never be confirmed.
+func (tp *TransactionPool) reportRejectedSet(ts []types.Transaction) {
+	var conflicts []types.TransactionID
+	for i := len(tp.recentSpends) - 1; i >= 0; i-- {
+		conflicts = append(conflicts, conflictingTransactions(ts, tp.recentSpends[i])...)
+	}
+	confirmed := len(conflicts) > 0
+	if !confirmed {
+		for _, set := range tp.transactionSets {
+			conflicts = append(conflicts, conflictingTransactions(ts, spentObjects(set))...)
+		}
+	}
+	if len(conflicts) == 0 {
+		return
+	}
+	tp.notifyDoubleSpend(modules.DoubleSpend{
+		Transactions:            transactionIDs(ts),
+		ConflictingTransactions: conflicts,
+		Confirmed:               confirmed,
+	})
+}
+
+// reportReplacedSet notifies subscribers that a transaction set was evicted
+// from the pool by a replacement that double spends it.
+func (tp *TransactionPool) reportReplacedSet(evicted, replacement []types.Transaction) {
+	tp.notifyDoubleSpend(modules.DoubleSpend{
+		Transactions:            transactionIDs(evicted),
+		ConflictingTransactions: conflictingTransactions(evicted, spentObjects(replacement)),
+		Evicted:                 true,
+	})
+}
+
+// reportConfirmedConflicts notifies subscribers of the unconfirmed sets that
+// could not be returned to the pool after a consensus change because they
+// conflict with transactions in the applied blocks.
+func (tp *TransactionPool) reportConfirmedConflicts(unconfirmedSets [][]types.Transaction, cc modules.ConsensusChange) {
+	spends := make(map[ObjectID]types.TransactionID)
+	for _, block := range cc.AppliedBlocks {
+		for oid, id := range spentObjects(block.Transactions) {
+			spends[oid] = id
+		}
+	}
+	for _, set := range unconfirmedSets {
+		if len(set) == 0 || tp.transactionInPool(set[len(set)-1].ID()) {
+			continue
+		}
+		conflicts := conflictingTransactions(set, spends)
+		if len(conflicts) == 0 {
+			continue
+		}
+		tp.notifyDoubleSpend(modules.DoubleSpend{
+			Transactions:            transactionIDs(set),
+			ConflictingTransactions: conflicts,
+			Confirmed:               true,
+			Evicted:                 true,
+		})
+	}
+}
+
+// DoubleSpendSubscribe adds a subscriber that is notified whenever a
+// transaction is rejected or evicted because a conflicting spend arrived in
+// the pool or was confirmed.
+func (tp *TransactionPool) DoubleSpendSubscribe(subscriber modules.DoubleSpendSubscriber) {
+	tp.mu.Lock()
+	tp.doubleSpendSubscribers = append(tp.doubleSpendSubscribers, subscriber)
+	tp.mu.Unlock()
+}
+
+// DoubleSpendUnsubscribe removes a double spend subscriber. If the subscriber
+// is not subscribed, DoubleSpendUnsubscribe does nothing.
+func (tp *TransactionPool) DoubleSpendUnsubscribe(subscriber modules.DoubleSpendSubscriber) {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	for i := range tp.doubleSpendSubscribers {
+		if tp.doubleSpendSubscribers[i] == subscriber {
+			tp.doubleSpendSubscribers = append(tp.doubleSpendSubscribers[0:i], tp.doubleSpendSubscribers[i+1:]...)
+			break
+		}
+	}
+}
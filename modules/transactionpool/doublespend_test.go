@@ -0,0 +1,138 @@
+package transactionpool
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// doubleSpendRecorder records the double spends reported by the transaction
+// pool.
+type doubleSpendRecorder struct {
+	doubleSpends []modules.DoubleSpend
+}
+
+// ReceiveDoubleSpend implements modules.DoubleSpendSubscriber.
+func (dsr *doubleSpendRecorder) ReceiveDoubleSpend(ds modules.DoubleSpend) {
+	dsr.doubleSpends = append(dsr.doubleSpends, ds)
+}
+
+// TestDoubleSpendNotifications checks that subscribers are notified when
+// transaction sets are rejected or evicted because of conflicting spends in
+// the pool and in the blockchain.
+func TestDoubleSpendNotifications(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+
+	// Create a confirmed output that anyone can spend.
+	emptyUH := types.UnlockConditions{}.UnlockHash()
+	builder := tpt.wallet.StartTransaction()
+	funding := types.SiacoinPrecision.Mul64(10)
+	if err := builder.FundSiacoins(funding); err != nil {
+		t.Fatal(err)
+	}
+	i := builder.AddSiacoinOutput(types.SiacoinOutput{Value: funding, UnlockHash: emptyUH})
+	tSet, err := builder.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tpt.tpool.AcceptTransactionSet(tSet); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tpt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	outputID := tSet[len(tSet)-1].SiacoinOutputID(i)
+	spend := func(fee types.Currency, dest types.UnlockHash) []types.Transaction {
+		return []types.Transaction{{
+			SiacoinInputs:  []types.SiacoinInput{{ParentID: outputID}},
+			SiacoinOutputs: []types.SiacoinOutput{{Value: funding.Sub(fee), UnlockHash: dest}},
+			MinerFees:      []types.Currency{fee},
+		}}
+	}
+
+	dsr := new(doubleSpendRecorder)
+	tpt.tpool.DoubleSpendSubscribe(dsr)
+	checkLast := func(n int, rejected, conflicting []types.Transaction, confirmed, evicted bool) {
+		if len(dsr.doubleSpends) != n {
+			t.Fatalf("expected %v double spends, got %v", n, len(dsr.doubleSpends))
+		}
+		ds := dsr.doubleSpends[n-1]
+		if len(ds.Transactions) != len(rejected) || ds.Transactions[0] != rejected[0].ID() {
+			t.Fatal("wrong transactions reported:", ds.Transactions)
+		}
+		if len(ds.ConflictingTransactions) != len(conflicting) || ds.ConflictingTransactions[0] != conflicting[0].ID() {
+			t.Fatal("wrong conflicting transactions reported:", ds.ConflictingTransactions)
+		}
+		if ds.Confirmed != confirmed || ds.Evicted != evicted {
+			t.Fatalf("expected confirmed %v and evicted %v, got %v and %v", confirmed, evicted, ds.Confirmed, ds.Evicted)
+		}
+	}
+
+	original := spend(types.SiacoinPrecision, types.UnlockHash{1})
+	if err := tpt.tpool.AcceptTransactionSet(original); err != nil {
+		t.Fatal(err)
+	}
+	if len(dsr.doubleSpends) != 0 {
+		t.Fatal("double spend reported for a valid set")
+	}
+
+	// A double spend that does not pay enough to replace the original is
+	// rejected.
+	lowFee := spend(types.SiacoinPrecision.Div64(2), types.UnlockHash{2})
+	if err := tpt.tpool.AcceptTransactionSet(lowFee); err != errLowReplacementFee {
+		t.Fatal("expected errLowReplacementFee, got", err)
+	}
+	checkLast(1, lowFee, original, false, false)
+
+	// A double spend that pays enough evicts the original.
+	replacement := spend(types.SiacoinPrecision.Mul64(2), types.UnlockHash{2})
+	if err := tpt.tpool.AcceptTransactionSet(replacement); err != nil {
+		t.Fatal(err)
+	}
+	checkLast(2, original, replacement, false, true)
+
+	// Mine a block containing a different spend of the output. The
+	// replacement is evicted from the pool.
+	confirmedFee := types.SiacoinPrecision.Mul64(3)
+	confirmed := spend(confirmedFee, types.UnlockHash{3})
+	block, target, err := tpt.miner.BlockForWork()
+	if err != nil {
+		t.Fatal(err)
+	}
+	block.Transactions = confirmed
+	block.MinerPayouts = []types.SiacoinOutput{{
+		Value:      types.CalculateCoinbase(tpt.cs.Height() + 1).Add(confirmedFee),
+		UnlockHash: block.MinerPayouts[0].UnlockHash,
+	}}
+	solvedBlock, solved := tpt.miner.SolveBlock(block, target)
+	if !solved {
+		t.Fatal("failed to solve block")
+	}
+	if err := tpt.cs.AcceptBlock(solvedBlock); err != nil {
+		t.Fatal(err)
+	}
+	checkLast(3, replacement, confirmed, true, true)
+
+	// Submitting the original again should report the confirmed conflict.
+	if err := tpt.tpool.AcceptTransactionSet(original); err == nil {
+		t.Fatal("double spend of a confirmed output was accepted")
+	}
+	checkLast(4, original, confirmed, true, false)
+
+	// Unsubscribed subscribers are not notified.
+	tpt.tpool.DoubleSpendUnsubscribe(dsr)
+	if err := tpt.tpool.AcceptTransactionSet(original); err == nil {
+		t.Fatal("double spend of a confirmed output was accepted")
+	}
+	if len(dsr.doubleSpends) != 4 {
+		t.Fatal("unsubscribed subscriber was notified")
+	}
+}
@@ -0,0 +1,299 @@
+package transactionpool
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+var (
+	// defaultMaxTransactionAge is the amount of time that a transaction may
+	// spend in the transaction pool if the eviction policy has not been
+	// changed.
+	defaultMaxTransactionAge = build.Select(build.Var{
+		Standard: 72 * time.Hour,
+		Dev:      6 * time.Hour,
+		Testing:  time.Hour,
+	}).(time.Duration)
+
+	// evictionInterval is how often the transaction pool checks for
+	// transaction sets that violate the eviction policy.
+	evictionInterval = build.Select(build.Var{
+		Standard: time.Minute,
+		Dev:      10 * time.Second,
+		Testing:  100 * time.Millisecond,
+	}).(time.Duration)
+
+	// defaultMaxPoolSize is the maximum size in bytes of the transaction pool
+	// if the eviction policy has not been changed.
+	defaultMaxPoolSize = build.Select(build.Var{
+		Standard: uint64(20e6),
+		Dev:      uint64(20e6),
+		Testing:  uint64(5e6),
+	}).(uint64)
+
+	// errBelowEvictionFloor is returned if a transaction set pays less than
+	// the sets that were evicted to keep the transaction pool below its
+	// maximum size.
+	errBelowEvictionFloor = errors.New("transaction set pays less than the eviction floor of the full transaction pool")
+
+	// errBelowMinFee is returned if a transaction set pays less than the
+	// minimum fee of the eviction policy.
+	errBelowMinFee = errors.New("transaction set pays less than the minimum fee of the transaction pool")
+
+	// errTransactionExpired is returned if a transaction set contains a
+	// transaction that was evicted for exceeding the maximum age.
+	errTransactionExpired = errors.New("transaction set contains a transaction that was evicted for exceeding the maximum age")
+
+	// errMaxSizeTooSmall is returned if an eviction policy has a nonzero
+	// maximum pool size that could not hold a single transaction set.
+	errMaxSizeTooSmall = errors.New("maximum transaction pool size must be at least the transaction set size limit")
+
+	// errNegativeMaxAge is returned if an eviction policy has a negative
+	// maximum transaction age.
+	errNegativeMaxAge = errors.New("maximum transaction age cannot be negative")
+)
+
+// evictionCandidate is a transaction set in the pool along with the
+// fee-per-byte that it pays.
+type evictionCandidate struct {
+	fee   types.Currency
+	setID TransactionSetID
+}
+
+// candidatesByFee sorts eviction candidates by fee-per-byte, lowest first.
+type candidatesByFee []evictionCandidate
+
+func (cs candidatesByFee) Len() int           { return len(cs) }
+func (cs candidatesByFee) Less(i, j int) bool { return cs[i].fee.Cmp(cs[j].fee) < 0 }
+func (cs candidatesByFee) Swap(i, j int)      { cs[i], cs[j] = cs[j], cs[i] }
+
+// checkEvictionPolicy returns an error if a transaction set would be evicted
+// by the fee floor of the eviction policy as soon as it was accepted, or if
+// it contains a transaction that has already been evicted for its age.
+func (tp *TransactionPool) checkEvictionPolicy(ts []types.Transaction) error {
+	for _, txn := range ts {
+		if _, exists := tp.expiredTransactions[txn.ID()]; exists {
+			return errTransactionExpired
+		}
+	}
+	fee := modules.CalculateFee(ts)
+	if fee.Cmp(tp.evictionPolicy.MinFee) < 0 {
+		return errBelowMinFee
+	}
+	if fee.Cmp(tp.evictionFloor) < 0 {
+		return errBelowEvictionFloor
+	}
+	return nil
+}
+
+// transactionInPool returns true if the transaction is part of a set in the
+// transaction pool.
+func (tp *TransactionPool) transactionInPool(id types.TransactionID) bool {
+	for _, set := range tp.transactionSets {
+		for _, txn := range set {
+			if txn.ID() == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// enforceMaxSize evicts the transaction sets paying the lowest fee-per-byte
+// until the transaction pool is no larger than the maximum size of the
+// eviction policy. The eviction floor is raised above the fee of every
+// evicted set, so that sets paying no more than them are refused until the
+// pool is purged. The return value indicates whether any sets were evicted.
+func (tp *TransactionPool) enforceMaxSize() bool {
+	maxSize := tp.evictionPolicy.MaxSize
+	if maxSize == 0 || uint64(tp.transactionListSize) <= maxSize {
+		return false
+	}
+
+	candidates := make([]evictionCandidate, 0, len(tp.transactionSets))
+	for setID, set := range tp.transactionSets {
+		candidates = append(candidates, evictionCandidate{
+			fee:   modules.CalculateFee(set),
+			setID: setID,
+		})
+	}
+	sort.Sort(candidatesByFee(candidates))
+	for _, c := range candidates {
+		if uint64(tp.transactionListSize) <= maxSize {
+			break
+		}
+		for _, txn := range tp.transactionSets[c.setID] {
+			delete(tp.transactionFirstSeen, txn.ID())
+		}
+		tp.removeTransactionSet(c.setID)
+		if floor := c.fee.Add(types.NewCurrency64(1)); floor.Cmp(tp.evictionFloor) > 0 {
+			tp.evictionFloor = floor
+		}
+	}
+	return true
+}
+
+// recordFirstSeen records the time at which each transaction in a newly
+// accepted set was first seen. Transactions that are already known keep their
+// original time, so that resubmitting a transaction does not reset its age.
+func (tp *TransactionPool) recordFirstSeen(ts []types.Transaction, now time.Time) {
+	for _, txn := range ts {
+		id := txn.ID()
+		if _, exists := tp.transactionFirstSeen[id]; !exists {
+			tp.transactionFirstSeen[id] = now
+		}
+	}
+}
+
+// evictTransactions removes every transaction set that violates the eviction
+// policy. A set has expired if any of its transactions has been in the pool
+// for longer than the maximum age; its transactions are remembered as
+// expired for at least defaultMaxTransactionAge, and for the maximum age if
+// it is longer. Transactions that are no longer in the pool are forgotten.
+// The return value indicates whether any sets were evicted.
+func (tp *TransactionPool) evictTransactions(now time.Time) bool {
+	policy := tp.evictionPolicy
+	retention := defaultMaxTransactionAge
+	if policy.MaxAge > retention {
+		retention = policy.MaxAge
+	}
+	for id, evictedAt := range tp.expiredTransactions {
+		if now.Sub(evictedAt) > retention {
+			delete(tp.expiredTransactions, id)
+		}
+	}
+
+	firstSeen := make(map[types.TransactionID]time.Time)
+	var evicted []TransactionSetID
+	for setID, set := range tp.transactionSets {
+		oldest := now
+		for _, txn := range set {
+			id := txn.ID()
+			// Transactions that were not accepted through AcceptTransactionSet,
+			// such as those returned to the pool by a reorg, start aging now.
+			seen, exists := tp.transactionFirstSeen[id]
+			if !exists {
+				seen = now
+			}
+			firstSeen[id] = seen
+			if seen.Before(oldest) {
+				oldest = seen
+			}
+		}
+		expired := policy.MaxAge > 0 && now.Sub(oldest) > policy.MaxAge
+		if expired {
+			for _, txn := range set {
+				tp.expiredTransactions[txn.ID()] = now
+			}
+		}
+		if expired || modules.CalculateFee(set).Cmp(policy.MinFee) < 0 {
+			evicted = append(evicted, setID)
+		}
+	}
+	for _, setID := range evicted {
+		for _, txn := range tp.transactionSets[setID] {
+			delete(firstSeen, txn.ID())
+		}
+		tp.removeTransactionSet(setID)
+	}
+	tp.transactionFirstSeen = firstSeen
+	return len(evicted) > 0
+}
+
+// managedEvictTransactions evicts the transaction sets that violate the
+// eviction policy, and informs subscribers if any were evicted.
+func (tp *TransactionPool) managedEvictTransactions() {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	if tp.evictTransactions(time.Now()) {
+		tp.updateFeeBacklog()
+		tp.updateSubscribersTransactions()
+	}
+}
+
+// threadedEvictTransactions periodically evicts the transaction sets that
+// violate the eviction policy.
+func (tp *TransactionPool) threadedEvictTransactions() {
+	if err := tp.tg.Add(); err != nil {
+		return
+	}
+	defer tp.tg.Done()
+	for {
+		select {
+		case <-tp.tg.StopChan():
+			return
+		case <-time.After(evictionInterval):
+		}
+		tp.managedEvictTransactions()
+	}
+}
+
+// getEvictionPolicy returns the eviction policy stored in the database, or
+// the default policy if none has been stored.
+func (tp *TransactionPool) getEvictionPolicy(tx *bolt.Tx) (modules.TransactionPoolEvictionPolicy, error) {
+	policy := modules.TransactionPoolEvictionPolicy{
+		MaxAge:  defaultMaxTransactionAge,
+		MaxSize: defaultMaxPoolSize,
+	}
+	policyBytes := tx.Bucket(bucketEvictionPolicy).Get(fieldEvictionPolicy)
+	if policyBytes == nil {
+		return policy, nil
+	}
+	err := encoding.Unmarshal(policyBytes, &policy)
+	return policy, err
+}
+
+// putEvictionPolicy stores the eviction policy in the database.
+func (tp *TransactionPool) putEvictionPolicy(tx *bolt.Tx, policy modules.TransactionPoolEvictionPolicy) error {
+	return tx.Bucket(bucketEvictionPolicy).Put(fieldEvictionPolicy, encoding.Marshal(policy))
+}
+
+// EvictionPolicy returns the policy used to evict transaction sets from the
+// transaction pool.
+func (tp *TransactionPool) EvictionPolicy() modules.TransactionPoolEvictionPolicy {
+	tp.mu.RLock()
+	defer tp.mu.RUnlock()
+	return tp.evictionPolicy
+}
+
+// EvictionFloor returns the fee-per-byte that a transaction set must pay to
+// be accepted while the transaction pool is full.
+func (tp *TransactionPool) EvictionFloor() types.Currency {
+	tp.mu.RLock()
+	defer tp.mu.RUnlock()
+	return tp.evictionFloor
+}
+
+// SetEvictionPolicy sets the policy used to evict transaction sets from the
+// transaction pool. Sets that violate the new policy are evicted immediately.
+func (tp *TransactionPool) SetEvictionPolicy(policy modules.TransactionPoolEvictionPolicy) error {
+	if policy.MaxAge < 0 {
+		return errNegativeMaxAge
+	}
+	if policy.MaxSize != 0 && policy.MaxSize < modules.TransactionSetSizeLimit {
+		return errMaxSizeTooSmall
+	}
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	err := tp.db.Update(func(tx *bolt.Tx) error {
+		return tp.putEvictionPolicy(tx, policy)
+	})
+	if err != nil {
+		return err
+	}
+	tp.evictionPolicy = policy
+	evicted := tp.evictTransactions(time.Now())
+	if tp.enforceMaxSize() || evicted {
+		tp.updateFeeBacklog()
+		tp.updateSubscribersTransactions()
+	}
+	return nil
+}
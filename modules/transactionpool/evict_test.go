@@ -0,0 +1,210 @@
+package transactionpool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestEvictTransactions checks that transaction sets are evicted once they
+// exceed the maximum age or fall below the minimum fee of the eviction policy.
+func TestEvictTransactions(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+
+	// Create a transaction set that pays a small fee.
+	fee := types.SiacoinPrecision
+	txnBuilder := tpt.wallet.StartTransaction()
+	err = txnBuilder.FundSiacoins(fee)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txnBuilder.AddMinerFee(fee)
+	txnSet, err := txnBuilder.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tpt.tpool.AcceptTransactionSet(txnSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The set should survive an eviction pass that does not violate the
+	// policy, and keep its age.
+	tpt.tpool.mu.Lock()
+	seen := tpt.tpool.transactionFirstSeen[txnSet[len(txnSet)-1].ID()]
+	evicted := tpt.tpool.evictTransactions(time.Now())
+	tpt.tpool.mu.Unlock()
+	if evicted || seen.IsZero() {
+		t.Fatal("set was evicted or its age was not recorded")
+	}
+
+	// The set should be evicted once it exceeds the maximum age.
+	tpt.tpool.mu.Lock()
+	evicted = tpt.tpool.evictTransactions(seen.Add(defaultMaxTransactionAge + time.Second))
+	tpt.tpool.mu.Unlock()
+	if !evicted || len(tpt.tpool.TransactionList()) != 0 {
+		t.Fatal("expired set was not evicted")
+	}
+
+	// Resubmitting the expired set should not reset its age. Once the pool
+	// is purged, the set can be added again.
+	err = tpt.tpool.AcceptTransactionSet(txnSet)
+	if err != errTransactionExpired {
+		t.Fatal("expected errTransactionExpired, got", err)
+	}
+	tpt.tpool.PurgeTransactionPool()
+
+	// Add the set again, then raise the fee floor above its fee.
+	err = tpt.tpool.AcceptTransactionSet(txnSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := modules.TransactionPoolEvictionPolicy{
+		MinFee: modules.CalculateFee(txnSet).Add(types.NewCurrency64(1)),
+	}
+	err = tpt.tpool.SetEvictionPolicy(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tpt.tpool.TransactionList()) != 0 {
+		t.Fatal("set below the fee floor was not evicted")
+	}
+	err = tpt.tpool.AcceptTransactionSet(txnSet)
+	if err != errBelowMinFee {
+		t.Fatal("expected errBelowMinFee, got", err)
+	}
+	if !tpt.tpool.EvictionPolicy().MinFee.Equals(policy.MinFee) {
+		t.Fatal("eviction policy was not set")
+	}
+
+	// Negative ages are not allowed.
+	err = tpt.tpool.SetEvictionPolicy(modules.TransactionPoolEvictionPolicy{MaxAge: -1})
+	if err != errNegativeMaxAge {
+		t.Fatal("expected errNegativeMaxAge, got", err)
+	}
+}
+
+// TestIntegrationThreadedEviction checks that the transaction pool evicts
+// expired transaction sets in the background.
+func TestIntegrationThreadedEviction(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+
+	err = tpt.tpool.SetEvictionPolicy(modules.TransactionPoolEvictionPolicy{
+		MaxAge: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tpt.wallet.SendSiacoins(types.SiacoinPrecision, types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tpt.tpool.TransactionList()) == 0 {
+		t.Fatal("transaction was not added to the pool")
+	}
+	for i := 0; i < 50 && len(tpt.tpool.TransactionList()) != 0; i++ {
+		time.Sleep(evictionInterval)
+	}
+	if len(tpt.tpool.TransactionList()) != 0 {
+		t.Fatal("expired transaction was not evicted")
+	}
+}
+
+// TestEnforceMaxSize checks that the transaction sets paying the lowest fees
+// are evicted when the transaction pool exceeds its maximum size, and that
+// sets paying no more than them are refused until the pool is purged.
+func TestEnforceMaxSize(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+
+	// Mine a few more blocks so that the wallet has several mature outputs.
+	for i := 0; i < 3; i++ {
+		_, err = tpt.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Create three independent sets paying increasing fees. All of the sets
+	// are signed before any is accepted, so that they do not spend each
+	// other's outputs.
+	var sets [][]types.Transaction
+	for i := uint64(1); i <= 3; i++ {
+		fee := types.SiacoinPrecision.Mul64(i)
+		txnBuilder := tpt.wallet.StartTransaction()
+		err = txnBuilder.FundSiacoins(fee)
+		if err != nil {
+			t.Fatal(err)
+		}
+		txnBuilder.AddMinerFee(fee)
+		txnSet, err := txnBuilder.Sign(true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sets = append(sets, txnSet)
+	}
+	for _, txnSet := range sets {
+		err = tpt.tpool.AcceptTransactionSet(txnSet)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Shrink the pool so that one set no longer fits. The set paying the
+	// lowest fee should be evicted, and the floor raised above its fee.
+	tpt.tpool.mu.Lock()
+	tpt.tpool.evictionPolicy.MaxSize = uint64(tpt.tpool.transactionListSize - 1)
+	evicted := tpt.tpool.enforceMaxSize()
+	inPool := tpt.tpool.transactionInPool(sets[0][len(sets[0])-1].ID())
+	tpt.tpool.mu.Unlock()
+	if !evicted || inPool {
+		t.Fatal("the set paying the lowest fee was not evicted")
+	}
+	floor := modules.CalculateFee(sets[0]).Add(types.NewCurrency64(1))
+	if !tpt.tpool.EvictionFloor().Equals(floor) {
+		t.Fatal("eviction floor was not raised:", tpt.tpool.EvictionFloor())
+	}
+	if len(tpt.tpool.TransactionList()) != len(sets[1])+len(sets[2]) {
+		t.Fatal("too many sets were evicted")
+	}
+
+	// The evicted set should be refused while the floor is in place.
+	err = tpt.tpool.AcceptTransactionSet(sets[0])
+	if err != errBelowEvictionFloor {
+		t.Fatal("expected errBelowEvictionFloor, got", err)
+	}
+
+	// Purging the pool should reset the floor.
+	tpt.tpool.PurgeTransactionPool()
+	if !tpt.tpool.EvictionFloor().IsZero() {
+		t.Fatal("eviction floor was not reset")
+	}
+
+	// Sizes too small to hold a set are not allowed.
+	err = tpt.tpool.SetEvictionPolicy(modules.TransactionPoolEvictionPolicy{MaxSize: 1})
+	if err != errMaxSizeTooSmall {
+		t.Fatal("expected errMaxSizeTooSmall, got", err)
+	}
+}
@@ -0,0 +1,209 @@
+package transactionpool
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+const (
+	// feeEstimationBlocks is the number of recent blocks that are considered
+	// when estimating fees.
+	feeEstimationBlocks = 20
+
+	// feeMinimumTarget is the confirmation target of the minimum fee returned
+	// by FeeEstimation.
+	feeMinimumTarget = 3
+
+	// maxFeeTarget is the largest confirmation target that can be passed to
+	// FeeEstimate.
+	maxFeeTarget = 144
+)
+
+var (
+	// feeBlockCapacity is the number of bytes of transactions that are
+	// expected to fit in a block, leaving room for the block header and the
+	// miner payouts.
+	feeBlockCapacity = types.BlockSizeLimit - 5e3
+
+	// feeCongestedBlockSize is the size above which a block is considered
+	// congested. The lowest fee in a congested block is a useful indicator of
+	// the fee required to get into the next block. Blocks below this size are
+	// treated as though any fee would have been accepted.
+	feeCongestedBlockSize = types.BlockSizeLimit / 2
+
+	// errInvalidFeeTarget is returned by FeeEstimate if the confirmation
+	// target is zero or larger than maxFeeTarget.
+	errInvalidFeeTarget = errors.New("fee estimation target must be between 1 and 144 blocks")
+
+	// minRecommendedFee and maxRecommendedFee are the fees returned by
+	// FeeEstimation when the network is not congested.
+	//
+	// TODO: The current minimum has been reduced significantly to account for
+	// legacy renters that are not correctly adding transaction fees. The
+	// minimum has been set to 1 siacoin per kb (or 1/1000 SC per byte), but
+	// really should look more like 10 SC per kb. But, legacy renters are using
+	// a much lower value, which means hosts would be incompatible if the
+	// minimum recommended were set to 10. The value has been set to 1, which
+	// should be okay temporarily while the renters are given time to upgrade.
+	minRecommendedFee = types.SiacoinPrecision.Mul64(1).Div64(1e3)
+	maxRecommendedFee = types.SiacoinPrecision.Mul64(5).Div64(1e3)
+)
+
+type (
+	// setFee records the size and fee-per-byte of an unconfirmed transaction
+	// set.
+	setFee struct {
+		size       uint64
+		feePerByte types.Currency
+	}
+
+	// blockFee records the size of a recently confirmed block and the lowest
+	// fee-per-byte paid by one of its transactions.
+	blockFee struct {
+		size          uint64
+		minFeePerByte types.Currency
+	}
+
+	// setFeesByFee sorts unconfirmed transaction sets by fee-per-byte, highest
+	// first.
+	setFeesByFee []setFee
+
+	// currencies sorts a list of currencies from lowest to highest.
+	currencies []types.Currency
+)
+
+func (sf setFeesByFee) Len() int           { return len(sf) }
+func (sf setFeesByFee) Less(i, j int) bool { return sf[i].feePerByte.Cmp(sf[j].feePerByte) > 0 }
+func (sf setFeesByFee) Swap(i, j int)      { sf[i], sf[j] = sf[j], sf[i] }
+
+func (c currencies) Len() int           { return len(c) }
+func (c currencies) Less(i, j int) bool { return c[i].Cmp(c[j]) < 0 }
+func (c currencies) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+
+// newBlockFee summarizes the fees paid in a block. Transactions that do not
+// pay a fee are ignored, as they are typically children of a set whose parent
+// paid the fee.
+func newBlockFee(b types.Block) blockFee {
+	bf := blockFee{
+		size: uint64(len(encoding.Marshal(b))),
+	}
+	found := false
+	for _, txn := range b.Transactions {
+		if len(txn.MinerFees) == 0 {
+			continue
+		}
+		fee := modules.CalculateFee([]types.Transaction{txn})
+		if !found || fee.Cmp(bf.minFeePerByte) < 0 {
+			bf.minFeePerByte = fee
+			found = true
+		}
+	}
+	return bf
+}
+
+// updateFeeBacklog refreshes the summary of the unconfirmed transaction sets
+// used for fee estimation. It should be called whenever the transaction pool
+// changes.
+func (tp *TransactionPool) updateFeeBacklog() {
+	backlog := make([]setFee, 0, len(tp.transactionSets))
+	for _, set := range tp.transactionSets {
+		backlog = append(backlog, setFee{
+			size:       uint64(len(encoding.Marshal(set))),
+			feePerByte: modules.CalculateFee(set),
+		})
+	}
+	sort.Sort(setFeesByFee(backlog))
+
+	tp.feeMu.Lock()
+	tp.feeBacklog = backlog
+	tp.feeMu.Unlock()
+}
+
+// updateRecentBlockFees records the fees of blocks that were applied by a
+// consensus change, and forgets the fees of blocks that were reverted.
+func (tp *TransactionPool) updateRecentBlockFees(cc modules.ConsensusChange) {
+	tp.feeMu.Lock()
+	defer tp.feeMu.Unlock()
+	for range cc.RevertedBlocks {
+		if len(tp.recentBlockFees) > 0 {
+			tp.recentBlockFees = tp.recentBlockFees[:len(tp.recentBlockFees)-1]
+		}
+	}
+	for _, block := range cc.AppliedBlocks {
+		tp.recentBlockFees = append(tp.recentBlockFees, newBlockFee(block))
+	}
+	if len(tp.recentBlockFees) > feeEstimationBlocks {
+		tp.recentBlockFees = append([]blockFee(nil), tp.recentBlockFees[len(tp.recentBlockFees)-feeEstimationBlocks:]...)
+	}
+}
+
+// FeeEstimate returns the fee-per-byte that a transaction set should pay to be
+// confirmed within target blocks. Two estimates are made, and the larger of
+// the two is returned. The first looks at the unconfirmed transactions in the
+// pool: a set must outbid every set that would not fit in the next target
+// blocks. The second looks at the lowest fees that were accepted by recent
+// congested blocks, where a larger target allows a lower percentile to be
+// used. If the network is not congested, the estimate will be zero.
+func (tp *TransactionPool) FeeEstimate(target types.BlockHeight) (types.Currency, error) {
+	if target == 0 || target > maxFeeTarget {
+		return types.Currency{}, errInvalidFeeTarget
+	}
+	tp.feeMu.Lock()
+	defer tp.feeMu.Unlock()
+
+	// Find the fee of the highest paying set that would not fit in the next
+	// target blocks.
+	var backlogFee types.Currency
+	capacity := uint64(target) * feeBlockCapacity
+	var filled uint64
+	for _, sf := range tp.feeBacklog {
+		filled += sf.size
+		if filled > capacity {
+			backlogFee = sf.feePerByte
+			break
+		}
+	}
+
+	// Find the fee that was sufficient for inclusion in all but 1 in
+	// (target+1) of the recent blocks.
+	var recentFee types.Currency
+	if len(tp.recentBlockFees) > 0 {
+		recent := make([]types.Currency, len(tp.recentBlockFees))
+		for i, bf := range tp.recentBlockFees {
+			if bf.size >= feeCongestedBlockSize {
+				recent[i] = bf.minFeePerByte
+			} else {
+				recent[i] = types.ZeroCurrency
+			}
+		}
+		sort.Sort(currencies(recent))
+		recentFee = recent[len(recent)/(int(target)+1)]
+	}
+
+	if backlogFee.Cmp(recentFee) > 0 {
+		return backlogFee, nil
+	}
+	return recentFee, nil
+}
+
+// FeeEstimation returns an estimation for what fee should be applied to
+// transactions. The minimum recommended fee targets confirmation within
+// feeMinimumTarget blocks, and the maximum recommended fee targets the next
+// block. Neither falls below the fees recommended for an uncongested network.
+func (tp *TransactionPool) FeeEstimation() (min, max types.Currency) {
+	min, max = minRecommendedFee, maxRecommendedFee
+	if fee, err := tp.FeeEstimate(feeMinimumTarget); err == nil && fee.Cmp(min) > 0 {
+		min = fee
+	}
+	if fee, err := tp.FeeEstimate(1); err == nil && fee.Cmp(max) > 0 {
+		max = fee
+	}
+	if min.Cmp(max) > 0 {
+		max = min
+	}
+	return min, max
+}
@@ -0,0 +1,135 @@
+package transactionpool
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestFeeEstimate checks that FeeEstimate accounts for both the pool backlog
+// and the fees paid in recent congested blocks.
+func TestFeeEstimate(t *testing.T) {
+	tp := new(TransactionPool)
+
+	// Invalid targets should be rejected.
+	if _, err := tp.FeeEstimate(0); err != errInvalidFeeTarget {
+		t.Fatal("expected errInvalidFeeTarget, got", err)
+	}
+	if _, err := tp.FeeEstimate(maxFeeTarget + 1); err != errInvalidFeeTarget {
+		t.Fatal("expected errInvalidFeeTarget, got", err)
+	}
+
+	// With no backlog and no recent blocks, the estimate should be zero.
+	fee, err := tp.FeeEstimate(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fee.IsZero() {
+		t.Fatal("expected a zero estimate for an empty pool, got", fee)
+	}
+
+	// Fill the pool with a block and a half of transactions. Getting into the
+	// next block requires outbidding the sets that do not fit, but there is
+	// room for everything within two blocks.
+	tp.feeBacklog = []setFee{
+		{size: feeBlockCapacity / 2, feePerByte: types.NewCurrency64(30)},
+		{size: feeBlockCapacity / 2, feePerByte: types.NewCurrency64(20)},
+		{size: feeBlockCapacity / 2, feePerByte: types.NewCurrency64(10)},
+	}
+	if fee, _ := tp.FeeEstimate(1); fee.Cmp(types.NewCurrency64(10)) != 0 {
+		t.Error("expected backlog estimate of 10, got", fee)
+	}
+	if fee, _ := tp.FeeEstimate(2); !fee.IsZero() {
+		t.Error("expected zero estimate for a two block target, got", fee)
+	}
+
+	// Record a history where most recent blocks were congested. Uncongested
+	// blocks count as accepting any fee.
+	tp.feeBacklog = nil
+	for i := 0; i < 10; i++ {
+		tp.recentBlockFees = append(tp.recentBlockFees, blockFee{size: feeCongestedBlockSize, minFeePerByte: types.NewCurrency64(uint64(100 + i))})
+	}
+	for i := 0; i < 2; i++ {
+		tp.recentBlockFees = append(tp.recentBlockFees, blockFee{size: 1e3})
+	}
+	one, _ := tp.FeeEstimate(1)
+	three, _ := tp.FeeEstimate(3)
+	many, _ := tp.FeeEstimate(maxFeeTarget)
+	if one.Cmp(types.NewCurrency64(104)) != 0 {
+		t.Error("expected median estimate of 104, got", one)
+	}
+	if three.Cmp(types.NewCurrency64(101)) != 0 {
+		t.Error("expected estimate of 101 for a three block target, got", three)
+	}
+	if !many.IsZero() {
+		t.Error("expected zero estimate for a distant target, got", many)
+	}
+
+	// FeeEstimation should rise above the static recommendations when the
+	// network is congested.
+	min, max := tp.FeeEstimation()
+	if min.Cmp(minRecommendedFee) != 0 || max.Cmp(maxRecommendedFee) != 0 {
+		t.Error("small fees should not change the recommendations:", min, max)
+	}
+	tp.feeBacklog = []setFee{
+		{size: feeBlockCapacity, feePerByte: maxRecommendedFee.Mul64(3)},
+		{size: feeBlockCapacity, feePerByte: maxRecommendedFee.Mul64(2)},
+	}
+	min, max = tp.FeeEstimation()
+	if min.Cmp(minRecommendedFee) != 0 || max.Cmp(maxRecommendedFee.Mul64(2)) != 0 {
+		t.Error("congested pool did not raise the maximum recommendation:", min, max)
+	}
+}
+
+// TestIntegrationRecentBlockFees checks that the transaction pool tracks the
+// fees of recent blocks and the backlog of unconfirmed transactions.
+func TestIntegrationRecentBlockFees(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+
+	tpt.tpool.feeMu.Lock()
+	numBlocks := len(tpt.tpool.recentBlockFees)
+	tpt.tpool.feeMu.Unlock()
+	if numBlocks == 0 || numBlocks > feeEstimationBlocks {
+		t.Fatal("unexpected number of recent blocks:", numBlocks)
+	}
+
+	// The network is not congested, so the recommendations should not change.
+	min, max := tpt.tpool.FeeEstimation()
+	if min.Cmp(minRecommendedFee) != 0 || max.Cmp(maxRecommendedFee) != 0 {
+		t.Error("unexpected fee recommendations:", min, max)
+	}
+
+	// A transaction sent by the wallet should appear in the backlog, and
+	// disappear once it is mined.
+	_, err = tpt.wallet.SendSiacoins(types.NewCurrency64(100), types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpt.tpool.feeMu.Lock()
+	backlog := len(tpt.tpool.feeBacklog)
+	tpt.tpool.feeMu.Unlock()
+	if backlog != 1 {
+		t.Fatal("expected one set in the backlog, got", backlog)
+	}
+	b, _ := tpt.miner.FindBlock()
+	if err := tpt.cs.AcceptBlock(b); err != nil {
+		t.Fatal(err)
+	}
+	tpt.tpool.feeMu.Lock()
+	backlog = len(tpt.tpool.feeBacklog)
+	last := tpt.tpool.recentBlockFees[len(tpt.tpool.recentBlockFees)-1]
+	tpt.tpool.feeMu.Unlock()
+	if backlog != 0 {
+		t.Fatal("expected an empty backlog, got", backlog)
+	}
+	if last.minFeePerByte.IsZero() {
+		t.Error("fee of the mined transaction was not recorded")
+	}
+}
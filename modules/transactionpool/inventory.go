@@ -0,0 +1,210 @@
+package transactionpool
+
+import (
+	"errors"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// Transaction sets are relayed to peers that support inventory relay by
+// announcing their IDs first. The peer replies with the indices of the
+// announced sets that it does not know, and only those sets are sent, so that
+// a set crosses each connection about once instead of once per peer that
+// relays it. Older peers are sent every set with the RelayTransactionSet RPC.
+
+const (
+	// inventoryRelayVersion is the first version of siad that understands
+	// the AnnounceTxnSets RPC. It is only used for peers that do not share
+	// their capabilities.
+	inventoryRelayVersion = "1.2.0"
+
+	// maxAnnouncedSets is the largest number of transaction set IDs that
+	// are announced in a single call.
+	maxAnnouncedSets = 1000
+)
+
+var (
+	// requestedSetMemory is how long the transaction pool remembers that it
+	// requested an announced set, so that the same set is not requested from
+	// every peer that announces it.
+	requestedSetMemory = build.Select(build.Var{
+		Standard: 10 * time.Minute,
+		Dev:      5 * time.Minute,
+		Testing:  10 * time.Second,
+	}).(time.Duration)
+
+	// errAnnouncedSetMismatch is returned when a peer sends a transaction set
+	// that does not match the ID it announced.
+	errAnnouncedSetMismatch = errors.New("peer sent a transaction set that does not match its announcement")
+
+	// errBadSetRequest is returned when a peer requests a transaction set
+	// that was not announced to it.
+	errBadSetRequest = errors.New("peer requested a transaction set that was not announced")
+)
+
+// wantedSets returns the indices of the announced set IDs that are neither in
+// the pool nor recently requested, and marks them as requested.
+func (tp *TransactionPool) wantedSets(ids []TransactionSetID, now time.Time) []uint64 {
+	for id, requested := range tp.requestedSets {
+		if now.Sub(requested) >= requestedSetMemory {
+			delete(tp.requestedSets, id)
+		}
+	}
+	var wanted []uint64
+	for i, id := range ids {
+		if _, exists := tp.transactionSets[id]; exists {
+			continue
+		}
+		if _, requested := tp.requestedSets[id]; requested {
+			continue
+		}
+		tp.requestedSets[id] = now
+		wanted = append(wanted, uint64(i))
+	}
+	return wanted
+}
+
+// managedAcceptRelayedSet accepts a transaction set relayed by the peer at
+// host, subject to the peer's relay limits.
+func (tp *TransactionPool) managedAcceptRelayedSet(host string, ts []types.Transaction) error {
+	err := tp.managedCheckRelay(host, uint64(len(encoding.Marshal(ts))), time.Now())
+	if err != nil {
+		return err
+	}
+	err = tp.AcceptTransactionSet(ts)
+	if err == nil || err == modules.ErrDuplicateTransactionSet || err == errOrphanTransactionSet {
+		tp.managedRecordRelay(host, ts)
+	}
+	if err == errOrphanTransactionSet {
+		// Sets relayed ahead of their parents are not a failure of the peer.
+		return nil
+	}
+	return err
+}
+
+// rpcAnnounceTxnSets is an RPC that receives the IDs of transaction sets from
+// a peer, requests the sets that the pool does not have, and accepts them.
+func (tp *TransactionPool) rpcAnnounceTxnSets(conn modules.PeerConn) error {
+	err := conn.SetDeadline(time.Now().Add(relayTransactionSetTimeout))
+	if err != nil {
+		return err
+	}
+	// Announcements from a peer that has exceeded its relay limits are
+	// ignored.
+	host := conn.RPCAddr().Host()
+	err = tp.managedCheckRelay(host, 0, time.Now())
+	if err != nil {
+		return err
+	}
+	var ids []TransactionSetID
+	err = encoding.ReadObject(conn, &ids, maxAnnouncedSets*crypto.HashSize+8)
+	if err != nil {
+		return err
+	}
+
+	tp.mu.Lock()
+	wanted := tp.wantedSets(ids, time.Now())
+	tp.mu.Unlock()
+	err = encoding.WriteObject(conn, wanted)
+	if err != nil || len(wanted) == 0 {
+		return err
+	}
+
+	// Read all of the requested sets before accepting them, so that the peer
+	// is not kept waiting while they are validated.
+	sets := make([][]types.Transaction, 0, len(wanted))
+	for _, i := range wanted {
+		var ts []types.Transaction
+		err = encoding.ReadObject(conn, &ts, modules.TransactionSetSizeLimit)
+		if err != nil {
+			return err
+		}
+		if TransactionSetID(crypto.HashObject(ts)) != ids[i] {
+			return errAnnouncedSetMismatch
+		}
+		sets = append(sets, ts)
+	}
+	conn.Close()
+	for _, ts := range sets {
+		if err := tp.managedAcceptRelayedSet(host, ts); err != nil && err != modules.ErrDuplicateTransactionSet {
+			return err
+		}
+	}
+	return nil
+}
+
+// managedAnnounceTransactionSets announces the sets to the peer at addr,
+// sending the sets that the peer requests.
+func (tp *TransactionPool) managedAnnounceTransactionSets(addr modules.NetAddress, sets [][]types.Transaction) error {
+	ids := make([]TransactionSetID, len(sets))
+	for i, ts := range sets {
+		ids[i] = TransactionSetID(crypto.HashObject(ts))
+	}
+	return tp.gateway.RPC(addr, "AnnounceTxnSets", func(conn modules.PeerConn) error {
+		err := conn.SetDeadline(time.Now().Add(relayTransactionSetTimeout))
+		if err != nil {
+			return err
+		}
+		err = encoding.WriteObject(conn, ids)
+		if err != nil {
+			return err
+		}
+		var wanted []uint64
+		err = encoding.ReadObject(conn, &wanted, uint64(len(ids))*8+8)
+		if err != nil {
+			return err
+		}
+		for _, i := range wanted {
+			if i >= uint64(len(sets)) {
+				return errBadSetRequest
+			}
+			err = encoding.WriteObject(conn, sets[i])
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// relayTransactionSets relays transaction sets to the gateway's peers,
+// announcing them to peers that support inventory relay and sending them
+// outright to older peers. Peers that do not respond to an announcement are
+// not retried, as the sets are rebroadcast if they stay unconfirmed.
+func (tp *TransactionPool) relayTransactionSets(sets [][]types.Transaction, peers []modules.Peer) {
+	if err := tp.tg.Add(); err != nil {
+		return
+	}
+	defer tp.tg.Done()
+
+	var legacy []modules.Peer
+	for _, p := range peers {
+		if !p.Supports(modules.CapabilityInventoryRelay, inventoryRelayVersion) {
+			legacy = append(legacy, p)
+			continue
+		}
+		for start := 0; start < len(sets); start += maxAnnouncedSets {
+			end := start + maxAnnouncedSets
+			if end > len(sets) {
+				end = len(sets)
+			}
+			go func(addr modules.NetAddress, sets [][]types.Transaction) {
+				if err := tp.tg.Add(); err != nil {
+					return
+				}
+				defer tp.tg.Done()
+				tp.managedAnnounceTransactionSets(addr, sets)
+			}(p.NetAddress, sets[start:end])
+		}
+	}
+	if len(legacy) > 0 {
+		for _, ts := range sets {
+			go tp.gateway.Broadcast("RelayTransactionSet", ts, legacy)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package transactionpool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestWantedSets checks that only announced sets that are neither in the pool
+// nor recently requested are requested.
+func TestWantedSets(t *testing.T) {
+	tp := &TransactionPool{
+		transactionSets: make(map[TransactionSetID][]types.Transaction),
+		requestedSets:   make(map[TransactionSetID]time.Time),
+	}
+	inPool := TransactionSetID(crypto.Hash{1})
+	tp.transactionSets[inPool] = nil
+	ids := []TransactionSetID{inPool, {2}, {3}}
+
+	now := time.Now()
+	wanted := tp.wantedSets(ids, now)
+	if len(wanted) != 2 || wanted[0] != 1 || wanted[1] != 2 {
+		t.Fatal("expected sets 1 and 2 to be wanted, got", wanted)
+	}
+	// Sets that were just requested are not requested again.
+	if wanted := tp.wantedSets(ids, now.Add(time.Second)); len(wanted) != 0 {
+		t.Fatal("recently requested sets were requested again:", wanted)
+	}
+	// Requests are forgotten after a while.
+	if wanted := tp.wantedSets(ids, now.Add(requestedSetMemory)); len(wanted) != 2 {
+		t.Fatal("expected requests to be forgotten, got", wanted)
+	}
+}
+
+// TestIntegrationInventoryRelay checks that transaction sets are relayed
+// between peers by announcing their IDs.
+func TestIntegrationInventoryRelay(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	tpt1, err := createTpoolTester(t.Name() + "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt1.Close()
+	tpt2, err := createTpoolTester(t.Name() + "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt2.Close()
+
+	// Only the announcement RPC is available, so the set can only reach tpt2
+	// through inventory relay.
+	tpt2.gateway.UnregisterRPC("RelayTransactionSet")
+	defer tpt2.gateway.RegisterRPC("RelayTransactionSet", tpt2.tpool.relayTransactionSet)
+	// Mine a block so that tpt2 synchronizes to tpt1's longer chain upon
+	// connecting, and can validate tpt1's transactions.
+	if _, err := tpt1.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tpt2.gateway.Connect(tpt1.gateway.Address()); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50 && tpt1.cs.CurrentBlock().ID() != tpt2.cs.CurrentBlock().ID(); i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if tpt1.cs.CurrentBlock().ID() != tpt2.cs.CurrentBlock().ID() {
+		t.Fatal("tpt2 did not synchronize to tpt1")
+	}
+
+	txns, err := tpt1.wallet.SendSiacoins(types.NewCurrency64(1), types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inPool := func() bool {
+		tpt2.tpool.mu.Lock()
+		defer tpt2.tpool.mu.Unlock()
+		return tpt2.tpool.transactionInPool(txns[len(txns)-1].ID())
+	}
+	for i := 0; i < 50 && !inPool(); i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if !inPool() {
+		t.Fatal("transaction set was not relayed to tpt2")
+	}
+
+	// Announcing the set again does not send it, as tpt2 already has it.
+	tpt2.tpool.mu.Lock()
+	wanted := tpt2.tpool.wantedSets([]TransactionSetID{TransactionSetID(crypto.HashObject(txns))}, time.Now())
+	tpt2.tpool.mu.Unlock()
+	if len(wanted) != 0 {
+		t.Fatal("set in the pool is wanted:", wanted)
+	}
+}
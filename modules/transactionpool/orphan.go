@@ -0,0 +1,146 @@
+package transactionpool
+
+import (
+	"errors"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	errOrphanTransactionSet = errors.New("transaction set spends outputs that are not known yet, it will be accepted if its parents arrive")
+	errFullOrphanPool       = errors.New("transaction set spends outputs that are not known yet, and is too large to be held until its parents arrive")
+
+	// maxOrphanSets is the maximum number of orphan transaction sets that
+	// are held by the pool, and maxOrphanPoolSize is the maximum combined
+	// size of the orphan sets in bytes. When either limit is reached, the
+	// oldest orphans are dropped to make room.
+	maxOrphanSets = build.Select(build.Var{
+		Standard: 100,
+		Dev:      50,
+		Testing:  3,
+	}).(int)
+	maxOrphanPoolSize = build.Select(build.Var{
+		Standard: int(2e6),
+		Dev:      int(2e6),
+		Testing:  int(100e3),
+	}).(int)
+
+	// orphanTimeout is how long an orphan set is held while waiting for its
+	// parents. Orphans with parents that never arrive may be spending outputs
+	// that do not exist or were spent long ago.
+	orphanTimeout = build.Select(build.Var{
+		Standard: 10 * time.Minute,
+		Dev:      2 * time.Minute,
+		Testing:  10 * time.Second,
+	}).(time.Duration)
+)
+
+// An orphanSet is a transaction set that spends outputs, or references file
+// contracts, which are neither in the consensus set nor in the pool. Orphans usually arrive from peers ahead
+// of their parents during bursts of transactions, and are held until the
+// parents arrive.
+type orphanSet struct {
+	id    TransactionSetID
+	txns  []types.Transaction
+	size  int
+	added time.Time
+}
+
+// isMissingParentErr returns true if err indicates that a transaction set
+// spends an output, or references a file contract, that does not exist.
+func isMissingParentErr(err error) bool {
+	return err == modules.ErrMissingSiacoinOutput ||
+		err == modules.ErrMissingSiafundOutput ||
+		err == modules.ErrMissingFileContract
+}
+
+// removeOrphan removes the orphan at index i.
+func (tp *TransactionPool) removeOrphan(i int) {
+	tp.orphanPoolSize -= tp.orphans[i].size
+	tp.orphans = append(tp.orphans[:i], tp.orphans[i+1:]...)
+}
+
+// pruneOrphans drops the orphans that have been held for longer than
+// orphanTimeout.
+func (tp *TransactionPool) pruneOrphans(now time.Time) {
+	for i := 0; i < len(tp.orphans); {
+		if now.Sub(tp.orphans[i].added) > orphanTimeout {
+			tp.removeOrphan(i)
+			continue
+		}
+		i++
+	}
+}
+
+// addOrphan holds a transaction set that could not be accepted because its
+// parents are unknown. A set that spends an output which was spent by a
+// recently confirmed transaction is a double spend rather than an orphan, and
+// is rejected. The oldest orphans are dropped if the orphan pool is full.
+func (tp *TransactionPool) addOrphan(ts []types.Transaction, now time.Time) error {
+	for i := len(tp.recentSpends) - 1; i >= 0; i-- {
+		if len(conflictingTransactions(ts, tp.recentSpends[i])) > 0 {
+			tp.reportRejectedSet(ts)
+			return modules.NewConsensusConflict("transaction set spends outputs that have already been spent")
+		}
+	}
+
+	tp.pruneOrphans(now)
+	id := TransactionSetID(crypto.HashObject(ts))
+	for _, orphan := range tp.orphans {
+		if orphan.id == id {
+			return errOrphanTransactionSet
+		}
+	}
+	size := len(encoding.Marshal(ts))
+	if size > maxOrphanPoolSize {
+		return errFullOrphanPool
+	}
+	for len(tp.orphans) >= maxOrphanSets || tp.orphanPoolSize+size > maxOrphanPoolSize {
+		tp.removeOrphan(0)
+	}
+	tp.orphans = append(tp.orphans, orphanSet{
+		id:    id,
+		txns:  ts,
+		size:  size,
+		added: now,
+	})
+	tp.orphanPoolSize += size
+	return errOrphanTransactionSet
+}
+
+// promoteOrphans tries to add every orphan to the pool, in the order that
+// they arrived. Orphans whose parents are still unknown stay in the orphan
+// pool, and orphans that are invalid for any other reason are dropped. The
+// orphans are retried until no more can be promoted, so that chains of
+// orphans are resolved. Promoted sets are relayed to peers. true is returned
+// if any orphans were promoted.
+func (tp *TransactionPool) promoteOrphans(txnFn func([]types.Transaction) (modules.ConsensusChange, error)) bool {
+	now := time.Now()
+	tp.pruneOrphans(now)
+	promoted := false
+	for progress := true; progress; {
+		progress = false
+		for i := 0; i < len(tp.orphans); {
+			ts := tp.orphans[i].txns
+			err := tp.acceptTransactionSet(ts, txnFn)
+			if err == errOrphanTransactionSet {
+				i++
+				continue
+			}
+			tp.removeOrphan(i)
+			if err != nil {
+				continue
+			}
+			progress, promoted = true, true
+			tp.recordFirstSeen(ts, now)
+			go tp.relayTransactionSets([][]types.Transaction{ts}, tp.gateway.Peers())
+			tp.scheduleRebroadcast(ts)
+		}
+	}
+	return promoted
+}
@@ -0,0 +1,151 @@
+package transactionpool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// orphanTestSet returns a parent transaction and a child that spends its
+// output, funded by the wallet of the tester.
+func orphanTestSet(t *testing.T, tpt *tpoolTester) (parent, child types.Transaction) {
+	fund := types.NewCurrency64(30e6)
+	txnBuilder := tpt.wallet.StartTransaction()
+	err := txnBuilder.FundSiacoins(fund)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txnBuilder.AddMinerFee(fund)
+	txnSet, err := txnBuilder.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txnSet) != 2 {
+		t.Fatal("expected a parent and a child, got", len(txnSet))
+	}
+	return txnSet[0], txnSet[1]
+}
+
+// TestOrphanPromotion checks that a transaction set which arrives before its
+// parent is held, and promoted into the pool when the parent arrives.
+func TestOrphanPromotion(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+
+	parent, child := orphanTestSet(t, tpt)
+	err = tpt.tpool.AcceptTransactionSet([]types.Transaction{child})
+	if err != errOrphanTransactionSet {
+		t.Fatal("expected errOrphanTransactionSet, got", err)
+	}
+	if len(tpt.tpool.TransactionList()) != 0 || len(tpt.tpool.orphans) != 1 {
+		t.Fatal("the orphan was not held outside of the pool")
+	}
+
+	err = tpt.tpool.AcceptTransactionSet([]types.Transaction{parent})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tpt.tpool.TransactionList()) != 2 || len(tpt.tpool.orphans) != 0 {
+		t.Fatal("the orphan was not promoted when its parent arrived")
+	}
+}
+
+// TestOrphanPromotionOnBlock checks that an orphan is promoted when its parent
+// is confirmed in a block.
+func TestOrphanPromotionOnBlock(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+
+	parent, child := orphanTestSet(t, tpt)
+	err = tpt.tpool.AcceptTransactionSet([]types.Transaction{child})
+	if err != errOrphanTransactionSet {
+		t.Fatal("expected errOrphanTransactionSet, got", err)
+	}
+
+	block, target, err := tpt.miner.BlockForWork()
+	if err != nil {
+		t.Fatal(err)
+	}
+	block.Transactions = append(block.Transactions, parent)
+	solvedBlock, solved := tpt.miner.SolveBlock(block, target)
+	if !solved {
+		t.Fatal("failed to solve block")
+	}
+	err = tpt.cs.AcceptBlock(solvedBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tl := tpt.tpool.TransactionList()
+	if len(tl) != 1 || tl[0].ID() != child.ID() {
+		t.Fatal("the orphan was not promoted when its parent was confirmed")
+	}
+}
+
+// TestOrphanPoolLimits checks that the orphan pool drops its oldest orphans
+// when it is full, and drops orphans that have been held too long.
+func TestOrphanPoolLimits(t *testing.T) {
+	tp := &TransactionPool{}
+	now := time.Now()
+	orphan := func(i int) []types.Transaction {
+		return []types.Transaction{{ArbitraryData: [][]byte{{byte(i)}}}}
+	}
+	for i := 0; i < maxOrphanSets+1; i++ {
+		if err := tp.addOrphan(orphan(i), now); err != errOrphanTransactionSet {
+			t.Fatal(err)
+		}
+	}
+	if len(tp.orphans) != maxOrphanSets || tp.orphans[0].txns[0].ArbitraryData[0][0] != 1 {
+		t.Fatal("the oldest orphan was not dropped")
+	}
+	if err := tp.addOrphan(orphan(1), now); err != errOrphanTransactionSet || len(tp.orphans) != maxOrphanSets {
+		t.Fatal("a duplicate orphan was added")
+	}
+
+	// A large orphan can push out several smaller ones, but an orphan larger
+	// than the whole pool is refused.
+	large := []types.Transaction{{ArbitraryData: [][]byte{make([]byte, maxOrphanPoolSize/2)}}}
+	if err := tp.addOrphan(large, now); err != errOrphanTransactionSet || tp.orphanPoolSize > maxOrphanPoolSize {
+		t.Fatal("the orphan pool grew too large:", tp.orphanPoolSize)
+	}
+	huge := []types.Transaction{{ArbitraryData: [][]byte{make([]byte, maxOrphanPoolSize)}}}
+	if err := tp.addOrphan(huge, now); err != errFullOrphanPool {
+		t.Fatal("expected errFullOrphanPool, got", err)
+	}
+
+	// Orphans expire after orphanTimeout.
+	tp.pruneOrphans(now.Add(orphanTimeout + time.Second))
+	if len(tp.orphans) != 0 || tp.orphanPoolSize != 0 {
+		t.Fatal("orphans did not expire")
+	}
+}
+
+// TestIsMissingParentErr checks that every error for a missing output or file
+// contract is treated as a missing parent.
+func TestIsMissingParentErr(t *testing.T) {
+	for _, err := range []error{
+		modules.ErrMissingSiacoinOutput,
+		modules.ErrMissingSiafundOutput,
+		modules.ErrMissingFileContract,
+	} {
+		if !isMissingParentErr(err) {
+			t.Error("not treated as a missing parent:", err)
+		}
+	}
+	if isMissingParentErr(modules.ErrDuplicateTransactionSet) || isMissingParentErr(nil) {
+		t.Error("unrelated error treated as a missing parent")
+	}
+}
@@ -21,6 +21,10 @@ var (
 	// been confirmed on the blockchain.
 	bucketConfirmedTransactions = []byte("ConfirmedTransactions")
 
+	// bucketEvictionPolicy holds the policy used to evict transaction sets
+	// from the transaction pool.
+	bucketEvictionPolicy = []byte("EvictionPolicy")
+
 	// errNilConsensusChange is returned if there is no consensus change in the
 	// database.
 	errNilConsensusChange = errors.New("no consensus change found")
@@ -28,6 +32,10 @@ var (
 	// fieldRecentConsensusChange is the field in bucketRecentConsensusChange
 	// that holds the value of the most recent consensus change.
 	fieldRecentConsensusChange = []byte("RecentConsensusChange")
+
+	// fieldEvictionPolicy is the field in bucketEvictionPolicy that holds the
+	// eviction policy.
+	fieldEvictionPolicy = []byte("EvictionPolicy")
 )
 
 // resetDB deletes all consensus related persistence from the transaction pool.
@@ -65,6 +73,8 @@ func (tp *TransactionPool) initPersist() error {
 		buckets := [][]byte{
 			bucketRecentConsensusChange,
 			bucketConfirmedTransactions,
+			bucketEvictionPolicy,
+			bucketRelayLimits,
 		}
 		for _, bucket := range buckets {
 			_, err := tx.CreateBucketIfNotExists(bucket)
@@ -73,6 +83,18 @@ func (tp *TransactionPool) initPersist() error {
 			}
 		}
 
+		// Load the eviction policy.
+		tp.evictionPolicy, err = tp.getEvictionPolicy(tx)
+		if err != nil {
+			return err
+		}
+
+		// Load the relay limits.
+		tp.relayLimits, err = tp.getRelayLimits(tx)
+		if err != nil {
+			return err
+		}
+
 		// Get the recent consensus change.
 		cc, err = tp.getRecentConsensusChange(tx)
 		if err == errNilConsensusChange {
@@ -0,0 +1,117 @@
+package transactionpool
+
+import (
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	// rebroadcastInterval is the number of blocks that a transaction set may
+	// stay unconfirmed before it is relayed to peers again. Peers may drop
+	// transactions during network hiccups or restarts, leaving them stuck in
+	// the local pool.
+	rebroadcastInterval = build.Select(build.Var{
+		Standard: types.BlockHeight(6),
+		Dev:      types.BlockHeight(3),
+		Testing:  types.BlockHeight(1),
+	}).(types.BlockHeight)
+
+	// maxRebroadcastInterval is the longest that the rebroadcast interval of
+	// a transaction set can grow to. The interval doubles after every
+	// rebroadcast, so that sets which cannot be confirmed do not flood peers.
+	maxRebroadcastInterval = build.Select(build.Var{
+		Standard: types.BlockHeight(144),
+		Dev:      types.BlockHeight(24),
+		Testing:  types.BlockHeight(4),
+	}).(types.BlockHeight)
+)
+
+// rebroadcastState tracks when a transaction should next be relayed to peers.
+type rebroadcastState struct {
+	next     types.BlockHeight
+	interval types.BlockHeight
+}
+
+// scheduleRebroadcast schedules the transactions of a newly broadcast set to
+// be rebroadcast if they are still unconfirmed after rebroadcastInterval
+// blocks. Transactions that are already scheduled keep their schedule.
+func (tp *TransactionPool) scheduleRebroadcast(ts []types.Transaction) {
+	for _, txn := range ts {
+		id := txn.ID()
+		if _, exists := tp.rebroadcasts[id]; !exists {
+			tp.rebroadcasts[id] = rebroadcastState{
+				next:     tp.blockHeight + rebroadcastInterval,
+				interval: rebroadcastInterval,
+			}
+		}
+	}
+}
+
+// updateBlockHeight updates the number of blocks the transaction pool has
+// seen. The height is only used to schedule rebroadcasts, so it does not need
+// to match the height of the consensus set.
+func (tp *TransactionPool) updateBlockHeight(cc modules.ConsensusChange) {
+	for range cc.RevertedBlocks {
+		if tp.blockHeight > 0 {
+			tp.blockHeight--
+		}
+	}
+	tp.blockHeight += types.BlockHeight(len(cc.AppliedBlocks))
+}
+
+// dueRebroadcasts returns the transaction sets in the pool that contain a
+// transaction due to be rebroadcast, and reschedules their transactions with
+// a doubled interval. Transactions that are no longer in the pool are
+// forgotten.
+func (tp *TransactionPool) dueRebroadcasts() [][]types.Transaction {
+	var due [][]types.Transaction
+	rebroadcasts := make(map[types.TransactionID]rebroadcastState)
+	for _, set := range tp.transactionSets {
+		isDue := false
+		for _, txn := range set {
+			state, exists := tp.rebroadcasts[txn.ID()]
+			// Transactions that were not accepted through
+			// AcceptTransactionSet, such as those returned to the pool by a
+			// reorg, are scheduled now.
+			if !exists {
+				state = rebroadcastState{
+					next:     tp.blockHeight + rebroadcastInterval,
+					interval: rebroadcastInterval,
+				}
+			}
+			isDue = isDue || tp.blockHeight >= state.next
+			rebroadcasts[txn.ID()] = state
+		}
+		if !isDue {
+			continue
+		}
+		due = append(due, set)
+		for _, txn := range set {
+			state := rebroadcasts[txn.ID()]
+			state.interval *= 2
+			if state.interval > maxRebroadcastInterval {
+				state.interval = maxRebroadcastInterval
+			}
+			state.next = tp.blockHeight + state.interval
+			rebroadcasts[txn.ID()] = state
+		}
+	}
+	tp.rebroadcasts = rebroadcasts
+	return due
+}
+
+// rebroadcastTransactionSets relays the transaction sets that have stayed
+// unconfirmed for longer than their rebroadcast interval to peers. Nothing is
+// relayed while the consensus set is syncing, as peers would not be able to
+// validate the sets.
+func (tp *TransactionPool) rebroadcastTransactionSets(synced bool) {
+	if !synced {
+		return
+	}
+	due := tp.dueRebroadcasts()
+	if len(due) == 0 {
+		return
+	}
+	go tp.relayTransactionSets(due, tp.gateway.Peers())
+}
@@ -0,0 +1,75 @@
+package transactionpool
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestDueRebroadcasts checks that unconfirmed transaction sets are
+// rebroadcast with an increasing interval, and that sets which leave the pool
+// are no longer tracked.
+func TestDueRebroadcasts(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+
+	txnSet, err := tpt.wallet.SendSiacoins(types.SiacoinPrecision, types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tpt.tpool.mu.Lock()
+	defer tpt.tpool.mu.Unlock()
+	start := tpt.tpool.blockHeight
+	if len(tpt.tpool.rebroadcasts) != len(txnSet) {
+		t.Fatal("accepted transactions were not scheduled for rebroadcast")
+	}
+
+	// Walk forward one block at a time, recording the heights at which the set
+	// is due. The interval starts at rebroadcastInterval and doubles up to
+	// maxRebroadcastInterval.
+	var dueAt []types.BlockHeight
+	for i := types.BlockHeight(0); i <= 3*maxRebroadcastInterval; i++ {
+		tpt.tpool.blockHeight = start + i
+		due := tpt.tpool.dueRebroadcasts()
+		if len(due) > 1 {
+			t.Fatal("expected at most one set to be due, got", len(due))
+		} else if len(due) == 1 {
+			dueAt = append(dueAt, i)
+		}
+	}
+	var expected []types.BlockHeight
+	interval := rebroadcastInterval
+	for h := rebroadcastInterval; h <= 3*maxRebroadcastInterval; h += interval {
+		expected = append(expected, h)
+		interval *= 2
+		if interval > maxRebroadcastInterval {
+			interval = maxRebroadcastInterval
+		}
+	}
+	if len(dueAt) != len(expected) {
+		t.Fatal("set was rebroadcast at", dueAt, "expected", expected)
+	}
+	for i := range dueAt {
+		if dueAt[i] != expected[i] {
+			t.Fatal("set was rebroadcast at", dueAt, "expected", expected)
+		}
+	}
+
+	// Once the set leaves the pool it should be forgotten.
+	for setID := range tpt.tpool.transactionSets {
+		tpt.tpool.removeTransactionSet(setID)
+	}
+	if due := tpt.tpool.dueRebroadcasts(); len(due) != 0 {
+		t.Fatal("set that left the pool was rebroadcast")
+	}
+	if len(tpt.tpool.rebroadcasts) != 0 {
+		t.Fatal("set that left the pool is still scheduled for rebroadcast")
+	}
+}
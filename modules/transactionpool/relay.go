@@ -0,0 +1,186 @@
+package transactionpool
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+var (
+	// bucketRelayLimits holds the limits on the transaction sets that each
+	// peer may relay to the transaction pool.
+	bucketRelayLimits = []byte("RelayLimits")
+
+	// fieldRelayLimits is the field in bucketRelayLimits that holds the relay
+	// limits.
+	fieldRelayLimits = []byte("RelayLimits")
+
+	// defaultRelayLimits are the relay limits used if the limits have not
+	// been changed. A peer relaying honestly forwards each set once, so the
+	// limits are well above the rate at which the network produces
+	// transactions.
+	defaultRelayLimits = build.Select(build.Var{
+		Standard: modules.TransactionPoolRelayLimits{
+			MaxSets:     300,
+			MaxBytes:    10e6,
+			Interval:    time.Minute,
+			BanDuration: 10 * time.Minute,
+		},
+		Dev: modules.TransactionPoolRelayLimits{
+			MaxSets:     300,
+			MaxBytes:    10e6,
+			Interval:    time.Minute,
+			BanDuration: time.Minute,
+		},
+		Testing: modules.TransactionPoolRelayLimits{
+			MaxSets:     1000,
+			MaxBytes:    50e6,
+			Interval:    time.Minute,
+			BanDuration: 10 * time.Second,
+		},
+	}).(modules.TransactionPoolRelayLimits)
+
+	// errRelayPeerBanned is returned when a banned peer relays a transaction
+	// set.
+	errRelayPeerBanned = errors.New("peer has exceeded its relay limits and is temporarily ignored")
+
+	// errRelayRateExceeded is returned when a peer relays more than its
+	// limits allow.
+	errRelayRateExceeded = errors.New("peer has exceeded its relay limits")
+
+	// errRelayInterval is returned by SetRelayLimits if the interval is not
+	// positive.
+	errRelayInterval = errors.New("relay interval must be positive")
+
+	// errNegativeBanDuration is returned by SetRelayLimits if the ban
+	// duration is negative.
+	errNegativeBanDuration = errors.New("relay ban duration cannot be negative")
+)
+
+// relayPeer tracks the transaction sets relayed by a peer in the current
+// relay interval.
+type relayPeer struct {
+	windowStart time.Time
+	sets        uint64
+	bytes       uint64
+	ignored     uint64
+	bannedUntil time.Time
+}
+
+// relayPeersByHost sorts relay peers by host.
+type relayPeersByHost []modules.TransactionPoolRelayPeer
+
+func (rp relayPeersByHost) Len() int           { return len(rp) }
+func (rp relayPeersByHost) Less(i, j int) bool { return rp[i].Host < rp[j].Host }
+func (rp relayPeersByHost) Swap(i, j int)      { rp[i], rp[j] = rp[j], rp[i] }
+
+// managedCheckRelay records that the peer at host is relaying a transaction
+// set of the provided size, and returns an error if the set should be
+// ignored. A size of zero checks whether the peer is banned without counting
+// a set, so that a banned peer is ignored before its set is decoded.
+func (tp *TransactionPool) managedCheckRelay(host string, size uint64, now time.Time) error {
+	tp.relayMu.Lock()
+	defer tp.relayMu.Unlock()
+
+	// Forget peers that have been quiet for a whole interval and are not
+	// banned.
+	limits := tp.relayLimits
+	for h, p := range tp.relayPeers {
+		if now.Sub(p.windowStart) >= limits.Interval && !now.Before(p.bannedUntil) {
+			delete(tp.relayPeers, h)
+		}
+	}
+
+	p, exists := tp.relayPeers[host]
+	if !exists {
+		p = &relayPeer{windowStart: now}
+		tp.relayPeers[host] = p
+	}
+	if now.Before(p.bannedUntil) {
+		p.ignored++
+		return errRelayPeerBanned
+	}
+	if size == 0 {
+		return nil
+	}
+	if now.Sub(p.windowStart) >= limits.Interval {
+		p.windowStart = now
+		p.sets, p.bytes = 0, 0
+	}
+	if (limits.MaxSets != 0 && p.sets+1 > limits.MaxSets) || (limits.MaxBytes != 0 && p.bytes+size > limits.MaxBytes) {
+		p.ignored++
+		if limits.BanDuration > 0 {
+			p.bannedUntil = now.Add(limits.BanDuration)
+		}
+		return errRelayRateExceeded
+	}
+	p.sets++
+	p.bytes += size
+	return nil
+}
+
+// getRelayLimits returns the relay limits stored in the database, or the
+// default limits if none have been stored.
+func (tp *TransactionPool) getRelayLimits(tx *bolt.Tx) (modules.TransactionPoolRelayLimits, error) {
+	limitsBytes := tx.Bucket(bucketRelayLimits).Get(fieldRelayLimits)
+	if limitsBytes == nil {
+		return defaultRelayLimits, nil
+	}
+	var limits modules.TransactionPoolRelayLimits
+	err := encoding.Unmarshal(limitsBytes, &limits)
+	return limits, err
+}
+
+// RelayLimits returns the limits on the transaction sets that each peer may
+// relay to the transaction pool.
+func (tp *TransactionPool) RelayLimits() modules.TransactionPoolRelayLimits {
+	tp.relayMu.Lock()
+	defer tp.relayMu.Unlock()
+	return tp.relayLimits
+}
+
+// RelayPeers returns the relay activity of the peers that have relayed
+// transaction sets recently or are banned, sorted by host.
+func (tp *TransactionPool) RelayPeers() []modules.TransactionPoolRelayPeer {
+	tp.relayMu.Lock()
+	defer tp.relayMu.Unlock()
+	var peers []modules.TransactionPoolRelayPeer
+	for host, p := range tp.relayPeers {
+		peers = append(peers, modules.TransactionPoolRelayPeer{
+			Host:        host,
+			Sets:        p.sets,
+			Bytes:       p.bytes,
+			Ignored:     p.ignored,
+			BannedUntil: p.bannedUntil,
+		})
+	}
+	sort.Sort(relayPeersByHost(peers))
+	return peers
+}
+
+// SetRelayLimits sets the limits on the transaction sets that each peer may
+// relay to the transaction pool. Peers that are banned stay banned.
+func (tp *TransactionPool) SetRelayLimits(limits modules.TransactionPoolRelayLimits) error {
+	if limits.Interval <= 0 {
+		return errRelayInterval
+	}
+	if limits.BanDuration < 0 {
+		return errNegativeBanDuration
+	}
+	tp.relayMu.Lock()
+	defer tp.relayMu.Unlock()
+	err := tp.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketRelayLimits).Put(fieldRelayLimits, encoding.Marshal(limits))
+	})
+	if err != nil {
+		return err
+	}
+	tp.relayLimits = limits
+	return nil
+}
@@ -0,0 +1,99 @@
+package transactionpool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestCheckRelay checks that peers relaying more than the relay limits allow
+// are throttled, and banned if the limits have a ban duration.
+func TestCheckRelay(t *testing.T) {
+	tp := &TransactionPool{
+		relayLimits: modules.TransactionPoolRelayLimits{
+			MaxSets:  2,
+			MaxBytes: 1000,
+			Interval: time.Minute,
+		},
+		relayPeers: make(map[string]*relayPeer),
+	}
+	now := time.Now()
+
+	// The third set within an interval is throttled, but other peers and
+	// later intervals are unaffected.
+	for i := 0; i < 2; i++ {
+		if err := tp.managedCheckRelay("foo", 10, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tp.managedCheckRelay("foo", 10, now); err != errRelayRateExceeded {
+		t.Fatal("expected errRelayRateExceeded, got", err)
+	}
+	if err := tp.managedCheckRelay("bar", 10, now); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Minute)
+	if err := tp.managedCheckRelay("foo", 10, now); err != nil {
+		t.Fatal(err)
+	}
+	if len(tp.relayPeers) != 1 {
+		t.Fatal("quiet peers were not forgotten:", len(tp.relayPeers))
+	}
+
+	// Exceeding the byte limit bans the peer when the limits have a ban
+	// duration.
+	tp.relayLimits.BanDuration = time.Hour
+	if err := tp.managedCheckRelay("foo", 1000, now); err != errRelayRateExceeded {
+		t.Fatal("expected errRelayRateExceeded, got", err)
+	}
+	now = now.Add(2 * time.Minute)
+	if err := tp.managedCheckRelay("foo", 0, now); err != errRelayPeerBanned {
+		t.Fatal("expected errRelayPeerBanned, got", err)
+	}
+	peers := tp.RelayPeers()
+	if len(peers) != 1 || peers[0].Host != "foo" || peers[0].Ignored != 2 || !peers[0].BannedUntil.After(now) {
+		t.Fatal("wrong relay peers:", peers)
+	}
+	now = now.Add(time.Hour)
+	if err := tp.managedCheckRelay("foo", 10, now); err != nil {
+		t.Fatal("the ban did not expire:", err)
+	}
+}
+
+// TestSetRelayLimits checks that the relay limits are validated and
+// persisted.
+func TestSetRelayLimits(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+
+	if tpt.tpool.RelayLimits() != defaultRelayLimits {
+		t.Fatal("the default relay limits are not used")
+	}
+	if err := tpt.tpool.SetRelayLimits(modules.TransactionPoolRelayLimits{}); err != errRelayInterval {
+		t.Fatal("expected errRelayInterval, got", err)
+	}
+	limits := modules.TransactionPoolRelayLimits{MaxSets: 5, Interval: time.Second, BanDuration: time.Minute}
+	if err := tpt.tpool.SetRelayLimits(limits); err != nil {
+		t.Fatal(err)
+	}
+
+	// Restart the transaction pool and check that the limits were kept.
+	persistDir := tpt.tpool.persistDir
+	if err := tpt.tpool.Close(); err != nil {
+		t.Fatal(err)
+	}
+	tpt.tpool, err = New(tpt.cs, tpt.gateway, persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tpt.tpool.RelayLimits() != limits {
+		t.Fatal("the relay limits were not persisted:", tpt.tpool.RelayLimits())
+	}
+}
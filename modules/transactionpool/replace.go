@@ -0,0 +1,102 @@
+package transactionpool
+
+import (
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+const (
+	// replacementFeeNum and replacementFeeDenom set the minimum increase in
+	// fee-per-byte required for a transaction set to replace the sets it
+	// conflicts with. A replacement must pay at least 10% more per byte than
+	// every set it evicts.
+	replacementFeeNum   = 11
+	replacementFeeDenom = 10
+)
+
+// setFees returns the total miner fees paid by a transaction set.
+func setFees(ts []types.Transaction) types.Currency {
+	var sum types.Currency
+	for _, txn := range ts {
+		for _, fee := range txn.MinerFees {
+			sum = sum.Add(fee)
+		}
+	}
+	return sum
+}
+
+// removeTransactionSet removes a transaction set from the pool, along with
+// all of the objects that point to it.
+func (tp *TransactionPool) removeTransactionSet(setID TransactionSetID) {
+	tp.transactionListSize -= len(encoding.Marshal(tp.transactionSets[setID]))
+	delete(tp.transactionSets, setID)
+	delete(tp.transactionSetDiffs, setID)
+	for oid, id := range tp.knownObjects {
+		if id == setID {
+			delete(tp.knownObjects, oid)
+		}
+	}
+}
+
+// replaceConflicts replaces the conflicting transaction sets with ts. The
+// replacement must be valid on its own, must pay more in total fees than the
+// sets it evicts, and must pay a sufficiently higher fee-per-byte than each of
+// them.
+func (tp *TransactionPool) replaceConflicts(ts []types.Transaction, conflicts []TransactionSetID, txnFn func([]types.Transaction) (modules.ConsensusChange, error)) error {
+	// Collect the distinct sets that will be evicted.
+	evicted := make(map[TransactionSetID]struct{})
+	for _, conflict := range conflicts {
+		if _, exists := tp.transactionSets[conflict]; exists {
+			evicted[conflict] = struct{}{}
+		}
+	}
+
+	// Check that the replacement pays enough.
+	fee := setFees(ts)
+	feePerByte := modules.CalculateFee(ts)
+	var evictedFees types.Currency
+	for setID := range evicted {
+		set := tp.transactionSets[setID]
+		evictedFees = evictedFees.Add(setFees(set))
+		minFeePerByte := modules.CalculateFee(set).Mul64(replacementFeeNum).Div64(replacementFeeDenom)
+		if feePerByte.Cmp(minFeePerByte) <= 0 {
+			return errLowReplacementFee
+		}
+	}
+	if fee.Cmp(evictedFees) <= 0 {
+		return errLowReplacementFee
+	}
+
+	// The replacement must be valid without any of the sets it evicts.
+	cc, err := txnFn(ts)
+	if err != nil {
+		return modules.NewConsensusConflict("replacement transaction set is invalid: " + err.Error())
+	}
+
+	// Evict the conflicts and add the replacement to the pool.
+	for setID := range evicted {
+		tp.reportReplacedSet(tp.transactionSets[setID], ts)
+		tp.removeTransactionSet(setID)
+	}
+	setID := TransactionSetID(crypto.HashObject(ts))
+	tp.transactionSets[setID] = ts
+	for _, oid := range relatedObjectIDs(ts) {
+		tp.knownObjects[oid] = setID
+	}
+	tp.transactionSetDiffs[setID] = cc
+	tp.transactionListSize += len(encoding.Marshal(ts))
+	return nil
+}
+
+// SetReplaceByFee sets whether a transaction set that double spends
+// unconfirmed transaction sets may replace them by paying a higher fee.
+// Replacement is enabled by default. Operators that accept unconfirmed
+// payments may wish to disable it, so that a payment cannot be replaced by one
+// that sends the coins elsewhere.
+func (tp *TransactionPool) SetReplaceByFee(enabled bool) {
+	tp.mu.Lock()
+	tp.replaceByFee = enabled
+	tp.mu.Unlock()
+}
@@ -0,0 +1,97 @@
+package transactionpool
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestReplaceByFee checks that a transaction set that double spends an
+// unconfirmed set replaces it only if it pays a sufficiently higher fee, and
+// only if replacement is enabled.
+func TestReplaceByFee(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+
+	// Create a confirmed output that anyone can spend.
+	emptyUH := types.UnlockConditions{}.UnlockHash()
+	builder := tpt.wallet.StartTransaction()
+	funding := types.SiacoinPrecision.Mul64(10)
+	if err := builder.FundSiacoins(funding); err != nil {
+		t.Fatal(err)
+	}
+	i := builder.AddSiacoinOutput(types.SiacoinOutput{Value: funding, UnlockHash: emptyUH})
+	tSet, err := builder.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tpt.tpool.AcceptTransactionSet(tSet); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tpt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	outputID := tSet[len(tSet)-1].SiacoinOutputID(i)
+
+	// spend returns a transaction that spends the output to the provided
+	// address, paying the provided fee.
+	spend := func(fee types.Currency, dest types.UnlockHash) []types.Transaction {
+		return []types.Transaction{{
+			SiacoinInputs:  []types.SiacoinInput{{ParentID: outputID}},
+			SiacoinOutputs: []types.SiacoinOutput{{Value: funding.Sub(fee), UnlockHash: dest}},
+			MinerFees:      []types.Currency{fee},
+		}}
+	}
+	original := spend(types.SiacoinPrecision, types.UnlockHash{1})
+	if err := tpt.tpool.AcceptTransactionSet(original); err != nil {
+		t.Fatal(err)
+	}
+
+	// A double spend that pays less, or only slightly more, should be
+	// rejected.
+	err = tpt.tpool.AcceptTransactionSet(spend(types.SiacoinPrecision.Div64(2), types.UnlockHash{2}))
+	if err != errLowReplacementFee {
+		t.Fatal("expected errLowReplacementFee, got", err)
+	}
+	err = tpt.tpool.AcceptTransactionSet(spend(types.SiacoinPrecision.Add(types.NewCurrency64(1)), types.UnlockHash{2}))
+	if err != errLowReplacementFee {
+		t.Fatal("expected errLowReplacementFee, got", err)
+	}
+
+	// With replacement disabled, even a large fee should not help.
+	tpt.tpool.SetReplaceByFee(false)
+	err = tpt.tpool.AcceptTransactionSet(spend(types.SiacoinPrecision.Mul64(2), types.UnlockHash{2}))
+	if _, ok := err.(modules.ConsensusConflict); !ok {
+		t.Fatal("expected a consensus conflict, got", err)
+	}
+
+	// With replacement enabled, the double spend should evict the original.
+	tpt.tpool.SetReplaceByFee(true)
+	replacement := spend(types.SiacoinPrecision.Mul64(2), types.UnlockHash{2})
+	if err := tpt.tpool.AcceptTransactionSet(replacement); err != nil {
+		t.Fatal(err)
+	}
+	txns := tpt.tpool.TransactionList()
+	if len(txns) != 1 || txns[0].ID() != replacement[0].ID() {
+		t.Fatal("original transaction was not replaced:", txns)
+	}
+
+	// The original should no longer be accepted, and the replacement should
+	// be mined.
+	if err := tpt.tpool.AcceptTransactionSet(original); err == nil {
+		t.Fatal("original transaction was accepted after being replaced")
+	}
+	if _, err := tpt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	if len(tpt.tpool.TransactionList()) != 0 {
+		t.Fatal("replacement was not mined")
+	}
+}
@@ -0,0 +1,129 @@
+package transactionpool
+
+import (
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// recordConflicts records that the transactions of a double spend conflict
+// with each other, in both directions, so that the risk of whichever side
+// remains in the pool reflects the conflict.
+func (tp *TransactionPool) recordConflicts(ds modules.DoubleSpend) {
+	record := func(id, conflict types.TransactionID) {
+		conflicts, exists := tp.observedConflicts[id]
+		if !exists {
+			conflicts = make(map[types.TransactionID]struct{})
+			tp.observedConflicts[id] = conflicts
+		}
+		conflicts[conflict] = struct{}{}
+	}
+	for _, id := range ds.Transactions {
+		for _, conflict := range ds.ConflictingTransactions {
+			record(id, conflict)
+			record(conflict, id)
+		}
+	}
+}
+
+// managedRecordRelay records that the peer at host relayed the transactions
+// in ts.
+func (tp *TransactionPool) managedRecordRelay(host string, ts []types.Transaction) {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	for _, txn := range ts {
+		id := txn.ID()
+		peers, exists := tp.transactionRelayers[id]
+		if !exists {
+			peers = make(map[string]struct{})
+			tp.transactionRelayers[id] = peers
+		}
+		peers[host] = struct{}{}
+	}
+}
+
+// pruneRiskTracking forgets the conflicts and relaying peers of transactions
+// that are not in the pool or the orphan pool.
+func (tp *TransactionPool) pruneRiskTracking() {
+	known := make(map[types.TransactionID]struct{})
+	for _, set := range tp.transactionSets {
+		for _, txn := range set {
+			known[txn.ID()] = struct{}{}
+		}
+	}
+	for _, orphan := range tp.orphans {
+		for _, txn := range orphan.txns {
+			known[txn.ID()] = struct{}{}
+		}
+	}
+	for id := range tp.observedConflicts {
+		if _, exists := known[id]; !exists {
+			delete(tp.observedConflicts, id)
+		}
+	}
+	for id := range tp.transactionRelayers {
+		if _, exists := known[id]; !exists {
+			delete(tp.transactionRelayers, id)
+		}
+	}
+}
+
+// TransactionRisk returns a summary of the risk that the transaction in the
+// pool with the provided ID is not confirmed. The fee of a transaction is
+// that of the chunk containing it, as a miner must include the transaction's
+// ancestors along with it, and conflicts with any transaction in the chunk
+// are reported.
+func (tp *TransactionPool) TransactionRisk(id types.TransactionID) (modules.TransactionPoolRisk, bool) {
+	tp.mu.RLock()
+	defer tp.mu.RUnlock()
+
+	chunks := tp.feeOrderedChunks()
+	index := -1
+	var total uint64
+	for i, c := range chunks {
+		total += c.size
+		for _, txn := range c.txns {
+			if txn.ID() == id {
+				index = i
+			}
+		}
+	}
+	if index == -1 {
+		return modules.TransactionPoolRisk{}, false
+	}
+	chunk := chunks[index]
+
+	var ahead, below uint64
+	for _, c := range chunks {
+		switch c.fee.Cmp(chunk.fee) {
+		case 1:
+			ahead += c.size
+		case -1:
+			below += c.size
+		}
+	}
+
+	seen, exists := tp.transactionFirstSeen[id]
+	if !exists {
+		seen = time.Now()
+	}
+	risk := modules.TransactionPoolRisk{
+		ID:            id,
+		FirstSeen:     types.Timestamp(seen.Unix()),
+		FeePerByte:    chunk.fee,
+		FeePercentile: 100 * float64(below) / float64(total),
+		BytesAhead:    ahead,
+		RelayPeers:    len(tp.transactionRelayers[id]),
+	}
+	reported := make(map[types.TransactionID]struct{})
+	for _, txn := range chunk.txns {
+		for conflict := range tp.observedConflicts[txn.ID()] {
+			if _, dup := reported[conflict]; !dup {
+				reported[conflict] = struct{}{}
+				risk.ConflictingTransactions = append(risk.ConflictingTransactions, conflict)
+			}
+		}
+	}
+	return risk, true
+}
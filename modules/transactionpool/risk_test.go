@@ -0,0 +1,96 @@
+package transactionpool
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestTransactionRisk checks that the risk of an unconfirmed transaction
+// reflects its fee relative to the pool, the conflicting spends seen by the
+// pool, and the peers that relayed it.
+func TestTransactionRisk(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+
+	// Create two confirmed outputs that anyone can spend.
+	emptyUH := types.UnlockConditions{}.UnlockHash()
+	builder := tpt.wallet.StartTransaction()
+	funding := types.SiacoinPrecision.Mul64(10)
+	if err := builder.FundSiacoins(funding.Mul64(2)); err != nil {
+		t.Fatal(err)
+	}
+	i := builder.AddSiacoinOutput(types.SiacoinOutput{Value: funding, UnlockHash: emptyUH})
+	j := builder.AddSiacoinOutput(types.SiacoinOutput{Value: funding, UnlockHash: emptyUH})
+	tSet, err := builder.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tpt.tpool.AcceptTransactionSet(tSet); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tpt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	spend := func(output uint64, fee types.Currency, dest types.UnlockHash) []types.Transaction {
+		return []types.Transaction{{
+			SiacoinInputs:  []types.SiacoinInput{{ParentID: tSet[len(tSet)-1].SiacoinOutputID(output)}},
+			SiacoinOutputs: []types.SiacoinOutput{{Value: funding.Sub(fee), UnlockHash: dest}},
+			MinerFees:      []types.Currency{fee},
+		}}
+	}
+
+	high := spend(i, types.SiacoinPrecision, types.UnlockHash{1})
+	low := spend(j, types.SiacoinPrecision.Div64(10), types.UnlockHash{2})
+	for _, set := range [][]types.Transaction{high, low} {
+		if err := tpt.tpool.AcceptTransactionSet(set); err != nil {
+			t.Fatal(err)
+		}
+	}
+	highSize := uint64(len(encoding.Marshal(high[0])))
+	lowSize := uint64(len(encoding.Marshal(low[0])))
+
+	if _, exists := tpt.tpool.TransactionRisk(types.TransactionID{}); exists {
+		t.Fatal("risk reported for a transaction that is not in the pool")
+	}
+	risk, exists := tpt.tpool.TransactionRisk(low[0].ID())
+	if !exists || risk.FeePercentile != 0 || risk.BytesAhead != highSize || len(risk.ConflictingTransactions) != 0 || risk.RelayPeers != 0 {
+		t.Fatal("wrong risk for the low fee transaction:", risk)
+	}
+	risk, exists = tpt.tpool.TransactionRisk(high[0].ID())
+	if !exists || risk.FeePercentile != 100*float64(lowSize)/float64(lowSize+highSize) || risk.BytesAhead != 0 {
+		t.Fatal("wrong risk for the high fee transaction:", risk)
+	}
+
+	// A rejected double spend is reported as a conflict, and each peer that
+	// relayed the transaction is counted once.
+	doubleSpend := spend(i, types.SiacoinPrecision.Div64(2), types.UnlockHash{3})
+	if err := tpt.tpool.AcceptTransactionSet(doubleSpend); err != errLowReplacementFee {
+		t.Fatal("expected errLowReplacementFee, got", err)
+	}
+	tpt.tpool.managedRecordRelay("foo", high)
+	tpt.tpool.managedRecordRelay("bar", high)
+	tpt.tpool.managedRecordRelay("foo", high)
+	risk, _ = tpt.tpool.TransactionRisk(high[0].ID())
+	if len(risk.ConflictingTransactions) != 1 || risk.ConflictingTransactions[0] != doubleSpend[0].ID() || risk.RelayPeers != 2 {
+		t.Fatal("conflicts or relaying peers were not reported:", risk)
+	}
+
+	// Once the transactions are confirmed, their conflicts and relaying peers
+	// are forgotten.
+	if _, err := tpt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	tpt.tpool.mu.RLock()
+	defer tpt.tpool.mu.RUnlock()
+	if len(tpt.tpool.observedConflicts) != 0 || len(tpt.tpool.transactionRelayers) != 0 {
+		t.Fatal("risk tracking was not pruned:", tpt.tpool.observedConflicts, tpt.tpool.transactionRelayers)
+	}
+}
@@ -0,0 +1,91 @@
+package transactionpool
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// feeOrderedSet is a transaction set in the pool along with the fee-per-byte
+// that it pays and its encoded size.
+type feeOrderedSet struct {
+	fee  types.Currency
+	id   TransactionSetID
+	set  []types.Transaction
+	size uint64
+}
+
+// setsByFee sorts transaction sets by fee-per-byte, highest first. Sets
+// paying the same fee are ordered by id so that the order is deterministic.
+type setsByFee []feeOrderedSet
+
+func (sf setsByFee) Len() int      { return len(sf) }
+func (sf setsByFee) Swap(i, j int) { sf[i], sf[j] = sf[j], sf[i] }
+func (sf setsByFee) Less(i, j int) bool {
+	if c := sf[i].fee.Cmp(sf[j].fee); c != 0 {
+		return c > 0
+	}
+	return bytes.Compare(sf[i].id[:], sf[j].id[:]) < 0
+}
+
+// feeOrderedSets returns the transaction sets in the pool ordered by
+// fee-per-byte, highest first. The transactions within each set keep their
+// order, so that every transaction follows the transactions it depends on.
+// Sets in the pool never depend on each other, because dependent sets are
+// merged when they are accepted.
+func (tp *TransactionPool) feeOrderedSets() []feeOrderedSet {
+	sets := make([]feeOrderedSet, 0, len(tp.transactionSets))
+	for id, set := range tp.transactionSets {
+		sets = append(sets, feeOrderedSet{
+			fee:  modules.CalculateFee(set),
+			id:   id,
+			set:  set,
+			size: uint64(len(encoding.Marshal(set))),
+		})
+	}
+	sort.Sort(setsByFee(sets))
+	return sets
+}
+
+// feeOrderedTransactions returns every transaction in the pool, with the
+// chunks paying the highest fee-per-byte first. A transaction paying a low
+// fee is placed alongside the highest paying child that depends on it.
+func (tp *TransactionPool) feeOrderedTransactions() []types.Transaction {
+	var txns []types.Transaction
+	for _, c := range tp.feeOrderedChunks() {
+		txns = append(txns, c.txns...)
+	}
+	return txns
+}
+
+// TransactionsForBlock returns the transactions that pay the most in fees
+// while fitting in maxSize bytes. Transactions are added in chunks, in order
+// of fee-per-byte, highest first; a chunk consists of a transaction and the
+// ancestors it needs, so a child paying a high fee can pull in a parent paying
+// a low fee. A chunk that does not fit is skipped along with the rest of its
+// set, and smaller chunks paying less may still be added after it.
+// Transactions that depend on each other are kept in an order that can be put
+// into a block.
+func (tp *TransactionPool) TransactionsForBlock(maxSize uint64) []types.Transaction {
+	tp.mu.RLock()
+	defer tp.mu.RUnlock()
+
+	var txns []types.Transaction
+	remaining := maxSize
+	skipped := make(map[TransactionSetID]struct{})
+	for _, c := range tp.feeOrderedChunks() {
+		if _, ok := skipped[c.setID]; ok {
+			continue
+		}
+		if c.size > remaining {
+			skipped[c.setID] = struct{}{}
+			continue
+		}
+		remaining -= c.size
+		txns = append(txns, c.txns...)
+	}
+	return txns
+}
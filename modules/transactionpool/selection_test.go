@@ -0,0 +1,78 @@
+package transactionpool
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestTransactionsForBlock checks that transactions are selected in order of
+// fee-per-byte and that the selection respects the size limit.
+func TestTransactionsForBlock(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+
+	// Mine a few more blocks so that the wallet has several mature outputs.
+	for i := 0; i < 3; i++ {
+		_, err = tpt.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Create three independent sets paying increasing fees, and add them to
+	// the pool lowest fee first.
+	var sets [][]types.Transaction
+	for i := uint64(1); i <= 3; i++ {
+		fee := types.SiacoinPrecision.Mul64(i)
+		txnBuilder := tpt.wallet.StartTransaction()
+		err = txnBuilder.FundSiacoins(fee)
+		if err != nil {
+			t.Fatal(err)
+		}
+		txnBuilder.AddMinerFee(fee)
+		txnSet, err := txnBuilder.Sign(true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sets = append(sets, txnSet)
+	}
+	for _, txnSet := range sets {
+		err = tpt.tpool.AcceptTransactionSet(txnSet)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// With enough room, every set should be returned, highest fee first.
+	txns := tpt.tpool.TransactionsForBlock(types.BlockSizeLimit)
+	var expected []types.Transaction
+	for i := len(sets) - 1; i >= 0; i-- {
+		expected = append(expected, sets[i]...)
+	}
+	if len(txns) != len(expected) {
+		t.Fatal("expected", len(expected), "transactions, got", len(txns))
+	}
+	for i := range txns {
+		if txns[i].ID() != expected[i].ID() {
+			t.Fatal("transactions are not ordered by fee")
+		}
+	}
+
+	// With room for only one set, the set paying the highest fee should be
+	// returned.
+	txns = tpt.tpool.TransactionsForBlock(uint64(len(encoding.Marshal(sets[2]))))
+	if len(txns) != len(sets[2]) || txns[0].ID() != sets[2][0].ID() {
+		t.Fatal("the set paying the highest fee was not selected")
+	}
+	if len(tpt.tpool.TransactionsForBlock(0)) != 0 {
+		t.Fatal("transactions were returned without any room")
+	}
+}
@@ -0,0 +1,144 @@
+package transactionpool
+
+import (
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// transactionParents returns, for each transaction in the set, the IDs of the
+// earlier transactions in the set that create the objects it spends or
+// revises.
+func transactionParents(ts []types.Transaction) [][]types.TransactionID {
+	creators := make(map[ObjectID]types.TransactionID)
+	parents := make([][]types.TransactionID, len(ts))
+	for i, txn := range ts {
+		var spent []ObjectID
+		for _, sci := range txn.SiacoinInputs {
+			spent = append(spent, ObjectID(sci.ParentID))
+		}
+		for _, fcr := range txn.FileContractRevisions {
+			spent = append(spent, ObjectID(fcr.ParentID))
+		}
+		for _, sp := range txn.StorageProofs {
+			spent = append(spent, ObjectID(sp.ParentID))
+		}
+		for _, sfi := range txn.SiafundInputs {
+			spent = append(spent, ObjectID(sfi.ParentID))
+		}
+		seen := make(map[types.TransactionID]struct{})
+		for _, oid := range spent {
+			parent, exists := creators[oid]
+			if _, dup := seen[parent]; exists && !dup {
+				seen[parent] = struct{}{}
+				parents[i] = append(parents[i], parent)
+			}
+		}
+
+		id := txn.ID()
+		for j := range txn.SiacoinOutputs {
+			creators[ObjectID(txn.SiacoinOutputID(uint64(j)))] = id
+		}
+		for j := range txn.FileContracts {
+			creators[ObjectID(txn.FileContractID(uint64(j)))] = id
+		}
+		for j := range txn.SiafundOutputs {
+			creators[ObjectID(txn.SiafundOutputID(uint64(j)))] = id
+		}
+	}
+	return parents
+}
+
+// setSnapshot returns a snapshot of a transaction set in the pool. Children
+// are derived from the parents of the later transactions in the set.
+// Transactions that were not accepted through AcceptTransactionSet, such as
+// those returned to the pool by a reorg, are reported as first seen now.
+func (tp *TransactionPool) setSnapshot(s feeOrderedSet, now time.Time) modules.TransactionSetSnapshot {
+	set := modules.TransactionSetSnapshot{
+		ID:           crypto.Hash(s.id),
+		Size:         s.size,
+		Fees:         setFees(s.set),
+		FeePerByte:   s.fee,
+		Transactions: make([]modules.TransactionSnapshot, len(s.set)),
+	}
+	parents := transactionParents(s.set)
+	children := make(map[types.TransactionID][]types.TransactionID)
+	for i, txn := range s.set {
+		for _, parent := range parents[i] {
+			children[parent] = append(children[parent], txn.ID())
+		}
+	}
+	for i, txn := range s.set {
+		id := txn.ID()
+		seen, exists := tp.transactionFirstSeen[id]
+		if !exists {
+			seen = now
+		}
+		set.Transactions[i] = modules.TransactionSnapshot{
+			ID:          id,
+			Transaction: txn,
+			Parents:     parents[i],
+			Children:    children[id],
+			Size:        uint64(len(encoding.Marshal(txn))),
+			Fees:        setFees([]types.Transaction{txn}),
+			FirstSeen:   types.Timestamp(seen.Unix()),
+		}
+	}
+	return set
+}
+
+// TransactionSetSnapshot returns a consistent, fee-ordered snapshot of every
+// transaction set in the pool, including the parents of each transaction.
+func (tp *TransactionPool) TransactionSetSnapshot() (modules.TransactionPoolSnapshot, error) {
+	if err := tp.tg.Add(); err != nil {
+		return modules.TransactionPoolSnapshot{}, err
+	}
+	defer tp.tg.Done()
+	tp.mu.RLock()
+	defer tp.mu.RUnlock()
+
+	var snapshot modules.TransactionPoolSnapshot
+	err := tp.db.View(func(tx *bolt.Tx) error {
+		ccid, err := tp.getRecentConsensusChange(tx)
+		snapshot.ConsensusChange = crypto.Hash(ccid)
+		return err
+	})
+	if err != nil {
+		return modules.TransactionPoolSnapshot{}, err
+	}
+
+	now := time.Now()
+	for _, s := range tp.feeOrderedSets() {
+		snapshot.Size += s.size
+		snapshot.Sets = append(snapshot.Sets, tp.setSnapshot(s, now))
+	}
+	return snapshot, nil
+}
+
+// UnconfirmedTransaction returns a snapshot of the transaction in the pool
+// with the provided ID, along with a snapshot of the set that contains it.
+func (tp *TransactionPool) UnconfirmedTransaction(id types.TransactionID) (modules.TransactionSnapshot, modules.TransactionSetSnapshot, bool) {
+	tp.mu.RLock()
+	defer tp.mu.RUnlock()
+
+	for setID, set := range tp.transactionSets {
+		for i, txn := range set {
+			if txn.ID() != id {
+				continue
+			}
+			s := tp.setSnapshot(feeOrderedSet{
+				fee:  modules.CalculateFee(set),
+				id:   setID,
+				set:  set,
+				size: uint64(len(encoding.Marshal(set))),
+			}, time.Now())
+			return s.Transactions[i], s, true
+		}
+	}
+	return modules.TransactionSnapshot{}, modules.TransactionSetSnapshot{}, false
+}
@@ -0,0 +1,77 @@
+package transactionpool
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestTransactionSetSnapshot checks that the snapshot contains every set in
+// the pool and links each transaction to the transactions it spends from.
+func TestTransactionSetSnapshot(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+
+	// Create a set with a parent transaction that funds a child.
+	fee := types.SiacoinPrecision
+	txnBuilder := tpt.wallet.StartTransaction()
+	err = txnBuilder.FundSiacoins(fee)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txnBuilder.AddMinerFee(fee)
+	txnSet, err := txnBuilder.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txnSet) != 2 {
+		t.Fatal("expected a parent and a child transaction, got", len(txnSet))
+	}
+	err = tpt.tpool.AcceptTransactionSet(txnSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := tpt.tpool.TransactionSetSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshot.Sets) != 1 {
+		t.Fatal("expected one set, got", len(snapshot.Sets))
+	}
+	set := snapshot.Sets[0]
+	if len(set.Transactions) != 2 || !set.Fees.Equals(fee) || snapshot.Size != set.Size {
+		t.Fatal("snapshot set does not match the accepted set:", set)
+	}
+	parent, child := set.Transactions[0], set.Transactions[1]
+	if parent.ID != txnSet[0].ID() || len(parent.Parents) != 0 {
+		t.Fatal("parent transaction should not have parents in the pool:", parent.Parents)
+	}
+	if len(child.Parents) != 1 || child.Parents[0] != parent.ID {
+		t.Fatal("child transaction is not linked to its parent:", child.Parents)
+	}
+	if len(parent.Children) != 1 || parent.Children[0] != child.ID || len(child.Children) != 0 {
+		t.Fatal("parent transaction is not linked to its child:", parent.Children, child.Children)
+	}
+	if !child.Fees.Equals(fee) || !parent.Fees.IsZero() || parent.Size+child.Size > set.Size {
+		t.Fatal("wrong transaction fees or sizes:", parent.Fees, child.Fees, parent.Size, child.Size)
+	}
+	if parent.FirstSeen == 0 || types.CurrentTimestamp() < child.FirstSeen {
+		t.Fatal("wrong first seen time:", parent.FirstSeen, child.FirstSeen)
+	}
+
+	// Transactions can be looked up individually.
+	txn, txnSet2, exists := tpt.tpool.UnconfirmedTransaction(child.ID)
+	if !exists || txn.ID != child.ID || len(txn.Parents) != 1 || txnSet2.ID != set.ID {
+		t.Fatal("lookup returned the wrong transaction:", txn, exists)
+	}
+	if _, _, exists := tpt.tpool.UnconfirmedTransaction(types.TransactionID{}); exists {
+		t.Fatal("lookup of an unknown transaction should fail")
+	}
+}
@@ -2,17 +2,14 @@ package transactionpool
 
 import (
 	"github.com/NebulousLabs/Sia/modules"
-	"github.com/NebulousLabs/Sia/types"
 )
 
 // updateSubscribersTransactions sends a new transaction pool update to all
-// subscribers.
+// subscribers. The transactions are ordered by the fee-per-byte of the chunks
+// they belong to, highest first.
 func (tp *TransactionPool) updateSubscribersTransactions() {
-	var txns []types.Transaction
+	txns := tp.feeOrderedTransactions()
 	var cc modules.ConsensusChange
-	for _, tSet := range tp.transactionSets {
-		txns = append(txns, tSet...)
-	}
 	for _, tSetDiff := range tp.transactionSetDiffs {
 		cc = cc.Append(tSetDiff)
 	}
@@ -32,10 +29,7 @@ func (tp *TransactionPool) TransactionPoolSubscribe(subscriber modules.Transacti
 	tp.subscribers = append(tp.subscribers, subscriber)
 
 	// Send the new subscriber the transaction pool set.
-	var txns []types.Transaction
-	for _, tSet := range tp.transactionSets {
-		txns = append(txns, tSet...)
-	}
+	txns := tp.feeOrderedTransactions()
 	var cc modules.ConsensusChange
 	for _, tSetDiff := range tp.transactionSetDiffs {
 		cc = cc.Append(tSetDiff)
@@ -2,12 +2,15 @@ package transactionpool
 
 import (
 	"errors"
+	"sync"
+	"time"
 
 	"github.com/NebulousLabs/demotemutex"
 
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/persist"
+	siasync "github.com/NebulousLabs/Sia/sync"
 	"github.com/NebulousLabs/Sia/types"
 )
 
@@ -68,10 +71,88 @@ type (
 		// subscriber.
 		subscribers []modules.TransactionPoolSubscriber
 
+		// doubleSpendSubscribers are notified when a transaction set is
+		// rejected or evicted because of a conflicting spend. recentSpends
+		// holds the objects spent in the most recent blocks, newest last, so
+		// that sets conflicting with confirmed transactions can be reported.
+		doubleSpendSubscribers []modules.DoubleSpendSubscriber
+		recentSpends           []map[ObjectID]types.TransactionID
+
+		// observedConflicts holds, for each transaction involved in a double
+		// spend, the transactions it conflicts with, and transactionRelayers
+		// holds the hosts of the peers that relayed each transaction. They
+		// are used to report the risk of unconfirmed transactions, and are
+		// pruned to the transactions in the pool when a block is processed.
+		observedConflicts   map[types.TransactionID]map[types.TransactionID]struct{}
+		transactionRelayers map[types.TransactionID]map[string]struct{}
+
+		// replaceByFee indicates whether a transaction set that double spends
+		// unconfirmed transaction sets may replace them by paying a higher
+		// fee.
+		replaceByFee bool
+
+		// The eviction policy determines which transaction sets are removed
+		// from the pool by threadedEvictTransactions. transactionFirstSeen
+		// records when each transaction in the pool was first accepted, and
+		// is not reset when the pool is rebuilt after a consensus change.
+		evictionPolicy       modules.TransactionPoolEvictionPolicy
+		transactionFirstSeen map[types.TransactionID]time.Time
+
+		// expiredTransactions records when transactions were evicted for
+		// exceeding the maximum age of the eviction policy. Sets containing
+		// them are refused, so that resubmitting an expired set does not
+		// reset its age.
+		expiredTransactions map[types.TransactionID]time.Time
+
+		// evictionFloor is the lowest fee-per-byte that a transaction set
+		// may pay and still be accepted. It is raised when sets are evicted to
+		// keep the pool below the maximum size of the eviction policy, and
+		// reset when the pool is purged.
+		evictionFloor types.Currency
+
+		// blockHeight counts the blocks that the transaction pool has seen,
+		// and rebroadcasts records when each transaction in the pool should
+		// next be relayed to peers if it is still unconfirmed.
+		blockHeight  types.BlockHeight
+		rebroadcasts map[types.TransactionID]rebroadcastState
+
+		// relayPeers tracks the transaction sets relayed by each peer, keyed
+		// by host, so that peers exceeding relayLimits can be ignored before
+		// their sets are validated. They are protected by relayMu so that
+		// the limits can be checked without waiting for the pool.
+		relayLimits modules.TransactionPoolRelayLimits
+		relayPeers  map[string]*relayPeer
+		relayMu     sync.Mutex
+
+		// requestedSets records when each transaction set announced by a
+		// peer was requested, see inventory.go.
+		requestedSets map[TransactionSetID]time.Time
+
+		// orphans holds the transaction sets that spend outputs which are not
+		// known yet, in the order that they arrived, and orphanPoolSize is
+		// their combined size in bytes.
+		orphans        []orphanSet
+		orphanPoolSize int
+
+		// The fee backlog summarizes the unconfirmed transaction sets, and the
+		// recent block fees summarize the most recently confirmed blocks. They
+		// are protected by a separate lock so that fees can be estimated by
+		// modules that are being notified of a transaction pool update.
+		feeBacklog      []setFee
+		recentBlockFees []blockFee
+		feeMu           sync.Mutex
+
 		// Utilities.
 		db         *persist.BoltDatabase
 		mu         demotemutex.DemoteMutex
 		persistDir string
+		tg         siasync.ThreadGroup
+	}
+
+	// A compactBlockSourceRegistrar is a consensus set that can reconstruct
+	// compact blocks relayed by peers from the transactions in the pool.
+	compactBlockSourceRegistrar interface {
+		RegisterCompactBlockSource(func() []types.Transaction)
 	}
 )
 
@@ -94,6 +175,17 @@ func New(cs modules.ConsensusSet, g modules.Gateway, persistDir string) (*Transa
 		transactionSets:     make(map[TransactionSetID][]types.Transaction),
 		transactionSetDiffs: make(map[TransactionSetID]modules.ConsensusChange),
 
+		observedConflicts:   make(map[types.TransactionID]map[types.TransactionID]struct{}),
+		transactionRelayers: make(map[types.TransactionID]map[string]struct{}),
+
+		replaceByFee: true,
+
+		transactionFirstSeen: make(map[types.TransactionID]time.Time),
+		expiredTransactions:  make(map[types.TransactionID]time.Time),
+		rebroadcasts:         make(map[types.TransactionID]rebroadcastState),
+		relayPeers:           make(map[string]*relayPeer),
+		requestedSets:        make(map[TransactionSetID]time.Time),
+
 		persistDir: persistDir,
 	}
 
@@ -105,42 +197,37 @@ func New(cs modules.ConsensusSet, g modules.Gateway, persistDir string) (*Transa
 
 	// Register RPCs
 	g.RegisterRPC("RelayTransactionSet", tp.relayTransactionSet)
+	g.RegisterRPC("AnnounceTxnSets", tp.rpcAnnounceTxnSets)
+	g.RegisterCapability(modules.CapabilityInventoryRelay)
+
+	// Let the consensus set reconstruct compact blocks from the pool.
+	if cbs, ok := cs.(compactBlockSourceRegistrar); ok {
+		cbs.RegisterCompactBlockSource(tp.TransactionList)
+	}
+
+	go tp.threadedEvictTransactions()
 	return tp, nil
 }
 
 func (tp *TransactionPool) Close() error {
+	if err := tp.tg.Stop(); err != nil {
+		return err
+	}
 	tp.gateway.UnregisterRPC("RelayTransactionSet")
+	tp.gateway.UnregisterRPC("AnnounceTxnSets")
+	tp.gateway.UnregisterCapability(modules.CapabilityInventoryRelay)
+	if cbs, ok := tp.consensusSet.(compactBlockSourceRegistrar); ok {
+		cbs.RegisterCompactBlockSource(nil)
+	}
 	tp.consensusSet.Unsubscribe(tp)
 	return tp.db.Close()
 }
 
-// FeeEstimation returns an estimation for what fee should be applied to
-// transactions.
-func (tp *TransactionPool) FeeEstimation() (min, max types.Currency) {
-	// TODO: The fee estimation tool should look at the recent blocks and use
-	// them to guage what sort of fee should be required, as opposed to just
-	// guessing blindly.
-	//
-	// TODO: The current minimum has been reduced significantly to account for
-	// legacy renters that are not correctly adding transaction fees. The
-	// minimum has been set to 1 siacoin per kb (or 1/1000 SC per byte), but
-	// really should look more like 10 SC per kb. But, legacy renters are using
-	// a much lower value, which means hosts would be incompatible if the
-	// minimum recommended were set to 10. The value has been set to 1, which
-	// should be okay temporarily while the renters are given time to upgrade.
-	return types.SiacoinPrecision.Mul64(1).Div64(1e3), types.SiacoinPrecision.Mul64(5).Div64(1e3)
-}
-
 // TransactionList returns a list of all transactions in the transaction pool.
 // The transactions are provided in an order that can acceptably be put into a
-// block.
+// block, with the sets paying the highest fee-per-byte first.
 func (tp *TransactionPool) TransactionList() []types.Transaction {
 	tp.mu.Lock()
 	defer tp.mu.Unlock()
-
-	var txns []types.Transaction
-	for _, tSet := range tp.transactionSets {
-		txns = append(txns, tSet...)
-	}
-	return txns
+	return tp.feeOrderedTransactions()
 }
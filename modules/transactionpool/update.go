@@ -1,6 +1,8 @@
 package transactionpool
 
 import (
+	"time"
+
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 
@@ -13,12 +15,15 @@ func (tp *TransactionPool) purge() {
 	tp.transactionSets = make(map[TransactionSetID][]types.Transaction)
 	tp.transactionSetDiffs = make(map[TransactionSetID]modules.ConsensusChange)
 	tp.transactionListSize = 0
+	tp.evictionFloor = types.ZeroCurrency
 }
 
 // ProcessConsensusChange gets called to inform the transaction pool of changes
 // to the consensus set.
 func (tp *TransactionPool) ProcessConsensusChange(cc modules.ConsensusChange) {
 	tp.mu.Lock()
+	tp.updateBlockHeight(cc)
+	tp.updateRecentSpends(cc)
 
 	// Update the database of confirmed transactions.
 	err := tp.db.Update(func(tx *bolt.Tx) error {
@@ -110,8 +115,17 @@ func (tp *TransactionPool) ProcessConsensusChange(cc modules.ConsensusChange) {
 	for _, set := range unconfirmedSets {
 		tp.acceptTransactionSet(set, cc.TryTransactionSet) // Error is not checked.
 	}
+	tp.reportConfirmedConflicts(unconfirmedSets, cc)
+	// The applied blocks may contain the parents of orphans.
+	tp.promoteOrphans(cc.TryTransactionSet)
+	tp.enforceMaxSize()
+	tp.pruneRiskTracking()
+	tp.rebroadcastTransactionSets(cc.Synced)
 
-	// Inform subscribers that an update has executed.
+	// Update the fee estimation data and inform subscribers that an update
+	// has executed.
+	tp.updateRecentBlockFees(cc)
+	tp.updateFeeBacklog()
 	tp.mu.Demote()
 	tp.updateSubscribersTransactions()
 	tp.mu.DemotedUnlock()
@@ -121,5 +135,13 @@ func (tp *TransactionPool) ProcessConsensusChange(cc modules.ConsensusChange) {
 func (tp *TransactionPool) PurgeTransactionPool() {
 	tp.mu.Lock()
 	tp.purge()
+	tp.transactionFirstSeen = make(map[types.TransactionID]time.Time)
+	tp.expiredTransactions = make(map[types.TransactionID]time.Time)
+	tp.rebroadcasts = make(map[types.TransactionID]rebroadcastState)
+	tp.orphans = nil
+	tp.orphanPoolSize = 0
+	tp.observedConflicts = make(map[types.TransactionID]map[types.TransactionID]struct{})
+	tp.transactionRelayers = make(map[types.TransactionID]map[string]struct{})
+	tp.updateFeeBacklog()
 	tp.mu.Unlock()
 }
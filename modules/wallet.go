@@ -3,6 +3,7 @@ package modules
 import (
 	"bytes"
 	"errors"
+	"time"
 
 	"github.com/NebulousLabs/entropy-mnemonics"
 
@@ -41,6 +42,15 @@ var (
 	// ErrLockedWallet is returned when an action cannot be performed due to
 	// the wallet being locked.
 	ErrLockedWallet = errors.New("wallet must be unlocked before it can be used")
+
+	// ErrInvalidAtomicSwap is returned if an atomic swap does not match the
+	// template created by ConstructAtomicSwap.
+	ErrInvalidAtomicSwap = errors.New("atomic swap is malformed")
+
+	// ErrApprovalRequired is returned when a send exceeds the threshold of
+	// the wallet's approval policy. The send must be queued with
+	// QueueSiacoins and approved with ApproveSend instead.
+	ErrApprovalRequired = errors.New("amount exceeds the approval threshold; the send must be approved")
 )
 
 type (
@@ -98,6 +108,176 @@ type (
 		Outputs []ProcessedOutput `json:"outputs"`
 	}
 
+	// An AddressHistory is the balance and transaction history of a single
+	// wallet address, computed from the wallet's processed transactions.
+	// ConfirmedSiacoinBalance is the value of all siacoin outputs and miner
+	// payouts received by the address minus the value of all siacoin inputs
+	// spent from it, so it includes miner payouts that have not matured yet.
+	// The unconfirmed fields cover the address's share of the unconfirmed
+	// transaction set.
+	AddressHistory struct {
+		Address types.UnlockHash `json:"address"`
+
+		ConfirmedSiacoinBalance     types.Currency `json:"confirmedsiacoinbalance"`
+		UnconfirmedOutgoingSiacoins types.Currency `json:"unconfirmedoutgoingsiacoins"`
+		UnconfirmedIncomingSiacoins types.Currency `json:"unconfirmedincomingsiacoins"`
+		SiafundBalance              types.Currency `json:"siafundbalance"`
+
+		ConfirmedTransactions   []ProcessedTransaction `json:"confirmedtransactions"`
+		UnconfirmedTransactions []ProcessedTransaction `json:"unconfirmedtransactions"`
+	}
+
+	// An AddressUsage summarizes the confirmed activity of an address
+	// generated from the wallet's primary seed. Index is the position of the
+	// address in the seed. Transactions counts the confirmed transactions
+	// that spend from or send to the address, and Seen reports whether there
+	// is at least one. TotalReceived is the sum of all siacoin outputs and
+	// miner payouts ever sent to the address, and Balance is the value of its
+	// unspent siacoin outputs, excluding dust as in ConfirmedBalance.
+	AddressUsage struct {
+		Address       types.UnlockHash `json:"address"`
+		Index         uint64           `json:"index"`
+		Seen          bool             `json:"seen"`
+		Transactions  uint64           `json:"transactions"`
+		TotalReceived types.Currency   `json:"totalreceived"`
+		Balance       types.Currency   `json:"balance"`
+	}
+
+	// A SpendableBalance describes the siacoins that the wallet will be able
+	// to spend once the blockchain reaches a given height. Siacoins is the sum
+	// of Available, Maturing, and Timelocked.
+	SpendableBalance struct {
+		Height   types.BlockHeight `json:"height"`
+		Siacoins types.Currency    `json:"siacoins"`
+
+		// Available is the value of confirmed outputs that are already
+		// spendable.
+		Available types.Currency `json:"available"`
+
+		// Maturing is the value of delayed outputs, such as miner payouts and
+		// file contract payouts, that mature by Height.
+		Maturing types.Currency `json:"maturing"`
+
+		// Timelocked is the value of confirmed outputs whose timelock expires
+		// by Height.
+		Timelocked types.Currency `json:"timelocked"`
+	}
+
+	// A ProvenanceOutput is a ProcessedOutput along with the ID of the output
+	// it created.
+	ProvenanceOutput struct {
+		ID types.OutputID `json:"id"`
+		ProcessedOutput
+	}
+
+	// An OutputProvenance is the history of an output that the wallet has
+	// controlled. It records the transaction that created the output and, if
+	// the output has been spent, the transaction that spent it along with the
+	// outputs created by that transaction. Miner payouts are created by a
+	// block rather than a transaction, in which case the block ID is reported
+	// as the transaction ID.
+	//
+	// Unconfirmed transactions are reported with a height and timestamp of
+	// math.MaxUint64, matching UnconfirmedTransactions.
+	OutputProvenance struct {
+		Output ProvenanceOutput `json:"output"`
+
+		ReceivedTransactionID types.TransactionID `json:"receivedtransactionid"`
+		ReceivedHeight        types.BlockHeight   `json:"receivedheight"`
+		ReceivedTimestamp     types.Timestamp     `json:"receivedtimestamp"`
+
+		Spent              bool                `json:"spent"`
+		SpentTransactionID types.TransactionID `json:"spenttransactionid"`
+		SpentHeight        types.BlockHeight   `json:"spentheight"`
+		SpentTimestamp     types.Timestamp     `json:"spenttimestamp"`
+		SpentInto          []ProvenanceOutput  `json:"spentinto"`
+	}
+
+	// An ApprovalPolicy describes which sends require a second party to
+	// approve them before they are signed and broadcast. Sends of more than
+	// Threshold hastings must be approved within Timeout of being queued. A
+	// zero Threshold disables the approval requirement. If Window is not
+	// zero, Threshold also caps the total sent without approval within any
+	// Window, so that a large send cannot be split into smaller ones.
+	ApprovalPolicy struct {
+		Threshold types.Currency `json:"threshold"`
+		Timeout   time.Duration  `json:"timeout"`
+		Window    time.Duration  `json:"window"`
+	}
+
+	// A PendingSend is a send that is waiting to be approved. It expires if
+	// it has not been approved by the Expires time.
+	PendingSend struct {
+		ID          crypto.Hash      `json:"id"`
+		Amount      types.Currency   `json:"amount"`
+		Destination types.UnlockHash `json:"destination"`
+		Created     time.Time        `json:"created"`
+		Expires     time.Time        `json:"expires"`
+	}
+
+	// An AtomicSwap is a hashed-timelock output that locks siacoins so that
+	// they can be claimed by the holder of ClaimKey who also knows the
+	// secret behind HashLock, or refunded to the holder of RefundKey once the
+	// blockchain reaches Timelock. Sia's unlock conditions cannot check the
+	// preimage of a hash, so the hashlock is the public key derived from the
+	// secret; the claim transaction reveals the secret in its arbitrary data.
+	// The refund path is enforced by signatures from the claim key that are
+	// only valid after Timelock, which the claimer adds to the refund
+	// transaction before the swap is funded. The claim key is weighted so
+	// that every spend needs it; the refund key and the hashlock can never
+	// spend the output together.
+	//
+	// Funding is the transaction set that creates the swap output, and
+	// Refund spends the output back to the funder.
+	AtomicSwap struct {
+		Value     types.Currency      `json:"value"`
+		HashLock  types.SiaPublicKey  `json:"hashlock"`
+		ClaimKey  types.SiaPublicKey  `json:"claimkey"`
+		RefundKey types.SiaPublicKey  `json:"refundkey"`
+		Timelock  types.BlockHeight   `json:"timelock"`
+		Funding   []types.Transaction `json:"funding"`
+		Refund    types.Transaction   `json:"refund"`
+	}
+
+	// A WalletDBProblem is an inconsistency found in the wallet database by
+	// CheckDB. Key is the hex-encoded key of the offending entry, and is empty
+	// if the problem is not with a single entry. Repair suggests how the
+	// problem can be fixed.
+	WalletDBProblem struct {
+		Bucket      string `json:"bucket"`
+		Key         string `json:"key"`
+		Description string `json:"description"`
+		Repair      string `json:"repair"`
+	}
+
+	// A WalletDBBucket is the name of a bucket in the wallet database and the
+	// number of entries it holds.
+	WalletDBBucket struct {
+		Name    string `json:"name"`
+		Entries int    `json:"entries"`
+	}
+
+	// A WalletDBReport is the result of checking the integrity of the wallet
+	// database. Entries that are not used by the wallet and would be removed
+	// by CompactDB are counted in StaleEntries rather than reported as
+	// problems. The outputs and transactions of the wallet can only be
+	// checked against its keys while the wallet is unlocked, which is
+	// indicated by KeysChecked.
+	WalletDBReport struct {
+		Size         uint64            `json:"size"`
+		Buckets      []WalletDBBucket  `json:"buckets"`
+		KeysChecked  bool              `json:"keyschecked"`
+		StaleEntries int               `json:"staleentries"`
+		Problems     []WalletDBProblem `json:"problems"`
+	}
+
+	// A WalletDBCompaction is the result of compacting the wallet database.
+	WalletDBCompaction struct {
+		SizeBefore     uint64 `json:"sizebefore"`
+		SizeAfter      uint64 `json:"sizeafter"`
+		EntriesRemoved int    `json:"entriesremoved"`
+	}
+
 	// TransactionBuilder is used to construct custom transactions. A transaction
 	// builder is initialized via 'RegisterTransaction' and then can be modified by
 	// adding funds or other fields. The transaction is completed by calling
@@ -155,8 +335,21 @@ type (
 		// be left unsigned.
 		AddFileContractRevision(types.FileContractRevision) uint64
 
+		// SignFileContractRevision adds a signature to the transaction that
+		// covers only the file contract revision at the provided index,
+		// returning the index of the signature within the transaction. The
+		// public key matching 'sk' must appear in the unlock conditions of the
+		// revision. The signature is independent of the wallet's keys, which
+		// allows a renter or host to sign a revision that is then funded and
+		// signed by the wallet.
+		SignFileContractRevision(index uint64, sk crypto.SecretKey) (uint64, error)
+
 		// AddStorageProof adds a storage proof to the transaction, returning
-		// the index of the storage proof within the transaction.
+		// the index of the storage proof within the transaction. A
+		// transaction containing a storage proof cannot contain any siacoin
+		// outputs, siafund outputs, file contracts, or file contract
+		// revisions; funds added by 'FundSiacoins' are moved into a parent
+		// transaction so that the proof can still carry a miner fee.
 		AddStorageProof(types.StorageProof) uint64
 
 		// AddSiafundInput adds a siafund input to the transaction, returning
@@ -190,6 +383,9 @@ type (
 		// object will cover all fields that have already been added to the
 		// transaction, but will also leave room for more fields to be added.
 		//
+		// An error will be returned if the transaction mixes storage proofs
+		// with fields that are not allowed alongside them.
+		//
 		// An error will be returned if there are multiple calls to 'Sign',
 		// sometimes even if the first call to Sign has failed. Sign should
 		// only ever be called once, and if the first signing fails, the
@@ -230,6 +426,12 @@ type (
 		// unlocked using the encryption password.
 		Encrypted() bool
 
+		// ChangeKey re-encrypts the wallet under a new master key. The seeds
+		// and keys of the wallet are unchanged, so no funds need to be moved.
+		// If newKey is blank, the hash of the primary seed will be used as the
+		// key. ChangeKey can be called whether or not the wallet is unlocked.
+		ChangeKey(masterKey, newKey crypto.TwofishKey) error
+
 		// InitFromSeed functions like Encrypt, but using a specified seed.
 		// Unlike Encrypt, the blockchain will be scanned to determine the
 		// seed's progress. For this reason, InitFromSeed should not be called
@@ -252,12 +454,34 @@ type (
 		// Unlocked returns true if the wallet is currently unlocked, false
 		// otherwise.
 		Unlocked() bool
+
+		// LockTimeout returns the amount of time the wallet may remain
+		// unlocked without signing a transaction before it locks itself. A
+		// timeout of zero means the wallet never locks itself.
+		LockTimeout() time.Duration
+
+		// SetLockTimeout sets the wallet's lock timeout. The timeout is
+		// persisted, and a timeout of zero disables automatic locking.
+		SetLockTimeout(time.Duration) error
+
+		// UnlockedUntil returns the time at which the wallet will lock
+		// itself, or the zero time if the wallet is locked or has no lock
+		// timeout.
+		UnlockedUntil() time.Time
+
+		// ExtendUnlock postpones the automatic lock of an unlocked wallet by
+		// the provided duration.
+		ExtendUnlock(time.Duration) error
 	}
 
 	// KeyManager manages wallet keys, including the use of seeds, creating and
 	// loading backups, and providing a layer of compatibility for older wallet
 	// files.
 	KeyManager interface {
+		// AddressUsage returns the usage statistics of every address that
+		// has been generated from the primary seed, ordered by index.
+		AddressUsage() ([]AddressUsage, error)
+
 		// AllAddresses returns all addresses that the wallet is able to spend
 		// from, including unseeded addresses. Addresses are returned sorted in
 		// byte-order.
@@ -273,6 +497,11 @@ type (
 		// filepath. The backup will have all seeds and keys.
 		CreateBackup(string) error
 
+		// CreateEncryptedBackup functions like CreateBackup, but encrypts the
+		// backup with the provided key as it is written. The backup can be
+		// decrypted with the key's NewStreamReader method.
+		CreateEncryptedBackup(string, crypto.TwofishKey) error
+
 		// LoadBackup will load a backup of the wallet from the provided
 		// address. The backup wallet will be added as an auxiliary seed, not
 		// as a primary seed.
@@ -325,6 +554,12 @@ type (
 		// refund transactions.
 		ConfirmedBalance() (siacoinBalance types.Currency, siafundBalance types.Currency, siacoinClaimBalance types.Currency)
 
+		// SpendableBalance returns the siacoins that will be spendable once
+		// the blockchain reaches the provided height. Confirmed outputs are
+		// only counted if they will have at least minConfirmations
+		// confirmations at that height.
+		SpendableBalance(height types.BlockHeight, minConfirmations types.BlockHeight) (SpendableBalance, error)
+
 		// UnconfirmedBalance returns the unconfirmed balance of the wallet.
 		// Outgoing funds and incoming funds are reported separately. Refund
 		// outputs are included, meaning that sending a single coin to
@@ -332,6 +567,10 @@ type (
 		// not considered in the unconfirmed balance.
 		UnconfirmedBalance() (outgoingSiacoins types.Currency, incomingSiacoins types.Currency)
 
+		// AddressHistory returns the balance and transaction history of a
+		// single wallet address.
+		AddressHistory(types.UnlockHash) (AddressHistory, error)
+
 		// AddressTransactions returns all of the transactions that are related
 		// to a given address.
 		AddressTransactions(types.UnlockHash) []ProcessedTransaction
@@ -354,6 +593,11 @@ type (
 		// relative to the wallet.
 		UnconfirmedTransactions() []ProcessedTransaction
 
+		// OutputProvenance returns the history of an output that the wallet
+		// has controlled, including when it was received, when and where it
+		// was spent, and the outputs it was spent into.
+		OutputProvenance(types.OutputID) (OutputProvenance, error)
+
 		// RegisterTransaction takes a transaction and its parents and returns
 		// a TransactionBuilder which can be used to expand the transaction.
 		RegisterTransaction(t types.Transaction, parents []types.Transaction) TransactionBuilder
@@ -365,7 +609,8 @@ type (
 		// SendSiacoins is a tool for sending siacoins from the wallet to an
 		// address. Sending money usually results in multiple transactions. The
 		// transactions are automatically given to the transaction pool, and
-		// are also returned to the caller.
+		// are also returned to the caller. ErrApprovalRequired is returned if
+		// the amount exceeds the threshold of the approval policy.
 		SendSiacoins(amount types.Currency, dest types.UnlockHash) ([]types.Transaction, error)
 
 		// SendSiafunds is a tool for sending siafunds from the wallet to an
@@ -373,6 +618,71 @@ type (
 		// transactions are automatically given to the transaction pool, and
 		// are also returned to the caller.
 		SendSiafunds(amount types.Currency, dest types.UnlockHash) ([]types.Transaction, error)
+
+		// ApprovalPolicy returns the policy that determines which sends must
+		// be approved.
+		ApprovalPolicy() ApprovalPolicy
+
+		// SetApprovalPolicy sets and persists the approval policy. Pending
+		// sends are not affected.
+		SetApprovalPolicy(ApprovalPolicy) error
+
+		// QueueSiacoins queues a send of siacoins that exceeds the approval
+		// threshold. Nothing is signed until the send is approved.
+		QueueSiacoins(amount types.Currency, dest types.UnlockHash) (PendingSend, error)
+
+		// PendingSends returns the sends that are waiting to be approved.
+		// Expired sends are not included.
+		PendingSends() []PendingSend
+
+		// ApproveSend signs and broadcasts a pending send, returning the
+		// transactions that were given to the transaction pool.
+		ApproveSend(id crypto.Hash) ([]types.Transaction, error)
+
+		// RejectSend removes a pending send without signing it.
+		RejectSend(id crypto.Hash) error
+
+		// AtomicSwapKey returns a new public key from the wallet's primary
+		// seed, to be used as the claim key of an atomic swap.
+		AtomicSwapKey() (types.SiaPublicKey, error)
+
+		// ConstructAtomicSwap funds an atomic swap of 'value' siacoins and
+		// signs the funder's half of the refund transaction. Nothing is
+		// broadcast; the swap must be countersigned by the claimer with
+		// ParticipateAtomicSwap and then given to PublishAtomicSwap.
+		ConstructAtomicSwap(value types.Currency, hashLock, claimKey types.SiaPublicKey, timelock types.BlockHeight) (AtomicSwap, error)
+
+		// AuditAtomicSwap checks that an atomic swap matches the template
+		// created by ConstructAtomicSwap and that its signatures are valid.
+		AuditAtomicSwap(AtomicSwap) error
+
+		// ParticipateAtomicSwap audits an atomic swap whose claim key belongs
+		// to the wallet and countersigns its refund transaction, so that the
+		// funder can refund the swap once the timelock has passed.
+		ParticipateAtomicSwap(AtomicSwap) (AtomicSwap, error)
+
+		// PublishAtomicSwap audits an atomic swap whose refund transaction
+		// has been countersigned and broadcasts its funding transactions.
+		PublishAtomicSwap(AtomicSwap) error
+
+		// ClaimAtomicSwap spends the output of an atomic swap to the wallet,
+		// revealing the secret behind its hashlock. The claim transaction is
+		// broadcast and returned.
+		ClaimAtomicSwap(swap AtomicSwap, secret crypto.Hash) (types.Transaction, error)
+
+		// RefundAtomicSwap broadcasts the refund transaction of an atomic
+		// swap once its timelock has passed.
+		RefundAtomicSwap(AtomicSwap) (types.Transaction, error)
+
+		// CheckDB checks the integrity of the wallet database, reporting
+		// corrupt entries, inconsistencies between the wallet's keys,
+		// processed transactions, and output set, and how they can be
+		// repaired.
+		CheckDB() (WalletDBReport, error)
+
+		// CompactDB rewrites the wallet database without free pages, unknown
+		// buckets, or spent output records that no longer affect the wallet.
+		CompactDB() (WalletDBCompaction, error)
 	}
 )
 
@@ -382,6 +692,41 @@ func CalculateWalletTransactionID(tid types.TransactionID, oid types.OutputID) W
 	return WalletTransactionID(crypto.HashAll(tid, oid))
 }
 
+// AtomicSwapHashLock returns the hashlock of an atomic swap that can be
+// claimed by revealing the provided secret.
+func AtomicSwapHashLock(secret crypto.Hash) types.SiaPublicKey {
+	_, pk := crypto.GenerateKeyPairDeterministic(secret)
+	return types.Ed25519PublicKey(pk)
+}
+
+// UnlockConditions returns the unlock conditions of the swap output. The claim
+// key is listed twice and three signatures are required, so the output can be
+// spent by the claim key together with either the hashlock or the refund key,
+// but not by the hashlock and the refund key alone.
+func (s AtomicSwap) UnlockConditions() types.UnlockConditions {
+	return types.UnlockConditions{
+		PublicKeys:         []types.SiaPublicKey{s.ClaimKey, s.ClaimKey, s.HashLock, s.RefundKey},
+		SignaturesRequired: 3,
+	}
+}
+
+// OutputID returns the ID of the swap output, which is created by the last
+// transaction of the funding set. The bool indicates whether the output was
+// found.
+func (s AtomicSwap) OutputID() (types.SiacoinOutputID, bool) {
+	if len(s.Funding) == 0 {
+		return types.SiacoinOutputID{}, false
+	}
+	txn := s.Funding[len(s.Funding)-1]
+	uh := s.UnlockConditions().UnlockHash()
+	for i, sco := range txn.SiacoinOutputs {
+		if sco.UnlockHash == uh && sco.Value.Equals(s.Value) {
+			return txn.SiacoinOutputID(uint64(i)), true
+		}
+	}
+	return types.SiacoinOutputID{}, false
+}
+
 // SeedToString converts a wallet seed to a human friendly string.
 func SeedToString(seed Seed, did mnemonics.DictionaryID) (string, error) {
 	fullChecksum := crypto.HashObject(seed)
@@ -0,0 +1,192 @@
+package wallet
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/fastrand"
+)
+
+var (
+	errApprovalTimeout     = errors.New("an approval policy with a threshold must have a positive timeout")
+	errApprovalWindow      = errors.New("approval window cannot be negative")
+	errBelowThreshold      = errors.New("amount does not exceed the approval threshold; send it directly")
+	errPendingSendExpired  = errors.New("pending send has expired")
+	errPendingSendNotFound = errors.New("no pending send with that id")
+)
+
+// pendingSendsByCreation sorts pending sends by the time they were queued,
+// oldest first.
+type pendingSendsByCreation []modules.PendingSend
+
+func (ps pendingSendsByCreation) Len() int           { return len(ps) }
+func (ps pendingSendsByCreation) Less(i, j int) bool { return ps[i].Created.Before(ps[j].Created) }
+func (ps pendingSendsByCreation) Swap(i, j int)      { ps[i], ps[j] = ps[j], ps[i] }
+
+// An unapprovedSend is a send that was made without approval, and counts
+// towards the threshold of the approval policy until it leaves the policy's
+// window.
+type unapprovedSend struct {
+	amount types.Currency
+	sent   time.Time
+}
+
+// requiresApproval returns true if a send of amount must be approved under the
+// wallet's approval policy, either because it exceeds the threshold on its own
+// or because it would take the total sent without approval within the
+// policy's window over the threshold. Sends that have left the window are
+// forgotten. It must be called with a write-lock.
+func (w *Wallet) requiresApproval(amount types.Currency, now time.Time) bool {
+	policy := w.approvalPolicy
+	if policy.Threshold.IsZero() {
+		return false
+	}
+	total := amount
+	if policy.Window > 0 {
+		for len(w.unapprovedSends) > 0 && now.Sub(w.unapprovedSends[0].sent) > policy.Window {
+			w.unapprovedSends = w.unapprovedSends[1:]
+		}
+		for _, us := range w.unapprovedSends {
+			total = total.Add(us.amount)
+		}
+	}
+	return total.Cmp(policy.Threshold) > 0
+}
+
+// forgetUnapprovedSend removes a send that failed from the sends counting
+// towards the approval threshold. It must be called with a write-lock.
+func (w *Wallet) forgetUnapprovedSend(us unapprovedSend) {
+	for i := range w.unapprovedSends {
+		if w.unapprovedSends[i].sent.Equal(us.sent) && w.unapprovedSends[i].amount.Equals(us.amount) {
+			w.unapprovedSends = append(w.unapprovedSends[:i], w.unapprovedSends[i+1:]...)
+			return
+		}
+	}
+}
+
+// pruneExpiredSends removes the pending sends that were not approved in time.
+// It must be called with a write-lock.
+func (w *Wallet) pruneExpiredSends(now time.Time) {
+	for id, ps := range w.pendingSends {
+		if now.After(ps.Expires) {
+			w.log.Printf("INFO: Pending send %v of %v hastings to %v expired without approval.", id, ps.Amount, ps.Destination)
+			delete(w.pendingSends, id)
+		}
+	}
+}
+
+// ApprovalPolicy returns the policy that determines which sends must be
+// approved before they are signed.
+func (w *Wallet) ApprovalPolicy() modules.ApprovalPolicy {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.approvalPolicy
+}
+
+// SetApprovalPolicy sets the policy that determines which sends must be
+// approved before they are signed. Sends that are already pending keep their
+// original expiration time.
+func (w *Wallet) SetApprovalPolicy(policy modules.ApprovalPolicy) error {
+	if policy.Timeout < 0 || (!policy.Threshold.IsZero() && policy.Timeout == 0) {
+		return errApprovalTimeout
+	} else if policy.Window < 0 {
+		return errApprovalWindow
+	}
+	if err := w.tg.Add(); err != nil {
+		return err
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := dbPutApprovalPolicy(w.dbTx, policy); err != nil {
+		return err
+	}
+	w.approvalPolicy = policy
+	return nil
+}
+
+// QueueSiacoins queues a send of 'amount' to 'dest' that exceeds the approval
+// threshold. The send is not signed until ApproveSend is called, and expires
+// if it is not approved within the timeout of the approval policy.
+func (w *Wallet) QueueSiacoins(amount types.Currency, dest types.UnlockHash) (modules.PendingSend, error) {
+	if err := w.tg.Add(); err != nil {
+		return modules.PendingSend{}, err
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now()
+	if !w.requiresApproval(amount, now) {
+		return modules.PendingSend{}, errBelowThreshold
+	}
+	w.pruneExpiredSends(now)
+	var id crypto.Hash
+	fastrand.Read(id[:])
+	ps := modules.PendingSend{
+		ID:          id,
+		Amount:      amount,
+		Destination: dest,
+		Created:     now,
+		Expires:     now.Add(w.approvalPolicy.Timeout),
+	}
+	w.pendingSends[id] = ps
+	w.log.Printf("INFO: Queued send %v of %v hastings to %v for approval.", id, amount, dest)
+	return ps, nil
+}
+
+// PendingSends returns the sends that are waiting to be approved, oldest
+// first.
+func (w *Wallet) PendingSends() []modules.PendingSend {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pruneExpiredSends(time.Now())
+
+	pending := make([]modules.PendingSend, 0, len(w.pendingSends))
+	for _, ps := range w.pendingSends {
+		pending = append(pending, ps)
+	}
+	sort.Sort(pendingSendsByCreation(pending))
+	return pending
+}
+
+// ApproveSend signs a pending send and gives it to the transaction pool. The
+// send is removed from the queue even if signing fails, so that a failed send
+// has to be queued and approved again.
+func (w *Wallet) ApproveSend(id crypto.Hash) ([]types.Transaction, error) {
+	if err := w.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer w.tg.Done()
+
+	w.mu.Lock()
+	ps, exists := w.pendingSends[id]
+	delete(w.pendingSends, id)
+	w.mu.Unlock()
+	if !exists {
+		return nil, errPendingSendNotFound
+	} else if time.Now().After(ps.Expires) {
+		return nil, errPendingSendExpired
+	}
+
+	w.log.Printf("INFO: Approved send %v of %v hastings to %v.", id, ps.Amount, ps.Destination)
+	return w.managedSendSiacoins(ps.Amount, ps.Destination)
+}
+
+// RejectSend removes a pending send without signing it.
+func (w *Wallet) RejectSend(id crypto.Hash) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, exists := w.pendingSends[id]; !exists {
+		return errPendingSendNotFound
+	}
+	delete(w.pendingSends, id)
+	w.log.Printf("INFO: Rejected send %v.", id)
+	return nil
+}
@@ -0,0 +1,167 @@
+package wallet
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestApprovalPolicy checks that sends above the approval threshold must be
+// queued and approved, that pending sends can be rejected and expire, and that
+// the policy is persisted.
+func TestApprovalPolicy(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// Invalid policies should be rejected.
+	threshold := types.NewCurrency64(10000)
+	if err := wt.wallet.SetApprovalPolicy(modules.ApprovalPolicy{Threshold: threshold}); err != errApprovalTimeout {
+		t.Fatal("expected errApprovalTimeout, got", err)
+	}
+	if err := wt.wallet.SetApprovalPolicy(modules.ApprovalPolicy{Timeout: -time.Second}); err != errApprovalTimeout {
+		t.Fatal("expected errApprovalTimeout, got", err)
+	}
+	if err := wt.wallet.SetApprovalPolicy(modules.ApprovalPolicy{Threshold: threshold, Timeout: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Sends at or below the threshold should go through directly, and cannot
+	// be queued.
+	if _, err := wt.wallet.SendSiacoins(threshold, types.UnlockHash{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.wallet.QueueSiacoins(threshold, types.UnlockHash{}); err != errBelowThreshold {
+		t.Fatal("expected errBelowThreshold, got", err)
+	}
+
+	// Sends above the threshold should be refused until they are approved.
+	amount := threshold.Add(types.NewCurrency64(1))
+	if _, err := wt.wallet.SendSiacoins(amount, types.UnlockHash{}); err != modules.ErrApprovalRequired {
+		t.Fatal("expected ErrApprovalRequired, got", err)
+	}
+	ps, err := wt.wallet.QueueSiacoins(amount, types.UnlockHash{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending := wt.wallet.PendingSends(); len(pending) != 1 || pending[0].ID != ps.ID {
+		t.Fatal("queued send is not pending:", pending)
+	}
+	txns, err := wt.wallet.ApproveSend(ps.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, txn := range txns {
+		for _, sco := range txn.SiacoinOutputs {
+			found = found || (sco.UnlockHash == types.UnlockHash{1} && sco.Value.Equals(amount))
+		}
+	}
+	if !found {
+		t.Fatal("approved transactions do not pay the destination")
+	}
+	if _, err := wt.wallet.ApproveSend(ps.ID); err != errPendingSendNotFound {
+		t.Fatal("expected errPendingSendNotFound, got", err)
+	}
+
+	// Rejected sends should be removed.
+	ps, err = wt.wallet.QueueSiacoins(amount, types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.wallet.RejectSend(ps.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.wallet.RejectSend(crypto.Hash{}); err != errPendingSendNotFound {
+		t.Fatal("expected errPendingSendNotFound, got", err)
+	}
+	if len(wt.wallet.PendingSends()) != 0 {
+		t.Fatal("rejected send is still pending")
+	}
+
+	// Sends that are not approved in time should expire.
+	if err := wt.wallet.SetApprovalPolicy(modules.ApprovalPolicy{Threshold: threshold, Timeout: time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	ps, err = wt.wallet.QueueSiacoins(amount, types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if _, err := wt.wallet.ApproveSend(ps.ID); err != errPendingSendExpired {
+		t.Fatal("expected errPendingSendExpired, got", err)
+	}
+
+	// The policy should survive a restart.
+	if err := wt.wallet.SetApprovalPolicy(modules.ApprovalPolicy{Threshold: threshold, Timeout: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.wallet.Close(); err != nil {
+		t.Fatal(err)
+	}
+	w, err := New(wt.cs, wt.tpool, filepath.Join(wt.persistDir, modules.WalletDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt.wallet = w
+	if policy := w.ApprovalPolicy(); !policy.Threshold.Equals(threshold) || policy.Timeout != time.Hour {
+		t.Fatal("approval policy was not persisted:", policy)
+	}
+}
+
+// TestApprovalWindow checks that the threshold of an approval policy with a
+// window caps the total sent without approval within the window.
+func TestApprovalWindow(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	if err := wt.wallet.SetApprovalPolicy(modules.ApprovalPolicy{Window: -time.Second}); err != errApprovalWindow {
+		t.Fatal("expected errApprovalWindow, got", err)
+	}
+	threshold := types.NewCurrency64(10000)
+	policy := modules.ApprovalPolicy{Threshold: threshold, Timeout: time.Hour, Window: time.Hour}
+	if err := wt.wallet.SetApprovalPolicy(policy); err != nil {
+		t.Fatal(err)
+	}
+
+	// Splitting a send that exceeds the threshold into two halves should
+	// not avoid approval.
+	half := threshold.Div64(2)
+	if _, err := wt.wallet.SendSiacoins(half, types.UnlockHash{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.wallet.SendSiacoins(half, types.UnlockHash{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.wallet.SendSiacoins(types.NewCurrency64(1), types.UnlockHash{}); err != modules.ErrApprovalRequired {
+		t.Fatal("expected ErrApprovalRequired, got", err)
+	}
+	if _, err := wt.wallet.QueueSiacoins(types.NewCurrency64(1), types.UnlockHash{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Sends that have left the window no longer count.
+	wt.wallet.mu.Lock()
+	for i := range wt.wallet.unapprovedSends {
+		wt.wallet.unapprovedSends[i].sent = time.Now().Add(-2 * time.Hour)
+	}
+	wt.wallet.mu.Unlock()
+	if _, err := wt.wallet.SendSiacoins(types.NewCurrency64(1), types.UnlockHash{}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,333 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	// atomicSwapFee is the miner fee paid by each of the funding, claim, and
+	// refund transactions of an atomic swap.
+	atomicSwapFee = types.SiacoinPrecision.Mul64(10)
+
+	errSwapKeyNotFound    = errors.New("wallet does not hold the key")
+	errSwapSecret         = errors.New("secret does not match the hashlock")
+	errSwapTimelock       = errors.New("timelock of the atomic swap has already passed")
+	errSwapUnsignedRefund = errors.New("refund transaction has not been countersigned by the claimer")
+)
+
+// swapSecretKey returns the secret key in the wallet that corresponds to spk.
+func (w *Wallet) swapSecretKey(spk types.SiaPublicKey) (crypto.SecretKey, bool) {
+	for _, key := range w.keys {
+		for i, pk := range key.UnlockConditions.PublicKeys {
+			if i < len(key.SecretKeys) && pk.Algorithm == spk.Algorithm && bytes.Equal(pk.Key, spk.Key) {
+				return key.SecretKeys[i], true
+			}
+		}
+	}
+	return crypto.SecretKey{}, false
+}
+
+// signSwapInput adds signatures covering the whole transaction to the input
+// of txn that spends the swap output, one for each position of the key in the
+// swap's unlock conditions. The signatures are not valid until the blockchain
+// reaches 'timelock'.
+func signSwapInput(txn *types.Transaction, swap modules.AtomicSwap, outputID types.SiacoinOutputID, sk crypto.SecretKey, timelock types.BlockHeight) error {
+	pk := sk.PublicKey()
+	uc := swap.UnlockConditions()
+	signed := false
+	for i, spk := range uc.PublicKeys {
+		if !bytes.Equal(spk.Key, pk[:]) {
+			continue
+		}
+		txn.TransactionSignatures = append(txn.TransactionSignatures, types.TransactionSignature{
+			ParentID:       crypto.Hash(outputID),
+			CoveredFields:  types.CoveredFields{WholeTransaction: true},
+			PublicKeyIndex: uint64(i),
+			Timelock:       timelock,
+		})
+		sigIndex := len(txn.TransactionSignatures) - 1
+		sig := crypto.SignHash(txn.SigHash(sigIndex), sk)
+		txn.TransactionSignatures[sigIndex].Signature = sig[:]
+		signed = true
+	}
+	if !signed {
+		return errSwapKeyNotFound
+	}
+	return nil
+}
+
+// checkAtomicSwap checks that an atomic swap matches the template created by
+// ConstructAtomicSwap. The funding transactions must be valid at the provided
+// height. The signatures of the refund transaction must be valid, but it may
+// not have been countersigned yet; the countersignatures of the claim key must
+// not be valid before the timelock.
+func checkAtomicSwap(swap modules.AtomicSwap, height types.BlockHeight) error {
+	for _, spk := range []types.SiaPublicKey{swap.HashLock, swap.ClaimKey, swap.RefundKey} {
+		if spk.Algorithm != types.SignatureEd25519 || len(spk.Key) != crypto.PublicKeySize {
+			return build.ExtendErr("atomic swap keys must be ed25519 keys", modules.ErrInvalidAtomicSwap)
+		}
+	}
+	if swap.Value.Cmp(atomicSwapFee) <= 0 {
+		return build.ExtendErr("swap value does not cover the refund fee", modules.ErrInvalidAtomicSwap)
+	}
+	outputID, ok := swap.OutputID()
+	if !ok {
+		return build.ExtendErr("funding transactions do not create the swap output", modules.ErrInvalidAtomicSwap)
+	}
+	for _, txn := range swap.Funding {
+		if err := txn.StandaloneValid(height); err != nil {
+			return build.ExtendErr("invalid funding transaction", err)
+		}
+	}
+
+	uc := swap.UnlockConditions()
+	refund := swap.Refund
+	if len(refund.SiacoinInputs) != 1 || refund.SiacoinInputs[0].ParentID != outputID || refund.SiacoinInputs[0].UnlockConditions.UnlockHash() != uc.UnlockHash() {
+		return build.ExtendErr("refund transaction does not spend the swap output", modules.ErrInvalidAtomicSwap)
+	}
+	for i, sig := range refund.TransactionSignatures {
+		if sig.PublicKeyIndex >= uint64(len(uc.PublicKeys)) || len(sig.Signature) != crypto.SignatureSize {
+			return build.ExtendErr("refund transaction has a malformed signature", modules.ErrInvalidAtomicSwap)
+		}
+		if sig.PublicKeyIndex <= 1 && sig.Timelock != swap.Timelock {
+			return build.ExtendErr("refund countersignature does not respect the timelock", modules.ErrInvalidAtomicSwap)
+		}
+		var pk crypto.PublicKey
+		copy(pk[:], uc.PublicKeys[sig.PublicKeyIndex].Key)
+		var s crypto.Signature
+		copy(s[:], sig.Signature)
+		if err := crypto.VerifyHash(refund.SigHash(i), pk, s); err != nil {
+			return build.ExtendErr("refund transaction has an invalid signature", err)
+		}
+	}
+	return nil
+}
+
+// AtomicSwapKey returns a new public key from the wallet's primary seed, to be
+// used as the claim key of an atomic swap.
+func (w *Wallet) AtomicSwapKey() (types.SiaPublicKey, error) {
+	uc, err := w.NextAddress()
+	if err != nil {
+		return types.SiaPublicKey{}, err
+	}
+	return uc.PublicKeys[0], nil
+}
+
+// ConstructAtomicSwap funds an atomic swap of 'value' siacoins and signs the
+// funder's half of the refund transaction. The refund key and the refund
+// destination are taken from the wallet's primary seed. Nothing is broadcast.
+func (w *Wallet) ConstructAtomicSwap(value types.Currency, hashLock, claimKey types.SiaPublicKey, timelock types.BlockHeight) (modules.AtomicSwap, error) {
+	if err := w.tg.Add(); err != nil {
+		return modules.AtomicSwap{}, err
+	}
+	defer w.tg.Done()
+	if timelock <= w.cs.Height() {
+		return modules.AtomicSwap{}, errSwapTimelock
+	} else if value.Cmp(atomicSwapFee) <= 0 {
+		return modules.AtomicSwap{}, build.ExtendErr("swap value does not cover the refund fee", modules.ErrInvalidAtomicSwap)
+	}
+
+	refundUC, err := w.NextAddress()
+	if err != nil {
+		return modules.AtomicSwap{}, err
+	}
+	swap := modules.AtomicSwap{
+		Value:     value,
+		HashLock:  hashLock,
+		ClaimKey:  claimKey,
+		RefundKey: refundUC.PublicKeys[0],
+		Timelock:  timelock,
+	}
+
+	txnBuilder := w.StartTransaction()
+	err = txnBuilder.FundSiacoins(value.Add(atomicSwapFee))
+	if err != nil {
+		return modules.AtomicSwap{}, build.ExtendErr("unable to fund atomic swap", err)
+	}
+	txnBuilder.AddMinerFee(atomicSwapFee)
+	txnBuilder.AddSiacoinOutput(types.SiacoinOutput{
+		Value:      value,
+		UnlockHash: swap.UnlockConditions().UnlockHash(),
+	})
+	swap.Funding, err = txnBuilder.Sign(true)
+	if err != nil {
+		txnBuilder.Drop()
+		return modules.AtomicSwap{}, build.ExtendErr("unable to sign funding transaction", err)
+	}
+
+	outputID, _ := swap.OutputID()
+	swap.Refund = types.Transaction{
+		SiacoinInputs: []types.SiacoinInput{{
+			ParentID:         outputID,
+			UnlockConditions: swap.UnlockConditions(),
+		}},
+		SiacoinOutputs: []types.SiacoinOutput{{
+			Value:      value.Sub(atomicSwapFee),
+			UnlockHash: refundUC.UnlockHash(),
+		}},
+		MinerFees: []types.Currency{atomicSwapFee},
+	}
+	w.mu.RLock()
+	sk, ok := w.swapSecretKey(swap.RefundKey)
+	w.mu.RUnlock()
+	if !ok {
+		return modules.AtomicSwap{}, errSwapKeyNotFound
+	}
+	if err := signSwapInput(&swap.Refund, swap, outputID, sk, 0); err != nil {
+		return modules.AtomicSwap{}, err
+	}
+	return swap, nil
+}
+
+// AuditAtomicSwap checks that an atomic swap matches the template created by
+// ConstructAtomicSwap and that its signatures are valid. It does not check
+// whether the swap has been funded; the claimer should wait for the funding
+// transactions to be confirmed before revealing the secret.
+func (w *Wallet) AuditAtomicSwap(swap modules.AtomicSwap) error {
+	return checkAtomicSwap(swap, w.cs.Height())
+}
+
+// ParticipateAtomicSwap audits an atomic swap whose claim key belongs to the
+// wallet and countersigns its refund transaction with a signature that is not
+// valid until the timelock.
+func (w *Wallet) ParticipateAtomicSwap(swap modules.AtomicSwap) (modules.AtomicSwap, error) {
+	if err := w.tg.Add(); err != nil {
+		return modules.AtomicSwap{}, err
+	}
+	defer w.tg.Done()
+
+	height := w.cs.Height()
+	if err := checkAtomicSwap(swap, height); err != nil {
+		return modules.AtomicSwap{}, err
+	}
+	if swap.Timelock <= height {
+		return modules.AtomicSwap{}, errSwapTimelock
+	}
+	for _, sig := range swap.Refund.TransactionSignatures {
+		if sig.PublicKeyIndex == 0 {
+			return swap, nil
+		}
+	}
+
+	w.mu.RLock()
+	unlocked := w.unlocked
+	sk, ok := w.swapSecretKey(swap.ClaimKey)
+	w.mu.RUnlock()
+	if !unlocked {
+		return modules.AtomicSwap{}, modules.ErrLockedWallet
+	} else if !ok {
+		return modules.AtomicSwap{}, errSwapKeyNotFound
+	}
+	outputID, _ := swap.OutputID()
+	swap.Refund.TransactionSignatures = append([]types.TransactionSignature(nil), swap.Refund.TransactionSignatures...)
+	if err := signSwapInput(&swap.Refund, swap, outputID, sk, swap.Timelock); err != nil {
+		return modules.AtomicSwap{}, err
+	}
+	return swap, nil
+}
+
+// PublishAtomicSwap audits an atomic swap whose refund transaction has been
+// countersigned and broadcasts its funding transactions.
+func (w *Wallet) PublishAtomicSwap(swap modules.AtomicSwap) error {
+	if err := w.tg.Add(); err != nil {
+		return err
+	}
+	defer w.tg.Done()
+
+	if err := checkAtomicSwap(swap, w.cs.Height()); err != nil {
+		return err
+	}
+	if err := swap.Refund.StandaloneValid(swap.Timelock); err != nil {
+		return build.ComposeErrors(errSwapUnsignedRefund, err)
+	}
+	err := w.managedSubmitTransactionSet(swap.Funding)
+	if err != nil {
+		return build.ExtendErr("unable to get funding transactions accepted", err)
+	}
+	return nil
+}
+
+// ClaimAtomicSwap spends the output of an atomic swap to the wallet using the
+// claim key and the key derived from the secret. The secret is revealed in the
+// arbitrary data of the claim transaction, so that the funder can use it to
+// complete their side of the swap.
+func (w *Wallet) ClaimAtomicSwap(swap modules.AtomicSwap, secret crypto.Hash) (types.Transaction, error) {
+	if err := w.tg.Add(); err != nil {
+		return types.Transaction{}, err
+	}
+	defer w.tg.Done()
+
+	hashLock := modules.AtomicSwapHashLock(secret)
+	if !bytes.Equal(hashLock.Key, swap.HashLock.Key) {
+		return types.Transaction{}, errSwapSecret
+	}
+	if err := checkAtomicSwap(swap, w.cs.Height()); err != nil {
+		return types.Transaction{}, err
+	}
+	w.mu.RLock()
+	unlocked := w.unlocked
+	sk, ok := w.swapSecretKey(swap.ClaimKey)
+	w.mu.RUnlock()
+	if !unlocked {
+		return types.Transaction{}, modules.ErrLockedWallet
+	} else if !ok {
+		return types.Transaction{}, errSwapKeyNotFound
+	}
+	claimUC, err := w.NextAddress()
+	if err != nil {
+		return types.Transaction{}, err
+	}
+
+	outputID, _ := swap.OutputID()
+	claim := types.Transaction{
+		SiacoinInputs: []types.SiacoinInput{{
+			ParentID:         outputID,
+			UnlockConditions: swap.UnlockConditions(),
+		}},
+		SiacoinOutputs: []types.SiacoinOutput{{
+			Value:      swap.Value.Sub(atomicSwapFee),
+			UnlockHash: claimUC.UnlockHash(),
+		}},
+		MinerFees:     []types.Currency{atomicSwapFee},
+		ArbitraryData: [][]byte{append(modules.PrefixNonSia[:], secret[:]...)},
+	}
+	hashKey, _ := crypto.GenerateKeyPairDeterministic(secret)
+	for _, key := range []crypto.SecretKey{sk, hashKey} {
+		if err := signSwapInput(&claim, swap, outputID, key, 0); err != nil {
+			return types.Transaction{}, err
+		}
+	}
+	err = w.managedSubmitTransactionSet([]types.Transaction{claim})
+	if err != nil {
+		return types.Transaction{}, build.ExtendErr("unable to get claim transaction accepted", err)
+	}
+	return claim, nil
+}
+
+// RefundAtomicSwap broadcasts the countersigned refund transaction of an
+// atomic swap. The transaction pool rejects the refund until the timelock
+// has passed.
+func (w *Wallet) RefundAtomicSwap(swap modules.AtomicSwap) (types.Transaction, error) {
+	if err := w.tg.Add(); err != nil {
+		return types.Transaction{}, err
+	}
+	defer w.tg.Done()
+
+	if err := checkAtomicSwap(swap, w.cs.Height()); err != nil {
+		return types.Transaction{}, err
+	}
+	if err := swap.Refund.StandaloneValid(swap.Timelock); err != nil {
+		return types.Transaction{}, build.ComposeErrors(errSwapUnsignedRefund, err)
+	}
+	err := w.managedSubmitTransactionSet([]types.Transaction{swap.Refund})
+	if err != nil {
+		return types.Transaction{}, build.ExtendErr("unable to get refund transaction accepted", err)
+	}
+	return swap.Refund, nil
+}
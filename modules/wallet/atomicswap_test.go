@@ -0,0 +1,137 @@
+package wallet
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestAtomicSwap checks that an atomic swap can be claimed with its secret,
+// and that a swap that is not claimed can be refunded once its timelock has
+// passed.
+func TestAtomicSwap(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// The wallet plays both the funder and the claimer.
+	var secret crypto.Hash
+	secret[0] = 1
+	hashLock := modules.AtomicSwapHashLock(secret)
+	claimKey, err := wt.wallet.AtomicSwapKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := types.SiacoinPrecision.Mul64(100)
+	newSwap := func(timelock types.BlockHeight) modules.AtomicSwap {
+		swap, err := wt.wallet.ConstructAtomicSwap(value, hashLock, claimKey, timelock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := wt.wallet.AuditAtomicSwap(swap); err != nil {
+			t.Fatal(err)
+		}
+		// The funding transactions cannot be published until the claimer
+		// has countersigned the refund.
+		if err := wt.wallet.PublishAtomicSwap(swap); err == nil {
+			t.Fatal("published a swap without a countersigned refund")
+		}
+		swap, err = wt.wallet.ParticipateAtomicSwap(swap)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := wt.wallet.PublishAtomicSwap(swap); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := wt.miner.AddBlock(); err != nil {
+			t.Fatal(err)
+		}
+		return swap
+	}
+
+	// A tampered swap should fail the audit.
+	swap := newSwap(wt.cs.Height() + 10)
+	tampered := swap
+	tampered.Timelock++
+	if err := wt.wallet.AuditAtomicSwap(tampered); err == nil {
+		t.Fatal("audit accepted a swap with the wrong timelock")
+	}
+
+	// The refund key and the hashlock must not be able to spend the output
+	// without the claim key, even once the secret is known.
+	wt.wallet.mu.RLock()
+	refundKey, ok := wt.wallet.swapSecretKey(swap.RefundKey)
+	wt.wallet.mu.RUnlock()
+	if !ok {
+		t.Fatal("wallet does not hold the refund key")
+	}
+	hashKey, _ := crypto.GenerateKeyPairDeterministic(secret)
+	outputID, _ := swap.OutputID()
+	steal := types.Transaction{
+		SiacoinInputs: []types.SiacoinInput{{
+			ParentID:         outputID,
+			UnlockConditions: swap.UnlockConditions(),
+		}},
+		SiacoinOutputs: []types.SiacoinOutput{{
+			Value:      swap.Value.Sub(atomicSwapFee),
+			UnlockHash: swap.Refund.SiacoinOutputs[0].UnlockHash,
+		}},
+		MinerFees: []types.Currency{atomicSwapFee},
+	}
+	for _, sk := range []crypto.SecretKey{refundKey, hashKey} {
+		if err := signSwapInput(&steal, swap, outputID, sk, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := steal.StandaloneValid(wt.cs.Height()); err == nil {
+		t.Fatal("refund key and hashlock were able to spend the swap output")
+	}
+
+	// Claim the swap. The wrong secret is refused, and the claim reveals the
+	// secret.
+	if _, err := wt.wallet.ClaimAtomicSwap(swap, crypto.Hash{}); err != errSwapSecret {
+		t.Fatal("expected errSwapSecret, got", err)
+	}
+	claim, err := wt.wallet.ClaimAtomicSwap(swap, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claim.ArbitraryData) != 1 || !bytes.HasSuffix(claim.ArbitraryData[0], secret[:]) {
+		t.Fatal("claim transaction does not reveal the secret")
+	}
+	if _, err := wt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.wallet.RefundAtomicSwap(swap); err == nil {
+		t.Fatal("refunded a swap that was already claimed")
+	}
+
+	// The refund of a second swap is refused until the timelock has passed.
+	swap = newSwap(wt.cs.Height() + 3)
+	if _, err := wt.wallet.RefundAtomicSwap(swap); err == nil {
+		t.Fatal("refunded a swap before its timelock")
+	}
+	for wt.cs.Height() < swap.Timelock {
+		if _, err := wt.miner.AddBlock(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	refund, err := wt.wallet.RefundAtomicSwap(swap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := wt.wallet.Transaction(refund.ID()); !ok {
+		t.Fatal("refund transaction was not confirmed")
+	}
+}
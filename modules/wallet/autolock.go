@@ -0,0 +1,127 @@
+package wallet
+
+import (
+	"errors"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+var (
+	errInvalidUnlockExtension = errors.New("unlock extension must be greater than zero")
+	errNegativeLockTimeout    = errors.New("lock timeout cannot be negative")
+	errNoLockTimeout          = errors.New("wallet does not have a lock timeout")
+)
+
+// LockTimeout returns the amount of time the wallet may remain unlocked
+// without signing a transaction before it locks itself. A timeout of zero
+// means that the wallet never locks itself.
+func (w *Wallet) LockTimeout() time.Duration {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.lockTimeout
+}
+
+// SetLockTimeout sets the amount of time the wallet may remain unlocked
+// without signing a transaction before it locks itself. A timeout of zero
+// disables automatic locking. If the wallet is unlocked, the new timeout takes
+// effect immediately, counting from the time of the call.
+func (w *Wallet) SetLockTimeout(timeout time.Duration) error {
+	if timeout < 0 {
+		return errNegativeLockTimeout
+	}
+	if err := w.tg.Add(); err != nil {
+		return err
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := dbPutLockTimeout(w.dbTx, timeout); err != nil {
+		return err
+	}
+	w.lockTimeout = timeout
+	if w.unlocked {
+		if timeout == 0 {
+			w.setUnlockedUntil(time.Time{})
+		} else {
+			w.setUnlockedUntil(time.Now().Add(timeout))
+		}
+	}
+	return nil
+}
+
+// UnlockedUntil returns the time at which the wallet will lock itself. The
+// zero time is returned if the wallet is locked or has no lock timeout.
+func (w *Wallet) UnlockedUntil() time.Time {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.unlockedUntil
+}
+
+// ExtendUnlock postpones the automatic lock of an unlocked wallet by the
+// provided duration.
+func (w *Wallet) ExtendUnlock(duration time.Duration) error {
+	if duration <= 0 {
+		return errInvalidUnlockExtension
+	}
+	if err := w.tg.Add(); err != nil {
+		return err
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.unlocked {
+		return modules.ErrLockedWallet
+	} else if w.unlockedUntil.IsZero() {
+		return errNoLockTimeout
+	}
+	w.setUnlockedUntil(w.unlockedUntil.Add(duration))
+	return nil
+}
+
+// managedPostponeAutoLock restarts the automatic lock countdown. It is called
+// whenever the wallet signs a transaction. A deadline that was extended past
+// the full timeout is left alone.
+func (w *Wallet) managedPostponeAutoLock() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.unlocked || w.lockTimeout == 0 {
+		return
+	}
+	if deadline := time.Now().Add(w.lockTimeout); deadline.After(w.unlockedUntil) {
+		w.setUnlockedUntil(deadline)
+	}
+}
+
+// setUnlockedUntil schedules the wallet to lock itself at the provided time,
+// replacing any previously scheduled lock. A zero time cancels the automatic
+// lock. It must be called with a write-lock.
+func (w *Wallet) setUnlockedUntil(deadline time.Time) {
+	if w.lockTimer != nil {
+		w.lockTimer.Stop()
+		w.lockTimer = nil
+	}
+	w.unlockedUntil = deadline
+	if !deadline.IsZero() {
+		w.lockTimer = time.AfterFunc(time.Until(deadline), w.threadedAutoLock)
+	}
+}
+
+// threadedAutoLock locks the wallet once its unlock deadline has passed.
+func (w *Wallet) threadedAutoLock() {
+	if err := w.tg.Add(); err != nil {
+		return
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// The deadline may have been moved or cleared while the timer was firing.
+	if !w.unlocked || w.unlockedUntil.IsZero() || time.Now().Before(w.unlockedUntil) {
+		return
+	}
+	w.log.Println("INFO: Locking wallet after", w.lockTimeout, "without signing a transaction.")
+	w.lock()
+}
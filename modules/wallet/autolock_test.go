@@ -0,0 +1,98 @@
+package wallet
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestLockTimeout checks that the wallet locks itself after the lock timeout
+// elapses, that signing and extending postpone the lock, and that the timeout
+// is persisted.
+func TestLockTimeout(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// By default, the wallet should never lock itself.
+	if wt.wallet.LockTimeout() != 0 || !wt.wallet.UnlockedUntil().IsZero() {
+		t.Fatal("wallet should not have a lock timeout by default")
+	}
+	if err := wt.wallet.ExtendUnlock(time.Hour); err != errNoLockTimeout {
+		t.Fatal("expected errNoLockTimeout, got", err)
+	}
+	if err := wt.wallet.SetLockTimeout(-time.Second); err != errNegativeLockTimeout {
+		t.Fatal("expected errNegativeLockTimeout, got", err)
+	}
+
+	// Setting a timeout on an unlocked wallet should take effect immediately.
+	if err := wt.wallet.SetLockTimeout(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if remaining := time.Until(wt.wallet.UnlockedUntil()); remaining <= 0 || remaining > time.Hour {
+		t.Fatal("unexpected remaining unlock time:", remaining)
+	}
+	if err := wt.wallet.ExtendUnlock(0); err != errInvalidUnlockExtension {
+		t.Fatal("expected errInvalidUnlockExtension, got", err)
+	}
+	if err := wt.wallet.ExtendUnlock(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if remaining := time.Until(wt.wallet.UnlockedUntil()); remaining <= time.Hour {
+		t.Fatal("unlock time was not extended:", remaining)
+	}
+	// Signing a transaction should not shorten an extended deadline.
+	if _, err := wt.wallet.SendSiacoins(types.NewCurrency64(5000), types.UnlockHash{}); err != nil {
+		t.Fatal(err)
+	}
+	if remaining := time.Until(wt.wallet.UnlockedUntil()); remaining <= time.Hour {
+		t.Fatal("signing shortened the unlock time:", remaining)
+	}
+
+	// A short timeout should lock the wallet without intervention.
+	if err := wt.wallet.SetLockTimeout(100 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50 && wt.wallet.Unlocked(); i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if wt.wallet.Unlocked() {
+		t.Fatal("wallet did not lock itself after the lock timeout")
+	}
+	if !wt.wallet.UnlockedUntil().IsZero() {
+		t.Fatal("locked wallet should not report an unlock deadline")
+	}
+	if err := wt.wallet.ExtendUnlock(time.Hour); err != modules.ErrLockedWallet {
+		t.Fatal("expected ErrLockedWallet, got", err)
+	}
+
+	// The timeout should survive a restart, and apply to the next unlock.
+	if err := wt.wallet.SetLockTimeout(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.wallet.Close(); err != nil {
+		t.Fatal(err)
+	}
+	w, err := New(wt.cs, wt.tpool, filepath.Join(wt.persistDir, modules.WalletDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt.wallet = w
+	if w.LockTimeout() != time.Hour {
+		t.Fatal("lock timeout was not persisted:", w.LockTimeout())
+	}
+	if err := w.Unlock(wt.walletMasterKey); err != nil {
+		t.Fatal(err)
+	}
+	if remaining := time.Until(w.UnlockedUntil()); remaining <= 0 || remaining > time.Hour {
+		t.Fatal("unexpected remaining unlock time after restart:", remaining)
+	}
+}
@@ -0,0 +1,181 @@
+package wallet
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// boltStore is a walletStore backed by a bolt database. Each of the wallet's
+// buckets is a bolt bucket. The wallet's history is not indexed, so history
+// queries scan bucketProcessedTransactions.
+type boltStore struct {
+	db *persist.BoltDatabase
+}
+
+// boltTx is a walletTx on a boltStore.
+type boltTx struct {
+	tx *bolt.Tx
+}
+
+// openBoltStore opens the bolt database at filename, creating it and the
+// wallet's buckets if they do not exist.
+func openBoltStore(filename string) (*boltStore, error) {
+	db, err := persist.OpenDatabase(dbMetadata, filename)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, b := range dbBuckets {
+			_, err := tx.CreateBucketIfNotExists(b)
+			if err != nil {
+				return fmt.Errorf("could not create bucket %v: %v", string(b), err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &boltStore{db: db}, nil
+}
+
+// Begin implements walletStore.
+func (s *boltStore) Begin() (walletTx, error) {
+	tx, err := s.db.Begin(true)
+	if err != nil {
+		return nil, err
+	}
+	return &boltTx{tx: tx}, nil
+}
+
+// Close implements walletStore.
+func (s *boltStore) Close() error {
+	return s.db.Close()
+}
+
+// Path implements walletStore.
+func (s *boltStore) Path() string {
+	return s.db.Path()
+}
+
+// Get implements walletTx.
+func (btx *boltTx) Get(bucket, key []byte) []byte {
+	return btx.tx.Bucket(bucket).Get(key)
+}
+
+// Put implements walletTx.
+func (btx *boltTx) Put(bucket, key, val []byte) error {
+	return btx.tx.Bucket(bucket).Put(key, val)
+}
+
+// Delete implements walletTx.
+func (btx *boltTx) Delete(bucket, key []byte) error {
+	return btx.tx.Bucket(bucket).Delete(key)
+}
+
+// ForEach implements walletTx.
+func (btx *boltTx) ForEach(bucket []byte, fn func(key, val []byte) error) error {
+	return btx.tx.Bucket(bucket).ForEach(fn)
+}
+
+// Last implements walletTx.
+func (btx *boltTx) Last(bucket []byte) (key, val []byte) {
+	return btx.tx.Bucket(bucket).Cursor().Last()
+}
+
+// NextSequence implements walletTx.
+func (btx *boltTx) NextSequence(bucket []byte) (uint64, error) {
+	return btx.tx.Bucket(bucket).NextSequence()
+}
+
+// Sequence implements walletTx.
+func (btx *boltTx) Sequence(bucket []byte) uint64 {
+	return btx.tx.Bucket(bucket).Sequence()
+}
+
+// SetSequence implements walletTx.
+func (btx *boltTx) SetSequence(bucket []byte, seq uint64) error {
+	return btx.tx.Bucket(bucket).SetSequence(seq)
+}
+
+// BucketNames implements walletTx.
+func (btx *boltTx) BucketNames() (names [][]byte) {
+	btx.tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+		names = append(names, append([]byte(nil), name...))
+		return nil
+	})
+	return names
+}
+
+// ProcessedTransaction implements walletTx.
+func (btx *boltTx) ProcessedTransaction(txid types.TransactionID) (pt modules.ProcessedTransaction, found bool, err error) {
+	err = scanProcessedTransactions(btx, func(p modules.ProcessedTransaction) bool {
+		if p.TransactionID == txid {
+			pt, found = p, true
+		}
+		return !found
+	})
+	return pt, found, err
+}
+
+// AddressTransactions implements walletTx.
+func (btx *boltTx) AddressTransactions(uh types.UnlockHash) (pts []modules.ProcessedTransaction, err error) {
+	err = scanProcessedTransactions(btx, func(pt modules.ProcessedTransaction) bool {
+		if processedTransactionInvolves(pt, uh) {
+			pts = append(pts, pt)
+		}
+		return true
+	})
+	return pts, err
+}
+
+// TransactionsInRange implements walletTx.
+func (btx *boltTx) TransactionsInRange(startHeight, endHeight types.BlockHeight) (pts []modules.ProcessedTransaction, err error) {
+	err = scanProcessedTransactions(btx, func(pt modules.ProcessedTransaction) bool {
+		// transactions are stored in chronological order, so we can stop as
+		// soon as we are above endHeight
+		if pt.ConfirmationHeight > endHeight {
+			return false
+		}
+		if pt.ConfirmationHeight >= startHeight {
+			pts = append(pts, pt)
+		}
+		return true
+	})
+	return pts, err
+}
+
+// Size implements walletTx.
+func (btx *boltTx) Size() int64 {
+	return btx.tx.Size()
+}
+
+// Check implements walletTx.
+func (btx *boltTx) Check() (errs []error) {
+	for err := range btx.tx.Check() {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// WriteTo implements walletTx.
+func (btx *boltTx) WriteTo(w io.Writer) (int64, error) {
+	return btx.tx.WriteTo(w)
+}
+
+// Commit implements walletTx.
+func (btx *boltTx) Commit() error {
+	return btx.tx.Commit()
+}
+
+// Rollback implements walletTx.
+func (btx *boltTx) Rollback() error {
+	return btx.tx.Rollback()
+}
@@ -8,11 +8,14 @@ import (
 	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
-
-	"github.com/NebulousLabs/bolt"
 )
 
 var (
+	// bucketDelayedSiacoinOutputs maps a SiacoinOutputID to a
+	// delayedSiacoinOutput. Only delayed outputs that the wallet controls are
+	// stored, and outputs are removed once they mature. The wallet uses these
+	// outputs to report balances that will become spendable in the future.
+	bucketDelayedSiacoinOutputs = []byte("bucketDelayedSiacoinOutputs")
 	// bucketHistoricClaimStarts maps a SiafundOutputID to the value of the
 	// siafund pool when the output was processed. It stores every such output
 	// in the blockchain. The wallet uses this mapping to determine the "claim
@@ -47,6 +50,7 @@ var (
 	bucketWallet = []byte("bucketWallet")
 
 	dbBuckets = [][]byte{
+		bucketDelayedSiacoinOutputs,
 		bucketHistoricClaimStarts,
 		bucketHistoricOutputs,
 		bucketProcessedTransactions,
@@ -65,8 +69,19 @@ var (
 	keyConsensusHeight        = []byte("keyConsensusHeight")
 	keySpendableKeyFiles      = []byte("keySpendableKeyFiles")
 	keyAuxiliarySeedFiles     = []byte("keyAuxiliarySeedFiles")
+	keyLockTimeout            = []byte("keyLockTimeout")
+	keyApprovalPolicy         = []byte("keyApprovalPolicy")
+	keyDelayedOutputsScanned  = []byte("keyDelayedOutputsScanned")
 )
 
+// delayedSiacoinOutput is a siacoin output that cannot be spent until the
+// blockchain reaches its maturity height, such as a miner payout or a file
+// contract payout.
+type delayedSiacoinOutput struct {
+	SiacoinOutput  types.SiacoinOutput
+	MaturityHeight types.BlockHeight
+}
+
 // threadedDBUpdate commits the active database transaction and starts a new
 // transaction.
 func (w *Wallet) threadedDBUpdate() {
@@ -97,40 +112,40 @@ func (w *Wallet) syncDB() {
 		w.dbTx.Rollback()
 	}
 	// begin a new tx
-	w.dbTx, err = w.db.Begin(true)
+	w.dbTx, err = w.db.Begin()
 	if err != nil {
 		w.log.Severe("ERROR: failed to start database update:", err)
 	}
 }
 
 // dbPut is a helper function for storing a marshalled key/value pair.
-func dbPut(b *bolt.Bucket, key, val interface{}) error {
-	return b.Put(encoding.Marshal(key), encoding.Marshal(val))
+func dbPut(tx walletTx, bucket []byte, key, val interface{}) error {
+	return tx.Put(bucket, encoding.Marshal(key), encoding.Marshal(val))
 }
 
 // dbGet is a helper function for retrieving a marshalled key/value pair. val
 // must be a pointer.
-func dbGet(b *bolt.Bucket, key, val interface{}) error {
-	return encoding.Unmarshal(b.Get(encoding.Marshal(key)), val)
+func dbGet(tx walletTx, bucket []byte, key, val interface{}) error {
+	return encoding.Unmarshal(tx.Get(bucket, encoding.Marshal(key)), val)
 }
 
 // dbDelete is a helper function for deleting a marshalled key/value pair.
-func dbDelete(b *bolt.Bucket, key interface{}) error {
-	return b.Delete(encoding.Marshal(key))
+func dbDelete(tx walletTx, bucket []byte, key interface{}) error {
+	return tx.Delete(bucket, encoding.Marshal(key))
 }
 
 // dbForEach is a helper function for iterating over a bucket and calling fn
 // on each entry. fn must be a function with two parameters. The key/value
 // bytes of each bucket entry will be unmarshalled into the types of fn's
 // parameters.
-func dbForEach(b *bolt.Bucket, fn interface{}) error {
+func dbForEach(tx walletTx, bucket []byte, fn interface{}) error {
 	// check function type
 	fnVal, fnTyp := reflect.ValueOf(fn), reflect.TypeOf(fn)
 	if fnTyp.Kind() != reflect.Func || fnTyp.NumIn() != 2 {
 		panic("bad fn type: needed func(key, val), got " + fnTyp.String())
 	}
 
-	return b.ForEach(func(keyBytes, valBytes []byte) error {
+	return tx.ForEach(bucket, func(keyBytes, valBytes []byte) error {
 		key, val := reflect.New(fnTyp.In(0)), reflect.New(fnTyp.In(1))
 		if err := encoding.Unmarshal(keyBytes, key.Interface()); err != nil {
 			return err
@@ -144,130 +159,174 @@ func dbForEach(b *bolt.Bucket, fn interface{}) error {
 
 // Type-safe wrappers around the db helpers
 
-func dbPutHistoricClaimStart(tx *bolt.Tx, id types.SiafundOutputID, c types.Currency) error {
-	return dbPut(tx.Bucket(bucketHistoricClaimStarts), id, c)
+func dbPutDelayedSiacoinOutput(tx walletTx, id types.SiacoinOutputID, dsco delayedSiacoinOutput) error {
+	return dbPut(tx, bucketDelayedSiacoinOutputs, id, dsco)
 }
-func dbGetHistoricClaimStart(tx *bolt.Tx, id types.SiafundOutputID) (c types.Currency, err error) {
-	err = dbGet(tx.Bucket(bucketHistoricClaimStarts), id, &c)
+func dbDeleteDelayedSiacoinOutput(tx walletTx, id types.SiacoinOutputID) error {
+	return dbDelete(tx, bucketDelayedSiacoinOutputs, id)
+}
+func dbForEachDelayedSiacoinOutput(tx walletTx, fn func(types.SiacoinOutputID, delayedSiacoinOutput)) error {
+	return dbForEach(tx, bucketDelayedSiacoinOutputs, fn)
+}
+
+func dbPutHistoricClaimStart(tx walletTx, id types.SiafundOutputID, c types.Currency) error {
+	return dbPut(tx, bucketHistoricClaimStarts, id, c)
+}
+func dbGetHistoricClaimStart(tx walletTx, id types.SiafundOutputID) (c types.Currency, err error) {
+	err = dbGet(tx, bucketHistoricClaimStarts, id, &c)
 	return
 }
 
-func dbPutHistoricOutput(tx *bolt.Tx, id types.OutputID, c types.Currency) error {
-	return dbPut(tx.Bucket(bucketHistoricOutputs), id, c)
+func dbPutHistoricOutput(tx walletTx, id types.OutputID, c types.Currency) error {
+	return dbPut(tx, bucketHistoricOutputs, id, c)
 }
-func dbGetHistoricOutput(tx *bolt.Tx, id types.OutputID) (c types.Currency, err error) {
-	err = dbGet(tx.Bucket(bucketHistoricOutputs), id, &c)
+func dbGetHistoricOutput(tx walletTx, id types.OutputID) (c types.Currency, err error) {
+	err = dbGet(tx, bucketHistoricOutputs, id, &c)
 	return
 }
 
-func dbPutSiacoinOutput(tx *bolt.Tx, id types.SiacoinOutputID, output types.SiacoinOutput) error {
-	return dbPut(tx.Bucket(bucketSiacoinOutputs), id, output)
+func dbPutSiacoinOutput(tx walletTx, id types.SiacoinOutputID, output types.SiacoinOutput) error {
+	return dbPut(tx, bucketSiacoinOutputs, id, output)
 }
-func dbGetSiacoinOutput(tx *bolt.Tx, id types.SiacoinOutputID) (output types.SiacoinOutput, err error) {
-	err = dbGet(tx.Bucket(bucketSiacoinOutputs), id, &output)
+func dbGetSiacoinOutput(tx walletTx, id types.SiacoinOutputID) (output types.SiacoinOutput, err error) {
+	err = dbGet(tx, bucketSiacoinOutputs, id, &output)
 	return
 }
-func dbDeleteSiacoinOutput(tx *bolt.Tx, id types.SiacoinOutputID) error {
-	return dbDelete(tx.Bucket(bucketSiacoinOutputs), id)
+func dbDeleteSiacoinOutput(tx walletTx, id types.SiacoinOutputID) error {
+	return dbDelete(tx, bucketSiacoinOutputs, id)
 }
-func dbForEachSiacoinOutput(tx *bolt.Tx, fn func(types.SiacoinOutputID, types.SiacoinOutput)) error {
-	return dbForEach(tx.Bucket(bucketSiacoinOutputs), fn)
+func dbForEachSiacoinOutput(tx walletTx, fn func(types.SiacoinOutputID, types.SiacoinOutput)) error {
+	return dbForEach(tx, bucketSiacoinOutputs, fn)
 }
 
-func dbPutSiafundOutput(tx *bolt.Tx, id types.SiafundOutputID, output types.SiafundOutput) error {
-	return dbPut(tx.Bucket(bucketSiafundOutputs), id, output)
+func dbPutSiafundOutput(tx walletTx, id types.SiafundOutputID, output types.SiafundOutput) error {
+	return dbPut(tx, bucketSiafundOutputs, id, output)
 }
-func dbGetSiafundOutput(tx *bolt.Tx, id types.SiafundOutputID) (output types.SiafundOutput, err error) {
-	err = dbGet(tx.Bucket(bucketSiafundOutputs), id, &output)
+func dbGetSiafundOutput(tx walletTx, id types.SiafundOutputID) (output types.SiafundOutput, err error) {
+	err = dbGet(tx, bucketSiafundOutputs, id, &output)
 	return
 }
-func dbDeleteSiafundOutput(tx *bolt.Tx, id types.SiafundOutputID) error {
-	return dbDelete(tx.Bucket(bucketSiafundOutputs), id)
+func dbDeleteSiafundOutput(tx walletTx, id types.SiafundOutputID) error {
+	return dbDelete(tx, bucketSiafundOutputs, id)
 }
-func dbForEachSiafundOutput(tx *bolt.Tx, fn func(types.SiafundOutputID, types.SiafundOutput)) error {
-	return dbForEach(tx.Bucket(bucketSiafundOutputs), fn)
+func dbForEachSiafundOutput(tx walletTx, fn func(types.SiafundOutputID, types.SiafundOutput)) error {
+	return dbForEach(tx, bucketSiafundOutputs, fn)
 }
 
-func dbPutSpentOutput(tx *bolt.Tx, id types.OutputID, height types.BlockHeight) error {
-	return dbPut(tx.Bucket(bucketSpentOutputs), id, height)
+func dbPutSpentOutput(tx walletTx, id types.OutputID, height types.BlockHeight) error {
+	return dbPut(tx, bucketSpentOutputs, id, height)
 }
-func dbGetSpentOutput(tx *bolt.Tx, id types.OutputID) (height types.BlockHeight, err error) {
-	err = dbGet(tx.Bucket(bucketSpentOutputs), id, &height)
+func dbGetSpentOutput(tx walletTx, id types.OutputID) (height types.BlockHeight, err error) {
+	err = dbGet(tx, bucketSpentOutputs, id, &height)
 	return
 }
-func dbDeleteSpentOutput(tx *bolt.Tx, id types.OutputID) error {
-	return dbDelete(tx.Bucket(bucketSpentOutputs), id)
+func dbDeleteSpentOutput(tx walletTx, id types.OutputID) error {
+	return dbDelete(tx, bucketSpentOutputs, id)
 }
 
 // bucketProcessedTransactions works a little differently: the key is
 // meaningless, only used to order the transactions chronologically.
 
-func dbAppendProcessedTransaction(tx *bolt.Tx, pt modules.ProcessedTransaction) error {
-	b := tx.Bucket(bucketProcessedTransactions)
-	key, err := b.NextSequence()
+func dbAppendProcessedTransaction(tx walletTx, pt modules.ProcessedTransaction) error {
+	key, err := tx.NextSequence(bucketProcessedTransactions)
 	if err != nil {
 		return err
 	}
 	// big-endian is used so that the keys are properly sorted
 	keyBytes := make([]byte, 8)
 	binary.BigEndian.PutUint64(keyBytes, key)
-	return b.Put(keyBytes, encoding.Marshal(pt))
+	return tx.Put(bucketProcessedTransactions, keyBytes, encoding.Marshal(pt))
 }
-func dbGetLastProcessedTransaction(tx *bolt.Tx) (pt modules.ProcessedTransaction, err error) {
-	_, val := tx.Bucket(bucketProcessedTransactions).Cursor().Last()
+func dbGetLastProcessedTransaction(tx walletTx) (pt modules.ProcessedTransaction, err error) {
+	_, val := tx.Last(bucketProcessedTransactions)
 	err = encoding.Unmarshal(val, &pt)
 	return
 }
-func dbDeleteLastProcessedTransaction(tx *bolt.Tx) error {
+func dbDeleteLastProcessedTransaction(tx walletTx) error {
 	// delete the last entry in the bucket. Note that we don't need to
 	// decrement the sequence integer; we only care that the next integer is
 	// larger than the previous one.
-	b := tx.Bucket(bucketProcessedTransactions)
-	key, _ := b.Cursor().Last()
-	return b.Delete(key)
+	key, _ := tx.Last(bucketProcessedTransactions)
+	return tx.Delete(bucketProcessedTransactions, key)
 }
-func dbForEachProcessedTransaction(tx *bolt.Tx, fn func(modules.ProcessedTransaction)) error {
-	return dbForEach(tx.Bucket(bucketProcessedTransactions), func(_ uint64, pt modules.ProcessedTransaction) {
+func dbForEachProcessedTransaction(tx walletTx, fn func(modules.ProcessedTransaction)) error {
+	return dbForEach(tx, bucketProcessedTransactions, func(_ uint64, pt modules.ProcessedTransaction) {
 		fn(pt)
 	})
 }
 
 // dbGetWalletUID returns the UID assigned to the wallet's primary seed.
-func dbGetWalletUID(tx *bolt.Tx) (uid uniqueID) {
-	copy(uid[:], tx.Bucket(bucketWallet).Get(keyUID))
+func dbGetWalletUID(tx walletTx) (uid uniqueID) {
+	copy(uid[:], tx.Get(bucketWallet, keyUID))
 	return
 }
 
 // dbGetPrimarySeedProgress returns the number of keys generated from the
 // primary seed.
-func dbGetPrimarySeedProgress(tx *bolt.Tx) (progress uint64, err error) {
-	err = encoding.Unmarshal(tx.Bucket(bucketWallet).Get(keyPrimarySeedProgress), &progress)
+func dbGetPrimarySeedProgress(tx walletTx) (progress uint64, err error) {
+	err = encoding.Unmarshal(tx.Get(bucketWallet, keyPrimarySeedProgress), &progress)
 	return
 }
 
 // dbPutPrimarySeedProgress sets the primary seed progress counter.
-func dbPutPrimarySeedProgress(tx *bolt.Tx, progress uint64) error {
-	return tx.Bucket(bucketWallet).Put(keyPrimarySeedProgress, encoding.Marshal(progress))
+func dbPutPrimarySeedProgress(tx walletTx, progress uint64) error {
+	return tx.Put(bucketWallet, keyPrimarySeedProgress, encoding.Marshal(progress))
 }
 
 // dbGetConsensusChangeID returns the ID of the last ConsensusChange processed by the wallet.
-func dbGetConsensusChangeID(tx *bolt.Tx) (cc modules.ConsensusChangeID) {
-	copy(cc[:], tx.Bucket(bucketWallet).Get(keyConsensusChange))
+func dbGetConsensusChangeID(tx walletTx) (cc modules.ConsensusChangeID) {
+	copy(cc[:], tx.Get(bucketWallet, keyConsensusChange))
 	return
 }
 
 // dbPutConsensusChangeID stores the ID of the last ConsensusChange processed by the wallet.
-func dbPutConsensusChangeID(tx *bolt.Tx, cc modules.ConsensusChangeID) error {
-	return tx.Bucket(bucketWallet).Put(keyConsensusChange, cc[:])
+func dbPutConsensusChangeID(tx walletTx, cc modules.ConsensusChangeID) error {
+	return tx.Put(bucketWallet, keyConsensusChange, cc[:])
 }
 
 // dbGetConsensusHeight returns the height that the wallet has scanned to.
-func dbGetConsensusHeight(tx *bolt.Tx) (height types.BlockHeight, err error) {
-	err = encoding.Unmarshal(tx.Bucket(bucketWallet).Get(keyConsensusHeight), &height)
+func dbGetConsensusHeight(tx walletTx) (height types.BlockHeight, err error) {
+	err = encoding.Unmarshal(tx.Get(bucketWallet, keyConsensusHeight), &height)
 	return
 }
 
 // dbPutConsensusHeight stores the height that the wallet has scanned to.
-func dbPutConsensusHeight(tx *bolt.Tx, height types.BlockHeight) error {
-	return tx.Bucket(bucketWallet).Put(keyConsensusHeight, encoding.Marshal(height))
+func dbPutConsensusHeight(tx walletTx, height types.BlockHeight) error {
+	return tx.Put(bucketWallet, keyConsensusHeight, encoding.Marshal(height))
+}
+
+// dbGetDelayedOutputsScanned returns whether bucketDelayedSiacoinOutputs holds
+// every delayed output of the wallet. It is false for wallets that processed
+// blocks before the bucket was introduced, until they have been rescanned.
+func dbGetDelayedOutputsScanned(tx walletTx) (scanned bool, err error) {
+	err = encoding.Unmarshal(tx.Get(bucketWallet, keyDelayedOutputsScanned), &scanned)
+	return
+}
+
+// dbPutDelayedOutputsScanned stores whether bucketDelayedSiacoinOutputs holds
+// every delayed output of the wallet.
+func dbPutDelayedOutputsScanned(tx walletTx, scanned bool) error {
+	return tx.Put(bucketWallet, keyDelayedOutputsScanned, encoding.Marshal(scanned))
+}
+
+// dbGetLockTimeout returns the wallet's automatic lock timeout.
+func dbGetLockTimeout(tx walletTx) (timeout time.Duration, err error) {
+	err = encoding.Unmarshal(tx.Get(bucketWallet, keyLockTimeout), &timeout)
+	return
+}
+
+// dbPutLockTimeout stores the wallet's automatic lock timeout.
+func dbPutLockTimeout(tx walletTx, timeout time.Duration) error {
+	return tx.Put(bucketWallet, keyLockTimeout, encoding.Marshal(timeout))
+}
+
+// dbGetApprovalPolicy returns the wallet's approval policy.
+func dbGetApprovalPolicy(tx walletTx) (policy modules.ApprovalPolicy, err error) {
+	err = encoding.Unmarshal(tx.Get(bucketWallet, keyApprovalPolicy), &policy)
+	return
+}
+
+// dbPutApprovalPolicy stores the wallet's approval policy.
+func dbPutApprovalPolicy(tx walletTx, policy modules.ApprovalPolicy) error {
+	return tx.Put(bucketWallet, keyApprovalPolicy, encoding.Marshal(policy))
 }
@@ -8,8 +8,6 @@ import (
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
-
-	"github.com/NebulousLabs/bolt"
 )
 
 // TestDBOpen tests the wallet.openDB method.
@@ -26,14 +24,20 @@ func TestDBOpen(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	w.db.View(func(tx *bolt.Tx) error {
-		for _, b := range dbBuckets {
-			if tx.Bucket(b) == nil {
-				t.Error("bucket", string(b), "does not exist")
-			}
+	tx, err := w.db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[string]bool)
+	for _, name := range tx.BucketNames() {
+		names[string(name)] = true
+	}
+	for _, b := range dbBuckets {
+		if !names[string(b)] {
+			t.Error("bucket", string(b), "does not exist")
 		}
-		return nil
-	})
+	}
+	tx.Rollback()
 	w.db.Close()
 }
 
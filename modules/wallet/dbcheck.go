@@ -0,0 +1,459 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+const (
+	// compactFile is the name of the file that the wallet database is
+	// written to while it is being compacted.
+	compactFile = dbFile + "_compact"
+
+	// repairCompact, repairRestart, and repairRestore are the repairs
+	// suggested for problems found in the wallet database. Stale data is
+	// removed by compaction, missing buckets and settings are recreated when
+	// siad starts, and damaged or inconsistent wallet data can be rebuilt by
+	// restoring the wallet from its seed into an empty wallet directory.
+	repairCompact = "compact the wallet database to remove it"
+	repairRestart = "restart siad to recreate it"
+	repairRestore = "restore the wallet from its seed in an empty wallet directory to rebuild the database; back up the wallet directory first"
+
+	// maxDBProblems is the maximum number of problems reported by CheckDB,
+	// so that a badly damaged database does not produce an enormous report.
+	maxDBProblems = 1000
+)
+
+var (
+	// errEphemeralDB is returned when compacting or backing up a wallet
+	// database that is never persisted.
+	errEphemeralDB = errors.New("the wallet database is not persisted")
+
+	// metadataBucket is the bucket that persist.OpenDatabase stores the
+	// database metadata in.
+	metadataBucket = []byte("Metadata")
+)
+
+// dbChecker accumulates the results of checking the wallet database.
+type dbChecker struct {
+	report modules.WalletDBReport
+}
+
+// problem records a problem with the entry at key in bucket. A nil key
+// records a problem that is not with a single entry.
+func (c *dbChecker) problem(bucket []byte, key []byte, repair string, format string, args ...interface{}) {
+	if len(c.report.Problems) >= maxDBProblems {
+		return
+	}
+	c.report.Problems = append(c.report.Problems, modules.WalletDBProblem{
+		Bucket:      string(bucket),
+		Key:         hex.EncodeToString(key),
+		Description: fmt.Sprintf(format, args...),
+		Repair:      repair,
+	})
+}
+
+// decode unmarshals the key and value of a bucket entry, recording a problem
+// if either is corrupt. It returns false if the entry could not be decoded.
+func (c *dbChecker) decode(bucket, keyBytes, valBytes []byte, key, val interface{}) bool {
+	if key != nil {
+		if err := encoding.Unmarshal(keyBytes, key); err != nil {
+			c.problem(bucket, keyBytes, repairRestore, "could not decode key: %v", err)
+			return false
+		}
+	}
+	if err := encoding.Unmarshal(valBytes, val); err != nil {
+		c.problem(bucket, keyBytes, repairRestore, "could not decode value: %v", err)
+		return false
+	}
+	return true
+}
+
+// staleSpentOutput returns true if the record that an output was spent at
+// spendHeight no longer affects the wallet. Spent outputs can be respent once
+// RespendTimeout blocks have passed, so records older than that are only kept
+// for outputs still in the wallet's output set.
+func staleSpentOutput(tx walletTx, id types.OutputID, spendHeight, height types.BlockHeight) bool {
+	if spendHeight+RespendTimeout > height {
+		return false
+	}
+	key := encoding.Marshal(id)
+	return tx.Get(bucketSiacoinOutputs, key) == nil && tx.Get(bucketSiafundOutputs, key) == nil
+}
+
+// checkDB checks the integrity of the wallet database.
+func (w *Wallet) checkDB(tx walletTx) modules.WalletDBReport {
+	c := new(dbChecker)
+	c.report.Size = uint64(tx.Size())
+
+	// Check the pages of the database itself.
+	for _, err := range tx.Check() {
+		c.problem(nil, nil, repairRestore, "database file is damaged: %v", err)
+	}
+
+	// Check that the wallet's buckets exist, and that there are no others.
+	known := map[string]bool{string(metadataBucket): true}
+	names := tx.BucketNames()
+	present := make(map[string]bool)
+	for _, name := range names {
+		present[string(name)] = true
+	}
+	for _, b := range dbBuckets {
+		known[string(b)] = true
+		if !present[string(b)] {
+			c.problem(b, nil, repairRestart, "bucket is missing")
+		}
+	}
+	for _, name := range names {
+		entries := bucketEntries(tx, name)
+		if !known[string(name)] {
+			c.problem(name, nil, repairCompact, "bucket is not used by the wallet")
+			c.report.StaleEntries += entries
+		}
+		c.report.Buckets = append(c.report.Buckets, modules.WalletDBBucket{
+			Name:    string(name),
+			Entries: entries,
+		})
+	}
+	for _, b := range dbBuckets {
+		if !present[string(b)] {
+			return c.report
+		}
+	}
+
+	// Check the wallet's settings.
+	if len(tx.Get(bucketWallet, keyUID)) != len(uniqueID{}) {
+		c.problem(bucketWallet, keyUID, repairRestore, "wallet UID is missing or malformed")
+	}
+	var height types.BlockHeight
+	var sfs []seedFile
+	var skfs []spendableKeyFile
+	var progress uint64
+	var primary seedFile
+	var policy modules.ApprovalPolicy
+	settings := []struct {
+		key      []byte
+		val      interface{}
+		required bool
+	}{
+		{keyConsensusHeight, &height, true},
+		{keyAuxiliarySeedFiles, &sfs, true},
+		{keySpendableKeyFiles, &skfs, true},
+		{keyLockTimeout, new(time.Duration), true},
+		{keyApprovalPolicy, &policy, true},
+		{keyDelayedOutputsScanned, new(bool), true},
+		{keyPrimarySeedFile, &primary, false},
+		{keyPrimarySeedProgress, &progress, w.encrypted},
+	}
+	for _, s := range settings {
+		val := tx.Get(bucketWallet, s.key)
+		if val == nil {
+			if s.required {
+				c.problem(bucketWallet, s.key, repairRestart, "setting %v is missing", string(s.key))
+			}
+			continue
+		}
+		c.decode(bucketWallet, s.key, val, nil, s.val)
+	}
+
+	// The outputs and transactions of the wallet can only be compared to
+	// its keys while the wallet is unlocked.
+	c.report.KeysChecked = w.unlocked
+	owned := func(uh types.UnlockHash) bool {
+		_, exists := w.keys[uh]
+		return !w.unlocked || exists
+	}
+
+	// Check that the wallet's outputs belong to the wallet and match the
+	// output history. File contract and siafund claim payouts are not
+	// created by transactions, so siacoin outputs may be missing from the
+	// history.
+	historic := func(b, key []byte, id types.OutputID, value types.Currency, required bool) {
+		var historicValue types.Currency
+		val := tx.Get(bucketHistoricOutputs, encoding.Marshal(id))
+		if val == nil {
+			if required {
+				c.problem(b, key, repairRestore, "output is missing from the output history")
+			}
+		} else if encoding.Unmarshal(val, &historicValue) == nil && historicValue.Cmp(value) != 0 {
+			c.problem(b, key, repairRestore, "output has a value of %v, but %v in the output history", value, historicValue)
+		}
+	}
+	tx.ForEach(bucketSiacoinOutputs, func(k, v []byte) error {
+		var id types.SiacoinOutputID
+		var sco types.SiacoinOutput
+		if !c.decode(bucketSiacoinOutputs, k, v, &id, &sco) {
+			return nil
+		}
+		if !owned(sco.UnlockHash) {
+			c.problem(bucketSiacoinOutputs, k, repairRestore, "output is sent to %v, which is not a wallet address", sco.UnlockHash)
+		}
+		historic(bucketSiacoinOutputs, k, types.OutputID(id), sco.Value, false)
+		return nil
+	})
+	tx.ForEach(bucketSiafundOutputs, func(k, v []byte) error {
+		var id types.SiafundOutputID
+		var sfo types.SiafundOutput
+		if !c.decode(bucketSiafundOutputs, k, v, &id, &sfo) {
+			return nil
+		}
+		if !owned(sfo.UnlockHash) {
+			c.problem(bucketSiafundOutputs, k, repairRestore, "output is sent to %v, which is not a wallet address", sfo.UnlockHash)
+		}
+		historic(bucketSiafundOutputs, k, types.OutputID(id), sfo.Value, true)
+		if tx.Get(bucketHistoricClaimStarts, k) == nil {
+			c.problem(bucketSiafundOutputs, k, repairRestore, "output has no recorded claim start")
+		}
+		return nil
+	})
+	tx.ForEach(bucketDelayedSiacoinOutputs, func(k, v []byte) error {
+		var id types.SiacoinOutputID
+		var dso delayedSiacoinOutput
+		if !c.decode(bucketDelayedSiacoinOutputs, k, v, &id, &dso) {
+			return nil
+		}
+		if !owned(dso.SiacoinOutput.UnlockHash) {
+			c.problem(bucketDelayedSiacoinOutputs, k, repairRestore, "output is sent to %v, which is not a wallet address", dso.SiacoinOutput.UnlockHash)
+		}
+		if dso.MaturityHeight < height {
+			c.problem(bucketDelayedSiacoinOutputs, k, repairRestore, "output matured at height %v but was not moved to the output set", dso.MaturityHeight)
+		}
+		return nil
+	})
+	tx.ForEach(bucketSpentOutputs, func(k, v []byte) error {
+		var id types.OutputID
+		var spendHeight types.BlockHeight
+		if c.decode(bucketSpentOutputs, k, v, &id, &spendHeight) && staleSpentOutput(tx, id, spendHeight, height) {
+			c.report.StaleEntries++
+		}
+		return nil
+	})
+	tx.ForEach(bucketHistoricOutputs, func(k, v []byte) error {
+		c.decode(bucketHistoricOutputs, k, v, new(types.OutputID), new(types.Currency))
+		return nil
+	})
+	tx.ForEach(bucketHistoricClaimStarts, func(k, v []byte) error {
+		c.decode(bucketHistoricClaimStarts, k, v, new(types.SiafundOutputID), new(types.Currency))
+		return nil
+	})
+
+	// Check that the processed transactions are in chronological order, are
+	// not ahead of the wallet, and that the wallet addresses they involve
+	// belong to the wallet.
+	var lastHeight types.BlockHeight
+	tx.ForEach(bucketProcessedTransactions, func(k, v []byte) error {
+		var pt modules.ProcessedTransaction
+		if !c.decode(bucketProcessedTransactions, k, v, nil, &pt) {
+			return nil
+		}
+		if pt.ConfirmationHeight < lastHeight {
+			c.problem(bucketProcessedTransactions, k, repairRestore, "transaction %v confirmed at height %v is recorded after a transaction confirmed at height %v", pt.TransactionID, pt.ConfirmationHeight, lastHeight)
+		}
+		if pt.ConfirmationHeight > height {
+			c.problem(bucketProcessedTransactions, k, repairRestore, "transaction %v is confirmed at height %v, above the wallet height of %v", pt.TransactionID, pt.ConfirmationHeight, height)
+		}
+		lastHeight = pt.ConfirmationHeight
+		for _, input := range pt.Inputs {
+			if input.WalletAddress && !owned(input.RelatedAddress) {
+				c.problem(bucketProcessedTransactions, k, repairRestore, "transaction %v spends from %v, which is not a wallet address", pt.TransactionID, input.RelatedAddress)
+			}
+		}
+		for _, output := range pt.Outputs {
+			if output.WalletAddress && !owned(output.RelatedAddress) {
+				c.problem(bucketProcessedTransactions, k, repairRestore, "transaction %v pays %v, which is not a wallet address", pt.TransactionID, output.RelatedAddress)
+			}
+		}
+		return nil
+	})
+	return c.report
+}
+
+// compactDB copies the wallet's buckets from a bolt transaction into the
+// empty bolt database dst, leaving out unknown buckets and stale spent output
+// records. The number of entries left out is returned.
+func compactDB(btx *boltTx, dst *bolt.DB) (removed int, err error) {
+	height, err := dbGetConsensusHeight(btx)
+	if err != nil {
+		return 0, err
+	}
+	for _, name := range btx.BucketNames() {
+		if string(name) != string(metadataBucket) {
+			removed += bucketEntries(btx, name)
+		}
+	}
+
+	err = dst.Update(func(dstTx *bolt.Tx) error {
+		for _, name := range dbBuckets {
+			src := btx.tx.Bucket(name)
+			b, err := dstTx.CreateBucketIfNotExists(name)
+			if err != nil {
+				return err
+			}
+			if err := b.SetSequence(src.Sequence()); err != nil {
+				return err
+			}
+			// Entries are inserted in key order, so pages can be filled
+			// completely.
+			b.FillPercent = 1
+			err = src.ForEach(func(k, v []byte) error {
+				if string(name) == string(bucketSpentOutputs) {
+					var id types.OutputID
+					var spendHeight types.BlockHeight
+					if encoding.Unmarshal(k, &id) == nil && encoding.Unmarshal(v, &spendHeight) == nil && staleSpentOutput(btx, id, spendHeight, height) {
+						return nil
+					}
+				}
+				removed--
+				return b.Put(k, v)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// pruneDB deletes the entries of buckets that are not used by the wallet and
+// the spent output records that no longer affect the wallet, returning the
+// number of entries deleted.
+func pruneDB(tx walletTx) (removed int, err error) {
+	height, err := dbGetConsensusHeight(tx)
+	if err != nil {
+		return 0, err
+	}
+	known := make(map[string]bool)
+	for _, b := range dbBuckets {
+		known[string(b)] = true
+	}
+	for _, name := range tx.BucketNames() {
+		if known[string(name)] && string(name) != string(bucketSpentOutputs) {
+			continue
+		}
+		var stale [][]byte
+		err := tx.ForEach(name, func(k, v []byte) error {
+			if known[string(name)] {
+				var id types.OutputID
+				var spendHeight types.BlockHeight
+				if encoding.Unmarshal(k, &id) != nil || encoding.Unmarshal(v, &spendHeight) != nil || !staleSpentOutput(tx, id, spendHeight, height) {
+					return nil
+				}
+			}
+			stale = append(stale, k)
+			return nil
+		})
+		if err != nil {
+			return removed, err
+		}
+		for _, k := range stale {
+			if err := tx.Delete(name, k); err != nil {
+				return removed, err
+			}
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// CheckDB checks the integrity of the wallet database, reporting corrupt
+// entries, inconsistencies between the wallet's keys, processed transactions,
+// and output set, and how they can be repaired. Outputs and transactions are
+// only checked against the wallet's keys while the wallet is unlocked.
+func (w *Wallet) CheckDB() (modules.WalletDBReport, error) {
+	if err := w.tg.Add(); err != nil {
+		return modules.WalletDBReport{}, err
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.checkDB(w.dbTx), nil
+}
+
+// CompactDB rewrites the wallet database without free pages, unknown buckets,
+// or spent output records that no longer affect the wallet. Wallet databases
+// from old versions can accumulate all three. The compacted database replaces
+// the original only once it has been written in full.
+func (w *Wallet) CompactDB() (modules.WalletDBCompaction, error) {
+	if err := w.tg.Add(); err != nil {
+		return modules.WalletDBCompaction{}, err
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.backend == BackendSQLite {
+		return w.compactSQLite()
+	}
+	dbFilename := filepath.Join(w.persistDir, dbFile)
+	if w.db.Path() != dbFilename {
+		return modules.WalletDBCompaction{}, errEphemeralDB
+	}
+
+	// Commit the outstanding changes and copy the database into a new file.
+	w.syncDB()
+	compactFilename := filepath.Join(w.persistDir, compactFile)
+	os.Remove(compactFilename)
+	dst, err := persist.OpenDatabase(dbMetadata, compactFilename)
+	if err != nil {
+		return modules.WalletDBCompaction{}, err
+	}
+	var compaction modules.WalletDBCompaction
+	compaction.SizeBefore = uint64(w.dbTx.Size())
+	compaction.EntriesRemoved, err = compactDB(w.dbTx.(*boltTx), dst.DB)
+	if err == nil {
+		err = dst.Sync()
+	}
+	if err == nil {
+		err = dst.View(func(tx *bolt.Tx) error {
+			compaction.SizeAfter = uint64(tx.Size())
+			return nil
+		})
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(compactFilename)
+		return modules.WalletDBCompaction{}, err
+	}
+
+	// Replace the database with the compacted copy. Open files cannot be
+	// replaced on some platforms, so the database is closed first.
+	if err := w.dbTx.Rollback(); err != nil {
+		return modules.WalletDBCompaction{}, err
+	}
+	if err := w.db.Close(); err != nil {
+		return modules.WalletDBCompaction{}, err
+	}
+	renameErr := os.Rename(compactFilename, dbFilename)
+	if renameErr != nil {
+		os.Remove(compactFilename)
+	}
+	w.db, err = openBoltStore(dbFilename)
+	if err != nil {
+		w.log.Severe("ERROR: failed to reopen the wallet database after compaction:", err)
+		return modules.WalletDBCompaction{}, err
+	}
+	w.dbTx, err = w.db.Begin()
+	if err != nil {
+		w.log.Severe("ERROR: failed to start database update:", err)
+		return modules.WalletDBCompaction{}, err
+	}
+	if renameErr != nil {
+		return modules.WalletDBCompaction{}, renameErr
+	}
+	w.log.Printf("INFO: compacted the wallet database from %v to %v bytes, removing %v stale entries", compaction.SizeBefore, compaction.SizeAfter, compaction.EntriesRemoved)
+	return compaction, nil
+}
@@ -0,0 +1,150 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestCheckDB checks that CheckDB reports stale and corrupt entries in the
+// wallet database, and that CompactDB removes stale entries without
+// disturbing the wallet.
+func TestCheckDB(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// A freshly mined wallet should have no problems.
+	report, err := wt.wallet.CheckDB()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(report.Problems) != 0 {
+		t.Fatal("expected no problems, got", report.Problems)
+	} else if !report.KeysChecked {
+		t.Fatal("keys should be checked while the wallet is unlocked")
+	}
+
+	// Mine enough blocks for spent output records from the start of the
+	// blockchain to expire.
+	for i := 0; i < RespendTimeout; i++ {
+		if _, err := wt.miner.AddBlock(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	balance, _, _ := wt.wallet.ConfirmedBalance()
+
+	// Add an unknown bucket, a stale spent output record, a corrupt
+	// historic output, and an output that does not belong to the wallet.
+	wt.wallet.mu.Lock()
+	tx := wt.wallet.dbTx
+	b, err := tx.(*boltTx).tx.CreateBucket([]byte("bucketOldCruft"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Put([]byte("foo"), []byte("bar"))
+	dbPutSpentOutput(tx, types.OutputID{1}, 0)
+	tx.Put(bucketHistoricOutputs, encoding.Marshal(types.OutputID{2}), []byte{1})
+	dbPutSiacoinOutput(tx, types.SiacoinOutputID{3}, types.SiacoinOutput{UnlockHash: types.UnlockHash{3}})
+	wt.wallet.mu.Unlock()
+
+	report, err = wt.wallet.CheckDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.StaleEntries != 2 {
+		t.Fatal("expected 2 stale entries, got", report.StaleEntries)
+	}
+	problems := make(map[string]int)
+	for _, p := range report.Problems {
+		problems[p.Bucket]++
+		if p.Repair == "" {
+			t.Error("problem has no repair suggestion:", p)
+		}
+	}
+	if len(report.Problems) != 3 || problems["bucketOldCruft"] != 1 || problems[string(bucketHistoricOutputs)] != 1 || problems[string(bucketSiacoinOutputs)] != 1 {
+		t.Fatal("unexpected problems:", report.Problems)
+	}
+
+	// Compaction should remove the stale entries, but keep the rest.
+	compaction, err := wt.wallet.CompactDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if compaction.EntriesRemoved != 2 {
+		t.Fatal("expected 2 entries to be removed, got", compaction.EntriesRemoved)
+	} else if compaction.SizeAfter > compaction.SizeBefore {
+		t.Fatalf("database grew from %v to %v bytes", compaction.SizeBefore, compaction.SizeAfter)
+	}
+	report, err = wt.wallet.CheckDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.StaleEntries != 0 || len(report.Problems) != 2 {
+		t.Fatal("unexpected report after compaction:", report)
+	}
+
+	// Remove the problems and check that the wallet still works, including
+	// after a restart.
+	wt.wallet.mu.Lock()
+	dbDeleteSiacoinOutput(wt.wallet.dbTx, types.SiacoinOutputID{3})
+	dbPutHistoricOutput(wt.wallet.dbTx, types.OutputID{2}, types.ZeroCurrency)
+	wt.wallet.mu.Unlock()
+	if newBalance, _, _ := wt.wallet.ConfirmedBalance(); !newBalance.Equals(balance) {
+		t.Fatalf("balance changed from %v to %v", balance, newBalance)
+	}
+	if _, err := wt.wallet.SendSiacoins(types.SiacoinPrecision, types.UnlockHash{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.wallet.Close(); err != nil {
+		t.Fatal(err)
+	}
+	w, err := New(wt.cs, wt.tpool, wt.wallet.persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt.wallet = w
+	if err := w.Unlock(wt.walletMasterKey); err != nil {
+		t.Fatal(err)
+	}
+	report, err = w.CheckDB()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(report.Problems) != 0 {
+		t.Fatal("expected no problems, got", report.Problems)
+	}
+}
+
+// TestCompactEphemeralDB checks that the database of an ephemeral wallet
+// cannot be compacted.
+func TestCompactEphemeralDB(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	seed, _, err := wt.wallet.PrimarySeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewEphemeral(wt.cs, wt.tpool, seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if _, err := w.CompactDB(); err != errEphemeralDB {
+		t.Fatal("expected errEphemeralDB, got", err)
+	}
+}
@@ -44,9 +44,7 @@ func TestDefragWallet(t *testing.T) {
 
 	// defrag should keep the outputs below the threshold
 	wt.wallet.mu.Lock()
-	// force a sync because bucket stats may not be reliable until commit
-	wt.wallet.syncDB()
-	siacoinOutputs := wt.wallet.dbTx.Bucket(bucketSiacoinOutputs).Stats().KeyN
+	siacoinOutputs := bucketEntries(wt.wallet.dbTx, bucketSiacoinOutputs)
 	wt.wallet.mu.Unlock()
 	if siacoinOutputs > defragThreshold {
 		t.Fatalf("defrag should result in fewer than defragThreshold outputs, got %v wanted %v\n", siacoinOutputs, defragThreshold)
@@ -108,9 +106,7 @@ func TestDefragWalletDust(t *testing.T) {
 	time.Sleep(time.Second)
 
 	wt.wallet.mu.Lock()
-	// force a sync because bucket stats may not be reliable until commit
-	wt.wallet.syncDB()
-	siacoinOutputs := wt.wallet.dbTx.Bucket(bucketSiacoinOutputs).Stats().KeyN
+	siacoinOutputs := bucketEntries(wt.wallet.dbTx, bucketSiacoinOutputs)
 	wt.wallet.mu.Unlock()
 	if siacoinOutputs < defragThreshold {
 		t.Fatal("defrag consolidated dust outputs")
@@ -10,7 +10,6 @@ import (
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
-	"github.com/NebulousLabs/bolt"
 	"github.com/NebulousLabs/fastrand"
 )
 
@@ -46,17 +45,16 @@ func verifyEncryption(key crypto.TwofishKey, encrypted crypto.Ciphertext) error
 }
 
 // checkMasterKey verifies that the masterKey is the key used to encrypt the wallet.
-func checkMasterKey(tx *bolt.Tx, masterKey crypto.TwofishKey) error {
+func checkMasterKey(tx walletTx, masterKey crypto.TwofishKey) error {
 	uk := uidEncryptionKey(masterKey, dbGetWalletUID(tx))
-	encryptedVerification := tx.Bucket(bucketWallet).Get(keyEncryptionVerification)
+	encryptedVerification := tx.Get(bucketWallet, keyEncryptionVerification)
 	return verifyEncryption(uk, encryptedVerification)
 }
 
 // initEncryption initializes and encrypts the primary SeedFile.
 func (w *Wallet) initEncryption(masterKey crypto.TwofishKey, seed modules.Seed) (modules.Seed, error) {
-	wb := w.dbTx.Bucket(bucketWallet)
 	// Check if the wallet encryption key has already been set.
-	if wb.Get(keyEncryptionVerification) != nil {
+	if w.dbTx.Get(bucketWallet, keyEncryptionVerification) != nil {
 		return modules.Seed{}, errReencrypt
 	}
 
@@ -64,11 +62,11 @@ func (w *Wallet) initEncryption(masterKey crypto.TwofishKey, seed modules.Seed)
 	sf := createSeedFile(masterKey, seed)
 
 	// set this as the primary seedFile
-	err := wb.Put(keyPrimarySeedFile, encoding.Marshal(sf))
+	err := w.dbTx.Put(bucketWallet, keyPrimarySeedFile, encoding.Marshal(sf))
 	if err != nil {
 		return modules.Seed{}, err
 	}
-	err = wb.Put(keyPrimarySeedProgress, encoding.Marshal(uint64(0)))
+	err = w.dbTx.Put(bucketWallet, keyPrimarySeedProgress, encoding.Marshal(uint64(0)))
 	if err != nil {
 		return modules.Seed{}, err
 	}
@@ -76,7 +74,7 @@ func (w *Wallet) initEncryption(masterKey crypto.TwofishKey, seed modules.Seed)
 	// Establish the encryption verification using the masterKey. After this
 	// point, the wallet is encrypted.
 	uk := uidEncryptionKey(masterKey, dbGetWalletUID(w.dbTx))
-	err = wb.Put(keyEncryptionVerification, uk.EncryptBytes(verificationPlaintext))
+	err = w.dbTx.Put(bucketWallet, keyEncryptionVerification, uk.EncryptBytes(verificationPlaintext))
 	if err != nil {
 		return modules.Seed{}, err
 	}
@@ -121,24 +119,23 @@ func (w *Wallet) managedUnlock(masterKey crypto.TwofishKey) error {
 		lastChange = dbGetConsensusChangeID(w.dbTx)
 
 		// primarySeedFile + primarySeedProgress
-		wb := w.dbTx.Bucket(bucketWallet)
-		err = encoding.Unmarshal(wb.Get(keyPrimarySeedFile), &primarySeedFile)
+		err = encoding.Unmarshal(w.dbTx.Get(bucketWallet, keyPrimarySeedFile), &primarySeedFile)
 		if err != nil {
 			return err
 		}
-		err = encoding.Unmarshal(wb.Get(keyPrimarySeedProgress), &primarySeedProgress)
+		err = encoding.Unmarshal(w.dbTx.Get(bucketWallet, keyPrimarySeedProgress), &primarySeedProgress)
 		if err != nil {
 			return err
 		}
 
 		// auxiliarySeedFiles
-		err = encoding.Unmarshal(wb.Get(keyAuxiliarySeedFiles), &auxiliarySeedFiles)
+		err = encoding.Unmarshal(w.dbTx.Get(bucketWallet, keyAuxiliarySeedFiles), &auxiliarySeedFiles)
 		if err != nil {
 			return err
 		}
 
 		// unseededKeyFiles
-		err = encoding.Unmarshal(wb.Get(keySpendableKeyFiles), &unseededKeyFiles)
+		err = encoding.Unmarshal(w.dbTx.Get(bucketWallet, keySpendableKeyFiles), &unseededKeyFiles)
 		if err != nil {
 			return err
 		}
@@ -199,6 +196,12 @@ func (w *Wallet) managedUnlock(masterKey crypto.TwofishKey) error {
 		go w.rescanMessage(done)
 		defer close(done)
 
+		// Wallets that processed blocks before delayed outputs were tracked
+		// need to find the outputs that are already delayed.
+		if err := w.managedScanDelayedOutputs(); err != nil {
+			return fmt.Errorf("failed to scan for delayed outputs: %v", err)
+		}
+
 		err = w.cs.ConsensusSetSubscribe(w, lastChange)
 		if err == modules.ErrInvalidConsensusChangeID {
 			// something went wrong; resubscribe from the beginning
@@ -221,6 +224,9 @@ func (w *Wallet) managedUnlock(masterKey crypto.TwofishKey) error {
 	w.mu.Lock()
 	w.unlocked = true
 	w.subscribed = true
+	if w.lockTimeout > 0 {
+		w.setUnlockedUntil(time.Now().Add(w.lockTimeout))
+	}
 	w.mu.Unlock()
 	return nil
 }
@@ -321,7 +327,13 @@ func (w *Wallet) InitFromSeed(masterKey crypto.TwofishKey, seed modules.Seed) er
 	if !w.cs.Synced() {
 		return errors.New("cannot init from seed until blockchain is synced")
 	}
+	return w.managedInitFromSeed(masterKey, seed)
+}
 
+// managedInitFromSeed encrypts the wallet using the provided seed and sets the
+// primary seed progress by scanning the current blockchain. Unlike
+// InitFromSeed, it does not require the consensus set to be synced.
+func (w *Wallet) managedInitFromSeed(masterKey crypto.TwofishKey, seed modules.Seed) error {
 	// If masterKey is blank, use the hash of the seed.
 	if masterKey == (crypto.TwofishKey{}) {
 		masterKey = crypto.TwofishKey(crypto.HashObject(seed))
@@ -348,6 +360,88 @@ func (w *Wallet) InitFromSeed(masterKey crypto.TwofishKey, seed modules.Seed) er
 	return dbPutPrimarySeedProgress(w.dbTx, uint64(progress))
 }
 
+// ChangeKey re-encrypts the wallet's seeds and keys under a new master key.
+// If newKey is blank, the hash of the primary seed is used. The wallet's seeds
+// and addresses are not affected.
+func (w *Wallet) ChangeKey(masterKey, newKey crypto.TwofishKey) error {
+	if err := w.tg.Add(); err != nil {
+		return err
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.encrypted {
+		return errUnencryptedWallet
+	}
+	if err := checkMasterKey(w.dbTx, masterKey); err != nil {
+		return err
+	}
+
+	// Decrypt all of the seeds and keys using the old master key.
+	var primarySeedFile seedFile
+	var auxiliarySeedFiles []seedFile
+	var unseededKeyFiles []spendableKeyFile
+	if err := encoding.Unmarshal(w.dbTx.Get(bucketWallet, keyPrimarySeedFile), &primarySeedFile); err != nil {
+		return err
+	}
+	if err := encoding.Unmarshal(w.dbTx.Get(bucketWallet, keyAuxiliarySeedFiles), &auxiliarySeedFiles); err != nil {
+		return err
+	}
+	if err := encoding.Unmarshal(w.dbTx.Get(bucketWallet, keySpendableKeyFiles), &unseededKeyFiles); err != nil {
+		return err
+	}
+	primarySeed, err := decryptSeedFile(masterKey, primarySeedFile)
+	if err != nil {
+		return err
+	}
+	auxiliarySeeds := make([]modules.Seed, len(auxiliarySeedFiles))
+	for i, sf := range auxiliarySeedFiles {
+		if auxiliarySeeds[i], err = decryptSeedFile(masterKey, sf); err != nil {
+			return err
+		}
+	}
+	unseededKeys := make([]spendableKey, len(unseededKeyFiles))
+	for i, skf := range unseededKeyFiles {
+		if unseededKeys[i], err = decryptSpendableKeyFile(masterKey, skf); err != nil {
+			return err
+		}
+	}
+
+	// If newKey is blank, use the hash of the primary seed.
+	if newKey == (crypto.TwofishKey{}) {
+		newKey = crypto.TwofishKey(crypto.HashObject(primarySeed))
+	}
+
+	// Re-encrypt everything under the new master key.
+	for i, seed := range auxiliarySeeds {
+		auxiliarySeedFiles[i] = createSeedFile(newKey, seed)
+	}
+	for i, sk := range unseededKeys {
+		unseededKeyFiles[i] = createSpendableKeyFile(newKey, sk)
+	}
+	uk := uidEncryptionKey(newKey, dbGetWalletUID(w.dbTx))
+	updates := []struct {
+		key, val []byte
+	}{
+		{keyPrimarySeedFile, encoding.Marshal(createSeedFile(newKey, primarySeed))},
+		{keyAuxiliarySeedFiles, encoding.Marshal(auxiliarySeedFiles)},
+		{keySpendableKeyFiles, encoding.Marshal(unseededKeyFiles)},
+		{keyEncryptionVerification, uk.EncryptBytes(verificationPlaintext)},
+	}
+	for _, u := range updates {
+		if err := w.dbTx.Put(bucketWallet, u.key, u.val); err != nil {
+			return err
+		}
+	}
+
+	// Flush the changes immediately so that the old key does not remain valid
+	// on disk.
+	w.syncDB()
+	w.log.Println("INFO: Wallet encryption key changed.")
+	return nil
+}
+
 // Unlocked indicates whether the wallet is locked or unlocked.
 func (w *Wallet) Unlocked() bool {
 	w.mu.RLock()
@@ -364,13 +458,19 @@ func (w *Wallet) Lock() error {
 		return modules.ErrLockedWallet
 	}
 	w.log.Println("INFO: Locking wallet.")
+	w.lock()
+	return nil
+}
 
+// lock erases all keys from memory and cancels any pending automatic lock.
+// It must be called with a write-lock.
+func (w *Wallet) lock() {
 	// Wipe all of the seeds and secret keys. They will be replaced upon
 	// calling 'Unlock' again. Note that since the public keys are not wiped,
 	// we can continue processing blocks.
 	w.wipeSecrets()
 	w.unlocked = false
-	return nil
+	w.setUnlockedUntil(time.Time{})
 }
 
 // Unlock will decrypt the wallet seed and load all of the addresses into
@@ -284,3 +284,81 @@ func TestInitFromSeed(t *testing.T) {
 		t.Fatalf("wallet should have correct balance after loading seed: wanted %v, got %v", origBal, newBal)
 	}
 }
+
+// TestChangeKey checks that the wallet can be re-encrypted under a new key
+// without losing any of its seeds or keys.
+func TestChangeKey(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// Give the wallet an auxiliary seed and an unseeded key.
+	var auxSeed modules.Seed
+	fastrand.Read(auxSeed[:])
+	if err := wt.wallet.LoadSeed(wt.walletMasterKey, auxSeed); err != nil {
+		t.Fatal(err)
+	}
+	var keySeed modules.Seed
+	fastrand.Read(keySeed[:])
+	sk := generateSpendableKey(keySeed, 0)
+	wt.wallet.mu.Lock()
+	err = wt.wallet.loadSpendableKey(wt.walletMasterKey, sk)
+	wt.wallet.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	primarySeed, _, err := wt.wallet.PrimarySeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	balance, _, _ := wt.wallet.ConfirmedBalance()
+
+	// Changing the key requires the current key.
+	newKey := crypto.GenerateTwofishKey()
+	if err := wt.wallet.ChangeKey(newKey, newKey); err != modules.ErrBadEncryptionKey {
+		t.Fatal("expected ErrBadEncryptionKey, got", err)
+	}
+	if err := wt.wallet.ChangeKey(wt.walletMasterKey, newKey); err != nil {
+		t.Fatal(err)
+	}
+
+	// The old key should no longer unlock the wallet, but the new one should,
+	// and all of the seeds and keys should still be present.
+	if err := wt.wallet.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.wallet.Unlock(wt.walletMasterKey); err != modules.ErrBadEncryptionKey {
+		t.Fatal("expected ErrBadEncryptionKey, got", err)
+	}
+	if err := wt.wallet.Unlock(newKey); err != nil {
+		t.Fatal(err)
+	}
+	if seed, _, _ := wt.wallet.PrimarySeed(); seed != primarySeed {
+		t.Fatal("primary seed changed")
+	}
+	if seeds, err := wt.wallet.AllSeeds(); err != nil || len(seeds) != 2 || seeds[1] != auxSeed {
+		t.Fatal("auxiliary seed was not preserved:", err)
+	}
+	if _, ok := wt.wallet.keys[sk.UnlockConditions.UnlockHash()]; !ok {
+		t.Fatal("unseeded key was not preserved")
+	}
+	if balance2, _, _ := wt.wallet.ConfirmedBalance(); !balance2.Equals(balance) {
+		t.Fatal("balance changed after changing the key")
+	}
+
+	// A blank key should make the hash of the primary seed the key.
+	if err := wt.wallet.ChangeKey(newKey, crypto.TwofishKey{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.wallet.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.wallet.Unlock(crypto.TwofishKey(crypto.HashObject(primarySeed))); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,231 @@
+package wallet
+
+import (
+	"bytes"
+	"io"
+	"sort"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// memStore is a walletStore that only lives in memory. It backs ephemeral
+// wallets, which must not leave any of their state on disk. Like boltStore,
+// it does not index the wallet's history.
+type memStore struct {
+	buckets map[string]*memBucket
+}
+
+// memBucket is a bucket of a memStore.
+type memBucket struct {
+	entries  map[string][]byte
+	sequence uint64
+}
+
+// memTx is a walletTx on a memStore. Changes are applied to the store
+// directly, and undone on Rollback.
+type memTx struct {
+	store *memStore
+	undo  []func()
+}
+
+// newMemStore creates an empty memStore holding the wallet's buckets.
+func newMemStore() *memStore {
+	s := &memStore{
+		buckets: make(map[string]*memBucket),
+	}
+	for _, b := range dbBuckets {
+		s.buckets[string(b)] = &memBucket{
+			entries: make(map[string][]byte),
+		}
+	}
+	return s
+}
+
+// Begin implements walletStore.
+func (s *memStore) Begin() (walletTx, error) {
+	return &memTx{store: s}, nil
+}
+
+// Close implements walletStore. The contents of the store are dropped.
+func (s *memStore) Close() error {
+	s.buckets = nil
+	return nil
+}
+
+// Path implements walletStore. A memStore has no file.
+func (s *memStore) Path() string {
+	return ""
+}
+
+// sortedKeys returns the keys of bucket in order.
+func (mtx *memTx) sortedKeys(bucket []byte) []string {
+	b := mtx.store.buckets[string(bucket)]
+	keys := make([]string, 0, len(b.entries))
+	for key := range b.entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Get implements walletTx.
+func (mtx *memTx) Get(bucket, key []byte) []byte {
+	return mtx.store.buckets[string(bucket)].entries[string(key)]
+}
+
+// Put implements walletTx.
+func (mtx *memTx) Put(bucket, key, val []byte) error {
+	b := mtx.store.buckets[string(bucket)]
+	k := string(key)
+	old, exists := b.entries[k]
+	mtx.undo = append(mtx.undo, func() {
+		if exists {
+			b.entries[k] = old
+		} else {
+			delete(b.entries, k)
+		}
+	})
+	b.entries[k] = append([]byte(nil), val...)
+	return nil
+}
+
+// Delete implements walletTx.
+func (mtx *memTx) Delete(bucket, key []byte) error {
+	b := mtx.store.buckets[string(bucket)]
+	k := string(key)
+	old, exists := b.entries[k]
+	if !exists {
+		return nil
+	}
+	mtx.undo = append(mtx.undo, func() {
+		b.entries[k] = old
+	})
+	delete(b.entries, k)
+	return nil
+}
+
+// ForEach implements walletTx.
+func (mtx *memTx) ForEach(bucket []byte, fn func(key, val []byte) error) error {
+	b := mtx.store.buckets[string(bucket)]
+	for _, key := range mtx.sortedKeys(bucket) {
+		if err := fn([]byte(key), b.entries[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Last implements walletTx.
+func (mtx *memTx) Last(bucket []byte) (key, val []byte) {
+	b := mtx.store.buckets[string(bucket)]
+	for k, v := range b.entries {
+		if key == nil || bytes.Compare([]byte(k), key) > 0 {
+			key, val = []byte(k), v
+		}
+	}
+	return key, val
+}
+
+// NextSequence implements walletTx.
+func (mtx *memTx) NextSequence(bucket []byte) (uint64, error) {
+	seq := mtx.Sequence(bucket) + 1
+	return seq, mtx.SetSequence(bucket, seq)
+}
+
+// Sequence implements walletTx.
+func (mtx *memTx) Sequence(bucket []byte) uint64 {
+	return mtx.store.buckets[string(bucket)].sequence
+}
+
+// SetSequence implements walletTx.
+func (mtx *memTx) SetSequence(bucket []byte, seq uint64) error {
+	b := mtx.store.buckets[string(bucket)]
+	old := b.sequence
+	mtx.undo = append(mtx.undo, func() {
+		b.sequence = old
+	})
+	b.sequence = seq
+	return nil
+}
+
+// BucketNames implements walletTx.
+func (mtx *memTx) BucketNames() (names [][]byte) {
+	for name := range mtx.store.buckets {
+		names = append(names, []byte(name))
+	}
+	return names
+}
+
+// ProcessedTransaction implements walletTx.
+func (mtx *memTx) ProcessedTransaction(txid types.TransactionID) (pt modules.ProcessedTransaction, found bool, err error) {
+	err = scanProcessedTransactions(mtx, func(p modules.ProcessedTransaction) bool {
+		if p.TransactionID == txid {
+			pt, found = p, true
+		}
+		return !found
+	})
+	return pt, found, err
+}
+
+// AddressTransactions implements walletTx.
+func (mtx *memTx) AddressTransactions(uh types.UnlockHash) (pts []modules.ProcessedTransaction, err error) {
+	err = scanProcessedTransactions(mtx, func(pt modules.ProcessedTransaction) bool {
+		if processedTransactionInvolves(pt, uh) {
+			pts = append(pts, pt)
+		}
+		return true
+	})
+	return pts, err
+}
+
+// TransactionsInRange implements walletTx.
+func (mtx *memTx) TransactionsInRange(startHeight, endHeight types.BlockHeight) (pts []modules.ProcessedTransaction, err error) {
+	err = scanProcessedTransactions(mtx, func(pt modules.ProcessedTransaction) bool {
+		if pt.ConfirmationHeight > endHeight {
+			return false
+		}
+		if pt.ConfirmationHeight >= startHeight {
+			pts = append(pts, pt)
+		}
+		return true
+	})
+	return pts, err
+}
+
+// Size implements walletTx. It returns the size of the keys and values held
+// by the store.
+func (mtx *memTx) Size() (size int64) {
+	for _, b := range mtx.store.buckets {
+		for key, val := range b.entries {
+			size += int64(len(key) + len(val))
+		}
+	}
+	return size
+}
+
+// Check implements walletTx. A memStore has no file that could be corrupted.
+func (mtx *memTx) Check() []error {
+	return nil
+}
+
+// WriteTo implements walletTx. A memStore cannot be backed up, as the backup
+// would put the wallet's state on disk.
+func (mtx *memTx) WriteTo(w io.Writer) (int64, error) {
+	return 0, errEphemeralDB
+}
+
+// Commit implements walletTx.
+func (mtx *memTx) Commit() error {
+	mtx.undo = nil
+	return nil
+}
+
+// Rollback implements walletTx.
+func (mtx *memTx) Rollback() error {
+	for i := len(mtx.undo) - 1; i >= 0; i-- {
+		mtx.undo[i]()
+	}
+	mtx.undo = nil
+	return nil
+}
@@ -1,10 +1,18 @@
 package wallet
 
 import (
+	"errors"
+	"time"
+
 	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 )
 
+var (
+	errSpendableHeightInPast = errors.New("cannot report spendable balance at a height below the current height")
+)
+
 // sortedOutputs is a struct containing a slice of siacoin outputs and their
 // corresponding ids. sortedOutputs can be sorted using the sort package.
 type sortedOutputs struct {
@@ -32,6 +40,71 @@ func (w *Wallet) ConfirmedBalance() (siacoinBalance types.Currency, siafundBalan
 	return
 }
 
+// SpendableBalance returns the siacoins that the wallet will be able to spend
+// once the blockchain reaches the provided height, assuming no outputs are
+// spent in the meantime. Confirmed outputs are only counted if they will have
+// at least minConfirmations confirmations at that height. Delayed outputs are
+// counted once they mature, regardless of minConfirmations. As with
+// ConfirmedBalance, dust outputs and unconfirmed transactions are ignored.
+func (w *Wallet) SpendableBalance(height types.BlockHeight, minConfirmations types.BlockHeight) (sb modules.SpendableBalance, err error) {
+	// ensure durability of reported balance
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.syncDB()
+
+	consensusHeight, err := dbGetConsensusHeight(w.dbTx)
+	if err != nil {
+		return modules.SpendableBalance{}, err
+	} else if height < consensusHeight {
+		return modules.SpendableBalance{}, errSpendableHeightInPast
+	}
+	sb.Height = height
+
+	// Determine the height at which each output was confirmed. Outputs that
+	// were not created by a wallet transaction, such as matured file contract
+	// payouts, are treated as having been confirmed at height 0.
+	confirmationHeights := make(map[types.OutputID]types.BlockHeight)
+	err = dbForEachProcessedTransaction(w.dbTx, func(pt modules.ProcessedTransaction) {
+		for _, id := range processedOutputIDs(pt) {
+			confirmationHeights[id] = pt.ConfirmationHeight
+		}
+	})
+	if err != nil {
+		return modules.SpendableBalance{}, err
+	}
+
+	err = dbForEachSiacoinOutput(w.dbTx, func(id types.SiacoinOutputID, sco types.SiacoinOutput) {
+		if sco.Value.Cmp(dustValue()) <= 0 {
+			return
+		}
+		// An output confirmed at height h has one confirmation at height h.
+		if height+1 < confirmationHeights[types.OutputID(id)]+minConfirmations {
+			return
+		}
+		timelock := w.keys[sco.UnlockHash].UnlockConditions.Timelock
+		if timelock > height {
+			return
+		} else if timelock > consensusHeight {
+			sb.Timelocked = sb.Timelocked.Add(sco.Value)
+		} else {
+			sb.Available = sb.Available.Add(sco.Value)
+		}
+	})
+	if err != nil {
+		return modules.SpendableBalance{}, err
+	}
+	err = dbForEachDelayedSiacoinOutput(w.dbTx, func(_ types.SiacoinOutputID, dsco delayedSiacoinOutput) {
+		if dsco.MaturityHeight <= height && dsco.SiacoinOutput.Value.Cmp(dustValue()) > 0 {
+			sb.Maturing = sb.Maturing.Add(dsco.SiacoinOutput.Value)
+		}
+	})
+	if err != nil {
+		return modules.SpendableBalance{}, err
+	}
+	sb.Siacoins = sb.Available.Add(sb.Maturing).Add(sb.Timelocked)
+	return sb, nil
+}
+
 // UnconfirmedBalance returns the number of outgoing and incoming siacoins in
 // the unconfirmed transaction set. Refund outputs are included in this
 // reporting.
@@ -55,13 +128,37 @@ func (w *Wallet) UnconfirmedBalance() (outgoingSiacoins types.Currency, incoming
 }
 
 // SendSiacoins creates a transaction sending 'amount' to 'dest'. The transaction
-// is submitted to the transaction pool and is also returned.
+// is submitted to the transaction pool and is also returned. Sends that require
+// approval under the approval policy are refused, and must be queued instead.
 func (w *Wallet) SendSiacoins(amount types.Currency, dest types.UnlockHash) ([]types.Transaction, error) {
 	if err := w.tg.Add(); err != nil {
 		return nil, err
 	}
 	defer w.tg.Done()
 
+	// The send counts towards the approval threshold as soon as it is
+	// allowed, so that concurrent sends cannot exceed it together.
+	w.mu.Lock()
+	us := unapprovedSend{amount: amount, sent: time.Now()}
+	if w.requiresApproval(amount, us.sent) {
+		w.mu.Unlock()
+		return nil, modules.ErrApprovalRequired
+	}
+	w.unapprovedSends = append(w.unapprovedSends, us)
+	w.mu.Unlock()
+
+	txnSet, err := w.managedSendSiacoins(amount, dest)
+	if err != nil {
+		w.mu.Lock()
+		w.forgetUnapprovedSend(us)
+		w.mu.Unlock()
+	}
+	return txnSet, err
+}
+
+// managedSendSiacoins creates, signs, and broadcasts a transaction sending
+// 'amount' to 'dest', without consulting the approval policy.
+func (w *Wallet) managedSendSiacoins(amount types.Currency, dest types.UnlockHash) ([]types.Transaction, error) {
 	tpoolFee := types.SiacoinPrecision.Mul64(10) // TODO: better fee algo.
 	output := types.SiacoinOutput{
 		Value:      amount,
@@ -79,7 +176,7 @@ func (w *Wallet) SendSiacoins(amount types.Currency, dest types.UnlockHash) ([]t
 	if err != nil {
 		return nil, build.ExtendErr("unable to sign transaction", err)
 	}
-	err = w.tpool.AcceptTransactionSet(txnSet)
+	err = w.managedSubmitTransactionSet(txnSet)
 	if err != nil {
 		return nil, build.ExtendErr("unable to get transaction accepted", err)
 	}
@@ -114,7 +211,7 @@ func (w *Wallet) SendSiafunds(amount types.Currency, dest types.UnlockHash) ([]t
 	if err != nil {
 		return nil, err
 	}
-	err = w.tpool.AcceptTransactionSet(txnSet)
+	err = w.managedSubmitTransactionSet(txnSet)
 	if err != nil {
 		return nil, err
 	}
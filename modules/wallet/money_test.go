@@ -181,3 +181,125 @@ func TestIntegrationSortedOutputsSorting(t *testing.T) {
 		}
 	}
 }
+
+// TestSpendableBalance probes the SpendableBalance method of the wallet.
+func TestSpendableBalance(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+	height := wt.cs.Height()
+
+	if _, err := wt.wallet.SpendableBalance(height-1, 0); err != errSpendableHeightInPast {
+		t.Fatal("expected errSpendableHeightInPast, got", err)
+	}
+
+	// At the current height, only confirmed outputs should be spendable.
+	confirmed, _, _ := wt.wallet.ConfirmedBalance()
+	sb, err := wt.wallet.SpendableBalance(height, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sb.Available.Equals(confirmed) || !sb.Maturing.IsZero() || !sb.Siacoins.Equals(confirmed) {
+		t.Fatal("unexpected spendable balance at the current height:", sb)
+	}
+
+	// Mining a block creates a miner payout that matures MaturityDelay
+	// blocks later.
+	b, _ := wt.miner.FindBlock()
+	if err := wt.cs.AcceptBlock(b); err != nil {
+		t.Fatal(err)
+	}
+	var payout types.Currency
+	for _, mp := range b.MinerPayouts {
+		payout = payout.Add(mp.Value)
+	}
+	height = wt.cs.Height()
+	confirmed, _, _ = wt.wallet.ConfirmedBalance()
+	sb, err = wt.wallet.SpendableBalance(height+types.MaturityDelay-1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sb.Siacoins.Equals(sb.Available.Add(sb.Maturing)) {
+		t.Fatal("spendable balance does not add up:", sb)
+	}
+	sb2, err := wt.wallet.SpendableBalance(height+types.MaturityDelay, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sb2.Maturing.Sub(sb.Maturing).Equals(payout) || !sb2.Available.Equals(confirmed) {
+		t.Fatal("new miner payout should mature exactly MaturityDelay blocks after it was created:", sb, sb2)
+	}
+
+	// Requiring more confirmations than the blockchain has blocks should
+	// exclude all confirmed outputs.
+	sb, err = wt.wallet.SpendableBalance(height, height+2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sb.Available.IsZero() {
+		t.Fatal("outputs without enough confirmations were counted:", sb.Available)
+	}
+}
+
+// TestScanDelayedOutputs checks that the delayed outputs of a wallet that
+// processed blocks before they were tracked are found by a rescan.
+func TestScanDelayedOutputs(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+	height := wt.cs.Height() + types.MaturityDelay
+	before, err := wt.wallet.SpendableBalance(height, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before.Maturing.IsZero() {
+		t.Fatal("wallet has no maturing miner payouts")
+	}
+
+	// Forget the delayed outputs, as a wallet created before they were
+	// tracked would.
+	wt.wallet.mu.Lock()
+	var ids []types.SiacoinOutputID
+	err = dbForEachDelayedSiacoinOutput(wt.wallet.dbTx, func(id types.SiacoinOutputID, _ delayedSiacoinOutput) {
+		ids = append(ids, id)
+	})
+	for _, id := range ids {
+		if err == nil {
+			err = dbDeleteDelayedSiacoinOutput(wt.wallet.dbTx, id)
+		}
+	}
+	if err == nil {
+		err = dbPutDelayedOutputsScanned(wt.wallet.dbTx, false)
+	}
+	wt.wallet.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := wt.wallet.managedScanDelayedOutputs(); err != nil {
+		t.Fatal(err)
+	}
+	after, err := wt.wallet.SpendableBalance(height, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !after.Maturing.Equals(before.Maturing) {
+		t.Fatal("rescan did not find the delayed outputs:", before.Maturing, after.Maturing)
+	}
+	wt.wallet.mu.Lock()
+	scanned, err := dbGetDelayedOutputsScanned(wt.wallet.dbTx)
+	wt.wallet.mu.Unlock()
+	if err != nil || !scanned {
+		t.Fatal("wallet was not marked as scanned:", scanned, err)
+	}
+}
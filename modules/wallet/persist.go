@@ -1,18 +1,18 @@
 package wallet
 
 import (
-	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"time"
 
+	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/persist"
 	"github.com/NebulousLabs/fastrand"
-
-	"github.com/NebulousLabs/bolt"
 )
 
 const (
@@ -35,43 +35,90 @@ type spendableKeyFile struct {
 	SpendableKey           crypto.Ciphertext
 }
 
+// openStore opens the database at filename with the wallet's backend.
+func (w *Wallet) openStore(filename string) (walletStore, error) {
+	if w.backend == BackendSQLite {
+		return openSQLiteStore(filename)
+	}
+	return openBoltStore(filename)
+}
+
 // openDB loads the set database and populates it with the necessary buckets.
 func (w *Wallet) openDB(filename string) (err error) {
-	w.db, err = persist.OpenDatabase(dbMetadata, filename)
+	w.db, err = w.openStore(filename)
 	if err != nil {
 		return err
 	}
-	// initialize the database
-	err = w.db.Update(func(tx *bolt.Tx) error {
-		for _, b := range dbBuckets {
-			_, err := tx.CreateBucketIfNotExists(b)
-			if err != nil {
-				return fmt.Errorf("could not create bucket %v: %v", string(b), err)
+	return w.initStore()
+}
+
+// initStore initializes the fields of the wallet's database.
+func (w *Wallet) initStore() error {
+	tx, err := w.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := w.initDB(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// initDB sets the fields of bucketWallet that are missing, and loads the
+// settings that the wallet keeps in memory.
+func (w *Wallet) initDB(tx walletTx) error {
+	// if the wallet does not have a UID, create one
+	if tx.Get(bucketWallet, keyUID) == nil {
+		uid := make([]byte, len(uniqueID{}))
+		fastrand.Read(uid[:])
+		tx.Put(bucketWallet, keyUID, uid)
+	}
+	// if fields in bucketWallet are nil, set them to zero to prevent unmarshal errors
+	defaults := []struct {
+		key []byte
+		val interface{}
+	}{
+		{keyConsensusHeight, uint64(0)},
+		{keyAuxiliarySeedFiles, []seedFile{}},
+		{keySpendableKeyFiles, []spendableKeyFile{}},
+		{keyLockTimeout, time.Duration(0)},
+		{keyApprovalPolicy, modules.ApprovalPolicy{}},
+	}
+	for _, d := range defaults {
+		if tx.Get(bucketWallet, d.key) == nil {
+			if err := tx.Put(bucketWallet, d.key, encoding.Marshal(d.val)); err != nil {
+				return err
 			}
 		}
-		// if the wallet does not have a UID, create one
-		if tx.Bucket(bucketWallet).Get(keyUID) == nil {
-			uid := make([]byte, len(uniqueID{}))
-			fastrand.Read(uid[:])
-			tx.Bucket(bucketWallet).Put(keyUID, uid)
-		}
-		// if fields in bucketWallet are nil, set them to zero to prevent unmarshal errors
-		wb := tx.Bucket(bucketWallet)
-		if wb.Get(keyConsensusHeight) == nil {
-			wb.Put(keyConsensusHeight, encoding.Marshal(uint64(0)))
-		}
-		if wb.Get(keyAuxiliarySeedFiles) == nil {
-			wb.Put(keyAuxiliarySeedFiles, encoding.Marshal([]seedFile{}))
-		}
-		if wb.Get(keySpendableKeyFiles) == nil {
-			wb.Put(keySpendableKeyFiles, encoding.Marshal([]spendableKeyFile{}))
+	}
+
+	// the delayed outputs of a wallet that has not processed any blocks yet
+	// will be found by the initial scan; wallets that processed blocks
+	// before bucketDelayedSiacoinOutputs existed are rescanned on unlock
+	if tx.Get(bucketWallet, keyDelayedOutputsScanned) == nil {
+		fresh := dbGetConsensusChangeID(tx) == modules.ConsensusChangeBeginning
+		if err := dbPutDelayedOutputsScanned(tx, fresh); err != nil {
+			return err
 		}
+	}
 
-		// check whether wallet is encrypted
-		w.encrypted = tx.Bucket(bucketWallet).Get(keyEncryptionVerification) != nil
-		return nil
-	})
-	return err
+	// load the automatic lock timeout
+	var err error
+	w.lockTimeout, err = dbGetLockTimeout(tx)
+	if err != nil {
+		return err
+	}
+
+	// load the approval policy
+	w.approvalPolicy, err = dbGetApprovalPolicy(tx)
+	if err != nil {
+		return err
+	}
+
+	// check whether wallet is encrypted
+	w.encrypted = tx.Get(bucketWallet, keyEncryptionVerification) != nil
+	return nil
 }
 
 // initPersist loads all of the wallet's persistence files into memory,
@@ -93,14 +140,25 @@ func (w *Wallet) initPersist() error {
 	// Open the database.
 	dbFilename := filepath.Join(w.persistDir, dbFile)
 	compatFilename := filepath.Join(w.persistDir, compatFile)
+	sqliteFilename := filepath.Join(w.persistDir, sqliteFile)
 	_, dbErr := os.Stat(dbFilename)
 	_, compatErr := os.Stat(compatFilename)
-	if dbErr != nil && compatErr == nil {
+	_, sqliteErr := os.Stat(sqliteFilename)
+	switch {
+	case w.backend == BackendSQLite && sqliteErr != nil && (dbErr == nil || compatErr == nil):
+		// the wallet has not been migrated from the bolt backend yet
+		err = w.migrateToSQLite(dbFilename, compatFilename, sqliteFilename)
+	case w.backend == BackendSQLite:
+		err = w.openDB(sqliteFilename)
+	case sqliteErr == nil && dbErr != nil:
+		// the wallet has been migrated to the SQLite backend
+		err = errWrongBackend
+	case dbErr != nil && compatErr == nil:
 		// database does not exist, but old persist does; convert it
 		err = w.convertPersistFrom112To120(dbFilename, compatFilename)
-	} else {
+	default:
 		// either database exists or neither exists; open/create the database
-		err = w.openDB(filepath.Join(w.persistDir, dbFile))
+		err = w.openDB(dbFilename)
 	}
 	if err != nil {
 		return err
@@ -110,6 +168,18 @@ func (w *Wallet) initPersist() error {
 	return nil
 }
 
+// initEphemeralPersist initializes a database that is never persisted. The
+// database only lives in memory, so nothing is written to disk.
+func (w *Wallet) initEphemeralPersist() error {
+	w.log = persist.NewLogger(ioutil.Discard)
+	w.db = newMemStore()
+	if err := w.initStore(); err != nil {
+		return err
+	}
+	w.tg.AfterStop(func() { w.db.Close() })
+	return nil
+}
+
 // createBackup copies the wallet database to dst.
 func (w *Wallet) createBackup(dst io.Writer) error {
 	_, err := w.dbTx.WriteTo(dst)
@@ -124,6 +194,9 @@ func (w *Wallet) CreateBackup(backupFilepath string) error {
 	defer w.tg.Done()
 	w.mu.Lock()
 	defer w.mu.Unlock()
+	if w.db.Path() == "" {
+		return errEphemeralDB
+	}
 	f, err := os.Create(backupFilepath)
 	if err != nil {
 		return err
@@ -132,6 +205,34 @@ func (w *Wallet) CreateBackup(backupFilepath string) error {
 	return w.createBackup(f)
 }
 
+// CreateEncryptedBackup creates a backup file at the desired filepath,
+// encrypted with the provided key. The backup is encrypted in chunks as it is
+// written, so the wallet database is never held in memory.
+func (w *Wallet) CreateEncryptedBackup(backupFilepath string, key crypto.TwofishKey) error {
+	if err := w.tg.Add(); err != nil {
+		return err
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.db.Path() == "" {
+		return errEphemeralDB
+	}
+	f, err := os.Create(backupFilepath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sw, err := key.NewStreamWriter(f)
+	if err != nil {
+		return err
+	}
+	if err := w.createBackup(sw); err != nil {
+		return err
+	}
+	return sw.Close()
+}
+
 // compat112Persist is the structure of the wallet.json file used in v1.1.2
 type compat112Persist struct {
 	UID                    uniqueID
@@ -157,37 +258,95 @@ func (w *Wallet) convertPersistFrom112To120(dbFilename, compatFilename string) e
 		return err
 	}
 
-	w.db, err = persist.OpenDatabase(dbMetadata, dbFilename)
+	db, err := openBoltStore(dbFilename)
 	if err != nil {
 		return err
 	}
+	w.db = db
 	// initialize the database
-	err = w.db.Update(func(tx *bolt.Tx) error {
-		for _, b := range dbBuckets {
-			_, err := tx.CreateBucket(b)
-			if err != nil {
-				return fmt.Errorf("could not create bucket %v: %v", string(b), err)
-			}
-		}
-		// set UID, verification, seeds, and seed progress
-		tx.Bucket(bucketWallet).Put(keyUID, data.UID[:])
-		tx.Bucket(bucketWallet).Put(keyEncryptionVerification, data.EncryptionVerification)
-		tx.Bucket(bucketWallet).Put(keyPrimarySeedFile, encoding.Marshal(data.PrimarySeedFile))
-		tx.Bucket(bucketWallet).Put(keyAuxiliarySeedFiles, encoding.Marshal(data.AuxiliarySeedFiles))
-		tx.Bucket(bucketWallet).Put(keySpendableKeyFiles, encoding.Marshal(data.UnseededKeys))
-		// old wallets had a "preload depth" of 25
-		dbPutPrimarySeedProgress(tx, data.PrimarySeedProgress+25)
-
-		// set consensus height and CCID to zero so that a full rescan is
-		// triggered
-		dbPutConsensusHeight(tx, 0)
-		dbPutConsensusChangeID(tx, modules.ConsensusChangeBeginning)
-		return nil
-	})
+	tx, err := w.db.Begin()
+	if err != nil {
+		return err
+	}
+	// set UID, verification, seeds, and seed progress
+	tx.Put(bucketWallet, keyUID, data.UID[:])
+	tx.Put(bucketWallet, keyEncryptionVerification, data.EncryptionVerification)
+	tx.Put(bucketWallet, keyPrimarySeedFile, encoding.Marshal(data.PrimarySeedFile))
+	tx.Put(bucketWallet, keyAuxiliarySeedFiles, encoding.Marshal(data.AuxiliarySeedFiles))
+	tx.Put(bucketWallet, keySpendableKeyFiles, encoding.Marshal(data.UnseededKeys))
+	// old wallets had a "preload depth" of 25
+	dbPutPrimarySeedProgress(tx, data.PrimarySeedProgress+25)
+
+	// set consensus height and CCID to zero so that a full rescan is
+	// triggered
+	dbPutConsensusHeight(tx, 0)
+	dbPutConsensusChangeID(tx, modules.ConsensusChangeBeginning)
+	err = tx.Commit()
 	w.encrypted = true
 	return err
 }
 
+// migrateToSQLite copies a wallet stored with the bolt backend into a new
+// SQLite database and opens it. The bolt database is renamed afterwards, so
+// that it is not opened again by mistake.
+func (w *Wallet) migrateToSQLite(dbFilename, compatFilename, sqliteFilename string) error {
+	var src walletStore
+	var err error
+	if _, statErr := os.Stat(dbFilename); statErr != nil {
+		err = w.convertPersistFrom112To120(dbFilename, compatFilename)
+		src = w.db
+	} else {
+		src, err = openBoltStore(dbFilename)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Copy the wallet into a temporary file, so that an interrupted
+	// migration is started over.
+	tmpFilename := sqliteFilename + "_migrating"
+	os.Remove(tmpFilename)
+	dst, err := openSQLiteStore(tmpFilename)
+	if err != nil {
+		src.Close()
+		return err
+	}
+	err = func() error {
+		srcTx, err := src.Begin()
+		if err != nil {
+			return err
+		}
+		defer srcTx.Rollback()
+		dstTx, err := dst.Begin()
+		if err != nil {
+			return err
+		}
+		if err := copyStore(dstTx, srcTx); err != nil {
+			dstTx.Rollback()
+			return err
+		}
+		return dstTx.Commit()
+	}()
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := src.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpFilename, sqliteFilename)
+	}
+	if err != nil {
+		os.Remove(tmpFilename)
+		return build.ExtendErr("could not migrate the wallet to the "+BackendSQLite+" backend", err)
+	}
+	if err := os.Rename(dbFilename, dbFilename+"_migrated"); err != nil {
+		return err
+	}
+	w.log.Println("INFO: migrated the wallet to the " + BackendSQLite + " backend")
+	return w.openDB(sqliteFilename)
+}
+
 /*
 // LoadBackup loads a backup file from the provided filepath. The backup file
 // primary seed is loaded as an auxiliary seed.
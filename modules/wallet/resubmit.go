@@ -0,0 +1,100 @@
+package wallet
+
+import (
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// managedSubmitTransactionSet submits a transaction set to the transaction
+// pool. If the set is accepted, the wallet tracks it until it is confirmed so
+// that it can be resubmitted if it falls out of the pool.
+func (w *Wallet) managedSubmitTransactionSet(txnSet []types.Transaction) error {
+	err := w.tpool.AcceptTransactionSet(txnSet)
+	if err != nil || len(txnSet) == 0 {
+		return err
+	}
+	w.mu.Lock()
+	w.submittedSets[txnSet[len(txnSet)-1].ID()] = txnSet
+	w.mu.Unlock()
+	return nil
+}
+
+// forgetConfirmedSets stops tracking the submitted sets that have a
+// transaction in one of the provided blocks. If a block containing the set is
+// later reverted, the transaction pool will hold on to the set again.
+func (w *Wallet) forgetConfirmedSets(blocks []types.Block) {
+	if len(w.submittedSets) == 0 {
+		return
+	}
+	confirmed := make(map[types.TransactionID]struct{})
+	for _, block := range blocks {
+		for _, txn := range block.Transactions {
+			confirmed[txn.ID()] = struct{}{}
+		}
+	}
+	for id, set := range w.submittedSets {
+		for _, txn := range set {
+			if _, exists := confirmed[txn.ID()]; exists {
+				delete(w.submittedSets, id)
+				break
+			}
+		}
+	}
+}
+
+// threadedResubmitTransactionSets resubmits the sets that the wallet has
+// submitted to the transaction pool but that are no longer in it, for example
+// because they were evicted or the pool was purged. Sets that the pool
+// rejects can no longer be confirmed, and are forgotten along with the outputs
+// they spent; this includes sets that the eviction policy removed for their
+// age or their fee, which the pool refuses to take back.
+func (w *Wallet) threadedResubmitTransactionSets() {
+	if err := w.tg.Add(); err != nil {
+		return
+	}
+	defer w.tg.Done()
+
+	w.mu.RLock()
+	if len(w.submittedSets) == 0 {
+		w.mu.RUnlock()
+		return
+	}
+	sets := make(map[types.TransactionID][]types.Transaction, len(w.submittedSets))
+	for id, set := range w.submittedSets {
+		sets[id] = set
+	}
+	w.mu.RUnlock()
+
+	inPool := make(map[types.TransactionID]struct{})
+	for _, txn := range w.tpool.TransactionList() {
+		inPool[txn.ID()] = struct{}{}
+	}
+	for id, set := range sets {
+		if _, exists := inPool[id]; exists {
+			continue
+		}
+		err := w.tpool.AcceptTransactionSet(set)
+		if err == nil || err == modules.ErrDuplicateTransactionSet {
+			w.log.Debugln("Resubmitted unconfirmed transaction set", id)
+			continue
+		}
+		w.log.Println("WARN: unconfirmed transaction set could not be resubmitted and will no longer be tracked:", err)
+		w.mu.Lock()
+		delete(w.submittedSets, id)
+		w.releaseSpentOutputs(set)
+		w.mu.Unlock()
+	}
+}
+
+// releaseSpentOutputs makes the outputs spent by a transaction set that will
+// never be confirmed available to new transactions again.
+func (w *Wallet) releaseSpentOutputs(set []types.Transaction) {
+	for _, txn := range set {
+		for _, sci := range txn.SiacoinInputs {
+			dbDeleteSpentOutput(w.dbTx, types.OutputID(sci.ParentID))
+		}
+		for _, sfi := range txn.SiafundInputs {
+			dbDeleteSpentOutput(w.dbTx, types.OutputID(sfi.ParentID))
+		}
+	}
+}
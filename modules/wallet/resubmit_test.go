@@ -0,0 +1,106 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestResubmitTransactionSets checks that the wallet resubmits its sets when
+// they fall out of the transaction pool, and forgets them once confirmed.
+func TestResubmitTransactionSets(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	txnSet, err := wt.wallet.SendSiacoins(types.SiacoinPrecision, types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inPool := func() bool {
+		for _, txn := range wt.tpool.TransactionList() {
+			if txn.ID() == txnSet[len(txnSet)-1].ID() {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Purge the pool, as if the set had been dropped. The wallet should put it
+	// back.
+	wt.tpool.PurgeTransactionPool()
+	if inPool() {
+		t.Fatal("purge did not remove the set from the pool")
+	}
+	wt.wallet.threadedResubmitTransactionSets()
+	if !inPool() {
+		t.Fatal("set was not resubmitted to the pool")
+	}
+
+	// Once the set is confirmed, the wallet should stop tracking it.
+	_, err = wt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt.wallet.mu.RLock()
+	tracked := len(wt.wallet.submittedSets)
+	wt.wallet.mu.RUnlock()
+	if tracked != 0 {
+		t.Fatal("confirmed set is still tracked by the wallet")
+	}
+}
+
+// TestResubmitEvictedTransactionSets checks that the wallet does not put sets
+// back into the transaction pool after the eviction policy removed them.
+func TestResubmitEvictedTransactionSets(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	for _, policy := range []modules.TransactionPoolEvictionPolicy{
+		{MaxAge: time.Nanosecond},
+		{MinFee: types.SiacoinPrecision.Mul64(1e6)},
+	} {
+		wt.tpool.PurgeTransactionPool()
+		err = wt.tpool.SetEvictionPolicy(modules.TransactionPoolEvictionPolicy{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = wt.wallet.SendSiacoins(types.SiacoinPrecision, types.UnlockHash{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = wt.tpool.SetEvictionPolicy(policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(wt.tpool.TransactionList()) != 0 {
+			t.Fatal("set was not evicted by policy", policy)
+		}
+
+		// The pool refuses the evicted set, so the wallet should stop
+		// tracking it instead of putting it back.
+		wt.wallet.threadedResubmitTransactionSets()
+		if len(wt.tpool.TransactionList()) != 0 {
+			t.Fatal("evicted set was resubmitted under policy", policy)
+		}
+		wt.wallet.mu.RLock()
+		tracked := len(wt.wallet.submittedSets)
+		wt.wallet.mu.RUnlock()
+		if tracked != 0 {
+			t.Fatal("evicted set is still tracked by the wallet under policy", policy)
+		}
+	}
+}
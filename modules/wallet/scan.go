@@ -168,3 +168,71 @@ func newSeedScanner(seed modules.Seed) *seedScanner {
 		siafundOutputs: make(map[types.SiafundOutputID]scannedOutput),
 	}
 }
+
+// A delayedOutputScanner scans the blockchain for the delayed siacoin outputs
+// of a wallet. It is used to fill bucketDelayedSiacoinOutputs for wallets that
+// processed blocks before the bucket was introduced.
+type delayedOutputScanner struct {
+	addresses map[types.UnlockHash]struct{}
+	outputs   map[types.SiacoinOutputID]delayedSiacoinOutput
+}
+
+// ProcessConsensusChange tracks the delayed outputs that are sent to the
+// scanner's addresses and that have not matured yet.
+func (s *delayedOutputScanner) ProcessConsensusChange(cc modules.ConsensusChange) {
+	for _, diff := range cc.DelayedSiacoinOutputDiffs {
+		if _, exists := s.addresses[diff.SiacoinOutput.UnlockHash]; !exists {
+			continue
+		}
+		if diff.Direction == modules.DiffApply {
+			s.outputs[diff.ID] = delayedSiacoinOutput{
+				SiacoinOutput:  diff.SiacoinOutput,
+				MaturityHeight: diff.MaturityHeight,
+			}
+		} else {
+			// NOTE: DiffRevert means the output either matured or was in a
+			// block that was reverted.
+			delete(s.outputs, diff.ID)
+		}
+	}
+}
+
+// managedScanDelayedOutputs fills bucketDelayedSiacoinOutputs with the delayed
+// outputs of the wallet, if the wallet processed blocks before the bucket was
+// introduced. The scan only happens once. The wallet must be subscribed to the
+// consensus set afterwards, which brings the outputs up to date with the
+// wallet's own consensus change.
+func (w *Wallet) managedScanDelayedOutputs() error {
+	w.mu.Lock()
+	scanned, err := dbGetDelayedOutputsScanned(w.dbTx)
+	if err != nil || scanned {
+		w.mu.Unlock()
+		return err
+	}
+	s := &delayedOutputScanner{
+		addresses: make(map[types.UnlockHash]struct{}, len(w.keys)),
+		outputs:   make(map[types.SiacoinOutputID]delayedSiacoinOutput),
+	}
+	for uh := range w.keys {
+		s.addresses[uh] = struct{}{}
+	}
+	w.mu.Unlock()
+
+	if err := w.cs.ConsensusSetSubscribe(s, modules.ConsensusChangeBeginning); err != nil {
+		return err
+	}
+	w.cs.Unsubscribe(s)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for id, dsco := range s.outputs {
+		if err := dbPutDelayedSiacoinOutput(w.dbTx, id, dsco); err != nil {
+			return err
+		}
+	}
+	if err := dbPutDelayedOutputsScanned(w.dbTx, true); err != nil {
+		return err
+	}
+	w.log.Printf("INFO: found %v delayed outputs while scanning for the spendable balance", len(s.outputs))
+	return nil
+}
@@ -9,7 +9,6 @@ import (
 	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
-	"github.com/NebulousLabs/bolt"
 	"github.com/NebulousLabs/fastrand"
 )
 
@@ -128,11 +127,11 @@ func (w *Wallet) loadSeed(masterKey crypto.TwofishKey, seed modules.Seed) error
 
 	// add the seedFile
 	var current []seedFile
-	err = encoding.Unmarshal(w.dbTx.Bucket(bucketWallet).Get(keyAuxiliarySeedFiles), &current)
+	err = encoding.Unmarshal(w.dbTx.Get(bucketWallet, keyAuxiliarySeedFiles), &current)
 	if err != nil {
 		return err
 	}
-	err = w.dbTx.Bucket(bucketWallet).Put(keyAuxiliarySeedFiles, encoding.Marshal(append(current, sf)))
+	err = w.dbTx.Put(bucketWallet, keyAuxiliarySeedFiles, encoding.Marshal(append(current, sf)))
 	if err != nil {
 		return err
 	}
@@ -144,7 +143,7 @@ func (w *Wallet) loadSeed(masterKey crypto.TwofishKey, seed modules.Seed) error
 }
 
 // nextPrimarySeedAddress fetches the next address from the primary seed.
-func (w *Wallet) nextPrimarySeedAddress(tx *bolt.Tx) (types.UnlockConditions, error) {
+func (w *Wallet) nextPrimarySeedAddress(tx walletTx) (types.UnlockConditions, error) {
 	// Check that the wallet has been unlocked.
 	if !w.unlocked {
 		return types.UnlockConditions{}, modules.ErrLockedWallet
@@ -377,6 +376,6 @@ func (w *Wallet) SweepSeed(seed modules.Seed) (coins, funds types.Currency, err
 
 	// submit the transaction
 	txnSet := append(parents, txn)
-	err = w.tpool.AcceptTransactionSet(txnSet)
+	err = w.managedSubmitTransactionSet(txnSet)
 	return
 }
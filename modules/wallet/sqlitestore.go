@@ -0,0 +1,545 @@
+// +build cgo
+
+package wallet
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/persist"
+	"github.com/NebulousLabs/Sia/types"
+
+	// The SQLite driver registers itself with database/sql.
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const (
+	// sqliteFile is the name of the wallet database when the SQLite backend
+	// is used.
+	sqliteFile = modules.WalletDir + ".sqlite"
+
+	// sqlitePageSize is the number of entries that ForEach reads from the
+	// database at a time.
+	sqlitePageSize = 1000
+)
+
+// sqliteSchema creates the tables of a SQLite wallet database. The entries of
+// most buckets are kept in a single table. Processed transactions are kept in
+// their own table, indexed by transaction ID and confirmation height, and the
+// addresses that each one involves are indexed in a second table.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS metadata (
+	header  TEXT NOT NULL,
+	version TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS buckets (
+	name     BLOB PRIMARY KEY,
+	sequence INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS entries (
+	bucket BLOB NOT NULL,
+	key    BLOB NOT NULL,
+	value  BLOB NOT NULL,
+	PRIMARY KEY (bucket, key)
+) WITHOUT ROWID;
+CREATE TABLE IF NOT EXISTS processed_transactions (
+	seq    INTEGER PRIMARY KEY,
+	txid   BLOB NOT NULL,
+	height INTEGER NOT NULL,
+	value  BLOB NOT NULL
+);
+CREATE INDEX IF NOT EXISTS processed_transactions_txid ON processed_transactions (txid);
+CREATE INDEX IF NOT EXISTS processed_transactions_height ON processed_transactions (height, seq);
+CREATE TABLE IF NOT EXISTS processed_transaction_addresses (
+	address BLOB NOT NULL,
+	seq     INTEGER NOT NULL,
+	PRIMARY KEY (address, seq)
+) WITHOUT ROWID;
+CREATE INDEX IF NOT EXISTS processed_transaction_addresses_seq ON processed_transaction_addresses (seq);
+`
+
+var (
+	// errBadProcessedTransactionKey is returned when an entry of
+	// bucketProcessedTransactions does not have an 8-byte sequence number as
+	// its key.
+	errBadProcessedTransactionKey = errors.New("processed transaction keys must be 8-byte sequence numbers")
+)
+
+// sqliteStore is a walletStore backed by a SQLite database.
+type sqliteStore struct {
+	db       *sql.DB
+	filename string
+}
+
+// sqliteTx is a walletTx on a sqliteStore. Methods that cannot return an
+// error record the first error that they encounter, and Commit returns it
+// instead of committing.
+type sqliteTx struct {
+	store *sqliteStore
+	tx    *sql.Tx
+	err   error
+}
+
+// openSQLiteStore opens the SQLite database at filename, creating it and the
+// wallet's buckets if they do not exist.
+func openSQLiteStore(filename string) (*sqliteStore, error) {
+	db, err := sql.Open("sqlite3", "file:"+filename+"?_journal_mode=WAL")
+	if err != nil {
+		return nil, err
+	}
+	// The wallet only ever has one transaction open, and SQLite only allows
+	// one writer at a time.
+	db.SetMaxOpenConns(1)
+	s := &sqliteStore{db: db, filename: filename}
+	if err := s.init(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// init creates the tables and buckets of the database if they do not exist,
+// and checks its metadata.
+func (s *sqliteStore) init() error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(sqliteSchema); err != nil {
+		return err
+	}
+
+	var md persist.Metadata
+	err = tx.QueryRow("SELECT header, version FROM metadata").Scan(&md.Header, &md.Version)
+	if err == sql.ErrNoRows {
+		md = dbMetadata
+		_, err = tx.Exec("INSERT INTO metadata (header, version) VALUES (?, ?)", md.Header, md.Version)
+	}
+	if err != nil {
+		return err
+	} else if md.Header != dbMetadata.Header {
+		return persist.ErrBadHeader
+	} else if md.Version != dbMetadata.Version {
+		return persist.ErrBadVersion
+	}
+
+	for _, b := range dbBuckets {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO buckets (name) VALUES (?)", b); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Begin implements walletStore.
+func (s *sqliteStore) Begin() (walletTx, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteTx{store: s, tx: tx}, nil
+}
+
+// Close implements walletStore.
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
+
+// Path implements walletStore.
+func (s *sqliteStore) Path() string {
+	return s.filename
+}
+
+// fail records err if it is the first error of the transaction.
+func (stx *sqliteTx) fail(err error) {
+	if stx.err == nil && err != nil {
+		stx.err = err
+	}
+}
+
+// isProcessedTransactions returns true if bucket is
+// bucketProcessedTransactions, whose entries are kept in their own table.
+func isProcessedTransactions(bucket []byte) bool {
+	return string(bucket) == string(bucketProcessedTransactions)
+}
+
+// seqKey returns the bucketProcessedTransactions key of a sequence number.
+func seqKey(seq int64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(seq))
+	return key
+}
+
+// keySeq returns the sequence number of a bucketProcessedTransactions key.
+func keySeq(key []byte) (int64, error) {
+	if len(key) != 8 {
+		return 0, errBadProcessedTransactionKey
+	}
+	return int64(binary.BigEndian.Uint64(key)), nil
+}
+
+// Get implements walletTx.
+func (stx *sqliteTx) Get(bucket, key []byte) (val []byte) {
+	var err error
+	if isProcessedTransactions(bucket) {
+		seq, seqErr := keySeq(key)
+		if seqErr != nil {
+			return nil
+		}
+		err = stx.tx.QueryRow("SELECT value FROM processed_transactions WHERE seq = ?", seq).Scan(&val)
+	} else {
+		err = stx.tx.QueryRow("SELECT value FROM entries WHERE bucket = ? AND key = ?", bucket, key).Scan(&val)
+	}
+	if err != sql.ErrNoRows {
+		stx.fail(err)
+	}
+	return val
+}
+
+// Put implements walletTx.
+func (stx *sqliteTx) Put(bucket, key, val []byte) error {
+	if !isProcessedTransactions(bucket) {
+		_, err := stx.tx.Exec("INSERT OR REPLACE INTO entries (bucket, key, value) VALUES (?, ?, ?)", bucket, key, val)
+		return err
+	}
+
+	seq, err := keySeq(key)
+	if err != nil {
+		return err
+	}
+	if err := stx.Delete(bucket, key); err != nil {
+		return err
+	}
+	// A transaction that cannot be decoded is still stored, so that it is
+	// reported by CheckDB, but it is not indexed.
+	var pt modules.ProcessedTransaction
+	if encoding.Unmarshal(val, &pt) != nil {
+		pt = modules.ProcessedTransaction{}
+	}
+	_, err = stx.tx.Exec("INSERT INTO processed_transactions (seq, txid, height, value) VALUES (?, ?, ?, ?)", seq, pt.TransactionID[:], int64(pt.ConfirmationHeight), val)
+	if err != nil {
+		return err
+	}
+	addrs := make(map[types.UnlockHash]struct{})
+	for _, input := range pt.Inputs {
+		addrs[input.RelatedAddress] = struct{}{}
+	}
+	for _, output := range pt.Outputs {
+		addrs[output.RelatedAddress] = struct{}{}
+	}
+	for addr := range addrs {
+		_, err := stx.tx.Exec("INSERT INTO processed_transaction_addresses (address, seq) VALUES (?, ?)", addr[:], seq)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete implements walletTx.
+func (stx *sqliteTx) Delete(bucket, key []byte) error {
+	if !isProcessedTransactions(bucket) {
+		_, err := stx.tx.Exec("DELETE FROM entries WHERE bucket = ? AND key = ?", bucket, key)
+		return err
+	}
+	seq, err := keySeq(key)
+	if err != nil {
+		return err
+	}
+	if _, err := stx.tx.Exec("DELETE FROM processed_transaction_addresses WHERE seq = ?", seq); err != nil {
+		return err
+	}
+	_, err = stx.tx.Exec("DELETE FROM processed_transactions WHERE seq = ?", seq)
+	return err
+}
+
+// sqliteEntry is a key/value pair read from the database.
+type sqliteEntry struct {
+	key, val []byte
+}
+
+// page returns up to sqlitePageSize entries of bucket with keys greater than
+// after, or all keys if after is nil.
+func (stx *sqliteTx) page(bucket, after []byte) ([]sqliteEntry, error) {
+	var rows *sql.Rows
+	var err error
+	if isProcessedTransactions(bucket) {
+		afterSeq := int64(-1)
+		if after != nil {
+			afterSeq, _ = keySeq(after)
+		}
+		rows, err = stx.tx.Query("SELECT seq, value FROM processed_transactions WHERE seq > ? ORDER BY seq LIMIT ?", afterSeq, sqlitePageSize)
+	} else if after == nil {
+		rows, err = stx.tx.Query("SELECT key, value FROM entries WHERE bucket = ? ORDER BY key LIMIT ?", bucket, sqlitePageSize)
+	} else {
+		rows, err = stx.tx.Query("SELECT key, value FROM entries WHERE bucket = ? AND key > ? ORDER BY key LIMIT ?", bucket, after, sqlitePageSize)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []sqliteEntry
+	for rows.Next() {
+		var e sqliteEntry
+		if isProcessedTransactions(bucket) {
+			var seq int64
+			err = rows.Scan(&seq, &e.val)
+			e.key = seqKey(seq)
+		} else {
+			err = rows.Scan(&e.key, &e.val)
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// ForEach implements walletTx. Entries are read a page at a time, so fn may
+// read from the database.
+func (stx *sqliteTx) ForEach(bucket []byte, fn func(key, val []byte) error) error {
+	var after []byte
+	for {
+		entries, err := stx.page(bucket, after)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := fn(e.key, e.val); err != nil {
+				return err
+			}
+		}
+		if len(entries) < sqlitePageSize {
+			return nil
+		}
+		after = entries[len(entries)-1].key
+	}
+}
+
+// Last implements walletTx.
+func (stx *sqliteTx) Last(bucket []byte) (key, val []byte) {
+	var err error
+	if isProcessedTransactions(bucket) {
+		var seq int64
+		err = stx.tx.QueryRow("SELECT seq, value FROM processed_transactions ORDER BY seq DESC LIMIT 1").Scan(&seq, &val)
+		if err == nil {
+			key = seqKey(seq)
+		}
+	} else {
+		err = stx.tx.QueryRow("SELECT key, value FROM entries WHERE bucket = ? ORDER BY key DESC LIMIT 1", bucket).Scan(&key, &val)
+	}
+	if err != sql.ErrNoRows {
+		stx.fail(err)
+	}
+	return key, val
+}
+
+// NextSequence implements walletTx.
+func (stx *sqliteTx) NextSequence(bucket []byte) (uint64, error) {
+	seq := stx.Sequence(bucket) + 1
+	return seq, stx.SetSequence(bucket, seq)
+}
+
+// Sequence implements walletTx.
+func (stx *sqliteTx) Sequence(bucket []byte) (seq uint64) {
+	var s int64
+	err := stx.tx.QueryRow("SELECT sequence FROM buckets WHERE name = ?", bucket).Scan(&s)
+	if err != sql.ErrNoRows {
+		stx.fail(err)
+	}
+	return uint64(s)
+}
+
+// SetSequence implements walletTx.
+func (stx *sqliteTx) SetSequence(bucket []byte, seq uint64) error {
+	_, err := stx.tx.Exec("INSERT OR REPLACE INTO buckets (name, sequence) VALUES (?, ?)", bucket, int64(seq))
+	return err
+}
+
+// BucketNames implements walletTx. Buckets that are not used by the wallet
+// exist as long as they have entries.
+func (stx *sqliteTx) BucketNames() (names [][]byte) {
+	rows, err := stx.tx.Query("SELECT name FROM buckets UNION SELECT DISTINCT bucket FROM entries ORDER BY 1")
+	if err != nil {
+		stx.fail(err)
+		return nil
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name []byte
+		if err := rows.Scan(&name); err != nil {
+			stx.fail(err)
+			return nil
+		}
+		names = append(names, name)
+	}
+	stx.fail(rows.Err())
+	return names
+}
+
+// queryProcessedTransactions returns the processed transactions selected by
+// query, which must select their values in chronological order.
+func (stx *sqliteTx) queryProcessedTransactions(query string, args ...interface{}) ([]modules.ProcessedTransaction, error) {
+	rows, err := stx.tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pts []modules.ProcessedTransaction
+	for rows.Next() {
+		var val []byte
+		if err := rows.Scan(&val); err != nil {
+			return nil, err
+		}
+		var pt modules.ProcessedTransaction
+		if err := encoding.Unmarshal(val, &pt); err != nil {
+			return nil, err
+		}
+		pts = append(pts, pt)
+	}
+	return pts, rows.Err()
+}
+
+// ProcessedTransaction implements walletTx.
+func (stx *sqliteTx) ProcessedTransaction(txid types.TransactionID) (modules.ProcessedTransaction, bool, error) {
+	pts, err := stx.queryProcessedTransactions("SELECT value FROM processed_transactions WHERE txid = ? ORDER BY seq LIMIT 1", txid[:])
+	if err != nil || len(pts) == 0 {
+		return modules.ProcessedTransaction{}, false, err
+	}
+	return pts[0], true, nil
+}
+
+// AddressTransactions implements walletTx.
+func (stx *sqliteTx) AddressTransactions(uh types.UnlockHash) ([]modules.ProcessedTransaction, error) {
+	return stx.queryProcessedTransactions(`SELECT pt.value FROM processed_transaction_addresses AS a
+		JOIN processed_transactions AS pt ON pt.seq = a.seq
+		WHERE a.address = ? ORDER BY a.seq`, uh[:])
+}
+
+// TransactionsInRange implements walletTx.
+func (stx *sqliteTx) TransactionsInRange(startHeight, endHeight types.BlockHeight) ([]modules.ProcessedTransaction, error) {
+	// SQLite integers are signed.
+	if endHeight > math.MaxInt64 {
+		endHeight = math.MaxInt64
+	}
+	return stx.queryProcessedTransactions("SELECT value FROM processed_transactions WHERE height BETWEEN ? AND ? ORDER BY seq", int64(startHeight), int64(endHeight))
+}
+
+// Size implements walletTx.
+func (stx *sqliteTx) Size() int64 {
+	var pages, pageSize int64
+	if err := stx.tx.QueryRow("PRAGMA page_count").Scan(&pages); err != nil {
+		stx.fail(err)
+		return 0
+	}
+	if err := stx.tx.QueryRow("PRAGMA page_size").Scan(&pageSize); err != nil {
+		stx.fail(err)
+		return 0
+	}
+	return pages * pageSize
+}
+
+// Check implements walletTx.
+func (stx *sqliteTx) Check() (errs []error) {
+	rows, err := stx.tx.Query("PRAGMA integrity_check")
+	if err != nil {
+		return []error{err}
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var result string
+		if err := rows.Scan(&result); err != nil {
+			return append(errs, err)
+		}
+		if result != "ok" {
+			errs = append(errs, errors.New(result))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// WriteTo implements walletTx. SQLite can only copy a database outside of a
+// transaction, so the transaction is committed, the database is copied, and
+// a new transaction is started in its place.
+func (stx *sqliteTx) WriteTo(w io.Writer) (int64, error) {
+	if err := stx.Commit(); err != nil {
+		return 0, err
+	}
+	defer func() {
+		tx, err := stx.store.db.Begin()
+		stx.tx, stx.err = tx, err
+	}()
+
+	f, err := ioutil.TempFile(filepath.Dir(stx.store.filename), sqliteFile+"_backup")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if _, err := stx.store.db.Exec("VACUUM INTO ?", f.Name()); err != nil {
+		return 0, err
+	}
+	return io.Copy(w, f)
+}
+
+// Commit implements walletTx.
+func (stx *sqliteTx) Commit() error {
+	if stx.tx == nil {
+		return stx.err
+	} else if stx.err != nil {
+		stx.tx.Rollback()
+		return stx.err
+	}
+	return stx.tx.Commit()
+}
+
+// Rollback implements walletTx.
+func (stx *sqliteTx) Rollback() error {
+	if stx.tx == nil {
+		return stx.err
+	}
+	return stx.tx.Rollback()
+}
+
+// compactSQLite compacts a wallet database that uses the SQLite backend. The
+// stale entries are deleted, and the database is then rebuilt by SQLite
+// without its free pages.
+func (w *Wallet) compactSQLite() (compaction modules.WalletDBCompaction, err error) {
+	s := w.db.(*sqliteStore)
+	compaction.SizeBefore = uint64(w.dbTx.Size())
+	compaction.EntriesRemoved, err = pruneDB(w.dbTx)
+	if err != nil {
+		return modules.WalletDBCompaction{}, err
+	}
+	// SQLite can only rebuild a database outside of a transaction.
+	if err := w.dbTx.Commit(); err != nil {
+		return modules.WalletDBCompaction{}, err
+	}
+	_, vacuumErr := s.db.Exec("VACUUM")
+	w.dbTx, err = w.db.Begin()
+	if err != nil {
+		w.log.Severe("ERROR: failed to start database update:", err)
+		return modules.WalletDBCompaction{}, err
+	}
+	if vacuumErr != nil {
+		return modules.WalletDBCompaction{}, vacuumErr
+	}
+	compaction.SizeAfter = uint64(w.dbTx.Size())
+	w.log.Printf("INFO: compacted the wallet database from %v to %v bytes, removing %v stale entries", compaction.SizeBefore, compaction.SizeAfter, compaction.EntriesRemoved)
+	return compaction, nil
+}
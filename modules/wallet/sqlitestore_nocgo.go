@@ -0,0 +1,23 @@
+// +build !cgo
+
+package wallet
+
+import (
+	"errors"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// errSQLiteUnavailable is returned when the SQLite backend is used by a
+// wallet built without cgo, which the SQLite driver requires.
+var errSQLiteUnavailable = errors.New("sqlite backend not available: siad was built without cgo")
+
+// openSQLiteStore returns errSQLiteUnavailable.
+func openSQLiteStore(filename string) (walletStore, error) {
+	return nil, errSQLiteUnavailable
+}
+
+// compactSQLite returns errSQLiteUnavailable.
+func (w *Wallet) compactSQLite() (modules.WalletDBCompaction, error) {
+	return modules.WalletDBCompaction{}, errSQLiteUnavailable
+}
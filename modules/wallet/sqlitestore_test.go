@@ -0,0 +1,148 @@
+// +build cgo
+
+package wallet
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// walletHistory is the history of a wallet as reported by its history
+// queries.
+type walletHistory struct {
+	all     []byte
+	recent  []byte
+	address []byte
+	txn     []byte
+}
+
+// history queries the history of w, encoding the results so that they can be
+// compared.
+func history(t *testing.T, w *Wallet, uh types.UnlockHash, txid types.TransactionID) walletHistory {
+	all, err := w.Transactions(0, ^types.BlockHeight(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recent, err := w.Transactions(types.MaturityDelay, types.MaturityDelay+2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txn, found := w.Transaction(txid)
+	if !found {
+		t.Fatal("transaction", txid, "was not found")
+	}
+	return walletHistory{
+		all:     encoding.Marshal(all),
+		recent:  encoding.Marshal(recent),
+		address: encoding.Marshal(w.AddressTransactions(uh)),
+		txn:     encoding.Marshal(txn),
+	}
+}
+
+// TestSQLiteMigration checks that a wallet is migrated from the bolt backend
+// to the SQLite backend, that both backends answer history queries the same
+// way, and that a migrated wallet cannot be opened with the bolt backend.
+func TestSQLiteMigration(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// Send some siacoins to the wallet itself, so that it has a history to
+	// compare.
+	uc, err := wt.wallet.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	txns, err := wt.wallet.SendSiacoins(types.SiacoinPrecision.Mul64(10), uc.UnlockHash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	txid := txns[len(txns)-1].ID()
+	before := history(t, wt.wallet, uc.UnlockHash(), txid)
+	if len(wt.wallet.AddressTransactions(uc.UnlockHash())) == 0 {
+		t.Fatal("address has no transactions")
+	}
+	balance, _, _ := wt.wallet.ConfirmedBalance()
+
+	// Reopen the wallet with the SQLite backend.
+	if err := wt.wallet.Close(); err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewWithBackend(wt.cs, wt.tpool, wt.wallet.persistDir, BackendSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt.wallet = w
+	if err := w.Unlock(wt.walletMasterKey); err != nil {
+		t.Fatal(err)
+	}
+	if w.db.Path() != filepath.Join(w.persistDir, sqliteFile) {
+		t.Fatal("wallet was not migrated")
+	}
+	if after := history(t, w, uc.UnlockHash(), txid); !reflect.DeepEqual(before, after) {
+		t.Fatal("history differs after migrating to the SQLite backend")
+	}
+	if newBalance, _, _ := w.ConfirmedBalance(); !newBalance.Equals(balance) {
+		t.Fatalf("balance changed from %v to %v", balance, newBalance)
+	}
+	report, err := w.CheckDB()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(report.Problems) != 0 {
+		t.Fatal("expected no problems, got", report.Problems)
+	}
+	if _, err := w.CompactDB(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.SendSiacoins(types.SiacoinPrecision, types.UnlockHash{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.CreateBackup(filepath.Join(w.persistDir, "backup.sqlite")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The migrated wallet cannot be opened with the bolt backend, but can be
+	// opened with the SQLite backend again.
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(wt.cs, wt.tpool, w.persistDir); err != errWrongBackend {
+		t.Fatal("expected errWrongBackend, got", err)
+	}
+	w, err = NewWithBackend(wt.cs, wt.tpool, w.persistDir, BackendSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt.wallet = w
+	if err := w.Unlock(wt.walletMasterKey); err != nil {
+		t.Fatal(err)
+	}
+	if after := history(t, w, uc.UnlockHash(), txid); !reflect.DeepEqual(before, after) {
+		t.Fatal("history differs after reopening the SQLite backend")
+	}
+}
+
+// TestUnknownBackend checks that a wallet cannot be created with an unknown
+// backend.
+func TestUnknownBackend(t *testing.T) {
+	wt, err := createBlankWalletTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+	if _, err := NewWithBackend(wt.cs, wt.tpool, wt.persistDir, "leveldb"); err != errUnknownBackend {
+		t.Fatal("expected errUnknownBackend, got", err)
+	}
+}
@@ -0,0 +1,173 @@
+package wallet
+
+import (
+	"errors"
+	"io"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+const (
+	// BackendBolt stores the wallet in a bolt database. It is the default
+	// backend.
+	BackendBolt = "bolt"
+
+	// BackendSQLite stores the wallet in a SQLite database, which indexes the
+	// wallet's processed transactions by ID, address, and confirmation
+	// height. Wallets with a long transaction history answer history queries
+	// much faster with this backend.
+	BackendSQLite = "sqlite"
+)
+
+var (
+	// errUnknownBackend is returned when creating a wallet with an
+	// unrecognized backend.
+	errUnknownBackend = errors.New("unknown wallet backend; expected \"" + BackendBolt + "\" or \"" + BackendSQLite + "\"")
+
+	// errWrongBackend is returned when opening a wallet with the bolt backend
+	// after it has been migrated to the SQLite backend.
+	errWrongBackend = errors.New("the wallet has been migrated to the " + BackendSQLite + " backend")
+)
+
+// A walletStore is a database that holds the wallet's persistent state. The
+// wallet keeps a single walletTx open at all times, and commits it
+// periodically to avoid excessive disk writes.
+type walletStore interface {
+	// Begin starts a read-write transaction.
+	Begin() (walletTx, error)
+
+	// Close closes the database.
+	Close() error
+
+	// Path returns the filename of the database.
+	Path() string
+}
+
+// A walletTx is a read-write transaction on a walletStore. The wallet's state
+// is organized into the buckets listed in dbBuckets, each holding key/value
+// pairs in key order. Stores may index the entries of
+// bucketProcessedTransactions so that the history queries can be answered
+// without scanning the wallet's entire history.
+type walletTx interface {
+	// Get returns the value of key in bucket, or nil if there is none.
+	Get(bucket, key []byte) []byte
+
+	// Put sets the value of key in bucket.
+	Put(bucket, key, val []byte) error
+
+	// Delete removes key from bucket.
+	Delete(bucket, key []byte) error
+
+	// ForEach calls fn on each entry of bucket, in key order. fn must not
+	// modify the bucket.
+	ForEach(bucket []byte, fn func(key, val []byte) error) error
+
+	// Last returns the entry of bucket with the largest key, or nil if the
+	// bucket is empty.
+	Last(bucket []byte) (key, val []byte)
+
+	// NextSequence increments and returns the sequence number of bucket.
+	NextSequence(bucket []byte) (uint64, error)
+
+	// Sequence returns the sequence number of bucket, and SetSequence
+	// changes it.
+	Sequence(bucket []byte) uint64
+	SetSequence(bucket []byte, seq uint64) error
+
+	// BucketNames returns the names of all of the buckets in the database,
+	// including buckets that are not used by the wallet.
+	BucketNames() [][]byte
+
+	// ProcessedTransaction returns the processed transaction with the given
+	// ID.
+	ProcessedTransaction(txid types.TransactionID) (pt modules.ProcessedTransaction, found bool, err error)
+
+	// AddressTransactions returns the processed transactions that involve
+	// the address, in chronological order.
+	AddressTransactions(uh types.UnlockHash) ([]modules.ProcessedTransaction, error)
+
+	// TransactionsInRange returns the processed transactions confirmed in
+	// the range [startHeight, endHeight], in chronological order.
+	TransactionsInRange(startHeight, endHeight types.BlockHeight) ([]modules.ProcessedTransaction, error)
+
+	// Size returns the size of the database in bytes.
+	Size() int64
+
+	// Check checks the integrity of the database file, returning any
+	// problems found.
+	Check() []error
+
+	// WriteTo writes a copy of the database, as it appears in the
+	// transaction, to w.
+	WriteTo(w io.Writer) (int64, error)
+
+	// Commit commits the transaction, and Rollback discards it.
+	Commit() error
+	Rollback() error
+}
+
+// bucketEntries returns the number of entries in bucket, including entries
+// that have not been committed yet.
+func bucketEntries(tx walletTx, bucket []byte) (n int) {
+	tx.ForEach(bucket, func(_, _ []byte) error {
+		n++
+		return nil
+	})
+	return n
+}
+
+// processedTransactionInvolves returns true if pt spends from or sends to the
+// address.
+func processedTransactionInvolves(pt modules.ProcessedTransaction, uh types.UnlockHash) bool {
+	for _, input := range pt.Inputs {
+		if input.RelatedAddress == uh {
+			return true
+		}
+	}
+	for _, output := range pt.Outputs {
+		if output.RelatedAddress == uh {
+			return true
+		}
+	}
+	return false
+}
+
+// scanProcessedTransactions calls fn on each processed transaction in
+// chronological order, decoding them one at a time, until fn returns false.
+// It is used by stores that do not index the wallet's history.
+func scanProcessedTransactions(tx walletTx, fn func(modules.ProcessedTransaction) bool) error {
+	errStop := errors.New("stop")
+	err := tx.ForEach(bucketProcessedTransactions, func(_, val []byte) error {
+		var pt modules.ProcessedTransaction
+		if err := encoding.Unmarshal(val, &pt); err != nil {
+			return err
+		}
+		if !fn(pt) {
+			return errStop
+		}
+		return nil
+	})
+	if err == errStop {
+		err = nil
+	}
+	return err
+}
+
+// copyStore copies the wallet's buckets and their sequence numbers from src
+// to dst.
+func copyStore(dst, src walletTx) error {
+	for _, bucket := range dbBuckets {
+		err := src.ForEach(bucket, func(key, val []byte) error {
+			return dst.Put(bucket, key, val)
+		})
+		if err != nil {
+			return err
+		}
+		if err := dst.SetSequence(bucket, src.Sequence(bucket)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -9,8 +9,6 @@ import (
 	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
-
-	"github.com/NebulousLabs/bolt"
 )
 
 var (
@@ -28,6 +26,14 @@ var (
 
 	// errDustOutput indicates an output is not spendable because it is dust.
 	errDustOutput = errors.New("output is too small")
+
+	// errRevisionIndexOutOfRange is returned when signing a file contract
+	// revision that does not exist in the transaction.
+	errRevisionIndexOutOfRange = errors.New("file contract revision index is out of range")
+
+	// errRevisionKeyMismatch is returned when signing a file contract
+	// revision with a key that does not appear in its unlock conditions.
+	errRevisionKeyMismatch = errors.New("key does not appear in the unlock conditions of the file contract revision")
 )
 
 // transactionBuilder allows transactions to be manually constructed, including
@@ -92,7 +98,7 @@ func addSignatures(txn *types.Transaction, cf types.CoveredFields, uc types.Unlo
 }
 
 // checkOutput is a helper function used to determine if an output is usable.
-func (w *Wallet) checkOutput(tx *bolt.Tx, currentHeight types.BlockHeight, id types.SiacoinOutputID, output types.SiacoinOutput) error {
+func (w *Wallet) checkOutput(tx walletTx, currentHeight types.BlockHeight, id types.SiacoinOutputID, output types.SiacoinOutput) error {
 	// Check that an output is not dust
 	if output.Value.Cmp(dustValue()) < 0 {
 		return errDustOutput
@@ -267,15 +273,17 @@ func (tb *transactionBuilder) FundSiafunds(amount types.Currency) error {
 	var potentialFund types.Currency
 	parentTxn := types.Transaction{}
 	var spentSfoids []types.SiafundOutputID
-	c := tb.wallet.dbTx.Bucket(bucketSiafundOutputs).Cursor()
-	for idBytes, sfoBytes := c.First(); idBytes != nil; idBytes, sfoBytes = c.Next() {
-		var sfoid types.SiafundOutputID
-		var sfo types.SiafundOutput
-		if err := encoding.Unmarshal(idBytes, &sfoid); err != nil {
-			return err
-		} else if err := encoding.Unmarshal(sfoBytes, &sfo); err != nil {
-			return err
-		}
+	var sfoids []types.SiafundOutputID
+	var sfos []types.SiafundOutput
+	err = dbForEachSiafundOutput(tb.wallet.dbTx, func(sfoid types.SiafundOutputID, sfo types.SiafundOutput) {
+		sfoids = append(sfoids, sfoid)
+		sfos = append(sfos, sfo)
+	})
+	if err != nil {
+		return err
+	}
+	for i, sfoid := range sfoids {
+		sfo := sfos[i]
 
 		// Check that this output has not recently been spent by the wallet.
 		spendHeight, err := dbGetSpentOutput(tb.wallet.dbTx, types.OutputID(sfoid))
@@ -421,6 +429,35 @@ func (tb *transactionBuilder) AddFileContractRevision(fcr types.FileContractRevi
 	return uint64(len(tb.transaction.FileContractRevisions) - 1)
 }
 
+// SignFileContractRevision adds a signature covering only the file contract
+// revision at the provided index, returning the index of the signature within
+// the transaction. The public key of sk must appear in the unlock conditions of
+// the revision.
+func (tb *transactionBuilder) SignFileContractRevision(index uint64, sk crypto.SecretKey) (uint64, error) {
+	if index >= uint64(len(tb.transaction.FileContractRevisions)) {
+		return 0, errRevisionIndexOutOfRange
+	}
+	fcr := tb.transaction.FileContractRevisions[index]
+	pk := sk.PublicKey()
+	for i, siaPubKey := range fcr.UnlockConditions.PublicKeys {
+		if !bytes.Equal(siaPubKey.Key, pk[:]) {
+			continue
+		}
+		tb.transaction.TransactionSignatures = append(tb.transaction.TransactionSignatures, types.TransactionSignature{
+			ParentID:       crypto.Hash(fcr.ParentID),
+			PublicKeyIndex: uint64(i),
+			CoveredFields: types.CoveredFields{
+				FileContractRevisions: []uint64{index},
+			},
+		})
+		sigIndex := len(tb.transaction.TransactionSignatures) - 1
+		encodedSig := crypto.SignHash(tb.transaction.SigHash(sigIndex), sk)
+		tb.transaction.TransactionSignatures[sigIndex].Signature = encodedSig[:]
+		return uint64(sigIndex), nil
+	}
+	return 0, errRevisionKeyMismatch
+}
+
 // AddStorageProof adds a storage proof to the transaction, returning the index
 // of the storage proof within the transaction.
 func (tb *transactionBuilder) AddStorageProof(sp types.StorageProof) uint64 {
@@ -502,6 +539,14 @@ func (tb *transactionBuilder) Sign(wholeTransaction bool) ([]types.Transaction,
 	if tb.signed {
 		return nil, errBuilderAlreadySigned
 	}
+	// Storage proofs may not appear alongside any outputs or contracts. The
+	// check is done here so that no inputs are signed for an invalid
+	// transaction.
+	txn := tb.transaction
+	if len(txn.StorageProofs) > 0 && (len(txn.SiacoinOutputs) > 0 || len(txn.FileContracts) > 0 ||
+		len(txn.FileContractRevisions) > 0 || len(txn.SiafundOutputs) > 0) {
+		return nil, types.ErrStorageProofWithOutputs
+	}
 
 	// Create the coveredfields struct.
 	var coveredFields types.CoveredFields
@@ -542,6 +587,9 @@ func (tb *transactionBuilder) Sign(wholeTransaction bool) ([]types.Transaction,
 		coveredFields.TransactionSignatures = append(coveredFields.TransactionSignatures, uint64(i))
 	}
 
+	// Signing counts as wallet activity, postponing the automatic lock.
+	tb.wallet.managedPostponeAutoLock()
+
 	// For each siacoin input in the transaction that we added, provide a
 	// signature.
 	tb.wallet.mu.RLock()
@@ -4,6 +4,7 @@ import (
 	"sync"
 	"testing"
 
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 )
@@ -444,3 +445,121 @@ func TestParallelBuilders(t *testing.T) {
 		t.Fatal("did not get the expected ending balance", expected, endingSCConfirmed, startingSCConfirmed)
 	}
 }
+
+// TestFileContractRevisionBuilder checks that a file contract and a revision
+// of it can be built with the transaction builder, with the wallet funding and
+// signing the siacoin inputs and external keys signing the revision.
+func TestFileContractRevisionBuilder(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// Create a contract controlled by two external keys.
+	renterSK, renterPK := crypto.GenerateKeyPair()
+	hostSK, hostPK := crypto.GenerateKeyPair()
+	uc := types.UnlockConditions{
+		PublicKeys: []types.SiaPublicKey{
+			{Algorithm: types.SignatureEd25519, Key: renterPK[:]},
+			{Algorithm: types.SignatureEd25519, Key: hostPK[:]},
+		},
+		SignaturesRequired: 2,
+	}
+	payout := types.NewCurrency64(10e9)
+	outputs := []types.SiacoinOutput{{Value: types.PostTax(wt.cs.Height(), payout)}}
+	fc := types.FileContract{
+		WindowStart:        wt.cs.Height() + 10,
+		WindowEnd:          wt.cs.Height() + 20,
+		Payout:             payout,
+		ValidProofOutputs:  outputs,
+		MissedProofOutputs: outputs,
+		UnlockHash:         uc.UnlockHash(),
+	}
+	b := wt.wallet.StartTransaction()
+	if err := b.FundSiacoins(payout); err != nil {
+		t.Fatal(err)
+	}
+	b.AddFileContract(fc)
+	txnSet, err := b.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.tpool.AcceptTransactionSet(txnSet); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Revise the contract, funding the miner fee from the wallet.
+	fcr := types.FileContractRevision{
+		ParentID:              txnSet[len(txnSet)-1].FileContractID(0),
+		UnlockConditions:      uc,
+		NewRevisionNumber:     1,
+		NewWindowStart:        fc.WindowStart,
+		NewWindowEnd:          fc.WindowEnd,
+		NewValidProofOutputs:  fc.ValidProofOutputs,
+		NewMissedProofOutputs: fc.MissedProofOutputs,
+		NewUnlockHash:         fc.UnlockHash,
+	}
+	b = wt.wallet.StartTransaction()
+	index := b.AddFileContractRevision(fcr)
+	if _, err := b.SignFileContractRevision(index+1, renterSK); err != errRevisionIndexOutOfRange {
+		t.Fatal("expected errRevisionIndexOutOfRange, got", err)
+	}
+	otherSK, _ := crypto.GenerateKeyPair()
+	if _, err := b.SignFileContractRevision(index, otherSK); err != errRevisionKeyMismatch {
+		t.Fatal("expected errRevisionKeyMismatch, got", err)
+	}
+	for _, sk := range []crypto.SecretKey{renterSK, hostSK} {
+		if _, err := b.SignFileContractRevision(index, sk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fee := types.SiacoinPrecision
+	if err := b.FundSiacoins(fee); err != nil {
+		t.Fatal(err)
+	}
+	b.AddMinerFee(fee)
+	txnSet, err = b.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.tpool.AcceptTransactionSet(txnSet); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestStorageProofWithOutputs checks that Sign refuses to sign a transaction
+// that mixes a storage proof with outputs.
+func TestStorageProofWithOutputs(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	b := wt.wallet.StartTransaction()
+	defer b.Drop()
+	if err := b.FundSiacoins(types.SiacoinPrecision); err != nil {
+		t.Fatal(err)
+	}
+	b.AddSiacoinOutput(types.SiacoinOutput{Value: types.SiacoinPrecision})
+	b.AddStorageProof(types.StorageProof{})
+	if _, err := b.Sign(true); err != types.ErrStorageProofWithOutputs {
+		t.Fatal("expected ErrStorageProofWithOutputs, got", err)
+	}
+	if _, _, _, sigs := b.ViewAdded(); len(sigs) != 0 {
+		t.Fatal("no inputs should have been signed")
+	}
+}
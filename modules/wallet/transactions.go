@@ -3,15 +3,97 @@ package wallet
 import (
 	"errors"
 
-	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 )
 
 var (
-	errOutOfBounds = errors.New("requesting transactions at unknown confirmation heights")
+	errOutOfBounds    = errors.New("requesting transactions at unknown confirmation heights")
+	errUnknownAddress = errors.New("address does not belong to the wallet")
+	errUnknownOutput  = errors.New("output has never been controlled by the wallet")
 )
 
+// addressBalanceDelta returns the siacoins and siafunds that a processed
+// transaction sends to and spends from the provided address.
+func addressBalanceDelta(pt modules.ProcessedTransaction, uh types.UnlockHash) (scIn, scOut, sfIn, sfOut types.Currency, relevant bool) {
+	for _, input := range pt.Inputs {
+		if input.RelatedAddress != uh {
+			continue
+		}
+		relevant = true
+		switch input.FundType {
+		case types.SpecifierSiacoinInput:
+			scOut = scOut.Add(input.Value)
+		case types.SpecifierSiafundInput:
+			sfOut = sfOut.Add(input.Value)
+		}
+	}
+	for _, output := range pt.Outputs {
+		if output.RelatedAddress != uh {
+			continue
+		}
+		relevant = true
+		switch output.FundType {
+		case types.SpecifierSiacoinOutput, types.SpecifierMinerPayout:
+			scIn = scIn.Add(output.Value)
+		case types.SpecifierSiafundOutput:
+			sfIn = sfIn.Add(output.Value)
+		}
+	}
+	return
+}
+
+// AddressHistory returns the balance and transaction history of a single
+// wallet address. An error is returned if the address does not belong to the
+// wallet, as the wallet only tracks the transactions of its own addresses.
+func (w *Wallet) AddressHistory(uh types.UnlockHash) (ah modules.AddressHistory, err error) {
+	if err := w.tg.Add(); err != nil {
+		return modules.AddressHistory{}, err
+	}
+	defer w.tg.Done()
+
+	// ensure durability of reported history
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.keys[uh]; !ok {
+		return modules.AddressHistory{}, errUnknownAddress
+	}
+	w.syncDB()
+
+	ah.Address = uh
+	pts, err := w.dbTx.AddressTransactions(uh)
+	if err != nil {
+		return modules.AddressHistory{}, err
+	}
+	var received, spent, sfReceived, sfSpent types.Currency
+	for _, pt := range pts {
+		scIn, scOut, sfIn, sfOut, _ := addressBalanceDelta(pt, uh)
+		received, spent = received.Add(scIn), spent.Add(scOut)
+		sfReceived, sfSpent = sfReceived.Add(sfIn), sfSpent.Add(sfOut)
+	}
+	ah.ConfirmedTransactions = pts
+	// An address cannot spend more than it has received, but guard against
+	// underflow anyway.
+	if received.Cmp(spent) >= 0 {
+		ah.ConfirmedSiacoinBalance = received.Sub(spent)
+	}
+	if sfReceived.Cmp(sfSpent) >= 0 {
+		ah.SiafundBalance = sfReceived.Sub(sfSpent)
+	}
+
+	for _, upt := range w.unconfirmedProcessedTransactions {
+		scIn, scOut, _, _, relevant := addressBalanceDelta(upt, uh)
+		if !relevant {
+			continue
+		}
+		ah.UnconfirmedIncomingSiacoins = ah.UnconfirmedIncomingSiacoins.Add(scIn)
+		ah.UnconfirmedOutgoingSiacoins = ah.UnconfirmedOutgoingSiacoins.Add(scOut)
+		ah.UnconfirmedTransactions = append(ah.UnconfirmedTransactions, upt)
+	}
+	return ah, nil
+}
+
 // AddressTransactions returns all of the wallet transactions associated with a
 // single unlock hash.
 func (w *Wallet) AddressTransactions(uh types.UnlockHash) (pts []modules.ProcessedTransaction) {
@@ -20,19 +102,10 @@ func (w *Wallet) AddressTransactions(uh types.UnlockHash) (pts []modules.Process
 	defer w.mu.Unlock()
 	w.syncDB()
 
-	dbForEachProcessedTransaction(w.dbTx, func(pt modules.ProcessedTransaction) {
-		relevant := false
-		for _, input := range pt.Inputs {
-			relevant = relevant || input.RelatedAddress == uh
-		}
-		for _, output := range pt.Outputs {
-			relevant = relevant || output.RelatedAddress == uh
-		}
-		if relevant {
-			pts = append(pts, pt)
-		}
-	})
-
+	pts, err := w.dbTx.AddressTransactions(uh)
+	if err != nil {
+		w.log.Severe("ERROR: failed to read the wallet's transactions:", err)
+	}
 	return pts
 }
 
@@ -75,20 +148,11 @@ func (w *Wallet) Transaction(txid types.TransactionID) (pt modules.ProcessedTran
 	defer w.mu.Unlock()
 	w.syncDB()
 
-	c := w.dbTx.Bucket(bucketProcessedTransactions).Cursor()
-	for key, val := c.First(); key != nil; key, val = c.Next() {
-		if err := encoding.Unmarshal(val, &pt); err != nil {
-			if err != nil {
-				w.log.Severe("ERROR: failed to decode database entry:", err)
-			}
-			return
-		}
-		if pt.TransactionID == txid {
-			return pt, true
-		}
+	pt, found, err := w.dbTx.ProcessedTransaction(txid)
+	if err != nil {
+		w.log.Severe("ERROR: failed to decode database entry:", err)
 	}
-
-	return
+	return pt, found
 }
 
 // Transactions returns all transactions relevant to the wallet that were
@@ -106,23 +170,7 @@ func (w *Wallet) Transactions(startHeight, endHeight types.BlockHeight) (pts []m
 		return nil, errOutOfBounds
 	}
 
-	c := w.dbTx.Bucket(bucketProcessedTransactions).Cursor()
-	for key, val := c.First(); key != nil; key, val = c.Next() {
-		var pt modules.ProcessedTransaction
-		if err = encoding.Unmarshal(val, &pt); err != nil {
-			return
-		}
-		if pt.ConfirmationHeight < startHeight {
-			continue
-		} else if pt.ConfirmationHeight > endHeight {
-			// transactions are stored in chronological order, so we can
-			// break as soon as we are above endHeight
-			break
-		} else {
-			pts = append(pts, pt)
-		}
-	}
-	return
+	return w.dbTx.TransactionsInRange(startHeight, endHeight)
 }
 
 // UnconfirmedTransactions returns the set of unconfirmed transactions that are
@@ -132,3 +180,107 @@ func (w *Wallet) UnconfirmedTransactions() []modules.ProcessedTransaction {
 	defer w.mu.RUnlock()
 	return w.unconfirmedProcessedTransactions
 }
+
+// processedOutputIDs returns the ID of each output in a ProcessedTransaction,
+// in the same order as pt.Outputs. Miner fees do not create outputs, and are
+// given an empty ID.
+func processedOutputIDs(pt modules.ProcessedTransaction) []types.OutputID {
+	ids := make([]types.OutputID, len(pt.Outputs))
+	var scoIndex, sfoIndex, claimIndex uint64
+	for i, po := range pt.Outputs {
+		switch po.FundType {
+		case types.SpecifierMinerPayout:
+			// The transaction ID of a miner payout transaction is the ID of
+			// the block. See types.Block.MinerPayoutID.
+			ids[i] = types.OutputID(crypto.HashAll(types.BlockID(pt.TransactionID), uint64(i)))
+		case types.SpecifierSiacoinOutput:
+			ids[i] = types.OutputID(pt.Transaction.SiacoinOutputID(scoIndex))
+			scoIndex++
+		case types.SpecifierSiafundOutput:
+			ids[i] = types.OutputID(pt.Transaction.SiafundOutputID(sfoIndex))
+			sfoIndex++
+		case types.SpecifierClaimOutput:
+			ids[i] = types.OutputID(pt.Transaction.SiafundInputs[claimIndex].ParentID.SiaClaimOutputID())
+			claimIndex++
+		}
+	}
+	return ids
+}
+
+// spendsOutput returns true if the transaction has an input that spends the
+// output with the given ID.
+func spendsOutput(txn types.Transaction, id types.OutputID) bool {
+	for _, sci := range txn.SiacoinInputs {
+		if types.OutputID(sci.ParentID) == id {
+			return true
+		}
+	}
+	for _, sfi := range txn.SiafundInputs {
+		if types.OutputID(sfi.ParentID) == id {
+			return true
+		}
+	}
+	return false
+}
+
+// OutputProvenance returns the history of an output that the wallet has
+// controlled: the transaction that created it, and, if it has been spent, the
+// transaction that spent it and the outputs created by that transaction.
+// Unconfirmed transactions are considered after all confirmed transactions.
+func (w *Wallet) OutputProvenance(id types.OutputID) (op modules.OutputProvenance, err error) {
+	// ensure durability of reported transactions
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.syncDB()
+
+	var pts []modules.ProcessedTransaction
+	err = dbForEachProcessedTransaction(w.dbTx, func(pt modules.ProcessedTransaction) {
+		pts = append(pts, pt)
+	})
+	if err != nil {
+		return modules.OutputProvenance{}, err
+	}
+	pts = append(pts, w.unconfirmedProcessedTransactions...)
+
+	// Find the transaction that created the output. Transactions are stored
+	// in chronological order, so only the transactions that follow it need to
+	// be searched for the transaction that spent it.
+	received := -1
+	for i, pt := range pts {
+		for j, oid := range processedOutputIDs(pt) {
+			if oid != id || !pt.Outputs[j].WalletAddress {
+				continue
+			}
+			op.Output = modules.ProvenanceOutput{ID: id, ProcessedOutput: pt.Outputs[j]}
+			op.ReceivedTransactionID = pt.TransactionID
+			op.ReceivedHeight = pt.ConfirmationHeight
+			op.ReceivedTimestamp = pt.ConfirmationTimestamp
+			received = i
+			break
+		}
+		if received != -1 {
+			break
+		}
+	}
+	if received == -1 {
+		return modules.OutputProvenance{}, errUnknownOutput
+	}
+
+	for _, pt := range pts[received+1:] {
+		if !spendsOutput(pt.Transaction, id) {
+			continue
+		}
+		op.Spent = true
+		op.SpentTransactionID = pt.TransactionID
+		op.SpentHeight = pt.ConfirmationHeight
+		op.SpentTimestamp = pt.ConfirmationTimestamp
+		for i, oid := range processedOutputIDs(pt) {
+			if pt.Outputs[i].FundType == types.SpecifierMinerFee {
+				continue
+			}
+			op.SpentInto = append(op.SpentInto, modules.ProvenanceOutput{ID: oid, ProcessedOutput: pt.Outputs[i]})
+		}
+		break
+	}
+	return op, nil
+}
@@ -1,6 +1,7 @@
 package wallet
 
 import (
+	"math"
 	"testing"
 
 	"github.com/NebulousLabs/Sia/types"
@@ -131,3 +132,141 @@ func TestIntegrationAddressTransactions(t *testing.T) {
 		t.Error("addresses unconfirmed transactions should be empty")
 	}
 }
+
+// TestIntegrationOutputProvenance checks that the wallet reports where its
+// outputs came from and where they went.
+func TestIntegrationOutputProvenance(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// Outputs that the wallet never controlled should be rejected.
+	if _, err := wt.wallet.OutputProvenance(types.OutputID{}); err != errUnknownOutput {
+		t.Fatal("expected errUnknownOutput, got", err)
+	}
+
+	// Spend some outputs; the first transaction funds the second using the
+	// miner payouts received by the wallet tester.
+	sendTxns, err := wt.wallet.SendSiacoins(types.NewCurrency64(5000), types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent := sendTxns[0]
+	if len(parent.SiacoinInputs) == 0 {
+		t.Fatal("expected the parent transaction to have inputs")
+	}
+	spentID := types.OutputID(parent.SiacoinInputs[0].ParentID)
+
+	checkProvenance := func(height types.BlockHeight) {
+		op, err := wt.wallet.OutputProvenance(spentID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if op.Output.ID != spentID || op.Output.FundType != types.SpecifierMinerPayout || !op.Output.WalletAddress {
+			t.Fatal("wrong output reported:", op.Output)
+		}
+		if _, exists := wt.wallet.Transaction(op.ReceivedTransactionID); !exists {
+			t.Fatal("receiving transaction is not in the wallet history")
+		}
+		if !op.Spent || op.SpentTransactionID != parent.ID() || op.SpentHeight != height {
+			t.Fatal("wrong spend reported:", op.Spent, op.SpentTransactionID, op.SpentHeight)
+		}
+		if len(op.SpentInto) != len(parent.SiacoinOutputs) {
+			t.Fatal("expected", len(parent.SiacoinOutputs), "outputs, got", len(op.SpentInto))
+		}
+		for i, sco := range op.SpentInto {
+			if sco.ID != types.OutputID(parent.SiacoinOutputID(uint64(i))) {
+				t.Fatal("wrong output ID reported for output", i)
+			}
+		}
+	}
+	checkProvenance(types.BlockHeight(math.MaxUint64))
+
+	// Once the transaction is confirmed, the spend height should be reported.
+	b, _ := wt.miner.FindBlock()
+	if err := wt.cs.AcceptBlock(b); err != nil {
+		t.Fatal(err)
+	}
+	checkProvenance(wt.cs.Height())
+
+	// The outputs created by the spend should report the spend as the
+	// transaction they were received in.
+	op, err := wt.wallet.OutputProvenance(types.OutputID(sendTxns[1].SiacoinInputs[0].ParentID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op.ReceivedTransactionID != parent.ID() || op.ReceivedHeight != wt.cs.Height() {
+		t.Fatal("wrong receipt reported:", op.ReceivedTransactionID, op.ReceivedHeight)
+	}
+}
+
+// TestIntegrationAddressHistory checks that AddressHistory reports the balance
+// and transactions of a single address as they move from unconfirmed to
+// confirmed.
+func TestIntegrationAddressHistory(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// Addresses that do not belong to the wallet should be rejected.
+	if _, err := wt.wallet.AddressHistory(types.UnlockHash{1}); err != errUnknownAddress {
+		t.Fatal("expected errUnknownAddress, got", err)
+	}
+
+	uc, err := wt.wallet.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := uc.UnlockHash()
+	ah, err := wt.wallet.AddressHistory(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ah.Address != addr || !ah.ConfirmedSiacoinBalance.IsZero() || len(ah.ConfirmedTransactions) != 0 || len(ah.UnconfirmedTransactions) != 0 {
+		t.Fatal("fresh address should have no history:", ah)
+	}
+
+	// Send coins to the address; they should show up as unconfirmed.
+	amount := types.SiacoinPrecision.Mul64(100)
+	if _, err := wt.wallet.SendSiacoins(amount, addr); err != nil {
+		t.Fatal(err)
+	}
+	ah, err = wt.wallet.AddressHistory(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ah.UnconfirmedIncomingSiacoins.Equals(amount) || !ah.UnconfirmedOutgoingSiacoins.IsZero() || len(ah.UnconfirmedTransactions) != 1 {
+		t.Fatal("unexpected unconfirmed history:", ah)
+	}
+	if !ah.ConfirmedSiacoinBalance.IsZero() || len(ah.ConfirmedTransactions) != 0 {
+		t.Fatal("unconfirmed transaction affected the confirmed history:", ah)
+	}
+
+	// Once mined, the coins should move to the confirmed balance.
+	if _, err := wt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	ah, err = wt.wallet.AddressHistory(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ah.ConfirmedSiacoinBalance.Equals(amount) || len(ah.ConfirmedTransactions) != 1 {
+		t.Fatal("unexpected confirmed history:", ah)
+	}
+	if !ah.UnconfirmedIncomingSiacoins.IsZero() || len(ah.UnconfirmedTransactions) != 0 {
+		t.Fatal("confirmed transaction is still reported as unconfirmed:", ah)
+	}
+	if len(wt.wallet.AddressTransactions(addr)) != len(ah.ConfirmedTransactions) {
+		t.Fatal("AddressHistory and AddressTransactions disagree")
+	}
+}
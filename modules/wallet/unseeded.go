@@ -67,6 +67,19 @@ func decryptSpendableKeyFile(masterKey crypto.TwofishKey, uk spendableKeyFile) (
 	return
 }
 
+// createSpendableKeyFile encrypts a spendable key using a fresh UID.
+func createSpendableKeyFile(masterKey crypto.TwofishKey, sk spendableKey) spendableKeyFile {
+	// Create a UID and encryption verification.
+	var skf spendableKeyFile
+	fastrand.Read(skf.UID[:])
+	encryptionKey := uidEncryptionKey(masterKey, skf.UID)
+	skf.EncryptionVerification = encryptionKey.EncryptBytes(verificationPlaintext)
+
+	// Encrypt the key.
+	skf.SpendableKey = encryptionKey.EncryptBytes(encoding.Marshal(sk))
+	return skf
+}
+
 // integrateSpendableKey loads a spendableKey into the wallet.
 func (w *Wallet) integrateSpendableKey(masterKey crypto.TwofishKey, sk spendableKey) {
 	w.keys[sk.UnlockConditions.UnlockHash()] = sk
@@ -88,25 +101,17 @@ func (w *Wallet) loadSpendableKey(masterKey crypto.TwofishKey, sk spendableKey)
 
 	// TODO: Check that the key is actually spendable.
 
-	// Create a UID and encryption verification.
-	var skf spendableKeyFile
-	fastrand.Read(skf.UID[:])
-	encryptionKey := uidEncryptionKey(masterKey, skf.UID)
-	skf.EncryptionVerification = encryptionKey.EncryptBytes(verificationPlaintext)
-
-	// Encrypt and save the key.
-	skf.SpendableKey = encryptionKey.EncryptBytes(encoding.Marshal(sk))
-
+	skf := createSpendableKeyFile(masterKey, sk)
 	err := checkMasterKey(w.dbTx, masterKey)
 	if err != nil {
 		return err
 	}
 	var current []spendableKeyFile
-	err = encoding.Unmarshal(w.dbTx.Bucket(bucketWallet).Get(keySpendableKeyFiles), &current)
+	err = encoding.Unmarshal(w.dbTx.Get(bucketWallet, keySpendableKeyFiles), &current)
 	if err != nil {
 		return err
 	}
-	return w.dbTx.Bucket(bucketWallet).Put(keySpendableKeyFiles, encoding.Marshal(append(current, skf)))
+	return w.dbTx.Put(bucketWallet, keySpendableKeyFiles, encoding.Marshal(append(current, skf)))
 
 	// w.keys[sk.UnlockConditions.UnlockHash()] = sk -> aids with duplicate
 	// detection, but causes db inconsistency. Rescanning is probably the
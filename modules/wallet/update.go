@@ -6,8 +6,6 @@ import (
 
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
-
-	"github.com/NebulousLabs/bolt"
 )
 
 // isWalletAddress is a helper function that checks if an UnlockHash is
@@ -19,7 +17,7 @@ func (w *Wallet) isWalletAddress(uh types.UnlockHash) bool {
 
 // updateConfirmedSet uses a consensus change to update the confirmed set of
 // outputs as understood by the wallet.
-func (w *Wallet) updateConfirmedSet(tx *bolt.Tx, cc modules.ConsensusChange) error {
+func (w *Wallet) updateConfirmedSet(tx walletTx, cc modules.ConsensusChange) error {
 	for _, diff := range cc.SiacoinOutputDiffs {
 		// Verify that the diff is relevant to the wallet.
 		if !w.isWalletAddress(diff.SiacoinOutput.UnlockHash) {
@@ -52,6 +50,25 @@ func (w *Wallet) updateConfirmedSet(tx *bolt.Tx, cc modules.ConsensusChange) err
 			return err
 		}
 	}
+	for _, diff := range cc.DelayedSiacoinOutputDiffs {
+		// Verify that the diff is relevant to the wallet.
+		if !w.isWalletAddress(diff.SiacoinOutput.UnlockHash) {
+			continue
+		}
+
+		var err error
+		if diff.Direction == modules.DiffApply {
+			err = dbPutDelayedSiacoinOutput(tx, diff.ID, delayedSiacoinOutput{
+				SiacoinOutput:  diff.SiacoinOutput,
+				MaturityHeight: diff.MaturityHeight,
+			})
+		} else {
+			err = dbDeleteDelayedSiacoinOutput(tx, diff.ID)
+		}
+		if err != nil {
+			return err
+		}
+	}
 	for _, diff := range cc.SiafundPoolDiffs {
 		if diff.Direction == modules.DiffApply {
 			w.siafundPool = diff.Adjusted
@@ -64,7 +81,7 @@ func (w *Wallet) updateConfirmedSet(tx *bolt.Tx, cc modules.ConsensusChange) err
 
 // revertHistory reverts any transaction history that was destroyed by reverted
 // blocks in the consensus change.
-func (w *Wallet) revertHistory(tx *bolt.Tx, reverted []types.Block) error {
+func (w *Wallet) revertHistory(tx walletTx, reverted []types.Block) error {
 	for _, block := range reverted {
 		// Remove any transactions that have been reverted.
 		for i := len(block.Transactions) - 1; i >= 0; i-- {
@@ -109,7 +126,7 @@ func (w *Wallet) revertHistory(tx *bolt.Tx, reverted []types.Block) error {
 
 // applyHistory applies any transaction history that was introduced by the
 // applied blocks.
-func (w *Wallet) applyHistory(tx *bolt.Tx, applied []types.Block) error {
+func (w *Wallet) applyHistory(tx walletTx, applied []types.Block) error {
 	for _, block := range applied {
 		consensusHeight, err := dbGetConsensusHeight(tx)
 		if err != nil {
@@ -299,9 +316,11 @@ func (w *Wallet) ProcessConsensusChange(cc modules.ConsensusChange) {
 	if err := dbPutConsensusChangeID(w.dbTx, cc.ID); err != nil {
 		w.log.Println("ERROR: failed to update consensus change ID:", err)
 	}
+	w.forgetConfirmedSets(cc.AppliedBlocks)
 
 	if cc.Synced {
 		go w.threadedDefragWallet()
+		go w.threadedResubmitTransactionSets()
 	}
 }
 
@@ -8,8 +8,7 @@ import (
 	"fmt"
 	"sort"
 	"sync"
-
-	"github.com/NebulousLabs/bolt"
+	"time"
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
@@ -57,6 +56,29 @@ type Wallet struct {
 	subscribed  bool
 	primarySeed modules.Seed
 
+	// lockTimeout is the amount of time the wallet may remain unlocked
+	// without signing a transaction before it locks itself. A lockTimeout of
+	// zero disables automatic locking. unlockedUntil is the time at which the
+	// wallet will lock itself, and lockTimer fires at that time.
+	lockTimeout   time.Duration
+	unlockedUntil time.Time
+	lockTimer     *time.Timer
+
+	// approvalPolicy determines which sends must be approved before they are
+	// signed. pendingSends holds the sends that are waiting for approval; it
+	// is not persisted, so pending sends are dropped when siad restarts.
+	// unapprovedSends holds the sends made without approval within the
+	// window of the approval policy, oldest first.
+	approvalPolicy  modules.ApprovalPolicy
+	pendingSends    map[crypto.Hash]modules.PendingSend
+	unapprovedSends []unapprovedSend
+
+	// submittedSets holds the transaction sets that the wallet has submitted
+	// to the transaction pool and that have not yet been confirmed, keyed by
+	// the ID of the last transaction in each set. Sets that fall out of the
+	// pool are resubmitted. Like pendingSends, it is not persisted.
+	submittedSets map[types.TransactionID][]types.Transaction
+
 	// The wallet's dependencies. siafundPool is tracked separately from the
 	// consensus set to minimize the number of queries that the wallet needs
 	// to make to the consensus set; queries to the consensus set are very
@@ -80,9 +102,10 @@ type Wallet struct {
 	// The wallet's database tracks its seeds, keys, outputs, and
 	// transactions. A global db transaction is maintained in memory to avoid
 	// excessive disk writes. Any operations involving dbTx must hold an
-	// exclusive lock.
-	db   *persist.BoltDatabase
-	dbTx *bolt.Tx
+	// exclusive lock. backend is the kind of database that the wallet uses.
+	db      walletStore
+	dbTx    walletTx
+	backend string
 
 	persistDir string
 	log        *persist.Logger
@@ -97,6 +120,50 @@ type Wallet struct {
 // not loaded into the wallet during the call to 'new', but rather during the
 // call to 'Unlock'.
 func New(cs modules.ConsensusSet, tpool modules.TransactionPool, persistDir string) (*Wallet, error) {
+	return NewWithBackend(cs, tpool, persistDir, BackendBolt)
+}
+
+// NewWithBackend creates a new wallet like New, storing it in the given kind
+// of database. A wallet stored with the bolt backend is migrated the first
+// time that it is opened with the SQLite backend; it cannot be opened with the
+// bolt backend afterwards.
+func NewWithBackend(cs modules.ConsensusSet, tpool modules.TransactionPool, persistDir string, backend string) (*Wallet, error) {
+	if backend != BackendBolt && backend != BackendSQLite {
+		return nil, errUnknownBackend
+	}
+	return newWallet(cs, tpool, persistDir, backend, false)
+}
+
+// NewEphemeral creates a wallet that keeps all of its keys and state in
+// memory. No wallet directory or database file is kept, and the wallet is
+// initialized from seed and unlocked before being returned. Restarting the process and calling
+// NewEphemeral with the same seed will re-derive the same wallet. The wallet
+// can be unlocked again after a call to Lock by using the hash of the seed as
+// the master key.
+//
+// NewEphemeral does not wait for the consensus set to be synced. Addresses
+// that have not appeared in the blockchain at the time of the call may be
+// handed out again after a restart.
+func NewEphemeral(cs modules.ConsensusSet, tpool modules.TransactionPool, seed modules.Seed) (*Wallet, error) {
+	w, err := newWallet(cs, tpool, "", BackendBolt, true)
+	if err != nil {
+		return nil, err
+	}
+	masterKey := crypto.TwofishKey(crypto.HashObject(seed))
+	if err := w.managedInitFromSeed(masterKey, seed); err != nil {
+		w.Close()
+		return nil, err
+	}
+	if err := w.Unlock(masterKey); err != nil {
+		w.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// newWallet creates a wallet, either backed by a database in persistDir or,
+// if ephemeral is set, by a database that only lives in memory.
+func newWallet(cs modules.ConsensusSet, tpool modules.TransactionPool, persistDir string, backend string, ephemeral bool) (*Wallet, error) {
 	// Check for nil dependencies.
 	if cs == nil {
 		return nil, errNilConsensusSet
@@ -110,17 +177,25 @@ func New(cs modules.ConsensusSet, tpool modules.TransactionPool, persistDir stri
 		cs:    cs,
 		tpool: tpool,
 
-		keys: make(map[types.UnlockHash]spendableKey),
+		keys:          make(map[types.UnlockHash]spendableKey),
+		pendingSends:  make(map[crypto.Hash]modules.PendingSend),
+		submittedSets: make(map[types.TransactionID][]types.Transaction),
 
+		backend:    backend,
 		persistDir: persistDir,
 	}
-	err := w.initPersist()
+	var err error
+	if ephemeral {
+		err = w.initEphemeralPersist()
+	} else {
+		err = w.initPersist()
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	// begin the initial transaction
-	w.dbTx, err = w.db.Begin(true)
+	w.dbTx, err = w.db.Begin()
 	if err != nil {
 		w.log.Critical("ERROR: failed to start database update:", err)
 	}
@@ -177,3 +252,72 @@ func (w *Wallet) AllAddresses() []types.UnlockHash {
 	sort.Sort(addrs)
 	return addrs
 }
+
+// AddressUsage returns the index, confirmed transaction count, total siacoins
+// received, and balance of every address that has been generated from the
+// primary seed, ordered by index.
+func (w *Wallet) AddressUsage() ([]modules.AddressUsage, error) {
+	if err := w.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer w.tg.Done()
+
+	// ensure durability of reported usage
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.unlocked {
+		return nil, modules.ErrLockedWallet
+	}
+	w.syncDB()
+
+	progress, err := dbGetPrimarySeedProgress(w.dbTx)
+	if err != nil {
+		return nil, err
+	}
+	usage := make([]modules.AddressUsage, progress)
+	indices := make(map[types.UnlockHash]int, progress)
+	for i, sk := range generateKeys(w.primarySeed, 0, progress) {
+		addr := sk.UnlockConditions.UnlockHash()
+		usage[i] = modules.AddressUsage{
+			Address: addr,
+			Index:   uint64(i),
+		}
+		indices[addr] = i
+	}
+
+	err = dbForEachProcessedTransaction(w.dbTx, func(pt modules.ProcessedTransaction) {
+		related := make(map[int]struct{})
+		for _, input := range pt.Inputs {
+			if i, ok := indices[input.RelatedAddress]; ok {
+				related[i] = struct{}{}
+			}
+		}
+		for _, output := range pt.Outputs {
+			i, ok := indices[output.RelatedAddress]
+			if !ok {
+				continue
+			}
+			related[i] = struct{}{}
+			if output.FundType == types.SpecifierSiacoinOutput || output.FundType == types.SpecifierMinerPayout {
+				usage[i].TotalReceived = usage[i].TotalReceived.Add(output.Value)
+			}
+		}
+		for i := range related {
+			usage[i].Seen = true
+			usage[i].Transactions++
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = dbForEachSiacoinOutput(w.dbTx, func(_ types.SiacoinOutputID, sco types.SiacoinOutput) {
+		i, ok := indices[sco.UnlockHash]
+		if ok && sco.Value.Cmp(dustValue()) > 0 {
+			usage[i].Balance = usage[i].Balance.Add(sco.Value)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
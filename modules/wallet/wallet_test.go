@@ -1,6 +1,7 @@
 package wallet
 
 import (
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -193,6 +194,76 @@ func TestAllAddresses(t *testing.T) {
 	}
 }
 
+// TestAddressUsage checks that AddressUsage reports every primary seed address
+// in order, along with the siacoins it has received and its balance.
+func TestAddressUsage(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// Send coins to a fresh address and confirm the transaction.
+	uc, err := wt.wallet.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := uc.UnlockHash()
+	amount := types.SiacoinPrecision.Mul64(100)
+	if _, err := wt.wallet.SendSiacoins(amount, addr); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	usage, err := wt.wallet.AddressUsage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, remaining, err := wt.wallet.PrimarySeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uint64(len(usage)) != maxScanKeys-remaining {
+		t.Fatal("expected", maxScanKeys-remaining, "addresses, got", len(usage))
+	}
+	var found bool
+	var balance types.Currency
+	for i, u := range usage {
+		if u.Index != uint64(i) || u.Address != generateSpendableKey(wt.wallet.primarySeed, u.Index).UnlockConditions.UnlockHash() {
+			t.Fatal("address usage is out of order at index", i)
+		}
+		if u.Seen != (u.Transactions > 0) || u.Balance.Cmp(u.TotalReceived) > 0 {
+			t.Fatal("inconsistent address usage:", u)
+		}
+		balance = balance.Add(u.Balance)
+		if u.Address == addr {
+			found = true
+			if !u.Seen || u.Transactions != 1 || !u.TotalReceived.Equals(amount) || !u.Balance.Equals(amount) {
+				t.Fatal("unexpected usage for the new address:", u)
+			}
+		}
+	}
+	if !found {
+		t.Fatal("new address is missing from the usage list")
+	}
+	if confirmed, _, _ := wt.wallet.ConfirmedBalance(); !balance.Equals(confirmed) {
+		t.Fatal("address balances do not add up to the confirmed balance:", balance, confirmed)
+	}
+
+	// The wallet must be unlocked to derive its addresses.
+	if err := wt.wallet.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.wallet.AddressUsage(); err != modules.ErrLockedWallet {
+		t.Fatal("expected ErrLockedWallet, got", err)
+	}
+}
+
 // TestCloseWallet tries to close the wallet.
 func TestCloseWallet(t *testing.T) {
 	if testing.Short() {
@@ -220,3 +291,59 @@ func TestCloseWallet(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// TestNewEphemeral checks that an ephemeral wallet is unlocked on creation and
+// recovers the same funds each time it is created from the same seed.
+func TestNewEphemeral(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	seed, _, err := wt.wallet.PrimarySeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _, _ := wt.wallet.ConfirmedBalance()
+	if expected.IsZero() {
+		t.Fatal("wallet tester should have a balance")
+	}
+
+	for i := 0; i < 2; i++ {
+		w, err := NewEphemeral(wt.cs, wt.tpool, seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !w.Unlocked() {
+			t.Fatal("ephemeral wallet should be unlocked")
+		}
+		if w.persistDir != "" || w.db.Path() != "" {
+			t.Fatal("ephemeral wallet should not have a persist dir or database file")
+		}
+		backup := filepath.Join(wt.wallet.persistDir, "ephemeral.backup")
+		if err := w.CreateBackup(backup); err != errEphemeralDB {
+			t.Fatal("expected errEphemeralDB, got", err)
+		}
+		if _, err := os.Stat(backup); !os.IsNotExist(err) {
+			t.Fatal("backup file was created for an ephemeral wallet")
+		}
+		if balance, _, _ := w.ConfirmedBalance(); !balance.Equals(expected) {
+			t.Fatalf("expected balance %v, got %v", expected, balance)
+		}
+
+		// The wallet should be able to unlock itself using the hash of the seed.
+		if err := w.Lock(); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Unlock(crypto.TwofishKey(crypto.HashObject(seed))); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
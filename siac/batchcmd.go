@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	batchCmd = &cobra.Command{
+		Use:   "batch [script.json]",
+		Short: "Run a script of siac operations",
+		Long: `Run a list of operations read from a JSON script. Each operation names a siac
+command and its arguments, e.g.:
+
+	[
+		{"op": "host config", "args": ["mincontractprice", "10SC"]},
+		{"op": "host folder resize", "args": ["/mnt/disk1", "2TB"]},
+		{"op": "host announce"}
+	]
+
+Every operation is checked before any of them are run, so a script containing
+an unknown operation or a malformed argument has no effect. Operations are then
+run in order, stopping at the first one that fails. A JSON summary of each
+step is printed to stdout, and siac exits with a non-zero code if any step did
+not succeed.
+
+Available operations:
+     gateway connect [address]
+     gateway disconnect [address]
+     host announce [address]
+     host config [setting] [value]
+     host folder add [path] [size]
+     host folder remove [path]
+     host folder resize [path] [size]
+     host sector delete [root]
+     miner start
+     miner stop
+     renter pause [duration]
+     renter resume
+     wallet lock`,
+		Run: wrap(batchcmd),
+	}
+)
+
+// Step statuses reported in a batch summary.
+const (
+	batchStatusOK      = "ok"
+	batchStatusFailed  = "failed"
+	batchStatusInvalid = "invalid"
+	batchStatusSkipped = "skipped"
+)
+
+var (
+	errBatchEmpty       = errors.New("script does not contain any operations")
+	errBatchUnknownOp   = errors.New("unknown operation")
+	errBatchNumArgs     = errors.New("wrong number of arguments")
+	errBatchStepsFailed = errors.New("batch did not complete")
+)
+
+type (
+	// batchStep is a single operation in a batch script.
+	batchStep struct {
+		Op   string   `json:"op"`
+		Args []string `json:"args"`
+	}
+
+	// batchCall is an API call made on behalf of a batch step.
+	batchCall struct {
+		get  bool
+		call string
+		vals string
+	}
+
+	// batchOp describes how a batch operation is turned into API calls.
+	// prepare is called with exactly nargs arguments, or with up to maxArgs
+	// arguments if maxArgs is greater than nargs.
+	batchOp struct {
+		nargs   int
+		maxArgs int
+		prepare func(args []string) ([]batchCall, error)
+	}
+
+	// batchStepResult reports the outcome of a single batch step.
+	batchStepResult struct {
+		Step   int      `json:"step"`
+		Op     string   `json:"op"`
+		Args   []string `json:"args"`
+		Status string   `json:"status"`
+		Error  string   `json:"error,omitempty"`
+	}
+
+	// batchSummary is the machine-readable report printed by `siac batch`.
+	batchSummary struct {
+		Success   bool              `json:"success"`
+		Completed int               `json:"completed"`
+		Steps     []batchStepResult `json:"steps"`
+	}
+)
+
+// batchOps lists the operations that can be used in a batch script. The names
+// match the corresponding siac commands.
+var batchOps = map[string]batchOp{
+	"gateway connect": {nargs: 1, prepare: func(args []string) ([]batchCall, error) {
+		return []batchCall{{call: "/gateway/connect/" + args[0]}}, nil
+	}},
+	"gateway disconnect": {nargs: 1, prepare: func(args []string) ([]batchCall, error) {
+		return []batchCall{{call: "/gateway/disconnect/" + args[0]}}, nil
+	}},
+	"host announce": {nargs: 0, maxArgs: 1, prepare: func(args []string) ([]batchCall, error) {
+		announce := batchCall{call: "/host/announce"}
+		if len(args) == 1 {
+			announce.vals = url.Values{"netaddress": {args[0]}}.Encode()
+		}
+		return []batchCall{announce, {call: "/host", vals: "acceptingcontracts=true"}}, nil
+	}},
+	"host config": {nargs: 2, prepare: func(args []string) ([]batchCall, error) {
+		value, err := parseHostSetting(args[0], args[1])
+		if err != nil {
+			return nil, err
+		}
+		return []batchCall{{call: "/host", vals: url.Values{args[0]: {value}}.Encode()}}, nil
+	}},
+	"host folder add": {nargs: 2, prepare: func(args []string) ([]batchCall, error) {
+		size, err := parseFilesize(args[1])
+		if err != nil {
+			return nil, fmt.Errorf("could not parse size: %v", err)
+		}
+		return []batchCall{{call: "/host/storage/folders/add", vals: url.Values{"path": {abs(args[0])}, "size": {size}}.Encode()}}, nil
+	}},
+	"host folder remove": {nargs: 1, prepare: func(args []string) ([]batchCall, error) {
+		return []batchCall{{call: "/host/storage/folders/remove", vals: url.Values{"path": {abs(args[0])}}.Encode()}}, nil
+	}},
+	"host folder resize": {nargs: 2, prepare: func(args []string) ([]batchCall, error) {
+		size, err := parseFilesize(args[1])
+		if err != nil {
+			return nil, fmt.Errorf("could not parse size: %v", err)
+		}
+		return []batchCall{{call: "/host/storage/folders/resize", vals: url.Values{"path": {abs(args[0])}, "newsize": {size}}.Encode()}}, nil
+	}},
+	"host sector delete": {nargs: 1, prepare: func(args []string) ([]batchCall, error) {
+		return []batchCall{{call: "/host/storage/sectors/delete/" + args[0]}}, nil
+	}},
+	"miner start": {nargs: 0, prepare: func([]string) ([]batchCall, error) {
+		return []batchCall{{get: true, call: "/miner/start"}}, nil
+	}},
+	"miner stop": {nargs: 0, prepare: func([]string) ([]batchCall, error) {
+		return []batchCall{{get: true, call: "/miner/stop"}}, nil
+	}},
+	"renter pause": {nargs: 1, prepare: func(args []string) ([]batchCall, error) {
+		return []batchCall{{call: "/renter/pause", vals: url.Values{"duration": {args[0]}}.Encode()}}, nil
+	}},
+	"renter resume": {nargs: 0, prepare: func([]string) ([]batchCall, error) {
+		return []batchCall{{call: "/renter/resume"}}, nil
+	}},
+	"wallet lock": {nargs: 0, prepare: func([]string) ([]batchCall, error) {
+		return []batchCall{{call: "/wallet/lock"}}, nil
+	}},
+}
+
+// prepareBatch checks every step of a batch script and converts each one into
+// the API calls that carry it out. If a step is invalid, its index is returned
+// along with the error.
+func prepareBatch(steps []batchStep) ([][]batchCall, int, error) {
+	if len(steps) == 0 {
+		return nil, 0, errBatchEmpty
+	}
+	calls := make([][]batchCall, len(steps))
+	for i, step := range steps {
+		op, ok := batchOps[step.Op]
+		if !ok {
+			return nil, i, fmt.Errorf("%v: %q", errBatchUnknownOp, step.Op)
+		}
+		maxArgs := op.nargs
+		if op.maxArgs > maxArgs {
+			maxArgs = op.maxArgs
+		}
+		if len(step.Args) < op.nargs || len(step.Args) > maxArgs {
+			return nil, i, fmt.Errorf("%v for %q: got %v", errBatchNumArgs, step.Op, len(step.Args))
+		}
+		c, err := op.prepare(step.Args)
+		if err != nil {
+			return nil, i, err
+		}
+		calls[i] = c
+	}
+	return calls, 0, nil
+}
+
+// runBatch executes a batch script, stopping at the first step that fails. The
+// function that performs each API call is passed in so that the batch logic
+// does not depend on a running daemon.
+func runBatch(steps []batchStep, do func(batchCall) error) batchSummary {
+	summary := batchSummary{
+		Steps: make([]batchStepResult, len(steps)),
+	}
+	for i, step := range steps {
+		summary.Steps[i] = batchStepResult{
+			Step:   i,
+			Op:     step.Op,
+			Args:   step.Args,
+			Status: batchStatusSkipped,
+		}
+	}
+
+	calls, invalid, err := prepareBatch(steps)
+	if err != nil {
+		if len(steps) > 0 {
+			summary.Steps[invalid].Status = batchStatusInvalid
+			summary.Steps[invalid].Error = err.Error()
+		}
+		return summary
+	}
+
+	for i := range steps {
+		for _, c := range calls[i] {
+			if err = do(c); err != nil {
+				break
+			}
+		}
+		if err != nil {
+			summary.Steps[i].Status = batchStatusFailed
+			summary.Steps[i].Error = err.Error()
+			return summary
+		}
+		summary.Steps[i].Status = batchStatusOK
+		summary.Completed++
+	}
+	summary.Success = true
+	return summary
+}
+
+// batchcmd is the handler for the command `siac batch [script.json]`.
+// Runs each operation in the script and prints a JSON summary of the results.
+func batchcmd(filename string) {
+	f, err := os.Open(filename)
+	if err != nil {
+		die("Could not open batch script:", err)
+	}
+	var steps []batchStep
+	err = json.NewDecoder(f).Decode(&steps)
+	f.Close()
+	if err != nil {
+		die("Could not parse batch script:", err)
+	}
+
+	summary := runBatch(steps, func(c batchCall) error {
+		if c.get {
+			return get(c.call)
+		}
+		return post(c.call, c.vals)
+	})
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(summary); err != nil {
+		die("Could not encode batch summary:", err)
+	}
+	if !summary.Success {
+		if len(steps) == 0 {
+			die(errBatchEmpty)
+		}
+		die(errBatchStepsFailed)
+	}
+}
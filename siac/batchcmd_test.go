@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+)
+
+// TestPrepareBatch checks that invalid batch scripts are rejected before any
+// step is run.
+func TestPrepareBatch(t *testing.T) {
+	tests := []struct {
+		steps   []batchStep
+		invalid int
+		valid   bool
+	}{
+		{nil, 0, false},
+		{[]batchStep{{Op: "wallet lock"}}, 0, true},
+		{[]batchStep{{Op: "host announce"}, {Op: "host announce", Args: []string{"foo.com:9982"}}}, 0, true},
+		{[]batchStep{{Op: "wallet lock"}, {Op: "wallet explode"}}, 1, false},
+		{[]batchStep{{Op: "wallet lock", Args: []string{"now"}}}, 0, false},
+		{[]batchStep{{Op: "host announce", Args: []string{"a", "b"}}}, 0, false},
+		{[]batchStep{{Op: "host config", Args: []string{"mincontractprice", "1SC"}}}, 0, true},
+		{[]batchStep{{Op: "wallet lock"}, {Op: "host config", Args: []string{"mincontractprice", "lots"}}}, 1, false},
+		{[]batchStep{{Op: "host config", Args: []string{"notasetting", "1"}}}, 0, false},
+		{[]batchStep{{Op: "host folder resize", Args: []string{"/foo", "1XB"}}}, 0, false},
+	}
+	for i, test := range tests {
+		calls, invalid, err := prepareBatch(test.steps)
+		if test.valid && err != nil {
+			t.Errorf("%v: expected script to be valid, got %v", i, err)
+		} else if !test.valid && (err == nil || invalid != test.invalid) {
+			t.Errorf("%v: expected step %v to be invalid, got step %v (%v)", i, test.invalid, invalid, err)
+		} else if test.valid && len(calls) != len(test.steps) {
+			t.Errorf("%v: expected %v steps, got %v", i, len(test.steps), len(calls))
+		}
+	}
+
+	// Currencies should be converted to hastings.
+	calls, _, err := prepareBatch([]batchStep{{Op: "host config", Args: []string{"mincontractprice", "1SC"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls[0][0].call != "/host" || calls[0][0].vals != "mincontractprice=1000000000000000000000000" {
+		t.Fatal("unexpected call:", calls[0][0])
+	}
+
+	// Paths should be escaped in the query string.
+	calls, _, err = prepareBatch([]batchStep{{Op: "host folder add", Args: []string{"/foo&bar=baz", "1GB"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vals, err := url.ParseQuery(calls[0][0].vals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vals.Get("path") != "/foo&bar=baz" || vals.Get("size") != "1000000000" {
+		t.Fatal("unexpected query string:", calls[0][0].vals)
+	}
+}
+
+// TestRunBatch checks that a batch stops at the first failing step and
+// reports the status of every step.
+func TestRunBatch(t *testing.T) {
+	steps := []batchStep{
+		{Op: "wallet lock"},
+		{Op: "renter resume"},
+		{Op: "miner stop"},
+	}
+	var calls []batchCall
+	summary := runBatch(steps, func(c batchCall) error {
+		calls = append(calls, c)
+		if c.call == "/renter/resume" {
+			return errors.New("renter is not paused")
+		}
+		return nil
+	})
+	if summary.Success || summary.Completed != 1 || len(calls) != 2 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	for i, status := range []string{batchStatusOK, batchStatusFailed, batchStatusSkipped} {
+		if summary.Steps[i].Status != status {
+			t.Errorf("expected step %v to be %v, got %v", i, status, summary.Steps[i].Status)
+		}
+	}
+	if summary.Steps[1].Error != "renter is not paused" {
+		t.Error("failed step should report its error, got", summary.Steps[1].Error)
+	}
+
+	// An invalid script should not run any steps.
+	calls = nil
+	summary = runBatch(append(steps, batchStep{Op: "bogus"}), func(c batchCall) error {
+		calls = append(calls, c)
+		return nil
+	})
+	if summary.Success || len(calls) != 0 || summary.Steps[3].Status != batchStatusInvalid || summary.Steps[0].Status != batchStatusSkipped {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+
+	// A successful script should run every step.
+	summary = runBatch(steps[:1], func(batchCall) error { return nil })
+	if !summary.Success || summary.Completed != 1 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+}
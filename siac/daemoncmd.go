@@ -2,12 +2,49 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"text/tabwriter"
 
 	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/spf13/cobra"
 )
 
 var (
+	bandwidthCmd = &cobra.Command{
+		Use:   "bandwidth",
+		Short: "View the shared bandwidth budget",
+		Long: `View the bandwidth budget shared by the renter and the host, and the traffic of
+each role since siad started. When the budget is exhausted, the host serving
+renters takes priority over renter downloads, which take priority over renter
+uploads and repairs.`,
+		Run: wrap(bandwidthcmd),
+	}
+
+	bandwidthLimitCmd = &cobra.Command{
+		Use:   "limit [maxdownloadspeed] [maxuploadspeed]",
+		Short: "Set the shared bandwidth budget",
+		Long: `Cap the rate at which the renter and the host together download and upload.
+Speeds are given in bytes per second, and accept units, e.g. 500KB or 2MiB. A
+speed of 0 removes the cap.`,
+		Run: wrap(bandwidthlimitcmd),
+	}
+
+	featuresCmd = &cobra.Command{
+		Use:   "features",
+		Short: "View feature flags",
+		Long:  "List the feature flags of the Sia daemon and whether each is enabled.",
+		Run:   wrap(featurescmd),
+	}
+
+	featuresSetCmd = &cobra.Command{
+		Use:   "set [name] [on|off|default]",
+		Short: "Enable or disable a feature",
+		Long: `Override a feature flag, enabling or disabling the behavior it guards.
+'default' removes the override. Overrides persist across restarts.`,
+		Run: wrap(featuressetcmd),
+	}
+
 	stopCmd = &cobra.Command{
 		Use:   "stop",
 		Short: "Stop the Sia daemon",
@@ -46,6 +83,15 @@ type daemonVersion struct {
 	Version string
 }
 
+type daemonBandwidth struct {
+	modules.BandwidthLimits
+	Classes []modules.BandwidthClassStats `json:"classes"`
+}
+
+type daemonFeatures struct {
+	Features []modules.FeatureFlag `json:"features"`
+}
+
 // version prints the version of siac and siad.
 func versioncmd() {
 	fmt.Println("Sia Client v" + build.Version)
@@ -58,6 +104,89 @@ func versioncmd() {
 	fmt.Println("Sia Daemon v" + versioninfo.Version)
 }
 
+// bandwidthcmd is the handler for the command `siac bandwidth`.
+// Prints the shared bandwidth budget and the traffic of each class.
+func bandwidthcmd() {
+	var db daemonBandwidth
+	err := getAPI("/daemon/bandwidth", &db)
+	if err != nil {
+		die("Could not get bandwidth budget:", err)
+	}
+	fmt.Println("Max download speed:", speedUnits(db.MaxDownloadSpeed))
+	fmt.Println("Max upload speed:", speedUnits(db.MaxUploadSpeed))
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Priority\tClass\tDownloaded\tUploaded\tWaiting")
+	for _, c := range db.Classes {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", c.Priority, c.Class, filesizeUnits(int64(c.Downloaded)), filesizeUnits(int64(c.Uploaded)), c.Waiting)
+	}
+	w.Flush()
+}
+
+// bandwidthlimitcmd is the handler for the command
+// `siac bandwidth limit [maxdownloadspeed] [maxuploadspeed]`.
+// Sets the shared bandwidth budget.
+func bandwidthlimitcmd(downloadSpeed, uploadSpeed string) {
+	download, err := parseFilesize(downloadSpeed)
+	if err != nil {
+		die("Could not parse download speed:", err)
+	}
+	upload, err := parseFilesize(uploadSpeed)
+	if err != nil {
+		die("Could not parse upload speed:", err)
+	}
+	err = post("/daemon/bandwidth", "maxdownloadspeed="+download+"&maxuploadspeed="+upload)
+	if err != nil {
+		die("Could not set bandwidth budget:", err)
+	}
+	fmt.Println("Set shared bandwidth budget.")
+}
+
+// featurescmd is the handler for the command `siac features`.
+// Lists the daemon's feature flags.
+func featurescmd() {
+	var df daemonFeatures
+	err := getAPI("/daemon/features", &df)
+	if err != nil {
+		die("Could not get feature flags:", err)
+	}
+	if len(df.Features) == 0 {
+		fmt.Println("No feature flags.")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Name\tEnabled\tDefault\tDescription")
+	for _, f := range df.Features {
+		enabled := yesNo(f.Enabled)
+		if f.Overridden {
+			enabled += " (overridden)"
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", f.Name, enabled, yesNo(f.Default), f.Description)
+	}
+	w.Flush()
+}
+
+// featuressetcmd is the handler for the command
+// `siac features set [name] [on|off|default]`.
+// Overrides a feature flag.
+func featuressetcmd(name, setting string) {
+	var enabled string
+	switch setting {
+	case "on":
+		enabled = "true"
+	case "off":
+		enabled = "false"
+	case "default":
+	default:
+		die("Setting must be 'on', 'off', or 'default'.")
+	}
+	err := post("/daemon/features", "name="+name+"&enabled="+enabled)
+	if err != nil {
+		die("Could not set feature flag:", err)
+	}
+	fmt.Println("Set feature flag", name, "to", setting+".")
+}
+
 // stopcmd is the handler for the command `siac stop`.
 // Stops the daemon.
 func stopcmd() {
@@ -3,9 +3,11 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
 
 	"github.com/NebulousLabs/Sia/api"
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 
 	"github.com/spf13/cobra"
@@ -26,6 +28,25 @@ var (
 			"file. Intended for upload to `https://rankings.sia.tech/`.",
 		Run: wrap(renterexportcontracttxnscmd),
 	}
+
+	renterExportManifestCmd = &cobra.Command{
+		Use:   "manifest [destination]",
+		Short: "export a signed manifest of all renter files",
+		Long: "Export a signed manifest listing every file known to the renter, along with " +
+			"its content hash, size, redundancy, and hosts. Keep the manifest somewhere " +
+			"other than the siad data directory, and use 'siac renter verify-manifest' " +
+			"to check a restored renter against it.",
+		Run: wrap(renterexportmanifestcmd),
+	}
+
+	renterExportFilesCmd = &cobra.Command{
+		Use:   "files [paths] [destination]",
+		Short: "export files to a .sia file that can be shared",
+		Long: "Export the comma-separated list of files to a .sia file. Another renter can " +
+			"load the .sia file with 'siac renter load' and download the files, forming " +
+			"contracts with the hosts that store them if needed.",
+		Run: wrap(renterexportfilescmd),
+	}
 )
 
 // renterexportcontracttxnscmd is the handler for the command `siac renter export contract-txns`.
@@ -51,3 +72,38 @@ func renterexportcontracttxnscmd(destination string) {
 	}
 	fmt.Println("Exported contract data to", destination)
 }
+
+// renterexportfilescmd is the handler for the command `siac renter export files`.
+// Exports the files at paths to a shareable .sia file.
+func renterexportfilescmd(paths, destination string) {
+	destination = abs(destination)
+	err := get("/renter/share?siapaths=" + url.QueryEscape(paths) + "&destination=" + url.QueryEscape(destination))
+	if err != nil {
+		die("Could not export files:", err)
+	}
+	fmt.Println("Exported files to", destination)
+}
+
+// renterexportmanifestcmd is the handler for the command `siac renter export manifest`.
+// Exports a signed manifest of the renter's files to JSON.
+func renterexportmanifestcmd(destination string) {
+	var m modules.RenterManifest
+	err := getAPI("/renter/manifest", &m)
+	if err != nil {
+		die("Could not retrieve manifest:", err)
+	}
+	destination = abs(destination)
+	file, err := os.Create(destination)
+	if err != nil {
+		die("Could not export to file:", err)
+	}
+	defer file.Close()
+	enc := json.NewEncoder(file)
+	enc.SetIndent("", "\t")
+	err = enc.Encode(m)
+	if err != nil {
+		die("Could not export to file:", err)
+	}
+	fmt.Printf("Exported manifest of %v files to %v\n", len(m.Files), destination)
+	fmt.Println("Manifest public key:", m.PublicKey.String())
+}
@@ -2,8 +2,12 @@ package main
 
 import (
 	"fmt"
+	"net/url"
 	"os"
+	"sort"
+	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -32,6 +36,16 @@ var (
 		Run:   wrap(gatewaydisconnectcmd),
 	}
 
+	gatewayForgetKeyCmd = &cobra.Command{
+		Use:   "forgetkey [address]",
+		Short: "Forget the identity key of a peer",
+		Long: `Forget the identity key that a peer has presented. The gateway refuses a peer
+that presents a different identity key than it has before, so the key of a
+peer that changed its identity key must be forgotten before the gateway
+connects to it again.`,
+		Run: wrap(gatewayforgetkeycmd),
+	}
+
 	gatewayAddressCmd = &cobra.Command{
 		Use:   "address",
 		Short: "Print the gateway address",
@@ -39,12 +53,143 @@ var (
 		Run:   wrap(gatewayaddresscmd),
 	}
 
+	gatewayRateLimitCmd = &cobra.Command{
+		Use:   "ratelimit [maxdownloadspeed] [maxuploadspeed]",
+		Short: "Set the gateway's bandwidth caps",
+		Long: `Cap the rate at which the gateway downloads from and uploads to its peers,
+summed across all peer connections. Speeds are given in bytes per second, and
+accept units, e.g. 500KB or 2MiB. A speed of 0 removes the cap.`,
+		Run: wrap(gatewayratelimitcmd),
+	}
+
+	gatewayAnchorsCmd = &cobra.Command{
+		Use:   "anchors",
+		Short: "View or modify the anchor peers",
+		Long: `View the anchor peers. The gateway always stays connected to its anchor peers,
+reconnecting to them soon after losing a connection, and never disconnects
+from them to make room for other peers.`,
+		Run: wrap(gatewayanchorscmd),
+	}
+
+	gatewayAnchorsAddCmd = &cobra.Command{
+		Use:   "add [address]",
+		Short: "Add an anchor peer",
+		Long:  "Add a peer to the anchor peers. The gateway connects to it immediately.",
+		Run:   wrap(gatewayanchorsaddcmd),
+	}
+
+	gatewayAnchorsRemoveCmd = &cobra.Command{
+		Use:   "remove [address]",
+		Short: "Remove an anchor peer",
+		Long:  "Remove a peer from the anchor peers. The gateway stays connected to it as an ordinary peer.",
+		Run:   wrap(gatewayanchorsremovecmd),
+	}
+
+	gatewayBlacklistCmd = &cobra.Command{
+		Use:   "blacklist",
+		Short: "View or modify the peer blacklist",
+		Long: `View the peer blacklist. The gateway never connects to or accepts connections
+from peers that match the blacklist. Entries are IP addresses, CIDR ranges, or
+onion hostnames.`,
+		Run: wrap(gatewayblacklistcmd),
+	}
+
+	gatewayBlacklistAddCmd = &cobra.Command{
+		Use:   "add [entry]",
+		Short: "Add an entry to the peer blacklist",
+		Long:  "Add an IP address, CIDR range, or onion hostname to the peer blacklist, disconnecting from peers that match it.",
+		Run:   wrap(gatewayblacklistaddcmd),
+	}
+
+	gatewayBlacklistRemoveCmd = &cobra.Command{
+		Use:   "remove [entry]",
+		Short: "Remove an entry from the peer blacklist",
+		Long:  "Remove an entry from the peer blacklist.",
+		Run:   wrap(gatewayblacklistremovecmd),
+	}
+
+	gatewayWhitelistCmd = &cobra.Command{
+		Use:   "whitelist",
+		Short: "View or modify the peer whitelist",
+		Long: `View the peer whitelist. If the whitelist is not empty, the gateway only
+connects to and accepts connections from peers that match it. Entries are IP
+addresses, CIDR ranges, or onion hostnames.`,
+		Run: wrap(gatewaywhitelistcmd),
+	}
+
+	gatewayWhitelistAddCmd = &cobra.Command{
+		Use:   "add [entry]",
+		Short: "Add an entry to the peer whitelist",
+		Long:  "Add an IP address, CIDR range, or onion hostname to the peer whitelist. Adding the first entry disconnects from all peers that do not match it.",
+		Run:   wrap(gatewaywhitelistaddcmd),
+	}
+
+	gatewayWhitelistRemoveCmd = &cobra.Command{
+		Use:   "remove [entry]",
+		Short: "Remove an entry from the peer whitelist",
+		Long:  "Remove an entry from the peer whitelist.",
+		Run:   wrap(gatewaywhitelistremovecmd),
+	}
+
+	gatewayLimitsCmd = &cobra.Command{
+		Use:   "limits",
+		Short: "View the gateway's peer limits",
+		Long:  "View the limits on the number of peers that the gateway connects to.",
+		Run:   wrap(gatewaylimitscmd),
+	}
+
+	gatewayLimitsSetCmd = &cobra.Command{
+		Use:   "set [maxinbound] [targetoutbound] [whitelistslots]",
+		Short: "Change the gateway's peer limits",
+		Long: `Change the limits on the number of peers that the gateway connects to. When
+the gateway has maxinbound inbound peers, new inbound peers replace existing
+ones. The gateway makes outbound connections until it has targetoutbound
+outbound peers. Whitelisted peers may use whitelistslots inbound slots beyond
+maxinbound.`,
+		Run: wrap(gatewaylimitssetcmd),
+	}
+
+	gatewayRPCTimeoutsCmd = &cobra.Command{
+		Use:   "rpctimeouts",
+		Short: "View the gateway's RPC timeouts",
+		Long:  "View the deadlines that the gateway enforces on RPCs, by default and for individual RPCs.",
+		Run:   wrap(gatewayrpctimeoutscmd),
+	}
+
+	gatewayRPCTimeoutsSetCmd = &cobra.Command{
+		Use:   "set [rpc] [readtimeout] [writetimeout] [timeout]",
+		Short: "Change the gateway's RPC timeouts",
+		Long: `Change the deadlines that the gateway enforces on an RPC, or on all RPCs if rpc
+is "default". readtimeout and writetimeout limit how long a single read or
+write may block, and timeout limits the length of the whole RPC. Timeouts are
+durations such as 30s or 5m. Setting all three timeouts of an RPC to 0 makes
+it use the defaults again. Peers that miss a deadline are penalized.`,
+		Run: wrap(gatewayrpctimeoutssetcmd),
+	}
+
 	gatewayListCmd = &cobra.Command{
 		Use:   "list",
 		Short: "View a list of peers",
 		Long:  "View the current peer list.",
 		Run:   wrap(gatewaylistcmd),
 	}
+
+	gatewayOfflineCmd = &cobra.Command{
+		Use:   "offline",
+		Short: "Take the gateway off the network",
+		Long: `Take the gateway off the network for maintenance. In-flight RPCs are allowed
+to finish, then the gateway disconnects from every peer and stops dialing and
+accepting peers until it is brought back online. The other modules keep
+running. The gateway stays offline across restarts.`,
+		Run: wrap(gatewayofflinecmd),
+	}
+
+	gatewayOnlineCmd = &cobra.Command{
+		Use:   "online",
+		Short: "Bring the gateway back onto the network",
+		Long:  "Bring the gateway back onto the network after taking it offline.",
+		Run:   wrap(gatewayonlinecmd),
+	}
 )
 
 // gatewayconnectcmd is the handler for the command `siac gateway add [address]`.
@@ -67,6 +212,263 @@ func gatewaydisconnectcmd(addr string) {
 	fmt.Println("Removed", addr, "from peer list.")
 }
 
+// gatewayforgetkeycmd is the handler for the command `siac gateway forgetkey
+// [address]`. Forgets the identity key of a peer.
+func gatewayforgetkeycmd(addr string) {
+	err := post("/gateway/forgetkey/"+addr, "")
+	if err != nil {
+		die("Could not forget identity key:", err)
+	}
+	fmt.Println("Forgot the identity key of", addr)
+}
+
+// gatewayofflinecmd is the handler for the command `siac gateway offline`.
+// Takes the gateway off the network.
+func gatewayofflinecmd() {
+	err := post("/gateway/offline", "")
+	if err != nil {
+		die("Could not take the gateway offline:", err)
+	}
+	fmt.Println("Gateway is offline.")
+}
+
+// gatewayonlinecmd is the handler for the command `siac gateway online`.
+// Brings the gateway back onto the network.
+func gatewayonlinecmd() {
+	err := post("/gateway/online", "")
+	if err != nil {
+		die("Could not bring the gateway online:", err)
+	}
+	fmt.Println("Gateway is online.")
+}
+
+// gatewayratelimitcmd is the handler for the command
+// `siac gateway ratelimit [maxdownloadspeed] [maxuploadspeed]`.
+// Sets the gateway's bandwidth caps.
+func gatewayratelimitcmd(downloadSpeed, uploadSpeed string) {
+	download, err := parseFilesize(downloadSpeed)
+	if err != nil {
+		die("Could not parse download speed:", err)
+	}
+	upload, err := parseFilesize(uploadSpeed)
+	if err != nil {
+		die("Could not parse upload speed:", err)
+	}
+	err = post("/gateway", "maxdownloadspeed="+download+"&maxuploadspeed="+upload)
+	if err != nil {
+		die("Could not set rate limits:", err)
+	}
+	fmt.Println("Set gateway rate limits.")
+}
+
+// gatewayfilterlist fetches the gateway's blacklist or whitelist.
+func gatewayfilterlist(list string) []string {
+	var gf api.GatewayFilterGET
+	err := getAPI("/gateway/filter", &gf)
+	if err != nil {
+		die("Could not get peer filter:", err)
+	}
+	if list == "blacklist" {
+		return gf.Blacklist
+	}
+	return gf.Whitelist
+}
+
+// gatewayfilterprint prints the gateway's blacklist or whitelist.
+func gatewayfilterprint(list string) {
+	entries := gatewayfilterlist(list)
+	if len(entries) == 0 {
+		fmt.Printf("The %v is empty.\n", list)
+		return
+	}
+	for _, entry := range entries {
+		fmt.Println(entry)
+	}
+}
+
+// gatewayfilterupdate adds an entry to or removes an entry from the gateway's
+// blacklist or whitelist.
+func gatewayfilterupdate(list, entry string, add bool) {
+	var entries []string
+	for _, e := range gatewayfilterlist(list) {
+		if e != entry {
+			entries = append(entries, e)
+		}
+	}
+	if add {
+		entries = append(entries, entry)
+	}
+	err := post("/gateway/filter", list+"="+url.QueryEscape(strings.Join(entries, ",")))
+	if err != nil {
+		die("Could not update the "+list+":", err)
+	}
+	if add {
+		fmt.Printf("Added %v to the %v.\n", entry, list)
+	} else {
+		fmt.Printf("Removed %v from the %v.\n", entry, list)
+	}
+}
+
+// gatewayanchorslist returns the gateway's anchor peers.
+func gatewayanchorslist() []string {
+	var ga api.GatewayAnchorsGET
+	err := getAPI("/gateway/anchors", &ga)
+	if err != nil {
+		die("Could not get anchor peers:", err)
+	}
+	anchors := make([]string, len(ga.Anchors))
+	for i, addr := range ga.Anchors {
+		anchors[i] = string(addr)
+	}
+	return anchors
+}
+
+// gatewayanchorsupdate adds a peer to or removes a peer from the gateway's
+// anchor peers.
+func gatewayanchorsupdate(addr string, add bool) {
+	var anchors []string
+	for _, a := range gatewayanchorslist() {
+		if a != addr {
+			anchors = append(anchors, a)
+		}
+	}
+	if add {
+		anchors = append(anchors, addr)
+	}
+	err := post("/gateway/anchors", "anchors="+url.QueryEscape(strings.Join(anchors, ",")))
+	if err != nil {
+		die("Could not update the anchor peers:", err)
+	}
+	if add {
+		fmt.Printf("Added %v to the anchor peers.\n", addr)
+	} else {
+		fmt.Printf("Removed %v from the anchor peers.\n", addr)
+	}
+}
+
+// gatewayanchorscmd is the handler for the command `siac gateway anchors`.
+// Prints the anchor peers.
+func gatewayanchorscmd() {
+	anchors := gatewayanchorslist()
+	if len(anchors) == 0 {
+		fmt.Println("There are no anchor peers.")
+		return
+	}
+	for _, addr := range anchors {
+		fmt.Println(addr)
+	}
+}
+
+// gatewayanchorsaddcmd is the handler for the command
+// `siac gateway anchors add [address]`.
+func gatewayanchorsaddcmd(addr string) {
+	gatewayanchorsupdate(addr, true)
+}
+
+// gatewayanchorsremovecmd is the handler for the command
+// `siac gateway anchors remove [address]`.
+func gatewayanchorsremovecmd(addr string) {
+	gatewayanchorsupdate(addr, false)
+}
+
+// gatewayblacklistcmd is the handler for the command `siac gateway blacklist`.
+// Prints the peer blacklist.
+func gatewayblacklistcmd() {
+	gatewayfilterprint("blacklist")
+}
+
+// gatewayblacklistaddcmd is the handler for the command
+// `siac gateway blacklist add [entry]`.
+func gatewayblacklistaddcmd(entry string) {
+	gatewayfilterupdate("blacklist", entry, true)
+}
+
+// gatewayblacklistremovecmd is the handler for the command
+// `siac gateway blacklist remove [entry]`.
+func gatewayblacklistremovecmd(entry string) {
+	gatewayfilterupdate("blacklist", entry, false)
+}
+
+// gatewaywhitelistcmd is the handler for the command `siac gateway whitelist`.
+// Prints the peer whitelist.
+func gatewaywhitelistcmd() {
+	gatewayfilterprint("whitelist")
+}
+
+// gatewaywhitelistaddcmd is the handler for the command
+// `siac gateway whitelist add [entry]`.
+func gatewaywhitelistaddcmd(entry string) {
+	gatewayfilterupdate("whitelist", entry, true)
+}
+
+// gatewaywhitelistremovecmd is the handler for the command
+// `siac gateway whitelist remove [entry]`.
+func gatewaywhitelistremovecmd(entry string) {
+	gatewayfilterupdate("whitelist", entry, false)
+}
+
+// gatewaylimitscmd is the handler for the command `siac gateway limits`.
+// Prints the gateway's peer limits.
+func gatewaylimitscmd() {
+	var gl api.GatewayLimitsGET
+	err := getAPI("/gateway/limits", &gl)
+	if err != nil {
+		die("Could not get peer limits:", err)
+	}
+	fmt.Printf(`Max Inbound:     %v
+Target Outbound: %v
+Whitelist Slots: %v
+`, gl.MaxInbound, gl.TargetOutbound, gl.WhitelistSlots)
+}
+
+// gatewaylimitssetcmd is the handler for the command
+// `siac gateway limits set [maxinbound] [targetoutbound] [whitelistslots]`.
+// Changes the gateway's peer limits.
+func gatewaylimitssetcmd(maxInbound, targetOutbound, whitelistSlots string) {
+	err := post("/gateway/limits", "maxinbound="+maxInbound+"&targetoutbound="+targetOutbound+"&whitelistslots="+whitelistSlots)
+	if err != nil {
+		die("Could not set peer limits:", err)
+	}
+	fmt.Println("Set gateway peer limits.")
+}
+
+// gatewayrpctimeoutscmd is the handler for the command
+// `siac gateway rpctimeouts`. Prints the gateway's RPC timeouts.
+func gatewayrpctimeoutscmd() {
+	var grt api.GatewayRPCTimeoutsGET
+	err := getAPI("/gateway/rpctimeouts", &grt)
+	if err != nil {
+		die("Could not get RPC timeouts:", err)
+	}
+	var names []string
+	for name := range grt.PerRPC {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RPC\tRead Timeout\tWrite Timeout\tTimeout")
+	fmt.Fprintf(w, "default\t%v\t%v\t%v\n", grt.Default.ReadTimeout, grt.Default.WriteTimeout, grt.Default.Timeout)
+	for _, name := range names {
+		rt := grt.PerRPC[name]
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", name, rt.ReadTimeout, rt.WriteTimeout, rt.Timeout)
+	}
+	w.Flush()
+}
+
+// gatewayrpctimeoutssetcmd is the handler for the command
+// `siac gateway rpctimeouts set [rpc] [readtimeout] [writetimeout] [timeout]`.
+// Changes the gateway's RPC timeouts.
+func gatewayrpctimeoutssetcmd(rpc, readTimeout, writeTimeout, timeout string) {
+	if rpc == "default" {
+		rpc = ""
+	}
+	err := post("/gateway/rpctimeouts", "rpc="+url.QueryEscape(rpc)+"&readtimeout="+readTimeout+"&writetimeout="+writeTimeout+"&timeout="+timeout)
+	if err != nil {
+		die("Could not set RPC timeouts:", err)
+	}
+	fmt.Println("Set gateway RPC timeouts.")
+}
+
 // gatewayaddresscmd is the handler for the command `siac gateway address`.
 // Prints the gateway's network address.
 func gatewayaddresscmd() {
@@ -87,7 +489,21 @@ func gatewaycmd() {
 		die("Could not get gateway address:", err)
 	}
 	fmt.Println("Address:", info.NetAddress)
+	fmt.Println("Public key:", info.PublicKey.String())
+	if info.Offline {
+		fmt.Println("Status: offline")
+	}
 	fmt.Println("Active peers:", len(info.Peers))
+	fmt.Println("Max download speed:", speedUnits(info.MaxDownloadSpeed))
+	fmt.Println("Max upload speed:", speedUnits(info.MaxUploadSpeed))
+}
+
+// speedUnits describes a bandwidth cap, where 0 means no cap.
+func speedUnits(speed int64) string {
+	if speed == 0 {
+		return "unlimited"
+	}
+	return filesizeUnits(speed) + "/s"
 }
 
 // gatewaylistcmd is the handler for the command `siac gateway list`.
@@ -100,13 +516,24 @@ func gatewaylistcmd() {
 	}
 	if len(info.Peers) == 0 {
 		fmt.Println("No peers to show.")
+	} else {
+		fmt.Println(len(info.Peers), "active peers:")
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "Version\tOutbound\tBan Score\tAddress")
+		for _, peer := range info.Peers {
+			fmt.Fprintf(w, "%v\t%v\t%.0f\t%v\n", peer.Version, yesNo(!peer.Inbound), peer.BanScore, peer.NetAddress)
+		}
+		w.Flush()
+	}
+	if len(info.BannedPeers) == 0 {
 		return
 	}
-	fmt.Println(len(info.Peers), "active peers:")
+	fmt.Println()
+	fmt.Println(len(info.BannedPeers), "banned peers:")
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "Version\tOutbound\tAddress")
-	for _, peer := range info.Peers {
-		fmt.Fprintf(w, "%v\t%v\t%v\n", peer.Version, yesNo(!peer.Inbound), peer.NetAddress)
+	fmt.Fprintln(w, "Address\tReason\tBanned Until")
+	for _, ban := range info.BannedPeers {
+		fmt.Fprintf(w, "%v\t%v\t%v\n", ban.NetAddress, ban.Reason, ban.Until.Format(time.RFC822))
 	}
 	w.Flush()
 }
@@ -2,9 +2,12 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"math/big"
+	"net/url"
 	"os"
 	"text/tabwriter"
+	"time"
 
 	"github.com/NebulousLabs/Sia/api"
 	"github.com/NebulousLabs/Sia/modules"
@@ -34,6 +37,24 @@ Available settings:
      netaddress:           string
      windowsize:           blocks
 
+     acceptingrelays: boolean
+     relayaddress:    string (empty to stop using a relay)
+
+     maxconcurrentdownloads:    number of RPCs (0 for no limit)
+     maxconcurrentnegotiations: number of RPCs (0 for no limit)
+     maxconcurrentrevisions:    number of RPCs (0 for no limit)
+
+     maxrenterdownloadspeed: bytes per second, per renter (0 for no limit)
+     maxrenteruploadspeed:   bytes per second, per renter (0 for no limit)
+
+     mincontractduration:   blocks
+     mincontractsize:       bytes, applies to renewals (0 for no limit)
+     maxcontractsize:       bytes (0 for no limit)
+     maxcontractsperrenter: number of contracts (0 for no limit)
+     maxstorageperrenter:   bytes (0 for no limit)
+     renterwhitelist:       comma separated renter keys (empty for no whitelist)
+     renterblacklist:       comma separated renter keys
+
      collateral:       currency
      collateralbudget: currency
      maxcollateral:    currency
@@ -51,10 +72,112 @@ For a description of each parameter, see doc/API.md.
 
 To configure the host to accept new contracts, set acceptingcontracts to true:
 	siac host config acceptingcontracts true
+
+A host that cannot be reached from the internet can accept renters through a
+relay host that has acceptingrelays set:
+	siac host config relayaddress relay.example.com:9982
+
+To only accept contracts from certain renters, list their public keys:
+	siac host config renterwhitelist ed25519:<hex>,ed25519:<hex>
 `,
 		Run: wrap(hostconfigcmd),
 	}
 
+	hostAbuseCmd = &cobra.Command{
+		Use:   "abuse",
+		Short: "List renters that have been throttled or banned",
+		Long: `List renters that have recently misbehaved, along with whether they have been
+throttled or banned by the host's abuse detection, and any overrides set by
+the host operator.`,
+		Run: wrap(hostabusecmd),
+	}
+
+	hostAbuseOverrideCmd = &cobra.Command{
+		Use:   "override [renter] [allow|ban|clear]",
+		Short: "Override the abuse detection for a renter",
+		Long: `Override the abuse detection for a renter, identified by its public key
+(ed25519:...) or its IP address. Allowed renters are never throttled or
+banned, and banned renters are refused until the override is cleared.
+Clearing an override also forgets the renter's record.`,
+		Run: wrap(hostabuseoverridecmd),
+	}
+
+	hostBandwidthCmd = &cobra.Command{
+		Use:   "bandwidth",
+		Short: "Show the bandwidth used by each renter",
+		Long: `Show the bandwidth used by each renter that has transferred data within the
+last day. Per-renter rate limits can be set with:
+	siac host config maxrenterdownloadspeed 1MB
+	siac host config maxrenteruploadspeed 1MB`,
+		Run: wrap(hostbandwidthcmd),
+	}
+
+	hostContractsCmd = &cobra.Command{
+		Use:   "contracts",
+		Short: "List the host's storage obligations",
+		Long: `List the storage obligations of the host, with the renter, the size of the
+data stored, the expiration height, the potential revenue, the risked
+collateral, and the status of the storage proof of each contract. With
+--format, every field of the obligations is printed as json or csv, for use by
+other programs.`,
+		Run: wrap(hostcontractscmd),
+	}
+
+	hostContractsViewCmd = &cobra.Command{
+		Use:   "view [id]",
+		Short: "Show the details of a storage obligation",
+		Long:  "Show the details of the storage obligation of a single file contract.",
+		Run:   wrap(hostcontractsviewcmd),
+	}
+
+	hostFinancialsCmd = &cobra.Command{
+		Use:   "financials [day|week|month]",
+		Short: "Show the host's revenue and losses by period",
+		Long: `Show the host's revenue, committed collateral, and losses from missed storage
+proofs for each of the most recent days, weeks, or months. Revenue is counted
+in the period in which the storage proof for its contract was submitted.`,
+		Run: wrap(hostfinancialscmd),
+	}
+
+	hostGCCmd = &cobra.Command{
+		Use:   "gc",
+		Short: "Remove the sectors of expired contracts",
+		Long: `Remove the sectors of contracts whose proof window has closed, and show how
+much space was reclaimed in each storage folder. The host does not remove the
+sectors of expired contracts until this command is run.`,
+		Run: wrap(hostgccmd),
+	}
+
+	hostScoreCmd = &cobra.Command{
+		Use:   "score",
+		Short: "Check the host's reachability and preview its score",
+		Long: `Show whether peers have been able to reach the host at its address, and
+preview the score that renters would assign to the host. The score preview
+requires the renter module.`,
+		Run: wrap(hostscorecmd),
+	}
+
+	hostBackupCmd = &cobra.Command{
+		Use:   "backup [destination]",
+		Short: "Back up the host's contracts",
+		Long: `Write a backup of the host's keys, settings, and contracts to the destination
+file. If the disk holding the host's files is lost but the storage folders
+survive, the backup can be restored with 'siac host restore' without losing the
+collateral of the open contracts. The backup contains the host's secret key,
+and should be stored as carefully as a wallet backup.`,
+		Run: wrap(hostbackupcmd),
+	}
+
+	hostRestoreCmd = &cobra.Command{
+		Use:   "restore [source]",
+		Short: "Restore the host's contracts from a backup",
+		Long: `Restore a backup written by 'siac host backup'. The backup is checked and
+staged, and is restored the next time siad starts. A backup can only be
+restored into a host that has no contracts, and the storage folders must be at
+the same paths as when the backup was taken.`,
+		Run: wrap(hostrestorecmd),
+	}
+
 	hostAnnounceCmd = &cobra.Command{
 		Use:   "announce",
 		Short: "Announce yourself as a host",
@@ -64,14 +187,21 @@ You can revert this by running:
 	siac host config acceptingcontracts false
 You may also supply a specific address to be announced, e.g.:
 	siac host announce my-host-domain.com:9001
-Doing so will override the standard connectivity checks.`,
+IPv6 addresses with a port must be enclosed in brackets, e.g.:
+	siac host announce [2001:db8::1]:9982
+If the port is left out, the host's own port is used.
+Doing so will override the standard connectivity checks.
+
+The host re-announces on its own when its automatically determined address
+changes. Announcing a DNS name keeps the announcement valid across changes of
+the host's IP address.`,
 		Run: hostannouncecmd,
 	}
 
 	hostFolderCmd = &cobra.Command{
 		Use:   "folder",
-		Short: "Add, remove, or resize a storage folder",
-		Long:  "Add, remove, or resize a storage folder.",
+		Short: "Add, remove, resize, move, or check a storage folder",
+		Long:  "Add, remove, resize, move, or check a storage folder.",
 	}
 
 	hostFolderAddCmd = &cobra.Command{
@@ -81,6 +211,27 @@ Doing so will override the standard connectivity checks.`,
 		Run:   wrap(hostfolderaddcmd),
 	}
 
+	hostFolderMoveCmd = &cobra.Command{
+		Use:   "move [path] [new path]",
+		Short: "Point a missing storage folder at the new location of its files",
+		Long: `Point a storage folder at the new location of its files, for example after its
+disk was mounted elsewhere. A folder whose files cannot be found when siad starts
+is listed as missing; stop siad before moving the files of a folder. The files
+at the new path are checked before the folder is moved, and the sectors in the
+folder are kept.`,
+		Run: wrap(hostfoldermovecmd),
+	}
+
+	hostFolderPreflightCmd = &cobra.Command{
+		Use:   "preflight [path]",
+		Short: "Check whether a folder can safely be used as a storage folder",
+		Long: `Check whether a folder can safely be used as a storage folder. The check
+measures the write and fsync latency of the folder and verifies that data synced
+to it can be read back. Run it before adding a folder on a network filesystem
+such as NFS or SMB.`,
+		Run: wrap(hostfolderpreflightcmd),
+	}
+
 	hostFolderRemoveCmd = &cobra.Command{
 		Use:   "remove [path]",
 		Short: "Remove a storage folder from the host",
@@ -99,6 +250,16 @@ other storage folders.`,
 		Run: wrap(hostfolderresizecmd),
 	}
 
+	hostFolderTierCmd = &cobra.Command{
+		Use:   "tier [path] [tier]",
+		Short: "Set the placement tier of a storage folder",
+		Long: `Tag a storage folder as 'fast' (e.g. an SSD) or 'capacity' (e.g. an HDD). New
+sectors are written to fast folders while they have room, and sectors that have
+not been read for a few days are moved down to capacity folders. Folders are in
+the capacity tier unless tagged otherwise.`,
+		Run: wrap(hostfoldertiercmd),
+	}
+
 	hostSectorCmd = &cobra.Command{
 		Use:   "sector",
 		Short: "Add or delete a sector (add not supported)",
@@ -171,7 +332,9 @@ func hostcmd() {
 		Add(fm.PotentialUploadBandwidthRevenue)
 	// determine the display method for the net address.
 	netaddr := es.NetAddress
-	if is.NetAddress == "" {
+	if is.NetAddress == "" && is.RelayAddress != "" {
+		netaddr += " (relayed through " + is.RelayAddress + ")"
+	} else if is.NetAddress == "" {
 		netaddr += " (automatically determined)"
 	} else {
 		netaddr += " (manually specified)"
@@ -190,6 +353,24 @@ Host Internal Settings:
 	netaddress:           %v
 	windowsize:           %v Hours
 
+	acceptingrelays: %v
+	relayaddress:    %v
+
+	maxconcurrentdownloads:    %v
+	maxconcurrentnegotiations: %v
+	maxconcurrentrevisions:    %v
+
+	maxrenterdownloadspeed: %v
+	maxrenteruploadspeed:   %v
+
+	mincontractduration:   %v Blocks
+	mincontractsize:       %v
+	maxcontractsize:       %v
+	maxcontractsperrenter: %v
+	maxstorageperrenter:   %v
+	renterwhitelist:       %v
+	renterblacklist:       %v
+
 	collateral:       %v / TB / Month
 	collateralbudget: %v 
 	maxcollateral:    %v Per Contract
@@ -220,6 +401,7 @@ Host Financials:
 RPC Stats:
 	Error Calls:        %v
 	Unrecognized Calls: %v
+	Rejected Calls:     %v
 	Download Calls:     %v
 	Renew Calls:        %v
 	Revise Calls:       %v
@@ -233,6 +415,23 @@ RPC Stats:
 			filesizeUnits(int64(is.MaxReviseBatchSize)), netaddr,
 			is.WindowSize/6,
 
+			yesNo(is.AcceptingRelays), is.RelayAddress,
+
+			concurrencyLimit(is.MaxConcurrentDownloads),
+			concurrencyLimit(is.MaxConcurrentNegotiations),
+			concurrencyLimit(is.MaxConcurrentRevisions),
+
+			speedLimit(is.MaxRenterDownloadSpeed),
+			speedLimit(is.MaxRenterUploadSpeed),
+
+			is.MinContractDuration,
+			sizeLimit(is.MinContractSize),
+			sizeLimit(is.MaxContractSize),
+			concurrencyLimit(is.MaxContractsPerRenter),
+			sizeLimit(is.MaxStoragePerRenter),
+			renterList(is.RenterWhitelist),
+			renterList(is.RenterBlacklist),
+
 			currencyUnits(is.Collateral.Mul(modules.BlockBytesPerMonthTerabyte)),
 			currencyUnits(is.CollateralBudget),
 			currencyUnits(is.MaxCollateral),
@@ -258,7 +457,7 @@ RPC Stats:
 			currencyUnits(fm.UploadBandwidthRevenue),
 			currencyUnits(fm.PotentialUploadBandwidthRevenue),
 
-			nm.ErrorCalls, nm.UnrecognizedCalls, nm.DownloadCalls,
+			nm.ErrorCalls, nm.UnrecognizedCalls, nm.RejectedCalls, nm.DownloadCalls,
 			nm.RenewCalls, nm.ReviseCalls, nm.SettingsCalls,
 			nm.FormContractCalls)
 	} else {
@@ -285,6 +484,15 @@ RPC Stats:
 			currencyUnits(totalRevenue))
 	}
 
+	// describe announcements that are out of date.
+	as := hg.AnnouncementStatus
+	if as.Pending && as.AnnouncedAddress != "" {
+		fmt.Printf("\nThe host announced %v, but is now reachable at %v. The host will re-announce on its own while it is accepting contracts or has contracts.\n", as.AnnouncedAddress, as.CurrentAddress)
+	}
+	if as.Warning != "" {
+		fmt.Println("\nWarning:", as.Warning)
+	}
+
 	fmt.Println("\nStorage Folders:")
 
 	// display storage folder info
@@ -293,24 +501,290 @@ RPC Stats:
 		return
 	}
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
-	fmt.Fprintf(w, "\tUsed\tCapacity\t%% Used\tPath\n")
+	fmt.Fprintf(w, "\tUsed\tCapacity\t%% Used\tTier\tPath\n")
 	for _, folder := range sg.Folders {
 		curSize := int64(folder.Capacity - folder.CapacityRemaining)
 		pctUsed := 100 * (float64(curSize) / float64(folder.Capacity))
-		fmt.Fprintf(w, "\t%s\t%s\t%.2f\t%s\n", filesizeUnits(curSize), filesizeUnits(int64(folder.Capacity)), pctUsed, folder.Path)
+		path := folder.Path
+		if folder.Missing {
+			path += " (missing)"
+		}
+		fmt.Fprintf(w, "\t%s\t%s\t%.2f\t%s\t%s\n", filesizeUnits(curSize), filesizeUnits(int64(folder.Capacity)), pctUsed, folder.Tier, path)
+	}
+	w.Flush()
+}
+
+// hostabusecmd is the handler for the command `siac host abuse`.
+// Lists renters that have recently misbehaved.
+func hostabusecmd() {
+	hag := new(api.HostAbuseGET)
+	err := getAPI("/host/abuse", hag)
+	if err != nil {
+		die("Could not fetch renter abuse records:", err)
+	}
+	if len(hag.Renters) == 0 {
+		fmt.Println("No renters have misbehaved recently.")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
+	fmt.Fprintln(w, "Renter\tAddress\tStatus\tOverride\tContracts\tFailed\tUnderpaid\tReason")
+	for _, r := range hag.Renters {
+		status := r.Status
+		if !r.Until.IsZero() {
+			status += " until " + r.Until.Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n", r.Renter, r.Address, status, r.Override,
+			r.RecentContracts, r.RecentFailedNegotiations, r.RecentUnderpaidDownloads, r.Reason)
+	}
+	w.Flush()
+}
+
+// hostabuseoverridecmd is the handler for the command
+// `siac host abuse override [renter] [allow|ban|clear]`.
+func hostabuseoverridecmd(renter, override string) {
+	if override == "clear" {
+		override = ""
+	}
+	err := post("/host/abuse/override", "renter="+url.QueryEscape(renter)+"&override="+override)
+	if err != nil {
+		die("Could not set abuse override:", err)
+	}
+	if override == "" {
+		fmt.Println("Cleared abuse override for", renter)
+	} else {
+		fmt.Printf("Set abuse override for %v to %v\n", renter, override)
+	}
+}
+
+// hostbandwidthcmd is the handler for the command `siac host bandwidth`.
+func hostbandwidthcmd() {
+	hbg := new(api.HostBandwidthGET)
+	err := getAPI("/host/bandwidth", hbg)
+	if err != nil {
+		die("Could not fetch renter bandwidth:", err)
+	}
+	if len(hbg.Renters) == 0 {
+		fmt.Println("No renters have transferred data recently.")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
+	fmt.Fprintln(w, "Renter\tAddress\tDownloaded\tUploaded\tLast Transfer")
+	for _, r := range hbg.Renters {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", r.Renter, r.Address, filesizeUnits(int64(r.Downloaded)),
+			filesizeUnits(int64(r.Uploaded)), r.LastTransfer.Format("2006-01-02 15:04"))
+	}
+	w.Flush()
+}
+
+// hostcontractscmd is the handler for the command `siac host contracts`.
+func hostcontractscmd() {
+	if hostContractsFormat != "" {
+		resp, err := apiGet("/host/contracts?format=" + url.QueryEscape(hostContractsFormat))
+		if err != nil {
+			die("Could not export storage obligations:", err)
+		}
+		defer resp.Body.Close()
+		_, err = io.Copy(os.Stdout, resp.Body)
+		if err != nil {
+			die("Could not export storage obligations:", err)
+		}
+		return
+	}
+	hcg := new(api.HostContractsGET)
+	err := getAPI("/host/contracts", hcg)
+	if err != nil {
+		die("Could not fetch storage obligations:", err)
+	}
+	if len(hcg.Contracts) == 0 {
+		fmt.Println("The host has no storage obligations.")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
+	fmt.Fprintln(w, "ID\tRenter\tSize\tExpiration\tPotential Revenue\tRisked Collateral\tProof")
+	for _, so := range hcg.Contracts {
+		revenue := so.ContractCost.Add(so.PotentialStorageRevenue).Add(so.PotentialDownloadRevenue).Add(so.PotentialUploadRevenue)
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n", so.ID, so.RenterPublicKey, filesizeUnits(int64(so.DataSize)),
+			so.ExpirationHeight, currencyUnits(revenue), currencyUnits(so.RiskedCollateral), so.ProofStatus)
+	}
+	w.Flush()
+}
+
+// hostcontractsviewcmd is the handler for the command
+// `siac host contracts view [id]`.
+func hostcontractsviewcmd(id string) {
+	hcg := new(api.HostContractGET)
+	err := getAPI("/host/contracts/"+id, hcg)
+	if err != nil {
+		die("Could not fetch storage obligation:", err)
+	}
+	so := hcg.Contract
+	fmt.Printf(`Contract %v
+	Renter:             %v
+	Negotiated:         %v
+	Expiration:         %v
+	Proof Deadline:     %v
+	Data Size:          %v (%v sectors)
+
+	Contract Cost:      %v
+	Storage Revenue:    %v
+	Download Revenue:   %v
+	Upload Revenue:     %v
+	Locked Collateral:  %v
+	Risked Collateral:  %v
+	Transaction Fees:   %v
+
+	Origin Confirmed:   %v
+	Revision Confirmed: %v
+	Proof Status:       %v (%v attempts)
+`, so.ID, so.RenterPublicKey, so.NegotiationHeight, so.ExpirationHeight, so.ProofDeadline,
+		filesizeUnits(int64(so.DataSize)), so.SectorCount,
+		currencyUnits(so.ContractCost), currencyUnits(so.PotentialStorageRevenue),
+		currencyUnits(so.PotentialDownloadRevenue), currencyUnits(so.PotentialUploadRevenue),
+		currencyUnits(so.LockedCollateral), currencyUnits(so.RiskedCollateral),
+		currencyUnits(so.TransactionFeesAdded), yesNo(so.OriginConfirmed), yesNo(so.RevisionConfirmed),
+		so.ProofStatus, so.ProofAttempts)
+}
+
+// hostfinancialscmd is the handler for the command
+// `siac host financials [day|week|month]`.
+func hostfinancialscmd(period string) {
+	hfg := new(api.HostFinancialsGET)
+	err := getAPI(fmt.Sprintf("/host/financials?period=%v&count=%v", url.QueryEscape(period), hostFinancialsCount), hfg)
+	if err != nil {
+		die("Could not fetch financial history:", err)
+	}
+	layout := "2006-01-02"
+	if period == modules.HostFinancialPeriodMonth {
+		layout = "2006-01"
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
+	fmt.Fprintln(w, "Period\tContracts\tProofs\tMissed\tRevenue\tLocked Collateral\tRisked Collateral\tLost Revenue\tLost Collateral")
+	for _, p := range hfg.Periods {
+		revenue := p.ContractCompensation.Add(p.StorageRevenue).Add(p.DownloadBandwidthRevenue).Add(p.UploadBandwidthRevenue)
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n", p.Start.Format(layout),
+			p.ContractsFormed, p.SuccessfulProofs, p.MissedProofs, currencyUnits(revenue),
+			currencyUnits(p.LockedStorageCollateral), currencyUnits(p.RiskedStorageCollateral),
+			currencyUnits(p.LostRevenue), currencyUnits(p.LostStorageCollateral))
+	}
+	w.Flush()
+}
+
+// hostbackupcmd is the handler for the command `siac host backup [destination]`.
+func hostbackupcmd(destination string) {
+	err := post("/host/backup", "destination="+abs(destination))
+	if err != nil {
+		die("Could not back up the host:", err)
+	}
+	fmt.Println("Host backup written to", abs(destination))
+}
+
+// hostrestorecmd is the handler for the command `siac host restore [source]`.
+func hostrestorecmd(source string) {
+	err := post("/host/restore", "source="+abs(source))
+	if err != nil {
+		die("Could not restore the host:", err)
+	}
+	fmt.Println("Host backup staged. Restart siad to restore it.")
+}
+
+// hostgccmd is the handler for the command `siac host gc`.
+func hostgccmd() {
+	var status api.HostGCGET
+	err := getAPI("/host/gc", &status)
+	if err != nil {
+		die("Could not fetch garbage collection status:", err)
+	}
+	if status.CollectableObligations == 0 {
+		fmt.Println("No expired contracts to collect.")
+		if status.WaitingObligations != 0 {
+			fmt.Printf("%v resolved contracts are kept until their proof window closes.\n", status.WaitingObligations)
+		}
+		return
+	}
+	fmt.Printf("Removing %v sectors of %v expired contracts...\n", status.CollectableSectors, status.CollectableObligations)
+
+	var gc api.HostGCPOST
+	err = postResp("/host/gc", "", &gc)
+	if err != nil {
+		die("Could not collect garbage:", err)
+	}
+	fmt.Printf("Released %v sectors of %v contracts, reclaiming %v.\n", gc.SectorsReleased, gc.Obligations, filesizeUnits(int64(gc.BytesReclaimed)))
+	if len(gc.Folders) == 0 {
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
+	fmt.Fprintln(w, "Folder\tSectors\tReclaimed")
+	for _, f := range gc.Folders {
+		fmt.Fprintf(w, "%v\t%v\t%v\n", f.Path, f.Sectors, filesizeUnits(int64(f.Bytes)))
+	}
+	w.Flush()
+}
+
+// hostscorecmd is the handler for the command `siac host score`.
+func hostscorecmd() {
+	var r api.HostReachabilityGET
+	err := getAPI("/host/reachability", &r)
+	if err != nil {
+		die("Could not fetch reachability checks:", err)
+	}
+	fmt.Println("Reachability:")
+	if len(r.History) == 0 {
+		fmt.Println("\tThe host has not been checked by a peer yet.")
+	} else {
+		fmt.Printf("\tAddress:    %v\n", r.Address)
+		fmt.Printf("\tReachable:  %v (checked by %v at %v)\n", yesNo(r.Reachable), r.Prober, r.LastCheck.Format(time.RFC822))
+		if r.LastError != "" {
+			fmt.Printf("\tLast Error: %v\n", r.LastError)
+		}
+		fmt.Printf("\tUptime:     %.2f%% over %v checks\n", r.Uptime*100, len(r.History))
 	}
+
+	var sg api.HostScoreGET
+	err = getAPI("/host/score", &sg)
+	if err != nil {
+		fmt.Println("\nScore preview unavailable:", err)
+		return
+	}
+	sb := sg.ScoreBreakdown
+	fmt.Println("\nScore Preview:")
+	if !sg.InHostDB {
+		fmt.Println("\tThe host is not in the hostdb yet, and is scored as a new host.")
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "\tTotal Score:\t %.0f\n\n", sg.Score*1e12)
+	fmt.Fprintf(w, "\tAge:\t %.3f\n", sb.AgeAdjustment)
+	fmt.Fprintf(w, "\tBurn:\t %.3f\n", sb.BurnAdjustment)
+	fmt.Fprintf(w, "\tCollateral:\t %.3f\n", sb.CollateralAdjustment)
+	fmt.Fprintf(w, "\tPrice:\t %.3f\n", sb.PriceAdjustment*1e6)
+	fmt.Fprintf(w, "\tStorage:\t %.3f\n", sb.StorageRemainingAdjustment)
+	fmt.Fprintf(w, "\tUptime:\t %.3f\n", sb.UptimeAdjustment)
+	fmt.Fprintf(w, "\tVersion:\t %.3f\n", sb.VersionAdjustment)
 	w.Flush()
 }
 
 // hostconfigcmd is the handler for the command `siac host config [setting] [value]`.
 // Modifies host settings.
 func hostconfigcmd(param, value string) {
+	value, err := parseHostSetting(param, value)
+	if err != nil {
+		die(err)
+	}
+	err = post("/host", param+"="+value)
+	if err != nil {
+		die("Could not update host settings:", err)
+	}
+	fmt.Println("Host settings updated.")
+}
+
+// parseHostSetting checks that param is a host setting and converts value into
+// the units expected by the API.
+func parseHostSetting(param, value string) (string, error) {
 	switch param {
 	// currency (convert to hastings)
 	case "collateralbudget", "maxcollateral", "mincontractprice":
 		hastings, err := parseCurrency(value)
 		if err != nil {
-			die("Could not parse "+param+":", err)
+			return "", fmt.Errorf("could not parse %v: %v", param, err)
 		}
 		value = hastings
 
@@ -318,7 +792,7 @@ func hostconfigcmd(param, value string) {
 	case "mindownloadbandwidthprice", "minuploadbandwidthprice":
 		hastings, err := parseCurrency(value)
 		if err != nil {
-			die("Could not parse "+param+":", err)
+			return "", fmt.Errorf("could not parse %v: %v", param, err)
 		}
 		i, _ := new(big.Int).SetString(hastings, 10)
 		c := types.NewCurrency(i).Div(modules.BytesPerTerabyte)
@@ -328,25 +802,40 @@ func hostconfigcmd(param, value string) {
 	case "collateral", "minstorageprice":
 		hastings, err := parseCurrency(value)
 		if err != nil {
-			die("Could not parse "+param+":", err)
+			return "", fmt.Errorf("could not parse %v: %v", param, err)
 		}
 		i, _ := new(big.Int).SetString(hastings, 10)
 		c := types.NewCurrency(i).Div(modules.BlockBytesPerMonthTerabyte)
 		value = c.String()
 
+	// bytes per second, which may be given with units
+	case "maxrenterdownloadspeed", "maxrenteruploadspeed":
+		bytes, err := parseFilesize(value)
+		if err != nil {
+			return "", fmt.Errorf("could not parse %v: %v", param, err)
+		}
+		value = bytes
+
+	// bytes, which may be given with units
+	case "mincontractsize", "maxcontractsize", "maxstorageperrenter":
+		bytes, err := parseFilesize(value)
+		if err != nil {
+			return "", fmt.Errorf("could not parse %v: %v", param, err)
+		}
+		value = bytes
+
 	// other valid settings
 	case "acceptingcontracts", "maxdownloadbatchsize", "maxduration",
-		"maxrevisebatchsize", "netaddress", "windowsize":
+		"maxrevisebatchsize", "netaddress", "windowsize",
+		"acceptingrelays", "relayaddress",
+		"maxconcurrentdownloads", "maxconcurrentnegotiations", "maxconcurrentrevisions",
+		"mincontractduration", "maxcontractsperrenter", "renterwhitelist", "renterblacklist":
 
 	// invalid settings
 	default:
-		die("\"" + param + "\" is not a host setting")
-	}
-	err := post("/host", param+"="+value)
-	if err != nil {
-		die("Could not update host settings:", err)
+		return "", fmt.Errorf("%q is not a host setting", param)
 	}
-	fmt.Println("Host settings updated.")
+	return value, nil
 }
 
 // hostannouncecmd is the handler for the command `siac host announce`.
@@ -358,7 +847,7 @@ func hostannouncecmd(cmd *cobra.Command, args []string) {
 	case 0:
 		err = post("/host/announce", "")
 	case 1:
-		err = post("/host/announce", "netaddress="+args[0])
+		err = post("/host/announce", "netaddress="+url.QueryEscape(args[0]))
 	default:
 		cmd.UsageFunc()(cmd)
 		os.Exit(exitCodeUsage)
@@ -393,6 +882,35 @@ func hostfolderaddcmd(path, size string) {
 	fmt.Println("Added folder", path)
 }
 
+// hostfoldermovecmd points a missing storage folder at a new path.
+func hostfoldermovecmd(path, newpath string) {
+	err := post("/host/storage/folders/move", fmt.Sprintf("path=%s&newpath=%s", abs(path), abs(newpath)))
+	if err != nil {
+		die("Could not move folder:", err)
+	}
+	fmt.Printf("Moved folder %v to %v\n", path, newpath)
+}
+
+// hostfolderpreflightcmd checks whether a folder can safely be added to the
+// host.
+func hostfolderpreflightcmd(path string) {
+	var sfp modules.StorageFolderPreflight
+	err := getAPI("/host/storage/folders/preflight?path="+url.QueryEscape(abs(path)), &sfp)
+	if err != nil {
+		die("Could not check folder:", err)
+	}
+	fmt.Printf(`Folder:        %v
+Filesystem:    %v (network: %v)
+Write latency: %v
+Sync latency:  %v
+Write barrier: %v
+In use:        %v
+`, sfp.Path, sfp.Filesystem, yesNo(sfp.NetworkFilesystem), sfp.WriteLatency, sfp.SyncLatency, yesNo(sfp.WriteBarrier), yesNo(sfp.InUse))
+	for _, warning := range sfp.Warnings {
+		fmt.Println("Warning:", warning)
+	}
+}
+
 // hostfolderremovecmd removes a folder from the host.
 func hostfolderremovecmd(path string) {
 	err := post("/host/storage/folders/remove", "path="+abs(path))
@@ -415,6 +933,15 @@ func hostfolderresizecmd(path, newsize string) {
 	fmt.Printf("Resized folder %v to %v\n", path, newsize)
 }
 
+// hostfoldertiercmd sets the placement tier of a storage folder.
+func hostfoldertiercmd(path, tier string) {
+	err := post("/host/storage/folders/tier", fmt.Sprintf("path=%s&tier=%s", abs(path), tier))
+	if err != nil {
+		die("Could not set folder tier:", err)
+	}
+	fmt.Printf("Moved folder %v to the %v tier\n", path, tier)
+}
+
 // hostsectordeletecmd deletes a sector from the host.
 func hostsectordeletecmd(root string) {
 	err := post("/host/storage/sectors/delete/"+root, "")
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"os"
 	"reflect"
+	"strings"
 
 	"github.com/bgentry/speakeasy"
 	"github.com/spf13/cobra"
@@ -18,11 +19,22 @@ import (
 
 var (
 	// Flags.
-	addr              string // override default API address
-	initPassword      bool   // supply a custom password when creating a wallet
-	hostVerbose       bool   // display additional host info
-	renterShowHistory bool   // Show download history in addition to download queue.
-	renterListVerbose bool   // Show additional info about uploaded files.
+	addr                 string // override default API address
+	initPassword         bool   // supply a custom password when creating a wallet
+	hostVerbose          bool   // display additional host info
+	hostContractsFormat  string // Machine-readable format of host contracts.
+	hostFinancialsCount  int    // Number of periods shown by host financials.
+	renterShowHistory    bool   // Show download history in addition to download queue.
+	renterDownloadOffset uint64 // Offset of the first byte of a partial download.
+	renterDownloadLength uint64 // Number of bytes of a partial download.
+	renterListVerbose    bool   // Show additional info about uploaded files.
+	renterDataPieces     int    // Number of data pieces of an uploaded file.
+	renterParityPieces   int    // Number of parity pieces of an uploaded file.
+	utilsGenesisSiacoins string // Siacoins allocated by a private network's genesis block.
+	walletAddressUsage   bool   // Show usage statistics for each address.
+	walletApprovalWindow string // Window over which unapproved sends are added up.
+	walletBackupCrypt    bool   // Encrypt a wallet backup with a password.
+	walletCheckDBCompact bool   // Compact the wallet database after checking it.
 
 	// Globals.
 	rootCmd *cobra.Command // Root command cobra object, used by bash completion cmd.
@@ -53,6 +65,16 @@ func decodeError(resp *http.Response) error {
 	return apiErr
 }
 
+// passwordPrompt returns the prompt for the password that a request refused
+// with 401 Unauthorized should be retried with. Calls that approve wallet
+// sends require the approval password rather than the API password.
+func passwordPrompt(resp *http.Response) string {
+	if strings.Contains(resp.Header.Get("WWW-Authenticate"), api.ApprovalRealm) {
+		return "Approval password: "
+	}
+	return "API password: "
+}
+
 // apiGet wraps a GET request with a status code check, such that if the GET does
 // not return 2xx, the error will be read and returned. The response body is
 // not closed.
@@ -68,7 +90,7 @@ func apiGet(call string) (*http.Response, error) {
 	if resp.StatusCode == http.StatusUnauthorized {
 		resp.Body.Close()
 		// Prompt for password and retry request with authentication.
-		password, err := speakeasy.Ask("API password: ")
+		password, err := speakeasy.Ask(passwordPrompt(resp))
 		if err != nil {
 			return nil, err
 		}
@@ -136,7 +158,7 @@ func apiPost(call, vals string) (*http.Response, error) {
 	if resp.StatusCode == http.StatusUnauthorized {
 		resp.Body.Close()
 		// Prompt for password and retry request with authentication.
-		password, err := speakeasy.Ask("API password: ")
+		password, err := speakeasy.Ask(passwordPrompt(resp))
 		if err != nil {
 			return nil, err
 		}
@@ -236,14 +258,24 @@ func main() {
 	root.AddCommand(versionCmd)
 	root.AddCommand(stopCmd)
 
+	root.AddCommand(bandwidthCmd)
+	bandwidthCmd.AddCommand(bandwidthLimitCmd)
+
+	root.AddCommand(featuresCmd)
+	featuresCmd.AddCommand(featuresSetCmd)
+
 	root.AddCommand(updateCmd)
 	updateCmd.AddCommand(updateCheckCmd)
 
 	root.AddCommand(hostCmd)
-	hostCmd.AddCommand(hostConfigCmd, hostAnnounceCmd, hostFolderCmd, hostSectorCmd)
-	hostFolderCmd.AddCommand(hostFolderAddCmd, hostFolderRemoveCmd, hostFolderResizeCmd)
+	hostCmd.AddCommand(hostConfigCmd, hostAnnounceCmd, hostAbuseCmd, hostBandwidthCmd, hostContractsCmd, hostFinancialsCmd, hostGCCmd, hostScoreCmd, hostBackupCmd, hostRestoreCmd, hostFolderCmd, hostSectorCmd)
+	hostAbuseCmd.AddCommand(hostAbuseOverrideCmd)
+	hostContractsCmd.AddCommand(hostContractsViewCmd)
+	hostFolderCmd.AddCommand(hostFolderAddCmd, hostFolderMoveCmd, hostFolderPreflightCmd, hostFolderRemoveCmd, hostFolderResizeCmd, hostFolderTierCmd)
 	hostSectorCmd.AddCommand(hostSectorDeleteCmd)
 	hostCmd.Flags().BoolVarP(&hostVerbose, "verbose", "v", false, "Display detailed host info")
+	hostContractsCmd.Flags().StringVarP(&hostContractsFormat, "format", "f", "", "Print every field of the contracts as json or csv")
+	hostFinancialsCmd.Flags().IntVarP(&hostFinancialsCount, "count", "n", 12, "Number of periods to display, ending with the current period")
 
 	root.AddCommand(hostdbCmd)
 	hostdbCmd.AddCommand(hostdbViewCmd)
@@ -254,29 +286,49 @@ func main() {
 	minerCmd.AddCommand(minerStartCmd, minerStopCmd)
 
 	root.AddCommand(walletCmd)
-	walletCmd.AddCommand(walletAddressCmd, walletAddressesCmd, walletInitCmd, walletInitSeedCmd,
-		walletLoadCmd, walletLockCmd, walletSeedsCmd, walletSendCmd, walletSweepCmd,
-		walletBalanceCmd, walletTransactionsCmd, walletUnlockCmd)
+	walletCmd.AddCommand(walletAddressCmd, walletAddressesCmd, walletApprovalsCmd, walletApprovalPolicyCmd, walletApproveCmd, walletBackupCmd, walletChangePasswordCmd, walletCheckDBCmd, walletDecryptBackupCmd,
+		walletInitCmd, walletInitSeedCmd, walletLoadCmd, walletLockCmd, walletLockTimeoutCmd, walletSeedsCmd, walletSendCmd, walletSweepCmd,
+		walletBalanceCmd, walletTransactionsCmd, walletUnlockCmd, walletExtendCmd, walletRejectCmd)
 	walletInitCmd.Flags().BoolVarP(&initPassword, "password", "p", false, "Prompt for a custom password")
+	walletAddressesCmd.Flags().BoolVarP(&walletAddressUsage, "usage", "u", false, "Show the index, transaction count, amount received, and balance of each address")
+	walletBackupCmd.Flags().BoolVarP(&walletBackupCrypt, "encrypt", "e", false, "Prompt for a password and encrypt the backup with it")
+	walletApprovalPolicyCmd.Flags().StringVarP(&walletApprovalWindow, "window", "w", "", "Cap the total sent without approval within this window, e.g. 24h")
+	walletCheckDBCmd.Flags().BoolVarP(&walletCheckDBCompact, "compact", "c", false, "Compact the wallet database after checking it")
 	walletLoadCmd.AddCommand(walletLoad033xCmd, walletLoadSeedCmd, walletLoadSiagCmd)
 	walletSendCmd.AddCommand(walletSendSiacoinsCmd, walletSendSiafundsCmd)
 
 	root.AddCommand(renterCmd)
 	renterCmd.AddCommand(renterFilesDeleteCmd, renterFilesDownloadCmd,
 		renterDownloadsCmd, renterAllowanceCmd, renterSetAllowanceCmd,
-		renterContractsCmd, renterFilesListCmd, renterFilesRenameCmd,
+		renterContractsCmd, renterFilesListCmd, renterFilesLoadCmd, renterFilesRenameCmd,
 		renterFilesUploadCmd, renterUploadsCmd, renterExportCmd,
-		renterPricesCmd)
+		renterPricesCmd, renterPauseCmd, renterResumeCmd, renterVerifyManifestCmd,
+		renterGCCmd, renterHandoffCmd, renterHealthCmd)
 	renterCmd.Flags().BoolVarP(&renterListVerbose, "verbose", "v", false, "Show additional file info such as redundancy")
+	renterFilesDownloadCmd.Flags().Uint64Var(&renterDownloadOffset, "offset", 0, "Offset of the first byte to download (requires --length)")
+	renterFilesDownloadCmd.Flags().Uint64Var(&renterDownloadLength, "length", 0, "Number of bytes to download, starting at --offset")
 	renterDownloadsCmd.Flags().BoolVarP(&renterShowHistory, "history", "H", false, "Show download history in addition to the download queue")
 	renterFilesListCmd.Flags().BoolVarP(&renterListVerbose, "verbose", "v", false, "Show additional file info such as redundancy")
-	renterExportCmd.AddCommand(renterExportContractTxnsCmd)
+	renterFilesUploadCmd.Flags().IntVar(&renterDataPieces, "data-pieces", 0, "Number of data pieces to erasure code the file with (requires --parity-pieces)")
+	renterFilesUploadCmd.Flags().IntVar(&renterParityPieces, "parity-pieces", 0, "Number of parity pieces to erasure code the file with (requires --data-pieces)")
+	renterExportCmd.AddCommand(renterExportContractTxnsCmd, renterExportFilesCmd, renterExportManifestCmd)
 
 	root.AddCommand(gatewayCmd)
-	gatewayCmd.AddCommand(gatewayConnectCmd, gatewayDisconnectCmd, gatewayAddressCmd, gatewayListCmd)
+	gatewayCmd.AddCommand(gatewayConnectCmd, gatewayDisconnectCmd, gatewayForgetKeyCmd, gatewayAddressCmd, gatewayAnchorsCmd, gatewayBlacklistCmd, gatewayLimitsCmd, gatewayListCmd, gatewayOfflineCmd, gatewayOnlineCmd, gatewayRateLimitCmd, gatewayRPCTimeoutsCmd, gatewayWhitelistCmd)
+	gatewayAnchorsCmd.AddCommand(gatewayAnchorsAddCmd, gatewayAnchorsRemoveCmd)
+	gatewayBlacklistCmd.AddCommand(gatewayBlacklistAddCmd, gatewayBlacklistRemoveCmd)
+	gatewayWhitelistCmd.AddCommand(gatewayWhitelistAddCmd, gatewayWhitelistRemoveCmd)
+	gatewayLimitsCmd.AddCommand(gatewayLimitsSetCmd)
+	gatewayRPCTimeoutsCmd.AddCommand(gatewayRPCTimeoutsSetCmd)
 
 	root.AddCommand(consensusCmd)
 
+	root.AddCommand(batchCmd)
+
+	root.AddCommand(utilsCmd)
+	utilsCmd.AddCommand(utilsGenGenesisCmd)
+	utilsGenGenesisCmd.Flags().StringVarP(&utilsGenesisSiacoins, "siacoins", "", "", "Siacoins given to the address by the genesis block, e.g. 1000000SC")
+
 	root.AddCommand(bashcomplCmd)
 
 	// parse flags
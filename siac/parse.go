@@ -141,3 +141,38 @@ func yesNo(b bool) string {
 	}
 	return "No"
 }
+
+// speedLimit converts a rate limit in bytes per second to a human-readable
+// string, where zero means that there is no limit.
+func speedLimit(n uint64) string {
+	if n == 0 {
+		return "No limit"
+	}
+	return filesizeUnits(int64(n)) + "/s"
+}
+
+// sizeLimit converts a limit in bytes to a human-readable string, where zero
+// means that there is no limit.
+func sizeLimit(n uint64) string {
+	if n == 0 {
+		return "No limit"
+	}
+	return filesizeUnits(int64(n))
+}
+
+// renterList converts a list of renters to a human-readable string.
+func renterList(renters []string) string {
+	if len(renters) == 0 {
+		return "None"
+	}
+	return strings.Join(renters, ", ")
+}
+
+// concurrencyLimit converts a concurrency limit to a human-readable string,
+// where zero means that there is no limit.
+func concurrencyLimit(n uint64) string {
+	if n == 0 {
+		return "No limit"
+	}
+	return fmt.Sprint(n)
+}
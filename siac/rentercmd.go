@@ -1,7 +1,10 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"math/big"
+	"net/url"
 	"os"
 	"path/filepath"
 	"sort"
@@ -12,6 +15,7 @@ import (
 
 	"github.com/NebulousLabs/Sia/api"
 	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
 )
 
 var (
@@ -73,8 +77,10 @@ have a reasonable number (>30) of hosts in your hostdb.`,
 	renterFilesDownloadCmd = &cobra.Command{
 		Use:   "download [path] [destination]",
 		Short: "Download a file",
-		Long:  "Download a previously-uploaded file to a specified destination.",
-		Run:   wrap(renterfilesdownloadcmd),
+		Long: "Download a previously-uploaded file to a specified destination. Use " +
+			"--offset and --length to download only part of the file; only the chunks " +
+			"covering that part are fetched from hosts.",
+		Run: wrap(renterfilesdownloadcmd),
 	}
 
 	renterFilesListCmd = &cobra.Command{
@@ -85,6 +91,14 @@ have a reasonable number (>30) of hosts in your hostdb.`,
 		Run:     wrap(renterfileslistcmd),
 	}
 
+	renterFilesLoadCmd = &cobra.Command{
+		Use:   "load [source]",
+		Short: "Load files from a .sia file",
+		Long: "Load the files listed in a .sia file exported by another renter. Contracts " +
+			"are formed with the hosts of the files that the renter has no contract with.",
+		Run: wrap(renterfilesloadcmd),
+	}
+
 	renterFilesRenameCmd = &cobra.Command{
 		Use:     "rename [path] [newpath]",
 		Aliases: []string{"mv"},
@@ -96,8 +110,45 @@ have a reasonable number (>30) of hosts in your hostdb.`,
 	renterFilesUploadCmd = &cobra.Command{
 		Use:   "upload [source] [path]",
 		Short: "Upload a file",
-		Long:  "Upload a file to [path] on the Sia network.",
-		Run:   wrap(renterfilesuploadcmd),
+		Long: "Upload a file to [path] on the Sia network. By default the file is erasure " +
+			"coded into 10 data pieces and 20 parity pieces per chunk; use --data-pieces " +
+			"and --parity-pieces to choose different parameters for this file.",
+		Run: wrap(renterfilesuploadcmd),
+	}
+
+	renterPauseCmd = &cobra.Command{
+		Use:   "pause [duration]",
+		Short: "Temporarily halt all renter activity",
+		Long: `Halt uploads, downloads, repairs, and contract formation for the given
+duration, e.g. "90m" or "2h". Activity resumes automatically once the duration
+has elapsed. Contracts that enter their renew window are still renewed.`,
+		Run: wrap(renterpausecmd),
+	}
+
+	renterResumeCmd = &cobra.Command{
+		Use:   "resume",
+		Short: "Resume renter activity",
+		Long:  "Resume renter activity that was halted by 'siac renter pause'.",
+		Run:   wrap(renterresumecmd),
+	}
+
+	renterGCCmd = &cobra.Command{
+		Use:   "gc",
+		Short: "Remove stale and orphaned renter metadata",
+		Long: `List the contracts referenced by files that have expired without being
+renewed, files that can no longer be downloaded or repaired, and orphaned
+metadata in the renter directory. After confirmation, the listed metadata is
+removed and unrecoverable files are deleted from the renter.`,
+		Run: wrap(rentergccmd),
+	}
+
+	renterHandoffCmd = &cobra.Command{
+		Use:   "handoff [from] [to]",
+		Short: "Move data from a retiring host to another host",
+		Long: `Ask the retiring host at [from] to transfer the renter's data directly to the
+host at [to], then re-point the renter's files at [to] instead of repairing
+them. The renter must have a contract with both hosts.`,
+		Run: wrap(renterhandoffcmd),
 	}
 
 	renterPricesCmd = &cobra.Command{
@@ -106,6 +157,24 @@ have a reasonable number (>30) of hosts in your hostdb.`,
 		Long:  "Display the estimated prices of storing files, retrieving files, and creating a set of contracts",
 		Run:   wrap(renterpricescmd),
 	}
+
+	renterHealthCmd = &cobra.Command{
+		Use:   "health",
+		Short: "Report the health of the renter",
+		Long: `Check the redundancy of the renter's files, its contracts against the
+allowance, the runway of the wallet balance, and the number of hosts in the
+hostdb. Prints a score out of 100 and recommendations for any problems found.`,
+		Run: wrap(renterhealthcmd),
+	}
+
+	renterVerifyManifestCmd = &cobra.Command{
+		Use:   "verify-manifest [manifest]",
+		Short: "Check the renter against an exported manifest",
+		Long: `Check that a manifest exported by 'siac renter export manifest' has a valid
+signature, was signed by this renter, and that every file it lists is still
+known to the renter with the same size and content hash.`,
+		Run: wrap(renterverifymanifestcmd),
+	}
 )
 
 // abs returns the absolute representation of a path.
@@ -139,11 +208,105 @@ func rentercmd() {
 `, currencyUnits(fm.StorageSpending), currencyUnits(fm.UploadSpending),
 		currencyUnits(fm.DownloadSpending), currencyUnits(unspent),
 		currencyUnits(fm.ContractSpending))
+	if rg.Paused {
+		fmt.Printf("Renter activity is paused until %v.\n\n", rg.PausedUntil.Format(time.RFC1123))
+	}
 
 	// also list files
 	renterfileslistcmd()
 }
 
+// printGarbage prints the renter metadata listed in g, and returns the number
+// of entries.
+func printGarbage(g modules.RenterGarbage) int {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, sc := range g.StaleContracts {
+		fmt.Fprintf(w, "Stale contract:\t%v\t%v\t%v pieces, expired at height %v\n", sc.SiaPath, sc.NetAddress, sc.Pieces, sc.WindowStart)
+	}
+	for _, op := range g.OrphanedPieces {
+		fmt.Fprintf(w, "Orphaned pieces:\t%v\t%v pieces\n", op.SiaPath, op.Pieces)
+	}
+	for _, path := range g.UnrecoverableFiles {
+		fmt.Fprintf(w, "Unrecoverable file:\t%v\n", path)
+	}
+	for _, path := range g.OrphanedShareFiles {
+		fmt.Fprintf(w, "Orphaned .sia file:\t%v\n", path)
+	}
+	for _, path := range g.OrphanedTracking {
+		fmt.Fprintf(w, "Orphaned tracking entry:\t%v\n", path)
+	}
+	w.Flush()
+	return len(g.StaleContracts) + len(g.OrphanedPieces) + len(g.UnrecoverableFiles) + len(g.OrphanedShareFiles) + len(g.OrphanedTracking)
+}
+
+// rentergccmd is the handler for the command `siac renter gc`. Lists stale
+// and orphaned renter metadata, and removes it after confirmation.
+func rentergccmd() {
+	var g modules.RenterGarbage
+	err := getAPI("/renter/gc", &g)
+	if err != nil {
+		die("Could not get renter garbage:", err)
+	}
+	if printGarbage(g) == 0 {
+		fmt.Println("No stale or orphaned renter metadata found.")
+		return
+	}
+	if len(g.UnrecoverableFiles) > 0 {
+		fmt.Printf("%v unrecoverable files will be deleted from the renter.\n", len(g.UnrecoverableFiles))
+	}
+	fmt.Print("Remove the metadata listed above? [y/N] ")
+	var answer string
+	fmt.Scanln(&answer)
+	if answer != "y" && answer != "Y" {
+		fmt.Println("Nothing was removed.")
+		return
+	}
+
+	confirmed, err := json.Marshal(g)
+	if err != nil {
+		die("Could not encode renter garbage:", err)
+	}
+	err = postResp("/renter/gc", "garbage="+url.QueryEscape(string(confirmed)), &g)
+	if err != nil {
+		die("Could not collect renter garbage:", err)
+	}
+	fmt.Printf("Removed %v stale contracts and %v unrecoverable files.\n", len(g.StaleContracts), len(g.UnrecoverableFiles))
+}
+
+// renterhandoffcmd is the handler for the command `siac renter handoff
+// [from] [to]`. Hands off the renter's data from a retiring host.
+func renterhandoffcmd(from, to string) {
+	var record modules.HandoffRecord
+	err := postResp("/renter/handoff", "from="+from+"&to="+to, &record)
+	if err != nil {
+		die("Could not hand off data:", err)
+	}
+	fmt.Printf("%v handed off %v sectors to %v at height %v.\n", from, len(record.Authorization.SectorRoots), to, record.Height)
+}
+
+// renterpausecmd is the handler for the command `siac renter pause
+// [duration]`. Halts all renter activity for the given duration.
+func renterpausecmd(duration string) {
+	if _, err := time.ParseDuration(duration); err != nil {
+		die("Could not parse duration:", err)
+	}
+	err := post("/renter/pause", "duration="+duration)
+	if err != nil {
+		die("Could not pause the renter:", err)
+	}
+	fmt.Println("Renter activity paused for", duration)
+}
+
+// renterresumecmd is the handler for the command `siac renter resume`.
+// Resumes renter activity halted by `siac renter pause`.
+func renterresumecmd() {
+	err := post("/renter/resume", "")
+	if err != nil {
+		die("Could not resume the renter:", err)
+	}
+	fmt.Println("Renter activity resumed")
+}
+
 // renteruploadscmd is the handler for the command `siac renter uploads`.
 // Lists files currently uploading.
 func renteruploadscmd() {
@@ -187,7 +350,7 @@ func renterdownloadscmd() {
 	// Filter out files that have been downloaded.
 	var downloading []modules.DownloadInfo
 	for _, file := range queue.Downloads {
-		if file.Received != file.Filesize {
+		if file.Received != file.Length {
 			downloading = append(downloading, file)
 		}
 	}
@@ -196,7 +359,7 @@ func renterdownloadscmd() {
 	} else {
 		fmt.Println("Downloading", len(downloading), "files:")
 		for _, file := range downloading {
-			fmt.Printf("%s: %5.1f%% %s -> %s\n", file.StartTime.Format("Jan 02 03:04 PM"), 100*float64(file.Received)/float64(file.Filesize), file.SiaPath, file.Destination)
+			fmt.Printf("%s: %5.1f%% %s -> %s\n", file.StartTime.Format("Jan 02 03:04 PM"), 100*float64(file.Received)/float64(file.Length), file.SiaPath, file.Destination)
 		}
 	}
 	if !renterShowHistory {
@@ -206,7 +369,7 @@ func renterdownloadscmd() {
 	// Filter out files that are downloading.
 	var downloaded []modules.DownloadInfo
 	for _, file := range queue.Downloads {
-		if file.Received == file.Filesize {
+		if file.Received == file.Length {
 			downloaded = append(downloaded, file)
 		}
 	}
@@ -332,16 +495,20 @@ func renterfilesdownloadcmd(path, destination string) {
 				if d.Filesize == 0 {
 					continue // file hasn't appeared in queue yet
 				}
-				pct := 100 * float64(d.Received) / float64(d.Filesize)
+				pct := 100 * float64(d.Received) / float64(d.Length)
 				elapsed := time.Since(d.StartTime)
 				elapsed -= elapsed % time.Second // round to nearest second
 				mbps := (float64(d.Received*8) / 1e6) / time.Since(d.StartTime).Seconds()
-				fmt.Printf("\rDownloading... %5.1f%% of %v, %v elapsed, %.2f Mbps    ", pct, filesizeUnits(int64(d.Filesize)), elapsed, mbps)
+				fmt.Printf("\rDownloading... %5.1f%% of %v, %v elapsed, %.2f Mbps    ", pct, filesizeUnits(int64(d.Length)), elapsed, mbps)
 			}
 		}
 	}()
 
-	err := get("/renter/download/" + path + "?destination=" + destination)
+	query := "?destination=" + destination
+	if renterDownloadLength != 0 {
+		query += fmt.Sprintf("&offset=%v&length=%v", renterDownloadOffset, renterDownloadLength)
+	}
+	err := get("/renter/download/" + path + query)
 	close(done)
 	if err != nil {
 		die("Could not download file:", err)
@@ -372,7 +539,7 @@ func renterfileslistcmd() {
 	fmt.Println("Tracking", len(rf.Files), "files:")
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 	if renterListVerbose {
-		fmt.Fprintln(w, "File size\tAvailable\tProgress\tRedundancy\tRenewing\tSia path")
+		fmt.Fprintln(w, "File size\tAvailable\tProgress\tRedundancy\tPieces\tRenewing\tSia path")
 	}
 	sort.Sort(bySiaPath(rf.Files))
 	for _, file := range rf.Files {
@@ -388,7 +555,8 @@ func renterfileslistcmd() {
 			if file.UploadProgress == -1 {
 				uploadProgressStr = "-"
 			}
-			fmt.Fprintf(w, "\t%s\t%8s\t%10s\t%s", availableStr, uploadProgressStr, redundancyStr, renewingStr)
+			piecesStr := fmt.Sprintf("%v-of-%v", file.DataPieces, file.DataPieces+file.ParityPieces)
+			fmt.Fprintf(w, "\t%s\t%8s\t%10s\t%s\t%s", availableStr, uploadProgressStr, redundancyStr, piecesStr, renewingStr)
 		}
 		fmt.Fprintf(w, "\t%s", file.SiaPath)
 		if !renterListVerbose && !file.Available {
@@ -409,10 +577,27 @@ func renterfilesrenamecmd(path, newpath string) {
 	fmt.Printf("Renamed %s to %s\n", path, newpath)
 }
 
+// renterfilesloadcmd is the handler for the command `siac renter load [source]`.
+// Loads the files of a shared .sia file into the renter.
+func renterfilesloadcmd(source string) {
+	var rl api.RenterLoad
+	err := postResp("/renter/load", "source="+url.QueryEscape(abs(source)), &rl)
+	if err != nil {
+		die("Could not load files:", err)
+	}
+	for _, path := range rl.FilesAdded {
+		fmt.Println("Loaded", path)
+	}
+}
+
 // renterfilesuploadcmd is the handler for the command `siac renter upload [source] [path]`.
 // Uploads the [source] file to [path] on the Sia network.
 func renterfilesuploadcmd(source, path string) {
-	err := post("/renter/upload/"+path, "source="+abs(source))
+	vals := "source=" + abs(source)
+	if renterDataPieces != 0 || renterParityPieces != 0 {
+		vals += fmt.Sprintf("&datapieces=%v&paritypieces=%v", renterDataPieces, renterParityPieces)
+	}
+	err := post("/renter/upload/"+path, vals)
 	if err != nil {
 		die("Could not upload file:", err)
 	}
@@ -436,3 +621,271 @@ func renterpricescmd() {
 	fmt.Fprintln(w, "\tUpload 1 TB:\t", currencyUnits(rpg.UploadTerabyte))
 	w.Flush()
 }
+
+// compareManifests compares a previously exported manifest against the
+// renter's current manifest. It returns the paths of files that are missing
+// from the current manifest, and of files whose size or content hash differ.
+func compareManifests(saved, current modules.RenterManifest) (missing, changed []string) {
+	files := make(map[string]modules.RenterManifestFile, len(current.Files))
+	for _, f := range current.Files {
+		files[f.SiaPath] = f
+	}
+	for _, f := range saved.Files {
+		cur, ok := files[f.SiaPath]
+		if !ok {
+			missing = append(missing, f.SiaPath)
+		} else if cur.Filesize != f.Filesize || cur.ContentHash != f.ContentHash {
+			changed = append(changed, f.SiaPath)
+		}
+	}
+	return missing, changed
+}
+
+// renterverifymanifestcmd is the handler for the command `siac renter
+// verify-manifest [manifest]`. Checks the renter against an exported manifest.
+func renterverifymanifestcmd(filename string) {
+	f, err := os.Open(abs(filename))
+	if err != nil {
+		die("Could not open manifest:", err)
+	}
+	var saved modules.RenterManifest
+	err = json.NewDecoder(f).Decode(&saved)
+	f.Close()
+	if err != nil {
+		die("Could not parse manifest:", err)
+	}
+	if err := saved.VerifySignature(); err != nil {
+		die("Could not verify manifest:", err)
+	}
+
+	var current modules.RenterManifest
+	err = getAPI("/renter/manifest", &current)
+	if err != nil {
+		die("Could not retrieve manifest:", err)
+	}
+	if saved.PublicKey.String() != current.PublicKey.String() {
+		die("Manifest was not signed by this renter. Manifest key:", saved.PublicKey.String(), "renter key:", current.PublicKey.String())
+	}
+
+	missing, changed := compareManifests(saved, current)
+	for _, path := range missing {
+		fmt.Println("Missing:", path)
+	}
+	for _, path := range changed {
+		fmt.Println("Changed:", path)
+	}
+	if len(missing) > 0 || len(changed) > 0 {
+		die(fmt.Sprintf("%v of %v files in the manifest are missing or changed", len(missing)+len(changed), len(saved.Files)))
+	}
+	fmt.Printf("All %v files in the manifest (created at height %v) are present and unchanged.\n", len(saved.Files), saved.Height)
+}
+
+// renterHealthRedundancy is the redundancy below which `siac renter health`
+// reports a file as at risk.
+const renterHealthRedundancy = 1.5
+
+type (
+	// A renterHealthCheck is the outcome of one of the checks made by `siac
+	// renter health`. Penalty is subtracted from the score of the report, and
+	// Recommendation is empty if the check found no problem.
+	renterHealthCheck struct {
+		Name           string
+		Status         string
+		Penalty        int
+		Recommendation string
+	}
+
+	// A renterHealthReport scores the health of the renter out of 100.
+	renterHealthReport struct {
+		Score  int
+		Checks []renterHealthCheck
+	}
+)
+
+// renterHealth aggregates the renter's settings, files, contracts, wallet,
+// and active hosts into a scored report.
+func renterHealth(rg api.RenterGET, files []modules.FileInfo, contracts []api.RenterContract, wg api.WalletGET, activeHosts int) renterHealthReport {
+	allowance := rg.Settings.Allowance
+	var checks []renterHealthCheck
+
+	// Files. Files that are still uploading are expected to have a low
+	// redundancy, so only finished uploads are counted.
+	var uploading, unavailable, lowRedundancy, notRenewing int
+	for _, f := range files {
+		switch {
+		case f.UploadProgress < 100:
+			uploading++
+		case !f.Available:
+			unavailable++
+		case f.Redundancy < renterHealthRedundancy:
+			lowRedundancy++
+		}
+		if !f.Renewing {
+			notRenewing++
+		}
+	}
+	fc := renterHealthCheck{
+		Name:   "Files",
+		Status: fmt.Sprintf("%v files, %v uploading", len(files), uploading),
+	}
+	if unavailable > 0 {
+		fc.Penalty = 30
+		fc.Status += fmt.Sprintf(", %v unavailable", unavailable)
+		fc.Recommendation = fmt.Sprintf("%v files cannot be downloaded; restore them from a local copy or remove them with 'siac renter delete'", unavailable)
+	} else if lowRedundancy > 0 {
+		fc.Penalty = 5 * lowRedundancy
+		if fc.Penalty > 25 {
+			fc.Penalty = 25
+		}
+		fc.Status += fmt.Sprintf(", %v below %vx redundancy", lowRedundancy, renterHealthRedundancy)
+		fc.Recommendation = fmt.Sprintf("%v files below %vx redundancy; keep siad online so that they can be repaired", lowRedundancy, renterHealthRedundancy)
+	}
+	checks = append(checks, fc)
+
+	// Contracts and allowance.
+	cc := renterHealthCheck{
+		Name:   "Contracts",
+		Status: fmt.Sprintf("%v of %v", len(contracts), allowance.Hosts),
+	}
+	if allowance.Hosts == 0 {
+		cc.Penalty = 40
+		cc.Status = "no allowance set"
+		cc.Recommendation = "set an allowance with 'siac renter setallowance' so that contracts are formed"
+	} else if uint64(len(contracts)) < allowance.Hosts {
+		missing := allowance.Hosts - uint64(len(contracts))
+		cc.Penalty = int(25 * missing / allowance.Hosts)
+		if cc.Penalty == 0 {
+			cc.Penalty = 1
+		}
+		cc.Recommendation = fmt.Sprintf("only %v of %v contracts formed", len(contracts), allowance.Hosts)
+		if c := rg.ContractFormation.BindingConstraint; c != "" {
+			cc.Recommendation += " (limited by " + c + ")"
+		}
+		cc.Recommendation += "; check 'siac renter' for the reason and adjust the allowance"
+	} else if notRenewing > 0 {
+		cc.Penalty = 10
+		cc.Recommendation = fmt.Sprintf("%v files will not be renewed; make sure the allowance covers them", notRenewing)
+	}
+	checks = append(checks, cc)
+	if !allowance.Funds.IsZero() {
+		ac := renterHealthCheck{
+			Name:   "Allowance",
+			Status: fmt.Sprintf("%v of %v unspent", currencyUnits(rg.FinancialMetrics.Unspent), currencyUnits(allowance.Funds)),
+		}
+		if rg.FinancialMetrics.Unspent.Mul64(10).Cmp(allowance.Funds) < 0 {
+			ac.Penalty = 15
+			ac.Recommendation = "less than 10% of the allowance is unspent; increase allowance funds"
+		}
+		checks = append(checks, ac)
+	}
+
+	// Wallet runway, measured in allowance periods.
+	wc := renterHealthCheck{Name: "Wallet"}
+	balance := wg.ConfirmedSiacoinBalance
+	if balance.Cmp(wg.UnconfirmedOutgoingSiacoins) > 0 {
+		balance = balance.Sub(wg.UnconfirmedOutgoingSiacoins)
+	} else {
+		balance = types.ZeroCurrency
+	}
+	wc.Status = currencyUnits(balance)
+	if !allowance.Funds.IsZero() {
+		runway, _ := new(big.Rat).SetFrac(balance.Big(), allowance.Funds.Big()).Float64()
+		wc.Status += fmt.Sprintf(", %.1f periods of runway", runway)
+		if runway < 1 {
+			wc.Penalty = 15
+			wc.Recommendation = fmt.Sprintf("the wallet cannot fund another period; add at least %v to renew contracts", currencyUnits(allowance.Funds.Sub(balance)))
+		}
+	}
+	if !wg.Unlocked {
+		wc.Penalty += 10
+		wc.Status += ", locked"
+		if wc.Recommendation == "" {
+			wc.Recommendation = "unlock the wallet with 'siac wallet unlock' so that contracts can be renewed"
+		}
+	}
+	checks = append(checks, wc)
+
+	// Hostdb quality.
+	hc := renterHealthCheck{
+		Name:   "Hostdb",
+		Status: fmt.Sprintf("%v active hosts", activeHosts),
+	}
+	if uint64(activeHosts) < allowance.Hosts {
+		hc.Penalty = 20
+		hc.Recommendation = fmt.Sprintf("only %v active hosts for an allowance of %v; wait for the hostdb to find more hosts or lower the allowance's host count", activeHosts, allowance.Hosts)
+	} else if uint64(activeHosts) < 2*allowance.Hosts {
+		hc.Penalty = 5
+		hc.Recommendation = fmt.Sprintf("only %v active hosts to choose %v from; replacements for bad hosts will be scarce", activeHosts, allowance.Hosts)
+	}
+	checks = append(checks, hc)
+
+	if rg.Paused {
+		checks = append(checks, renterHealthCheck{
+			Name:           "Activity",
+			Status:         "paused until " + rg.PausedUntil.Format(time.RFC1123),
+			Penalty:        10,
+			Recommendation: "renter activity is paused; resume it with 'siac renter resume'",
+		})
+	}
+
+	report := renterHealthReport{Score: 100, Checks: checks}
+	for _, c := range checks {
+		report.Score -= c.Penalty
+	}
+	if report.Score < 0 {
+		report.Score = 0
+	}
+	return report
+}
+
+// renterhealthcmd is the handler for the command `siac renter health`.
+// Prints a scored report of the renter's health with recommendations.
+func renterhealthcmd() {
+	var rg api.RenterGET
+	if err := getAPI("/renter", &rg); err != nil {
+		die("Could not get renter info:", err)
+	}
+	var rf api.RenterFiles
+	if err := getAPI("/renter/files", &rf); err != nil {
+		die("Could not get file list:", err)
+	}
+	var rc api.RenterContracts
+	if err := getAPI("/renter/contracts", &rc); err != nil {
+		die("Could not get contracts:", err)
+	}
+	var wg api.WalletGET
+	if err := getAPI("/wallet", &wg); err != nil {
+		die("Could not get wallet status:", err)
+	}
+	var hosts api.HostdbActiveGET
+	if err := getAPI("/hostdb/active", &hosts); err != nil {
+		die("Could not get active hosts:", err)
+	}
+
+	report := renterHealth(rg, rf.Files, rc.Contracts, wg, len(hosts.Hosts))
+	fmt.Printf("Renter health: %v/100\n\n", report.Score)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, c := range report.Checks {
+		status := "OK"
+		if c.Penalty > 0 {
+			status = fmt.Sprintf("-%v", c.Penalty)
+		}
+		fmt.Fprintf(w, "  %v:\t%v\t%v\n", c.Name, c.Status, status)
+	}
+	w.Flush()
+
+	var recommendations []string
+	for _, c := range report.Checks {
+		if c.Recommendation != "" {
+			recommendations = append(recommendations, c.Recommendation)
+		}
+	}
+	if len(recommendations) == 0 {
+		fmt.Println("\nNo problems found.")
+		return
+	}
+	fmt.Println("\nRecommendations:")
+	for _, r := range recommendations {
+		fmt.Println("  -", r)
+	}
+}
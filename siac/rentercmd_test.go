@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/api"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestRenterHealth tests that renterHealth scores a healthy renter perfectly,
+// and reports a recommendation for each problem with an unhealthy one.
+func TestRenterHealth(t *testing.T) {
+	var rg api.RenterGET
+	rg.Settings.Allowance = modules.Allowance{
+		Funds: types.SiacoinPrecision.Mul64(1000),
+		Hosts: 3,
+	}
+	rg.FinancialMetrics.Unspent = types.SiacoinPrecision.Mul64(500)
+	files := []modules.FileInfo{
+		{SiaPath: "a", Available: true, Renewing: true, Redundancy: 3, UploadProgress: 100},
+		{SiaPath: "b", Available: true, Renewing: true, Redundancy: 0.5, UploadProgress: 20},
+	}
+	contracts := make([]api.RenterContract, 3)
+	wg := api.WalletGET{
+		Unlocked:                true,
+		ConfirmedSiacoinBalance: types.SiacoinPrecision.Mul64(2500),
+	}
+
+	report := renterHealth(rg, files, contracts, wg, 10)
+	if report.Score != 100 {
+		t.Fatal("expected a healthy renter to score 100, got", report.Score, report.Checks)
+	}
+	for _, c := range report.Checks {
+		if c.Recommendation != "" {
+			t.Error("unexpected recommendation for a healthy renter:", c.Recommendation)
+		}
+	}
+
+	// Lower the redundancy of a finished file, drop a contract, spend most of
+	// the allowance, and drain the wallet.
+	files[0].Redundancy = 1.2
+	contracts = contracts[:2]
+	rg.FinancialMetrics.Unspent = types.SiacoinPrecision.Mul64(50)
+	wg.ConfirmedSiacoinBalance = types.SiacoinPrecision.Mul64(400)
+	report = renterHealth(rg, files, contracts, wg, 4)
+	if report.Score >= 100 || report.Score <= 0 {
+		t.Fatal("expected a degraded score, got", report.Score)
+	}
+	var recommendations string
+	for _, c := range report.Checks {
+		recommendations += c.Recommendation + "\n"
+	}
+	for _, s := range []string{
+		"1 files below 1.5x redundancy",
+		"only 2 of 3 contracts formed",
+		"increase allowance funds",
+		"add at least 600 SC",
+		"only 4 active hosts",
+	} {
+		if !strings.Contains(recommendations, s) {
+			t.Errorf("expected a recommendation containing %q, got:\n%v", s, recommendations)
+		}
+	}
+
+	// A renter without an allowance is told to set one.
+	report = renterHealth(api.RenterGET{}, nil, nil, wg, 0)
+	if !strings.Contains(report.Checks[1].Recommendation, "setallowance") {
+		t.Error("expected a recommendation to set an allowance, got", report.Checks[1].Recommendation)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	utilsCmd = &cobra.Command{
+		Use:   "utils",
+		Short: "Various utilities for working with Sia",
+		Long:  "Various utilities for working with Sia. These commands do not require a running siad.",
+	}
+
+	utilsGenGenesisCmd = &cobra.Command{
+		Use:   "gen-genesis [network id] [address] [destination]",
+		Short: "Create the genesis config of a private network",
+		Long: `Create the genesis config of a private network and write it to destination as
+JSON. All siafunds are given to the address, along with the siacoins specified
+by --siacoins. Every node on the private network must be started with
+'siad --genesis [destination]' and its own sia directory. Nodes only connect to
+peers with the same network ID.`,
+		Run: wrap(utilsgengenesiscmd),
+	}
+)
+
+// utilsgengenesiscmd is the handler for the command
+// `siac utils gen-genesis [network id] [address] [destination]`.
+// Writes the genesis config of a new private network to destination.
+func utilsgengenesiscmd(networkID, addr, destination string) {
+	var uh types.UnlockHash
+	if err := uh.LoadString(addr); err != nil {
+		die("Could not parse address:", err)
+	}
+	gc := types.GenesisConfig{
+		NetworkID: networkID,
+		Timestamp: types.CurrentTimestamp(),
+		SiafundAllocation: []types.SiafundOutput{{
+			Value:      types.SiafundCount,
+			UnlockHash: uh,
+		}},
+	}
+	if utilsGenesisSiacoins != "" {
+		hastings, err := parseCurrency(utilsGenesisSiacoins)
+		if err != nil {
+			die("Could not parse amount:", err)
+		}
+		var value types.Currency
+		if _, err := fmt.Sscan(hastings, &value); err != nil {
+			die("Could not parse amount:", err)
+		}
+		gc.SiacoinAllocation = []types.SiacoinOutput{{
+			Value:      value,
+			UnlockHash: uh,
+		}}
+	}
+	if err := gc.Validate(); err != nil {
+		die("Invalid genesis config:", err)
+	}
+
+	f, err := os.Create(abs(destination))
+	if err != nil {
+		die("Could not create genesis config:", err)
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "\t")
+	err = enc.Encode(gc)
+	if err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+	if err != nil {
+		die("Could not write genesis config:", err)
+	}
+	fmt.Printf("Genesis config for network %q written to %v\n", networkID, abs(destination))
+}
@@ -2,12 +2,18 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"math/big"
+	"net/url"
+	"os"
+	"text/tabwriter"
+	"time"
 
 	"github.com/bgentry/speakeasy"
 	"github.com/spf13/cobra"
 
 	"github.com/NebulousLabs/Sia/api"
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/types"
 )
 
@@ -41,8 +47,83 @@ The smallest unit of siacoins is the hasting. One siacoin is 10^24 hastings. Oth
 	walletAddressesCmd = &cobra.Command{
 		Use:   "addresses",
 		Short: "List all addresses",
-		Long:  "List all addresses that have been generated by the wallet",
-		Run:   wrap(walletaddressescmd),
+		Long: `List all addresses that have been generated by the wallet. With --usage,
+list each address generated from the primary seed along with its index, the
+number of confirmed transactions it appears in, the total siacoins it has
+received, and its current balance.`,
+		Run: wrap(walletaddressescmd),
+	}
+
+	walletApprovalsCmd = &cobra.Command{
+		Use:   "approvals",
+		Short: "List sends that are waiting for approval",
+		Long: `Show the wallet's approval policy and the sends that are waiting for approval.
+Sends above the approval threshold are not signed until they are approved with
+'siac wallet approve', using the approval password that siad was started with.`,
+		Run: wrap(walletapprovalscmd),
+	}
+
+	walletApprovalPolicyCmd = &cobra.Command{
+		Use:   "approvalpolicy [threshold] [timeout]",
+		Short: "Set the wallet's approval policy",
+		Long: `Require sends of more than 'threshold' siacoins to be approved within
+'timeout', e.g. 100KS 24h. Sends that are not approved in time are dropped. A
+threshold of 0 disables approvals. With --window, the threshold also caps the
+total sent without approval within the window. Requires the approval password.`,
+		Run: wrap(walletapprovalpolicycmd),
+	}
+
+	walletApproveCmd = &cobra.Command{
+		Use:   "approve [id]",
+		Short: "Approve a pending send",
+		Long:  "Sign and broadcast a send that is waiting for approval. Requires the approval password.",
+		Run:   wrap(walletapprovecmd),
+	}
+
+	walletRejectCmd = &cobra.Command{
+		Use:   "reject [id]",
+		Short: "Reject a pending send",
+		Long:  "Drop a send that is waiting for approval without signing it. Requires the approval password.",
+		Run:   wrap(walletrejectcmd),
+	}
+
+	walletBackupCmd = &cobra.Command{
+		Use:   "backup [destination]",
+		Short: "Back up the wallet",
+		Long: `Write a backup of the wallet database to the destination file. The backup
+contains every seed and key of the wallet, encrypted with the wallet password.
+With --encrypt, the whole backup is also encrypted with a separate password,
+and can be restored with 'siac wallet decrypt-backup'.`,
+		Run: wrap(walletbackupcmd),
+	}
+
+	walletChangePasswordCmd = &cobra.Command{
+		Use:   "change-password",
+		Short: "Change the wallet password",
+		Long: `Re-encrypt the wallet under a new password. The wallet's seeds and addresses
+are unchanged. Leaving the new password blank will make the primary seed the
+wallet password.`,
+		Run: wrap(walletchangepasswordcmd),
+	}
+
+	walletCheckDBCmd = &cobra.Command{
+		Use:   "checkdb",
+		Short: "Check the wallet database for corruption",
+		Long: `Check the integrity of the wallet database, reporting corrupt entries and
+inconsistencies between the wallet's keys, transactions, and outputs, along
+with how they can be repaired. Outputs and transactions are only checked
+against the wallet's keys while the wallet is unlocked. With --compact, the
+database is then rewritten without free space or stale entries.`,
+		Run: wrap(walletcheckdbcmd),
+	}
+
+	walletDecryptBackupCmd = &cobra.Command{
+		Use:   "decrypt-backup [source] [destination]",
+		Short: "Decrypt an encrypted wallet backup",
+		Long: `Decrypt a backup created with 'siac wallet backup --encrypt', writing the
+wallet database to the destination file. The daemon does not need to be
+running.`,
+		Run: wrap(walletdecryptbackupcmd),
 	}
 
 	walletInitCmd = &cobra.Command{
@@ -96,6 +177,15 @@ By default the wallet encryption / unlock password is the same as the generated
 		Run:   wrap(walletlockcmd),
 	}
 
+	walletLockTimeoutCmd = &cobra.Command{
+		Use:   "locktimeout [duration]",
+		Short: "Set the wallet's lock timeout",
+		Long: `Set how long the wallet may stay unlocked without signing a transaction
+before it locks itself, e.g. 15m or 2h. A duration of 0 disables automatic
+locking.`,
+		Run: wrap(walletlocktimeoutcmd),
+	}
+
 	walletSeedsCmd = &cobra.Command{
 		Use:   "seeds",
 		Short: "View information about your seeds",
@@ -152,6 +242,13 @@ will be sent to your wallet.`,
 		Run:   wrap(wallettransactionscmd),
 	}
 
+	walletExtendCmd = &cobra.Command{
+		Use:   "extend [duration]",
+		Short: "Extend the wallet's unlock time",
+		Long:  "Postpone the automatic lock of an unlocked wallet by the provided duration, e.g. 30m.",
+		Run:   wrap(walletextendcmd),
+	}
+
 	walletUnlockCmd = &cobra.Command{
 		Use:   `unlock`,
 		Short: "Unlock the wallet",
@@ -175,6 +272,10 @@ func walletaddresscmd() {
 
 // walletaddressescmd fetches the list of addresses that the wallet knows.
 func walletaddressescmd() {
+	if walletAddressUsage {
+		walletaddressusagecmd()
+		return
+	}
 	addrs := new(api.WalletAddressesGET)
 	err := getAPI("/wallet/addresses", addrs)
 	if err != nil {
@@ -185,6 +286,131 @@ func walletaddressescmd() {
 	}
 }
 
+// walletaddressusagecmd lists the usage statistics of each address generated
+// from the primary seed.
+func walletaddressusagecmd() {
+	usage := new(api.WalletAddressUsageGET)
+	err := getAPI("/wallet/addresses/usage", usage)
+	if err != nil {
+		die("Failed to fetch address usage:", err)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Index\tAddress\tTransactions\tReceived\tBalance")
+	for _, u := range usage.Addresses {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", u.Index, u.Address, u.Transactions,
+			currencyUnits(u.TotalReceived), currencyUnits(u.Balance))
+	}
+	w.Flush()
+}
+
+// walletbackupcmd creates a backup of the wallet, optionally encrypted with a
+// password.
+func walletbackupcmd(destination string) {
+	qs := "destination=" + url.QueryEscape(abs(destination))
+	if walletBackupCrypt {
+		password, err := speakeasy.Ask("Backup password: ")
+		if err != nil {
+			die("Reading password failed:", err)
+		}
+		confirmPassword, err := speakeasy.Ask("Confirm backup password: ")
+		if err != nil {
+			die("Reading password failed:", err)
+		}
+		if password == "" || password != confirmPassword {
+			die("Backup passwords are blank or do not match")
+		}
+		qs += "&encryptionpassword=" + url.QueryEscape(password)
+	}
+	err := get("/wallet/backup?" + qs)
+	if err != nil {
+		die("Could not create backup:", err)
+	}
+	fmt.Println("Wallet backup written to", abs(destination))
+}
+
+// walletcheckdbcmd checks the integrity of the wallet database, optionally
+// compacting it afterwards.
+func walletcheckdbcmd() {
+	var report api.WalletDBCheckGET
+	err := getAPI("/wallet/db/check", &report)
+	if err != nil {
+		die("Could not check wallet database:", err)
+	}
+	fmt.Printf("Database size: %v\n", filesizeUnits(int64(report.Size)))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Bucket\tEntries")
+	for _, b := range report.Buckets {
+		fmt.Fprintf(w, "%v\t%v\n", b.Name, b.Entries)
+	}
+	w.Flush()
+	if !report.KeysChecked {
+		fmt.Println("The wallet is locked, so outputs and transactions were not checked against its keys.")
+	}
+	fmt.Printf("Stale entries: %v\n", report.StaleEntries)
+
+	if len(report.Problems) == 0 {
+		fmt.Println("No problems found.")
+	} else {
+		fmt.Printf("%v problems found:\n", len(report.Problems))
+		for _, p := range report.Problems {
+			location := p.Bucket
+			if p.Key != "" {
+				location += " " + p.Key
+			}
+			if location != "" {
+				location += ": "
+			}
+			fmt.Printf("  %v%v\n    repair: %v\n", location, p.Description, p.Repair)
+		}
+	}
+
+	if walletCheckDBCompact {
+		var compaction api.WalletDBCompactPOST
+		err := postResp("/wallet/db/compact", "", &compaction)
+		if err != nil {
+			die("Could not compact wallet database:", err)
+		}
+		fmt.Printf("Compacted the wallet database from %v to %v, removing %v stale entries.\n",
+			filesizeUnits(int64(compaction.SizeBefore)), filesizeUnits(int64(compaction.SizeAfter)), compaction.EntriesRemoved)
+	} else if report.StaleEntries > 0 {
+		fmt.Println("Run 'siac wallet checkdb --compact' to remove stale entries and free space.")
+	}
+}
+
+// walletdecryptbackupcmd decrypts a backup created by walletbackupcmd. The
+// backup is decrypted locally, without contacting the daemon.
+func walletdecryptbackupcmd(source, destination string) {
+	password, err := speakeasy.Ask("Backup password: ")
+	if err != nil {
+		die("Reading password failed:", err)
+	}
+	src, err := os.Open(source)
+	if err != nil {
+		die("Could not open backup:", err)
+	}
+	defer src.Close()
+	key := crypto.TwofishKey(crypto.HashObject(password))
+	sr, err := key.NewStreamReader(src)
+	if err != nil {
+		die("Could not read backup:", err)
+	}
+	dst, err := os.Create(destination)
+	if err != nil {
+		die("Could not create destination:", err)
+	}
+	_, err = io.Copy(dst, sr)
+	if err == nil {
+		err = dst.Close()
+	} else {
+		dst.Close()
+	}
+	if err != nil {
+		os.Remove(destination)
+		die("Could not decrypt backup (is the password correct?):", err)
+	}
+	fmt.Println("Wallet database written to", destination)
+}
+
 // walletinitcmd encrypts the wallet with the given password
 func walletinitcmd() {
 	var er api.WalletInitPOST
@@ -208,6 +434,31 @@ func walletinitcmd() {
 	}
 }
 
+// walletchangepasswordcmd changes the password of the wallet.
+func walletchangepasswordcmd() {
+	password, err := speakeasy.Ask("Current wallet password: ")
+	if err != nil {
+		die("Reading password failed:", err)
+	}
+	newPassword, err := speakeasy.Ask("New wallet password: ")
+	if err != nil {
+		die("Reading password failed:", err)
+	}
+	confirmPassword, err := speakeasy.Ask("Confirm new wallet password: ")
+	if err != nil {
+		die("Reading password failed:", err)
+	}
+	if newPassword != confirmPassword {
+		die("New passwords do not match")
+	}
+	qs := fmt.Sprintf("encryptionpassword=%s&newpassword=%s", password, newPassword)
+	err = post("/wallet/changepassword", qs)
+	if err != nil {
+		die("Could not change wallet password:", err)
+	}
+	fmt.Println("Wallet password changed")
+}
+
 // walletinitseedcmd initializes the wallet from a preexisting seed.
 func walletinitseedcmd() {
 	seed, err := speakeasy.Ask("Seed: ")
@@ -287,6 +538,36 @@ func walletlockcmd() {
 	}
 }
 
+// walletlocktimeoutcmd sets the wallet's lock timeout.
+func walletlocktimeoutcmd(duration string) {
+	timeout, err := time.ParseDuration(duration)
+	if err != nil {
+		die("Could not parse duration:", err)
+	}
+	err = post("/wallet/locktimeout", fmt.Sprintf("timeout=%d", uint64(timeout.Seconds())))
+	if err != nil {
+		die("Could not set lock timeout:", err)
+	}
+	if timeout == 0 {
+		fmt.Println("Automatic locking disabled")
+		return
+	}
+	fmt.Println("Wallet will lock after", timeout, "without signing a transaction")
+}
+
+// walletextendcmd postpones the automatic lock of the wallet.
+func walletextendcmd(duration string) {
+	extension, err := time.ParseDuration(duration)
+	if err != nil {
+		die("Could not parse duration:", err)
+	}
+	err = post("/wallet/unlock/extend", fmt.Sprintf("duration=%d", uint64(extension.Seconds())))
+	if err != nil {
+		die("Could not extend unlock time:", err)
+	}
+	fmt.Println("Wallet unlock time extended by", extension)
+}
+
 // walletseedcmd returns the current seed {
 func walletseedscmd() {
 	var seedInfo api.WalletSeedsGET
@@ -315,13 +596,89 @@ func walletsendsiacoinscmd(amount, dest string) {
 	if err != nil {
 		die("Could not parse amount:", err)
 	}
-	err = post("/wallet/siacoins", fmt.Sprintf("amount=%s&destination=%s", hastings, dest))
+	var resp api.WalletSiacoinsPOST
+	err = postResp("/wallet/siacoins", fmt.Sprintf("amount=%s&destination=%s", hastings, dest), &resp)
 	if err != nil {
 		die("Could not send siacoins:", err)
 	}
+	if resp.PendingApproval {
+		fmt.Printf("Send of %s hastings to %s requires approval before %v\n", hastings, dest, resp.PendingSend.Expires.Format(time.RFC822))
+		fmt.Printf("Approve it with 'siac wallet approve %v'\n", resp.PendingSend.ID)
+		return
+	}
 	fmt.Printf("Sent %s hastings to %s\n", hastings, dest)
 }
 
+// walletapprovalscmd lists the sends that are waiting for approval.
+func walletapprovalscmd() {
+	var wa api.WalletApprovalsGET
+	err := getAPI("/wallet/approvals", &wa)
+	if err != nil {
+		die("Could not get pending sends:", err)
+	}
+	if wa.Threshold.IsZero() {
+		fmt.Println("Approvals are disabled")
+	} else {
+		fmt.Printf("Sends above %v must be approved within %v\n", currencyUnits(wa.Threshold), time.Duration(wa.Timeout)*time.Second)
+		if wa.Window != 0 {
+			fmt.Printf("Sends totalling more than %v within %v must also be approved\n", currencyUnits(wa.Threshold), time.Duration(wa.Window)*time.Second)
+		}
+	}
+	if len(wa.PendingSends) == 0 {
+		fmt.Println("No sends are waiting for approval.")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tAmount\tDestination\tExpires")
+	for _, ps := range wa.PendingSends {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", ps.ID, currencyUnits(ps.Amount), ps.Destination, ps.Expires.Format(time.RFC822))
+	}
+	w.Flush()
+}
+
+// walletapprovalpolicycmd sets the wallet's approval policy.
+func walletapprovalpolicycmd(threshold, duration string) {
+	hastings, err := parseCurrency(threshold)
+	if err != nil {
+		die("Could not parse threshold:", err)
+	}
+	timeout, err := time.ParseDuration(duration)
+	if err != nil {
+		die("Could not parse timeout:", err)
+	}
+	var window time.Duration
+	if walletApprovalWindow != "" {
+		window, err = time.ParseDuration(walletApprovalWindow)
+		if err != nil {
+			die("Could not parse window:", err)
+		}
+	}
+	err = post("/wallet/approvals", fmt.Sprintf("threshold=%s&timeout=%d&window=%d", hastings, uint64(timeout.Seconds()), uint64(window.Seconds())))
+	if err != nil {
+		die("Could not set approval policy:", err)
+	}
+	fmt.Println("Approval policy updated")
+}
+
+// walletapprovecmd approves a pending send.
+func walletapprovecmd(id string) {
+	var resp api.WalletSiacoinsPOST
+	err := postResp("/wallet/approvals/approve/"+id, "", &resp)
+	if err != nil {
+		die("Could not approve send:", err)
+	}
+	fmt.Println("Approved send", id)
+}
+
+// walletrejectcmd rejects a pending send.
+func walletrejectcmd(id string) {
+	err := post("/wallet/approvals/reject/"+id, "")
+	if err != nil {
+		die("Could not reject send:", err)
+	}
+	fmt.Println("Rejected send", id)
+}
+
 // walletsendsiafundscmd sends siafunds to a destination address.
 func walletsendsiafundscmd(amount, dest string) {
 	err := post("/wallet/siafunds", fmt.Sprintf("amount=%s&destination=%s", amount, dest))
@@ -367,6 +724,9 @@ Siafunds:            %v SF
 Siafund Claims:      %v H
 `, encStatus, currencyUnits(status.ConfirmedSiacoinBalance), delta,
 		status.ConfirmedSiacoinBalance, status.SiafundBalance, status.SiacoinClaimBalance)
+	if status.UnlockRemaining > 0 {
+		fmt.Printf("Locks in:            %v\n", time.Duration(status.UnlockRemaining)*time.Second)
+	}
 }
 
 // walletsweepcmd sweeps coins and funds from a seed.
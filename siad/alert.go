@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+)
+
+// alertWebhookTimeout is the amount of time siad will wait for the alert
+// webhook to accept an alert.
+const alertWebhookTimeout = 30 * time.Second
+
+var errInvalidAlertWebhook = errors.New("alert webhook must be an http or https URL")
+
+// processAlertWebhook checks that the alert webhook, if one was provided, is an
+// absolute http or https URL.
+func processAlertWebhook(webhook string) (string, error) {
+	return processWebhook(webhook, errInvalidAlertWebhook)
+}
+
+// processWebhook checks that webhook, if one was provided, is an absolute
+// http or https URL, returning errInvalid if it is not.
+func processWebhook(webhook string, errInvalid error) (string, error) {
+	if webhook == "" {
+		return "", nil
+	}
+	u, err := url.Parse(webhook)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", errInvalid
+	}
+	return u.String(), nil
+}
+
+// postJSON POSTs v to webhook as JSON, returning an error if the webhook does
+// not respond with a 2xx status.
+func postJSON(client *http.Client, webhook string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Post(webhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.New("webhook returned unexpected status: " + resp.Status)
+	}
+	return nil
+}
+
+// alertWebhook returns an alert handler that POSTs each alert as JSON to the
+// provided URL. Alerts are sent in the background so that the goroutine that
+// raised the alert is not blocked. Delivery failures are printed to stderr
+// instead of being reported as alerts, to avoid a feedback loop.
+func alertWebhook(webhook string) func(build.Alert) {
+	client := &http.Client{Timeout: alertWebhookTimeout}
+	return func(a build.Alert) {
+		go func() {
+			if err := postJSON(client, webhook, a); err != nil {
+				fmt.Fprintln(os.Stderr, "Could not deliver alert to webhook:", err)
+			}
+		}()
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+)
+
+// TestProcessAlertWebhook checks that only http and https URLs are accepted as
+// alert webhooks.
+func TestProcessAlertWebhook(t *testing.T) {
+	tests := []struct {
+		webhook string
+		valid   bool
+	}{
+		{"", true},
+		{"http://localhost:8080/alerts", true},
+		{"https://example.com/hook", true},
+		{"ftp://example.com/hook", false},
+		{"example.com/hook", false},
+		{"http://", false},
+	}
+	for _, test := range tests {
+		_, err := processAlertWebhook(test.webhook)
+		if (err == nil) != test.valid {
+			t.Errorf("processAlertWebhook(%q): expected valid=%v, got %v", test.webhook, test.valid, err)
+		}
+	}
+}
+
+// TestAlertWebhook checks that alerts are POSTed to the webhook as JSON.
+func TestAlertWebhook(t *testing.T) {
+	received := make(chan build.Alert, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var a build.Alert
+		if err := json.NewDecoder(req.Body).Decode(&a); err != nil {
+			t.Error(err)
+		}
+		received <- a
+	}))
+	defer ts.Close()
+
+	sent := build.Alert{
+		Severity: build.AlertSeverityCritical,
+		Message:  "webhook test",
+		Stack:    "stack",
+	}
+	alertWebhook(ts.URL)(sent)
+	select {
+	case a := <-received:
+		if a.Severity != sent.Severity || a.Message != sent.Message || a.Stack != sent.Stack {
+			t.Error("webhook received the wrong alert:", a)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook did not receive the alert")
+	}
+}
@@ -22,7 +22,9 @@ import (
 	"github.com/NebulousLabs/Sia/modules/transactionpool"
 	"github.com/NebulousLabs/Sia/modules/wallet"
 	"github.com/NebulousLabs/Sia/profile"
+	"github.com/NebulousLabs/Sia/types"
 
+	"github.com/NebulousLabs/entropy-mnemonics"
 	"github.com/bgentry/speakeasy"
 	"github.com/spf13/cobra"
 )
@@ -30,6 +32,12 @@ import (
 // verifyAPISecurity checks that the security values are consistent with a
 // sane, secure system.
 func verifyAPISecurity(config Config) error {
+	// Approvals only provide dual control if the API password is required to
+	// initiate a send.
+	if config.Siad.AuthenticateApprovals && !config.Siad.AuthenticateAPI {
+		return errors.New("cannot use --authenticate-approvals without setting an api password")
+	}
+
 	// Make sure that only the loopback address is allowed unless the
 	// --disable-api-security flag has been used.
 	if !config.Siad.AllowAPIBind {
@@ -86,7 +94,10 @@ func processConfig(config Config) (Config, error) {
 	config.Siad.HostAddr = processNetAddr(config.Siad.HostAddr)
 	config.Siad.Modules, err1 = processModules(config.Siad.Modules)
 	err2 := verifyAPISecurity(config)
-	err := build.JoinErrors([]error{err1, err2}, ", and ")
+	var err3, err4 error
+	config.Siad.AlertWebhook, err3 = processAlertWebhook(config.Siad.AlertWebhook)
+	config.Siad.ReceiptWebhook, err4 = processReceiptWebhook(config.Siad.ReceiptWebhook)
+	err := build.JoinErrors([]error{err1, err2, err3, err4}, ", and ")
 	if err != nil {
 		return Config{}, err
 	}
@@ -107,12 +118,66 @@ func startDaemon(config Config) (err error) {
 		}
 	}
 
+	// Prompt user for approval password.
+	if config.Siad.AuthenticateApprovals {
+		config.ApprovalPassword, err = speakeasy.Ask("Enter approval password: ")
+		if err != nil {
+			return err
+		}
+		if config.ApprovalPassword == "" {
+			return errors.New("password cannot be blank")
+		} else if config.ApprovalPassword == config.APIPassword {
+			return errors.New("approval password must be different from the API password")
+		}
+	}
+
+	// Read the seed of the ephemeral wallet, prompting the user if it was not
+	// provided through the environment.
+	if config.Siad.EphemeralWallet {
+		seedStr := os.Getenv("SIA_WALLET_SEED")
+		if seedStr == "" {
+			seedStr, err = speakeasy.Ask("Enter wallet seed: ")
+			if err != nil {
+				return err
+			}
+		}
+		config.WalletSeed, err = modules.StringToSeed(seedStr, mnemonics.English)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Process the config variables after they are parsed by cobra.
 	config, err = processConfig(config)
 	if err != nil {
 		return err
 	}
 
+	// Join a private network, if a genesis config was provided. This must
+	// happen before any modules are created.
+	if config.Siad.Genesis != "" {
+		if err := loadGenesis(config.Siad.Genesis); err != nil {
+			return err
+		}
+		fmt.Println("Using private network", types.NetworkID)
+	}
+
+	// Apply the feature flag overrides saved by the operator. This must
+	// happen before any modules are created.
+	if err := modules.LoadFeatureOverrides(config.Siad.SiaDir); err != nil {
+		return err
+	}
+
+	// Apply the bandwidth limits shared by the renter and the host.
+	if err := modules.LoadSharedBandwidthLimits(config.Siad.SiaDir); err != nil {
+		return err
+	}
+
+	// Forward alerts to the webhook, if one was provided.
+	if config.Siad.AlertWebhook != "" {
+		build.AddAlertHandler(alertWebhook(config.Siad.AlertWebhook))
+	}
+
 	// Print a startup message.
 	fmt.Println("Loading...")
 	loadStart := time.Now()
@@ -135,10 +200,15 @@ func startDaemon(config Config) (err error) {
 	if strings.Contains(config.Siad.Modules, "g") {
 		i++
 		fmt.Printf("(%d/%d) Loading gateway...\n", i, len(config.Siad.Modules))
-		g, err = gateway.New(config.Siad.RPCaddr, !config.Siad.NoBootstrap, filepath.Join(config.Siad.SiaDir, modules.GatewayDir))
+		proxy := gateway.ProxyConfig{
+			SOCKS5:     config.Siad.GatewayProxy,
+			TorControl: config.Siad.GatewayTorControl,
+		}
+		gw, err := gateway.NewWithProxy(config.Siad.RPCaddr, !config.Siad.NoBootstrap, filepath.Join(config.Siad.SiaDir, modules.GatewayDir), proxy)
 		if err != nil {
 			return err
 		}
+		g = gw
 		defer func() {
 			fmt.Println("Closing gateway...")
 			err := g.Close()
@@ -146,6 +216,11 @@ func startDaemon(config Config) (err error) {
 				fmt.Println("Error during gateway shutdown:", err)
 			}
 		}()
+		if config.Siad.GatewayQUIC {
+			if err := gw.EnableQUIC(); err != nil {
+				return err
+			}
+		}
 	}
 	var cs modules.ConsensusSet
 	if strings.Contains(config.Siad.Modules, "c") {
@@ -167,7 +242,7 @@ func startDaemon(config Config) (err error) {
 	if strings.Contains(config.Siad.Modules, "e") {
 		i++
 		fmt.Printf("(%d/%d) Loading explorer...\n", i, len(config.Siad.Modules))
-		e, err = explorer.New(cs, filepath.Join(config.Siad.SiaDir, modules.ExplorerDir))
+		e, err = explorer.New(g, cs, filepath.Join(config.Siad.SiaDir, modules.ExplorerDir))
 		if err != nil {
 			return err
 		}
@@ -183,10 +258,12 @@ func startDaemon(config Config) (err error) {
 	if strings.Contains(config.Siad.Modules, "t") {
 		i++
 		fmt.Printf("(%d/%d) Loading transaction pool...\n", i, len(config.Siad.Modules))
-		tpool, err = transactionpool.New(cs, g, filepath.Join(config.Siad.SiaDir, modules.TransactionPoolDir))
+		tp, err := transactionpool.New(cs, g, filepath.Join(config.Siad.SiaDir, modules.TransactionPoolDir))
 		if err != nil {
 			return err
 		}
+		tp.SetReplaceByFee(!config.Siad.NoReplaceByFee)
+		tpool = tp
 		defer func() {
 			fmt.Println("Closing transaction pool...")
 			err := tpool.Close()
@@ -199,7 +276,11 @@ func startDaemon(config Config) (err error) {
 	if strings.Contains(config.Siad.Modules, "w") {
 		i++
 		fmt.Printf("(%d/%d) Loading wallet...\n", i, len(config.Siad.Modules))
-		w, err = wallet.New(cs, tpool, filepath.Join(config.Siad.SiaDir, modules.WalletDir))
+		if config.Siad.EphemeralWallet {
+			w, err = wallet.NewEphemeral(cs, tpool, config.WalletSeed)
+		} else {
+			w, err = wallet.NewWithBackend(cs, tpool, filepath.Join(config.Siad.SiaDir, modules.WalletDir), config.Siad.WalletBackend)
+		}
 		if err != nil {
 			return err
 		}
@@ -210,6 +291,11 @@ func startDaemon(config Config) (err error) {
 				fmt.Println("Error during wallet shutdown:", err)
 			}
 		}()
+		// Without an approval password, the sends that the policy queues
+		// could never be approved, and the policy could not be changed.
+		if !config.Siad.AuthenticateApprovals && !w.ApprovalPolicy().Threshold.IsZero() {
+			return errors.New("the wallet has an approval policy; siad must be started with --authenticate-approvals")
+		}
 	}
 	var m modules.Miner
 	if strings.Contains(config.Siad.Modules, "m") {
@@ -231,10 +317,14 @@ func startDaemon(config Config) (err error) {
 	if strings.Contains(config.Siad.Modules, "h") {
 		i++
 		fmt.Printf("(%d/%d) Loading host...\n", i, len(config.Siad.Modules))
-		h, err = host.New(cs, tpool, w, config.Siad.HostAddr, filepath.Join(config.Siad.SiaDir, modules.HostDir))
+		hst, err := host.New(cs, tpool, w, config.Siad.HostAddr, filepath.Join(config.Siad.SiaDir, modules.HostDir))
 		if err != nil {
 			return err
 		}
+		if g != nil {
+			hst.UseGateway(g)
+		}
+		h = hst
 		defer func() {
 			fmt.Println("Closing host...")
 			err := h.Close()
@@ -247,10 +337,14 @@ func startDaemon(config Config) (err error) {
 	if strings.Contains(config.Siad.Modules, "r") {
 		i++
 		fmt.Printf("(%d/%d) Loading renter...\n", i, len(config.Siad.Modules))
-		r, err = renter.New(g, cs, w, tpool, filepath.Join(config.Siad.SiaDir, modules.RenterDir))
+		rt, err := renter.New(g, cs, w, tpool, filepath.Join(config.Siad.SiaDir, modules.RenterDir))
 		if err != nil {
 			return err
 		}
+		if config.Siad.ReceiptWebhook != "" {
+			rt.AddUploadReceiptHandler(receiptWebhook(config.Siad.ReceiptWebhook))
+		}
+		r = rt
 		defer func() {
 			fmt.Println("Closing renter...")
 			err := r.Close()
@@ -273,6 +367,14 @@ func startDaemon(config Config) (err error) {
 		tpool,
 		w,
 	)
+	a.SetApprovalPassword(config.ApprovalPassword)
+
+	// Watch the modules for stalls.
+	if !config.Siad.NoWatchdog {
+		stopWatchdog := make(chan struct{})
+		defer close(stopWatchdog)
+		go newWatchdog(cs, h, r).threadedRun(stopWatchdog)
+	}
 
 	// connect the API to the server
 	srv.mux.Handle("/", a)
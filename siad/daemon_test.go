@@ -162,4 +162,18 @@ func TestVerifyAPISecurity(t *testing.T) {
 	if err != nil {
 		t.Error("public + securityOff with authentication was rejected:", err)
 	}
+
+	// Check that approvals cannot be authenticated without an api password.
+	var approvalsUnauthenticated Config
+	approvalsUnauthenticated.Siad.APIaddr = "127.0.0.1:9980"
+	approvalsUnauthenticated.Siad.AuthenticateApprovals = true
+	err = verifyAPISecurity(approvalsUnauthenticated)
+	if err == nil {
+		t.Error("approval password was accepted without an api password")
+	}
+	approvalsUnauthenticated.Siad.AuthenticateAPI = true
+	err = verifyAPISecurity(approvalsUnauthenticated)
+	if err != nil {
+		t.Error("approval password with an api password was rejected:", err)
+	}
 }
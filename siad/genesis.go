@@ -0,0 +1,28 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// loadGenesis reads a genesis config from a JSON file, such as one created by
+// `siac utils gen-genesis`, and uses it in place of the genesis block of the
+// public network.
+func loadGenesis(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var gc types.GenesisConfig
+	if err := json.NewDecoder(f).Decode(&gc); err != nil {
+		return fmt.Errorf("could not decode genesis config: %v", err)
+	}
+	if err := types.UseGenesis(gc); err != nil {
+		return fmt.Errorf("invalid genesis config: %v", err)
+	}
+	return nil
+}
@@ -3,10 +3,14 @@ package main
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/spf13/cobra"
 
 	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/host/contractmanager"
+	"github.com/NebulousLabs/Sia/modules/wallet"
 )
 
 var (
@@ -29,6 +33,16 @@ type Config struct {
 	// --authenticate-api flag is set.
 	APIPassword string
 
+	// The ApprovalPassword is input by the user after the daemon starts up,
+	// if the --authenticate-approvals flag is set. It is required to approve
+	// sends that exceed the wallet's approval threshold.
+	ApprovalPassword string
+
+	// The WalletSeed is input by the user after the daemon starts up, if the
+	// --ephemeral-wallet flag is set. It can also be provided through the
+	// SIA_WALLET_SEED environment variable.
+	WalletSeed modules.Seed
+
 	// The Siad variables are referenced directly by cobra, and are set
 	// according to the flags.
 	Siad struct {
@@ -37,14 +51,26 @@ type Config struct {
 		HostAddr     string
 		AllowAPIBind bool
 
-		Modules           string
-		NoBootstrap       bool
-		RequiredUserAgent string
-		AuthenticateAPI   bool
+		Modules               string
+		GatewayQUIC           bool
+		GatewayProxy          string
+		GatewayTorControl     string
+		NoBootstrap           bool
+		NoReplaceByFee        bool
+		NoWatchdog            bool
+		RequiredUserAgent     string
+		AuthenticateAPI       bool
+		AuthenticateApprovals bool
+		EphemeralWallet       bool
+		WalletBackend         string
 
 		Profile    bool
 		ProfileDir string
 		SiaDir     string
+
+		AlertWebhook   string
+		Genesis        string
+		ReceiptWebhook string
 	}
 }
 
@@ -55,6 +81,17 @@ func die(args ...interface{}) {
 	os.Exit(exitCodeGeneral)
 }
 
+// rotateSectorSaltCmd is a cobra command that re-keys the stored sectors of
+// the host under a new sector salt.
+func rotateSectorSaltCmd(*cobra.Command, []string) {
+	dir := filepath.Join(globalConfig.Siad.SiaDir, modules.HostDir, modules.ContractManagerDir)
+	err := contractmanager.RotateSectorSalt(dir)
+	if err != nil {
+		die("Could not rotate the sector salt:", err)
+	}
+	fmt.Println("Sector salt rotated.")
+}
+
 // versionCmd is a cobra command that prints the version of siad.
 func versionCmd(*cobra.Command, []string) {
 	switch build.Release {
@@ -156,6 +193,20 @@ func main() {
 		Run:   modulesCmd,
 	})
 
+	rotateCmd := &cobra.Command{
+		Use:   "rotate-sector-salt",
+		Short: "Re-key the stored sectors of the host under a new sector salt",
+		Long: `Replace the sector salt of the host's contract manager with a new random salt,
+re-keying every sector in its storage folders. siad must be stopped, and must
+have shut down cleanly. Every stored sector is read, so the rotation can take
+hours; if it is interrupted, run the command again to resume it. siad refuses
+to start the host until the rotation is finished. Host backups taken before
+the rotation cannot be restored afterwards.`,
+		Run: rotateSectorSaltCmd,
+	}
+	rotateCmd.Flags().StringVarP(&globalConfig.Siad.SiaDir, "sia-directory", "d", "", "location of the sia directory")
+	root.AddCommand(rotateCmd)
+
 	// Set default values, which have the lowest priority.
 	root.Flags().StringVarP(&globalConfig.Siad.RequiredUserAgent, "agent", "", "Sia-Agent", "required substring for the user agent")
 	root.Flags().StringVarP(&globalConfig.Siad.HostAddr, "host-addr", "", ":9982", "which port the host listens on")
@@ -163,10 +214,21 @@ func main() {
 	root.Flags().StringVarP(&globalConfig.Siad.APIaddr, "api-addr", "", "localhost:9980", "which host:port the API server listens on")
 	root.Flags().StringVarP(&globalConfig.Siad.SiaDir, "sia-directory", "d", "", "location of the sia directory")
 	root.Flags().BoolVarP(&globalConfig.Siad.NoBootstrap, "no-bootstrap", "", false, "disable bootstrapping on this run")
+	root.Flags().BoolVarP(&globalConfig.Siad.GatewayQUIC, "gateway-quic", "", false, "also accept and make peer connections over QUIC (experimental)")
+	root.Flags().StringVarP(&globalConfig.Siad.GatewayProxy, "gateway-proxy", "", "", "SOCKS5 proxy that outbound peer connections are made through, e.g. Tor's 127.0.0.1:9050")
+	root.Flags().StringVarP(&globalConfig.Siad.GatewayTorControl, "gateway-tor-control", "", "", "Tor control port used to publish the gateway as an onion service, e.g. 127.0.0.1:9051 (requires --gateway-proxy)")
+	root.Flags().BoolVarP(&globalConfig.Siad.NoReplaceByFee, "no-replace-by-fee", "", false, "reject unconfirmed transactions that double spend the transaction pool, even if they pay a higher fee")
+	root.Flags().BoolVarP(&globalConfig.Siad.NoWatchdog, "no-watchdog", "", false, "do not raise alerts when modules stop making progress")
 	root.Flags().BoolVarP(&globalConfig.Siad.Profile, "profile", "", false, "enable profiling")
 	root.Flags().StringVarP(&globalConfig.Siad.RPCaddr, "rpc-addr", "", ":9981", "which port the gateway listens on")
 	root.Flags().StringVarP(&globalConfig.Siad.Modules, "modules", "M", "cghrtw", "enabled modules, see 'siad modules' for more info")
 	root.Flags().BoolVarP(&globalConfig.Siad.AuthenticateAPI, "authenticate-api", "", false, "enable API password protection")
+	root.Flags().BoolVarP(&globalConfig.Siad.AuthenticateApprovals, "authenticate-approvals", "", false, "prompt for a second password, required to approve large wallet sends")
+	root.Flags().BoolVarP(&globalConfig.Siad.EphemeralWallet, "ephemeral-wallet", "", false, "keep the wallet in memory only, initialized from a seed read from SIA_WALLET_SEED or the terminal")
+	root.Flags().StringVarP(&globalConfig.Siad.WalletBackend, "wallet-backend", "", wallet.BackendBolt, "database that the wallet is stored in, either \"bolt\" or \"sqlite\"; a bolt wallet is migrated the first time it is opened with sqlite")
+	root.Flags().StringVarP(&globalConfig.Siad.Genesis, "genesis", "", "", "genesis config of a private network, created with 'siac utils gen-genesis'")
+	root.Flags().StringVarP(&globalConfig.Siad.AlertWebhook, "alert-webhook", "", "", "URL that critical errors and recovered panics are POSTed to as JSON")
+	root.Flags().StringVarP(&globalConfig.Siad.ReceiptWebhook, "receipt-webhook", "", "", "URL that a signed receipt is POSTed to as JSON when a file finishes uploading")
 	root.Flags().BoolVarP(&globalConfig.Siad.AllowAPIBind, "disable-api-security", "", false, "allow siad to listen on a non-localhost address (DANGEROUS)")
 
 	// Parse cmdline flags, overwriting both the default values and the config
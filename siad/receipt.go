@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// receiptWebhookTimeout is the amount of time siad will wait for the receipt
+// webhook to accept an upload receipt.
+const receiptWebhookTimeout = 30 * time.Second
+
+var errInvalidReceiptWebhook = errors.New("receipt webhook must be an http or https URL")
+
+// processReceiptWebhook checks that the receipt webhook, if one was provided,
+// is an absolute http or https URL.
+func processReceiptWebhook(webhook string) (string, error) {
+	return processWebhook(webhook, errInvalidReceiptWebhook)
+}
+
+// receiptWebhook returns an upload receipt handler that POSTs each receipt as
+// JSON to the provided URL. Receipts are sent in the background so that the
+// renter's repair loop is not blocked. Receipts that could not be delivered
+// can still be fetched from /renter/receipts.
+func receiptWebhook(webhook string) func(modules.RenterUploadReceipt) {
+	client := &http.Client{Timeout: receiptWebhookTimeout}
+	return func(r modules.RenterUploadReceipt) {
+		go func() {
+			if err := postJSON(client, webhook, r); err != nil {
+				fmt.Fprintln(os.Stderr, "Could not deliver upload receipt for", r.SiaPath, "to webhook:", err)
+			}
+		}()
+	}
+}
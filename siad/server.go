@@ -15,10 +15,12 @@ import (
 	"path/filepath"
 	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/NebulousLabs/Sia/api"
 	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 
 	"github.com/inconshreveable/go-update"
@@ -63,6 +65,20 @@ type (
 	DaemonVersion struct {
 		Version string `json:"version"`
 	}
+	// DaemonAlerts lists the most recent alerts raised by siad.
+	DaemonAlerts struct {
+		Alerts []build.Alert `json:"alerts"`
+	}
+	// DaemonBandwidth contains the bandwidth limits shared by the renter and
+	// the host, and the traffic of each bandwidth class.
+	DaemonBandwidth struct {
+		modules.BandwidthLimits
+		Classes []modules.BandwidthClassStats `json:"classes"`
+	}
+	// DaemonFeatures lists the feature flags registered by siad's modules.
+	DaemonFeatures struct {
+		Features []modules.FeatureFlag `json:"features"`
+	}
 	// UpdateInfo indicates whether an update is available, and to what
 	// version.
 	UpdateInfo struct {
@@ -329,6 +345,85 @@ func (srv *Server) daemonConstantsHandler(w http.ResponseWriter, _ *http.Request
 	api.WriteJSON(w, sc)
 }
 
+// daemonAlertsHandler handles the API call that requests the most recent
+// alerts, which are raised by critical errors and recovered panics.
+func (srv *Server) daemonAlertsHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	api.WriteJSON(w, DaemonAlerts{Alerts: build.Alerts()})
+}
+
+// daemonBandwidthHandlerGET handles the API call that requests the bandwidth
+// limits shared by the renter and the host.
+func (srv *Server) daemonBandwidthHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	api.WriteJSON(w, DaemonBandwidth{
+		BandwidthLimits: modules.SharedBandwidthLimits(),
+		Classes:         modules.SharedBandwidthStats(),
+	})
+}
+
+// daemonBandwidthHandlerPOST handles the API call that changes the bandwidth
+// limits shared by the renter and the host. Limits that are not provided are
+// left unchanged.
+func (srv *Server) daemonBandwidthHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	bl := modules.SharedBandwidthLimits()
+	for _, param := range []struct {
+		name  string
+		speed *int64
+	}{
+		{"maxdownloadspeed", &bl.MaxDownloadSpeed},
+		{"maxuploadspeed", &bl.MaxUploadSpeed},
+	} {
+		if v := req.FormValue(param.name); v != "" {
+			speed, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				api.WriteError(w, api.Error{Message: "unable to parse " + param.name + ": " + err.Error()}, http.StatusBadRequest)
+				return
+			}
+			*param.speed = speed
+		}
+	}
+	err := modules.SetSharedBandwidthLimits(bl)
+	if err == modules.ErrNegativeBandwidthLimit {
+		api.WriteError(w, api.Error{Message: "error when calling /daemon/bandwidth: " + err.Error()}, http.StatusBadRequest)
+		return
+	} else if err != nil {
+		api.WriteError(w, api.Error{Message: "error when calling /daemon/bandwidth: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	api.WriteSuccess(w)
+}
+
+// daemonFeaturesHandlerGET handles the API call that lists the feature flags
+// and whether each is enabled.
+func (srv *Server) daemonFeaturesHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	api.WriteJSON(w, DaemonFeatures{Features: modules.FeatureFlags()})
+}
+
+// daemonFeaturesHandlerPOST handles the API call that overrides a feature
+// flag, or restores its default if enabled is empty.
+func (srv *Server) daemonFeaturesHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	name := req.FormValue("name")
+	var err error
+	switch req.FormValue("enabled") {
+	case "":
+		err = modules.ClearFeatureOverride(name)
+	case "true":
+		err = modules.SetFeatureOverride(name, true)
+	case "false":
+		err = modules.SetFeatureOverride(name, false)
+	default:
+		api.WriteError(w, api.Error{Message: "enabled must be 'true', 'false', or empty"}, http.StatusBadRequest)
+		return
+	}
+	if err == modules.ErrUnknownFeature {
+		api.WriteError(w, api.Error{Message: "error when calling /daemon/features: " + err.Error()}, http.StatusBadRequest)
+		return
+	} else if err != nil {
+		api.WriteError(w, api.Error{Message: "error when calling /daemon/features: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	api.WriteSuccess(w)
+}
+
 // daemonVersionHandler handles the API call that requests the daemon's version.
 func (srv *Server) daemonVersionHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
 	api.WriteJSON(w, DaemonVersion{Version: build.Version})
@@ -354,7 +449,12 @@ func (srv *Server) daemonStopHandler(w http.ResponseWriter, _ *http.Request, _ h
 func (srv *Server) daemonHandler(password string) http.Handler {
 	router := httprouter.New()
 
+	router.GET("/daemon/alerts", srv.daemonAlertsHandler)
+	router.GET("/daemon/bandwidth", srv.daemonBandwidthHandlerGET)
+	router.POST("/daemon/bandwidth", api.RequirePassword(srv.daemonBandwidthHandlerPOST, password))
 	router.GET("/daemon/constants", srv.daemonConstantsHandler)
+	router.GET("/daemon/features", srv.daemonFeaturesHandlerGET)
+	router.POST("/daemon/features", api.RequirePassword(srv.daemonFeaturesHandlerPOST, password))
 	router.GET("/daemon/version", srv.daemonVersionHandler)
 	router.GET("/daemon/update", srv.daemonUpdateHandlerGET)
 	router.POST("/daemon/update", srv.daemonUpdateHandlerPOST)
@@ -384,7 +484,7 @@ func NewServer(bindAddr, requiredUserAgent, requiredPassword string) (*Server, e
 	}
 
 	// Register siad routes
-	srv.mux.Handle("/daemon/", api.RequireUserAgent(srv.daemonHandler(requiredPassword), requiredUserAgent))
+	srv.mux.Handle("/daemon/", api.RecoverPanics(api.RequireUserAgent(srv.daemonHandler(requiredPassword), requiredUserAgent)))
 
 	return srv, nil
 }
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	// watchdogInterval is how often the watchdog checks the modules for
+	// progress.
+	watchdogInterval = build.Select(build.Var{
+		Standard: time.Minute,
+		Dev:      15 * time.Second,
+		Testing:  time.Second,
+	}).(time.Duration)
+
+	// watchdogStallTimeout is how long a module may go without making progress
+	// on pending work before the watchdog considers it stalled.
+	watchdogStallTimeout = build.Select(build.Var{
+		Standard: 30 * time.Minute,
+		Dev:      5 * time.Minute,
+		Testing:  10 * time.Second,
+	}).(time.Duration)
+
+	// watchdogProofGrace is the number of blocks past its proof deadline that
+	// a storage obligation may remain unresolved before the watchdog
+	// considers the host's action items stalled. The host resolves
+	// obligations at their proof deadline, so a few blocks are enough to
+	// absorb reorgs and slow block processing.
+	watchdogProofGrace = build.Select(build.Var{
+		Standard: types.BlockHeight(6),
+		Dev:      types.BlockHeight(3),
+		Testing:  types.BlockHeight(2),
+	}).(types.BlockHeight)
+)
+
+// progress records the last time that a piece of pending work advanced.
+type progress struct {
+	value   float64
+	changed time.Time
+}
+
+// A watchdog periodically checks that the modules of siad are making progress
+// on their pending work, and raises a critical alert with diagnostics when a
+// module appears to be stalled. Each stall is reported once; it is reported
+// again only after the module has recovered and stalled anew. Modules that
+// are not loaded are nil and are not checked.
+type watchdog struct {
+	cs     modules.ConsensusSet
+	host   modules.Host
+	renter modules.Renter
+
+	// alert is called with a description of each stall that is detected.
+	alert func(...interface{})
+
+	height    progress
+	downloads map[string]progress
+	uploads   map[string]progress
+
+	// stalled holds the stalls that have been reported and have not yet
+	// recovered, keyed by a description of the stalled work.
+	stalled map[string]bool
+}
+
+// newWatchdog returns a watchdog for the provided modules, any of which may be
+// nil. Stalls are reported with build.RecordStall.
+func newWatchdog(cs modules.ConsensusSet, h modules.Host, r modules.Renter) *watchdog {
+	return &watchdog{
+		cs:     cs,
+		host:   h,
+		renter: r,
+
+		alert: build.RecordStall,
+
+		downloads: make(map[string]progress),
+		uploads:   make(map[string]progress),
+		stalled:   make(map[string]bool),
+	}
+}
+
+// track updates the progress of a piece of work and returns how long it has
+// gone without advancing.
+func track(p progress, value float64, now time.Time) (progress, time.Duration) {
+	if p.changed.IsZero() || value != p.value {
+		return progress{value: value, changed: now}, 0
+	}
+	return p, now.Sub(p.changed)
+}
+
+// checkConsensus reports a stall if the consensus set is not synced, meaning
+// that peers have reported longer chains, but its height has not advanced.
+func (wd *watchdog) checkConsensus(now time.Time) map[string]string {
+	stalls := make(map[string]string)
+	height := wd.cs.Height()
+	var idle time.Duration
+	wd.height, idle = track(wd.height, float64(height), now)
+	if !wd.cs.Synced() && idle >= watchdogStallTimeout {
+		stalls["consensus"] = fmt.Sprintf("consensus: stuck at height %v for %v while peers report a longer chain", height, idle)
+	}
+	return stalls
+}
+
+// checkRenter reports downloads that have stopped receiving data and uploads
+// that have stopped making progress while the renter is not paused.
+func (wd *watchdog) checkRenter(now time.Time) map[string]string {
+	stalls := make(map[string]string)
+	downloads := make(map[string]progress)
+	for _, d := range wd.renter.DownloadQueue() {
+		if d.Error != "" || d.Received >= d.Length {
+			continue
+		}
+		key := d.SiaPath + " -> " + d.Destination + " @ " + d.StartTime.String()
+		p, idle := track(wd.downloads[key], float64(d.Received), now)
+		downloads[key] = p
+		if idle >= watchdogStallTimeout {
+			stalls["download "+key] = fmt.Sprintf("renter: download of %v to %v stuck at %v of %v bytes for %v", d.SiaPath, d.Destination, d.Received, d.Length, idle)
+		}
+	}
+	wd.downloads = downloads
+
+	uploads := make(map[string]progress)
+	paused := now.Before(wd.renter.PausedUntil())
+	for _, f := range wd.renter.FileList() {
+		if f.UploadProgress >= 100 {
+			continue
+		}
+		p, idle := track(wd.uploads[f.SiaPath], f.UploadProgress, now)
+		if paused {
+			// Progress is not expected while the renter is paused.
+			p.changed = now
+			idle = 0
+		}
+		uploads[f.SiaPath] = p
+		if idle >= watchdogStallTimeout {
+			stalls["upload "+f.SiaPath] = fmt.Sprintf("renter: upload of %v stuck at %.2f%% for %v", f.SiaPath, f.UploadProgress, idle)
+		}
+	}
+	wd.uploads = uploads
+	return stalls
+}
+
+// checkHost reports storage obligations that are still unresolved well after
+// their proof deadline, which means that the host's action items are not
+// being processed.
+func (wd *watchdog) checkHost() map[string]string {
+	stalls := make(map[string]string)
+	height := wd.cs.Height()
+	for _, so := range wd.host.StorageObligations() {
+		// An ObligationStatus of zero means that the obligation is unresolved.
+		if so.ObligationStatus != 0 || height < so.ProofDeadline+watchdogProofGrace {
+			continue
+		}
+		stalls["obligation "+so.ID.String()] = fmt.Sprintf("host: storage obligation %v unresolved at height %v, proof deadline was %v (proof constructed: %v, proof confirmed: %v)", so.ID, height, so.ProofDeadline, so.ProofConstructed, so.ProofConfirmed)
+	}
+	return stalls
+}
+
+// check checks every loaded module for stalls, and raises a single alert
+// describing the stalls that have not been reported yet.
+func (wd *watchdog) check(now time.Time) {
+	stalls := make(map[string]string)
+	merge := func(m map[string]string) {
+		for k, v := range m {
+			stalls[k] = v
+		}
+	}
+	if wd.cs != nil {
+		merge(wd.checkConsensus(now))
+	}
+	if wd.renter != nil {
+		merge(wd.checkRenter(now))
+	}
+	if wd.host != nil && wd.cs != nil {
+		merge(wd.checkHost())
+	}
+
+	var fresh []string
+	for k, v := range stalls {
+		if !wd.stalled[k] {
+			fresh = append(fresh, v)
+		}
+	}
+	wd.stalled = make(map[string]bool)
+	for k := range stalls {
+		wd.stalled[k] = true
+	}
+	if len(fresh) > 0 {
+		sort.Strings(fresh)
+		wd.alert(strings.Join(fresh, "\n"))
+	}
+}
+
+// threadedRun checks the modules every watchdogInterval until stop is closed.
+func (wd *watchdog) threadedRun(stop <-chan struct{}) {
+	ticker := time.NewTicker(watchdogInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			wd.check(now)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// watchdogCS, watchdogHost and watchdogRenter are stub modules that report
+// the fields set by the test.
+type (
+	watchdogCS struct {
+		modules.ConsensusSet
+		height types.BlockHeight
+		synced bool
+	}
+	watchdogHost struct {
+		modules.Host
+		sos []modules.StorageObligation
+	}
+	watchdogRenter struct {
+		modules.Renter
+		downloads []modules.DownloadInfo
+		files     []modules.FileInfo
+		paused    time.Time
+	}
+)
+
+func (cs *watchdogCS) Height() types.BlockHeight                        { return cs.height }
+func (cs *watchdogCS) Synced() bool                                     { return cs.synced }
+func (h *watchdogHost) StorageObligations() []modules.StorageObligation { return h.sos }
+func (r *watchdogRenter) DownloadQueue() []modules.DownloadInfo         { return r.downloads }
+func (r *watchdogRenter) FileList() []modules.FileInfo                  { return r.files }
+func (r *watchdogRenter) PausedUntil() time.Time                        { return r.paused }
+
+// TestWatchdog checks that the watchdog reports each stalled module once, and
+// does not report modules that are making progress.
+func TestWatchdog(t *testing.T) {
+	cs := &watchdogCS{height: 10}
+	h := &watchdogHost{}
+	r := &watchdogRenter{}
+	wd := newWatchdog(cs, h, r)
+	var alerts []string
+	wd.alert = func(v ...interface{}) {
+		alerts = append(alerts, fmt.Sprint(v...))
+	}
+
+	// Start a download and an upload that never progress, while the consensus
+	// set is behind its peers.
+	r.downloads = []modules.DownloadInfo{{SiaPath: "foo", Destination: "/tmp/foo", Filesize: 100, Length: 100, Received: 10}}
+	r.files = []modules.FileInfo{{SiaPath: "bar", UploadProgress: 50}}
+	now := time.Now()
+	wd.check(now)
+	if len(alerts) != 0 {
+		t.Fatal("work was reported as stalled before the stall timeout:", alerts)
+	}
+
+	// Only the upload progresses, and the renter stays paused, so only the
+	// consensus set and the download are stalled.
+	r.files[0].UploadProgress = 60
+	r.paused = now.Add(2 * watchdogStallTimeout)
+	now = now.Add(watchdogStallTimeout)
+	wd.check(now)
+	if len(alerts) != 1 {
+		t.Fatal("expected one alert, got", alerts)
+	}
+	if !strings.Contains(alerts[0], "consensus: stuck at height 10") || !strings.Contains(alerts[0], "download of foo") || strings.Contains(alerts[0], "upload") {
+		t.Fatal("wrong stalls reported:", alerts[0])
+	}
+
+	// The same stalls are not reported again, but a host obligation that is
+	// unresolved after its proof deadline is.
+	h.sos = []modules.StorageObligation{{ProofDeadline: cs.height - watchdogProofGrace}}
+	now = now.Add(time.Minute)
+	wd.check(now)
+	if len(alerts) != 2 || !strings.Contains(alerts[1], "host: storage obligation") || strings.Contains(alerts[1], "consensus") {
+		t.Fatal("wrong stalls reported:", alerts)
+	}
+
+	// Once the modules recover, nothing is reported. A stall that recurs after
+	// recovering is reported again.
+	cs.synced = true
+	r.downloads[0].Received = 100
+	h.sos[0].ObligationStatus = 2
+	now = now.Add(time.Minute)
+	wd.check(now)
+	cs.synced = false
+	cs.height++
+	now = now.Add(watchdogStallTimeout)
+	wd.check(now)
+	if len(alerts) != 2 {
+		t.Fatal("a recovered module was reported:", alerts[2:])
+	}
+	now = now.Add(watchdogStallTimeout)
+	wd.check(now)
+	if len(alerts) != 3 || !strings.Contains(alerts[2], "consensus: stuck at height 11") {
+		t.Fatal("a recurring stall was not reported:", alerts)
+	}
+}
@@ -367,12 +367,5 @@ func init() {
 	}
 
 	// Create the genesis block.
-	GenesisBlock = Block{
-		Timestamp: GenesisTimestamp,
-		Transactions: []Transaction{
-			{SiafundOutputs: GenesisSiafundAllocation},
-		},
-	}
-	// Calculate the genesis ID.
-	GenesisID = GenesisBlock.ID()
+	createGenesisBlock()
 }
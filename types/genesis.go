@@ -0,0 +1,89 @@
+package types
+
+// genesis.go allows the genesis block to be replaced at startup, so that
+// private networks can be run with the standard build of siad.
+
+import (
+	"errors"
+)
+
+// MaxNetworkIDLength is the maximum length of the ID of a private network.
+const MaxNetworkIDLength = 64
+
+var (
+	// NetworkID identifies the private network that the node is participating
+	// in. It is empty on the public Sia network. Peers only connect to each
+	// other if their network IDs match.
+	NetworkID string
+
+	// GenesisSiacoinAllocation is the set of siacoin outputs created by the
+	// genesis block. It is empty on the public Sia network.
+	GenesisSiacoinAllocation []SiacoinOutput
+
+	errEmptyNetworkID         = errors.New("private networks must have a network ID")
+	errNetworkIDTooLong       = errors.New("network ID is too long")
+	errZeroGenesisSiacoins    = errors.New("genesis siacoin allocations must be nonzero")
+	errWrongGenesisSiafundSum = errors.New("genesis siafund allocations must add up to the total number of siafunds")
+)
+
+// A GenesisConfig describes the genesis block of a private network.
+type GenesisConfig struct {
+	NetworkID         string          `json:"networkid"`
+	Timestamp         Timestamp       `json:"timestamp"`
+	SiacoinAllocation []SiacoinOutput `json:"siacoinallocation"`
+	SiafundAllocation []SiafundOutput `json:"siafundallocation"`
+}
+
+// Validate returns an error if the genesis config cannot be used to create a
+// private network.
+func (gc GenesisConfig) Validate() error {
+	if gc.NetworkID == "" {
+		return errEmptyNetworkID
+	}
+	if len(gc.NetworkID) > MaxNetworkIDLength {
+		return errNetworkIDTooLong
+	}
+	for _, sco := range gc.SiacoinAllocation {
+		if sco.Value.IsZero() {
+			return errZeroGenesisSiacoins
+		}
+	}
+	var siafunds Currency
+	for _, sfo := range gc.SiafundAllocation {
+		siafunds = siafunds.Add(sfo.Value)
+	}
+	if !siafunds.Equals(SiafundCount) {
+		return errWrongGenesisSiafundSum
+	}
+	return nil
+}
+
+// UseGenesis replaces the genesis block and network ID with the ones described
+// by the genesis config. It must be called before any modules are created.
+func UseGenesis(gc GenesisConfig) error {
+	if err := gc.Validate(); err != nil {
+		return err
+	}
+	NetworkID = gc.NetworkID
+	GenesisTimestamp = gc.Timestamp
+	GenesisSiacoinAllocation = gc.SiacoinAllocation
+	GenesisSiafundAllocation = gc.SiafundAllocation
+	createGenesisBlock()
+	return nil
+}
+
+// createGenesisBlock creates the genesis block from the genesis timestamp and
+// allocations.
+func createGenesisBlock() {
+	GenesisBlock = Block{
+		Timestamp: GenesisTimestamp,
+		Transactions: []Transaction{
+			{
+				SiacoinOutputs: GenesisSiacoinAllocation,
+				SiafundOutputs: GenesisSiafundAllocation,
+			},
+		},
+	}
+	// Calculate the genesis ID.
+	GenesisID = GenesisBlock.ID()
+}
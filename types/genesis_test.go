@@ -0,0 +1,94 @@
+package types
+
+import (
+	"testing"
+)
+
+// TestGenesisConfigValidate probes the Validate method of GenesisConfig.
+func TestGenesisConfigValidate(t *testing.T) {
+	valid := GenesisConfig{
+		NetworkID: "foo",
+		SiacoinAllocation: []SiacoinOutput{
+			{Value: NewCurrency64(1)},
+		},
+		SiafundAllocation: []SiafundOutput{
+			{Value: SiafundCount.Sub(NewCurrency64(1))},
+			{Value: NewCurrency64(1)},
+		},
+	}
+	if err := valid.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	gc := valid
+	gc.NetworkID = ""
+	if err := gc.Validate(); err != errEmptyNetworkID {
+		t.Error("expected errEmptyNetworkID, got", err)
+	}
+	gc.NetworkID = string(make([]byte, MaxNetworkIDLength+1))
+	if err := gc.Validate(); err != errNetworkIDTooLong {
+		t.Error("expected errNetworkIDTooLong, got", err)
+	}
+
+	gc = valid
+	gc.SiacoinAllocation = []SiacoinOutput{{}}
+	if err := gc.Validate(); err != errZeroGenesisSiacoins {
+		t.Error("expected errZeroGenesisSiacoins, got", err)
+	}
+
+	gc = valid
+	gc.SiafundAllocation = gc.SiafundAllocation[:1]
+	if err := gc.Validate(); err != errWrongGenesisSiafundSum {
+		t.Error("expected errWrongGenesisSiafundSum, got", err)
+	}
+}
+
+// TestUseGenesis checks that UseGenesis replaces the genesis block and that
+// the genesis siacoins are counted as part of the supply.
+func TestUseGenesis(t *testing.T) {
+	// Restore the genesis block of the testing network afterwards.
+	oldNetworkID, oldTimestamp := NetworkID, GenesisTimestamp
+	oldSiacoins, oldSiafunds := GenesisSiacoinAllocation, GenesisSiafundAllocation
+	defer func() {
+		NetworkID, GenesisTimestamp = oldNetworkID, oldTimestamp
+		GenesisSiacoinAllocation, GenesisSiafundAllocation = oldSiacoins, oldSiafunds
+		createGenesisBlock()
+	}()
+	oldID := GenesisID
+	oldSupply := CalculateNumSiacoins(10)
+
+	gc := GenesisConfig{
+		NetworkID: "foo",
+		Timestamp: GenesisTimestamp,
+		SiacoinAllocation: []SiacoinOutput{
+			{Value: SiacoinPrecision.Mul64(50)},
+		},
+		SiafundAllocation: []SiafundOutput{
+			{Value: SiafundCount},
+		},
+	}
+	if err := UseGenesis(gc); err != nil {
+		t.Fatal(err)
+	}
+	if NetworkID != "foo" {
+		t.Error("network ID was not set")
+	}
+	if GenesisID == oldID || GenesisID != GenesisBlock.ID() {
+		t.Error("genesis ID was not updated")
+	}
+	if len(GenesisBlock.Transactions[0].SiacoinOutputs) != 1 || len(GenesisBlock.Transactions[0].SiafundOutputs) != 1 {
+		t.Error("genesis block does not contain the allocations")
+	}
+	if !CalculateNumSiacoins(10).Equals(oldSupply.Add(SiacoinPrecision.Mul64(50))) {
+		t.Error("genesis siacoins were not added to the supply")
+	}
+
+	// An invalid config should not change the genesis block.
+	id := GenesisID
+	if err := UseGenesis(GenesisConfig{}); err == nil {
+		t.Error("expected an error")
+	}
+	if GenesisID != id {
+		t.Error("invalid config changed the genesis block")
+	}
+}